@@ -8,6 +8,7 @@ package api
 import (
 	"net/url"
 	"regexp"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -43,6 +44,34 @@ type Plugin struct {
 	LastError       string   `json:"last_error"`
 }
 
+// Health defines the agent health status
+type Health struct {
+	Status       string                 `json:"status"`
+	StaleSources []string               `json:"stale_sources,omitempty"`
+	Errors       map[string]HealthError `json:"errors"`
+}
+
+// HealthError defines the error history of a category (e.g. config, broker, collector, plugin)
+type HealthError struct {
+	Count     uint64    `json:"count"`
+	Last      time.Time `json:"last"`
+	Message   string    `json:"message"`
+	Retryable bool      `json:"retryable"`
+}
+
+// Collectors defines list of builtin collectors
+type Collectors []Collector
+
+// Collector defines a builtin collector
+type Collector struct {
+	ID              string `json:"name"`
+	Enabled         bool   `json:"enabled"`
+	LastRunStart    string `json:"last_run_start"`
+	LastRunEnd      string `json:"last_run_end"`
+	LastRunDuration string `json:"last_run_duration"`
+	LastError       string `json:"last_error"`
+}
+
 // New creates a new circonus-agent api client
 func New(agentURL string) (*Client, error) {
 	if agentURL == "" {
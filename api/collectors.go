@@ -0,0 +1,71 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Collectors retrieves the builtin collectors from the agent
+func (c *Client) Collectors() (*Collectors, error) {
+	data, err := c.get("/collectors/")
+	if err != nil {
+		return nil, err
+	}
+
+	var v Collectors
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, errors.Wrap(err, "parsing collectors")
+	}
+
+	return &v, nil
+}
+
+// SetCollector enables or disables a builtin collector in the running agent,
+// the agent only accepts the change from the local host
+func (c *Client) SetCollector(id string, enabled bool) error {
+	if id == "" {
+		return errors.New("invalid collector id (empty)")
+	}
+
+	state := "disable"
+	if enabled {
+		state = "enable"
+	}
+
+	au, err := c.agentURL.Parse("/collectors/" + id + "/" + state)
+	if err != nil {
+		return errors.Wrap(err, "creating request url")
+	}
+
+	client := &http.Client{}
+	req, err := http.NewRequest("PUT", au.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "preparing request")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "request")
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading response")
+	}
+
+	return errors.Errorf("%s - %s - %s", resp.Status, au.String(), strings.TrimSpace(string(data)))
+}
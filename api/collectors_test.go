@@ -0,0 +1,96 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCollectors(t *testing.T) {
+	t.Log("Testing Collectors")
+
+	tests := []struct {
+		name        string
+		response    string
+		shouldErr   bool
+		expectedErr string
+	}{
+		{"invalid (json/parse)", "invalid", true, "parsing collectors: invalid character 'i' looking for beginning of value"},
+		{"valid", `[{"name":"cpu","enabled":true}]`, false, ""},
+	}
+
+	for _, test := range tests {
+		resp := test.response
+		t.Log("\t", test.name)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(resp))
+		}))
+
+		c, err := New(ts.URL)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+
+		_, err = c.Collectors()
+
+		if test.shouldErr {
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if err.Error() != test.expectedErr {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+		} else if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+
+		ts.Close()
+	}
+}
+
+func TestSetCollector(t *testing.T) {
+	t.Log("Testing SetCollector")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Fatalf("expected PUT, got %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/collectors/cpu/disable", "/collectors/cpu/enable":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unknown builtin", http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	t.Log("\tinvalid (id)")
+	if err := c.SetCollector("", false); err == nil {
+		t.Fatal("expected error")
+	}
+
+	t.Log("\tdisable")
+	if err := c.SetCollector("cpu", false); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	t.Log("\tenable")
+	if err := c.SetCollector("cpu", true); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	t.Log("\tunknown")
+	if err := c.SetCollector("foo", true); err == nil {
+		t.Fatal("expected error")
+	}
+}
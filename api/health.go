@@ -0,0 +1,53 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Health retrieves the health status and recent errors from the agent,
+// a degraded agent (503) is not an error
+func (c *Client) Health() (*Health, error) {
+	au, err := c.agentURL.Parse("/health")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request url")
+	}
+
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", au.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request")
+	}
+
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response")
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, errors.Errorf("%s - %s - %s", resp.Status, au.String(), strings.TrimSpace(string(data)))
+	}
+
+	var v Health
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, errors.Wrap(err, "parsing health")
+	}
+
+	return &v, nil
+}
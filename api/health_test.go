@@ -0,0 +1,65 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	t.Log("Testing Health")
+
+	tests := []struct {
+		name        string
+		status      int
+		response    string
+		shouldErr   bool
+		expectedErr string
+	}{
+		{"invalid (json/parse)", http.StatusOK, "invalid", true, "parsing health: invalid character 'i' looking for beginning of value"},
+		{"alive", http.StatusOK, `{"status":"alive","errors":{"plugin":{"count":1,"message":"failed"}}}`, false, ""},
+		{"degraded", http.StatusServiceUnavailable, `{"status":"degraded","stale_sources":["statsd"],"errors":{}}`, false, ""},
+	}
+
+	for _, test := range tests {
+		tst := test
+		t.Log("\t", tst.name)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") != "application/json" {
+				t.Fatalf("expected json accept header, got (%s)", r.Header.Get("Accept"))
+			}
+			w.WriteHeader(tst.status)
+			_, _ = w.Write([]byte(tst.response))
+		}))
+
+		c, err := New(ts.URL)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+
+		h, err := c.Health()
+
+		if tst.shouldErr {
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if err.Error() != tst.expectedErr {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("expected no error, got (%s)", err)
+			}
+			if h.Status == "" {
+				t.Fatal("expected status")
+			}
+		}
+
+		ts.Close()
+	}
+}
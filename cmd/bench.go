@@ -0,0 +1,80 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/bench"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchCfg  bench.Config
+	benchJSON bool
+)
+
+// benchCmd generates synthetic load against a running agent
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Generate synthetic load against a running agent",
+	Long: `Synthesizes statsd traffic and collector series (/write) against a
+running agent and reports throughput, drops and latency. Use to size
+hosts and validate tuning changes.
+
+NOTE: use an agent which is not being polled by a broker when verifying,
+metrics flushed by /run requests from the broker will be counted as drops.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt)
+		defer signal.Stop(sigCh)
+		go func() {
+			select {
+			case <-sigCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		res, err := bench.Run(ctx, benchCfg)
+		if err != nil {
+			return errors.Wrap(err, "bench")
+		}
+
+		if benchJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(res)
+		}
+
+		res.Report(os.Stdout)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(benchCmd)
+
+	flags := benchCmd.Flags()
+	flags.StringVar(&benchCfg.AgentURL, "agent-url", "http://127.0.0.1:2609", "Agent URL")
+	flags.StringVar(&benchCfg.StatsdAddr, "statsd-addr", "127.0.0.1:8125", "Agent StatsD UDP address")
+	flags.DurationVar(&benchCfg.Duration, "duration", 10*time.Second, "Duration to generate load")
+	flags.IntVar(&benchCfg.StatsdRate, "statsd-rate", 1000, "StatsD packets per second (0 to disable)")
+	flags.IntVar(&benchCfg.StatsdNames, "statsd-names", 100, "Number of unique StatsD metric names")
+	flags.IntVar(&benchCfg.Series, "series", 1000, "Collector series per /write request (0 to disable)")
+	flags.DurationVar(&benchCfg.Interval, "interval", time.Second, "Interval between /write requests")
+	flags.StringVar(&benchCfg.Prefix, "prefix", "bench.", "Metric name prefix")
+	flags.BoolVar(&benchCfg.Verify, "verify", true, "Read metrics back from the agent (/run/statsd, /run/write) to count drops")
+	flags.BoolVar(&benchJSON, "json", false, "Output results as JSON")
+}
@@ -0,0 +1,174 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/api"
+	"github.com/circonus-labs/circonus-agent/internal/agentctl"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ctlAgentURL string
+	ctlJSON     bool
+	ctlFollow   bool
+	ctlInterval time.Duration
+)
+
+// ctlCmd groups the commands which talk to a running agent
+var ctlCmd = &cobra.Command{
+	Use:   "ctl",
+	Short: "Inspect and control a running agent",
+	Long: `Talks to a running agent through its local API to show live status,
+trigger runs, enable or disable builtin collectors and tail recent errors.
+
+NOTE: builtin collectors can only be enabled or disabled from the local
+host (loopback or unix socket), the change is not persisted - restarting
+the agent restores the configured collectors.`,
+}
+
+var ctlStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show agent health, builtin collectors and plugins",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := api.New(ctlAgentURL)
+		if err != nil {
+			return errors.Wrap(err, "ctl status")
+		}
+		s, err := agentctl.GetStatus(c)
+		if err != nil {
+			return errors.Wrap(err, "ctl status")
+		}
+		if ctlJSON {
+			return ctlEncode(s)
+		}
+		s.Report(os.Stdout)
+		return nil
+	},
+}
+
+var ctlRunCmd = &cobra.Command{
+	Use:   "run [id]",
+	Short: "Trigger a run (all, or one builtin/plugin) and show the metrics",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := api.New(ctlAgentURL)
+		if err != nil {
+			return errors.Wrap(err, "ctl run")
+		}
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		m, err := c.Metrics(id)
+		if err != nil {
+			return errors.Wrap(err, "ctl run")
+		}
+		if ctlJSON {
+			return ctlEncode(m)
+		}
+		names := make([]string, 0, len(*m))
+		for name := range *m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			metric := (*m)[name]
+			fmt.Printf("%s %s %v\n", name, metric.Type, metric.Value)
+		}
+		fmt.Printf("%d metrics\n", len(names))
+		return nil
+	},
+}
+
+var ctlEnableCmd = &cobra.Command{
+	Use:   "enable <id>",
+	Short: "Enable a builtin collector in the running agent",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ctlSetCollector(args[0], true)
+	},
+}
+
+var ctlDisableCmd = &cobra.Command{
+	Use:   "disable <id>",
+	Short: "Disable a builtin collector in the running agent",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ctlSetCollector(args[0], false)
+	},
+}
+
+var ctlErrorsCmd = &cobra.Command{
+	Use:   "errors",
+	Short: "Show recent errors (error categories, builtins and plugins)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := api.New(ctlAgentURL)
+		if err != nil {
+			return errors.Wrap(err, "ctl errors")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt)
+		defer signal.Stop(sigCh)
+		go func() {
+			select {
+			case <-sigCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		return agentctl.TailErrors(ctx, os.Stdout, c, ctlFollow, ctlInterval)
+	},
+}
+
+func ctlSetCollector(id string, enabled bool) error {
+	c, err := api.New(ctlAgentURL)
+	if err != nil {
+		return errors.Wrap(err, "ctl")
+	}
+	if err := c.SetCollector(id, enabled); err != nil {
+		return errors.Wrapf(err, "ctl %s", id)
+	}
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	fmt.Printf("%s %s\n", id, state)
+	return nil
+}
+
+func ctlEncode(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func init() {
+	RootCmd.AddCommand(ctlCmd)
+	ctlCmd.AddCommand(ctlStatusCmd, ctlRunCmd, ctlEnableCmd, ctlDisableCmd, ctlErrorsCmd)
+
+	ctlCmd.PersistentFlags().StringVar(&ctlAgentURL, "agent-url", "http://127.0.0.1:2609", "Agent URL")
+	ctlStatusCmd.Flags().BoolVar(&ctlJSON, "json", false, "Output status as JSON")
+	ctlRunCmd.Flags().BoolVar(&ctlJSON, "json", false, "Output metrics as JSON")
+	ctlErrorsCmd.Flags().BoolVarP(&ctlFollow, "follow", "f", false, "Poll the agent and show new errors until interrupted")
+	ctlErrorsCmd.Flags().DurationVar(&ctlInterval, "interval", 5*time.Second, "Polling interval when following")
+}
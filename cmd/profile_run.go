@@ -0,0 +1,96 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/plugins"
+	"github.com/circonus-labs/circonus-agent/internal/runprofile"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileRunSort string
+	profileRunJSON bool
+)
+
+// profileRunCmd runs each collector and plugin once and reports their cost
+var profileRunCmd = &cobra.Command{
+	Use:   "profile-run",
+	Short: "Run each collector and plugin once and report their cost",
+	Long: `Loads the builtin collectors and plugins from the configuration (file
+and environment), runs each one at a time and reports the wall and cpu
+time, allocations, number of metrics and output size of each. Use to
+find, and disable, expensive collectors and plugins.
+
+NOTE: allocations and the cpu time of builtins are measured for the
+whole agent process, background collectors (e.g. statsd, syslog) add
+noise. The cpu time of plugins is that of the plugin process.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := runprofile.Sort(nil, profileRunSort); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt)
+		defer signal.Stop(sigCh)
+		go func() {
+			select {
+			case <-sigCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if _, err := config.ApplyProfile(log.Logger); err != nil {
+			return errors.Wrap(err, "profile-run")
+		}
+
+		b, err := builtins.New(ctx)
+		if err != nil {
+			return errors.Wrap(err, "profile-run builtins")
+		}
+		p, err := plugins.New(ctx, defaults.PluginPath)
+		if err != nil {
+			return errors.Wrap(err, "profile-run plugins")
+		}
+		if err := p.Load(b); err != nil {
+			return errors.Wrap(err, "profile-run plugins")
+		}
+
+		timings := runprofile.Run(ctx, b, p)
+		_ = runprofile.Sort(timings, profileRunSort)
+
+		if profileRunJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(timings)
+		}
+
+		runprofile.Report(os.Stdout, timings)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(profileRunCmd)
+
+	flags := profileRunCmd.Flags()
+	flags.StringVar(&profileRunSort, "sort", runprofile.SortWall, "Sort report by (wall|cpu|alloc|metrics|bytes), descending")
+	flags.BoolVar(&profileRunJSON, "json", false, "Output results as JSON")
+}
@@ -8,6 +8,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	stdlog "log"
 	"os"
 	"runtime"
@@ -16,6 +17,7 @@ import (
 	"github.com/circonus-labs/circonus-agent/internal/agent"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/logging"
 	"github.com/circonus-labs/circonus-agent/internal/release"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -24,7 +26,10 @@ import (
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	logFile *logging.RotatingFile
+)
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -127,6 +132,40 @@ func init() {
 		}
 	}
 
+	{
+		const (
+			key         = config.KeyListenACLFile
+			longOpt     = "listen-acl-file"
+			envVar      = release.ENVPREFIX + "_LISTEN_ACL_FILE"
+			description = "Listener access control file (JSON, per-listener allow/deny lists and auth token)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyHooksFile
+			longOpt     = "hooks-file"
+			envVar      = release.ENVPREFIX + "_HOOKS_FILE"
+			description = "Local threshold hooks file (JSON, rules evaluated on collected metrics, running commands/writing files/logging on breach)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
 	{
 		var (
 			key         = config.KeyCollectors
@@ -244,32 +283,33 @@ func init() {
 
 	{
 		const (
-			key         = config.KeyListenSocket
-			longOpt     = "listen-socket"
-			shortOpt    = "L"
-			envVar      = release.ENVPREFIX + "_LISTEN_SOCKET"
-			description = "Unix socket to create"
+			key          = config.KeyWMIHostProcess
+			longOpt      = "wmi-host-process"
+			envVar       = release.ENVPREFIX + "_WMI_HOST_PROCESS"
+			defaultValue = defaults.WMIHostProcess
+			description  = "Windows containers, collect from the host with the wmi builtins when running as a HostProcess container"
 		)
 
-		RootCmd.Flags().StringSliceP(longOpt, shortOpt, []string{}, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key      = config.KeyPluginDir
-			longOpt  = "plugin-dir"
-			shortOpt = "p"
-			envVar   = release.ENVPREFIX + "_PLUGIN_DIR"
+			key         = config.KeyListenSocket
+			longOpt     = "listen-socket"
+			shortOpt    = "L"
+			envVar      = release.ENVPREFIX + "_LISTEN_SOCKET"
+			description = "Unix socket to create"
 		)
-		description := "Plugin directory (" + defaults.PluginPath + ")"
 
-		RootCmd.Flags().StringP(longOpt, shortOpt, "", desc(description, envVar))
+		RootCmd.Flags().StringSliceP(longOpt, shortOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
@@ -280,187 +320,180 @@ func init() {
 
 	{
 		const (
-			key         = config.KeyPluginList
-			longOpt     = "plugin-list"
-			envVar      = release.ENVPREFIX + "_PLUGIN_LIST"
-			description = "List of explicit plugin commands to run"
+			key          = config.KeyListenSocketAPI
+			longOpt      = "listen-socket-api"
+			envVar       = release.ENVPREFIX + "_LISTEN_SOCKET_API"
+			description  = "Serve the full local API on unix socket(s), not only /write"
+			defaultValue = defaults.ListenSocketAPI
 		)
 
-		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyPluginTTLUnits
-			longOpt     = "plugin-ttl-units"
-			envVar      = release.ENVPREFIX + "_PLUGIN_TTL_UNITS"
-			description = "Default plugin TTL units"
+			key         = config.KeyListenSocketMode
+			longOpt     = "listen-socket-mode"
+			envVar      = release.ENVPREFIX + "_LISTEN_SOCKET_MODE"
+			description = "Octal permissions for unix socket(s) e.g. 0660 (default: as created, subject to umask)"
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.PluginTTLUnits, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.PluginTTLUnits)
 	}
 
-	//
-	// Reverse mode
-	//
 	{
 		const (
-			key         = config.KeyReverse
-			longOpt     = "reverse"
-			shortOpt    = "r"
-			envVar      = release.ENVPREFIX + "_REVERSE"
-			description = "Enable reverse connection"
+			key          = config.KeyListenSocketOnly
+			longOpt      = "listen-socket-only"
+			envVar       = release.ENVPREFIX + "_LISTEN_SOCKET_ONLY"
+			description  = "Only listen on unix socket(s), disable tcp listener(s) (not compatible with --reverse)"
+			defaultValue = defaults.ListenSocketOnly
 		)
 
-		RootCmd.Flags().BoolP(longOpt, shortOpt, defaults.Reverse, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.Reverse)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key          = config.KeyReverseBrokerCAFile
-			longOpt      = "reverse-broker-ca-file"
-			defaultValue = ""
-			envVar       = release.ENVPREFIX + "_REVERSE_BROKER_CA_FILE"
-			description  = "Broker CA certificate file"
+			key          = config.KeyStatusUI
+			longOpt      = "status-ui"
+			envVar       = release.ENVPREFIX + "_STATUS_UI"
+			description  = "Serve a status page (/ui) showing collector status, last flush, reverse state and recent errors"
+			defaultValue = defaults.StatusUI
 		)
 
-		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key          = config.KeyReverseMaxConnRetry
-			longOpt      = "reverse-max-conn-retry"
-			defaultValue = defaults.ReverseMaxConnRetry
-			envVar       = release.ENVPREFIX + "_REVERSE_MAX_CONN_RETRY"
-			description  = "Max attempts to retry persistently failing reverse connection to broker [-1=indefinitely]"
+			key         = config.KeyCORSOrigins
+			longOpt     = "cors-origin"
+			envVar      = release.ENVPREFIX + "_CORS_ORIGIN"
+			description = "Origin allowed to make cross-origin GET requests to the local api, e.g. https://dashboard.example.com (* for any)"
 		)
 
-		RootCmd.Flags().Int(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaultValue)
 	}
 
-	//
-	// Check
-	//
 	{
 		const (
-			key          = config.KeyCheckBundleID
-			longOpt      = "check-id"
-			defaultValue = ""
-			shortOpt     = "I"
-			envVar       = release.ENVPREFIX + "_CHECK_ID"
-			description  = "Check Bundle ID or 'cosi' for cosi system check (for reverse and auto enable new metrics)"
+			key          = config.KeyK8sNodeMode
+			longOpt      = "k8s-node-mode"
+			envVar       = release.ENVPREFIX + "_K8S_NODE_MODE"
+			description  = "Run as a Kubernetes DaemonSet, node name and tags from the downward API (NODE_NAME, POD_NAMESPACE), host paths under --k8s-host-root"
+			defaultValue = defaults.K8sNodeMode
 		)
 
-		RootCmd.Flags().StringP(longOpt, shortOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
-		const (
-			key         = config.KeyCheckCreate
-			longOpt     = "check-create"
-			shortOpt    = "C"
-			envVar      = release.ENVPREFIX + "_CHECK_CREATE"
-			description = "Create check bundle (for reverse and auto enable new metrics)"
+		var (
+			key         = config.KeyK8sNodeLabels
+			longOpt     = "k8s-node-labels"
+			envVar      = release.ENVPREFIX + "_K8S_NODE_LABELS"
+			description = "Node labels added as base tags in k8s node mode (requires get on nodes for the service account)"
 		)
 
-		RootCmd.Flags().BoolP(longOpt, shortOpt, defaults.CheckCreate, desc(description, envVar))
+		RootCmd.Flags().StringSlice(longOpt, defaults.K8sNodeLabels, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.CheckCreate)
+		viper.SetDefault(key, defaults.K8sNodeLabels)
 	}
 
 	{
 		const (
-			key         = config.KeyCheckUpdate
-			longOpt     = "check-update"
-			shortOpt    = "U"
-			envVar      = release.ENVPREFIX + "_CHECK_UPDATE"
-			description = "Force check bundle update at start (with all configurable check bundle attributes)"
+			key          = config.KeyK8sHostRoot
+			longOpt      = "k8s-host-root"
+			envVar       = release.ENVPREFIX + "_K8S_HOST_ROOT"
+			description  = "Mount point of the host's root filesystem in k8s node mode (e.g. /host/proc, /host/sys)"
+			defaultValue = defaults.K8sHostRoot
 		)
 
-		RootCmd.Flags().BoolP(longOpt, shortOpt, defaults.CheckCreate, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.CheckCreate)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyCheckTarget
-			longOpt     = "check-target"
-			shortOpt    = "T"
-			envVar      = release.ENVPREFIX + "_CHECK_TARGET"
-			description = "Check target host (for creating a new check)"
+			key          = config.KeyLocalMode
+			longOpt      = "local-mode"
+			envVar       = release.ENVPREFIX + "_LOCAL_MODE"
+			description  = "Run without any Circonus API interaction, disables check management, reverse and statsd group check"
+			defaultValue = defaults.LocalMode
 		)
 
-		RootCmd.Flags().StringP(longOpt, shortOpt, defaults.CheckTarget, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.CheckTarget)
-
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyCheckTitle
-			longOpt     = "check-title"
-			envVar      = release.ENVPREFIX + "_CHECK_TITLE"
-			description = "Title [display name] to use, if creating a check bundle (default \"<check-target> /agent\")"
+			key         = config.KeyMaintenanceWindows
+			longOpt     = "maintenance-window"
+			envVar      = release.ENVPREFIX + "_MAINTENANCE_WINDOW"
+			description = "Scheduled window pausing builtins/plugins (cron|duration|selectors, e.g. '0 2 * * 6|3h|disk diskstats backup*', env: ; separated), a maintenance gauge is emitted"
 		)
 
-		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		// not a StringSlice, cron schedules contain commas (lists)
+		RootCmd.Flags().StringArray(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
@@ -471,66 +504,62 @@ func init() {
 
 	{
 		const (
-			key         = config.KeyCheckBroker
-			longOpt     = "check-broker"
-			envVar      = release.ENVPREFIX + "_CHECK_BROKER"
-			description = "ID of Broker to use or 'select' for random selection of valid broker, if creating a check bundle"
+			key         = config.KeyMetricAggregate
+			longOpt     = "metric-aggregate"
+			envVar      = release.ENVPREFIX + "_METRIC_AGGREGATE"
+			description = "Per builtin aggregation of per-instance metrics (id:category[:functions], e.g. cpu:cpu:avg|max), instances are replaced by sum|avg|min|max|count"
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.CheckBroker, desc(description, envVar))
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.CheckBroker)
 	}
 
 	{
 		const (
-			key         = config.KeyCheckTags
-			longOpt     = "check-tags"
-			envVar      = release.ENVPREFIX + "_CHECK_TAGS"
-			description = "Tags [comma separated list] to use, if creating a check bundle"
+			key         = config.KeyMetricTopK
+			longOpt     = "metric-topk"
+			envVar      = release.ENVPREFIX + "_METRIC_TOPK"
+			description = "Per builtin top K instance selection (id:category:k:metric, e.g. processes:process-name:10:PercentProcessorTime), other instances are summed"
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.CheckTags, desc(description, envVar))
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.CheckTags)
 	}
 
 	{
 		const (
-			key         = config.KeyCheckMetricStreamtags
-			shortOpt    = "S"
-			longOpt     = "check-metric-streamtags"
-			envVar      = release.ENVPREFIX + "_CHECK_METRIC_STREAMTAGS"
-			description = "Add check tags to metrics as stream tags"
+			key         = config.KeyOutputTags
+			longOpt     = "output-tags"
+			envVar      = release.ENVPREFIX + "_OUTPUT_TAGS"
+			description = "Per output metric name prefix and stream tags (output:prefix:value, output:add:cat:val, output:remove:cat), outputs: check, prometheus"
 		)
 
-		RootCmd.Flags().BoolP(longOpt, shortOpt, false, desc(description, envVar))
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, false)
 	}
 
 	{
 		const (
-			key          = config.KeyCheckMetricFilters
-			longOpt      = "check-metric-filters"
-			defaultValue = ""
-			envVar       = release.ENVPREFIX + "_CHECK_METRIC_FILTERS"
-			description  = "List of filters used to manage which metrics are collected"
+			key          = config.KeyMetricMerge
+			longOpt      = "metric-merge"
+			envVar       = release.ENVPREFIX + "_METRIC_MERGE"
+			description  = "Handling of a metric (same name and tags) emitted more than once within a flush, by multiple sources or clients (last|sum|reject)"
+			defaultValue = defaults.MetricMerge
 		)
 
 		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
@@ -540,52 +569,56 @@ func init() {
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
+
 	{
 		const (
-			key         = config.KeyCheckMetricFilterFile
-			longOpt     = "check-metric-filter-file"
-			envVar      = release.ENVPREFIX + "_CHECK_METRIC_FILTER_FILE"
-			description = "JSON file with metric filters"
+			key          = config.KeyMaxPendingSeries
+			longOpt      = "max-pending-series"
+			envVar       = release.ENVPREFIX + "_MAX_PENDING_SERIES"
+			description  = "Maximum distinct series statsd and the receiver each accumulate between flushes, new series beyond the limit are dropped (0=no limit)"
+			defaultValue = defaults.MaxPendingSeries
 		)
-		defaultValue := defaults.CheckMetricFilterFile
 
-		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().Uint(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
+
 	{
 		const (
-			key         = config.KeyCheckUpdateMetricFilters
-			longOpt     = "check-update-metric-filters"
-			envVar      = release.ENVPREFIX + "_CHECK_UPDATE_METRIC_FILTERS"
-			description = "Update check bundle with configured metric filters when agent starts"
+			key          = config.KeyStaleSourceAge
+			longOpt      = "stale-source-age"
+			envVar       = release.ENVPREFIX + "_STALE_SOURCE_AGE"
+			description  = "Emit source_age_seconds per source, a source is stale when it has not produced metrics for this long (e.g. 5m) [0=disabled]"
+			defaultValue = defaults.StaleSourceAge
 		)
-		defaultValue := defaults.CheckUpdateMetricFilters
 
-		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyCheckPeriod
-			longOpt     = "check-period"
-			envVar      = release.ENVPREFIX + "_CHECK_PERIOD"
-			description = "When broker requests metrics [10-300] seconds"
+			key         = config.KeyStaleSources
+			longOpt     = "stale-sources"
+			envVar      = release.ENVPREFIX + "_STALE_SOURCES"
+			description = "Mandatory sources (builtins|plugins|receiver|statsd|prometheus|otlp|graphite), /health is degraded when any is stale"
 		)
-		defaultValue := defaults.CheckPeriod
 
-		RootCmd.Flags().Uint(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
@@ -593,16 +626,16 @@ func init() {
 			bindEnvError(envVar, err)
 		}
 	}
+
 	{
 		const (
-			key         = config.KeyCheckTimeout
-			longOpt     = "check-timeout"
-			envVar      = release.ENVPREFIX + "_CHECK_TIMEOUT"
-			description = "Timeout when broker requests metrics [0-300] seconds"
+			key         = config.KeyProxyTargets
+			longOpt     = "proxy-target"
+			envVar      = release.ENVPREFIX + "_PROXY_TARGET"
+			description = "Local exporter served through /proxy/<name> (name=url, prometheus text format) e.g. node=http://localhost:9100/metrics"
 		)
-		defaultValue := defaults.CheckTimeout
 
-		RootCmd.Flags().Float64(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
@@ -611,18 +644,15 @@ func init() {
 		}
 	}
 
-	//
-	// API
-	//
 	{
 		const (
-			key          = config.KeyAPITokenKey
-			longOpt      = "api-key"
-			defaultValue = ""
-			envVar       = release.ENVPREFIX + "_API_KEY"
-			description  = "Circonus API Token key"
+			key         = config.KeyMetricTTL
+			longOpt     = "metric-ttl"
+			envVar      = release.ENVPREFIX + "_METRIC_TTL"
+			description = "Per source metric TTL (source:duration, e.g. plugins:10m), series not reported within the TTL are retired"
 		)
-		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
@@ -633,47 +663,49 @@ func init() {
 
 	{
 		const (
-			key         = config.KeyAPITokenApp
-			longOpt     = "api-app"
-			envVar      = release.ENVPREFIX + "_API_APP"
-			description = "Circonus API Token app"
+			key          = config.KeyMetricTombstones
+			longOpt      = "metric-tombstones"
+			envVar       = release.ENVPREFIX + "_METRIC_TOMBSTONES"
+			description  = "Emit a tombstone (null value) once for each series retired by the metric TTL"
+			defaultValue = defaults.MetricTombstones
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.APIApp, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.APIApp)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyAPIURL
-			longOpt     = "api-url"
-			envVar      = release.ENVPREFIX + "_API_URL"
-			description = "Circonus API URL"
+			key          = config.KeyTextMetricResend
+			longOpt      = "text-metric-resend"
+			envVar       = release.ENVPREFIX + "_TEXT_METRIC_RESEND"
+			description  = "Submit text metrics only when their value changes, or at least once per interval (e.g. 10m) [0=every flush]"
+			defaultValue = defaults.TextMetricResend
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.APIURL, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.APIURL)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key          = config.KeyAPICAFile
-			longOpt      = "api-ca-file"
-			defaultValue = ""
-			envVar       = release.ENVPREFIX + "_API_CA_FILE"
-			description  = "Circonus API CA certificate file"
+			key          = config.KeyWriteDedupTTL
+			longOpt      = "write-dedup-ttl"
+			envVar       = release.ENVPREFIX + "_WRITE_DEDUP_TTL"
+			description  = "How long /write idempotency keys (Idempotency-Key header) are held, a retried write with the same key is not applied again [0=keys ignored]"
+			defaultValue = defaults.WriteDedupTTL
 		)
 
 		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
@@ -683,320 +715,1756 @@ func init() {
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaultValue)
 	}
 
-	//
-	// SSL
-	//
 	{
 		const (
-			key          = config.KeySSLListen
-			longOpt      = "ssl-listen"
-			defaultValue = ""
-			envVar       = release.ENVPREFIX + "_SSL_LISTEN"
-			description  = "SSL listen address and port [IP]:[PORT] - setting enables SSL"
+			key         = config.KeyPluginBundleInterval
+			longOpt     = "plugin-bundle-interval"
+			envVar      = release.ENVPREFIX + "_PLUGIN_BUNDLE_INTERVAL"
+			description = "How often to check for an updated plugin bundle [0=only when the agent starts]"
 		)
 
-		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaults.PluginBundleInterval, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaults.PluginBundleInterval)
 	}
 
 	{
 		const (
-			key         = config.KeySSLCertFile
-			longOpt     = "ssl-cert-file"
-			envVar      = release.ENVPREFIX + "_SSL_CERT_FILE"
-			description = "SSL Certificate file (PEM cert and CAs concatenated together)"
+			key         = config.KeyPluginBundlePublicKey
+			longOpt     = "plugin-bundle-public-key"
+			envVar      = release.ENVPREFIX + "_PLUGIN_BUNDLE_PUBLIC_KEY"
+			description = "Ed25519 public key (base64) used to verify the plugin bundle signature"
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.SSLCertFile, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.SSLCertFile)
 	}
 
 	{
 		const (
-			key         = config.KeySSLKeyFile
-			longOpt     = "ssl-key-file"
-			envVar      = release.ENVPREFIX + "_SSL_KEY_FILE"
-			description = "SSL Key file"
+			key         = config.KeyPluginBundleRole
+			longOpt     = "plugin-bundle-role"
+			envVar      = release.ENVPREFIX + "_PLUGIN_BUNDLE_ROLE"
+			description = "Host role, replaces {role} in the plugin bundle URL (default: applied profile name)"
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.SSLKeyFile, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.SSLKeyFile)
 	}
 
 	{
 		const (
-			key         = config.KeySSLVerify
-			longOpt     = "ssl-verify"
-			envVar      = release.ENVPREFIX + "_SSL_VERIFY"
-			description = "Enable SSL verification"
+			key         = config.KeyPluginBundleURL
+			longOpt     = "plugin-bundle-url"
+			envVar      = release.ENVPREFIX + "_PLUGIN_BUNDLE_URL"
+			description = "URL (https or s3) of a signed plugin bundle (tar.gz) to install in the plugin directory"
 		)
 
-		RootCmd.Flags().Bool(longOpt, defaults.SSLVerify, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.SSLVerify)
+	}
+
+	{
+		const (
+			key      = config.KeyPluginDir
+			longOpt  = "plugin-dir"
+			shortOpt = "p"
+			envVar   = release.ENVPREFIX + "_PLUGIN_DIR"
+		)
+		description := "Plugin directory (" + defaults.PluginPath + ")"
+
+		RootCmd.Flags().StringP(longOpt, shortOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyPluginList
+			longOpt     = "plugin-list"
+			envVar      = release.ENVPREFIX + "_PLUGIN_LIST"
+			description = "List of explicit plugin commands to run"
+		)
+
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyPluginMaxOutputBytes
+			longOpt     = "plugin-max-output-bytes"
+			envVar      = release.ENVPREFIX + "_PLUGIN_MAX_OUTPUT_BYTES"
+			description = "Max plugin output size in bytes (per run, or per batch for long running plugins), larger output terminates the plugin [0=unlimited]"
+		)
+
+		RootCmd.Flags().Int(longOpt, defaults.PluginMaxOutputBytes, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.PluginMaxOutputBytes)
+	}
+
+	{
+		const (
+			key         = config.KeyPluginMaxMemoryBytes
+			longOpt     = "plugin-max-memory-bytes"
+			envVar      = release.ENVPREFIX + "_PLUGIN_MAX_MEMORY_BYTES"
+			description = "Windows, max committed memory in bytes of a plugin's process tree (job object), allocations beyond the limit fail [0=unlimited]"
+		)
+
+		RootCmd.Flags().Int64(longOpt, defaults.PluginMaxMemoryBytes, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.PluginMaxMemoryBytes)
+	}
+
+	{
+		const (
+			key         = config.KeyPluginMaxCPUPercent
+			longOpt     = "plugin-max-cpu-percent"
+			envVar      = release.ENVPREFIX + "_PLUGIN_MAX_CPU_PERCENT"
+			description = "Windows, max cpu rate of a plugin's process tree (job object) as a percent of all cpus [0=unlimited]"
+		)
+
+		RootCmd.Flags().Int(longOpt, defaults.PluginMaxCPUPercent, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.PluginMaxCPUPercent)
+	}
+
+	{
+		const (
+			key          = config.KeyPluginTimeout
+			longOpt      = "plugin-timeout"
+			envVar       = release.ENVPREFIX + "_PLUGIN_TIMEOUT"
+			description  = "Plugin runs taking longer are terminated (e.g. 30s) [0=no timeout]"
+			defaultValue = defaults.PluginTimeout
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key         = config.KeyPluginTTLUnits
+			longOpt     = "plugin-ttl-units"
+			envVar      = release.ENVPREFIX + "_PLUGIN_TTL_UNITS"
+			description = "Default plugin TTL units"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.PluginTTLUnits, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.PluginTTLUnits)
+	}
+
+	{
+		const (
+			key         = config.KeyProfile
+			longOpt     = "profile"
+			envVar      = release.ENVPREFIX + "_PROFILE"
+			description = "Name of configuration profile to apply (default: first profile matching host)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key          = config.KeyRunDeltaEncoding
+			longOpt      = "run-delta-encoding"
+			envVar       = release.ENVPREFIX + "_RUN_DELTA_ENCODING"
+			description  = "Delta encoded /run responses (metric name dictionary, changes versus a prior response) for clients negotiating them"
+			defaultValue = defaults.RunDeltaEncoding
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key          = config.KeyRunMaxResponseBytes
+			longOpt      = "run-max-response-bytes"
+			envVar       = release.ENVPREFIX + "_RUN_MAX_RESPONSE_BYTES"
+			description  = "Max /run response size in bytes (uncompressed), larger responses are paginated with continuation tokens [0=disabled]"
+			defaultValue = defaults.RunMaxResponseBytes
+		)
+
+		RootCmd.Flags().Int(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key         = config.KeyRuntimeGOGC
+			longOpt     = "runtime-gogc"
+			envVar      = release.ENVPREFIX + "_RUNTIME_GOGC"
+			description = "Garbage collection target percentage, as GOGC (e.g. 200 trades memory for fewer collections) [0=runtime default]"
+		)
+
+		RootCmd.Flags().Int(longOpt, defaults.RuntimeGOGC, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.RuntimeGOGC)
+	}
+
+	{
+		const (
+			key         = config.KeyRuntimeMemoryLimit
+			longOpt     = "runtime-memory-limit"
+			envVar      = release.ENVPREFIX + "_RUNTIME_MEMORY_LIMIT"
+			description = "Runtime soft memory limit, as GOMEMLIMIT (e.g. 1GiB)"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.RuntimeMemoryLimit, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.RuntimeMemoryLimit)
+	}
+
+	{
+		const (
+			key         = config.KeyRuntimeBallast
+			longOpt     = "runtime-ballast"
+			envVar      = release.ENVPREFIX + "_RUNTIME_BALLAST"
+			description = "Heap ballast size (e.g. 256MiB), reduces GC frequency for very large metric sets"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.RuntimeBallast, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.RuntimeBallast)
 	}
 
 	//
-	// StatsD
+	// Reverse mode
 	//
 	{
 		const (
-			key         = config.KeyStatsdDisabled
-			longOpt     = "no-statsd"
-			envVar      = release.ENVPREFIX + "_NO_STATSD"
-			description = "Disable StatsD listener"
+			key         = config.KeyReverse
+			longOpt     = "reverse"
+			shortOpt    = "r"
+			envVar      = release.ENVPREFIX + "_REVERSE"
+			description = "Enable reverse connection"
 		)
 
-		RootCmd.Flags().Bool(longOpt, defaults.NoStatsd, desc(description, envVar))
+		RootCmd.Flags().BoolP(longOpt, shortOpt, defaults.Reverse, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.Reverse)
+	}
+
+	{
+		const (
+			key          = config.KeyReverseAdminKeyFile
+			longOpt      = "reverse-admin-key-file"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_REVERSE_ADMIN_KEY_FILE"
+			description  = "Public key file (ed25519, PEM) verifying administrative commands received over the reverse connection [empty=disabled]"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key          = config.KeyReverseBrokerCAFile
+			longOpt      = "reverse-broker-ca-file"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_REVERSE_BROKER_CA_FILE"
+			description  = "Broker CA certificate file"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key          = config.KeyReverseBrokerCARefresh
+			longOpt      = "reverse-broker-ca-refresh"
+			defaultValue = defaults.ReverseBrokerCARefresh
+			envVar       = release.ENVPREFIX + "_REVERSE_BROKER_CA_REFRESH"
+			description  = "How often to refresh the Broker CA certificate, reverse connections are re-established if it changed [0=disabled]"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key          = config.KeyReverseDialPolicy
+			longOpt      = "reverse-dial-policy"
+			defaultValue = defaults.ReverseDialPolicy
+			envVar       = release.ENVPREFIX + "_REVERSE_DIAL_POLICY"
+			description  = "Address family policy when connecting to broker (any|ipv4|ipv6|prefer-ipv4|prefer-ipv6)"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key          = config.KeyReverseMaxConnRetry
+			longOpt      = "reverse-max-conn-retry"
+			defaultValue = defaults.ReverseMaxConnRetry
+			envVar       = release.ENVPREFIX + "_REVERSE_MAX_CONN_RETRY"
+			description  = "Max attempts to retry persistently failing reverse connection to broker [-1=indefinitely]"
+		)
+
+		RootCmd.Flags().Int(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	//
+	// Check
+	//
+	{
+		const (
+			key          = config.KeyCheckBundleID
+			longOpt      = "check-id"
+			defaultValue = ""
+			shortOpt     = "I"
+			envVar       = release.ENVPREFIX + "_CHECK_ID"
+			description  = "Check Bundle ID or 'cosi' for cosi system check (for reverse and auto enable new metrics)"
+		)
+
+		RootCmd.Flags().StringP(longOpt, shortOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckCreate
+			longOpt     = "check-create"
+			shortOpt    = "C"
+			envVar      = release.ENVPREFIX + "_CHECK_CREATE"
+			description = "Create check bundle (for reverse and auto enable new metrics)"
+		)
+
+		RootCmd.Flags().BoolP(longOpt, shortOpt, defaults.CheckCreate, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.CheckCreate)
+	}
+
+	{
+		const (
+			key         = config.KeyCheckUpdate
+			longOpt     = "check-update"
+			shortOpt    = "U"
+			envVar      = release.ENVPREFIX + "_CHECK_UPDATE"
+			description = "Force check bundle update at start (with all configurable check bundle attributes)"
+		)
+
+		RootCmd.Flags().BoolP(longOpt, shortOpt, defaults.CheckCreate, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.CheckCreate)
+	}
+
+	{
+		const (
+			key         = config.KeyCheckTarget
+			longOpt     = "check-target"
+			shortOpt    = "T"
+			envVar      = release.ENVPREFIX + "_CHECK_TARGET"
+			description = "Check target host (for creating a new check)"
+		)
+
+		RootCmd.Flags().StringP(longOpt, shortOpt, defaults.CheckTarget, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.CheckTarget)
+
+	}
+
+	{
+		const (
+			key         = config.KeyCheckTargetStrategy
+			longOpt     = "check-target-strategy"
+			envVar      = release.ENVPREFIX + "_CHECK_TARGET_STRATEGY"
+			description = "Strategies to derive check target from hostname, comma separated, applied in order (hostname|fqdn|strip-domain|lowercase)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckTargetTemplate
+			longOpt     = "check-target-template"
+			envVar      = release.ENVPREFIX + "_CHECK_TARGET_TEMPLATE"
+			description = "Template to derive check target (e.g. '{{lower .ShortName}}.example.com', fields: Hostname, FQDN, ShortName)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckTitle
+			longOpt     = "check-title"
+			envVar      = release.ENVPREFIX + "_CHECK_TITLE"
+			description = "Title [display name] template to use, if creating/updating a check bundle (e.g. '{{.ShortName}} {{.Role}} /agent', fields: Hostname, ShortName, Target, Role, Tags, Version, InstanceID) (default \"<check-target> /agent\")"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckNotes
+			longOpt     = "check-notes"
+			envVar      = release.ENVPREFIX + "_CHECK_NOTES"
+			description = "Notes template to use, if creating/updating a check bundle (same fields as --check-title)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckBroker
+			longOpt     = "check-broker"
+			envVar      = release.ENVPREFIX + "_CHECK_BROKER"
+			description = "ID of Broker to use or 'select' for random selection of valid broker, if creating a check bundle"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.CheckBroker, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.CheckBroker)
+	}
+
+	{
+		const (
+			key         = config.KeyCheckBrokerTags
+			longOpt     = "check-broker-tags"
+			envVar      = release.ENVPREFIX + "_CHECK_BROKER_TAGS"
+			description = "Broker tags allow-list [comma separated list, glob patterns e.g. region:us-east*], if selecting a broker for a check bundle"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckBrokerMaxLatency
+			longOpt     = "check-broker-max-latency"
+			envVar      = release.ENVPREFIX + "_CHECK_BROKER_MAX_LATENCY"
+			description = "Max connect latency (e.g. 250ms), if selecting a broker for a check bundle [0=no limit]"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.CheckBrokerMaxLatency, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.CheckBrokerMaxLatency)
+	}
+
+	{
+		const (
+			key         = config.KeyCheckBrokerHosts
+			longOpt     = "check-broker-host"
+			envVar      = release.ENVPREFIX + "_CHECK_BROKER_HOST"
+			description = "Static broker host address override, host=ip (e.g. broker.example.com=10.1.2.3), used instead of resolving the broker host"
+		)
+
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckBrokerDNSServers
+			longOpt     = "check-broker-dns-server"
+			envVar      = release.ENVPREFIX + "_CHECK_BROKER_DNS_SERVER"
+			description = "DNS server, ip[:port] (default port 53), used to resolve broker hosts instead of the system resolver"
+		)
+
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckRefreshInterval
+			longOpt     = "check-refresh-interval"
+			envVar      = release.ENVPREFIX + "_CHECK_REFRESH_INTERVAL"
+			description = "How often to refresh the check and broker configurations from the API (min 1m)"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.CheckRefreshInterval, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.CheckRefreshInterval)
+	}
+
+	{
+		const (
+			key         = config.KeyCheckRoutes
+			longOpt     = "check-routes"
+			envVar      = release.ENVPREFIX + "_CHECK_ROUTES"
+			description = "Route matching metrics to other existing check bundles (BUNDLE_ID:name:REGEX or BUNDLE_ID:tag:CATEGORY:REGEX), first matching rule wins"
+		)
+
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckTags
+			longOpt     = "check-tags"
+			envVar      = release.ENVPREFIX + "_CHECK_TAGS"
+			description = "Tags [comma separated list] to use, if creating a check bundle"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.CheckTags, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.CheckTags)
+	}
+
+	{
+		const (
+			key         = config.KeyCheckMetricStreamtags
+			shortOpt    = "S"
+			longOpt     = "check-metric-streamtags"
+			envVar      = release.ENVPREFIX + "_CHECK_METRIC_STREAMTAGS"
+			description = "Add check tags to metrics as stream tags"
+		)
+
+		RootCmd.Flags().BoolP(longOpt, shortOpt, false, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, false)
+	}
+
+	{
+		const (
+			key          = config.KeyCheckMetricFilters
+			longOpt      = "check-metric-filters"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_CHECK_METRIC_FILTERS"
+			description  = "List of filters used to manage which metrics are collected"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+	{
+		const (
+			key         = config.KeyCheckMetricFilterFile
+			longOpt     = "check-metric-filter-file"
+			envVar      = release.ENVPREFIX + "_CHECK_METRIC_FILTER_FILE"
+			description = "JSON file with metric filters"
+		)
+		defaultValue := defaults.CheckMetricFilterFile
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+	{
+		const (
+			key         = config.KeyCheckUpdateMetricFilters
+			longOpt     = "check-update-metric-filters"
+			envVar      = release.ENVPREFIX + "_CHECK_UPDATE_METRIC_FILTERS"
+			description = "Update check bundle with configured metric filters when agent starts"
+		)
+		defaultValue := defaults.CheckUpdateMetricFilters
+
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCheckPeriod
+			longOpt     = "check-period"
+			envVar      = release.ENVPREFIX + "_CHECK_PERIOD"
+			description = "When broker requests metrics [10-300] seconds"
+		)
+		defaultValue := defaults.CheckPeriod
+
+		RootCmd.Flags().Uint(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+	{
+		const (
+			key         = config.KeyCheckTimeout
+			longOpt     = "check-timeout"
+			envVar      = release.ENVPREFIX + "_CHECK_TIMEOUT"
+			description = "Timeout when broker requests metrics [0-300] seconds"
+		)
+		defaultValue := defaults.CheckTimeout
+
+		RootCmd.Flags().Float64(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	//
+	// API
+	//
+	{
+		const (
+			key          = config.KeyAPITokenKey
+			longOpt      = "api-key"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_API_KEY"
+			description  = "Circonus API Token key"
+		)
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyAPITokenApp
+			longOpt     = "api-app"
+			envVar      = release.ENVPREFIX + "_API_APP"
+			description = "Circonus API Token app"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.APIApp, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.APIApp)
+	}
+
+	{
+		const (
+			key         = config.KeyAPIURL
+			longOpt     = "api-url"
+			envVar      = release.ENVPREFIX + "_API_URL"
+			description = "Circonus API URL"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.APIURL, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.APIURL)
+	}
+
+	{
+		const (
+			key          = config.KeyAPIMaxRetries
+			longOpt      = "api-max-retries"
+			envVar       = release.ENVPREFIX + "_API_MAX_RETRIES"
+			description  = "Number of times to retry failed Circonus API calls (exponential backoff, rate limit aware)"
+			defaultValue = defaults.APIMaxRetries
+		)
+
+		RootCmd.Flags().Int(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key         = config.KeyStateDir
+			longOpt     = "state-dir"
+			envVar      = release.ENVPREFIX + "_STATE_DIR"
+			description = "Directory for persisted state (audit, heartbeat, counters, metric states, plugin bundle), kept in memory when not writable"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyCacheDir
+			longOpt     = "cache-dir"
+			envVar      = release.ENVPREFIX + "_CACHE_DIR"
+			description = "Directory for cached Circonus API results, kept in memory when not writable"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeySpoolDir
+			longOpt     = "spool-dir"
+			envVar      = release.ENVPREFIX + "_SPOOL_DIR"
+			description = "Directory for archived flushes (with --flush-archive-count/max-age), kept in memory when not writable"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyAPICacheDir
+			longOpt     = "api-cache-dir"
+			envVar      = release.ENVPREFIX + "_API_CACHE_DIR"
+			description = "Directory to cache Circonus API results (check, bundle, broker) for use during API outages [empty=disabled]"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.APICacheDir, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.APICacheDir)
+	}
+
+	{
+		const (
+			key          = config.KeyAPICAFile
+			longOpt      = "api-ca-file"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_API_CA_FILE"
+			description  = "Circonus API CA certificate file"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	//
+	// SSL
+	//
+	{
+		const (
+			key          = config.KeySSLListen
+			longOpt      = "ssl-listen"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_SSL_LISTEN"
+			description  = "SSL listen address and port [IP]:[PORT] - setting enables SSL"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeySSLCertFile
+			longOpt     = "ssl-cert-file"
+			envVar      = release.ENVPREFIX + "_SSL_CERT_FILE"
+			description = "SSL Certificate file (PEM cert and CAs concatenated together)"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.SSLCertFile, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.SSLCertFile)
+	}
+
+	{
+		const (
+			key         = config.KeySSLKeyFile
+			longOpt     = "ssl-key-file"
+			envVar      = release.ENVPREFIX + "_SSL_KEY_FILE"
+			description = "SSL Key file"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.SSLKeyFile, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.SSLKeyFile)
+	}
+
+	{
+		const (
+			key         = config.KeySSLVerify
+			longOpt     = "ssl-verify"
+			envVar      = release.ENVPREFIX + "_SSL_VERIFY"
+			description = "Enable SSL verification"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.SSLVerify, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.SSLVerify)
+	}
+
+	//
+	// StatsD
+	//
+	{
+		const (
+			key         = config.KeyStatsdDisabled
+			longOpt     = "no-statsd"
+			envVar      = release.ENVPREFIX + "_NO_STATSD"
+			description = "Disable StatsD listener"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.NoStatsd, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.NoStatsd)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdAddr
+			longOpt     = "statsd-addr"
+			envVar      = release.ENVPREFIX + "_STATSD_ADDR"
+			description = "StatsD address to listen on"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdAddr, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdAddr)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdPort
+			longOpt     = "statsd-port"
+			envVar      = release.ENVPREFIX + "_STATSD_PORT"
+			description = "StatsD port to listen on"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdPort, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdPort)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdHostPrefix
+			longOpt     = "statsd-host-prefix"
+			envVar      = release.ENVPREFIX + "_STATSD_HOST_PREFIX"
+			description = "StatsD host metric prefix"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdHostPrefix, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdHostPrefix)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdHostCategory
+			longOpt     = "statsd-host-category"
+			envVar      = release.ENVPREFIX + "_STATSD_HOST_CATEGORY"
+			description = "StatsD host metric category"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdHostCategory, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdHostCategory)
+	}
+
+	{
+		const (
+			key          = config.KeyStatsdGroupCID
+			longOpt      = "statsd-group-cid"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_STATSD_GROUP_CID"
+			description  = "StatsD group check bundle ID"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdGroupCreate
+			longOpt     = "statsd-group-create"
+			envVar      = release.ENVPREFIX + "_STATSD_GROUP_CREATE"
+			description = "Find or create the StatsD group check (by target), if a group check bundle ID is not supplied"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.StatsdGroupCreate, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdGroupCreate)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdGroupTarget
+			longOpt     = "statsd-group-target"
+			envVar      = release.ENVPREFIX + "_STATSD_GROUP_TARGET"
+			description = "StatsD group check target, shared by the agents in a group, if creating the group check"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdGroupTarget, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdGroupTarget)
+	}
+
+	{
+		const (
+			key          = config.KeyStatsdGroupTags
+			longOpt      = "statsd-group-tags"
+			defaultValue = ""
+			envVar       = release.ENVPREFIX + "_STATSD_GROUP_TAGS"
+			description  = "Tags [comma separated list] to use, if creating the StatsD group check"
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdGroupBroker
+			longOpt     = "statsd-group-broker"
+			envVar      = release.ENVPREFIX + "_STATSD_GROUP_BROKER"
+			description = "ID of Broker to use or 'select' for random selection of valid broker, if creating the StatsD group check"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdGroupBroker, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdGroupBroker)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdGroupPrefix
+			longOpt     = "statsd-group-prefix"
+			envVar      = release.ENVPREFIX + "_STATSD_GROUP_PREFIX"
+			description = "StatsD group metric prefix"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdGroupPrefix, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdGroupPrefix)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdGroupCounters
+			longOpt     = "statsd-group-counters"
+			envVar      = release.ENVPREFIX + "_STATSD_GROUP_COUNTERS"
+			description = "StatsD group metric counter handling (average|sum)"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdGroupCounters, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdGroupCounters)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdGroupGauges
+			longOpt     = "statsd-group-gauges"
+			envVar      = release.ENVPREFIX + "_STATSD_GROUP_GAUGES"
+			description = "StatsD group gauge operator"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdGroupGauges, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdGroupGauges)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdGroupSets
+			longOpt     = "statsd-group-sets"
+			envVar      = release.ENVPREFIX + "_STATSD_GROPUP_SETS"
+			description = "StatsD group set operator"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.StatsdGroupSets, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdGroupSets)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdEnableTCP
+			longOpt     = "statsd-enable-tcp"
+			envVar      = release.ENVPREFIX + "_STATSD_ENABLE_TCP"
+			description = "Enable StatsD TCP listener"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.StatsdEnableTCP, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdEnableTCP)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdMaxTCPConns
+			longOpt     = "statsd-max-tcp-connections"
+			envVar      = release.ENVPREFIX + "_STATSD_MAX_TCP_CONNS"
+			description = "StatsD maximum TCP connections"
+		)
+
+		RootCmd.Flags().Uint(longOpt, defaults.StatsdMaxTCPConns, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.StatsdMaxTCPConns)
+	}
+
+	{
+		const (
+			key         = config.KeyStatsdTCPPort
+			longOpt     = "statsd-tcp-port"
+			envVar      = release.ENVPREFIX + "_STATSD_TCP_PORT"
+			description = "StatsD TCP listener port (default the StatsD port)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key          = config.KeyStatsdTCPFraming
+			longOpt      = "statsd-tcp-framing"
+			envVar       = release.ENVPREFIX + "_STATSD_TCP_FRAMING"
+			description  = "StatsD TCP client framing, newline terminated metrics or length (4 byte, big endian) prefixed frames (newline|length)"
+			defaultValue = defaults.StatsdTCPFraming
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key          = config.KeyStatsdTCPReadTimeout
+			longOpt      = "statsd-tcp-read-timeout"
+			envVar       = release.ENVPREFIX + "_STATSD_TCP_READ_TIMEOUT"
+			description  = "StatsD TCP connections idle for longer are closed (0=no timeout)"
+			defaultValue = defaults.StatsdTCPReadTimeout
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key          = config.KeyStatsdQueueSize
+			longOpt      = "statsd-queue-size"
+			envVar       = release.ENVPREFIX + "_STATSD_QUEUE_SIZE"
+			description  = "StatsD received packets queued for processing"
+			defaultValue = defaults.StatsdQueueSize
+		)
+
+		RootCmd.Flags().Uint(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key          = config.KeyStatsdQueuePolicy
+			longOpt      = "statsd-queue-policy"
+			envVar       = release.ENVPREFIX + "_STATSD_QUEUE_POLICY"
+			description  = "StatsD handling of received packets when the queue is full (pushback|drop-oldest)"
+			defaultValue = defaults.StatsdQueuePolicy
+		)
+
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	// Graphite listener
+
+	{
+		const (
+			key         = config.KeyGraphiteAddr
+			longOpt     = "graphite-addr"
+			envVar      = release.ENVPREFIX + "_GRAPHITE_ADDR"
+			description = "Graphite plaintext protocol (tcp) listener address:port, e.g. :2003 (default disabled)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyGraphiteMapping
+			longOpt     = "graphite-mapping"
+			envVar      = release.ENVPREFIX + "_GRAPHITE_MAPPING"
+			description = `Graphite metric path mapping rules (pattern name [cat:val ...], e.g. "servers.*.cpu cpu host:$1")`
+		)
+
+		RootCmd.Flags().StringSlice(longOpt, []string{}, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	// OTLP receiver
+
+	{
+		const (
+			key         = config.KeyOTLPAddr
+			longOpt     = "otlp-addr"
+			envVar      = release.ENVPREFIX + "_OTLP_ADDR"
+			description = "OTLP (OpenTelemetry) metrics receiver address:port, e.g. :4318 (default disabled)"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyOTLPCertFile
+			longOpt     = "otlp-cert-file"
+			envVar      = release.ENVPREFIX + "_OTLP_CERT_FILE"
+			description = "OTLP receiver TLS certificate file (PEM cert), required for OTLP/gRPC"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyOTLPKeyFile
+			longOpt     = "otlp-key-file"
+			envVar      = release.ENVPREFIX + "_OTLP_KEY_FILE"
+			description = "OTLP receiver TLS key file"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.NoStatsd)
 	}
 
+	// Miscellenous
+
 	{
 		const (
-			key         = config.KeyStatsdAddr
-			longOpt     = "statsd-addr"
-			envVar      = release.ENVPREFIX + "_STATSD_ADDR"
-			description = "StatsD address to listen on"
+			key         = config.KeyDisableGzip
+			longOpt     = "no-gzip"
+			envVar      = release.ENVPREFIX + "_NO_GZIP"
+			description = "Disable gzip HTTP responses"
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdAddr, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaults.DisableGzip, description)
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdAddr)
+		viper.SetDefault(key, defaults.DisableGzip)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdPort
-			longOpt     = "statsd-port"
-			envVar      = release.ENVPREFIX + "_STATSD_PORT"
-			description = "StatsD port to listen on"
+			key          = config.KeyAuditConfig
+			longOpt      = "audit-config"
+			envVar       = release.ENVPREFIX + "_AUDIT_CONFIG"
+			description  = "Emit configuration and plugin change tracking metrics (changes between agent starts)"
+			defaultValue = defaults.AuditConfig
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdPort, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdPort)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdHostPrefix
-			longOpt     = "statsd-host-prefix"
-			envVar      = release.ENVPREFIX + "_STATSD_HOST_PREFIX"
-			description = "StatsD host metric prefix"
+			key         = config.KeyAuditStateFile
+			longOpt     = "audit-state-file"
+			envVar      = release.ENVPREFIX + "_AUDIT_STATE_FILE"
+			description = "Configuration audit state file (must be writeable by user running agent)"
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdHostPrefix, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaults.AuditStateFile, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdHostPrefix)
+		viper.SetDefault(key, defaults.AuditStateFile)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdHostCategory
-			longOpt     = "statsd-host-category"
-			envVar      = release.ENVPREFIX + "_STATSD_HOST_CATEGORY"
-			description = "StatsD host metric category"
+			key          = config.KeyHeartbeat
+			longOpt      = "heartbeat"
+			envVar       = release.ENVPREFIX + "_HEARTBEAT"
+			description  = "Emit heartbeat metrics (flush sequence, timestamp and restart count) with each full run"
+			defaultValue = defaults.Heartbeat
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdHostCategory, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdHostCategory)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key          = config.KeyStatsdGroupCID
-			longOpt      = "statsd-group-cid"
-			defaultValue = ""
-			envVar       = release.ENVPREFIX + "_STATSD_GROUP_CID"
-			description  = "StatsD group check bundle ID"
+			key         = config.KeyHeartbeatStateFile
+			longOpt     = "heartbeat-state-file"
+			envVar      = release.ENVPREFIX + "_HEARTBEAT_STATE_FILE"
+			description = "Heartbeat state file, restart count (must be writeable by user running agent)"
 		)
 
-		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaults.HeartbeatStateFile, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
+		viper.SetDefault(key, defaults.HeartbeatStateFile)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdGroupPrefix
-			longOpt     = "statsd-group-prefix"
-			envVar      = release.ENVPREFIX + "_STATSD_GROUP_PREFIX"
-			description = "StatsD group metric prefix"
+			key          = config.KeyWatchdog
+			longOpt      = "watchdog"
+			envVar       = release.ENVPREFIX + "_WATCHDOG"
+			description  = "Watchdog, sample goroutine counts and detect stuck builtin collector and plugin runs (stacks dumped to the log)"
+			defaultValue = defaults.Watchdog
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdGroupPrefix, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdGroupPrefix)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdGroupCounters
-			longOpt     = "statsd-group-counters"
-			envVar      = release.ENVPREFIX + "_STATSD_GROUP_COUNTERS"
-			description = "StatsD group metric counter handling (average|sum)"
+			key          = config.KeyWatchdogInterval
+			longOpt      = "watchdog-interval"
+			envVar       = release.ENVPREFIX + "_WATCHDOG_INTERVAL"
+			description  = "Watchdog check interval"
+			defaultValue = defaults.WatchdogInterval
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdGroupCounters, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdGroupCounters)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdGroupGauges
-			longOpt     = "statsd-group-gauges"
-			envVar      = release.ENVPREFIX + "_STATSD_GROUP_GAUGES"
-			description = "StatsD group gauge operator"
+			key          = config.KeyWatchdogCollectorTimeout
+			longOpt      = "watchdog-collector-timeout"
+			envVar       = release.ENVPREFIX + "_WATCHDOG_COLLECTOR_TIMEOUT"
+			description  = "Watchdog, expected maximum builtin collector run time (plugins use --plugin-timeout)"
+			defaultValue = defaults.WatchdogCollectorTimeout
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdGroupGauges, desc(description, envVar))
+		RootCmd.Flags().String(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdGroupGauges)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdGroupSets
-			longOpt     = "statsd-group-sets"
-			envVar      = release.ENVPREFIX + "_STATSD_GROPUP_SETS"
-			description = "StatsD group set operator"
+			key          = config.KeyWatchdogStuckFactor
+			longOpt      = "watchdog-stuck-factor"
+			envVar       = release.ENVPREFIX + "_WATCHDOG_STUCK_FACTOR"
+			description  = "Watchdog, runs are stuck when running longer than factor times their timeout"
+			defaultValue = defaults.WatchdogStuckFactor
 		)
 
-		RootCmd.Flags().String(longOpt, defaults.StatsdGroupSets, desc(description, envVar))
+		RootCmd.Flags().Int(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdGroupSets)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdEnableTCP
-			longOpt     = "statsd-enable-tcp"
-			envVar      = release.ENVPREFIX + "_STATSD_ENABLE_TCP"
-			description = "Enable StatsD TCP listener"
+			key          = config.KeyWatchdogRestart
+			longOpt      = "watchdog-restart"
+			envVar       = release.ENVPREFIX + "_WATCHDOG_RESTART"
+			description  = "Watchdog, restart the subsystem of a stuck run (abandon the builtin collector run, kill the plugin)"
+			defaultValue = defaults.WatchdogRestart
 		)
 
-		RootCmd.Flags().Bool(longOpt, defaults.StatsdEnableTCP, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdEnableTCP)
+		viper.SetDefault(key, defaultValue)
 	}
 
 	{
 		const (
-			key         = config.KeyStatsdMaxTCPConns
-			longOpt     = "statsd-max-tcp-connections"
-			envVar      = release.ENVPREFIX + "_STATSD_MAX_TCP_CONNS"
-			description = "StatsD maximum TCP connections"
+			key          = config.KeyCounterState
+			longOpt      = "counter-state"
+			envVar       = release.ENVPREFIX + "_COUNTER_STATE"
+			description  = "Persist counter state (StatsD counters not yet flushed) across agent restarts"
+			defaultValue = defaults.CounterState
 		)
 
-		RootCmd.Flags().Uint(longOpt, defaults.StatsdMaxTCPConns, desc(description, envVar))
+		RootCmd.Flags().Bool(longOpt, defaultValue, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.StatsdMaxTCPConns)
+		viper.SetDefault(key, defaultValue)
 	}
 
-	// Miscellenous
-
 	{
 		const (
-			key         = config.KeyDisableGzip
-			longOpt     = "no-gzip"
-			envVar      = release.ENVPREFIX + "_NO_GZIP"
-			description = "Disable gzip HTTP responses"
+			key         = config.KeyCounterStateFile
+			longOpt     = "counter-state-file"
+			envVar      = release.ENVPREFIX + "_COUNTER_STATE_FILE"
+			description = "Counter state file (must be writeable by user running agent)"
 		)
 
-		RootCmd.Flags().Bool(longOpt, defaults.DisableGzip, description)
+		RootCmd.Flags().String(longOpt, defaults.CounterStateFile, desc(description, envVar))
 		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
 			bindFlagError(longOpt, err)
 		}
 		if err := viper.BindEnv(key, envVar); err != nil {
 			bindEnvError(envVar, err)
 		}
-		viper.SetDefault(key, defaults.DisableGzip)
+		viper.SetDefault(key, defaults.CounterStateFile)
 	}
 
 	{
@@ -1073,6 +2541,59 @@ func init() {
 		}
 	}
 
+	{
+		const (
+			key         = config.KeyFlushArchiveCount
+			longOpt     = "flush-archive-count"
+			envVar      = release.ENVPREFIX + "_FLUSH_ARCHIVE_COUNT"
+			description = "Number of recent flushes kept for /debug/flushes [0=no count limit]"
+		)
+
+		RootCmd.Flags().Int(longOpt, defaults.FlushArchiveCount, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.FlushArchiveCount)
+	}
+
+	{
+		const (
+			key         = config.KeyFlushArchiveDir
+			longOpt     = "flush-archive-dir"
+			envVar      = release.ENVPREFIX + "_FLUSH_ARCHIVE_DIR"
+			description = "Directory where recent flushes are persisted [empty=memory only]"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyFlushArchiveMaxAge
+			longOpt     = "flush-archive-max-age"
+			envVar      = release.ENVPREFIX + "_FLUSH_ARCHIVE_MAX_AGE"
+			description = "How long recent flushes are kept for /debug/flushes [0=no age limit]"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.FlushArchiveMaxAge, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.FlushArchiveMaxAge)
+	}
+
 	{
 		const (
 			key         = config.KeyLogLevel
@@ -1109,6 +2630,149 @@ func init() {
 		viper.SetDefault(key, defaults.LogPretty)
 	}
 
+	{
+		const (
+			key         = config.KeyLogAccess
+			longOpt     = "log-access"
+			envVar      = release.ENVPREFIX + "_LOG_ACCESS"
+			description = "Emit structured access log lines for server requests"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.LogAccess, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.LogAccess)
+	}
+
+	{
+		const (
+			key         = config.KeyLogTraceSpans
+			longOpt     = "log-trace-spans"
+			envVar      = release.ENVPREFIX + "_LOG_TRACE_SPANS"
+			description = "Emit trace span log lines for /run handling (honors W3C traceparent header)"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.LogTraceSpans, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.LogTraceSpans)
+	}
+
+	{
+		const (
+			key         = config.KeyLogFile
+			longOpt     = "log-file"
+			envVar      = release.ENVPREFIX + "_LOG_FILE"
+			description = "Write log to file (rotated), instead of stdout"
+		)
+
+		RootCmd.Flags().String(longOpt, "", desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+	}
+
+	{
+		const (
+			key         = config.KeyLogFileMaxSize
+			longOpt     = "log-file-max-size"
+			envVar      = release.ENVPREFIX + "_LOG_FILE_MAX_SIZE"
+			description = "Rotate log file when it exceeds size in MB (0 to disable)"
+		)
+
+		RootCmd.Flags().Int(longOpt, defaults.LogFileMaxSize, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.LogFileMaxSize)
+	}
+
+	{
+		const (
+			key         = config.KeyLogFileMaxAge
+			longOpt     = "log-file-max-age"
+			envVar      = release.ENVPREFIX + "_LOG_FILE_MAX_AGE"
+			description = "Rotate log file when older than duration (0 to disable)"
+		)
+
+		RootCmd.Flags().String(longOpt, defaults.LogFileMaxAge, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.LogFileMaxAge)
+	}
+
+	{
+		const (
+			key         = config.KeyLogFileMaxBackups
+			longOpt     = "log-file-max-backups"
+			envVar      = release.ENVPREFIX + "_LOG_FILE_MAX_BACKUPS"
+			description = "Number of rotated log files to retain (0 retains all)"
+		)
+
+		RootCmd.Flags().Int(longOpt, defaults.LogFileMaxBackups, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.LogFileMaxBackups)
+	}
+
+	{
+		const (
+			key         = config.KeyLogFileCompress
+			longOpt     = "log-file-compress"
+			envVar      = release.ENVPREFIX + "_LOG_FILE_COMPRESS"
+			description = "Compress (gzip) rotated log files"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.LogFileCompress, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.LogFileCompress)
+	}
+
+	{
+		const (
+			key         = config.KeyLogSystem
+			longOpt     = "log-system"
+			envVar      = release.ENVPREFIX + "_LOG_SYSTEM"
+			description = "Also send log to system log (syslog, or Windows Event Log)"
+		)
+
+		RootCmd.Flags().Bool(longOpt, defaults.LogSystem, desc(description, envVar))
+		if err := viper.BindPFlag(key, RootCmd.Flags().Lookup(longOpt)); err != nil {
+			bindFlagError(longOpt, err)
+		}
+		if err := viper.BindEnv(key, envVar); err != nil {
+			bindEnvError(envVar, err)
+		}
+		viper.SetDefault(key, defaults.LogSystem)
+	}
+
 	//
 	// Clustering options
 	//
@@ -1273,6 +2937,13 @@ func initLogging(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	//
+	// Log file and/or system log
+	//
+	if err := initLogOutput(); err != nil {
+		return err
+	}
+
 	//
 	// Enable debug logging, if requested
 	// otherwise, default to info level and set custom level, if specified
@@ -1309,6 +2980,63 @@ func initLogging(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// initLogOutput directs log output to a rotating log file (if configured)
+// and/or the system log, service managed agents do not retain stdout
+func initLogOutput() error {
+	file := viper.GetString(config.KeyLogFile)
+	system := viper.GetBool(config.KeyLogSystem)
+	if file == "" && !system {
+		return nil
+	}
+
+	writers := []io.Writer{}
+
+	if file != "" {
+		maxAge := time.Duration(0)
+		if age := viper.GetString(config.KeyLogFileMaxAge); age != "" && age != "0" {
+			d, err := time.ParseDuration(age)
+			if err != nil {
+				return errors.Wrap(err, "parsing log file max age")
+			}
+			maxAge = d
+		}
+		if logFile != nil {
+			_ = logFile.Close()
+			logFile = nil
+		}
+		rf, err := logging.NewRotatingFile(
+			file,
+			int64(viper.GetInt(config.KeyLogFileMaxSize))*1024*1024,
+			maxAge,
+			viper.GetInt(config.KeyLogFileMaxBackups),
+			viper.GetBool(config.KeyLogFileCompress))
+		if err != nil {
+			return errors.Wrap(err, "log file")
+		}
+		logFile = rf
+		writers = append(writers, rf)
+	} else if viper.GetBool(config.KeyLogPretty) && runtime.GOOS != "windows" {
+		writers = append(writers, zerolog.ConsoleWriter{Out: os.Stdout})
+	} else {
+		writers = append(writers, zerolog.SyncWriter(os.Stdout))
+	}
+
+	if system {
+		sw, err := logging.NewSystemWriter(release.NAME)
+		if err != nil {
+			return errors.Wrap(err, "system log")
+		}
+		writers = append(writers, sw)
+	}
+
+	log.Logger = zerolog.New(zerolog.MultiLevelWriter(writers...)).With().Timestamp().Logger()
+	stdlog.SetOutput(log.Logger)
+
+	log.Debug().Str("file", file).Bool("system", system).Msg("log output")
+
+	return nil
+}
+
 // initConfig reads in config file and/or ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
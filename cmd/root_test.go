@@ -6,10 +6,15 @@
 package cmd
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
@@ -58,6 +63,46 @@ func TestInitLogging(t *testing.T) {
 		viper.Reset()
 	}
 
+	t.Log("log file")
+	{
+		dir, err := ioutil.TempDir("", "logfile")
+		if err != nil {
+			t.Fatalf("creating temp dir (%s)", err)
+		}
+		defer os.RemoveAll(dir)
+		origLogger := log.Logger
+		defer func() { log.Logger = origLogger }()
+		file := filepath.Join(dir, "agent.log")
+		viper.Set(config.KeyLogFile, file)
+		viper.Set(config.KeyLogLevel, "info")
+		if err := initLogging(nil, []string{}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		log.Info().Msg("test")
+		if err := logFile.Close(); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if !strings.Contains(string(data), `"message":"test"`) {
+			t.Fatalf("expected log line, got (%s)", string(data))
+		}
+		viper.Reset()
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+
+	t.Log("log file, invalid max age")
+	{
+		viper.Set(config.KeyLogFile, filepath.Join(os.TempDir(), "agent.log"))
+		viper.Set(config.KeyLogFileMaxAge, "foo")
+		if err := initLogging(nil, []string{}); err == nil {
+			t.Fatal("expected error")
+		}
+		viper.Reset()
+	}
+
 	t.Log("debug flag")
 	{
 		viper.Set(config.KeyDebug, true)
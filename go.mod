@@ -6,7 +6,7 @@ require (
 	github.com/circonus-labs/circonus-gometrics/v3 v3.0.0
 	github.com/circonus-labs/circonusllhist v0.1.4
 	github.com/circonus-labs/go-apiclient v0.7.6
-	github.com/go-ole/go-ole v1.2.4 // indirect
+	github.com/go-ole/go-ole v1.2.4
 	github.com/gojuno/minimock/v3 v3.0.6
 	github.com/hashicorp/go-hclog v0.10.1 // indirect
 	github.com/hashicorp/go-retryablehttp v0.6.4 // indirect
@@ -21,7 +21,7 @@ require (
 	github.com/spf13/cast v1.3.1 // indirect
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.0
 	github.com/tv42/httpunix v0.0.0-20191220191345-2ba4b9c3382c // indirect
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a
@@ -0,0 +1,60 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package agent
+
+import (
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// reverseAdmin executes the administrative commands received over the
+// reverse connection (see connection.Admin)
+type reverseAdmin struct {
+	a *Agent
+}
+
+var logLevels = map[string]zerolog.Level{
+	"panic":    zerolog.PanicLevel,
+	"fatal":    zerolog.FatalLevel,
+	"error":    zerolog.ErrorLevel,
+	"warn":     zerolog.WarnLevel,
+	"info":     zerolog.InfoLevel,
+	"debug":    zerolog.DebugLevel,
+	"disabled": zerolog.Disabled,
+}
+
+// RescanPlugins scans the plugin directory for new/updated/removed plugins
+func (ra reverseAdmin) RescanPlugins() error {
+	return ra.a.plugins.Rescan(ra.a.builtins)
+}
+
+// SetLogLevel changes the log level of the running agent
+func (ra reverseAdmin) SetLogLevel(level string) error {
+	l, ok := logLevels[level]
+	if !ok {
+		return errors.Errorf("unknown log level (%s)", level)
+	}
+	zerolog.SetGlobalLevel(l)
+	viper.Set(config.KeyLogLevel, level)
+	ra.a.logger.Info().Str("log-level", level).Msg("logging level")
+	return nil
+}
+
+// RunCollector starts a run of a builtin collector, the metrics are
+// submitted with the next flush
+func (ra reverseAdmin) RunCollector(id string) error {
+	if !ra.a.builtins.IsBuiltin(id) {
+		return errors.Errorf("unknown builtin collector (%s)", id)
+	}
+	go func() {
+		if err := ra.a.builtins.Run(ra.a.groupCtx, id); err != nil {
+			ra.a.logger.Warn().Err(err).Str("id", id).Msg("running builtin collector")
+		}
+	}()
+	return nil
+}
@@ -14,13 +14,20 @@ import (
 	"github.com/circonus-labs/circonus-agent/internal/check"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/counterstate"
+	"github.com/circonus-labs/circonus-agent/internal/errs"
+	"github.com/circonus-labs/circonus-agent/internal/graphite"
+	"github.com/circonus-labs/circonus-agent/internal/otlp"
+	"github.com/circonus-labs/circonus-agent/internal/pluginbundle"
 	"github.com/circonus-labs/circonus-agent/internal/plugins"
 	"github.com/circonus-labs/circonus-agent/internal/release"
 	"github.com/circonus-labs/circonus-agent/internal/reverse"
 	"github.com/circonus-labs/circonus-agent/internal/server"
 	"github.com/circonus-labs/circonus-agent/internal/statsd"
+	"github.com/circonus-labs/circonus-agent/internal/watchdog"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -33,9 +40,15 @@ type Agent struct {
 	check        *check.Check
 	listenServer *server.Server
 	plugins      *plugins.Plugins
+	bundle       *pluginbundle.Bundle
 	reverseConn  *reverse.Reverse
+	routeConns   []*reverse.Reverse
 	signalCh     chan os.Signal
 	statsdServer *statsd.Server
+	otlpServer   *otlp.Server
+	graphiteSvr  *graphite.Server
+	counters     *counterstate.Store
+	watchdog     *watchdog.Watchdog
 	logger       zerolog.Logger
 }
 
@@ -44,7 +57,6 @@ func New() (*Agent, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	g, gctx := errgroup.WithContext(ctx)
 
-	var err error
 	a := Agent{
 		group:       g,
 		groupCtx:    gctx,
@@ -53,9 +65,18 @@ func New() (*Agent, error) {
 		logger:      log.With().Str("pkg", "agent").Logger(),
 	}
 
+	profile, err := config.ApplyProfile(a.logger)
+	if err != nil {
+		return nil, errs.NewConfig(err)
+	}
+
 	err = config.Validate()
 	if err != nil {
-		return nil, err
+		return nil, errs.NewConfig(err)
+	}
+
+	if err = applyRuntimeTuning(a.logger); err != nil {
+		return nil, errs.NewConfig(err)
 	}
 
 	a.check, err = check.New(nil)
@@ -72,16 +93,43 @@ func New() (*Agent, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	a.bundle, err = pluginbundle.New(a.plugins.Dir(), profile)
+	if err != nil {
+		return nil, errs.NewConfig(err)
+	}
+	bundleCtx, bundleCancel := context.WithTimeout(a.groupCtx, pluginbundle.StartupTimeout)
+	_, err = a.bundle.Update(bundleCtx)
+	bundleCancel()
+	if err != nil {
+		a.logger.Warn().Err(err).Msg("plugin bundle, using plugins already installed")
+	}
+
 	if err = a.plugins.Scan(a.builtins); err != nil {
 		return nil, err
 	}
 
-	a.statsdServer, err = statsd.New(a.groupCtx)
+	a.counters, err = counterstate.New()
+	if err != nil {
+		return nil, errs.NewConfig(err)
+	}
+
+	a.statsdServer, err = statsd.New(a.groupCtx, a.counters)
+	if err != nil {
+		return nil, err
+	}
+
+	a.otlpServer, err = otlp.New(a.groupCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.graphiteSvr, err = graphite.New(a.groupCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	a.listenServer, err = server.New(a.groupCtx, a.check, a.builtins, a.plugins, a.statsdServer)
+	a.listenServer, err = server.New(a.groupCtx, a.check, a.builtins, a.plugins, a.statsdServer, a.otlpServer, a.graphiteSvr)
 	if err != nil {
 		return nil, err
 	}
@@ -90,31 +138,96 @@ func New() (*Agent, error) {
 	if err != nil {
 		return nil, err
 	}
-	a.reverseConn, err = reverse.New(a.logger, a.check, agentAddress)
+	a.reverseConn, err = reverse.New(a.logger, a.check, agentAddress, reverseAdmin{a: &a})
+	if err != nil {
+		return nil, err
+	}
+	a.routeConns, err = a.newRouteConns(agentAddress)
 	if err != nil {
 		return nil, err
 	}
 
+	a.watchdog, err = watchdog.New(a.groupCtx)
+	if err != nil {
+		return nil, errs.NewConfig(err)
+	}
+
 	a.signalNotifySetup()
 
 	return &a, nil
 }
 
+// newRouteConns returns a reverse connection for each check bundle which is
+// the destination of a metric route (see --check-routes), the broker of each
+// check requests the metrics routed to it over its own reverse connection
+func (a *Agent) newRouteConns(agentAddress string) ([]*reverse.Reverse, error) {
+	if !viper.GetBool(config.KeyReverse) {
+		return nil, nil
+	}
+
+	routes, err := config.CheckRoutes()
+	if err != nil {
+		return nil, errs.NewConfig(err)
+	}
+
+	conns := make([]*reverse.Reverse, 0, len(routes))
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if seen[route.ID] {
+			continue
+		}
+		seen[route.ID] = true
+
+		routeChk, err := a.check.NewRoute(route)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := reverse.New(a.logger, routeChk, agentAddress, nil)
+		if err != nil {
+			return nil, err
+		}
+		a.logger.Info().Str("route", route.ID).Str("bundle_cid", route.BundleID).Msg("metric route reverse connection")
+		conns = append(conns, rc)
+	}
+
+	return conns, nil
+}
+
 // Start the agent
 func (a *Agent) Start() error {
 	a.group.Go(a.handleSignals)
 	a.group.Go(a.statsdServer.Start)
+	a.group.Go(a.otlpServer.Start)
+	a.group.Go(a.graphiteSvr.Start)
 	a.group.Go(func() error {
 		return a.reverseConn.Start(a.groupCtx)
 	})
+	for _, rc := range a.routeConns {
+		rc := rc
+		a.group.Go(func() error {
+			return rc.Start(a.groupCtx)
+		})
+	}
 	a.group.Go(a.listenServer.Start)
+	a.group.Go(a.watchdog.Start)
+	a.group.Go(func() error {
+		return a.bundle.Start(a.groupCtx, func() error {
+			return a.plugins.Rescan(a.builtins)
+		})
+	})
 
 	a.logger.Debug().
 		Int("pid", os.Getpid()).
 		Str("name", release.NAME).
 		Str("ver", release.VERSION).Msg("Starting wait")
 
-	return a.group.Wait()
+	err := a.group.Wait()
+
+	if serr := a.counters.Save(); serr != nil {
+		a.logger.Warn().Err(serr).Msg("saving counter state")
+	}
+
+	return err
 }
 
 // Stop cleans up and shuts down the Agent
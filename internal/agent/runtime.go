@@ -0,0 +1,88 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package agent
+
+import (
+	"expvar"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/alecthomas/units"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// ballast is a heap allocation which is never used, it raises the heap size
+// the GC targets, reducing the frequency of collections for agents with very
+// large metric sets. The pages are never touched so they are not resident.
+var ballast []byte
+
+// gcPercent is the gc percent in effect after tuning is applied
+var gcPercent int
+
+// applyRuntimeTuning sets GC percent, memory limit, and heap ballast from config
+func applyRuntimeTuning(logger zerolog.Logger) error {
+	if gogc := viper.GetInt(config.KeyRuntimeGOGC); gogc != 0 {
+		prev := debug.SetGCPercent(gogc)
+		gcPercent = gogc
+		logger.Info().Int("gogc", gogc).Int("previous", prev).Msg("runtime, gc percent")
+	} else {
+		// SetGCPercent returns the previous setting, put it back
+		gcPercent = debug.SetGCPercent(100)
+		debug.SetGCPercent(gcPercent)
+	}
+
+	if limit := viper.GetString(config.KeyRuntimeMemoryLimit); limit != "" {
+		n, err := units.ParseBase2Bytes(limit)
+		if err != nil {
+			return errors.Wrap(err, "parsing runtime memory limit")
+		}
+		if err := setMemoryLimit(int64(n)); err != nil {
+			return err
+		}
+		logger.Info().Str("limit", limit).Int64("bytes", int64(n)).Msg("runtime, memory limit")
+	}
+
+	if size := viper.GetString(config.KeyRuntimeBallast); size != "" {
+		n, err := units.ParseBase2Bytes(size)
+		if err != nil {
+			return errors.Wrap(err, "parsing runtime ballast")
+		}
+		if n < 0 {
+			return errors.Errorf("invalid runtime ballast (%s)", size)
+		}
+		ballast = make([]byte, int64(n))
+		logger.Info().Str("size", size).Int64("bytes", int64(n)).Msg("runtime, heap ballast")
+	}
+
+	publishRuntimeStats()
+
+	return nil
+}
+
+// publishRuntimeStats exposes gc behavior in the agent's self metrics (/stats)
+// so the effect of the runtime tuning options can be observed
+func publishRuntimeStats() {
+	if expvar.Get("runtime") != nil {
+		return
+	}
+	expvar.Publish("runtime", expvar.Func(func() interface{} {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return map[string]interface{}{
+			"gogc":            gcPercent,
+			"ballast_bytes":   len(ballast),
+			"heap_alloc":      ms.HeapAlloc,
+			"next_gc":         ms.NextGC,
+			"num_gc":          ms.NumGC,
+			"gc_cpu_fraction": ms.GCCPUFraction,
+			"pause_total_ns":  ms.PauseTotalNs,
+			"last_pause_ns":   ms.PauseNs[(ms.NumGC+255)%256],
+		}
+	}))
+}
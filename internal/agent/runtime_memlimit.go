@@ -0,0 +1,16 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build go1.19
+
+package agent
+
+import "runtime/debug"
+
+// setMemoryLimit sets the runtime soft memory limit (GOMEMLIMIT)
+func setMemoryLimit(limit int64) error {
+	debug.SetMemoryLimit(limit)
+	return nil
+}
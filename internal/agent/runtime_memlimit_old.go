@@ -0,0 +1,15 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !go1.19
+
+package agent
+
+import "github.com/pkg/errors"
+
+// setMemoryLimit is not supported prior to go1.19
+func setMemoryLimit(limit int64) error {
+	return errors.New("runtime memory limit requires agent built with go1.19+")
+}
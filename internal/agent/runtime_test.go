@@ -0,0 +1,84 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package agent
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestApplyRuntimeTuning(t *testing.T) {
+	t.Log("Testing applyRuntimeTuning")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	logger := zerolog.Nop()
+
+	defer func() {
+		viper.Reset()
+		ballast = nil
+		debug.SetGCPercent(100)
+	}()
+
+	t.Log("defaults")
+	{
+		viper.Reset()
+		if err := applyRuntimeTuning(logger); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if ballast != nil {
+			t.Fatal("expected no ballast")
+		}
+	}
+
+	t.Log("gogc")
+	{
+		viper.Reset()
+		viper.Set(config.KeyRuntimeGOGC, 200)
+		if err := applyRuntimeTuning(logger); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if gcPercent != 200 {
+			t.Fatalf("expected 200, got %d", gcPercent)
+		}
+		if prev := debug.SetGCPercent(100); prev != 200 {
+			t.Fatalf("expected runtime gc percent 200, got %d", prev)
+		}
+	}
+
+	t.Log("ballast")
+	{
+		viper.Reset()
+		viper.Set(config.KeyRuntimeBallast, "1MiB")
+		if err := applyRuntimeTuning(logger); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if len(ballast) != 1024*1024 {
+			t.Fatalf("expected 1MiB ballast, got %d", len(ballast))
+		}
+	}
+
+	t.Log("invalid ballast")
+	{
+		viper.Reset()
+		viper.Set(config.KeyRuntimeBallast, "lots")
+		if err := applyRuntimeTuning(logger); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("invalid memory limit")
+	{
+		viper.Reset()
+		viper.Set(config.KeyRuntimeMemoryLimit, "1 bazillion")
+		if err := applyRuntimeTuning(logger); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package agentctl implements the operator commands (status, run, collectors,
+// enable/disable and errors) which talk to a running agent through its local
+// API.
+package agentctl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/api"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SourceCategory an error category reported by /health
+	SourceCategory = "category"
+	// SourceBuiltin a builtin collector
+	SourceBuiltin = "builtin"
+	// SourcePlugin a plugin
+	SourcePlugin = "plugin"
+)
+
+// ErrorEntry is the last error of an error category, builtin or plugin
+type ErrorEntry struct {
+	Source  string    `json:"source"`
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Count   uint64    `json:"count,omitempty"` // error categories only
+}
+
+// Status is the live status of the agent
+type Status struct {
+	Health     *api.Health     `json:"health"`
+	Collectors *api.Collectors `json:"collectors"`
+	Plugins    *api.Inventory  `json:"plugins"`
+}
+
+// GetStatus retrieves the health, builtin collectors and plugins from the agent
+func GetStatus(c *api.Client) (*Status, error) {
+	h, err := c.Health()
+	if err != nil {
+		return nil, errors.Wrap(err, "health")
+	}
+	cs, err := c.Collectors()
+	if err != nil {
+		return nil, errors.Wrap(err, "collectors")
+	}
+	inv, err := c.Inventory()
+	if err != nil {
+		return nil, errors.Wrap(err, "inventory")
+	}
+
+	return &Status{Health: h, Collectors: cs, Plugins: inv}, nil
+}
+
+// Report writes the status as a summary followed by a table of the
+// builtin collectors and plugins
+func (s *Status) Report(w io.Writer) {
+	fmt.Fprintf(w, "Status: %s\n", s.Health.Status)
+	if len(s.Health.StaleSources) > 0 {
+		fmt.Fprintf(w, "Stale sources: %s\n", strings.Join(s.Health.StaleSources, ", "))
+	}
+	for _, e := range s.Errors() {
+		if e.Source == SourceCategory {
+			fmt.Fprintf(w, "Errors: %s %d (last %s: %s)\n", e.ID, e.Count, e.Time.Format(time.RFC3339), e.Message)
+		}
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tID\tENABLED\tLAST RUN\tDURATION\tERROR")
+	if s.Collectors != nil {
+		for _, c := range *s.Collectors {
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\t%s\n", SourceBuiltin, c.ID, c.Enabled, c.LastRunEnd, c.LastRunDuration, c.LastError)
+		}
+	}
+	if s.Plugins != nil {
+		plugins := *s.Plugins
+		sort.Slice(plugins, func(i, j int) bool { return plugins[i].ID < plugins[j].ID })
+		for _, p := range plugins {
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\t%s\n", SourcePlugin, p.ID, true, p.LastRunEnd, p.LastRunDuration, p.LastError)
+		}
+	}
+	tw.Flush()
+}
+
+// Errors returns the last error of each error category, builtin collector
+// and plugin, oldest first
+func (s *Status) Errors() []ErrorEntry {
+	var entries []ErrorEntry
+
+	if s.Health != nil {
+		for cat, e := range s.Health.Errors {
+			entries = append(entries, ErrorEntry{Source: SourceCategory, ID: cat, Time: e.Last, Message: e.Message, Count: e.Count})
+		}
+	}
+	if s.Collectors != nil {
+		for _, c := range *s.Collectors {
+			if c.LastError != "" {
+				entries = append(entries, ErrorEntry{Source: SourceBuiltin, ID: c.ID, Time: parseTime(c.LastRunEnd), Message: c.LastError})
+			}
+		}
+	}
+	if s.Plugins != nil {
+		for _, p := range *s.Plugins {
+			if p.LastError != "" {
+				entries = append(entries, ErrorEntry{Source: SourcePlugin, ID: p.ID, Time: parseTime(p.LastRunEnd), Message: p.LastError})
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Source+entries[i].ID < entries[j].Source+entries[j].ID
+		}
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries
+}
+
+// TailErrors writes the recent errors, when follow is set it polls the agent
+// every interval and writes errors which are new or changed until ctx is done
+func TailErrors(ctx context.Context, w io.Writer, c *api.Client, follow bool, interval time.Duration) error {
+	seen := make(map[string]ErrorEntry)
+
+	for {
+		s, err := GetStatus(c)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range s.Errors() {
+			key := e.Source + ":" + e.ID
+			if prev, ok := seen[key]; ok && prev.Time.Equal(e.Time) && prev.Message == e.Message {
+				continue
+			}
+			seen[key] = e
+			fmt.Fprintf(w, "%s %s %s: %s\n", e.Time.Format(time.RFC3339), e.Source, e.ID, e.Message)
+		}
+
+		if !follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// parseTime parses the RFC3339 run times reported by the agent, returns the
+// zero time if the value is not valid (e.g. never run)
+func parseTime(v string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
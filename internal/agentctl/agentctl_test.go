@@ -0,0 +1,112 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package agentctl
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/api"
+)
+
+func testAgent(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			_, _ = w.Write([]byte(`{"status":"alive","errors":{"plugin":{"count":2,"last":"2020-06-01T10:00:02Z","message":"exit status 1"}}}`))
+		case "/collectors/":
+			_, _ = w.Write([]byte(`[{"name":"cpu","enabled":true,"last_run_end":"2020-06-01T10:00:00Z"},{"name":"disk","enabled":false,"last_run_end":"2020-06-01T10:00:01Z","last_error":"no disks"}]`))
+		case "/inventory/":
+			_, _ = w.Write([]byte(`[{"id":"foo","name":"foo","last_run_end":"2020-06-01T10:00:02Z","last_error":"exit status 1"}]`))
+		default:
+			t.Fatalf("unexpected request (%s)", r.URL.Path)
+		}
+	}))
+}
+
+func TestGetStatus(t *testing.T) {
+	t.Log("Testing GetStatus")
+
+	ts := testAgent(t)
+	defer ts.Close()
+
+	c, err := api.New(ts.URL)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	s, err := GetStatus(c)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	t.Log("\terrors")
+	{
+		entries := s.Errors()
+		if len(entries) != 3 {
+			t.Fatalf("expected 3 errors, got %v", entries)
+		}
+		if entries[0].Source != SourceBuiltin || entries[0].ID != "disk" {
+			t.Fatalf("expected disk first (oldest), got %v", entries[0])
+		}
+		if entries[1].Source != SourceCategory || entries[1].Count != 2 {
+			t.Fatalf("expected plugin category, got %v", entries[1])
+		}
+	}
+
+	t.Log("\treport")
+	{
+		var buf bytes.Buffer
+		s.Report(&buf)
+		out := buf.String()
+		for _, expect := range []string{"Status: alive", "Errors: plugin 2", "builtin  disk", "plugin   foo"} {
+			if !strings.Contains(out, expect) {
+				t.Fatalf("expected (%s) in report, got\n%s", expect, out)
+			}
+		}
+	}
+}
+
+func TestTailErrors(t *testing.T) {
+	t.Log("Testing TailErrors")
+
+	ts := testAgent(t)
+	defer ts.Close()
+
+	c, err := api.New(ts.URL)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	t.Log("\tno follow")
+	{
+		var buf bytes.Buffer
+		if err := TailErrors(context.Background(), &buf, c, false, time.Second); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if n := strings.Count(buf.String(), "\n"); n != 3 {
+			t.Fatalf("expected 3 lines, got %d\n%s", n, buf.String())
+		}
+	}
+
+	t.Log("\tfollow (unchanged errors written once)")
+	{
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		var buf bytes.Buffer
+		if err := TailErrors(ctx, &buf, c, true, 10*time.Millisecond); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if n := strings.Count(buf.String(), "\n"); n != 3 {
+			t.Fatalf("expected 3 lines, got %d\n%s", n, buf.String())
+		}
+	}
+}
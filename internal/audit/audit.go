@@ -0,0 +1,277 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package audit tracks changes of the agent's effective configuration (the
+// merged flag, environment and config file settings) and of the active
+// plugins between agent starts. Changes are emitted as metrics, so config
+// drift is visible in the metric stream itself.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// HashMetric text metric, hash of the effective configuration
+	HashMetric = "agent_config_hash"
+	// ChangesMetric counter, number of configuration changes detected
+	ChangesMetric = "agent_config_changes"
+	// ChangedKeysMetric text metric, keys changed by the last configuration change
+	ChangedKeysMetric = "agent_config_changed_keys"
+	// PluginChangesMetric counter, number of plugin changes (added/removed) detected
+	PluginChangesMetric = "agent_plugin_changes"
+	// PluginsAddedMetric text metric, plugins added by the last plugin change
+	PluginsAddedMetric = "agent_plugins_added"
+	// PluginsRemovedMetric text metric, plugins removed by the last plugin change
+	PluginsRemovedMetric = "agent_plugins_removed"
+
+	hashLen = 12 // hex characters of the configuration hash emitted
+)
+
+// state is persisted to the state file, the previous start's configuration
+// is compared against it
+type state struct {
+	Hash           string            `json:"hash"`
+	Keys           map[string]string `json:"keys"` // hash of each setting's value
+	Plugins        []string          `json:"plugins"`
+	ConfigChanges  uint64            `json:"config_changes"`
+	ChangedKeys    []string          `json:"changed_keys"`
+	PluginChanges  uint64            `json:"plugin_changes"`
+	PluginsAdded   []string          `json:"plugins_added"`
+	PluginsRemoved []string          `json:"plugins_removed"`
+}
+
+// Audit emits the configuration change tracking metrics
+type Audit struct {
+	state    state
+	baseTags tags.Tags
+	logger   zerolog.Logger
+	sync.Mutex
+}
+
+// New compares the effective configuration and the active plugins with the
+// state saved by the previous start and saves the current state, returns
+// nil if configuration auditing is not enabled
+func New(plugins []string) (*Audit, error) {
+	if !viper.GetBool(config.KeyAuditConfig) {
+		return nil, nil
+	}
+
+	// an empty state file keeps the state in memory (e.g. read-only filesystem), every start is a baseline
+	stateFile := viper.GetString(config.KeyAuditStateFile)
+
+	a := &Audit{
+		baseTags: tags.FromList(tags.GetBaseTags()),
+		logger:   log.With().Str("pkg", "audit").Logger(),
+	}
+
+	keys := configHashes()
+	cur := state{
+		Hash:    configHash(keys),
+		Keys:    keys,
+		Plugins: append([]string{}, plugins...),
+	}
+	sort.Strings(cur.Plugins)
+
+	prev, err := loadState(stateFile)
+	switch {
+	case stateFile == "":
+		a.logger.Info().Msg("no audit state file, state kept in memory, recording baseline")
+	case err == nil:
+		cur.ConfigChanges = prev.ConfigChanges
+		cur.ChangedKeys = prev.ChangedKeys
+		cur.PluginChanges = prev.PluginChanges
+		cur.PluginsAdded = prev.PluginsAdded
+		cur.PluginsRemoved = prev.PluginsRemoved
+
+		if prev.Hash != cur.Hash {
+			cur.ConfigChanges++
+			cur.ChangedKeys = changedKeys(prev.Keys, cur.Keys)
+			a.logger.Info().Strs("changed_keys", cur.ChangedKeys).Str("hash", shortHash(cur.Hash)).Msg("configuration changed")
+		}
+
+		added, removed := diffLists(prev.Plugins, cur.Plugins)
+		if len(added) > 0 || len(removed) > 0 {
+			cur.PluginChanges++
+			cur.PluginsAdded = added
+			cur.PluginsRemoved = removed
+			a.logger.Info().Strs("added", added).Strs("removed", removed).Msg("plugins changed")
+		}
+	case os.IsNotExist(errors.Cause(err)):
+		a.logger.Info().Str("file", stateFile).Msg("no previous audit state, recording baseline")
+	default:
+		a.logger.Warn().Err(err).Str("file", stateFile).Msg("ignoring previous audit state, recording baseline")
+	}
+
+	a.state = cur
+
+	if stateFile == "" {
+		return a, nil
+	}
+
+	if err := saveState(stateFile, &cur); err != nil {
+		a.logger.Warn().Err(err).Str("file", stateFile).Msg("saving audit state")
+	}
+
+	return a, nil
+}
+
+// Apply adds the configuration change tracking metrics
+func (a *Audit) Apply(metrics *cgm.Metrics) {
+	if a == nil || metrics == nil {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	a.addMetric(metrics, HashMetric, "s", shortHash(a.state.Hash))
+	a.addMetric(metrics, ChangesMetric, "L", a.state.ConfigChanges)
+	if len(a.state.ChangedKeys) > 0 {
+		a.addMetric(metrics, ChangedKeysMetric, "s", strings.Join(a.state.ChangedKeys, ","))
+	}
+	a.addMetric(metrics, PluginChangesMetric, "L", a.state.PluginChanges)
+	if len(a.state.PluginsAdded) > 0 {
+		a.addMetric(metrics, PluginsAddedMetric, "s", strings.Join(a.state.PluginsAdded, ","))
+	}
+	if len(a.state.PluginsRemoved) > 0 {
+		a.addMetric(metrics, PluginsRemovedMetric, "s", strings.Join(a.state.PluginsRemoved, ","))
+	}
+}
+
+func (a *Audit) addMetric(metrics *cgm.Metrics, name, mtype string, val interface{}) {
+	(*metrics)[tags.MetricNameWithStreamTags(name, a.baseTags)] = cgm.Metric{Type: mtype, Value: val}
+}
+
+// configHashes returns a hash of the value of each effective setting
+func configHashes() map[string]string {
+	keys := viper.AllKeys()
+	hashes := make(map[string]string, len(keys))
+	for _, key := range keys {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%v", viper.Get(key))))
+		hashes[key] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+// configHash returns the hash of the effective configuration, from the
+// sorted setting hashes
+func configHash(keys map[string]string) string {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, key := range names {
+		fmt.Fprintf(h, "%s=%s\n", key, keys[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func shortHash(hash string) string {
+	if len(hash) > hashLen {
+		return hash[:hashLen]
+	}
+	return hash
+}
+
+// changedKeys returns the sorted keys added, removed or with a different value
+func changedKeys(prev, cur map[string]string) []string {
+	changed := []string{}
+	for key, hash := range cur {
+		if prevHash, ok := prev[key]; !ok || prevHash != hash {
+			changed = append(changed, key)
+		}
+	}
+	for key := range prev {
+		if _, ok := cur[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// diffLists returns the items added to and removed from prev (both sorted)
+func diffLists(prev, cur []string) ([]string, []string) {
+	inPrev := make(map[string]bool, len(prev))
+	for _, item := range prev {
+		inPrev[item] = true
+	}
+	inCur := make(map[string]bool, len(cur))
+	for _, item := range cur {
+		inCur[item] = true
+	}
+
+	added := []string{}
+	for _, item := range cur {
+		if !inPrev[item] {
+			added = append(added, item)
+		}
+	}
+	removed := []string{}
+	for _, item := range prev {
+		if !inCur[item] {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
+
+func loadState(file string) (*state, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading state file")
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, errors.Wrap(err, "parsing state file")
+	}
+
+	return &s, nil
+}
+
+func saveState(file string, s *state) error {
+	sf, err := ioutil.TempFile(filepath.Dir(file), "audit")
+	if err != nil {
+		return errors.Wrap(err, "creating temp state file")
+	}
+
+	enc := json.NewEncoder(sf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		sf.Close()
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "error encoding state (removing temp file)")
+	}
+
+	sf.Close()
+	if err := os.Rename(sf.Name(), file); err != nil {
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "updating state file (removing temp file)")
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "audit.json")
+
+	t.Log("\tnot enabled")
+	{
+		viper.Reset()
+		a, err := New(nil)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if a != nil {
+			t.Fatal("expected nil")
+		}
+	}
+
+	t.Log("\tno state file (in memory)")
+	{
+		viper.Reset()
+		viper.Set(config.KeyAuditConfig, true)
+		a, err := New(nil)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if a == nil || a.state.ConfigChanges != 0 {
+			t.Fatalf("expected baseline audit, got %#v", a)
+		}
+	}
+
+	viper.Reset()
+	viper.Set(config.KeyAuditConfig, true)
+	viper.Set(config.KeyAuditStateFile, stateFile)
+	viper.Set(config.KeyCollectors, []string{"procfs/cpu", "procfs/disk"})
+	viper.Set(config.KeyLogLevel, "info")
+
+	var firstHash interface{}
+
+	t.Log("\tbaseline")
+	{
+		a, err := New([]string{"nginx", "mysql"})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := cgm.Metrics{}
+		a.Apply(&metrics)
+
+		m, ok := testutil.FindMetric(metrics, HashMetric)
+		if !ok || len(m.Value.(string)) != hashLen {
+			t.Fatalf("expected %d character hash, got %v", hashLen, m.Value)
+		}
+		firstHash = m.Value
+		if m, ok := testutil.FindMetric(metrics, ChangesMetric); !ok || m.Value.(uint64) != 0 {
+			t.Fatalf("expected 0 config changes, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, PluginChangesMetric); !ok || m.Value.(uint64) != 0 {
+			t.Fatalf("expected 0 plugin changes, got %v", m.Value)
+		}
+		if _, ok := testutil.FindMetric(metrics, ChangedKeysMetric); ok {
+			t.Fatal("expected no changed keys")
+		}
+		if _, err := os.Stat(stateFile); err != nil {
+			t.Fatalf("expected state file, got (%s)", err)
+		}
+	}
+
+	t.Log("\tunchanged")
+	{
+		a, err := New([]string{"mysql", "nginx"})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := cgm.Metrics{}
+		a.Apply(&metrics)
+		if m, _ := testutil.FindMetric(metrics, HashMetric); m.Value != firstHash {
+			t.Fatalf("expected hash %v, got %v", firstHash, m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, ChangesMetric); m.Value.(uint64) != 0 {
+			t.Fatalf("expected 0 config changes, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, PluginChangesMetric); m.Value.(uint64) != 0 {
+			t.Fatalf("expected 0 plugin changes, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tconfig and plugins changed")
+	{
+		viper.Set(config.KeyCollectors, []string{"procfs/cpu"})
+		viper.Set(config.KeyDebug, true)
+		a, err := New([]string{"mysql", "redis"})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := cgm.Metrics{}
+		a.Apply(&metrics)
+		if m, _ := testutil.FindMetric(metrics, HashMetric); m.Value == firstHash {
+			t.Fatalf("expected new hash, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, ChangesMetric); m.Value.(uint64) != 1 {
+			t.Fatalf("expected 1 config change, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, ChangedKeysMetric); m.Value != "collectors,debug" {
+			t.Fatalf("expected changed keys collectors,debug, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, PluginChangesMetric); m.Value.(uint64) != 1 {
+			t.Fatalf("expected 1 plugin change, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, PluginsAddedMetric); m.Value != "redis" {
+			t.Fatalf("expected redis added, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, PluginsRemovedMetric); m.Value != "nginx" {
+			t.Fatalf("expected nginx removed, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tlast change carried over")
+	{
+		a, err := New([]string{"mysql", "redis"})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := cgm.Metrics{}
+		a.Apply(&metrics)
+		if m, _ := testutil.FindMetric(metrics, ChangesMetric); m.Value.(uint64) != 1 {
+			t.Fatalf("expected 1 config change, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, ChangedKeysMetric); m.Value != "collectors,debug" {
+			t.Fatalf("expected changed keys collectors,debug, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tinvalid state file")
+	{
+		if err := ioutil.WriteFile(stateFile, []byte("{"), 0644); err != nil {
+			t.Fatalf("writing state file (%s)", err)
+		}
+		a, err := New([]string{"mysql"})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if a.state.ConfigChanges != 0 || a.state.PluginChanges != 0 {
+			t.Fatalf("expected new baseline, got %#v", a.state)
+		}
+	}
+}
+
+func TestApplyNil(t *testing.T) {
+	t.Log("Testing Apply (not enabled)")
+
+	var a *Audit
+	metrics := cgm.Metrics{}
+	a.Apply(&metrics)
+	if len(metrics) != 0 {
+		t.Fatalf("expected no metrics, got %v", metrics)
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	t.Log("Testing changedKeys")
+
+	prev := map[string]string{"a": "1", "b": "2", "c": "3"}
+	cur := map[string]string{"a": "1", "b": "4", "d": "5"}
+	expect := []string{"b", "c", "d"}
+	if got := changedKeys(prev, cur); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %v, got %v", expect, got)
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package bench generates synthetic statsd traffic and collector (/write)
+// series against a running agent and reports throughput, drops and latency.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/pkg/errors"
+)
+
+const (
+	// collectorID is the /write id used for the synthetic collector series
+	collectorID = "bench"
+	// settleDelay allows the agent to process queued statsd packets before verifying
+	settleDelay = 2 * time.Second
+)
+
+// Config defines the benchmark parameters
+type Config struct {
+	AgentURL    string        // agent base url, e.g. http://127.0.0.1:2609
+	StatsdAddr  string        // agent statsd udp address, e.g. 127.0.0.1:8125
+	Duration    time.Duration // how long to generate traffic
+	StatsdRate  int           // statsd packets per second (0 disables)
+	StatsdNames int           // number of unique statsd metric names
+	Prefix      string        // metric name prefix
+	Series      int           // collector series per /write request (0 disables)
+	Interval    time.Duration // interval between /write requests
+	Verify      bool          // read metrics back via /run to count drops
+}
+
+// Latency summarizes request latencies
+type Latency struct {
+	Count int           `json:"count"`
+	Min   time.Duration `json:"min"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// Result is the benchmark report
+type Result struct {
+	Duration       time.Duration `json:"duration"`
+	StatsdSent     uint64        `json:"statsd_sent"`
+	StatsdErrors   uint64        `json:"statsd_errors"`
+	StatsdRate     float64       `json:"statsd_rate"`
+	StatsdReceived uint64        `json:"statsd_received"`
+	StatsdDropped  uint64        `json:"statsd_dropped"`
+	WriteRequests  uint64        `json:"write_requests"`
+	WriteErrors    uint64        `json:"write_errors"`
+	WriteSeries    uint64        `json:"write_series"`
+	WriteRate      float64       `json:"write_rate"`
+	WriteLatency   Latency       `json:"write_latency"`
+	RunLatency     Latency       `json:"run_latency"`
+	Verified       bool          `json:"verified"`
+}
+
+// Validate checks the benchmark configuration
+func (c *Config) Validate() error {
+	if c.Duration <= 0 {
+		return errors.New("invalid duration (<=0)")
+	}
+	if c.StatsdRate < 0 || c.Series < 0 {
+		return errors.New("invalid rate/series (<0)")
+	}
+	if c.StatsdRate == 0 && c.Series == 0 {
+		return errors.New("nothing to do, statsd rate and series are both zero")
+	}
+	if c.StatsdRate > 0 {
+		if c.StatsdAddr == "" {
+			return errors.New("invalid statsd address (empty)")
+		}
+		if c.StatsdNames < 1 {
+			return errors.New("invalid statsd names (<1)")
+		}
+	}
+	if (c.Series > 0 || c.Verify) && c.AgentURL == "" {
+		return errors.New("invalid agent url (empty)")
+	}
+	if c.Series > 0 && c.Interval <= 0 {
+		return errors.New("invalid interval (<=0)")
+	}
+	if c.Prefix == "" {
+		return errors.New("invalid prefix (empty)")
+	}
+	return nil
+}
+
+// Run executes the benchmark
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.AgentURL = strings.TrimSuffix(cfg.AgentURL, "/")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	res := &Result{}
+
+	if cfg.Verify {
+		// discard anything queued before the benchmark starts
+		if _, _, err := fetchMetrics(ctx, client, cfg.AgentURL+"/run/statsd"); err != nil {
+			return nil, errors.Wrap(err, "initial statsd flush")
+		}
+		if _, _, err := fetchMetrics(ctx, client, cfg.AgentURL+"/run/write"); err != nil {
+			return nil, errors.Wrap(err, "initial receiver flush")
+		}
+	}
+
+	bctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var (
+		wg           sync.WaitGroup
+		statsdErr    error
+		writeLatency []time.Duration
+	)
+
+	start := time.Now()
+
+	if cfg.StatsdRate > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statsdErr = sendStatsd(bctx, cfg, &res.StatsdSent, &res.StatsdErrors)
+		}()
+	}
+
+	if cfg.Series > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			writeLatency = sendSeries(bctx, client, cfg, res)
+		}()
+	}
+
+	wg.Wait()
+	res.Duration = time.Since(start)
+
+	if statsdErr != nil {
+		return nil, statsdErr
+	}
+
+	secs := res.Duration.Seconds()
+	res.StatsdRate = float64(res.StatsdSent) / secs
+	res.WriteRate = float64(res.WriteSeries) / secs
+	res.WriteLatency = summarize(writeLatency)
+
+	if !cfg.Verify {
+		return res, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return res, nil
+	case <-time.After(settleDelay):
+	}
+
+	runLatency := []time.Duration{}
+	if cfg.StatsdRate > 0 {
+		metrics, d, err := fetchMetrics(ctx, client, cfg.AgentURL+"/run/statsd")
+		if err != nil {
+			return nil, errors.Wrap(err, "verifying statsd")
+		}
+		runLatency = append(runLatency, d)
+		res.StatsdReceived = sumCounters(metrics, cfg.Prefix)
+		if res.StatsdSent > res.StatsdReceived {
+			res.StatsdDropped = res.StatsdSent - res.StatsdReceived
+		}
+	}
+	if cfg.Series > 0 {
+		_, d, err := fetchMetrics(ctx, client, cfg.AgentURL+"/run/write")
+		if err != nil {
+			return nil, errors.Wrap(err, "verifying receiver")
+		}
+		runLatency = append(runLatency, d)
+	}
+	res.RunLatency = summarize(runLatency)
+	res.Verified = true
+
+	return res, nil
+}
+
+// sendStatsd sends counter increments, one per packet, at the configured rate
+func sendStatsd(ctx context.Context, cfg Config, sent, failed *uint64) error {
+	conn, err := net.Dial("udp", cfg.StatsdAddr)
+	if err != nil {
+		return errors.Wrap(err, "connecting to statsd")
+	}
+	defer conn.Close()
+
+	// send in batches every 10ms to reach higher rates without a timer per packet
+	const tick = 10 * time.Millisecond
+	perTick := float64(cfg.StatsdRate) * tick.Seconds()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	owed := 0.0
+	n := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		owed += perTick
+		for ; owed >= 1; owed-- {
+			line := fmt.Sprintf("%sc%d:1|c", cfg.Prefix, n%cfg.StatsdNames)
+			n++
+			if _, err := conn.Write([]byte(line)); err != nil {
+				atomic.AddUint64(failed, 1)
+				continue
+			}
+			atomic.AddUint64(sent, 1)
+		}
+	}
+}
+
+// sendSeries posts synthetic collector series to /write at each interval
+func sendSeries(ctx context.Context, client *http.Client, cfg Config, res *Result) []time.Duration {
+	latencies := []time.Duration{}
+	url := cfg.AgentURL + "/write/" + collectorID
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	iter := 0
+	for {
+		payload := make(tags.JSONMetrics, cfg.Series)
+		for i := 0; i < cfg.Series; i++ {
+			payload[fmt.Sprintf("%sseries%d", cfg.Prefix, i)] = tags.JSONMetric{Type: "n", Value: float64(iter + i)}
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			res.WriteErrors++
+		} else {
+			start := time.Now()
+			if err := post(ctx, client, url, data); err != nil {
+				if ctx.Err() != nil {
+					return latencies
+				}
+				res.WriteErrors++
+			} else {
+				latencies = append(latencies, time.Since(start))
+				res.WriteSeries += uint64(cfg.Series)
+			}
+			res.WriteRequests++
+		}
+		iter++
+
+		select {
+		case <-ctx.Done():
+			return latencies
+		case <-ticker.C:
+		}
+	}
+}
+
+func post(ctx context.Context, client *http.Client, url string, data []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// fetchMetrics retrieves metrics from the agent, returning the request duration
+func fetchMetrics(ctx context.Context, client *http.Client, url string) (map[string]interface{}, time.Duration, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	d := time.Since(start)
+	if err != nil {
+		return nil, d, errors.Wrap(err, "reading response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, d, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	var metrics map[string]interface{}
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return nil, d, errors.Wrap(err, "parsing metrics")
+	}
+	return metrics, d, nil
+}
+
+// sumCounters totals the values of counters with the prefix (stream tags are ignored)
+func sumCounters(metrics map[string]interface{}, prefix string) uint64 {
+	var total uint64
+	for name, m := range metrics {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		mv, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := mv["_value"].(float64); ok {
+			total += uint64(v)
+		}
+	}
+	return total
+}
+
+// summarize computes latency percentiles
+func summarize(samples []time.Duration) Latency {
+	l := Latency{Count: len(samples)}
+	if len(samples) == 0 {
+		return l
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	pct := func(p float64) time.Duration {
+		idx := int(p*float64(len(sorted))+0.5) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(sorted) {
+			idx = len(sorted) - 1
+		}
+		return sorted[idx]
+	}
+	l.Min = sorted[0]
+	l.P50 = pct(0.50)
+	l.P95 = pct(0.95)
+	l.P99 = pct(0.99)
+	l.Max = sorted[len(sorted)-1]
+	return l
+}
+
+// Report writes a human readable summary of the result
+func (r *Result) Report(w io.Writer) {
+	fmt.Fprintf(w, "duration:        %s\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "statsd sent:     %d (%.1f/s), send errors: %d\n", r.StatsdSent, r.StatsdRate, r.StatsdErrors)
+	if r.Verified {
+		pct := 0.0
+		if r.StatsdSent > 0 {
+			pct = float64(r.StatsdDropped) / float64(r.StatsdSent) * 100
+		}
+		fmt.Fprintf(w, "statsd received: %d, dropped: %d (%.2f%%)\n", r.StatsdReceived, r.StatsdDropped, pct)
+	}
+	fmt.Fprintf(w, "write requests:  %d, errors: %d, series: %d (%.1f/s)\n", r.WriteRequests, r.WriteErrors, r.WriteSeries, r.WriteRate)
+	fmt.Fprintf(w, "write latency:   %s\n", r.WriteLatency)
+	if r.Verified {
+		fmt.Fprintf(w, "run latency:     %s\n", r.RunLatency)
+	}
+}
+
+func (l Latency) String() string {
+	if l.Count == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("min %s, p50 %s, p95 %s, p99 %s, max %s (n=%d)",
+		l.Min.Round(time.Microsecond), l.P50.Round(time.Microsecond), l.P95.Round(time.Microsecond),
+		l.P99.Round(time.Microsecond), l.Max.Round(time.Microsecond), l.Count)
+}
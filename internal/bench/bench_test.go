@@ -0,0 +1,181 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	t.Log("Testing Validate")
+
+	valid := Config{
+		AgentURL:    "http://127.0.0.1:2609",
+		StatsdAddr:  "127.0.0.1:8125",
+		Duration:    time.Second,
+		StatsdRate:  10,
+		StatsdNames: 1,
+		Series:      1,
+		Interval:    time.Second,
+		Prefix:      "bench.",
+	}
+
+	tests := []struct {
+		desc   string
+		modify func(*Config)
+		err    bool
+	}{
+		{"valid", func(c *Config) {}, false},
+		{"no duration", func(c *Config) { c.Duration = 0 }, true},
+		{"nothing to do", func(c *Config) { c.StatsdRate = 0; c.Series = 0 }, true},
+		{"no statsd addr", func(c *Config) { c.StatsdAddr = "" }, true},
+		{"no statsd names", func(c *Config) { c.StatsdNames = 0 }, true},
+		{"no agent url", func(c *Config) { c.AgentURL = "" }, true},
+		{"no interval", func(c *Config) { c.Interval = 0 }, true},
+		{"no prefix", func(c *Config) { c.Prefix = "" }, true},
+		{"statsd only, no agent url", func(c *Config) { c.Series = 0; c.AgentURL = "" }, false},
+	}
+
+	for _, test := range tests {
+		tst := test
+		t.Logf("\t%s", tst.desc)
+		cfg := valid
+		tst.modify(&cfg)
+		err := cfg.Validate()
+		if tst.err && err == nil {
+			t.Fatal("expected error")
+		}
+		if !tst.err && err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Log("Testing Run")
+
+	// fake agent statsd listener, counts received increments
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	defer pc.Close()
+
+	var mu sync.Mutex
+	counts := map[string]uint64{}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			name := strings.SplitN(string(buf[:n]), ":", 2)[0]
+			mu.Lock()
+			counts[name]++
+			mu.Unlock()
+		}
+	}()
+
+	var writes, series int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write/bench":
+			var m map[string]interface{}
+			data, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(data, &m); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			writes++
+			series += len(m)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case "/run/statsd":
+			mu.Lock()
+			out := map[string]interface{}{}
+			for name, c := range counts {
+				out[name+"|ST[statsd_type:count]"] = map[string]interface{}{"_type": "L", "_value": c}
+			}
+			counts = map[string]uint64{}
+			mu.Unlock()
+			_ = json.NewEncoder(w).Encode(out)
+		case "/run/write":
+			_, _ = w.Write([]byte("{}"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cfg := Config{
+		AgentURL:    ts.URL,
+		StatsdAddr:  pc.LocalAddr().String(),
+		Duration:    300 * time.Millisecond,
+		StatsdRate:  500,
+		StatsdNames: 5,
+		Series:      10,
+		Interval:    50 * time.Millisecond,
+		Prefix:      "bench.",
+		Verify:      true,
+	}
+
+	res, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if res.StatsdSent == 0 {
+		t.Fatal("expected statsd packets sent")
+	}
+	if res.StatsdReceived == 0 || res.StatsdReceived > res.StatsdSent {
+		t.Fatalf("expected received (%d) <= sent (%d)", res.StatsdReceived, res.StatsdSent)
+	}
+	if res.StatsdReceived+res.StatsdDropped != res.StatsdSent {
+		t.Fatalf("expected received+dropped == sent, got %d+%d != %d", res.StatsdReceived, res.StatsdDropped, res.StatsdSent)
+	}
+	if res.WriteRequests == 0 || res.WriteErrors != 0 {
+		t.Fatalf("expected write requests w/o errors, got %d/%d", res.WriteRequests, res.WriteErrors)
+	}
+	if res.WriteSeries != uint64(series) {
+		t.Fatalf("expected %d series, got %d", series, res.WriteSeries)
+	}
+	if res.WriteLatency.Count != writes {
+		t.Fatalf("expected %d latency samples, got %d", writes, res.WriteLatency.Count)
+	}
+	if !res.Verified || res.RunLatency.Count != 2 {
+		t.Fatalf("expected verified with 2 run latency samples, got %v %d", res.Verified, res.RunLatency.Count)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	t.Log("Testing summarize")
+
+	if l := summarize(nil); l.Count != 0 || l.String() != "n/a" {
+		t.Fatalf("expected empty summary, got %v", l)
+	}
+
+	samples := make([]time.Duration, 100)
+	for i := range samples {
+		samples[i] = time.Duration(100-i) * time.Millisecond
+	}
+	l := summarize(samples)
+	if l.Min != time.Millisecond || l.Max != 100*time.Millisecond {
+		t.Fatalf("unexpected min/max %s/%s", l.Min, l.Max)
+	}
+	if l.P50 != 50*time.Millisecond || l.P95 != 95*time.Millisecond || l.P99 != 99*time.Millisecond {
+		t.Fatalf("unexpected percentiles %s", l)
+	}
+}
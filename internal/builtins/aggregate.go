@@ -0,0 +1,97 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package builtins
+
+import (
+	"math"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// aggregateGroup the instance values of a metric (name and the stream tags
+// other than the instance tag)
+type aggregateGroup struct {
+	name  string
+	tags  tags.Tags
+	count int
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// aggregate replaces the per-instance metrics (metrics with a stream tag of
+// the aggregation category) with a metric per aggregation function, tagged
+// aggregate:<function>. Metrics without the tag and non-numeric metrics
+// (text, histograms) are passed through.
+func aggregate(metrics cgm.Metrics, agg config.MetricAggregation) cgm.Metrics {
+	out := make(cgm.Metrics, len(metrics))
+	groups := make(map[string]*aggregateGroup)
+
+	for name, m := range metrics {
+		v, numeric := sample.Float64(m.Value)
+		base, tagList, ok := tags.SplitMetricStreamTags(name)
+		if !numeric || !ok {
+			out[name] = m
+			continue
+		}
+
+		instance := false
+		other := make(tags.Tags, 0, len(tagList))
+		for _, t := range tagList {
+			if t.Category == agg.Category {
+				instance = true
+				continue
+			}
+			other = append(other, t)
+		}
+		if !instance {
+			out[name] = m
+			continue
+		}
+
+		key := tags.MetricNameWithStreamTags(base, other)
+		g, ok := groups[key]
+		if !ok {
+			g = &aggregateGroup{name: base, tags: other, min: math.Inf(1), max: math.Inf(-1)}
+			groups[key] = g
+		}
+		g.count++
+		g.sum += v
+		g.min = math.Min(g.min, v)
+		g.max = math.Max(g.max, v)
+	}
+
+	for _, g := range groups {
+		for _, fn := range agg.Functions {
+			var v interface{}
+			switch fn {
+			case config.MetricAggregateSum:
+				v = g.sum
+			case config.MetricAggregateAvg:
+				v = g.sum / float64(g.count)
+			case config.MetricAggregateMin:
+				v = g.min
+			case config.MetricAggregateMax:
+				v = g.max
+			case config.MetricAggregateCount:
+				v = uint64(g.count)
+			default:
+				continue
+			}
+			mtype := "n"
+			if fn == config.MetricAggregateCount {
+				mtype = "L"
+			}
+			tagList := append(append(tags.Tags{}, g.tags...), tags.Tag{Category: "aggregate", Value: fn})
+			out[tags.MetricNameWithStreamTags(g.name, tagList)] = cgm.Metric{Type: mtype, Value: v}
+		}
+	}
+
+	return out
+}
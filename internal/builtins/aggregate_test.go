@@ -0,0 +1,100 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package builtins
+
+import (
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestAggregate(t *testing.T) {
+	t.Log("Testing aggregate")
+
+	name := func(metric string, tagList ...tags.Tag) string {
+		return tags.MetricNameWithStreamTags(metric, tags.Tags(tagList))
+	}
+	units := tags.Tag{Category: "units", Value: "percent"}
+	cpu := func(id string) tags.Tag { return tags.Tag{Category: "cpu", Value: id} }
+
+	metrics := cgm.Metrics{
+		name("user", cpu("0"), units):   cgm.Metric{Type: "n", Value: 10.0},
+		name("user", cpu("1"), units):   cgm.Metric{Type: "n", Value: 30.0},
+		name("user", cpu("2"), units):   cgm.Metric{Type: "n", Value: 20.0},
+		name("ctxt", cpu("0")):          cgm.Metric{Type: "L", Value: uint64(100)},
+		name("ctxt", cpu("1")):          cgm.Metric{Type: "L", Value: uint64(50)},
+		name("user", units):             cgm.Metric{Type: "n", Value: 20.0},
+		name("model", cpu("0")):         cgm.Metric{Type: "s", Value: "xeon"},
+		"procs_running":                 cgm.Metric{Type: "L", Value: uint64(3)},
+		name("idle", units, cpu("all")): cgm.Metric{Type: "n", Value: 70.0},
+	}
+
+	t.Log("\tdefault functions")
+	{
+		agg := config.MetricAggregation{Category: "cpu", Functions: []string{"sum", "avg", "max"}}
+		out := aggregate(metrics, agg)
+
+		expect := map[string]float64{
+			name("user", units, tags.Tag{Category: "aggregate", Value: "sum"}): 60,
+			name("user", units, tags.Tag{Category: "aggregate", Value: "avg"}): 20,
+			name("user", units, tags.Tag{Category: "aggregate", Value: "max"}): 30,
+			name("ctxt", tags.Tag{Category: "aggregate", Value: "sum"}):        150,
+			name("idle", units, tags.Tag{Category: "aggregate", Value: "avg"}): 70,
+		}
+		for mn, v := range expect {
+			m, ok := out[mn]
+			if !ok {
+				t.Fatalf("expected %s in %v", mn, out)
+			}
+			if m.Type != "n" || m.Value.(float64) != v {
+				t.Fatalf("expected %s %v, got %#v", mn, v, m)
+			}
+		}
+
+		// per-instance metrics are replaced, others passed through
+		if _, ok := out[name("user", cpu("0"), units)]; ok {
+			t.Fatal("expected per-instance metric to be replaced")
+		}
+		for _, mn := range []string{name("user", units), name("model", cpu("0")), "procs_running"} {
+			if _, ok := out[mn]; !ok {
+				t.Fatalf("expected %s to be passed through", mn)
+			}
+		}
+		if len(out) != 3*3+3 {
+			t.Fatalf("expected 12 metrics, got %d (%v)", len(out), out)
+		}
+	}
+
+	t.Log("\tmin and count")
+	{
+		agg := config.MetricAggregation{Category: "cpu", Functions: []string{"min", "count"}}
+		out := aggregate(metrics, agg)
+
+		if m := out[name("user", units, tags.Tag{Category: "aggregate", Value: "min"})]; m.Value != 10.0 {
+			t.Fatalf("expected min 10, got %#v", m)
+		}
+		if m := out[name("ctxt", tags.Tag{Category: "aggregate", Value: "count"})]; m.Type != "L" || m.Value != uint64(2) {
+			t.Fatalf("expected count 2, got %#v", m)
+		}
+	}
+
+	t.Log("\tunencoded stream tags")
+	{
+		metrics := cgm.Metrics{
+			"usage|ST[container:a,units:bytes]": cgm.Metric{Type: "L", Value: uint64(1)},
+			"usage|ST[container:b,units:bytes]": cgm.Metric{Type: "L", Value: uint64(2)},
+		}
+		agg := config.MetricAggregation{Category: "container", Functions: []string{"sum"}}
+		out := aggregate(metrics, agg)
+
+		mn := name("usage", tags.Tag{Category: "units", Value: "bytes"}, tags.Tag{Category: "aggregate", Value: "sum"})
+		if m, ok := out[mn]; !ok || m.Value != 3.0 || len(out) != 1 {
+			t.Fatalf("expected %s 3, got %v", mn, out)
+		}
+	}
+}
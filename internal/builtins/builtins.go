@@ -8,12 +8,19 @@ package builtins
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/backup"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/flow"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/ntp"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/prometheus"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/syslog"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/ups"
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/watchdog"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	appstats "github.com/maier/go-appstats"
 	"github.com/pkg/errors"
@@ -24,9 +31,13 @@ import (
 
 // Builtins defines the internal metric collector manager
 type Builtins struct {
-	collectors map[string]collector.Collector
-	logger     zerolog.Logger
-	running    bool
+	collectors   map[string]collector.Collector
+	disabled     map[string]bool                     // collectors disabled at runtime (e.g. circonus-agentd ctl disable)
+	aggregations map[string]config.MetricAggregation // collectors with per-instance metrics aggregated (--metric-aggregate)
+	topKs        map[string]config.MetricTopK        // collectors with a top K instance selection (--metric-topk)
+	maintenance  []config.MaintenanceWindow          // scheduled windows pausing collectors (--maintenance-window)
+	logger       zerolog.Logger
+	running      bool
 	sync.Mutex
 }
 
@@ -34,17 +45,36 @@ type Builtins struct {
 func New(ctx context.Context) (*Builtins, error) {
 	b := Builtins{
 		collectors: make(map[string]collector.Collector),
+		disabled:   make(map[string]bool),
 		logger:     log.With().Str("pkg", "builtins").Logger(),
 	}
 
 	b.logger.Info().Msg("configuring builtins")
 
+	aggs, err := config.MetricAggregations()
+	if err != nil {
+		return nil, errors.Wrap(err, "metric aggregate config")
+	}
+	b.aggregations = aggs
+
+	topKs, err := config.MetricTopKs()
+	if err != nil {
+		return nil, errors.Wrap(err, "metric top k config")
+	}
+	b.topKs = topKs
+
+	windows, err := config.MaintenanceWindows()
+	if err != nil {
+		return nil, errors.Wrap(err, "maintenance window config")
+	}
+	b.maintenance = windows
+
 	if viper.GetBool(config.KeyClusterEnabled) && !viper.GetBool(config.KeyClusterEnableBuiltins) {
 		b.logger.Info().Msg("cluster mode - builtins disabled")
 		return &b, nil
 	}
 
-	err := b.configure(ctx)
+	err = b.configure(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "configuring builtins")
 	}
@@ -59,6 +89,60 @@ func New(ctx context.Context) (*Builtins, error) {
 		_ = appstats.IncrementInt("builtins.total")
 	}
 
+	// flow applies to all platforms
+	fc, err := flow.New(ctx, "")
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("flow collector, disabling")
+	} else {
+		b.logger.Info().Str("id", fc.ID()).Msg("enabled builtin")
+		b.collectors[fc.ID()] = fc
+		_ = appstats.IncrementInt("builtins.total")
+	}
+
+	// syslog applies to all platforms
+	sc, err := syslog.New(ctx, "")
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("syslog collector, disabling")
+	} else {
+		b.logger.Info().Str("id", sc.ID()).Msg("enabled builtin")
+		b.collectors[sc.ID()] = sc
+		_ = appstats.IncrementInt("builtins.total")
+	}
+
+	// backup applies to all platforms
+	bc, err := backup.New("")
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("backup collector, disabling")
+	} else {
+		b.logger.Info().Str("id", bc.ID()).Msg("enabled builtin")
+		b.collectors[bc.ID()] = bc
+		_ = appstats.IncrementInt("builtins.total")
+	}
+
+	// ntp applies to all platforms (not enabled by default)
+	ntpCollectors, err := ntp.New()
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("ntp collectors, disabling")
+	} else {
+		for _, c := range ntpCollectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled ntp builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	// ups applies to all platforms (not enabled by default)
+	upsCollectors, err := ups.New()
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("ups collectors, disabling")
+	} else {
+		for _, c := range upsCollectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled ups builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
 	return &b, nil
 }
 
@@ -87,34 +171,27 @@ func (b *Builtins) Run(ctx context.Context, id string) error {
 
 	var wg sync.WaitGroup
 
+	active := config.ActiveMaintenanceWindows(b.maintenance, start)
+
 	if id == "" {
-		wg.Add(len(b.collectors))
 		for id, c := range b.collectors {
-			clog := c.Logger()
-			clog.Debug().Msg("collecting")
-			go func(id string, c collector.Collector) {
-				err := c.Collect(ctx)
-				if err != nil {
-					clog.Error().Err(err).Msg(id)
-				}
-				clog.Debug().Str("duration", time.Since(start).String()).Msg("done")
-				wg.Done()
-			}(id, c)
+			if b.isDisabled(id) {
+				continue
+			}
+			if config.InMaintenance(active, id) {
+				b.logger.Debug().Str("id", id).Msg("builtin paused, maintenance window")
+				continue
+			}
+			b.collect(ctx, &wg, id, c, start)
 		}
 	} else {
 		c, ok := b.collectors[id]
-		if ok {
-			wg.Add(1)
-			clog := c.Logger()
-			clog.Debug().Msg("collecting")
-			go func(id string, c collector.Collector) {
-				err := c.Collect(ctx)
-				if err != nil {
-					clog.Error().Err(err).Msg(id)
-				}
-				clog.Debug().Str("duration", time.Since(start).String()).Msg("done")
-				wg.Done()
-			}(id, c)
+		if ok && b.isDisabled(id) {
+			b.logger.Debug().Str("id", id).Msg("builtin disabled")
+		} else if ok && config.InMaintenance(active, id) {
+			b.logger.Debug().Str("id", id).Msg("builtin paused, maintenance window")
+		} else if ok {
+			b.collect(ctx, &wg, id, c, start)
 		} else {
 			b.logger.Warn().Str("id", id).Msg("unknown builtin")
 		}
@@ -138,6 +215,117 @@ func (b *Builtins) Run(ctx context.Context, id string) error {
 	return nil
 }
 
+// collect runs a collector, the run is tracked by the watchdog. A restart of a
+// stuck run cancels the collector's context and stops waiting for it, so the
+// other collectors keep being run. The collector itself remains busy until its
+// Collect returns.
+func (b *Builtins) collect(ctx context.Context, wg *sync.WaitGroup, id string, c collector.Collector, start time.Time) {
+	wg.Add(1)
+	clog := c.Logger()
+	clog.Debug().Msg("collecting")
+
+	cctx, cancel := context.WithCancel(ctx)
+	var release sync.Once
+	done := watchdog.Track(watchdog.Builtins, id, 0, func() {
+		cancel()
+		release.Do(wg.Done)
+	})
+
+	go func() {
+		err := c.Collect(cctx)
+		if err != nil {
+			clog.Error().Err(err).Msg(id)
+		}
+		clog.Debug().Str("duration", time.Since(start).String()).Msg("done")
+		done()
+		cancel()
+		release.Do(wg.Done)
+	}()
+}
+
+// IDs returns the sorted ids of the enabled collectors
+func (b *Builtins) IDs() []string {
+	b.Lock()
+	defer b.Unlock()
+
+	ids := make([]string, 0, len(b.collectors))
+	for id := range b.collectors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// CollectorStatus defines the state of a collector for the /collectors endpoint
+type CollectorStatus struct {
+	collector.InventoryStats
+	Enabled bool `json:"enabled"`
+}
+
+// Collectors returns the status of the collectors, sorted by id
+func (b *Builtins) Collectors() []CollectorStatus {
+	b.Lock()
+	defer b.Unlock()
+
+	status := make([]CollectorStatus, 0, len(b.collectors))
+	for id, c := range b.collectors {
+		status = append(status, CollectorStatus{
+			InventoryStats: c.Inventory(),
+			Enabled:        !b.disabled[id],
+		})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].ID < status[j].ID })
+
+	return status
+}
+
+// SetEnabled enables or disables a collector at runtime, disabled collectors
+// are not run or flushed. Not persisted, a restart restores the configured
+// collectors.
+func (b *Builtins) SetEnabled(id string, enabled bool) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if _, ok := b.collectors[id]; !ok {
+		return errors.Errorf("unknown builtin (%s)", id)
+	}
+
+	if enabled {
+		delete(b.disabled, id)
+	} else {
+		b.disabled[id] = true
+	}
+	b.logger.Info().Str("id", id).Bool("enabled", enabled).Msg("builtin state changed")
+
+	return nil
+}
+
+// isDisabled determines if a collector has been disabled at runtime
+func (b *Builtins) isDisabled(id string) bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.disabled[id]
+}
+
+// CollectOne runs a single collector and returns its metrics, used for
+// one-off runs (e.g. profile-run), not the /run handling
+func (b *Builtins) CollectOne(ctx context.Context, id string) (cgm.Metrics, error) {
+	b.Lock()
+	c, ok := b.collectors[id]
+	b.Unlock()
+
+	if !ok {
+		return nil, errors.Errorf("unknown builtin (%s)", id)
+	}
+
+	if err := c.Collect(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.Flush(), nil
+}
+
 // IsBuiltin determines if an id is a builtin or not
 func (b *Builtins) IsBuiltin(id string) bool {
 	if id == "" {
@@ -165,17 +353,37 @@ func (b *Builtins) Flush(id string) *cgm.Metrics {
 		b.logger.Warn().Err(err).Msg("setting app stat")
 	}
 
-	metrics := cgm.Metrics{}
+	metrics := collector.GetMetrics()
 
 	if len(b.collectors) == 0 {
 		return &metrics // nothing to do
 	}
 
-	for _, c := range b.collectors {
-		for name, val := range c.Flush() {
+	active := config.ActiveMaintenanceWindows(b.maintenance, time.Now())
+
+	for id, c := range b.collectors {
+		if b.disabled[id] || config.InMaintenance(active, id) {
+			continue
+		}
+		cm := c.Flush()
+		if agg, ok := b.aggregations[id]; ok {
+			cm = aggregate(cm, agg)
+		} else if sel, ok := b.topKs[id]; ok {
+			cm = topK(cm, sel)
+		}
+		for name, val := range cm {
 			metrics[name] = val
 		}
 	}
 
 	return &metrics
 }
+
+// Release returns metrics obtained from Flush for reuse, once the caller is
+// done with them, avoiding regrowing a large map on every run
+func (b *Builtins) Release(metrics *cgm.Metrics) {
+	if metrics == nil {
+		return
+	}
+	collector.PutMetrics(*metrics)
+}
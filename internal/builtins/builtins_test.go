@@ -13,6 +13,7 @@ import (
 
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
@@ -304,3 +305,57 @@ func TestFlush(t *testing.T) {
 		}
 	}
 }
+
+func TestSetEnabled(t *testing.T) {
+	t.Log("Testing SetEnabled")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	b, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	b.collectors["foo"] = &foo{id: "foo", lastError: errors.New("none")}
+
+	t.Log("unknown")
+	{
+		if err := b.SetEnabled("bar", false); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("disable")
+	{
+		if err := b.SetEnabled("foo", false); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := b.Run(context.Background(), ""); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := b.Flush("")
+		if len(*metrics) > 0 {
+			t.Fatalf("expected empty metrics, got %#v", *metrics)
+		}
+		status := b.Collectors()
+		if len(status) != 1 || status[0].ID != "foo" || status[0].Enabled {
+			t.Fatalf("expected foo disabled, got %#v", status)
+		}
+	}
+
+	t.Log("enable")
+	{
+		if err := b.SetEnabled("foo", true); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := b.Run(context.Background(), ""); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := b.Flush("")
+		if len(*metrics) == 0 {
+			t.Fatal("expected metrics")
+		}
+		status := b.Collectors()
+		if len(status) != 1 || !status[0].Enabled {
+			t.Fatalf("expected foo enabled, got %#v", status)
+		}
+	}
+}
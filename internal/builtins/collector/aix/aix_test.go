@@ -0,0 +1,212 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package aix
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func TestCPUCollect(t *testing.T) {
+	t.Log("Testing CPU Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	orig := readCPUTotal
+	defer func() { readCPUTotal = orig }()
+
+	ct := cpuTotal{
+		ncpus:   4,
+		user:    600,
+		sys:     200,
+		idle:    3000,
+		wait:    200,
+		pswitch: 1000,
+		loadavg: [3]uint64{1 << sbits, 2 << sbits, 3 << sbits},
+	}
+	readCPUTotal = func() (*cpuTotal, error) { v := ct; return &v, nil }
+
+	c, err := NewCPUCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "num_cpu"); !ok || m.Value.(int) != 4 {
+		t.Fatalf("expected num_cpu 4, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "cpu_user", "units:centiseconds"); !ok || m.Value.(float64) != 150 {
+		t.Fatalf("expected cpu_user 150, got %v", m.Value)
+	}
+	// busy 800 of all 4000
+	if m, ok := testutil.FindMetric(metrics, "cpu_used", "units:percent"); !ok || m.Value.(float64) != 20 {
+		t.Fatalf("expected cpu_used 20, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "load_5min"); !ok || m.Value.(float64) != 2 {
+		t.Fatalf("expected load_5min 2, got %v", m.Value)
+	}
+
+	t.Log("\tused since last collection")
+	{
+		ct.user += 300
+		ct.idle += 700
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "cpu_used", "units:percent"); !ok || m.Value.(float64) != 30 {
+			t.Fatalf("expected cpu_used 30, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tperfstat error")
+	{
+		readCPUTotal = func() (*cpuTotal, error) { return nil, errors.New("boom") }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestVMCollect(t *testing.T) {
+	t.Log("Testing VM Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	orig := readMemoryTotal
+	defer func() { readMemoryTotal = orig }()
+
+	readMemoryTotal = func() (*memTotal, error) {
+		return &memTotal{
+			realTotal: 1000000,
+			realFree:  250000,
+			numperm:   100000,
+			pgspTotal: 200000,
+			pgspFree:  150000,
+			pgexct:    42,
+		}, nil
+	}
+
+	c, err := NewVMCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "memory_total", "units:bytes"); !ok || m.Value.(uint64) != 1000000*perfstatPageSize {
+		t.Fatalf("expected memory_total %d, got %v", 1000000*perfstatPageSize, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "memory_used", "units:percent"); !ok || m.Value.(float64) != 75 {
+		t.Fatalf("expected memory_used 75%%, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "file_cache", "units:bytes"); !ok || m.Value.(uint64) != 100000*perfstatPageSize {
+		t.Fatalf("expected file_cache %d, got %v", 100000*perfstatPageSize, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "swap_used", "units:percent"); !ok || m.Value.(float64) != 25 {
+		t.Fatalf("expected swap_used 25%%, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "pg_fault"); !ok || m.Value.(uint64) != 42 {
+		t.Fatalf("expected pg_fault 42, got %v", m.Value)
+	}
+
+	t.Log("\tno real memory")
+	{
+		readMemoryTotal = func() (*memTotal, error) { return &memTotal{}, nil }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestDiskCollect(t *testing.T) {
+	t.Log("Testing Disk Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	orig := readDisks
+	defer func() { readDisks = orig }()
+
+	readDisks = func() ([]diskStat, error) {
+		return []diskStat{
+			{name: "hdisk0", bsize: 512, xfers: 100, xrate: 60, rblks: 1000, wblks: 500},
+			{name: "cd0", bsize: 2048},
+		}, nil
+	}
+
+	c, err := NewDiskCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	dc := c.(*Disk)
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "writes", "disk:hdisk0", "units:operations"); !ok || m.Value.(uint64) != 40 {
+		t.Fatalf("expected hdisk0 writes 40, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "reads", "disk:hdisk0", "units:bytes"); !ok || m.Value.(uint64) != 512000 {
+		t.Fatalf("expected hdisk0 read bytes 512000, got %v", m.Value)
+	}
+
+	t.Log("\texclude cd0")
+	{
+		dc.exclude = regexp.MustCompile(fmt.Sprintf(regexPat, "cd[0-9]+"))
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if _, ok := testutil.FindMetric(metrics, "reads", "disk:cd0"); ok {
+			t.Fatal("expected cd0 to be excluded")
+		}
+	}
+}
+
+func TestNetIFCollect(t *testing.T) {
+	t.Log("Testing NetIF Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	orig := readNetInterfaces
+	defer func() { readNetInterfaces = orig }()
+
+	readNetInterfaces = func() ([]netIfStat, error) {
+		return []netIfStat{
+			{name: "en0", ibytes: 300000, ipackets: 200, oerrors: 2},
+			{name: "lo0", ibytes: 1000},
+		}, nil
+	}
+
+	c, err := NewNetIFCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "recv", "network-interface:en0", "units:bytes"); !ok || m.Value.(uint64) != 300000 {
+		t.Fatalf("expected en0 recv bytes 300000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "errors", "network-interface:en0", "direction:out"); !ok || m.Value.(uint64) != 2 {
+		t.Fatalf("expected en0 out errors 2, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "recv", "network-interface:lo0"); ok {
+		t.Fatal("expected lo0 to be excluded by default")
+	}
+}
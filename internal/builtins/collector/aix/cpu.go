@@ -0,0 +1,111 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package aix
+
+import (
+	"context"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// CPU metrics from perfstat_cpu_total
+type CPU struct {
+	common
+	lastAll  float64
+	lastBusy float64
+	haveLast bool
+}
+
+// cpuOptions defines what elements can be overridden in a config file
+type cpuOptions struct {
+	commonOptions
+}
+
+// readCPUTotal returns the perfstat cpu totals, overridden in tests
+var readCPUTotal = perfstatCPUTotal
+
+// NewCPUCollector creates new aix cpu collector
+func NewCPUCollector(cfgBaseName string) (collector.Collector, error) {
+	c := CPU{
+		common: newCommon(NameCPU, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts cpuOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from perfstat
+func (c *CPU) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	ct, err := readCPUTotal()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	if ct.ncpus == 0 {
+		err := errors.New("no cpus reported")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsCentiseconds := tags.Tag{Category: "units", Value: "centiseconds"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+	tagUnitsProcesses := tags.Tag{Category: "units", Value: "processes"}
+
+	// ncpus is the online logical cpus, it changes with smt mode and dlpar
+	numCPU := float64(ct.ncpus)
+	_ = c.addMetric(&metrics, "", "num_cpu", "I", int(ct.ncpus), tags.Tags{})
+
+	user, sys, idle, wait := float64(ct.user), float64(ct.sys), float64(ct.idle), float64(ct.wait)
+	_ = c.addMetric(&metrics, "", "cpu_user", "n", user/numCPU, tags.Tags{tagUnitsCentiseconds})
+	_ = c.addMetric(&metrics, "", "cpu_system", "n", sys/numCPU, tags.Tags{tagUnitsCentiseconds})
+	_ = c.addMetric(&metrics, "", "cpu_idle", "n", idle/numCPU, tags.Tags{tagUnitsCentiseconds})
+	_ = c.addMetric(&metrics, "", "cpu_iowait", "n", wait/numCPU, tags.Tags{tagUnitsCentiseconds})
+
+	busy := user + sys
+	all := busy + idle + wait
+	used := float64(0)
+	if c.haveLast {
+		if all > c.lastAll {
+			used = ((busy - c.lastBusy) / (all - c.lastAll)) * 100
+		}
+	} else if all > 0 {
+		used = (busy / all) * 100
+	}
+	_ = c.addMetric(&metrics, "", "cpu_used", "n", used, tags.Tags{tagUnitsPercent})
+	c.lastAll, c.lastBusy, c.haveLast = all, busy, true
+
+	_ = c.addMetric(&metrics, "", "ctxt", "L", ct.pswitch, tags.Tags{tags.Tag{Category: "units", Value: "switches"}})
+	_ = c.addMetric(&metrics, "", "interrupts", "L", ct.devintrs, tags.Tags{tags.Tag{Category: "units", Value: "interrupts"}})
+	_ = c.addMetric(&metrics, "", "soft_interrupts", "L", ct.softintrs, tags.Tags{tags.Tag{Category: "units", Value: "interrupts"}})
+	_ = c.addMetric(&metrics, "", "syscalls", "L", ct.syscall, tags.Tags{tags.Tag{Category: "units", Value: "calls"}})
+
+	// there is no generic load collector on aix, report the load averages here
+	loads := []string{"load_1min", "load_5min", "load_15min"}
+	for i, name := range loads {
+		_ = c.addMetric(&metrics, "", name, "n", float64(ct.loadavg[i])/float64(1<<sbits), tags.Tags{tagUnitsProcesses})
+	}
+	_ = c.addMetric(&metrics, "", "runqueue", "L", ct.runque, tags.Tags{tagUnitsProcesses})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
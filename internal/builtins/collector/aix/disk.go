@@ -0,0 +1,113 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package aix
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Disk metrics from perfstat_disk
+type Disk struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// diskOptions defines what elements can be overridden in a config file
+type diskOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// readDisks returns the perfstat disk statistics, overridden in tests
+var readDisks = perfstatDisks
+
+// NewDiskCollector creates new aix disk collector
+func NewDiskCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Disk{
+		common:  newCommon(NameDisk, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	var opts diskOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from perfstat
+func (c *Disk) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	disks, err := readDisks()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsOperations := tags.Tag{Category: "units", Value: "operations"}
+
+	for _, d := range disks {
+		if c.exclude.MatchString(d.name) || !c.include.MatchString(d.name) {
+			c.logger.Debug().Str("disk", d.name).Msg("excluded disk name, skipping")
+			continue
+		}
+
+		diskTag := tags.Tag{Category: "disk", Value: d.name}
+
+		// xrate is the subset of transfers which were reads
+		writes := uint64(0)
+		if d.xfers > d.xrate {
+			writes = d.xfers - d.xrate
+		}
+
+		_ = c.addMetric(&metrics, "", "reads", "L", d.xrate, tags.Tags{diskTag, tagUnitsOperations})
+		_ = c.addMetric(&metrics, "", "writes", "L", writes, tags.Tags{diskTag, tagUnitsOperations})
+		_ = c.addMetric(&metrics, "", "reads", "L", d.rblks*d.bsize, tags.Tags{diskTag, tagUnitsBytes})
+		_ = c.addMetric(&metrics, "", "writes", "L", d.wblks*d.bsize, tags.Tags{diskTag, tagUnitsBytes})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package aix
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// NetIF metrics from perfstat_netinterface
+type NetIF struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// netIFOptions defines what elements can be overridden in a config file
+type netIFOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// readNetInterfaces returns the perfstat interface statistics, overridden in tests
+var readNetInterfaces = perfstatNetInterfaces
+
+// NewNetIFCollector creates new aix if collector
+func NewNetIFCollector(cfgBaseName string) (collector.Collector, error) {
+	c := NetIF{
+		common:  newCommon(NameNetInterface, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: regexp.MustCompile(fmt.Sprintf(regexPat, "lo[0-9]*")),
+	}
+
+	var opts netIFOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from perfstat
+func (c *NetIF) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	ifaces, err := readNetInterfaces()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	unitBytesTag := tags.Tag{Category: "units", Value: "bytes"}
+	unitPacketsTag := tags.Tag{Category: "units", Value: "packets"}
+	dirInTag := tags.Tag{Category: "direction", Value: "in"}
+	dirOutTag := tags.Tag{Category: "direction", Value: "out"}
+
+	metricType := "L" // uint64
+	for _, ifs := range ifaces {
+		if c.exclude.MatchString(ifs.name) || !c.include.MatchString(ifs.name) {
+			c.logger.Debug().Str("iface", ifs.name).Msg("excluded iface name, skipping")
+			continue
+		}
+
+		stats := []struct {
+			name  string
+			value uint64
+			stags tags.Tags
+		}{
+			{name: "recv", value: ifs.ibytes, stags: tags.Tags{unitBytesTag}},
+			{name: "recv", value: ifs.ipackets, stags: tags.Tags{unitPacketsTag}},
+			{name: "errors", value: ifs.ierrors, stags: tags.Tags{dirInTag}},
+			{name: "drops", value: ifs.iqdrops, stags: tags.Tags{dirInTag, unitPacketsTag}},
+			{name: "sent", value: ifs.obytes, stags: tags.Tags{unitBytesTag}},
+			{name: "sent", value: ifs.opackets, stags: tags.Tags{unitPacketsTag}},
+			{name: "errors", value: ifs.oerrors, stags: tags.Tags{dirOutTag}},
+			{name: "collision", value: ifs.collisions, stags: tags.Tags{dirOutTag}},
+		}
+
+		for _, s := range stats {
+			tagList := tags.Tags{tags.Tag{Category: "network-interface", Value: ifs.name}}
+			tagList = append(tagList, s.stags...)
+			_ = c.addMetric(&metrics, "", s.name, metricType, s.value, tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
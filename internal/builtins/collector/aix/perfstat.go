@@ -0,0 +1,71 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package aix
+
+// cpuTotal perfstat_cpu_total_t values, times are clock ticks (1/100 second)
+type cpuTotal struct {
+	ncpus     uint64
+	user      uint64
+	sys       uint64
+	idle      uint64
+	wait      uint64
+	pswitch   uint64
+	syscall   uint64
+	devintrs  uint64
+	softintrs uint64
+	runque    uint64
+	loadavg   [3]uint64 // fixed point, scaled by 1<<sbits
+}
+
+// sbits perfstat loadavg fixed point scale (SBITS)
+const sbits = 16
+
+// memTotal perfstat_memory_total_t values, sizes are 4KB pages
+type memTotal struct {
+	realTotal   uint64
+	realFree    uint64
+	realPinned  uint64
+	realInuse   uint64
+	realSystem  uint64
+	realUser    uint64
+	realProcess uint64
+	numperm     uint64 // file cache
+	pgspTotal   uint64
+	pgspFree    uint64
+	pgexct      uint64 // page faults
+	pgins       uint64
+	pgouts      uint64
+	pgspins     uint64
+	pgspouts    uint64
+	scans       uint64
+	pgsteals    uint64
+}
+
+// perfstatPageSize size of the pages perfstat memory values are reported in
+const perfstatPageSize = 4096
+
+// diskStat perfstat_disk_t values
+type diskStat struct {
+	name  string
+	bsize uint64 // block size
+	xfers uint64 // transfers
+	xrate uint64 // transfers from disk (reads)
+	rblks uint64 // blocks read
+	wblks uint64 // blocks written
+}
+
+// netIfStat perfstat_netinterface_t values
+type netIfStat struct {
+	name       string
+	ipackets   uint64
+	ibytes     uint64
+	ierrors    uint64
+	opackets   uint64
+	obytes     uint64
+	oerrors    uint64
+	collisions uint64
+	iqdrops    uint64
+}
@@ -0,0 +1,141 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build aix,cgo
+
+package aix
+
+/*
+#cgo LDFLAGS: -lperfstat
+#include <libperfstat.h>
+*/
+import "C"
+
+import (
+	"github.com/pkg/errors"
+)
+
+// perfstatErr perfstat functions return -1 and set errno on failure
+func perfstatErr(fn string, err error) error {
+	if err == nil {
+		return errors.Errorf("%s failed", fn)
+	}
+	return errors.Wrap(err, fn)
+}
+
+func perfstatCPUTotal() (*cpuTotal, error) {
+	var ct C.perfstat_cpu_total_t
+	if rc, err := C.perfstat_cpu_total(nil, &ct, C.sizeof_perfstat_cpu_total_t, 1); rc <= 0 {
+		return nil, perfstatErr("perfstat_cpu_total", err)
+	}
+
+	return &cpuTotal{
+		ncpus:     uint64(ct.ncpus),
+		user:      uint64(ct.user),
+		sys:       uint64(ct.sys),
+		idle:      uint64(ct.idle),
+		wait:      uint64(ct.wait),
+		pswitch:   uint64(ct.pswitch),
+		syscall:   uint64(ct.syscall),
+		devintrs:  uint64(ct.devintrs),
+		softintrs: uint64(ct.softintrs),
+		runque:    uint64(ct.runque),
+		loadavg:   [3]uint64{uint64(ct.loadavg[0]), uint64(ct.loadavg[1]), uint64(ct.loadavg[2])},
+	}, nil
+}
+
+func perfstatMemoryTotal() (*memTotal, error) {
+	var mt C.perfstat_memory_total_t
+	if rc, err := C.perfstat_memory_total(nil, &mt, C.sizeof_perfstat_memory_total_t, 1); rc <= 0 {
+		return nil, perfstatErr("perfstat_memory_total", err)
+	}
+
+	return &memTotal{
+		realTotal:   uint64(mt.real_total),
+		realFree:    uint64(mt.real_free),
+		realPinned:  uint64(mt.real_pinned),
+		realInuse:   uint64(mt.real_inuse),
+		realSystem:  uint64(mt.real_system),
+		realUser:    uint64(mt.real_user),
+		realProcess: uint64(mt.real_process),
+		numperm:     uint64(mt.numperm),
+		pgspTotal:   uint64(mt.pgsp_total),
+		pgspFree:    uint64(mt.pgsp_free),
+		pgexct:      uint64(mt.pgexct),
+		pgins:       uint64(mt.pgins),
+		pgouts:      uint64(mt.pgouts),
+		pgspins:     uint64(mt.pgspins),
+		pgspouts:    uint64(mt.pgspouts),
+		scans:       uint64(mt.scans),
+		pgsteals:    uint64(mt.pgsteals),
+	}, nil
+}
+
+func perfstatDisks() ([]diskStat, error) {
+	// a nil id and buffer returns the number of disks
+	n, err := C.perfstat_disk(nil, nil, C.sizeof_perfstat_disk_t, 0)
+	if n < 0 {
+		return nil, perfstatErr("perfstat_disk count", err)
+	}
+	if n == 0 {
+		return []diskStat{}, nil
+	}
+
+	disks := make([]C.perfstat_disk_t, n)
+	var first C.perfstat_id_t // empty name, FIRST_DISK
+	n, err = C.perfstat_disk(&first, &disks[0], C.sizeof_perfstat_disk_t, n)
+	if n < 0 {
+		return nil, perfstatErr("perfstat_disk", err)
+	}
+
+	stats := make([]diskStat, 0, n)
+	for _, d := range disks[:n] {
+		stats = append(stats, diskStat{
+			name:  C.GoString(&d.name[0]),
+			bsize: uint64(d.bsize),
+			xfers: uint64(d.xfers),
+			xrate: uint64(d.xrate),
+			rblks: uint64(d.rblks),
+			wblks: uint64(d.wblks),
+		})
+	}
+
+	return stats, nil
+}
+
+func perfstatNetInterfaces() ([]netIfStat, error) {
+	// a nil id and buffer returns the number of interfaces
+	n, err := C.perfstat_netinterface(nil, nil, C.sizeof_perfstat_netinterface_t, 0)
+	if n < 0 {
+		return nil, perfstatErr("perfstat_netinterface count", err)
+	}
+	if n == 0 {
+		return []netIfStat{}, nil
+	}
+
+	ifaces := make([]C.perfstat_netinterface_t, n)
+	var first C.perfstat_id_t // empty name, FIRST_NETINTERFACE
+	n, err = C.perfstat_netinterface(&first, &ifaces[0], C.sizeof_perfstat_netinterface_t, n)
+	if n < 0 {
+		return nil, perfstatErr("perfstat_netinterface", err)
+	}
+
+	stats := make([]netIfStat, 0, n)
+	for _, i := range ifaces[:n] {
+		stats = append(stats, netIfStat{
+			name:       C.GoString(&i.name[0]),
+			ipackets:   uint64(i.ipackets),
+			ibytes:     uint64(i.ibytes),
+			ierrors:    uint64(i.ierrors),
+			opackets:   uint64(i.opackets),
+			obytes:     uint64(i.obytes),
+			oerrors:    uint64(i.oerrors),
+			collisions: uint64(i.collisions),
+			iqdrops:    uint64(i.if_iqdrops),
+		})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,31 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !aix !cgo
+
+package aix
+
+import (
+	"github.com/pkg/errors"
+)
+
+// libperfstat is only available on AIX, with cgo
+var errNoPerfstat = errors.New("libperfstat requires an AIX agent built with cgo")
+
+func perfstatCPUTotal() (*cpuTotal, error) {
+	return nil, errNoPerfstat
+}
+
+func perfstatMemoryTotal() (*memTotal, error) {
+	return nil, errNoPerfstat
+}
+
+func perfstatDisks() ([]diskStat, error) {
+	return nil, errNoPerfstat
+}
+
+func perfstatNetInterfaces() ([]netIfStat, error) {
+	return nil, errNoPerfstat
+}
@@ -0,0 +1,123 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package aix
+
+import (
+	"context"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// VM metrics from perfstat_memory_total
+type VM struct {
+	common
+}
+
+// vmOptions defines what elements can be overridden in a config file
+type vmOptions struct {
+	commonOptions
+}
+
+// readMemoryTotal returns the perfstat memory totals, overridden in tests
+var readMemoryTotal = perfstatMemoryTotal
+
+// NewVMCollector creates new aix vm collector
+func NewVMCollector(cfgBaseName string) (collector.Collector, error) {
+	c := VM{
+		common: newCommon(NameVM, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts vmOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from perfstat
+func (c *VM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	mt, err := readMemoryTotal()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	if mt.realTotal == 0 {
+		err := errors.New("invalid real memory total (0)")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+	tagUnitsFaults := tags.Tag{Category: "units", Value: "faults"}
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+
+	memStats := []struct {
+		name  string
+		pages uint64
+	}{
+		{"pinned", mt.realPinned},
+		{"system", mt.realSystem},
+		{"user", mt.realUser},
+		{"process", mt.realProcess},
+		{"file_cache", mt.numperm},
+	}
+	for _, s := range memStats {
+		_ = c.addMetric(&metrics, "", s.name, "L", s.pages*perfstatPageSize, tags.Tags{tagUnitsBytes})
+	}
+
+	total, free := mt.realTotal, mt.realFree
+	if free > total {
+		free = total
+	}
+	used := total - free
+	_ = c.addMetric(&metrics, "", "memory_total", "L", total*perfstatPageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(&metrics, "", "memory_free", "L", free*perfstatPageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(&metrics, "", "memory_used", "L", used*perfstatPageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(&metrics, "", "memory_used", "n", (float64(used)/float64(total))*100, tags.Tags{tagUnitsPercent})
+	_ = c.addMetric(&metrics, "", "memory_free", "n", (float64(free)/float64(total))*100, tags.Tags{tagUnitsPercent})
+
+	// paging space
+	swapTotal, swapFree := mt.pgspTotal, mt.pgspFree
+	if swapFree > swapTotal {
+		swapFree = swapTotal
+	}
+	swapUsed := swapTotal - swapFree
+	swapUsedPct := float64(0)
+	if swapTotal > 0 {
+		swapUsedPct = (float64(swapUsed) / float64(swapTotal)) * 100
+	}
+	_ = c.addMetric(&metrics, "", "swap_total", "L", swapTotal*perfstatPageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(&metrics, "", "swap_used", "L", swapUsed*perfstatPageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(&metrics, "", "swap_used", "n", swapUsedPct, tags.Tags{tagUnitsPercent})
+	_ = c.addMetric(&metrics, "", "swap_free", "L", swapFree*perfstatPageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(&metrics, "", "swap_free", "n", 100-swapUsedPct, tags.Tags{tagUnitsPercent})
+
+	_ = c.addMetric(&metrics, "", "pg_fault", "L", mt.pgexct, tags.Tags{tagUnitsFaults})
+	_ = c.addMetric(&metrics, "", "pg_page_in", "L", mt.pgins, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_page_out", "L", mt.pgouts, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_swap_in", "L", mt.pgspins, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_swap_out", "L", mt.pgspouts, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_scan", "L", mt.scans, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_steal", "L", mt.pgsteals, tags.Tags{tagUnitsPages})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package backup builtin backup job result collector, parses the job status
+// reported by backup tools (cli output or log files, e.g. NetBackup
+// bpdbjobs) into per job success, exit status, bytes and duration metrics
+package backup
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	NameBackup  = "backup"
+	PackageName = "builtins.backup"
+)
+
+// Backup metrics from backup job status sources
+type Backup struct {
+	common
+	sources []*source
+}
+
+// backupOptions defines what elements can be overridden in a config file
+type backupOptions struct {
+	commonOptions
+
+	// collector specific
+	Sources []SourceDef `json:"sources" toml:"sources" yaml:"sources"`
+}
+
+// New creates new backup collector
+func New(cfgBaseName string) (collector.Collector, error) {
+	c := Backup{
+		common: newCommon(NameBackup, tags.FromList(tags.GetBaseTags())),
+	}
+
+	// Backup is a special builtin, like prom it requires a configuration
+	// file, it is only enabled when sources are explicitly configured.
+	// The default config is a file named backup_collector.(json|toml|yaml)
+	// located in the agent's default etc path.
+	if cfgBaseName == "" {
+		cfgBaseName = path.Join(defaults.EtcPath, "backup_collector")
+	}
+
+	var opts backupOptions
+	if err := config.LoadConfigFile(cfgBaseName, &opts); err != nil {
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Str("base", cfgBaseName).Interface("config", opts).Msg("loaded config")
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if len(opts.Sources) == 0 {
+		return nil, errors.Errorf("%s no sources configured", c.pkgID)
+	}
+
+	for _, def := range opts.Sources {
+		s, err := newSource(def)
+		if err != nil {
+			return nil, errors.Wrap(err, c.pkgID)
+		}
+		c.sources = append(c.sources, s)
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the backup job sources
+func (c *Backup) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsSeconds := tags.Tag{Category: "units", Value: "seconds"}
+	tagUnitsJobs := tags.Tag{Category: "units", Value: "jobs"}
+
+	now := time.Now()
+	for _, s := range c.sources {
+		sourceTag := tags.Tag{Category: "backup-source", Value: s.name}
+
+		data, err := s.read(ctx)
+		if err != nil {
+			// one failing source does not prevent reporting the others
+			c.logger.Warn().Err(err).Str("source", s.name).Msg("reading backup job status")
+			_ = c.addMetric(&metrics, "", "source_ok", "I", 0, tags.Tags{sourceTag})
+			continue
+		}
+		_ = c.addMetric(&metrics, "", "source_ok", "I", 1, tags.Tags{sourceTag})
+
+		results := s.parse(data)
+		failed := 0
+		for _, r := range results {
+			jobTags := tags.Tags{sourceTag, tags.Tag{Category: "backup-job", Value: r.job}}
+			jobTags = append(jobTags, r.tags...)
+
+			success := 0
+			if r.success {
+				success = 1
+			} else {
+				failed++
+			}
+			_ = c.addMetric(&metrics, "", "job_success", "I", success, jobTags)
+
+			if status, err := strconv.ParseInt(r.status, 10, 32); err == nil {
+				_ = c.addMetric(&metrics, "", "job_status", "i", status, jobTags)
+			}
+			if r.hasBytes {
+				_ = c.addMetric(&metrics, "", "job_bytes", "L", round(r.bytes), append(tags.Tags{tagUnitsBytes}, jobTags...))
+			}
+			if r.hasDur {
+				_ = c.addMetric(&metrics, "", "job_duration", "n", r.duration, append(tags.Tags{tagUnitsSeconds}, jobTags...))
+			}
+			if !r.end.IsZero() {
+				_ = c.addMetric(&metrics, "", "job_age", "L", round(now.Sub(r.end).Seconds()), append(tags.Tags{tagUnitsSeconds}, jobTags...))
+			}
+		}
+
+		_ = c.addMetric(&metrics, "", "jobs", "I", len(results), tags.Tags{sourceTag, tagUnitsJobs})
+		_ = c.addMetric(&metrics, "", "jobs_failed", "I", failed, tags.Tags{sourceTag, tagUnitsJobs})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package backup
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tmissing config")
+	{
+		if _, err := New(filepath.Join("testdata", "missing")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tbad source")
+	{
+		if _, err := New(filepath.Join("testdata", "bad_source")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tvalid")
+	{
+		c, err := New(filepath.Join("testdata", "backup_collector"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(c.(*Backup).sources) != 2 {
+			t.Fatalf("expected 2 sources, got %d", len(c.(*Backup).sources))
+		}
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	t.Log("Testing newSource")
+
+	tests := []struct {
+		desc string
+		def  SourceDef
+	}{
+		{"no name", SourceDef{File: "x", Match: "(?P<job>a)(?P<status>b)"}},
+		{"no command or file", SourceDef{Name: "x", Match: "(?P<job>a)(?P<status>b)"}},
+		{"command and file", SourceDef{Name: "x", Command: "x", File: "x", Match: "(?P<job>a)(?P<status>b)"}},
+		{"no match", SourceDef{Name: "x", File: "x"}},
+		{"invalid match", SourceDef{Name: "x", File: "x", Match: "("}},
+		{"no job group", SourceDef{Name: "x", File: "x", Match: "(?P<status>b)"}},
+		{"invalid success", SourceDef{Name: "x", File: "x", Match: "(?P<job>a)(?P<status>b)", Success: "("}},
+		{"invalid bytes scale", SourceDef{Name: "x", File: "x", Match: "(?P<job>a)(?P<status>b)", BytesScale: -1}},
+		{"invalid timeout", SourceDef{Name: "x", Command: "x", Match: "(?P<job>a)(?P<status>b)", Timeout: "x"}},
+		{"unknown tag group", SourceDef{Name: "x", File: "x", Match: "(?P<job>a)(?P<status>b)", Tags: []string{"client"}}},
+	}
+
+	for _, test := range tests {
+		t.Logf("\t%s", test.desc)
+		if _, err := newSource(test.def); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	t.Log("Testing parseDuration")
+
+	tests := []struct {
+		s      string
+		expect float64
+		ok     bool
+	}{
+		{"90", 90, true},
+		{"1.5", 1.5, true},
+		{"01:02:03", 3723, true},
+		{"02:03", 123, true},
+		{"1h30m", 5400, true},
+		{"", 0, false},
+		{"a:b", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		v, ok := parseDuration(test.s)
+		if ok != test.ok || v != test.expect {
+			t.Fatalf("%q expected %v (%v), got %v (%v)", test.s, test.expect, test.ok, v, ok)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Log("Testing Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	orig := runCommand
+	defer func() { runCommand = orig }()
+	runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join("testdata", "bpdbjobs.txt"))
+	}
+
+	c, err := New(filepath.Join("testdata", "backup_collector"))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	nbu := "backup-source:netbackup"
+
+	// newest first, the latest end time wins (job 1201 not 1100), active job 1203 is not done
+	if m, ok := testutil.FindMetric(metrics, "job_success", nbu, "backup-job:prod_db", "client:db01"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected prod_db success, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "job_bytes", nbu, "backup-job:prod_db"); !ok || m.Value.(uint64) != 2048000*1024 {
+		t.Fatalf("expected prod_db bytes %d, got %v", 2048000*1024, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "job_duration", nbu, "backup-job:prod_db"); !ok || m.Value.(float64) != 3600 {
+		t.Fatalf("expected prod_db duration 3600, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "job_age", nbu, "backup-job:prod_db"); !ok {
+		t.Fatal("expected prod_db job_age")
+	}
+	if m, ok := testutil.FindMetric(metrics, "job_status", nbu, "backup-job:prod_files"); !ok || m.Value.(int64) != 96 {
+		t.Fatalf("expected prod_files status 96, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "jobs_failed", nbu); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected 1 failed netbackup job, got %v", m.Value)
+	}
+
+	nightly := "backup-source:nightly"
+
+	if m, ok := testutil.FindMetric(metrics, "job_success", nightly, "backup-job:home"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected home success, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "job_duration", nightly, "backup-job:home"); !ok || m.Value.(float64) != 600 {
+		t.Fatalf("expected home duration 600, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "job_status", nightly); ok {
+		t.Fatal("expected no job_status for non-numeric status")
+	}
+	if m, ok := testutil.FindMetric(metrics, "jobs", nightly); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected 2 nightly jobs, got %v", m.Value)
+	}
+
+	t.Log("\tcommand error")
+	{
+		runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) { return nil, errors.New("boom") }
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "source_ok", nbu); !ok || m.Value.(int) != 0 {
+			t.Fatalf("expected netbackup source_ok 0, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, "source_ok", nightly); !ok || m.Value.(int) != 1 {
+			t.Fatalf("expected nightly source_ok 1, got %v", m.Value)
+		}
+	}
+}
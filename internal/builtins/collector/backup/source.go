@@ -0,0 +1,322 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/pkg/errors"
+)
+
+// SourceDef defines a backup job status source, the output of a command
+// (e.g. `bpdbjobs -report -most_columns`) or a log file, parsed line by line
+type SourceDef struct {
+	Name       string   `json:"name" toml:"name" yaml:"name"`
+	Command    string   `json:"command" toml:"command" yaml:"command"`
+	Args       []string `json:"args" toml:"args" yaml:"args"`
+	File       string   `json:"file" toml:"file" yaml:"file"`
+	Match      string   `json:"match" toml:"match" yaml:"match"`
+	Success    string   `json:"success" toml:"success" yaml:"success"`
+	BytesScale float64  `json:"bytes_scale" toml:"bytes_scale" yaml:"bytes_scale"`
+	Tags       []string `json:"tags" toml:"tags" yaml:"tags"`
+	Timeout    string   `json:"timeout" toml:"timeout" yaml:"timeout"`
+}
+
+const (
+	// named capture groups of the match regular expression
+	groupJob      = "job"      // required, job name
+	groupStatus   = "status"   // required, exit state/status of the job
+	groupBytes    = "bytes"    // optional, bytes (scaled by bytes_scale) written
+	groupDuration = "duration" // optional, seconds, [hh:]mm:ss or go duration
+	groupEnd      = "end"      // optional, job end time, unix epoch seconds or RFC3339
+
+	defaultSuccess = `(?i)^(0|ok|success|successful|succeeded|completed)$`
+	defaultTimeout = 30 * time.Second
+
+	// maxReadBytes only the end of log files is parsed
+	maxReadBytes = 1024 * 1024
+
+	// maxJobs limits the jobs (job and tag combinations) tracked per source
+	maxJobs = 1000
+)
+
+// source a compiled backup job status source
+type source struct {
+	name       string
+	command    string
+	args       []string
+	file       string
+	match      *regexp.Regexp
+	success    *regexp.Regexp
+	bytesScale float64
+	timeout    time.Duration
+	jobIdx     int
+	statusIdx  int
+	bytesIdx   int
+	durIdx     int
+	endIdx     int
+	tagNames   []string
+	tagIdx     []int
+}
+
+// jobResult the most recent result of a job
+type jobResult struct {
+	job      string
+	tags     tags.Tags
+	status   string
+	success  bool
+	bytes    float64
+	hasBytes bool
+	duration float64
+	hasDur   bool
+	end      time.Time
+}
+
+// runCommand runs a backup tool cli and returns its output, overridden in tests
+var runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s %s", cmd, strings.Join(args, " "))
+	}
+	return out, nil
+}
+
+// newSource compiles a source definition
+func newSource(def SourceDef) (*source, error) {
+	if def.Name == "" {
+		return nil, errors.New("source name is required")
+	}
+	if (def.Command == "") == (def.File == "") {
+		return nil, errors.Errorf("source %s requires one of command or file", def.Name)
+	}
+	if def.Match == "" {
+		return nil, errors.Errorf("source %s match is required", def.Name)
+	}
+
+	s := source{
+		name:       def.Name,
+		command:    def.Command,
+		args:       def.Args,
+		file:       def.File,
+		bytesScale: 1,
+		timeout:    defaultTimeout,
+	}
+
+	rx, err := regexp.Compile(def.Match)
+	if err != nil {
+		return nil, errors.Wrapf(err, "source %s compiling match", def.Name)
+	}
+	s.match = rx
+
+	if s.jobIdx = subexpIndex(rx, groupJob); s.jobIdx == -1 {
+		return nil, errors.Errorf("source %s match requires a (?P<%s>) group", def.Name, groupJob)
+	}
+	if s.statusIdx = subexpIndex(rx, groupStatus); s.statusIdx == -1 {
+		return nil, errors.Errorf("source %s match requires a (?P<%s>) group", def.Name, groupStatus)
+	}
+	s.bytesIdx = subexpIndex(rx, groupBytes)
+	s.durIdx = subexpIndex(rx, groupDuration)
+	s.endIdx = subexpIndex(rx, groupEnd)
+
+	success := defaultSuccess
+	if def.Success != "" {
+		success = def.Success
+	}
+	if s.success, err = regexp.Compile(success); err != nil {
+		return nil, errors.Wrapf(err, "source %s compiling success", def.Name)
+	}
+
+	if def.BytesScale < 0 {
+		return nil, errors.Errorf("source %s invalid bytes_scale (%v)", def.Name, def.BytesScale)
+	} else if def.BytesScale > 0 {
+		s.bytesScale = def.BytesScale
+	}
+
+	if def.Timeout != "" {
+		if s.timeout, err = time.ParseDuration(def.Timeout); err != nil {
+			return nil, errors.Wrapf(err, "source %s parsing timeout", def.Name)
+		}
+	}
+
+	for _, name := range def.Tags {
+		idx := subexpIndex(rx, name)
+		if idx == -1 {
+			return nil, errors.Errorf("source %s tag group (%s) not in match", def.Name, name)
+		}
+		s.tagNames = append(s.tagNames, name)
+		s.tagIdx = append(s.tagIdx, idx)
+	}
+
+	return &s, nil
+}
+
+// read returns the command output or the end of the log file
+func (s *source) read(ctx context.Context) ([]byte, error) {
+	if s.command != "" {
+		cctx, cancel := context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+		return runCommand(cctx, s.command, s.args...)
+	}
+
+	f, err := os.Open(s.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() > maxReadBytes {
+		if _, err := f.Seek(-maxReadBytes, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() > maxReadBytes {
+		// skip the partial first line
+		if idx := bytes.IndexByte(data, '\n'); idx != -1 {
+			data = data[idx+1:]
+		}
+	}
+	return data, nil
+}
+
+// parse returns the most recent result of each job in data, the result
+// with the latest end time, or the last matching line when there is no end
+func (s *source) parse(data []byte) []*jobResult {
+	jobs := make(map[string]*jobResult)
+	order := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxReadBytes)
+	for scanner.Scan() {
+		m := s.match.FindStringSubmatch(scanner.Text())
+		if m == nil || m[s.jobIdx] == "" {
+			continue
+		}
+
+		r := &jobResult{
+			job:     m[s.jobIdx],
+			status:  m[s.statusIdx],
+			success: s.success.MatchString(m[s.statusIdx]),
+		}
+		if s.bytesIdx != -1 {
+			if v, err := strconv.ParseFloat(m[s.bytesIdx], 64); err == nil {
+				r.bytes = v * s.bytesScale
+				r.hasBytes = true
+			}
+		}
+		if s.durIdx != -1 {
+			if v, ok := parseDuration(m[s.durIdx]); ok {
+				r.duration = v
+				r.hasDur = true
+			}
+		}
+		if s.endIdx != -1 {
+			r.end = parseTime(m[s.endIdx])
+		}
+
+		var key strings.Builder
+		key.WriteString(r.job)
+		for i, idx := range s.tagIdx {
+			key.WriteByte(',')
+			key.WriteString(s.tagNames[i])
+			key.WriteByte('=')
+			key.WriteString(m[idx])
+			r.tags = append(r.tags, tags.Tag{Category: s.tagNames[i], Value: m[idx]})
+		}
+
+		prev, ok := jobs[key.String()]
+		if !ok {
+			if len(jobs) >= maxJobs {
+				continue
+			}
+			order = append(order, key.String())
+		} else if !r.end.IsZero() && r.end.Before(prev.end) {
+			continue // older run
+		}
+		jobs[key.String()] = r
+	}
+
+	results := make([]*jobResult, 0, len(order))
+	for _, k := range order {
+		results = append(results, jobs[k])
+	}
+	return results
+}
+
+// parseDuration parses seconds, [hh:]mm:ss or a go duration into seconds
+func parseDuration(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, true
+	}
+	if strings.Contains(s, ":") {
+		secs := float64(0)
+		for _, part := range strings.Split(s, ":") {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0, false
+			}
+			secs = secs*60 + v
+		}
+		return secs, true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d.Seconds(), true
+	}
+	return 0, false
+}
+
+// parseTime parses unix epoch seconds or RFC3339, zero time if invalid
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil && v > 0 {
+		return time.Unix(v, 0)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// round returns v rounded to the nearest uint64
+func round(v float64) uint64 {
+	if v <= 0 {
+		return 0
+	}
+	return uint64(math.Round(v))
+}
+
+// subexpIndex returns the index of the named capture group, -1 if not found
+func subexpIndex(rx *regexp.Regexp, name string) int {
+	for i, n := range rx.SubexpNames() {
+		if n != "" && n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,141 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"context"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Battery metrics from the IOKit AppleSmartBattery
+type Battery struct {
+	common
+}
+
+// batteryOptions defines what elements can be overridden in a config file
+type batteryOptions struct {
+	commonOptions
+}
+
+const batteryClass = "AppleSmartBattery"
+
+// NewBatteryCollector creates new darwin battery collector, an error is
+// returned if the system does not have a battery
+func NewBatteryCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Battery{
+		common: newCommon(NameBattery, tags.FromList(tags.GetBaseTags())),
+	}
+
+	entries, err := readIOReg(context.Background(), batteryClass)
+	if err != nil {
+		return nil, errors.Wrap(err, c.pkgID)
+	}
+	if batteryEntry(entries) == nil {
+		return nil, errors.Errorf("%s no battery found", c.pkgID)
+	}
+
+	var opts batteryOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the IOKit registry
+func (c *Battery) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	entries, err := readIOReg(ctx, batteryClass)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	b := batteryEntry(entries)
+	if b == nil {
+		err := errors.New("no battery found")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	// capacities are mAh on intel systems, MaxCapacity is 100 (percent) on
+	// apple silicon where AppleRawMaxCapacity holds the mAh value
+	current, cok := b.uint("CurrentCapacity")
+	maxCap, mok := b.uint("MaxCapacity")
+	if cok && mok && maxCap > 0 {
+		_ = c.addMetric(&metrics, "", "charge", "n", (float64(current)/float64(maxCap))*100, tags.Tags{tagUnitsPercent})
+	}
+	rawMax, ok := b.uint("AppleRawMaxCapacity")
+	if !ok {
+		rawMax = maxCap
+	}
+	if design, ok := b.uint("DesignCapacity"); ok && design > 0 && rawMax > 0 {
+		_ = c.addMetric(&metrics, "", "health", "n", (float64(rawMax)/float64(design))*100, tags.Tags{tagUnitsPercent})
+	}
+
+	if v, ok := b.uint("CycleCount"); ok {
+		_ = c.addMetric(&metrics, "", "cycle_count", "L", v, tags.Tags{tags.Tag{Category: "units", Value: "cycles"}})
+	}
+	if v, ok := b.uint("Temperature"); ok {
+		// hundredths of a degree celsius
+		_ = c.addMetric(&metrics, "", "temperature", "n", float64(v)/100, tags.Tags{tags.Tag{Category: "units", Value: "celsius"}})
+	}
+	if v, ok := b.uint("Voltage"); ok {
+		_ = c.addMetric(&metrics, "", "voltage", "L", v, tags.Tags{tags.Tag{Category: "units", Value: "millivolts"}})
+	}
+	if v, ok := b.int("Amperage"); ok {
+		// negative when discharging
+		_ = c.addMetric(&metrics, "", "amperage", "l", v, tags.Tags{tags.Tag{Category: "units", Value: "milliamperes"}})
+	}
+	if v, ok := b.uint("TimeRemaining"); ok && v < 0xffff {
+		_ = c.addMetric(&metrics, "", "time_remaining", "L", v, tags.Tags{tags.Tag{Category: "units", Value: "minutes"}})
+	}
+
+	states := []struct {
+		prop string
+		name string
+	}{
+		{"IsCharging", "charging"},
+		{"ExternalConnected", "external_power"},
+		{"FullyCharged", "fully_charged"},
+	}
+	for _, s := range states {
+		if v, ok := b.bool(s.prop); ok {
+			state := 0
+			if v {
+				state = 1
+			}
+			_ = c.addMetric(&metrics, "", s.name, "I", state, tags.Tags{})
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// batteryEntry returns the first battery registry entry
+func batteryEntry(entries []*ioregEntry) *ioregEntry {
+	for _, e := range entries {
+		if e.class == batteryClass {
+			return e
+		}
+	}
+	return nil
+}
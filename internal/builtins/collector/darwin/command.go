@@ -0,0 +1,29 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ioregPath  = "/usr/sbin/ioreg"
+	pmsetPath  = "/usr/bin/pmset"
+	vmStatPath = "/usr/bin/vm_stat"
+)
+
+// runCommand runs a system utility and returns its output, overridden in tests
+var runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s %s", cmd, strings.Join(args, " "))
+	}
+	return out, nil
+}
@@ -0,0 +1,178 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// CPU metrics from host_processor_info
+type CPU struct {
+	common
+	reportAllCPUs bool                  // OPT report all cpus (vs just total) may be overridden in config file
+	lastRunValues map[string]lastValues // values from last run
+}
+
+// cpuOptions defines what elements can be overridden in a config file
+type cpuOptions struct {
+	commonOptions
+
+	// collector specific
+	AllCPU string `json:"report_all_cpus" toml:"report_all_cpus" yaml:"report_all_cpus"`
+}
+
+type lastValues struct {
+	all  float64
+	busy float64
+}
+
+// cpu states (mach/machine.h CPU_STATE_*)
+const (
+	cpuStateUser = iota
+	cpuStateSystem
+	cpuStateIdle
+	cpuStateNice
+	cpuStates
+)
+
+// cpuTicks state ticks of one cpu, ticks are 1/100 second (CLK_TCK)
+type cpuTicks [cpuStates]uint64
+
+// readCPUTicks returns the per cpu state ticks, overridden in tests
+var readCPUTicks = hostCPUTicks
+
+// NewCPUCollector creates new darwin cpu collector
+func NewCPUCollector(cfgBaseName string) (collector.Collector, error) {
+	c := CPU{
+		common:        newCommon(NameCPU, tags.FromList(tags.GetBaseTags())),
+		lastRunValues: make(map[string]lastValues),
+	}
+
+	if _, err := readCPUTicks(); err != nil {
+		return nil, errors.Wrap(err, c.pkgID)
+	}
+
+	var opts cpuOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.AllCPU != "" {
+		rpt, err := strconv.ParseBool(opts.AllCPU)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing report_all_cpus", c.pkgID)
+		}
+		c.reportAllCPUs = rpt
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from host_processor_info
+func (c *CPU) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	perCPU, err := readCPUTicks()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	if len(perCPU) == 0 {
+		err := errors.New("no cpus found")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsCentiseconds := tags.Tag{Category: "units", Value: "centiseconds"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	numCPU := len(perCPU)
+	_ = c.addMetric(&metrics, "", "num_cpu", "I", numCPU, tags.Tags{})
+
+	var total cpuTicks
+	cpus := make(map[string]cpuTicks, numCPU+1)
+	for i, ticks := range perCPU {
+		for state, v := range ticks {
+			total[state] += v
+		}
+		if c.reportAllCPUs {
+			cpus[strconv.Itoa(i)] = ticks
+		}
+	}
+	cpus[""] = total
+
+	for id, ticks := range cpus {
+		n := float64(numCPU) // aggregate cpu metrics
+		if id != "" {
+			n = 1 // individual cpu metrics
+		}
+
+		for mn, mv := range c.cpuMetrics(id, ticks, n) {
+			var tagList tags.Tags
+
+			if id != "" {
+				tagList = append(tagList, tags.Tag{Category: "cpu", Value: id})
+			}
+
+			if mn == "cpu_used" {
+				tagList = append(tagList, tagUnitsPercent)
+			} else {
+				tagList = append(tagList, tagUnitsCentiseconds)
+			}
+
+			_ = c.addMetric(&metrics, "", mn, mv.Type, mv.Value, tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// cpuMetrics calculates metrics from the cpu state ticks
+func (c *CPU) cpuMetrics(id string, ticks cpuTicks, numCPU float64) cgm.Metrics {
+	metricType := "n" // resmon double
+
+	user := float64(ticks[cpuStateUser])
+	nice := float64(ticks[cpuStateNice])
+	sys := float64(ticks[cpuStateSystem])
+	idle := float64(ticks[cpuStateIdle])
+	busy := user + nice + sys
+
+	metrics := cgm.Metrics{
+		"cpu_user":   cgm.Metric{Type: metricType, Value: user / numCPU},
+		"cpu_nice":   cgm.Metric{Type: metricType, Value: nice / numCPU},
+		"cpu_system": cgm.Metric{Type: metricType, Value: sys / numCPU},
+		"cpu_idle":   cgm.Metric{Type: metricType, Value: idle / numCPU},
+	}
+
+	all := busy + idle
+	used := float64(0)
+	if lrv, ok := c.lastRunValues[id]; ok {
+		if all > lrv.all {
+			used = ((busy - lrv.busy) / (all - lrv.all)) * 100
+		}
+	} else if all > 0 {
+		used = (busy / all) * 100
+	}
+	metrics["cpu_used"] = cgm.Metric{Type: metricType, Value: used}
+	c.lastRunValues[id] = lastValues{all: all, busy: busy}
+
+	return metrics
+}
@@ -0,0 +1,407 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+// stubCommand replaces runCommand with one returning the named testdata file
+func stubCommand(t *testing.T, file string) func() {
+	orig := runCommand
+	runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("reading testdata (%s)", err)
+		}
+		return data, nil
+	}
+	return func() { runCommand = orig }
+}
+
+func TestCPUCollect(t *testing.T) {
+	t.Log("Testing CPU Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	orig := readCPUTicks
+	defer func() { readCPUTicks = orig }()
+
+	ticks := []cpuTicks{
+		{cpuStateUser: 100, cpuStateSystem: 50, cpuStateIdle: 800, cpuStateNice: 50},
+		{cpuStateUser: 300, cpuStateSystem: 100, cpuStateIdle: 600, cpuStateNice: 0},
+	}
+	readCPUTicks = func() ([]cpuTicks, error) { return ticks, nil }
+
+	c, err := NewCPUCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "num_cpu", "collector:cpu"); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected num_cpu 2, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "cpu_user", "units:centiseconds"); !ok || m.Value.(float64) != 200 {
+		t.Fatalf("expected cpu_user 200, got %v", m.Value)
+	}
+	// busy 600 of all 2000
+	if m, ok := testutil.FindMetric(metrics, "cpu_used", "units:percent"); !ok || m.Value.(float64) != 30 {
+		t.Fatalf("expected cpu_used 30, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "cpu_used", "cpu:0"); ok {
+		t.Fatal("expected no per cpu metrics")
+	}
+
+	t.Log("\treport all cpus")
+	{
+		c.(*CPU).reportAllCPUs = true
+		ticks[0] = cpuTicks{cpuStateUser: 200, cpuStateSystem: 50, cpuStateIdle: 900, cpuStateNice: 50}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		// busy 300 of all 1200 since boot
+		if m, ok := testutil.FindMetric(metrics, "cpu_used", "cpu:0"); !ok || m.Value.(float64) != 25 {
+			t.Fatalf("expected cpu 0 cpu_used 25, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, "cpu_user", "cpu:1"); !ok || m.Value.(float64) != 300 {
+			t.Fatalf("expected cpu 1 cpu_user 300, got %v", m.Value)
+		}
+	}
+}
+
+func TestParseVMStat(t *testing.T) {
+	t.Log("Testing parseVMStat")
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "vm_stat.txt"))
+	if err != nil {
+		t.Fatalf("reading testdata (%s)", err)
+	}
+
+	vs, err := parseVMStat(data)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if vs.pageSize != 4096 {
+		t.Fatalf("expected page size 4096, got %d", vs.pageSize)
+	}
+	if vs.faults != 123456789 {
+		t.Fatalf("expected faults 123456789, got %d", vs.faults)
+	}
+	if vs.compressor != 50000 {
+		t.Fatalf("expected compressor 50000, got %d", vs.compressor)
+	}
+
+	t.Log("\tno page size")
+	{
+		_, err := parseVMStat([]byte("Pages free: 1.\n"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tinvalid value")
+	{
+		_, err := parseVMStat([]byte("Mach Virtual Memory Statistics: (page size of 4096 bytes)\nPages free: abc.\n"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestVMCollect(t *testing.T) {
+	t.Log("Testing VM Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubCommand(t, "vm_stat.txt")()
+	origStats, origUint64, origRaw := readVMStats, sysctlUint64, sysctlRaw
+	defer func() { readVMStats, sysctlUint64, sysctlRaw = origStats, origUint64, origRaw }()
+
+	readVMStats = func(ctx context.Context) (*vmStats, error) {
+		out, err := runCommand(ctx, vmStatPath)
+		if err != nil {
+			return nil, err
+		}
+		return parseVMStat(out)
+	}
+	sysctlUint64 = func(name string, args ...int) (uint64, error) { return 8 * 1024 * 1024 * 1024, nil }
+	sysctlRaw = func(name string, args ...int) ([]byte, error) {
+		b := make([]byte, xswUsageSize)
+		binary.LittleEndian.PutUint64(b[0:], 2048*1024*1024)
+		binary.LittleEndian.PutUint64(b[8:], 1536*1024*1024)
+		binary.LittleEndian.PutUint64(b[16:], 512*1024*1024)
+		return b, nil
+	}
+
+	c, err := NewVMCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	// (400000 anonymous - 10000 purgeable + 200000 wired + 50000 compressor) pages
+	used := uint64(640000 * 4096)
+	if m, ok := testutil.FindMetric(metrics, "memory_used", "units:bytes"); !ok || m.Value.(uint64) != used {
+		t.Fatalf("expected memory_used %d, got %v", used, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "wired", "units:bytes"); !ok || m.Value.(uint64) != 200000*4096 {
+		t.Fatalf("expected wired %d, got %v", 200000*4096, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "swap_used", "units:percent"); !ok || m.Value.(float64) != 25 {
+		t.Fatalf("expected swap_used 25, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "pg_swap_out"); !ok || m.Value.(uint64) != 1012 {
+		t.Fatalf("expected pg_swap_out 1012, got %v", m.Value)
+	}
+}
+
+func TestParseIOReg(t *testing.T) {
+	t.Log("Testing parseIOReg")
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "ioreg_battery.txt"))
+	if err != nil {
+		t.Fatalf("reading testdata (%s)", err)
+	}
+
+	entries, err := parseIOReg(data)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.class != "AppleSmartBattery" {
+		t.Fatalf("expected AppleSmartBattery, got %s", e.class)
+	}
+	if v, ok := e.string("DeviceName"); !ok || v != "bq40z651" {
+		t.Fatalf("expected DeviceName bq40z651, got %s (%v)", v, ok)
+	}
+	if v, ok := e.int("Amperage"); !ok || v != -1096 {
+		t.Fatalf("expected Amperage -1096, got %d (%v)", v, ok)
+	}
+	if v, ok := e.bool("IsCharging"); !ok || v {
+		t.Fatalf("expected IsCharging false, got %v (%v)", v, ok)
+	}
+	if d := e.dict("BatteryData"); d["StateOfCharge"] != 87 {
+		t.Fatalf("expected StateOfCharge 87, got %v", d)
+	}
+	if _, ok := e.uint("DeviceName"); ok {
+		t.Fatal("expected non-numeric property to not parse")
+	}
+}
+
+func TestDiskCollect(t *testing.T) {
+	t.Log("Testing Disk Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubCommand(t, "ioreg_disk.txt")()
+
+	c, err := NewDiskCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "reads", "device:disk0", "units:bytes"); !ok || m.Value.(uint64) != 51234567890 {
+		t.Fatalf("expected disk0 read bytes 51234567890, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "read_time", "device:disk0"); !ok || m.Value.(uint64) != 987654 {
+		t.Fatalf("expected disk0 read_time 987654, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "writes", "device:disk4", "units:operations"); !ok || m.Value.(uint64) != 10 {
+		t.Fatalf("expected disk4 writes 10, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "reads", "device:disk0s1"); ok {
+		t.Fatal("expected no partition metrics")
+	}
+}
+
+func TestBatteryCollect(t *testing.T) {
+	t.Log("Testing Battery Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tno battery")
+	{
+		restore := stubCommand(t, "pmset_therm_none.txt")
+		_, err := NewBatteryCollector(filepath.Join("testdata", "missing"))
+		restore()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	defer stubCommand(t, "ioreg_battery.txt")()
+
+	c, err := NewBatteryCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "charge", "units:percent"); !ok || m.Value.(float64) != 87 {
+		t.Fatalf("expected charge 87, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "temperature", "units:celsius"); !ok || m.Value.(float64) != 30.12 {
+		t.Fatalf("expected temperature 30.12, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "amperage"); !ok || m.Value.(int64) != -1096 {
+		t.Fatalf("expected amperage -1096, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "external_power"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected external_power 0, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "health"); !ok {
+		t.Fatal("expected health metric")
+	}
+}
+
+func TestThermalCollect(t *testing.T) {
+	t.Log("Testing Thermal Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	tt := []struct {
+		file       string
+		speedLimit uint64
+		available  bool
+	}{
+		{"pmset_therm.txt", 72, true},
+		{"pmset_therm_none.txt", 100, false},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s", tst.file)
+		restore := stubCommand(t, tst.file)
+
+		c, err := NewThermalCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		restore()
+
+		if m, ok := testutil.FindMetric(metrics, "cpu_speed_limit"); !ok || m.Value.(uint64) != tst.speedLimit {
+			t.Fatalf("expected cpu_speed_limit %d, got %v", tst.speedLimit, m.Value)
+		}
+		if _, ok := testutil.FindMetric(metrics, "cpu_available"); ok != tst.available {
+			t.Fatalf("expected cpu_available present %v", tst.available)
+		}
+	}
+}
+
+// ifInfo2 builds an RTM_IFINFO2 message with a link level sockaddr for the name
+func ifInfo2(index uint16, name string, ibytes uint64) []byte {
+	sdl := make([]byte, 8+len(name))
+	sdl[0] = byte(len(sdl))
+	sdl[1] = afLink
+	binary.LittleEndian.PutUint16(sdl[2:], index)
+	sdl[5] = byte(len(name))
+	copy(sdl[8:], name)
+
+	msg := make([]byte, ifMsghdr2Size+len(sdl))
+	binary.LittleEndian.PutUint16(msg[0:], uint16(len(msg)))
+	msg[ifmType] = rtmIfInfo2
+	binary.LittleEndian.PutUint32(msg[ifmAddrs:], rtaIfp)
+	binary.LittleEndian.PutUint16(msg[12:], index)
+	binary.LittleEndian.PutUint64(msg[ifDataIBytes:], ibytes)
+	binary.LittleEndian.PutUint64(msg[ifDataOPackets:], ibytes/100)
+	copy(msg[ifMsghdr2Size:], sdl)
+	return msg
+}
+
+func TestDecodeIfList2(t *testing.T) {
+	t.Log("Testing decodeIfList2")
+
+	// RTM_NEWMADDR2 address message, ignored
+	addrMsg := make([]byte, 20)
+	binary.LittleEndian.PutUint16(addrMsg[0:], uint16(len(addrMsg)))
+	addrMsg[ifmType] = 0x13
+
+	var rib []byte
+	rib = append(rib, ifInfo2(1, "lo0", 1000)...)
+	rib = append(rib, addrMsg...)
+	rib = append(rib, ifInfo2(4, "en0", 123456)...)
+
+	ifaces, err := decodeIfList2(rib)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if len(ifaces) != 2 {
+		t.Fatalf("expected 2 interfaces, got %d", len(ifaces))
+	}
+	if ifaces[1].name != "en0" {
+		t.Fatalf("expected en0, got %s", ifaces[1].name)
+	}
+	if v := binary.LittleEndian.Uint64(ifaces[1].msg[ifDataIBytes:]); v != 123456 {
+		t.Fatalf("expected ibytes 123456, got %d", v)
+	}
+
+	t.Log("\tinvalid length")
+	{
+		bad := ifInfo2(1, "lo0", 1)
+		binary.LittleEndian.PutUint16(bad[0:], uint16(len(bad)+10))
+		if _, err := decodeIfList2(bad); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestNetIFCollect(t *testing.T) {
+	t.Log("Testing NetIF Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	orig := routeRIB
+	defer func() { routeRIB = orig }()
+
+	routeRIB = func() ([]byte, error) {
+		rib := ifInfo2(1, "lo0", 1000)
+		return append(rib, ifInfo2(4, "en0", 123456)...), nil
+	}
+
+	c, err := NewNetIFCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "recv", "network-interface:en0", "units:bytes"); !ok || m.Value.(uint64) != 123456 {
+		t.Fatalf("expected en0 recv bytes 123456, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "sent", "network-interface:en0", "units:packets"); !ok || m.Value.(uint64) != 1234 {
+		t.Fatalf("expected en0 sent packets 1234, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "recv", "network-interface:lo0"); ok {
+		t.Fatal("expected lo0 to be excluded")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Disk metrics from the IOKit IOBlockStorageDriver statistics
+type Disk struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// diskOptions defines what elements can be overridden in a config file
+type diskOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// diskStats statistics of one block storage driver and the bsd name of its media
+type diskStats struct {
+	name  string
+	stats map[string]uint64
+}
+
+const nsecPerMillisecond = 1e6
+
+// NewDiskCollector creates new darwin disk collector
+func NewDiskCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Disk{
+		common:  newCommon(NameDisk, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	var opts diskOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the IOKit registry
+func (c *Disk) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	entries, err := readIOReg(ctx, "IOBlockStorageDriver")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	unitOperationsTag := tags.Tag{Category: "units", Value: "operations"}
+	unitBytesTag := tags.Tag{Category: "units", Value: "bytes"}
+	unitMillisecondsTag := tags.Tag{Category: "units", Value: "milliseconds"}
+
+	metricType := "L" // uint64
+	for _, ds := range diskDrivers(entries) {
+		if c.exclude.MatchString(ds.name) || !c.include.MatchString(ds.name) {
+			c.logger.Debug().Str("device", ds.name).Msg("excluded device name, ignoring")
+			continue
+		}
+
+		diskTags := tags.Tags{
+			tags.Tag{Category: "device", Value: ds.name},
+		}
+
+		{
+			tagList := tags.Tags{unitOperationsTag}
+			tagList = append(tagList, diskTags...)
+			_ = c.addMetric(&metrics, "", "reads", metricType, ds.stats["Operations (Read)"], tagList)
+			_ = c.addMetric(&metrics, "", "writes", metricType, ds.stats["Operations (Write)"], tagList)
+			_ = c.addMetric(&metrics, "", "read_errors", metricType, ds.stats["Errors (Read)"], tagList)
+			_ = c.addMetric(&metrics, "", "write_errors", metricType, ds.stats["Errors (Write)"], tagList)
+			_ = c.addMetric(&metrics, "", "read_retries", metricType, ds.stats["Retries (Read)"], tagList)
+			_ = c.addMetric(&metrics, "", "write_retries", metricType, ds.stats["Retries (Write)"], tagList)
+		}
+
+		{
+			tagList := tags.Tags{unitBytesTag}
+			tagList = append(tagList, diskTags...)
+			_ = c.addMetric(&metrics, "", "reads", metricType, ds.stats["Bytes (Read)"], tagList)
+			_ = c.addMetric(&metrics, "", "writes", metricType, ds.stats["Bytes (Write)"], tagList)
+		}
+
+		{
+			tagList := tags.Tags{unitMillisecondsTag}
+			tagList = append(tagList, diskTags...)
+			_ = c.addMetric(&metrics, "", "read_time", metricType, ds.stats["Total Time (Read)"]/nsecPerMillisecond, tagList)
+			_ = c.addMetric(&metrics, "", "write_time", metricType, ds.stats["Total Time (Write)"]/nsecPerMillisecond, tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// diskDrivers pairs the statistics of each block storage driver with the
+// bsd name of the first media object below it (the whole disk)
+func diskDrivers(entries []*ioregEntry) []diskStats {
+	var disks []diskStats
+	var cur *diskStats
+
+	for _, e := range entries {
+		if e.class == "IOBlockStorageDriver" {
+			if cur != nil && cur.name != "" {
+				disks = append(disks, *cur)
+			}
+			cur = &diskStats{stats: e.dict("Statistics")}
+			continue
+		}
+		if cur == nil || cur.name != "" {
+			continue
+		}
+		if name, ok := e.string("BSD Name"); ok {
+			cur.name = name
+		}
+	}
+	if cur != nil && cur.name != "" {
+		disks = append(disks, *cur)
+	}
+
+	return disks
+}
@@ -0,0 +1,100 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build darwin,cgo
+
+package darwin
+
+/*
+#include <mach/mach_host.h>
+#include <mach/host_info.h>
+#include <mach/processor_info.h>
+#include <mach/vm_map.h>
+*/
+import "C"
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// hostCPUTicks returns the per cpu state ticks from host_processor_info
+func hostCPUTicks() ([]cpuTicks, error) {
+	var (
+		count   C.mach_msg_type_number_t
+		cpuload *C.processor_cpu_load_info_data_t
+		ncpu    C.natural_t
+	)
+
+	status := C.host_processor_info(C.host_t(C.mach_host_self()),
+		C.PROCESSOR_CPU_LOAD_INFO,
+		&ncpu,
+		(*C.processor_info_array_t)(unsafe.Pointer(&cpuload)),
+		&count)
+	if status != C.KERN_SUCCESS {
+		return nil, errors.Errorf("host_processor_info error=%d", status)
+	}
+
+	// the info array is allocated in our address space by the kernel
+	defer C.vm_deallocate(C.vm_map_t(C.mach_task_self_),
+		C.vm_address_t(uintptr(unsafe.Pointer(cpuload))),
+		C.vm_size_t(uintptr(count)*unsafe.Sizeof(C.integer_t(0))))
+
+	loads := (*[1 << 16]C.processor_cpu_load_info_data_t)(unsafe.Pointer(cpuload))[:ncpu:ncpu]
+	ticks := make([]cpuTicks, len(loads))
+	for i, l := range loads {
+		ticks[i] = cpuTicks{
+			cpuStateUser:   uint64(l.cpu_ticks[C.CPU_STATE_USER]),
+			cpuStateSystem: uint64(l.cpu_ticks[C.CPU_STATE_SYSTEM]),
+			cpuStateIdle:   uint64(l.cpu_ticks[C.CPU_STATE_IDLE]),
+			cpuStateNice:   uint64(l.cpu_ticks[C.CPU_STATE_NICE]),
+		}
+	}
+
+	return ticks, nil
+}
+
+// hostVMStats returns the virtual memory statistics from host_statistics64
+func hostVMStats(ctx context.Context) (*vmStats, error) {
+	host := C.host_t(C.mach_host_self())
+
+	var pageSize C.vm_size_t
+	if status := C.host_page_size(host, &pageSize); status != C.KERN_SUCCESS {
+		return nil, errors.Errorf("host_page_size error=%d", status)
+	}
+
+	var vs C.vm_statistics64_data_t
+	count := C.mach_msg_type_number_t(C.HOST_VM_INFO64_COUNT)
+	status := C.host_statistics64(host,
+		C.HOST_VM_INFO64,
+		C.host_info64_t(unsafe.Pointer(&vs)),
+		&count)
+	if status != C.KERN_SUCCESS {
+		return nil, errors.Errorf("host_statistics64 error=%d", status)
+	}
+
+	return &vmStats{
+		pageSize:       uint64(pageSize),
+		free:           uint64(vs.free_count),
+		active:         uint64(vs.active_count),
+		inactive:       uint64(vs.inactive_count),
+		speculative:    uint64(vs.speculative_count),
+		wired:          uint64(vs.wire_count),
+		purgeable:      uint64(vs.purgeable_count),
+		compressor:     uint64(vs.compressor_page_count),
+		internal:       uint64(vs.internal_page_count),
+		external:       uint64(vs.external_page_count),
+		faults:         uint64(vs.faults),
+		cowFaults:      uint64(vs.cow_faults),
+		pageins:        uint64(vs.pageins),
+		pageouts:       uint64(vs.pageouts),
+		swapins:        uint64(vs.swapins),
+		swapouts:       uint64(vs.swapouts),
+		compressions:   uint64(vs.compressions),
+		decompressions: uint64(vs.decompressions),
+	}, nil
+}
@@ -0,0 +1,30 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !darwin !cgo
+
+package darwin
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// hostCPUTicks host_processor_info is only available with cgo, there is no
+// utility reporting the raw per cpu state ticks
+func hostCPUTicks() ([]cpuTicks, error) {
+	return nil, errors.New("cpu state ticks require an agent built with cgo")
+}
+
+// hostVMStats returns the virtual memory statistics from vm_stat(1), which
+// reports host_statistics64
+func hostVMStats(ctx context.Context) (*vmStats, error) {
+	out, err := runCommand(ctx, vmStatPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseVMStat(out)
+}
@@ -0,0 +1,133 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ioregEntry an IOKit registry object and its properties as printed by ioreg(8)
+type ioregEntry struct {
+	class string
+	props map[string]string
+}
+
+var (
+	ioregObjectRx = regexp.MustCompile(`\+-o .+<class ([^,>]+)`)
+	ioregPropRx   = regexp.MustCompile(`^[\s|]*"([^"]+)" = (.*)$`)
+	ioregDictRx   = regexp.MustCompile(`"([^"]+)"=(-?\d+)`)
+)
+
+// readIOReg runs ioreg for the subtrees rooted at objects of the IOKit class
+func readIOReg(ctx context.Context, class string) ([]*ioregEntry, error) {
+	out, err := runCommand(ctx, ioregPath, "-r", "-l", "-w", "0", "-c", class)
+	if err != nil {
+		return nil, err
+	}
+	return parseIOReg(out)
+}
+
+// parseIOReg parses ioreg(8) text output into registry entries in output order
+func parseIOReg(data []byte) ([]*ioregEntry, error) {
+	var entries []*ioregEntry
+	var cur *ioregEntry
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if m := ioregObjectRx.FindStringSubmatch(line); m != nil {
+			cur = &ioregEntry{class: m[1], props: make(map[string]string)}
+			entries = append(entries, cur)
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if m := ioregPropRx.FindStringSubmatch(line); m != nil {
+			cur.props[m[1]] = m[2]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "scanning ioreg output")
+	}
+
+	return entries, nil
+}
+
+// string returns the value of a string property
+func (e *ioregEntry) string(prop string) (string, bool) {
+	v, ok := e.props[prop]
+	if !ok || len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return "", false
+	}
+	return v[1 : len(v)-1], true
+}
+
+// uint returns the value of an unsigned integer property
+func (e *ioregEntry) uint(prop string) (uint64, bool) {
+	v, ok := e.props[prop]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// int returns the value of a signed integer property, ioreg prints
+// negative values as their unsigned 64 bit representation
+func (e *ioregEntry) int(prop string) (int64, bool) {
+	v, ok := e.props[prop]
+	if !ok {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, true
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+// bool returns the value of a boolean (Yes/No) property
+func (e *ioregEntry) bool(prop string) (bool, bool) {
+	switch e.props[prop] {
+	case "Yes":
+		return true, true
+	case "No":
+		return false, true
+	}
+	return false, false
+}
+
+// dict returns the integer values of a dictionary property
+func (e *ioregEntry) dict(prop string) map[string]uint64 {
+	v, ok := e.props[prop]
+	if !ok || !strings.HasPrefix(v, "{") {
+		return nil
+	}
+	vals := make(map[string]uint64)
+	for _, m := range ioregDictRx.FindAllStringSubmatch(v, -1) {
+		n, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		vals[m[1]] = n
+	}
+	return vals
+}
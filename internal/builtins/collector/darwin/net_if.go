@@ -0,0 +1,198 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// NetIF metrics from the NET_RT_IFLIST2 interface list
+type NetIF struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// netIFOptions defines what elements can be overridden in a config file
+type netIFOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// struct if_msghdr2 and the if_data64 counters it carries (net/if.h),
+// offsets are from the start of the message
+const (
+	rtmIfInfo2       = 0x12 // RTM_IFINFO2
+	rtaIfp           = 0x10 // RTA_IFP
+	afLink           = 0x12 // AF_LINK
+	ifMsghdr2Size    = 160
+	ifmType          = 3
+	ifmAddrs         = 4
+	ifDataIPackets   = 56
+	ifDataIErrors    = 64
+	ifDataOPackets   = 72
+	ifDataOErrors    = 80
+	ifDataCollisions = 88
+	ifDataIBytes     = 96
+	ifDataOBytes     = 104
+	ifDataIMcasts    = 112
+	ifDataOMcasts    = 120
+	ifDataIQDrops    = 128
+	ifDataNoProto    = 136
+)
+
+// ifStats interface name and its if_msghdr2 message
+type ifStats struct {
+	name string
+	msg  []byte
+}
+
+// NewNetIFCollector creates new darwin if collector
+func NewNetIFCollector(cfgBaseName string) (collector.Collector, error) {
+	c := NetIF{
+		common:  newCommon(NameNetInterface, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: regexp.MustCompile(fmt.Sprintf(regexPat, `lo[0-9]*`)),
+	}
+
+	var opts netIFOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the routing socket interface list
+func (c *NetIF) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	rib, err := routeRIB()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	ifaces, err := decodeIfList2(rib)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	unitBytesTag := tags.Tag{Category: "units", Value: "bytes"}
+	unitPacketsTag := tags.Tag{Category: "units", Value: "packets"}
+	dirInTag := tags.Tag{Category: "direction", Value: "in"}
+	dirOutTag := tags.Tag{Category: "direction", Value: "out"}
+
+	stats := []struct {
+		offset int
+		name   string
+		stags  tags.Tags
+	}{
+		{offset: ifDataIBytes, name: "recv", stags: tags.Tags{unitBytesTag}},
+		{offset: ifDataIPackets, name: "recv", stags: tags.Tags{unitPacketsTag}},
+		{offset: ifDataIErrors, name: "errors", stags: tags.Tags{dirInTag}},
+		{offset: ifDataIQDrops, name: "drops", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{offset: ifDataIMcasts, name: "multicast", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{offset: ifDataNoProto, name: "noproto", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{offset: ifDataOBytes, name: "sent", stags: tags.Tags{unitBytesTag}},
+		{offset: ifDataOPackets, name: "sent", stags: tags.Tags{unitPacketsTag}},
+		{offset: ifDataOErrors, name: "errors", stags: tags.Tags{dirOutTag}},
+		{offset: ifDataOMcasts, name: "multicast", stags: tags.Tags{dirOutTag, unitPacketsTag}},
+		{offset: ifDataCollisions, name: "collision", stags: tags.Tags{dirOutTag}},
+	}
+
+	metricType := "L" // uint64
+	for _, iface := range ifaces {
+		if c.exclude.MatchString(iface.name) || !c.include.MatchString(iface.name) {
+			c.logger.Debug().Str("iface", iface.name).Msg("excluded iface name, skipping")
+			continue
+		}
+
+		for _, s := range stats {
+			tagList := tags.Tags{tags.Tag{Category: "network-interface", Value: iface.name}}
+			tagList = append(tagList, s.stags...)
+			_ = c.addMetric(&metrics, "", s.name, metricType, binary.LittleEndian.Uint64(iface.msg[s.offset:s.offset+8]), tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// decodeIfList2 extracts the RTM_IFINFO2 messages from a NET_RT_IFLIST2
+// routing information base, the interface name is taken from the link
+// level sockaddr following the message header (darwin platforms are all
+// little endian)
+func decodeIfList2(rib []byte) ([]ifStats, error) {
+	var ifaces []ifStats
+
+	for len(rib) >= 4 {
+		msgLen := int(binary.LittleEndian.Uint16(rib[0:]))
+		if msgLen < 4 || msgLen > len(rib) {
+			return nil, errors.Errorf("invalid routing message length (%d)", msgLen)
+		}
+		msg := rib[:msgLen]
+		rib = rib[msgLen:]
+
+		if msg[ifmType] != rtmIfInfo2 || msgLen < ifMsghdr2Size {
+			continue // address messages for the interface
+		}
+		if binary.LittleEndian.Uint32(msg[ifmAddrs:])&rtaIfp == 0 {
+			continue
+		}
+
+		// struct sockaddr_dl { u_char sdl_len; u_char sdl_family; u_short sdl_index;
+		//                      u_char sdl_type; u_char sdl_nlen; u_char sdl_alen; u_char sdl_slen; char sdl_data[]; }
+		sdl := msg[ifMsghdr2Size:]
+		if len(sdl) < 8 || sdl[1] != afLink {
+			continue
+		}
+		nameLen := int(sdl[5])
+		if nameLen == 0 || 8+nameLen > len(sdl) {
+			continue
+		}
+
+		ifaces = append(ifaces, ifStats{name: string(sdl[8 : 8+nameLen]), msg: msg})
+	}
+
+	return ifaces, nil
+}
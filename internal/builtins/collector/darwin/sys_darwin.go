@@ -0,0 +1,26 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build darwin
+
+package darwin
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// sysctl accessors, overridden in tests
+	sysctlUint64 = unix.SysctlUint64
+	sysctlRaw    = unix.SysctlRaw
+
+	// routeRIB returns the NET_RT_IFLIST2 routing information base, the
+	// interface list and 64 bit counters getifaddrs(3) is built on
+	routeRIB = func() ([]byte, error) {
+		return syscall.RouteRIB(syscall.NET_RT_IFLIST2, 0)
+	}
+)
@@ -0,0 +1,28 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !darwin
+
+package darwin
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+var errNotSupported = errors.New("not supported on " + runtime.GOOS)
+
+var (
+	sysctlUint64 = func(name string, args ...int) (uint64, error) {
+		return 0, errNotSupported
+	}
+	sysctlRaw = func(name string, args ...int) ([]byte, error) {
+		return nil, errNotSupported
+	}
+	routeRIB = func() ([]byte, error) {
+		return nil, errNotSupported
+	}
+)
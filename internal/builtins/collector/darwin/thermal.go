@@ -0,0 +1,119 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Thermal metrics from the power management thermal state (thermal throttling)
+type Thermal struct {
+	common
+}
+
+// thermalOptions defines what elements can be overridden in a config file
+type thermalOptions struct {
+	commonOptions
+}
+
+var pmsetThermRx = regexp.MustCompile(`^\s*(CPU_[A-Za-z_]+)\s*=\s*(\d+)\s*$`)
+
+// NewThermalCollector creates new darwin thermal collector
+func NewThermalCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Thermal{
+		common: newCommon(NameThermal, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts thermalOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from pmset
+func (c *Thermal) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	out, err := runCommand(ctx, pmsetPath, "-g", "therm")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	therm, err := parsePmsetTherm(out)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	stats := []struct {
+		key   string
+		name  string
+		units string
+	}{
+		{"CPU_Speed_Limit", "cpu_speed_limit", "percent"},
+		{"CPU_Scheduler_Limit", "cpu_scheduler_limit", "percent"},
+		{"CPU_Available_CPUs", "cpu_available", "cpus"},
+	}
+	for _, s := range stats {
+		if v, ok := therm[s.key]; ok {
+			_ = c.addMetric(&metrics, "", s.name, "L", v, tags.Tags{tags.Tag{Category: "units", Value: s.units}})
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// parsePmsetTherm parses `pmset -g therm` output, when no cpu power status
+// has been recorded the cpu is not being throttled and the limits are 100%
+func parsePmsetTherm(data []byte) (map[string]uint64, error) {
+	therm := make(map[string]uint64)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if strings.Contains(line, "No CPU power status has been recorded") {
+			therm["CPU_Speed_Limit"] = 100
+			therm["CPU_Scheduler_Limit"] = 100
+			continue
+		}
+		m := pmsetThermRx.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		v, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing pmset line (%s)", line)
+		}
+		therm[m[1]] = v
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "scanning pmset output")
+	}
+
+	return therm, nil
+}
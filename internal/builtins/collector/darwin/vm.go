@@ -0,0 +1,259 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package darwin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// VM metrics from host_statistics64, hw.memsize and vm.swapusage
+type VM struct {
+	common
+}
+
+// vmOptions defines what elements can be overridden in a config file
+type vmOptions struct {
+	commonOptions
+}
+
+// vmStats host_statistics64 virtual memory statistics, counts are pages
+type vmStats struct {
+	pageSize       uint64
+	free           uint64
+	active         uint64
+	inactive       uint64
+	speculative    uint64
+	wired          uint64
+	purgeable      uint64
+	compressor     uint64 // pages occupied by the compressor
+	internal       uint64 // anonymous pages
+	external       uint64 // file-backed pages
+	faults         uint64
+	cowFaults      uint64
+	pageins        uint64
+	pageouts       uint64
+	swapins        uint64
+	swapouts       uint64
+	compressions   uint64
+	decompressions uint64
+}
+
+// readVMStats returns the virtual memory statistics, overridden in tests
+var readVMStats = hostVMStats
+
+// xswUsageSize size of struct xsw_usage returned by vm.swapusage
+const xswUsageSize = 32
+
+var vmStatPageSizeRx = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// NewVMCollector creates new darwin vm collector
+func NewVMCollector(cfgBaseName string) (collector.Collector, error) {
+	c := VM{
+		common: newCommon(NameVM, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts vmOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from host_statistics64 and sysctl
+func (c *VM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	if err := c.memStats(ctx, &metrics); err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	if err := c.swapStats(&metrics); err != nil {
+		c.logger.Warn().Err(err).Msg("swap")
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+func (c *VM) memStats(ctx context.Context, metrics *cgm.Metrics) error {
+	total, err := sysctlUint64("hw.memsize")
+	if err != nil {
+		return errors.Wrap(err, "hw.memsize")
+	}
+	if total == 0 {
+		return errors.New("invalid hw.memsize (0)")
+	}
+
+	vs, err := readVMStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+	tagUnitsFaults := tags.Tag{Category: "units", Value: "faults"}
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+
+	memStats := []struct {
+		name  string
+		pages uint64
+	}{
+		{"free", vs.free},
+		{"active", vs.active},
+		{"inactive", vs.inactive},
+		{"speculative", vs.speculative},
+		{"wired", vs.wired},
+		{"purgeable", vs.purgeable},
+		{"compressed", vs.compressor},
+		{"file_backed", vs.external},
+	}
+	for _, s := range memStats {
+		_ = c.addMetric(metrics, "", s.name, "L", s.pages*vs.pageSize, tags.Tags{tagUnitsBytes})
+	}
+
+	// used as Activity Monitor reports it, app memory (anonymous less
+	// purgeable) plus wired plus compressed
+	usedPages := vs.wired + vs.compressor
+	if vs.internal > vs.purgeable {
+		usedPages += vs.internal - vs.purgeable
+	}
+	used := usedPages * vs.pageSize
+	if used > total {
+		used = total
+	}
+	free := total - used
+
+	_ = c.addMetric(metrics, "", "memory_total", "L", total, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "memory_free", "L", free, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "memory_used", "L", used, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "memory_used", "n", (float64(used)/float64(total))*100, tags.Tags{tagUnitsPercent})
+	_ = c.addMetric(metrics, "", "memory_free", "n", (float64(free)/float64(total))*100, tags.Tags{tagUnitsPercent})
+
+	_ = c.addMetric(metrics, "", "pg_fault", "L", vs.faults, tags.Tags{tagUnitsFaults})
+	_ = c.addMetric(metrics, "", "pg_fault_cow", "L", vs.cowFaults, tags.Tags{tagUnitsFaults})
+	_ = c.addMetric(metrics, "", "pg_page_in", "L", vs.pageins, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(metrics, "", "pg_page_out", "L", vs.pageouts, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(metrics, "", "pg_swap_in", "L", vs.swapins, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(metrics, "", "pg_swap_out", "L", vs.swapouts, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(metrics, "", "pg_compressions", "L", vs.compressions, tags.Tags{tagUnitsPages})
+	_ = c.addMetric(metrics, "", "pg_decompressions", "L", vs.decompressions, tags.Tags{tagUnitsPages})
+
+	return nil
+}
+
+func (c *VM) swapStats(metrics *cgm.Metrics) error {
+	data, err := sysctlRaw("vm.swapusage")
+	if err != nil {
+		return errors.Wrap(err, "vm.swapusage")
+	}
+	if len(data) < xswUsageSize {
+		return errors.Errorf("vm.swapusage short read (%d)", len(data))
+	}
+
+	// struct xsw_usage { u_int64_t xsu_total; u_int64_t xsu_avail; u_int64_t xsu_used; ... }
+	// darwin platforms are all little endian
+	total := binary.LittleEndian.Uint64(data[0:])
+	used := binary.LittleEndian.Uint64(data[16:])
+	if used > total {
+		used = total
+	}
+
+	usedPct := float64(0)
+	if total > 0 {
+		usedPct = (float64(used) / float64(total)) * 100
+	}
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	_ = c.addMetric(metrics, "", "swap_total", "L", total, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "swap_used", "L", used, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "swap_used", "n", usedPct, tags.Tags{tagUnitsPercent})
+	_ = c.addMetric(metrics, "", "swap_free", "L", total-used, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "swap_free", "n", 100-usedPct, tags.Tags{tagUnitsPercent})
+
+	return nil
+}
+
+// parseVMStat parses vm_stat(1) output
+func parseVMStat(data []byte) (*vmStats, error) {
+	vs := vmStats{}
+	fields := map[string]*uint64{
+		"Pages free":                   &vs.free,
+		"Pages active":                 &vs.active,
+		"Pages inactive":               &vs.inactive,
+		"Pages speculative":            &vs.speculative,
+		"Pages wired down":             &vs.wired,
+		"Pages purgeable":              &vs.purgeable,
+		"Pages occupied by compressor": &vs.compressor,
+		"Anonymous pages":              &vs.internal,
+		"File-backed pages":            &vs.external,
+		"Translation faults":           &vs.faults,
+		"Pages copy-on-write":          &vs.cowFaults,
+		"Pageins":                      &vs.pageins,
+		"Pageouts":                     &vs.pageouts,
+		"Swapins":                      &vs.swapins,
+		"Swapouts":                     &vs.swapouts,
+		"Compressions":                 &vs.compressions,
+		"Decompressions":               &vs.decompressions,
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if m := vmStatPageSizeRx.FindStringSubmatch(line); m != nil {
+			v, err := strconv.ParseUint(m[1], 10, 64)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing vm_stat page size")
+			}
+			vs.pageSize = v
+			continue
+		}
+		sep := strings.LastIndexByte(line, ':')
+		if sep < 0 {
+			continue
+		}
+		field, ok := fields[strings.Trim(line[:sep], `"`)]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(strings.Trim(line[sep+1:], " ."), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing vm_stat line (%s)", line)
+		}
+		*field = v
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "scanning vm_stat output")
+	}
+
+	if vs.pageSize == 0 {
+		return nil, errors.New("vm_stat page size not found")
+	}
+
+	return &vs, nil
+}
@@ -0,0 +1,204 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package flow
+
+import (
+	"net"
+	"sort"
+	"sync"
+)
+
+// flowRecord a decoded flow (or sampled packet), bytes and packets are
+// already scaled by the sampling rate
+type flowRecord struct {
+	src     net.IP
+	dst     net.IP
+	bytes   uint64
+	packets uint64
+}
+
+// prefixGroup a named set of networks traffic is summarized for
+type prefixGroup struct {
+	name string
+	nets []*net.IPNet
+}
+
+func (g *prefixGroup) contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range g.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// counters traffic totals
+type counters struct {
+	flows   uint64
+	bytes   uint64
+	packets uint64
+}
+
+func (c *counters) add(r flowRecord) {
+	c.flows++
+	c.bytes += r.bytes
+	c.packets += r.packets
+}
+
+// protoCounters per flow protocol totals
+type protoCounters struct {
+	counters
+	datagrams uint64
+	errors    uint64
+}
+
+// groupKey prefix group and traffic direction (in: destination is in the
+// group, out: source is in the group)
+type groupKey struct {
+	group     string
+	direction string
+}
+
+// talker a source address and its traffic in the current interval
+type talker struct {
+	addr string
+	counters
+}
+
+// maxTalkers limits the addresses tracked in an interval, traffic from new
+// addresses beyond the limit is not attributed to a talker
+const maxTalkers = 10000
+
+// aggregator summarizes decoded flows, protocol and group totals are
+// cumulative, talkers are reset each time a snapshot is taken
+type aggregator struct {
+	groups  []prefixGroup
+	topN    int
+	protos  map[string]*protoCounters
+	grouped map[groupKey]*counters
+	talkers map[string]*counters
+	sync.Mutex
+}
+
+// aggregateSnapshot a copy of the aggregator state for a collection
+type aggregateSnapshot struct {
+	protos  map[string]protoCounters
+	grouped map[groupKey]counters
+	talkers []talker // top talkers by bytes, largest first
+}
+
+func newAggregator(groups []prefixGroup, topN int) *aggregator {
+	return &aggregator{
+		groups:  groups,
+		topN:    topN,
+		protos:  make(map[string]*protoCounters),
+		grouped: make(map[groupKey]*counters),
+		talkers: make(map[string]*counters),
+	}
+}
+
+func (a *aggregator) proto(name string) *protoCounters {
+	pc, ok := a.protos[name]
+	if !ok {
+		pc = &protoCounters{}
+		a.protos[name] = pc
+	}
+	return pc
+}
+
+// add records decoded from one datagram
+func (a *aggregator) add(proto string, records []flowRecord) {
+	a.Lock()
+	defer a.Unlock()
+
+	pc := a.proto(proto)
+	pc.datagrams++
+
+	for _, r := range records {
+		pc.add(r)
+
+		for i := range a.groups {
+			g := &a.groups[i]
+			if g.contains(r.dst) {
+				a.group(groupKey{g.name, "in"}).add(r)
+			}
+			if g.contains(r.src) {
+				a.group(groupKey{g.name, "out"}).add(r)
+			}
+		}
+
+		if a.topN > 0 && r.src != nil {
+			addr := r.src.String()
+			t, ok := a.talkers[addr]
+			if !ok {
+				if len(a.talkers) >= maxTalkers {
+					continue
+				}
+				t = &counters{}
+				a.talkers[addr] = t
+			}
+			t.add(r)
+		}
+	}
+}
+
+func (a *aggregator) group(k groupKey) *counters {
+	c, ok := a.grouped[k]
+	if !ok {
+		c = &counters{}
+		a.grouped[k] = c
+	}
+	return c
+}
+
+// addError counts a datagram which could not be decoded
+func (a *aggregator) addError(proto string) {
+	a.Lock()
+	defer a.Unlock()
+	pc := a.proto(proto)
+	pc.datagrams++
+	pc.errors++
+}
+
+// snapshot returns the current totals and the top talkers since the last snapshot
+func (a *aggregator) snapshot() aggregateSnapshot {
+	a.Lock()
+	defer a.Unlock()
+
+	s := aggregateSnapshot{
+		protos:  make(map[string]protoCounters, len(a.protos)),
+		grouped: make(map[groupKey]counters, len(a.grouped)),
+	}
+	for k, v := range a.protos {
+		s.protos[k] = *v
+	}
+	for k, v := range a.grouped {
+		s.grouped[k] = *v
+	}
+
+	if a.topN > 0 {
+		talkers := make([]talker, 0, len(a.talkers))
+		for addr, c := range a.talkers {
+			talkers = append(talkers, talker{addr: addr, counters: *c})
+		}
+		sort.Slice(talkers, func(i, j int) bool {
+			if talkers[i].bytes == talkers[j].bytes {
+				return talkers[i].addr < talkers[j].addr
+			}
+			return talkers[i].bytes > talkers[j].bytes
+		})
+		if len(talkers) > a.topN {
+			talkers = talkers[:a.topN]
+		}
+		s.talkers = talkers
+		a.talkers = make(map[string]*counters)
+	}
+
+	return s
+}
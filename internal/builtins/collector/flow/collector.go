@@ -0,0 +1,214 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package flow
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// common defines flow metrics common elements
+type common struct {
+	id              string         // OPT id of the collector (used as metric name prefix)
+	pkgID           string         // package prefix used for logging and errors
+	lastEnd         time.Time      // last collection end time
+	lastError       string         // last collection error
+	lastMetrics     cgm.Metrics    // last metrics collected
+	lastRunDuration time.Duration  // last collection duration
+	lastStart       time.Time      // last collection start time
+	logger          zerolog.Logger // collector logging instance
+	running         bool           // is collector currently running
+	runTTL          time.Duration  // OPT ttl for collectors (default is for every request)
+	baseTags        tags.Tags
+	sync.Mutex
+}
+
+// commonOptions defines the options all collectors support in a config file
+type commonOptions struct {
+	ID     string `json:"id" toml:"id" yaml:"id"`
+	RunTTL string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// Define stubs to satisfy the collector.Collector interface.
+//
+// The individual collector implementations must override Collect and Flush.
+//
+// ID and Inventory are generic and do not need to be overridden unless the
+// collector implementation requires it.
+
+func newCommon(id string, baseTags cgm.Tags) common {
+	return common{
+		id:       id,
+		pkgID:    PackageName + "." + id,
+		logger:   log.With().Str("pkg", PackageName).Str("id", id).Logger(),
+		runTTL:   time.Duration(0),
+		baseTags: baseTags,
+	}
+}
+
+// loadOptions loads a collector config file (if one exists) into opts
+func (c *common) loadOptions(cfgBaseName string, opts interface{}) error {
+	if cfgBaseName == "" {
+		return nil
+	}
+
+	err := config.LoadConfigFile(cfgBaseName, opts)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return nil
+		}
+		c.logger.Warn().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Str("base", cfgBaseName).Interface("config", opts).Msg("loaded config")
+	return nil
+}
+
+// applyCommonOptions applies id and run_ttl settings
+func (c *common) applyCommonOptions(opts commonOptions) error {
+	if opts.ID != "" {
+		c.id = opts.ID
+	}
+
+	if opts.RunTTL != "" {
+		dur, err := time.ParseDuration(opts.RunTTL)
+		if err != nil {
+			return errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return nil
+}
+
+// Collect returns collector metrics
+func (c *common) Collect(ctx context.Context) error {
+	c.Lock()
+	defer c.Unlock()
+	return collector.ErrNotImplemented
+}
+
+// Flush returns last metrics collected
+func (c *common) Flush() cgm.Metrics {
+	c.Lock()
+	defer c.Unlock()
+	if c.lastMetrics == nil {
+		c.lastMetrics = cgm.Metrics{}
+	}
+	return c.lastMetrics
+}
+
+// ID returns the id of the instance
+func (c *common) ID() string {
+	c.Lock()
+	defer c.Unlock()
+	return c.id
+}
+
+// Inventory returns collector stats for /inventory endpoint
+func (c *common) Inventory() collector.InventoryStats {
+	c.Lock()
+	defer c.Unlock()
+	return collector.InventoryStats{
+		ID:              c.id,
+		LastRunStart:    c.lastStart.Format(time.RFC3339Nano),
+		LastRunEnd:      c.lastEnd.Format(time.RFC3339Nano),
+		LastRunDuration: c.lastRunDuration.String(),
+		LastError:       c.lastError,
+	}
+}
+
+// Logger returns collector's instance of logger
+func (c *common) Logger() zerolog.Logger {
+	return c.logger
+}
+
+// startRun checks the run ttl and running state, marks the collector as running
+func (c *common) startRun() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	return nil
+}
+
+// addMetric to internal buffer if metric is active
+func (c *common) addMetric(metrics *cgm.Metrics, prefix string, mname, mtype string, mval interface{}, mtags tags.Tags) error {
+	if metrics == nil {
+		return errors.New("invalid metric submission")
+	}
+
+	if mname == "" {
+		return errors.New("invalid metric, no name")
+	}
+
+	if mtype == "" {
+		return errors.New("invalid metric, no type")
+	}
+
+	metricName := mname
+	if prefix != "" {
+		metricName = prefix + defaults.MetricNameSeparator + mname
+	}
+
+	tagList := tags.GetTags()
+	defer tags.PutTags(tagList)
+	*tagList = append(*tagList, c.baseTags...)
+	*tagList = append(*tagList,
+		tags.Tag{Category: "source", Value: release.NAME},
+		tags.Tag{Category: "collector", Value: c.id})
+	*tagList = append(*tagList, mtags...)
+
+	metricName = tags.MetricNameWithStreamTags(metricName, *tagList)
+	(*metrics)[metricName] = cgm.Metric{Type: mtype, Value: mval}
+
+	return nil
+}
+
+// setStatus is used in Collect to set the collector status
+func (c *common) setStatus(metrics cgm.Metrics, err error) {
+	c.Lock()
+	if err == nil {
+		c.lastError = ""
+		c.lastMetrics = metrics
+	} else {
+		c.lastError = err.Error()
+		// on error, ensure metrics are reset
+		// do not keep returning a stale set of metrics
+		c.lastMetrics = cgm.Metrics{}
+	}
+	c.lastEnd = time.Now()
+	if !c.lastStart.IsZero() {
+		c.lastRunDuration = time.Since(c.lastStart)
+	}
+	c.running = false
+	c.Unlock()
+}
@@ -0,0 +1,269 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package flow builtin network flow summarizer, receives sFlow v5, NetFlow v5
+// and IPFIX datagrams and reports aggregate traffic metrics
+package flow
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	appstats "github.com/maier/go-appstats"
+	"github.com/pkg/errors"
+)
+
+const (
+	NameFlow    = "flow"
+	PackageName = "builtins.flow"
+
+	protoSflow    = "sflow"
+	protoNetflow5 = "netflow5"
+	protoIPFIX    = "ipfix"
+	protoUnknown  = "unknown"
+
+	maxDatagramSize   = 65535
+	defaultTopTalkers = 10
+)
+
+// defaultListen NetFlow, sFlow and IPFIX ports, the format of each datagram is
+// detected so any listener accepts any of the formats
+var defaultListen = []string{":2055", ":6343", ":4739"}
+
+// Flow summarizes received flow datagrams
+type Flow struct {
+	common
+	agg       *aggregator
+	templates *ipfixTemplates
+	conns     []*net.UDPConn
+}
+
+// flowOptions defines what elements can be overridden in a config file
+type flowOptions struct {
+	commonOptions
+
+	// collector specific
+	Listen       []string            `json:"listen" toml:"listen" yaml:"listen"`
+	PrefixGroups map[string][]string `json:"prefix_groups" toml:"prefix_groups" yaml:"prefix_groups"`
+	TopTalkers   int                 `json:"top_talkers" toml:"top_talkers" yaml:"top_talkers"`
+}
+
+// New creates new flow collector, listeners run until ctx is done
+func New(ctx context.Context, cfgBaseName string) (collector.Collector, error) {
+	c := Flow{
+		common:    newCommon(NameFlow, tags.FromList(tags.GetBaseTags())),
+		templates: newIPFIXTemplates(),
+	}
+
+	// Flow is a special builtin, like prom it requires a configuration file,
+	// listening for flows is only enabled when explicitly configured. The
+	// default config is a file named flow_collector.(json|toml|yaml) located
+	// in the agent's default etc path.
+	if cfgBaseName == "" {
+		cfgBaseName = path.Join(defaults.EtcPath, "flow_collector")
+	}
+
+	var opts flowOptions
+	if err := config.LoadConfigFile(cfgBaseName, &opts); err != nil {
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Str("base", cfgBaseName).Interface("config", opts).Msg("loaded config")
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	groupNames := make([]string, 0, len(opts.PrefixGroups))
+	for name := range opts.PrefixGroups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	groups := make([]prefixGroup, 0, len(groupNames))
+	for _, name := range groupNames {
+		g := prefixGroup{name: name}
+		for _, cidr := range opts.PrefixGroups[name] {
+			_, n, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s parsing prefix group %s", c.pkgID, name)
+			}
+			g.nets = append(g.nets, n)
+		}
+		groups = append(groups, g)
+	}
+
+	topN := opts.TopTalkers
+	if topN == 0 {
+		topN = defaultTopTalkers
+	}
+	c.agg = newAggregator(groups, topN) // negative disables top talkers
+
+	listen := opts.Listen
+	if len(listen) == 0 {
+		listen = defaultListen
+	}
+	for _, addr := range listen {
+		if err := c.listen(ctx, addr); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		c.close()
+	}()
+
+	return &c, nil
+}
+
+// listen starts a udp listener receiving flow datagrams
+func (c *Flow) listen(ctx context.Context, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrapf(err, "%s invalid listen address '%s'", c.pkgID, address)
+	}
+	address = net.JoinHostPort(config.StripBrackets(host), port)
+
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return errors.Wrapf(err, "%s resolving listen address '%s'", c.pkgID, address)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "%s listening on '%s'", c.pkgID, address)
+	}
+
+	c.Lock()
+	c.conns = append(c.conns, conn)
+	c.Unlock()
+
+	c.logger.Info().Str("addr", conn.LocalAddr().String()).Msg("flow listener started")
+
+	go func() {
+		buff := make([]byte, maxDatagramSize)
+		for {
+			n, from, err := conn.ReadFromUDP(buff)
+			if err != nil {
+				if ctx.Err() != nil || strings.Contains(err.Error(), "use of closed network connection") {
+					return
+				}
+				c.logger.Warn().Err(err).Msg("flow reader")
+				continue
+			}
+			_ = appstats.IncrementInt("flow_datagrams_total")
+			c.handle(from.IP.String(), buff[:n])
+		}
+	}()
+
+	return nil
+}
+
+// close stops the listeners
+func (c *Flow) close() {
+	c.Lock()
+	defer c.Unlock()
+	for _, conn := range c.conns {
+		_ = conn.Close()
+	}
+	c.conns = nil
+}
+
+// handle decodes a datagram and adds its flows to the aggregates
+func (c *Flow) handle(exporter string, data []byte) {
+	proto := detectProto(data)
+
+	var records []flowRecord
+	var err error
+	switch proto {
+	case protoSflow:
+		records, err = decodeSflow(data)
+	case protoNetflow5:
+		records, err = decodeNetflow5(data)
+	case protoIPFIX:
+		records, err = c.templates.decodeIPFIX(exporter, data)
+	default:
+		err = errors.New("unsupported flow datagram")
+	}
+
+	if err != nil {
+		c.logger.Debug().Err(err).Str("exporter", exporter).Str("proto", proto).Msg("decoding datagram")
+		c.agg.addError(proto)
+		return
+	}
+
+	c.agg.add(proto, records)
+}
+
+// detectProto identifies the datagram format from the version field, sFlow
+// has a 32 bit version, NetFlow and IPFIX a 16 bit version
+func detectProto(data []byte) string {
+	if len(data) < 4 {
+		return protoUnknown
+	}
+	switch binary.BigEndian.Uint16(data[0:2]) {
+	case 5:
+		return protoNetflow5
+	case 10:
+		return protoIPFIX
+	case 0:
+		if binary.BigEndian.Uint32(data[0:4]) == 5 {
+			return protoSflow
+		}
+	}
+	return protoUnknown
+}
+
+// Collect reports the flow aggregates
+func (c *Flow) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	snap := c.agg.snapshot()
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPackets := tags.Tag{Category: "units", Value: "packets"}
+
+	for proto, pc := range snap.protos {
+		protoTag := tags.Tag{Category: "flow-protocol", Value: proto}
+		_ = c.addMetric(&metrics, "", "datagrams", "L", pc.datagrams, tags.Tags{protoTag})
+		_ = c.addMetric(&metrics, "", "decode_errors", "L", pc.errors, tags.Tags{protoTag})
+		_ = c.addMetric(&metrics, "", "flows", "L", pc.flows, tags.Tags{protoTag})
+		_ = c.addMetric(&metrics, "", "traffic", "L", pc.bytes, tags.Tags{protoTag, tagUnitsBytes})
+		_ = c.addMetric(&metrics, "", "traffic", "L", pc.packets, tags.Tags{protoTag, tagUnitsPackets})
+	}
+
+	for k, gc := range snap.grouped {
+		groupTags := tags.Tags{
+			tags.Tag{Category: "prefix-group", Value: k.group},
+			tags.Tag{Category: "direction", Value: k.direction},
+		}
+		_ = c.addMetric(&metrics, "", "group_flows", "L", gc.flows, groupTags)
+		_ = c.addMetric(&metrics, "", "group_traffic", "L", gc.bytes, append(groupTags, tagUnitsBytes))
+		_ = c.addMetric(&metrics, "", "group_traffic", "L", gc.packets, append(groupTags, tagUnitsPackets))
+	}
+
+	// talker traffic is since the last collection
+	for _, t := range snap.talkers {
+		talkerTag := tags.Tag{Category: "talker", Value: t.addr}
+		_ = c.addMetric(&metrics, "", "talker_traffic", "L", t.bytes, tags.Tags{talkerTag, tagUnitsBytes})
+		_ = c.addMetric(&metrics, "", "talker_traffic", "L", t.packets, tags.Tags{talkerTag, tagUnitsPackets})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -0,0 +1,409 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package flow
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+type testFlow struct {
+	src, dst string
+	packets  uint32
+	bytes    uint32
+}
+
+// netflow5Datagram builds a NetFlow v5 datagram
+func netflow5Datagram(rate uint16, flows ...testFlow) []byte {
+	data := make([]byte, netflow5HeaderLen+len(flows)*netflow5RecordLen)
+	binary.BigEndian.PutUint16(data[0:2], 5)
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(flows)))
+	binary.BigEndian.PutUint16(data[22:24], 0x4000|rate) // mode 1, deterministic
+	for i, f := range flows {
+		r := data[netflow5HeaderLen+i*netflow5RecordLen:]
+		copy(r[0:4], net.ParseIP(f.src).To4())
+		copy(r[4:8], net.ParseIP(f.dst).To4())
+		binary.BigEndian.PutUint32(r[16:20], f.packets)
+		binary.BigEndian.PutUint32(r[20:24], f.bytes)
+	}
+	return data
+}
+
+func put16(b []byte, v ...uint16) []byte {
+	for _, n := range v {
+		b = append(b, byte(n>>8), byte(n))
+	}
+	return b
+}
+
+func put32(b []byte, v ...uint32) []byte {
+	for _, n := range v {
+		b = append(b, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return b
+}
+
+// ipfixMessage wraps sets in an IPFIX message header
+func ipfixMessage(domain uint32, sets ...[]byte) []byte {
+	msg := put16(nil, 10, 0)
+	msg = put32(msg, 0, 1, domain)
+	for _, s := range sets {
+		msg = append(msg, s...)
+	}
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(msg)))
+	return msg
+}
+
+// ipfixSet builds a set with the given id and body
+func ipfixSet(id uint16, body []byte) []byte {
+	s := put16(nil, id, uint16(len(body)+ipfixSetHeaderLen))
+	return append(s, body...)
+}
+
+// ipfixTemplate template 256: src v4, dst v4, octets (8), packets (4), an
+// enterprise field and a variable length field
+func ipfixTemplate() []byte {
+	t := put16(nil, 256, 6)
+	t = put16(t, ieSourceIPv4Address, 4, ieDestIPv4Address, 4, ieOctetDeltaCount, 8, iePacketDeltaCount, 4)
+	t = put16(t, 0x8000|100, 2)
+	t = put32(t, 9)
+	t = put16(t, 82, ipfixVariableLength) // interfaceName
+	return ipfixSet(ipfixTemplateSetID, t)
+}
+
+func ipfixRecord(f testFlow, ifName string) []byte {
+	r := append([]byte(nil), net.ParseIP(f.src).To4()...)
+	r = append(r, net.ParseIP(f.dst).To4()...)
+	r = put32(r, 0, f.bytes)
+	r = put32(r, f.packets)
+	r = put16(r, 7)
+	r = append(r, byte(len(ifName)))
+	return append(r, ifName...)
+}
+
+// sflowDatagram builds an sFlow v5 datagram with one flow sample holding a
+// raw ethernet/ipv4 header record and one counter sample
+func sflowDatagram(rate uint32, frameLen uint32, src, dst string) []byte {
+	hdr := make([]byte, 14+20)
+	binary.BigEndian.PutUint16(hdr[12:14], etherTypeIPv4)
+	hdr[14] = 0x45
+	copy(hdr[14+12:14+16], net.ParseIP(src).To4())
+	copy(hdr[14+16:14+20], net.ParseIP(dst).To4())
+	hdr = append(hdr, 0, 0) // pad to 4 bytes
+
+	raw := put32(nil, sflowHeaderProtoEthernet, frameLen, 4, 34)
+	raw = append(raw, hdr...)
+
+	sample := put32(nil, 1, 3, rate, 1000, 0, 1, 2, 1)
+	sample = put32(sample, sflowRawPacketHeader, uint32(len(raw)))
+	sample = append(sample, raw...)
+
+	counters := put32(nil, 1, 3, 0)
+
+	d := put32(nil, 5, sflowAddrIPv4)
+	d = append(d, 192, 0, 2, 1)
+	d = put32(d, 0, 1, 1000, 2)
+	d = put32(d, sflowFlowSample, uint32(len(sample)))
+	d = append(d, sample...)
+	d = put32(d, 2, uint32(len(counters)))
+	d = append(d, counters...)
+	return d
+}
+
+func TestDetectProto(t *testing.T) {
+	t.Log("Testing detectProto")
+
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{netflow5Datagram(0), protoNetflow5},
+		{ipfixMessage(1), protoIPFIX},
+		{sflowDatagram(1, 64, "10.0.0.1", "10.0.0.2"), protoSflow},
+		{put16(nil, 9, 0), protoUnknown},
+		{[]byte{0}, protoUnknown},
+	}
+	for _, tt := range tests {
+		if got := detectProto(tt.data); got != tt.want {
+			t.Fatalf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestDecodeNetflow5(t *testing.T) {
+	t.Log("Testing decodeNetflow5")
+
+	data := netflow5Datagram(10,
+		testFlow{"10.1.2.3", "192.168.10.5", 3, 1500},
+		testFlow{"192.168.10.5", "10.1.2.3", 2, 200})
+
+	records, err := decodeNetflow5(data)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	r := records[0]
+	if r.src.String() != "10.1.2.3" || r.dst.String() != "192.168.10.5" {
+		t.Fatalf("unexpected addresses %s -> %s", r.src, r.dst)
+	}
+	if r.bytes != 15000 || r.packets != 30 {
+		t.Fatalf("expected sampled 15000 bytes 30 packets, got %d %d", r.bytes, r.packets)
+	}
+
+	t.Log("\ttruncated")
+	{
+		if _, err := decodeNetflow5(data[:len(data)-1]); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	t.Log("Testing decodeIPFIX")
+
+	tmpl := newIPFIXTemplates()
+	data := ipfixSet(256, append(ipfixRecord(testFlow{"10.1.0.9", "8.8.8.8", 4, 4000}, "eth0"), 0, 0)) // padded
+
+	t.Log("\tdata before template")
+	{
+		records, err := tmpl.decodeIPFIX("192.0.2.1", ipfixMessage(1, data))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if len(records) != 0 {
+			t.Fatalf("expected 0 records, got %d", len(records))
+		}
+	}
+
+	t.Log("\ttemplate and data")
+	{
+		records, err := tmpl.decodeIPFIX("192.0.2.1", ipfixMessage(1, ipfixTemplate(), data))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if len(records) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(records))
+		}
+		r := records[0]
+		if r.src.String() != "10.1.0.9" || r.dst.String() != "8.8.8.8" || r.bytes != 4000 || r.packets != 4 {
+			t.Fatalf("unexpected record %s -> %s %d %d", r.src, r.dst, r.bytes, r.packets)
+		}
+	}
+
+	t.Log("\ttemplate scoped to exporter and domain")
+	{
+		records, err := tmpl.decodeIPFIX("192.0.2.1", ipfixMessage(2, data))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if len(records) != 0 {
+			t.Fatalf("expected 0 records, got %d", len(records))
+		}
+	}
+
+	t.Log("\tinvalid set length")
+	{
+		msg := ipfixMessage(1, data)
+		binary.BigEndian.PutUint16(msg[ipfixHeaderLen+2:], 2)
+		if _, err := tmpl.decodeIPFIX("192.0.2.1", msg); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestDecodeSflow(t *testing.T) {
+	t.Log("Testing decodeSflow")
+
+	records, err := decodeSflow(sflowDatagram(512, 1514, "10.1.0.7", "192.168.10.20"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.src.String() != "10.1.0.7" || r.dst.String() != "192.168.10.20" {
+		t.Fatalf("unexpected addresses %s -> %s", r.src, r.dst)
+	}
+	if r.bytes != 1514*512 || r.packets != 512 {
+		t.Fatalf("expected sampled %d bytes 512 packets, got %d %d", 1514*512, r.bytes, r.packets)
+	}
+
+	t.Log("\ttruncated")
+	{
+		data := sflowDatagram(512, 1514, "10.1.0.7", "192.168.10.20")
+		if _, err := decodeSflow(data[:40]); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	t.Log("Testing aggregator")
+
+	_, office, _ := net.ParseCIDR("10.1.0.0/16")
+	a := newAggregator([]prefixGroup{{name: "office", nets: []*net.IPNet{office}}}, 1)
+
+	a.add(protoNetflow5, []flowRecord{
+		{src: net.ParseIP("10.1.0.1"), dst: net.ParseIP("8.8.8.8"), bytes: 100, packets: 1},
+		{src: net.ParseIP("8.8.8.8"), dst: net.ParseIP("10.1.0.1"), bytes: 900, packets: 3},
+	})
+	a.addError(protoNetflow5)
+
+	s := a.snapshot()
+	pc := s.protos[protoNetflow5]
+	if pc.datagrams != 2 || pc.errors != 1 || pc.flows != 2 || pc.bytes != 1000 {
+		t.Fatalf("unexpected protocol counters %+v", pc)
+	}
+	if g := s.grouped[groupKey{"office", "in"}]; g.bytes != 900 || g.packets != 3 {
+		t.Fatalf("unexpected office in counters %+v", g)
+	}
+	if g := s.grouped[groupKey{"office", "out"}]; g.bytes != 100 {
+		t.Fatalf("unexpected office out counters %+v", g)
+	}
+	if len(s.talkers) != 1 || s.talkers[0].addr != "8.8.8.8" {
+		t.Fatalf("expected top talker 8.8.8.8, got %+v", s.talkers)
+	}
+
+	t.Log("\ttalkers reset, totals cumulative")
+	{
+		s := a.snapshot()
+		if len(s.talkers) != 0 {
+			t.Fatalf("expected no talkers, got %+v", s.talkers)
+		}
+		if s.protos[protoNetflow5].bytes != 1000 {
+			t.Fatalf("expected cumulative bytes 1000, got %d", s.protos[protoNetflow5].bytes)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tno config")
+	{
+		if _, err := New(context.Background(), filepath.Join("testdata", "missing")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tbad prefix")
+	{
+		if _, err := New(context.Background(), filepath.Join("testdata", "bad_prefix")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Log("Testing Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := New(ctx, filepath.Join("testdata", "flow_collector"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	fc := c.(*Flow)
+	addr := fc.conns[0].LocalAddr().(*net.UDPAddr)
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatalf("dialing listener (%s)", err)
+	}
+	defer conn.Close()
+
+	datagrams := [][]byte{
+		netflow5Datagram(0,
+			testFlow{"10.1.2.3", "192.168.10.5", 3, 1500},
+			testFlow{"10.1.2.4", "192.168.10.5", 1, 100},
+			testFlow{"172.16.0.1", "192.168.10.5", 2, 700}),
+		sflowDatagram(10, 100, "192.168.10.5", "10.1.2.3"),
+		{0xff, 0xff, 0, 0},
+	}
+	for _, d := range datagrams {
+		if _, err := conn.Write(d); err != nil {
+			t.Fatalf("writing datagram (%s)", err)
+		}
+	}
+
+	// wait for the datagrams to be handled
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fc.agg.Lock()
+		n := 0
+		for _, pc := range fc.agg.protos {
+			n += int(pc.datagrams)
+		}
+		fc.agg.Unlock()
+		if n == len(datagrams) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for datagrams, %d handled", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := c.Collect(ctx); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "flows", "flow-protocol:netflow5"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected netflow5 flows 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "traffic", "flow-protocol:sflow", "units:bytes"); !ok || m.Value.(uint64) != 1000 {
+		t.Fatalf("expected sflow bytes 1000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "decode_errors", "flow-protocol:unknown"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected unknown decode_errors 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "group_traffic", "prefix-group:servers", "direction:in", "units:bytes"); !ok || m.Value.(uint64) != 2300 {
+		t.Fatalf("expected servers in bytes 2300, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "group_traffic", "prefix-group:office", "direction:in", "units:bytes"); !ok || m.Value.(uint64) != 1000 {
+		t.Fatalf("expected office in bytes 1000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "talker_traffic", "talker:10.1.2.3", "units:bytes"); !ok || m.Value.(uint64) != 1500 {
+		t.Fatalf("expected talker 10.1.2.3 bytes 1500, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "talker_traffic", "talker:10.1.2.4"); ok {
+		t.Fatal("expected 10.1.2.4 to not be a top talker")
+	}
+
+	t.Log("\tlisteners closed on cancel")
+	{
+		cancel()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			fc.Lock()
+			n := len(fc.conns)
+			fc.Unlock()
+			if n == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for listeners to close")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
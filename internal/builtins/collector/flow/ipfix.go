@@ -0,0 +1,219 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package flow
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ipfixHeaderLen      = 16
+	ipfixSetHeaderLen   = 4
+	ipfixTemplateSetID  = 2
+	ipfixOptionsSetID   = 3
+	ipfixMinDataSetID   = 256
+	ipfixVariableLength = 65535
+
+	// information elements used
+	ieOctetDeltaCount   = 1
+	iePacketDeltaCount  = 2
+	ieSourceIPv4Address = 8
+	ieDestIPv4Address   = 12
+	ieSourceIPv6Address = 27
+	ieDestIPv6Address   = 28
+	ieSamplingInterval  = 34
+)
+
+// ipfixField template field specifier
+type ipfixField struct {
+	id     uint16
+	length uint16
+	pen    bool // enterprise specific, never one of the elements used
+}
+
+// ipfixTemplateKey templates are scoped to the exporter and observation domain
+type ipfixTemplateKey struct {
+	exporter string
+	domain   uint32
+	id       uint16
+}
+
+// ipfixTemplates caches the templates announced by exporters, data sets
+// received before their template are dropped
+type ipfixTemplates struct {
+	templates map[ipfixTemplateKey][]ipfixField
+	sync.Mutex
+}
+
+func newIPFIXTemplates() *ipfixTemplates {
+	return &ipfixTemplates{templates: make(map[ipfixTemplateKey][]ipfixField)}
+}
+
+// decodeIPFIX decodes an IPFIX message, templates are added to the cache and
+// data records for known templates are returned
+func (t *ipfixTemplates) decodeIPFIX(exporter string, data []byte) ([]flowRecord, error) {
+	if len(data) < ipfixHeaderLen {
+		return nil, errors.Errorf("ipfix message too short (%d)", len(data))
+	}
+
+	msgLen := int(binary.BigEndian.Uint16(data[2:4]))
+	if msgLen < ipfixHeaderLen || msgLen > len(data) {
+		return nil, errors.Errorf("ipfix message length invalid (%d of %d)", msgLen, len(data))
+	}
+	domain := binary.BigEndian.Uint32(data[12:16])
+
+	var records []flowRecord
+	for off := ipfixHeaderLen; off+ipfixSetHeaderLen <= msgLen; {
+		setID := binary.BigEndian.Uint16(data[off : off+2])
+		setLen := int(binary.BigEndian.Uint16(data[off+2 : off+4]))
+		if setLen < ipfixSetHeaderLen || off+setLen > msgLen {
+			return records, errors.Errorf("ipfix set length invalid (%d)", setLen)
+		}
+		set := data[off+ipfixSetHeaderLen : off+setLen]
+		off += setLen
+
+		switch {
+		case setID == ipfixTemplateSetID:
+			if err := t.addTemplates(exporter, domain, set); err != nil {
+				return records, err
+			}
+		case setID == ipfixOptionsSetID:
+			// options templates describe exporter metadata, not flows
+		case setID >= ipfixMinDataSetID:
+			t.Lock()
+			fields, ok := t.templates[ipfixTemplateKey{exporter, domain, setID}]
+			t.Unlock()
+			if !ok {
+				continue // template not seen yet
+			}
+			recs, err := decodeIPFIXData(fields, set)
+			if err != nil {
+				return records, err
+			}
+			records = append(records, recs...)
+		}
+	}
+
+	return records, nil
+}
+
+// addTemplates parses a template set
+func (t *ipfixTemplates) addTemplates(exporter string, domain uint32, set []byte) error {
+	for off := 0; off+4 <= len(set); {
+		id := binary.BigEndian.Uint16(set[off : off+2])
+		count := int(binary.BigEndian.Uint16(set[off+2 : off+4]))
+		off += 4
+		if id < ipfixMinDataSetID {
+			break // set padding
+		}
+
+		fields := make([]ipfixField, 0, count)
+		for i := 0; i < count; i++ {
+			if off+4 > len(set) {
+				return errors.Errorf("ipfix template %d truncated", id)
+			}
+			f := ipfixField{
+				id:     binary.BigEndian.Uint16(set[off:off+2]) & 0x7fff,
+				length: binary.BigEndian.Uint16(set[off+2 : off+4]),
+				pen:    set[off]&0x80 != 0,
+			}
+			off += 4
+			if f.pen {
+				off += 4 // enterprise number
+			}
+			fields = append(fields, f)
+		}
+
+		t.Lock()
+		t.templates[ipfixTemplateKey{exporter, domain, id}] = fields
+		t.Unlock()
+	}
+	return nil
+}
+
+// decodeIPFIXData decodes the records in a data set
+func decodeIPFIXData(fields []ipfixField, set []byte) ([]flowRecord, error) {
+	minLen := minRecordLen(fields)
+	if minLen == 0 {
+		return nil, nil
+	}
+
+	var records []flowRecord
+	// anything shorter than a record at the end of the set is padding
+	for off := 0; off+minLen <= len(set); {
+		var r flowRecord
+		rate := uint64(1)
+		for _, f := range fields {
+			l := int(f.length)
+			if f.length == ipfixVariableLength {
+				if off >= len(set) {
+					return records, errors.New("ipfix variable length field truncated")
+				}
+				l = int(set[off])
+				off++
+				if l == 255 {
+					if off+2 > len(set) {
+						return records, errors.New("ipfix variable length field truncated")
+					}
+					l = int(binary.BigEndian.Uint16(set[off : off+2]))
+					off += 2
+				}
+			}
+			if off+l > len(set) {
+				return records, errors.New("ipfix data record truncated")
+			}
+			v := set[off : off+l]
+			off += l
+			if f.pen {
+				continue
+			}
+			switch f.id {
+			case ieOctetDeltaCount:
+				r.bytes = readUintN(v)
+			case iePacketDeltaCount:
+				r.packets = readUintN(v)
+			case ieSamplingInterval:
+				if n := readUintN(v); n > 0 {
+					rate = n
+				}
+			case ieSourceIPv4Address, ieSourceIPv6Address:
+				r.src = net.IP(append([]byte(nil), v...))
+			case ieDestIPv4Address, ieDestIPv6Address:
+				r.dst = net.IP(append([]byte(nil), v...))
+			}
+		}
+		r.bytes *= rate
+		r.packets *= rate
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// minRecordLen minimum length of a record, variable length fields take at least one byte
+func minRecordLen(fields []ipfixField) int {
+	n := 0
+	for _, f := range fields {
+		if f.length == ipfixVariableLength {
+			n++
+			continue
+		}
+		n += int(f.length)
+	}
+	return n
+}
+
+// readUintN reads a big endian unsigned integer of up to 8 bytes (reduced size encoding)
+func readUintN(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package flow
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	netflow5HeaderLen = 24
+	netflow5RecordLen = 48
+)
+
+// decodeNetflow5 decodes a NetFlow v5 datagram, byte and packet counts are
+// scaled by the sampling interval from the header (when sampling is enabled)
+func decodeNetflow5(data []byte) ([]flowRecord, error) {
+	if len(data) < netflow5HeaderLen {
+		return nil, errors.Errorf("netflow v5 datagram too short (%d)", len(data))
+	}
+
+	count := int(binary.BigEndian.Uint16(data[2:4]))
+	if len(data) < netflow5HeaderLen+count*netflow5RecordLen {
+		return nil, errors.Errorf("netflow v5 datagram truncated, %d records in %d bytes", count, len(data))
+	}
+
+	// the low 14 bits are the interval, the high 2 bits the sampling mode
+	rate := uint64(binary.BigEndian.Uint16(data[22:24]) & 0x3fff)
+	if rate == 0 {
+		rate = 1
+	}
+
+	records := make([]flowRecord, 0, count)
+	for i := 0; i < count; i++ {
+		r := data[netflow5HeaderLen+i*netflow5RecordLen:]
+		records = append(records, flowRecord{
+			src:     net.IP(append([]byte(nil), r[0:4]...)),
+			dst:     net.IP(append([]byte(nil), r[4:8]...)),
+			packets: uint64(binary.BigEndian.Uint32(r[16:20])) * rate,
+			bytes:   uint64(binary.BigEndian.Uint32(r[20:24])) * rate,
+		})
+	}
+
+	return records, nil
+}
@@ -0,0 +1,286 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package flow
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sflowAddrIPv4 = 1
+	sflowAddrIPv6 = 2
+
+	// sample formats (enterprise 0)
+	sflowFlowSample         = 1
+	sflowExpandedFlowSample = 3
+
+	// flow record formats (enterprise 0)
+	sflowRawPacketHeader = 1
+	sflowSampledIPv4     = 3
+	sflowSampledIPv6     = 4
+
+	sflowHeaderProtoEthernet = 1
+	sflowHeaderProtoIPv4     = 11
+	sflowHeaderProtoIPv6     = 12
+
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+)
+
+// sflowReader reads the XDR encoded sFlow datagram fields
+type sflowReader struct {
+	data []byte
+	off  int
+}
+
+func (r *sflowReader) uint32() (uint32, error) {
+	if r.off+4 > len(r.data) {
+		return 0, errors.New("sflow datagram truncated")
+	}
+	v := binary.BigEndian.Uint32(r.data[r.off : r.off+4])
+	r.off += 4
+	return v, nil
+}
+
+// bytes returns the next n bytes, skipping the XDR padding to 4 bytes
+func (r *sflowReader) bytes(n int) ([]byte, error) {
+	padded := (n + 3) &^ 3
+	if n < 0 || r.off+padded > len(r.data) {
+		return nil, errors.New("sflow datagram truncated")
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += padded
+	return b, nil
+}
+
+// decodeSflow decodes an sFlow v5 datagram, each sampled packet is scaled by
+// the sampling rate to estimate the bytes and packets it represents, counter
+// samples are ignored
+func decodeSflow(data []byte) ([]flowRecord, error) {
+	r := &sflowReader{data: data}
+
+	if v, err := r.uint32(); err != nil {
+		return nil, err
+	} else if v != 5 {
+		return nil, errors.Errorf("unsupported sflow version (%d)", v)
+	}
+
+	addrType, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	switch addrType {
+	case sflowAddrIPv4:
+		_, err = r.bytes(net.IPv4len)
+	case sflowAddrIPv6:
+		_, err = r.bytes(net.IPv6len)
+	default:
+		err = errors.Errorf("unknown sflow agent address type (%d)", addrType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// sub agent id, sequence number, uptime
+	if _, err := r.bytes(12); err != nil {
+		return nil, err
+	}
+
+	numSamples, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []flowRecord
+	for i := uint32(0); i < numSamples; i++ {
+		format, err := r.uint32()
+		if err != nil {
+			return records, err
+		}
+		length, err := r.uint32()
+		if err != nil {
+			return records, err
+		}
+		sample, err := r.bytes(int(length))
+		if err != nil {
+			return records, err
+		}
+
+		switch format {
+		case sflowFlowSample, sflowExpandedFlowSample:
+			recs, err := decodeSflowFlowSample(sample, format == sflowExpandedFlowSample)
+			if err != nil {
+				return records, err
+			}
+			records = append(records, recs...)
+		}
+	}
+
+	return records, nil
+}
+
+// decodeSflowFlowSample decodes a (expanded) flow sample
+func decodeSflowFlowSample(data []byte, expanded bool) ([]flowRecord, error) {
+	r := &sflowReader{data: data}
+
+	// sequence number, source id (expanded: type and index)
+	skip := 8
+	if expanded {
+		skip = 12
+	}
+	if _, err := r.bytes(skip); err != nil {
+		return nil, err
+	}
+	rate, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if rate == 0 {
+		rate = 1
+	}
+	// sample pool, drops, input, output (expanded: format and value each)
+	skip = 16
+	if expanded {
+		skip = 24
+	}
+	if _, err := r.bytes(skip); err != nil {
+		return nil, err
+	}
+
+	numRecords, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []flowRecord
+	for i := uint32(0); i < numRecords; i++ {
+		format, err := r.uint32()
+		if err != nil {
+			return records, err
+		}
+		length, err := r.uint32()
+		if err != nil {
+			return records, err
+		}
+		data, err := r.bytes(int(length))
+		if err != nil {
+			return records, err
+		}
+
+		var rec flowRecord
+		var ok bool
+		switch format {
+		case sflowRawPacketHeader:
+			rec, ok = decodeSflowRawHeader(data)
+		case sflowSampledIPv4:
+			rec, ok = decodeSflowSampledIP(data, net.IPv4len)
+		case sflowSampledIPv6:
+			rec, ok = decodeSflowSampledIP(data, net.IPv6len)
+		}
+		if !ok {
+			continue
+		}
+		rec.bytes *= uint64(rate)
+		rec.packets = uint64(rate)
+		records = append(records, rec)
+		break // the other records describe the same packet
+	}
+
+	return records, nil
+}
+
+// decodeSflowRawHeader extracts the addresses from a sampled packet header
+func decodeSflowRawHeader(data []byte) (flowRecord, bool) {
+	r := &sflowReader{data: data}
+	proto, err := r.uint32()
+	if err != nil {
+		return flowRecord{}, false
+	}
+	frameLen, err := r.uint32()
+	if err != nil {
+		return flowRecord{}, false
+	}
+	if _, err = r.uint32(); err != nil { // stripped
+		return flowRecord{}, false
+	}
+	hdrLen, err := r.uint32()
+	if err != nil {
+		return flowRecord{}, false
+	}
+	hdr, err := r.bytes(int(hdrLen))
+	if err != nil {
+		return flowRecord{}, false
+	}
+
+	etherType := uint16(0)
+	switch proto {
+	case sflowHeaderProtoEthernet:
+		if len(hdr) < 14 {
+			return flowRecord{}, false
+		}
+		etherType = binary.BigEndian.Uint16(hdr[12:14])
+		hdr = hdr[14:]
+		for etherType == etherTypeVLAN && len(hdr) >= 4 {
+			etherType = binary.BigEndian.Uint16(hdr[2:4])
+			hdr = hdr[4:]
+		}
+	case sflowHeaderProtoIPv4:
+		etherType = etherTypeIPv4
+	case sflowHeaderProtoIPv6:
+		etherType = etherTypeIPv6
+	default:
+		return flowRecord{}, false
+	}
+
+	rec := flowRecord{bytes: uint64(frameLen)}
+	switch etherType {
+	case etherTypeIPv4:
+		if len(hdr) < 20 {
+			return flowRecord{}, false
+		}
+		rec.src = net.IP(append([]byte(nil), hdr[12:16]...))
+		rec.dst = net.IP(append([]byte(nil), hdr[16:20]...))
+	case etherTypeIPv6:
+		if len(hdr) < 40 {
+			return flowRecord{}, false
+		}
+		rec.src = net.IP(append([]byte(nil), hdr[8:24]...))
+		rec.dst = net.IP(append([]byte(nil), hdr[24:40]...))
+	default:
+		return flowRecord{}, false
+	}
+
+	return rec, true
+}
+
+// decodeSflowSampledIP decodes a sampled ipv4/ipv6 record
+func decodeSflowSampledIP(data []byte, addrLen int) (flowRecord, bool) {
+	r := &sflowReader{data: data}
+	length, err := r.uint32()
+	if err != nil {
+		return flowRecord{}, false
+	}
+	if _, err = r.uint32(); err != nil { // protocol
+		return flowRecord{}, false
+	}
+	src, err := r.bytes(addrLen)
+	if err != nil {
+		return flowRecord{}, false
+	}
+	dst, err := r.bytes(addrLen)
+	if err != nil {
+		return flowRecord{}, false
+	}
+	return flowRecord{
+		src:   net.IP(append([]byte(nil), src...)),
+		dst:   net.IP(append([]byte(nil), dst...)),
+		bytes: uint64(length),
+	}, true
+}
@@ -0,0 +1,240 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build freebsd
+
+package freebsd
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// cpu states, in kern.cp_time/kern.cp_times order (sys/resource.h)
+const (
+	cpUser = iota
+	cpNice
+	cpSys
+	cpIntr
+	cpIdle
+	cpuStates
+)
+
+// CPU metrics from sysctl kern.cp_time(s)
+type CPU struct {
+	common
+	numCPU        float64               // number of cpus
+	tickNorm      float64               // stathz ticks normalized to centiseconds
+	reportAllCPUs bool                  // OPT report all cpus (vs just total) may be overridden in config file
+	lastRunValues map[string]lastValues // values from last run
+}
+
+// cpuOptions defines what elements can be overridden in a config file
+type cpuOptions struct {
+	commonOptions
+
+	// collector specific
+	AllCPU string `json:"report_all_cpus" toml:"report_all_cpus" yaml:"report_all_cpus"`
+}
+
+type lastValues struct {
+	all  float64
+	busy float64
+}
+
+// NewCPUCollector creates new freebsd cpu collector
+func NewCPUCollector(cfgBaseName string) (collector.Collector, error) {
+	c := CPU{
+		common:        newCommon(NameCPU, tags.FromList(tags.GetBaseTags())),
+		numCPU:        float64(runtime.NumCPU()),
+		lastRunValues: make(map[string]lastValues),
+	}
+
+	stathz, err := statHz()
+	if err != nil {
+		return nil, errors.Wrap(err, c.pkgID)
+	}
+	c.tickNorm = 100 / float64(stathz)
+
+	var opts cpuOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.AllCPU != "" {
+		rpt, err := strconv.ParseBool(opts.AllCPU)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing report_all_cpus", c.pkgID)
+		}
+		c.reportAllCPUs = rpt
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysctl
+func (c *CPU) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	tagUnitsCentiseconds := tags.Tag{Category: "units", Value: "centiseconds"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	_ = c.addMetric(&metrics, "", "num_cpu", "I", runtime.NumCPU(), tags.Tags{})
+
+	data, err := sysctlRaw("kern.cp_time")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrapf(err, "%s kern.cp_time", c.pkgID)
+	}
+	states := decodeLongs(data)
+	if len(states) != cpuStates {
+		err := errors.Errorf("unexpected number of cpu states (%d)", len(states))
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	cpus := map[string][]uint64{"": states}
+
+	if c.reportAllCPUs {
+		data, err := sysctlRaw("kern.cp_times")
+		if err != nil {
+			c.setStatus(metrics, err)
+			return errors.Wrapf(err, "%s kern.cp_times", c.pkgID)
+		}
+		all := decodeLongs(data)
+		for i := 0; i+cpuStates <= len(all); i += cpuStates {
+			cpus[strconv.Itoa(i/cpuStates)] = all[i : i+cpuStates]
+		}
+	}
+
+	for id, states := range cpus {
+		numCPU := c.numCPU // aggregate cpu metrics
+		if id != "" {
+			numCPU = 1 // individual cpu metrics
+		}
+
+		for mn, mv := range c.cpuMetrics(id, states, numCPU) {
+			var tagList tags.Tags
+
+			if id != "" {
+				tagList = append(tagList, tags.Tag{Category: "cpu", Value: id})
+			}
+
+			if mn == "cpu_used" {
+				tagList = append(tagList, tagUnitsPercent)
+			} else {
+				tagList = append(tagList, tagUnitsCentiseconds)
+			}
+
+			_ = c.addMetric(&metrics, "", mn, mv.Type, mv.Value, tagList)
+		}
+	}
+
+	counters := []struct {
+		sysctl string
+		name   string
+		units  string
+	}{
+		{"vm.stats.sys.v_swtch", "ctxt", "switches"},
+		{"vm.stats.sys.v_intr", "interrupts", "interrupts"},
+		{"vm.stats.sys.v_soft", "soft_interrupts", "interrupts"},
+		{"vm.stats.sys.v_syscall", "syscalls", "calls"},
+	}
+	for _, ctr := range counters {
+		v, err := sysctlUint(ctr.sysctl)
+		if err != nil {
+			c.logger.Warn().Err(err).Msg("counter")
+			continue
+		}
+		_ = c.addMetric(&metrics, "", ctr.name, "L", v, tags.Tags{tags.Tag{Category: "units", Value: ctr.units}})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// cpuMetrics calculates metrics from the cpu state ticks
+func (c *CPU) cpuMetrics(id string, states []uint64, numCPU float64) cgm.Metrics {
+	metricType := "n" // resmon double
+
+	user := float64(states[cpUser])
+	nice := float64(states[cpNice])
+	sys := float64(states[cpSys])
+	intr := float64(states[cpIntr])
+	idle := float64(states[cpIdle])
+	busy := user + nice + sys + intr
+
+	metrics := cgm.Metrics{
+		"cpu_user":   cgm.Metric{Type: metricType, Value: (user / numCPU) * c.tickNorm},
+		"cpu_nice":   cgm.Metric{Type: metricType, Value: (nice / numCPU) * c.tickNorm},
+		"cpu_system": cgm.Metric{Type: metricType, Value: (sys / numCPU) * c.tickNorm},
+		"cpu_irq":    cgm.Metric{Type: metricType, Value: (intr / numCPU) * c.tickNorm},
+		"cpu_idle":   cgm.Metric{Type: metricType, Value: (idle / numCPU) * c.tickNorm},
+	}
+
+	all := busy + idle
+	used := float64(0)
+	if lrv, ok := c.lastRunValues[id]; ok {
+		if all > lrv.all {
+			used = ((busy - lrv.busy) / (all - lrv.all)) * 100
+		}
+	} else if all > 0 {
+		used = (busy / all) * 100
+	}
+	metrics["cpu_used"] = cgm.Metric{Type: metricType, Value: used}
+	c.lastRunValues[id] = lastValues{all: all, busy: busy}
+
+	return metrics
+}
+
+// statHz returns the statistics clock frequency (kern.clockrate stathz), the
+// rate at which the cpu state counters are incremented
+func statHz() (int32, error) {
+	// struct clockinfo { int hz; int tick; int spare; int stathz; int profhz; }
+	data, err := sysctlRaw("kern.clockrate")
+	if err != nil {
+		return 0, errors.Wrap(err, "kern.clockrate")
+	}
+	if len(data) < 16 {
+		return 0, errors.Errorf("kern.clockrate unexpected size (%d)", len(data))
+	}
+	stathz := int32(byteOrder.Uint32(data[12:16]))
+	if stathz <= 0 {
+		// stathz is zero when the statistics clock is driven by hz
+		stathz = int32(byteOrder.Uint32(data[0:4]))
+	}
+	if stathz <= 0 {
+		return 0, errors.Errorf("kern.clockrate invalid stathz (%d)", stathz)
+	}
+	return stathz, nil
+}
+
+// decodeLongs decodes an array of C longs (native size)
+func decodeLongs(data []byte) []uint64 {
+	size := strconv.IntSize / 8
+	vals := make([]uint64, 0, len(data)/size)
+	for i := 0; i+size <= len(data); i += size {
+		if size == 8 {
+			vals = append(vals, byteOrder.Uint64(data[i:i+size]))
+		} else {
+			vals = append(vals, uint64(byteOrder.Uint32(data[i:i+size])))
+		}
+	}
+	return vals
+}
@@ -0,0 +1,233 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build freebsd
+
+package freebsd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Disk metrics from sysctl kern.devstat.all
+type Disk struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// diskOptions defines what elements can be overridden in a config file
+type diskOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// struct devstat (sys/devicestat.h, DEVSTAT_VERSION 6) layout on 64 bit platforms
+const (
+	devstatVersion       = 6
+	devstatSize          = 288
+	devstatStartCount    = 8
+	devstatEndCount      = 12
+	devstatDeviceName    = 44
+	devstatNameLen       = 16
+	devstatUnitNumber    = 60
+	devstatBytes         = 64
+	devstatOperations    = 96
+	devstatDuration      = 128
+	devstatBusyTime      = 192
+	devstatDeviceType    = 260
+	devstatTypePass      = 0x100 // DEVSTAT_TYPE_PASS pass-through devices
+	devstatTransRead     = 1     // DEVSTAT_READ
+	devstatTransWrite    = 2     // DEVSTAT_WRITE
+	devstatTransFree     = 3     // DEVSTAT_FREE (trim/delete)
+	devstatGenerationLen = 8     // kern.devstat.all is prefixed with the generation (long)
+)
+
+type devStats struct {
+	name                    string
+	passThrough             bool
+	inProgress              uint64
+	reads, writes, frees    uint64
+	bytesRead, bytesWritten uint64
+	bytesFreed              uint64
+	readms, writems, freems uint64
+	ioms                    uint64
+}
+
+// NewDiskCollector creates new freebsd disk collector
+func NewDiskCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Disk{
+		common:  newCommon(NameDisk, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	if strconv.IntSize != 64 {
+		return nil, errors.Errorf("%s unsupported architecture (devstat layout)", c.pkgID)
+	}
+
+	if v, err := sysctlUint("kern.devstat.version"); err != nil {
+		return nil, errors.Wrap(err, c.pkgID)
+	} else if v != devstatVersion {
+		return nil, errors.Errorf("%s unsupported devstat version (%d)", c.pkgID, v)
+	}
+
+	var opts diskOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysctl
+func (c *Disk) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	data, err := sysctlRaw("kern.devstat.all")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrapf(err, "%s kern.devstat.all", c.pkgID)
+	}
+
+	stats, err := decodeDevstats(data)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	unitOperationsTag := tags.Tag{Category: "units", Value: "operations"}
+	unitBytesTag := tags.Tag{Category: "units", Value: "bytes"}
+	unitMillisecondsTag := tags.Tag{Category: "units", Value: "milliseconds"}
+
+	metricType := "L" // uint64
+	for _, ds := range stats {
+		if ds.passThrough {
+			continue
+		}
+
+		if c.exclude.MatchString(ds.name) || !c.include.MatchString(ds.name) {
+			c.logger.Debug().Str("device", ds.name).Msg("excluded device name, ignoring")
+			continue
+		}
+
+		diskTags := tags.Tags{
+			tags.Tag{Category: "device", Value: ds.name},
+		}
+
+		{
+			tagList := tags.Tags{unitOperationsTag}
+			tagList = append(tagList, diskTags...)
+			_ = c.addMetric(&metrics, "", "reads", metricType, ds.reads, tagList)
+			_ = c.addMetric(&metrics, "", "writes", metricType, ds.writes, tagList)
+			_ = c.addMetric(&metrics, "", "discards", metricType, ds.frees, tagList)
+			_ = c.addMetric(&metrics, "", "iops_in_progress", metricType, ds.inProgress, tagList)
+		}
+
+		{
+			tagList := tags.Tags{unitBytesTag}
+			tagList = append(tagList, diskTags...)
+			_ = c.addMetric(&metrics, "", "reads", metricType, ds.bytesRead, tagList)
+			_ = c.addMetric(&metrics, "", "writes", metricType, ds.bytesWritten, tagList)
+			_ = c.addMetric(&metrics, "", "discards", metricType, ds.bytesFreed, tagList)
+		}
+
+		{
+			tagList := tags.Tags{unitMillisecondsTag}
+			tagList = append(tagList, diskTags...)
+			_ = c.addMetric(&metrics, "", "read_time", metricType, ds.readms, tagList)
+			_ = c.addMetric(&metrics, "", "write_time", metricType, ds.writems, tagList)
+			_ = c.addMetric(&metrics, "", "discard_time", metricType, ds.freems, tagList)
+			_ = c.addMetric(&metrics, "", "io_time", metricType, ds.ioms, tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// decodeDevstats decodes the devstat structures returned by kern.devstat.all
+func decodeDevstats(data []byte) ([]devStats, error) {
+	if len(data) < devstatGenerationLen || (len(data)-devstatGenerationLen)%devstatSize != 0 {
+		return nil, errors.Errorf("kern.devstat.all unexpected size (%d)", len(data))
+	}
+
+	u32 := func(b []byte, off int) uint64 { return uint64(byteOrder.Uint32(b[off : off+4])) }
+	u64 := func(b []byte, off int) uint64 { return byteOrder.Uint64(b[off : off+8]) }
+	ms := func(b []byte, off int) uint64 { // struct bintime { time_t sec; uint64_t frac; }
+		sec := u64(b, off)
+		frac := u64(b, off+8)
+		return sec*1000 + ((frac>>32)*1000)>>32
+	}
+
+	data = data[devstatGenerationLen:]
+	stats := make([]devStats, 0, len(data)/devstatSize)
+	for off := 0; off < len(data); off += devstatSize {
+		b := data[off : off+devstatSize]
+
+		name := b[devstatDeviceName : devstatDeviceName+devstatNameLen]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+
+		ds := devStats{
+			name:         string(name) + strconv.FormatUint(u32(b, devstatUnitNumber), 10),
+			passThrough:  u32(b, devstatDeviceType)&devstatTypePass != 0,
+			reads:        u64(b, devstatOperations+devstatTransRead*8),
+			writes:       u64(b, devstatOperations+devstatTransWrite*8),
+			frees:        u64(b, devstatOperations+devstatTransFree*8),
+			bytesRead:    u64(b, devstatBytes+devstatTransRead*8),
+			bytesWritten: u64(b, devstatBytes+devstatTransWrite*8),
+			bytesFreed:   u64(b, devstatBytes+devstatTransFree*8),
+			readms:       ms(b, devstatDuration+devstatTransRead*16),
+			writems:      ms(b, devstatDuration+devstatTransWrite*16),
+			freems:       ms(b, devstatDuration+devstatTransFree*16),
+			ioms:         ms(b, devstatBusyTime),
+		}
+		if start, end := u32(b, devstatStartCount), u32(b, devstatEndCount); start > end {
+			ds.inProgress = start - end
+		}
+
+		stats = append(stats, ds)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,107 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build freebsd
+
+// Package freebsd builtin FreeBSD-specific collectors using sysctl
+package freebsd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix  = "freebsd/"
+	PackageName      = "builtins.freebsd"
+	NameCPU          = "cpu"
+	NameDisk         = "disk"
+	NameNetInterface = "if"
+	NameVM           = "vm"
+	regexPat         = `^(?:%s)$` // fmt pattern used compile include/exclude regular expressions
+)
+
+var (
+	defaultExcludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ""))
+	defaultIncludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ".+"))
+)
+
+// New creates new FreeBSD collectors
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "freebsd" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "freebsd_"+name+"_collector")
+		switch name {
+		case NameCPU:
+			c, err := NewCPUCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			// prime the cpu counters for cpu_used
+			_ = c.Collect(ctx)
+			_ = c.Flush()
+			collectors = append(collectors, c)
+
+		case NameDisk:
+			c, err := NewDiskCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameNetInterface:
+			c, err := NewNetIFCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameVM:
+			c, err := NewVMCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
@@ -0,0 +1,195 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build freebsd
+
+package freebsd
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/unix"
+)
+
+func TestDecodeLongs(t *testing.T) {
+	t.Log("Testing decodeLongs")
+
+	size := strconv.IntSize / 8
+	data := make([]byte, size*cpuStates)
+	for i := 0; i < cpuStates; i++ {
+		if size == 8 {
+			byteOrder.PutUint64(data[i*size:], uint64(i+1))
+		} else {
+			byteOrder.PutUint32(data[i*size:], uint32(i+1))
+		}
+	}
+
+	vals := decodeLongs(data)
+	if len(vals) != cpuStates {
+		t.Fatalf("expected %d values, got %d", cpuStates, len(vals))
+	}
+	for i, v := range vals {
+		if v != uint64(i+1) {
+			t.Fatalf("expected %d, got %d", i+1, v)
+		}
+	}
+}
+
+func TestStatHz(t *testing.T) {
+	t.Log("Testing statHz")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer func() { sysctlRaw = unix.SysctlRaw }()
+
+	clockinfo := func(hz, stathz uint32) []byte {
+		b := make([]byte, 20)
+		byteOrder.PutUint32(b[0:], hz)
+		byteOrder.PutUint32(b[12:], stathz)
+		return b
+	}
+
+	tt := []struct {
+		name      string
+		data      []byte
+		expect    int32
+		shouldErr bool
+	}{
+		{"stathz", clockinfo(1000, 127), 127, false},
+		{"hz", clockinfo(100, 0), 100, false},
+		{"short", []byte{1, 2, 3}, 0, true},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s", tst.name)
+		data := tst.data
+		sysctlRaw = func(name string, args ...int) ([]byte, error) { return data, nil }
+		v, err := statHz()
+		if tst.shouldErr {
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if v != tst.expect {
+			t.Fatalf("expected %d, got %d", tst.expect, v)
+		}
+	}
+
+	t.Log("\ttest -- sysctl error")
+	sysctlRaw = func(name string, args ...int) ([]byte, error) { return nil, errors.New("no") }
+	if _, err := statHz(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecodeXswdev(t *testing.T) {
+	t.Log("Testing decodeXswdev")
+
+	for _, tst := range []struct{ size, offset int }{{20, 12}, {24, 16}, {32, 20}} {
+		t.Logf("\ttest -- size %d", tst.size)
+		b := make([]byte, tst.size)
+		byteOrder.PutUint32(b[tst.offset:], 1000)
+		byteOrder.PutUint32(b[tst.offset+4:], 250)
+		nblks, used, err := decodeXswdev(b)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if nblks != 1000 || used != 250 {
+			t.Fatalf("expected 1000/250, got %d/%d", nblks, used)
+		}
+	}
+
+	t.Log("\ttest -- invalid size")
+	if _, _, err := decodeXswdev(make([]byte, 28)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecodeDevstats(t *testing.T) {
+	t.Log("Testing decodeDevstats")
+
+	devstat := func(name string, unit uint32, devType uint32) []byte {
+		b := make([]byte, devstatSize)
+		byteOrder.PutUint32(b[devstatStartCount:], 12)
+		byteOrder.PutUint32(b[devstatEndCount:], 10)
+		copy(b[devstatDeviceName:], name)
+		byteOrder.PutUint32(b[devstatUnitNumber:], unit)
+		byteOrder.PutUint64(b[devstatOperations+devstatTransRead*8:], 5)
+		byteOrder.PutUint64(b[devstatOperations+devstatTransWrite*8:], 7)
+		byteOrder.PutUint64(b[devstatBytes+devstatTransRead*8:], 4096)
+		byteOrder.PutUint64(b[devstatBytes+devstatTransWrite*8:], 8192)
+		byteOrder.PutUint64(b[devstatDuration+devstatTransRead*16:], 2)       // sec
+		byteOrder.PutUint64(b[devstatDuration+devstatTransRead*16+8:], 1<<63) // frac .5
+		byteOrder.PutUint64(b[devstatBusyTime:], 3)                           // sec
+		byteOrder.PutUint32(b[devstatDeviceType:], devType)
+		return b
+	}
+
+	data := make([]byte, devstatGenerationLen)
+	data = append(data, devstat("ada", 0, 0)...)
+	data = append(data, devstat("pass", 1, devstatTypePass)...)
+
+	stats, err := decodeDevstats(data)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(stats))
+	}
+
+	ds := stats[0]
+	if ds.name != "ada0" {
+		t.Fatalf("expected ada0, got %s", ds.name)
+	}
+	if ds.passThrough {
+		t.Fatal("expected not pass through")
+	}
+	if ds.reads != 5 || ds.writes != 7 || ds.bytesRead != 4096 || ds.bytesWritten != 8192 {
+		t.Fatalf("unexpected counters %#v", ds)
+	}
+	if ds.readms != 2500 || ds.ioms != 3000 {
+		t.Fatalf("unexpected times %#v", ds)
+	}
+	if ds.inProgress != 2 {
+		t.Fatalf("expected 2 in progress, got %d", ds.inProgress)
+	}
+
+	if !stats[1].passThrough || stats[1].name != "pass1" {
+		t.Fatalf("unexpected pass through device %#v", stats[1])
+	}
+
+	t.Log("\ttest -- invalid size")
+	if _, err := decodeDevstats(make([]byte, devstatGenerationLen+10)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestIfmibData(t *testing.T) {
+	t.Log("Testing ifmibData")
+
+	for _, size := range []int{204, 208} {
+		t.Logf("\ttest -- size %d", size)
+		b := make([]byte, size)
+		byteOrder.PutUint64(b[size-ifDataSize+ifDataIBytes:], 1234)
+		ifData, err := ifmibData(b)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if v := byteOrder.Uint64(ifData[ifDataIBytes:]); v != 1234 {
+			t.Fatalf("expected 1234, got %d", v)
+		}
+	}
+
+	t.Log("\ttest -- invalid size")
+	if _, err := ifmibData(make([]byte, 10)); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build freebsd
+
+package freebsd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// NetIF metrics from sysctl net.link.generic.ifdata
+type NetIF struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// netIFOptions defines what elements can be overridden in a config file
+type netIFOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// struct if_data (net/if.h, FreeBSD 11+) is the last member of struct ifmibdata,
+// all counters are uint64 so the layout is the same on all platforms
+const (
+	ifDataSize       = 152
+	ifDataIPackets   = 24
+	ifDataIErrors    = 32
+	ifDataOPackets   = 40
+	ifDataOErrors    = 48
+	ifDataCollisions = 56
+	ifDataIBytes     = 64
+	ifDataOBytes     = 72
+	ifDataIMcasts    = 80
+	ifDataOMcasts    = 88
+	ifDataIQDrops    = 96
+	ifDataOQDrops    = 104
+	ifDataNoProto    = 112
+	ifmibIfdata      = 1 // IFDATA_GENERAL
+)
+
+// NewNetIFCollector creates new freebsd if collector
+func NewNetIFCollector(cfgBaseName string) (collector.Collector, error) {
+	c := NetIF{
+		common:  newCommon(NameNetInterface, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: regexp.MustCompile(fmt.Sprintf(regexPat, `lo[0-9]*`)),
+	}
+
+	var opts netIFOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysctl
+func (c *NetIF) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	unitBytesTag := tags.Tag{Category: "units", Value: "bytes"}
+	unitPacketsTag := tags.Tag{Category: "units", Value: "packets"}
+	dirInTag := tags.Tag{Category: "direction", Value: "in"}
+	dirOutTag := tags.Tag{Category: "direction", Value: "out"}
+
+	stats := []struct {
+		offset int
+		name   string
+		stags  tags.Tags
+	}{
+		{offset: ifDataIBytes, name: "recv", stags: tags.Tags{unitBytesTag}},
+		{offset: ifDataIPackets, name: "recv", stags: tags.Tags{unitPacketsTag}},
+		{offset: ifDataIErrors, name: "errors", stags: tags.Tags{dirInTag}},
+		{offset: ifDataIQDrops, name: "drops", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{offset: ifDataIMcasts, name: "multicast", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{offset: ifDataNoProto, name: "noproto", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{offset: ifDataOBytes, name: "sent", stags: tags.Tags{unitBytesTag}},
+		{offset: ifDataOPackets, name: "sent", stags: tags.Tags{unitPacketsTag}},
+		{offset: ifDataOErrors, name: "errors", stags: tags.Tags{dirOutTag}},
+		{offset: ifDataOQDrops, name: "drops", stags: tags.Tags{dirOutTag, unitPacketsTag}},
+		{offset: ifDataOMcasts, name: "multicast", stags: tags.Tags{dirOutTag, unitPacketsTag}},
+		{offset: ifDataCollisions, name: "collision", stags: tags.Tags{dirOutTag}},
+	}
+
+	metricType := "L" // uint64
+	for _, iface := range ifaces {
+		if c.exclude.MatchString(iface.Name) || !c.include.MatchString(iface.Name) {
+			c.logger.Debug().Str("iface", iface.Name).Msg("excluded iface name, skipping")
+			continue
+		}
+
+		data, err := sysctlRaw("net.link.generic.ifdata", iface.Index, ifmibIfdata)
+		if err != nil {
+			c.logger.Warn().Err(err).Str("iface", iface.Name).Msg("net.link.generic.ifdata")
+			continue
+		}
+
+		ifData, err := ifmibData(data)
+		if err != nil {
+			c.logger.Warn().Err(err).Str("iface", iface.Name).Msg("decoding ifmibdata")
+			continue
+		}
+
+		for _, s := range stats {
+			tagList := tags.Tags{tags.Tag{Category: "network-interface", Value: iface.Name}}
+			tagList = append(tagList, s.stags...)
+			_ = c.addMetric(&metrics, "", s.name, metricType, byteOrder.Uint64(ifData[s.offset:s.offset+8]), tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// ifmibData returns the struct if_data portion of struct ifmibdata, the
+// offset of if_data within ifmibdata depends on platform alignment
+func ifmibData(data []byte) ([]byte, error) {
+	if len(data) < ifDataSize {
+		return nil, errors.Errorf("unexpected size (%d)", len(data))
+	}
+	return data[len(data)-ifDataSize:], nil
+}
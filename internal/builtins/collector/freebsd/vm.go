@@ -0,0 +1,209 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build freebsd
+
+package freebsd
+
+import (
+	"context"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// VM metrics from sysctl vm.stats.vm and vm.swap_info
+type VM struct {
+	common
+}
+
+// vmOptions defines what elements can be overridden in a config file
+type vmOptions struct {
+	commonOptions
+}
+
+// NewVMCollector creates new freebsd vm collector
+func NewVMCollector(cfgBaseName string) (collector.Collector, error) {
+	c := VM{
+		common: newCommon(NameVM, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts vmOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if _, err := sysctlUint("vm.stats.vm.v_page_size"); err != nil {
+		return nil, errors.Wrap(err, c.pkgID)
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysctl
+func (c *VM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	pageSize, err := sysctlUint("vm.stats.vm.v_page_size")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	if err := c.memCollect(&metrics, pageSize); err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	if err := c.swapCollect(&metrics, pageSize); err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+func (c *VM) memCollect(metrics *cgm.Metrics, pageSize uint64) error {
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+	tagUnitsFaults := tags.Tag{Category: "units", Value: "faults"}
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+
+	pages := func(name string) (uint64, bool) {
+		v, err := sysctlUint("vm.stats.vm." + name)
+		if err != nil {
+			return 0, false // not all counters exist on all releases (e.g. v_laundry_count 12+, v_cache_count <12)
+		}
+		return v, true
+	}
+
+	total, ok := pages("v_page_count")
+	if !ok || total == 0 {
+		return errors.New("unable to retrieve vm.stats.vm.v_page_count")
+	}
+
+	var available uint64
+	memStats := []struct {
+		sysctl    string
+		name      string
+		available bool
+	}{
+		{"v_free_count", "free", true},
+		{"v_active_count", "active", false},
+		{"v_inactive_count", "inactive", true},
+		{"v_laundry_count", "laundry", true},
+		{"v_cache_count", "cached", true},
+		{"v_wire_count", "wired", false},
+	}
+	for _, s := range memStats {
+		v, ok := pages(s.sysctl)
+		if !ok {
+			continue
+		}
+		if s.available {
+			available += v
+		}
+		_ = c.addMetric(metrics, "", s.name, "L", v*pageSize, tags.Tags{tagUnitsBytes})
+	}
+
+	used := total - available
+	if available > total {
+		used = 0
+	}
+
+	_ = c.addMetric(metrics, "", "memory_total", "L", total*pageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "memory_free", "L", available*pageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "memory_used", "L", used*pageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "memory_used", "n", (float64(used)/float64(total))*100, tags.Tags{tagUnitsPercent})
+	_ = c.addMetric(metrics, "", "memory_free", "n", (float64(available)/float64(total))*100, tags.Tags{tagUnitsPercent})
+
+	if v, ok := pages("v_vm_faults"); ok {
+		_ = c.addMetric(metrics, "", "pg_fault", "L", v, tags.Tags{tagUnitsFaults})
+	}
+	if v, ok := pages("v_io_faults"); ok {
+		_ = c.addMetric(metrics, "", "pg_fault_major", "L", v, tags.Tags{tagUnitsFaults})
+	}
+	if v, ok := pages("v_swappgsin"); ok {
+		_ = c.addMetric(metrics, "", "pg_swap_in", "L", v, tags.Tags{tagUnitsPages})
+	}
+	if v, ok := pages("v_swappgsout"); ok {
+		_ = c.addMetric(metrics, "", "pg_swap_out", "L", v, tags.Tags{tagUnitsPages})
+	}
+
+	return nil
+}
+
+func (c *VM) swapCollect(metrics *cgm.Metrics, pageSize uint64) error {
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	var total, used uint64
+	for i := 0; ; i++ {
+		data, err := sysctlRaw("vm.swap_info", i)
+		if err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				break // no more swap devices
+			}
+			return errors.Wrap(err, "vm.swap_info")
+		}
+		nblks, nused, err := decodeXswdev(data)
+		if err != nil {
+			return err
+		}
+		total += nblks
+		used += nused
+	}
+
+	usedPct := float64(0)
+	if total > 0 {
+		usedPct = (float64(used) / float64(total)) * 100
+	}
+
+	_ = c.addMetric(metrics, "", "swap_total", "L", total*pageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "swap_used", "L", used*pageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "swap_used", "n", usedPct, tags.Tags{tagUnitsPercent})
+	_ = c.addMetric(metrics, "", "swap_free", "L", (total-used)*pageSize, tags.Tags{tagUnitsBytes})
+	_ = c.addMetric(metrics, "", "swap_free", "n", 100-usedPct, tags.Tags{tagUnitsPercent})
+
+	return nil
+}
+
+// decodeXswdev returns the size and used pages of a swap device
+//
+// struct xswdev { u_int xsw_version; dev_t xsw_dev; int xsw_flags; int xsw_nblks; int xsw_used; }
+//
+// dev_t is 32 bit before FreeBSD 12 and 64 bit after, the size of the structure
+// (including alignment padding) identifies the layout.
+func decodeXswdev(data []byte) (uint64, uint64, error) {
+	var nblksOffset int
+	switch len(data) {
+	case 20: // 32 bit dev_t
+		nblksOffset = 12
+	case 24: // 64 bit dev_t, 4 byte alignment (i386)
+		nblksOffset = 16
+	case 32: // 64 bit dev_t, 8 byte alignment
+		nblksOffset = 20
+	default:
+		return 0, 0, errors.Errorf("vm.swap_info unexpected size (%d)", len(data))
+	}
+	nblks := int32(byteOrder.Uint32(data[nblksOffset : nblksOffset+4]))
+	nused := int32(byteOrder.Uint32(data[nblksOffset+4 : nblksOffset+8]))
+	if nblks < 0 || nused < 0 {
+		return 0, 0, errors.Errorf("vm.swap_info invalid values (%d/%d)", nblks, nused)
+	}
+	return uint64(nblks), uint64(nused), nil
+}
@@ -103,15 +103,15 @@ func (c *gencommon) addMetric(metrics *cgm.Metrics, mname, mtype string, mval in
 		return errors.New("invalid metric, no type")
 	}
 
-	var tagList tags.Tags
-	tagList = append(tagList, c.baseTags...)
-	tagList = append(tagList, tags.Tags{
+	tagList := tags.GetTags()
+	defer tags.PutTags(tagList)
+	*tagList = append(*tagList, c.baseTags...)
+	*tagList = append(*tagList,
 		tags.Tag{Category: "source", Value: release.NAME},
-		tags.Tag{Category: "collector", Value: c.id},
-	}...)
-	tagList = append(tagList, mtags...)
+		tags.Tag{Category: "collector", Value: c.id})
+	*tagList = append(*tagList, mtags...)
 
-	metricName := tags.MetricNameWithStreamTags(mname, tagList)
+	metricName := tags.MetricNameWithStreamTags(mname, *tagList)
 
 	(*metrics)[metricName] = cgm.Metric{Type: mtype, Value: mval}
 
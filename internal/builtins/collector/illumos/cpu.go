@@ -0,0 +1,194 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package illumos
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// CPU metrics from kstat cpu::sys
+type CPU struct {
+	common
+	reportAllCPUs bool                  // OPT report all cpus (vs just total) may be overridden in config file
+	lastRunValues map[string]lastValues // values from last run
+}
+
+// cpuOptions defines what elements can be overridden in a config file
+type cpuOptions struct {
+	commonOptions
+
+	// collector specific
+	AllCPU string `json:"report_all_cpus" toml:"report_all_cpus" yaml:"report_all_cpus"`
+}
+
+type lastValues struct {
+	all  float64
+	busy float64
+}
+
+// cpuTimes nanoseconds spent in each cpu state
+type cpuTimes struct {
+	user, kernel, idle, intr float64
+}
+
+const nsecPerCentisecond = 1e7
+
+// NewCPUCollector creates new illumos cpu collector
+func NewCPUCollector(cfgBaseName string) (collector.Collector, error) {
+	c := CPU{
+		common:        newCommon(NameCPU, tags.FromList(tags.GetBaseTags())),
+		lastRunValues: make(map[string]lastValues),
+	}
+
+	var opts cpuOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.AllCPU != "" {
+		rpt, err := strconv.ParseBool(opts.AllCPU)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing report_all_cpus", c.pkgID)
+		}
+		c.reportAllCPUs = rpt
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from kstat
+func (c *CPU) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	groups, err := readKstats(ctx, "cpu::sys:")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	if len(groups) == 0 {
+		err := errors.New("no cpu kstats found")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsCentiseconds := tags.Tag{Category: "units", Value: "centiseconds"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	numCPU := len(groups)
+	_ = c.addMetric(&metrics, "", "num_cpu", "I", numCPU, tags.Tags{})
+
+	var total cpuTimes
+	var ctxt, interrupts, syscalls uint64
+	perCPU := make(map[string]cpuTimes, numCPU)
+	for _, g := range groups {
+		var t cpuTimes
+		if v, ok := g.uint("cpu_nsec_user"); ok {
+			t.user = float64(v)
+		}
+		if v, ok := g.uint("cpu_nsec_kernel"); ok {
+			t.kernel = float64(v)
+		}
+		if v, ok := g.uint("cpu_nsec_idle"); ok {
+			t.idle = float64(v)
+		}
+		if v, ok := g.uint("cpu_nsec_intr"); ok {
+			t.intr = float64(v)
+		}
+		total.user += t.user
+		total.kernel += t.kernel
+		total.idle += t.idle
+		total.intr += t.intr
+		if c.reportAllCPUs {
+			perCPU[g.instance] = t
+		}
+
+		if v, ok := g.uint("pswitch"); ok {
+			ctxt += v
+		}
+		if v, ok := g.uint("intr"); ok {
+			interrupts += v
+		}
+		if v, ok := g.uint("syscall"); ok {
+			syscalls += v
+		}
+	}
+
+	perCPU[""] = total
+
+	for id, t := range perCPU {
+		n := float64(numCPU) // aggregate cpu metrics
+		if id != "" {
+			n = 1 // individual cpu metrics
+		}
+
+		for mn, mv := range c.cpuMetrics(id, t, n) {
+			var tagList tags.Tags
+
+			if id != "" {
+				tagList = append(tagList, tags.Tag{Category: "cpu", Value: id})
+			}
+
+			if mn == "cpu_used" {
+				tagList = append(tagList, tagUnitsPercent)
+			} else {
+				tagList = append(tagList, tagUnitsCentiseconds)
+			}
+
+			_ = c.addMetric(&metrics, "", mn, mv.Type, mv.Value, tagList)
+		}
+	}
+
+	_ = c.addMetric(&metrics, "", "ctxt", "L", ctxt, tags.Tags{tags.Tag{Category: "units", Value: "switches"}})
+	_ = c.addMetric(&metrics, "", "interrupts", "L", interrupts, tags.Tags{tags.Tag{Category: "units", Value: "interrupts"}})
+	_ = c.addMetric(&metrics, "", "syscalls", "L", syscalls, tags.Tags{tags.Tag{Category: "units", Value: "calls"}})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// cpuMetrics calculates metrics from the cpu state times, interrupt time
+// is reported separately and is not included in cpu_used
+func (c *CPU) cpuMetrics(id string, t cpuTimes, numCPU float64) cgm.Metrics {
+	metricType := "n" // resmon double
+
+	norm := numCPU * nsecPerCentisecond
+
+	metrics := cgm.Metrics{
+		"cpu_user":   cgm.Metric{Type: metricType, Value: t.user / norm},
+		"cpu_system": cgm.Metric{Type: metricType, Value: t.kernel / norm},
+		"cpu_idle":   cgm.Metric{Type: metricType, Value: t.idle / norm},
+		"cpu_irq":    cgm.Metric{Type: metricType, Value: t.intr / norm},
+	}
+
+	busy := t.user + t.kernel
+	all := busy + t.idle
+	used := float64(0)
+	if lrv, ok := c.lastRunValues[id]; ok {
+		if all > lrv.all {
+			used = ((busy - lrv.busy) / (all - lrv.all)) * 100
+		}
+	} else if all > 0 {
+		used = (busy / all) * 100
+	}
+	metrics["cpu_used"] = cgm.Metric{Type: metricType, Value: used}
+	c.lastRunValues[id] = lastValues{all: all, busy: busy}
+
+	return metrics
+}
@@ -0,0 +1,261 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package illumos
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// stubKstat replaces runKstat with one returning the named testdata file
+func stubKstat(t *testing.T, file string) func() {
+	orig := runKstat
+	runKstat = func(ctx context.Context, specs ...string) ([]byte, error) {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("reading testdata (%s)", err)
+		}
+		return data, nil
+	}
+	return func() { runKstat = orig }
+}
+
+func TestParseKstats(t *testing.T) {
+	t.Log("Testing parseKstats")
+
+	data := []byte("cpu:0:sys:cpu_nsec_idle\t100\ncpu:0:sys:pswitch\t5\n" +
+		"unix:0:a:b:c:stat\t1\n" +
+		"sd:0:sd0,err:Product\tVBOX HARDDISK\n" +
+		"continued value without a tab\n" +
+		"cpu:1:sys:cpu_nsec_idle\t200\n")
+
+	groups, err := parseKstats(data)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if len(groups) != 4 {
+		t.Fatalf("expected 4 groups, got %d", len(groups))
+	}
+
+	g := groups[0]
+	if g.module != "cpu" || g.instance != "0" || g.name != "sys" {
+		t.Fatalf("unexpected group %s:%s:%s", g.module, g.instance, g.name)
+	}
+	if v, ok := g.uint("pswitch"); !ok || v != 5 {
+		t.Fatalf("expected pswitch 5, got %d (%v)", v, ok)
+	}
+	if _, ok := g.uint("missing"); ok {
+		t.Fatal("expected missing stat to not be found")
+	}
+
+	if groups[1].name != "a:b:c" {
+		t.Fatalf("expected name 'a:b:c', got '%s'", groups[1].name)
+	}
+	if _, ok := groups[2].uint("Product"); ok {
+		t.Fatal("expected non-numeric stat to not parse")
+	}
+	if groups[3].instance != "1" {
+		t.Fatalf("expected instance 1, got %s", groups[3].instance)
+	}
+
+	t.Log("\tinvalid line")
+	{
+		_, err := parseKstats([]byte("cpu:0\t1\n"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestCPUCollect(t *testing.T) {
+	t.Log("Testing CPU Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubKstat(t, "cpu.txt")()
+
+	c, err := NewCPUCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "num_cpu", "collector:cpu"); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected num_cpu 2, got %v", m.Value)
+	}
+	// (5e8 + 2e9) ns user over 2 cpus in centiseconds
+	if m, ok := testutil.FindMetric(metrics, "cpu_user", "units:centiseconds"); !ok || m.Value.(float64) != 125 {
+		t.Fatalf("expected cpu_user 125, got %v", m.Value)
+	}
+	// busy 4e9 of all 2e10
+	if m, ok := testutil.FindMetric(metrics, "cpu_used", "units:percent"); !ok || m.Value.(float64) != 20 {
+		t.Fatalf("expected cpu_used 20, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "ctxt"); !ok || m.Value.(uint64) != 4000 {
+		t.Fatalf("expected ctxt 4000, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "cpu_user", "cpu:0"); ok {
+		t.Fatal("expected no per cpu metrics")
+	}
+}
+
+func TestVMCollect(t *testing.T) {
+	t.Log("Testing VM Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubKstat(t, "vm.txt")()
+
+	c, err := NewVMCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	vmc := c.(*VM)
+	vmc.pageSize = 4096
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "memory_total", "units:bytes"); !ok || m.Value.(uint64) != 1000000*4096 {
+		t.Fatalf("expected memory_total %d, got %v", 1000000*4096, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "memory_used", "units:percent"); !ok || m.Value.(float64) != 75 {
+		t.Fatalf("expected memory_used 75%%, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "pg_fault"); !ok || m.Value.(uint64) != 200 {
+		t.Fatalf("expected pg_fault 200, got %v", m.Value)
+	}
+
+	t.Log("\tno system_pages")
+	{
+		runKstat = func(ctx context.Context, specs ...string) ([]byte, error) { return []byte{}, nil }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tkstat error")
+	{
+		runKstat = func(ctx context.Context, specs ...string) ([]byte, error) { return nil, errors.New("boom") }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestZFSCollect(t *testing.T) {
+	t.Log("Testing ZFS Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubKstat(t, "zfs.txt")()
+
+	c, err := NewZFSCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "arc_size", "units:bytes"); !ok || m.Value.(uint64) != 4000000000 {
+		t.Fatalf("expected arc_size 4000000000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "arc_hit_ratio", "units:percent"); !ok || m.Value.(float64) != 90 {
+		t.Fatalf("expected arc_hit_ratio 90, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "arc_p"); ok {
+		t.Fatal("expected no arc_p metric")
+	}
+}
+
+func TestNetIFCollect(t *testing.T) {
+	t.Log("Testing NetIF Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubKstat(t, "if.txt")()
+
+	c, err := NewNetIFCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	nc := c.(*NetIF)
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "recv", "network-interface:net0", "units:bytes"); !ok || m.Value.(uint64) != 300000 {
+		t.Fatalf("expected net0 recv bytes 300000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "errors", "network-interface:net0", "direction:out"); !ok || m.Value.(uint64) != 2 {
+		t.Fatalf("expected net0 out errors 2, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "recv", "network-interface:net1"); !ok {
+		t.Fatal("expected net1 metrics")
+	}
+
+	t.Log("\texclude net1")
+	{
+		nc.exclude = regexp.MustCompile(fmt.Sprintf(regexPat, "net1"))
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if _, ok := testutil.FindMetric(metrics, "recv", "network-interface:net1"); ok {
+			t.Fatal("expected net1 to be excluded")
+		}
+	}
+}
+
+func TestZonesCollect(t *testing.T) {
+	t.Log("Testing Zones Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubKstat(t, "zones.txt")()
+
+	c, err := NewZonesCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	zone := "zone:7b5981c4-1889-4c0b-a8b0-f2a9f2a6e5b1"
+
+	if m, ok := testutil.FindMetric(metrics, "load_5min", "zone:global"); !ok || m.Value.(float64) != 1 {
+		t.Fatalf("expected global load_5min 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "cpu_user", zone); !ok || m.Value.(uint64) != 200 {
+		t.Fatalf("expected zone cpu_user 200, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "memory_cap", zone); !ok || m.Value.(uint64) != 2147483648 {
+		t.Fatalf("expected zone memory_cap 2147483648, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "memory_cap_exceeded", zone); !ok || m.Value.(uint64) != 2 {
+		t.Fatalf("expected zone memory_cap_exceeded 2, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "memory_cap", "zone:global"); ok {
+		t.Fatal("expected no memory_cap for uncapped global zone")
+	}
+	if _, ok := testutil.FindMetric(metrics, "swap_cap", "zone:global"); ok {
+		t.Fatal("expected no swap_cap for uncapped global zone")
+	}
+}
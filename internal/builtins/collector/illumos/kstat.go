@@ -0,0 +1,103 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package illumos
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// kstatPath kstat(1M) command, kstats are read with the command rather than
+// libkstat so the agent does not require cgo
+var kstatPath = "/usr/bin/kstat"
+
+// runKstat runs kstat in parseable mode for the module:instance:name:statistic
+// specs (empty fields match all), overridden in tests
+var runKstat = func(ctx context.Context, specs ...string) ([]byte, error) {
+	args := append([]string{"-p"}, specs...)
+	out, err := exec.CommandContext(ctx, kstatPath, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s %s", kstatPath, strings.Join(args, " "))
+	}
+	return out, nil
+}
+
+// kstatGroup is the set of statistics of one kstat (module:instance:name)
+type kstatGroup struct {
+	module   string
+	instance string
+	name     string
+	stats    map[string]string
+}
+
+// readKstats runs kstat and returns the kstats in output order
+func readKstats(ctx context.Context, specs ...string) ([]*kstatGroup, error) {
+	out, err := runKstat(ctx, specs...)
+	if err != nil {
+		return nil, err
+	}
+	return parseKstats(out)
+}
+
+// parseKstats parses `kstat -p` output, lines are module:instance:name:statistic<TAB>value
+func parseKstats(data []byte) ([]*kstatGroup, error) {
+	var groups []*kstatGroup
+	var cur *kstatGroup
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			continue
+		}
+		tab := strings.IndexByte(line, '\t')
+		if tab < 0 {
+			continue // continuation of a multi-line string value
+		}
+		key, value := line[:tab], line[tab+1:]
+
+		// module and instance never contain ':', the name can
+		parts := strings.SplitN(key, ":", 3)
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid kstat line (%s)", line)
+		}
+		sep := strings.LastIndexByte(parts[2], ':')
+		if sep < 0 {
+			return nil, errors.Errorf("invalid kstat line (%s)", line)
+		}
+		module, instance, name, stat := parts[0], parts[1], parts[2][:sep], parts[2][sep+1:]
+
+		if cur == nil || cur.module != module || cur.instance != instance || cur.name != name {
+			cur = &kstatGroup{module: module, instance: instance, name: name, stats: make(map[string]string)}
+			groups = append(groups, cur)
+		}
+		cur.stats[stat] = value
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "scanning kstat output")
+	}
+
+	return groups, nil
+}
+
+// uint returns the value of an unsigned integer statistic
+func (g *kstatGroup) uint(stat string) (uint64, bool) {
+	v, ok := g.stats[stat]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
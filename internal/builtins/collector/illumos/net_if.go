@@ -0,0 +1,130 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package illumos
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// NetIF metrics from kstat link::: (datalinks visible in the zone)
+type NetIF struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// netIFOptions defines what elements can be overridden in a config file
+type netIFOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// NewNetIFCollector creates new illumos if collector
+func NewNetIFCollector(cfgBaseName string) (collector.Collector, error) {
+	c := NetIF{
+		common:  newCommon(NameNetInterface, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	var opts netIFOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from kstat
+func (c *NetIF) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	groups, err := readKstats(ctx, "link:::")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	unitBytesTag := tags.Tag{Category: "units", Value: "bytes"}
+	unitPacketsTag := tags.Tag{Category: "units", Value: "packets"}
+	dirInTag := tags.Tag{Category: "direction", Value: "in"}
+	dirOutTag := tags.Tag{Category: "direction", Value: "out"}
+
+	stats := []struct {
+		stat  string
+		name  string
+		stags tags.Tags
+	}{
+		{stat: "rbytes64", name: "recv", stags: tags.Tags{unitBytesTag}},
+		{stat: "ipackets64", name: "recv", stags: tags.Tags{unitPacketsTag}},
+		{stat: "ierrors", name: "errors", stags: tags.Tags{dirInTag}},
+		{stat: "norcvbuf", name: "drops", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{stat: "multircv", name: "multicast", stags: tags.Tags{dirInTag, unitPacketsTag}},
+		{stat: "obytes64", name: "sent", stags: tags.Tags{unitBytesTag}},
+		{stat: "opackets64", name: "sent", stags: tags.Tags{unitPacketsTag}},
+		{stat: "oerrors", name: "errors", stags: tags.Tags{dirOutTag}},
+		{stat: "noxmtbuf", name: "drops", stags: tags.Tags{dirOutTag, unitPacketsTag}},
+		{stat: "multixmt", name: "multicast", stags: tags.Tags{dirOutTag, unitPacketsTag}},
+		{stat: "collisions", name: "collision", stags: tags.Tags{dirOutTag}},
+	}
+
+	metricType := "L" // uint64
+	for _, g := range groups {
+		iface := g.name
+
+		if c.exclude.MatchString(iface) || !c.include.MatchString(iface) {
+			c.logger.Debug().Str("iface", iface).Msg("excluded iface name, skipping")
+			continue
+		}
+
+		for _, s := range stats {
+			v, ok := g.uint(s.stat)
+			if !ok {
+				continue
+			}
+			tagList := tags.Tags{tags.Tag{Category: "network-interface", Value: iface}}
+			tagList = append(tagList, s.stags...)
+			_ = c.addMetric(&metrics, "", s.name, metricType, v, tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
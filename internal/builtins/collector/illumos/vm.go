@@ -0,0 +1,119 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package illumos
+
+import (
+	"context"
+	"os"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// VM metrics from kstat unix:0:system_pages and cpu::vm
+type VM struct {
+	common
+	pageSize uint64
+}
+
+// vmOptions defines what elements can be overridden in a config file
+type vmOptions struct {
+	commonOptions
+}
+
+// NewVMCollector creates new illumos vm collector
+func NewVMCollector(cfgBaseName string) (collector.Collector, error) {
+	c := VM{
+		common:   newCommon(NameVM, tags.FromList(tags.GetBaseTags())),
+		pageSize: uint64(os.Getpagesize()),
+	}
+
+	var opts vmOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from kstat
+func (c *VM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	groups, err := readKstats(ctx, "unix:0:system_pages:", "cpu::vm:")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+	tagUnitsFaults := tags.Tag{Category: "units", Value: "faults"}
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+
+	var haveMem bool
+	vmCounters := map[string]uint64{}
+	for _, g := range groups {
+		switch {
+		case g.module == "unix" && g.name == "system_pages":
+			total, ok := g.uint("physmem")
+			if !ok || total == 0 {
+				continue
+			}
+			free, _ := g.uint("freemem")
+			used := uint64(0)
+			if total > free {
+				used = total - free
+			}
+			haveMem = true
+
+			_ = c.addMetric(&metrics, "", "memory_total", "L", total*c.pageSize, tags.Tags{tagUnitsBytes})
+			_ = c.addMetric(&metrics, "", "memory_free", "L", free*c.pageSize, tags.Tags{tagUnitsBytes})
+			_ = c.addMetric(&metrics, "", "memory_free", "n", (float64(free)/float64(total))*100, tags.Tags{tagUnitsPercent})
+			_ = c.addMetric(&metrics, "", "memory_used", "L", used*c.pageSize, tags.Tags{tagUnitsBytes})
+			_ = c.addMetric(&metrics, "", "memory_used", "n", (float64(used)/float64(total))*100, tags.Tags{tagUnitsPercent})
+			if v, ok := g.uint("availrmem"); ok {
+				_ = c.addMetric(&metrics, "", "memory_available", "L", v*c.pageSize, tags.Tags{tagUnitsBytes})
+			}
+			if v, ok := g.uint("pp_kernel"); ok {
+				_ = c.addMetric(&metrics, "", "kernel", "L", v*c.pageSize, tags.Tags{tagUnitsBytes})
+			}
+
+		case g.module == "cpu" && g.name == "vm":
+			for _, stat := range []string{"as_fault", "maj_fault", "pgpgin", "pgpgout", "anonpgin", "anonpgout"} {
+				if v, ok := g.uint(stat); ok {
+					vmCounters[stat] += v
+				}
+			}
+		}
+	}
+
+	if !haveMem {
+		err := errors.New("no system_pages kstat found")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	_ = c.addMetric(&metrics, "", "pg_fault", "L", vmCounters["as_fault"], tags.Tags{tagUnitsFaults})
+	_ = c.addMetric(&metrics, "", "pg_fault_major", "L", vmCounters["maj_fault"], tags.Tags{tagUnitsFaults})
+	_ = c.addMetric(&metrics, "", "pg_page_in", "L", vmCounters["pgpgin"], tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_page_out", "L", vmCounters["pgpgout"], tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_swap_in", "L", vmCounters["anonpgin"], tags.Tags{tagUnitsPages})
+	_ = c.addMetric(&metrics, "", "pg_swap_out", "L", vmCounters["anonpgout"], tags.Tags{tagUnitsPages})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
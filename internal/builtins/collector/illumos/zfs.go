@@ -0,0 +1,126 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package illumos
+
+import (
+	"context"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// ZFS ARC metrics from kstat zfs:0:arcstats
+type ZFS struct {
+	common
+	lastHits   uint64
+	lastMisses uint64
+}
+
+// zfsOptions defines what elements can be overridden in a config file
+type zfsOptions struct {
+	commonOptions
+}
+
+// arcStats reported arcstats statistics and their units
+var arcStats = []struct {
+	stat  string
+	units string
+}{
+	{"size", "bytes"},
+	{"c", "bytes"},
+	{"c_min", "bytes"},
+	{"c_max", "bytes"},
+	{"p", "bytes"},
+	{"data_size", "bytes"},
+	{"metadata_size", "bytes"},
+	{"other_size", "bytes"},
+	{"hits", "operations"},
+	{"misses", "operations"},
+	{"demand_data_hits", "operations"},
+	{"demand_data_misses", "operations"},
+	{"demand_metadata_hits", "operations"},
+	{"demand_metadata_misses", "operations"},
+	{"prefetch_data_hits", "operations"},
+	{"prefetch_data_misses", "operations"},
+	{"prefetch_metadata_hits", "operations"},
+	{"prefetch_metadata_misses", "operations"},
+	{"mru_hits", "operations"},
+	{"mfu_hits", "operations"},
+	{"deleted", "operations"},
+	{"evict_skip", "operations"},
+	{"memory_throttle_count", "operations"},
+	{"l2_size", "bytes"},
+	{"l2_asize", "bytes"},
+	{"l2_hits", "operations"},
+	{"l2_misses", "operations"},
+	{"l2_read_bytes", "bytes"},
+	{"l2_write_bytes", "bytes"},
+}
+
+// NewZFSCollector creates new illumos zfs arc collector
+func NewZFSCollector(cfgBaseName string) (collector.Collector, error) {
+	c := ZFS{
+		common: newCommon(NameZFS, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts zfsOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from kstat
+func (c *ZFS) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	groups, err := readKstats(ctx, "zfs:0:arcstats:")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	if len(groups) == 0 {
+		err := errors.New("no arcstats kstat found")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	arc := groups[0]
+	for _, s := range arcStats {
+		if v, ok := arc.uint(s.stat); ok {
+			_ = c.addMetric(&metrics, "", "arc_"+s.stat, "L", v, tags.Tags{tags.Tag{Category: "units", Value: s.units}})
+		}
+	}
+
+	// hit ratio since the last collection (or boot, for the first collection)
+	hits, hok := arc.uint("hits")
+	misses, mok := arc.uint("misses")
+	if hok && mok {
+		dh, dm := hits, misses
+		if hits >= c.lastHits && misses >= c.lastMisses {
+			dh, dm = hits-c.lastHits, misses-c.lastMisses
+		}
+		if dh+dm > 0 {
+			ratio := (float64(dh) / float64(dh+dm)) * 100
+			_ = c.addMetric(&metrics, "", "arc_hit_ratio", "n", ratio, tags.Tags{tags.Tag{Category: "units", Value: "percent"}})
+		}
+		c.lastHits, c.lastMisses = hits, misses
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
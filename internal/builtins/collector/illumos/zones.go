@@ -0,0 +1,163 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package illumos
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Zones metrics from kstat zones::: and memory_cap::: (all zones when run
+// in the global zone, the agent's own zone when run in a non-global zone)
+type Zones struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// zonesOptions defines what elements can be overridden in a config file
+type zonesOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// fscale avenrun fixed point scale (FSCALE)
+const fscale = 256
+
+// NewZonesCollector creates new illumos zones collector
+func NewZonesCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Zones{
+		common:  newCommon(NameZones, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	var opts zonesOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from kstat
+func (c *Zones) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	groups, err := readKstats(ctx, "zones:::", "memory_cap:::")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsNanoseconds := tags.Tag{Category: "units", Value: "nanoseconds"}
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+	tagUnitsProcesses := tags.Tag{Category: "units", Value: "processes"}
+
+	for _, g := range groups {
+		// kstat names are truncated, use the zonename statistic when present
+		zone := g.name
+		if zn, ok := g.stats["zonename"]; ok && zn != "" {
+			zone = zn
+		}
+
+		if c.exclude.MatchString(zone) || !c.include.MatchString(zone) {
+			c.logger.Debug().Str("zone", zone).Msg("excluded zone name, skipping")
+			continue
+		}
+
+		zoneTag := tags.Tag{Category: "zone", Value: zone}
+
+		switch g.module {
+		case "zones":
+			cpuStats := []struct{ stat, name string }{
+				{"nsec_user", "cpu_user"},
+				{"nsec_sys", "cpu_system"},
+				{"nsec_waitrq", "cpu_wait_rq"},
+			}
+			for _, s := range cpuStats {
+				if v, ok := g.uint(s.stat); ok {
+					_ = c.addMetric(&metrics, "", s.name, "L", v, tags.Tags{zoneTag, tagUnitsNanoseconds})
+				}
+			}
+
+			loadStats := []struct{ stat, name string }{
+				{"avenrun_1min", "load_1min"},
+				{"avenrun_5min", "load_5min"},
+				{"avenrun_15min", "load_15min"},
+			}
+			for _, s := range loadStats {
+				if v, ok := g.uint(s.stat); ok {
+					_ = c.addMetric(&metrics, "", s.name, "n", float64(v)/fscale, tags.Tags{zoneTag, tagUnitsProcesses})
+				}
+			}
+
+		case "memory_cap":
+			byteStats := []struct{ stat, name string }{
+				{"rss", "memory_rss"},
+				{"physcap", "memory_cap"},
+				{"swap", "swap_used"},
+				{"swapcap", "swap_cap"},
+				{"pagedout", "memory_paged_out"},
+			}
+			for _, s := range byteStats {
+				v, ok := g.uint(s.stat)
+				if !ok {
+					continue
+				}
+				if (s.stat == "physcap" || s.stat == "swapcap") && (v == 0 || v == math.MaxUint64) {
+					continue // no cap
+				}
+				_ = c.addMetric(&metrics, "", s.name, "L", v, tags.Tags{zoneTag, tagUnitsBytes})
+			}
+
+			if v, ok := g.uint("nover"); ok {
+				_ = c.addMetric(&metrics, "", "memory_cap_exceeded", "L", v, tags.Tags{zoneTag})
+			}
+			if v, ok := g.uint("anonpgin"); ok {
+				_ = c.addMetric(&metrics, "", "pg_swap_in", "L", v, tags.Tags{zoneTag, tagUnitsPages})
+			}
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package bgp builtin linux collectors for BGP routing daemons (FRR and bird),
+// per peer session state, prefix counts and flaps read from the daemon's
+// control socket
+package bgp
+
+import (
+	"context"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "bgp/"
+	PackageName     = "builtins.linux.bgp"
+	NameBird        = "bird"
+	NameFRR         = "frr"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// peer is a BGP session of the routing daemon
+type peer struct {
+	name        string // peer address (frr) or protocol name (bird)
+	remoteAS    string
+	state       string
+	established bool
+	uptime      uint64 // seconds
+	hasUptime   bool
+	flaps       uint64
+	families    []family
+}
+
+// family prefix counts of a peer for an address family (frr) or channel (bird)
+type family struct {
+	name        string // empty for bird 1.x protocols, which have no channels
+	received    uint64
+	accepted    uint64
+	hasAccepted bool
+	sent        uint64
+	hasSent     bool
+}
+
+// peerStates BGP finite state machine states, values as in the BGP4-MIB
+// bgpPeerState (0 is used for unknown/administrative states)
+var peerStates = map[string]int{
+	"idle":        1,
+	"connect":     2,
+	"active":      3,
+	"opensent":    4,
+	"openconfirm": 5,
+	"established": 6,
+}
+
+// New creates new bgp collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "bgp_"+name+"_collector")
+		switch name {
+		case NameBird:
+			c, err := NewBirdCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameFRR:
+			c, err := NewFRRCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+// stateValue returns the numeric FSM state of a daemon state string,
+// e.g. "Established" or "Idle (Admin)"
+func stateValue(state string) int {
+	s := strings.ToLower(strings.TrimSpace(state))
+	if idx := strings.IndexAny(s, " ("); idx != -1 {
+		s = s[:idx]
+	}
+	return peerStates[s]
+}
+
+// addPeerMetrics adds the per peer session and prefix metrics and the
+// number of peers and established peers
+func (c *common) addPeerMetrics(metrics *cgm.Metrics, peers []peer) {
+	tagUnitsPrefixes := tags.Tag{Category: "units", Value: "prefixes"}
+
+	established := 0
+	for _, p := range peers {
+		peerTags := tags.Tags{tags.Tag{Category: "bgp-peer", Value: p.name}}
+		if p.remoteAS != "" {
+			peerTags = append(peerTags, tags.Tag{Category: "remote-as", Value: p.remoteAS})
+		}
+
+		up := 0
+		if p.established {
+			up = 1
+			established++
+		}
+		_ = c.addMetric(metrics, "", "peer_state", "I", stateValue(p.state), peerTags)
+		_ = c.addMetric(metrics, "", "peer_established", "I", up, peerTags)
+		_ = c.addMetric(metrics, "", "peer_flaps", "L", p.flaps, peerTags)
+		if p.hasUptime {
+			_ = c.addMetric(metrics, "", "peer_uptime", "L", p.uptime, append(tags.Tags{tags.Tag{Category: "units", Value: "seconds"}}, peerTags...))
+		}
+
+		for _, f := range p.families {
+			familyTags := tags.Tags{tagUnitsPrefixes}
+			familyTags = append(familyTags, peerTags...)
+			if f.name != "" {
+				familyTags = append(familyTags, tags.Tag{Category: "address-family", Value: f.name})
+			}
+			_ = c.addMetric(metrics, "", "prefixes_received", "L", f.received, familyTags)
+			if f.hasAccepted {
+				_ = c.addMetric(metrics, "", "prefixes_accepted", "L", f.accepted, familyTags)
+			}
+			if f.hasSent {
+				_ = c.addMetric(metrics, "", "prefixes_sent", "L", f.sent, familyTags)
+			}
+		}
+	}
+
+	_ = c.addMetric(metrics, "", "peers", "I", len(peers), tags.Tags{})
+	_ = c.addMetric(metrics, "", "peers_established", "I", established, tags.Tags{})
+}
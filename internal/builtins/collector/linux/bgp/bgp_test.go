@@ -0,0 +1,256 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package bgp
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+// serveSocket listens on a unix socket in a temp dir, each connection is
+// handled by handler, returns the socket path and a cleanup func
+func serveSocket(t *testing.T, handler func(net.Conn)) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bgp")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	socketPath := filepath.Join(dir, "test.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listening (%s)", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			handler(conn)
+			conn.Close()
+		}
+	}()
+	return socketPath, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// vtyHandler replies to a vty command with the reply and status
+func vtyHandler(reply []byte, status byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		if _, err := bufio.NewReader(conn).ReadString(0); err != nil {
+			return
+		}
+		_, _ = conn.Write(append(reply, 0, 0, 0, status))
+	}
+}
+
+// birdHandler sends the greeting and replies to a command with reply
+func birdHandler(reply []byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		_, _ = conn.Write([]byte("0001 BIRD 2.0.7 ready.\n"))
+		if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+			return
+		}
+		_, _ = conn.Write(reply)
+	}
+}
+
+func TestStateValue(t *testing.T) {
+	t.Log("Testing stateValue")
+
+	tests := map[string]int{
+		"Established":  6,
+		"Idle (Admin)": 1,
+		"Active":       3,
+		"OpenSent":     4,
+		"Clearing":     0,
+	}
+	for state, expect := range tests {
+		if v := stateValue(state); v != expect {
+			t.Fatalf("%q expected %d got %d", state, expect, v)
+		}
+	}
+}
+
+func TestParseFRRSummary(t *testing.T) {
+	t.Log("Testing parseFRRSummary")
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "frr_summary.json"))
+	if err != nil {
+		t.Fatalf("reading testdata (%s)", err)
+	}
+
+	peers, err := parseFRRSummary(data)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(peers))
+	}
+
+	p := peers[0]
+	if p.name != "10.0.0.2" || !p.established || p.uptime != 3723 || p.flaps != 2 || p.remoteAS != "65002" {
+		t.Fatalf("unexpected peer %#v", p)
+	}
+	if len(p.families) != 2 || p.families[1].name != "ipv6Unicast" || p.families[1].received != 4 {
+		t.Fatalf("unexpected families %#v", p.families)
+	}
+	if peers[1].remoteAS != "4200000000" || peers[1].established || peers[1].hasUptime {
+		t.Fatalf("unexpected peer %#v", peers[1])
+	}
+
+	t.Log("\tinvalid")
+	{
+		if _, err := parseFRRSummary([]byte("% Unknown command")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestFRRCollect(t *testing.T) {
+	t.Log("Testing FRR Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "frr_summary.json"))
+	if err != nil {
+		t.Fatalf("reading testdata (%s)", err)
+	}
+	socketPath, cleanup := serveSocket(t, vtyHandler(data, 0))
+	defer cleanup()
+
+	c, err := NewFRRCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	c.(*FRR).socketPath = socketPath
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "peer_state", "bgp-peer:10.0.0.2", "remote-as:65002"); !ok || m.Value.(int) != 6 {
+		t.Fatalf("expected peer state 6, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "peer_uptime", "bgp-peer:10.0.0.2"); !ok || m.Value.(uint64) != 3723 {
+		t.Fatalf("expected peer uptime 3723, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "prefixes_received", "bgp-peer:10.0.0.2", "address-family:ipv4Unicast"); !ok || m.Value.(uint64) != 10 {
+		t.Fatalf("expected 10 prefixes received, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "prefixes_sent", "bgp-peer:10.0.0.2", "address-family:ipv6Unicast"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected 1 prefix sent, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "peer_uptime", "bgp-peer:10.0.0.3"); ok {
+		t.Fatal("expected no uptime for idle peer")
+	}
+	if m, ok := testutil.FindMetric(metrics, "peers_established"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected 1 established peer, got %v", m.Value)
+	}
+
+	t.Log("\tcommand error")
+	{
+		errPath, errCleanup := serveSocket(t, vtyHandler([]byte("% Unknown command"), 1))
+		defer errCleanup()
+		c.(*FRR).socketPath = errPath
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tno socket")
+	{
+		c.(*FRR).socketPath = filepath.Join("testdata", "missing.sock")
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestBirdCollect(t *testing.T) {
+	t.Log("Testing Bird Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "bird_protocols.txt"))
+	if err != nil {
+		t.Fatalf("reading testdata (%s)", err)
+	}
+	socketPath, cleanup := serveSocket(t, birdHandler(data))
+	defer cleanup()
+
+	c, err := NewBirdCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	c.(*Bird).socketPath = socketPath
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "peer_established", "bgp-peer:upstream1", "remote-as:65002"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected upstream1 established, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "peer_state", "bgp-peer:upstream2"); !ok || m.Value.(int) != 3 {
+		t.Fatalf("expected upstream2 state 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "prefixes_received", "bgp-peer:upstream1", "address-family:ipv4"); !ok || m.Value.(uint64) != 12 {
+		t.Fatalf("expected 12 prefixes received, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "prefixes_accepted", "bgp-peer:upstream1", "address-family:ipv4"); !ok || m.Value.(uint64) != 10 {
+		t.Fatalf("expected 10 prefixes accepted, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "prefixes_sent", "bgp-peer:upstream1", "address-family:ipv6"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected 1 prefix sent, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "peer_state", "bgp-peer:device1"); ok {
+		t.Fatal("expected non-BGP protocols to be ignored")
+	}
+	if m, ok := testutil.FindMetric(metrics, "peers"); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected 2 peers, got %v", m.Value)
+	}
+
+	t.Log("\tflap counted")
+	{
+		down := strings.Replace(string(data), "BGP state:          Established", "BGP state:          Connect", 1)
+		downPath, downCleanup := serveSocket(t, birdHandler([]byte(down)))
+		defer downCleanup()
+		c.(*Bird).socketPath = downPath
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "peer_flaps", "bgp-peer:upstream1"); !ok || m.Value.(uint64) != 1 {
+			t.Fatalf("expected 1 flap, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tcommand error")
+	{
+		errPath, errCleanup := serveSocket(t, birdHandler([]byte("9001 Parse error\n")))
+		defer errCleanup()
+		c.(*Bird).socketPath = errPath
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
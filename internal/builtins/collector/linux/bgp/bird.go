@@ -0,0 +1,253 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package bgp
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Bird metrics from the bird control socket (`show protocols all`), bird
+// does not report session flaps, they are counted by the collector
+type Bird struct {
+	common
+	socketPath  string
+	timeout     time.Duration
+	established map[string]bool   // session state at the last collection
+	flaps       map[string]uint64 // sessions lost since the agent started
+}
+
+// birdOptions defines what elements can be overridden in a config file
+type birdOptions struct {
+	commonOptions
+
+	// collector specific
+	SocketPath string `json:"socket_path" toml:"socket_path" yaml:"socket_path"`
+	Timeout    string `json:"timeout" toml:"timeout" yaml:"timeout"`
+}
+
+const (
+	defaultBirdSocketPath = "/run/bird/bird.ctl"
+	birdProtocolsCmd      = "show protocols all"
+)
+
+// Routes:         10 imported, 2 filtered, 5 exported, 10 preferred
+var birdRoutesRx = regexp.MustCompile(`(\d+) (imported|filtered|exported)`)
+
+// NewBirdCollector creates new bird bgp collector
+func NewBirdCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Bird{
+		common:      newCommon(NameBird, tags.FromList(tags.GetBaseTags())),
+		socketPath:  defaultBirdSocketPath,
+		timeout:     defaultTimeout,
+		established: make(map[string]bool),
+		flaps:       make(map[string]uint64),
+	}
+
+	var opts birdOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.SocketPath != "" {
+		c.socketPath = opts.SocketPath
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from bird
+func (c *Bird) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := birdQuery(cctx, c.socketPath, birdProtocolsCmd)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	peers := parseBirdProtocols(out)
+
+	c.Lock()
+	for i, p := range peers {
+		if c.established[p.name] && !p.established {
+			c.flaps[p.name]++
+		}
+		c.established[p.name] = p.established
+		peers[i].flaps = c.flaps[p.name]
+	}
+	c.Unlock()
+
+	c.addPeerMetrics(&metrics, peers)
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// birdQuery runs a command on the bird control socket, reply lines are
+// prefixed with a four digit code followed by '-' (more lines follow) or
+// ' ' (last line), continuation lines start with a space. The reply ends
+// with a 0xxx (success), 8xxx (runtime error) or 9xxx (parse error) code.
+func birdQuery(ctx context.Context, socketPath, cmd string) ([]string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to bird socket")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	// greeting, e.g. "0001 BIRD 2.0.7 ready."
+	if !scanner.Scan() {
+		return nil, errors.Wrap(scanner.Err(), "reading bird greeting")
+	}
+	if !strings.HasPrefix(scanner.Text(), "0001 ") {
+		return nil, errors.Errorf("unexpected bird greeting (%s)", scanner.Text())
+	}
+
+	if _, err := conn.Write([]byte(cmd + "\n")); err != nil {
+		return nil, errors.Wrap(err, "sending bird command")
+	}
+
+	lines := []string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		lines = append(lines, line)
+		if len(line) >= 5 && line[4] == ' ' && isReplyCode(line[:4]) {
+			switch line[0] {
+			case '0':
+				return lines, nil
+			case '8', '9':
+				return nil, errors.Errorf("bird command (%s): %s", cmd, strings.TrimSpace(line[5:]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading bird reply")
+	}
+	return nil, errors.New("incomplete bird reply")
+}
+
+// isReplyCode returns true if s is a four digit reply code
+func isReplyCode(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBirdProtocols parses the `show protocols all` reply lines into the
+// BGP protocols (peers), 1002 lines are the protocol summary (name, proto,
+// table, state, since, info) followed by 1006 protocol details
+func parseBirdProtocols(lines []string) []peer {
+	peers := []peer{}
+	var cur *peer
+	var fam *family
+
+	for _, line := range lines {
+		code, text := "", ""
+		switch {
+		case len(line) >= 5 && isReplyCode(line[:4]) && (line[4] == '-' || line[4] == ' '):
+			code, text = line[:4], line[5:]
+		case strings.HasPrefix(line, " "):
+			text = line[1:]
+		default:
+			continue
+		}
+
+		if code == "1002" {
+			cur, fam = nil, nil
+			fields := strings.Fields(text)
+			if len(fields) < 4 || fields[1] != "BGP" {
+				continue
+			}
+			peers = append(peers, peer{name: fields[0]})
+			cur = &peers[len(peers)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		text = strings.TrimSpace(text)
+		switch {
+		case strings.HasPrefix(text, "BGP state:"):
+			cur.state = strings.TrimSpace(strings.TrimPrefix(text, "BGP state:"))
+			cur.established = stateValue(cur.state) == peerStates["established"]
+		case strings.HasPrefix(text, "Neighbor AS:"):
+			cur.remoteAS = strings.TrimSpace(strings.TrimPrefix(text, "Neighbor AS:"))
+		case strings.HasPrefix(text, "Channel "):
+			cur.families = append(cur.families, family{name: strings.TrimSpace(strings.TrimPrefix(text, "Channel "))})
+			fam = &cur.families[len(cur.families)-1]
+		case strings.HasPrefix(text, "Routes:"):
+			if fam == nil {
+				// bird 1.x, no channels
+				cur.families = append(cur.families, family{})
+				fam = &cur.families[len(cur.families)-1]
+			}
+			for _, m := range birdRoutesRx.FindAllStringSubmatch(text, -1) {
+				v, _ := strconv.ParseUint(m[1], 10, 64)
+				switch m[2] {
+				case "imported":
+					fam.accepted = v
+					fam.hasAccepted = true
+					fam.received += v
+				case "filtered":
+					fam.received += v
+				case "exported":
+					fam.sent = v
+					fam.hasSent = true
+				}
+			}
+		}
+	}
+
+	return peers
+}
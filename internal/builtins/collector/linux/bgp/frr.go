@@ -0,0 +1,227 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package bgp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// FRR metrics from the bgpd vty socket (`show bgp summary json`)
+type FRR struct {
+	common
+	socketPath string
+	timeout    time.Duration
+}
+
+// frrOptions defines what elements can be overridden in a config file
+type frrOptions struct {
+	commonOptions
+
+	// collector specific
+	SocketPath string `json:"socket_path" toml:"socket_path" yaml:"socket_path"`
+	Timeout    string `json:"timeout" toml:"timeout" yaml:"timeout"`
+}
+
+// frrPeer is a peer of an address family in the bgp summary
+type frrPeer struct {
+	RemoteAs           interface{} `json:"remoteAs"`
+	State              string      `json:"state"`
+	PeerUptimeMsec     *uint64     `json:"peerUptimeMsec"`
+	PfxRcd             *uint64     `json:"pfxRcd"`
+	PfxSnt             *uint64     `json:"pfxSnt"`
+	ConnectionsDropped uint64      `json:"connectionsDropped"`
+}
+
+const (
+	defaultFRRSocketPath = "/var/run/frr/bgpd.vty"
+	frrSummaryCmd        = "show bgp summary json"
+)
+
+// NewFRRCollector creates new frr bgp collector
+func NewFRRCollector(cfgBaseName string) (collector.Collector, error) {
+	c := FRR{
+		common:     newCommon(NameFRR, tags.FromList(tags.GetBaseTags())),
+		socketPath: defaultFRRSocketPath,
+		timeout:    defaultTimeout,
+	}
+
+	var opts frrOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.SocketPath != "" {
+		c.socketPath = opts.SocketPath
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from bgpd
+func (c *FRR) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := vtyQuery(cctx, c.socketPath, frrSummaryCmd)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	peers, err := parseFRRSummary(out)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	c.addPeerMetrics(&metrics, peers)
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// vtyQuery runs a command on a FRR daemon vty socket, as vtysh does: the
+// command is sent nul terminated, the reply ends with three nul bytes and
+// the command status (0 success)
+func vtyQuery(ctx context.Context, socketPath, cmd string) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to vty socket")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := conn.Write(append([]byte(cmd), 0)); err != nil {
+		return nil, errors.Wrap(err, "sending vty command")
+	}
+
+	var reply bytes.Buffer
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			reply.Write(buf[:n])
+			if data := reply.Bytes(); len(data) >= 4 && bytes.Equal(data[len(data)-4:len(data)-1], []byte{0, 0, 0}) {
+				if status := data[len(data)-1]; status != 0 {
+					return nil, errors.Errorf("vty command (%s) status %d: %s", cmd, status, bytes.TrimSpace(data[:len(data)-4]))
+				}
+				return data[:len(data)-4], nil
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading vty reply")
+		}
+	}
+}
+
+// parseFRRSummary parses `show bgp summary json`, an object keyed by address
+// family (e.g. ipv4Unicast) each with the peers of the family
+func parseFRRSummary(data []byte) ([]peer, error) {
+	var summary map[string]json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&summary); err != nil {
+		return nil, errors.Wrap(err, "parsing bgp summary")
+	}
+
+	afis := make([]string, 0, len(summary))
+	for afi := range summary {
+		afis = append(afis, afi)
+	}
+	sort.Strings(afis)
+
+	peers := []peer{}
+	index := make(map[string]int)
+	for _, afi := range afis {
+		var afiPeers struct {
+			Peers map[string]frrPeer `json:"peers"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(summary[afi]))
+		dec.UseNumber()
+		if err := dec.Decode(&afiPeers); err != nil {
+			continue // not an address family
+		}
+
+		names := make([]string, 0, len(afiPeers.Peers))
+		for name := range afiPeers.Peers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fp := afiPeers.Peers[name]
+			idx, ok := index[name]
+			if !ok {
+				p := peer{
+					name:        name,
+					state:       fp.State,
+					established: stateValue(fp.State) == peerStates["established"],
+					flaps:       fp.ConnectionsDropped,
+				}
+				if fp.RemoteAs != nil {
+					if as, ok := fp.RemoteAs.(json.Number); ok {
+						p.remoteAS = as.String()
+					}
+				}
+				if p.established && fp.PeerUptimeMsec != nil {
+					p.uptime = *fp.PeerUptimeMsec / 1000
+					p.hasUptime = true
+				}
+				peers = append(peers, p)
+				idx = len(peers) - 1
+				index[name] = idx
+			}
+
+			f := family{name: afi}
+			if fp.PfxRcd != nil {
+				f.received = *fp.PfxRcd
+			}
+			if fp.PfxSnt != nil {
+				f.sent = *fp.PfxSnt
+				f.hasSent = true
+			}
+			peers[idx].families = append(peers[idx].families, f)
+		}
+	}
+
+	return peers, nil
+}
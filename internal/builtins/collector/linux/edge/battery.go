@@ -0,0 +1,177 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package edge
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Battery metrics from the sysfs power_supply class (battery charge, health
+// and charging state, and whether external/AC power is online)
+type Battery struct {
+	common
+}
+
+// batteryOptions defines what elements can be overridden in a config file
+type batteryOptions struct {
+	commonOptions
+}
+
+const powerSupplyDir = "class/power_supply" // relative to sysfs
+
+// NewBatteryCollector creates new edge battery collector
+func NewBatteryCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := Battery{
+		common: newCommon(NameBattery, sysFSPath, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts batteryOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *Battery) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	supplyDir := filepath.Join(c.sysFSPath, powerSupplyDir)
+	entries, err := ioutil.ReadDir(supplyDir)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	batteries := 0
+	external := 0
+	externalOnline := 0
+	for _, entry := range entries {
+		dir := filepath.Join(supplyDir, entry.Name())
+		supplyType := readString(filepath.Join(dir, "type"))
+		switch supplyType {
+		case "Battery":
+			// peripheral batteries (e.g. wireless mice) do not power the system
+			if readString(filepath.Join(dir, "scope")) == "Device" {
+				continue
+			}
+			if present, ok := readUint(filepath.Join(dir, "present")); ok && present == 0 {
+				continue
+			}
+			batteries++
+			c.addBatteryMetrics(&metrics, entry.Name(), dir)
+
+		case "Mains", "USB", "USB_C", "USB_PD":
+			online, ok := readUint(filepath.Join(dir, "online"))
+			if !ok {
+				continue
+			}
+			external++
+			if online > 0 {
+				externalOnline = 1
+			}
+			_ = c.addMetric(&metrics, "", "online", "L", online, tags.Tags{
+				tags.Tag{Category: "power-supply", Value: entry.Name()},
+				tags.Tag{Category: "type", Value: strings.ToLower(supplyType)},
+			})
+		}
+	}
+
+	_ = c.addMetric(&metrics, "", "batteries", "I", batteries, tags.Tags{})
+	if external > 0 {
+		_ = c.addMetric(&metrics, "", "ac_online", "I", externalOnline, tags.Tags{})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// addBatteryMetrics adds the metrics of a battery, energy and power values
+// are reported in µWh/µW (or charge in µAh for some batteries) and voltage in µV
+func (c *Battery) addBatteryMetrics(metrics *cgm.Metrics, name, dir string) {
+	batteryTag := tags.Tag{Category: "battery", Value: name}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	if v, ok := readUint(filepath.Join(dir, "capacity")); ok {
+		_ = c.addMetric(metrics, "", "charge", "L", v, tags.Tags{batteryTag, tagUnitsPercent})
+	}
+
+	// health as the remaining full capacity relative to the design capacity
+	for _, prefix := range []string{"energy", "charge"} {
+		full, fullOK := readUint(filepath.Join(dir, prefix+"_full"))
+		design, designOK := readUint(filepath.Join(dir, prefix+"_full_design"))
+		if fullOK && designOK && design > 0 {
+			_ = c.addMetric(metrics, "", "health", "n", float64(full)/float64(design)*100, tags.Tags{batteryTag, tagUnitsPercent})
+			break
+		}
+	}
+
+	// health state reported by the battery, unknown is not reported
+	if health := readString(filepath.Join(dir, "health")); health != "" && health != "Unknown" {
+		ok := 0
+		if health == "Good" {
+			ok = 1
+		}
+		_ = c.addMetric(metrics, "", "health_ok", "I", ok, tags.Tags{batteryTag})
+	}
+
+	if status := readString(filepath.Join(dir, "status")); status != "" {
+		charging, discharging := 0, 0
+		switch status {
+		case "Charging":
+			charging = 1
+		case "Discharging":
+			discharging = 1
+		}
+		_ = c.addMetric(metrics, "", "charging", "I", charging, tags.Tags{batteryTag})
+		_ = c.addMetric(metrics, "", "discharging", "I", discharging, tags.Tags{batteryTag})
+	}
+
+	if v, ok := readUint(filepath.Join(dir, "cycle_count")); ok {
+		_ = c.addMetric(metrics, "", "cycle_count", "L", v, tags.Tags{batteryTag})
+	}
+
+	voltage, voltageOK := readUint(filepath.Join(dir, "voltage_now"))
+	if voltageOK {
+		_ = c.addMetric(metrics, "", "voltage", "n", float64(voltage)/1e6, tags.Tags{batteryTag, tags.Tag{Category: "units", Value: "volts"}})
+	}
+
+	tagUnitsWatts := tags.Tag{Category: "units", Value: "watts"}
+	if v, ok := readUint(filepath.Join(dir, "power_now")); ok {
+		_ = c.addMetric(metrics, "", "power", "n", float64(v)/1e6, tags.Tags{batteryTag, tagUnitsWatts})
+	} else if v, ok := readUint(filepath.Join(dir, "current_now")); ok && voltageOK {
+		_ = c.addMetric(metrics, "", "power", "n", float64(v)/1e6*float64(voltage)/1e6, tags.Tags{batteryTag, tagUnitsWatts})
+	}
+}
+
+// readString returns the trimmed content of a sysfs attribute, empty if
+// the attribute does not exist
+func readString(file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,146 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package edge
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// CPUFreq metrics from sysfs cpufreq and thermal_throttle (per cpu frequency,
+// frequency limits and throttle counters)
+type CPUFreq struct {
+	common
+}
+
+// cpuFreqOptions defines what elements can be overridden in a config file
+type cpuFreqOptions struct {
+	commonOptions
+}
+
+const cpuDevicesDir = "devices/system/cpu" // relative to sysfs
+
+var cpuDirRx = regexp.MustCompile(`^cpu[0-9]+$`)
+
+// NewCPUFreqCollector creates new edge cpufreq collector
+func NewCPUFreqCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := CPUFreq{
+		common: newCommon(NameCPUFreq, sysFSPath, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts cpuFreqOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *CPUFreq) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cpuDir := filepath.Join(c.sysFSPath, cpuDevicesDir)
+	entries, err := ioutil.ReadDir(cpuDir)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsHertz := tags.Tag{Category: "units", Value: "hertz"}
+	tagUnitsEvents := tags.Tag{Category: "units", Value: "events"}
+
+	found := false
+	for _, entry := range entries {
+		if !cpuDirRx.MatchString(entry.Name()) {
+			continue
+		}
+		dir := filepath.Join(cpuDir, entry.Name())
+		cpuTag := tags.Tag{Category: "cpu", Value: strings.TrimPrefix(entry.Name(), "cpu")}
+
+		// frequencies are reported in kHz
+		cur, curOK := readUint(filepath.Join(dir, "cpufreq", "scaling_cur_freq"))
+		maxFreq, maxOK := readUint(filepath.Join(dir, "cpufreq", "scaling_max_freq"))
+		hwMax, hwMaxOK := readUint(filepath.Join(dir, "cpufreq", "cpuinfo_max_freq"))
+		if curOK {
+			found = true
+			_ = c.addMetric(&metrics, "", "freq_current", "L", cur*1000, tags.Tags{cpuTag, tagUnitsHertz})
+		}
+		if maxOK {
+			found = true
+			_ = c.addMetric(&metrics, "", "freq_max", "L", maxFreq*1000, tags.Tags{cpuTag, tagUnitsHertz})
+		}
+		if hwMaxOK {
+			found = true
+			_ = c.addMetric(&metrics, "", "freq_hw_max", "L", hwMax*1000, tags.Tags{cpuTag, tagUnitsHertz})
+		}
+		if maxOK && hwMaxOK {
+			// the policy limit is below the hardware maximum (e.g. thermal or power capping)
+			limited := uint64(0)
+			if maxFreq < hwMax {
+				limited = 1
+			}
+			_ = c.addMetric(&metrics, "", "freq_limited", "L", limited, tags.Tags{cpuTag})
+		}
+		if v, ok := readUint(filepath.Join(dir, "cpufreq", "stats", "total_trans")); ok {
+			found = true
+			_ = c.addMetric(&metrics, "", "freq_transitions", "L", v, tags.Tags{cpuTag, tagUnitsEvents})
+		}
+
+		// x86 thermal throttling event counters
+		throttleStats := []struct{ file, kind string }{
+			{"core_throttle_count", "core"},
+			{"package_throttle_count", "package"},
+		}
+		for _, s := range throttleStats {
+			if v, ok := readUint(filepath.Join(dir, "thermal_throttle", s.file)); ok {
+				found = true
+				_ = c.addMetric(&metrics, "", "throttle_count", "L", v, tags.Tags{cpuTag, tagUnitsEvents, tags.Tag{Category: "type", Value: s.kind}})
+			}
+		}
+	}
+
+	if !found {
+		err := errors.New("no cpufreq or thermal_throttle data found")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// readUint reads a sysfs file containing a single unsigned integer
+func readUint(file string) (uint64, bool) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,92 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package edge builtin linux collectors for edge/IoT devices (Raspberry Pi firmware, cpufreq throttling and battery/power supply)
+package edge
+
+import (
+	"context"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "edge/"
+	PackageName     = "builtins.linux.edge"
+	NameBattery     = "battery"
+	NameCPUFreq     = "cpufreq"
+	NameRPi         = "rpi"
+)
+
+// New creates new edge collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	SysFSPath := viper.GetString(config.KeyHostSys)
+	if SysFSPath == "" {
+		SysFSPath = defaults.HostSys
+	}
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "edge_"+name+"_collector")
+		switch name {
+		case NameBattery:
+			c, err := NewBatteryCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameCPUFreq:
+			c, err := NewCPUFreqCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameRPi:
+			c, err := NewRPiCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
@@ -0,0 +1,282 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package edge
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// stubVcgencmd replaces runCommand with one answering vcgencmd commands
+func stubVcgencmd(outputs map[string]string) func() {
+	orig := runCommand
+	runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+		out, ok := outputs[strings.Join(args, " ")]
+		if !ok {
+			return nil, errors.New("command not found")
+		}
+		return []byte(out), nil
+	}
+	return func() { runCommand = orig }
+}
+
+func TestParseVcgencmd(t *testing.T) {
+	t.Log("Testing parseVcgencmd")
+
+	tests := []struct {
+		out    string
+		key    string
+		suffix string
+		want   float64
+		err    bool
+	}{
+		{"temp=48.3'C\n", "temp", "'C", 48.3, false},
+		{"frequency(48)=1500398464\n", "frequency", "", 1500398464, false},
+		{"volt=0.8500V\n", "volt", "V", 0.85, false},
+		{"error=1 error_msg=\"Command not registered\"\n", "volt", "V", 0, true},
+		{"volt=abc\n", "volt", "V", 0, true},
+	}
+
+	for _, tt := range tests {
+		v, err := parseVcgencmd([]byte(tt.out), tt.key, tt.suffix)
+		if tt.err {
+			if err == nil {
+				t.Fatalf("expected error for (%s)", tt.out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if v != tt.want {
+			t.Fatalf("expected %v, got %v", tt.want, v)
+		}
+	}
+}
+
+func TestRPiCollect(t *testing.T) {
+	t.Log("Testing RPi Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubVcgencmd(map[string]string{
+		"get_throttled":      "throttled=0x50005\n",
+		"measure_temp":       "temp=61.8'C\n",
+		"measure_clock arm":  "frequency(48)=600117184\n",
+		"measure_volts core": "volt=0.8500V\n",
+	})()
+
+	c, err := NewRPiCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "throttled_flags"); !ok || m.Value.(uint64) != 0x50005 {
+		t.Fatalf("expected throttled_flags 0x50005, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "under_voltage", "state:active"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected active under_voltage 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "freq_capped", "state:active"); !ok || m.Value.(uint64) != 0 {
+		t.Fatalf("expected active freq_capped 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "throttled", "state:occurred"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected occurred throttled 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "temperature", "units:celsius"); !ok || m.Value.(float64) != 61.8 {
+		t.Fatalf("expected temperature 61.8, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "arm_clock", "units:hertz"); !ok || m.Value.(float64) != 600117184 {
+		t.Fatalf("expected arm_clock 600117184, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "core_voltage", "units:volts"); !ok || m.Value.(float64) != 0.85 {
+		t.Fatalf("expected core_voltage 0.85, got %v", m.Value)
+	}
+
+	t.Log("\tno vcgencmd, not a raspberry pi")
+	{
+		defer stubVcgencmd(map[string]string{})()
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestRPiCollectSysFS(t *testing.T) {
+	t.Log("Testing RPi Collect (sysfs, no vcgencmd)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubVcgencmd(map[string]string{})()
+
+	dir, err := ioutil.TempDir("", "edge")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		firmwareThrottled: "80008\n",
+		thermalZoneTemp:   "47236\n",
+	}
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("creating dir (%s)", err)
+		}
+		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatalf("writing file (%s)", err)
+		}
+	}
+
+	c, err := NewRPiCollector(filepath.Join("testdata", "missing"), dir)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "soft_temp_limit", "state:active"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected active soft_temp_limit 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "soft_temp_limit", "state:occurred"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected occurred soft_temp_limit 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "temperature", "units:celsius"); !ok || m.Value.(float64) != 47.236 {
+		t.Fatalf("expected temperature 47.236, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "arm_clock"); ok {
+		t.Fatal("expected no arm_clock without vcgencmd")
+	}
+}
+
+func TestCPUFreqCollect(t *testing.T) {
+	t.Log("Testing CPUFreq Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewCPUFreqCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "freq_current", "cpu:1", "units:hertz"); !ok || m.Value.(uint64) != 600000000 {
+		t.Fatalf("expected cpu1 freq_current 600000000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "freq_limited", "cpu:0"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected cpu0 freq_limited 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "freq_limited", "cpu:1"); !ok || m.Value.(uint64) != 0 {
+		t.Fatalf("expected cpu1 freq_limited 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "throttle_count", "cpu:0", "type:package"); !ok || m.Value.(uint64) != 5 {
+		t.Fatalf("expected cpu0 package throttle_count 5, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "throttle_count", "cpu:1"); ok {
+		t.Fatal("expected no cpu1 throttle_count")
+	}
+
+	t.Log("\tno cpufreq")
+	{
+		c, err := NewCPUFreqCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestBatteryCollect(t *testing.T) {
+	t.Log("Testing Battery Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewBatteryCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "batteries"); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected batteries 2 (device scope ignored), got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "ac_online"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected ac_online 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "online", "power-supply:AC", "type:mains"); !ok || m.Value.(uint64) != 0 {
+		t.Fatalf("expected AC online 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "charge", "battery:BAT0", "units:percent"); !ok || m.Value.(uint64) != 87 {
+		t.Fatalf("expected BAT0 charge 87, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "health", "battery:BAT0", "units:percent"); !ok || m.Value.(float64) != 90 {
+		t.Fatalf("expected BAT0 health 90, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "health", "battery:BAT1"); !ok || m.Value.(float64) != 90 {
+		t.Fatalf("expected BAT1 health 90 (charge based), got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "health_ok", "battery:BAT0"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected BAT0 health_ok 1, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "health_ok", "battery:BAT1"); ok {
+		t.Fatal("expected no BAT1 health_ok")
+	}
+	if m, ok := testutil.FindMetric(metrics, "discharging", "battery:BAT0"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected BAT0 discharging 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "charging", "battery:BAT1"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected BAT1 charging 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "cycle_count", "battery:BAT0"); !ok || m.Value.(uint64) != 132 {
+		t.Fatalf("expected BAT0 cycle_count 132, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "voltage", "battery:BAT0", "units:volts"); !ok || m.Value.(float64) != 12.45 {
+		t.Fatalf("expected BAT0 voltage 12.45, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "power", "battery:BAT0", "units:watts"); !ok || m.Value.(float64) != 9.5 {
+		t.Fatalf("expected BAT0 power 9.5, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "power", "battery:BAT1"); !ok || m.Value.(float64) != 16.5 {
+		t.Fatalf("expected BAT1 power 16.5 (current*voltage), got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "charge", "battery:hidpp_battery_0"); ok {
+		t.Fatal("expected no device scope battery metrics")
+	}
+
+	t.Log("\tno power_supply")
+	{
+		c, err := NewBatteryCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package edge
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// RPi metrics from the Raspberry Pi firmware (throttle/undervoltage flags,
+// core temperature, arm clock and core voltage)
+type RPi struct {
+	common
+	vcgencmdPath string
+}
+
+// rpiOptions defines what elements can be overridden in a config file
+type rpiOptions struct {
+	commonOptions
+
+	// collector specific
+	VcgencmdPath string `json:"vcgencmd_path" toml:"vcgencmd_path" yaml:"vcgencmd_path"`
+}
+
+const (
+	defaultVcgencmdPath = "vcgencmd"                                        // resolved using PATH
+	firmwareThrottled   = "devices/platform/soc/soc:firmware/get_throttled" // relative to sysfs
+	thermalZoneTemp     = "class/thermal/thermal_zone0/temp"                // relative to sysfs
+)
+
+// throttledFlags get_throttled bits, the low bits are the current state and
+// the high bits are sticky, set if the condition has occurred since boot
+var throttledFlags = []struct {
+	name string
+	bit  uint
+}{
+	{"under_voltage", 0},
+	{"freq_capped", 1},
+	{"throttled", 2},
+	{"soft_temp_limit", 3},
+}
+
+const throttledOccurredShift = 16
+
+// runCommand runs a system utility and returns its output, overridden in tests
+var runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s %s", cmd, strings.Join(args, " "))
+	}
+	return out, nil
+}
+
+// NewRPiCollector creates new edge rpi collector
+func NewRPiCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := RPi{
+		common:       newCommon(NameRPi, sysFSPath, tags.FromList(tags.GetBaseTags())),
+		vcgencmdPath: defaultVcgencmdPath,
+	}
+
+	var opts rpiOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.VcgencmdPath != "" {
+		c.vcgencmdPath = opts.VcgencmdPath
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the firmware
+func (c *RPi) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	flags, err := c.throttled(ctx)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	_ = c.addMetric(&metrics, "", "throttled_flags", "L", flags, tags.Tags{})
+	for _, f := range throttledFlags {
+		active := (flags >> f.bit) & 1
+		occurred := (flags >> (f.bit + throttledOccurredShift)) & 1
+		_ = c.addMetric(&metrics, "", f.name, "L", active, tags.Tags{tags.Tag{Category: "state", Value: "active"}})
+		_ = c.addMetric(&metrics, "", f.name, "L", occurred, tags.Tags{tags.Tag{Category: "state", Value: "occurred"}})
+	}
+
+	if temp, err := c.temperature(ctx); err != nil {
+		c.logger.Debug().Err(err).Msg("core temperature")
+	} else {
+		_ = c.addMetric(&metrics, "", "temperature", "n", temp, tags.Tags{tags.Tag{Category: "units", Value: "celsius"}})
+	}
+
+	// clock and voltage are only available from the firmware via vcgencmd
+	if out, err := runCommand(ctx, c.vcgencmdPath, "measure_clock", "arm"); err != nil {
+		c.logger.Debug().Err(err).Msg("arm clock")
+	} else if v, err := parseVcgencmd(out, "frequency", ""); err != nil {
+		c.logger.Debug().Err(err).Msg("arm clock")
+	} else {
+		_ = c.addMetric(&metrics, "", "arm_clock", "n", v, tags.Tags{tags.Tag{Category: "units", Value: "hertz"}})
+	}
+
+	if out, err := runCommand(ctx, c.vcgencmdPath, "measure_volts", "core"); err != nil {
+		c.logger.Debug().Err(err).Msg("core voltage")
+	} else if v, err := parseVcgencmd(out, "volt", "V"); err != nil {
+		c.logger.Debug().Err(err).Msg("core voltage")
+	} else {
+		_ = c.addMetric(&metrics, "", "core_voltage", "n", v, tags.Tags{tags.Tag{Category: "units", Value: "volts"}})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// throttled returns the get_throttled flags, from sysfs when the kernel
+// exposes the firmware value (no fork, works in containers with /sys
+// mounted) otherwise from vcgencmd
+func (c *RPi) throttled(ctx context.Context) (uint64, error) {
+	if data, err := ioutil.ReadFile(filepath.Join(c.sysFSPath, firmwareThrottled)); err == nil {
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"), 16, 64)
+		if err != nil {
+			return 0, errors.Wrap(err, "parsing firmware get_throttled")
+		}
+		return v, nil
+	}
+
+	out, err := runCommand(ctx, c.vcgencmdPath, "get_throttled")
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(s, "throttled=0x") {
+		return 0, errors.Errorf("unexpected get_throttled output (%s)", s)
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "throttled=0x"), 16, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing get_throttled")
+	}
+	return v, nil
+}
+
+// temperature returns the soc temperature in celsius, from vcgencmd with
+// the first thermal zone as a fallback
+func (c *RPi) temperature(ctx context.Context) (float64, error) {
+	if out, err := runCommand(ctx, c.vcgencmdPath, "measure_temp"); err == nil {
+		return parseVcgencmd(out, "temp", "'C")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(c.sysFSPath, thermalZoneTemp))
+	if err != nil {
+		return 0, errors.Wrap(err, "reading thermal zone")
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing thermal zone")
+	}
+	return float64(v) / 1000, nil // millidegrees
+}
+
+// parseVcgencmd parses vcgencmd `key=value[suffix]` output, the key may
+// carry an argument e.g. `frequency(48)=1500398464`
+func parseVcgencmd(data []byte, key, suffix string) (float64, error) {
+	s := strings.TrimSpace(string(data))
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], key) {
+		return 0, errors.Errorf("unexpected vcgencmd output (%s)", s)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(parts[1], suffix), 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parsing vcgencmd %s", key)
+	}
+	return v, nil
+}
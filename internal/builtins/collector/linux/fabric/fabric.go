@@ -0,0 +1,147 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package fabric builtin linux collectors for HPC and storage network
+// adapters (InfiniBand/RDMA ports, Fibre Channel HBAs) from sysfs and
+// multipath storage paths from multipathd
+package fabric
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "fabric/"
+	PackageName     = "builtins.linux.fabric"
+	NameFC          = "fc"
+	NameInfiniBand  = "infiniband"
+	NameMultipath   = "multipath"
+	regexPat        = `^(?:%s)$` // fmt pattern used compile include/exclude regular expressions
+)
+
+var (
+	defaultExcludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ""))
+	defaultIncludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ".+"))
+)
+
+// New creates new fabric collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	SysFSPath := viper.GetString(config.KeyHostSys)
+	if SysFSPath == "" {
+		SysFSPath = defaults.HostSys
+	}
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "fabric_"+name+"_collector")
+		switch name {
+		case NameFC:
+			c, err := NewFCCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameInfiniBand:
+			c, err := NewInfiniBandCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameMultipath:
+			c, err := NewMultipathCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+// compileRegexes compiles the include/exclude options, empty options are the defaults
+func compileRegexes(pkgID, include, exclude string) (*regexp.Regexp, *regexp.Regexp, error) {
+	inc, exc := defaultIncludeRegex, defaultExcludeRegex
+	if include != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, include))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling include regex", pkgID)
+		}
+		inc = rx
+	}
+	if exclude != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, exclude))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling exclude regex", pkgID)
+		}
+		exc = rx
+	}
+	return inc, exc, nil
+}
+
+// readUint reads a sysfs file containing a single unsigned integer (decimal or 0x hex)
+func readUint(file string) (uint64, bool) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// readString reads a sysfs file containing a single (trimmed) value
+func readString(file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
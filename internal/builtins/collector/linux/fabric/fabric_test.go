@@ -0,0 +1,239 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package fabric
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestInfiniBandCollect(t *testing.T) {
+	t.Log("Testing InfiniBand Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewInfiniBandCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "state", "device:mlx5_0", "port:1", "link-layer:InfiniBand"); !ok || m.Value.(uint64) != 4 {
+		t.Fatalf("expected mlx5_0 state 4, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "state", "device:mlx5_1"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected mlx5_1 state 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rate", "device:mlx5_0"); !ok || m.Value.(float64) != 100e9 {
+		t.Fatalf("expected mlx5_0 rate 100e9, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "xmit_bytes", "device:mlx5_0", "units:bytes"); !ok || m.Value.(uint64) != 4000 {
+		t.Fatalf("expected xmit_bytes 4000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rcv_bytes", "device:mlx5_0"); !ok || m.Value.(uint64) != 10000 {
+		t.Fatalf("expected rcv_bytes 10000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "link_downed", "device:mlx5_0"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected link_downed 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "symbol_error", "device:mlx5_0"); !ok || m.Value.(uint64) != 7 {
+		t.Fatalf("expected symbol_error 7, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "hw`out_of_buffer"); ok {
+		t.Fatal("expected no hw counters by default")
+	}
+
+	t.Log("\thw counters, exclude device")
+	{
+		c, err := NewInfiniBandCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		ib := c.(*InfiniBand)
+		ib.hwCounters = true
+		ib.exclude, _, _ = compileRegexes("", "mlx5_1", "")
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "hw`out_of_buffer", "device:mlx5_0"); !ok || m.Value.(uint64) != 12 {
+			t.Fatalf("expected hw`out_of_buffer 12, got %v", m.Value)
+		}
+		if _, ok := testutil.FindMetric(metrics, "state", "device:mlx5_1"); ok {
+			t.Fatal("expected mlx5_1 excluded")
+		}
+	}
+
+	t.Log("\tno infiniband")
+	{
+		c, err := NewInfiniBandCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestFCCollect(t *testing.T) {
+	t.Log("Testing FC Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewFCCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "port_online", "fc-host:host1", "port-name:0x10000090fa1b2c3d"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected host1 port_online 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "port_online", "fc-host:host2"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected host2 port_online 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "tx_frames", "fc-host:host1"); !ok || m.Value.(uint64) != 1000 {
+		t.Fatalf("expected tx_frames 1000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rx_bytes", "fc-host:host1", "units:bytes"); !ok || m.Value.(uint64) != 2048 {
+		t.Fatalf("expected rx_bytes 2048, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "invalid_crc_count", "fc-host:host1"); !ok || m.Value.(uint64) != 5 {
+		t.Fatalf("expected invalid_crc_count 5, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "fcp_control_requests"); ok {
+		t.Fatal("expected unsupported statistic to be skipped")
+	}
+
+	t.Log("\tno fc_host")
+	{
+		c, err := NewFCCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+// serveSocket listens on a unix socket in a temp dir, each connection is
+// handled by handler, returns the socket path and a cleanup func
+func serveSocket(t *testing.T, handler func(net.Conn)) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "fabric")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	socketPath := filepath.Join(dir, "test.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listening (%s)", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			handler(conn)
+			conn.Close()
+		}
+	}()
+	return socketPath, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// multipathdHandler replies to a multipathd command with the reply
+func multipathdHandler(reply []byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		hdr := make([]byte, sizeTLen)
+		if _, err := io.ReadFull(conn, hdr); err != nil {
+			return
+		}
+		cmd := make([]byte, decodeSizeT(hdr))
+		if _, err := io.ReadFull(conn, cmd); err != nil {
+			return
+		}
+		out := append([]byte{}, reply...)
+		if string(cmd) != multipathMapsCmd+"\x00" {
+			out = []byte("fail\n")
+		}
+		out = append(out, 0)
+		_, _ = conn.Write(append(encodeSizeT(uint64(len(out))), out...))
+	}
+}
+
+func TestMultipathCollect(t *testing.T) {
+	t.Log("Testing Multipath Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "multipath_maps.json"))
+	if err != nil {
+		t.Fatalf("reading testdata (%s)", err)
+	}
+	socketPath, cleanup := serveSocket(t, multipathdHandler(data))
+	defer cleanup()
+
+	c, err := NewMultipathCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	c.(*Multipath).socketPath = socketPath
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	expect := []struct {
+		name  string
+		tag   string
+		value int
+	}{
+		{"paths_total", "multipath-map:mpatha", 4},
+		{"paths_active", "multipath-map:mpatha", 2},
+		{"paths_failed", "multipath-map:mpatha", 2},
+		{"paths_total", "multipath-map:mpathb", 1},
+		{"paths_failed", "multipath-map:mpathb", 0},
+	}
+	for _, e := range expect {
+		m, ok := testutil.FindMetric(metrics, e.name, e.tag, "units:paths")
+		if !ok || m.Value.(int) != e.value {
+			t.Fatalf("expected %s %s %d, got %v", e.name, e.tag, e.value, m.Value)
+		}
+	}
+	if m, ok := testutil.FindMetric(metrics, "path_faults", "multipath-map:mpatha", "dm-device:dm-0"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected path_faults 3, got %v", m.Value)
+	}
+
+	t.Log("\tno socket")
+	{
+		c.(*Multipath).socketPath = filepath.Join("testdata", "missing.sock")
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
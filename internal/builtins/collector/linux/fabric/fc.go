@@ -0,0 +1,136 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package fabric
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// FC metrics from the sysfs fc_host class, Fibre Channel HBA port state and
+// statistics (link failures, invalid CRC, tx/rx frames, etc.)
+type FC struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// fcOptions defines what elements can be overridden in a config file
+type fcOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+const fcHostClassDir = "class/fc_host" // relative to sysfs
+
+// fcWordCounters are in units of fibre channel words (4 bytes)
+var fcWordCounters = map[string]string{
+	"tx_words": "tx_bytes",
+	"rx_words": "rx_bytes",
+}
+
+// NewFCCollector creates new fabric fc collector
+func NewFCCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := FC{
+		common:  newCommon(NameFC, sysFSPath, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	var opts fcOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	inc, exc, err := compileRegexes(c.pkgID, opts.IncludeRegex, opts.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+	c.include, c.exclude = inc, exc
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *FC) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	classDir := filepath.Join(c.sysFSPath, fcHostClassDir)
+	hosts, err := ioutil.ReadDir(classDir)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	for _, host := range hosts {
+		if c.exclude.MatchString(host.Name()) || !c.include.MatchString(host.Name()) {
+			continue
+		}
+		c.addHostMetrics(&metrics, host.Name(), filepath.Join(classDir, host.Name()))
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+func (c *FC) addHostMetrics(metrics *cgm.Metrics, host, dir string) {
+	tagList := tags.Tags{tags.Tag{Category: "fc-host", Value: host}}
+	if wwpn := readString(filepath.Join(dir, "port_name")); wwpn != "" {
+		tagList = append(tagList, tags.Tag{Category: "port-name", Value: wwpn})
+	}
+
+	// e.g. Online, Linkdown, Offline
+	if state := readString(filepath.Join(dir, "port_state")); state != "" {
+		online := 0
+		if state == "Online" {
+			online = 1
+		}
+		_ = c.addMetric(metrics, "", "port_online", "I", online, tagList)
+	}
+
+	statsDir := filepath.Join(dir, "statistics")
+	entries, err := ioutil.ReadDir(statsDir)
+	if err != nil {
+		c.logger.Warn().Err(err).Str("fc-host", host).Msg("reading statistics")
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		// hex values, all ones when the driver does not maintain the statistic
+		v, ok := readUint(filepath.Join(statsDir, entry.Name()))
+		if !ok || v == math.MaxUint64 {
+			continue
+		}
+		if bytesName, ok := fcWordCounters[entry.Name()]; ok {
+			_ = c.addMetric(metrics, "", bytesName, "L", v*4, append(tagList, tags.Tag{Category: "units", Value: "bytes"}))
+			continue
+		}
+		_ = c.addMetric(metrics, "", entry.Name(), "L", v, tagList)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package fabric
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// InfiniBand metrics from the sysfs infiniband class, port state and the
+// port counters (InfiniBand, RoCE and iWARP RDMA devices), optionally the
+// driver specific hw_counters
+type InfiniBand struct {
+	common
+	include    *regexp.Regexp
+	exclude    *regexp.Regexp
+	hwCounters bool
+}
+
+// infiniBandOptions defines what elements can be overridden in a config file
+type infiniBandOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	HWCounters   string `json:"hw_counters" toml:"hw_counters" yaml:"hw_counters"`
+}
+
+const infiniBandClassDir = "class/infiniband" // relative to sysfs
+
+// infiniBandDataCounters are in units of 4 octets (per lane)
+var infiniBandDataCounters = map[string]string{
+	"port_xmit_data": "xmit_bytes",
+	"port_rcv_data":  "rcv_bytes",
+}
+
+// NewInfiniBandCollector creates new fabric infiniband collector
+func NewInfiniBandCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := InfiniBand{
+		common:  newCommon(NameInfiniBand, sysFSPath, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	var opts infiniBandOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	inc, exc, err := compileRegexes(c.pkgID, opts.IncludeRegex, opts.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+	c.include, c.exclude = inc, exc
+
+	if opts.HWCounters != "" {
+		hw, err := strconv.ParseBool(opts.HWCounters)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing hw_counters", c.pkgID)
+		}
+		c.hwCounters = hw
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *InfiniBand) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	classDir := filepath.Join(c.sysFSPath, infiniBandClassDir)
+	devices, err := ioutil.ReadDir(classDir)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	for _, device := range devices {
+		if c.exclude.MatchString(device.Name()) || !c.include.MatchString(device.Name()) {
+			continue
+		}
+		portsDir := filepath.Join(classDir, device.Name(), "ports")
+		ports, err := ioutil.ReadDir(portsDir)
+		if err != nil {
+			c.logger.Warn().Err(err).Str("device", device.Name()).Msg("reading ports")
+			continue
+		}
+		for _, port := range ports {
+			c.addPortMetrics(&metrics, device.Name(), port.Name(), filepath.Join(portsDir, port.Name()))
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+func (c *InfiniBand) addPortMetrics(metrics *cgm.Metrics, device, port, dir string) {
+	tagList := tags.Tags{
+		tags.Tag{Category: "device", Value: device},
+		tags.Tag{Category: "port", Value: port},
+	}
+	if ll := readString(filepath.Join(dir, "link_layer")); ll != "" {
+		tagList = append(tagList, tags.Tag{Category: "link-layer", Value: ll})
+	}
+
+	// e.g. "4: ACTIVE" and "5: LinkUp"
+	for _, state := range []string{"state", "phys_state"} {
+		if v, ok := parseEnumValue(readString(filepath.Join(dir, state))); ok {
+			_ = c.addMetric(metrics, "", state, "L", v, tagList)
+		}
+	}
+	if rate, ok := parseRate(readString(filepath.Join(dir, "rate"))); ok {
+		_ = c.addMetric(metrics, "", "rate", "n", rate, append(tagList, tags.Tag{Category: "units", Value: "bits/sec"}))
+	}
+
+	c.addCounters(metrics, filepath.Join(dir, "counters"), "", tagList)
+	if c.hwCounters {
+		c.addCounters(metrics, filepath.Join(dir, "hw_counters"), "hw", tagList)
+	}
+}
+
+// addCounters adds each counter file in dir, the data counters are converted to bytes
+func (c *InfiniBand) addCounters(metrics *cgm.Metrics, dir, prefix string, tagList tags.Tags) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		v, ok := readUint(filepath.Join(dir, entry.Name()))
+		if !ok {
+			continue
+		}
+		name := entry.Name()
+		if prefix == "" {
+			if bytesName, ok := infiniBandDataCounters[name]; ok {
+				_ = c.addMetric(metrics, "", bytesName, "L", v*4, append(tagList, tags.Tag{Category: "units", Value: "bytes"}))
+				continue
+			}
+		}
+		_ = c.addMetric(metrics, prefix, name, "L", v, tagList)
+	}
+}
+
+// parseEnumValue returns the numeric value of a sysfs "N: NAME" attribute
+func parseEnumValue(s string) (uint64, bool) {
+	idx := strings.Index(s, ":")
+	if idx == -1 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(s[:idx]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseRate returns the port rate in bits/sec from e.g. "100 Gb/sec (4X EDR)"
+func parseRate(s string) (float64, bool) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || fields[1] != "Gb/sec" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * 1e9, true
+}
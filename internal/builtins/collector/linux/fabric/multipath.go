@@ -0,0 +1,239 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package fabric
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"regexp"
+	"time"
+	"unsafe"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Multipath metrics from multipathd (`show maps json`), device-mapper
+// multipath path state per map so path failures are visible before the
+// last path of a device fails
+type Multipath struct {
+	common
+	socketPath string
+	timeout    time.Duration
+	include    *regexp.Regexp
+	exclude    *regexp.Regexp
+}
+
+// multipathOptions defines what elements can be overridden in a config file
+type multipathOptions struct {
+	commonOptions
+
+	// collector specific
+	SocketPath   string `json:"socket_path" toml:"socket_path" yaml:"socket_path"`
+	Timeout      string `json:"timeout" toml:"timeout" yaml:"timeout"`
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+type multipathMaps struct {
+	Maps []struct {
+		Name       string `json:"name"`
+		Sysfs      string `json:"sysfs"`       // e.g. dm-0
+		PathFaults uint64 `json:"path_faults"` // since multipathd started
+		PathGroups []struct {
+			Paths []struct {
+				Dev   string `json:"dev"`
+				DMSt  string `json:"dm_st"`  // device-mapper path state, active or failed
+				ChkSt string `json:"chk_st"` // path checker state, e.g. ready, faulty, ghost
+			} `json:"paths"`
+		} `json:"path_groups"`
+	} `json:"maps"`
+}
+
+const (
+	// multipathd listens on an abstract unix socket
+	defaultMultipathSocketPath = "@/org/kernel/linux/storage/multipathd"
+	defaultMultipathTimeout    = 5 * time.Second
+	multipathMapsCmd           = "show maps json"
+	maxMultipathReply          = 16 * 1024 * 1024
+)
+
+// NewMultipathCollector creates new fabric multipath collector
+func NewMultipathCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Multipath{
+		common:     newCommon(NameMultipath, "", tags.FromList(tags.GetBaseTags())),
+		socketPath: defaultMultipathSocketPath,
+		timeout:    defaultMultipathTimeout,
+		include:    defaultIncludeRegex,
+		exclude:    defaultExcludeRegex,
+	}
+
+	var opts multipathOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.SocketPath != "" {
+		c.socketPath = opts.SocketPath
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	inc, exc, err := compileRegexes(c.pkgID, opts.IncludeRegex, opts.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+	c.include, c.exclude = inc, exc
+
+	return &c, nil
+}
+
+// Collect metrics from multipathd
+func (c *Multipath) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := multipathQuery(cctx, c.socketPath, multipathMapsCmd)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	var maps multipathMaps
+	if err := json.Unmarshal(out, &maps); err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrapf(err, "%s parsing multipathd reply", c.pkgID)
+	}
+
+	tagUnitsPaths := tags.Tag{Category: "units", Value: "paths"}
+
+	for _, m := range maps.Maps {
+		if c.exclude.MatchString(m.Name) || !c.include.MatchString(m.Name) {
+			continue
+		}
+		tagList := tags.Tags{tags.Tag{Category: "multipath-map", Value: m.Name}}
+		if m.Sysfs != "" {
+			tagList = append(tagList, tags.Tag{Category: "dm-device", Value: m.Sysfs})
+		}
+
+		total, active, failed := 0, 0, 0
+		for _, pg := range m.PathGroups {
+			for _, p := range pg.Paths {
+				total++
+				// the checker detects a faulty path before device-mapper fails it
+				if p.DMSt == "failed" || p.ChkSt == "faulty" {
+					failed++
+					continue
+				}
+				active++
+			}
+		}
+
+		_ = c.addMetric(&metrics, "", "paths_total", "I", total, append(tagList, tagUnitsPaths))
+		_ = c.addMetric(&metrics, "", "paths_active", "I", active, append(tagList, tagUnitsPaths))
+		_ = c.addMetric(&metrics, "", "paths_failed", "I", failed, append(tagList, tagUnitsPaths))
+		_ = c.addMetric(&metrics, "", "path_faults", "L", m.PathFaults, tagList)
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// multipathQuery runs a command on the multipathd socket (libmpathcmd
+// protocol), requests and replies are a size_t length in host byte order
+// followed by a nul terminated string
+func multipathQuery(ctx context.Context, socketPath, cmd string) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to multipathd socket")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	req := append([]byte(cmd), 0)
+	if _, err := conn.Write(append(encodeSizeT(uint64(len(req))), req...)); err != nil {
+		return nil, errors.Wrap(err, "sending multipathd command")
+	}
+
+	hdr := make([]byte, sizeTLen)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return nil, errors.Wrap(err, "reading multipathd reply length")
+	}
+	n := decodeSizeT(hdr)
+	if n > maxMultipathReply {
+		return nil, errors.Errorf("multipathd reply too large (%d)", n)
+	}
+	reply := make([]byte, n)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, errors.Wrap(err, "reading multipathd reply")
+	}
+
+	// nul terminated, e.g. "fail\n" for an unknown command
+	for len(reply) > 0 && reply[len(reply)-1] == 0 {
+		reply = reply[:len(reply)-1]
+	}
+	if string(reply) == "fail\n" {
+		return nil, errors.Errorf("multipathd command (%s) failed", cmd)
+	}
+
+	return reply, nil
+}
+
+const sizeTLen = int(unsafe.Sizeof(uintptr(0)))
+
+var hostByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+func encodeSizeT(v uint64) []byte {
+	b := make([]byte, sizeTLen)
+	if sizeTLen == 4 {
+		hostByteOrder.PutUint32(b, uint32(v))
+	} else {
+		hostByteOrder.PutUint64(b, v)
+	}
+	return b
+}
+
+func decodeSizeT(b []byte) uint64 {
+	if sizeTLen == 4 {
+		return uint64(hostByteOrder.Uint32(b))
+	}
+	return hostByteOrder.Uint64(b)
+}
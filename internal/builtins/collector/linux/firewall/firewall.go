@@ -0,0 +1,180 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package firewall builtin linux collectors for iptables and nftables rule
+// counters, packets and bytes of the rules selected by their comment
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "firewall/"
+	PackageName     = "builtins.linux.firewall"
+	NameIPTables    = "iptables"
+	NameNFTables    = "nftables"
+
+	defaultTimeout = 10 * time.Second
+	regexPat       = `^(?:%s)$` // fmt pattern used compile include/exclude regular expressions
+)
+
+var (
+	defaultExcludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ""))
+	defaultIncludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ".+"))
+)
+
+// counter packets and bytes of a rule (rules in the same chain with the same
+// comment are summed), chain policy or named counter
+type counter struct {
+	family  string
+	table   string
+	chain   string
+	name    string
+	packets uint64
+	bytes   uint64
+}
+
+// counters accumulates counters in the order they were first seen
+type counters struct {
+	list  []*counter
+	index map[string]*counter
+}
+
+// runCommand runs a firewall utility and returns its output, overridden in tests
+var runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s %s", cmd, strings.Join(args, " "))
+	}
+	return out, nil
+}
+
+// New creates new firewall collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "firewall_"+name+"_collector")
+		switch name {
+		case NameIPTables:
+			c, err := NewIPTablesCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameNFTables:
+			c, err := NewNFTablesCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+func newCounters() *counters {
+	return &counters{index: make(map[string]*counter)}
+}
+
+// add adds packets and bytes to the counter identified by family, table,
+// chain and name
+func (cs *counters) add(family, table, chain, name string, packets, bytes uint64) {
+	key := strings.Join([]string{family, table, chain, name}, "\x00")
+	c, ok := cs.index[key]
+	if !ok {
+		c = &counter{family: family, table: table, chain: chain, name: name}
+		cs.index[key] = c
+		cs.list = append(cs.list, c)
+	}
+	c.packets += packets
+	c.bytes += bytes
+}
+
+// compileRegexes compiles the include/exclude options, empty options use the defaults
+func compileRegexes(pkgID, include, exclude string) (*regexp.Regexp, *regexp.Regexp, error) {
+	inc, exc := defaultIncludeRegex, defaultExcludeRegex
+	if include != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, include))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling include regex", pkgID)
+		}
+		inc = rx
+	}
+	if exclude != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, exclude))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling exclude regex", pkgID)
+		}
+		exc = rx
+	}
+	return inc, exc, nil
+}
+
+// addCounterMetrics adds <prefix>_packets and <prefix>_bytes for each counter,
+// the counter name is tagged with nameCategory
+func (c *common) addCounterMetrics(metrics *cgm.Metrics, prefix, nameCategory string, cs *counters) {
+	tagUnitsPackets := tags.Tag{Category: "units", Value: "packets"}
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+
+	for _, ctr := range cs.list {
+		ctrTags := tags.Tags{
+			tags.Tag{Category: "family", Value: ctr.family},
+			tags.Tag{Category: "table", Value: ctr.table},
+		}
+		if ctr.chain != "" {
+			ctrTags = append(ctrTags, tags.Tag{Category: "chain", Value: ctr.chain})
+		}
+		if nameCategory != "" {
+			ctrTags = append(ctrTags, tags.Tag{Category: nameCategory, Value: ctr.name})
+		}
+		_ = c.addMetric(metrics, "", prefix+"_packets", "L", ctr.packets, append(tags.Tags{tagUnitsPackets}, ctrTags...))
+		_ = c.addMetric(metrics, "", prefix+"_bytes", "L", ctr.bytes, append(tags.Tags{tagUnitsBytes}, ctrTags...))
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package firewall
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// stubCommands returns the testdata file for each command
+func stubCommands(files map[string]string) func() {
+	orig := runCommand
+	runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+		file, ok := files[cmd]
+		if !ok {
+			return nil, errors.Errorf("unexpected command %s %v", cmd, args)
+		}
+		return ioutil.ReadFile(filepath.Join("testdata", file))
+	}
+	return func() { runCommand = orig }
+}
+
+func TestNewIPTablesCollector(t *testing.T) {
+	t.Log("Testing NewIPTablesCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tno config")
+	{
+		c, err := NewIPTablesCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		ipt := c.(*IPTables)
+		if ipt.ip6tablesSavePath != defaultIP6TablesSavePath || !ipt.reportChains {
+			t.Fatalf("expected defaults, got %#v", ipt)
+		}
+	}
+
+	t.Log("\tconfig settings")
+	{
+		c, err := NewIPTablesCollector(filepath.Join("testdata", "config_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		ipt := c.(*IPTables)
+		if ipt.ip6tablesSavePath != "" || ipt.reportChains || !ipt.exclude.MatchString("debug x") {
+			t.Fatalf("expected settings applied, got %#v", ipt)
+		}
+	}
+
+	for _, cfg := range []string{"config_include_regex_invalid_setting", "config_report_chains_invalid_setting"} {
+		t.Logf("\t%s", cfg)
+		if _, err := NewIPTablesCollector(filepath.Join("testdata", cfg)); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestIPTablesCollect(t *testing.T) {
+	t.Log("Testing IPTables Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubCommands(map[string]string{
+		defaultIPTablesSavePath:  "iptables_save.txt",
+		defaultIP6TablesSavePath: "ip6tables_save.txt",
+	})()
+
+	c, err := NewIPTablesCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	// rules with the same comment in a chain are summed
+	if m, ok := testutil.FindMetric(metrics, "rule_packets", "family:ip", "table:filter", "chain:INPUT", "rule:allow ssh"); !ok || m.Value.(uint64) != 150 {
+		t.Fatalf("expected 150 packets, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rule_bytes", "family:ip6", "rule:allow ssh"); !ok || m.Value.(uint64) != 720 {
+		t.Fatalf("expected 720 bytes, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rule_packets", "rule:https"); !ok || m.Value.(uint64) != 7 {
+		t.Fatalf("expected 7 packets, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rule_packets", "chain:SSH_LIMIT", `rule:debug "x"`); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected 3 packets, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rule_packets", "table:nat", "rule:masquerade"); !ok || m.Value.(uint64) != 4 {
+		t.Fatalf("expected 4 packets, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "chain_bytes", "family:ip", "table:filter", "chain:INPUT"); !ok || m.Value.(uint64) != 9600 {
+		t.Fatalf("expected 9600 bytes, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "chain_packets", "chain:SSH_LIMIT"); ok {
+		t.Fatal("expected no user chain counters")
+	}
+
+	// 4 ipv4 rule counters, 1 ipv6, 7 ipv4 chains, 3 ipv6 chains
+	if len(metrics) != (4+1+7+3)*2 {
+		t.Fatalf("expected %d metrics, got %d", (4+1+7+3)*2, len(metrics))
+	}
+
+	t.Log("\tcommand error")
+	{
+		runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) { return nil, errors.New("boom") }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestNFTablesCollect(t *testing.T) {
+	t.Log("Testing NFTables Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubCommands(map[string]string{defaultNftPath: "nft_ruleset.json"})()
+
+	c, err := NewNFTablesCollector(filepath.Join("testdata", "config_settings"))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "rule_packets", "family:inet", "table:filter", "chain:input", "rule:allow ssh"); !ok || m.Value.(uint64) != 100 {
+		t.Fatalf("expected 100 packets, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "counter_bytes", "family:inet", "table:filter", "counter:web"); !ok || m.Value.(uint64) != 420 {
+		t.Fatalf("expected 420 bytes, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "rule_packets", "rule:debug"); ok {
+		t.Fatal("expected excluded rule to be skipped")
+	}
+	if _, ok := testutil.FindMetric(metrics, "rule_packets", "rule:https"); ok {
+		t.Fatal("expected rule with a named counter reference to be skipped")
+	}
+	if len(metrics) != 4 {
+		t.Fatalf("expected 4 metrics, got %d", len(metrics))
+	}
+
+	t.Log("\tinvalid ruleset")
+	{
+		runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+			return []byte("Error: syntax"), nil
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
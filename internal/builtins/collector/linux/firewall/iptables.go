@@ -0,0 +1,209 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package firewall
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// IPTables metrics from `iptables-save -c` and `ip6tables-save -c`, rule
+// counters of rules with a comment matching the include/exclude regexes and
+// built-in chain policy counters
+type IPTables struct {
+	common
+	include           *regexp.Regexp
+	exclude           *regexp.Regexp
+	iptablesSavePath  string
+	ip6tablesSavePath string
+	reportChains      bool
+	timeout           time.Duration
+}
+
+// iptablesOptions defines what elements can be overridden in a config file
+type iptablesOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex      string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex      string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	IPTablesSavePath  string `json:"iptables_save_path" toml:"iptables_save_path" yaml:"iptables_save_path"`
+	IP6TablesSavePath string `json:"ip6tables_save_path" toml:"ip6tables_save_path" yaml:"ip6tables_save_path"`
+	EnableIPv6        string `json:"enable_ipv6" toml:"enable_ipv6" yaml:"enable_ipv6"`
+	ReportChains      string `json:"report_chains" toml:"report_chains" yaml:"report_chains"`
+	Timeout           string `json:"timeout" toml:"timeout" yaml:"timeout"`
+}
+
+const (
+	defaultIPTablesSavePath  = "iptables-save"  // resolved using PATH
+	defaultIP6TablesSavePath = "ip6tables-save" // resolved using PATH
+)
+
+var (
+	// [packets:bytes] -A CHAIN rule...
+	iptablesRuleRx = regexp.MustCompile(`^\[(\d+):(\d+)\]\s+-A\s+(\S+)\s*(.*)$`)
+	// :CHAIN POLICY [packets:bytes]
+	iptablesChainRx = regexp.MustCompile(`^:(\S+)\s+(\S+)\s+\[(\d+):(\d+)\]`)
+	// -m comment --comment "text" or --comment text
+	iptablesCommentRx = regexp.MustCompile(`--comment\s+(?:"((?:[^"\\]|\\.)*)"|(\S+))`)
+)
+
+// NewIPTablesCollector creates new iptables counter collector
+func NewIPTablesCollector(cfgBaseName string) (collector.Collector, error) {
+	c := IPTables{
+		common:            newCommon(NameIPTables, tags.FromList(tags.GetBaseTags())),
+		include:           defaultIncludeRegex,
+		exclude:           defaultExcludeRegex,
+		iptablesSavePath:  defaultIPTablesSavePath,
+		ip6tablesSavePath: defaultIP6TablesSavePath,
+		reportChains:      true,
+		timeout:           defaultTimeout,
+	}
+
+	var opts iptablesOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	inc, exc, err := compileRegexes(c.pkgID, opts.IncludeRegex, opts.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+	c.include, c.exclude = inc, exc
+
+	if opts.IPTablesSavePath != "" {
+		c.iptablesSavePath = opts.IPTablesSavePath
+	}
+
+	if opts.IP6TablesSavePath != "" {
+		c.ip6tablesSavePath = opts.IP6TablesSavePath
+	}
+
+	if opts.EnableIPv6 != "" {
+		ipv6, err := strconv.ParseBool(opts.EnableIPv6)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing enable_ipv6", c.pkgID)
+		}
+		if !ipv6 {
+			c.ip6tablesSavePath = ""
+		}
+	}
+
+	if opts.ReportChains != "" {
+		rc, err := strconv.ParseBool(opts.ReportChains)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing report_chains", c.pkgID)
+		}
+		c.reportChains = rc
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from iptables-save
+func (c *IPTables) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	rules := newCounters()
+	chains := newCounters()
+
+	saves := []struct{ family, cmd string }{{"ip", c.iptablesSavePath}}
+	if c.ip6tablesSavePath != "" {
+		saves = append(saves, struct{ family, cmd string }{"ip6", c.ip6tablesSavePath})
+	}
+	for _, s := range saves {
+		out, err := runCommand(cctx, s.cmd, "-c")
+		if err != nil {
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+		c.parseSave(out, s.family, rules, chains)
+	}
+
+	c.addCounterMetrics(&metrics, "rule", "rule", rules)
+	if c.reportChains {
+		c.addCounterMetrics(&metrics, "chain", "", chains)
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// parseSave parses iptables-save -c output, rules with a comment matching the
+// include/exclude regexes are added to rules, built-in chain (those with a
+// policy) counters to chains
+func (c *IPTables) parseSave(data []byte, family string, rules, chains *counters) {
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+
+		case strings.HasPrefix(line, ":"):
+			m := iptablesChainRx.FindStringSubmatch(line)
+			if m == nil || m[2] == "-" {
+				continue // user defined chains have no policy or counters
+			}
+			packets, _ := strconv.ParseUint(m[3], 10, 64)
+			octets, _ := strconv.ParseUint(m[4], 10, 64)
+			chains.add(family, table, m[1], "", packets, octets)
+
+		case strings.HasPrefix(line, "["):
+			m := iptablesRuleRx.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			cm := iptablesCommentRx.FindStringSubmatch(m[4])
+			if cm == nil {
+				continue // rules without a comment have no stable identifier
+			}
+			comment := cm[2]
+			if cm[1] != "" {
+				comment = strings.ReplaceAll(cm[1], `\"`, `"`)
+			}
+			if c.exclude.MatchString(comment) || !c.include.MatchString(comment) {
+				continue
+			}
+			packets, _ := strconv.ParseUint(m[1], 10, 64)
+			octets, _ := strconv.ParseUint(m[2], 10, 64)
+			rules.add(family, table, m[3], comment, packets, octets)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package firewall
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// NFTables metrics from `nft -j list ruleset`, counters of rules with a
+// comment and named counter objects matching the include/exclude regexes
+type NFTables struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	nftPath string
+	timeout time.Duration
+}
+
+// nftablesOptions defines what elements can be overridden in a config file
+type nftablesOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	NftPath      string `json:"nft_path" toml:"nft_path" yaml:"nft_path"`
+	Timeout      string `json:"timeout" toml:"timeout" yaml:"timeout"`
+}
+
+// nftRule a rule object of the json ruleset
+type nftRule struct {
+	Family  string                       `json:"family"`
+	Table   string                       `json:"table"`
+	Chain   string                       `json:"chain"`
+	Comment string                       `json:"comment"`
+	Expr    []map[string]json.RawMessage `json:"expr"`
+}
+
+// nftCounter an anonymous rule counter or a named counter object
+type nftCounter struct {
+	Family  string `json:"family"`
+	Table   string `json:"table"`
+	Name    string `json:"name"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+const defaultNftPath = "nft" // resolved using PATH
+
+// NewNFTablesCollector creates new nftables counter collector
+func NewNFTablesCollector(cfgBaseName string) (collector.Collector, error) {
+	c := NFTables{
+		common:  newCommon(NameNFTables, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+		nftPath: defaultNftPath,
+		timeout: defaultTimeout,
+	}
+
+	var opts nftablesOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	inc, exc, err := compileRegexes(c.pkgID, opts.IncludeRegex, opts.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+	c.include, c.exclude = inc, exc
+
+	if opts.NftPath != "" {
+		c.nftPath = opts.NftPath
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from nft
+func (c *NFTables) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := runCommand(cctx, c.nftPath, "-j", "list", "ruleset")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	rules, named, err := c.parseRuleset(out)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	c.addCounterMetrics(&metrics, "rule", "rule", rules)
+	c.addCounterMetrics(&metrics, "counter", "counter", named)
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// parseRuleset parses the json ruleset, returns the counters of rules with a
+// comment and the named counters matching the include/exclude regexes
+func (c *NFTables) parseRuleset(data []byte) (*counters, *counters, error) {
+	var ruleset struct {
+		Objects []map[string]json.RawMessage `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &ruleset); err != nil {
+		return nil, nil, errors.Wrap(err, "parsing nft ruleset")
+	}
+
+	rules := newCounters()
+	named := newCounters()
+	for _, obj := range ruleset.Objects {
+		if raw, ok := obj["rule"]; ok {
+			var r nftRule
+			if err := json.Unmarshal(raw, &r); err != nil {
+				continue
+			}
+			if r.Comment == "" || c.exclude.MatchString(r.Comment) || !c.include.MatchString(r.Comment) {
+				continue
+			}
+			for _, expr := range r.Expr {
+				craw, ok := expr["counter"]
+				if !ok {
+					continue
+				}
+				var ctr nftCounter
+				if err := json.Unmarshal(craw, &ctr); err != nil {
+					continue // reference to a named counter, e.g. "counter": "name"
+				}
+				rules.add(r.Family, r.Table, r.Chain, r.Comment, ctr.Packets, ctr.Bytes)
+			}
+			continue
+		}
+
+		if raw, ok := obj["counter"]; ok {
+			var ctr nftCounter
+			if err := json.Unmarshal(raw, &ctr); err != nil {
+				continue
+			}
+			if c.exclude.MatchString(ctr.Name) || !c.include.MatchString(ctr.Name) {
+				continue
+			}
+			named.add(ctr.Family, ctr.Table, "", ctr.Name, ctr.Packets, ctr.Bytes)
+		}
+	}
+
+	return rules, named, nil
+}
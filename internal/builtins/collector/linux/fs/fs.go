@@ -0,0 +1,103 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package fs builtin linux collectors for filesystems (quota usage and limits)
+package fs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "fs/"
+	PackageName     = "builtins.linux.fs"
+	NameQuota       = "quota"
+	regexPat        = `^(?:%s)$` // fmt pattern used compile include/exclude regular expressions
+)
+
+var (
+	defaultExcludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ""))
+	defaultIncludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ".+"))
+)
+
+// New creates new fs collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	ProcFSPath := viper.GetString(config.KeyHostProc)
+	if ProcFSPath == "" {
+		ProcFSPath = defaults.HostProc
+	}
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "fs_"+name+"_collector")
+		switch name {
+		case NameQuota:
+			c, err := NewQuotaCollector(cfgBase, ProcFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+// compileRegexes compiles the include/exclude options, empty options are the defaults
+func compileRegexes(pkgID, include, exclude string) (*regexp.Regexp, *regexp.Regexp, error) {
+	inc, exc := defaultIncludeRegex, defaultExcludeRegex
+	if include != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, include))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling include regex", pkgID)
+		}
+		inc = rx
+	}
+	if exclude != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, exclude))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling exclude regex", pkgID)
+		}
+		exc = rx
+	}
+	return inc, exc, nil
+}
@@ -0,0 +1,118 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package fs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/unix"
+)
+
+
+func TestQuotaCollect(t *testing.T) {
+	t.Log("Testing Quota Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	// user quotas for ids 0 and 1001 on /dev/sdb1, no group quotas, a project
+	// quota for id 10, no quotas enabled on /dev/sda1
+	quotas := map[string]map[int][]nextDqblk{
+		"/dev/sdb1": {
+			0: {
+				{CurSpace: 4096, CurInodes: 3, ID: 0},
+				{BHardLimit: 1048576, BSoftLimit: 524288, CurSpace: 1 << 20, IHardLimit: 1000, ISoftLimit: 500, CurInodes: 42, ID: 1001},
+			},
+			2: {
+				{BHardLimit: 2048, CurSpace: 8192, CurInodes: 2, ID: 10},
+			},
+		},
+	}
+	defer func(fn func(string, int, uint32) (*nextDqblk, error)) { getNextQuota = fn }(getNextQuota)
+	getNextQuota = func(device string, quotaType int, id uint32) (*nextDqblk, error) {
+		dev, ok := quotas[device]
+		if !ok {
+			return nil, unix.ESRCH
+		}
+		qt, ok := dev[quotaType]
+		if !ok {
+			return nil, unix.ESRCH
+		}
+		for i := range qt {
+			if qt[i].ID >= id {
+				return &qt[i], nil
+			}
+		}
+		return nil, unix.ENOENT
+	}
+
+	c, err := NewQuotaCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "proc"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "space_used", "mount:/srv/home", "quota-type:user", "id:1001", "units:bytes"); !ok || m.Value.(uint64) != 1<<20 {
+		t.Fatalf("expected space_used %d, got %v", 1<<20, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "space_hard_limit", "quota-type:user", "id:1001"); !ok || m.Value.(uint64) != 1<<30 {
+		t.Fatalf("expected space_hard_limit %d, got %v", 1<<30, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "inodes_used", "quota-type:user", "id:0", "units:inodes"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected inodes_used 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "space_hard_limit", "quota-type:project", "id:10"); !ok || m.Value.(uint64) != 2048*1024 {
+		t.Fatalf("expected space_hard_limit %d, got %v", 2048*1024, m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "space_used", "quota-type:group"); ok {
+		t.Fatal("expected no group quotas")
+	}
+	if _, ok := testutil.FindMetric(metrics, "space_used", "mount:/var/lib/bind mount"); ok {
+		t.Fatal("expected bind mount to be skipped")
+	}
+
+	t.Log("\tmax ids")
+	{
+		c.(*Quota).maxIDs = 1
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if _, ok := testutil.FindMetric(metrics, "space_used", "quota-type:user", "id:1001"); ok {
+			t.Fatal("expected ids to be truncated")
+		}
+	}
+
+	t.Log("\tno mounts")
+	{
+		c, err := NewQuotaCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	t.Log("Testing unescapeMountPath")
+
+	if p := unescapeMountPath(`/mnt/a\040b\011c`); p != "/mnt/a b\tc" {
+		t.Fatalf("unexpected path (%q)", p)
+	}
+	if p := unescapeMountPath(`/mnt/x\`); p != `/mnt/x\` {
+		t.Fatalf("unexpected path (%q)", p)
+	}
+}
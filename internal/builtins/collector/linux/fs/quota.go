@@ -0,0 +1,261 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package fs
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Quota metrics, per user, group and project quota usage and limits on the
+// filesystems with quotas enabled (XFS, ext4) from quotactl
+type Quota struct {
+	common
+	include    *regexp.Regexp
+	exclude    *regexp.Regexp
+	quotaTypes []string
+	maxIDs     int
+}
+
+// quotaOptions defines what elements can be overridden in a config file
+type quotaOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string   `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string   `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	QuotaTypes   []string `json:"quota_types" toml:"quota_types" yaml:"quota_types"`
+	MaxIDs       string   `json:"max_ids" toml:"max_ids" yaml:"max_ids"`
+}
+
+// nextDqblk is the kernel's struct if_nextdqblk (Q_GETNEXTQUOTA)
+type nextDqblk struct {
+	BHardLimit uint64 // blocks of qiBlockSize bytes
+	BSoftLimit uint64
+	CurSpace   uint64 // bytes
+	IHardLimit uint64
+	ISoftLimit uint64
+	CurInodes  uint64
+	BTime      uint64
+	ITime      uint64
+	Valid      uint32
+	ID         uint32
+}
+
+const (
+	qGetNextQuota = 0x800009 // Q_GETNEXTQUOTA, kernel 4.6+
+	qiBlockSize   = 1024     // QIF_DQBLKSIZE
+	defaultMaxIDs = 1000
+)
+
+// quotaTypeIDs map the quota types to the quotactl types (USRQUOTA, GRPQUOTA, PRJQUOTA)
+var quotaTypeIDs = map[string]int{
+	"user":    0,
+	"group":   1,
+	"project": 2,
+}
+
+// quotaFSTypes are the filesystems checked for quotas
+var quotaFSTypes = map[string]bool{
+	"ext3": true,
+	"ext4": true,
+	"xfs":  true,
+}
+
+// getNextQuota returns the quota of the first id >= id with a quota on the
+// device, unix.ENOENT when there are no more ids, unix.ESRCH when quotas of
+// the type are not enabled
+var getNextQuota = func(device string, quotaType int, id uint32) (*nextDqblk, error) {
+	dev, err := unix.BytePtrFromString(device)
+	if err != nil {
+		return nil, err
+	}
+	var dq nextDqblk
+	cmd := qGetNextQuota<<8 | quotaType&0xff
+	_, _, errno := unix.Syscall6(unix.SYS_QUOTACTL, uintptr(cmd), uintptr(unsafe.Pointer(dev)), uintptr(id), uintptr(unsafe.Pointer(&dq)), 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return &dq, nil
+}
+
+// NewQuotaCollector creates new fs quota collector
+func NewQuotaCollector(cfgBaseName, procFSPath string) (collector.Collector, error) {
+	c := Quota{
+		common:     newCommon(NameQuota, procFSPath, "", tags.FromList(tags.GetBaseTags())),
+		include:    defaultIncludeRegex,
+		exclude:    defaultExcludeRegex,
+		quotaTypes: []string{"user", "group", "project"},
+		maxIDs:     defaultMaxIDs,
+	}
+
+	var opts quotaOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	inc, exc, err := compileRegexes(c.pkgID, opts.IncludeRegex, opts.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+	c.include, c.exclude = inc, exc
+
+	if len(opts.QuotaTypes) > 0 {
+		for _, qt := range opts.QuotaTypes {
+			if _, ok := quotaTypeIDs[qt]; !ok {
+				return nil, errors.Errorf("%s invalid quota type (%s)", c.pkgID, qt)
+			}
+		}
+		c.quotaTypes = opts.QuotaTypes
+	}
+
+	if opts.MaxIDs != "" {
+		n, err := strconv.Atoi(opts.MaxIDs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing max_ids", c.pkgID)
+		}
+		c.maxIDs = n
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from quotactl
+func (c *Quota) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	mounts, err := quotaMounts(filepath.Join(c.procFSPath, "mounts"))
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	for _, m := range mounts {
+		if c.exclude.MatchString(m.dir) || !c.include.MatchString(m.dir) {
+			continue
+		}
+		for _, qt := range c.quotaTypes {
+			if err := c.addQuotaMetrics(&metrics, m, qt); err != nil {
+				c.logger.Warn().Err(err).Str("mount", m.dir).Str("type", qt).Msg("reading quotas")
+			}
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// addQuotaMetrics adds the metrics for each id with a quota of the type on the mount
+func (c *Quota) addQuotaMetrics(metrics *cgm.Metrics, m mount, quotaType string) error {
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+	tagUnitsInodes := tags.Tag{Category: "units", Value: "inodes"}
+
+	id := uint32(0)
+	for n := 0; n < c.maxIDs; n++ {
+		dq, err := getNextQuota(m.device, quotaTypeIDs[quotaType], id)
+		if err != nil {
+			if err == unix.ENOENT || err == unix.ESRCH {
+				return nil // no more ids, or quotas of the type are not enabled
+			}
+			return err
+		}
+
+		tagList := tags.Tags{
+			tags.Tag{Category: "mount", Value: m.dir},
+			tags.Tag{Category: "quota-type", Value: quotaType},
+			tags.Tag{Category: "id", Value: strconv.FormatUint(uint64(dq.ID), 10)},
+		}
+		_ = c.addMetric(metrics, "", "space_used", "L", dq.CurSpace, append(tagList, tagUnitsBytes))
+		_ = c.addMetric(metrics, "", "space_soft_limit", "L", dq.BSoftLimit*qiBlockSize, append(tagList, tagUnitsBytes))
+		_ = c.addMetric(metrics, "", "space_hard_limit", "L", dq.BHardLimit*qiBlockSize, append(tagList, tagUnitsBytes))
+		_ = c.addMetric(metrics, "", "inodes_used", "L", dq.CurInodes, append(tagList, tagUnitsInodes))
+		_ = c.addMetric(metrics, "", "inodes_soft_limit", "L", dq.ISoftLimit, append(tagList, tagUnitsInodes))
+		_ = c.addMetric(metrics, "", "inodes_hard_limit", "L", dq.IHardLimit, append(tagList, tagUnitsInodes))
+
+		if dq.ID == ^uint32(0) {
+			return nil
+		}
+		id = dq.ID + 1
+	}
+
+	c.logger.Warn().Str("mount", m.dir).Str("type", quotaType).Int("max_ids", c.maxIDs).Msg("quota ids truncated")
+	return nil
+}
+
+// mount is a filesystem which may have quotas
+type mount struct {
+	device string
+	dir    string
+}
+
+// quotaMounts returns the mounted filesystems which support quotas
+func quotaMounts(file string) ([]mount, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	mounts := []mount{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// device dir type options dump pass
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || !quotaFSTypes[fields[2]] {
+			continue
+		}
+		// bind mounts of the same device
+		if seen[fields[0]] {
+			continue
+		}
+		seen[fields[0]] = true
+		mounts = append(mounts, mount{device: fields[0], dir: unescapeMountPath(fields[1])})
+	}
+
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (e.g. \040 space) in /proc/mounts paths
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
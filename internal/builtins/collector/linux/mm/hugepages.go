@@ -0,0 +1,106 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package mm
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// HugePages metrics from the sysfs huge page pools, one pool per supported
+// page size (e.g. 2048kB, 1048576kB)
+type HugePages struct {
+	common
+}
+
+// hugePagesOptions defines what elements can be overridden in a config file
+type hugePagesOptions struct {
+	commonOptions
+}
+
+const hugePagesDir = "kernel/mm/hugepages" // relative to sysfs
+
+var hugePagesDirRx = regexp.MustCompile(`^hugepages-([0-9]+kB)$`)
+
+// NewHugePagesCollector creates new mm hugepages collector
+func NewHugePagesCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := HugePages{
+		common: newCommon(NameHugePages, sysFSPath, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts hugePagesOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *HugePages) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	poolsDir := filepath.Join(c.sysFSPath, hugePagesDir)
+	entries, err := ioutil.ReadDir(poolsDir)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+
+	for _, entry := range entries {
+		m := hugePagesDirRx.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		dir := filepath.Join(poolsDir, entry.Name())
+		tagList := tags.Tags{tags.Tag{Category: "size", Value: m[1]}, tagUnitsPages}
+
+		total, totalOK := readUint(filepath.Join(dir, "nr_hugepages"))
+		free, freeOK := readUint(filepath.Join(dir, "free_hugepages"))
+		if totalOK {
+			_ = c.addMetric(&metrics, "", "pages_total", "L", total, tagList)
+		}
+		if freeOK {
+			_ = c.addMetric(&metrics, "", "pages_free", "L", free, tagList)
+		}
+		if totalOK && freeOK && total >= free {
+			_ = c.addMetric(&metrics, "", "pages_used", "L", total-free, tagList)
+		}
+		// reserved, allocation committed but not yet faulted in
+		if v, ok := readUint(filepath.Join(dir, "resv_hugepages")); ok {
+			_ = c.addMetric(&metrics, "", "pages_reserved", "L", v, tagList)
+		}
+		// surplus, allocated beyond nr_hugepages (up to nr_overcommit_hugepages)
+		if v, ok := readUint(filepath.Join(dir, "surplus_hugepages")); ok {
+			_ = c.addMetric(&metrics, "", "pages_surplus", "L", v, tagList)
+		}
+		if v, ok := readUint(filepath.Join(dir, "nr_overcommit_hugepages")); ok {
+			_ = c.addMetric(&metrics, "", "pages_overcommit", "L", v, tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
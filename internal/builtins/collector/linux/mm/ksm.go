@@ -0,0 +1,96 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package mm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// KSM metrics from sysfs kernel samepage merging (pages shared between
+// processes e.g. virtual machines with identical memory pages)
+type KSM struct {
+	common
+	pageSize uint64
+}
+
+// ksmOptions defines what elements can be overridden in a config file
+type ksmOptions struct {
+	commonOptions
+}
+
+const ksmDir = "kernel/mm/ksm" // relative to sysfs
+
+// NewKSMCollector creates new mm ksm collector
+func NewKSMCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := KSM{
+		common:   newCommon(NameKSM, sysFSPath, tags.FromList(tags.GetBaseTags())),
+		pageSize: uint64(os.Getpagesize()),
+	}
+
+	var opts ksmOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *KSM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(c.sysFSPath, ksmDir)
+	if _, err := os.Stat(dir); err != nil {
+		// kernel built without CONFIG_KSM
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+
+	// run: 0 stopped, 1 running, 2 stopped and all merged pages unmerged
+	if v, ok := readUint(filepath.Join(dir, "run")); ok {
+		_ = c.addMetric(&metrics, "", "run", "L", v, tags.Tags{})
+	}
+
+	pages := []string{
+		"pages_shared",   // shared pages in use
+		"pages_sharing",  // sites sharing them, i.e. pages saved
+		"pages_unshared", // unique pages repeatedly checked for merging
+		"pages_volatile", // pages changing too fast to be merged
+	}
+	for _, name := range pages {
+		if v, ok := readUint(filepath.Join(dir, name)); ok {
+			_ = c.addMetric(&metrics, "", name, "L", v, tags.Tags{tagUnitsPages})
+		}
+	}
+	if v, ok := readUint(filepath.Join(dir, "pages_sharing")); ok {
+		_ = c.addMetric(&metrics, "", "saved", "L", v*c.pageSize, tags.Tags{tags.Tag{Category: "units", Value: "bytes"}})
+	}
+	if v, ok := readUint(filepath.Join(dir, "full_scans")); ok {
+		_ = c.addMetric(&metrics, "", "full_scans", "L", v, tags.Tags{tags.Tag{Category: "units", Value: "scans"}})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
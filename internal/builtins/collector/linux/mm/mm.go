@@ -0,0 +1,123 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package mm builtin linux collectors for kernel memory management (huge page
+// pools, kernel samepage merging, swap activity, zswap and zram) from sysfs
+// and procfs
+package mm
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "mm/"
+	PackageName     = "builtins.linux.mm"
+	NameHugePages   = "hugepages"
+	NameKSM         = "ksm"
+	NameSwap        = "swap"
+	NameZRAM        = "zram"
+)
+
+// New creates new mm collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	ProcFSPath := viper.GetString(config.KeyHostProc)
+	if ProcFSPath == "" {
+		ProcFSPath = defaults.HostProc
+	}
+
+	SysFSPath := viper.GetString(config.KeyHostSys)
+	if SysFSPath == "" {
+		SysFSPath = defaults.HostSys
+	}
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "mm_"+name+"_collector")
+		switch name {
+		case NameHugePages:
+			c, err := NewHugePagesCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameKSM:
+			c, err := NewKSMCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameSwap:
+			c, err := NewSwapCollector(cfgBase, ProcFSPath, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameZRAM:
+			c, err := NewZRAMCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+// readUint reads a sysfs file containing a single unsigned integer
+func readUint(file string) (uint64, bool) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
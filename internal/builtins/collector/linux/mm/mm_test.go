@@ -0,0 +1,195 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package mm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestHugePagesCollect(t *testing.T) {
+	t.Log("Testing HugePages Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewHugePagesCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "pages_total", "size:2048kB"); !ok || m.Value.(uint64) != 512 {
+		t.Fatalf("expected 2048kB pages_total 512, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "pages_used", "size:2048kB"); !ok || m.Value.(uint64) != 384 {
+		t.Fatalf("expected 2048kB pages_used 384, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "pages_surplus", "size:2048kB"); !ok || m.Value.(uint64) != 4 {
+		t.Fatalf("expected 2048kB pages_surplus 4, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "pages_free", "size:1048576kB"); !ok || m.Value.(uint64) != 4 {
+		t.Fatalf("expected 1048576kB pages_free 4, got %v", m.Value)
+	}
+	if len(metrics) != 12 {
+		t.Fatalf("expected 12 metrics, got %d", len(metrics))
+	}
+
+	t.Log("\tno sysfs")
+	{
+		c, err := NewHugePagesCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestKSMCollect(t *testing.T) {
+	t.Log("Testing KSM Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewKSMCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "run"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected run 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "pages_sharing", "units:pages"); !ok || m.Value.(uint64) != 9600 {
+		t.Fatalf("expected pages_sharing 9600, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "saved", "units:bytes"); !ok || m.Value.(uint64) != 9600*uint64(os.Getpagesize()) {
+		t.Fatalf("expected saved %d, got %v", 9600*os.Getpagesize(), m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "full_scans"); !ok || m.Value.(uint64) != 42 {
+		t.Fatalf("expected full_scans 42, got %v", m.Value)
+	}
+
+	t.Log("\tno ksm")
+	{
+		c, err := NewKSMCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestSwapCollect(t *testing.T) {
+	t.Log("Testing Swap Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewSwapCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "proc"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "pswpout", "units:pages"); !ok || m.Value.(uint64) != 300 {
+		t.Fatalf("expected pswpout 300, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "pswpout_persec"); ok {
+		t.Fatal("expected no rate on the first collection")
+	}
+	if m, ok := testutil.FindMetric(metrics, "zswap_enabled"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected zswap_enabled 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "zswap_pool_size", "units:bytes"); !ok || m.Value.(uint64) != 4194304 {
+		t.Fatalf("expected zswap_pool_size 4194304, got %v", m.Value)
+	}
+	stored := 3072 * uint64(os.Getpagesize())
+	if m, ok := testutil.FindMetric(metrics, "zswap_stored", "units:bytes"); !ok || m.Value.(uint64) != stored {
+		t.Fatalf("expected zswap_stored %d, got %v", stored, m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "zswap_written_back_pages"); !ok || m.Value.(uint64) != 17 {
+		t.Fatalf("expected zswap_written_back_pages 17, got %v", m.Value)
+	}
+
+	t.Log("\trates")
+	{
+		s := c.(*Swap)
+		s.lastCounts["pswpout"] = 200
+		s.lastTime = s.lastTime.Add(-10 * time.Second)
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		m, ok := testutil.FindMetric(metrics, "pswpout_persec", "units:pages")
+		if !ok || m.Value.(float64) < 9 || m.Value.(float64) > 10 {
+			t.Fatalf("expected pswpout_persec ~10, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, "pswpin_persec"); !ok || m.Value.(float64) != 0 {
+			t.Fatalf("expected pswpin_persec 0, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tno vmstat")
+	{
+		c, err := NewSwapCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestZRAMCollect(t *testing.T) {
+	t.Log("Testing ZRAM Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewZRAMCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "orig_data_size", "device:zram0", "units:bytes"); !ok || m.Value.(uint64) != 1048576 {
+		t.Fatalf("expected orig_data_size 1048576, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "huge_pages", "device:zram0", "units:pages"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected huge_pages 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "compression_ratio", "device:zram0"); !ok || m.Value.(float64) != 4 {
+		t.Fatalf("expected compression_ratio 4, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "notify_free", "device:zram0"); !ok || m.Value.(uint64) != 42 {
+		t.Fatalf("expected notify_free 42, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "disk_size", "device:sda"); ok {
+		t.Fatal("expected only zram devices")
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package mm
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Swap metrics from procfs vmstat (pages swapped in and out) and, when the
+// zswap compressed swap cache is available, the zswap pool from sysfs,
+// debugfs and procfs meminfo
+type Swap struct {
+	common
+	procFSPath string
+	pageSize   uint64
+	lastCounts map[string]uint64 // vmstat counters at the last collection
+	lastTime   time.Time
+}
+
+// swapOptions defines what elements can be overridden in a config file
+type swapOptions struct {
+	commonOptions
+}
+
+const (
+	zswapParamsDir  = "module/zswap/parameters" // relative to sysfs
+	zswapDebugFSDir = "kernel/debug/zswap"      // relative to sysfs, requires root
+)
+
+// swapCounters are the vmstat swap activity counters, in pages
+var swapCounters = []string{
+	"pswpin",  // pages swapped in
+	"pswpout", // pages swapped out
+	"zswpin",  // pages loaded from zswap
+	"zswpout", // pages stored in zswap
+}
+
+// zswapDebugCounters are the zswap debugfs counters
+var zswapDebugCounters = []string{
+	"pool_limit_hit",
+	"reject_alloc_fail",
+	"reject_compress_poor",
+	"reject_kmemcache_fail",
+	"reject_reclaim_fail",
+	"written_back_pages",
+}
+
+// NewSwapCollector creates new mm swap collector
+func NewSwapCollector(cfgBaseName, procFSPath, sysFSPath string) (collector.Collector, error) {
+	c := Swap{
+		common:     newCommon(NameSwap, sysFSPath, tags.FromList(tags.GetBaseTags())),
+		procFSPath: procFSPath,
+		pageSize:   uint64(os.Getpagesize()),
+		lastCounts: make(map[string]uint64),
+	}
+
+	var opts swapOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from procfs and sysfs
+func (c *Swap) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	vmstat, err := readKeyValues(filepath.Join(c.procFSPath, "vmstat"))
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsPages := tags.Tag{Category: "units", Value: "pages"}
+
+	// counters, and rates since the last collection
+	now := time.Now()
+	elapsed := now.Sub(c.lastTime).Seconds()
+	for _, name := range swapCounters {
+		v, ok := vmstat[name]
+		if !ok {
+			continue
+		}
+		_ = c.addMetric(&metrics, "", name, "L", v, tags.Tags{tagUnitsPages})
+		if last, ok := c.lastCounts[name]; ok && v >= last && elapsed > 0 {
+			_ = c.addMetric(&metrics, "", name+"_persec", "n", float64(v-last)/elapsed, tags.Tags{tagUnitsPages})
+		}
+		c.lastCounts[name] = v
+	}
+	c.lastTime = now
+
+	c.addZswapMetrics(&metrics)
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// addZswapMetrics adds the zswap pool metrics, if zswap is available
+func (c *Swap) addZswapMetrics(metrics *cgm.Metrics) {
+	enabled, err := ioutil.ReadFile(filepath.Join(c.sysFSPath, zswapParamsDir, "enabled"))
+	if err != nil {
+		return // kernel built without zswap
+	}
+	v := 0
+	if strings.TrimSpace(string(enabled)) == "Y" {
+		v = 1
+	}
+	_ = c.addMetric(metrics, "", "zswap_enabled", "I", v, tags.Tags{})
+
+	tagUnitsBytes := tags.Tag{Category: "units", Value: "bytes"}
+
+	// pool size (compressed) and stored (uncompressed) from debugfs, the
+	// meminfo Zswap and Zswapped fields (kernel 5.19+) do not require root
+	debugDir := filepath.Join(c.sysFSPath, zswapDebugFSDir)
+	poolSize, poolOK := readUint(filepath.Join(debugDir, "pool_total_size"))
+	stored, storedOK := readUint(filepath.Join(debugDir, "stored_pages"))
+	stored *= c.pageSize
+	if !poolOK || !storedOK {
+		if meminfo, err := readKeyValues(filepath.Join(c.procFSPath, "meminfo")); err == nil {
+			poolSize, poolOK = meminfo["Zswap:"]
+			stored, storedOK = meminfo["Zswapped:"]
+			poolSize *= 1024 // kB
+			stored *= 1024
+		}
+	}
+	if poolOK {
+		_ = c.addMetric(metrics, "", "zswap_pool_size", "L", poolSize, tags.Tags{tagUnitsBytes})
+	}
+	if storedOK {
+		_ = c.addMetric(metrics, "", "zswap_stored", "L", stored, tags.Tags{tagUnitsBytes})
+	}
+	if poolOK && storedOK && poolSize > 0 {
+		_ = c.addMetric(metrics, "", "zswap_compression_ratio", "n", float64(stored)/float64(poolSize), tags.Tags{})
+	}
+
+	for _, name := range zswapDebugCounters {
+		if v, ok := readUint(filepath.Join(debugDir, name)); ok {
+			_ = c.addMetric(metrics, "", "zswap_"+name, "L", v, tags.Tags{})
+		}
+	}
+}
+
+// readKeyValues reads a procfs file of "name value [kB]" lines (e.g. vmstat
+// or meminfo), names are as in the file (meminfo names include the colon)
+func readKeyValues(file string) (map[string]uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = v
+	}
+
+	return values, scanner.Err()
+}
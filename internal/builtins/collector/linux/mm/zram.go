@@ -0,0 +1,146 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package mm
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// ZRAM metrics from the sysfs zram block devices (compressed ram disks,
+// commonly used for swap)
+type ZRAM struct {
+	common
+}
+
+// zramOptions defines what elements can be overridden in a config file
+type zramOptions struct {
+	commonOptions
+}
+
+const blockDir = "block" // relative to sysfs
+
+// zramMMStat fields of the zram mm_stat file, in order
+var zramMMStat = []struct {
+	name  string
+	units string
+}{
+	{"orig_data_size", "bytes"},  // uncompressed size of the data stored
+	{"compr_data_size", "bytes"}, // compressed size of the data stored
+	{"mem_used_total", "bytes"},  // memory allocated, including fragmentation and metadata
+	{"mem_limit", "bytes"},       // 0 no limit
+	{"mem_used_max", "bytes"},
+	{"same_pages", "pages"}, // same element filled pages, no memory allocated
+	{"pages_compacted", "pages"},
+	{"huge_pages", "pages"}, // incompressible pages
+}
+
+// zramIOStat fields of the zram io_stat file, in order
+var zramIOStat = []string{
+	"failed_reads",
+	"failed_writes",
+	"invalid_io",
+	"notify_free",
+}
+
+// NewZRAMCollector creates new mm zram collector
+func NewZRAMCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := ZRAM{
+		common: newCommon(NameZRAM, sysFSPath, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts zramOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *ZRAM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	devDir := filepath.Join(c.sysFSPath, blockDir)
+	entries, err := ioutil.ReadDir(devDir)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "zram") {
+			continue
+		}
+		dir := filepath.Join(devDir, entry.Name())
+		tagDevice := tags.Tag{Category: "device", Value: entry.Name()}
+
+		// 0 when the device is not initialized (reset)
+		if v, ok := readUint(filepath.Join(dir, "disksize")); ok {
+			_ = c.addMetric(&metrics, "", "disk_size", "L", v, tags.Tags{tagDevice, tags.Tag{Category: "units", Value: "bytes"}})
+		}
+
+		if stats, ok := readFields(filepath.Join(dir, "mm_stat")); ok {
+			for i, f := range zramMMStat {
+				if i >= len(stats) {
+					break
+				}
+				_ = c.addMetric(&metrics, "", f.name, "L", stats[i], tags.Tags{tagDevice, tags.Tag{Category: "units", Value: f.units}})
+			}
+			if len(stats) > 1 && stats[1] > 0 {
+				_ = c.addMetric(&metrics, "", "compression_ratio", "n", float64(stats[0])/float64(stats[1]), tags.Tags{tagDevice})
+			}
+		}
+
+		if stats, ok := readFields(filepath.Join(dir, "io_stat")); ok {
+			for i, name := range zramIOStat {
+				if i >= len(stats) {
+					break
+				}
+				_ = c.addMetric(&metrics, "", name, "L", stats[i], tags.Tags{tagDevice})
+			}
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// readFields reads a sysfs file containing a single line of unsigned integers
+func readFields(file string) ([]uint64, bool) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	fields := strings.Fields(string(data))
+	values := make([]uint64, 0, len(fields))
+	for _, f := range fields {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	return values, true
+}
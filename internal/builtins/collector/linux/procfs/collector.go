@@ -136,15 +136,15 @@ func (c *common) addMetric(metrics *cgm.Metrics, prefix string, mname, mtype str
 		metricName = prefix + defaults.MetricNameSeparator + mname
 	}
 
-	var tagList tags.Tags
-	tagList = append(tagList, c.baseTags...)
-	tagList = append(tagList, tags.Tags{
+	tagList := tags.GetTags()
+	defer tags.PutTags(tagList)
+	*tagList = append(*tagList, c.baseTags...)
+	*tagList = append(*tagList,
 		tags.Tag{Category: "source", Value: release.NAME},
-		tags.Tag{Category: "collector", Value: c.id},
-	}...)
-	tagList = append(tagList, mtags...)
+		tags.Tag{Category: "collector", Value: c.id})
+	*tagList = append(*tagList, mtags...)
 
-	metricName = tags.MetricNameWithStreamTags(metricName, tagList)
+	metricName = tags.MetricNameWithStreamTags(metricName, *tagList)
 	(*metrics)[metricName] = cgm.Metric{Type: mtype, Value: mval}
 
 	return nil
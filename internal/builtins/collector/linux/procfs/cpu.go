@@ -26,7 +26,7 @@ import (
 // CPU metrics from the Linux ProcFS
 type CPU struct {
 	common
-	numCPU        float64               // number of cpus
+	numCPU        float64               // number of online cpus, from the last collection
 	clockNorm     float64               // cpu clock normalized to 100Hz tick rate
 	reportAllCPUs bool                  // OPT report all cpus (vs just total) may be overridden in config file
 	lastRunValues map[string]lastValues // values from last run
@@ -155,10 +155,22 @@ func (c *CPU) Collect(ctx context.Context) error {
 		return errors.Wrap(err, c.pkgID)
 	}
 
-	_ = c.addMetric(&metrics, "", "num_cpu", "I", runtime.NumCPU(), tags.Tags{})
+	// cpus can be brought on/offline at runtime (e.g. smt mode changes and
+	// dynamic lpar on POWER) and runtime.NumCPU is limited to the agent's
+	// affinity mask, use the cpus currently listed in /proc/stat
+	numCPU := onlineCPUs(lines)
+	if numCPU == 0 {
+		numCPU = runtime.NumCPU()
+	}
+	c.numCPU = float64(numCPU)
+
+	_ = c.addMetric(&metrics, "", "num_cpu", "I", numCPU, tags.Tags{})
 
 	for _, line := range lines {
 		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
 
 		switch fields[0] {
 		case "processes":
@@ -220,6 +232,17 @@ func (c *CPU) Collect(ctx context.Context) error {
 	return nil
 }
 
+// onlineCPUs counts the individual cpu lines (cpuN) in /proc/stat
+func onlineCPUs(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if len(line) > 3 && strings.HasPrefix(line, "cpu") && line[3] >= '0' && line[3] <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
 func (c *CPU) parseCPU(fields []string) (string, *cgm.Metrics, error) {
 	var numCPU float64
 	var cpuID string
@@ -256,3 +256,30 @@ func TestCPUCollect(t *testing.T) {
 		}
 	}
 }
+
+func TestOnlineCPUs(t *testing.T) {
+	t.Log("Testing onlineCPUs")
+
+	tt := []struct {
+		name   string
+		lines  []string
+		expect int
+	}{
+		{"none", []string{"intr 1 2 3", "ctxt 123"}, 0},
+		{"aggregate only", []string{"cpu  85 0 206 1961 104 0 5 0 0 0"}, 0},
+		{"smt offline", []string{
+			"cpu  85 0 206 1961 104 0 5 0 0 0",
+			"cpu0 85 0 206 1961 104 0 5 0 0 0",
+			"cpu8 85 0 206 1961 104 0 5 0 0 0",
+			"cpu16 85 0 206 1961 104 0 5 0 0 0",
+			"intr 15313 136 10 0",
+		}, 3},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s", tst.name)
+		if n := onlineCPUs(tst.lines); n != tst.expect {
+			t.Fatalf("expected %d, got %d", tst.expect, n)
+		}
+	}
+}
@@ -10,9 +10,7 @@ package procfs
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -21,20 +19,17 @@ import (
 
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
 	"github.com/circonus-labs/circonus-agent/internal/config"
-	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/pkg/errors"
-	"github.com/spf13/viper"
 )
 
 // Disk metrics from the Linux ProcFS
 type Disk struct {
 	common
-	include           *regexp.Regexp
-	exclude           *regexp.Regexp
-	sectorSizeDefault uint64
-	sectorSizeCache   map[string]uint64
+	include    *regexp.Regexp
+	exclude    *regexp.Regexp
+	sectorSize uint64 // size of the /proc/diskstats sector unit
 }
 
 // diskOptions defines what elements can be overridden in a config file
@@ -80,10 +75,11 @@ func NewDiskCollector(cfgBaseName, procFSPath string) (collector.Collector, erro
 		common: newCommon(NameDisk, procFSPath, procFile, tags.FromList(tags.GetBaseTags())),
 	}
 
-	c.sectorSizeCache = make(map[string]uint64)
 	c.include = defaultIncludeRegex
 	c.exclude = defaultExcludeRegex
-	c.sectorSizeDefault = 512
+	// diskstats counts 512 byte sectors regardless of the logical or
+	// physical block size of the device (e.g. 4k native disks)
+	c.sectorSize = 512
 
 	if cfgBaseName == "" {
 		if _, err := os.Stat(c.file); os.IsNotExist(err) {
@@ -124,7 +120,7 @@ func NewDiskCollector(cfgBaseName, procFSPath string) (collector.Collector, erro
 		if err != nil {
 			return nil, errors.Wrapf(err, "%s parsing default sector size", c.pkgID)
 		}
-		c.sectorSizeDefault = v
+		c.sectorSize = v
 	}
 
 	if opts.ID != "" {
@@ -301,36 +297,6 @@ func (c *Disk) Collect(ctx context.Context) error {
 	return nil
 }
 
-func (c *Disk) getSectorSize(dev string) uint64 {
-	if sz, have := c.sectorSizeCache[dev]; have {
-		return sz
-	}
-
-	sysFSPath := viper.GetString(config.KeyHostSys)
-	if sysFSPath == "" {
-		sysFSPath = defaults.HostSys
-	}
-	fn := path.Join(string(os.PathSeparator), sysFSPath, "block", dev, "queue", "physical_block_size")
-
-	c.logger.Debug().Str("fn", fn).Msg("checking for sector size")
-
-	data, err := ioutil.ReadFile(fn)
-	if err != nil {
-		c.logger.Debug().Err(err).Str("device", dev).Msg("reading block size, using default")
-		c.sectorSizeCache[dev] = c.sectorSizeDefault
-		return c.sectorSizeDefault
-	}
-	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
-	if err != nil {
-		c.logger.Debug().Err(err).Str("device", dev).Str("block_size", string(data)).Msg("parsing block size, using default")
-		c.sectorSizeCache[dev] = c.sectorSizeDefault
-		return c.sectorSizeDefault
-	}
-
-	c.sectorSizeCache[dev] = v
-	return v
-}
-
 func (c *Disk) parse(fields []string) (*dstats, error) {
 	devName := fields[2]
 	if devName == "" {
@@ -338,7 +304,7 @@ func (c *Disk) parse(fields []string) (*dstats, error) {
 		return nil, errors.New("invalid device name (empty)")
 	}
 
-	sectorSz := c.getSectorSize(devName)
+	sectorSz := c.sectorSize
 
 	pe := errors.New("parsing field")
 	d := dstats{
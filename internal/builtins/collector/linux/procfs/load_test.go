@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
 	"github.com/rs/zerolog"
 )
@@ -206,3 +207,29 @@ func TestLoadCollect(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadCollectRecorded(t *testing.T) {
+	t.Log("Testing Collect (recorded procfs)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	procFS, cleanup := testutil.ProcFSTree(t, map[string]string{
+		"loadavg": "1.50 0.75 0.25 3/512 4242\n",
+		"stat":    "cpu  1 2 3 4 5 6 7 0 0 0\nctxt 987654\nprocesses 4242\nprocs_running 3\nprocs_blocked 1\n",
+	})
+	defer cleanup()
+
+	c, err := NewLoadCollector("", procFS)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := testutil.Collect(t, c)
+
+	testutil.AssertMetric(t, metrics, "load_1min", "n", 1.5, "units:processes")
+	testutil.AssertMetric(t, metrics, "load_15min", "n", 0.25)
+	testutil.AssertMetric(t, metrics, "total", "l", 4242)
+	testutil.AssertMetric(t, metrics, "running", "l", 3)
+	testutil.AssertMetric(t, metrics, "blocked", "l", 1)
+	testutil.AssertMetric(t, metrics, "ctxt", "l", 987654, "units:switches")
+}
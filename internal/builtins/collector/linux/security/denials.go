@@ -0,0 +1,225 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package security
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Denials counts SELinux AVC denials and AppArmor denials logged by the
+// kernel audit subsystem (the auditd log or, without auditd, the kernel log).
+// Counters are cumulative from the start of the agent, the log is followed
+// across rotation.
+type Denials struct {
+	common
+	logs     []*denialLog
+	selinux  map[selinuxKey]uint64
+	apparmor map[string]uint64
+}
+
+// denialsOptions defines what elements can be overridden in a config file
+type denialsOptions struct {
+	commonOptions
+
+	// collector specific
+	LogFiles []string `json:"log_files" toml:"log_files" yaml:"log_files"`
+}
+
+// denialLog a log file followed by the collector
+type denialLog struct {
+	file   string
+	inode  uint64
+	offset int64
+}
+
+type selinuxKey struct {
+	domain string // source type (scontext) e.g. httpd_t
+	class  string // target class (tclass) e.g. file
+}
+
+const (
+	auditLog        = "log/audit/audit.log" // relative to host var
+	maxDenialsRead  = 16 * 1024 * 1024      // bytes read from a log per collection
+	selinuxDenied   = "avc:  denied"
+	apparmorDenied  = `apparmor="DENIED"`
+	unknownTagValue = "unknown"
+)
+
+// NewDenialsCollector creates new security denials collector
+func NewDenialsCollector(cfgBaseName, varPath string) (collector.Collector, error) {
+	c := Denials{
+		common:   newCommon(NameDenials, "", "", tags.FromList(tags.GetBaseTags())),
+		selinux:  make(map[selinuxKey]uint64),
+		apparmor: make(map[string]uint64),
+	}
+
+	var opts denialsOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	files := opts.LogFiles
+	if len(files) == 0 {
+		files = []string{filepath.Join(varPath, auditLog)}
+	}
+	for _, file := range files {
+		// only denials logged after the agent starts are counted
+		l := &denialLog{file: file}
+		if fi, err := os.Stat(file); err == nil {
+			l.inode = inode(fi)
+			l.offset = fi.Size()
+		} else {
+			c.logger.Warn().Err(err).Str("file", file).Msg("denials log, will retry")
+		}
+		c.logs = append(c.logs, l)
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the audit log(s)
+func (c *Denials) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, l := range c.logs {
+		if err := c.readLog(l); err != nil {
+			c.logger.Warn().Err(err).Str("file", l.file).Msg("reading denials log")
+			lastErr = err
+		}
+	}
+	if lastErr != nil && len(c.logs) == 1 {
+		c.setStatus(metrics, lastErr)
+		return errors.Wrap(lastErr, c.pkgID)
+	}
+
+	for k, n := range c.selinux {
+		_ = c.addMetric(&metrics, "", "selinux_denials", "L", n, tags.Tags{
+			tags.Tag{Category: "domain", Value: k.domain},
+			tags.Tag{Category: "class", Value: k.class},
+		})
+	}
+	for profile, n := range c.apparmor {
+		_ = c.addMetric(&metrics, "", "apparmor_denials", "L", n, tags.Tags{
+			tags.Tag{Category: "profile", Value: profile},
+		})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// readLog counts the denials logged since the last read, a rotated log (new
+// inode or truncated) is read from the start
+func (c *Denials) readLog(l *denialLog) error {
+	f, err := os.Open(l.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if ino := inode(fi); ino != l.inode || fi.Size() < l.offset {
+		l.inode = ino
+		l.offset = 0
+	}
+	if fi.Size() == l.offset {
+		return nil
+	}
+
+	if _, err := f.Seek(l.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(io.LimitReader(f, maxDenialsRead))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			// partial line, read again on the next collection
+			break
+		}
+		l.offset += int64(len(line))
+		c.countDenial(line)
+	}
+
+	return nil
+}
+
+// countDenial counts the line if it is a selinux or apparmor denial
+func (c *Denials) countDenial(line string) {
+	switch {
+	case strings.Contains(line, selinuxDenied):
+		domain := unknownTagValue
+		if ctx := auditField(line, "scontext"); ctx != "" {
+			// user:role:type:level
+			if parts := strings.Split(ctx, ":"); len(parts) > 2 {
+				domain = parts[2]
+			}
+		}
+		class := auditField(line, "tclass")
+		if class == "" {
+			class = unknownTagValue
+		}
+		c.selinux[selinuxKey{domain: domain, class: class}]++
+	case strings.Contains(line, apparmorDenied):
+		profile := auditField(line, "profile")
+		if profile == "" {
+			profile = unknownTagValue
+		}
+		c.apparmor[profile]++
+	}
+}
+
+// auditField returns the value of a name=value field in an audit record, quotes removed
+func auditField(line, name string) string {
+	idx := strings.Index(line, " "+name+"=")
+	if idx == -1 {
+		return ""
+	}
+	v := line[idx+len(name)+2:]
+	if strings.HasPrefix(v, `"`) {
+		if end := strings.Index(v[1:], `"`); end != -1 {
+			return v[1 : end+1]
+		}
+		return ""
+	}
+	if end := strings.IndexAny(v, " \n"); end != -1 {
+		v = v[:end]
+	}
+	return v
+}
+
+// inode returns the inode of a file, used to detect log rotation
+func inode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
@@ -0,0 +1,288 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package security
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// LUKS metrics from sysfs device-mapper crypt targets (active LUKS/dm-crypt
+// mappings) and the mounted volumes, a volume is encrypted when a crypt
+// mapping is anywhere in its block device stack (e.g. LVM on LUKS)
+type LUKS struct {
+	common
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// luksOptions defines what elements can be overridden in a config file
+type luksOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// blockDevice a sysfs block device
+type blockDevice struct {
+	name      string
+	dmName    string // device-mapper name (e.g. luks-<uuid>, vg-root)
+	cryptType string // luks1, luks2, plain, etc. for dm-crypt mappings
+	suspended bool
+	slaves    []string
+}
+
+const (
+	blockClassDir = "class/block"    // relative to sysfs
+	mountInfo     = "self/mountinfo" // relative to procfs
+	maxStackDepth = 16               // block device stacking depth limit
+	regexPat      = `^(?:%s)$`       // fmt pattern used compile include/exclude regular expressions
+)
+
+var (
+	defaultExcludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ""))
+	defaultIncludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ".+"))
+)
+
+// NewLUKSCollector creates new security luks collector
+func NewLUKSCollector(cfgBaseName, procFSPath, sysFSPath string) (collector.Collector, error) {
+	c := LUKS{
+		common:  newCommon(NameLUKS, procFSPath, sysFSPath, tags.FromList(tags.GetBaseTags())),
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+	}
+
+	var opts luksOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs and procfs
+func (c *LUKS) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	devices, byNumber, err := c.blockDevices()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	crypts := 0
+	for _, dev := range devices {
+		if dev.cryptType == "" {
+			continue
+		}
+		crypts++
+		devTags := tags.Tags{
+			tags.Tag{Category: "crypt-device", Value: dev.dmName},
+			tags.Tag{Category: "crypt-type", Value: dev.cryptType},
+		}
+		for _, slave := range dev.slaves {
+			devTags = append(devTags, tags.Tag{Category: "backing-device", Value: slave})
+		}
+		suspended := 0
+		if dev.suspended {
+			suspended = 1
+		}
+		_ = c.addMetric(&metrics, "", "crypt_suspended", "I", suspended, devTags)
+	}
+	_ = c.addMetric(&metrics, "", "crypt_devices", "I", crypts, tags.Tags{})
+
+	volumes, err := c.mountedVolumes(byNumber)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	encrypted := 0
+	for _, vol := range volumes {
+		isEncrypted := 0
+		if c.isEncrypted(devices, vol.device, 0) {
+			isEncrypted = 1
+			encrypted++
+		}
+		_ = c.addMetric(&metrics, "", "volume_encrypted", "I", isEncrypted, tags.Tags{
+			tags.Tag{Category: "volume", Value: vol.mountPoint},
+			tags.Tag{Category: "device", Value: vol.device},
+			tags.Tag{Category: "fs-type", Value: vol.fsType},
+		})
+	}
+	_ = c.addMetric(&metrics, "", "volumes", "I", len(volumes), tags.Tags{})
+	_ = c.addMetric(&metrics, "", "volumes_encrypted", "I", encrypted, tags.Tags{})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// blockDevices returns the sysfs block devices by name and the device names
+// by major:minor number
+func (c *LUKS) blockDevices() (map[string]*blockDevice, map[string]string, error) {
+	classDir := filepath.Join(c.sysFSPath, blockClassDir)
+	entries, err := ioutil.ReadDir(classDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	devices := make(map[string]*blockDevice, len(entries))
+	byNumber := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join(classDir, name)
+		dev := &blockDevice{name: name}
+
+		if num, err := readTrimmed(filepath.Join(dir, "dev")); err == nil {
+			byNumber[num] = name
+		}
+
+		if slaves, err := ioutil.ReadDir(filepath.Join(dir, "slaves")); err == nil {
+			for _, s := range slaves {
+				dev.slaves = append(dev.slaves, s.Name())
+			}
+		}
+
+		// dm-crypt uuids are CRYPT-<TYPE>-..., e.g. CRYPT-LUKS2-<luks uuid>-<name>
+		if uuid, err := readTrimmed(filepath.Join(dir, "dm", "uuid")); err == nil {
+			dev.dmName, _ = readTrimmed(filepath.Join(dir, "dm", "name"))
+			if dev.dmName == "" {
+				dev.dmName = name
+			}
+			if parts := strings.SplitN(uuid, "-", 3); len(parts) >= 2 && parts[0] == "CRYPT" {
+				dev.cryptType = strings.ToLower(parts[1])
+			}
+			if s, err := readTrimmed(filepath.Join(dir, "dm", "suspended")); err == nil {
+				dev.suspended = s == "1"
+			}
+		}
+
+		devices[name] = dev
+	}
+
+	return devices, byNumber, nil
+}
+
+// volume a mounted block device
+type volume struct {
+	mountPoint string
+	device     string
+	fsType     string
+}
+
+// mountedVolumes returns the mount points of block devices from mountinfo,
+// mount points are filtered by the include/exclude regexes
+func (c *LUKS) mountedVolumes(byNumber map[string]string) ([]volume, error) {
+	f, err := os.Open(filepath.Join(c.procFSPath, mountInfo))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	volumes := []volume{}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep == -1 || sep+1 >= len(fields) {
+			continue
+		}
+
+		device, ok := byNumber[fields[2]]
+		if !ok {
+			continue // not a block device (proc, tmpfs, etc.)
+		}
+		fsType := fields[sep+1]
+		if fsType == "squashfs" {
+			continue // read-only images (e.g. snaps), nothing to protect
+		}
+		mountPoint := unescapeMountPath(fields[4])
+		if seen[mountPoint] || c.exclude.MatchString(mountPoint) || !c.include.MatchString(mountPoint) {
+			continue
+		}
+		seen[mountPoint] = true
+		volumes = append(volumes, volume{mountPoint: mountPoint, device: device, fsType: fsType})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return volumes, nil
+}
+
+// isEncrypted returns true if the device or any device it is stacked on
+// (slaves, the parent disk of a partition is not followed) is a crypt mapping
+func (c *LUKS) isEncrypted(devices map[string]*blockDevice, name string, depth int) bool {
+	dev, ok := devices[name]
+	if !ok || depth > maxStackDepth {
+		return false
+	}
+	if dev.cryptType != "" {
+		return true
+	}
+	for _, slave := range dev.slaves {
+		if c.isEncrypted(devices, slave, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeMountPath decodes the octal escapes (space, tab, newline,
+// backslash) used in mountinfo paths
+func unescapeMountPath(p string) string {
+	if !strings.Contains(p, `\`) {
+		return p
+	}
+	r := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+	return r.Replace(p)
+}
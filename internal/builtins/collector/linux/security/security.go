@@ -0,0 +1,113 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package security builtin linux collectors for compliance reporting (LUKS
+// volume encryption and TPM status) and mandatory access control denials
+package security
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "security/"
+	PackageName     = "builtins.linux.security"
+	NameDenials     = "denials"
+	NameLUKS        = "luks"
+	NameTPM         = "tpm"
+)
+
+// New creates new security collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	ProcFSPath := viper.GetString(config.KeyHostProc)
+	if ProcFSPath == "" {
+		ProcFSPath = defaults.HostProc
+	}
+
+	SysFSPath := viper.GetString(config.KeyHostSys)
+	if SysFSPath == "" {
+		SysFSPath = defaults.HostSys
+	}
+
+	VarPath := viper.GetString(config.KeyHostVar)
+	if VarPath == "" {
+		VarPath = defaults.HostVar
+	}
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "security_"+name+"_collector")
+		switch name {
+		case NameDenials:
+			c, err := NewDenialsCollector(cfgBase, VarPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameLUKS:
+			c, err := NewLUKSCollector(cfgBase, ProcFSPath, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameTPM:
+			c, err := NewTPMCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+// readTrimmed returns the trimmed content of a sysfs attribute file
+func readTrimmed(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
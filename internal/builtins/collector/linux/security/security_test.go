@@ -0,0 +1,282 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package security
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestLUKSCollect(t *testing.T) {
+	t.Log("Testing LUKS Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewLUKSCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "proc"), filepath.Join("testdata", "sys"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "crypt_devices"); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected crypt_devices 2, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "crypt_suspended", "crypt-device:luks-2c8e1f4a", "crypt-type:luks2", "backing-device:sda2"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected luks crypt_suspended 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "crypt_suspended", "crypt-device:swap_crypt", "crypt-type:plain"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected plain crypt_suspended 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "volume_encrypted", "volume:/", "device:dm-1", "fs-type:ext4"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected / volume_encrypted 1 (lvm on luks), got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "volume_encrypted", "volume:/var/lib/docker"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected /var/lib/docker volume_encrypted 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "volume_encrypted", "volume:/boot"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected /boot volume_encrypted 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "volume_encrypted", "volume:/srv/backup data", "fs-type:xfs"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected /srv/backup data volume_encrypted 0, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "volume_encrypted", "volume:/snap/core18/1880"); ok {
+		t.Fatal("expected squashfs volume to be ignored")
+	}
+	if _, ok := testutil.FindMetric(metrics, "volume_encrypted", "volume:/proc"); ok {
+		t.Fatal("expected non-block volume to be ignored")
+	}
+	if m, ok := testutil.FindMetric(metrics, "volumes"); !ok || m.Value.(int) != 4 {
+		t.Fatalf("expected volumes 4, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "volumes_encrypted"); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected volumes_encrypted 2, got %v", m.Value)
+	}
+
+	t.Log("\texclude regex")
+	{
+		c, err := NewLUKSCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "proc"), filepath.Join("testdata", "sys"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		l := c.(*LUKS)
+		l.exclude = defaultIncludeRegex
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "volumes"); !ok || m.Value.(int) != 0 {
+			t.Fatalf("expected volumes 0, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tno sysfs")
+	{
+		c, err := NewLUKSCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "proc"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	t.Log("Testing unescapeMountPath")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"/", "/"},
+		{`/srv/backup\040data`, "/srv/backup data"},
+		{`/mnt/a\134b\011c`, "/mnt/a\\b\tc"},
+	}
+
+	for _, tt := range tests {
+		if got := unescapeMountPath(tt.in); got != tt.want {
+			t.Fatalf("expected (%s), got (%s)", tt.want, got)
+		}
+	}
+}
+
+func TestTPMCollect(t *testing.T) {
+	t.Log("Testing TPM Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\ttpm 2.0")
+	{
+		c, err := NewTPMCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "present"); !ok || m.Value.(int) != 1 {
+			t.Fatalf("expected present 1, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, "version_major"); !ok || m.Value.(uint64) != 2 {
+			t.Fatalf("expected version_major 2, got %v", m.Value)
+		}
+		if _, ok := testutil.FindMetric(metrics, "owned"); ok {
+			t.Fatal("expected no owned for tpm 2.0")
+		}
+	}
+
+	t.Log("\ttpm 1.2")
+	{
+		c, err := NewTPMCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "sys12"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "version_major"); !ok || m.Value.(uint64) != 1 {
+			t.Fatalf("expected version_major 1, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, "enabled"); !ok || m.Value.(uint64) != 1 {
+			t.Fatalf("expected enabled 1, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, "owned"); !ok || m.Value.(uint64) != 0 {
+			t.Fatalf("expected owned 0, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tno tpm")
+	{
+		c, err := NewTPMCollector(filepath.Join("testdata", "missing"), filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "present"); !ok || m.Value.(int) != 0 {
+			t.Fatalf("expected present 0, got %v", m.Value)
+		}
+		if len(metrics) != 1 {
+			t.Fatalf("expected 1 metric, got %d", len(metrics))
+		}
+	}
+}
+
+func TestDenialsCollect(t *testing.T) {
+	t.Log("Testing Denials Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := ioutil.TempDir("", "denials")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	logDir := filepath.Join(dir, "log", "audit")
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		t.Fatalf("creating log dir (%s)", err)
+	}
+	logFile := filepath.Join(logDir, "audit.log")
+	before := `type=AVC msg=audit(1600000000.100:10): avc:  denied  { read } for  pid=1 comm="httpd" scontext=system_u:system_r:httpd_t:s0 tcontext=system_u:object_r:user_home_t:s0 tclass=file permissive=0` + "\n"
+	if err := ioutil.WriteFile(logFile, []byte(before), 0600); err != nil {
+		t.Fatalf("writing log (%s)", err)
+	}
+
+	c, err := NewDenialsCollector(filepath.Join("testdata", "missing"), dir)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	appendLog := func(lines string) {
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatalf("opening log (%s)", err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(lines); err != nil {
+			t.Fatalf("writing log (%s)", err)
+		}
+	}
+
+	t.Log("\tdenials after start")
+	{
+		appendLog(before + before +
+			`type=SYSCALL msg=audit(1600000000.200:11): arch=c000003e syscall=2 success=no exit=-13` + "\n" +
+			`type=AVC msg=audit(1600000000.300:12): apparmor="DENIED" operation="open" profile="/usr/sbin/cupsd" name="/etc/shadow" pid=2 comm="cupsd" requested_mask="r" denied_mask="r"` + "\n" +
+			`type=AVC msg=audit(1600000000.400:13): apparmor="ALLOWED" operation="open" profile="/usr/sbin/cupsd" name="/etc/hosts"` + "\n" +
+			`type=AVC msg=audit(1600000000.500:14): avc:  denied  { name_bind } for  pid=3 comm="nginx" scontext=system_u:system_r:httpd_t:s0`)
+
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "selinux_denials", "domain:httpd_t", "class:file"); !ok || m.Value.(uint64) != 2 {
+			t.Fatalf("expected httpd_t file denials 2, got %v", m.Value)
+		}
+		if m, ok := testutil.FindMetric(metrics, "apparmor_denials", "profile:/usr/sbin/cupsd"); !ok || m.Value.(uint64) != 1 {
+			t.Fatalf("expected cupsd denials 1, got %v", m.Value)
+		}
+		if len(metrics) != 2 {
+			t.Fatalf("expected 2 metrics (partial line not counted), got %d", len(metrics))
+		}
+	}
+
+	t.Log("\tpartial line completed")
+	{
+		appendLog(" tclass=tcp_socket permissive=1\n")
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "selinux_denials", "domain:httpd_t", "class:tcp_socket"); !ok || m.Value.(uint64) != 1 {
+			t.Fatalf("expected httpd_t tcp_socket denials 1, got %v", m.Value)
+		}
+	}
+
+	t.Log("\trotated")
+	{
+		if err := os.Rename(logFile, logFile+".1"); err != nil {
+			t.Fatalf("rotating log (%s)", err)
+		}
+		if err := ioutil.WriteFile(logFile, []byte(before), 0600); err != nil {
+			t.Fatalf("writing log (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if m, ok := testutil.FindMetric(metrics, "selinux_denials", "domain:httpd_t", "class:file"); !ok || m.Value.(uint64) != 3 {
+			t.Fatalf("expected httpd_t file denials 3, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tno log")
+	{
+		c, err := NewDenialsCollector(filepath.Join("testdata", "missing"), filepath.Join(dir, "missing"))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
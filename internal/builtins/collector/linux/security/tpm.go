@@ -0,0 +1,119 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package security
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// TPM metrics from the sysfs tpm class (presence, specification version
+// and, for TPM 1.2, the enabled/active/owned state)
+type TPM struct {
+	common
+}
+
+// tpmOptions defines what elements can be overridden in a config file
+type tpmOptions struct {
+	commonOptions
+}
+
+const tpmClassDir = "class/tpm" // relative to sysfs
+
+// NewTPMCollector creates new security tpm collector
+func NewTPMCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := TPM{
+		common: newCommon(NameTPM, "", sysFSPath, tags.FromList(tags.GetBaseTags())),
+	}
+
+	var opts tpmOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from sysfs
+func (c *TPM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(c.sysFSPath, tpmClassDir, "tpm0")
+
+	present := 0
+	if _, err := os.Stat(dir); err == nil {
+		present = 1
+	} else if !os.IsNotExist(err) {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	_ = c.addMetric(&metrics, "", "present", "I", present, tags.Tags{})
+
+	if present == 1 {
+		if major, ok := tpmVersionMajor(dir); ok {
+			_ = c.addMetric(&metrics, "", "version_major", "I", major, tags.Tags{})
+		}
+
+		// TPM 1.2 state, not available for TPM 2.0 devices
+		for _, state := range []string{"enabled", "active", "owned"} {
+			v, err := readTrimmed(filepath.Join(dir, "device", state))
+			if err != nil {
+				continue
+			}
+			if n, err := strconv.ParseUint(v, 10, 8); err == nil {
+				_ = c.addMetric(&metrics, "", state, "I", n, tags.Tags{})
+			}
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// tpmVersionMajor returns the TPM major version, from tpm_version_major
+// (linux 5.6+) or the TCG version in the TPM 1.2 device caps
+func tpmVersionMajor(dir string) (uint64, bool) {
+	if v, err := readTrimmed(filepath.Join(dir, "tpm_version_major")); err == nil {
+		if major, err := strconv.ParseUint(v, 10, 8); err == nil {
+			return major, true
+		}
+	}
+	caps, err := readTrimmed(filepath.Join(dir, "device", "caps"))
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(caps, "\n") {
+		if !strings.HasPrefix(line, "TCG version:") {
+			continue
+		}
+		v := strings.TrimSpace(strings.TrimPrefix(line, "TCG version:"))
+		if idx := strings.Index(v, "."); idx != -1 {
+			v = v[:idx]
+		}
+		if major, err := strconv.ParseUint(v, 10, 8); err == nil {
+			return major, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,462 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package smart
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Drives metrics, drive health (temperature, reallocated sectors, media
+// errors, wear) from smartctl (ATA, SCSI and NVMe drives) or nvme-cli (NVMe
+// drives) so failing drives can be replaced before they fail
+type Drives struct {
+	common
+	devPath      string
+	smartctlPath string
+	nvmePath     string
+	nvmeTool     string
+	timeout      time.Duration
+	include      *regexp.Regexp
+	exclude      *regexp.Regexp
+}
+
+// drivesOptions defines what elements can be overridden in a config file
+type drivesOptions struct {
+	commonOptions
+
+	// collector specific
+	DevPath      string `json:"dev_path" toml:"dev_path" yaml:"dev_path"`
+	SmartctlPath string `json:"smartctl_path" toml:"smartctl_path" yaml:"smartctl_path"`
+	NVMePath     string `json:"nvme_path" toml:"nvme_path" yaml:"nvme_path"`
+	NVMeTool     string `json:"nvme_tool" toml:"nvme_tool" yaml:"nvme_tool"`
+	Timeout      string `json:"timeout" toml:"timeout" yaml:"timeout"`
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+}
+
+// drive is a physical drive discovered in sysfs
+type drive struct {
+	name  string // block device (e.g. sda) or nvme controller (e.g. nvme0)
+	model string
+	nvme  bool
+}
+
+// driveHealth is the health of a drive read with smartctl or nvme-cli
+type driveHealth struct {
+	protocol string // ATA, SCSI or NVMe
+	model    string
+	metrics  []healthMetric
+}
+
+// healthMetric is a drive health value
+type healthMetric struct {
+	name  string
+	mtype string
+	value interface{}
+	units string
+}
+
+type smartctlOutput struct {
+	Smartctl struct {
+		Messages []struct {
+			String string `json:"string"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	Device struct {
+		Protocol string `json:"protocol"` // ATA, SCSI or NVMe
+	} `json:"device"`
+	ModelName   string `json:"model_name"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current int64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime *struct {
+		Hours uint64 `json:"hours"`
+	} `json:"power_on_time"`
+	PowerCycleCount    *uint64 `json:"power_cycle_count"`
+	ATASmartAttributes struct {
+		Table []struct {
+			ID    int `json:"id"`
+			Value int `json:"value"` // normalized
+			Raw   struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeHealthLog *struct {
+		CriticalWarning  uint64 `json:"critical_warning"`
+		AvailableSpare   uint64 `json:"available_spare"`
+		PercentageUsed   uint64 `json:"percentage_used"`
+		UnsafeShutdowns  uint64 `json:"unsafe_shutdowns"`
+		MediaErrors      uint64 `json:"media_errors"`
+		NumErrLogEntries uint64 `json:"num_err_log_entries"`
+	} `json:"nvme_smart_health_information_log"`
+	SCSIGrownDefectList *uint64 `json:"scsi_grown_defect_list"`
+	SCSIPercentageUsed  *uint64 `json:"scsi_percentage_used_endurance_indicator"`
+}
+
+// nvmeSmartLog is the nvme-cli smart-log json output, values are numbers
+// (some versions print the 128 bit counters as doubles), the temperature is
+// in kelvin
+type nvmeSmartLog struct {
+	CriticalWarning  *float64 `json:"critical_warning"`
+	Temperature      *float64 `json:"temperature"`
+	AvailSpare       *float64 `json:"avail_spare"`
+	PercentUsed      *float64 `json:"percent_used"`
+	PowerCycles      *float64 `json:"power_cycles"`
+	PowerOnHours     *float64 `json:"power_on_hours"`
+	UnsafeShutdowns  *float64 `json:"unsafe_shutdowns"`
+	MediaErrors      *float64 `json:"media_errors"`
+	NumErrLogEntries *float64 `json:"num_err_log_entries"`
+}
+
+const (
+	defaultDevPath       = "/dev"
+	defaultSmartctlPath  = "smartctl"
+	defaultNVMePath      = "nvme"
+	defaultDrivesTimeout = 30 * time.Second
+	nvmeToolSmartctl     = "smartctl"
+	nvmeToolNVMe         = "nvme"
+
+	// smartctl exit status bits 0 and 1, the command line did not parse or
+	// the device could not be opened - the other bits report drive problems
+	smartctlFatalStatus = 0x03
+
+	// ATA SMART attribute ids
+	ataReallocatedSectors   = 5
+	ataWearLevelingCount    = 177 // normalized value is the remaining life (percent), e.g. Samsung
+	ataPendingSectors       = 197
+	ataUncorrectableSectors = 198
+	ataSSDLifeLeft          = 231
+	ataMediaWearout         = 233 // e.g. Intel
+
+	kelvinOffset = 273
+)
+
+var (
+	// nvme namespace block devices (e.g. nvme0n1), the health log is read from the controller (nvme0)
+	nvmeNamespaceRx = regexp.MustCompile(`^(nvme\d+)n\d+$`)
+	// block devices without SMART data (virtual, optical, floppy, network)
+	skipDeviceRx = regexp.MustCompile(`^(loop|ram|zram|dm-|md|sr|fd|nbd|rbd|vd|xvd)`)
+	// smartctl arguments, drives in standby are not spun up (skipped with exit status 0)
+	smartctlArgs = []string{"--json", "--info", "--health", "--attributes", "--nocheck=standby,0"}
+)
+
+// NewDrivesCollector creates new smart drives collector
+func NewDrivesCollector(cfgBaseName, sysFSPath string) (collector.Collector, error) {
+	c := Drives{
+		common:       newCommon(NameDrives, sysFSPath, tags.FromList(tags.GetBaseTags())),
+		devPath:      defaultDevPath,
+		smartctlPath: defaultSmartctlPath,
+		nvmePath:     defaultNVMePath,
+		nvmeTool:     nvmeToolSmartctl,
+		timeout:      defaultDrivesTimeout,
+		include:      defaultIncludeRegex,
+		exclude:      defaultExcludeRegex,
+	}
+
+	var opts drivesOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.DevPath != "" {
+		c.devPath = opts.DevPath
+	}
+
+	if opts.SmartctlPath != "" {
+		c.smartctlPath = opts.SmartctlPath
+	}
+
+	if opts.NVMePath != "" {
+		c.nvmePath = opts.NVMePath
+	}
+
+	switch opts.NVMeTool {
+	case "":
+	case nvmeToolSmartctl, nvmeToolNVMe:
+		c.nvmeTool = opts.NVMeTool
+	default:
+		return nil, errors.Errorf("%s invalid nvme_tool (%s)", c.pkgID, opts.NVMeTool)
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	inc, exc, err := compileRegexes(c.pkgID, opts.IncludeRegex, opts.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+	c.include, c.exclude = inc, exc
+
+	return &c, nil
+}
+
+// Collect drive health metrics
+func (c *Drives) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	drives, err := c.discover()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var lastErr error
+	read := 0
+	for _, d := range drives {
+		if c.exclude.MatchString(d.name) || !c.include.MatchString(d.name) {
+			continue
+		}
+
+		var health *driveHealth
+		if d.nvme && c.nvmeTool == nvmeToolNVMe {
+			health, err = c.readNVMe(cctx, d)
+		} else {
+			health, err = c.readSmartctl(cctx, d)
+		}
+		if err != nil {
+			c.logger.Warn().Err(err).Str("drive", d.name).Msg("reading drive health")
+			lastErr = err
+			continue
+		}
+		read++
+
+		tagList := tags.Tags{tags.Tag{Category: "drive", Value: d.name}}
+		if health.protocol != "" {
+			tagList = append(tagList, tags.Tag{Category: "drive-protocol", Value: strings.ToLower(health.protocol)})
+		}
+		// the sysfs model of ata drives is truncated (16 characters)
+		if model := health.model; model != "" || d.model != "" {
+			if model == "" {
+				model = d.model
+			}
+			tagList = append(tagList, tags.Tag{Category: "drive-model", Value: model})
+		}
+
+		for _, h := range health.metrics {
+			mtags := tagList
+			if h.units != "" {
+				mtags = append(tagList[:len(tagList):len(tagList)], tags.Tag{Category: "units", Value: h.units})
+			}
+			_ = c.addMetric(&metrics, "", h.name, h.mtype, h.value, mtags)
+		}
+	}
+
+	// every drive failed, e.g. smartctl not installed or not permitted
+	if read == 0 && lastErr != nil {
+		c.setStatus(metrics, lastErr)
+		return errors.Wrap(lastErr, c.pkgID)
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// discover returns the physical drives (block devices with a device) in
+// sysfs, nvme namespaces are collapsed to their controller
+func (c *Drives) discover() ([]drive, error) {
+	blockDir := filepath.Join(c.sysFSPath, "block")
+	entries, err := ioutil.ReadDir(blockDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading block devices")
+	}
+
+	var drives []drive
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		if skipDeviceRx.MatchString(name) {
+			continue
+		}
+		devDir := filepath.Join(blockDir, name, "device")
+		if _, err := os.Stat(devDir); err != nil {
+			continue // virtual
+		}
+		d := drive{name: name, model: readString(filepath.Join(devDir, "model"))}
+		if m := nvmeNamespaceRx.FindStringSubmatch(name); m != nil {
+			d.name = m[1]
+			d.nvme = true
+		}
+		if seen[d.name] {
+			continue
+		}
+		seen[d.name] = true
+		drives = append(drives, d)
+	}
+
+	if len(drives) == 0 {
+		return nil, errors.New("no drives found")
+	}
+
+	return drives, nil
+}
+
+// readSmartctl reads the health of a drive with smartctl
+func (c *Drives) readSmartctl(ctx context.Context, d drive) (*driveHealth, error) {
+	out, status, err := runCommand(ctx, c.smartctlPath, append(smartctlArgs[:len(smartctlArgs):len(smartctlArgs)], path.Join(c.devPath, d.name))...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sc smartctlOutput
+	if err := json.Unmarshal(out, &sc); err != nil {
+		return nil, errors.Wrap(err, "parsing smartctl output")
+	}
+
+	if status&smartctlFatalStatus != 0 {
+		msg := "unknown error"
+		if len(sc.Smartctl.Messages) > 0 {
+			msg = sc.Smartctl.Messages[0].String
+		}
+		return nil, errors.Errorf("smartctl exit status %d: %s", status, msg)
+	}
+
+	var health []healthMetric
+	if sc.SmartStatus != nil {
+		passed := 0
+		if sc.SmartStatus.Passed {
+			passed = 1
+		}
+		health = append(health, healthMetric{"health_passed", "I", passed, ""})
+	}
+	if sc.Temperature != nil {
+		health = append(health, healthMetric{"temperature", "l", sc.Temperature.Current, "celsius"})
+	}
+	if sc.PowerOnTime != nil {
+		health = append(health, healthMetric{"power_on_hours", "L", sc.PowerOnTime.Hours, "hours"})
+	}
+	if sc.PowerCycleCount != nil {
+		health = append(health, healthMetric{"power_cycles", "L", *sc.PowerCycleCount, ""})
+	}
+
+	wear := -1
+	for _, attr := range sc.ATASmartAttributes.Table {
+		switch attr.ID {
+		case ataReallocatedSectors:
+			health = append(health, healthMetric{"reallocated_sectors", "L", attr.Raw.Value, "sectors"})
+		case ataPendingSectors:
+			health = append(health, healthMetric{"pending_sectors", "L", attr.Raw.Value, "sectors"})
+		case ataUncorrectableSectors:
+			health = append(health, healthMetric{"uncorrectable_sectors", "L", attr.Raw.Value, "sectors"})
+		case ataWearLevelingCount, ataSSDLifeLeft, ataMediaWearout:
+			// the vendor attribute's normalized value counts down from 100 (new)
+			if wear < 0 && attr.Value <= 100 {
+				wear = 100 - attr.Value
+			}
+		}
+	}
+	if wear >= 0 {
+		health = append(health, healthMetric{"percent_used", "I", wear, "percent"})
+	}
+
+	if nl := sc.NVMeHealthLog; nl != nil {
+		health = append(health,
+			healthMetric{"critical_warning", "I", int(nl.CriticalWarning), ""},
+			healthMetric{"available_spare", "I", int(nl.AvailableSpare), "percent"},
+			healthMetric{"percent_used", "I", int(nl.PercentageUsed), "percent"},
+			healthMetric{"media_errors", "L", nl.MediaErrors, ""},
+			healthMetric{"unsafe_shutdowns", "L", nl.UnsafeShutdowns, ""},
+			healthMetric{"error_log_entries", "L", nl.NumErrLogEntries, ""})
+	}
+
+	if sc.SCSIGrownDefectList != nil {
+		health = append(health, healthMetric{"grown_defects", "L", *sc.SCSIGrownDefectList, "sectors"})
+	}
+	if sc.SCSIPercentageUsed != nil {
+		health = append(health, healthMetric{"percent_used", "I", int(*sc.SCSIPercentageUsed), "percent"})
+	}
+
+	return &driveHealth{protocol: sc.Device.Protocol, model: sc.ModelName, metrics: health}, nil
+}
+
+// readNVMe reads the health log of an nvme controller with nvme-cli
+func (c *Drives) readNVMe(ctx context.Context, d drive) (*driveHealth, error) {
+	out, status, err := runCommand(ctx, c.nvmePath, "smart-log", path.Join(c.devPath, d.name), "--output-format=json")
+	if err != nil {
+		return nil, err
+	}
+	if status != 0 {
+		return nil, errors.Errorf("nvme smart-log exit status %d: %s", status, strings.TrimSpace(string(out)))
+	}
+
+	var sl nvmeSmartLog
+	if err := json.Unmarshal(out, &sl); err != nil {
+		return nil, errors.Wrap(err, "parsing nvme smart-log output")
+	}
+
+	var health []healthMetric
+	if sl.CriticalWarning != nil {
+		passed := 0
+		if *sl.CriticalWarning == 0 {
+			passed = 1
+		}
+		health = append(health,
+			healthMetric{"health_passed", "I", passed, ""},
+			healthMetric{"critical_warning", "I", int(*sl.CriticalWarning), ""})
+	}
+	if sl.Temperature != nil {
+		health = append(health, healthMetric{"temperature", "l", int64(*sl.Temperature) - kelvinOffset, "celsius"})
+	}
+	for _, v := range []struct {
+		name  string
+		mtype string
+		value *float64
+		units string
+	}{
+		{"available_spare", "I", sl.AvailSpare, "percent"},
+		{"percent_used", "I", sl.PercentUsed, "percent"},
+		{"power_on_hours", "L", sl.PowerOnHours, "hours"},
+		{"power_cycles", "L", sl.PowerCycles, ""},
+		{"media_errors", "L", sl.MediaErrors, ""},
+		{"unsafe_shutdowns", "L", sl.UnsafeShutdowns, ""},
+		{"error_log_entries", "L", sl.NumErrLogEntries, ""},
+	} {
+		if v.value == nil {
+			continue
+		}
+		if v.mtype == "I" {
+			health = append(health, healthMetric{v.name, v.mtype, int(*v.value), v.units})
+			continue
+		}
+		health = append(health, healthMetric{v.name, v.mtype, uint64(*v.value), v.units})
+	}
+
+	return &driveHealth{protocol: "NVMe", metrics: health}, nil
+}
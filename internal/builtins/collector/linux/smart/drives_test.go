@@ -0,0 +1,261 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+var testSysFSPath = filepath.Join("testdata", "sys")
+
+// commandResult is the testdata file and exit status of a stubbed command
+type commandResult struct {
+	file   string
+	status int
+}
+
+// stubCommands returns the testdata file for each command, keyed by the
+// command and its device argument (e.g. "smartctl /dev/sda")
+func stubCommands(results map[string]commandResult) func() {
+	orig := runCommand
+	runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, int, error) {
+		dev := ""
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "/dev/") {
+				dev = arg
+			}
+		}
+		res, ok := results[cmd+" "+dev]
+		if !ok {
+			return nil, 0, errors.Errorf("unexpected command %s %v", cmd, args)
+		}
+		out, err := ioutil.ReadFile(filepath.Join("testdata", res.file))
+		return out, res.status, err
+	}
+	return func() { runCommand = orig }
+}
+
+func TestNewDrivesCollector(t *testing.T) {
+	t.Log("Testing NewDrivesCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tno config")
+	{
+		c, err := NewDrivesCollector("", testSysFSPath)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		d := c.(*Drives)
+		if d.nvmeTool != nvmeToolSmartctl || d.smartctlPath != defaultSmartctlPath || d.timeout != defaultDrivesTimeout {
+			t.Fatalf("expected defaults, got %#v", d)
+		}
+	}
+
+	t.Log("\tconfig settings")
+	{
+		c, err := NewDrivesCollector(filepath.Join("testdata", "config_settings"), testSysFSPath)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		d := c.(*Drives)
+		if d.nvmeTool != nvmeToolNVMe || d.nvmePath != "/usr/sbin/nvme" || d.smartctlPath != "/usr/sbin/smartctl" {
+			t.Fatalf("expected settings applied, got %#v", d)
+		}
+		if d.timeout != 10*time.Second || !d.exclude.MatchString("sdb") {
+			t.Fatalf("expected settings applied, got %#v", d)
+		}
+	}
+
+	for _, cfg := range []string{"config_nvme_tool_invalid_setting", "config_timeout_invalid_setting", "config_include_regex_invalid_setting"} {
+		t.Logf("\t%s", cfg)
+		if _, err := NewDrivesCollector(filepath.Join("testdata", cfg), testSysFSPath); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestDrivesDiscover(t *testing.T) {
+	t.Log("Testing Drives discover")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewDrivesCollector("", testSysFSPath)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	drives, err := c.(*Drives).discover()
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	// loop0, dm-0 (virtual) and sr0 (optical) are skipped, nvme0n1 and
+	// nvme0n2 are the namespaces of one controller
+	expect := []drive{
+		{name: "nvme0", model: "Samsung SSD 970 EVO Plus 1TB", nvme: true},
+		{name: "sda", model: "Samsung SSD 860"},
+		{name: "sdb", model: "ST4000NM0023"},
+	}
+	if len(drives) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, drives)
+	}
+	for i, d := range drives {
+		if d != expect[i] {
+			t.Fatalf("expected %v, got %v", expect[i], d)
+		}
+	}
+
+	t.Log("\tno drives")
+	{
+		c, err := NewDrivesCollector("", filepath.Join(testSysFSPath, "block", "sda"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if _, err := c.(*Drives).discover(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestDrivesCollect(t *testing.T) {
+	t.Log("Testing Drives Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tsmartctl")
+	{
+		defer stubCommands(map[string]commandResult{
+			"smartctl /dev/sda":   {"smartctl_sda.json", 0},
+			"smartctl /dev/sdb":   {"smartctl_sdb.json", 8},
+			"smartctl /dev/nvme0": {"smartctl_nvme0.json", 0},
+		})()
+
+		c, err := NewDrivesCollector("", testSysFSPath)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := c.Flush()
+
+		// ata
+		sda := []string{"drive:sda", "drive-protocol:ata", "drive-model:Samsung SSD 860 EVO 500GB"}
+		testutil.AssertMetric(t, metrics, "health_passed", "I", 1, sda...)
+		testutil.AssertMetric(t, metrics, "temperature", "l", 33, append(sda, "units:celsius")...)
+		testutil.AssertMetric(t, metrics, "power_on_hours", "L", 12345, append(sda, "units:hours")...)
+		testutil.AssertMetric(t, metrics, "power_cycles", "L", 321, sda...)
+		testutil.AssertMetric(t, metrics, "reallocated_sectors", "L", 2, append(sda, "units:sectors")...)
+		testutil.AssertMetric(t, metrics, "pending_sectors", "L", 0, append(sda, "units:sectors")...)
+		testutil.AssertMetric(t, metrics, "uncorrectable_sectors", "L", 1, append(sda, "units:sectors")...)
+		testutil.AssertMetric(t, metrics, "percent_used", "I", 4, append(sda, "units:percent")...)
+
+		// scsi, failing drive (exit status bit 3)
+		sdb := []string{"drive:sdb", "drive-protocol:scsi"}
+		testutil.AssertMetric(t, metrics, "health_passed", "I", 0, sdb...)
+		testutil.AssertMetric(t, metrics, "grown_defects", "L", 17, append(sdb, "units:sectors")...)
+		testutil.AssertMetric(t, metrics, "temperature", "l", 41, sdb...)
+
+		// nvme
+		nvme := []string{"drive:nvme0", "drive-protocol:nvme"}
+		testutil.AssertMetric(t, metrics, "health_passed", "I", 1, nvme...)
+		testutil.AssertMetric(t, metrics, "critical_warning", "I", 0, nvme...)
+		testutil.AssertMetric(t, metrics, "available_spare", "I", 100, append(nvme, "units:percent")...)
+		testutil.AssertMetric(t, metrics, "percent_used", "I", 3, append(nvme, "units:percent")...)
+		testutil.AssertMetric(t, metrics, "unsafe_shutdowns", "L", 27, nvme...)
+		testutil.AssertMetric(t, metrics, "error_log_entries", "L", 4, nvme...)
+		testutil.AssertMetric(t, metrics, "power_cycles", "L", 512, nvme...)
+	}
+
+	t.Log("\tnvme-cli")
+	{
+		defer stubCommands(map[string]commandResult{
+			"smartctl /dev/sda": {"smartctl_sda.json", 0},
+			"nvme /dev/nvme0":   {"nvme_nvme0.json", 0},
+		})()
+
+		c, err := NewDrivesCollector("", testSysFSPath)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		d := c.(*Drives)
+		d.nvmeTool = nvmeToolNVMe
+		_, d.exclude, _ = compileRegexes(d.pkgID, "", "sdb")
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := c.Flush()
+
+		nvme := []string{"drive:nvme0", "drive-protocol:nvme", "drive-model:Samsung SSD 970 EVO Plus 1TB"}
+		testutil.AssertMetric(t, metrics, "health_passed", "I", 0, nvme...)
+		testutil.AssertMetric(t, metrics, "critical_warning", "I", 4, nvme...)
+		testutil.AssertMetric(t, metrics, "temperature", "l", 38, append(nvme, "units:celsius")...)
+		testutil.AssertMetric(t, metrics, "available_spare", "I", 9, append(nvme, "units:percent")...)
+		testutil.AssertMetric(t, metrics, "percent_used", "I", 101, append(nvme, "units:percent")...)
+		testutil.AssertMetric(t, metrics, "media_errors", "L", 6, nvme...)
+		testutil.AssertMetric(t, metrics, "power_on_hours", "L", 8760, append(nvme, "units:hours")...)
+
+		if _, ok := testutil.FindMetric(metrics, "health_passed", "drive:sdb"); ok {
+			t.Fatal("expected excluded drive to be skipped")
+		}
+	}
+
+	t.Log("\tdrive errors")
+	{
+		defer stubCommands(map[string]commandResult{
+			"smartctl /dev/sda":   {"smartctl_open_failed.json", 2},
+			"smartctl /dev/sdb":   {"smartctl_sdb.json", 8},
+			"smartctl /dev/nvme0": {"smartctl_open_failed.json", 2},
+		})()
+
+		c, err := NewDrivesCollector("", testSysFSPath)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := c.Flush()
+
+		testutil.AssertMetric(t, metrics, "health_passed", "I", 0, "drive:sdb")
+		if _, ok := testutil.FindMetric(metrics, "health_passed", "drive:sda"); ok {
+			t.Fatal("expected no metrics for a drive which could not be opened")
+		}
+	}
+
+	t.Log("\tall drives failing")
+	{
+		defer stubCommands(map[string]commandResult{
+			"smartctl /dev/sda":   {"smartctl_open_failed.json", 2},
+			"smartctl /dev/sdb":   {"smartctl_open_failed.json", 2},
+			"smartctl /dev/nvme0": {"smartctl_open_failed.json", 2},
+		})()
+
+		c, err := NewDrivesCollector("", testSysFSPath)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		err = c.Collect(context.Background())
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(err.Error(), "Permission denied") {
+			t.Fatalf("expected smartctl message, got (%s)", err)
+		}
+	}
+}
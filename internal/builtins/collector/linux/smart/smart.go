@@ -0,0 +1,129 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+// Package smart builtin linux collector for drive health (SMART, NVMe health
+// log), drives are discovered from sysfs and read with smartctl or nvme-cli
+package smart
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "smart/"
+	PackageName     = "builtins.linux.smart"
+	NameDrives      = "drives"
+	regexPat        = `^(?:%s)$` // fmt pattern used compile include/exclude regular expressions
+)
+
+var (
+	defaultExcludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ""))
+	defaultIncludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ".+"))
+)
+
+// runCommand runs a drive utility and returns its output and exit status,
+// overridden in tests. A non-zero exit status is not an error, smartctl
+// reports drive problems in the exit status bits along with its output.
+var runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, int, error) {
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+			return out, ee.ExitCode(), nil
+		}
+		return nil, 0, errors.Wrapf(err, "running %s %s", cmd, strings.Join(args, " "))
+	}
+	return out, 0, nil
+}
+
+// New creates new smart collectors, none are enabled by default
+func New(ctx context.Context) ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	if runtime.GOOS != "linux" {
+		return none, nil
+	}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	SysFSPath := viper.GetString(config.KeyHostSys)
+	if SysFSPath == "" {
+		SysFSPath = defaults.HostSys
+	}
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "smart_"+name+"_collector")
+		switch name {
+		case NameDrives:
+			c, err := NewDrivesCollector(cfgBase, SysFSPath)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+// compileRegexes compiles the include/exclude options, empty options are the defaults
+func compileRegexes(pkgID, include, exclude string) (*regexp.Regexp, *regexp.Regexp, error) {
+	inc, exc := defaultIncludeRegex, defaultExcludeRegex
+	if include != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, include))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling include regex", pkgID)
+		}
+		inc = rx
+	}
+	if exclude != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, exclude))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s compiling exclude regex", pkgID)
+		}
+		exc = rx
+	}
+	return inc, exc, nil
+}
+
+// readString reads a sysfs file containing a single (trimmed) value
+func readString(file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
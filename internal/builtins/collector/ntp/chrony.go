@@ -0,0 +1,152 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package ntp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Chrony metrics from `chronyc serverstats` (requests served to clients) and
+// `chronyc -c -n sources` (reachability of the upstream sources)
+type Chrony struct {
+	common
+	chronycPath   string
+	timeout       time.Duration
+	reportSources bool
+}
+
+// chronyOptions defines what elements can be overridden in a config file
+type chronyOptions struct {
+	commonOptions
+
+	// collector specific
+	ChronycPath   string `json:"chronyc_path" toml:"chronyc_path" yaml:"chronyc_path"`
+	Timeout       string `json:"timeout" toml:"timeout" yaml:"timeout"`
+	ReportSources string `json:"report_sources" toml:"report_sources" yaml:"report_sources"`
+}
+
+// source is an upstream time source of the daemon
+type source struct {
+	name     string
+	selected bool
+	stratum  uint64
+	reach    uint64
+	offset   float64 // seconds
+}
+
+const defaultChronycPath = "chronyc" // resolved using PATH
+
+// NewChronyCollector creates new chrony server collector
+func NewChronyCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Chrony{
+		common:        newCommon(NameChrony, tags.FromList(tags.GetBaseTags())),
+		chronycPath:   defaultChronycPath,
+		timeout:       defaultTimeout,
+		reportSources: true,
+	}
+
+	var opts chronyOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.ChronycPath != "" {
+		c.chronycPath = opts.ChronycPath
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	if opts.ReportSources != "" {
+		rs, err := strconv.ParseBool(opts.ReportSources)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing report_sources", c.pkgID)
+		}
+		c.reportSources = rs
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from chronyc
+func (c *Chrony) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := runCommand(cctx, c.chronycPath, "serverstats")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsPackets := tags.Tag{Category: "units", Value: "packets"}
+	for name, v := range parseStats(out) {
+		mtags := tags.Tags{}
+		if strings.Contains(name, "packets") {
+			mtags = append(mtags, tagUnitsPackets)
+		}
+		_ = c.addMetric(&metrics, "", name, "L", v, mtags)
+	}
+
+	if c.reportSources {
+		out, err := runCommand(cctx, c.chronycPath, "-c", "-n", "sources")
+		if err != nil {
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+		c.addSourceMetrics(&metrics, parseChronySources(out))
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// parseChronySources parses `chronyc -c sources` csv output,
+// mode,state,name,stratum,poll,reach,last_rx,offset,offset_orig,error
+func parseChronySources(data []byte) []source {
+	sources := []source{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) < 8 {
+			continue
+		}
+		s := source{
+			name:     fields[2],
+			selected: fields[1] == "*",
+		}
+		s.stratum, _ = strconv.ParseUint(fields[3], 10, 64)
+		s.reach, _ = reachCount(fields[5])
+		s.offset, _ = strconv.ParseFloat(fields[7], 64)
+		sources = append(sources, s)
+	}
+	return sources
+}
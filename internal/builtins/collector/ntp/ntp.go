@@ -0,0 +1,169 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package ntp builtin NTP server collectors (chrony and ntpd), request and
+// drop counters of hosts serving time to clients and per source reachability
+package ntp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	CollectorPrefix = "ntp/"
+	PackageName     = "builtins.ntp"
+	NameChrony      = "chrony"
+	NameNTPD        = "ntpd"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// runCommand runs a daemon query utility and returns its output, overridden in tests
+var runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s %s", cmd, strings.Join(args, " "))
+	}
+	return out, nil
+}
+
+// New creates new ntp collectors, none are enabled by default
+func New() ([]collector.Collector, error) {
+	none := []collector.Collector{}
+
+	l := log.With().Str("pkg", PackageName).Logger()
+
+	enbledCollectors := viper.GetStringSlice(config.KeyCollectors)
+	if len(enbledCollectors) == 0 {
+		l.Info().Msg("no builtin collectors enabled")
+		return none, nil
+	}
+
+	collectors := make([]collector.Collector, 0, len(enbledCollectors))
+	initErrMsg := "initializing builtin collector"
+	for _, name := range enbledCollectors {
+		if !strings.HasPrefix(name, CollectorPrefix) {
+			continue
+		}
+		name = strings.Replace(name, CollectorPrefix, "", -1)
+		cfgBase := path.Join(defaults.EtcPath, "ntp_"+name+"_collector")
+		switch name {
+		case NameChrony:
+			c, err := NewChronyCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case NameNTPD:
+			c, err := NewNTPDCollector(cfgBase)
+			if err != nil {
+				l.Error().Str("name", name).Err(err).Msg(initErrMsg)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		default:
+			l.Warn().Str("name", name).Msg("unknown builtin collector, ignoring")
+		}
+	}
+
+	return collectors, nil
+}
+
+// parseStats parses "label: value" lines (chronyc serverstats, ntpq sysstats)
+// into counters keyed by metric name, non-numeric values are ignored
+func parseStats(data []byte) map[string]uint64 {
+	stats := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := metricName(parts[0])
+		if name == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		stats[name] = v
+	}
+	return stats
+}
+
+// metricName converts a stats label to a metric name,
+// e.g. "NTS-KE connections accepted" to nts_ke_connections_accepted
+func metricName(label string) string {
+	var sb strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(strings.TrimSpace(label)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if sep && sb.Len() > 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(r)
+			sep = false
+			continue
+		}
+		sep = true
+	}
+	return sb.String()
+}
+
+// reachCount returns the number of the last 8 polls of a source which were
+// answered, from the octal reach register
+func reachCount(reach string) (uint64, bool) {
+	v, err := strconv.ParseUint(reach, 8, 8)
+	if err != nil {
+		return 0, false
+	}
+	n := uint64(0)
+	for ; v > 0; v >>= 1 {
+		n += v & 1
+	}
+	return n, true
+}
+
+// addSourceMetrics adds the per source reachability metrics and the number
+// of sources and reachable sources
+func (c *common) addSourceMetrics(metrics *cgm.Metrics, sources []source) {
+	reachable := 0
+	for _, s := range sources {
+		sourceTags := tags.Tags{tags.Tag{Category: "ntp-source", Value: s.name}}
+		selected := 0
+		if s.selected {
+			selected = 1
+		}
+		if s.reach > 0 {
+			reachable++
+		}
+		_ = c.addMetric(metrics, "", "source_reach", "L", s.reach, sourceTags)
+		_ = c.addMetric(metrics, "", "source_selected", "I", selected, sourceTags)
+		_ = c.addMetric(metrics, "", "source_stratum", "L", s.stratum, sourceTags)
+		_ = c.addMetric(metrics, "", "source_offset", "n", s.offset, append(tags.Tags{tags.Tag{Category: "units", Value: "seconds"}}, sourceTags...))
+	}
+	_ = c.addMetric(metrics, "", "sources", "I", len(sources), tags.Tags{})
+	_ = c.addMetric(metrics, "", "sources_reachable", "I", reachable, tags.Tags{})
+}
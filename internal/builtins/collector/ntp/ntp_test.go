@@ -0,0 +1,195 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package ntp
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// stubCommands returns the testdata file for each command argument list
+func stubCommands(t *testing.T, files map[string]string) func() {
+	t.Helper()
+	orig := runCommand
+	runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+		file, ok := files[strings.Join(args, " ")]
+		if !ok {
+			return nil, errors.Errorf("unexpected command %s %v", cmd, args)
+		}
+		return ioutil.ReadFile(filepath.Join("testdata", file))
+	}
+	return func() { runCommand = orig }
+}
+
+func TestMetricName(t *testing.T) {
+	t.Log("Testing metricName")
+
+	tests := map[string]string{
+		"NTP packets received":        "ntp_packets_received",
+		"NTS-KE connections accepted": "nts_ke_connections_accepted",
+		"  KoD responses ":            "kod_responses",
+		"---":                         "",
+	}
+	for label, expect := range tests {
+		if name := metricName(label); name != expect {
+			t.Fatalf("%q expected (%s) got (%s)", label, expect, name)
+		}
+	}
+}
+
+func TestReachCount(t *testing.T) {
+	t.Log("Testing reachCount")
+
+	tests := []struct {
+		reach  string
+		expect uint64
+		ok     bool
+	}{
+		{"377", 8, true},
+		{"17", 4, true},
+		{"0", 0, true},
+		{"400", 0, false},
+		{"-", 0, false},
+	}
+	for _, test := range tests {
+		n, ok := reachCount(test.reach)
+		if n != test.expect || ok != test.ok {
+			t.Fatalf("%q expected %d (%v) got %d (%v)", test.reach, test.expect, test.ok, n, ok)
+		}
+	}
+}
+
+func TestNewChronyCollector(t *testing.T) {
+	t.Log("Testing NewChronyCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tno config")
+	{
+		c, err := NewChronyCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Chrony).chronycPath != defaultChronycPath || !c.(*Chrony).reportSources {
+			t.Fatalf("expected defaults, got %#v", c)
+		}
+	}
+
+	t.Log("\tconfig settings")
+	{
+		c, err := NewChronyCollector(filepath.Join("testdata", "config_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Chrony).chronycPath != "/usr/bin/chronyc" || c.(*Chrony).reportSources {
+			t.Fatalf("expected settings applied, got %#v", c)
+		}
+	}
+
+	for _, cfg := range []string{"bad_report_sources", "bad_timeout"} {
+		t.Logf("\t%s", cfg)
+		if _, err := NewChronyCollector(filepath.Join("testdata", cfg)); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestChronyCollect(t *testing.T) {
+	t.Log("Testing Chrony Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubCommands(t, map[string]string{
+		"serverstats":   "serverstats.txt",
+		"-c -n sources": "chrony_sources.txt",
+	})()
+
+	c, err := NewChronyCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "ntp_packets_received", "units:packets"); !ok || m.Value.(uint64) != 1598 {
+		t.Fatalf("expected ntp_packets_received 1598, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "ntp_packets_dropped"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected ntp_packets_dropped 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "client_log_records_dropped"); !ok || m.Value.(uint64) != 7 {
+		t.Fatalf("expected client_log_records_dropped 7, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "source_reach", "ntp-source:192.0.2.2"); !ok || m.Value.(uint64) != 4 {
+		t.Fatalf("expected source reach 4, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "source_selected", "ntp-source:192.0.2.1"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected source selected, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "source_offset", "ntp-source:192.0.2.1"); !ok || m.Value.(float64) != -0.000012345 {
+		t.Fatalf("expected source offset, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "sources_reachable"); !ok || m.Value.(int) != 2 {
+		t.Fatalf("expected 2 reachable sources, got %v", m.Value)
+	}
+
+	t.Log("\tcommand error")
+	{
+		runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) { return nil, errors.New("boom") }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestNTPDCollect(t *testing.T) {
+	t.Log("Testing NTPD Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer stubCommands(t, map[string]string{
+		"-c sysstats": "sysstats.txt",
+		"-pn":         "ntpq_peers.txt",
+	})()
+
+	c, err := NewNTPDCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "packets_received", "units:packets"); !ok || m.Value.(uint64) != 5000 {
+		t.Fatalf("expected packets_received 5000, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "rate_limited"); !ok || m.Value.(uint64) != 12 {
+		t.Fatalf("expected rate_limited 12, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "uptime", "units:seconds"); !ok || m.Value.(uint64) != 1234 {
+		t.Fatalf("expected uptime 1234, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "source_stratum", "ntp-source:192.0.2.3"); !ok || m.Value.(uint64) != 16 {
+		t.Fatalf("expected source stratum 16, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "source_offset", "ntp-source:192.0.2.2"); !ok || m.Value.(float64) != 0.000104 {
+		t.Fatalf("expected source offset 0.000104, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "source_selected", "ntp-source:192.0.2.2"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected source not selected, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "sources"); !ok || m.Value.(int) != 3 {
+		t.Fatalf("expected 3 sources, got %v", m.Value)
+	}
+}
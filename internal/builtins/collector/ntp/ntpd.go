@@ -0,0 +1,152 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package ntp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// NTPD metrics from `ntpq -c sysstats` (packets received from clients and
+// why they were dropped) and `ntpq -pn` (reachability of the upstream sources)
+type NTPD struct {
+	common
+	ntpqPath      string
+	timeout       time.Duration
+	reportSources bool
+}
+
+// ntpdOptions defines what elements can be overridden in a config file
+type ntpdOptions struct {
+	commonOptions
+
+	// collector specific
+	NtpqPath      string `json:"ntpq_path" toml:"ntpq_path" yaml:"ntpq_path"`
+	Timeout       string `json:"timeout" toml:"timeout" yaml:"timeout"`
+	ReportSources string `json:"report_sources" toml:"report_sources" yaml:"report_sources"`
+}
+
+const defaultNtpqPath = "ntpq" // resolved using PATH
+
+// NewNTPDCollector creates new ntpd server collector
+func NewNTPDCollector(cfgBaseName string) (collector.Collector, error) {
+	c := NTPD{
+		common:        newCommon(NameNTPD, tags.FromList(tags.GetBaseTags())),
+		ntpqPath:      defaultNtpqPath,
+		timeout:       defaultTimeout,
+		reportSources: true,
+	}
+
+	var opts ntpdOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.NtpqPath != "" {
+		c.ntpqPath = opts.NtpqPath
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	if opts.ReportSources != "" {
+		rs, err := strconv.ParseBool(opts.ReportSources)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing report_sources", c.pkgID)
+		}
+		c.reportSources = rs
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from ntpq
+func (c *NTPD) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := runCommand(cctx, c.ntpqPath, "-c", "sysstats")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsPackets := tags.Tag{Category: "units", Value: "packets"}
+	tagUnitsSeconds := tags.Tag{Category: "units", Value: "seconds"}
+	for name, v := range parseStats(out) {
+		switch name {
+		case "uptime", "sysstats_reset":
+			_ = c.addMetric(&metrics, "", name, "L", v, tags.Tags{tagUnitsSeconds})
+		default:
+			_ = c.addMetric(&metrics, "", name, "L", v, tags.Tags{tagUnitsPackets})
+		}
+	}
+
+	if c.reportSources {
+		out, err := runCommand(cctx, c.ntpqPath, "-pn")
+		if err != nil {
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+		c.addSourceMetrics(&metrics, parseNtpqPeers(out))
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// parseNtpqPeers parses `ntpq -pn` output (remote, refid, st, t, when, poll,
+// reach, delay, offset, jitter), the first character of each peer line is the
+// tally code (`*` system peer), offset is in milliseconds
+func parseNtpqPeers(data []byte) []source {
+	sources := []source{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 2 || strings.HasPrefix(line, "=") {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) < 9 || fields[0] == "remote" {
+			continue
+		}
+		s := source{
+			name:     fields[0],
+			selected: line[0] == '*',
+		}
+		s.stratum, _ = strconv.ParseUint(fields[2], 10, 64)
+		s.reach, _ = reachCount(fields[6])
+		if ms, err := strconv.ParseFloat(fields[8], 64); err == nil {
+			s.offset = ms / 1000
+		}
+		sources = append(sources, s)
+	}
+	return sources
+}
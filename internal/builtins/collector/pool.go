@@ -0,0 +1,32 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package collector
+
+import (
+	"sync"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+var metricsPool = sync.Pool{New: func() interface{} { return cgm.Metrics{} }}
+
+// GetMetrics returns an empty metrics map from the pool, maps returned via
+// PutMetrics retain their capacity so the next run does not regrow them
+func GetMetrics() cgm.Metrics {
+	return metricsPool.Get().(cgm.Metrics)
+}
+
+// PutMetrics clears a metrics map and returns it to the pool, the map must
+// not be referenced by the caller afterwards
+func PutMetrics(m cgm.Metrics) {
+	if m == nil {
+		return
+	}
+	for k := range m {
+		delete(m, k)
+	}
+	metricsPool.Put(m)
+}
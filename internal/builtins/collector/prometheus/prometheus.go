@@ -9,7 +9,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"net/url"
 	"path"
@@ -20,23 +19,39 @@ import (
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/promtext"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/pkg/errors"
 	dto "github.com/prometheus/client_model/go"
-	"github.com/prometheus/common/expfmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 // URLDef defines a url to fetch text formatted prom metrics from
 type URLDef struct {
-	ID   string `json:"id" toml:"id" yaml:"id"`
-	URL  string `json:"url" toml:"url" yaml:"url"`
-	TTL  string `json:"ttl" toml:"ttl" yaml:"ttl"`
-	uttl time.Duration
+	ID           string `json:"id" toml:"id" yaml:"id"`
+	URL          string `json:"url" toml:"url" yaml:"url"`
+	TTL          string `json:"ttl" toml:"ttl" yaml:"ttl"`
+	Interval     string `json:"interval" toml:"interval" yaml:"interval"`
+	IncludeRegex string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	uttl         time.Duration
+	interval     time.Duration  // scrape no more frequently than interval, reuse last scrape in between
+	include      *regexp.Regexp // metric (family) names to include, nil for all
+	exclude      *regexp.Regexp // metric (family) names to exclude, nil for none
 }
 
+// promScrape is the last scrape of a url with an interval
+type promScrape struct {
+	ts      time.Time
+	metrics cgm.Metrics
+}
+
+const (
+	regexPat = `^(?:%s)$` // fmt pattern used compile include/exclude regular expressions
+)
+
 // Prom defines prom collector
 type Prom struct {
 	pkgID           string         // package prefix used for logging and errors
@@ -51,13 +66,16 @@ type Prom struct {
 	running         bool           // is collector currently running
 	runTTL          time.Duration  // OPT ttl for collector (default is for every request)
 	baseTags        []string
+	scrapes         map[string]*promScrape // last scrape by url id, for urls with an interval
 	sync.Mutex
 }
 
 // promOptions defines what elements can be overridden in a config file
 type promOptions struct {
-	RunTTL string   `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
-	URLs   []URLDef `json:"urls" toml:"urls" yaml:"urls"`
+	RunTTL       string   `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	IncludeRegex string   `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string   `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	URLs         []URLDef `json:"urls" toml:"urls" yaml:"urls"`
 }
 
 // New creates new prom collector
@@ -66,6 +84,7 @@ func New(cfgBaseName string) (collector.Collector, error) {
 		pkgID:           "builtins.prometheus",
 		metricNameRegex: regexp.MustCompile("[\r\n\"']"), // used to strip unwanted characters
 		baseTags:        tags.GetBaseTags(),
+		scrapes:         make(map[string]*promScrape),
 	}
 
 	c.logger = log.With().Str("pkg", c.pkgID).Logger()
@@ -90,6 +109,24 @@ func New(cfgBaseName string) (collector.Collector, error) {
 	if len(opts.URLs) == 0 {
 		return nil, errors.New("'urls' is REQUIRED in configuration")
 	}
+
+	// include/exclude apply to all urls unless overridden in the url definition
+	var include, exclude *regexp.Regexp
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		include = rx
+	}
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		exclude = rx
+	}
+
 	for i, u := range opts.URLs {
 		if u.ID == "" {
 			c.logger.Warn().Int("item", i).Interface("url", u).Msg("invalid id (empty), ignoring URL entry")
@@ -115,6 +152,32 @@ func New(cfgBaseName string) (collector.Collector, error) {
 		if u.uttl == time.Duration(0) {
 			u.uttl = 30 * time.Second
 		}
+		if u.Interval != "" {
+			interval, err := time.ParseDuration(u.Interval)
+			if err != nil {
+				c.logger.Warn().Err(err).Int("item", i).Interface("url", u).Msg("invalid interval, ignoring")
+			} else {
+				u.interval = interval
+			}
+		}
+		u.include = include
+		if u.IncludeRegex != "" {
+			rx, err := regexp.Compile(fmt.Sprintf(regexPat, u.IncludeRegex))
+			if err != nil {
+				c.logger.Warn().Err(err).Int("item", i).Interface("url", u).Msg("invalid include regex, ignoring URL entry")
+				continue
+			}
+			u.include = rx
+		}
+		u.exclude = exclude
+		if u.ExcludeRegex != "" {
+			rx, err := regexp.Compile(fmt.Sprintf(regexPat, u.ExcludeRegex))
+			if err != nil {
+				c.logger.Warn().Err(err).Int("item", i).Interface("url", u).Msg("invalid exclude regex, ignoring URL entry")
+				continue
+			}
+			u.exclude = rx
+		}
 		c.logger.Debug().Int("item", i).Interface("url", u).Msg("enabling prom collection URL")
 		c.urls = append(c.urls, u)
 	}
@@ -154,6 +217,10 @@ func (c *Prom) Collect(ctx context.Context) error {
 	c.Unlock()
 
 	for _, u := range c.urls {
+		if u.interval > time.Duration(0) {
+			c.scrape(u, &metrics)
+			continue
+		}
 		c.logger.Debug().Str("id", u.ID).Str("url", u.URL).Msg("prom fetch request")
 		err := c.fetchPromMetrics(u, &metrics)
 		if err != nil {
@@ -165,6 +232,26 @@ func (c *Prom) Collect(ctx context.Context) error {
 	return nil
 }
 
+// scrape adds the metrics of a url with an interval, the url is only fetched
+// when its last scrape is older than the interval
+func (c *Prom) scrape(u URLDef, metrics *cgm.Metrics) {
+	last, ok := c.scrapes[u.ID]
+	if !ok || time.Since(last.ts) >= u.interval {
+		c.logger.Debug().Str("id", u.ID).Str("url", u.URL).Msg("prom fetch request")
+		scraped := cgm.Metrics{}
+		if err := c.fetchPromMetrics(u, &scraped); err != nil {
+			c.logger.Error().Err(err).Interface("url", u).Msg("fetching prom metrics")
+			delete(c.scrapes, u.ID) // do not report stale metrics, retry on next collection
+			return
+		}
+		last = &promScrape{ts: time.Now(), metrics: scraped}
+		c.scrapes[u.ID] = last
+	}
+	for mn, mv := range last.metrics {
+		(*metrics)[mn] = mv
+	}
+}
+
 func (c *Prom) fetchPromMetrics(u URLDef, metrics *cgm.Metrics) error {
 	req, err := http.NewRequest("GET", u.URL, nil)
 	if err != nil {
@@ -199,7 +286,7 @@ func (c *Prom) fetchPromMetrics(u URLDef, metrics *cgm.Metrics) error {
 			return
 		}
 		defer resp.Body.Close()
-		ec <- c.parse(u.ID, resp.Body, metrics)
+		ec <- c.parse(u, resp.Body, metrics)
 	}()
 
 	select {
@@ -211,72 +298,28 @@ func (c *Prom) fetchPromMetrics(u URLDef, metrics *cgm.Metrics) error {
 	}
 }
 
-func (c *Prom) parse(id string, data io.Reader, metrics *cgm.Metrics) error {
-	var parser expfmt.TextParser
-
-	// formats supported from https://prometheus.io/docs/instrumenting/exposition_formats/
+func (c *Prom) parse(u URLDef, data io.Reader, metrics *cgm.Metrics) error {
+	return promtext.Parse(data, u.wanted, func(name string, m *dto.Metric, val interface{}) {
+		mtags := c.getLabels(m)
+		mtags = append(mtags, cgm.Tag{Category: "prom_id", Value: u.ID})
+		_ = c.addMetric(metrics, "", name, mtags, "n", val)
+	})
+}
 
-	metricFamilies, err := parser.TextToMetricFamilies(data)
-	if err != nil {
-		return err
+// wanted reports whether metrics of the family named name are collected from the url
+func (u URLDef) wanted(name string) bool {
+	if u.include != nil && !u.include.MatchString(name) {
+		return false
 	}
-
-	pfx := ""
-	for mn, mf := range metricFamilies {
-		for _, m := range mf.Metric {
-			metricName := mn
-			tags := c.getLabels(m)
-			tags = append(tags, cgm.Tag{Category: "prom_id", Value: id})
-			switch mf.GetType() {
-			case dto.MetricType_SUMMARY:
-				_ = c.addMetric(metrics, pfx, metricName+"_count", tags, "n", float64(m.GetSummary().GetSampleCount()))
-				_ = c.addMetric(metrics, pfx, metricName+"_sum", tags, "n", float64(m.GetSummary().GetSampleSum()))
-				for qn, qv := range c.getQuantiles(m) {
-					_ = c.addMetric(metrics, pfx, metricName+"_"+qn, tags, "n", qv)
-				}
-			case dto.MetricType_HISTOGRAM:
-				_ = c.addMetric(metrics, pfx, metricName+"_count", tags, "n", float64(m.GetHistogram().GetSampleCount()))
-				_ = c.addMetric(metrics, pfx, metricName+"_sum", tags, "n", float64(m.GetHistogram().GetSampleSum()))
-				for bn, bv := range c.getBuckets(m) {
-					_ = c.addMetric(metrics, pfx, metricName+"_"+bn, tags, "n", bv)
-				}
-			default:
-				switch {
-				case m.Gauge != nil:
-					if m.GetGauge().Value != nil {
-						_ = c.addMetric(metrics, pfx, metricName, tags, "n", *m.GetGauge().Value)
-					}
-				case m.Counter != nil:
-					if m.GetCounter().Value != nil {
-						_ = c.addMetric(metrics, pfx, metricName, tags, "n", *m.GetCounter().Value)
-					}
-				case m.Untyped != nil:
-					if m.GetUntyped().Value != nil {
-						if *m.GetUntyped().Value == math.Inf(+1) {
-							c.logger.Warn().Str("metric", metricName).Str("type", mf.GetType().String()).Str("value", (*m).GetUntyped().String()).Msg("cannot coerce +Inf to uint64")
-							continue
-						}
-						_ = c.addMetric(metrics, pfx, metricName, tags, "n", *m.GetUntyped().Value)
-					}
-				}
-			}
-		}
+	if u.exclude != nil && u.exclude.MatchString(name) {
+		return false
 	}
-
-	return nil
+	return true
 }
 
 func (c *Prom) getLabels(m *dto.Metric) tags.Tags {
 	// Need to use cgm.Tags format and return a converted stream tags string
-	labels := []string{}
-
-	for _, label := range m.Label {
-		if label.Name != nil && label.Value != nil {
-			ln := c.metricNameRegex.ReplaceAllString(*label.Name, "")
-			lv := c.metricNameRegex.ReplaceAllString(*label.Value, "")
-			labels = append(labels, ln+tags.Delimiter+lv) // stream tags take form cat:val
-		}
-	}
+	labels := promtext.Labels(m, c.metricNameRegex)
 
 	if len(labels) > 0 {
 		tagList := make([]string, 0, len(c.baseTags)+len(labels))
@@ -288,23 +331,3 @@ func (c *Prom) getLabels(m *dto.Metric) tags.Tags {
 
 	return tags.FromList(c.baseTags)
 }
-
-func (c *Prom) getQuantiles(m *dto.Metric) map[string]float64 {
-	ret := make(map[string]float64)
-	for _, q := range m.GetSummary().Quantile {
-		if q.Value != nil && !math.IsNaN(*q.Value) {
-			ret[fmt.Sprint(*q.Quantile)] = *q.Value
-		}
-	}
-	return ret
-}
-
-func (c *Prom) getBuckets(m *dto.Metric) map[string]uint64 {
-	ret := make(map[string]uint64)
-	for _, b := range m.GetHistogram().Bucket {
-		if b.CumulativeCount != nil {
-			ret[fmt.Sprint(*b.UpperBound)] = *b.CumulativeCount
-		}
-	}
-	return ret
-}
@@ -12,9 +12,11 @@ import (
 	"net/http/httptest"
 	"path"
 	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 
+	"github.com/circonus-labs/circonus-agent/internal/sample"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/rs/zerolog"
@@ -122,6 +124,72 @@ func TestNew(t *testing.T) {
 		}
 	}
 
+	t.Log("config (include regex)")
+	{
+		c, err := New(filepath.Join("testdata", "config_include_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		for _, u := range c.(*Prom).urls {
+			if u.include == nil || u.include.String() != `^(?:^foo)$` {
+				t.Fatalf("expected include on %s, got (%v)", u.ID, u.include)
+			}
+		}
+	}
+
+	t.Log("config (include regex invalid)")
+	{
+		_, err := New(filepath.Join("testdata", "config_include_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (exclude regex)")
+	{
+		c, err := New(filepath.Join("testdata", "config_exclude_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		for _, u := range c.(*Prom).urls {
+			if u.exclude == nil || u.exclude.String() != `^(?:^foo)$` {
+				t.Fatalf("expected exclude on %s, got (%v)", u.ID, u.exclude)
+			}
+		}
+	}
+
+	t.Log("config (exclude regex invalid)")
+	{
+		_, err := New(filepath.Join("testdata", "config_exclude_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (url interval and regexes)")
+	{
+		c, err := New(filepath.Join("testdata", "config_url_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		urls := c.(*Prom).urls
+		if len(urls) != 2 {
+			t.Fatalf("expected 2 URLs (invalid regex ignored), got (%#v)", urls)
+		}
+		if urls[0].interval != time.Minute {
+			t.Fatalf("expected 1m interval, got %s", urls[0].interval)
+		}
+		if !urls[0].wanted("http_requests_total") || urls[0].wanted("threads_started") || urls[0].wanted("go_goroutines") {
+			t.Fatal("foo: expected only http_ metrics")
+		}
+		if urls[1].interval != 0 {
+			t.Fatalf("expected no interval, got %s", urls[1].interval)
+		}
+		if !urls[1].wanted("threads_started") || urls[1].wanted("process_open_fds") || !urls[1].wanted("go_goroutines") {
+			t.Fatal("bar: expected url exclude to override config exclude")
+		}
+	}
+
 	t.Log("valid")
 	{
 		c, err := New(path.Join("testdata", "valid"))
@@ -195,9 +263,14 @@ func TestCollect(t *testing.T) {
 		if !ok {
 			t.Fatalf("expected metric '%s', %#v", mn, m)
 		}
+		// sample timestamp is preserved
+		v, ts := sample.Unwrap(testMetric.Value)
 		expect := float64(3)
-		if testMetric.Value.(float64) != expect {
-			t.Fatalf("expected %v got %v", expect, testMetric.Value)
+		if v.(float64) != expect {
+			t.Fatalf("expected %v got %v", expect, v)
+		}
+		if ts != 1395066363000 {
+			t.Fatalf("expected timestamp 1395066363000 got %d", ts)
 		}
 	}
 
@@ -235,3 +308,92 @@ func TestCollectTimeout(t *testing.T) {
 		t.Fatalf("expected %d metrics, got %d", numExpected, len(m))
 	}
 }
+
+func TestCollectFilters(t *testing.T) {
+	t.Log("Testing Collect w/include and exclude")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, promData)
+	}))
+	defer ts.Close()
+
+	c, err := New(path.Join("testdata", "valid"))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	c.(*Prom).urls = []URLDef{{
+		ID:      "foo",
+		URL:     ts.URL,
+		include: regexp.MustCompile(fmt.Sprintf(regexPat, `http_.+|test`)),
+		exclude: regexp.MustCompile(fmt.Sprintf(regexPat, `http_request_duration_seconds`)),
+	}}
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	m := c.Flush()
+	numExpected := 3 // http_requests_total (2) and test
+	if len(m) != numExpected {
+		t.Fatalf("expected %d metrics, got %d %#v", numExpected, len(m), m)
+	}
+	for mn := range m {
+		name, _, _ := tags.SplitMetricStreamTags(mn)
+		if name != "http_requests_total" && name != "test" {
+			t.Fatalf("unexpected metric %s", mn)
+		}
+	}
+}
+
+func TestCollectInterval(t *testing.T) {
+	t.Log("Testing Collect w/interval")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		fmt.Fprintf(w, "test %d\n", fetches)
+	}))
+	defer ts.Close()
+
+	c, err := New(path.Join("testdata", "valid"))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	c.(*Prom).urls = []URLDef{{ID: "foo", URL: ts.URL, interval: time.Minute}}
+
+	for i := 0; i < 3; i++ {
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		m := c.Flush()
+		if len(m) != 1 {
+			t.Fatalf("expected 1 metric, got %d", len(m))
+		}
+		for _, v := range m {
+			if v.Value.(float64) != 1 {
+				t.Fatalf("expected last scrape value 1, got %v", v.Value)
+			}
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected 1 fetch, got %d", fetches)
+	}
+
+	t.Log("\tinterval elapsed")
+	{
+		c.(*Prom).scrapes["foo"].ts = time.Now().Add(-2 * time.Minute)
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		for _, v := range c.Flush() {
+			if v.Value.(float64) != 2 {
+				t.Fatalf("expected new scrape value 2, got %v", v.Value)
+			}
+		}
+		if fetches != 2 {
+			t.Fatalf("expected 2 fetches, got %d", fetches)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package syslog
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// message the parts of a syslog message used for metrics
+type message struct {
+	facility int
+	severity int
+	hostname string
+	program  string
+	text     string
+}
+
+// severityNames RFC5424 severity keywords
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// rfc3164Timestamp e.g. `Oct 15 22:14:15 ` (day is space padded)
+var rfc3164Timestamp = regexp.MustCompile(`^[A-Z][a-z]{2} [ 0-9][0-9] [0-9]{2}:[0-9]{2}:[0-9]{2} `)
+
+// parseMessage parses an RFC5424 or RFC3164 (BSD) syslog message, BSD
+// messages are parsed on a best effort basis as devices vary in what they send
+func parseMessage(line string) (message, error) {
+	line = strings.TrimRight(line, "\r\n\x00")
+
+	if !strings.HasPrefix(line, "<") {
+		return message{}, errors.New("missing priority")
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return message{}, errors.New("invalid priority")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri > 191 {
+		return message{}, errors.New("invalid priority")
+	}
+
+	msg := message{facility: pri >> 3, severity: pri & 7}
+	rest := line[end+1:]
+
+	if strings.HasPrefix(rest, "1 ") {
+		err = parseRFC5424(rest[2:], &msg)
+	} else {
+		parseRFC3164(rest, &msg)
+	}
+
+	return msg, err
+}
+
+// parseRFC5424 parses `TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]`
+func parseRFC5424(s string, msg *message) error {
+	fields := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		sp := strings.IndexByte(s, ' ')
+		if sp == -1 {
+			return errors.New("rfc5424 header truncated")
+		}
+		fields = append(fields, s[:sp])
+		s = s[sp+1:]
+	}
+	if fields[1] != "-" {
+		msg.hostname = fields[1]
+	}
+	if fields[2] != "-" {
+		msg.program = fields[2]
+	}
+
+	// structured data, `-` or one or more [id param="value"...] elements
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	} else {
+		for strings.HasPrefix(s, "[") {
+			n, err := sdElementLen(s)
+			if err != nil {
+				return err
+			}
+			s = s[n:]
+		}
+	}
+	msg.text = strings.TrimPrefix(strings.TrimPrefix(s, " "), "\ufeff") // BOM
+
+	return nil
+}
+
+// sdElementLen returns the length of the structured data element at the
+// start of s, param values are quoted and may contain escaped `"`, `]` and `\`
+func sdElementLen(s string) (int, error) {
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case ']':
+			if !quoted {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, errors.New("rfc5424 structured data not terminated")
+}
+
+// parseRFC3164 parses `[TIMESTAMP ][HOSTNAME ]TAG[PID]: MSG`
+func parseRFC3164(s string, msg *message) {
+	if loc := rfc3164Timestamp.FindStringIndex(s); loc != nil {
+		s = s[loc[1]:]
+	}
+
+	// the hostname is optional, the first token is the tag when it looks like one
+	if sp := strings.IndexByte(s, ' '); sp > 0 && !isTag(s[:sp]) {
+		msg.hostname = s[:sp]
+		s = s[sp+1:]
+	}
+
+	sp := strings.IndexByte(s, ' ')
+	if sp > 0 && isTag(s[:sp]) {
+		tag := s[:sp]
+		if i := strings.IndexAny(tag, "[:"); i > 0 {
+			tag = tag[:i]
+		}
+		msg.program = tag
+		s = s[sp+1:]
+	}
+
+	msg.text = s
+}
+
+// isTag whether a token is a program tag, `prog:` or `prog[pid]:`
+func isTag(token string) bool {
+	return strings.HasSuffix(token, ":") || (strings.Contains(token, "[") && strings.HasSuffix(token, "]"))
+}
+
+// severityName returns the keyword for a severity
+func severityName(severity int) string {
+	if severity < 0 || severity >= len(severityNames) {
+		return strconv.Itoa(severity)
+	}
+	return severityNames[severity]
+}
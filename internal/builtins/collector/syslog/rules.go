@@ -0,0 +1,171 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package syslog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/pkg/errors"
+)
+
+// RuleDef defines a metric extraction rule
+type RuleDef struct {
+	Name    string   `json:"name" toml:"name" yaml:"name"`
+	Program string   `json:"program" toml:"program" yaml:"program"`
+	Match   string   `json:"match" toml:"match" yaml:"match"`
+	Type    string   `json:"type" toml:"type" yaml:"type"`
+	Value   string   `json:"value" toml:"value" yaml:"value"`
+	Tags    []string `json:"tags" toml:"tags" yaml:"tags"`
+}
+
+const (
+	ruleCounter = "counter"
+	ruleGauge   = "gauge"
+
+	// maxRuleSeries limits the tag combinations tracked per rule
+	maxRuleSeries = 1000
+)
+
+// rule a compiled extraction rule
+type rule struct {
+	name     string
+	kind     string
+	program  *regexp.Regexp
+	match    *regexp.Regexp
+	valueIdx int // capture group of the value, -1 counts matches
+	tagNames []string
+	tagIdx   []int
+	series   map[string]*ruleSeries
+}
+
+// ruleSeries the value of a rule for one combination of tag values
+type ruleSeries struct {
+	tags  tags.Tags
+	count uint64
+	value float64
+}
+
+// newRule compiles a rule definition
+func newRule(def RuleDef) (*rule, error) {
+	if def.Name == "" {
+		return nil, errors.New("rule name is required")
+	}
+	if def.Match == "" {
+		return nil, errors.Errorf("rule %s match is required", def.Name)
+	}
+
+	r := rule{
+		name:     def.Name,
+		kind:     strings.ToLower(def.Type),
+		valueIdx: -1,
+		series:   make(map[string]*ruleSeries),
+	}
+
+	switch r.kind {
+	case "":
+		r.kind = ruleCounter
+	case ruleCounter, ruleGauge:
+	default:
+		return nil, errors.Errorf("rule %s invalid type (%s)", def.Name, def.Type)
+	}
+
+	rx, err := regexp.Compile(def.Match)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule %s compiling match", def.Name)
+	}
+	r.match = rx
+
+	if def.Program != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, def.Program))
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule %s compiling program", def.Name)
+		}
+		r.program = rx
+	}
+
+	if def.Value != "" {
+		r.valueIdx = subexpIndex(r.match, def.Value)
+		if r.valueIdx == -1 {
+			return nil, errors.Errorf("rule %s value group (%s) not in match", def.Name, def.Value)
+		}
+	} else if r.kind == ruleGauge {
+		return nil, errors.Errorf("rule %s gauge requires a value group", def.Name)
+	}
+
+	for _, name := range def.Tags {
+		idx := subexpIndex(r.match, name)
+		if idx == -1 {
+			return nil, errors.Errorf("rule %s tag group (%s) not in match", def.Name, name)
+		}
+		r.tagNames = append(r.tagNames, name)
+		r.tagIdx = append(r.tagIdx, idx)
+	}
+
+	return &r, nil
+}
+
+// apply updates the rule from a message, returns whether the message matched
+func (r *rule) apply(msg message) bool {
+	if r.program != nil && !r.program.MatchString(msg.program) {
+		return false
+	}
+	m := r.match.FindStringSubmatch(msg.text)
+	if m == nil {
+		return false
+	}
+
+	value := float64(1)
+	if r.valueIdx != -1 {
+		v, err := strconv.ParseFloat(m[r.valueIdx], 64)
+		if err != nil {
+			return false
+		}
+		value = v
+	}
+
+	var key strings.Builder
+	for i, idx := range r.tagIdx {
+		key.WriteString(r.tagNames[i])
+		key.WriteByte('=')
+		key.WriteString(m[idx])
+		key.WriteByte(',')
+	}
+
+	s, ok := r.series[key.String()]
+	if !ok {
+		if len(r.series) >= maxRuleSeries {
+			return true
+		}
+		s = &ruleSeries{}
+		for i, idx := range r.tagIdx {
+			s.tags = append(s.tags, tags.Tag{Category: r.tagNames[i], Value: m[idx]})
+		}
+		r.series[key.String()] = s
+	}
+
+	s.count++
+	if r.kind == ruleGauge {
+		s.value = value
+	} else if r.valueIdx != -1 {
+		s.value += value
+	}
+
+	return true
+}
+
+// subexpIndex returns the index of the named capture group, -1 if not found
+func subexpIndex(rx *regexp.Regexp, name string) int {
+	for i, n := range rx.SubexpNames() {
+		if n != "" && n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,402 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package syslog builtin syslog receiver, counts messages by severity and
+// program and extracts metrics from messages using regular expression rules
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	appstats "github.com/maier/go-appstats"
+	"github.com/pkg/errors"
+)
+
+const (
+	NameSyslog  = "syslog"
+	PackageName = "builtins.syslog"
+	regexPat    = `^(?:%s)$` // fmt pattern used compile program regular expressions
+
+	maxMessageSize        = 65535
+	maxOctetCountLen      = 6 // len("65535 "), octet count of a maxMessageSize frame
+	defaultMaxTCPConns    = 100
+	defaultTCPIdleTimeout = 5 * time.Minute
+
+	// maxPrograms limits the programs counted individually, messages from
+	// additional programs are counted as program `other`
+	maxPrograms = 500
+)
+
+// defaultListenUDP unprivileged default, port 514 requires the agent to run as root
+var defaultListenUDP = []string{":5514"}
+
+// Syslog receives syslog messages
+type Syslog struct {
+	common
+	rules       []*rule
+	maxTCPConns int
+	counts      map[countKey]uint64
+	parseErrors uint64
+	statsmu     sync.Mutex
+	udpConns    []*net.UDPConn
+	tcpListens  []*net.TCPListener
+	tcpConns    map[net.Conn]struct{}
+}
+
+// countKey messages are counted by severity and program
+type countKey struct {
+	severity int
+	program  string
+}
+
+// syslogOptions defines what elements can be overridden in a config file
+type syslogOptions struct {
+	commonOptions
+
+	// collector specific
+	ListenUDP   []string  `json:"listen_udp" toml:"listen_udp" yaml:"listen_udp"`
+	ListenTCP   []string  `json:"listen_tcp" toml:"listen_tcp" yaml:"listen_tcp"`
+	MaxTCPConns int       `json:"max_tcp_connections" toml:"max_tcp_connections" yaml:"max_tcp_connections"`
+	Rules       []RuleDef `json:"rules" toml:"rules" yaml:"rules"`
+}
+
+// New creates new syslog collector, listeners run until ctx is done
+func New(ctx context.Context, cfgBaseName string) (collector.Collector, error) {
+	c := Syslog{
+		common:      newCommon(NameSyslog, tags.FromList(tags.GetBaseTags())),
+		maxTCPConns: defaultMaxTCPConns,
+		counts:      make(map[countKey]uint64),
+		tcpConns:    make(map[net.Conn]struct{}),
+	}
+
+	// Syslog is a special builtin, like prom it requires a configuration
+	// file, listening for messages is only enabled when explicitly configured.
+	// The default config is a file named syslog_collector.(json|toml|yaml)
+	// located in the agent's default etc path.
+	if cfgBaseName == "" {
+		cfgBaseName = path.Join(defaults.EtcPath, "syslog_collector")
+	}
+
+	var opts syslogOptions
+	if err := config.LoadConfigFile(cfgBaseName, &opts); err != nil {
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Str("base", cfgBaseName).Interface("config", opts).Msg("loaded config")
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.MaxTCPConns > 0 {
+		c.maxTCPConns = opts.MaxTCPConns
+	}
+
+	for _, def := range opts.Rules {
+		r, err := newRule(def)
+		if err != nil {
+			return nil, errors.Wrap(err, c.pkgID)
+		}
+		c.rules = append(c.rules, r)
+	}
+
+	listenUDP := opts.ListenUDP
+	if len(listenUDP) == 0 && len(opts.ListenTCP) == 0 {
+		listenUDP = defaultListenUDP
+	}
+	for _, addr := range listenUDP {
+		if err := c.listenUDP(ctx, addr); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	for _, addr := range opts.ListenTCP {
+		if err := c.listenTCP(ctx, addr); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		c.close()
+	}()
+
+	return &c, nil
+}
+
+// normalizeAddress accepts bracketed ipv6 literals
+func normalizeAddress(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(config.StripBrackets(host), port), nil
+}
+
+// listenUDP starts a udp listener, one message per datagram
+func (c *Syslog) listenUDP(ctx context.Context, address string) error {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return errors.Wrapf(err, "%s invalid udp listen address", c.pkgID)
+	}
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return errors.Wrapf(err, "%s resolving udp listen address '%s'", c.pkgID, address)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "%s listening on udp '%s'", c.pkgID, address)
+	}
+
+	c.Lock()
+	c.udpConns = append(c.udpConns, conn)
+	c.Unlock()
+
+	c.logger.Info().Str("addr", conn.LocalAddr().String()).Msg("syslog udp listener started")
+
+	go func() {
+		buff := make([]byte, maxMessageSize)
+		for {
+			n, err := conn.Read(buff)
+			if err != nil {
+				if ctx.Err() != nil || strings.Contains(err.Error(), "use of closed network connection") {
+					return
+				}
+				c.logger.Warn().Err(err).Msg("udp reader")
+				continue
+			}
+			_ = appstats.IncrementInt("syslog_messages_total")
+			c.handle(string(buff[:n]))
+		}
+	}()
+
+	return nil
+}
+
+// listenTCP starts a tcp listener, messages are framed by octet counting or
+// newlines (RFC6587)
+func (c *Syslog) listenTCP(ctx context.Context, address string) error {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return errors.Wrapf(err, "%s invalid tcp listen address", c.pkgID)
+	}
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, "%s resolving tcp listen address '%s'", c.pkgID, address)
+	}
+	l, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "%s listening on tcp '%s'", c.pkgID, address)
+	}
+
+	c.Lock()
+	c.tcpListens = append(c.tcpListens, l)
+	c.Unlock()
+
+	c.logger.Info().Str("addr", l.Addr().String()).Msg("syslog tcp listener started")
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if ctx.Err() != nil || strings.Contains(err.Error(), "use of closed network connection") {
+					return
+				}
+				c.logger.Warn().Err(err).Msg("accepting tcp connection")
+				continue
+			}
+			c.Lock()
+			if len(c.tcpConns) >= c.maxTCPConns {
+				c.Unlock()
+				c.logger.Warn().Str("remote", conn.RemoteAddr().String()).Int("max", c.maxTCPConns).Msg("max tcp connections reached, refusing connection")
+				_ = conn.Close()
+				continue
+			}
+			c.tcpConns[conn] = struct{}{}
+			c.Unlock()
+			go c.tcpReader(conn)
+		}
+	}()
+
+	return nil
+}
+
+// tcpReader reads messages from a tcp connection until it is closed or idle
+func (c *Syslog) tcpReader(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		c.Lock()
+		delete(c.tcpConns, conn)
+		c.Unlock()
+	}()
+
+	r := bufio.NewReaderSize(conn, 4096)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(defaultTCPIdleTimeout)); err != nil {
+			return
+		}
+		msg, err := readFrame(r)
+		if err != nil {
+			if err != io.EOF && !strings.Contains(err.Error(), "use of closed network connection") {
+				c.logger.Debug().Err(err).Str("remote", conn.RemoteAddr().String()).Msg("tcp reader")
+			}
+			return
+		}
+		if msg == "" {
+			continue
+		}
+		_ = appstats.IncrementInt("syslog_messages_total")
+		c.handle(msg)
+	}
+}
+
+// readFrame reads one message, octet counted (`LEN SP MSG`) when the frame
+// starts with a digit, otherwise newline terminated. At most maxMessageSize
+// bytes of a message are buffered, the rest of an oversized newline terminated
+// message is discarded.
+func readFrame(r *bufio.Reader) (string, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if b[0] >= '0' && b[0] <= '9' {
+		lenStr, err := readDelim(r, ' ', maxOctetCountLen, false)
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(lenStr)))
+		if err != nil || n <= 0 || n > maxMessageSize {
+			return "", errors.Errorf("invalid octet count (%s)", strings.TrimSpace(string(lenStr)))
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+
+	line, err := readDelim(r, '\n', maxMessageSize, true)
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// readDelim reads up to and including delim, buffering at most limit bytes.
+// When the limit is exceeded the rest is discarded up to delim if discard is
+// set, otherwise an error is returned.
+func readDelim(r *bufio.Reader, delim byte, limit int, discard bool) ([]byte, error) {
+	var data []byte
+	for {
+		frag, err := r.ReadSlice(delim)
+		if room := limit - len(data); len(frag) > room {
+			if !discard {
+				return nil, errors.Errorf("frame exceeds %d bytes", limit)
+			}
+			if room > 0 {
+				data = append(data, frag[:room]...)
+			}
+		} else {
+			data = append(data, frag...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return data, err
+	}
+}
+
+// close stops the listeners and closes open tcp connections
+func (c *Syslog) close() {
+	c.Lock()
+	defer c.Unlock()
+	for _, conn := range c.udpConns {
+		_ = conn.Close()
+	}
+	c.udpConns = nil
+	for _, l := range c.tcpListens {
+		_ = l.Close()
+	}
+	c.tcpListens = nil
+	for conn := range c.tcpConns {
+		_ = conn.Close()
+	}
+}
+
+// handle parses a message, counts it and applies the extraction rules
+func (c *Syslog) handle(line string) {
+	msg, err := parseMessage(line)
+
+	c.statsmu.Lock()
+	defer c.statsmu.Unlock()
+
+	if err != nil {
+		c.logger.Debug().Err(err).Str("message", line).Msg("parsing message")
+		c.parseErrors++
+		return
+	}
+
+	program := msg.program
+	if program == "" {
+		program = "-"
+	}
+	k := countKey{severity: msg.severity, program: program}
+	if _, ok := c.counts[k]; !ok && len(c.counts) >= maxPrograms*len(severityNames) {
+		k.program = "other"
+	}
+	c.counts[k]++
+
+	for _, r := range c.rules {
+		r.apply(msg)
+	}
+}
+
+// Collect reports the message counts and rule metrics
+func (c *Syslog) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	c.statsmu.Lock()
+	for k, n := range c.counts {
+		_ = c.addMetric(&metrics, "", "messages", "L", n, tags.Tags{
+			tags.Tag{Category: "severity", Value: severityName(k.severity)},
+			tags.Tag{Category: "program", Value: k.program},
+		})
+	}
+	_ = c.addMetric(&metrics, "", "parse_errors", "L", c.parseErrors, tags.Tags{})
+
+	for _, r := range c.rules {
+		for _, s := range r.series {
+			if r.valueIdx != -1 {
+				// gauge last value or counter sum of values
+				_ = c.addMetric(&metrics, "", r.name, "n", s.value, s.tags)
+				continue
+			}
+			_ = c.addMetric(&metrics, "", r.name, "L", s.count, s.tags)
+		}
+	}
+	c.statsmu.Unlock()
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -0,0 +1,275 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestParseMessage(t *testing.T) {
+	t.Log("Testing parseMessage")
+
+	tests := []struct {
+		line     string
+		severity int
+		facility int
+		hostname string
+		program  string
+		text     string
+	}{
+		{`<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - BOM'su root' failed`, 2, 4, "mymachine.example.com", "su", `BOM'su root' failed`},
+		{`<165>1 2003-10-11T22:14:15.003Z host evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App]lication"][x@1 a="\"]"] An application event`, 5, 20, "host", "evntslog", "An application event"},
+		{`<13>1 - - - - - -`, 5, 1, "", "", ""},
+		{`<38>Oct 15 22:14:15 gateway sshd[1234]: Failed password for root from 192.0.2.1 port 22 ssh2`, 6, 4, "gateway", "sshd", "Failed password for root from 192.0.2.1 port 22 ssh2"},
+		{`<4>Oct  5 01:02:03 kernel: eth0 link down`, 4, 0, "", "kernel", "eth0 link down"},
+		{`<187>%LINK-3-UPDOWN: Interface Gi0/1, changed state to down`, 3, 23, "", "%LINK-3-UPDOWN", "Interface Gi0/1, changed state to down"},
+		{"<30>dnsmasq[99]: query from 10.0.0.2\n", 6, 3, "", "dnsmasq", "query from 10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		msg, err := parseMessage(tt.line)
+		if err != nil {
+			t.Fatalf("expected no error for (%s), got (%s)", tt.line, err)
+		}
+		if msg.severity != tt.severity || msg.facility != tt.facility {
+			t.Fatalf("expected severity %d facility %d, got %d %d (%s)", tt.severity, tt.facility, msg.severity, msg.facility, tt.line)
+		}
+		if msg.hostname != tt.hostname || msg.program != tt.program || msg.text != tt.text {
+			t.Fatalf("expected %q %q %q, got %q %q %q", tt.hostname, tt.program, tt.text, msg.hostname, msg.program, msg.text)
+		}
+	}
+
+	t.Log("\tinvalid")
+	{
+		for _, line := range []string{"no priority", "<>x", "<999>x", "<1x>x", `<34>1 2003-10-11T22:14:15Z host`, `<34>1 - - - - - [x@1 a="b"`} {
+			if _, err := parseMessage(line); err == nil {
+				t.Fatalf("expected error for (%s)", line)
+			}
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	t.Log("Testing rules")
+
+	t.Log("\tinvalid definitions")
+	{
+		defs := []RuleDef{
+			{Match: "x"},
+			{Name: "a"},
+			{Name: "a", Match: "("},
+			{Name: "a", Match: "x", Type: "histogram"},
+			{Name: "a", Match: "x", Type: "gauge"},
+			{Name: "a", Match: "(?P<v>x)", Value: "missing"},
+			{Name: "a", Match: "(?P<v>x)", Tags: []string{"missing"}},
+		}
+		for _, def := range defs {
+			if _, err := newRule(def); err == nil {
+				t.Fatalf("expected error for %+v", def)
+			}
+		}
+	}
+
+	t.Log("\tcounter sum")
+	{
+		r, err := newRule(RuleDef{Name: "bytes", Program: "nginx", Match: `status=(?P<status>\d+) bytes=(?P<bytes>\d+)`, Value: "bytes", Tags: []string{"status"}})
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		r.apply(message{program: "nginx", text: "status=200 bytes=100"})
+		r.apply(message{program: "nginx", text: "status=200 bytes=50"})
+		r.apply(message{program: "nginx", text: "status=404 bytes=10"})
+		if r.apply(message{program: "apache", text: "status=200 bytes=100"}) {
+			t.Fatal("expected program to not match")
+		}
+		if len(r.series) != 2 {
+			t.Fatalf("expected 2 series, got %d", len(r.series))
+		}
+		if s := r.series["status=200,"]; s == nil || s.value != 150 || s.count != 2 {
+			t.Fatalf("expected status 200 sum 150, got %+v", s)
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	t.Log("Testing readFrame")
+
+	r := bufio.NewReader(strings.NewReader("11 <13>1 - - x<13>newline\r\n6 <13>ab"))
+	expected := []string{"<13>1 - - x", "<13>newline", "<13>ab"}
+	for _, want := range expected {
+		got, err := readFrame(r)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+	if _, err := readFrame(r); err == nil {
+		t.Fatal("expected EOF")
+	}
+
+	t.Log("\tinvalid octet count")
+	{
+		r := bufio.NewReader(strings.NewReader("99999999 <13>x"))
+		if _, err := readFrame(r); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\toversized octet count")
+	{
+		r := bufio.NewReader(strings.NewReader(strings.Repeat("1", 10*maxMessageSize)))
+		_, err := readFrame(r)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if r.Buffered() > 4096 {
+			t.Fatalf("expected bounded read, %d bytes buffered", r.Buffered())
+		}
+	}
+
+	t.Log("\toversized message")
+	{
+		long := "<13>" + strings.Repeat("x", 2*maxMessageSize)
+		r := bufio.NewReader(strings.NewReader(long + "\n<13>next\n" + long))
+		for _, want := range []string{long[:maxMessageSize], "<13>next", long[:maxMessageSize]} {
+			got, err := readFrame(r)
+			if err != nil {
+				t.Fatalf("expected no error, got (%s)", err)
+			}
+			if got != want {
+				t.Fatalf("expected %d bytes %q..., got %d bytes %q...", len(want), want[:10], len(got), got[:10])
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tno config")
+	{
+		if _, err := New(context.Background(), filepath.Join("testdata", "missing")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tbad rule")
+	{
+		if _, err := New(context.Background(), filepath.Join("testdata", "bad_rule")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Log("Testing Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := New(ctx, filepath.Join("testdata", "syslog_collector"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	sc := c.(*Syslog)
+
+	udpConn, err := net.Dial("udp", sc.udpConns[0].LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dialing udp listener (%s)", err)
+	}
+	defer udpConn.Close()
+
+	udpMessages := []string{
+		"<38>Oct 15 22:14:15 gateway sshd[1234]: Failed password for root from 192.0.2.1 port 22 ssh2",
+		"<38>Oct 15 22:14:16 gateway sshd[1234]: Failed password for invalid user admin from 192.0.2.1 port 22 ssh2",
+		"<38>Oct 15 22:14:17 gateway sshd[1234]: Failed password for root from 192.0.2.1 port 22 ssh2",
+		"not syslog",
+	}
+	for _, m := range udpMessages {
+		if _, err := udpConn.Write([]byte(m)); err != nil {
+			t.Fatalf("writing udp message (%s)", err)
+		}
+	}
+
+	tcpConn, err := net.Dial("tcp", sc.tcpListens[0].Addr().String())
+	if err != nil {
+		t.Fatalf("dialing tcp listener (%s)", err)
+	}
+	defer tcpConn.Close()
+
+	tcpMessages := []string{
+		"<187>1 2020-10-15T22:14:15Z switch1 - - - - Interface Gi0/1, changed state to down",
+		"<190>1 2020-10-15T22:14:16Z switch1 envmon - - - temperature 41.5C",
+		"<190>1 2020-10-15T22:14:17Z switch1 envmon - - - temperature 43C",
+	}
+	for _, m := range tcpMessages {
+		if _, err := fmt.Fprintf(tcpConn, "%d %s", len(m), m); err != nil {
+			t.Fatalf("writing tcp message (%s)", err)
+		}
+	}
+
+	// wait for the messages to be handled
+	total := uint64(len(udpMessages) + len(tcpMessages))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sc.statsmu.Lock()
+		n := sc.parseErrors
+		for _, v := range sc.counts {
+			n += v
+		}
+		sc.statsmu.Unlock()
+		if n == total {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for messages, %d handled", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := c.Collect(ctx); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "messages", "severity:info", "program:sshd"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected sshd info messages 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "messages", "severity:err", "program:-"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected err messages without program 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "parse_errors"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected parse_errors 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "ssh_auth_failures", "user:root"); !ok || m.Value.(uint64) != 2 {
+		t.Fatalf("expected root ssh_auth_failures 2, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "ssh_auth_failures", "user:admin"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected admin ssh_auth_failures 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "link_down"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected link_down 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "temperature"); !ok || m.Value.(float64) != 43 {
+		t.Fatalf("expected temperature 43, got %v", m.Value)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ProcFSTree writes a procfs tree, files by path relative to the procfs root
+// (e.g. "loadavg", "net/dev", "1/stat"), to a temporary directory. The
+// directory is passed to a collector as its procfs path, call cleanup when
+// done. A recorded tree kept in a testdata directory can be passed as-is.
+func ProcFSTree(t testing.TB, files map[string]string) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "procfs")
+	if err != nil {
+		t.Fatalf("creating procfs tree, expected NO error, got (%s)", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			cleanup()
+			t.Fatalf("creating procfs tree, expected NO error, got (%s)", err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			cleanup()
+			t.Fatalf("creating procfs tree, expected NO error, got (%s)", err)
+		}
+	}
+
+	return dir, cleanup
+}
@@ -0,0 +1,126 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package testutil is a test harness for builtin collectors. Recorded procfs
+// trees (ProcFSTree or a testdata directory passed as the procfs path) and
+// recorded WMI result sets (WMIReplay) are run through a collector and the
+// emitted metrics asserted on, so collectors can be tested without the
+// hardware or OS they collect from.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// Collect runs a collection and returns the metrics flushed, the test fails
+// if the collection returns an error
+func Collect(t testing.TB, c collector.Collector) cgm.Metrics {
+	t.Helper()
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("%s collect, expected NO error, got (%s)", c.ID(), err)
+	}
+	return c.Flush()
+}
+
+// FindMetric returns the metric named name (without stream tags) having all of
+// the tags in tagList (cat:val, the metric may have additional tags e.g. base tags)
+func FindMetric(metrics cgm.Metrics, name string, tagList ...string) (cgm.Metric, bool) {
+	// tags as encoded in metric names (e.g. values are lower case)
+	_, want, _ := tags.SplitMetricStreamTags(tags.MetricNameWithStreamTags(name, tags.FromList(tagList)))
+	for fullName, metric := range metrics {
+		baseName, metricTags, _ := tags.SplitMetricStreamTags(fullName)
+		if baseName != name {
+			continue
+		}
+		if hasTags(metricTags, want) {
+			return metric, true
+		}
+	}
+	return cgm.Metric{}, false
+}
+
+// AssertMetric verifies the metric named name with the tags in tagList was
+// emitted with the type and value. Numeric values are compared by value
+// (e.g. uint64(1) matches int(1)), others must be equal.
+func AssertMetric(t testing.TB, metrics cgm.Metrics, name, mtype string, value interface{}, tagList ...string) {
+	t.Helper()
+	metric, ok := FindMetric(metrics, name, tagList...)
+	if !ok {
+		t.Fatalf("expected metric %s %v, have %v", name, tagList, MetricNames(metrics))
+	}
+	if metric.Type != mtype {
+		t.Fatalf("%s %v expected type %s, got %s", name, tagList, mtype, metric.Type)
+	}
+	if !equalValues(metric.Value, value) {
+		t.Fatalf("%s %v expected value %v (%T), got %v (%T)", name, tagList, value, value, metric.Value, metric.Value)
+	}
+}
+
+// AssertNoMetric verifies the metric named name with the tags in tagList was not emitted
+func AssertNoMetric(t testing.TB, metrics cgm.Metrics, name string, tagList ...string) {
+	t.Helper()
+	if metric, ok := FindMetric(metrics, name, tagList...); ok {
+		t.Fatalf("expected no metric %s %v, got %v", name, tagList, metric)
+	}
+}
+
+// MetricNames returns the sorted names (with stream tags) of the metrics, for failure messages
+func MetricNames(metrics cgm.Metrics) []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hasTags(have, want tags.Tags) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h.Category == w.Category && h.Value == w.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func equalValues(have, want interface{}) bool {
+	hf, hok := sample.Float64(have)
+	wf, wok := sample.Float64(want)
+	if !hok || !wok {
+		return reflect.DeepEqual(have, want)
+	}
+	if hi, ok := integer(have); ok {
+		if wi, ok := integer(want); ok {
+			return hi == wi
+		}
+	}
+	return hf == wf
+}
+
+// integer returns an integer value as a string, exact for 64 bit integers
+// (float64 is not above 2^53)
+func integer(v interface{}) (string, bool) {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), true
+	}
+	return "", false
+}
@@ -0,0 +1,193 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+type win32Memory struct {
+	AvailableBytes uint64
+	CacheBytes     uint64
+}
+
+type win32LogicalDisk struct {
+	Name             string
+	FreeMegabytes    uint32
+	PercentFreeSpace uint32
+}
+
+func TestFindMetric(t *testing.T) {
+	t.Log("Testing FindMetric")
+
+	metrics := cgm.Metrics{
+		tags.MetricNameWithStreamTags("load`1min", tags.Tags{{Category: "source", Value: "circonus-agent"}}):                                    {Type: "n", Value: 0.5},
+		tags.MetricNameWithStreamTags("disk`free", tags.Tags{{Category: "source", Value: "circonus-agent"}, {Category: "device", Value: "C:"}}): {Type: "L", Value: uint64(51200)},
+		"plain": {Type: "i", Value: 1},
+	}
+
+	t.Log("\tno tags")
+	{
+		AssertMetric(t, metrics, "load`1min", "n", 0.5)
+		AssertMetric(t, metrics, "plain", "i", uint64(1))
+	}
+
+	t.Log("\ttag subset")
+	{
+		AssertMetric(t, metrics, "disk`free", "L", 51200, "device:C:")
+		AssertNoMetric(t, metrics, "disk`free", "device:D:")
+	}
+
+	t.Log("\tmissing")
+	{
+		if _, ok := FindMetric(metrics, "load`5min"); ok {
+			t.Fatal("expected not found")
+		}
+	}
+
+	t.Log("\tvalues")
+	{
+		tt := []struct {
+			have  interface{}
+			want  interface{}
+			equal bool
+		}{
+			{uint64(1), 1, true},
+			{float64(2), uint32(2), true},
+			{0.5, 0.5, true},
+			{uint64(18446744073709551615), uint64(18446744073709551615), true},
+			{uint64(1), 2, false},
+			{"a", "a", true},
+			{"1", 1, false},
+		}
+		for _, tst := range tt {
+			if equalValues(tst.have, tst.want) != tst.equal {
+				t.Fatalf("%v (%T) %v (%T) expected equal %v", tst.have, tst.have, tst.want, tst.want, tst.equal)
+			}
+		}
+	}
+}
+
+func TestProcFSTree(t *testing.T) {
+	t.Log("Testing ProcFSTree")
+
+	dir, cleanup := ProcFSTree(t, map[string]string{
+		"loadavg": "0.50 0.40 0.30 1/100 1234\n",
+		"net/dev": "Inter-|   Receive\n",
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "net", "dev"))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if string(data) != "Inter-|   Receive\n" {
+		t.Fatalf("unexpected content (%s)", string(data))
+	}
+
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected removed, got (%v)", err)
+	}
+}
+
+func TestWMIReplay(t *testing.T) {
+	t.Log("Testing WMIReplay")
+
+	t.Log("\trecording")
+	{
+		r, err := NewWMIReplay(filepath.Join("testdata", "wmi_recording.json"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		var mem []win32Memory
+		if err := r.Query("SELECT AvailableBytes, CacheBytes FROM Win32_PerfFormattedData_PerfOS_Memory", &mem); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(mem) != 1 || mem[0].AvailableBytes != 4294967296 {
+			t.Fatalf("unexpected result %#v", mem)
+		}
+
+		disks := []win32LogicalDisk{{Name: "stale"}}
+		if err := r.QueryNamespace("SELECT Name FROM win32_perfformatteddata_perfdisk_logicaldisk WHERE Name = 'C:'", &disks, `root\CIMV2`); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(disks) != 2 || disks[0].Name != "C:" || disks[0].FreeMegabytes != 51200 {
+			t.Fatalf("unexpected result %#v", disks)
+		}
+
+		if err := r.Query("SELECT Name FROM Win32_Processor", &disks); err == nil {
+			t.Fatal("expected error (no result set)")
+		}
+		if err := r.Query("SELECT Name FROM Win32_PerfFormattedData_PerfOS_Memory", mem); err == nil {
+			t.Fatal("expected error (invalid destination)")
+		}
+
+		if n := len(r.Queries()); n != 4 {
+			t.Fatalf("expected 4 queries, got %d", n)
+		}
+	}
+
+	t.Log("\tresults")
+	{
+		r, err := WMIResults(map[string]interface{}{
+			"Win32_PerfFormattedData_PerfOS_Memory": []win32Memory{{AvailableBytes: 1, CacheBytes: 2}},
+		})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		var mem []win32Memory
+		if err := r.Query("SELECT * FROM Win32_PerfFormattedData_PerfOS_Memory", &mem); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(mem) != 1 || mem[0].CacheBytes != 2 {
+			t.Fatalf("unexpected result %#v", mem)
+		}
+	}
+
+	t.Log("\twrite recording")
+	{
+		dir, err := ioutil.TempDir("", "wmireplay")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		defer os.RemoveAll(dir)
+
+		file := filepath.Join(dir, "recording.json")
+		if err := WriteWMIRecording(file, map[string]interface{}{
+			"Win32_PerfFormattedData_PerfDisk_LogicalDisk": []win32LogicalDisk{{Name: "D:", PercentFreeSpace: 10}},
+		}); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		r, err := NewWMIReplay(file)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		var disks []win32LogicalDisk
+		if err := r.Query("SELECT * FROM Win32_PerfFormattedData_PerfDisk_LogicalDisk", &disks); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(disks) != 1 || disks[0].PercentFreeSpace != 10 {
+			t.Fatalf("unexpected result %#v", disks)
+		}
+	}
+
+	t.Log("\tinvalid recording")
+	{
+		if _, err := NewWMIReplay(filepath.Join("testdata", "missing.json")); err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := WMIResults(map[string]interface{}{"Win32_Processor": 1}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
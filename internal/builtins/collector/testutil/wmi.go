@@ -0,0 +1,128 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var wmiClassRx = regexp.MustCompile(`(?i)\bFROM\s+([A-Za-z0-9_]+)`)
+
+// WMIReplay answers WMI queries with recorded result sets. A recording is a
+// JSON object of result sets by class, each an array of objects with the
+// class properties, e.g.
+//
+//	{"Win32_PerfFormattedData_PerfOS_Memory": [{"AvailableBytes": 1024, ...}]}
+//
+// PowerShell output can be used (Get-CimInstance -ClassName X | Select-Object *
+// | ConvertTo-Json), properties not in the collector's destination struct are
+// ignored. The Query and QueryNamespace methods have the signatures of the
+// github.com/StackExchange/wmi functions they replace. WHERE clauses are not
+// evaluated, the whole result set of the class is returned.
+type WMIReplay struct {
+	results map[string]json.RawMessage // by lower case class name
+	queries []string
+	sync.Mutex
+}
+
+// NewWMIReplay loads a recording file
+func NewWMIReplay(file string) (*WMIReplay, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading wmi recording")
+	}
+	return parseWMIRecording(data)
+}
+
+// WMIResults returns a replay of result sets by class, each a slice of
+// structs or maps with the class properties (e.g. the collector's
+// destination struct filled in by the test)
+func WMIResults(results map[string]interface{}) (*WMIReplay, error) {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding wmi results")
+	}
+	return parseWMIRecording(data)
+}
+
+// WriteWMIRecording writes result sets by class (e.g. the destinations of
+// wmi.Query run on a host) as a recording file for NewWMIReplay
+func WriteWMIRecording(file string, results map[string]interface{}) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding wmi recording")
+	}
+	return errors.Wrap(ioutil.WriteFile(file, data, 0644), "writing wmi recording")
+}
+
+func parseWMIRecording(data []byte) (*WMIReplay, error) {
+	var recording map[string]json.RawMessage
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return nil, errors.Wrap(err, "parsing wmi recording")
+	}
+
+	r := &WMIReplay{results: make(map[string]json.RawMessage, len(recording))}
+	for class, rows := range recording {
+		rows = bytes.TrimSpace(rows)
+		if bytes.HasPrefix(rows, []byte("{")) {
+			// ConvertTo-Json emits a single instance as an object
+			rows = append(append([]byte("["), rows...), ']')
+		}
+		if !bytes.HasPrefix(rows, []byte("[")) {
+			return nil, errors.Errorf("invalid wmi recording, %s result set is not an array", class)
+		}
+		r.results[strings.ToLower(class)] = rows
+	}
+	return r, nil
+}
+
+// Query decodes the recorded result set of the queried class into dst, a
+// pointer to a slice of the class struct
+func (r *WMIReplay) Query(query string, dst interface{}, connectServerArgs ...interface{}) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.queries = append(r.queries, query)
+
+	m := wmiClassRx.FindStringSubmatch(query)
+	if m == nil {
+		return errors.Errorf("no class in wmi query (%s)", query)
+	}
+
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("invalid wmi query destination (%T), expected pointer to slice", dst)
+	}
+
+	rows, ok := r.results[strings.ToLower(m[1])]
+	if !ok {
+		return errors.Errorf("no recorded result set for class %s", m[1])
+	}
+
+	dv.Elem().SetLen(0)
+	return errors.Wrapf(json.Unmarshal(rows, dst), "decoding recorded %s result set", m[1])
+}
+
+// QueryNamespace decodes the recorded result set of the queried class into
+// dst, the namespace is not used
+func (r *WMIReplay) QueryNamespace(query string, dst interface{}, namespace string) error {
+	return r.Query(query, dst)
+}
+
+// Queries returns the queries run, in order
+func (r *WMIReplay) Queries() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string(nil), r.queries...)
+}
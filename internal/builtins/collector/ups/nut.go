@@ -0,0 +1,322 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package ups
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// NUT metrics from a NUT upsd server (or compatible, e.g. the Synology and
+// QNAP UPS servers), numeric variables and ups.status flags of each UPS
+type NUT struct {
+	common
+	address string
+	upsList []string
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	timeout time.Duration
+}
+
+// nutOptions defines what elements can be overridden in a config file
+type nutOptions struct {
+	commonOptions
+
+	// collector specific
+	Address      string   `json:"address" toml:"address" yaml:"address"`
+	UPS          []string `json:"ups" toml:"ups" yaml:"ups"`
+	IncludeRegex string   `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex string   `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	Timeout      string   `json:"timeout" toml:"timeout" yaml:"timeout"`
+}
+
+const (
+	defaultNUTAddress = "localhost:3493"
+	regexPat          = `^(?:%s)$` // fmt pattern used compile include/exclude regular expressions
+)
+
+var (
+	defaultIncludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, ".+"))
+	defaultExcludeRegex = regexp.MustCompile(fmt.Sprintf(regexPat, `(driver|device)\..+`))
+
+	// upsStatusFlags maps the ups.status flags to metric names
+	upsStatusFlags = []struct{ flag, name string }{
+		{"OL", "status_online"},
+		{"OB", "status_on_battery"},
+		{"LB", "status_low_battery"},
+		{"RB", "status_replace_battery"},
+		{"CHRG", "status_charging"},
+		{"DISCHRG", "status_discharging"},
+		{"OVER", "status_overload"},
+		{"BYPASS", "status_bypass"},
+	}
+
+	// nutVarUnits maps variable name suffixes to units
+	nutVarUnits = []struct{ suffix, units string }{
+		{".charge", "percent"},
+		{".load", "percent"},
+		{".runtime", "seconds"},
+		{".voltage", "volts"},
+		{".current", "amperes"},
+		{".frequency", "hertz"},
+		{".temperature", "celsius"},
+		{".realpower", "watts"},
+		{".power", "volt-amperes"},
+	}
+)
+
+// NewNUTCollector creates new nut ups collector
+func NewNUTCollector(cfgBaseName string) (collector.Collector, error) {
+	c := NUT{
+		common:  newCommon(NameNUT, tags.FromList(tags.GetBaseTags())),
+		address: defaultNUTAddress,
+		include: defaultIncludeRegex,
+		exclude: defaultExcludeRegex,
+		timeout: defaultTimeout,
+	}
+
+	var opts nutOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.Address != "" {
+		c.address = opts.Address
+	}
+
+	if len(opts.UPS) > 0 {
+		c.upsList = opts.UPS
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	if opts.Timeout != "" {
+		dur, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing timeout", c.pkgID)
+		}
+		c.timeout = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from upsd
+func (c *NUT) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	client, err := nutConnect(cctx, c.address)
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+	defer client.close()
+
+	upsList := c.upsList
+	if len(upsList) == 0 {
+		upsList, err = client.listUPS()
+		if err != nil {
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+	}
+
+	for _, upsName := range upsList {
+		vars, err := client.listVars(upsName)
+		if err != nil {
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+		c.addUPSMetrics(&metrics, upsName, vars)
+	}
+
+	_ = c.addMetric(&metrics, "", "ups_devices", "I", len(upsList), tags.Tags{})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// addUPSMetrics adds the numeric variables matching the include/exclude
+// regexes and the ups.status flags of a UPS
+func (c *NUT) addUPSMetrics(metrics *cgm.Metrics, upsName string, vars map[string]string) {
+	upsTag := tags.Tag{Category: "ups", Value: upsName}
+
+	for name, val := range vars {
+		if c.exclude.MatchString(name) || !c.include.MatchString(name) {
+			continue
+		}
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			continue // e.g. ups.status, ups.mfr
+		}
+		mtags := tags.Tags{upsTag}
+		for _, u := range nutVarUnits {
+			if strings.HasSuffix(name, u.suffix) {
+				mtags = append(mtags, tags.Tag{Category: "units", Value: u.units})
+				break
+			}
+		}
+		_ = c.addMetric(metrics, "", strings.NewReplacer(".", "_", "-", "_").Replace(name), "n", v, mtags)
+	}
+
+	status, ok := vars["ups.status"]
+	if !ok {
+		return
+	}
+	flags := make(map[string]bool)
+	for _, f := range strings.Fields(status) {
+		flags[f] = true
+	}
+	for _, s := range upsStatusFlags {
+		v := 0
+		if flags[s.flag] {
+			v = 1
+		}
+		_ = c.addMetric(metrics, "", s.name, "I", v, tags.Tags{upsTag})
+	}
+}
+
+// nutClient a connection to a NUT server speaking the line based network protocol
+type nutClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// nutConnect connects to a NUT server, the context deadline applies to
+// all of the requests made on the connection
+func nutConnect(ctx context.Context, address string) (*nutClient, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to upsd")
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return &nutClient{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// close logs out and closes the connection
+func (nc *nutClient) close() {
+	_, _ = nc.conn.Write([]byte("LOGOUT\n"))
+	nc.conn.Close()
+}
+
+// list sends a LIST command and returns the lines between
+// "BEGIN LIST <query>" and "END LIST <query>"
+func (nc *nutClient) list(query string) ([]string, error) {
+	if _, err := fmt.Fprintf(nc.conn, "LIST %s\n", query); err != nil {
+		return nil, errors.Wrap(err, "sending upsd command")
+	}
+
+	begin := "BEGIN LIST " + query
+	end := "END LIST " + query
+	started := false
+	lines := []string{}
+	for {
+		line, err := nc.reader.ReadString('\n')
+		if err != nil {
+			return nil, errors.Wrap(err, "reading upsd reply")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "ERR "):
+			return nil, errors.Errorf("upsd LIST %s: %s", query, strings.TrimPrefix(line, "ERR "))
+		case line == begin:
+			started = true
+		case line == end:
+			return lines, nil
+		case started:
+			lines = append(lines, line)
+		}
+	}
+}
+
+// listUPS returns the names of the UPS devices of the server, from the
+// `UPS <upsname> "<description>"` lines
+func (nc *nutClient) listUPS() ([]string, error) {
+	lines, err := nc.list("UPS")
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "UPS" {
+			continue
+		}
+		names = append(names, fields[1])
+	}
+	return names, nil
+}
+
+// listVars returns the variables of a UPS, from the
+// `VAR <upsname> <varname> "<value>"` lines
+func (nc *nutClient) listVars(upsName string) (map[string]string, error) {
+	lines, err := nc.list("VAR " + upsName)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(lines))
+	for _, line := range lines {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 || fields[0] != "VAR" {
+			continue
+		}
+		vars[fields[2]] = unquoteNUT(fields[3])
+	}
+	return vars, nil
+}
+
+// unquoteNUT removes the quotes from a value, \" and \\ are unescaped
+func unquoteNUT(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,190 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package ups
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+// startUpsd starts a test upsd serving the "office" UPS with the variables
+// in testdata/upsd_vars.txt, returns the listen address
+func startUpsd(t *testing.T) (string, func()) {
+	t.Helper()
+
+	vars, err := ioutil.ReadFile(filepath.Join("testdata", "upsd_vars.txt"))
+	if err != nil {
+		t.Fatalf("reading upsd vars (%s)", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening (%s)", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					switch cmd := scanner.Text(); cmd {
+					case "LIST UPS":
+						fmt.Fprint(conn, "BEGIN LIST UPS\nUPS office \"Back-UPS ES 700G\"\nEND LIST UPS\n")
+					case "LIST VAR office":
+						fmt.Fprintf(conn, "BEGIN LIST VAR office\n%sEND LIST VAR office\n", vars)
+					case "LOGOUT":
+						fmt.Fprint(conn, "OK Goodbye\n")
+						return
+					default:
+						if strings.HasPrefix(cmd, "LIST VAR ") {
+							fmt.Fprint(conn, "ERR UNKNOWN-UPS\n")
+							continue
+						}
+						fmt.Fprint(conn, "ERR UNKNOWN-COMMAND\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+func TestUnquoteNUT(t *testing.T) {
+	t.Log("Testing unquoteNUT")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"87"`, "87"},
+		{`"OL CHRG"`, "OL CHRG"},
+		{`"say \"hi\" \\o/"`, `say "hi" \o/`},
+		{`100`, "100"},
+	}
+
+	for _, tt := range tests {
+		if got := unquoteNUT(tt.in); got != tt.want {
+			t.Fatalf("expected (%s), got (%s)", tt.want, got)
+		}
+	}
+}
+
+func TestNewNUTCollector(t *testing.T) {
+	t.Log("Testing NewNUTCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tno config")
+	{
+		c, err := NewNUTCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*NUT).address != defaultNUTAddress || len(c.(*NUT).upsList) != 0 {
+			t.Fatalf("expected defaults, got %#v", c)
+		}
+	}
+
+	t.Log("\tconfig settings")
+	{
+		c, err := NewNUTCollector(filepath.Join("testdata", "config_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		n := c.(*NUT)
+		if n.address != "192.0.2.10:3493" || len(n.upsList) != 2 || n.exclude.MatchString("device.model") {
+			t.Fatalf("expected settings applied, got %#v", c)
+		}
+	}
+
+	for _, cfg := range []string{"bad_include_regex", "bad_timeout"} {
+		t.Logf("\t%s", cfg)
+		if _, err := NewNUTCollector(filepath.Join("testdata", cfg)); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestNUTCollect(t *testing.T) {
+	t.Log("Testing NUT Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	addr, stop := startUpsd(t)
+	defer stop()
+
+	c, err := NewNUTCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	c.(*NUT).address = addr
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "ups_devices"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected ups_devices 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "battery_charge", "ups:office", "units:percent"); !ok || m.Value.(float64) != 87 {
+		t.Fatalf("expected battery_charge 87, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "battery_runtime", "ups:office", "units:seconds"); !ok || m.Value.(float64) != 1620 {
+		t.Fatalf("expected battery_runtime 1620, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "input_voltage", "units:volts"); !ok || m.Value.(float64) != 229 {
+		t.Fatalf("expected input_voltage 229, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "ups_load", "units:percent"); !ok || m.Value.(float64) != 23 {
+		t.Fatalf("expected ups_load 23, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "status_on_battery", "ups:office"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected status_on_battery 1, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "status_online", "ups:office"); !ok || m.Value.(int) != 0 {
+		t.Fatalf("expected status_online 0, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "status_discharging", "ups:office"); !ok || m.Value.(int) != 1 {
+		t.Fatalf("expected status_discharging 1, got %v", m.Value)
+	}
+	for _, name := range []string{"driver_parameter_pollinterval", "ups_status", "ups_mfr", "battery_mfr_date"} {
+		if _, ok := testutil.FindMetric(metrics, name); ok {
+			t.Fatalf("expected no %s", name)
+		}
+	}
+
+	t.Log("\tunknown ups")
+	{
+		c.(*NUT).upsList = []string{"rack"}
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tno server")
+	{
+		stop()
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
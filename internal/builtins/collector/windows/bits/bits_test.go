@@ -0,0 +1,97 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package bits
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const jobList = `
+BITSADMIN version 3.0
+BITS administration utility.
+(C) Copyright Microsoft Corp.
+
+{6511FB02-E195-40A2-B595-E8E2F8F47702} 'Windows Update' SUSPENDED 0 / 1 0 / UNKNOWN
+{A1B2C3D4-0000-1111-2222-333344445555} 'Edge Update' TRANSFERRING 1 / 3 1024 / 4096
+{B1B2C3D4-0000-1111-2222-333344445555} 'Store' ERROR 0 / 2 0 / 2048
+{C1B2C3D4-0000-1111-2222-333344445555} 'Done' TRANSFERRED 2 / 2 8192 / 8192
+Listed 4 job(s).
+`
+
+func TestParseJobList(t *testing.T) {
+	t.Log("Testing parseJobList")
+
+	jobs := parseJobList([]byte(jobList))
+	if len(jobs) != 4 {
+		t.Fatalf("expected 4 jobs, got %d", len(jobs))
+	}
+	if jobs[0].state != "SUSPENDED" || !jobs[0].bytesUnknown {
+		t.Fatalf("unexpected job %+v", jobs[0])
+	}
+	if jobs[1].filesDone != 1 || jobs[1].filesTotal != 3 || jobs[1].bytesTotal != 4096 {
+		t.Fatalf("unexpected job %+v", jobs[1])
+	}
+
+	t.Log("\tno jobs")
+	{
+		jobs := parseJobList([]byte("Listed 0 job(s).\n"))
+		if len(jobs) != 0 {
+			t.Fatalf("expected 0 jobs, got %d", len(jobs))
+		}
+	}
+}
+
+func TestJobsCollect(t *testing.T) {
+	t.Log("Testing Jobs Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	orig := runCommand
+	defer func() { runCommand = orig }()
+	runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+		return []byte(jobList), nil
+	}
+
+	c, err := NewJobsCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "backlog"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected backlog 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "jobs", "state:error"); !ok || m.Value.(uint64) != 1 {
+		t.Fatalf("expected 1 error job, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "jobs", "state:queued"); !ok || m.Value.(uint64) != 0 {
+		t.Fatalf("expected 0 queued jobs, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "files_pending"); !ok || m.Value.(uint64) != 5 {
+		t.Fatalf("expected files_pending 5, got %v", m.Value)
+	}
+	// unknown sizes are not included
+	if m, ok := testutil.FindMetric(metrics, "bytes_pending"); !ok || m.Value.(uint64) != 5120 {
+		t.Fatalf("expected bytes_pending 5120, got %v", m.Value)
+	}
+
+	t.Log("\tbitsadmin error")
+	{
+		runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) { return nil, errors.New("boom") }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package bits
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Jobs metrics from `bitsadmin /list /allusers` (BITS transfer jobs of all
+// users, by state)
+type Jobs struct {
+	common
+	bitsadminPath string
+}
+
+// jobsOptions defines what elements can be overridden in a config file
+type jobsOptions struct {
+	commonOptions
+
+	// collector specific
+	BitsadminPath string `json:"bitsadmin_path" toml:"bitsadmin_path" yaml:"bitsadmin_path"`
+}
+
+// job is a single BITS job from the bitsadmin job list
+type job struct {
+	state        string
+	filesDone    uint64
+	filesTotal   uint64
+	bytesDone    uint64
+	bytesTotal   uint64
+	bytesUnknown bool
+}
+
+const defaultBitsadminPath = "bitsadmin" // resolved using PATH
+
+// jobStates BG_JOB_STATE names as listed by bitsadmin, the last three are
+// finished jobs which are not part of the backlog
+var jobStates = []string{
+	"QUEUED",
+	"CONNECTING",
+	"TRANSFERRING",
+	"SUSPENDED",
+	"ERROR",
+	"TRANSIENT_ERROR",
+	"TRANSFERRED",
+	"ACKNOWLEDGED",
+	"CANCELLED",
+}
+
+// {GUID} 'display name' STATE files_done / files_total bytes_done / bytes_total
+var jobLineRx = regexp.MustCompile(`^\{[0-9A-Fa-f-]+\}\s+'.*'\s+([A-Z_]+)\s+(\d+)\s*/\s*(\d+)\s+(\d+|UNKNOWN)\s*/\s*(\d+|UNKNOWN)\s*$`)
+
+// runCommand runs a system utility and returns its output, overridden in tests
+var runCommand = func(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, cmd, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s %s", cmd, strings.Join(args, " "))
+	}
+	return out, nil
+}
+
+// NewJobsCollector creates new bits jobs collector
+func NewJobsCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Jobs{
+		common:        newCommon(NameJobs, tags.FromList(tags.GetBaseTags())),
+		bitsadminPath: defaultBitsadminPath,
+	}
+
+	var opts jobsOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.BitsadminPath != "" {
+		c.bitsadminPath = opts.BitsadminPath
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from bitsadmin
+func (c *Jobs) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	out, err := runCommand(ctx, c.bitsadminPath, "/list", "/allusers")
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	jobs := parseJobList(out)
+
+	counts := make(map[string]uint64, len(jobStates))
+	for _, s := range jobStates {
+		counts[s] = 0
+	}
+
+	backlog := uint64(0)
+	filesPending := uint64(0)
+	bytesPending := uint64(0)
+	for _, j := range jobs {
+		counts[j.state]++
+		switch j.state {
+		case "TRANSFERRED", "ACKNOWLEDGED", "CANCELLED":
+			continue
+		}
+		backlog++
+		if j.filesTotal > j.filesDone {
+			filesPending += j.filesTotal - j.filesDone
+		}
+		if !j.bytesUnknown && j.bytesTotal > j.bytesDone {
+			bytesPending += j.bytesTotal - j.bytesDone
+		}
+	}
+
+	tagUnitsJobs := tags.Tag{Category: "units", Value: "jobs"}
+
+	for state, n := range counts {
+		_ = c.addMetric(&metrics, "", "jobs", "L", n, tags.Tags{tagUnitsJobs, tags.Tag{Category: "state", Value: strings.ToLower(state)}})
+	}
+	_ = c.addMetric(&metrics, "", "backlog", "L", backlog, tags.Tags{tagUnitsJobs})
+	_ = c.addMetric(&metrics, "", "files_pending", "L", filesPending, tags.Tags{tags.Tag{Category: "units", Value: "files"}})
+	_ = c.addMetric(&metrics, "", "bytes_pending", "L", bytesPending, tags.Tags{tags.Tag{Category: "units", Value: "bytes"}})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// parseJobList parses the job lines of bitsadmin /list output, banner and
+// summary lines are ignored
+func parseJobList(data []byte) []job {
+	jobs := []job{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		m := jobLineRx.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		j := job{state: m[1]}
+		j.filesDone, _ = strconv.ParseUint(m[2], 10, 64)
+		j.filesTotal, _ = strconv.ParseUint(m[3], 10, 64)
+		if m[4] == "UNKNOWN" || m[5] == "UNKNOWN" {
+			j.bytesUnknown = true
+		} else {
+			j.bytesDone, _ = strconv.ParseUint(m[4], 10, 64)
+			j.bytesTotal, _ = strconv.ParseUint(m[5], 10, 64)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
@@ -0,0 +1,98 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func TestScopeCollect(t *testing.T) {
+	t.Log("Testing Scope Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	origMIB, origDeclined := readMIB, readDeclined
+	defer func() { readMIB, readDeclined = origMIB, origDeclined }()
+
+	readMIB = func() (*serverMIB, error) {
+		return &serverMIB{
+			discovers: 100,
+			declines:  4,
+			scopes: []scopeMIB{
+				{subnet: net.ParseIP("10.1.0.0").To4(), inUse: 190, free: 10, pendingOffers: 2},
+				{subnet: net.ParseIP("10.2.0.0").To4(), inUse: 0, free: 0},
+			},
+		}, nil
+	}
+	readDeclined = func(subnet net.IP) (uint64, error) {
+		if subnet.String() == "10.2.0.0" {
+			return 0, errors.New("boom")
+		}
+		return 3, nil
+	}
+
+	c, err := NewScopeCollector(filepath.Join("testdata", "missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	metrics := c.Flush()
+
+	if m, ok := testutil.FindMetric(metrics, "utilization", "scope:10.1.0.0"); !ok || m.Value.(float64) != 95 {
+		t.Fatalf("expected 10.1.0.0 utilization 95, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "in_use", "scope:10.1.0.0"); !ok || m.Value.(uint32) != 190 {
+		t.Fatalf("expected 10.1.0.0 in_use 190, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "declined", "scope:10.1.0.0"); !ok || m.Value.(uint64) != 3 {
+		t.Fatalf("expected 10.1.0.0 declined 3, got %v", m.Value)
+	}
+	if m, ok := testutil.FindMetric(metrics, "utilization", "scope:10.2.0.0"); !ok || m.Value.(float64) != 0 {
+		t.Fatalf("expected empty scope utilization 0, got %v", m.Value)
+	}
+	if _, ok := testutil.FindMetric(metrics, "declined", "scope:10.2.0.0"); ok {
+		t.Fatal("expected no declined metric when enumeration fails")
+	}
+	if m, ok := testutil.FindMetric(metrics, "declines"); !ok || m.Value.(uint32) != 4 {
+		t.Fatalf("expected declines 4, got %v", m.Value)
+	}
+
+	t.Log("\texclude scope, no declined")
+	{
+		sc := c.(*Scope)
+		sc.exclude = regexp.MustCompile(fmt.Sprintf(regexPat, `10\.2\..*`))
+		sc.reportDeclined = false
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		metrics := c.Flush()
+		if _, ok := testutil.FindMetric(metrics, "in_use", "scope:10.2.0.0"); ok {
+			t.Fatal("expected 10.2.0.0 to be excluded")
+		}
+		if _, ok := testutil.FindMetric(metrics, "declined"); ok {
+			t.Fatal("expected no declined metrics")
+		}
+	}
+
+	t.Log("\tmib error")
+	{
+		readMIB = func() (*serverMIB, error) { return nil, errors.New("boom") }
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
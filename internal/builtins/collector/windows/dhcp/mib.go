@@ -0,0 +1,29 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package dhcp
+
+import "net"
+
+// serverMIB DHCP_MIB_INFO_V5 server message counters (since the service started)
+type serverMIB struct {
+	discovers     uint32
+	offers        uint32
+	requests      uint32
+	acks          uint32
+	naks          uint32
+	declines      uint32
+	releases      uint32
+	delayedOffers uint32
+	scopes        []scopeMIB
+}
+
+// scopeMIB SCOPE_MIB_INFO_V5 per scope address counts
+type scopeMIB struct {
+	subnet        net.IP
+	inUse         uint32
+	free          uint32
+	pendingOffers uint32
+}
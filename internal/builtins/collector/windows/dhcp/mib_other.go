@@ -0,0 +1,22 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !windows
+
+package dhcp
+
+import (
+	"net"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+)
+
+func dhcpMIB() (*serverMIB, error) {
+	return nil, collector.ErrNotImplemented
+}
+
+func dhcpDeclined(subnet net.IP) (uint64, error) {
+	return 0, collector.ErrNotImplemented
+}
@@ -0,0 +1,203 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package dhcp
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modDhcpsapi                 = windows.NewLazySystemDLL("dhcpsapi.dll")
+	procDhcpGetMibInfoV5        = modDhcpsapi.NewProc("DhcpGetMibInfoV5")
+	procDhcpEnumSubnetClientsV5 = modDhcpsapi.NewProc("DhcpEnumSubnetClientsV5")
+	procDhcpRpcFreeMemory       = modDhcpsapi.NewProc("DhcpRpcFreeMemory")
+)
+
+const (
+	errorMoreData        = 234
+	errorNoMoreItems     = 259
+	addressStateMask     = 0x03
+	addressStateDeclined = 0x02
+	enumPreferredMax     = 65536
+)
+
+// dhcpMIBInfoV5 DHCP_MIB_INFO_V5
+type dhcpMIBInfoV5 struct {
+	Discovers               uint32
+	Offers                  uint32
+	Requests                uint32
+	Acks                    uint32
+	Naks                    uint32
+	Declines                uint32
+	Releases                uint32
+	ServerStartTimeLow      uint32
+	ServerStartTimeHigh     uint32
+	QtnNumLeases            uint32
+	QtnPctQtnLeases         uint32
+	QtnProbationLeases      uint32
+	QtnNonQtnLeases         uint32
+	QtnExemptLeases         uint32
+	QtnCapableClients       uint32
+	QtnIASErrors            uint32
+	DelayedOffers           uint32
+	ScopesWithDelayedOffers uint32
+	Scopes                  uint32
+	ScopeInfo               *scopeMIBInfoV5
+}
+
+// scopeMIBInfoV5 SCOPE_MIB_INFO_V5
+type scopeMIBInfoV5 struct {
+	Subnet            uint32
+	NumAddressesInuse uint32
+	NumAddressesFree  uint32
+	NumPendingOffers  uint32
+}
+
+// dhcpClientInfoV5 DHCP_CLIENT_INFO_V5
+type dhcpClientInfoV5 struct {
+	ClientIPAddress       uint32
+	SubnetMask            uint32
+	HardwareAddressLength uint32
+	HardwareAddress       *byte
+	ClientName            *uint16
+	ClientComment         *uint16
+	ClientLeaseExpires    uint64
+	OwnerIPAddress        uint32
+	OwnerNetBiosName      *uint16
+	OwnerHostName         *uint16
+	ClientType            byte
+	AddressState          byte
+}
+
+// dhcpClientInfoArrayV5 DHCP_CLIENT_INFO_ARRAY_V5
+type dhcpClientInfoArrayV5 struct {
+	NumElements uint32
+	Clients     **dhcpClientInfoV5
+}
+
+func ipFromDWORD(v uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, v)
+	return ip
+}
+
+func ipToDWORD(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+// dhcpMIB returns the local DHCP server mib counters
+func dhcpMIB() (*serverMIB, error) {
+	if err := procDhcpGetMibInfoV5.Find(); err != nil {
+		return nil, errors.Wrap(err, "dhcp server api not available")
+	}
+
+	var info *dhcpMIBInfoV5
+	r, _, _ := procDhcpGetMibInfoV5.Call(0, uintptr(unsafe.Pointer(&info))) // nil server, local
+	if r != 0 {
+		return nil, errors.Wrap(syscall.Errno(r), "DhcpGetMibInfoV5")
+	}
+	defer freeMemory(unsafe.Pointer(info))
+
+	mib := &serverMIB{
+		discovers:     info.Discovers,
+		offers:        info.Offers,
+		requests:      info.Requests,
+		acks:          info.Acks,
+		naks:          info.Naks,
+		declines:      info.Declines,
+		releases:      info.Releases,
+		delayedOffers: info.DelayedOffers,
+	}
+
+	if info.Scopes > 0 && info.ScopeInfo != nil {
+		scopes := (*[1 << 20]scopeMIBInfoV5)(unsafe.Pointer(info.ScopeInfo))[:info.Scopes:info.Scopes]
+		for _, s := range scopes {
+			mib.scopes = append(mib.scopes, scopeMIB{
+				subnet:        ipFromDWORD(s.Subnet),
+				inUse:         s.NumAddressesInuse,
+				free:          s.NumAddressesFree,
+				pendingOffers: s.NumPendingOffers,
+			})
+		}
+	}
+
+	return mib, nil
+}
+
+// dhcpDeclined returns the number of leases in a scope in the declined
+// (bad address) state
+func dhcpDeclined(subnet net.IP) (uint64, error) {
+	var declined uint64
+	var resume uint32
+	for {
+		var clients *dhcpClientInfoArrayV5
+		var read, total uint32
+		r, _, _ := procDhcpEnumSubnetClientsV5.Call(
+			0,
+			uintptr(ipToDWORD(subnet)),
+			uintptr(unsafe.Pointer(&resume)),
+			enumPreferredMax,
+			uintptr(unsafe.Pointer(&clients)),
+			uintptr(unsafe.Pointer(&read)),
+			uintptr(unsafe.Pointer(&total)))
+		if r != 0 && r != errorMoreData {
+			if r == errorNoMoreItems {
+				return declined, nil
+			}
+			return 0, errors.Wrap(syscall.Errno(r), "DhcpEnumSubnetClientsV5")
+		}
+
+		if clients != nil {
+			if clients.NumElements > 0 && clients.Clients != nil {
+				list := (*[1 << 20]*dhcpClientInfoV5)(unsafe.Pointer(clients.Clients))[:clients.NumElements:clients.NumElements]
+				for _, c := range list {
+					if c != nil && c.AddressState&addressStateMask == addressStateDeclined {
+						declined++
+					}
+				}
+			}
+			freeClients(clients)
+		}
+
+		if r != errorMoreData {
+			return declined, nil
+		}
+	}
+}
+
+// freeClients frees a client array returned by the dhcp server api
+func freeClients(clients *dhcpClientInfoArrayV5) {
+	if clients.NumElements > 0 && clients.Clients != nil {
+		list := (*[1 << 20]*dhcpClientInfoV5)(unsafe.Pointer(clients.Clients))[:clients.NumElements:clients.NumElements]
+		for _, c := range list {
+			if c == nil {
+				continue
+			}
+			freeMemory(unsafe.Pointer(c.HardwareAddress))
+			freeMemory(unsafe.Pointer(c.ClientName))
+			freeMemory(unsafe.Pointer(c.ClientComment))
+			freeMemory(unsafe.Pointer(c.OwnerNetBiosName))
+			freeMemory(unsafe.Pointer(c.OwnerHostName))
+			freeMemory(unsafe.Pointer(c))
+		}
+		freeMemory(unsafe.Pointer(clients.Clients))
+	}
+	freeMemory(unsafe.Pointer(clients))
+}
+
+func freeMemory(p unsafe.Pointer) {
+	if p != nil {
+		_, _, _ = procDhcpRpcFreeMemory.Call(uintptr(p))
+	}
+}
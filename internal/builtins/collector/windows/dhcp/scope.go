@@ -0,0 +1,158 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// Scope metrics from the DHCP server mib (scope utilization, leases in use,
+// declined leases) and the server message counters
+type Scope struct {
+	common
+	include        *regexp.Regexp
+	exclude        *regexp.Regexp
+	reportDeclined bool
+}
+
+// scopeOptions defines what elements can be overridden in a config file
+type scopeOptions struct {
+	commonOptions
+
+	// collector specific
+	IncludeRegex   string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex   string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	ReportDeclined string `json:"report_declined" toml:"report_declined" yaml:"report_declined"`
+}
+
+var (
+	// readMIB returns the dhcp server mib, overridden in tests
+	readMIB = dhcpMIB
+	// readDeclined returns the declined leases in a scope, overridden in tests
+	readDeclined = dhcpDeclined
+)
+
+// NewScopeCollector creates new dhcp scope collector
+func NewScopeCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Scope{
+		common:         newCommon(NameScope, tags.FromList(tags.GetBaseTags())),
+		include:        defaultIncludeRegex,
+		exclude:        defaultExcludeRegex,
+		reportDeclined: true,
+	}
+
+	var opts scopeOptions
+	if err := c.loadOptions(cfgBaseName, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyCommonOptions(opts.commonOptions); err != nil {
+		return nil, err
+	}
+
+	if opts.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	if opts.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, opts.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	if opts.ReportDeclined != "" {
+		rd, err := strconv.ParseBool(opts.ReportDeclined)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing report_declined", c.pkgID)
+		}
+		c.reportDeclined = rd
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the dhcp server
+func (c *Scope) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	if err := c.startRun(); err != nil {
+		return err
+	}
+
+	mib, err := readMIB()
+	if err != nil {
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	tagUnitsMessages := tags.Tag{Category: "units", Value: "messages"}
+	tagUnitsAddresses := tags.Tag{Category: "units", Value: "addresses"}
+	tagUnitsPercent := tags.Tag{Category: "units", Value: "percent"}
+
+	serverStats := []struct {
+		name  string
+		value uint32
+	}{
+		{"discovers", mib.discovers},
+		{"offers", mib.offers},
+		{"delayed_offers", mib.delayedOffers},
+		{"requests", mib.requests},
+		{"acks", mib.acks},
+		{"naks", mib.naks},
+		{"declines", mib.declines},
+		{"releases", mib.releases},
+	}
+	for _, s := range serverStats {
+		_ = c.addMetric(&metrics, "", s.name, "I", s.value, tags.Tags{tagUnitsMessages})
+	}
+	_ = c.addMetric(&metrics, "", "scopes", "I", len(mib.scopes), tags.Tags{})
+
+	for _, s := range mib.scopes {
+		scope := s.subnet.String()
+		if c.exclude.MatchString(scope) || !c.include.MatchString(scope) {
+			c.logger.Debug().Str("scope", scope).Msg("excluded scope, skipping")
+			continue
+		}
+
+		scopeTag := tags.Tag{Category: "scope", Value: scope}
+
+		_ = c.addMetric(&metrics, "", "in_use", "I", s.inUse, tags.Tags{scopeTag, tagUnitsAddresses})
+		_ = c.addMetric(&metrics, "", "free", "I", s.free, tags.Tags{scopeTag, tagUnitsAddresses})
+		_ = c.addMetric(&metrics, "", "pending_offers", "I", s.pendingOffers, tags.Tags{scopeTag, tagUnitsAddresses})
+
+		utilization := float64(0)
+		if total := uint64(s.inUse) + uint64(s.free); total > 0 {
+			utilization = (float64(s.inUse) / float64(total)) * 100
+		}
+		_ = c.addMetric(&metrics, "", "utilization", "n", utilization, tags.Tags{scopeTag, tagUnitsPercent})
+
+		if c.reportDeclined {
+			declined, err := readDeclined(s.subnet)
+			if err != nil {
+				c.logger.Warn().Err(err).Str("scope", scope).Msg("enumerating declined leases")
+				continue
+			}
+			_ = c.addMetric(&metrics, "", "declined", "L", declined, tags.Tags{scopeTag, tagUnitsAddresses})
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -86,19 +86,19 @@ func (c *common) addMetric(metrics *cgm.Metrics, pfx, mname, mtype string, mval
 		return errors.New("invalid metric, no type")
 	}
 
-	var tagList cgm.Tags
-	tagList = append(tagList, cgm.Tags{
+	tagList := tags.GetTags()
+	defer tags.PutTags(tagList)
+	*tagList = append(*tagList,
 		cgm.Tag{Category: "source", Value: release.NAME},
-		cgm.Tag{Category: "collector", Value: c.id},
-	}...)
-	tagList = append(tagList, c.baseTags...)
-	tagList = append(tagList, mtags...)
+		cgm.Tag{Category: "collector", Value: c.id})
+	*tagList = append(*tagList, c.baseTags...)
+	*tagList = append(*tagList, mtags...)
 
 	if pfx != "" {
 		mname = pfx + defaults.MetricNameSeparator + mname
 	}
 
-	metricName := tags.MetricNameWithStreamTags(c.cleanName(mname), tagList)
+	metricName := tags.MetricNameWithStreamTags(c.cleanName(mname), *tagList)
 	(*metrics)[metricName] = cgm.Metric{Type: mtype, Value: mval}
 
 	return nil
@@ -0,0 +1,66 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package pdh
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Decode appends an element to dst, a pointer to a slice of structs, for each
+// instance. The Name field is set to the instance name and numeric fields to
+// the counter value of the same (wmi property) name, fields without a value
+// are left zero. Fractional values are truncated, as in the wmi formatted
+// classes.
+func Decode(instances []Instance, dst interface{}) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Slice || dv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return errors.Errorf("invalid destination (%T), expected pointer to slice of structs", dst)
+	}
+
+	sv := dv.Elem()
+	et := sv.Type().Elem()
+
+	for _, inst := range instances {
+		ev := reflect.New(et).Elem()
+		for i := 0; i < et.NumField(); i++ {
+			sf := et.Field(i)
+			if sf.PkgPath != "" {
+				continue // unexported
+			}
+			fv := ev.Field(i)
+			if sf.Name == "Name" && fv.Kind() == reflect.String {
+				fv.SetString(inst.Name)
+				continue
+			}
+			v, ok := inst.Values[sf.Name]
+			if !ok || math.IsNaN(v) {
+				continue
+			}
+			switch fv.Kind() {
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				bits := fv.Type().Bits()
+				switch {
+				case v < 0:
+					fv.SetUint(0)
+				case v >= math.Ldexp(1, bits):
+					fv.SetUint(^uint64(0) >> uint(64-bits))
+				default:
+					fv.SetUint(uint64(v))
+				}
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				fv.SetInt(int64(v))
+			case reflect.Float32, reflect.Float64:
+				fv.SetFloat(v)
+			}
+		}
+		sv.Set(reflect.Append(sv, ev))
+	}
+
+	return nil
+}
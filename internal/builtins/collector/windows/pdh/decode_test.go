@@ -0,0 +1,82 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package pdh
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	t.Log("Testing Decode")
+
+	type class struct {
+		Name            string
+		DiskReadsPersec uint32
+		DiskBytesPersec uint64
+		PercentIdleTime uint64
+		Average         float64
+		Delta           int64
+		unexported      uint64
+	}
+
+	t.Log("\tinstances")
+	{
+		var dst []class
+		err := Decode([]Instance{
+			{Name: "C:", Values: map[string]float64{"DiskReadsPersec": 12.7, "DiskBytesPersec": 4096, "Average": 0.25, "Delta": -3, "unexported": 1}},
+			{Name: "_Total", Values: map[string]float64{"DiskReadsPersec": 12, "PercentIdleTime": math.NaN()}},
+		}, &dst)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(dst) != 2 {
+			t.Fatalf("expected 2 instances, got %d", len(dst))
+		}
+		expect := class{Name: "C:", DiskReadsPersec: 12, DiskBytesPersec: 4096, Average: 0.25, Delta: -3}
+		if dst[0] != expect {
+			t.Fatalf("expected %#v, got %#v", expect, dst[0])
+		}
+		if dst[1].Name != "_Total" || dst[1].DiskReadsPersec != 12 || dst[1].PercentIdleTime != 0 {
+			t.Fatalf("unexpected %#v", dst[1])
+		}
+	}
+
+	t.Log("\tout of range")
+	{
+		var dst []class
+		err := Decode([]Instance{{Values: map[string]float64{"DiskReadsPersec": 1e12, "DiskBytesPersec": -1}}}, &dst)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if dst[0].DiskReadsPersec != math.MaxUint32 || dst[0].DiskBytesPersec != 0 {
+			t.Fatalf("unexpected %#v", dst[0])
+		}
+	}
+
+	t.Log("\tappends")
+	{
+		dst := []class{{Name: "first"}}
+		if err := Decode([]Instance{{Name: "second"}}, &dst); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(dst) != 2 || dst[1].Name != "second" {
+			t.Fatalf("unexpected %#v", dst)
+		}
+	}
+
+	t.Log("\tinvalid destination")
+	{
+		var dst []class
+		if err := Decode(nil, dst); err == nil {
+			t.Fatal("expected error")
+		}
+		var names []string
+		if err := Decode(nil, &names); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
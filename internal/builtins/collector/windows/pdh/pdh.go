@@ -0,0 +1,182 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package pdh reads performance counters with the Performance Data Helper
+// (pdh.dll) api, an alternative to the wmi performance classes for the disk,
+// processor, memory and network interface collectors. WMI queries go through
+// the wmi service and its performance provider, they are heavyweight and
+// occasionally hang under load, pdh reads the counters in process.
+//
+// The counters of each object are named after the equivalent wmi class
+// properties, so the wmi collectors can decode them into their class structs
+// and emit the same metrics with either backend.
+package pdh
+
+// Object is a performance object and the counters read from it
+type Object struct {
+	Name      string            // english object name (e.g. LogicalDisk)
+	Instances bool              // multi-instance object, all instances (*) are read
+	Raw       bool              // raw counter values (e.g. cumulative byte counts), not cooked rates
+	Counters  map[string]string // english counter names by wmi property name
+}
+
+// Instance is an object instance (empty name for single instance objects)
+// and its counter values by wmi property name
+type Instance struct {
+	Name   string
+	Values map[string]float64
+}
+
+// diskCounters are common to the LogicalDisk and PhysicalDisk objects
+var diskCounters = map[string]string{
+	"AvgDiskBytesPerRead":     "Avg. Disk Bytes/Read",
+	"AvgDiskBytesPerTransfer": "Avg. Disk Bytes/Transfer",
+	"AvgDiskBytesPerWrite":    "Avg. Disk Bytes/Write",
+	"AvgDiskQueueLength":      "Avg. Disk Queue Length",
+	"AvgDiskReadQueueLength":  "Avg. Disk Read Queue Length",
+	"AvgDisksecPerRead":       "Avg. Disk sec/Read",
+	"AvgDisksecPerTransfer":   "Avg. Disk sec/Transfer",
+	"AvgDisksecPerWrite":      "Avg. Disk sec/Write",
+	"AvgDiskWriteQueueLength": "Avg. Disk Write Queue Length",
+	"CurrentDiskQueueLength":  "Current Disk Queue Length",
+	"DiskBytesPersec":         "Disk Bytes/sec",
+	"DiskReadBytesPersec":     "Disk Read Bytes/sec",
+	"DiskReadsPersec":         "Disk Reads/sec",
+	"DiskTransfersPersec":     "Disk Transfers/sec",
+	"DiskWriteBytesPersec":    "Disk Write Bytes/sec",
+	"DiskWritesPersec":        "Disk Writes/sec",
+	"PercentDiskReadTime":     "% Disk Read Time",
+	"PercentDiskTime":         "% Disk Time",
+	"PercentDiskWriteTime":    "% Disk Write Time",
+	"PercentIdleTime":         "% Idle Time",
+	"SplitIOPerSec":           "Split IO/Sec",
+}
+
+// LogicalDisk counters (Win32_PerfFormattedData_PerfDisk_LogicalDisk)
+var LogicalDisk = &Object{
+	Name:      "LogicalDisk",
+	Instances: true,
+	Counters: withCounters(diskCounters, map[string]string{
+		"FreeMegabytes":    "Free Megabytes",
+		"PercentFreeSpace": "% Free Space",
+	}),
+}
+
+// PhysicalDisk counters (Win32_PerfFormattedData_PerfDisk_PhysicalDisk)
+var PhysicalDisk = &Object{
+	Name:      "PhysicalDisk",
+	Instances: true,
+	Counters:  diskCounters,
+}
+
+// Processor counters (Win32_PerfFormattedData_PerfOS_Processor)
+var Processor = &Object{
+	Name:      "Processor",
+	Instances: true,
+	Counters: map[string]string{
+		"C1TransitionsPersec":   "C1 Transitions/sec",
+		"C2TransitionsPersec":   "C2 Transitions/sec",
+		"C3TransitionsPersec":   "C3 Transitions/sec",
+		"DPCsQueuedPersec":      "DPCs Queued/sec",
+		"InterruptsPersec":      "Interrupts/sec",
+		"PercentC1Time":         "% C1 Time",
+		"PercentC2Time":         "% C2 Time",
+		"PercentC3Time":         "% C3 Time",
+		"PercentDPCTime":        "% DPC Time",
+		"PercentIdleTime":       "% Idle Time",
+		"PercentInterruptTime":  "% Interrupt Time",
+		"PercentPrivilegedTime": "% Privileged Time",
+		"PercentProcessorTime":  "% Processor Time",
+		"PercentUserTime":       "% User Time",
+	},
+}
+
+// Memory counters (Win32_PerfFormattedData_PerfOS_Memory)
+var Memory = &Object{
+	Name: "Memory",
+	Counters: map[string]string{
+		"AvailableBytes":                  "Available Bytes",
+		"CacheBytes":                      "Cache Bytes",
+		"CacheFaultsPersec":               "Cache Faults/sec",
+		"CommittedBytes":                  "Committed Bytes",
+		"DemandZeroFaultsPersec":          "Demand Zero Faults/sec",
+		"FreeAndZeroPageListBytes":        "Free & Zero Page List Bytes",
+		"FreeSystemPageTableEntries":      "Free System Page Table Entries",
+		"ModifiedPageListBytes":           "Modified Page List Bytes",
+		"PageFaultsPersec":                "Page Faults/sec",
+		"PageReadsPersec":                 "Page Reads/sec",
+		"PagesInputPersec":                "Pages Input/sec",
+		"PagesOutputPersec":               "Pages Output/sec",
+		"PagesPersec":                     "Pages/sec",
+		"PageWritesPersec":                "Page Writes/sec",
+		"PercentCommittedBytesInUse":      "% Committed Bytes In Use",
+		"PoolNonpagedAllocs":              "Pool Nonpaged Allocs",
+		"PoolNonpagedBytes":               "Pool Nonpaged Bytes",
+		"PoolPagedAllocs":                 "Pool Paged Allocs",
+		"PoolPagedBytes":                  "Pool Paged Bytes",
+		"PoolPagedResidentBytes":          "Pool Paged Resident Bytes",
+		"StandbyCacheCoreBytes":           "Standby Cache Core Bytes",
+		"StandbyCacheNormalPriorityBytes": "Standby Cache Normal Priority Bytes",
+		"StandbyCacheReserveBytes":        "Standby Cache Reserve Bytes",
+		"SystemCacheResidentBytes":        "System Cache Resident Bytes",
+		"SystemCodeResidentBytes":         "System Code Resident Bytes",
+		"SystemCodeTotalBytes":            "System Code Total Bytes",
+		"SystemDriverTotalBytes":          "System Driver Total Bytes",
+		"TransitionFaultsPersec":          "Transition Faults/sec",
+		"TransitionPagesRePurposedPersec": "Transition Pages RePurposed/sec",
+		"WriteCopiesPersec":               "Write Copies/sec",
+	},
+}
+
+// NetworkInterface raw counters (Win32_PerfRawData_Tcpip_NetworkInterface)
+var NetworkInterface = &Object{
+	Name:      "Network Interface",
+	Instances: true,
+	Raw:       true,
+	Counters: map[string]string{
+		"BytesReceivedPersec":             "Bytes Received/sec",
+		"BytesSentPersec":                 "Bytes Sent/sec",
+		"BytesTotalPersec":                "Bytes Total/sec",
+		"CurrentBandwidth":                "Current Bandwidth",
+		"OffloadedConnections":            "Offloaded Connections",
+		"OutputQueueLength":               "Output Queue Length",
+		"PacketsOutboundDiscarded":        "Packets Outbound Discarded",
+		"PacketsOutboundErrors":           "Packets Outbound Errors",
+		"PacketsPersec":                   "Packets/sec",
+		"PacketsReceivedDiscarded":        "Packets Received Discarded",
+		"PacketsReceivedErrors":           "Packets Received Errors",
+		"PacketsReceivedNonUnicastPersec": "Packets Received Non-Unicast/sec",
+		"PacketsReceivedPersec":           "Packets Received/sec",
+		"PacketsReceivedUnicastPersec":    "Packets Received Unicast/sec",
+		"PacketsReceivedUnknown":          "Packets Received Unknown",
+		"PacketsSentNonUnicastPersec":     "Packets Sent Non-Unicast/sec",
+		"PacketsSentPersec":               "Packets Sent/sec",
+		"PacketsSentUnicastPersec":        "Packets Sent Unicast/sec",
+		"TCPActiveRSCConnections":         "TCP Active RSC Connections",
+		"TCPRSCAveragePacketSize":         "TCP RSC Average Packet Size",
+		"TCPRSCCoalescedPacketsPersec":    "TCP RSC Coalesced Packets/sec",
+		"TCPRSCExceptionsPersec":          "TCP RSC Exceptions/sec",
+	},
+}
+
+// withCounters returns the union of counter sets
+func withCounters(sets ...map[string]string) map[string]string {
+	counters := make(map[string]string)
+	for _, set := range sets {
+		for property, counter := range set {
+			counters[property] = counter
+		}
+	}
+	return counters
+}
+
+// CounterPath returns the path of a counter of the object, all instances of
+// a multi-instance object (e.g. \Processor(*)\% Processor Time)
+func (o *Object) CounterPath(counter string) string {
+	if o.Instances {
+		return `\` + o.Name + `(*)\` + counter
+	}
+	return `\` + o.Name + `\` + counter
+}
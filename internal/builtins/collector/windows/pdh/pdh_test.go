@@ -0,0 +1,27 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package pdh
+
+import (
+	"testing"
+)
+
+func TestCounterPath(t *testing.T) {
+	t.Log("Testing CounterPath")
+
+	if p := Processor.CounterPath("% Processor Time"); p != `\Processor(*)\% Processor Time` {
+		t.Fatalf("unexpected path %s", p)
+	}
+	if p := Memory.CounterPath("Available Bytes"); p != `\Memory\Available Bytes` {
+		t.Fatalf("unexpected path %s", p)
+	}
+	if _, ok := LogicalDisk.Counters["FreeMegabytes"]; !ok {
+		t.Fatal("expected logical disk FreeMegabytes")
+	}
+	if _, ok := PhysicalDisk.Counters["FreeMegabytes"]; ok {
+		t.Fatal("expected no physical disk FreeMegabytes")
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package pdh
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modPdh                           = windows.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQueryW                = modPdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW        = modPdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData          = modPdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterArrayW = modPdh.NewProc("PdhGetFormattedCounterArrayW")
+	procPdhGetRawCounterArrayW       = modPdh.NewProc("PdhGetRawCounterArrayW")
+	procPdhCloseQuery                = modPdh.NewProc("PdhCloseQuery")
+)
+
+const (
+	errorSuccess        = 0
+	pdhMoreData         = 0x800007D2
+	pdhNoData           = 0x800007D5
+	pdhInvalidData      = 0xC0000BC6
+	pdhCstatusValidData = 0x00000000
+	pdhCstatusNewData   = 0x00000001
+	pdhFmtDouble        = 0x00000200
+
+	// PDH_FMT_COUNTERVALUE_ITEM_DOUBLE, the value (a union of 8 byte types)
+	// is 8 byte aligned on 32 and 64 bit windows
+	fmtItemSize         = 24
+	fmtItemStatusOffset = 8
+	fmtItemValueOffset  = 16
+
+	// PDH_RAW_COUNTER_ITEM
+	rawItemSize         = 48
+	rawItemStatusOffset = 8
+	rawItemValueOffset  = 24 // FirstValue
+
+	// primeDelay between the first two collections of a query, cooked
+	// counters (rates, percentages) are computed from two samples
+	primeDelay = time.Second
+)
+
+// query is an open pdh query of the counters of an object
+type query struct {
+	handle   uintptr
+	counters map[string]uintptr // counter handles by wmi property name
+	primed   bool               // collected at least once
+	sync.Mutex
+}
+
+// queries are kept open between collections, by object
+var (
+	queries    = make(map[*Object]*query)
+	queriesMtx sync.Mutex
+)
+
+// counterValue is the value of a counter for an instance
+type counterValue struct {
+	instance string
+	value    float64
+}
+
+// Query collects the counters of an object. The pdh query of an object is
+// opened with its first collection and kept open, the first collection of a
+// cooked object takes two samples primeDelay apart. Counters which do not
+// exist on the host (e.g. older windows versions) are skipped. After an
+// error, the query is re-opened with the next collection.
+func Query(obj *Object) ([]Instance, error) {
+	queriesMtx.Lock()
+	q, ok := queries[obj]
+	if !ok {
+		var err error
+		q, err = openQuery(obj)
+		if err != nil {
+			queriesMtx.Unlock()
+			return nil, err
+		}
+		queries[obj] = q
+	}
+	queriesMtx.Unlock()
+
+	instances, err := q.collect(obj)
+	if err != nil {
+		queriesMtx.Lock()
+		if queries[obj] == q {
+			delete(queries, obj)
+		}
+		queriesMtx.Unlock()
+		q.close()
+		return nil, err
+	}
+
+	return instances, nil
+}
+
+// openQuery opens a query and adds the counters of the object
+func openQuery(obj *Object) (*query, error) {
+	if err := procPdhOpenQueryW.Find(); err != nil {
+		return nil, errors.Wrap(err, "pdh api")
+	}
+
+	q := &query{counters: make(map[string]uintptr)}
+	if r, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&q.handle))); r != errorSuccess {
+		return nil, errors.Errorf("opening %s pdh query: 0x%08x", obj.Name, r)
+	}
+
+	for property, counter := range obj.Counters {
+		path, err := windows.UTF16PtrFromString(obj.CounterPath(counter))
+		if err != nil {
+			q.close()
+			return nil, errors.Wrapf(err, "%s counter path", obj.Name)
+		}
+		var handle uintptr
+		if r, _, _ := procPdhAddEnglishCounterW.Call(q.handle, uintptr(unsafe.Pointer(path)), 0, uintptr(unsafe.Pointer(&handle))); r != errorSuccess {
+			continue // not available on this host
+		}
+		q.counters[property] = handle
+	}
+
+	if len(q.counters) == 0 {
+		q.close()
+		return nil, errors.Errorf("no %s counters available", obj.Name)
+	}
+
+	return q, nil
+}
+
+// collect samples the counters and returns the values by instance, sorted by instance name
+func (q *query) collect(obj *Object) ([]Instance, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.handle == 0 {
+		return nil, errors.Errorf("%s pdh query closed", obj.Name)
+	}
+
+	samples := 1
+	if !obj.Raw && !q.primed {
+		samples = 2
+	}
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			time.Sleep(primeDelay)
+		}
+		if r, _, _ := procPdhCollectQueryData.Call(q.handle); r != errorSuccess {
+			return nil, errors.Errorf("collecting %s pdh query data: 0x%08x", obj.Name, r)
+		}
+	}
+	q.primed = true
+
+	var instances []Instance
+	byName := make(map[string]int)
+	for property, handle := range q.counters {
+		values, err := counterValues(handle, obj.Raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", obj.CounterPath(obj.Counters[property]))
+		}
+		for _, cv := range values {
+			i, ok := byName[cv.instance]
+			if !ok {
+				i = len(instances)
+				byName[cv.instance] = i
+				instances = append(instances, Instance{Name: cv.instance, Values: make(map[string]float64)})
+			}
+			instances[i].Values[property] = cv.value
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+
+	return instances, nil
+}
+
+// close closes the query
+func (q *query) close() {
+	q.Lock()
+	defer q.Unlock()
+	if q.handle != 0 {
+		_, _, _ = procPdhCloseQuery.Call(q.handle)
+		q.handle = 0
+	}
+}
+
+// counterValues returns the value of a counter for each instance, formatted
+// as a double or the raw (first) value. Instances without valid data are not
+// returned.
+func counterValues(handle uintptr, raw bool) ([]counterValue, error) {
+	proc, format := procPdhGetFormattedCounterArrayW, uintptr(pdhFmtDouble)
+	itemSize, statusOffset, valueOffset := uintptr(fmtItemSize), uintptr(fmtItemStatusOffset), uintptr(fmtItemValueOffset)
+	if raw {
+		proc = procPdhGetRawCounterArrayW
+		itemSize, statusOffset, valueOffset = rawItemSize, rawItemStatusOffset, rawItemValueOffset
+	}
+
+	getArray := func(size, count *uint32, buf *uint64) uintptr {
+		if raw {
+			r, _, _ := proc.Call(handle, uintptr(unsafe.Pointer(size)), uintptr(unsafe.Pointer(count)), uintptr(unsafe.Pointer(buf)))
+			return r
+		}
+		r, _, _ := proc.Call(handle, format, uintptr(unsafe.Pointer(size)), uintptr(unsafe.Pointer(count)), uintptr(unsafe.Pointer(buf)))
+		return r
+	}
+
+	// the number of instances can change between the calls
+	// for the required buffer size and the values
+	var buf []uint64
+	var size, count uint32
+	for tries := 0; ; tries++ {
+		var bufp *uint64
+		if len(buf) > 0 {
+			bufp = &buf[0]
+		}
+		r := getArray(&size, &count, bufp)
+		switch {
+		case r == errorSuccess && bufp != nil:
+		case r == errorSuccess, r == pdhNoData, r == pdhInvalidData:
+			return nil, nil
+		case r == pdhMoreData && tries < 3:
+			buf = make([]uint64, (size+7)/8) // 8 byte aligned
+			continue
+		default:
+			return nil, errors.Errorf("pdh counter array: 0x%08x", r)
+		}
+		break
+	}
+
+	base := unsafe.Pointer(&buf[0])
+	values := make([]counterValue, 0, count)
+	for i := uintptr(0); i < uintptr(count); i++ {
+		item := unsafe.Pointer(uintptr(base) + i*itemSize)
+		status := *(*uint32)(unsafe.Pointer(uintptr(item) + statusOffset))
+		if status != pdhCstatusValidData && status != pdhCstatusNewData {
+			continue
+		}
+		cv := counterValue{instance: utf16PtrToString(*(**uint16)(item))}
+		if raw {
+			cv.value = float64(*(*int64)(unsafe.Pointer(uintptr(item) + valueOffset)))
+		} else {
+			cv.value = *(*float64)(unsafe.Pointer(uintptr(item) + valueOffset))
+		}
+		values = append(values, cv)
+	}
+
+	return values, nil
+}
+
+// utf16PtrToString returns the string of a nul terminated utf16 string
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	n := 0
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; n++ {
+		ptr = unsafe.Pointer(uintptr(ptr) + unsafe.Sizeof(*p))
+	}
+	return windows.UTF16ToString((*[1 << 29]uint16)(unsafe.Pointer(p))[:n:n])
+}
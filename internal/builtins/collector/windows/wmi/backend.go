@@ -0,0 +1,57 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/pdh"
+	"github.com/pkg/errors"
+)
+
+// Data sources, selected with the `backend` collector option (disk,
+// interface, memory and processor collectors). With "pdh" the counters are
+// read with the Performance Data Helper api rather than queried from the wmi
+// service, they are decoded into the same class structs so the metrics are
+// the same with either backend.
+const (
+	backendWMI = "wmi"
+	backendPDH = "pdh"
+)
+
+// pdhCollect reads the counters of a performance object, tests replace it
+var pdhCollect = pdh.Query
+
+// pdhInstanceNames replaces the characters the wmi performance classes
+// replace in instance names (e.g. "Intel(R) Ethernet" is "Intel[R] Ethernet")
+var pdhInstanceNames = strings.NewReplacer("(", "[", ")", "]", "#", "_", "/", "_", `\`, "_")
+
+// parseBackend validates a `backend` collector option
+func parseBackend(backend string) (string, error) {
+	switch backend {
+	case "", backendWMI:
+		return backendWMI, nil
+	case backendPDH:
+		return backendPDH, nil
+	default:
+		return "", errors.Errorf("invalid backend (%s)", backend)
+	}
+}
+
+// queryPDH reads the counters of a performance object into dst, a pointer
+// to a slice of the equivalent wmi class struct
+func queryPDH(obj *pdh.Object, dst interface{}) error {
+	instances, err := pdhCollect(obj)
+	if err != nil {
+		return err
+	}
+	for i := range instances {
+		instances[i].Name = pdhInstanceNames.Replace(instances[i].Name)
+	}
+	return pdh.Decode(instances, dst)
+}
@@ -0,0 +1,126 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/pdh"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func TestParseBackend(t *testing.T) {
+	t.Log("Testing parseBackend")
+
+	tests := []struct {
+		backend string
+		expect  string
+		err     bool
+	}{
+		{"", backendWMI, false},
+		{"wmi", backendWMI, false},
+		{"pdh", backendPDH, false},
+		{"PDH", "", true},
+		{"perflib", "", true},
+	}
+
+	for _, test := range tests {
+		t.Logf("\t%q", test.backend)
+		backend, err := parseBackend(test.backend)
+		if test.err {
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if backend != test.expect {
+			t.Fatalf("expected %s, got %s", test.expect, backend)
+		}
+	}
+}
+
+func TestCollectPDH(t *testing.T) {
+	t.Log("Testing Collect (pdh backend)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	defer func() { pdhCollect = pdh.Query }()
+
+	t.Log("\tmemory")
+	{
+		pdhCollect = func(obj *pdh.Object) ([]pdh.Instance, error) {
+			if obj != pdh.Memory {
+				t.Fatalf("unexpected object %s", obj.Name)
+			}
+			return []pdh.Instance{{Values: map[string]float64{
+				"AvailableBytes":   2147483648,
+				"CommittedBytes":   6442450944,
+				"PageFaultsPersec": 1234.6,
+			}}}, nil
+		}
+
+		c, err := NewMemoryCollector(filepath.Join("testdata", "config_backend_pdh_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "AvailableBytes", "L", 2147483648, "units:bytes")
+		testutil.AssertMetric(t, metrics, "CommittedBytes", "L", 6442450944)
+		testutil.AssertMetric(t, metrics, "PageFaultsPersec", "L", 1234)
+		testutil.AssertMetric(t, metrics, "WriteCopiesPersec", "L", 0)
+	}
+
+	t.Log("\tnetwork interface")
+	{
+		pdhCollect = func(obj *pdh.Object) ([]pdh.Instance, error) {
+			if obj != pdh.NetworkInterface {
+				t.Fatalf("unexpected object %s", obj.Name)
+			}
+			return []pdh.Instance{
+				{Name: "Intel(R) Ethernet #2", Values: map[string]float64{"BytesReceivedPersec": 1024, "CurrentBandwidth": 1e9}},
+				{Name: "_Total", Values: map[string]float64{"BytesReceivedPersec": 1024}},
+			}, nil
+		}
+
+		c, err := NewNetInterfaceCollector(filepath.Join("testdata", "config_backend_pdh_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "BytesReceivedPersec", "L", 1024, "network-interface:Intel[R]_Ethernet__2", "units:bytes")
+		testutil.AssertMetric(t, metrics, "CurrentBandwidth", "L", 1000000000, "network-interface:Intel[R]_Ethernet__2", "units:bits")
+		testutil.AssertMetric(t, metrics, "BytesReceivedPersec_Total", "L", 1024, "network-interface:all", "units:bytes")
+	}
+
+	t.Log("\tpdh error")
+	{
+		pdhCollect = func(obj *pdh.Object) ([]pdh.Instance, error) {
+			return nil, errors.New("no Processor counters available")
+		}
+
+		c, err := NewProcessorCollector(filepath.Join("testdata", "config_backend_pdh_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Win32_Battery defines the metrics to collect
+type Win32_Battery struct { //nolint: golint
+	DeviceID                 string
+	BatteryStatus            uint16
+	EstimatedChargeRemaining uint16
+	EstimatedRunTime         uint32
+	DesignCapacity           uint32
+	FullChargeCapacity       uint32
+}
+
+// Battery metrics from the Windows Management Interface (wmi)
+type Battery struct {
+	wmicommon
+}
+
+// batteryOnACStatus are the Win32_Battery BatteryStatus values indicating
+// the system has AC power (2 unknown/AC, 3 fully charged, 6-9 charging)
+var batteryOnACStatus = map[uint16]bool{2: true, 3: true, 6: true, 7: true, 8: true, 9: true}
+
+// batteryRunTimeUnknown is the EstimatedRunTime reported while on AC power
+const batteryRunTimeUnknown = 71582788
+
+// batteryOptions defines what elements can be overridden in a config file
+type batteryOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewBatteryCollector creates new wmi collector
+func NewBatteryCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Battery{}
+	c.id = "battery"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg batteryOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *Battery) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	var dst []Win32_Battery
+	qry := wmi.CreateQuery(dst, "")
+	if err := wmiQuery(qry, &dst); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "I"
+
+	onAC := 0
+	for _, item := range dst {
+		batteryTags := cgm.Tags{
+			cgm.Tag{Category: "battery", Value: c.cleanName(item.DeviceID)},
+		}
+
+		if batteryOnACStatus[item.BatteryStatus] {
+			onAC = 1
+		}
+
+		_ = c.addMetric(&metrics, "", "BatteryStatus", metricType, item.BatteryStatus, batteryTags)
+		_ = c.addMetric(&metrics, "", "EstimatedChargeRemaining", metricType, item.EstimatedChargeRemaining, append(batteryTags, cgm.Tag{Category: "units", Value: "percent"}))
+		if item.EstimatedRunTime != batteryRunTimeUnknown {
+			_ = c.addMetric(&metrics, "", "EstimatedRunTime", metricType, item.EstimatedRunTime, append(batteryTags, cgm.Tag{Category: "units", Value: "minutes"}))
+		}
+
+		// capacities are not reported by most battery drivers
+		if item.DesignCapacity > 0 && item.FullChargeCapacity > 0 {
+			health := float64(item.FullChargeCapacity) / float64(item.DesignCapacity) * 100
+			_ = c.addMetric(&metrics, "", "Health", "n", health, append(batteryTags, cgm.Tag{Category: "units", Value: "percent"}))
+		}
+	}
+
+	_ = c.addMetric(&metrics, "", "Batteries", metricType, len(dst), cgm.Tags{})
+	if len(dst) > 0 {
+		_ = c.addMetric(&metrics, "", "ACOnline", metricType, onAC, cgm.Tags{})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
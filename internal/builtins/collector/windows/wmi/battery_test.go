@@ -0,0 +1,137 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewBatteryCollector(t *testing.T) {
+	t.Log("Testing NewBatteryCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("no config")
+	{
+		_, err := NewBatteryCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (missing)")
+	{
+		_, err := NewBatteryCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (bad syntax)")
+	{
+		_, err := NewBatteryCollector(filepath.Join("testdata", "bad_syntax"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (config no settings)")
+	{
+		c, err := NewBatteryCollector(filepath.Join("testdata", "config_no_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c == nil {
+			t.Fatal("expected no nil")
+		}
+	}
+
+	t.Log("config (id setting)")
+	{
+		c, err := NewBatteryCollector(filepath.Join("testdata", "config_id_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Battery).id != "foo" {
+			t.Fatalf("expected foo, got (%s)", c.ID())
+		}
+	}
+
+	t.Log("config (metric name regex)")
+	{
+		c, err := NewBatteryCollector(filepath.Join("testdata", "config_metric_name_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := `^foo`
+		if c.(*Battery).metricNameRegex.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*Battery).metricNameRegex.String())
+		}
+	}
+
+	t.Log("config (metric name regex invalid)")
+	{
+		_, err := NewBatteryCollector(filepath.Join("testdata", "config_metric_name_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (metric name char)")
+	{
+		c, err := NewBatteryCollector(filepath.Join("testdata", "config_metric_name_char_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Battery).metricNameChar != "-" {
+			t.Fatal("expected '-'")
+		}
+	}
+
+	t.Log("config (run ttl 5m)")
+	{
+		c, err := NewBatteryCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Battery).runTTL != 5*time.Minute {
+			t.Fatal("expected 5m")
+		}
+	}
+
+	t.Log("config (run ttl invalid)")
+	{
+		_, err := NewBatteryCollector(filepath.Join("testdata", "config_run_ttl_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestBatteryFlush(t *testing.T) {
+	t.Log("Testing Flush")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewBatteryCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected metrics")
+	}
+	if len(metrics) > 0 {
+		t.Fatalf("expected empty metrics, got %v", metrics)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Win32_EncryptableVolume defines the metrics to collect
+type Win32_EncryptableVolume struct { //nolint: golint
+	ConversionStatus uint32
+	DeviceID         string
+	DriveLetter      string
+	EncryptionMethod uint32
+	ProtectionStatus uint32
+	VolumeType       uint32
+}
+
+// bitlockerNamespace is the wmi namespace of the BitLocker drive encryption classes
+const bitlockerNamespace = `root\CIMV2\Security\MicrosoftVolumeEncryption`
+
+// bitlockerVolumeTypes VolumeType values
+var bitlockerVolumeTypes = map[uint32]string{
+	0: "os",
+	1: "fixed",
+	2: "removable",
+}
+
+// BitLocker metrics from the Windows Management Interface (wmi)
+type BitLocker struct {
+	wmicommon
+}
+
+// bitlockerOptions defines what elements can be overridden in a config file
+type bitlockerOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewBitLockerCollector creates new wmi collector
+func NewBitLockerCollector(cfgBaseName string) (collector.Collector, error) {
+	c := BitLocker{}
+	c.id = "bitlocker"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg bitlockerOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *BitLocker) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	var dst []Win32_EncryptableVolume
+	qry := wmi.CreateQuery(dst, "")
+	if err := wmiQueryNamespace(qry, &dst, bitlockerNamespace); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "I"
+	protected := 0
+	for _, item := range dst {
+		volume := item.DriveLetter
+		if volume == "" {
+			volume = item.DeviceID // volumes without a drive letter
+		}
+		volumeType, ok := bitlockerVolumeTypes[item.VolumeType]
+		if !ok {
+			volumeType = "unknown"
+		}
+		volumeTags := cgm.Tags{
+			cgm.Tag{Category: "volume", Value: c.cleanName(volume)},
+			cgm.Tag{Category: "volume-type", Value: volumeType},
+		}
+
+		// ProtectionStatus 0 unprotected, 1 protected, 2 unknown (locked)
+		isProtected := 0
+		if item.ProtectionStatus == 1 {
+			isProtected = 1
+			protected++
+		}
+
+		_ = c.addMetric(&metrics, "", "Protected", metricType, isProtected, volumeTags)
+		_ = c.addMetric(&metrics, "", "ProtectionStatus", metricType, item.ProtectionStatus, volumeTags)
+		_ = c.addMetric(&metrics, "", "ConversionStatus", metricType, item.ConversionStatus, volumeTags)
+		_ = c.addMetric(&metrics, "", "EncryptionMethod", metricType, item.EncryptionMethod, volumeTags)
+	}
+
+	_ = c.addMetric(&metrics, "", "Volumes", metricType, len(dst), cgm.Tags{})
+	_ = c.addMetric(&metrics, "", "VolumesProtected", metricType, protected, cgm.Tags{})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
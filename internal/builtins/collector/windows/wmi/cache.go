@@ -151,7 +151,7 @@ func (c *Cache) Collect(ctx context.Context) error {
 
 	var dst []Win32_PerfFormattedData_PerfOS_Cache
 	qry := wmi.CreateQuery(dst, "")
-	if err := wmi.Query(qry, &dst); err != nil {
+	if err := wmiQuery(qry, &dst); err != nil {
 		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 		c.setStatus(metrics, err)
 		return errors.Wrap(err, c.pkgID)
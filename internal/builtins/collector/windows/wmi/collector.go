@@ -88,19 +88,19 @@ func (c *wmicommon) addMetric(metrics *cgm.Metrics, pfx, mname, mtype string, mv
 		return errors.New("invalid metric, no type")
 	}
 
-	var tagList cgm.Tags
-	tagList = append(tagList, cgm.Tags{
+	tagList := tags.GetTags()
+	defer tags.PutTags(tagList)
+	*tagList = append(*tagList,
 		cgm.Tag{Category: "source", Value: release.NAME},
-		cgm.Tag{Category: "collector", Value: c.id},
-	}...)
-	tagList = append(tagList, c.baseTags...)
-	tagList = append(tagList, mtags...)
+		cgm.Tag{Category: "collector", Value: c.id})
+	*tagList = append(*tagList, c.baseTags...)
+	*tagList = append(*tagList, mtags...)
 
 	if pfx != "" {
 		mname = pfx + defaults.MetricNameSeparator + mname
 	}
 
-	metricName := tags.MetricNameWithStreamTags(c.cleanName(mname), tagList)
+	metricName := tags.MetricNameWithStreamTags(c.cleanName(mname), *tagList)
 	(*metrics)[metricName] = cgm.Metric{Type: mtype, Value: mval}
 
 	return nil
@@ -108,6 +108,11 @@ func (c *wmicommon) addMetric(metrics *cgm.Metrics, pfx, mname, mtype string, mv
 
 // setStatus is used in Collect to set the collector status
 func (c *wmicommon) setStatus(metrics cgm.Metrics, err error) {
+	if err == nil {
+		c.addDiscovery(metrics)
+	} else {
+		c.instances = nil
+	}
 	c.Lock()
 	if err == nil {
 		c.lastError = ""
@@ -0,0 +1,145 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"os"
+
+	"github.com/StackExchange/wmi"
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Windows containers (process or hyper-v isolated) expose a subset of the
+// WMI classes available on a host - hardware and security providers are not
+// present and some performance classes are missing depending on the base
+// image. Collectors are only enabled when the classes they query exist. A
+// HostProcess container (kubernetes hostProcess pods) runs with the host's
+// view of the system, with --wmi-host-process the collectors run as on a host.
+
+type containerMode int
+
+const (
+	modeHost        containerMode = iota // not in a container
+	modeContainer                        // isolated container, only available classes
+	modeHostProcess                      // HostProcess container, collect from the host
+)
+
+// hostProcessEnvVar is set in HostProcess containers (the container's volume mount point)
+const hostProcessEnvVar = "CONTAINER_SANDBOX_MOUNT_POINT"
+
+func (m containerMode) String() string {
+	switch m {
+	case modeContainer:
+		return "container"
+	case modeHostProcess:
+		return "host-process"
+	default:
+		return "host"
+	}
+}
+
+// wmiClasses are the classes queried by a collector, the collector is enabled
+// in a container if any of them is available
+type wmiClasses struct {
+	classes  []string
+	hostOnly bool // hardware/security collectors, never enabled in an isolated container
+}
+
+var collectorClasses = map[string]wmiClasses{
+	"battery":           {hostOnly: true},
+	"bitlocker":         {hostOnly: true},
+	"cache":             {classes: []string{"Win32_PerfFormattedData_PerfOS_Cache"}},
+	"defender":          {hostOnly: true},
+	"disk":              {classes: []string{"Win32_PerfFormattedData_PerfDisk_LogicalDisk", "Win32_PerfFormattedData_PerfDisk_PhysicalDisk"}},
+	"fc":                {hostOnly: true},
+	"memory":            {classes: []string{"Win32_PerfFormattedData_PerfOS_Memory"}},
+	"mpio":              {hostOnly: true},
+	"mssql":             {classes: []string{"Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases"}},
+	"interface":         {classes: []string{"Win32_PerfRawData_Tcpip_NetworkInterface"}},
+	"ip":                {classes: []string{"Win32_PerfRawData_Tcpip_IPv4", "Win32_PerfRawData_Tcpip_IPv6"}},
+	"tcp":               {classes: []string{"Win32_PerfRawData_Tcpip_TCPv4", "Win32_PerfRawData_Tcpip_TCPv6"}},
+	"udp":               {classes: []string{"Win32_PerfRawData_Tcpip_UDPv4", "Win32_PerfRawData_Tcpip_UDPv6"}},
+	"objects":           {classes: []string{"Win32_PerfFormattedData_PerfOS_Objects"}},
+	"paging_file":       {classes: []string{"Win32_PerfFormattedData_PerfOS_PagingFile"}},
+	"print_queue":       {classes: []string{"Win32_PerfFormattedData_Spooler_PrintQueue"}},
+	"processes":         {classes: []string{"Win32_PerfFormattedData_PerfProc_Process"}},
+	"processor":         {classes: []string{"Win32_PerfFormattedData_PerfOS_Processor", "Win32_PerfRawData_PerfOS_Processor"}},
+	"services":          {classes: []string{"Win32_Service"}},
+	"smart":             {hostOnly: true},
+	"storage_spaces":    {hostOnly: true},
+	"terminal_services": {classes: []string{"Win32_PerfFormattedData_LocalSessionManager_TerminalServices", "Win32_LogonSession"}},
+	"tpm":               {hostOnly: true},
+	"web_service":       {classes: []string{"Win32_PerfFormattedData_W3SVC_WebService"}},
+}
+
+// classAvailable is a var so the class lookup can be replaced in tests
+var classAvailable = wmiClassAvailable
+
+// detectContainerMode determines if the agent is running in a Windows container
+func detectContainerMode(hostProcess bool, logger zerolog.Logger) containerMode {
+	inHostProcess := os.Getenv(hostProcessEnvVar) != ""
+	inContainer := inHostProcess
+	if !inContainer {
+		k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control`, registry.QUERY_VALUE)
+		if err == nil {
+			if _, _, err := k.GetIntegerValue("ContainerType"); err == nil {
+				inContainer = true
+			}
+			k.Close()
+		}
+	}
+
+	mode := resolveContainerMode(inContainer, inHostProcess, hostProcess)
+	if hostProcess && mode != modeHostProcess {
+		logger.Warn().Str("mode", mode.String()).Msg("wmi host-process mode requested, not running in a HostProcess container")
+	}
+	return mode
+}
+
+// resolveContainerMode returns the collection mode, host-process mode must be
+// requested and granted (running in a HostProcess container)
+func resolveContainerMode(inContainer, inHostProcess, hostProcess bool) containerMode {
+	switch {
+	case !inContainer:
+		return modeHost
+	case inHostProcess && hostProcess:
+		return modeHostProcess
+	default:
+		return modeContainer
+	}
+}
+
+// availableInMode determines if the named collector can run in the mode
+func availableInMode(name string, mode containerMode) bool {
+	if mode != modeContainer {
+		return true
+	}
+	c, ok := collectorClasses[name]
+	if !ok {
+		return true // unknown collector, reported by New
+	}
+	if c.hostOnly {
+		return false
+	}
+	for _, class := range c.classes {
+		if classAvailable(class) {
+			return true
+		}
+	}
+	return false
+}
+
+// wmiClassAvailable determines if a class is defined in the default namespace
+func wmiClassAvailable(class string) bool {
+	var dst []struct{}
+	if err := wmi.Query("SELECT * FROM meta_class WHERE __CLASS = '"+class+"'", &dst); err != nil {
+		return false
+	}
+	return len(dst) > 0
+}
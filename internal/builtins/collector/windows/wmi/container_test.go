@@ -0,0 +1,65 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import "testing"
+
+func TestResolveContainerMode(t *testing.T) {
+	t.Log("Testing resolveContainerMode")
+
+	tests := []struct {
+		name          string
+		inContainer   bool
+		inHostProcess bool
+		hostProcess   bool
+		want          containerMode
+	}{
+		{"host", false, false, false, modeHost},
+		{"host, host-process requested", false, false, true, modeHost},
+		{"container", true, false, false, modeContainer},
+		{"container, host-process not granted", true, false, true, modeContainer},
+		{"HostProcess container, not requested", true, true, false, modeContainer},
+		{"HostProcess container", true, true, true, modeHostProcess},
+	}
+
+	for _, tt := range tests {
+		if got := resolveContainerMode(tt.inContainer, tt.inHostProcess, tt.hostProcess); got != tt.want {
+			t.Fatalf("%s, expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAvailableInMode(t *testing.T) {
+	t.Log("Testing availableInMode")
+
+	defer func() { classAvailable = wmiClassAvailable }()
+	classAvailable = func(class string) bool {
+		return class == "Win32_PerfFormattedData_PerfOS_Memory" || class == "Win32_PerfRawData_Tcpip_IPv6"
+	}
+
+	tests := []struct {
+		name      string
+		collector string
+		mode      containerMode
+		want      bool
+	}{
+		{"host", "battery", modeHost, true},
+		{"host-process", "tpm", modeHostProcess, true},
+		{"container, host only", "battery", modeContainer, false},
+		{"container, available", "memory", modeContainer, true},
+		{"container, one of classes available", "ip", modeContainer, true},
+		{"container, not available", "cache", modeContainer, false},
+		{"container, unknown", "foo", modeContainer, true},
+	}
+
+	for _, tt := range tests {
+		if got := availableInMode(tt.collector, tt.mode); got != tt.want {
+			t.Fatalf("%s, expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+// The Win32_PerfFormattedData_* classes are cooked by the wmi provider using
+// its own refresh interval, they return 0 for counters sampled more often than
+// that and misbehave when queried concurrently. The Win32_PerfRawData_* classes
+// return the raw counter values, the agent cooks them with the documented
+// formulas using the previous sample it holds for each counter.
+// https://docs.microsoft.com/en-us/windows/win32/wmisdk/wmi-performance-counter-types
+
+// counterType is the CounterType qualifier of a raw class property
+type counterType int
+
+const (
+	perfCounterCounter  counterType = iota // PERF_COUNTER_COUNTER, PERF_COUNTER_BULK_COUNT: (N1-N0)/((T1-T0)/F)
+	perf100NsecTimer                       // PERF_100NSEC_TIMER: 100*(N1-N0)/(T1-T0), T=Timestamp_Sys100NS
+	perf100NsecTimerInv                    // PERF_100NSEC_TIMER_INV: 100*(1-(N1-N0)/(T1-T0)), T=Timestamp_Sys100NS
+	perfAverageTimer                       // PERF_AVERAGE_TIMER: ((N1-N0)/F)/(B1-B0)
+	perfAverageBulk                        // PERF_AVERAGE_BULK: (N1-N0)/(B1-B0)
+	perfRawFraction                        // PERF_RAW_FRACTION: 100*N/B
+)
+
+// rawTimestamps are the timestamp properties common to all raw classes
+type rawTimestamps struct {
+	Frequency uint64 // Frequency_PerfTime
+	PerfTime  uint64 // Timestamp_PerfTime
+	Sys100NS  uint64 // Timestamp_Sys100NS
+}
+
+// rawSample is a raw counter value (and its base, if the type has one)
+type rawSample struct {
+	ts    rawTimestamps
+	value uint64
+	base  uint64
+}
+
+// cooker holds the previous sample of each raw counter
+type cooker struct {
+	prev map[string]rawSample
+	curr map[string]rawSample
+}
+
+func newCooker() *cooker {
+	return &cooker{
+		prev: make(map[string]rawSample),
+		curr: make(map[string]rawSample),
+	}
+}
+
+// cook returns the cooked value of a raw counter (key identifies the instance
+// and counter), false if there is no usable previous sample (e.g. the first
+// collection or the counter wrapped)
+func (ck *cooker) cook(key string, ctype counterType, ts rawTimestamps, value, base uint64) (float64, bool) {
+	curr := rawSample{ts: ts, value: value, base: base}
+	ck.curr[key] = curr
+
+	if ctype == perfRawFraction {
+		return cookValue(ctype, rawSample{}, curr)
+	}
+
+	prev, ok := ck.prev[key]
+	if !ok {
+		return 0, false
+	}
+	return cookValue(ctype, prev, curr)
+}
+
+// done ends a collection, counters not sampled in it are forgotten
+func (ck *cooker) done() {
+	ck.prev = ck.curr
+	ck.curr = make(map[string]rawSample, len(ck.prev))
+}
+
+// cookValue applies the formula for the counter type to two samples
+func cookValue(ctype counterType, prev, curr rawSample) (float64, bool) {
+	if ctype == perfRawFraction {
+		if curr.base == 0 {
+			return 0, false
+		}
+		return 100 * float64(curr.value) / float64(curr.base), true
+	}
+
+	if curr.value < prev.value {
+		return 0, false // counter wrapped or reset
+	}
+	dn := float64(curr.value - prev.value)
+
+	switch ctype {
+	case perfCounterCounter:
+		if curr.ts.PerfTime <= prev.ts.PerfTime || curr.ts.Frequency == 0 {
+			return 0, false
+		}
+		return dn / (float64(curr.ts.PerfTime-prev.ts.PerfTime) / float64(curr.ts.Frequency)), true
+	case perf100NsecTimer, perf100NsecTimerInv:
+		if curr.ts.Sys100NS <= prev.ts.Sys100NS {
+			return 0, false
+		}
+		pct := 100 * dn / float64(curr.ts.Sys100NS-prev.ts.Sys100NS)
+		if ctype == perf100NsecTimerInv {
+			pct = 100 - pct
+		}
+		if pct < 0 {
+			pct = 0
+		} else if pct > 100 {
+			pct = 100
+		}
+		return pct, true
+	case perfAverageTimer, perfAverageBulk:
+		if curr.base < prev.base {
+			return 0, false
+		}
+		if curr.base == prev.base {
+			return 0, true // no operations in the interval
+		}
+		db := float64(curr.base - prev.base)
+		if ctype == perfAverageBulk {
+			return dn / db, true
+		}
+		if curr.ts.Frequency == 0 {
+			return 0, false
+		}
+		return (dn / float64(curr.ts.Frequency)) / db, true
+	}
+
+	return 0, false
+}
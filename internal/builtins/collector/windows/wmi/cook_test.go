@@ -0,0 +1,86 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"testing"
+)
+
+func TestCookValue(t *testing.T) {
+	t.Log("Testing cookValue")
+
+	prev := rawSample{ts: rawTimestamps{Frequency: 1000, PerfTime: 10000, Sys100NS: 100000000}}
+
+	tt := []struct {
+		description string
+		ctype       counterType
+		curr        rawSample
+		expect      float64
+		ok          bool
+	}{
+		{"counter 2s", perfCounterCounter, rawSample{ts: rawTimestamps{Frequency: 1000, PerfTime: 12000}, value: 50}, 25, true},
+		{"counter no time", perfCounterCounter, rawSample{ts: rawTimestamps{Frequency: 1000, PerfTime: 10000}, value: 50}, 0, false},
+		{"100ns timer 25%", perf100NsecTimer, rawSample{ts: rawTimestamps{Sys100NS: 110000000}, value: 2500000}, 25, true},
+		{"100ns timer inv 25%", perf100NsecTimerInv, rawSample{ts: rawTimestamps{Sys100NS: 110000000}, value: 7500000}, 25, true},
+		{"100ns timer clamped", perf100NsecTimer, rawSample{ts: rawTimestamps{Sys100NS: 110000000}, value: 20000000}, 100, true},
+		{"average bulk", perfAverageBulk, rawSample{value: 4096, base: 2}, 2048, true},
+		{"average bulk no ops", perfAverageBulk, rawSample{}, 0, true},
+		{"average timer", perfAverageTimer, rawSample{ts: rawTimestamps{Frequency: 1000}, value: 100, base: 4}, 0.025, true},
+		{"raw fraction", perfRawFraction, rawSample{value: 1, base: 4}, 25, true},
+		{"raw fraction no base", perfRawFraction, rawSample{value: 1}, 0, false},
+	}
+
+	for _, test := range tt {
+		t.Logf("\t%s", test.description)
+		v, ok := cookValue(test.ctype, prev, test.curr)
+		if ok != test.ok {
+			t.Fatalf("expected ok %v, got %v", test.ok, ok)
+		}
+		if v != test.expect {
+			t.Fatalf("expected %v, got %v", test.expect, v)
+		}
+	}
+
+	t.Log("\tcounter wrapped")
+	{
+		if _, ok := cookValue(perfCounterCounter, rawSample{value: 10}, rawSample{value: 5}); ok {
+			t.Fatal("expected not ok")
+		}
+	}
+}
+
+func TestCooker(t *testing.T) {
+	t.Log("Testing cooker")
+
+	ck := newCooker()
+
+	t.Log("\tfirst sample")
+	{
+		if _, ok := ck.cook("cpu0", perfCounterCounter, rawTimestamps{Frequency: 10, PerfTime: 10}, 5, 0); ok {
+			t.Fatal("expected not ok")
+		}
+		ck.done()
+	}
+
+	t.Log("\tsecond sample")
+	{
+		v, ok := ck.cook("cpu0", perfCounterCounter, rawTimestamps{Frequency: 10, PerfTime: 20}, 15, 0)
+		if !ok || v != 10 {
+			t.Fatalf("expected 10, got %v %v", v, ok)
+		}
+		ck.done()
+	}
+
+	t.Log("\tinstance gone")
+	{
+		ck.done()
+		if _, ok := ck.cook("cpu0", perfCounterCounter, rawTimestamps{Frequency: 10, PerfTime: 30}, 25, 0); ok {
+			t.Fatal("expected not ok")
+		}
+	}
+}
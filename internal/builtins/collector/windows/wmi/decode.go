@@ -0,0 +1,356 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/pkg/errors"
+)
+
+// Result decoding methods, selected with the `decode` collector option.
+// The reflection based decoding in StackExchange/wmi dominates cpu on hosts
+// with many disks/interfaces, collectors which know the properties they
+// need can read them directly from each result object instead.
+const (
+	decodeReflect = "reflect"
+	decodeDirect  = "direct"
+)
+
+// directRequest is a query handled by the direct query worker
+type directRequest struct {
+	query     string
+	namespace string // empty for the default namespace (root\CIMV2)
+	fn        func(row *wmiRow) error
+	done      chan error
+}
+
+var (
+	directOnce     sync.Once
+	directRequests chan *directRequest
+	directInitErr  error
+)
+
+// parseDecode validates a `decode` collector option
+func parseDecode(method string) (string, error) {
+	switch method {
+	case "", decodeReflect:
+		return decodeReflect, nil
+	case decodeDirect:
+		return decodeDirect, nil
+	default:
+		return "", errors.Errorf("invalid decode method (%s)", method)
+	}
+}
+
+// queryDirect runs a wmi query, fn is called for each result row and reads
+// the properties it needs directly (no reflection over a destination struct)
+func queryDirect(query string, fn func(row *wmiRow) error) error {
+	return queryDirectNamespace(query, "", fn)
+}
+
+// queryDirectNamespace runs a wmi query in namespace, see queryDirect
+func queryDirectNamespace(query, namespace string, fn func(row *wmiRow) error) error {
+	directOnce.Do(func() {
+		initDone := make(chan error)
+		directRequests = make(chan *directRequest)
+		go directWorker(initDone)
+		directInitErr = <-initDone
+	})
+	if directInitErr != nil {
+		return errors.Wrap(directInitErr, "initializing direct wmi query")
+	}
+
+	req := &directRequest{query: query, namespace: namespace, fn: fn, done: make(chan error)}
+	directRequests <- req
+	return <-req.done
+}
+
+// directWorker owns the COM apartment and the wmi service connection, COM
+// objects are tied to the thread which created them so all direct queries
+// are run from this goroutine. The connection is retained across queries,
+// re-creating it for every query leaks memory on WMF 5+ (see initialize).
+func directWorker(initDone chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		oleCode := err.(*ole.OleError).Code()
+		if oleCode != ole.S_OK && oleCode != 0x00000001 { // S_FALSE, already initialized
+			initDone <- err
+			return
+		}
+	}
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject("WbemScripting.SWbemLocator")
+	if err != nil {
+		initDone <- err
+		return
+	}
+	if unknown == nil {
+		initDone <- errors.New("SWbemLocator create object returned nil")
+		return
+	}
+	defer unknown.Release()
+
+	locator, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		initDone <- err
+		return
+	}
+	defer locator.Release()
+
+	serviceRaw, err := oleutil.CallMethod(locator, "ConnectServer")
+	if err != nil {
+		initDone <- err
+		return
+	}
+	defer serviceRaw.Clear() //nolint:errcheck
+	service := serviceRaw.ToIDispatch()
+
+	initDone <- nil
+
+	// connections to other namespaces are created when first queried
+	services := map[string]*ole.IDispatch{"": service}
+	for req := range directRequests {
+		svc, ok := services[req.namespace]
+		if !ok {
+			nsRaw, err := oleutil.CallMethod(locator, "ConnectServer", nil, req.namespace)
+			if err != nil {
+				req.done <- errors.Wrapf(err, "connecting to namespace %s", req.namespace)
+				continue
+			}
+			defer nsRaw.Clear() //nolint:errcheck
+			svc = nsRaw.ToIDispatch()
+			services[req.namespace] = svc
+		}
+		req.done <- execDirect(svc, req.query, req.fn)
+	}
+}
+
+// execDirect executes a query and calls fn for each result row
+func execDirect(service *ole.IDispatch, query string, fn func(row *wmiRow) error) error {
+	resultRaw, err := oleutil.CallMethod(service, "ExecQuery", query)
+	if err != nil {
+		return err
+	}
+	defer resultRaw.Clear() //nolint:errcheck
+	result := resultRaw.ToIDispatch()
+
+	enumProperty, err := result.GetProperty("_NewEnum")
+	if err != nil {
+		return err
+	}
+	defer enumProperty.Clear() //nolint:errcheck
+
+	enum, err := enumProperty.ToIUnknown().IEnumVARIANT(ole.IID_IEnumVariant)
+	if err != nil {
+		return err
+	}
+	if enum == nil {
+		return errors.New("unable to get IEnumVARIANT, enum is nil")
+	}
+	defer enum.Release()
+
+	// property dispatch ids are shared by all rows of a query (same class)
+	ids := make(map[string]int32)
+
+	for itemRaw, length, err := enum.Next(1); length > 0; itemRaw, length, err = enum.Next(1) {
+		if err != nil {
+			return err
+		}
+		row := wmiRow{item: itemRaw.ToIDispatch(), ids: ids}
+		err := fn(&row)
+		row.item.Release()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wmiRow is a single wmi result object, the first error encountered reading
+// properties is retained and returned by err so decoders can read all
+// properties and check for an error once
+type wmiRow struct {
+	item *ole.IDispatch
+	ids  map[string]int32
+	err  error
+}
+
+// value returns the value of a property, nil if the property is null
+func (r *wmiRow) value(name string) (interface{}, error) {
+	id, ok := r.ids[name]
+	if !ok {
+		var err error
+		id, err = r.item.GetSingleIDOfName(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "property %s", name)
+		}
+		r.ids[name] = id
+	}
+
+	prop, err := r.item.Invoke(id, ole.DISPATCH_PROPERTYGET)
+	if err != nil {
+		return nil, errors.Wrapf(err, "property %s", name)
+	}
+	defer prop.Clear() //nolint:errcheck
+
+	if prop.VT == ole.VT_NULL {
+		return nil, nil
+	}
+
+	return prop.Value(), nil
+}
+
+// embedded calls fn with an embedded object property (e.g. the Statistics of
+// MSFC_FibrePortHBAStatistics), fn is not called if the property is null
+func (r *wmiRow) embedded(name string, fn func(obj *wmiRow) error) {
+	if r.err != nil {
+		return
+	}
+	prop, err := r.item.GetProperty(name)
+	if err != nil {
+		r.err = errors.Wrapf(err, "property %s", name)
+		return
+	}
+	defer prop.Clear() //nolint:errcheck
+
+	if prop.VT != ole.VT_DISPATCH {
+		return
+	}
+
+	obj := wmiRow{item: prop.ToIDispatch(), ids: make(map[string]int32)}
+	if err := fn(&obj); err != nil {
+		r.err = errors.Wrapf(err, "property %s", name)
+	}
+}
+
+// embeddedArray calls fn with each object of an embedded object array
+// property (e.g. the DSM_Paths of DSM_Load_Balance_Policy_V2)
+func (r *wmiRow) embeddedArray(name string, fn func(obj *wmiRow) error) {
+	if r.err != nil {
+		return
+	}
+	prop, err := r.item.GetProperty(name)
+	if err != nil {
+		r.err = errors.Wrapf(err, "property %s", name)
+		return
+	}
+	defer prop.Clear() //nolint:errcheck
+
+	if prop.VT&ole.VT_ARRAY == 0 {
+		return
+	}
+
+	// arrays are returned as arrays of variants, objects are VT_DISPATCH
+	for _, v := range prop.ToArray().ToValueArray() {
+		item, ok := v.(*ole.IDispatch)
+		if !ok || item == nil {
+			continue
+		}
+		if r.err == nil {
+			obj := wmiRow{item: item, ids: make(map[string]int32)}
+			if err := fn(&obj); err != nil {
+				r.err = errors.Wrapf(err, "property %s", name)
+			}
+		}
+		item.Release()
+	}
+}
+
+// boolean reads a boolean property into dst
+func (r *wmiRow) boolean(dst *bool, name string) {
+	if r.err != nil {
+		return
+	}
+	v, err := r.value(name)
+	if err != nil {
+		r.err = err
+		return
+	}
+	if b, ok := v.(bool); ok {
+		*dst = b
+	}
+}
+
+// str reads a string property into dst
+func (r *wmiRow) str(dst *string, name string) {
+	if r.err != nil {
+		return
+	}
+	v, err := r.value(name)
+	if err != nil {
+		r.err = err
+		return
+	}
+	switch tv := v.(type) {
+	case nil:
+	case string:
+		*dst = tv
+	default:
+		*dst = fmt.Sprintf("%v", tv)
+	}
+}
+
+// u64 reads an unsigned integer property into dst, wmi returns uint64
+// (and often uint32) perf counter properties as strings
+func (r *wmiRow) u64(dst *uint64, name string) {
+	if r.err != nil {
+		return
+	}
+	v, err := r.value(name)
+	if err != nil {
+		r.err = err
+		return
+	}
+	switch tv := v.(type) {
+	case nil:
+	case string:
+		n, err := strconv.ParseUint(tv, 10, 64)
+		if err != nil {
+			r.err = errors.Wrapf(err, "property %s", name)
+			return
+		}
+		*dst = n
+	case uint8:
+		*dst = uint64(tv)
+	case uint16:
+		*dst = uint64(tv)
+	case uint32:
+		*dst = uint64(tv)
+	case uint64:
+		*dst = tv
+	case int8:
+		*dst = uint64(tv)
+	case int16:
+		*dst = uint64(tv)
+	case int32:
+		*dst = uint64(tv)
+	case int64:
+		*dst = uint64(tv)
+	default:
+		r.err = errors.Errorf("property %s, unsupported type %T", name, v)
+	}
+}
+
+// u32 reads an unsigned integer property into dst
+func (r *wmiRow) u32(dst *uint32, name string) {
+	var n uint64
+	r.u64(&n, name)
+	if r.err == nil {
+		*dst = uint32(n)
+	}
+}
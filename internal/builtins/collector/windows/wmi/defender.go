@@ -0,0 +1,219 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// MSFT_MpComputerStatus defines the metrics to collect
+type MSFT_MpComputerStatus struct { //nolint: golint
+	AMServiceEnabled              bool
+	AntispywareEnabled            bool
+	AntispywareSignatureAge       uint32
+	AntivirusEnabled              bool
+	AntivirusSignatureAge         uint32
+	AntivirusSignatureLastUpdated time.Time
+	BehaviorMonitorEnabled        bool
+	FullScanAge                   uint32
+	FullScanEndTime               time.Time
+	IoavProtectionEnabled         bool
+	NISEnabled                    bool
+	NISSignatureAge               uint32
+	OnAccessProtectionEnabled     bool
+	QuickScanAge                  uint32
+	QuickScanEndTime              time.Time
+	RealTimeProtectionEnabled     bool
+}
+
+// defenderNamespace is the wmi namespace of the Windows Defender classes
+const defenderNamespace = `root\Microsoft\Windows\Defender`
+
+// Defender metrics from the Windows Management Interface (wmi)
+type Defender struct {
+	wmicommon
+}
+
+// defenderOptions defines what elements can be overridden in a config file
+type defenderOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewDefenderCollector creates new wmi collector
+func NewDefenderCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Defender{}
+	c.id = "defender"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg defenderOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *Defender) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	var dst []MSFT_MpComputerStatus
+	qry := wmi.CreateQuery(dst, "")
+	if err := wmiQueryNamespace(qry, &dst, defenderNamespace); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "I"
+	tagUnitsDays := cgm.Tag{Category: "units", Value: "days"}
+	tagUnitsSeconds := cgm.Tag{Category: "units", Value: "seconds"}
+	now := time.Now()
+	for _, item := range dst {
+		enabled := []struct {
+			name  string
+			value bool
+		}{
+			{"AMServiceEnabled", item.AMServiceEnabled},
+			{"AntispywareEnabled", item.AntispywareEnabled},
+			{"AntivirusEnabled", item.AntivirusEnabled},
+			{"BehaviorMonitorEnabled", item.BehaviorMonitorEnabled},
+			{"IoavProtectionEnabled", item.IoavProtectionEnabled},
+			{"NISEnabled", item.NISEnabled},
+			{"OnAccessProtectionEnabled", item.OnAccessProtectionEnabled},
+			{"RealTimeProtectionEnabled", item.RealTimeProtectionEnabled},
+		}
+		for _, e := range enabled {
+			v := 0
+			if e.value {
+				v = 1
+			}
+			_ = c.addMetric(&metrics, "", e.name, metricType, v, cgm.Tags{})
+		}
+
+		// ages are MaxUint32 when a signature was never updated or a scan never ran
+		ages := []struct {
+			name  string
+			value uint32
+		}{
+			{"AntispywareSignatureAge", item.AntispywareSignatureAge},
+			{"AntivirusSignatureAge", item.AntivirusSignatureAge},
+			{"NISSignatureAge", item.NISSignatureAge},
+			{"QuickScanAge", item.QuickScanAge},
+			{"FullScanAge", item.FullScanAge},
+		}
+		for _, a := range ages {
+			if a.value == math.MaxUint32 {
+				continue
+			}
+			_ = c.addMetric(&metrics, "", a.name, metricType, a.value, cgm.Tags{tagUnitsDays})
+		}
+
+		times := []struct {
+			name  string
+			value time.Time
+		}{
+			{"SecondsSinceSignatureUpdate", item.AntivirusSignatureLastUpdated},
+			{"SecondsSinceQuickScan", item.QuickScanEndTime},
+			{"SecondsSinceFullScan", item.FullScanEndTime},
+		}
+		for _, t := range times {
+			if secs, ok := secondsSince(t.value, now); ok {
+				_ = c.addMetric(&metrics, "", t.name, "L", secs, cgm.Tags{tagUnitsSeconds})
+			}
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// secondsSince returns the whole seconds elapsed from t to now, false if t is
+// not set (e.g. a scan which never ran)
+func secondsSince(t, now time.Time) (uint64, bool) {
+	if t.IsZero() || t.Year() <= 1601 {
+		return 0, false
+	}
+	if t.After(now) {
+		return 0, true
+	}
+	return uint64(now.Sub(t).Seconds()), true
+}
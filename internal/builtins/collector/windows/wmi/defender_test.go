@@ -0,0 +1,156 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewDefenderCollector(t *testing.T) {
+	t.Log("Testing NewDefenderCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("no config")
+	{
+		_, err := NewDefenderCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (missing)")
+	{
+		_, err := NewDefenderCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (bad syntax)")
+	{
+		_, err := NewDefenderCollector(filepath.Join("testdata", "bad_syntax"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (config no settings)")
+	{
+		c, err := NewDefenderCollector(filepath.Join("testdata", "config_no_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c == nil {
+			t.Fatal("expected no nil")
+		}
+	}
+
+	t.Log("config (id setting)")
+	{
+		c, err := NewDefenderCollector(filepath.Join("testdata", "config_id_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Defender).id != "foo" {
+			t.Fatalf("expected foo, got (%s)", c.ID())
+		}
+	}
+
+	t.Log("config (metric name regex)")
+	{
+		c, err := NewDefenderCollector(filepath.Join("testdata", "config_metric_name_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := `^foo`
+		if c.(*Defender).metricNameRegex.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*Defender).metricNameRegex.String())
+		}
+	}
+
+	t.Log("config (metric name regex invalid)")
+	{
+		_, err := NewDefenderCollector(filepath.Join("testdata", "config_metric_name_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (metric name char)")
+	{
+		c, err := NewDefenderCollector(filepath.Join("testdata", "config_metric_name_char_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Defender).metricNameChar != "-" {
+			t.Fatal("expected '-'")
+		}
+	}
+
+	t.Log("config (run ttl 5m)")
+	{
+		c, err := NewDefenderCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Defender).runTTL != 5*time.Minute {
+			t.Fatal("expected 5m")
+		}
+	}
+
+	t.Log("config (run ttl invalid)")
+	{
+		_, err := NewDefenderCollector(filepath.Join("testdata", "config_run_ttl_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestDefenderFlush(t *testing.T) {
+	t.Log("Testing Flush")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewDefenderCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected metrics")
+	}
+	if len(metrics) > 0 {
+		t.Fatalf("expected empty metrics, got %v", metrics)
+	}
+}
+
+func TestSecondsSince(t *testing.T) {
+	t.Log("Testing secondsSince")
+
+	now := time.Now()
+
+	if secs, ok := secondsSince(now.Add(-90*time.Second), now); !ok || secs != 90 {
+		t.Fatalf("expected 90 (true), got %d (%v)", secs, ok)
+	}
+	if _, ok := secondsSince(time.Time{}, now); ok {
+		t.Fatal("expected zero time to not be set")
+	}
+	if _, ok := secondsSince(time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC), now); ok {
+		t.Fatal("expected filetime epoch to not be set")
+	}
+	if secs, ok := secondsSince(now.Add(time.Minute), now); !ok || secs != 0 {
+		t.Fatalf("expected 0 (true), got %d (%v)", secs, ok)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// discoveryMetric text metric, JSON array of the instance names seen by the collector
+const discoveryMetric = "discovered_instances"
+
+// seenInstance records an instance (tag value) emitted in the current collection,
+// totals ("all") are not recorded
+func (c *wmicommon) seenInstance(name string) {
+	if c.discoveryInterval <= 0 || name == "all" {
+		return
+	}
+	if c.instances == nil {
+		c.instances = make(map[string]bool)
+	}
+	c.instances[name] = true
+}
+
+// addDiscovery adds the discovery metric when the discovery interval has
+// elapsed, the instances seen are reset for the next collection
+func (c *wmicommon) addDiscovery(metrics cgm.Metrics) {
+	if c.discoveryInterval <= 0 {
+		return
+	}
+
+	seen := c.instances
+	c.instances = nil
+
+	if !c.lastDiscovery.IsZero() && time.Since(c.lastDiscovery) < c.discoveryInterval {
+		return
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data, err := json.Marshal(names)
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("encoding discovered instances")
+		return
+	}
+
+	_ = c.addMetric(&metrics, "", discoveryMetric, "s", string(data), cgm.Tags{})
+	c.lastDiscovery = time.Now()
+}
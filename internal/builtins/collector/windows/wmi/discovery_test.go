@@ -0,0 +1,79 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestAddDiscovery(t *testing.T) {
+	t.Log("Testing addDiscovery")
+
+	discovered := func(metrics cgm.Metrics) (string, bool) {
+		for name, m := range metrics {
+			if strings.HasPrefix(name, discoveryMetric+"|ST[") {
+				return m.Value.(string), true
+			}
+		}
+		return "", false
+	}
+
+	c := wmicommon{id: "disk", metricNameChar: defaultMetricChar, metricNameRegex: defaultMetricNameRegex}
+
+	t.Log("\tdisabled")
+	{
+		c.seenInstance("C:")
+		metrics := cgm.Metrics{}
+		c.addDiscovery(metrics)
+		if _, ok := discovered(metrics); ok {
+			t.Fatal("expected no discovery metric")
+		}
+	}
+
+	c.discoveryInterval = time.Hour
+
+	t.Log("\tfirst collection")
+	{
+		for _, name := range []string{"D:", "C:", "all", "C:"} {
+			c.seenInstance(name)
+		}
+		metrics := cgm.Metrics{}
+		c.addDiscovery(metrics)
+		if v, ok := discovered(metrics); !ok || v != `["C:","D:"]` {
+			t.Fatalf("expected [\"C:\",\"D:\"], got %v", v)
+		}
+	}
+
+	t.Log("\twithin interval")
+	{
+		c.seenInstance("C:")
+		metrics := cgm.Metrics{}
+		c.addDiscovery(metrics)
+		if _, ok := discovered(metrics); ok {
+			t.Fatal("expected no discovery metric")
+		}
+		if len(c.instances) != 0 {
+			t.Fatalf("expected instances reset, got %v", c.instances)
+		}
+	}
+
+	t.Log("\tinterval elapsed")
+	{
+		c.lastDiscovery = time.Now().Add(-2 * time.Hour)
+		c.seenInstance("E:")
+		metrics := cgm.Metrics{}
+		c.addDiscovery(metrics)
+		if v, ok := discovered(metrics); !ok || v != `["E:"]` {
+			t.Fatalf("expected [\"E:\"], got %v", v)
+		}
+	}
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/StackExchange/wmi"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/pdh"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
@@ -113,18 +114,23 @@ type Disk struct {
 	physical bool
 	include  *regexp.Regexp
 	exclude  *regexp.Regexp
+	decode   string
+	backend  string
 }
 
 // diskOptions defines what elements can be overridden in a config file
 type diskOptions struct {
-	ID              string `json:"id" toml:"id" yaml:"id"`
-	IncludeLogical  string `json:"logical_disks" toml:"logical_disks" yaml:"logical_disks"`
-	IncludePhysical string `json:"physical_disks" toml:"physical_disks" yaml:"physical_disks"`
-	IncludeRegex    string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
-	ExcludeRegex    string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
-	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
-	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
-	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	ID                string `json:"id" toml:"id" yaml:"id"`
+	Decode            string `json:"decode" toml:"decode" yaml:"decode"`
+	Backend           string `json:"backend" toml:"backend" yaml:"backend"`
+	IncludeLogical    string `json:"logical_disks" toml:"logical_disks" yaml:"logical_disks"`
+	IncludePhysical   string `json:"physical_disks" toml:"physical_disks" yaml:"physical_disks"`
+	IncludeRegex      string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex      string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex   string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar    string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL            string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	DiscoveryInterval string `json:"discovery_interval" toml:"discovery_interval" yaml:"discovery_interval"`
 }
 
 // NewDiskCollector creates new wmi collector
@@ -141,6 +147,8 @@ func NewDiskCollector(cfgBaseName string) (collector.Collector, error) {
 	c.physical = true
 	c.include = defaultIncludeRegex
 	c.exclude = defaultExcludeRegex
+	c.decode = decodeReflect
+	c.backend = backendWMI
 
 	if cfgBaseName == "" {
 		return &c, nil
@@ -196,6 +204,22 @@ func NewDiskCollector(cfgBaseName string) (collector.Collector, error) {
 		c.id = cfg.ID
 	}
 
+	if cfg.Decode != "" {
+		decode, err := parseDecode(cfg.Decode)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing decode", c.pkgID)
+		}
+		c.decode = decode
+	}
+
+	if cfg.Backend != "" {
+		backend, err := parseBackend(cfg.Backend)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing backend", c.pkgID)
+		}
+		c.backend = backend
+	}
+
 	if cfg.MetricNameRegex != "" {
 		rx, err := regexp.Compile(cfg.MetricNameRegex)
 		if err != nil {
@@ -208,6 +232,14 @@ func NewDiskCollector(cfgBaseName string) (collector.Collector, error) {
 		c.metricNameChar = cfg.MetricNameChar
 	}
 
+	if cfg.DiscoveryInterval != "" {
+		dur, err := time.ParseDuration(cfg.DiscoveryInterval)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing discovery_interval", c.pkgID)
+		}
+		c.wmicommon.discoveryInterval = dur
+	}
+
 	if cfg.RunTTL != "" {
 		dur, err := time.ParseDuration(cfg.RunTTL)
 		if err != nil {
@@ -245,7 +277,7 @@ func (c *Disk) Collect(ctx context.Context) error {
 	if c.logical {
 		var dst []Win32_PerfFormattedData_PerfDisk_LogicalDisk
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := c.queryLogical(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -264,7 +296,7 @@ func (c *Disk) Collect(ctx context.Context) error {
 	if c.physical {
 		var dst []Win32_PerfFormattedData_PerfDisk_PhysicalDisk
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := c.queryPhysical(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -284,6 +316,88 @@ func (c *Disk) Collect(ctx context.Context) error {
 	return nil
 }
 
+// queryLogical runs the logical disk query using the configured backend and decode method
+func (c *Disk) queryLogical(qry string, dst *[]Win32_PerfFormattedData_PerfDisk_LogicalDisk) error {
+	if c.backend == backendPDH {
+		return queryPDH(pdh.LogicalDisk, dst)
+	}
+	if c.decode != decodeDirect {
+		return wmiQuery(qry, dst)
+	}
+	return queryDirect(qry, func(row *wmiRow) error {
+		var dm Win32_PerfFormattedData_PerfDisk_LogicalDisk
+		row.u64(&dm.AvgDiskBytesPerRead, "AvgDiskBytesPerRead")
+		row.u64(&dm.AvgDiskBytesPerTransfer, "AvgDiskBytesPerTransfer")
+		row.u64(&dm.AvgDiskBytesPerWrite, "AvgDiskBytesPerWrite")
+		row.u64(&dm.AvgDiskQueueLength, "AvgDiskQueueLength")
+		row.u64(&dm.AvgDiskReadQueueLength, "AvgDiskReadQueueLength")
+		row.u32(&dm.AvgDisksecPerRead, "AvgDisksecPerRead")
+		row.u32(&dm.AvgDisksecPerTransfer, "AvgDisksecPerTransfer")
+		row.u32(&dm.AvgDisksecPerWrite, "AvgDisksecPerWrite")
+		row.u64(&dm.AvgDiskWriteQueueLength, "AvgDiskWriteQueueLength")
+		row.u32(&dm.CurrentDiskQueueLength, "CurrentDiskQueueLength")
+		row.u64(&dm.DiskBytesPersec, "DiskBytesPersec")
+		row.u64(&dm.DiskReadBytesPersec, "DiskReadBytesPersec")
+		row.u32(&dm.DiskReadsPersec, "DiskReadsPersec")
+		row.u32(&dm.DiskTransfersPersec, "DiskTransfersPersec")
+		row.u64(&dm.DiskWriteBytesPersec, "DiskWriteBytesPersec")
+		row.u64(&dm.DiskWritesPersec, "DiskWritesPersec")
+		row.u32(&dm.FreeMegabytes, "FreeMegabytes")
+		row.str(&dm.Name, "Name")
+		row.u64(&dm.PercentDiskReadTime, "PercentDiskReadTime")
+		row.u64(&dm.PercentDiskTime, "PercentDiskTime")
+		row.u64(&dm.PercentDiskWriteTime, "PercentDiskWriteTime")
+		row.u32(&dm.PercentFreeSpace, "PercentFreeSpace")
+		row.u64(&dm.PercentIdleTime, "PercentIdleTime")
+		row.u32(&dm.SplitIOPerSec, "SplitIOPerSec")
+		if row.err != nil {
+			return row.err
+		}
+		*dst = append(*dst, dm)
+		return nil
+	})
+}
+
+// queryPhysical runs the physical disk query using the configured backend and decode method
+func (c *Disk) queryPhysical(qry string, dst *[]Win32_PerfFormattedData_PerfDisk_PhysicalDisk) error {
+	if c.backend == backendPDH {
+		return queryPDH(pdh.PhysicalDisk, dst)
+	}
+	if c.decode != decodeDirect {
+		return wmiQuery(qry, dst)
+	}
+	return queryDirect(qry, func(row *wmiRow) error {
+		var dm Win32_PerfFormattedData_PerfDisk_PhysicalDisk
+		row.u64(&dm.AvgDiskBytesPerRead, "AvgDiskBytesPerRead")
+		row.u64(&dm.AvgDiskBytesPerTransfer, "AvgDiskBytesPerTransfer")
+		row.u64(&dm.AvgDiskBytesPerWrite, "AvgDiskBytesPerWrite")
+		row.u64(&dm.AvgDiskQueueLength, "AvgDiskQueueLength")
+		row.u64(&dm.AvgDiskReadQueueLength, "AvgDiskReadQueueLength")
+		row.u32(&dm.AvgDisksecPerRead, "AvgDisksecPerRead")
+		row.u32(&dm.AvgDisksecPerTransfer, "AvgDisksecPerTransfer")
+		row.u32(&dm.AvgDisksecPerWrite, "AvgDisksecPerWrite")
+		row.u64(&dm.AvgDiskWriteQueueLength, "AvgDiskWriteQueueLength")
+		row.u32(&dm.CurrentDiskQueueLength, "CurrentDiskQueueLength")
+		row.u64(&dm.DiskBytesPersec, "DiskBytesPersec")
+		row.u64(&dm.DiskReadBytesPersec, "DiskReadBytesPersec")
+		row.u32(&dm.DiskReadsPersec, "DiskReadsPersec")
+		row.u32(&dm.DiskTransfersPersec, "DiskTransfersPersec")
+		row.u64(&dm.DiskWriteBytesPersec, "DiskWriteBytesPersec")
+		row.u64(&dm.DiskWritesPersec, "DiskWritesPersec")
+		row.str(&dm.Name, "Name")
+		row.u64(&dm.PercentDiskReadTime, "PercentDiskReadTime")
+		row.u64(&dm.PercentDiskTime, "PercentDiskTime")
+		row.u64(&dm.PercentDiskWriteTime, "PercentDiskWriteTime")
+		row.u64(&dm.PercentIdleTime, "PercentIdleTime")
+		row.u32(&dm.SplitIOPerSec, "SplitIOPerSec")
+		if row.err != nil {
+			return row.err
+		}
+		*dst = append(*dst, dm)
+		return nil
+	})
+}
+
 func (c *Disk) emitLogicalDiskMetrics(metrics *cgm.Metrics, diskMetrics *Win32_PerfFormattedData_PerfDisk_LogicalDisk) error {
 	dm := genericDiskMetrics{
 		Name:                    diskMetrics.Name,
@@ -353,7 +467,7 @@ func (c *Disk) emitDiskMetrics(metrics *cgm.Metrics, diskType string, diskMetric
 	metricTypeUint64 := "I"
 
 	// apply include/exclude to CLEAN item name
-	diskName := c.cleanName(diskMetrics.Name)
+	diskName := c.instanceName(diskMetrics.Name)
 	if c.exclude.MatchString(diskName) || !c.include.MatchString(diskName) {
 		c.logger.Debug().Str("name", diskName).Msg("skipping, excluded")
 		return nil
@@ -365,6 +479,8 @@ func (c *Disk) emitDiskMetrics(metrics *cgm.Metrics, diskType string, diskMetric
 		metricSuffix = totalName
 	}
 
+	c.seenInstance(diskName)
+
 	tagList := cgm.Tags{
 		cgm.Tag{Category: "disk_type", Value: diskType},
 		cgm.Tag{Category: "disk_name", Value: diskName},
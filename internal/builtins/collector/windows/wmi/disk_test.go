@@ -117,6 +117,44 @@ func TestNewDiskCollector(t *testing.T) {
 		}
 	}
 
+	t.Log("config (decode setting direct)")
+	{
+		c, err := NewDiskCollector(filepath.Join("testdata", "config_decode_direct_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Disk).decode != decodeDirect {
+			t.Fatalf("expected %s, got %s", decodeDirect, c.(*Disk).decode)
+		}
+	}
+
+	t.Log("config (decode setting invalid)")
+	{
+		_, err := NewDiskCollector(filepath.Join("testdata", "config_decode_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (backend setting pdh)")
+	{
+		c, err := NewDiskCollector(filepath.Join("testdata", "config_backend_pdh_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Disk).backend != backendPDH {
+			t.Fatalf("expected %s, got %s", backendPDH, c.(*Disk).backend)
+		}
+	}
+
+	t.Log("config (backend setting invalid)")
+	{
+		_, err := NewDiskCollector(filepath.Join("testdata", "config_backend_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
 	t.Log("config (include regex)")
 	{
 		c, err := NewDiskCollector(filepath.Join("testdata", "config_include_regex_valid_setting"))
@@ -260,3 +298,48 @@ func TestDiskCollect(t *testing.T) {
 		t.Fatalf("expected metrics, got %v", metrics)
 	}
 }
+
+func TestDiskCollectDirect(t *testing.T) {
+	t.Log("Testing Collect (direct decode)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewDiskCollector(filepath.Join("testdata", "config_decode_direct_setting"))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if len(metrics) == 0 {
+		t.Fatalf("expected metrics, got %v", metrics)
+	}
+}
+
+func benchmarkDiskCollect(b *testing.B, cfg string) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewDiskCollector(cfg)
+	if err != nil {
+		b.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Collect(context.Background()); err != nil {
+			b.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+}
+
+func BenchmarkDiskCollectReflect(b *testing.B) {
+	benchmarkDiskCollect(b, "")
+}
+
+func BenchmarkDiskCollectDirect(b *testing.B) {
+	benchmarkDiskCollect(b, filepath.Join("testdata", "config_decode_direct_setting"))
+}
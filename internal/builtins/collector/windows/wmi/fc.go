@@ -0,0 +1,225 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// MSFC_HBAPortStatistics defines the metrics to collect, it is embedded in
+// MSFC_FibrePortHBAStatistics (one instance per HBA port)
+type MSFC_HBAPortStatistics struct { //nolint: golint
+	SecondsSinceLastReset        uint64
+	TxFrames                     uint64
+	TxWords                      uint64
+	RxFrames                     uint64
+	RxWords                      uint64
+	LIPCount                     uint64
+	NOSCount                     uint64
+	ErrorFrames                  uint64
+	DumpedFrames                 uint64
+	LinkFailureCount             uint64
+	LossOfSyncCount              uint64
+	LossOfSignalCount            uint64
+	PrimitiveSeqProtocolErrCount uint64
+	InvalidTxWordCount           uint64
+	InvalidCRCCount              uint64
+}
+
+// fcPort is an MSFC_FibrePortHBAStatistics instance
+type fcPort struct {
+	InstanceName string
+	Active       bool
+	Statistics   MSFC_HBAPortStatistics
+}
+
+// fcNamespace is the wmi namespace of the Fibre Channel HBA (HBA API) classes
+const fcNamespace = `root\WMI`
+
+// FC metrics from the Windows Management Interface (wmi)
+type FC struct {
+	wmicommon
+}
+
+// fcOptions defines what elements can be overridden in a config file
+type fcOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewFCCollector creates new wmi collector
+func NewFCCollector(cfgBaseName string) (collector.Collector, error) {
+	c := FC{}
+	c.id = "fc"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg fcOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *FC) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	// the statistics are an embedded object, which the reflection based
+	// decoding does not support - the properties are read directly
+	var dst []fcPort
+	qry := "SELECT InstanceName, Active, Statistics FROM MSFC_FibrePortHBAStatistics"
+	err := queryDirectNamespace(qry, fcNamespace, func(row *wmiRow) error {
+		var port fcPort
+		row.str(&port.InstanceName, "InstanceName")
+		row.boolean(&port.Active, "Active")
+		row.embedded("Statistics", func(obj *wmiRow) error {
+			s := &port.Statistics
+			obj.u64(&s.SecondsSinceLastReset, "SecondsSinceLastReset")
+			obj.u64(&s.TxFrames, "TxFrames")
+			obj.u64(&s.TxWords, "TxWords")
+			obj.u64(&s.RxFrames, "RxFrames")
+			obj.u64(&s.RxWords, "RxWords")
+			obj.u64(&s.LIPCount, "LIPCount")
+			obj.u64(&s.NOSCount, "NOSCount")
+			obj.u64(&s.ErrorFrames, "ErrorFrames")
+			obj.u64(&s.DumpedFrames, "DumpedFrames")
+			obj.u64(&s.LinkFailureCount, "LinkFailureCount")
+			obj.u64(&s.LossOfSyncCount, "LossOfSyncCount")
+			obj.u64(&s.LossOfSignalCount, "LossOfSignalCount")
+			obj.u64(&s.PrimitiveSeqProtocolErrCount, "PrimitiveSeqProtocolErrCount")
+			obj.u64(&s.InvalidTxWordCount, "InvalidTxWordCount")
+			obj.u64(&s.InvalidCRCCount, "InvalidCRCCount")
+			return obj.err
+		})
+		if row.err != nil {
+			return row.err
+		}
+		dst = append(dst, port)
+		return nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "L"
+	tagUnitsFrames := cgm.Tag{Category: "units", Value: "frames"}
+	tagUnitsBytes := cgm.Tag{Category: "units", Value: "bytes"}
+
+	for _, item := range dst {
+		tagList := cgm.Tags{cgm.Tag{Category: "hba-port", Value: item.InstanceName}}
+
+		active := 0
+		if item.Active {
+			active = 1
+		}
+		_ = c.addMetric(&metrics, "", "Active", "I", active, tagList)
+
+		s := item.Statistics
+		_ = c.addMetric(&metrics, "", "TxFrames", metricType, s.TxFrames, append(tagList, tagUnitsFrames))
+		_ = c.addMetric(&metrics, "", "RxFrames", metricType, s.RxFrames, append(tagList, tagUnitsFrames))
+		// fibre channel words are 4 bytes
+		_ = c.addMetric(&metrics, "", "TxBytes", metricType, s.TxWords*4, append(tagList, tagUnitsBytes))
+		_ = c.addMetric(&metrics, "", "RxBytes", metricType, s.RxWords*4, append(tagList, tagUnitsBytes))
+
+		counters := []struct {
+			name  string
+			value uint64
+		}{
+			{"LIPCount", s.LIPCount},
+			{"NOSCount", s.NOSCount},
+			{"ErrorFrames", s.ErrorFrames},
+			{"DumpedFrames", s.DumpedFrames},
+			{"LinkFailureCount", s.LinkFailureCount},
+			{"LossOfSyncCount", s.LossOfSyncCount},
+			{"LossOfSignalCount", s.LossOfSignalCount},
+			{"PrimitiveSeqProtocolErrCount", s.PrimitiveSeqProtocolErrCount},
+			{"InvalidTxWordCount", s.InvalidTxWordCount},
+			{"InvalidCRCCount", s.InvalidCRCCount},
+			{"SecondsSinceLastReset", s.SecondsSinceLastReset},
+		}
+		for _, ctr := range counters {
+			_ = c.addMetric(&metrics, "", ctr.name, metricType, ctr.value, tagList)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
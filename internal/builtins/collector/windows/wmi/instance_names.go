@@ -0,0 +1,71 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/pkg/errors"
+)
+
+// The performance classes (Win32_PerfFormattedData_*) and their properties
+// are addressed by their invariant (English) names, these do not change
+// with the system locale. Instance names (e.g. network adapter, printer and
+// session broker names) are localized by the OS and drivers however, the
+// normalization map translates them to one name used in metric tags and
+// matched by include/exclude regular expressions on every locale.
+
+// instanceNamesOptions defines the instance name normalization file
+type instanceNamesOptions struct {
+	InstanceNames map[string]string `json:"instance_names" toml:"instance_names" yaml:"instance_names"`
+}
+
+// instanceNames maps lower case localized instance names to normalized
+// names, loaded once by New before any collector is created
+var instanceNames map[string]string
+
+// loadInstanceNames loads the instance name normalization map, a missing file is not an error
+func loadInstanceNames(cfgBaseName string) error {
+	instanceNames = nil
+
+	var cfg instanceNamesOptions
+	if err := config.LoadConfigFile(cfgBaseName, &cfg); err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return nil
+		}
+		return errors.Wrap(err, "instance names config")
+	}
+
+	if len(cfg.InstanceNames) == 0 {
+		return nil
+	}
+
+	instanceNames = make(map[string]string, len(cfg.InstanceNames))
+	for localized, name := range cfg.InstanceNames {
+		if name == "" {
+			return errors.Errorf("invalid instance name for (%s), empty", localized)
+		}
+		instanceNames[strings.ToLower(localized)] = name
+	}
+
+	return nil
+}
+
+// normalizeInstanceName returns the normalized name of a (localized) instance name
+func normalizeInstanceName(name string) string {
+	if n, ok := instanceNames[strings.ToLower(name)]; ok {
+		return n
+	}
+	return name
+}
+
+// instanceName returns the normalized, clean, name of an instance
+func (c *wmicommon) instanceName(name string) string {
+	return c.cleanName(normalizeInstanceName(name))
+}
@@ -0,0 +1,57 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadInstanceNames(t *testing.T) {
+	t.Log("Testing loadInstanceNames")
+
+	defer func() { instanceNames = nil }()
+
+	t.Log("\tno config")
+	{
+		if err := loadInstanceNames(filepath.Join("testdata", "missing")); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if n := normalizeInstanceName("LAN-Verbindung"); n != "LAN-Verbindung" {
+			t.Fatalf("expected name unchanged, got (%s)", n)
+		}
+	}
+
+	t.Log("\tempty name")
+	{
+		if err := loadInstanceNames(filepath.Join("testdata", "instance_names_empty_name")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tvalid")
+	{
+		if err := loadInstanceNames(filepath.Join("testdata", "instance_names")); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		tests := []struct {
+			in   string
+			want string
+		}{
+			{"Intel[R] Ethernet-Verbindung I219-LM", "Intel[R] Ethernet Connection I219-LM"},
+			{"intel[r] ethernet-verbindung i219-lm", "Intel[R] Ethernet Connection I219-LM"},
+			{"Microsoft Print to PDF (Umgeleitet 2)", "Microsoft Print to PDF (redirected 2)"},
+			{"_Total", "_Total"},
+		}
+		for _, tt := range tests {
+			if got := normalizeInstanceName(tt.in); got != tt.want {
+				t.Fatalf("expected (%s), got (%s)", tt.want, got)
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/StackExchange/wmi"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/pdh"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
@@ -59,11 +60,13 @@ type Win32_PerfFormattedData_PerfOS_Memory struct { //nolint: golint
 // Memory metrics from the Windows Management Interface (wmi)
 type Memory struct {
 	wmicommon
+	backend string
 }
 
 // memoryOptions defines what elements can be overridden in a config file
 type memoryOptions struct {
 	ID              string `json:"id" toml:"id" yaml:"id"`
+	Backend         string `json:"backend" toml:"backend" yaml:"backend"`
 	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
 	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
 	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
@@ -79,6 +82,8 @@ func NewMemoryCollector(cfgBaseName string) (collector.Collector, error) {
 	c.metricNameRegex = defaultMetricNameRegex
 	c.baseTags = tags.FromList(tags.GetBaseTags())
 
+	c.backend = backendWMI
+
 	if cfgBaseName == "" {
 		return &c, nil
 	}
@@ -99,6 +104,14 @@ func NewMemoryCollector(cfgBaseName string) (collector.Collector, error) {
 		c.id = cfg.ID
 	}
 
+	if cfg.Backend != "" {
+		backend, err := parseBackend(cfg.Backend)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing backend", c.pkgID)
+		}
+		c.backend = backend
+	}
+
 	if cfg.MetricNameRegex != "" {
 		rx, err := regexp.Compile(cfg.MetricNameRegex)
 		if err != nil {
@@ -147,7 +160,7 @@ func (c *Memory) Collect(ctx context.Context) error {
 
 	var dst []Win32_PerfFormattedData_PerfOS_Memory
 	qry := wmi.CreateQuery(dst, "")
-	if err := wmi.Query(qry, &dst); err != nil {
+	if err := c.query(qry, &dst); err != nil {
 		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 		c.setStatus(metrics, err)
 		return errors.Wrap(err, c.pkgID)
@@ -197,3 +210,11 @@ func (c *Memory) Collect(ctx context.Context) error {
 	c.setStatus(metrics, nil)
 	return nil
 }
+
+// query runs the memory query using the configured backend
+func (c *Memory) query(qry string, dst *[]Win32_PerfFormattedData_PerfOS_Memory) error {
+	if c.backend == backendPDH {
+		return queryPDH(pdh.Memory, dst)
+	}
+	return wmiQuery(qry, dst)
+}
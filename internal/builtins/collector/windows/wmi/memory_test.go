@@ -13,6 +13,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
 	"github.com/rs/zerolog"
 )
 
@@ -67,6 +69,25 @@ func TestNewMemoryCollector(t *testing.T) {
 		}
 	}
 
+	t.Log("config (backend setting pdh)")
+	{
+		c, err := NewMemoryCollector(filepath.Join("testdata", "config_backend_pdh_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Memory).backend != backendPDH {
+			t.Fatalf("expected %s, got %s", backendPDH, c.(*Memory).backend)
+		}
+	}
+
+	t.Log("config (backend setting invalid)")
+	{
+		_, err := NewMemoryCollector(filepath.Join("testdata", "config_backend_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
 	t.Log("config (run ttl 5m)")
 	{
 		c, err := NewMemoryCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
@@ -128,3 +149,28 @@ func TestMemoryCollect(t *testing.T) {
 		t.Fatalf("expected metrics, got %v", metrics)
 	}
 }
+
+func TestMemoryCollectRecorded(t *testing.T) {
+	t.Log("Testing Collect (recorded wmi)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	replay, err := testutil.NewWMIReplay(filepath.Join("testdata", "recorded_memory.json"))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	wmiQuery = replay.Query
+	defer func() { wmiQuery = wmi.Query }()
+
+	c, err := NewMemoryCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := testutil.Collect(t, c)
+
+	testutil.AssertMetric(t, metrics, "AvailableBytes", "L", 2147483648, "units:bytes")
+	testutil.AssertMetric(t, metrics, "CommittedBytes", "L", 6442450944)
+	testutil.AssertMetric(t, metrics, "PageFaultsPersec", "L", 1234)
+	testutil.AssertMetric(t, metrics, "WriteCopiesPersec", "L", 0)
+}
@@ -0,0 +1,187 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// DSM_Path_V2 defines the path state to collect, DSM_Paths of the
+// DSM_Load_Balance_Policy_V2 embedded in DSM_QueryLBPolicy_V2 (one instance
+// per Microsoft DSM multipath disk)
+type DSM_Path_V2 struct { //nolint: golint
+	DsmPathId     uint64
+	PrimaryPath   uint32
+	OptimizedPath uint32
+	FailedPath    uint32
+}
+
+// mpioDisk is a DSM_QueryLBPolicy_V2 instance
+type mpioDisk struct {
+	InstanceName string
+	Paths        []DSM_Path_V2
+}
+
+// mpioNamespace is the wmi namespace of the MPIO and Microsoft DSM classes
+const mpioNamespace = `root\WMI`
+
+// MPIO metrics from the Windows Management Interface (wmi)
+type MPIO struct {
+	wmicommon
+}
+
+// mpioOptions defines what elements can be overridden in a config file
+type mpioOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewMPIOCollector creates new wmi collector
+func NewMPIOCollector(cfgBaseName string) (collector.Collector, error) {
+	c := MPIO{}
+	c.id = "mpio"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg mpioOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *MPIO) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	// the paths are an array of objects embedded in an embedded object, which
+	// the reflection based decoding does not support - the properties are read directly
+	var dst []mpioDisk
+	qry := "SELECT InstanceName, LoadBalancePolicy FROM DSM_QueryLBPolicy_V2"
+	err := queryDirectNamespace(qry, mpioNamespace, func(row *wmiRow) error {
+		var disk mpioDisk
+		row.str(&disk.InstanceName, "InstanceName")
+		row.embedded("LoadBalancePolicy", func(policy *wmiRow) error {
+			policy.embeddedArray("DSM_Paths", func(obj *wmiRow) error {
+				var p DSM_Path_V2
+				obj.u64(&p.DsmPathId, "DsmPathId")
+				obj.u32(&p.PrimaryPath, "PrimaryPath")
+				obj.u32(&p.OptimizedPath, "OptimizedPath")
+				obj.u32(&p.FailedPath, "FailedPath")
+				if obj.err != nil {
+					return obj.err
+				}
+				disk.Paths = append(disk.Paths, p)
+				return nil
+			})
+			return policy.err
+		})
+		if row.err != nil {
+			return row.err
+		}
+		dst = append(dst, disk)
+		return nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "I"
+	tagUnitsPaths := cgm.Tag{Category: "units", Value: "paths"}
+
+	for _, item := range dst {
+		tagList := cgm.Tags{cgm.Tag{Category: "mpio-disk", Value: item.InstanceName}, tagUnitsPaths}
+
+		active, failed := 0, 0
+		for _, p := range item.Paths {
+			if p.FailedPath != 0 {
+				failed++
+				continue
+			}
+			active++
+		}
+		_ = c.addMetric(&metrics, "", "PathsTotal", metricType, len(item.Paths), tagList)
+		_ = c.addMetric(&metrics, "", "PathsActive", metricType, active, tagList)
+		_ = c.addMetric(&metrics, "", "PathsFailed", metricType, failed, tagList)
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
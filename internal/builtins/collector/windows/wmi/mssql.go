@@ -0,0 +1,346 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// SQL Server performance classes are named after the instance, the structs
+// are named after the default instance (MSSQLSERVER) classes, e.g. for a
+// named instance SQLEXPRESS, Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks
+// is Win32_PerfFormattedData_MSSQLSQLEXPRESS_MSSQLSQLEXPRESSLocks.
+
+// Win32_PerfFormattedData_MSSQLSERVER_SQLServerBufferManager defines the metrics to collect
+type Win32_PerfFormattedData_MSSQLSERVER_SQLServerBufferManager struct { //nolint: golint
+	Buffercachehitratio   uint64
+	Checkpointpagespersec uint64
+	Databasepages         uint64
+	Freeliststallspersec  uint64
+	Lazywritespersec      uint64
+	Pagelifeexpectancy    uint64
+	Pagereadspersec       uint64
+	Pagewritespersec      uint64
+	Targetpages           uint64
+}
+
+// Win32_PerfFormattedData_MSSQLSERVER_SQLServerSQLStatistics defines the metrics to collect
+type Win32_PerfFormattedData_MSSQLSERVER_SQLServerSQLStatistics struct { //nolint: golint
+	BatchRequestsPersec     uint64
+	SQLAttentionrate        uint64
+	SQLCompilationsPersec   uint64
+	SQLReCompilationsPersec uint64
+}
+
+// Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks defines the metrics to collect
+type Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks struct { //nolint: golint
+	Name                    string
+	AverageWaitTimems       uint64
+	LockRequestsPersec      uint64
+	LockTimeoutsPersec      uint64
+	LockWaitsPersec         uint64
+	NumberofDeadlocksPersec uint64
+}
+
+// Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases defines the metrics to collect
+type Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases struct { //nolint: golint
+	Name                    string
+	ActiveTransactions      uint64
+	DataFilesSizeKB         uint64
+	LogBytesFlushedPersec   uint64
+	LogFilesSizeKB          uint64
+	LogFilesUsedSizeKB      uint64
+	LogFlushesPersec        uint64
+	LogGrowths              uint64
+	PercentLogUsed          uint64
+	TransactionsPersec      uint64
+	WriteTransactionsPersec uint64
+}
+
+const (
+	defaultMSSQLInstance = "MSSQLSERVER"
+	mssqlClassPrefix     = "Win32_PerfFormattedData_"
+)
+
+// mssqlInstanceRx valid instance names (part of the class names)
+var mssqlInstanceRx = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// MSSQL SQL Server metrics from the Windows Management Interface (wmi)
+type MSSQL struct {
+	wmicommon
+	instances []string
+	include   *regexp.Regexp
+	exclude   *regexp.Regexp
+}
+
+// mssqlOptions defines what elements can be overridden in a config file
+type mssqlOptions struct {
+	ID              string   `json:"id" toml:"id" yaml:"id"`
+	Instances       []string `json:"instances" toml:"instances" yaml:"instances"`
+	IncludeRegex    string   `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex    string   `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex string   `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string   `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string   `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewMSSQLCollector creates new wmi collector
+func NewMSSQLCollector(cfgBaseName string) (collector.Collector, error) {
+	c := MSSQL{}
+	c.id = "mssql"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	c.instances = []string{defaultMSSQLInstance}
+	c.include = defaultIncludeRegex
+	c.exclude = defaultExcludeRegex
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg mssqlOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if len(cfg.Instances) > 0 {
+		for _, instance := range cfg.Instances {
+			if !mssqlInstanceRx.MatchString(instance) {
+				return nil, errors.Errorf("%s invalid instance name (%s)", c.pkgID, instance)
+			}
+		}
+		c.instances = cfg.Instances
+	}
+
+	// include regex
+	if cfg.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	// exclude regex
+	if cfg.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// mssqlClass returns the class name of a SQL Server performance object of an instance
+func mssqlClass(instance, object string) string {
+	if strings.EqualFold(instance, defaultMSSQLInstance) {
+		return mssqlClassPrefix + defaultMSSQLInstance + "_SQLServer" + object
+	}
+	return mssqlClassPrefix + "MSSQL" + instance + "_MSSQL" + instance + object
+}
+
+// mssqlQuery returns the query of dst (a default instance class struct) for an instance
+func mssqlQuery(dst interface{}, instance, object string) string {
+	qry := wmi.CreateQuery(dst, "")
+	return strings.Replace(qry, " FROM "+mssqlClass(defaultMSSQLInstance, object), " FROM "+mssqlClass(instance, object), 1)
+}
+
+// Collect metrics from the wmi resource
+func (c *MSSQL) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	for _, instance := range c.instances {
+		if err := c.collectInstance(&metrics, instance); err != nil {
+			c.setStatus(metrics, err)
+			return errors.Wrapf(err, "%s instance %s", c.pkgID, instance)
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// collectInstance adds the buffer manager, sql statistics, locks and databases metrics of an instance
+func (c *MSSQL) collectInstance(metrics *cgm.Metrics, instance string) error {
+	metricType := "L"
+	instanceTag := cgm.Tag{Category: "sql-instance", Value: instance}
+	tagUnitsKilobytes := cgm.Tag{Category: "units", Value: "kilobytes"}
+	tagUnitsBytes := cgm.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPages := cgm.Tag{Category: "units", Value: "pages"}
+	tagUnitsPercent := cgm.Tag{Category: "units", Value: "percent"}
+	tagUnitsSeconds := cgm.Tag{Category: "units", Value: "seconds"}
+	tagUnitsMilliseconds := cgm.Tag{Category: "units", Value: "milliseconds"}
+	tagUnitsRequests := cgm.Tag{Category: "units", Value: "requests"}
+	tagUnitsTransactions := cgm.Tag{Category: "units", Value: "transactions"}
+
+	{
+		var dst []Win32_PerfFormattedData_MSSQLSERVER_SQLServerBufferManager
+		qry := mssqlQuery(dst, instance, "BufferManager")
+		if err := wmiQuery(qry, &dst); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			return err
+		}
+
+		for _, item := range dst {
+			_ = c.addMetric(metrics, "", "Buffercachehitratio", metricType, item.Buffercachehitratio, cgm.Tags{instanceTag, tagUnitsPercent})
+			_ = c.addMetric(metrics, "", "Checkpointpagespersec", metricType, item.Checkpointpagespersec, cgm.Tags{instanceTag, tagUnitsPages})
+			_ = c.addMetric(metrics, "", "Databasepages", metricType, item.Databasepages, cgm.Tags{instanceTag, tagUnitsPages})
+			_ = c.addMetric(metrics, "", "Freeliststallspersec", metricType, item.Freeliststallspersec, cgm.Tags{instanceTag, tagUnitsRequests})
+			_ = c.addMetric(metrics, "", "Lazywritespersec", metricType, item.Lazywritespersec, cgm.Tags{instanceTag, tagUnitsPages})
+			_ = c.addMetric(metrics, "", "Pagelifeexpectancy", metricType, item.Pagelifeexpectancy, cgm.Tags{instanceTag, tagUnitsSeconds})
+			_ = c.addMetric(metrics, "", "Pagereadspersec", metricType, item.Pagereadspersec, cgm.Tags{instanceTag, tagUnitsPages})
+			_ = c.addMetric(metrics, "", "Pagewritespersec", metricType, item.Pagewritespersec, cgm.Tags{instanceTag, tagUnitsPages})
+			_ = c.addMetric(metrics, "", "Targetpages", metricType, item.Targetpages, cgm.Tags{instanceTag, tagUnitsPages})
+		}
+	}
+
+	{
+		var dst []Win32_PerfFormattedData_MSSQLSERVER_SQLServerSQLStatistics
+		qry := mssqlQuery(dst, instance, "SQLStatistics")
+		if err := wmiQuery(qry, &dst); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			return err
+		}
+
+		for _, item := range dst {
+			_ = c.addMetric(metrics, "", "BatchRequestsPersec", metricType, item.BatchRequestsPersec, cgm.Tags{instanceTag, tagUnitsRequests})
+			_ = c.addMetric(metrics, "", "SQLAttentionrate", metricType, item.SQLAttentionrate, cgm.Tags{instanceTag, tagUnitsRequests})
+			_ = c.addMetric(metrics, "", "SQLCompilationsPersec", metricType, item.SQLCompilationsPersec, cgm.Tags{instanceTag, tagUnitsRequests})
+			_ = c.addMetric(metrics, "", "SQLReCompilationsPersec", metricType, item.SQLReCompilationsPersec, cgm.Tags{instanceTag, tagUnitsRequests})
+		}
+	}
+
+	{
+		var dst []Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks
+		qry := mssqlQuery(dst, instance, "Locks")
+		if err := wmiQuery(qry, &dst); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			return err
+		}
+
+		for _, item := range dst {
+			resourceName := item.Name
+			metricSuffix := ""
+			if strings.Contains(item.Name, totalName) {
+				resourceName = "all"
+				metricSuffix = totalName
+			}
+			resourceTag := cgm.Tag{Category: "lock-resource", Value: resourceName}
+
+			_ = c.addMetric(metrics, "", "AverageWaitTimems"+metricSuffix, metricType, item.AverageWaitTimems, cgm.Tags{instanceTag, resourceTag, tagUnitsMilliseconds})
+			_ = c.addMetric(metrics, "", "LockRequestsPersec"+metricSuffix, metricType, item.LockRequestsPersec, cgm.Tags{instanceTag, resourceTag, tagUnitsRequests})
+			_ = c.addMetric(metrics, "", "LockTimeoutsPersec"+metricSuffix, metricType, item.LockTimeoutsPersec, cgm.Tags{instanceTag, resourceTag, tagUnitsRequests})
+			_ = c.addMetric(metrics, "", "LockWaitsPersec"+metricSuffix, metricType, item.LockWaitsPersec, cgm.Tags{instanceTag, resourceTag, tagUnitsRequests})
+			_ = c.addMetric(metrics, "", "NumberofDeadlocksPersec"+metricSuffix, metricType, item.NumberofDeadlocksPersec, cgm.Tags{instanceTag, resourceTag, tagUnitsRequests})
+		}
+	}
+
+	{
+		var dst []Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases
+		qry := mssqlQuery(dst, instance, "Databases")
+		if err := wmiQuery(qry, &dst); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			return err
+		}
+
+		for _, item := range dst {
+			dbName := c.instanceName(item.Name)
+			if c.exclude.MatchString(dbName) || !c.include.MatchString(dbName) {
+				continue
+			}
+
+			metricSuffix := ""
+			if strings.Contains(item.Name, totalName) {
+				dbName = "all"
+				metricSuffix = totalName
+			}
+			dbTag := cgm.Tag{Category: "database", Value: dbName}
+
+			_ = c.addMetric(metrics, "", "ActiveTransactions"+metricSuffix, metricType, item.ActiveTransactions, cgm.Tags{instanceTag, dbTag, tagUnitsTransactions})
+			_ = c.addMetric(metrics, "", "DataFilesSizeKB"+metricSuffix, metricType, item.DataFilesSizeKB, cgm.Tags{instanceTag, dbTag, tagUnitsKilobytes})
+			_ = c.addMetric(metrics, "", "LogBytesFlushedPersec"+metricSuffix, metricType, item.LogBytesFlushedPersec, cgm.Tags{instanceTag, dbTag, tagUnitsBytes})
+			_ = c.addMetric(metrics, "", "LogFilesSizeKB"+metricSuffix, metricType, item.LogFilesSizeKB, cgm.Tags{instanceTag, dbTag, tagUnitsKilobytes})
+			_ = c.addMetric(metrics, "", "LogFilesUsedSizeKB"+metricSuffix, metricType, item.LogFilesUsedSizeKB, cgm.Tags{instanceTag, dbTag, tagUnitsKilobytes})
+			_ = c.addMetric(metrics, "", "LogFlushesPersec"+metricSuffix, metricType, item.LogFlushesPersec, cgm.Tags{instanceTag, dbTag})
+			_ = c.addMetric(metrics, "", "LogGrowths"+metricSuffix, metricType, item.LogGrowths, cgm.Tags{instanceTag, dbTag})
+			_ = c.addMetric(metrics, "", "PercentLogUsed"+metricSuffix, metricType, item.PercentLogUsed, cgm.Tags{instanceTag, dbTag, tagUnitsPercent})
+			_ = c.addMetric(metrics, "", "TransactionsPersec"+metricSuffix, metricType, item.TransactionsPersec, cgm.Tags{instanceTag, dbTag, tagUnitsTransactions})
+			_ = c.addMetric(metrics, "", "WriteTransactionsPersec"+metricSuffix, metricType, item.WriteTransactionsPersec, cgm.Tags{instanceTag, dbTag, tagUnitsTransactions})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestNewMSSQLCollector(t *testing.T) {
+	t.Log("Testing NewMSSQLCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("no config")
+	{
+		c, err := NewMSSQLCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(c.(*MSSQL).instances) != 1 || c.(*MSSQL).instances[0] != defaultMSSQLInstance {
+			t.Fatalf("expected default instance, got %v", c.(*MSSQL).instances)
+		}
+	}
+
+	t.Log("config (missing)")
+	{
+		_, err := NewMSSQLCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (bad syntax)")
+	{
+		_, err := NewMSSQLCollector(filepath.Join("testdata", "bad_syntax"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (config no settings)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_no_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c == nil {
+			t.Fatal("expected no nil")
+		}
+	}
+
+	t.Log("config (instances)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_instances_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if strings.Join(c.(*MSSQL).instances, ",") != "MSSQLSERVER,SQLEXPRESS" {
+			t.Fatalf("unexpected instances %v", c.(*MSSQL).instances)
+		}
+	}
+
+	t.Log("config (instances invalid)")
+	{
+		_, err := NewMSSQLCollector(filepath.Join("testdata", "config_instances_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (include regex)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_include_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := fmt.Sprintf(regexPat, `^foo`)
+		if c.(*MSSQL).include.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*MSSQL).include.String())
+		}
+	}
+
+	t.Log("config (include regex invalid)")
+	{
+		_, err := NewMSSQLCollector(filepath.Join("testdata", "config_include_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (exclude regex)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_exclude_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := fmt.Sprintf(regexPat, `^foo`)
+		if c.(*MSSQL).exclude.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*MSSQL).exclude.String())
+		}
+	}
+
+	t.Log("config (exclude regex invalid)")
+	{
+		_, err := NewMSSQLCollector(filepath.Join("testdata", "config_exclude_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (id setting)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_id_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*MSSQL).id != "foo" {
+			t.Fatalf("expected foo, got (%s)", c.ID())
+		}
+	}
+
+	t.Log("config (metric name regex)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_metric_name_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := `^foo`
+		if c.(*MSSQL).metricNameRegex.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*MSSQL).metricNameRegex.String())
+		}
+	}
+
+	t.Log("config (metric name regex invalid)")
+	{
+		_, err := NewMSSQLCollector(filepath.Join("testdata", "config_metric_name_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (metric name char)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_metric_name_char_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*MSSQL).metricNameChar != "-" {
+			t.Fatal("expected '-'")
+		}
+	}
+
+	t.Log("config (run ttl 5m)")
+	{
+		c, err := NewMSSQLCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*MSSQL).runTTL != 5*time.Minute {
+			t.Fatal("expected 5m")
+		}
+	}
+
+	t.Log("config (run ttl invalid)")
+	{
+		_, err := NewMSSQLCollector(filepath.Join("testdata", "config_run_ttl_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestMSSQLClass(t *testing.T) {
+	t.Log("Testing mssqlClass")
+
+	tests := []struct {
+		instance string
+		object   string
+		expect   string
+	}{
+		{"MSSQLSERVER", "Locks", "Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks"},
+		{"mssqlserver", "Databases", "Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases"},
+		{"SQLEXPRESS", "BufferManager", "Win32_PerfFormattedData_MSSQLSQLEXPRESS_MSSQLSQLEXPRESSBufferManager"},
+	}
+
+	for _, tst := range tests {
+		t.Logf("\t%s %s", tst.instance, tst.object)
+		if class := mssqlClass(tst.instance, tst.object); class != tst.expect {
+			t.Fatalf("expected %s, got %s", tst.expect, class)
+		}
+	}
+}
+
+func TestMSSQLFlush(t *testing.T) {
+	t.Log("Testing Flush")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewMSSQLCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected metrics")
+	}
+	if len(metrics) > 0 {
+		t.Fatalf("expected empty metrics, got %v", metrics)
+	}
+}
+
+func TestMSSQLCollectRecorded(t *testing.T) {
+	t.Log("Testing Collect (recorded wmi)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	replay, err := testutil.WMIResults(map[string]interface{}{
+		"Win32_PerfFormattedData_MSSQLSERVER_SQLServerBufferManager": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerBufferManager{
+			{Buffercachehitratio: 99, Pagelifeexpectancy: 3600, Databasepages: 102400},
+		},
+		"Win32_PerfFormattedData_MSSQLSERVER_SQLServerSQLStatistics": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerSQLStatistics{
+			{BatchRequestsPersec: 250, SQLCompilationsPersec: 12},
+		},
+		"Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks{
+			{Name: "_Total", LockWaitsPersec: 3, NumberofDeadlocksPersec: 1},
+			{Name: "Page", LockWaitsPersec: 2},
+			{Name: "Key", LockWaitsPersec: 1, NumberofDeadlocksPersec: 1},
+		},
+		"Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases{
+			{Name: "_Total", TransactionsPersec: 40, DataFilesSizeKB: 3072},
+			{Name: "master", TransactionsPersec: 5, DataFilesSizeKB: 1024},
+			{Name: "sales", TransactionsPersec: 35, DataFilesSizeKB: 2048, PercentLogUsed: 42},
+		},
+		"Win32_PerfFormattedData_MSSQLSQLEXPRESS_MSSQLSQLEXPRESSBufferManager": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerBufferManager{
+			{Buffercachehitratio: 90, Pagelifeexpectancy: 300},
+		},
+		"Win32_PerfFormattedData_MSSQLSQLEXPRESS_MSSQLSQLEXPRESSSQLStatistics": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerSQLStatistics{
+			{BatchRequestsPersec: 10},
+		},
+		"Win32_PerfFormattedData_MSSQLSQLEXPRESS_MSSQLSQLEXPRESSLocks": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerLocks{
+			{Name: "_Total"},
+		},
+		"Win32_PerfFormattedData_MSSQLSQLEXPRESS_MSSQLSQLEXPRESSDatabases": []Win32_PerfFormattedData_MSSQLSERVER_SQLServerDatabases{
+			{Name: "_Total", TransactionsPersec: 2},
+			{Name: "app", TransactionsPersec: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	wmiQuery = replay.Query
+	defer func() { wmiQuery = wmi.Query }()
+
+	t.Log("\tdefault instance")
+	{
+		c, err := NewMSSQLCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "Buffercachehitratio", "L", 99, "sql-instance:MSSQLSERVER", "units:percent")
+		testutil.AssertMetric(t, metrics, "Pagelifeexpectancy", "L", 3600, "sql-instance:MSSQLSERVER")
+		testutil.AssertMetric(t, metrics, "BatchRequestsPersec", "L", 250, "sql-instance:MSSQLSERVER")
+		testutil.AssertMetric(t, metrics, "LockWaitsPersec", "L", 2, "sql-instance:MSSQLSERVER", "lock-resource:Page")
+		testutil.AssertMetric(t, metrics, "NumberofDeadlocksPersec"+totalName, "L", 1, "sql-instance:MSSQLSERVER", "lock-resource:all")
+		testutil.AssertMetric(t, metrics, "TransactionsPersec", "L", 35, "sql-instance:MSSQLSERVER", "database:sales")
+		testutil.AssertMetric(t, metrics, "PercentLogUsed", "L", 42, "database:sales")
+		testutil.AssertMetric(t, metrics, "DataFilesSizeKB"+totalName, "L", 3072, "database:all", "units:kilobytes")
+		testutil.AssertNoMetric(t, metrics, "BatchRequestsPersec", "sql-instance:SQLEXPRESS")
+	}
+
+	t.Log("\tnamed instances, database include/exclude")
+	{
+		c, err := NewMSSQLCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		c.(*MSSQL).instances = []string{defaultMSSQLInstance, "SQLEXPRESS"}
+		c.(*MSSQL).exclude = regexp.MustCompile(fmt.Sprintf(regexPat, `master|_Total`))
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "BatchRequestsPersec", "L", 250, "sql-instance:MSSQLSERVER")
+		testutil.AssertMetric(t, metrics, "BatchRequestsPersec", "L", 10, "sql-instance:SQLEXPRESS")
+		testutil.AssertMetric(t, metrics, "TransactionsPersec", "L", 2, "sql-instance:SQLEXPRESS", "database:app")
+		testutil.AssertNoMetric(t, metrics, "TransactionsPersec", "database:master")
+		testutil.AssertNoMetric(t, metrics, "TransactionsPersec"+totalName, "database:all")
+	}
+
+	t.Log("\tinstance not installed")
+	{
+		c, err := NewMSSQLCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		c.(*MSSQL).instances = []string{"MISSING"}
+
+		if err := c.Collect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/StackExchange/wmi"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/pdh"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
@@ -56,16 +57,21 @@ type NetInterface struct {
 	wmicommon
 	include *regexp.Regexp
 	exclude *regexp.Regexp
+	decode  string
+	backend string
 }
 
 // netInterfaceOptions defines what elements can be overridden in a config file
 type netInterfaceOptions struct {
-	ID              string `json:"id" toml:"id" yaml:"id"`
-	IncludeRegex    string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
-	ExcludeRegex    string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
-	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
-	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
-	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	ID                string `json:"id" toml:"id" yaml:"id"`
+	Decode            string `json:"decode" toml:"decode" yaml:"decode"`
+	Backend           string `json:"backend" toml:"backend" yaml:"backend"`
+	IncludeRegex      string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex      string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex   string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar    string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL            string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	DiscoveryInterval string `json:"discovery_interval" toml:"discovery_interval" yaml:"discovery_interval"`
 }
 
 // NewNetInterfaceCollector creates new wmi collector
@@ -80,6 +86,8 @@ func NewNetInterfaceCollector(cfgBaseName string) (collector.Collector, error) {
 
 	c.include = defaultIncludeRegex
 	c.exclude = defaultExcludeRegex
+	c.decode = decodeReflect
+	c.backend = backendWMI
 
 	if cfgBaseName == "" {
 		return &c, nil
@@ -119,6 +127,22 @@ func NewNetInterfaceCollector(cfgBaseName string) (collector.Collector, error) {
 		c.id = cfg.ID
 	}
 
+	if cfg.Decode != "" {
+		decode, err := parseDecode(cfg.Decode)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing decode", c.pkgID)
+		}
+		c.decode = decode
+	}
+
+	if cfg.Backend != "" {
+		backend, err := parseBackend(cfg.Backend)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing backend", c.pkgID)
+		}
+		c.backend = backend
+	}
+
 	if cfg.MetricNameRegex != "" {
 		rx, err := regexp.Compile(cfg.MetricNameRegex)
 		if err != nil {
@@ -131,6 +155,14 @@ func NewNetInterfaceCollector(cfgBaseName string) (collector.Collector, error) {
 		c.metricNameChar = cfg.MetricNameChar
 	}
 
+	if cfg.DiscoveryInterval != "" {
+		dur, err := time.ParseDuration(cfg.DiscoveryInterval)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing discovery_interval", c.pkgID)
+		}
+		c.wmicommon.discoveryInterval = dur
+	}
+
 	if cfg.RunTTL != "" {
 		dur, err := time.ParseDuration(cfg.RunTTL)
 		if err != nil {
@@ -167,7 +199,7 @@ func (c *NetInterface) Collect(ctx context.Context) error {
 
 	var dst []Win32_PerfRawData_Tcpip_NetworkInterface
 	qry := wmi.CreateQuery(dst, "")
-	if err := wmi.Query(qry, &dst); err != nil {
+	if err := c.query(qry, &dst); err != nil {
 		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 		c.setStatus(metrics, err)
 		return errors.Wrap(err, c.pkgID)
@@ -179,7 +211,7 @@ func (c *NetInterface) Collect(ctx context.Context) error {
 	tagUnitsPackets := cgm.Tag{Category: "units", Value: "packets"}
 
 	for _, ifMetrics := range dst {
-		ifName := c.cleanName(ifMetrics.Name)
+		ifName := c.instanceName(ifMetrics.Name)
 		if c.exclude.MatchString(ifName) || !c.include.MatchString(ifName) {
 			continue
 		}
@@ -190,6 +222,7 @@ func (c *NetInterface) Collect(ctx context.Context) error {
 			metricSuffix = totalName
 		}
 
+		c.seenInstance(ifName)
 		ifTag := cgm.Tag{Category: "network-interface", Value: ifName}
 
 		_ = c.addMetric(&metrics, "", "BytesReceivedPersec"+metricSuffix, metricType, ifMetrics.BytesReceivedPersec, cgm.Tags{ifTag, tagUnitsBytes})
@@ -219,3 +252,44 @@ func (c *NetInterface) Collect(ctx context.Context) error {
 	c.setStatus(metrics, nil)
 	return nil
 }
+
+// query runs the network interface query using the configured backend and decode method
+func (c *NetInterface) query(qry string, dst *[]Win32_PerfRawData_Tcpip_NetworkInterface) error {
+	if c.backend == backendPDH {
+		return queryPDH(pdh.NetworkInterface, dst)
+	}
+	if c.decode != decodeDirect {
+		return wmiQuery(qry, dst)
+	}
+	return queryDirect(qry, func(row *wmiRow) error {
+		var im Win32_PerfRawData_Tcpip_NetworkInterface
+		row.u64(&im.BytesReceivedPersec, "BytesReceivedPersec")
+		row.u64(&im.BytesSentPersec, "BytesSentPersec")
+		row.u64(&im.BytesTotalPersec, "BytesTotalPersec")
+		row.u64(&im.CurrentBandwidth, "CurrentBandwidth")
+		row.str(&im.Name, "Name")
+		row.u64(&im.OffloadedConnections, "OffloadedConnections")
+		row.u64(&im.OutputQueueLength, "OutputQueueLength")
+		row.u64(&im.PacketsOutboundDiscarded, "PacketsOutboundDiscarded")
+		row.u64(&im.PacketsOutboundErrors, "PacketsOutboundErrors")
+		row.u64(&im.PacketsPersec, "PacketsPersec")
+		row.u64(&im.PacketsReceivedDiscarded, "PacketsReceivedDiscarded")
+		row.u64(&im.PacketsReceivedErrors, "PacketsReceivedErrors")
+		row.u64(&im.PacketsReceivedNonUnicastPersec, "PacketsReceivedNonUnicastPersec")
+		row.u64(&im.PacketsReceivedPersec, "PacketsReceivedPersec")
+		row.u64(&im.PacketsReceivedUnicastPersec, "PacketsReceivedUnicastPersec")
+		row.u64(&im.PacketsReceivedUnknown, "PacketsReceivedUnknown")
+		row.u64(&im.PacketsSentNonUnicastPersec, "PacketsSentNonUnicastPersec")
+		row.u64(&im.PacketsSentPersec, "PacketsSentPersec")
+		row.u64(&im.PacketsSentUnicastPersec, "PacketsSentUnicastPersec")
+		row.u64(&im.TCPActiveRSCConnections, "TCPActiveRSCConnections")
+		row.u64(&im.TCPRSCAveragePacketSize, "TCPRSCAveragePacketSize")
+		row.u64(&im.TCPRSCCoalescedPacketsPersec, "TCPRSCCoalescedPacketsPersec")
+		row.u64(&im.TCPRSCExceptionsPersec, "TCPRSCExceptionsPersec")
+		if row.err != nil {
+			return row.err
+		}
+		*dst = append(*dst, im)
+		return nil
+	})
+}
@@ -57,6 +57,44 @@ func TestNewNetInterfaceCollector(t *testing.T) {
 		}
 	}
 
+	t.Log("config (decode setting direct)")
+	{
+		c, err := NewNetInterfaceCollector(filepath.Join("testdata", "config_decode_direct_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*NetInterface).decode != decodeDirect {
+			t.Fatalf("expected %s, got %s", decodeDirect, c.(*NetInterface).decode)
+		}
+	}
+
+	t.Log("config (decode setting invalid)")
+	{
+		_, err := NewNetInterfaceCollector(filepath.Join("testdata", "config_decode_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (backend setting pdh)")
+	{
+		c, err := NewNetInterfaceCollector(filepath.Join("testdata", "config_backend_pdh_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*NetInterface).backend != backendPDH {
+			t.Fatalf("expected %s, got %s", backendPDH, c.(*NetInterface).backend)
+		}
+	}
+
+	t.Log("config (backend setting invalid)")
+	{
+		_, err := NewNetInterfaceCollector(filepath.Join("testdata", "config_backend_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
 	t.Log("config (include regex)")
 	{
 		c, err := NewNetInterfaceCollector(filepath.Join("testdata", "config_include_regex_valid_setting"))
@@ -200,3 +238,28 @@ func TestNetInterfaceCollect(t *testing.T) {
 		t.Fatalf("expected metrics, got %v", metrics)
 	}
 }
+
+func benchmarkNetInterfaceCollect(b *testing.B, cfg string) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewNetInterfaceCollector(cfg)
+	if err != nil {
+		b.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Collect(context.Background()); err != nil {
+			b.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+}
+
+func BenchmarkNetInterfaceCollectReflect(b *testing.B) {
+	benchmarkNetInterfaceCollect(b, "")
+}
+
+func BenchmarkNetInterfaceCollectDirect(b *testing.B) {
+	benchmarkNetInterfaceCollect(b, filepath.Join("testdata", "config_decode_direct_setting"))
+}
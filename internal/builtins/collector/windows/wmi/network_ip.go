@@ -184,7 +184,7 @@ func (c *NetIP) Collect(ctx context.Context) error {
 	if c.ipv4Enabled {
 		var dst []Win32_PerfRawData_Tcpip_IPv4
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := wmiQuery(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -220,7 +220,7 @@ func (c *NetIP) Collect(ctx context.Context) error {
 	if c.ipv6Enabled {
 		var dst []Win32_PerfRawData_Tcpip_IPv6
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := wmiQuery(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -168,7 +168,7 @@ func (c *NetTCP) Collect(ctx context.Context) error {
 	if c.ipv4Enabled {
 		var dst []Win32_PerfRawData_Tcpip_TCPv4
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := wmiQuery(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -196,7 +196,7 @@ func (c *NetTCP) Collect(ctx context.Context) error {
 	if c.ipv6Enabled {
 		var dst []Win32_PerfRawData_Tcpip_TCPv6
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := wmiQuery(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -159,7 +159,7 @@ func (c *NetUDP) Collect(ctx context.Context) error {
 	if c.ipv4Enabled {
 		var dst []Win32_PerfRawData_Tcpip_UDPv4
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := wmiQuery(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -183,7 +183,7 @@ func (c *NetUDP) Collect(ctx context.Context) error {
 	if c.ipv6Enabled {
 		var dst []Win32_PerfRawData_Tcpip_UDPv6
 		qry := wmi.CreateQuery(dst, "")
-		if err := wmi.Query(qry, &dst); err != nil {
+		if err := wmiQuery(qry, &dst); err != nil {
 			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 			c.setStatus(metrics, err)
 			return errors.Wrap(err, c.pkgID)
@@ -123,7 +123,7 @@ func (c *Objects) Collect(ctx context.Context) error {
 
 	var dst []Win32_PerfFormattedData_PerfOS_Objects
 	qry := wmi.CreateQuery(dst, "")
-	if err := wmi.Query(qry, &dst); err != nil {
+	if err := wmiQuery(qry, &dst); err != nil {
 		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 		c.setStatus(metrics, err)
 		return errors.Wrap(err, c.pkgID)
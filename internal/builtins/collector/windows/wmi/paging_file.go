@@ -145,7 +145,7 @@ func (c *PagingFile) Collect(ctx context.Context) error {
 
 	var dst []Win32_PerfFormattedData_PerfOS_PagingFile
 	qry := wmi.CreateQuery(dst, "")
-	if err := wmi.Query(qry, &dst); err != nil {
+	if err := wmiQuery(qry, &dst); err != nil {
 		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 		c.setStatus(metrics, err)
 		return errors.Wrap(err, c.pkgID)
@@ -154,7 +154,7 @@ func (c *PagingFile) Collect(ctx context.Context) error {
 	metricType := "I"
 	tagUnitsPercent := cgm.Tag{Category: "units", Value: "percent"}
 	for _, item := range dst {
-		itemName := c.cleanName(item.Name)
+		itemName := c.instanceName(item.Name)
 		if c.exclude.MatchString(itemName) || !c.include.MatchString(itemName) {
 			continue
 		}
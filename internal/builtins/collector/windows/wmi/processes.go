@@ -66,12 +66,13 @@ type Processes struct {
 
 // ProcessesOptions defines what elements can be overridden in a config file
 type ProcessesOptions struct {
-	ID              string `json:"id" toml:"id" yaml:"id"`
-	IncludeRegex    string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
-	ExcludeRegex    string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
-	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
-	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
-	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	ID                string `json:"id" toml:"id" yaml:"id"`
+	IncludeRegex      string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex      string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex   string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar    string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL            string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	DiscoveryInterval string `json:"discovery_interval" toml:"discovery_interval" yaml:"discovery_interval"`
 }
 
 // NewProcessesCollector creates new wmi collector
@@ -137,6 +138,14 @@ func NewProcessesCollector(cfgBaseName string) (collector.Collector, error) {
 		c.metricNameChar = cfg.MetricNameChar
 	}
 
+	if cfg.DiscoveryInterval != "" {
+		dur, err := time.ParseDuration(cfg.DiscoveryInterval)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing discovery_interval", c.pkgID)
+		}
+		c.wmicommon.discoveryInterval = dur
+	}
+
 	if cfg.RunTTL != "" {
 		dur, err := time.ParseDuration(cfg.RunTTL)
 		if err != nil {
@@ -173,7 +182,7 @@ func (c *Processes) Collect(ctx context.Context) error {
 
 	var dst []Win32_PerfFormattedData_PerfProc_Process
 	qry := wmi.CreateQuery(dst, "")
-	if err := wmi.Query(qry, &dst); err != nil {
+	if err := wmiQuery(qry, &dst); err != nil {
 		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 		c.setStatus(metrics, err)
 		return errors.Wrap(err, c.pkgID)
@@ -186,7 +195,7 @@ func (c *Processes) Collect(ctx context.Context) error {
 	tagUnitsOperations := cgm.Tag{Category: "units", Value: "operations"}
 	tagUnitsPercent := cgm.Tag{Category: "units", Value: "percent"}
 	for _, item := range dst {
-		itemName := c.cleanName(item.Name)
+		itemName := c.instanceName(item.Name)
 		if c.exclude.MatchString(itemName) || !c.include.MatchString(itemName) {
 			continue
 		}
@@ -197,6 +206,7 @@ func (c *Processes) Collect(ctx context.Context) error {
 			metricSuffix = totalName
 		}
 
+		c.seenInstance(itemName)
 		nameTag := cgm.Tag{Category: "process-name", Value: itemName}
 
 		_ = c.addMetric(&metrics, "", "CreatingProcessID"+metricSuffix, metricTypeUint32, item.CreatingProcessID, cgm.Tags{nameTag})
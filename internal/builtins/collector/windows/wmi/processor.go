@@ -17,6 +17,7 @@ import (
 
 	"github.com/StackExchange/wmi"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/pdh"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
@@ -43,17 +44,44 @@ type Win32_PerfFormattedData_PerfOS_Processor struct { //nolint: golint
 	PercentUserTime       uint64
 }
 
+// Win32_PerfRawData_PerfOS_Processor defines the raw counters to collect and cook
+type Win32_PerfRawData_PerfOS_Processor struct { //nolint: golint
+	Name                  string
+	C1TransitionsPersec   uint64
+	C2TransitionsPersec   uint64
+	C3TransitionsPersec   uint64
+	DPCsQueuedPersec      uint32
+	InterruptsPersec      uint32
+	PercentC1Time         uint64
+	PercentC2Time         uint64
+	PercentC3Time         uint64
+	PercentDPCTime        uint64
+	PercentIdleTime       uint64
+	PercentInterruptTime  uint64
+	PercentPrivilegedTime uint64
+	PercentProcessorTime  uint64
+	PercentUserTime       uint64
+	Frequency_PerfTime    uint64
+	Timestamp_PerfTime    uint64
+	Timestamp_Sys100NS    uint64
+}
+
 // Processor metrics from the Windows Management Interface (wmi)
 type Processor struct {
 	wmicommon
 	numCPU        float64
-	reportAllCPUs bool // may be overridden in config file
+	reportAllCPUs bool    // may be overridden in config file
+	rawData       bool    // may be overridden in config file
+	backend       string  // may be overridden in config file
+	cooker        *cooker // previous raw samples, when using raw data
 }
 
 // processorOptions defines what elements can be overridden in a config file
 type processorOptions struct {
 	ID              string `json:"id" toml:"id" yaml:"id"`
 	AllCPU          string `json:"report_all_cpus" toml:"report_all_cpus" yaml:"report_all_cpus"`
+	RawData         string `json:"raw_data" toml:"raw_data" yaml:"raw_data"`
+	Backend         string `json:"backend" toml:"backend" yaml:"backend"`
 	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
 	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
 	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
@@ -71,6 +99,7 @@ func NewProcessorCollector(cfgBaseName string) (collector.Collector, error) {
 
 	c.numCPU = float64(runtime.NumCPU())
 	c.reportAllCPUs = true
+	c.backend = backendWMI
 
 	if cfgBaseName == "" {
 		return &c, nil
@@ -96,6 +125,30 @@ func NewProcessorCollector(cfgBaseName string) (collector.Collector, error) {
 		c.reportAllCPUs = rpt
 	}
 
+	if cfg.RawData != "" {
+		raw, err := strconv.ParseBool(cfg.RawData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing raw_data", c.pkgID)
+		}
+		c.rawData = raw
+		if raw {
+			c.cooker = newCooker()
+		}
+	}
+
+	if cfg.Backend != "" {
+		backend, err := parseBackend(cfg.Backend)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing backend", c.pkgID)
+		}
+		c.backend = backend
+	}
+
+	// pdh cooks the counters
+	if c.rawData && c.backend == backendPDH {
+		return nil, errors.Errorf("%s raw_data is not supported with the %s backend", c.pkgID, backendPDH)
+	}
+
 	if cfg.ID != "" {
 		c.id = cfg.ID
 	}
@@ -146,9 +199,13 @@ func (c *Processor) Collect(ctx context.Context) error {
 	c.lastStart = time.Now()
 	c.Unlock()
 
+	if c.rawData {
+		return c.collectRaw(metrics)
+	}
+
 	var dst []Win32_PerfFormattedData_PerfOS_Processor
 	qry := wmi.CreateQuery(dst, "")
-	if err := wmi.Query(qry, &dst); err != nil {
+	if err := c.query(qry, &dst); err != nil {
 		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
 		c.setStatus(metrics, err)
 		return errors.Wrap(err, c.pkgID)
@@ -157,7 +214,7 @@ func (c *Processor) Collect(ctx context.Context) error {
 	metricType := "L"
 	tagUnitsPercent := cgm.Tag{Category: "units", Value: "percent"}
 	for _, item := range dst {
-		cpuID := c.cleanName(item.Name)
+		cpuID := c.instanceName(item.Name)
 
 		metricSuffix := ""
 		if strings.Contains(item.Name, totalName) {
@@ -188,3 +245,78 @@ func (c *Processor) Collect(ctx context.Context) error {
 	c.setStatus(metrics, nil)
 	return nil
 }
+
+// query runs the processor query using the configured backend
+func (c *Processor) query(qry string, dst *[]Win32_PerfFormattedData_PerfOS_Processor) error {
+	if c.backend == backendPDH {
+		return queryPDH(pdh.Processor, dst)
+	}
+	return wmiQuery(qry, dst)
+}
+
+// collectRaw collects the raw processor counters and cooks them, nothing is
+// emitted for a counter until it has a previous sample
+func (c *Processor) collectRaw(metrics cgm.Metrics) error {
+	var dst []Win32_PerfRawData_PerfOS_Processor
+	qry := wmi.CreateQuery(dst, "")
+	if err := wmiQuery(qry, &dst); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "n"
+	tagUnitsPercent := cgm.Tag{Category: "units", Value: "percent"}
+	for _, item := range dst {
+		cpuID := c.instanceName(item.Name)
+
+		metricSuffix := ""
+		if strings.Contains(item.Name, totalName) {
+			cpuID = "all"
+			metricSuffix = totalName
+		} else if !c.reportAllCPUs {
+			continue
+		}
+
+		cpuTag := cgm.Tag{Category: "cpu-id", Value: cpuID}
+		ts := rawTimestamps{
+			Frequency: item.Frequency_PerfTime,
+			PerfTime:  item.Timestamp_PerfTime,
+			Sys100NS:  item.Timestamp_Sys100NS,
+		}
+
+		counters := []struct {
+			name  string
+			ctype counterType
+			value uint64
+			tags  cgm.Tags
+		}{
+			{"PercentC1Time", perf100NsecTimer, item.PercentC1Time, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentC2Time", perf100NsecTimer, item.PercentC2Time, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentC3Time", perf100NsecTimer, item.PercentC3Time, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentIdleTime", perf100NsecTimer, item.PercentIdleTime, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentInterruptTime", perf100NsecTimer, item.PercentInterruptTime, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentDPCTime", perf100NsecTimer, item.PercentDPCTime, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentPrivilegedTime", perf100NsecTimer, item.PercentPrivilegedTime, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentUserTime", perf100NsecTimer, item.PercentUserTime, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"PercentProcessorTime", perf100NsecTimerInv, item.PercentProcessorTime, cgm.Tags{cpuTag, tagUnitsPercent}},
+			{"C1TransitionsPersec", perfCounterCounter, item.C1TransitionsPersec, cgm.Tags{cpuTag}},
+			{"C2TransitionsPersec", perfCounterCounter, item.C2TransitionsPersec, cgm.Tags{cpuTag}},
+			{"C3TransitionsPersec", perfCounterCounter, item.C3TransitionsPersec, cgm.Tags{cpuTag}},
+			{"InterruptsPersec", perfCounterCounter, uint64(item.InterruptsPersec), cgm.Tags{cpuTag}},
+			{"DPCsQueuedPersec", perfCounterCounter, uint64(item.DPCsQueuedPersec), cgm.Tags{cpuTag}},
+		}
+
+		for _, ctr := range counters {
+			v, ok := c.cooker.cook(item.Name+metricNameSeparator+ctr.name, ctr.ctype, ts, ctr.value, 0)
+			if !ok {
+				continue
+			}
+			_ = c.addMetric(&metrics, "", ctr.name+metricSuffix, metricType, v, ctr.tags)
+		}
+	}
+
+	c.cooker.done()
+	c.setStatus(metrics, nil)
+	return nil
+}
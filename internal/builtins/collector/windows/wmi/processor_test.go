@@ -86,6 +86,55 @@ func TestNewProcessorCollector(t *testing.T) {
 		}
 	}
 
+	t.Log("config (raw data setting true)")
+	{
+		c, err := NewProcessorCollector(filepath.Join("testdata", "config_raw_data_true_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !c.(*Processor).rawData {
+			t.Fatal("expected true")
+		}
+		if c.(*Processor).cooker == nil {
+			t.Fatal("expected cooker")
+		}
+	}
+
+	t.Log("config (raw data setting invalid)")
+	{
+		_, err := NewProcessorCollector(filepath.Join("testdata", "config_raw_data_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (backend setting pdh)")
+	{
+		c, err := NewProcessorCollector(filepath.Join("testdata", "config_backend_pdh_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Processor).backend != backendPDH {
+			t.Fatalf("expected %s, got %s", backendPDH, c.(*Processor).backend)
+		}
+	}
+
+	t.Log("config (backend setting invalid)")
+	{
+		_, err := NewProcessorCollector(filepath.Join("testdata", "config_backend_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (backend setting pdh with raw data)")
+	{
+		_, err := NewProcessorCollector(filepath.Join("testdata", "config_backend_pdh_raw_data_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
 	t.Log("config (id setting)")
 	{
 		c, err := NewProcessorCollector(filepath.Join("testdata", "config_id_setting"))
@@ -0,0 +1,221 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Win32_Service defines the metrics to collect
+type Win32_Service struct { //nolint: golint
+	Name      string
+	StartMode string
+	State     string
+}
+
+// serviceStates State values, as the service control manager SERVICE_STATUS current state codes
+var serviceStates = map[string]uint32{
+	"Stopped":          1,
+	"Start Pending":    2,
+	"Stop Pending":     3,
+	"Running":          4,
+	"Continue Pending": 5,
+	"Pause Pending":    6,
+	"Paused":           7,
+}
+
+// serviceStartModes StartMode values, as the service control manager SERVICE_START_TYPE codes
+var serviceStartModes = map[string]uint32{
+	"Boot":     0,
+	"System":   1,
+	"Auto":     2,
+	"Manual":   3,
+	"Disabled": 4,
+}
+
+const (
+	serviceStateUnknown     = 0 // State values not in serviceStates
+	serviceStartModeUnknown = 5 // StartMode values not in serviceStartModes
+)
+
+// Services state metrics from the Windows Management Interface (wmi)
+type Services struct {
+	wmicommon
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// servicesOptions defines what elements can be overridden in a config file
+type servicesOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	IncludeRegex    string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex    string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewServicesCollector creates new wmi collector
+func NewServicesCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Services{}
+	c.id = "services"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	c.include = defaultIncludeRegex
+	c.exclude = defaultExcludeRegex
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg servicesOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	// include regex
+	if cfg.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	// exclude regex
+	if cfg.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *Services) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	var dst []Win32_Service
+	qry := wmi.CreateQuery(dst, "")
+	if err := wmiQuery(qry, &dst); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "I"
+	numServices := 0
+	numRunning := 0
+	numAutoNotRunning := 0
+	for _, item := range dst {
+		// matched against the service name (e.g. "W32Time"), not the display name
+		if c.exclude.MatchString(item.Name) || !c.include.MatchString(item.Name) {
+			continue
+		}
+
+		state, ok := serviceStates[item.State]
+		if !ok {
+			state = serviceStateUnknown
+		}
+		startMode, ok := serviceStartModes[item.StartMode]
+		if !ok {
+			startMode = serviceStartModeUnknown
+		}
+
+		running := 0
+		if item.State == "Running" {
+			running = 1
+			numRunning++
+		} else if item.StartMode == "Auto" {
+			numAutoNotRunning++
+		}
+		numServices++
+
+		serviceTags := cgm.Tags{cgm.Tag{Category: "service", Value: c.cleanName(item.Name)}}
+
+		_ = c.addMetric(&metrics, "", "Running", metricType, running, serviceTags)
+		_ = c.addMetric(&metrics, "", "State", metricType, state, serviceTags)
+		_ = c.addMetric(&metrics, "", "StartMode", metricType, startMode, serviceTags)
+	}
+
+	_ = c.addMetric(&metrics, "", "Services", metricType, numServices, cgm.Tags{})
+	_ = c.addMetric(&metrics, "", "ServicesRunning", metricType, numRunning, cgm.Tags{})
+	_ = c.addMetric(&metrics, "", "AutoServicesNotRunning", metricType, numAutoNotRunning, cgm.Tags{})
+
+	c.setStatus(metrics, nil)
+	return nil
+}
@@ -0,0 +1,267 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestNewServicesCollector(t *testing.T) {
+	t.Log("Testing NewServicesCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("no config")
+	{
+		_, err := NewServicesCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (missing)")
+	{
+		_, err := NewServicesCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (bad syntax)")
+	{
+		_, err := NewServicesCollector(filepath.Join("testdata", "bad_syntax"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (config no settings)")
+	{
+		c, err := NewServicesCollector(filepath.Join("testdata", "config_no_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c == nil {
+			t.Fatal("expected no nil")
+		}
+	}
+
+	t.Log("config (include regex)")
+	{
+		c, err := NewServicesCollector(filepath.Join("testdata", "config_include_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := fmt.Sprintf(regexPat, `^foo`)
+		if c.(*Services).include.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*Services).include.String())
+		}
+	}
+
+	t.Log("config (include regex invalid)")
+	{
+		_, err := NewServicesCollector(filepath.Join("testdata", "config_include_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (exclude regex)")
+	{
+		c, err := NewServicesCollector(filepath.Join("testdata", "config_exclude_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := fmt.Sprintf(regexPat, `^foo`)
+		if c.(*Services).exclude.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*Services).exclude.String())
+		}
+	}
+
+	t.Log("config (exclude regex invalid)")
+	{
+		_, err := NewServicesCollector(filepath.Join("testdata", "config_exclude_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (id setting)")
+	{
+		c, err := NewServicesCollector(filepath.Join("testdata", "config_id_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Services).id != "foo" {
+			t.Fatalf("expected foo, got (%s)", c.ID())
+		}
+	}
+
+	t.Log("config (metric name regex)")
+	{
+		c, err := NewServicesCollector(filepath.Join("testdata", "config_metric_name_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := `^foo`
+		if c.(*Services).metricNameRegex.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*Services).metricNameRegex.String())
+		}
+	}
+
+	t.Log("config (metric name regex invalid)")
+	{
+		_, err := NewServicesCollector(filepath.Join("testdata", "config_metric_name_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (metric name char)")
+	{
+		c, err := NewServicesCollector(filepath.Join("testdata", "config_metric_name_char_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Services).metricNameChar != "-" {
+			t.Fatal("expected '-'")
+		}
+	}
+
+	t.Log("config (run ttl 5m)")
+	{
+		c, err := NewServicesCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Services).runTTL != 5*time.Minute {
+			t.Fatal("expected 5m")
+		}
+	}
+
+	t.Log("config (run ttl invalid)")
+	{
+		_, err := NewServicesCollector(filepath.Join("testdata", "config_run_ttl_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestServicesFlush(t *testing.T) {
+	t.Log("Testing Flush")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewServicesCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected metrics")
+	}
+	if len(metrics) > 0 {
+		t.Fatalf("expected empty metrics, got %v", metrics)
+	}
+}
+
+func TestServicesCollect(t *testing.T) {
+	t.Log("Testing Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewServicesCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected error")
+	}
+	if len(metrics) == 0 {
+		t.Fatalf("expected metrics, got %v", metrics)
+	}
+}
+
+func TestServicesCollectRecorded(t *testing.T) {
+	t.Log("Testing Collect (recorded wmi)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	replay, err := testutil.WMIResults(map[string]interface{}{
+		"Win32_Service": []Win32_Service{
+			{Name: "W32Time", StartMode: "Auto", State: "Running"},
+			{Name: "Spooler", StartMode: "Auto", State: "Stopped"},
+			{Name: "wuauserv", StartMode: "Manual", State: "Stopped"},
+			{Name: "MSSQL$SQLEXPRESS", StartMode: "Disabled", State: "Paused"},
+			{Name: "Dnscache", StartMode: "Auto", State: "Start Pending"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	wmiQuery = replay.Query
+	defer func() { wmiQuery = wmi.Query }()
+
+	t.Log("\tall services")
+	{
+		c, err := NewServicesCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "Running", "I", 1, "service:W32Time")
+		testutil.AssertMetric(t, metrics, "State", "I", 4, "service:W32Time")
+		testutil.AssertMetric(t, metrics, "StartMode", "I", 2, "service:W32Time")
+		testutil.AssertMetric(t, metrics, "Running", "I", 0, "service:Spooler")
+		testutil.AssertMetric(t, metrics, "State", "I", 1, "service:Spooler")
+		testutil.AssertMetric(t, metrics, "StartMode", "I", 3, "service:wuauserv")
+		testutil.AssertMetric(t, metrics, "State", "I", 7, "service:MSSQL_SQLEXPRESS")
+		testutil.AssertMetric(t, metrics, "StartMode", "I", 4, "service:MSSQL_SQLEXPRESS")
+		testutil.AssertMetric(t, metrics, "State", "I", 2, "service:Dnscache")
+		testutil.AssertMetric(t, metrics, "Services", "I", 5)
+		testutil.AssertMetric(t, metrics, "ServicesRunning", "I", 1)
+		testutil.AssertMetric(t, metrics, "AutoServicesNotRunning", "I", 2)
+	}
+
+	t.Log("\tinclude/exclude")
+	{
+		c, err := NewServicesCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		c.(*Services).include = regexp.MustCompile(fmt.Sprintf(regexPat, `W32Time|Spooler|wuauserv`))
+		c.(*Services).exclude = regexp.MustCompile(fmt.Sprintf(regexPat, `wuauserv`))
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "Running", "I", 1, "service:W32Time")
+		testutil.AssertNoMetric(t, metrics, "Running", "service:wuauserv")
+		testutil.AssertNoMetric(t, metrics, "Running", "service:Dnscache")
+		testutil.AssertMetric(t, metrics, "Services", "I", 2)
+		testutil.AssertMetric(t, metrics, "AutoServicesNotRunning", "I", 1)
+	}
+}
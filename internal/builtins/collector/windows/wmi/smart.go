@@ -0,0 +1,292 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// MSStorageDriver_FailurePredictStatus defines the drive failure prediction
+// (SMART health) status to collect
+type MSStorageDriver_FailurePredictStatus struct { //nolint: golint
+	InstanceName   string
+	Active         bool
+	PredictFailure bool
+	Reason         uint32
+}
+
+// MSStorageDriver_FailurePredictData defines the SMART data to collect,
+// VendorSpecific is the raw ATA SMART attribute table
+type MSStorageDriver_FailurePredictData struct { //nolint: golint
+	InstanceName   string
+	Active         bool
+	VendorSpecific []uint8
+}
+
+// smartNamespace is the wmi namespace of the storage driver failure prediction classes
+const smartNamespace = `root\WMI`
+
+// ATA SMART attribute table (SMART READ DATA), a 2 byte revision followed by
+// 30 entries of 12 bytes: id, flags (2), normalized value, worst, raw value
+// (6, little endian), reserved
+const (
+	smartAttrOffset = 2
+	smartAttrSize   = 12
+	smartAttrCount  = 30
+)
+
+// ATA SMART attribute ids
+const (
+	smartAttrReallocatedSectors   = 5
+	smartAttrPowerOnHours         = 9
+	smartAttrPowerCycles          = 12
+	smartAttrWearLevelingCount    = 177 // normalized value is the remaining life (percent), e.g. Samsung
+	smartAttrAirflowTemperature   = 190
+	smartAttrTemperature          = 194
+	smartAttrPendingSectors       = 197
+	smartAttrUncorrectableSectors = 198
+	smartAttrSSDLifeLeft          = 231
+	smartAttrMediaWearout         = 233 // e.g. Intel
+)
+
+// smartAttr is an entry of the ATA SMART attribute table
+type smartAttr struct {
+	value uint8
+	raw   uint64
+}
+
+// Smart metrics (drive health) from the Windows Management Interface (wmi)
+type Smart struct {
+	wmicommon
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// smartOptions defines what elements can be overridden in a config file
+type smartOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	IncludeRegex    string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex    string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewSmartCollector creates new wmi collector
+func NewSmartCollector(cfgBaseName string) (collector.Collector, error) {
+	c := Smart{}
+	c.id = "smart"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	c.include = defaultIncludeRegex
+	c.exclude = defaultExcludeRegex
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg smartOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	// include regex
+	if cfg.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	// exclude regex
+	if cfg.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *Smart) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	var status []MSStorageDriver_FailurePredictStatus
+	qry := wmi.CreateQuery(status, "")
+	if err := wmiQueryNamespace(qry, &status, smartNamespace); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	var data []MSStorageDriver_FailurePredictData
+	qry = wmi.CreateQuery(data, "")
+	if err := wmiQueryNamespace(qry, &data, smartNamespace); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	attrs := make(map[string]map[uint8]smartAttr, len(data))
+	for _, item := range data {
+		if item.Active {
+			attrs[item.InstanceName] = parseSmartAttrs(item.VendorSpecific)
+		}
+	}
+
+	tagUnitsCelsius := cgm.Tag{Category: "units", Value: "celsius"}
+	tagUnitsHours := cgm.Tag{Category: "units", Value: "hours"}
+	tagUnitsPercent := cgm.Tag{Category: "units", Value: "percent"}
+	tagUnitsSectors := cgm.Tag{Category: "units", Value: "sectors"}
+
+	for _, item := range status {
+		if !item.Active {
+			continue
+		}
+		driveName := c.instanceName(item.InstanceName)
+		if c.exclude.MatchString(driveName) || !c.include.MatchString(driveName) {
+			continue
+		}
+
+		driveTag := cgm.Tag{Category: "drive", Value: driveName}
+
+		predictFailure := 0
+		if item.PredictFailure {
+			predictFailure = 1
+		}
+		_ = c.addMetric(&metrics, "", "PredictFailure", "I", predictFailure, cgm.Tags{driveTag})
+
+		da, ok := attrs[item.InstanceName]
+		if !ok {
+			continue
+		}
+
+		// the raw temperature packs the current (low byte), min and max temperatures
+		if a, ok := da[smartAttrTemperature]; ok {
+			_ = c.addMetric(&metrics, "", "Temperature", "L", a.raw&0xff, cgm.Tags{driveTag, tagUnitsCelsius})
+		} else if a, ok := da[smartAttrAirflowTemperature]; ok {
+			_ = c.addMetric(&metrics, "", "Temperature", "L", a.raw&0xff, cgm.Tags{driveTag, tagUnitsCelsius})
+		}
+		if a, ok := da[smartAttrReallocatedSectors]; ok {
+			_ = c.addMetric(&metrics, "", "ReallocatedSectors", "L", a.raw&0xffffffff, cgm.Tags{driveTag, tagUnitsSectors})
+		}
+		if a, ok := da[smartAttrPendingSectors]; ok {
+			_ = c.addMetric(&metrics, "", "PendingSectors", "L", a.raw&0xffffffff, cgm.Tags{driveTag, tagUnitsSectors})
+		}
+		if a, ok := da[smartAttrUncorrectableSectors]; ok {
+			_ = c.addMetric(&metrics, "", "UncorrectableSectors", "L", a.raw&0xffffffff, cgm.Tags{driveTag, tagUnitsSectors})
+		}
+		// some drives pack minutes/seconds in the high bytes of the power on hours
+		if a, ok := da[smartAttrPowerOnHours]; ok {
+			_ = c.addMetric(&metrics, "", "PowerOnHours", "L", a.raw&0xffffffff, cgm.Tags{driveTag, tagUnitsHours})
+		}
+		if a, ok := da[smartAttrPowerCycles]; ok {
+			_ = c.addMetric(&metrics, "", "PowerCycles", "L", a.raw&0xffffffff, cgm.Tags{driveTag})
+		}
+		// ssd wear, the vendor attribute's normalized value counts down from 100 (new)
+		for _, id := range []uint8{smartAttrWearLevelingCount, smartAttrSSDLifeLeft, smartAttrMediaWearout} {
+			if a, ok := da[id]; ok && a.value <= 100 {
+				_ = c.addMetric(&metrics, "", "PercentUsed", "I", 100-int(a.value), cgm.Tags{driveTag, tagUnitsPercent})
+				break
+			}
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// parseSmartAttrs parses an ATA SMART attribute table, by attribute id
+func parseSmartAttrs(data []uint8) map[uint8]smartAttr {
+	attrs := make(map[uint8]smartAttr)
+	for i := 0; i < smartAttrCount; i++ {
+		off := smartAttrOffset + i*smartAttrSize
+		if off+smartAttrSize > len(data) {
+			break
+		}
+		entry := data[off : off+smartAttrSize]
+		if entry[0] == 0 {
+			continue // unused entry
+		}
+		raw := make([]byte, 8)
+		copy(raw, entry[5:11])
+		attrs[entry[0]] = smartAttr{value: entry[3], raw: binary.LittleEndian.Uint64(raw)}
+	}
+	return attrs
+}
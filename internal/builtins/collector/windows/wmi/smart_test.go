@@ -0,0 +1,181 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestNewSmartCollector(t *testing.T) {
+	t.Log("Testing NewSmartCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("no config")
+	{
+		_, err := NewSmartCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (missing)")
+	{
+		_, err := NewSmartCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (bad syntax)")
+	{
+		_, err := NewSmartCollector(filepath.Join("testdata", "bad_syntax"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (id setting)")
+	{
+		c, err := NewSmartCollector(filepath.Join("testdata", "config_id_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Smart).id != "foo" {
+			t.Fatalf("expected foo, got (%s)", c.ID())
+		}
+	}
+
+	t.Log("config (include regex)")
+	{
+		c, err := NewSmartCollector(filepath.Join("testdata", "config_include_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := `^(?:foo)$`
+		if c.(*Smart).include.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*Smart).include.String())
+		}
+	}
+
+	t.Log("config (include regex invalid)")
+	{
+		_, err := NewSmartCollector(filepath.Join("testdata", "config_include_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (exclude regex invalid)")
+	{
+		_, err := NewSmartCollector(filepath.Join("testdata", "config_exclude_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (run ttl 5m)")
+	{
+		c, err := NewSmartCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*Smart).runTTL != 5*time.Minute {
+			t.Fatal("expected 5m")
+		}
+	}
+
+	t.Log("config (run ttl invalid)")
+	{
+		_, err := NewSmartCollector(filepath.Join("testdata", "config_run_ttl_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+// smartAttrTable returns an ATA SMART attribute table with the entries
+// (id, normalized value, raw value)
+func smartAttrTable(entries ...[3]uint64) []uint8 {
+	data := make([]uint8, 512)
+	for i, e := range entries {
+		off := smartAttrOffset + i*smartAttrSize
+		data[off] = uint8(e[0])
+		data[off+3] = uint8(e[1])
+		for b := 0; b < 6; b++ {
+			data[off+5+b] = uint8(e[2] >> (8 * uint(b)))
+		}
+	}
+	return data
+}
+
+func TestSmartCollectRecorded(t *testing.T) {
+	t.Log("Testing Collect (recorded wmi)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	replay, err := testutil.WMIResults(map[string]interface{}{
+		"MSStorageDriver_FailurePredictStatus": []MSStorageDriver_FailurePredictStatus{
+			{InstanceName: `IDE\DiskSSD_860_EVO\5&1a2b_0`, Active: true},
+			{InstanceName: `IDE\DiskST4000DM004\5&3c4d_0`, Active: true, PredictFailure: true, Reason: 5},
+			{InstanceName: `SCSI\Disk&Ven_NVMe\6&5e6f_0`, Active: false},
+		},
+		"MSStorageDriver_FailurePredictData": []MSStorageDriver_FailurePredictData{
+			{InstanceName: `IDE\DiskSSD_860_EVO\5&1a2b_0`, Active: true, VendorSpecific: smartAttrTable(
+				[3]uint64{smartAttrReallocatedSectors, 100, 0},
+				[3]uint64{smartAttrPowerOnHours, 99, 12345},
+				[3]uint64{smartAttrPowerCycles, 99, 321},
+				[3]uint64{smartAttrWearLevelingCount, 97, 42},
+				[3]uint64{smartAttrTemperature, 67, 0x28140021}, // max 40, min 20, current 33
+			)},
+			{InstanceName: `IDE\DiskST4000DM004\5&3c4d_0`, Active: true, VendorSpecific: smartAttrTable(
+				[3]uint64{smartAttrReallocatedSectors, 80, 1024},
+				[3]uint64{smartAttrAirflowTemperature, 62, 38},
+				[3]uint64{smartAttrPendingSectors, 100, 8},
+				[3]uint64{smartAttrUncorrectableSectors, 100, 2},
+			)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	wmiQueryNamespace = replay.QueryNamespace
+	defer func() { wmiQueryNamespace = wmi.QueryNamespace }()
+
+	c, err := NewSmartCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := testutil.Collect(t, c)
+
+	ssd := `drive:IDE\DiskSSD_860_EVO\5_1a2b_0`
+	testutil.AssertMetric(t, metrics, "PredictFailure", "I", 0, ssd)
+	testutil.AssertMetric(t, metrics, "Temperature", "L", 33, ssd, "units:celsius")
+	testutil.AssertMetric(t, metrics, "ReallocatedSectors", "L", 0, ssd, "units:sectors")
+	testutil.AssertMetric(t, metrics, "PowerOnHours", "L", 12345, ssd, "units:hours")
+	testutil.AssertMetric(t, metrics, "PowerCycles", "L", 321, ssd)
+	testutil.AssertMetric(t, metrics, "PercentUsed", "I", 3, ssd, "units:percent")
+	testutil.AssertNoMetric(t, metrics, "PendingSectors", ssd)
+
+	hdd := `drive:IDE\DiskST4000DM004\5_3c4d_0`
+	testutil.AssertMetric(t, metrics, "PredictFailure", "I", 1, hdd)
+	testutil.AssertMetric(t, metrics, "Temperature", "L", 38, hdd, "units:celsius")
+	testutil.AssertMetric(t, metrics, "ReallocatedSectors", "L", 1024, hdd, "units:sectors")
+	testutil.AssertMetric(t, metrics, "PendingSectors", "L", 8, hdd, "units:sectors")
+	testutil.AssertMetric(t, metrics, "UncorrectableSectors", "L", 2, hdd, "units:sectors")
+	testutil.AssertNoMetric(t, metrics, "PercentUsed", hdd)
+
+	testutil.AssertNoMetric(t, metrics, "PredictFailure", `drive:SCSI\Disk_Ven_NVMe\6_5e6f_0`)
+}
@@ -0,0 +1,301 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// MSFT_StoragePool defines the metrics to collect
+type MSFT_StoragePool struct { //nolint: golint
+	FriendlyName  string
+	AllocatedSize uint64
+	HealthStatus  uint16
+	IsPrimordial  bool
+	IsReadOnly    bool
+	Size          uint64
+}
+
+// MSFT_VirtualDisk defines the metrics to collect
+type MSFT_VirtualDisk struct { //nolint: golint
+	FriendlyName           string
+	FootprintOnPool        uint64
+	HealthStatus           uint16
+	NumberOfDataCopies     uint16
+	PhysicalDiskRedundancy uint16
+	ResiliencySettingName  string
+	Size                   uint64
+}
+
+// MSFT_StorageJob defines the metrics to collect
+type MSFT_StorageJob struct { //nolint: golint
+	Name            string
+	BytesProcessed  uint64
+	BytesTotal      uint64
+	JobState        uint16
+	PercentComplete uint16
+}
+
+// MSFT_Volume defines the metrics to collect
+type MSFT_Volume struct { //nolint: golint
+	DriveLetter     uint16
+	FileSystemLabel string
+	HealthStatus    uint16
+	Path            string
+}
+
+const (
+	storageNamespace = `root\Microsoft\Windows\Storage`
+	jobStateRunning  = 4 // MSFT_StorageJob JobState
+)
+
+// StorageSpaces metrics from the Windows Management Interface (wmi)
+type StorageSpaces struct {
+	wmicommon
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// storageSpacesOptions defines what elements can be overridden in a config file
+type storageSpacesOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	IncludeRegex    string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex    string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewStorageSpacesCollector creates new wmi collector
+func NewStorageSpacesCollector(cfgBaseName string) (collector.Collector, error) {
+	c := StorageSpaces{}
+	c.id = "storage_spaces"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	c.include = defaultIncludeRegex
+	c.exclude = defaultExcludeRegex
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg storageSpacesOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	// include regex
+	if cfg.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	// exclude regex
+	if cfg.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *StorageSpaces) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	metricType := "I"
+	tagUnitsBytes := cgm.Tag{Category: "units", Value: "bytes"}
+	tagUnitsPercent := cgm.Tag{Category: "units", Value: "percent"}
+
+	// HealthStatus values are 0 healthy, 1 warning, 2 unhealthy, 5 unknown
+
+	{
+		var dst []MSFT_StoragePool
+		qry := wmi.CreateQuery(dst, "WHERE IsPrimordial = FALSE")
+		if err := wmiQueryNamespace(qry, &dst, storageNamespace); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+
+		for _, item := range dst {
+			itemName := c.cleanName(item.FriendlyName)
+			if c.exclude.MatchString(itemName) || !c.include.MatchString(itemName) {
+				continue
+			}
+
+			poolTag := cgm.Tag{Category: "storage-pool", Value: itemName}
+			readOnly := 0
+			if item.IsReadOnly {
+				readOnly = 1
+			}
+
+			_ = c.addMetric(&metrics, "", "PoolHealthStatus", metricType, item.HealthStatus, cgm.Tags{poolTag})
+			_ = c.addMetric(&metrics, "", "PoolIsReadOnly", metricType, readOnly, cgm.Tags{poolTag})
+			_ = c.addMetric(&metrics, "", "PoolSize", "L", item.Size, cgm.Tags{poolTag, tagUnitsBytes})
+			_ = c.addMetric(&metrics, "", "PoolAllocatedSize", "L", item.AllocatedSize, cgm.Tags{poolTag, tagUnitsBytes})
+		}
+	}
+
+	{
+		var dst []MSFT_VirtualDisk
+		qry := wmi.CreateQuery(dst, "")
+		if err := wmiQueryNamespace(qry, &dst, storageNamespace); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+
+		for _, item := range dst {
+			itemName := c.cleanName(item.FriendlyName)
+			if c.exclude.MatchString(itemName) || !c.include.MatchString(itemName) {
+				continue
+			}
+
+			vdTags := cgm.Tags{
+				cgm.Tag{Category: "virtual-disk", Value: itemName},
+				cgm.Tag{Category: "resiliency", Value: strings.ToLower(item.ResiliencySettingName)},
+			}
+
+			_ = c.addMetric(&metrics, "", "VirtualDiskHealthStatus", metricType, item.HealthStatus, vdTags)
+			_ = c.addMetric(&metrics, "", "VirtualDiskPhysicalDiskRedundancy", metricType, item.PhysicalDiskRedundancy, vdTags)
+			_ = c.addMetric(&metrics, "", "VirtualDiskNumberOfDataCopies", metricType, item.NumberOfDataCopies, vdTags)
+			_ = c.addMetric(&metrics, "", "VirtualDiskSize", "L", item.Size, append(cgm.Tags{tagUnitsBytes}, vdTags...))
+			_ = c.addMetric(&metrics, "", "VirtualDiskFootprintOnPool", "L", item.FootprintOnPool, append(cgm.Tags{tagUnitsBytes}, vdTags...))
+		}
+	}
+
+	{
+		// repair/regeneration/rebalance jobs, only running jobs are reported
+		var dst []MSFT_StorageJob
+		qry := wmi.CreateQuery(dst, "")
+		if err := wmiQueryNamespace(qry, &dst, storageNamespace); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+
+		running := 0
+		for _, item := range dst {
+			if item.JobState != jobStateRunning {
+				continue
+			}
+			running++
+
+			jobTag := cgm.Tag{Category: "storage-job", Value: c.cleanName(item.Name)}
+
+			_ = c.addMetric(&metrics, "", "JobPercentComplete", metricType, item.PercentComplete, cgm.Tags{jobTag, tagUnitsPercent})
+			_ = c.addMetric(&metrics, "", "JobBytesProcessed", "L", item.BytesProcessed, cgm.Tags{jobTag, tagUnitsBytes})
+			_ = c.addMetric(&metrics, "", "JobBytesTotal", "L", item.BytesTotal, cgm.Tags{jobTag, tagUnitsBytes})
+		}
+		_ = c.addMetric(&metrics, "", "JobsRunning", metricType, running, cgm.Tags{})
+	}
+
+	{
+		var dst []MSFT_Volume
+		qry := wmi.CreateQuery(dst, "WHERE FileSystem = 'ReFS'")
+		if err := wmiQueryNamespace(qry, &dst, storageNamespace); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+
+		for _, item := range dst {
+			volTag := cgm.Tag{Category: "refs-volume", Value: c.cleanName(volumeName(item))}
+
+			_ = c.addMetric(&metrics, "", "ReFSVolumeHealthStatus", metricType, item.HealthStatus, cgm.Tags{volTag})
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// volumeName returns the drive letter, label or path identifying a volume
+func volumeName(v MSFT_Volume) string {
+	switch {
+	case v.DriveLetter != 0:
+		return string(rune(v.DriveLetter)) + ":"
+	case v.FileSystemLabel != "":
+		return v.FileSystemLabel
+	default:
+		return v.Path
+	}
+}
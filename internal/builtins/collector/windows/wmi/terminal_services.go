@@ -0,0 +1,241 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Win32_PerfFormattedData_LocalSessionManager_TerminalServices defines the metrics to collect
+type Win32_PerfFormattedData_LocalSessionManager_TerminalServices struct { //nolint: golint
+	ActiveSessions   uint32
+	InactiveSessions uint32
+	TotalSessions    uint32
+}
+
+// Win32_PerfFormattedData_RemoteDesktopConnectionBrokerPerformanceCounterProvider_RemoteDesktopConnectionBrokerCounters defines the metrics to collect
+type Win32_PerfFormattedData_RemoteDesktopConnectionBrokerPerformanceCounterProvider_RemoteDesktopConnectionBrokerCounters struct { //nolint: golint
+	Name                  string
+	FailedConnections     uint32
+	PendingConnections    uint32
+	SuccessfulConnections uint64
+}
+
+// Win32_LogonSession defines the logon session properties used to derive the logon rate
+type Win32_LogonSession struct { //nolint: golint
+	LogonId   string //nolint: golint
+	StartTime time.Time
+}
+
+// remoteInteractiveLogon is the Win32_LogonSession LogonType of remote desktop logons
+const remoteInteractiveLogon = 10
+
+// TerminalServices metrics from the Windows Management Interface (wmi)
+type TerminalServices struct {
+	wmicommon
+	brokerEnabled bool
+	logonsEnabled bool
+	lastLogonScan time.Time // start time of the previous logon session scan
+}
+
+// terminalServicesOptions defines what elements can be overridden in a config file
+type terminalServicesOptions struct {
+	ID                     string `json:"id" toml:"id" yaml:"id"`
+	MetricNameRegex        string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar         string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL                 string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	EnableConnectionBroker string `json:"enable_connection_broker" toml:"enable_connection_broker" yaml:"enable_connection_broker"`
+	EnableLogons           string `json:"enable_logons" toml:"enable_logons" yaml:"enable_logons"`
+}
+
+// NewTerminalServicesCollector creates new wmi collector
+func NewTerminalServicesCollector(cfgBaseName string) (collector.Collector, error) {
+	c := TerminalServices{}
+	c.id = "terminal_services"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	c.brokerEnabled = false
+	c.logonsEnabled = true
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg terminalServicesOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if cfg.EnableConnectionBroker != "" {
+		broker, err := strconv.ParseBool(cfg.EnableConnectionBroker)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing enable_connection_broker", c.pkgID)
+		}
+		c.brokerEnabled = broker
+	}
+
+	if cfg.EnableLogons != "" {
+		logons, err := strconv.ParseBool(cfg.EnableLogons)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing enable_logons", c.pkgID)
+		}
+		c.logonsEnabled = logons
+	}
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *TerminalServices) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	metricType := "I"
+	tagUnitsSessions := cgm.Tag{Category: "units", Value: "sessions"}
+	tagUnitsConnections := cgm.Tag{Category: "units", Value: "connections"}
+	tagUnitsLogons := cgm.Tag{Category: "units", Value: "logons"}
+
+	{
+		var dst []Win32_PerfFormattedData_LocalSessionManager_TerminalServices
+		qry := wmi.CreateQuery(dst, "")
+		if err := wmiQuery(qry, &dst); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+
+		for _, item := range dst {
+			_ = c.addMetric(&metrics, "", "ActiveSessions", metricType, item.ActiveSessions, cgm.Tags{tagUnitsSessions})
+			_ = c.addMetric(&metrics, "", "InactiveSessions", metricType, item.InactiveSessions, cgm.Tags{tagUnitsSessions})
+			_ = c.addMetric(&metrics, "", "TotalSessions", metricType, item.TotalSessions, cgm.Tags{tagUnitsSessions})
+		}
+	}
+
+	if c.logonsEnabled {
+		var dst []Win32_LogonSession
+		qry := wmi.CreateQuery(dst, "WHERE LogonType = "+strconv.Itoa(remoteInteractiveLogon))
+		scanStart := time.Now()
+		if err := wmiQuery(qry, &dst); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+
+		// the first scan only establishes the starting point for the rate
+		if !c.lastLogonScan.IsZero() {
+			logons := countLogonsSince(dst, c.lastLogonScan)
+			elapsed := scanStart.Sub(c.lastLogonScan).Seconds()
+			_ = c.addMetric(&metrics, "", "Logons", metricType, logons, cgm.Tags{tagUnitsLogons})
+			if elapsed > 0 {
+				_ = c.addMetric(&metrics, "", "LogonsPersec", "n", float64(logons)/elapsed, cgm.Tags{tagUnitsLogons})
+			}
+		}
+		c.lastLogonScan = scanStart
+	}
+
+	if c.brokerEnabled {
+		var dst []Win32_PerfFormattedData_RemoteDesktopConnectionBrokerPerformanceCounterProvider_RemoteDesktopConnectionBrokerCounters
+		qry := wmi.CreateQuery(dst, "")
+		if err := wmiQuery(qry, &dst); err != nil {
+			c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+			c.setStatus(metrics, err)
+			return errors.Wrap(err, c.pkgID)
+		}
+
+		for _, item := range dst {
+			brokerName := c.instanceName(item.Name)
+			if brokerName == "" {
+				brokerName = "default"
+			}
+			brokerTag := cgm.Tag{Category: "connection-broker", Value: brokerName}
+
+			_ = c.addMetric(&metrics, "", "FailedConnections", metricType, item.FailedConnections, cgm.Tags{brokerTag, tagUnitsConnections})
+			_ = c.addMetric(&metrics, "", "PendingConnections", metricType, item.PendingConnections, cgm.Tags{brokerTag, tagUnitsConnections})
+			_ = c.addMetric(&metrics, "", "SuccessfulConnections", "L", item.SuccessfulConnections, cgm.Tags{brokerTag, tagUnitsConnections})
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// countLogonsSince returns the number of logon sessions started after since
+func countLogonsSince(sessions []Win32_LogonSession, since time.Time) uint32 {
+	n := uint32(0)
+	for _, s := range sessions {
+		if s.StartTime.After(since) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,239 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewTerminalServicesCollector(t *testing.T) {
+	t.Log("Testing NewTerminalServicesCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("no config")
+	{
+		_, err := NewTerminalServicesCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (missing)")
+	{
+		_, err := NewTerminalServicesCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (bad syntax)")
+	{
+		_, err := NewTerminalServicesCollector(filepath.Join("testdata", "bad_syntax"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (config no settings)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_no_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c == nil {
+			t.Fatal("expected no nil")
+		}
+	}
+
+	t.Log("config (enable connection broker setting true)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_enable_connection_broker_true_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !c.(*TerminalServices).brokerEnabled {
+			t.Fatal("expected true")
+		}
+	}
+
+	t.Log("config (enable connection broker setting false)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_enable_connection_broker_false_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*TerminalServices).brokerEnabled {
+			t.Fatal("expected false")
+		}
+	}
+
+	t.Log("config (enable connection broker setting invalid)")
+	{
+		_, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_enable_connection_broker_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (enable logons setting true)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_enable_logons_true_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !c.(*TerminalServices).logonsEnabled {
+			t.Fatal("expected true")
+		}
+	}
+
+	t.Log("config (enable logons setting false)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_enable_logons_false_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*TerminalServices).logonsEnabled {
+			t.Fatal("expected false")
+		}
+	}
+
+	t.Log("config (enable logons setting invalid)")
+	{
+		_, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_enable_logons_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (id setting)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_id_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*TerminalServices).id != "foo" {
+			t.Fatalf("expected foo, got (%s)", c.ID())
+		}
+	}
+
+	t.Log("config (metric name regex)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_metric_name_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := `^foo`
+		if c.(*TerminalServices).metricNameRegex.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*TerminalServices).metricNameRegex.String())
+		}
+	}
+
+	t.Log("config (metric name regex invalid)")
+	{
+		_, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_metric_name_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (metric name char)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_metric_name_char_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*TerminalServices).metricNameChar != "-" {
+			t.Fatal("expected '-'")
+		}
+	}
+
+	t.Log("config (run ttl 5m)")
+	{
+		c, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*TerminalServices).runTTL != 5*time.Minute {
+			t.Fatal("expected 5m")
+		}
+	}
+
+	t.Log("config (run ttl invalid)")
+	{
+		_, err := NewTerminalServicesCollector(filepath.Join("testdata", "config_run_ttl_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestTerminalServicesFlush(t *testing.T) {
+	t.Log("Testing Flush")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewTerminalServicesCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected metrics")
+	}
+	if len(metrics) > 0 {
+		t.Fatalf("expected empty metrics, got %v", metrics)
+	}
+}
+
+func TestTerminalServicesCollect(t *testing.T) {
+	t.Log("Testing Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewTerminalServicesCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected error")
+	}
+	if len(metrics) == 0 {
+		t.Fatalf("expected metrics, got %v", metrics)
+	}
+}
+
+func TestCountLogonsSince(t *testing.T) {
+	t.Log("Testing countLogonsSince")
+
+	now := time.Now()
+	sessions := []Win32_LogonSession{
+		{LogonId: "1", StartTime: now.Add(-10 * time.Minute)},
+		{LogonId: "2", StartTime: now.Add(-30 * time.Second)},
+		{LogonId: "3", StartTime: now.Add(-5 * time.Second)},
+	}
+
+	if n := countLogonsSince(sessions, now.Add(-time.Minute)); n != 2 {
+		t.Fatalf("expected 2, got %d", n)
+	}
+	if n := countLogonsSince(sessions, now); n != 0 {
+		t.Fatalf("expected 0, got %d", n)
+	}
+}
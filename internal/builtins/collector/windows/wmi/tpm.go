@@ -0,0 +1,181 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Win32_Tpm defines the metrics to collect
+type Win32_Tpm struct { //nolint: golint
+	IsActivated_InitialValue bool //nolint: golint
+	IsEnabled_InitialValue   bool //nolint: golint
+	IsOwned_InitialValue     bool //nolint: golint
+	SpecVersion              string
+}
+
+// tpmNamespace is the wmi namespace of the Trusted Platform Module class
+const tpmNamespace = `root\CIMV2\Security\MicrosoftTpm`
+
+// TPM metrics from the Windows Management Interface (wmi)
+type TPM struct {
+	wmicommon
+}
+
+// tpmOptions defines what elements can be overridden in a config file
+type tpmOptions struct {
+	ID              string `json:"id" toml:"id" yaml:"id"`
+	MetricNameRegex string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar  string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL          string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+}
+
+// NewTPMCollector creates new wmi collector
+func NewTPMCollector(cfgBaseName string) (collector.Collector, error) {
+	c := TPM{}
+	c.id = "tpm"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg tpmOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *TPM) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	var dst []Win32_Tpm
+	qry := wmi.CreateQuery(dst, "")
+	if err := wmiQueryNamespace(qry, &dst, tpmNamespace); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "I"
+
+	// there is no instance when the system has no TPM (or it is disabled in firmware)
+	present := 0
+	if len(dst) > 0 {
+		present = 1
+	}
+	_ = c.addMetric(&metrics, "", "Present", metricType, present, cgm.Tags{})
+
+	for _, item := range dst {
+		states := []struct {
+			name  string
+			value bool
+		}{
+			{"Activated", item.IsActivated_InitialValue},
+			{"Enabled", item.IsEnabled_InitialValue},
+			{"Owned", item.IsOwned_InitialValue},
+		}
+		for _, s := range states {
+			v := 0
+			if s.value {
+				v = 1
+			}
+			_ = c.addMetric(&metrics, "", s.name, metricType, v, cgm.Tags{})
+		}
+
+		if major, ok := tpmSpecMajor(item.SpecVersion); ok {
+			_ = c.addMetric(&metrics, "", "SpecVersionMajor", metricType, major, cgm.Tags{})
+		}
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
+
+// tpmSpecMajor returns the major version of the TPM specification from
+// SpecVersion, e.g. "2.0, 0, 1.38" or "1.2, 2, 3"
+func tpmSpecMajor(specVersion string) (uint64, bool) {
+	v := strings.TrimSpace(specVersion)
+	if idx := strings.IndexAny(v, ".,"); idx != -1 {
+		v = v[:idx]
+	}
+	major, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return major, true
+}
@@ -0,0 +1,206 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Win32_PerfFormattedData_W3SVC_WebService defines the metrics to collect
+type Win32_PerfFormattedData_W3SVC_WebService struct { //nolint: golint
+	Name                      string
+	BytesReceivedPersec       uint64
+	BytesSentPersec           uint64
+	BytesTotalPersec          uint64
+	CurrentConnections        uint32
+	GetRequestsPersec         uint32
+	MaximumConnections        uint32
+	NotFoundErrorsPersec      uint32
+	PostRequestsPersec        uint32
+	ServiceUptime             uint32
+	TotalMethodRequestsPersec uint32
+}
+
+// WebService IIS web site metrics from the Windows Management Interface (wmi)
+type WebService struct {
+	wmicommon
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// webServiceOptions defines what elements can be overridden in a config file
+type webServiceOptions struct {
+	ID                string `json:"id" toml:"id" yaml:"id"`
+	IncludeRegex      string `json:"include_regex" toml:"include_regex" yaml:"include_regex"`
+	ExcludeRegex      string `json:"exclude_regex" toml:"exclude_regex" yaml:"exclude_regex"`
+	MetricNameRegex   string `json:"metric_name_regex" toml:"metric_name_regex" yaml:"metric_name_regex"`
+	MetricNameChar    string `json:"metric_name_char" toml:"metric_name_char" yaml:"metric_name_char"`
+	RunTTL            string `json:"run_ttl" toml:"run_ttl" yaml:"run_ttl"`
+	DiscoveryInterval string `json:"discovery_interval" toml:"discovery_interval" yaml:"discovery_interval"`
+}
+
+// NewWebServiceCollector creates new wmi collector
+func NewWebServiceCollector(cfgBaseName string) (collector.Collector, error) {
+	c := WebService{}
+	c.id = "web_service"
+	c.pkgID = pkgName + "." + c.id
+	c.logger = log.With().Str("pkg", pkgName).Str("id", c.id).Logger()
+	c.metricNameChar = defaultMetricChar
+	c.metricNameRegex = defaultMetricNameRegex
+	c.baseTags = tags.FromList(tags.GetBaseTags())
+
+	c.include = defaultIncludeRegex
+	c.exclude = defaultExcludeRegex
+
+	if cfgBaseName == "" {
+		return &c, nil
+	}
+
+	var cfg webServiceOptions
+	err := config.LoadConfigFile(cfgBaseName, &cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no config found matching") {
+			return &c, nil
+		}
+		c.logger.Debug().Err(err).Str("file", cfgBaseName).Msg("loading config file")
+		return nil, errors.Wrapf(err, "%s config", c.pkgID)
+	}
+
+	c.logger.Debug().Interface("config", cfg).Msg("loaded config")
+
+	// include regex
+	if cfg.IncludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.IncludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling include regex", c.pkgID)
+		}
+		c.include = rx
+	}
+
+	// exclude regex
+	if cfg.ExcludeRegex != "" {
+		rx, err := regexp.Compile(fmt.Sprintf(regexPat, cfg.ExcludeRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compiling exclude regex", c.pkgID)
+		}
+		c.exclude = rx
+	}
+
+	if cfg.ID != "" {
+		c.id = cfg.ID
+	}
+
+	if cfg.MetricNameRegex != "" {
+		rx, err := regexp.Compile(cfg.MetricNameRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s compile metric_name_regex", c.pkgID)
+		}
+		c.metricNameRegex = rx
+	}
+
+	if cfg.MetricNameChar != "" {
+		c.metricNameChar = cfg.MetricNameChar
+	}
+
+	if cfg.DiscoveryInterval != "" {
+		dur, err := time.ParseDuration(cfg.DiscoveryInterval)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing discovery_interval", c.pkgID)
+		}
+		c.wmicommon.discoveryInterval = dur
+	}
+
+	if cfg.RunTTL != "" {
+		dur, err := time.ParseDuration(cfg.RunTTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s parsing run_ttl", c.pkgID)
+		}
+		c.runTTL = dur
+	}
+
+	return &c, nil
+}
+
+// Collect metrics from the wmi resource
+func (c *WebService) Collect(ctx context.Context) error {
+	metrics := cgm.Metrics{}
+
+	c.Lock()
+
+	if c.runTTL > time.Duration(0) {
+		if time.Since(c.lastEnd) < c.runTTL {
+			c.logger.Warn().Msg(collector.ErrTTLNotExpired.Error())
+			c.Unlock()
+			return collector.ErrTTLNotExpired
+		}
+	}
+	if c.running {
+		c.logger.Warn().Msg(collector.ErrAlreadyRunning.Error())
+		c.Unlock()
+		return collector.ErrAlreadyRunning
+	}
+
+	c.running = true
+	c.lastStart = time.Now()
+	c.Unlock()
+
+	var dst []Win32_PerfFormattedData_W3SVC_WebService
+	qry := wmi.CreateQuery(dst, "")
+	if err := wmiQuery(qry, &dst); err != nil {
+		c.logger.Error().Err(err).Str("query", qry).Msg("wmi query error")
+		c.setStatus(metrics, err)
+		return errors.Wrap(err, c.pkgID)
+	}
+
+	metricType := "I"
+	tagUnitsBytes := cgm.Tag{Category: "units", Value: "bytes"}
+	tagUnitsConnections := cgm.Tag{Category: "units", Value: "connections"}
+	tagUnitsRequests := cgm.Tag{Category: "units", Value: "requests"}
+	tagUnitsSeconds := cgm.Tag{Category: "units", Value: "seconds"}
+	for _, item := range dst {
+		itemName := c.instanceName(item.Name)
+		if c.exclude.MatchString(itemName) || !c.include.MatchString(itemName) {
+			continue
+		}
+
+		metricSuffix := ""
+		if strings.Contains(item.Name, totalName) {
+			itemName = "all"
+			metricSuffix = totalName
+		}
+
+		c.seenInstance(itemName)
+		siteTag := cgm.Tag{Category: "site", Value: itemName}
+
+		_ = c.addMetric(&metrics, "", "BytesReceivedPersec"+metricSuffix, "L", item.BytesReceivedPersec, cgm.Tags{siteTag, tagUnitsBytes})
+		_ = c.addMetric(&metrics, "", "BytesSentPersec"+metricSuffix, "L", item.BytesSentPersec, cgm.Tags{siteTag, tagUnitsBytes})
+		_ = c.addMetric(&metrics, "", "BytesTotalPersec"+metricSuffix, "L", item.BytesTotalPersec, cgm.Tags{siteTag, tagUnitsBytes})
+		_ = c.addMetric(&metrics, "", "CurrentConnections"+metricSuffix, metricType, item.CurrentConnections, cgm.Tags{siteTag, tagUnitsConnections})
+		_ = c.addMetric(&metrics, "", "GetRequestsPersec"+metricSuffix, metricType, item.GetRequestsPersec, cgm.Tags{siteTag, tagUnitsRequests})
+		_ = c.addMetric(&metrics, "", "MaximumConnections"+metricSuffix, metricType, item.MaximumConnections, cgm.Tags{siteTag, tagUnitsConnections})
+		_ = c.addMetric(&metrics, "", "NotFoundErrorsPersec"+metricSuffix, metricType, item.NotFoundErrorsPersec, cgm.Tags{siteTag, tagUnitsRequests})
+		_ = c.addMetric(&metrics, "", "PostRequestsPersec"+metricSuffix, metricType, item.PostRequestsPersec, cgm.Tags{siteTag, tagUnitsRequests})
+		_ = c.addMetric(&metrics, "", "ServiceUptime"+metricSuffix, metricType, item.ServiceUptime, cgm.Tags{siteTag, tagUnitsSeconds})
+		_ = c.addMetric(&metrics, "", "TotalMethodRequestsPersec"+metricSuffix, metricType, item.TotalMethodRequestsPersec, cgm.Tags{siteTag, tagUnitsRequests})
+	}
+
+	c.setStatus(metrics, nil)
+	return nil
+}
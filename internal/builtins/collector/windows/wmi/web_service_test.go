@@ -0,0 +1,255 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestNewWebServiceCollector(t *testing.T) {
+	t.Log("Testing NewWebServiceCollector")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("no config")
+	{
+		_, err := NewWebServiceCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (missing)")
+	{
+		_, err := NewWebServiceCollector(filepath.Join("testdata", "missing"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("config (bad syntax)")
+	{
+		_, err := NewWebServiceCollector(filepath.Join("testdata", "bad_syntax"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (config no settings)")
+	{
+		c, err := NewWebServiceCollector(filepath.Join("testdata", "config_no_settings"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c == nil {
+			t.Fatal("expected no nil")
+		}
+	}
+
+	t.Log("config (include regex)")
+	{
+		c, err := NewWebServiceCollector(filepath.Join("testdata", "config_include_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := fmt.Sprintf(regexPat, `^foo`)
+		if c.(*WebService).include.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*WebService).include.String())
+		}
+	}
+
+	t.Log("config (include regex invalid)")
+	{
+		_, err := NewWebServiceCollector(filepath.Join("testdata", "config_include_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (exclude regex)")
+	{
+		c, err := NewWebServiceCollector(filepath.Join("testdata", "config_exclude_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := fmt.Sprintf(regexPat, `^foo`)
+		if c.(*WebService).exclude.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*WebService).exclude.String())
+		}
+	}
+
+	t.Log("config (exclude regex invalid)")
+	{
+		_, err := NewWebServiceCollector(filepath.Join("testdata", "config_exclude_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (id setting)")
+	{
+		c, err := NewWebServiceCollector(filepath.Join("testdata", "config_id_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*WebService).id != "foo" {
+			t.Fatalf("expected foo, got (%s)", c.ID())
+		}
+	}
+
+	t.Log("config (metric name regex)")
+	{
+		c, err := NewWebServiceCollector(filepath.Join("testdata", "config_metric_name_regex_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := `^foo`
+		if c.(*WebService).metricNameRegex.String() != expect {
+			t.Fatalf("expected (%s) got (%s)", expect, c.(*WebService).metricNameRegex.String())
+		}
+	}
+
+	t.Log("config (metric name regex invalid)")
+	{
+		_, err := NewWebServiceCollector(filepath.Join("testdata", "config_metric_name_regex_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("config (metric name char)")
+	{
+		c, err := NewWebServiceCollector(filepath.Join("testdata", "config_metric_name_char_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*WebService).metricNameChar != "-" {
+			t.Fatal("expected '-'")
+		}
+	}
+
+	t.Log("config (run ttl 5m)")
+	{
+		c, err := NewWebServiceCollector(filepath.Join("testdata", "config_run_ttl_valid_setting"))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.(*WebService).runTTL != 5*time.Minute {
+			t.Fatal("expected 5m")
+		}
+	}
+
+	t.Log("config (run ttl invalid)")
+	{
+		_, err := NewWebServiceCollector(filepath.Join("testdata", "config_run_ttl_invalid_setting"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestWebServiceFlush(t *testing.T) {
+	t.Log("Testing Flush")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewWebServiceCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected metrics")
+	}
+	if len(metrics) > 0 {
+		t.Fatalf("expected empty metrics, got %v", metrics)
+	}
+}
+
+func TestWebServiceCollect(t *testing.T) {
+	t.Log("Testing Collect")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	c, err := NewWebServiceCollector("")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	if err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	metrics := c.Flush()
+	if metrics == nil {
+		t.Fatal("expected error")
+	}
+	if len(metrics) == 0 {
+		t.Fatalf("expected metrics, got %v", metrics)
+	}
+}
+
+func TestWebServiceCollectRecorded(t *testing.T) {
+	t.Log("Testing Collect (recorded wmi)")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	replay, err := testutil.WMIResults(map[string]interface{}{
+		"Win32_PerfFormattedData_W3SVC_WebService": []Win32_PerfFormattedData_W3SVC_WebService{
+			{Name: "_Total", CurrentConnections: 12, TotalMethodRequestsPersec: 150, BytesSentPersec: 204800, BytesReceivedPersec: 10240},
+			{Name: "Default Web Site", CurrentConnections: 10, TotalMethodRequestsPersec: 120, BytesSentPersec: 163840, BytesReceivedPersec: 8192},
+			{Name: "intranet", CurrentConnections: 2, TotalMethodRequestsPersec: 30, BytesSentPersec: 40960, BytesReceivedPersec: 2048},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	wmiQuery = replay.Query
+	defer func() { wmiQuery = wmi.Query }()
+
+	t.Log("\tall sites")
+	{
+		c, err := NewWebServiceCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "CurrentConnections", "I", 10, "site:Default_Web_Site")
+		testutil.AssertMetric(t, metrics, "TotalMethodRequestsPersec", "I", 120, "site:Default_Web_Site")
+		testutil.AssertMetric(t, metrics, "BytesSentPersec", "L", 163840, "site:Default_Web_Site", "units:bytes")
+		testutil.AssertMetric(t, metrics, "BytesReceivedPersec", "L", 2048, "site:intranet")
+		testutil.AssertMetric(t, metrics, "CurrentConnections"+totalName, "I", 12, "site:all")
+	}
+
+	t.Log("\tinclude/exclude")
+	{
+		c, err := NewWebServiceCollector("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		c.(*WebService).exclude = regexp.MustCompile(fmt.Sprintf(regexPat, `intranet|_Total`))
+
+		metrics := testutil.Collect(t, c)
+
+		testutil.AssertMetric(t, metrics, "CurrentConnections", "I", 10, "site:Default_Web_Site")
+		testutil.AssertNoMetric(t, metrics, "CurrentConnections", "site:intranet")
+		testutil.AssertNoMetric(t, metrics, "CurrentConnections"+totalName, "site:all")
+	}
+}
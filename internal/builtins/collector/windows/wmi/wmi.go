@@ -29,19 +29,22 @@ import (
 
 // wmicommon defines WMI metrics common elements
 type wmicommon struct {
-	id              string         // id of the collector (used as metric name prefix)
-	pkgID           string         // package prefix used for logging and errors
-	lastEnd         time.Time      // last collection end time
-	lastError       string         // last collection error
-	lastMetrics     cgm.Metrics    // last metrics collected
-	lastRunDuration time.Duration  // last collection duration
-	lastStart       time.Time      // last collection start time
-	logger          zerolog.Logger // collector logging instance
-	metricNameChar  string         // OPT character(s) used as replacement for metricNameRegex, may be overridden in config
-	metricNameRegex *regexp.Regexp // OPT regex for cleaning names, may be overridden in config
-	running         bool           // is collector currently running
-	runTTL          time.Duration  // OPT ttl for collections, may be overridden in config file (default is for every request)
-	baseTags        tags.Tags
+	id                string         // id of the collector (used as metric name prefix)
+	pkgID             string         // package prefix used for logging and errors
+	lastEnd           time.Time      // last collection end time
+	lastError         string         // last collection error
+	lastMetrics       cgm.Metrics    // last metrics collected
+	lastRunDuration   time.Duration  // last collection duration
+	lastStart         time.Time      // last collection start time
+	logger            zerolog.Logger // collector logging instance
+	metricNameChar    string         // OPT character(s) used as replacement for metricNameRegex, may be overridden in config
+	metricNameRegex   *regexp.Regexp // OPT regex for cleaning names, may be overridden in config
+	running           bool           // is collector currently running
+	runTTL            time.Duration  // OPT ttl for collections, may be overridden in config file (default is for every request)
+	baseTags          tags.Tags
+	discoveryInterval time.Duration   // OPT how often the instances seen are emitted, may be overridden in config file (default disabled)
+	lastDiscovery     time.Time       // last discovery metric emitted
+	instances         map[string]bool // instances seen in the current collection
 	sync.Mutex
 }
 
@@ -60,6 +63,13 @@ var (
 	defaultMetricNameRegex = regexp.MustCompile(`[^a-zA-Z0-9.-_:` + metricNameSeparator + `]`)
 )
 
+// wmi queries (reflect decoding) run by the collectors, tests replace them to
+// replay recorded result sets (see collector/testutil WMIReplay)
+var (
+	wmiQuery          = wmi.Query
+	wmiQueryNamespace = wmi.QueryNamespace
+)
+
 func initialize() error {
 	// This initialization prevents a memory leak on WMF 5+. See
 	// https://github.com/martinlindhe/wmi_exporter/issues/77 and
@@ -92,6 +102,15 @@ func New() ([]collector.Collector, error) {
 		return none, nil
 	}
 
+	if err := loadInstanceNames(path.Join(defaults.EtcPath, "wmi_instance_names")); err != nil {
+		l.Warn().Err(err).Msg("ignoring instance name normalization")
+	}
+
+	mode := detectContainerMode(viper.GetBool(config.KeyWMIHostProcess), l)
+	if mode != modeHost {
+		l.Info().Str("mode", mode.String()).Msg("running in windows container")
+	}
+
 	logError := func(name string, err error) {
 		l.Error().
 			Str("name", name).
@@ -106,7 +125,30 @@ func New() ([]collector.Collector, error) {
 		}
 		name = strings.Replace(name, wmiPrefix, "", -1)
 		cfgBase := "wmi_" + name + "_collector"
+		if !availableInMode(name, mode) {
+			l.Info().
+				Str("name", name).
+				Str("mode", mode.String()).
+				Msg("wmi classes not available in container, skipping builtin collector")
+			continue
+		}
 		switch name {
+		case "battery":
+			c, err := NewBatteryCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case "bitlocker":
+			c, err := NewBitLockerCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
 		case "cache":
 			c, err := NewCacheCollector(path.Join(defaults.EtcPath, cfgBase))
 			if err != nil {
@@ -115,6 +157,14 @@ func New() ([]collector.Collector, error) {
 			}
 			collectors = append(collectors, c)
 
+		case "defender":
+			c, err := NewDefenderCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
 		case "disk":
 			c, err := NewDiskCollector(path.Join(defaults.EtcPath, cfgBase))
 			if err != nil {
@@ -123,6 +173,14 @@ func New() ([]collector.Collector, error) {
 			}
 			collectors = append(collectors, c)
 
+		case "fc":
+			c, err := NewFCCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
 		case "memory":
 			c, err := NewMemoryCollector(path.Join(defaults.EtcPath, cfgBase))
 			if err != nil {
@@ -131,6 +189,22 @@ func New() ([]collector.Collector, error) {
 			}
 			collectors = append(collectors, c)
 
+		case "mpio":
+			c, err := NewMPIOCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case "mssql":
+			c, err := NewMSSQLCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
 		case "interface":
 			c, err := NewNetInterfaceCollector(path.Join(defaults.EtcPath, cfgBase))
 			if err != nil {
@@ -179,6 +253,14 @@ func New() ([]collector.Collector, error) {
 			}
 			collectors = append(collectors, c)
 
+		case "print_queue":
+			c, err := NewPrintQueueCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
 		case "processes":
 			c, err := NewProcessesCollector(path.Join(defaults.EtcPath, cfgBase))
 			if err != nil {
@@ -195,6 +277,54 @@ func New() ([]collector.Collector, error) {
 			}
 			collectors = append(collectors, c)
 
+		case "services":
+			c, err := NewServicesCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case "smart":
+			c, err := NewSmartCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case "storage_spaces":
+			c, err := NewStorageSpacesCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case "terminal_services":
+			c, err := NewTerminalServicesCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case "tpm":
+			c, err := NewTPMCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
+		case "web_service":
+			c, err := NewWebServiceCollector(path.Join(defaults.EtcPath, cfgBase))
+			if err != nil {
+				logError(name, err)
+				continue
+			}
+			collectors = append(collectors, c)
+
 		default:
 			l.Warn().
 				Str("name", name).
@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 //
 
-// +build !windows,!linux
+// +build !windows,!linux,!freebsd,!solaris,!darwin,!aix
 
 package builtins
 
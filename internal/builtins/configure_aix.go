@@ -0,0 +1,57 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build aix
+
+package builtins
+
+import (
+	"context"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/aix"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/generic"
+	appstats "github.com/maier/go-appstats"
+	"github.com/rs/zerolog/log"
+)
+
+func (b *Builtins) configure(ctx context.Context) error {
+	l := log.With().Str("pkg", "builtins").Logger()
+
+	{
+		// AIX (perfstat)
+		// NOTE: these take precedence over generic collectors with the same id
+		l.Debug().Msg("calling aix.New")
+		collectors, err := aix.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled aix builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// PSUtils
+		// NOTE: any duplicates created will be ignored e.g. if aix.cpu and
+		//       generic.cpu are both enabled, aix.cpu will take precedence
+		//       and the generic.cpu instance will be dropped.
+		l.Debug().Msg("calling generic.New")
+		collectors, err := generic.New()
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			if _, exists := b.collectors[c.ID()]; !exists {
+				b.logger.Info().Str("id", c.ID()).Msg("enabled generic builtin")
+				b.collectors[c.ID()] = c
+				_ = appstats.IncrementInt("builtins.total")
+			}
+		}
+	}
+
+	return nil
+}
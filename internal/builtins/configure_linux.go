@@ -11,7 +11,15 @@ import (
 	"context"
 
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/generic"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/bgp"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/edge"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/fabric"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/firewall"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/fs"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/mm"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/procfs"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/security"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/linux/smart"
 	appstats "github.com/maier/go-appstats"
 	"github.com/rs/zerolog/log"
 )
@@ -36,6 +44,126 @@ func (b *Builtins) configure(ctx context.Context) error {
 		}
 	}
 
+	{
+		// Edge (Raspberry Pi firmware, cpufreq throttling)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling edge.New")
+		collectors, err := edge.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled edge builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// BGP (FRR, bird routing daemons)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling bgp.New")
+		collectors, err := bgp.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled bgp builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// Firewall (iptables, nftables rule counters)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling firewall.New")
+		collectors, err := firewall.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled firewall builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// Security (LUKS, TPM, SELinux/AppArmor denials)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling security.New")
+		collectors, err := security.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled security builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// MM (huge pages, kernel samepage merging, swap, zram)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling mm.New")
+		collectors, err := mm.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled mm builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// Fabric (InfiniBand/RDMA ports, Fibre Channel HBAs, multipath)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling fabric.New")
+		collectors, err := fabric.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled fabric builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// SMART (drive health from smartctl, nvme-cli)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling smart.New")
+		collectors, err := smart.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled smart builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// FS (user, group and project quotas)
+		// NOTE: optional, not enabled by default
+		l.Debug().Msg("calling fs.New")
+		collectors, err := fs.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled fs builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
 	{
 		// PSUtils
 		// NOTE: psutils does not use the same metric names nor does it expose
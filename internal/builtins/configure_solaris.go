@@ -0,0 +1,57 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build solaris
+
+package builtins
+
+import (
+	"context"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/generic"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/illumos"
+	appstats "github.com/maier/go-appstats"
+	"github.com/rs/zerolog/log"
+)
+
+func (b *Builtins) configure(ctx context.Context) error {
+	l := log.With().Str("pkg", "builtins").Logger()
+
+	{
+		// illumos/Solaris (kstat)
+		// NOTE: these take precedence over generic collectors with the same id
+		l.Debug().Msg("calling illumos.New")
+		collectors, err := illumos.New(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled illumos builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// PSUtils
+		// NOTE: any duplicates created will be ignored e.g. if illumos.cpu and
+		//       generic.cpu are both enabled, illumos.cpu will take precedence
+		//       and the generic.cpu instance will be dropped.
+		l.Debug().Msg("calling generic.New")
+		collectors, err := generic.New()
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			if _, exists := b.collectors[c.ID()]; !exists {
+				b.logger.Info().Str("id", c.ID()).Msg("enabled generic builtin")
+				b.collectors[c.ID()] = c
+				_ = appstats.IncrementInt("builtins.total")
+			}
+		}
+	}
+
+	return nil
+}
@@ -11,6 +11,8 @@ import (
 	"context"
 
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/generic"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/bits"
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/dhcp"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/nvidia"
 	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/windows/wmi"
 	appstats "github.com/maier/go-appstats"
@@ -52,6 +54,34 @@ func (b *Builtins) configure(ctx context.Context) error {
 		}
 	}
 
+	{
+		// DHCP server collector(s)
+		l.Debug().Msg("calling dhcp.New")
+		collectors, err := dhcp.New()
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled dhcp builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
+	{
+		// BITS collector(s)
+		l.Debug().Msg("calling bits.New")
+		collectors, err := bits.New()
+		if err != nil {
+			return err
+		}
+		for _, c := range collectors {
+			b.logger.Info().Str("id", c.ID()).Msg("enabled bits builtin")
+			b.collectors[c.ID()] = c
+			_ = appstats.IncrementInt("builtins.total")
+		}
+	}
+
 	{
 		// PSUtils
 		// NOTE: enable any explicit generic builtins - wmi will take precdence if
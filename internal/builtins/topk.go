@@ -0,0 +1,103 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package builtins
+
+import (
+	"sort"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// topKOther is the instance the metrics of the instances outside the top K are summed into
+const topKOther = "other"
+
+// instanceMetric a metric with a stream tag of the top K category
+type instanceMetric struct {
+	instance string
+	base     string
+	tags     tags.Tags // other than the instance tag
+	metric   cgm.Metric
+}
+
+// topK passes through the metrics of the K instances (metrics with a stream
+// tag of the selection category) with the highest values of the selection
+// metric, the numeric metrics of the remaining instances are summed into a
+// metric tagged <category>:other. Metrics without the tag are passed through.
+func topK(metrics cgm.Metrics, sel config.MetricTopK) cgm.Metrics {
+	out := make(cgm.Metrics, len(metrics))
+	instMetrics := make(map[string]instanceMetric)
+	scores := make(map[string]float64)
+
+	for name, m := range metrics {
+		base, tagList, ok := tags.SplitMetricStreamTags(name)
+		if !ok {
+			out[name] = m
+			continue
+		}
+		im := instanceMetric{base: base, metric: m}
+		found := false
+		for _, t := range tagList {
+			if t.Category == sel.Category {
+				im.instance = t.Value
+				found = true
+				continue
+			}
+			im.tags = append(im.tags, t)
+		}
+		if !found {
+			out[name] = m
+			continue
+		}
+		instMetrics[name] = im
+		if _, ok := scores[im.instance]; !ok {
+			scores[im.instance] = 0
+		}
+		if base == sel.Metric {
+			if v, ok := sample.Float64(m.Value); ok {
+				scores[im.instance] += v
+			}
+		}
+	}
+
+	// highest values first, ties by instance for a stable selection
+	instances := make([]string, 0, len(scores))
+	for inst := range scores {
+		instances = append(instances, inst)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		si, sj := scores[instances[i]], scores[instances[j]]
+		if si != sj {
+			return si > sj
+		}
+		return instances[i] < instances[j]
+	})
+	top := make(map[string]bool, sel.K)
+	for i := 0; i < len(instances) && i < sel.K; i++ {
+		top[instances[i]] = true
+	}
+
+	other := make(map[string]float64)
+	for name, im := range instMetrics {
+		if top[im.instance] {
+			out[name] = im.metric
+			continue
+		}
+		v, ok := sample.Float64(im.metric.Value)
+		if !ok {
+			continue // text and histograms of the other instances are dropped
+		}
+		tagList := append(append(tags.Tags{}, im.tags...), tags.Tag{Category: sel.Category, Value: topKOther})
+		other[tags.MetricNameWithStreamTags(im.base, tagList)] += v
+	}
+	for name, v := range other {
+		out[name] = cgm.Metric{Type: "n", Value: v}
+	}
+
+	return out
+}
@@ -0,0 +1,85 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package builtins
+
+import (
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestTopK(t *testing.T) {
+	t.Log("Testing topK")
+
+	name := func(metric string, tagList ...tags.Tag) string {
+		return tags.MetricNameWithStreamTags(metric, tags.Tags(tagList))
+	}
+	proc := func(id string) tags.Tag { return tags.Tag{Category: "process-name", Value: id} }
+	units := tags.Tag{Category: "units", Value: "bytes"}
+
+	metrics := cgm.Metrics{
+		name("cpu", proc("a")):                           cgm.Metric{Type: "n", Value: 50.0},
+		name("cpu", proc("b")):                           cgm.Metric{Type: "n", Value: 5.0},
+		name("cpu", proc("c")):                           cgm.Metric{Type: "n", Value: 30.0},
+		name("cpu", proc("d")):                           cgm.Metric{Type: "n", Value: 1.0},
+		name("mem", proc("a"), units):                    cgm.Metric{Type: "L", Value: uint64(100)},
+		name("mem", proc("b"), units):                    cgm.Metric{Type: "L", Value: uint64(200)},
+		name("mem", proc("c"), units):                    cgm.Metric{Type: "L", Value: uint64(300)},
+		name("mem", proc("d"), units):                    cgm.Metric{Type: "L", Value: uint64(400)},
+		name("cmdline", proc("d")):                       cgm.Metric{Type: "s", Value: "/usr/bin/d"},
+		name("mem", tags.Tag{Category: "x", Value: "y"}): cgm.Metric{Type: "L", Value: uint64(1)},
+		"processes":                                      cgm.Metric{Type: "L", Value: uint64(4)},
+	}
+
+	t.Log("\ttop 2 by cpu")
+	{
+		out := topK(metrics, config.MetricTopK{Category: "process-name", K: 2, Metric: "cpu"})
+
+		for _, mn := range []string{
+			name("cpu", proc("a")), name("cpu", proc("c")),
+			name("mem", proc("a"), units), name("mem", proc("c"), units),
+			name("mem", tags.Tag{Category: "x", Value: "y"}), "processes",
+		} {
+			if _, ok := out[mn]; !ok {
+				t.Fatalf("expected %s to be passed through (%v)", mn, out)
+			}
+		}
+		if m := out[name("cpu", proc("other"))]; m.Type != "n" || m.Value != 6.0 {
+			t.Fatalf("expected other cpu 6, got %#v", m)
+		}
+		if m := out[name("mem", proc("other"), units)]; m.Value != 600.0 {
+			t.Fatalf("expected other mem 600, got %#v", m)
+		}
+		if _, ok := out[name("cmdline", proc("d"))]; ok {
+			t.Fatal("expected other text metric to be dropped")
+		}
+		if len(out) != 8 {
+			t.Fatalf("expected 8 metrics, got %d (%v)", len(out), out)
+		}
+	}
+
+	t.Log("\tfewer instances than k")
+	{
+		out := topK(metrics, config.MetricTopK{Category: "process-name", K: 10, Metric: "cpu"})
+		if len(out) != len(metrics) {
+			t.Fatalf("expected all %d metrics, got %d", len(metrics), len(out))
+		}
+	}
+
+	t.Log("\tmetric without values")
+	{
+		// all instances rank 0, ties are by instance
+		out := topK(metrics, config.MetricTopK{Category: "process-name", K: 1, Metric: "missing"})
+		if _, ok := out[name("cpu", proc("a"))]; !ok {
+			t.Fatal("expected instance a to be selected")
+		}
+		if m := out[name("cpu", proc("other"))]; m.Value != 36.0 {
+			t.Fatalf("expected other cpu 36, got %#v", m)
+		}
+	}
+}
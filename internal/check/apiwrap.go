@@ -0,0 +1,339 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package check
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/errs"
+	"github.com/circonus-labs/go-apiclient"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// resilientAPI wraps the circonus api client adding retries with exponential
+// backoff, rate limit handling and an on-disk cache of fetched check, check
+// bundle and broker configurations. The cache is used when the api cannot be
+// reached so that the agent can start with the last known configuration.
+type resilientAPI struct {
+	client     API
+	maxRetries int
+	minWait    time.Duration
+	maxWait    time.Duration
+	cacheDir   string
+	logger     zerolog.Logger
+}
+
+const (
+	apiMinRetryWait = 2 * time.Second
+	apiMaxRetryWait = 60 * time.Second
+)
+
+var (
+	cacheKeyRx      = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	permanentErrRx  = regexp.MustCompile(`code (400|401|403|404|409)\b`)
+	rateLimitErrRx  = regexp.MustCompile(`(code |response: )429\b`)
+	errNotCacheable = errors.New("not cacheable")
+)
+
+func newResilientAPI(client API, maxRetries int, cacheDir string, logger zerolog.Logger) *resilientAPI {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &resilientAPI{
+		client:     client,
+		maxRetries: maxRetries,
+		minWait:    apiMinRetryWait,
+		maxWait:    apiMaxRetryWait,
+		cacheDir:   cacheDir,
+		logger:     logger.With().Str("pkg", "check.api").Logger(),
+	}
+}
+
+// isPermanentAPIError identifies errors which will not succeed on retry
+func isPermanentAPIError(err error) bool {
+	return err != nil && permanentErrRx.MatchString(err.Error())
+}
+
+// isRateLimitAPIError identifies rate limit (429) errors
+func isRateLimitAPIError(err error) bool {
+	return err != nil && rateLimitErrRx.MatchString(err.Error())
+}
+
+// backoff returns the wait before the next attempt, rate limited requests wait the max
+func (a *resilientAPI) backoff(attempt int, err error) time.Duration {
+	if isRateLimitAPIError(err) {
+		return a.maxWait
+	}
+	wait := a.minWait << uint(attempt)
+	if wait <= 0 || wait > a.maxWait {
+		wait = a.maxWait
+	}
+	// jitter, 50%-100% of wait
+	half := int64(wait / 2)
+	if half > 0 {
+		wait = time.Duration(half + rand.Int63n(half+1)) //nolint:gosec
+	}
+	return wait
+}
+
+// retry calls fn until it succeeds, returns a permanent error, or retries are
+// exhausted. When idempotent is false (e.g. creating a check bundle), only
+// rate limited requests are retried. Permanent api errors are returned as
+// config errors, all others as (retryable) transient network errors.
+func (a *resilientAPI) retry(op string, idempotent bool, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if isPermanentAPIError(err) {
+			return errs.NewConfig(err)
+		}
+		if attempt >= a.maxRetries || (!idempotent && !isRateLimitAPIError(err)) {
+			return errs.NewNetwork(err)
+		}
+		wait := a.backoff(attempt, err)
+		a.logger.Warn().Err(err).Str("op", op).Int("attempt", attempt+1).Str("wait", wait.String()).Msg("api call failed, retrying")
+		time.Sleep(wait)
+	}
+}
+
+// cacheFile returns the cache file for a key, or an empty string if caching is disabled
+func (a *resilientAPI) cacheFile(key string) string {
+	if a.cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(a.cacheDir, cacheKeyRx.ReplaceAllString(strings.Trim(key, "/"), "_")+".json")
+}
+
+// saveCache writes an api result to the cache, errors are logged and ignored
+func (a *resilientAPI) saveCache(key string, v interface{}) {
+	file := a.cacheFile(key)
+	if file == "" {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		a.logger.Warn().Err(err).Str("key", key).Msg("encoding api result for cache")
+		return
+	}
+	if err := os.MkdirAll(a.cacheDir, 0700); err != nil {
+		a.logger.Warn().Err(err).Str("dir", a.cacheDir).Msg("creating api cache dir")
+		return
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		a.logger.Warn().Err(err).Str("file", tmp).Msg("writing api cache")
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		a.logger.Warn().Err(err).Str("file", file).Msg("saving api cache")
+	}
+}
+
+// loadCache reads a cached api result, used when the api is unavailable
+func (a *resilientAPI) loadCache(key string, v interface{}) error {
+	file := a.cacheFile(key)
+	if file == "" {
+		return errNotCacheable
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrap(err, "reading api cache")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.Wrap(err, "parsing api cache")
+	}
+	return nil
+}
+
+// fetch runs an idempotent api call with retries, caching successful results
+// and falling back to the last cached result if the call ultimately fails
+func (a *resilientAPI) fetch(op, key string, result interface{}, fn func() error) error {
+	err := a.retry(op, true, fn)
+	if err == nil {
+		a.saveCache(key, result)
+		return nil
+	}
+	if !errs.IsRetryable(err) {
+		return err
+	}
+	if cerr := a.loadCache(key, result); cerr != nil {
+		if cerr != errNotCacheable {
+			a.logger.Debug().Err(cerr).Str("key", key).Msg("no usable cached api result")
+		}
+		return err
+	}
+	a.logger.Warn().Err(err).Str("op", op).Str("key", key).Msg("api unavailable, using last known (cached) result")
+	return nil
+}
+
+func cidKey(prefix string, cid apiclient.CIDType) string {
+	if cid == nil {
+		return prefix
+	}
+	return prefix + "_" + path.Base(*cid)
+}
+
+func hashKey(prefix string, parts ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return prefix + "_" + hex.EncodeToString(h[:8])
+}
+
+func (a *resilientAPI) CreateCheckBundle(cfg *apiclient.CheckBundle) (*apiclient.CheckBundle, error) {
+	var bundle *apiclient.CheckBundle
+	err := a.retry("CreateCheckBundle", false, func() error {
+		var err error
+		bundle, err = a.client.CreateCheckBundle(cfg)
+		return err
+	})
+	return bundle, err
+}
+
+func (a *resilientAPI) FetchBroker(cid apiclient.CIDType) (*apiclient.Broker, error) {
+	broker := &apiclient.Broker{}
+	err := a.fetch("FetchBroker", cidKey("broker", cid), broker, func() error {
+		b, err := a.client.FetchBroker(cid)
+		if err == nil && b != nil {
+			*broker = *b
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return broker, nil
+}
+
+func (a *resilientAPI) FetchBrokers() (*[]apiclient.Broker, error) {
+	brokers := &[]apiclient.Broker{}
+	err := a.fetch("FetchBrokers", "brokers", brokers, func() error {
+		b, err := a.client.FetchBrokers()
+		if err == nil && b != nil {
+			*brokers = *b
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return brokers, nil
+}
+
+func (a *resilientAPI) FetchCheck(cid apiclient.CIDType) (*apiclient.Check, error) {
+	check := &apiclient.Check{}
+	err := a.fetch("FetchCheck", cidKey("check", cid), check, func() error {
+		c, err := a.client.FetchCheck(cid)
+		if err == nil && c != nil {
+			*check = *c
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (a *resilientAPI) FetchCheckBundle(cid apiclient.CIDType) (*apiclient.CheckBundle, error) {
+	bundle := &apiclient.CheckBundle{}
+	err := a.fetch("FetchCheckBundle", cidKey("check_bundle", cid), bundle, func() error {
+		b, err := a.client.FetchCheckBundle(cid)
+		if err == nil && b != nil {
+			*bundle = *b
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+func (a *resilientAPI) FetchCheckBundleMetrics(cid apiclient.CIDType) (*apiclient.CheckBundleMetrics, error) {
+	metrics := &apiclient.CheckBundleMetrics{}
+	err := a.fetch("FetchCheckBundleMetrics", cidKey("check_bundle_metrics", cid), metrics, func() error {
+		m, err := a.client.FetchCheckBundleMetrics(cid)
+		if err == nil && m != nil {
+			*metrics = *m
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func (a *resilientAPI) Get(url string) ([]byte, error) {
+	var data []byte
+	err := a.fetch("Get", hashKey("get", url), &data, func() error {
+		var err error
+		data, err = a.client.Get(url)
+		return err
+	})
+	return data, err
+}
+
+func (a *resilientAPI) SearchCheckBundles(searchCriteria *apiclient.SearchQueryType, filterCriteria *apiclient.SearchFilterType) (*[]apiclient.CheckBundle, error) {
+	keyParts := []string{}
+	if searchCriteria != nil {
+		keyParts = append(keyParts, string(*searchCriteria))
+	}
+	if filterCriteria != nil {
+		filters := make([]string, 0, len(*filterCriteria))
+		for k, v := range *filterCriteria {
+			filters = append(filters, k+"="+strings.Join(v, ","))
+		}
+		sort.Strings(filters)
+		keyParts = append(keyParts, filters...)
+	}
+	bundles := &[]apiclient.CheckBundle{}
+	err := a.fetch("SearchCheckBundles", hashKey("search_check_bundles", keyParts...), bundles, func() error {
+		b, err := a.client.SearchCheckBundles(searchCriteria, filterCriteria)
+		if err == nil && b != nil {
+			*bundles = *b
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+func (a *resilientAPI) UpdateCheckBundle(cfg *apiclient.CheckBundle) (*apiclient.CheckBundle, error) {
+	var bundle *apiclient.CheckBundle
+	err := a.retry("UpdateCheckBundle", true, func() error {
+		var err error
+		bundle, err = a.client.UpdateCheckBundle(cfg)
+		return err
+	})
+	return bundle, err
+}
+
+func (a *resilientAPI) UpdateCheckBundleMetrics(cfg *apiclient.CheckBundleMetrics) (*apiclient.CheckBundleMetrics, error) {
+	var metrics *apiclient.CheckBundleMetrics
+	err := a.retry("UpdateCheckBundleMetrics", true, func() error {
+		var err error
+		metrics, err = a.client.UpdateCheckBundleMetrics(cfg)
+		return err
+	})
+	return metrics, err
+}
@@ -0,0 +1,227 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package check
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/errs"
+	"github.com/circonus-labs/go-apiclient"
+	"github.com/gojuno/minimock/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func testResilientAPI(client API, cacheDir string) *resilientAPI {
+	a := newResilientAPI(client, 2, cacheDir, zerolog.Nop())
+	a.minWait = time.Millisecond
+	a.maxWait = 2 * time.Millisecond
+	return a
+}
+
+func TestResilientAPIRetry(t *testing.T) {
+	t.Log("Testing resilientAPI retry")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	mc := minimock.NewController(t)
+	defer mc.Finish()
+
+	t.Log("\ttransient error, then success")
+	{
+		m := NewAPIMock(mc)
+		calls := 0
+		m.FetchCheckMock.Set(func(cid apiclient.CIDType) (*apiclient.Check, error) {
+			calls++
+			if calls < 2 {
+				return nil, errors.New("- response: 503 unavailable")
+			}
+			return &testCheck, nil
+		})
+		a := testResilientAPI(m, "")
+		cid := "/check/1234"
+		c, err := a.FetchCheck(apiclient.CIDType(&cid))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.CID != testCheck.CID {
+			t.Fatalf("expected %s, got %s", testCheck.CID, c.CID)
+		}
+		if calls != 2 {
+			t.Fatalf("expected 2 calls, got %d", calls)
+		}
+	}
+
+	t.Log("\tretries exhausted")
+	{
+		m := NewAPIMock(mc)
+		calls := 0
+		m.FetchCheckMock.Set(func(cid apiclient.CIDType) (*apiclient.Check, error) {
+			calls++
+			return nil, errors.New("- response: 503 unavailable")
+		})
+		a := testResilientAPI(m, "")
+		cid := "/check/1234"
+		_, err := a.FetchCheck(apiclient.CIDType(&cid))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !errs.IsRetryable(err) || errs.CategoryOf(err) != errs.Network {
+			t.Fatalf("expected retryable network error, got (%s)", errs.CategoryOf(err))
+		}
+		if calls != 3 {
+			t.Fatalf("expected 3 calls, got %d", calls)
+		}
+	}
+
+	t.Log("\tpermanent error")
+	{
+		m := NewAPIMock(mc)
+		calls := 0
+		m.FetchCheckMock.Set(func(cid apiclient.CIDType) (*apiclient.Check, error) {
+			calls++
+			return nil, errors.New("API response code 404: not found")
+		})
+		a := testResilientAPI(m, "")
+		cid := "/check/1234"
+		_, err := a.FetchCheck(apiclient.CIDType(&cid))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if errs.IsRetryable(err) || errs.CategoryOf(err) != errs.Config {
+			t.Fatalf("expected non-retryable config error, got (%s)", errs.CategoryOf(err))
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+	}
+
+	t.Log("\tcreate, not retried")
+	{
+		m := NewAPIMock(mc)
+		calls := 0
+		m.CreateCheckBundleMock.Set(func(cfg *apiclient.CheckBundle) (*apiclient.CheckBundle, error) {
+			calls++
+			return nil, errors.New("- response: 503 unavailable")
+		})
+		a := testResilientAPI(m, "")
+		if _, err := a.CreateCheckBundle(&apiclient.CheckBundle{}); err == nil {
+			t.Fatal("expected error")
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+	}
+
+	t.Log("\tcreate, rate limited")
+	{
+		m := NewAPIMock(mc)
+		calls := 0
+		m.CreateCheckBundleMock.Set(func(cfg *apiclient.CheckBundle) (*apiclient.CheckBundle, error) {
+			calls++
+			if calls < 2 {
+				return nil, errors.New("- response: 429 rate limit exceeded")
+			}
+			return &testCheckBundle, nil
+		})
+		a := testResilientAPI(m, "")
+		if _, err := a.CreateCheckBundle(&apiclient.CheckBundle{}); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if calls != 2 {
+			t.Fatalf("expected 2 calls, got %d", calls)
+		}
+	}
+}
+
+func TestResilientAPICache(t *testing.T) {
+	t.Log("Testing resilientAPI cache")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	mc := minimock.NewController(t)
+	defer mc.Finish()
+
+	dir, err := ioutil.TempDir("", "apicache")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := NewAPIMock(mc)
+	apiDown := false
+	m.FetchBrokerMock.Set(func(cid apiclient.CIDType) (*apiclient.Broker, error) {
+		if apiDown {
+			return nil, errors.New("Circonus API call - connection refused")
+		}
+		return &testBroker, nil
+	})
+	m.GetMock.Set(func(url string) ([]byte, error) {
+		if apiDown {
+			return nil, errors.New("Circonus API call - connection refused")
+		}
+		return []byte(cacert.Contents), nil
+	})
+
+	a := testResilientAPI(m, dir)
+	cid := "/broker/1234"
+
+	t.Log("\tapi up, result cached")
+	{
+		b, err := a.FetchBroker(apiclient.CIDType(&cid))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if b.CID != testBroker.CID {
+			t.Fatalf("expected %s, got %s", testBroker.CID, b.CID)
+		}
+		if _, err := os.Stat(a.cacheFile(cidKey("broker", apiclient.CIDType(&cid)))); err != nil {
+			t.Fatalf("expected cache file, got (%s)", err)
+		}
+		if _, err := a.Get("/pki/ca.crt"); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	apiDown = true
+
+	t.Log("\tapi down, cached result")
+	{
+		b, err := a.FetchBroker(apiclient.CIDType(&cid))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if b.CID != testBroker.CID {
+			t.Fatalf("expected %s, got %s", testBroker.CID, b.CID)
+		}
+		data, err := a.Get("/pki/ca.crt")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if string(data) != cacert.Contents {
+			t.Fatal("expected cached ca cert")
+		}
+	}
+
+	t.Log("\tapi down, nothing cached")
+	{
+		other := "/broker/5678"
+		if _, err := a.FetchBroker(apiclient.CIDType(&other)); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tapi down, cache disabled")
+	{
+		na := testResilientAPI(m, "")
+		if _, err := na.FetchBroker(apiclient.CIDType(&cid)); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
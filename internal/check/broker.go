@@ -6,6 +6,7 @@
 package check
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -35,6 +36,7 @@ func (c *Check) brokerTLSConfig(reverseURL *url.URL) (*tls.Config, string, error
 	if !cp.AppendCertsFromPEM(cert) {
 		return nil, "", errors.New("unable to add Broker CA Certificate to x509 cert pool")
 	}
+	c.brokerCA = cert
 
 	tlsConfig := &tls.Config{
 		RootCAs:    cp,
@@ -46,6 +48,36 @@ func (c *Check) brokerTLSConfig(reverseURL *url.URL) (*tls.Config, string, error
 	return tlsConfig, cn, nil
 }
 
+// RefreshBrokerCA re-fetches the broker CA certificate (from the broker-ca-file,
+// if specified, or the API). If the certificate has changed, the reverse
+// configurations are rebuilt and true is returned so that active reverse
+// connections can be re-established using the new certificate.
+func (c *Check) RefreshBrokerCA() (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.reverse {
+		return false, nil
+	}
+
+	cert, err := c.fetchBrokerCA()
+	if err != nil {
+		return false, err
+	}
+
+	if bytes.Equal(cert, c.brokerCA) {
+		c.logger.Debug().Msg("broker CA certificate unchanged")
+		return false, nil
+	}
+
+	c.logger.Info().Msg("broker CA certificate changed, rebuilding reverse configuration")
+	if err := c.setReverseConfigs(); err != nil {
+		return false, errors.Wrap(err, "rebuilding reverse configuration")
+	}
+
+	return true, nil
+}
+
 func (c *Check) getBrokerCN(reverseURL *url.URL) (string, error) {
 	host := reverseURL.Hostname()
 
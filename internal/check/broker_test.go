@@ -6,7 +6,10 @@
 package check
 
 import (
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
@@ -98,3 +101,82 @@ func TestBrokerTLSConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestRefreshBrokerCA(t *testing.T) {
+	t.Log("Testing RefreshBrokerCA")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	mc := minimock.NewController(t)
+	client := genMockClient(mc)
+
+	t.Log("not reverse")
+	{
+		c := Check{client: client, broker: &testBroker, checkConfig: &testCheck}
+		changed, err := c.RefreshBrokerCA()
+		if err != nil {
+			t.Fatalf("expected NO error got (%s)", err)
+		}
+		if changed {
+			t.Fatal("expected not changed")
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "brokerca")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, err := ioutil.ReadFile("testdata/ca.crt")
+	if err != nil {
+		t.Fatalf("reading test ca (%s)", err)
+	}
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := ioutil.WriteFile(caFile, cert, 0600); err != nil {
+		t.Fatalf("writing test ca (%s)", err)
+	}
+	viper.Set(config.KeyReverseBrokerCAFile, caFile)
+	defer viper.Reset()
+
+	c := Check{client: client, broker: &testBroker, checkConfig: &testCheck, reverse: true}
+	if err := c.setReverseConfigs(); err != nil {
+		t.Fatalf("expected NO error got (%s)", err)
+	}
+
+	t.Log("unchanged")
+	{
+		changed, err := c.RefreshBrokerCA()
+		if err != nil {
+			t.Fatalf("expected NO error got (%s)", err)
+		}
+		if changed {
+			t.Fatal("expected not changed")
+		}
+	}
+
+	t.Log("changed")
+	{
+		if err := ioutil.WriteFile(caFile, append(cert, cert...), 0600); err != nil {
+			t.Fatalf("writing test ca (%s)", err)
+		}
+		changed, err := c.RefreshBrokerCA()
+		if err != nil {
+			t.Fatalf("expected NO error got (%s)", err)
+		}
+		if !changed {
+			t.Fatal("expected changed")
+		}
+		if c.revConfigs == nil || len(*c.revConfigs) != 2 {
+			t.Fatal("expected 2 rebuilt reverse configs")
+		}
+	}
+
+	t.Log("invalid file")
+	{
+		viper.Set(config.KeyReverseBrokerCAFile, filepath.Join(dir, "missing.crt"))
+		if _, err := c.RefreshBrokerCA(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
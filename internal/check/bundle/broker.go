@@ -8,17 +8,21 @@ package bundle
 import (
 	"math/rand"
 	"net"
+	"path"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/go-apiclient"
 	"github.com/pkg/errors"
 )
 
 // Select a broker for use when creating a check, if a specific broker
-// was not specified.
+// was not specified. Candidates are restricted to brokers matching the broker
+// tags allow-list (if any) and responding within the max latency (if any),
+// the fastest broker(s) are preferred.
 func (cb *Bundle) selectBroker(checkType string, brokerList *[]apiclient.Broker) (*apiclient.Broker, error) {
 	if checkType == "" {
 		return nil, errors.New("invalid check type (empty)")
@@ -35,9 +39,22 @@ func (cb *Bundle) selectBroker(checkType string, brokerList *[]apiclient.Broker)
 	validBrokers := make(map[string]apiclient.Broker)
 	haveEnterprise := false
 	threshold := 10 * time.Second
+	if cb.brokerMaxLatency > 0 {
+		threshold = cb.brokerMaxLatency
+	}
+	allowed := 0
 
 	for _, broker := range *brokerList {
 		broker := broker
+		if !cb.isAllowedBroker(&broker) {
+			cb.logger.Debug().
+				Str("broker", broker.Name).
+				Strs("tags", broker.Tags).
+				Msg("not in broker tags allow-list, skipping")
+			continue
+		}
+		allowed++
+
 		dur, ok := cb.isValidBroker(&broker, checkType)
 		if !ok {
 			continue
@@ -73,7 +90,13 @@ func (cb *Bundle) selectBroker(checkType string, brokerList *[]apiclient.Broker)
 	}
 
 	if len(validBrokers) == 0 {
-		return nil, errors.Errorf("found %d broker(s), zero are valid", len(*brokerList))
+		if allowed == 0 {
+			return nil, errors.Errorf("found %d broker(s), zero match broker tags (%s)", len(*brokerList), strings.Join(cb.brokerTags, ","))
+		}
+		if cb.brokerMaxLatency > 0 {
+			return nil, errors.Errorf("found %d broker(s), zero are valid within max latency (%s)", allowed, cb.brokerMaxLatency)
+		}
+		return nil, errors.Errorf("found %d broker(s), zero are valid", allowed)
 	}
 
 	var selectedBroker apiclient.Broker
@@ -89,6 +112,22 @@ func (cb *Bundle) selectBroker(checkType string, brokerList *[]apiclient.Broker)
 	return &selectedBroker, nil
 }
 
+// isAllowedBroker determines if the broker has a tag matching the broker tags allow-list
+func (cb *Bundle) isAllowedBroker(broker *apiclient.Broker) bool {
+	if len(cb.brokerTags) == 0 {
+		return true
+	}
+	for _, tag := range broker.Tags {
+		tag = strings.ToLower(tag)
+		for _, pattern := range cb.brokerTags {
+			if ok, _ := path.Match(pattern, tag); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Is the broker valid (active, supports check type, and reachable)
 func (cb *Bundle) isValidBroker(broker *apiclient.Broker, checkType string) (time.Duration, bool) {
 	if broker == nil {
@@ -157,8 +196,13 @@ func (cb *Bundle) isValidBroker(broker *apiclient.Broker, checkType string) (tim
 
 		for attempt := 1; attempt <= cb.brokerMaxRetries; attempt++ {
 			start := time.Now()
-			// broker must be reachable and respond within designated time
-			conn, err := net.DialTimeout("tcp", net.JoinHostPort(brokerHost, brokerPort), cb.brokerMaxResponseTime)
+			// broker must be reachable and respond within designated time,
+			// resolved honoring the broker host overrides and dns servers
+			var conn net.Conn
+			addr, err := config.ResolveDialAddr(net.JoinHostPort(brokerHost, brokerPort))
+			if err == nil {
+				conn, err = net.DialTimeout("tcp", addr.String(), cb.brokerMaxResponseTime)
+			}
 			if err == nil {
 				connDuration = time.Since(start)
 				conn.Close()
@@ -158,6 +158,7 @@ func TestBundle_selectBroker(t *testing.T) {
 		statusActiveBroker    string
 		brokerMaxResponseTime time.Duration
 		brokerMaxRetries      int
+		brokerTags            []string
 		logger                zerolog.Logger
 	}
 
@@ -168,6 +169,9 @@ func TestBundle_selectBroker(t *testing.T) {
 		logger:                log.With().Logger(),
 	}
 
+	allowListFields := defaultFields
+	allowListFields.brokerTags = []string{"region:us-east*"}
+
 	type args struct {
 		checkType  string
 		brokerList *[]apiclient.Broker
@@ -185,6 +189,8 @@ func TestBundle_selectBroker(t *testing.T) {
 		{"invalid broker list (empty)", defaultFields, args{checkType: "json:nad", brokerList: &[]apiclient.Broker{}}, nil, true, false},
 		{"no valid broker", defaultFields, args{checkType: "json:nad", brokerList: &noValidBrokers}, nil, true, false},
 		{"valid", defaultFields, args{checkType: "json:nad", brokerList: &noValidBrokers}, nil, false, true},
+		{"valid (allow-list)", allowListFields, args{checkType: "json:nad", brokerList: &noValidBrokers}, nil, false, true},
+		{"not in allow-list", fields{statusActiveBroker: "active", brokerMaxResponseTime: time.Millisecond * 100, brokerMaxRetries: 2, brokerTags: []string{"region:eu-*"}, logger: log.With().Logger()}, args{checkType: "json:nad", brokerList: &noValidBrokers}, nil, true, true},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -193,6 +199,7 @@ func TestBundle_selectBroker(t *testing.T) {
 				statusActiveBroker:    tt.fields.statusActiveBroker,
 				brokerMaxResponseTime: tt.fields.brokerMaxResponseTime,
 				brokerMaxRetries:      tt.fields.brokerMaxRetries,
+				brokerTags:            tt.fields.brokerTags,
 				logger:                tt.fields.logger,
 			}
 			// bl := tt.args.brokerList
@@ -216,6 +223,7 @@ func TestBundle_selectBroker(t *testing.T) {
 					{
 						CID:  "/broker/4321",
 						Type: "enterprise",
+						Tags: []string{"Region:US-East-1"},
 						Details: []apiclient.BrokerDetail{
 							{
 								IP:      &host,
@@ -227,7 +235,9 @@ func TestBundle_selectBroker(t *testing.T) {
 					},
 				}
 				// set the expected result to the dynamically created broker
-				tt.want = &(*tt.args.brokerList)[0]
+				if !tt.wantErr {
+					tt.want = &(*tt.args.brokerList)[0]
+				}
 			}
 			got, err := cb.selectBroker(tt.args.checkType, tt.args.brokerList)
 			if tt.needTestServer {
@@ -243,3 +253,28 @@ func TestBundle_selectBroker(t *testing.T) {
 		})
 	}
 }
+
+func TestBundle_isAllowedBroker(t *testing.T) {
+	broker := &apiclient.Broker{CID: "/broker/1", Tags: []string{"region:us-east-1", "provider:aws"}}
+
+	tests := []struct {
+		name       string
+		brokerTags []string
+		want       bool
+	}{
+		{"no allow-list", nil, true},
+		{"exact", []string{"provider:aws"}, true},
+		{"glob", []string{"region:us-*"}, true},
+		{"any of", []string{"region:eu-*", "provider:aws"}, true},
+		{"no match", []string{"region:eu-*"}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &Bundle{brokerTags: tt.brokerTags}
+			if got := cb.isAllowedBroker(broker); got != tt.want {
+				t.Errorf("Bundle.isAllowedBroker() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -33,6 +33,8 @@ type Bundle struct {
 	statusActiveBroker    string
 	brokerMaxResponseTime time.Duration
 	brokerMaxRetries      int
+	brokerMaxLatency      time.Duration
+	brokerTags            []string
 	bundle                *apiclient.CheckBundle
 	client                API
 	lastRefresh           time.Time
@@ -96,6 +98,16 @@ func New(client API) (*Bundle, error) {
 		statusActiveMetric:    StatusActive,
 	}
 
+	maxLatency, err := config.CheckBrokerMaxLatency()
+	if err != nil {
+		return nil, errors.Wrap(err, "check broker max latency")
+	}
+	if maxLatency > 0 {
+		cb.brokerMaxLatency = maxLatency
+		cb.brokerMaxResponseTime = maxLatency // no need to wait longer for a broker which would be rejected
+	}
+	cb.brokerTags = config.CheckBrokerTags()
+
 	isCreate := viper.GetBool(config.KeyCheckCreate)
 	isManaged := viper.GetBool(config.KeyCheckEnableNewMetrics)
 	isReverse := viper.GetBool(config.KeyReverse)
@@ -139,23 +151,28 @@ func New(client API) (*Bundle, error) {
 		//       and will be removed at some point in the future. all checks will be
 		//       using metric filters going forward.
 		//
-		cb.stateFile = filepath.Join(cb.statePath, "metrics.json")
-
-		if ok, err := cb.verifyStatePath(); !ok {
-			if err != nil {
-				cb.logger.Error().Err(err).Msg("verify state path")
+		if cb.statePath == "" {
+			// e.g. read-only filesystem, see config --state-dir
+			cb.logger.Info().Msg("no metric state dir, metric states kept in memory")
+		} else {
+			cb.stateFile = filepath.Join(cb.statePath, "metrics.json")
+
+			if ok, err := cb.verifyStatePath(); !ok {
+				if err != nil {
+					cb.logger.Error().Err(err).Msg("verify state path")
+				}
+				cb.logger.Warn().Str("state_path", cb.statePath).Msg("encountered state path issue(s), disabling check-enable-new-metrics")
+				viper.Set(config.KeyCheckEnableNewMetrics, false)
+				cb.manage = false
+				return &cb, nil
 			}
-			cb.logger.Warn().Str("state_path", cb.statePath).Msg("encountered state path issue(s), disabling check-enable-new-metrics")
-			viper.Set(config.KeyCheckEnableNewMetrics, false)
-			cb.manage = false
-			return &cb, nil
-		}
 
-		if ms, err := cb.loadState(); err != nil {
-			cb.logger.Error().Err(err).Msg("unable to load existing metric states, all metrics considered existing")
-		} else {
-			cb.metricStates = ms
-			cb.logger.Debug().Interface("metric_states", len(*cb.metricStates)).Msg("loaded metric states")
+			if ms, err := cb.loadState(); err != nil {
+				cb.logger.Error().Err(err).Msg("unable to load existing metric states, all metrics considered existing")
+			} else {
+				cb.metricStates = ms
+				cb.logger.Debug().Interface("metric_states", len(*cb.metricStates)).Msg("loaded metric states")
+			}
 		}
 
 		if err := cb.setMetricStates(&cb.bundle.Metrics); err != nil {
@@ -182,6 +199,31 @@ func New(client API) (*Bundle, error) {
 	return &cb, nil
 }
 
+// NewWithID returns an existing check bundle, e.g. the destination of a metric
+// route (see --check-routes). The bundle is only fetched, it is not created,
+// updated or managed.
+func NewWithID(client API, cid string) (*Bundle, error) {
+	if client == nil {
+		return nil, errors.New("invalid client (nil)")
+	}
+
+	cb := Bundle{
+		client:             client,
+		logger:             log.With().Str("pkg", "bundle").Str("cid", cid).Logger(),
+		statusActiveBroker: StatusActive,
+		statusActiveMetric: StatusActive,
+	}
+
+	b, err := cb.fetchCheckBundle(cid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching check for cid %s", cid)
+	}
+	cb.bundle = b
+	cb.logger.Debug().Interface("config", cb.bundle).Msg("using check bundle config")
+
+	return &cb, nil
+}
+
 // CID returns the check bundle cid
 func (cb *Bundle) CID() (string, error) {
 	cb.Lock()
@@ -217,7 +259,7 @@ func (cb *Bundle) Refresh() error {
 
 	cb.logger.Debug().Msg("refreshing check configuration using API")
 
-	b, err := cb.fetchCheckBundle(viper.GetString(config.KeyCheckBundleID))
+	b, err := cb.fetchCheckBundle(cb.bundle.CID)
 	if err != nil {
 		return errors.Wrap(err, "refresh check, fetching check")
 	}
@@ -416,11 +458,15 @@ func (cb *Bundle) createCheckBundle() (*apiclient.CheckBundle, error) {
 
 	cfg := apiclient.NewCheckBundle()
 	cfg.Target = target
-	cfg.DisplayName = viper.GetString(config.KeyCheckTitle)
-	if cfg.DisplayName == "" {
-		cfg.DisplayName = cfg.Target + " /agent"
+	title, err := config.CheckTitle()
+	if err != nil {
+		return nil, errors.Wrap(err, "check title")
+	}
+	cfg.DisplayName = title
+	note, err := config.CheckNotes("created")
+	if err != nil {
+		return nil, errors.Wrap(err, "check notes")
 	}
-	note := fmt.Sprintf("created by %s %s", release.NAME, release.VERSION)
 	cfg.Notes = &note
 	cfg.Type = "json:nad"
 	cfg.Config = apiclient.CheckBundleConfig{apiconf.URL: "http://" + targetAddr + "/"}
@@ -509,11 +555,15 @@ func (cb *Bundle) updateCheckBundle(cfg *apiclient.CheckBundle) (*apiclient.Chec
 	}
 
 	cfg.Target = target
-	cfg.DisplayName = viper.GetString(config.KeyCheckTitle)
-	if cfg.DisplayName == "" {
-		cfg.DisplayName = cfg.Target + " /agent"
+	title, err := config.CheckTitle()
+	if err != nil {
+		return nil, errors.Wrap(err, "check title")
+	}
+	cfg.DisplayName = title
+	note, err := config.CheckNotes("updated")
+	if err != nil {
+		return nil, errors.Wrap(err, "check notes")
 	}
-	note := fmt.Sprintf("updated by %s %s", release.NAME, release.VERSION)
 	cfg.Notes = &note
 	cfg.Config = apiclient.CheckBundleConfig{apiconf.URL: "http://" + targetAddr + "/"}
 	cfg.Metrics = []apiclient.CheckBundleMetric{}
@@ -44,6 +44,44 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewWithID(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	mc := minimock.NewController(t)
+	client := genMockClient(mc)
+	tests := []struct {
+		name    string
+		client  API
+		cid     string
+		wantCID string
+		wantErr bool
+	}{
+		{"invalid (nil client)", nil, "1234", "", true},
+		{"invalid cid", client, "abc", "", true},
+		{"api error", client, "000", "", true},
+		{"valid", client, "1234", testCheckBundle.CID, false},
+		{"valid (cid)", client, "/check_bundle/1234", testCheckBundle.CID, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewWithID(tt.client, tt.cid)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWithID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if cid, _ := got.CID(); cid != tt.wantCID {
+				t.Errorf("NewWithID() cid = %v, want %v", cid, tt.wantCID)
+			}
+			if got.manage {
+				t.Error("NewWithID() expected unmanaged bundle")
+			}
+		})
+	}
+}
+
 func TestFetchCheck(t *testing.T) {
 	t.Log("Testing fetchCheck")
 
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/circonus-labs/circonus-agent/internal/sample"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/circonus-labs/go-apiclient"
 	"github.com/pkg/errors"
@@ -157,7 +158,8 @@ func (cb *Bundle) configMetric(mn string, mv cgm.Metric) apiclient.CheckBundleMe
 	mtype := "numeric" // default
 	switch mv.Type {
 	case "n":
-		vt := reflect.TypeOf(mv.Value).Kind().String()
+		v, _ := sample.Unwrap(mv.Value)
+		vt := reflect.TypeOf(v).Kind().String()
 		cb.logger.Debug().
 			Str("mn", mn).
 			Interface("mv", mv).
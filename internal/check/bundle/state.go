@@ -34,8 +34,10 @@ func (cb *Bundle) setMetricStates(m *[]apiclient.CheckBundleMetric) error {
 
 	cb.lastRefresh = time.Now()
 	cb.metricStateUpdate = false
-	if err := cb.saveState(cb.metricStates); err != nil {
-		cb.logger.Warn().Err(err).Msg("saving metric states")
+	if cb.stateFile != "" { // otherwise, states kept in memory
+		if err := cb.saveState(cb.metricStates); err != nil {
+			cb.logger.Warn().Err(err).Msg("saving metric states")
+		}
 	}
 
 	cb.logger.Debug().Int("metrics", len(*cb.metricStates)).Msg("updating metric states done")
@@ -6,6 +6,9 @@
 package bundle
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
@@ -97,9 +100,15 @@ func TestSaveState(t *testing.T) {
 
 	t.Log("stateFile (valid)")
 	{
-		c := Bundle{stateFile: "testdata/state/save.test"}
+		dir, err := ioutil.TempDir("", "bundlestate")
+		if err != nil {
+			t.Fatalf("unable to create temp dir (%s)", err)
+		}
+		defer os.RemoveAll(dir)
 
-		err := c.saveState(&ms)
+		c := Bundle{stateFile: filepath.Join(dir, "save.test")}
+
+		err = c.saveState(&ms)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
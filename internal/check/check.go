@@ -26,6 +26,7 @@ import (
 // Check exposes the check bundle management interface
 type Check struct {
 	statusActiveBroker    string
+	brokerCA              []byte
 	brokerMaxResponseTime time.Duration
 	brokerMaxRetries      int
 	checkConfig           *apiclient.Check
@@ -36,6 +37,7 @@ type Check struct {
 	refreshTTL            time.Duration
 	reverse               bool
 	revConfigs            *ReverseConfigs
+	route                 string // metric route served by the check (see NewRoute), primary check if empty
 	sync.Mutex
 }
 
@@ -53,6 +55,7 @@ type ReverseConfig struct {
 	BrokerID   string
 	ReverseURL *url.URL
 	TLSConfig  *tls.Config
+	Route      string // metric route requested from the agent, primary check if empty
 }
 
 type ReverseConfigs map[string]ReverseConfig
@@ -170,7 +173,9 @@ func New(apiClient API) (*Check, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "creating circonus api client")
 		}
-		apiClient = client
+		// check, check bundle and broker refreshes use conditional requests
+		condClient := newConditionalAPI(client, cfg.URL, cfg.TokenKey, cfg.TokenApp, c.logger)
+		apiClient = newResilientAPI(condClient, viper.GetInt(config.KeyAPIMaxRetries), viper.GetString(config.KeyAPICacheDir), c.logger)
 	}
 
 	c.client = apiClient
@@ -201,6 +206,48 @@ func New(apiClient API) (*Check, error) {
 	return &c, nil
 }
 
+// NewRoute returns a check for an existing check bundle which is the
+// destination of a metric route (see --check-routes), using the api client
+// of the agent's check. The check bundle is not created, updated or managed.
+func (c *Check) NewRoute(route config.CheckRoute) (*Check, error) {
+	if c.client == nil {
+		return nil, errors.New("check management disabled, api client uninitialized")
+	}
+
+	rc := Check{
+		brokerMaxResponseTime: c.brokerMaxResponseTime,
+		brokerMaxRetries:      c.brokerMaxRetries,
+		client:                c.client,
+		logger:                c.logger.With().Str("route", route.ID).Logger(),
+		route:                 route.ID,
+		statusActiveBroker:    StatusActive,
+	}
+
+	b, err := bundle.NewWithID(rc.client, route.BundleID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "check route (%s)", route.ID)
+	}
+
+	rc.checkBundle = b
+
+	if err := rc.FetchCheckConfig(); err != nil {
+		return nil, err
+	}
+
+	if err := rc.FetchBrokerConfig(); err != nil {
+		return nil, err
+	}
+
+	if viper.GetBool(config.KeyReverse) {
+		if err := rc.setReverseConfigs(); err != nil {
+			return nil, errors.Wrap(err, "setting up reverse configuration")
+		}
+		rc.reverse = true
+	}
+
+	return &rc, nil
+}
+
 // CheckMeta returns check id, check bundle id, and check uuid
 func (c *Check) CheckMeta() (*Meta, error) {
 	c.Lock()
@@ -11,6 +11,7 @@ import (
 
 	"github.com/circonus-labs/circonus-agent/internal/check/bundle"
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/gojuno/minimock/v3"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 )
@@ -72,3 +73,45 @@ func TestCheck_CheckMeta(t *testing.T) {
 		})
 	}
 }
+
+func TestCheck_NewRoute(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	viper.Reset()
+	viper.Set(config.KeyReverse, false)
+	mc := minimock.NewController(t)
+	client := genMockClient(mc)
+	tests := []struct {
+		name    string
+		client  API
+		route   config.CheckRoute
+		wantErr bool
+	}{
+		{"nil client", nil, config.CheckRoute{ID: "1234", BundleID: "/check_bundle/1234"}, true},
+		{"api error", client, config.CheckRoute{ID: "000", BundleID: "/check_bundle/000"}, true},
+		{"valid", client, config.CheckRoute{ID: "1234", BundleID: "/check_bundle/1234"}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Check{client: tt.client}
+			got, err := c.NewRoute(tt.route)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check.NewRoute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.route != tt.route.ID {
+				t.Errorf("Check.NewRoute() route = %v, want %v", got.route, tt.route.ID)
+			}
+			meta, err := got.CheckMeta()
+			if err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if meta.BundleID != testCheck.CheckBundleCID {
+				t.Errorf("Check.NewRoute() bundle = %v, want %v", meta.BundleID, testCheck.CheckBundleCID)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/go-apiclient"
+	apiconfig "github.com/circonus-labs/go-apiclient/config"
+	appstats "github.com/maier/go-appstats"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultAPIURL = "https://api.circonus.com/v2"
+	defaultAPIApp = "circonus-goapiclient"
+)
+
+// conditionalAPI fetches the check, check bundle and broker configurations
+// with conditional requests (If-None-Match/If-Modified-Since). When the api
+// responds 304 Not Modified, the last response is reused, so the periodic
+// refreshes of a fleet of agents do not transfer unchanged configurations.
+// All other calls are passed to the api client.
+type conditionalAPI struct {
+	API
+	apiURL   string
+	tokenKey string
+	tokenApp string
+	client   *http.Client
+	cache    map[string]*conditionalEntry // by request path
+	logger   zerolog.Logger
+	sync.Mutex
+}
+
+// conditionalEntry is the last response for a path and its validators
+type conditionalEntry struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+func newConditionalAPI(client API, apiURL, tokenKey, tokenApp string, logger zerolog.Logger) *conditionalAPI {
+	// same defaults as the api client
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	if !strings.Contains(apiURL, "/") {
+		apiURL = fmt.Sprintf("https://%s/v2", apiURL)
+	}
+	if tokenApp == "" {
+		tokenApp = defaultAPIApp
+	}
+
+	return &conditionalAPI{
+		API:      client,
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		tokenKey: tokenKey,
+		tokenApp: tokenApp,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				Dial: (&net.Dialer{
+					Timeout: 30 * time.Second,
+				}).Dial,
+				TLSHandshakeTimeout: 10 * time.Second,
+				DisableKeepAlives:   true,
+				MaxIdleConnsPerHost: -1,
+			},
+		},
+		cache:  make(map[string]*conditionalEntry),
+		logger: logger.With().Str("pkg", "check.api").Logger(),
+	}
+}
+
+// cidPath returns the request path of a cid (e.g. 1234 or /check/1234)
+func cidPath(prefix string, cid apiclient.CIDType) (string, error) {
+	if cid == nil || *cid == "" {
+		return "", errors.Errorf("invalid %s CID (none)", strings.TrimPrefix(prefix, "/"))
+	}
+	if strings.HasPrefix(*cid, prefix+"/") {
+		return *cid, nil
+	}
+	return prefix + "/" + strings.TrimPrefix(*cid, "/"), nil
+}
+
+// get requests a path, with the validators of the last response if any. Error
+// messages match the api client's (e.g. "API response code 404: ...") so the
+// retry layer classifies them the same way.
+func (a *conditionalAPI) get(reqPath string) ([]byte, error) {
+	a.Lock()
+	entry := a.cache[reqPath]
+	a.Unlock()
+
+	req, err := http.NewRequest("GET", a.apiURL+reqPath, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating Circonus API request: %s", reqPath)
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-Circonus-Auth-Token", a.tokenKey)
+	req.Header.Add("X-Circonus-App-Name", a.tokenApp)
+	if entry != nil {
+		if entry.etag != "" {
+			req.Header.Add("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Add("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	_ = appstats.IncrementInt("check.api.requests")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, errors.Errorf("Circonus API call - %s: %+v", reqPath, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading Circonus API response")
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_ = appstats.IncrementInt("check.api.not_modified")
+		a.logger.Debug().Str("path", reqPath).Msg("not modified, using last response")
+		return entry.body, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == 429 || resp.StatusCode >= 500 {
+			return nil, errors.Errorf("- response: %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		return nil, errors.Errorf("API response code %d: %s", resp.StatusCode, string(body))
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+
+	a.Lock()
+	if etag != "" || lastModified != "" {
+		a.cache[reqPath] = &conditionalEntry{etag: etag, lastModified: lastModified, body: body}
+	} else {
+		delete(a.cache, reqPath)
+	}
+	a.Unlock()
+
+	return body, nil
+}
+
+// fetch gets a cid and decodes the response into v
+func (a *conditionalAPI) fetch(prefix, what string, cid apiclient.CIDType, v interface{}) error {
+	reqPath, err := cidPath(prefix, cid)
+	if err != nil {
+		return err
+	}
+	data, err := a.get(reqPath)
+	if err != nil {
+		return errors.Wrapf(err, "fetching %s", what)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.Wrapf(err, "parsing %s", what)
+	}
+	return nil
+}
+
+func (a *conditionalAPI) FetchBroker(cid apiclient.CIDType) (*apiclient.Broker, error) {
+	broker := &apiclient.Broker{}
+	if err := a.fetch(apiconfig.BrokerPrefix, "broker", cid, broker); err != nil {
+		return nil, err
+	}
+	return broker, nil
+}
+
+func (a *conditionalAPI) FetchCheck(cid apiclient.CIDType) (*apiclient.Check, error) {
+	check := &apiclient.Check{}
+	if err := a.fetch(apiconfig.CheckPrefix, "check", cid, check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (a *conditionalAPI) FetchCheckBundle(cid apiclient.CIDType) (*apiclient.CheckBundle, error) {
+	bundle := &apiclient.CheckBundle{}
+	if err := a.fetch(apiconfig.CheckBundlePrefix, "check bundle", cid, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
@@ -0,0 +1,164 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package check
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/go-apiclient"
+	"github.com/gojuno/minimock/v3"
+	"github.com/rs/zerolog"
+)
+
+func TestConditionalAPI(t *testing.T) {
+	t.Log("Testing conditionalAPI")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	checkData, err := json.Marshal(testCheck)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	var (
+		requests    int
+		notModified int
+		etag        = `"v1"`
+		lastReq     *http.Request
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		lastReq = r
+		switch r.URL.Path {
+		case "/v2/check/1234":
+			if r.Header.Get("If-None-Match") == etag {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write(checkData)
+		case "/v2/broker/1234":
+			_, _ = w.Write([]byte(`{"_cid":"/broker/1234","_name":"test"}`))
+		case "/v2/check_bundle/503":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("unavailable"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+		}
+	}))
+	defer ts.Close()
+
+	mc := minimock.NewController(t)
+	defer mc.Finish()
+
+	m := NewAPIMock(mc)
+	m.FetchBrokersMock.Return(&[]apiclient.Broker{testBroker}, nil)
+
+	a := newConditionalAPI(m, ts.URL+"/v2/", "key", "", zerolog.Nop())
+
+	t.Log("\tfirst fetch")
+	{
+		cid := "/check/1234"
+		c, err := a.FetchCheck(apiclient.CIDType(&cid))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.CID != testCheck.CID {
+			t.Fatalf("expected %s, got %s", testCheck.CID, c.CID)
+		}
+		if lastReq.Header.Get("X-Circonus-Auth-Token") != "key" || lastReq.Header.Get("X-Circonus-App-Name") != defaultAPIApp {
+			t.Fatalf("unexpected headers %v", lastReq.Header)
+		}
+		if lastReq.Header.Get("If-None-Match") != "" {
+			t.Fatal("expected no If-None-Match on first fetch")
+		}
+	}
+
+	t.Log("\tnot modified, last response reused")
+	{
+		cid := "1234"
+		c, err := a.FetchCheck(apiclient.CIDType(&cid))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c.CID != testCheck.CID {
+			t.Fatalf("expected %s, got %s", testCheck.CID, c.CID)
+		}
+		if notModified != 1 {
+			t.Fatalf("expected 1 not modified response, got %d", notModified)
+		}
+	}
+
+	t.Log("\tmodified")
+	{
+		etag = `"v2"`
+		cid := "/check/1234"
+		if _, err := a.FetchCheck(apiclient.CIDType(&cid)); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if notModified != 1 {
+			t.Fatalf("expected 1 not modified response, got %d", notModified)
+		}
+		if _, err := a.FetchCheck(apiclient.CIDType(&cid)); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if notModified != 2 {
+			t.Fatalf("expected 2 not modified responses, got %d", notModified)
+		}
+	}
+
+	t.Log("\tno validators")
+	{
+		cid := "/broker/1234"
+		for i := 0; i < 2; i++ {
+			b, err := a.FetchBroker(apiclient.CIDType(&cid))
+			if err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if b.Name != "test" {
+				t.Fatalf("unexpected broker %#v", b)
+			}
+			if lastReq.Header.Get("If-None-Match") != "" || lastReq.Header.Get("If-Modified-Since") != "" {
+				t.Fatal("expected unconditional request")
+			}
+		}
+	}
+
+	t.Log("\terrors")
+	{
+		cid := "/check_bundle/404"
+		_, err := a.FetchCheckBundle(apiclient.CIDType(&cid))
+		if !isPermanentAPIError(err) {
+			t.Fatalf("expected permanent error, got (%v)", err)
+		}
+		cid = "/check_bundle/503"
+		_, err = a.FetchCheckBundle(apiclient.CIDType(&cid))
+		if err == nil || isPermanentAPIError(err) || !strings.Contains(err.Error(), "503") {
+			t.Fatalf("expected transient error, got (%v)", err)
+		}
+		if _, err := a.FetchCheck(nil); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tother calls passed to the client")
+	{
+		n := requests
+		b, err := a.FetchBrokers()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(*b) != 1 || requests != n {
+			t.Fatalf("expected client call, got %d brokers, %d requests", len(*b), requests-n)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/pkg/errors"
 )
 
@@ -39,7 +40,7 @@ func (c *Check) setReverseConfigs() error {
 			return errors.Wrapf(err, "parsing check reverse URL (%s)", rURL)
 		}
 
-		brokerAddr, err := net.ResolveTCPAddr("tcp", reverseURL.Host)
+		brokerAddr, err := config.ResolveDialAddr(reverseURL.Host)
 		if err != nil {
 			return errors.Wrapf(err, "invalid reverse service address (%s)", rURL)
 		}
@@ -55,12 +56,15 @@ func (c *Check) setReverseConfigs() error {
 			BrokerID:   c.broker.CID,
 			BrokerAddr: brokerAddr,
 			TLSConfig:  tlsConfig,
+			Route:      c.route,
 		}
 
 		c.logger.Debug().
 			Str("CN", cn).
 			Str("reverse_url", reverseURL.String()).
 			Str("broker_id", c.broker.CID).
+			Str("broker_addr", brokerAddr.String()).
+			Str("family", config.AddressFamily(brokerAddr.IP)).
 			Bool("tls", tlsConfig != nil).
 			Msg("added reverse config")
 	}
@@ -88,6 +92,11 @@ func (c *Check) FindPrimaryBrokerInstance(cfgs *ReverseConfigs) (string, error)
 	c.logger.Debug().Msg("clustered broker identified, determining which owns check")
 	// clustered brokers, need to identify which broker is the primary for the check
 	for name, cfg := range *cfgs {
+		// dial the resolved broker address (honoring the broker host overrides
+		// and dns servers) rather than resolving the reverse url host again
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		brokerHost := cfg.ReverseURL.Host
+		brokerAddr := cfg.BrokerAddr.String()
 		client := &http.Client{
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				// NOTE: so client doesn't automatically try to connect to the
@@ -97,9 +106,12 @@ func (c *Check) FindPrimaryBrokerInstance(cfgs *ReverseConfigs) (string, error)
 			},
 			Transport: &http.Transport{
 				Proxy: http.ProxyFromEnvironment,
-				Dial: (&net.Dialer{
-					Timeout: 5 * time.Second,
-				}).Dial,
+				Dial: func(network, addr string) (net.Conn, error) {
+					if addr == brokerHost {
+						addr = brokerAddr
+					}
+					return dialer.Dial(network, addr)
+				},
 				TLSHandshakeTimeout: 3 * time.Second,
 				TLSClientConfig:     cfg.TLSConfig, // all reverse brokers use HTTPS/TLS
 				DisableKeepAlives:   true,
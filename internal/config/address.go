@@ -13,6 +13,31 @@ import (
 
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DialPolicyAny use whatever address the resolver returns first
+	DialPolicyAny = "any"
+	// DialPolicyIPv4 only use ipv4 addresses
+	DialPolicyIPv4 = "ipv4"
+	// DialPolicyIPv6 only use ipv6 addresses
+	DialPolicyIPv6 = "ipv6"
+	// DialPolicyPreferIPv4 use an ipv4 address if one is available, otherwise fall back to ipv6
+	DialPolicyPreferIPv4 = "prefer-ipv4"
+	// DialPolicyPreferIPv6 use an ipv6 address if one is available, otherwise fall back to ipv4
+	DialPolicyPreferIPv6 = "prefer-ipv6"
+
+	// FamilyIPv4 address family tag value for ipv4 addresses
+	FamilyIPv4 = "ipv4"
+	// FamilyIPv6 address family tag value for ipv6 addresses
+	FamilyIPv6 = "ipv6"
+	// FamilyAny address family tag value for unspecified (dual-stack) addresses
+	FamilyAny = "any"
+)
+
+var (
+	ipv6NoPortRx = regexp.MustCompile(`^\[[a-fA-F0-9:.]+(%[^\]]+)?\]$`)
 )
 
 // ParseListen verifies and parses a listen address spec
@@ -30,7 +55,7 @@ func ParseListen(spec string) (*net.TCPAddr, error) {
 		spec += defaults.Listen
 	}
 	// ipv6 w/o port, add default
-	if ok, _ := regexp.MatchString(`^\[[a-f0-9:]+\]$`, spec); ok {
+	if ipv6NoPortRx.MatchString(spec) {
 		spec += defaults.Listen
 	}
 
@@ -46,3 +71,85 @@ func ParseListen(spec string) (*net.TCPAddr, error) {
 
 	return addr, nil
 }
+
+// StripBrackets removes the brackets from a bracketed ipv6 literal (e.g. [::1] -> ::1)
+// so that it can be safely passed to net.JoinHostPort. Other values are returned unchanged.
+func StripBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// AddressFamily returns the address family of an ip, an unspecified
+// ip (nil or all zeros, e.g. ':2609') is reported as FamilyAny
+func AddressFamily(ip net.IP) string {
+	if ip == nil || ip.IsUnspecified() {
+		return FamilyAny
+	}
+	if ip.To4() != nil {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// IsValidDialPolicy verifies a dial policy setting
+func IsValidDialPolicy(policy string) bool {
+	switch policy {
+	case DialPolicyAny, DialPolicyIPv4, DialPolicyIPv6, DialPolicyPreferIPv4, DialPolicyPreferIPv6:
+		return true
+	default:
+		return false
+	}
+}
+
+// ResolveDialAddr resolves a host:port spec to an address to dial, honoring
+// the configured dial policy (reverse.dial_policy) and the broker host
+// overrides and dns servers (check.broker_hosts, check.broker_dns_servers).
+func ResolveDialAddr(hostport string) (*net.TCPAddr, error) {
+	policy := viper.GetString(KeyReverseDialPolicy)
+	if policy == "" {
+		policy = defaults.ReverseDialPolicy
+	}
+	return resolveDialAddr(hostport, policy)
+}
+
+func resolveDialAddr(hostport, policy string) (*net.TCPAddr, error) {
+	if !IsValidDialPolicy(policy) {
+		return nil, errors.Errorf("invalid dial policy (%s)", policy)
+	}
+
+	host, portSpec, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing dial address")
+	}
+	port, err := net.LookupPort("tcp", portSpec)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving dial port")
+	}
+
+	ips, err := LookupBrokerIP(host)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving dial host")
+	}
+	if len(ips) == 0 {
+		return nil, errors.Errorf("no addresses found for %s", host)
+	}
+
+	// any, as net.ResolveTCPAddr("tcp", ...), prefers ipv4
+	want := FamilyIPv4
+	if policy == DialPolicyIPv6 || policy == DialPolicyPreferIPv6 {
+		want = FamilyIPv6
+	}
+	for _, ip := range ips {
+		if AddressFamily(ip) == want {
+			return &net.TCPAddr{IP: ip, Port: port}, nil
+		}
+	}
+
+	if policy == DialPolicyIPv4 || policy == DialPolicyIPv6 {
+		return nil, errors.Errorf("no %s address found for %s", policy, host)
+	}
+
+	return &net.TCPAddr{IP: ips[0], Port: port}, nil
+}
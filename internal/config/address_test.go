@@ -6,9 +6,11 @@
 package config
 
 import (
+	"net"
 	"testing"
 
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/spf13/viper"
 )
 
 func TestParseListen(t *testing.T) {
@@ -66,6 +68,19 @@ func TestParseListen(t *testing.T) {
 		}
 	}
 
+	t.Log("ipv6 only, upper case ([FE80::1])")
+	{
+		spec := "[FE80::1]"
+		s, err := ParseListen(spec)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if s.String() != "[fe80::1]"+defaults.Listen {
+			t.Fatalf("unexpected net spec (%s)", s.String())
+		}
+	}
+
 	t.Log("invalid (::1)")
 	{
 		spec := "::1"
@@ -102,3 +117,144 @@ func TestParseListen(t *testing.T) {
 		}
 	}
 }
+
+func TestStripBrackets(t *testing.T) {
+	t.Log("Testing StripBrackets")
+
+	tests := []struct {
+		host   string
+		expect string
+	}{
+		{"[::1]", "::1"},
+		{"::1", "::1"},
+		{"127.0.0.1", "127.0.0.1"},
+		{"localhost", "localhost"},
+		{"[", "["},
+	}
+
+	for _, test := range tests {
+		t.Log(test.host)
+		if h := StripBrackets(test.host); h != test.expect {
+			t.Fatalf("expected (%s) got (%s)", test.expect, h)
+		}
+	}
+}
+
+func TestAddressFamily(t *testing.T) {
+	t.Log("Testing AddressFamily")
+
+	tests := []struct {
+		ip     net.IP
+		expect string
+	}{
+		{nil, FamilyAny},
+		{net.IPv4zero, FamilyAny},
+		{net.IPv6unspecified, FamilyAny},
+		{net.ParseIP("127.0.0.1"), FamilyIPv4},
+		{net.ParseIP("::ffff:10.0.0.1"), FamilyIPv4},
+		{net.ParseIP("::1"), FamilyIPv6},
+	}
+
+	for _, test := range tests {
+		t.Log(test.ip)
+		if f := AddressFamily(test.ip); f != test.expect {
+			t.Fatalf("expected (%s) got (%s)", test.expect, f)
+		}
+	}
+}
+
+func TestResolveDialAddr(t *testing.T) {
+	t.Log("Testing resolveDialAddr")
+
+	t.Log("invalid policy")
+	{
+		_, err := resolveDialAddr("127.0.0.1:43191", "foo")
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != "invalid dial policy (foo)" {
+			t.Fatalf("unexpected error (%s)", err)
+		}
+	}
+
+	t.Log("any, ipv4 literal")
+	{
+		addr, err := resolveDialAddr("127.0.0.1:43191", DialPolicyAny)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if addr.String() != "127.0.0.1:43191" {
+			t.Fatalf("unexpected address (%s)", addr)
+		}
+	}
+
+	t.Log("ipv6 only, ipv4 literal")
+	{
+		_, err := resolveDialAddr("127.0.0.1:43191", DialPolicyIPv6)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("prefer ipv6, ipv4 literal (fallback)")
+	{
+		addr, err := resolveDialAddr("127.0.0.1:43191", DialPolicyPreferIPv6)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if addr.String() != "127.0.0.1:43191" {
+			t.Fatalf("unexpected address (%s)", addr)
+		}
+	}
+
+	t.Log("prefer ipv4, ipv6 literal (fallback)")
+	{
+		addr, err := resolveDialAddr("[::1]:43191", DialPolicyPreferIPv4)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if addr.String() != "[::1]:43191" {
+			t.Fatalf("unexpected address (%s)", addr)
+		}
+	}
+
+	t.Log("prefer ipv4, invalid port")
+	{
+		_, err := resolveDialAddr("[::1]:abc", DialPolicyPreferIPv4)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("prefer ipv4, no port")
+	{
+		_, err := resolveDialAddr("::1", DialPolicyPreferIPv4)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("broker host override")
+	{
+		viper.Set(KeyCheckBrokerHosts, []string{"broker.example.com=10.1.2.3", "broker6.example.com=fd00::1"})
+		defer viper.Set(KeyCheckBrokerHosts, []string{})
+
+		addr, err := resolveDialAddr("broker.example.com:43191", DialPolicyAny)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if addr.String() != "10.1.2.3:43191" {
+			t.Fatalf("unexpected addr (%s)", addr.String())
+		}
+		if _, err := resolveDialAddr("broker6.example.com:43191", DialPolicyIPv4); err == nil {
+			t.Fatal("expected error")
+		}
+		addr, err = resolveDialAddr("broker6.example.com:43191", DialPolicyPreferIPv4)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if addr.String() != "[fd00::1]:43191" {
+			t.Fatalf("unexpected addr (%s)", addr.String())
+		}
+	}
+}
@@ -21,8 +21,8 @@ func apiRequired() bool {
 		return true
 	}
 
-	// statsd w/group check enabled require API access
-	if !viper.GetBool(KeyStatsdDisabled) && viper.GetString(KeyStatsdGroupCID) != "" {
+	// statsd w/group check enabled (or created) require API access
+	if !viper.GetBool(KeyStatsdDisabled) && (viper.GetString(KeyStatsdGroupCID) != "" || viper.GetBool(KeyStatsdGroupCreate)) {
 		return true
 	}
 
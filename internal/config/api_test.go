@@ -35,6 +35,18 @@ func TestValidateAPIOptions(t *testing.T) {
 		}
 	}
 
+	t.Log("API required (statsd w/group create)")
+	{
+		viper.Set(KeyStatsdGroupCID, "")
+		viper.Set(KeyStatsdGroupCreate, true)
+		yes := apiRequired()
+		if !yes {
+			t.Fatal("Expected true")
+		}
+		viper.Set(KeyStatsdGroupCreate, false)
+		viper.Set(KeyStatsdGroupCID, "123")
+	}
+
 	t.Log("API required (reverse disabled, statsd disabled)")
 	{
 		viper.Set(KeyReverse, false)
@@ -0,0 +1,172 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"context"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// CheckBrokerTags returns the broker tags allow-list (lowercase glob patterns), empty allows any broker
+func CheckBrokerTags() []string {
+	var tags []string
+	for _, tag := range strings.Split(viper.GetString(KeyCheckBrokerTags), ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// CheckBrokerMaxLatency returns the broker max connect latency, 0 when brokers are not limited by latency
+func CheckBrokerMaxLatency() (time.Duration, error) {
+	maxLatency := viper.GetString(KeyCheckBrokerMaxLatency)
+	if maxLatency == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(maxLatency)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing check broker max latency")
+	}
+	if d < 0 {
+		return 0, errors.Errorf("invalid check broker max latency (%s)", maxLatency)
+	}
+	return d, nil
+}
+
+// brokerDNSTimeout max time to resolve a broker host with each configured dns server
+const brokerDNSTimeout = 5 * time.Second
+
+// CheckBrokerHosts returns the static broker host address overrides, by lower case host name
+func CheckBrokerHosts() (map[string]net.IP, error) {
+	hosts := make(map[string]net.IP)
+	for _, entry := range viper.GetStringSlice(KeyCheckBrokerHosts) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.Errorf("invalid check broker host (%s), expected host=ip", entry)
+		}
+		ip := net.ParseIP(StripBrackets(strings.TrimSpace(parts[1])))
+		if ip == nil {
+			return nil, errors.Errorf("invalid check broker host (%s), invalid ip", entry)
+		}
+		hosts[strings.ToLower(strings.TrimSpace(parts[0]))] = ip
+	}
+	return hosts, nil
+}
+
+// CheckBrokerDNSServers returns the dns servers (ip:port) to resolve broker hosts, empty uses the system resolver
+func CheckBrokerDNSServers() ([]string, error) {
+	var servers []string
+	for _, server := range viper.GetStringSlice(KeyCheckBrokerDNSServers) {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(server)
+		if err != nil {
+			// ip w/o port, default to 53
+			host, port = StripBrackets(server), "53"
+		}
+		if net.ParseIP(host) == nil {
+			return nil, errors.Errorf("invalid check broker dns server (%s), expected ip[:port]", server)
+		}
+		if _, err := net.LookupPort("udp", port); err != nil {
+			return nil, errors.Wrapf(err, "invalid check broker dns server (%s)", server)
+		}
+		servers = append(servers, net.JoinHostPort(host, port))
+	}
+	return servers, nil
+}
+
+// LookupBrokerIP resolves a broker host, a static override (check.broker_hosts)
+// is used as-is, otherwise the host is resolved with the configured dns servers
+// (check.broker_dns_servers, in order) or the system resolver.
+func LookupBrokerIP(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	hosts, err := CheckBrokerHosts()
+	if err != nil {
+		return nil, err
+	}
+	if ip, ok := hosts[strings.ToLower(strings.TrimSuffix(host, "."))]; ok {
+		return []net.IP{ip}, nil
+	}
+
+	servers, err := CheckBrokerDNSServers()
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return net.LookupIP(host)
+	}
+
+	// the next server is only tried when a server fails (e.g. timeout), not when the host is not found
+	var lastErr error
+	for _, server := range servers {
+		ips, err := lookupIPWith(server, host)
+		if err == nil {
+			return ips, nil
+		}
+		lastErr = errors.Wrapf(err, "resolving %s with dns server %s", host, server)
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// lookupIPWith resolves host with a specific dns server
+func lookupIPWith(server, host string) ([]net.IP, error) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), brokerDNSTimeout)
+	defer cancel()
+
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// validateCheckBrokerOptions verifies the broker tags patterns, max latency, host overrides and dns servers
+func validateCheckBrokerOptions() error {
+	for _, tag := range CheckBrokerTags() {
+		if _, err := path.Match(tag, ""); err != nil {
+			return errors.Wrapf(err, "invalid check broker tag (%s)", tag)
+		}
+	}
+	if _, err := CheckBrokerMaxLatency(); err != nil {
+		return err
+	}
+	if _, err := CheckBrokerHosts(); err != nil {
+		return err
+	}
+	_, err := CheckBrokerDNSServers()
+	return err
+}
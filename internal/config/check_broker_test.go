@@ -0,0 +1,145 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateCheckBrokerOptions(t *testing.T) {
+	t.Log("Testing validateCheckBrokerOptions")
+
+	defer func() {
+		viper.Set(KeyCheckBrokerTags, "")
+		viper.Set(KeyCheckBrokerMaxLatency, "")
+		viper.Set(KeyCheckBrokerHosts, []string{})
+		viper.Set(KeyCheckBrokerDNSServers, []string{})
+	}()
+
+	t.Log("tags")
+	{
+		viper.Set(KeyCheckBrokerTags, " Region:US-East* ,, provider:aws")
+		if tags := CheckBrokerTags(); !reflect.DeepEqual(tags, []string{"region:us-east*", "provider:aws"}) {
+			t.Fatalf("unexpected tags %v", tags)
+		}
+		if err := validateCheckBrokerOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		viper.Set(KeyCheckBrokerTags, "region:[us")
+		if err := validateCheckBrokerOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+		viper.Set(KeyCheckBrokerTags, "")
+	}
+
+	t.Log("max latency")
+	{
+		for _, l := range []string{"", "0", "250ms", "1s"} {
+			viper.Set(KeyCheckBrokerMaxLatency, l)
+			if err := validateCheckBrokerOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", l, err)
+			}
+		}
+		for _, l := range []string{"250", "-1s", "fast"} {
+			viper.Set(KeyCheckBrokerMaxLatency, l)
+			if err := validateCheckBrokerOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", l)
+			}
+		}
+	}
+
+	viper.Set(KeyCheckBrokerMaxLatency, "")
+
+	t.Log("hosts")
+	{
+		viper.Set(KeyCheckBrokerHosts, []string{"Broker.Example.com=10.1.2.3", " broker6.example.com = [fd00::1] "})
+		hosts, err := CheckBrokerHosts()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !hosts["broker.example.com"].Equal(net.ParseIP("10.1.2.3")) || !hosts["broker6.example.com"].Equal(net.ParseIP("fd00::1")) {
+			t.Fatalf("unexpected hosts %v", hosts)
+		}
+		for _, h := range []string{"broker.example.com", "=10.1.2.3", "broker.example.com=broker2"} {
+			viper.Set(KeyCheckBrokerHosts, []string{h})
+			if err := validateCheckBrokerOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", h)
+			}
+		}
+		viper.Set(KeyCheckBrokerHosts, []string{})
+	}
+
+	t.Log("dns servers")
+	{
+		viper.Set(KeyCheckBrokerDNSServers, []string{"10.0.0.53", "10.0.0.54:5353", "fd00::53", "[fd00::54]:5353"})
+		servers, err := CheckBrokerDNSServers()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !reflect.DeepEqual(servers, []string{"10.0.0.53:53", "10.0.0.54:5353", "[fd00::53]:53", "[fd00::54]:5353"}) {
+			t.Fatalf("unexpected servers %v", servers)
+		}
+		for _, s := range []string{"dns.example.com", "10.0.0.53:dns53"} {
+			viper.Set(KeyCheckBrokerDNSServers, []string{s})
+			if err := validateCheckBrokerOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", s)
+			}
+		}
+		viper.Set(KeyCheckBrokerDNSServers, []string{})
+	}
+}
+
+func TestLookupBrokerIP(t *testing.T) {
+	t.Log("Testing LookupBrokerIP")
+
+	defer func() {
+		viper.Set(KeyCheckBrokerHosts, []string{})
+		viper.Set(KeyCheckBrokerDNSServers, []string{})
+	}()
+
+	t.Log("\tip literal")
+	{
+		ips, err := LookupBrokerIP("127.0.0.1")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+			t.Fatalf("unexpected ips %v", ips)
+		}
+	}
+
+	t.Log("\toverride, not resolved with dns servers")
+	{
+		viper.Set(KeyCheckBrokerHosts, []string{"broker.example.com=10.1.2.3"})
+		viper.Set(KeyCheckBrokerDNSServers, []string{"127.0.0.1:1"})
+		ips, err := LookupBrokerIP("BROKER.example.com.")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.1.2.3")) {
+			t.Fatalf("unexpected ips %v", ips)
+		}
+	}
+
+	t.Log("\tdns server unavailable")
+	{
+		if _, err := LookupBrokerIP("broker2.example.com"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tinvalid hosts")
+	{
+		viper.Set(KeyCheckBrokerHosts, []string{"broker.example.com"})
+		if _, err := LookupBrokerIP("broker.example.com"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// CheckInfo is the data available to check title and notes templates
+// e.g. "{{.ShortName}} {{.Role}} /agent" or "{{.Tags.env}} {{.InstanceID}}"
+type CheckInfo struct {
+	Hostname   string
+	ShortName  string
+	Target     string
+	Role       string            // plugin bundle role, or applied profile name
+	Tags       map[string]string // check tags (category:value)
+	Version    string            // agent version
+	InstanceID string            // cloud instance id (aws, gcp, azure), only retrieved when used
+}
+
+var cloudInstanceIDFor = lookupCloudInstanceID
+
+// CheckTitle returns the check bundle display name, rendered from the check
+// title template (default "<check-target> /agent")
+func CheckTitle() (string, error) {
+	tmpl := viper.GetString(KeyCheckTitle)
+	if tmpl == "" {
+		return viper.GetString(KeyCheckTarget) + " /agent", nil
+	}
+	title, err := renderCheckTemplate("title", tmpl)
+	if err != nil {
+		return "", err
+	}
+	if title == "" {
+		return "", errors.New("check title resolved to empty string")
+	}
+	return title, nil
+}
+
+// CheckNotes returns the check bundle notes, rendered from the check notes
+// template. The notes always identify the agent ("<action> by circonus-agent
+// <version>"), it is used to find the agent's check bundle.
+func CheckNotes(action string) (string, error) {
+	note := action + " by " + release.NAME + " " + release.VERSION
+	tmpl := viper.GetString(KeyCheckNotes)
+	if tmpl == "" {
+		return note, nil
+	}
+	notes, err := renderCheckTemplate("notes", tmpl)
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(notes, release.NAME) {
+		notes += "\n" + note
+	}
+	return strings.TrimSpace(notes), nil
+}
+
+// validateCheckTemplateOptions verifies the check title and notes templates parse
+func validateCheckTemplateOptions() error {
+	for name, key := range map[string]string{"title": KeyCheckTitle, "notes": KeyCheckNotes} {
+		if tmpl := viper.GetString(key); tmpl != "" {
+			if _, err := parseCheckTemplate(name, tmpl); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func parseCheckTemplate(name, tmpl string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(template.FuncMap{
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing check %s template", name)
+	}
+	return t, nil
+}
+
+// renderCheckTemplate renders a check title or notes template
+func renderCheckTemplate(name, tmpl string) (string, error) {
+	t, err := parseCheckTemplate(name, tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	hostname, err := osHostname()
+	if err != nil {
+		return "", errors.Wrap(err, "check template hostname")
+	}
+
+	info := CheckInfo{
+		Hostname:  hostname,
+		ShortName: shortName(hostname),
+		Target:    viper.GetString(KeyCheckTarget),
+		Role:      viper.GetString(KeyPluginBundleRole),
+		Tags:      make(map[string]string),
+		Version:   release.VERSION,
+	}
+	if info.Role == "" {
+		info.Role = viper.GetString(KeyProfile)
+	}
+	for _, tag := range strings.Split(viper.GetString(KeyCheckTags), ",") {
+		parts := strings.SplitN(strings.TrimSpace(tag), ":", 2)
+		if len(parts) == 2 && parts[0] != "" {
+			info.Tags[parts[0]] = parts[1]
+		}
+	}
+	if strings.Contains(tmpl, ".InstanceID") {
+		id, err := cloudInstanceIDFor()
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to retrieve cloud instance id for check template")
+		}
+		info.InstanceID = id
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, info); err != nil {
+		return "", errors.Wrapf(err, "executing check %s template", name)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,148 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestCheckTitle(t *testing.T) {
+	t.Log("Testing CheckTitle")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	osHostname = func() (string, error) { return "web01.example.com", nil }
+	cloudInstanceIDFor = func() (string, error) { return "i-0123456789", nil }
+	defer func() {
+		osHostname = os.Hostname
+		cloudInstanceIDFor = lookupCloudInstanceID
+		viper.Set(KeyCheckTarget, "")
+		viper.Set(KeyCheckTitle, "")
+		viper.Set(KeyCheckTags, "")
+		viper.Set(KeyProfile, "")
+		viper.Set(KeyPluginBundleRole, "")
+	}()
+
+	viper.Set(KeyCheckTarget, "web01")
+
+	t.Log("\tdefault")
+	{
+		title, err := CheckTitle()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if title != "web01 /agent" {
+			t.Fatalf("unexpected title (%s)", title)
+		}
+	}
+
+	t.Log("\ttemplate")
+	{
+		viper.Set(KeyProfile, "web")
+		viper.Set(KeyCheckTags, "env:prod,role:frontend")
+		viper.Set(KeyCheckTitle, "{{upper .ShortName}} {{.Role}} {{.Tags.env}} {{.InstanceID}}")
+		title, err := CheckTitle()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if title != "WEB01 web prod i-0123456789" {
+			t.Fatalf("unexpected title (%s)", title)
+		}
+	}
+
+	t.Log("\trole setting, missing tag")
+	{
+		viper.Set(KeyPluginBundleRole, "cache")
+		viper.Set(KeyCheckTitle, "{{.Role}} {{.Tags.dc}}")
+		title, err := CheckTitle()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if title != "cache" {
+			t.Fatalf("unexpected title (%s)", title)
+		}
+	}
+
+	t.Log("\tinstance id unavailable")
+	{
+		cloudInstanceIDFor = func() (string, error) { return "", errors.New("no metadata") }
+		viper.Set(KeyCheckTitle, "{{.ShortName}} {{.InstanceID}}")
+		title, err := CheckTitle()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if title != "web01" {
+			t.Fatalf("unexpected title (%s)", title)
+		}
+	}
+
+	t.Log("\tempty")
+	{
+		viper.Set(KeyCheckTitle, "{{.InstanceID}}")
+		if _, err := CheckTitle(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tinvalid")
+	{
+		viper.Set(KeyCheckTitle, "{{.Host")
+		if err := validateCheckTemplateOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestCheckNotes(t *testing.T) {
+	t.Log("Testing CheckNotes")
+
+	osHostname = func() (string, error) { return "web01.example.com", nil }
+	defer func() {
+		osHostname = os.Hostname
+		viper.Set(KeyCheckNotes, "")
+	}()
+
+	t.Log("\tdefault")
+	{
+		notes, err := CheckNotes("created")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if notes != "created by "+release.NAME+" "+release.VERSION {
+			t.Fatalf("unexpected notes (%s)", notes)
+		}
+	}
+
+	t.Log("\ttemplate (agent identified)")
+	{
+		viper.Set(KeyCheckNotes, "host {{.Hostname}}")
+		notes, err := CheckNotes("updated")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if notes != "host web01.example.com\nupdated by "+release.NAME+" "+release.VERSION {
+			t.Fatalf("unexpected notes (%s)", notes)
+		}
+	}
+
+	t.Log("\ttemplate (already identifies agent)")
+	{
+		viper.Set(KeyCheckNotes, release.NAME+" {{.Version}} on {{.Hostname}}")
+		notes, err := CheckNotes("updated")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if notes != release.NAME+" "+release.VERSION+" on web01.example.com" {
+			t.Fatalf("unexpected notes (%s)", notes)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// minCheckRefreshInterval limits the api load of a fleet of agents
+const minCheckRefreshInterval = time.Minute
+
+// CheckRefreshInterval returns how often the check and broker configurations are refreshed from the API
+func CheckRefreshInterval() (time.Duration, error) {
+	interval := viper.GetString(KeyCheckRefreshInterval)
+	if interval == "" {
+		interval = defaults.CheckRefreshInterval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing check refresh interval")
+	}
+	if d < minCheckRefreshInterval {
+		return 0, errors.Errorf("invalid check refresh interval (%s), min %s", interval, minCheckRefreshInterval)
+	}
+	return d, nil
+}
+
+// validateCheckRefreshOptions verifies the check refresh interval
+func validateCheckRefreshOptions() error {
+	_, err := CheckRefreshInterval()
+	return err
+}
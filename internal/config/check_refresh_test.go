@@ -0,0 +1,51 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateCheckRefreshOptions(t *testing.T) {
+	t.Log("Testing validateCheckRefreshOptions")
+
+	defer viper.Set(KeyCheckRefreshInterval, "")
+
+	t.Log("default")
+	{
+		viper.Set(KeyCheckRefreshInterval, "")
+		d, err := CheckRefreshInterval()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if d != 5*time.Minute {
+			t.Fatalf("unexpected interval %s", d)
+		}
+	}
+
+	t.Log("valid")
+	{
+		for _, i := range []string{"1m", "30m", "24h"} {
+			viper.Set(KeyCheckRefreshInterval, i)
+			if err := validateCheckRefreshOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", i, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, i := range []string{"5", "30s", "-5m", "often"} {
+			viper.Set(KeyCheckRefreshInterval, i)
+			if err := validateCheckRefreshOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// CheckRoute sends the metrics matching a rule to a check bundle other than
+// the agent's check, a metric name (without stream tags) matching Name or a
+// metric with a stream tag of TagCategory whose value matches TagValue
+type CheckRoute struct {
+	ID          string // numeric check bundle id, the route requested by the destination
+	BundleID    string // /check_bundle/N
+	Name        *regexp.Regexp
+	TagCategory string
+	TagValue    *regexp.Regexp
+}
+
+// CheckRoutes returns the metric routing rules (BUNDLE_ID:name:REGEX or
+// BUNDLE_ID:tag:CATEGORY:REGEX), in the order configured - the first rule
+// matching a metric determines its destination
+func CheckRoutes() ([]CheckRoute, error) {
+	settings := viper.GetStringSlice(KeyCheckRoutes)
+	if len(settings) == 0 {
+		return nil, nil
+	}
+
+	routes := make([]CheckRoute, 0, len(settings))
+	for _, setting := range settings {
+		parts := strings.SplitN(setting, ":", 3)
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid check route (%s), expected BUNDLE_ID:name:REGEX or BUNDLE_ID:tag:CATEGORY:REGEX", setting)
+		}
+
+		id := strings.TrimPrefix(strings.TrimSpace(parts[0]), "/check_bundle/")
+		if ok, _ := regexp.MatchString(`^[0-9]+$`, id); !ok {
+			return nil, errors.Errorf("invalid check route bundle id (%s)", parts[0])
+		}
+		route := CheckRoute{ID: id, BundleID: "/check_bundle/" + id}
+
+		pattern := parts[2]
+		switch match := strings.TrimSpace(parts[1]); match {
+		case "name":
+		case "tag":
+			tag := strings.SplitN(pattern, ":", 2)
+			if len(tag) != 2 || strings.TrimSpace(tag[0]) == "" {
+				return nil, errors.Errorf("invalid check route (%s), expected BUNDLE_ID:tag:CATEGORY:REGEX", setting)
+			}
+			route.TagCategory = strings.TrimSpace(tag[0])
+			pattern = tag[1]
+		default:
+			return nil, errors.Errorf("invalid check route match (%s), expected name or tag", match)
+		}
+
+		if pattern == "" {
+			return nil, errors.Errorf("invalid check route (%s), empty regex", setting)
+		}
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compiling check route regex (%s)", setting)
+		}
+		if route.TagCategory != "" {
+			route.TagValue = rx
+		} else {
+			route.Name = rx
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// validateCheckRouteOptions verifies the metric routing rules
+func validateCheckRouteOptions() error {
+	routes, err := CheckRoutes()
+	if err != nil {
+		return err
+	}
+
+	cid := strings.TrimPrefix(viper.GetString(KeyCheckBundleID), "/check_bundle/")
+	for _, route := range routes {
+		if route.ID == cid {
+			return errors.Errorf("check route bundle id (%s) is the agent's check bundle", route.BundleID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateCheckRouteOptions(t *testing.T) {
+	t.Log("Testing validateCheckRouteOptions")
+
+	// only the keys set are restored, later tests in the package use the api settings
+	defer func() {
+		viper.Set(KeyCheckBundleID, "")
+		viper.Set(KeyCheckRoutes, []string{})
+	}()
+
+	t.Log("not set")
+	{
+		viper.Set(KeyCheckBundleID, "")
+		viper.Set(KeyCheckRoutes, []string{})
+		if err := validateCheckRouteOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		routes, err := CheckRoutes()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if routes != nil {
+			t.Fatalf("expected no routes, got %v", routes)
+		}
+	}
+
+	t.Log("valid")
+	{
+		viper.Set(KeyCheckBundleID, "123")
+		viper.Set(KeyCheckRoutes, []string{
+			"456:tag:collector:^(audit|auth)$",
+			"/check_bundle/789:name:^security_",
+		})
+		if err := validateCheckRouteOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		routes, err := CheckRoutes()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(routes) != 2 {
+			t.Fatalf("expected 2 routes, got %v", routes)
+		}
+		r := routes[0]
+		if r.ID != "456" || r.BundleID != "/check_bundle/456" || r.TagCategory != "collector" || r.Name != nil {
+			t.Fatalf("unexpected route %#v", r)
+		}
+		if !r.TagValue.MatchString("auth") || r.TagValue.MatchString("authz") {
+			t.Fatalf("unexpected tag value regex %s", r.TagValue)
+		}
+		r = routes[1]
+		if r.ID != "789" || r.BundleID != "/check_bundle/789" || r.TagCategory != "" || r.TagValue != nil {
+			t.Fatalf("unexpected route %#v", r)
+		}
+		if !r.Name.MatchString("security_logins") {
+			t.Fatalf("unexpected name regex %s", r.Name)
+		}
+	}
+
+	tt := []struct {
+		name    string
+		setting []string
+		expect  string
+	}{
+		{"no regex", []string{"456:name"}, "invalid check route (456:name), expected BUNDLE_ID:name:REGEX or BUNDLE_ID:tag:CATEGORY:REGEX"},
+		{"invalid bundle id", []string{"abc:name:^foo"}, "invalid check route bundle id (abc)"},
+		{"invalid match", []string{"456:type:^foo"}, "invalid check route match (type), expected name or tag"},
+		{"no tag regex", []string{"456:tag:collector"}, "invalid check route (456:tag:collector), expected BUNDLE_ID:tag:CATEGORY:REGEX"},
+		{"empty regex", []string{"456:name:"}, "invalid check route (456:name:), empty regex"},
+		{"invalid regex", []string{"456:name:(foo"}, "compiling check route regex (456:name:(foo): error parsing regexp: missing closing ): `(foo`"},
+		{"agent check", []string{"/check_bundle/123:name:^foo"}, "check route bundle id (/check_bundle/123) is the agent's check bundle"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid - %s", tst.name)
+		viper.Set(KeyCheckBundleID, "123")
+		viper.Set(KeyCheckRoutes, tst.setting)
+		err := validateCheckRouteOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Fatalf("expected (%s) got (%s)", tst.expect, err)
+		}
+	}
+}
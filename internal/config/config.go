@@ -21,16 +21,81 @@ import (
 
 // Log defines the running config.log structure
 type Log struct {
-	Level  string `json:"level" yaml:"level" toml:"level"`
-	Pretty bool   `json:"pretty" yaml:"pretty" toml:"pretty"`
+	Level          string `json:"level" yaml:"level" toml:"level"`
+	Pretty         bool   `json:"pretty" yaml:"pretty" toml:"pretty"`
+	Access         bool   `json:"access" yaml:"access" toml:"access"`
+	TraceSpans     bool   `mapstructure:"trace_spans" json:"trace_spans" yaml:"trace_spans" toml:"trace_spans"`
+	File           string `json:"file" yaml:"file" toml:"file"`
+	FileMaxSize    int    `mapstructure:"file_max_size" json:"file_max_size" yaml:"file_max_size" toml:"file_max_size"`
+	FileMaxAge     string `mapstructure:"file_max_age" json:"file_max_age" yaml:"file_max_age" toml:"file_max_age"`
+	FileMaxBackups int    `mapstructure:"file_max_backups" json:"file_max_backups" yaml:"file_max_backups" toml:"file_max_backups"`
+	FileCompress   bool   `mapstructure:"file_compress" json:"file_compress" yaml:"file_compress" toml:"file_compress"`
+	System         bool   `json:"system" yaml:"system" toml:"system"`
+}
+
+// Audit defines the running config.audit structure
+type Audit struct {
+	Config    bool   `json:"config" yaml:"config" toml:"config"`
+	StateFile string `mapstructure:"state_file" json:"state_file" yaml:"state_file" toml:"state_file"`
+}
+
+// Heartbeat defines the running config.heartbeat structure
+type Heartbeat struct {
+	Enabled   bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	StateFile string `mapstructure:"state_file" json:"state_file" yaml:"state_file" toml:"state_file"`
+}
+
+// Watchdog defines the running config.watchdog structure
+type Watchdog struct {
+	CollectorTimeout string `mapstructure:"collector_timeout" json:"collector_timeout" yaml:"collector_timeout" toml:"collector_timeout"`
+	Enabled          bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Interval         string `json:"interval" yaml:"interval" toml:"interval"`
+	Restart          bool   `json:"restart" yaml:"restart" toml:"restart"`
+	StuckFactor      int    `mapstructure:"stuck_factor" json:"stuck_factor" yaml:"stuck_factor" toml:"stuck_factor"`
+}
+
+// CounterState defines the running config.counter_state structure
+type CounterState struct {
+	Enabled   bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	StateFile string `mapstructure:"state_file" json:"state_file" yaml:"state_file" toml:"state_file"`
+}
+
+// FlushArchive defines the running config.flush_archive structure
+type FlushArchive struct {
+	Count  int    `json:"count" yaml:"count" toml:"count"`
+	MaxAge string `mapstructure:"max_age" json:"max_age" yaml:"max_age" toml:"max_age"`
+	Dir    string `json:"dir" yaml:"dir" toml:"dir"`
+}
+
+// Graphite defines the running config.graphite structure
+type Graphite struct {
+	Addr    string   `json:"addr" yaml:"addr" toml:"addr"`
+	Mapping []string `json:"mapping" yaml:"mapping" toml:"mapping"`
+}
+
+// K8sNode defines the running config.k8s_node structure
+type K8sNode struct {
+	Enabled  bool     `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Labels   []string `json:"labels" yaml:"labels" toml:"labels"`
+	HostRoot string   `mapstructure:"host_root" json:"host_root" yaml:"host_root" toml:"host_root"`
+}
+
+// PluginBundle defines the running config.plugin_bundle structure
+type PluginBundle struct {
+	URL       string `json:"url" yaml:"url" toml:"url"`
+	Role      string `json:"role" yaml:"role" toml:"role"`
+	PublicKey string `mapstructure:"public_key" json:"public_key" yaml:"public_key" toml:"public_key"`
+	Interval  string `json:"interval" yaml:"interval" toml:"interval"`
 }
 
 // API defines the running config.api structure
 type API struct {
-	App    string `json:"app" yaml:"app" toml:"app"`
-	CAFile string `mapstructure:"ca_file" json:"ca_file" yaml:"ca_file" toml:"ca_file"`
-	Key    string `json:"key" yaml:"key" toml:"key"`
-	URL    string `json:"url" yaml:"url" toml:"url"`
+	App        string `json:"app" yaml:"app" toml:"app"`
+	CacheDir   string `mapstructure:"cache_dir" json:"cache_dir" yaml:"cache_dir" toml:"cache_dir"`
+	CAFile     string `mapstructure:"ca_file" json:"ca_file" yaml:"ca_file" toml:"ca_file"`
+	Key        string `json:"key" yaml:"key" toml:"key"`
+	MaxRetries int    `mapstructure:"max_retries" json:"max_retries" yaml:"max_retries" toml:"max_retries"`
+	URL        string `json:"url" yaml:"url" toml:"url"`
 }
 
 // ReverseCreateCheckOptions defines the running config.reverse.check structure
@@ -42,30 +107,56 @@ type ReverseCreateCheckOptions struct {
 
 // Check defines the check parameters
 type Check struct {
-	Broker              string  `json:"broker" yaml:"broker" toml:"broker"`
-	BundleID            string  `mapstructure:"bundle_id" json:"bundle_id" yaml:"bundle_id" toml:"bundle_id"`
-	Create              bool    `mapstructure:"create" json:"create" yaml:"create" toml:"create"`
-	MetricFilterFile    string  `mapstructure:"metric_filter_file" json:"metric_filter_file" yaml:"metric_filter_file" toml:"metric_filter_file"`
-	MetricFilters       string  `mapstructure:"metric_filters" json:"metric_filters" yaml:"metric_filters" toml:"metric_filters"` // needs to be json embedded in a string because rules are positional
-	MetricStreamtags    bool    `mapstructure:"metric_streamtags" json:"metric_streamtags" yaml:"metric_streamtags" toml:"metric_streamtags"`
-	Period              uint    `json:"period" toml:"period" yaml:"period"`
-	Tags                string  `json:"tags" yaml:"tags" toml:"tags"`
-	Target              string  `mapstructure:"target" json:"target" yaml:"target" toml:"target"`
-	Timeout             float64 `json:"timeout" toml:"timeout" yaml:"timeout"`
-	Title               string  `json:"title" yaml:"title" toml:"title"`
-	Update              bool    `json:"update" toml:"update" yaml:"update"`
-	UpdateMetricFilters bool    `mapstructure:"update_metric_filters" json:"update_metric_filters" yaml:"update_metric_filters" toml:"update_metric_filters"`
+	Broker              string   `json:"broker" yaml:"broker" toml:"broker"`
+	BrokerDNSServers    []string `mapstructure:"broker_dns_servers" json:"broker_dns_servers" yaml:"broker_dns_servers" toml:"broker_dns_servers"`
+	BrokerHosts         []string `mapstructure:"broker_hosts" json:"broker_hosts" yaml:"broker_hosts" toml:"broker_hosts"`
+	BrokerMaxLatency    string   `mapstructure:"broker_max_latency" json:"broker_max_latency" yaml:"broker_max_latency" toml:"broker_max_latency"`
+	BrokerTags          string   `mapstructure:"broker_tags" json:"broker_tags" yaml:"broker_tags" toml:"broker_tags"`
+	BundleID            string   `mapstructure:"bundle_id" json:"bundle_id" yaml:"bundle_id" toml:"bundle_id"`
+	Create              bool     `mapstructure:"create" json:"create" yaml:"create" toml:"create"`
+	MetricFilterFile    string   `mapstructure:"metric_filter_file" json:"metric_filter_file" yaml:"metric_filter_file" toml:"metric_filter_file"`
+	MetricFilters       string   `mapstructure:"metric_filters" json:"metric_filters" yaml:"metric_filters" toml:"metric_filters"` // needs to be json embedded in a string because rules are positional
+	MetricStreamtags    bool     `mapstructure:"metric_streamtags" json:"metric_streamtags" yaml:"metric_streamtags" toml:"metric_streamtags"`
+	Notes               string   `json:"notes" yaml:"notes" toml:"notes"`
+	Period              uint     `json:"period" toml:"period" yaml:"period"`
+	RefreshInterval     string   `mapstructure:"refresh_interval" json:"refresh_interval" yaml:"refresh_interval" toml:"refresh_interval"`
+	Routes              []string `json:"routes" yaml:"routes" toml:"routes"`
+	Tags                string   `json:"tags" yaml:"tags" toml:"tags"`
+	Target              string   `mapstructure:"target" json:"target" yaml:"target" toml:"target"`
+	TargetStrategy      string   `mapstructure:"target_strategy" json:"target_strategy" yaml:"target_strategy" toml:"target_strategy"`
+	TargetTemplate      string   `mapstructure:"target_template" json:"target_template" yaml:"target_template" toml:"target_template"`
+	Timeout             float64  `json:"timeout" toml:"timeout" yaml:"timeout"`
+	Title               string   `json:"title" yaml:"title" toml:"title"`
+	Update              bool     `json:"update" toml:"update" yaml:"update"`
+	UpdateMetricFilters bool     `mapstructure:"update_metric_filters" json:"update_metric_filters" yaml:"update_metric_filters" toml:"update_metric_filters"`
 	// hide deprecated config settings
 	EnableNewMetrics bool   `json:"-" yaml:"-" toml:"-"`
 	MetricRefreshTTL string `json:"-" yaml:"-" toml:"-"`
 	MetricStateDir   string `json:"-" yaml:"-" toml:"-"`
 }
 
+// OTLP defines the running config.otlp structure
+type OTLP struct {
+	Addr     string `json:"addr" yaml:"addr" toml:"addr"`
+	CertFile string `mapstructure:"cert_file" json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `mapstructure:"key_file" json:"key_file" yaml:"key_file" toml:"key_file"`
+}
+
 // Reverse defines the running config.reverse structure
 type Reverse struct {
-	BrokerCAFile string `mapstructure:"broker_ca_file" json:"broker_ca_file" yaml:"broker_ca_file" toml:"broker_ca_file"`
-	Enabled      bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
-	MaxConnRetry int    `mapstructure:"max_conn_retry" json:"max_conn_retry" yaml:"max_conn_retry" toml:"max_conn_retry"`
+	AdminKeyFile    string `mapstructure:"admin_key_file" json:"admin_key_file" yaml:"admin_key_file" toml:"admin_key_file"`
+	BrokerCAFile    string `mapstructure:"broker_ca_file" json:"broker_ca_file" yaml:"broker_ca_file" toml:"broker_ca_file"`
+	BrokerCARefresh string `mapstructure:"broker_ca_refresh" json:"broker_ca_refresh" yaml:"broker_ca_refresh" toml:"broker_ca_refresh"`
+	DialPolicy      string `mapstructure:"dial_policy" json:"dial_policy" yaml:"dial_policy" toml:"dial_policy"`
+	Enabled         bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	MaxConnRetry    int    `mapstructure:"max_conn_retry" json:"max_conn_retry" yaml:"max_conn_retry" toml:"max_conn_retry"`
+}
+
+// Runtime defines the running config.runtime structure
+type Runtime struct {
+	Ballast     string `json:"ballast" yaml:"ballast" toml:"ballast"`
+	GOGC        int    `mapstructure:"gogc" json:"gogc" yaml:"gogc" toml:"gogc"`
+	MemoryLimit string `mapstructure:"memory_limit" json:"memory_limit" yaml:"memory_limit" toml:"memory_limit"`
 }
 
 // SSL defines the running config.ssl structure
@@ -84,54 +175,123 @@ type StatsDHost struct {
 
 // StatsDGroup defines the running config.statsd.group structure
 type StatsDGroup struct {
+	Broker        string `json:"broker" yaml:"broker" toml:"broker"`
 	CheckBundleID string `mapstructure:"check_bundle_id" json:"check_bundle_id" yaml:"check_bundle_id" toml:"check_bundle_id"`
+	Create        bool   `json:"create" yaml:"create" toml:"create"`
 	Counters      string `json:"counters" yaml:"counters" toml:"counters"`
 	Gauges        string `json:"gauges" yaml:"gauges" toml:"gauges"`
 	MetricPrefix  string `mapstructure:"metric_prefix" json:"metric_prefix" yaml:"metric_prefix" toml:"metric_prefix"`
 	Sets          string `json:"sets" yaml:"sets" toml:"sets"`
+	Tags          string `json:"tags" yaml:"tags" toml:"tags"`
+	Target        string `json:"target" yaml:"target" toml:"target"`
 }
 
 // StatsD defines the running config.statsd structure
 type StatsD struct {
-	Disabled bool        `json:"disabled" yaml:"disabled" toml:"disabled"`
-	Group    StatsDGroup `json:"group" yaml:"group" toml:"group"`
-	Host     StatsDHost  `json:"host" yaml:"host" toml:"host"`
-	Addr     string      `join:"addr" yaml:"addr" toml:"addr"`
-	Port     string      `json:"port" yaml:"port" toml:"port"`
+	Disabled    bool        `json:"disabled" yaml:"disabled" toml:"disabled"`
+	Group       StatsDGroup `json:"group" yaml:"group" toml:"group"`
+	Host        StatsDHost  `json:"host" yaml:"host" toml:"host"`
+	Addr        string      `join:"addr" yaml:"addr" toml:"addr"`
+	Port        string      `json:"port" yaml:"port" toml:"port"`
+	QueuePolicy string      `mapstructure:"queue_policy" json:"queue_policy" yaml:"queue_policy" toml:"queue_policy"`
+	QueueSize   uint        `mapstructure:"queue_size" json:"queue_size" yaml:"queue_size" toml:"queue_size"`
+	EnableTCP   bool        `mapstructure:"enable_tcp" json:"enable_tcp" yaml:"enable_tcp" toml:"enable_tcp"`
+	MaxTCPConns uint        `mapstructure:"max_tcp_connections" json:"max_tcp_connections" yaml:"max_tcp_connections" toml:"max_tcp_connections"`
+	TCPPort     string      `mapstructure:"tcp_port" json:"tcp_port" yaml:"tcp_port" toml:"tcp_port"`
+	TCPFraming  string      `mapstructure:"tcp_framing" json:"tcp_framing" yaml:"tcp_framing" toml:"tcp_framing"`
+	TCPTimeout  string      `mapstructure:"tcp_read_timeout" json:"tcp_read_timeout" yaml:"tcp_read_timeout" toml:"tcp_read_timeout"`
+}
+
+// FailoverResource defines a clustered service resource (e.g. the network name of a
+// Windows failover cluster role), metrics matching MetricFilter are also emitted tagged
+// with the resource name by the node currently owning the resource address
+type FailoverResource struct {
+	Name         string `json:"name" yaml:"name" toml:"name"`                                                         // resource network name, used as the tag value
+	Address      string `json:"address" yaml:"address" toml:"address"`                                                // resource ip address, resolved from name when not set
+	MetricFilter string `mapstructure:"metric_filter" json:"metric_filter" yaml:"metric_filter" toml:"metric_filter"` // regex matched against metric names, default all metrics
 }
 
 // Config defines the running config structure
 type Config struct {
-	API              API      `json:"api" yaml:"api" toml:"api"`
-	Check            Check    `json:"check" yaml:"check" toml:"check"`
-	Collectors       []string `json:"collectors" yaml:"collectors" toml:"collectors"`
-	Debug            bool     `json:"debug" yaml:"debug" toml:"debug"`
-	DebugCGM         bool     `mapstructure:"debug_cgm" json:"debug_cgm" yaml:"debug_cgm" toml:"debug_cgm"`
-	DebugAPI         bool     `mapstructure:"debug_api" json:"debug_api" yaml:"debug_api" toml:"debug_api"`
-	DebugDumpMetrics string   `mapstructure:"debug_dump_metrics" json:"debug_dump_metrics" yaml:"debug_dump_metrics" toml:"debug_dump_metrics"`
-	Listen           []string `json:"listen" yaml:"listen" toml:"listen"`
-	ListenSocket     []string `mapstructure:"listen_socket" json:"listen_socket" yaml:"listen_socket" toml:"listen_socket"`
-	Log              Log      `json:"log" yaml:"log" toml:"log"`
-	PluginDir        string   `mapstructure:"plugin_dir" json:"plugin_dir" yaml:"plugin_dir" toml:"plugin_dir"`
-	PluginList       []string `mapstructure:"plugin_list" json:"plugin_list" yaml:"plugin_list" toml:"plugin_list"`
-	PluginTTLUnits   string   `mapstructure:"plugin_ttl_units" json:"plugin_ttl_units" yaml:"plugin_ttl_units" toml:"plugin_ttl_units"`
-	Reverse          Reverse  `json:"reverse" yaml:"reverse" toml:"reverse"`
-	SSL              SSL      `json:"ssl" yaml:"ssl" toml:"ssl"`
-	StatsD           StatsD   `json:"statsd" yaml:"statsd" toml:"statsd"`
-	HostProc         string   `mapstructure:"host_proc" json:"host_proc" toml:"host_proc" yaml:"host_proc"`
-	HostSys          string   `mapstructure:"host_sys" json:"host_sys" toml:"host_sys" yaml:"host_sys"`
-	HostEtc          string   `mapstructure:"host_etc" json:"host_etc" toml:"host_etc" yaml:"host_etc"`
-	HostVar          string   `mapstructure:"host_var" json:"host_var" toml:"host_var" yaml:"host_var"`
-	HostRun          string   `mapstructure:"host_run" json:"host_run" toml:"host_run" yaml:"host_run"`
+	API               API                `json:"api" yaml:"api" toml:"api"`
+	Audit             Audit              `json:"audit" yaml:"audit" toml:"audit"`
+	CacheDir          string             `mapstructure:"cache_dir" json:"cache_dir" yaml:"cache_dir" toml:"cache_dir"`
+	Check             Check              `json:"check" yaml:"check" toml:"check"`
+	Collectors        []string           `json:"collectors" yaml:"collectors" toml:"collectors"`
+	CORSOrigins       []string           `mapstructure:"cors_origins" json:"cors_origins" yaml:"cors_origins" toml:"cors_origins"`
+	CounterState      CounterState       `mapstructure:"counter_state" json:"counter_state" yaml:"counter_state" toml:"counter_state"`
+	Debug             bool               `json:"debug" yaml:"debug" toml:"debug"`
+	DebugCGM          bool               `mapstructure:"debug_cgm" json:"debug_cgm" yaml:"debug_cgm" toml:"debug_cgm"`
+	DebugAPI          bool               `mapstructure:"debug_api" json:"debug_api" yaml:"debug_api" toml:"debug_api"`
+	DebugDumpMetrics  string             `mapstructure:"debug_dump_metrics" json:"debug_dump_metrics" yaml:"debug_dump_metrics" toml:"debug_dump_metrics"`
+	FailoverResources []FailoverResource `mapstructure:"failover_resources" json:"failover_resources" yaml:"failover_resources" toml:"failover_resources"`
+	FlushArchive      FlushArchive       `mapstructure:"flush_archive" json:"flush_archive" yaml:"flush_archive" toml:"flush_archive"`
+	Graphite          Graphite           `json:"graphite" yaml:"graphite" toml:"graphite"`
+	Heartbeat         Heartbeat          `json:"heartbeat" yaml:"heartbeat" toml:"heartbeat"`
+	HooksFile         string             `mapstructure:"hooks_file" json:"hooks_file" yaml:"hooks_file" toml:"hooks_file"`
+	K8sNode           K8sNode            `mapstructure:"k8s_node" json:"k8s_node" yaml:"k8s_node" toml:"k8s_node"`
+	Listen            []string           `json:"listen" yaml:"listen" toml:"listen"`
+	ListenACLFile     string             `mapstructure:"listen_acl_file" json:"listen_acl_file" yaml:"listen_acl_file" toml:"listen_acl_file"`
+	ListenSocket      []string           `mapstructure:"listen_socket" json:"listen_socket" yaml:"listen_socket" toml:"listen_socket"`
+	ListenSocketAPI   bool               `mapstructure:"listen_socket_api" json:"listen_socket_api" yaml:"listen_socket_api" toml:"listen_socket_api"`
+	ListenSocketMode  string             `mapstructure:"listen_socket_mode" json:"listen_socket_mode" yaml:"listen_socket_mode" toml:"listen_socket_mode"`
+	ListenSocketOnly  bool               `mapstructure:"listen_socket_only" json:"listen_socket_only" yaml:"listen_socket_only" toml:"listen_socket_only"`
+	LocalMode         bool               `mapstructure:"local_mode" json:"local_mode" yaml:"local_mode" toml:"local_mode"`
+	Log               Log                `json:"log" yaml:"log" toml:"log"`
+	Maintenance       []string           `mapstructure:"maintenance_windows" json:"maintenance_windows" yaml:"maintenance_windows" toml:"maintenance_windows"`
+	MaxPendingSeries  uint               `mapstructure:"max_pending_series" json:"max_pending_series" yaml:"max_pending_series" toml:"max_pending_series"`
+	MetricAggregate   []string           `mapstructure:"metric_aggregate" json:"metric_aggregate" yaml:"metric_aggregate" toml:"metric_aggregate"`
+	MetricMerge       string             `mapstructure:"metric_merge" json:"metric_merge" yaml:"metric_merge" toml:"metric_merge"`
+	MetricTopK        []string           `mapstructure:"metric_topk" json:"metric_topk" yaml:"metric_topk" toml:"metric_topk"`
+	MetricTombstones  bool               `mapstructure:"metric_tombstones" json:"metric_tombstones" yaml:"metric_tombstones" toml:"metric_tombstones"`
+	MetricTTL         []string           `mapstructure:"metric_ttl" json:"metric_ttl" yaml:"metric_ttl" toml:"metric_ttl"`
+	OTLP              OTLP               `json:"otlp" yaml:"otlp" toml:"otlp"`
+	OutputTags        []string           `mapstructure:"output_tags" json:"output_tags" yaml:"output_tags" toml:"output_tags"`
+	PluginBundle      PluginBundle       `mapstructure:"plugin_bundle" json:"plugin_bundle" yaml:"plugin_bundle" toml:"plugin_bundle"`
+	PluginDir         string             `mapstructure:"plugin_dir" json:"plugin_dir" yaml:"plugin_dir" toml:"plugin_dir"`
+	PluginList        []string           `mapstructure:"plugin_list" json:"plugin_list" yaml:"plugin_list" toml:"plugin_list"`
+	PluginMaxCPU      int                `mapstructure:"plugin_max_cpu_percent" json:"plugin_max_cpu_percent" yaml:"plugin_max_cpu_percent" toml:"plugin_max_cpu_percent"`
+	PluginMaxMemory   int64              `mapstructure:"plugin_max_memory_bytes" json:"plugin_max_memory_bytes" yaml:"plugin_max_memory_bytes" toml:"plugin_max_memory_bytes"`
+	PluginMaxOutput   int                `mapstructure:"plugin_max_output_bytes" json:"plugin_max_output_bytes" yaml:"plugin_max_output_bytes" toml:"plugin_max_output_bytes"`
+	PluginTimeout     string             `mapstructure:"plugin_timeout" json:"plugin_timeout" yaml:"plugin_timeout" toml:"plugin_timeout"`
+	PluginTTLUnits    string             `mapstructure:"plugin_ttl_units" json:"plugin_ttl_units" yaml:"plugin_ttl_units" toml:"plugin_ttl_units"`
+	Profile           string             `json:"profile" yaml:"profile" toml:"profile"`
+	Profiles          []Profile          `json:"profiles" yaml:"profiles" toml:"profiles"`
+	ProxyTargets      []string           `mapstructure:"proxy_targets" json:"proxy_targets" yaml:"proxy_targets" toml:"proxy_targets"`
+	Reverse           Reverse            `json:"reverse" yaml:"reverse" toml:"reverse"`
+	SpoolDir          string             `mapstructure:"spool_dir" json:"spool_dir" yaml:"spool_dir" toml:"spool_dir"`
+	StaleSources      []string           `mapstructure:"stale_sources" json:"stale_sources" yaml:"stale_sources" toml:"stale_sources"`
+	StaleSourceAge    string             `mapstructure:"stale_source_age" json:"stale_source_age" yaml:"stale_source_age" toml:"stale_source_age"`
+	StateDir          string             `mapstructure:"state_dir" json:"state_dir" yaml:"state_dir" toml:"state_dir"`
+	StatusUI          bool               `mapstructure:"status_ui" json:"status_ui" yaml:"status_ui" toml:"status_ui"`
+	TextMetricResend  string             `mapstructure:"text_metric_resend" json:"text_metric_resend" yaml:"text_metric_resend" toml:"text_metric_resend"`
+	WriteDedupTTL     string             `mapstructure:"write_dedup_ttl" json:"write_dedup_ttl" yaml:"write_dedup_ttl" toml:"write_dedup_ttl"`
+	Runtime           Runtime            `json:"runtime" yaml:"runtime" toml:"runtime"`
+	RunDeltaEncoding  bool               `mapstructure:"run_delta_encoding" json:"run_delta_encoding" yaml:"run_delta_encoding" toml:"run_delta_encoding"`
+	RunMaxResponse    int                `mapstructure:"run_max_response_bytes" json:"run_max_response_bytes" yaml:"run_max_response_bytes" toml:"run_max_response_bytes"`
+	SSL               SSL                `json:"ssl" yaml:"ssl" toml:"ssl"`
+	StatsD            StatsD             `json:"statsd" yaml:"statsd" toml:"statsd"`
+	HostProc          string             `mapstructure:"host_proc" json:"host_proc" toml:"host_proc" yaml:"host_proc"`
+	HostSys           string             `mapstructure:"host_sys" json:"host_sys" toml:"host_sys" yaml:"host_sys"`
+	HostEtc           string             `mapstructure:"host_etc" json:"host_etc" toml:"host_etc" yaml:"host_etc"`
+	HostVar           string             `mapstructure:"host_var" json:"host_var" toml:"host_var" yaml:"host_var"`
+	HostRun           string             `mapstructure:"host_run" json:"host_run" toml:"host_run" yaml:"host_run"`
+	Watchdog          Watchdog           `json:"watchdog" yaml:"watchdog" toml:"watchdog"`
+	WMIHostProcess    bool               `mapstructure:"wmi_host_process" json:"wmi_host_process" toml:"wmi_host_process" yaml:"wmi_host_process"`
 }
 
-//
 // NOTE: adding a Key* MUST be reflected in the Config structures above
-//
 const (
+	// KeyAPICacheDir directory where api results (check, check bundle, broker) are cached
+	// for use when the api is unavailable (empty disables caching)
+	KeyAPICacheDir = "api.cache_dir"
+
 	// KeyAPICAFile custom ca for circonus api (e.g. inside)
 	KeyAPICAFile = "api.ca_file"
 
+	// KeyAPIMaxRetries number of times to retry failed api calls (with exponential backoff)
+	KeyAPIMaxRetries = "api.max_retries"
+
 	// KeyAPITokenApp circonus api token key application name
 	KeyAPITokenApp = "api.app"
 
@@ -141,6 +301,12 @@ const (
 	// KeyAPIURL custom circonus api url (e.g. inside)
 	KeyAPIURL = "api.url"
 
+	// KeyAuditConfig tracks changes of the effective configuration and plugins between agent starts
+	KeyAuditConfig = "audit.config"
+
+	// KeyAuditStateFile file where the configuration audit state is persisted
+	KeyAuditStateFile = "audit.state_file"
+
 	// KeyDebug enables debug messages
 	KeyDebug = "debug"
 
@@ -150,37 +316,266 @@ const (
 	// KeyDebugAPI enables debug messages for circonus API calls
 	KeyDebugAPI = "debug_api"
 
+	// KeyCORSOrigins origins allowed to make cross-origin requests to the local api (* for any)
+	KeyCORSOrigins = "cors_origins"
+
+	// KeyCounterState persists counter state (statsd counters not yet flushed) across agent restarts
+	KeyCounterState = "counter_state.enabled"
+
+	// KeyCounterStateFile file where the counter state is persisted
+	KeyCounterStateFile = "counter_state.state_file"
+
 	// KeyDebugDumpMetrics enables dumping metrics to a file as they are submitted to circonus
 	// it should contain a directory name where the user running circonus-agentd has write
 	// permissions. metrics will be dumped for each _successful_ request.
 	KeyDebugDumpMetrics = "debug_dump_metrics"
 
+	// KeyFailoverResources list of clustered service resources (see FailoverResource)
+	KeyFailoverResources = "failover_resources"
+
+	// KeyFlushArchiveCount number of recent flushes kept for /debug/flushes (0=no count limit)
+	KeyFlushArchiveCount = "flush_archive.count"
+
+	// KeyFlushArchiveMaxAge how long recent flushes are kept for /debug/flushes (0=no age limit)
+	KeyFlushArchiveMaxAge = "flush_archive.max_age"
+
+	// KeyFlushArchiveDir directory where archived flushes are persisted, kept in memory only when empty
+	KeyFlushArchiveDir = "flush_archive.dir"
+
 	// KeyListen primary address and port to listen on
 	KeyListen = "listen"
 
+	// KeyListenACLFile an external JSON file defining per-listener access settings (see etc/example_listen_acl.json)
+	KeyListenACLFile = "listen_acl_file"
+
+	// KeyHeartbeat emits a heartbeat (flush sequence, timestamp and restart count) with each full run
+	KeyHeartbeat = "heartbeat.enabled"
+
+	// KeyHeartbeatStateFile file where the agent restart count is persisted
+	KeyHeartbeatStateFile = "heartbeat.state_file"
+
+	// KeyWatchdog samples goroutine counts and detects builtin collector and plugin runs stuck
+	// past stuck_factor times their timeout, stacks are dumped to the log
+	KeyWatchdog = "watchdog.enabled"
+
+	// KeyWatchdogCollectorTimeout expected maximum run time of a builtin collector (plugins use plugin_timeout)
+	KeyWatchdogCollectorTimeout = "watchdog.collector_timeout"
+
+	// KeyWatchdogInterval how often the watchdog samples goroutines and checks for stuck runs
+	KeyWatchdogInterval = "watchdog.interval"
+
+	// KeyWatchdogRestart restart the subsystem of a stuck run (abandon the builtin collector run, kill the plugin)
+	KeyWatchdogRestart = "watchdog.restart"
+
+	// KeyWatchdogStuckFactor a run is stuck when running longer than stuck_factor times its timeout
+	KeyWatchdogStuckFactor = "watchdog.stuck_factor"
+
+	// KeyHooksFile an external JSON file defining local threshold rules and actions (see etc/example_hooks.json)
+	KeyHooksFile = "hooks_file"
+
 	// KeyListenSocket identifies one or more unix socket files to create
 	KeyListenSocket = "listen_socket"
 
+	// KeyListenSocketAPI serve the full local api (not only /write) on the unix socket(s)
+	KeyListenSocketAPI = "listen_socket_api"
+
+	// KeyListenSocketMode octal file permissions to apply to the unix socket(s) (e.g. 0660)
+	KeyListenSocketMode = "listen_socket_mode"
+
+	// KeyListenSocketOnly disable the tcp listener(s), only listen on the unix socket(s)
+	KeyListenSocketOnly = "listen_socket_only"
+
+	// KeyStatusUI serve the status page (/ui) showing collector, flush, reverse and error status
+	KeyStatusUI = "status_ui"
+
+	// KeyK8sNodeMode run as a kubernetes DaemonSet, node name and tags from the downward API
+	KeyK8sNodeMode = "k8s_node.enabled"
+
+	// KeyK8sNodeLabels node labels added as base tags in k8s node mode
+	KeyK8sNodeLabels = "k8s_node.labels"
+
+	// KeyK8sHostRoot mount point of the host's root filesystem in k8s node mode (host_proc etc. are relative to it)
+	KeyK8sHostRoot = "k8s_node.host_root"
+
+	// KeyLocalMode run without any Circonus API interaction (no check management, reverse or statsd group check)
+	KeyLocalMode = "local_mode"
+
 	// KeyLogLevel logging level (panic, fatal, error, warn, info, debug, disabled)
 	KeyLogLevel = "log.level"
 
 	// KeyLogPretty output formatted log lines (for running in foreground)
 	KeyLogPretty = "log.pretty"
 
+	// KeyLogAccess emit a structured access log line for each server request
+	KeyLogAccess = "log.access"
+
+	// KeyLogTraceSpans emit trace span log lines (OpenTelemetry/W3C trace context compatible ids) for /run handling
+	KeyLogTraceSpans = "log.trace_spans"
+
+	// KeyLogFile write log lines to a rotating file instead of stdout
+	KeyLogFile = "log.file"
+
+	// KeyLogFileMaxSize rotate the log file when it exceeds this size (MB, 0 disables)
+	KeyLogFileMaxSize = "log.file_max_size"
+
+	// KeyLogFileMaxAge rotate the log file when it is older than this duration (0 disables)
+	KeyLogFileMaxAge = "log.file_max_age"
+
+	// KeyLogFileMaxBackups number of rotated log files to retain (0 retains all)
+	KeyLogFileMaxBackups = "log.file_max_backups"
+
+	// KeyLogFileCompress gzip rotated log files
+	KeyLogFileCompress = "log.file_compress"
+
+	// KeyLogSystem also send log lines to the system log (syslog, or Windows Event Log)
+	KeyLogSystem = "log.system"
+
+	// KeyMaintenanceWindows scheduled windows (cron|duration|selectors) during which the
+	// selected builtin collectors and plugins are paused
+	KeyMaintenanceWindows = "maintenance_windows"
+
+	// KeyMaxPendingSeries maximum distinct series statsd and the receiver each accumulate
+	// between flushes, writes creating new series beyond the limit are dropped (0 no limit)
+	KeyMaxPendingSeries = "max_pending_series"
+
+	// KeyMetricTTL per source metric ttl (source:duration), series of the source not reported
+	// within the ttl are retired
+	KeyMetricTTL = "metric_ttl"
+
+	// KeyMetricTombstones emit a tombstone (null value) once for each retired series
+	KeyMetricTombstones = "metric_tombstones"
+
+	// KeyMetricAggregate per builtin collector aggregation of per-instance metrics
+	// (id:category[:functions]), the instances are replaced by sum/avg/min/max/count
+	KeyMetricAggregate = "metric_aggregate"
+
+	// KeyMetricTopK per builtin collector top K instance selection (id:category:k:metric),
+	// instances outside the top K by the metric are summed into an "other" instance
+	KeyMetricTopK = "metric_topk"
+
+	// KeyMetricMerge how a metric (same name and stream tags) emitted more than once within
+	// a flush is handled (last, sum, reject)
+	KeyMetricMerge = "metric_merge"
+
+	// KeyStateDir directory where the subsystem state (audit, heartbeat, counters, metric
+	// states, plugin bundle) is persisted, falls back to memory when not writable
+	KeyStateDir = "state_dir"
+
+	// KeyCacheDir directory where api results are cached, falls back to memory when not writable
+	KeyCacheDir = "cache_dir"
+
+	// KeySpoolDir directory where flushes are archived, falls back to memory when not writable
+	KeySpoolDir = "spool_dir"
+
+	// KeyStaleSourceAge age (time since a source last produced metrics) after which a
+	// source is stale, enables the source_age_seconds metrics (0=disabled)
+	KeyStaleSourceAge = "stale_source_age"
+
+	// KeyStaleSources mandatory sources, /health is degraded when any of them is stale
+	KeyStaleSources = "stale_sources"
+
+	// KeyTextMetricResend text metrics are only submitted when their value changes, or
+	// at least once per this interval (0=submitted with every flush)
+	KeyTextMetricResend = "text_metric_resend"
+
+	// KeyWriteDedupTTL how long the idempotency keys of /write requests are held,
+	// a retried write with the same key within the ttl is not applied again (0=keys ignored)
+	KeyWriteDedupTTL = "write_dedup_ttl"
+
+	// KeyGraphiteAddr address and port of the graphite plaintext protocol (tcp) listener (empty disables)
+	KeyGraphiteAddr = "graphite.addr"
+
+	// KeyGraphiteMapping rules mapping graphite metric paths to metric names with stream tags
+	KeyGraphiteMapping = "graphite.mapping"
+
+	// KeyOTLPAddr address and port of the OTLP (OpenTelemetry) metrics receiver (empty disables)
+	KeyOTLPAddr = "otlp.addr"
+
+	// KeyOTLPCertFile pem certificate file for the OTLP receiver (tls, required for OTLP/gRPC)
+	KeyOTLPCertFile = "otlp.cert_file"
+
+	// KeyOTLPKeyFile key for otlp.cert_file
+	KeyOTLPKeyFile = "otlp.key_file"
+
+	// KeyOutputTags per output metric name prefix and stream tag additions/removals
+	// (output:prefix:value, output:add:cat:val, output:remove:cat), outputs: check, prometheus
+	KeyOutputTags = "output_tags"
+
+	// KeyPluginBundleURL url (https or s3) of a signed tarball of plugins to install in the plugin directory,
+	// {role} is replaced with the plugin bundle role
+	KeyPluginBundleURL = "plugin_bundle.url"
+
+	// KeyPluginBundleRole role of the host, selects the plugin bundle (default: the applied profile name)
+	KeyPluginBundleRole = "plugin_bundle.role"
+
+	// KeyPluginBundlePublicKey ed25519 public key (base64) used to verify the plugin bundle signature
+	KeyPluginBundlePublicKey = "plugin_bundle.public_key"
+
+	// KeyPluginBundleInterval how often to check for an updated plugin bundle (0=only when the agent starts)
+	KeyPluginBundleInterval = "plugin_bundle.interval"
+
 	// KeyPluginDir plugin directory
 	KeyPluginDir = "plugin_dir"
 	// KeyPluginList is a list of explicit commands to run as plugins
 	KeyPluginList = "plugin_list"
 
+	// KeyPluginMaxCPUPercent cpu rate limit (percent of all cpus) of a plugin's process tree, windows only (0=unlimited)
+	KeyPluginMaxCPUPercent = "plugin_max_cpu_percent"
+
+	// KeyPluginMaxMemoryBytes committed memory limit of a plugin's process tree, windows only (0=unlimited)
+	KeyPluginMaxMemoryBytes = "plugin_max_memory_bytes"
+
+	// KeyPluginMaxOutputBytes max size of a plugin's output (per run, or per batch for long running plugins),
+	// plugins exceeding it are terminated (0=unlimited)
+	KeyPluginMaxOutputBytes = "plugin_max_output_bytes"
+
+	// KeyPluginTimeout plugin runs taking longer are terminated (0=no timeout)
+	KeyPluginTimeout = "plugin_timeout"
+
 	// KeyPluginTTLUnits plugin run ttl units
 	KeyPluginTTLUnits = "plugin_ttl_units"
 
+	// KeyProfile name of the profile to apply, when not set the first profile
+	// matching the host (hostname, environment variable, cloud tag) is applied
+	KeyProfile = "profile"
+
+	// KeyProfiles list of profiles (see Profile)
+	KeyProfiles = "profiles"
+
+	// KeyProxyTargets local exporters (name=url) served through /proxy/<name>
+	KeyProxyTargets = "proxy_targets"
+
+	// KeyRunDeltaEncoding negotiated delta encoded /run responses (dictionary of metric names and changes versus a prior response)
+	KeyRunDeltaEncoding = "run_delta_encoding"
+
+	// KeyRunMaxResponseBytes /run response size budget, larger responses are paginated (0=disabled)
+	KeyRunMaxResponseBytes = "run_max_response_bytes"
+
+	// KeyRuntimeBallast size of a heap ballast allocation (e.g. 256MiB), raises the heap size
+	// the GC targets for agents with very large metric sets (empty disables)
+	KeyRuntimeBallast = "runtime.ballast"
+
+	// KeyRuntimeGOGC garbage collection target percentage, as GOGC (0 leaves the runtime default)
+	KeyRuntimeGOGC = "runtime.gogc"
+
+	// KeyRuntimeMemoryLimit runtime soft memory limit, as GOMEMLIMIT (e.g. 1GiB, empty leaves the runtime default)
+	KeyRuntimeMemoryLimit = "runtime.memory_limit"
+
 	// KeyReverse indicates whether to use reverse connections
 	KeyReverse = "reverse.enabled"
 
+	// KeyReverseAdminKeyFile public key (ed25519, PEM) verifying administrative commands received over the reverse connection (empty=disabled)
+	KeyReverseAdminKeyFile = "reverse.admin_key_file"
+
 	// KeyReverseBrokerCAFile custom broker ca file
 	KeyReverseBrokerCAFile = "reverse.broker_ca_file"
 
+	// KeyReverseBrokerCARefresh how often to refresh the broker ca cert, reverse connections are re-established if it changed (0=disabled)
+	KeyReverseBrokerCARefresh = "reverse.broker_ca_refresh"
+
+	// KeyReverseDialPolicy address family policy used when dialing brokers (any|ipv4|ipv6|prefer-ipv4|prefer-ipv6)
+	KeyReverseDialPolicy = "reverse.dial_policy"
+
 	// KeyReverseMaxConnRetry how many times to retry a persistently failing broker connection. default 10, -1 = indefinitely
 	KeyReverseMaxConnRetry = "reverse.max_conn_retry"
 
@@ -205,9 +600,15 @@ const (
 	// KeyStatsdDisabled disables the default statsd listener
 	KeyStatsdDisabled = "statsd.disabled"
 
+	// KeyStatsdGroupBroker a specific broker ID to use when creating the statsd group check
+	KeyStatsdGroupBroker = "statsd.group.broker"
+
 	// KeyStatsdGroupCID circonus check bundle id for "group" metrics sent to statsd
 	KeyStatsdGroupCID = "statsd.group.check_bundle_id"
 
+	// KeyStatsdGroupCreate find or create the statsd group check when a group check bundle id is not supplied
+	KeyStatsdGroupCreate = "statsd.group.create"
+
 	// KeyStatsdGroupCounters operator for group counters (sum|average)
 	KeyStatsdGroupCounters = "statsd.group.counters"
 
@@ -220,6 +621,12 @@ const (
 	// KeyStatsdGroupSets operator for group sets (sum|average)
 	KeyStatsdGroupSets = "statsd.group.sets"
 
+	// KeyStatsdGroupTags a specific set of tags to use when creating the statsd group check
+	KeyStatsdGroupTags = "statsd.group.tags"
+
+	// KeyStatsdGroupTarget the target shared by the agents in a group, used to find or create the statsd group check
+	KeyStatsdGroupTarget = "statsd.group.target"
+
 	// KeyStatsdHostCategory "plugin" name to put metrics sent to host
 	KeyStatsdHostCategory = "statsd.host.category"
 
@@ -238,6 +645,21 @@ const (
 	// KeyStatsdMaxTCPConns set max statsd tcp connections
 	KeyStatsdMaxTCPConns = "statsd.max_tcp_connections"
 
+	// KeyStatsdTCPPort port for the statsd tcp listener (default: the statsd port)
+	KeyStatsdTCPPort = "statsd.tcp_port"
+
+	// KeyStatsdTCPFraming framing of metrics sent by statsd tcp clients (newline|length)
+	KeyStatsdTCPFraming = "statsd.tcp_framing"
+
+	// KeyStatsdTCPReadTimeout idle statsd tcp connections are closed after this duration (0 disables)
+	KeyStatsdTCPReadTimeout = "statsd.tcp_read_timeout"
+
+	// KeyStatsdQueuePolicy handling of received packets when the statsd packet queue is full (pushback|drop-oldest)
+	KeyStatsdQueuePolicy = "statsd.queue_policy"
+
+	// KeyStatsdQueueSize number of received packets queued for processing
+	KeyStatsdQueueSize = "statsd.queue_size"
+
 	// KeyCollectors defines the builtin collectors to enable
 	KeyCollectors = "collectors"
 	// KeyHostProc defines path builtins will use
@@ -250,6 +672,9 @@ const (
 	KeyHostVar = "host_var"
 	// KeyHostRun defines path builtins will use, if needed
 	KeyHostRun = "host_run"
+	// KeyWMIHostProcess collect from the host with the wmi builtins when running in a
+	// Windows HostProcess container (otherwise, only the classes available in the container)
+	KeyWMIHostProcess = "wmi_host_process"
 
 	// KeyDisableGzip disables gzip on http responses
 	KeyDisableGzip = "server.disable_gzip"
@@ -261,6 +686,14 @@ const (
 	// note: if not using reverse, this must be an IP address reachable by the broker
 	KeyCheckTarget = "check.target"
 
+	// KeyCheckTargetStrategy comma separated list of strategies used to derive the check
+	// target from the hostname, applied in order (hostname, fqdn, strip-domain, lowercase)
+	KeyCheckTargetStrategy = "check.target_strategy"
+
+	// KeyCheckTargetTemplate text/template used to derive the check target
+	// e.g. "{{lower .ShortName}}.example.com" (fields: Hostname, FQDN, ShortName)
+	KeyCheckTargetTemplate = "check.target_template"
+
 	// KeyCheckEnableNewMetrics toggles automatically enabling new metrics
 	KeyCheckEnableNewMetrics = "check.enable_new_metrics"
 	// KeyCheckMetricStateDir defines the path where check metric state will be maintained when --check-enable-new-metrics is turned on
@@ -307,9 +740,31 @@ const (
 	// KeyCheckBroker a specific broker ID to use when creating a new check bundle
 	KeyCheckBroker = "check.broker"
 
-	// KeyCheckTitle a specific title to use when creating a new check bundle
+	// KeyCheckBrokerTags allow-list of broker tags (comma separated, glob patterns e.g. region:us-east*)
+	// used to restrict the brokers selected when creating a new check bundle
+	KeyCheckBrokerTags = "check.broker_tags"
+
+	// KeyCheckBrokerMaxLatency max connect latency of brokers selected when creating a new check bundle
+	KeyCheckBrokerMaxLatency = "check.broker_max_latency"
+
+	// KeyCheckRefreshInterval how often the check and broker configurations are refreshed from the API
+	KeyCheckRefreshInterval = "check.refresh_interval"
+
+	// KeyCheckBrokerHosts static broker host address overrides (host=ip), used instead of resolving the host
+	KeyCheckBrokerHosts = "check.broker_hosts"
+
+	// KeyCheckBrokerDNSServers dns servers (ip[:port]) used to resolve broker hosts instead of the system resolver
+	KeyCheckBrokerDNSServers = "check.broker_dns_servers"
+
+	// KeyCheckTitle a specific title (text/template, see CheckInfo) to use when creating or updating a check bundle
 	KeyCheckTitle = "check.title"
 
+	// KeyCheckNotes notes (text/template, see CheckInfo) to use when creating or updating a check bundle
+	KeyCheckNotes = "check.notes"
+
+	// KeyCheckRoutes metric routing rules, sending matching metrics to other check bundles
+	KeyCheckRoutes = "check.routes"
+
 	// KeyCheckTags a specific set of tags to use when creating a new check bundle
 	KeyCheckTags = "check.tags"
 
@@ -337,6 +792,22 @@ var (
 // Validate verifies the required portions of the configuration
 func Validate() error {
 
+	if viper.GetBool(KeyLocalMode) {
+		applyLocalMode()
+	}
+
+	if viper.GetBool(KeyK8sNodeMode) {
+		if err := applyK8sNodeMode(); err != nil {
+			return err
+		}
+	}
+
+	applyStorageDirs()
+
+	if err := applyHostPaths(); err != nil {
+		return errors.Wrap(err, "host paths")
+	}
+
 	if apiRequired() {
 		err := validateAPIOptions()
 		if err != nil {
@@ -351,6 +822,102 @@ func Validate() error {
 		}
 	}
 
+	if err := validateMetricMergeOptions(); err != nil {
+		return errors.Wrap(err, "metric merge config")
+	}
+
+	if err := validateStaleSourceOptions(); err != nil {
+		return errors.Wrap(err, "stale source config")
+	}
+
+	if err := validateMetricTTLOptions(); err != nil {
+		return errors.Wrap(err, "metric ttl config")
+	}
+
+	if err := validateGraphiteOptions(); err != nil {
+		return errors.Wrap(err, "graphite config")
+	}
+
+	if err := validateMaintenanceWindowOptions(); err != nil {
+		return errors.Wrap(err, "maintenance window config")
+	}
+
+	if err := validateMetricAggregateOptions(); err != nil {
+		return errors.Wrap(err, "metric aggregate config")
+	}
+
+	if err := validateOTLPOptions(); err != nil {
+		return errors.Wrap(err, "otlp config")
+	}
+
+	if err := validateOutputTagOptions(); err != nil {
+		return errors.Wrap(err, "output tags config")
+	}
+
+	if err := validateMetricTopKOptions(); err != nil {
+		return errors.Wrap(err, "metric top k config")
+	}
+
+	if err := validateTextMetricResendOptions(); err != nil {
+		return errors.Wrap(err, "text metric resend config")
+	}
+
+	if err := validateWriteDedupOptions(); err != nil {
+		return errors.Wrap(err, "write dedup config")
+	}
+
+	if err := validateProxyTargetOptions(); err != nil {
+		return errors.Wrap(err, "proxy target config")
+	}
+
+	if err := validateFlushArchiveOptions(); err != nil {
+		return errors.Wrap(err, "flush archive config")
+	}
+
+	if err := validatePluginBundleOptions(); err != nil {
+		return errors.Wrap(err, "plugin bundle config")
+	}
+
+	if err := validatePluginTimeoutOptions(); err != nil {
+		return errors.Wrap(err, "plugin timeout config")
+	}
+
+	if err := validatePluginLimitOptions(); err != nil {
+		return errors.Wrap(err, "plugin limits config")
+	}
+
+	if err := validateListenSocketOptions(); err != nil {
+		return errors.Wrap(err, "listen socket config")
+	}
+
+	if err := validateCORSOptions(); err != nil {
+		return errors.Wrap(err, "cors config")
+	}
+
+	if err := validateWatchdogOptions(); err != nil {
+		return errors.Wrap(err, "watchdog config")
+	}
+
+	if err := resolveCheckTarget(); err != nil {
+		return errors.Wrap(err, "check target config")
+	}
+
+	if err := validateCheckBrokerOptions(); err != nil {
+		return errors.Wrap(err, "check broker config")
+	}
+
+	if err := validateCheckRefreshOptions(); err != nil {
+		return errors.Wrap(err, "check refresh config")
+	}
+
+	if err := validateCheckTemplateOptions(); err != nil {
+		return errors.Wrap(err, "check template config")
+	}
+
+	if err := validateCheckRouteOptions(); err != nil {
+		return errors.Wrap(err, "check routes config")
+	}
+
 	if viper.GetString(KeyCheckBundleID) != "" && viper.GetBool(KeyCheckCreate) {
 		return errors.New("use --check-create OR --check-id, they are mutually exclusive")
 	}
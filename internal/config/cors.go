@@ -0,0 +1,31 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// validateCORSOptions verifies the cors origins, * or scheme://host[:port]
+// (the Origin header sent by browsers, no path)
+func validateCORSOptions() error {
+	for _, origin := range viper.GetStringSlice(KeyCORSOrigins) {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return errors.Wrapf(err, "parsing cors origin (%s)", origin)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return errors.Errorf("invalid cors origin (%s), expected * or http[s]://host[:port]", origin)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateCORSOptions(t *testing.T) {
+	t.Log("Testing validateCORSOptions")
+
+	t.Log("valid")
+	{
+		for _, origin := range []string{"*", "http://localhost:8080", "https://dashboard.example.com"} {
+			viper.Set(KeyCORSOrigins, []string{origin})
+			if err := validateCORSOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", origin, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, origin := range []string{"localhost:8080", "ftp://example.com", "https://example.com/status", "https://", "http://exa mple.com"} {
+			viper.Set(KeyCORSOrigins, []string{origin})
+			if err := validateCORSOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", origin)
+			}
+		}
+	}
+
+	viper.Set(KeyCORSOrigins, []string{})
+}
@@ -31,6 +31,9 @@ const (
 	// APIApp defines the api app name associated with the api token key
 	APIApp = release.NAME
 
+	// APIMaxRetries number of times to retry failed api calls
+	APIMaxRetries = 3
+
 	// Reverse is false by default
 	Reverse = false
 
@@ -49,6 +52,27 @@ const (
 	// LogPretty colored/formatted output to stderr
 	LogPretty = false
 
+	// LogAccess request access logging disabled by default
+	LogAccess = false
+
+	// LogTraceSpans trace span logging disabled by default
+	LogTraceSpans = false
+
+	// LogFileMaxSize rotate log file at 10MB
+	LogFileMaxSize = 10
+
+	// LogFileMaxAge rotate log file daily
+	LogFileMaxAge = "24h"
+
+	// LogFileMaxBackups retain 7 rotated log files
+	LogFileMaxBackups = 7
+
+	// LogFileCompress rotated log files are not compressed by default
+	LogFileCompress = false
+
+	// LogSystem system log (syslog/event log) disabled by default
+	LogSystem = false
+
 	// UID to drop privileges to on start
 	UID = "nobody"
 
@@ -58,6 +82,96 @@ const (
 	// ReverseMaxConnRetry - how many times to retry persistently failing broker connection
 	ReverseMaxConnRetry = -1
 
+	// ListenSocketAPI - unix socket(s) only accept /write by default
+	ListenSocketAPI = false
+
+	// AuditConfig configuration change tracking disabled by default
+	AuditConfig = false
+
+	// Heartbeat agent heartbeat metrics disabled by default
+	Heartbeat = false
+
+	// Watchdog goroutine and stuck run watchdog disabled by default
+	Watchdog = false
+
+	// WatchdogCollectorTimeout builtin collector runs are expected to complete within a minute
+	WatchdogCollectorTimeout = "1m"
+
+	// WatchdogInterval sample goroutines and check for stuck runs every 30 seconds
+	WatchdogInterval = "30s"
+
+	// WatchdogRestart stuck runs are reported, not restarted
+	WatchdogRestart = false
+
+	// WatchdogStuckFactor runs are stuck after 3 times their timeout
+	WatchdogStuckFactor = 3
+
+	// CounterState counter state persistence disabled by default
+	CounterState = false
+
+	// ListenSocketOnly - tcp listener(s) are enabled by default
+	ListenSocketOnly = false
+
+	// StatusUI - status page disabled by default
+	StatusUI = false
+
+	// K8sNodeMode - not running as a kubernetes DaemonSet by default
+	K8sNodeMode = false
+
+	// K8sHostRoot - mount point of the host's root filesystem in the DaemonSet pod
+	K8sHostRoot = "/host"
+
+	// LocalMode - the Circonus API is used when required (check management, reverse, statsd group check)
+	LocalMode = false
+
+	// MaxPendingSeries - no limit on the series accumulated between flushes
+	MaxPendingSeries = uint(0)
+
+	// MetricMerge - the most recent value of a metric emitted more than once within a flush is used
+	MetricMerge = "last"
+
+	// MetricTombstones - retired series are not flagged
+	MetricTombstones = false
+
+	// StaleSourceAge - source staleness tracking disabled
+	StaleSourceAge = "0"
+
+	// FlushArchiveCount - recent flushes are not archived
+	FlushArchiveCount = 0
+
+	// FlushArchiveMaxAge - recent flushes are not archived
+	FlushArchiveMaxAge = "0"
+
+	// TextMetricResend - text metrics are submitted with every flush
+	TextMetricResend = "0"
+
+	// WriteDedupTTL - idempotency keys of /write requests are held for 5 minutes
+	WriteDedupTTL = "5m"
+
+	// ReverseBrokerCARefresh - how often the broker ca cert is refreshed
+	ReverseBrokerCARefresh = "24h"
+
+	// ReverseDialPolicy - address family policy used when dialing brokers
+	ReverseDialPolicy = "any"
+
+	// WMIHostProcess - wmi builtins only collect the classes available in a Windows container by default
+	WMIHostProcess = false
+
+	// RunDeltaEncoding - /run responses are not delta encoded by default
+	RunDeltaEncoding = false
+
+	// RunMaxResponseBytes - /run responses are not paginated by default
+	RunMaxResponseBytes = 0
+
+	// RuntimeBallast no heap ballast by default
+	RuntimeBallast = ""
+
+	// RuntimeGOGC leave gc percent at the runtime default (100, or GOGC env)
+	RuntimeGOGC = 0
+
+	// RuntimeMemoryLimit leave memory limit at the runtime default (none, or GOMEMLIMIT env)
+	RuntimeMemoryLimit = ""
+
 	// StatsdAddr to listen on
 	StatsdAddr = "localhost"
 
@@ -84,15 +198,52 @@ const (
 	// StatsdGroupSets defines how group counter metrics will be handled (average or sum)
 	StatsdGroupSets = "sum"
 
+	// StatsdGroupCreate defines if the statsd group check is found or created when a group check bundle id is not supplied
+	StatsdGroupCreate = false
+
+	// StatsdGroupBroker to use if creating the statsd group check, 'select' or '' will
+	// result in the first broker which supports httptrap checks being used
+	StatsdGroupBroker = "select"
+
+	// StatsdGroupTarget defines the target shared by the agents in a group, identifies the statsd group check
+	StatsdGroupTarget = "statsd-group"
+
 	// StatsdEnableTCP defines if the statsd tcp listener is enabled
 	StatsdEnableTCP = false
 
 	// StatsdMaxTCPConns defines the max statsd tcp client connections
 	StatsdMaxTCPConns = uint(250)
 
+	// StatsdTCPFraming statsd tcp clients send newline terminated metrics
+	StatsdTCPFraming = "newline"
+
+	// StatsdTCPReadTimeout idle statsd tcp connections are closed after this duration
+	StatsdTCPReadTimeout = "1m"
+
+	// StatsdQueuePolicy - readers wait for queue space when the statsd packet queue is full
+	StatsdQueuePolicy = "pushback"
+
+	// StatsdQueueSize defines the number of received statsd packets queued for processing
+	StatsdQueueSize = uint(1000)
+
 	// MetricNameSeparator defines character used to delimit metric name parts
 	MetricNameSeparator = "`"
 
+	// PluginMaxOutputBytes plugins emitting more than 32MB of output (per run) are terminated
+	PluginMaxOutputBytes = 32 * 1024 * 1024
+
+	// PluginMaxMemoryBytes plugin process trees are not limited by memory
+	PluginMaxMemoryBytes = int64(0)
+
+	// PluginMaxCPUPercent plugin process trees are not limited by cpu rate
+	PluginMaxCPUPercent = 0
+
+	// PluginTimeout plugin runs are not terminated (long running plugins do not exit)
+	PluginTimeout = "0"
+
+	// PluginBundleInterval check for an updated plugin bundle hourly
+	PluginBundleInterval = "1h"
+
 	// PluginTTLUnits defines the default TTL units for plugins with TTLs
 	// e.g. plugin_ttl30s.sh (30s ttl) plugin_ttl45.sh (would get default ttl units, e.g. 45s)
 	PluginTTLUnits = "s" // seconds
@@ -115,6 +266,12 @@ const (
 	// 3. Responds within reverse.brokerMaxResponseTime
 	CheckBroker = "select"
 
+	// CheckBrokerMaxLatency - brokers are not limited by connect latency (up to 10s)
+	CheckBrokerMaxLatency = "0"
+
+	// CheckRefreshInterval how often the check and broker configurations are refreshed from the API
+	CheckRefreshInterval = "5m"
+
 	// CheckTags to use if creating a check (comma separated list)
 	CheckTags = ""
 
@@ -150,6 +307,14 @@ var (
 	// OS specific - see init() below
 	Collectors = []string{}
 
+	// K8sNodeLabels defines the node labels added as base tags in k8s node mode
+	K8sNodeLabels = []string{
+		"topology.kubernetes.io/region",
+		"topology.kubernetes.io/zone",
+		"node.kubernetes.io/instance-type",
+		"kubernetes.io/os",
+	}
+
 	// EtcPath returns the default etc directory within base directory
 	EtcPath = "" // (e.g. /opt/circonus/agent/etc)
 
@@ -167,6 +332,18 @@ var (
 	// and be owned by the user running circonus-agentd (i.e. 'nobody').
 	CheckMetricStatePath = "" // (e.g. /opt/circonus/agent/state)
 
+	// APICacheDir returns the default api cache directory, within the state directory
+	APICacheDir = "" // (e.g. /opt/circonus/agent/state/api_cache)
+
+	// AuditStateFile returns the default configuration audit state file, within the state directory
+	AuditStateFile = "" // (e.g. /opt/circonus/agent/state/audit.json)
+
+	// HeartbeatStateFile returns the default heartbeat state file, within the state directory
+	HeartbeatStateFile = "" // (e.g. /opt/circonus/agent/state/heartbeat.json)
+
+	// CounterStateFile returns the default counter state file, within the state directory
+	CounterStateFile = "" // (e.g. /opt/circonus/agent/state/counters.json)
+
 	// CheckMetricFilters defines default filter to be used with new check creation
 	CheckMetricFilters = [][]string{{"deny", "^$", ""}, {"allow", "^.+$", ""}}
 	// CheckMetricFilterFile defines an external file (json) with metric filter definitions
@@ -213,6 +390,10 @@ func init() {
 
 	EtcPath = filepath.Join(BasePath, "etc")
 	CheckMetricStatePath = filepath.Join(BasePath, "state")
+	APICacheDir = filepath.Join(CheckMetricStatePath, "api_cache")
+	AuditStateFile = filepath.Join(CheckMetricStatePath, "audit.json")
+	HeartbeatStateFile = filepath.Join(CheckMetricStatePath, "heartbeat.json")
+	CounterStateFile = filepath.Join(CheckMetricStatePath, "counters.json")
 	PluginPath = filepath.Join(BasePath, "plugins")
 	SSLCertFile = filepath.Join(EtcPath, release.NAME+".pem")
 	SSLKeyFile = filepath.Join(EtcPath, release.NAME+".key")
@@ -236,6 +417,42 @@ func init() {
 			"procfs/proto",
 			"procfs/vm",
 		}
+	case "freebsd":
+		Collectors = []string{
+			"freebsd/cpu",
+			"freebsd/disk",
+			"generic/fs",
+			"freebsd/if",
+			"generic/load",
+			"generic/proto",
+			"freebsd/vm",
+		}
+	case "darwin":
+		Collectors = []string{
+			"darwin/cpu",
+			"darwin/disk",
+			"generic/fs",
+			"darwin/if",
+			"generic/load",
+			"darwin/thermal",
+			"darwin/vm",
+		}
+	case "aix":
+		Collectors = []string{
+			"aix/cpu",
+			"aix/disk",
+			"aix/if",
+			"aix/vm",
+		}
+	case "solaris", "illumos":
+		Collectors = []string{
+			"illumos/cpu",
+			"generic/fs",
+			"illumos/if",
+			"illumos/vm",
+			"illumos/zfs",
+			"illumos/zones",
+		}
 	case "windows":
 		Collectors = []string{
 			"wmi/cache",
@@ -0,0 +1,44 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// FlushArchiveMaxAge returns the flush archive max age, 0 when flushes are not limited by age
+func FlushArchiveMaxAge() (time.Duration, error) {
+	maxAge := viper.GetString(KeyFlushArchiveMaxAge)
+	if maxAge == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(maxAge)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing flush archive max age")
+	}
+	if d < 0 {
+		return 0, errors.Errorf("invalid flush archive max age (%s)", maxAge)
+	}
+	return d, nil
+}
+
+// validateFlushArchiveOptions verifies the flush archive count and max age
+func validateFlushArchiveOptions() error {
+	if n := viper.GetInt(KeyFlushArchiveCount); n < 0 {
+		return errors.Errorf("invalid flush archive count (%d)", n)
+	}
+	maxAge, err := FlushArchiveMaxAge()
+	if err != nil {
+		return err
+	}
+	if viper.GetString(KeyFlushArchiveDir) != "" && viper.GetInt(KeyFlushArchiveCount) == 0 && maxAge == 0 {
+		return errors.New("flush archive dir requires a flush archive count or max age")
+	}
+	return nil
+}
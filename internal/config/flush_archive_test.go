@@ -0,0 +1,64 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateFlushArchiveOptions(t *testing.T) {
+	t.Log("Testing validateFlushArchiveOptions")
+
+	defer func() {
+		viper.Set(KeyFlushArchiveCount, 0)
+		viper.Set(KeyFlushArchiveMaxAge, "")
+		viper.Set(KeyFlushArchiveDir, "")
+	}()
+
+	t.Log("valid")
+	{
+		for _, a := range []string{"", "0", "15m", "1h"} {
+			viper.Set(KeyFlushArchiveMaxAge, a)
+			if err := validateFlushArchiveOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", a, err)
+			}
+		}
+	}
+
+	t.Log("invalid max age")
+	{
+		for _, a := range []string{"15", "-5m", "soon"} {
+			viper.Set(KeyFlushArchiveMaxAge, a)
+			if err := validateFlushArchiveOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", a)
+			}
+		}
+		viper.Set(KeyFlushArchiveMaxAge, "")
+	}
+
+	t.Log("invalid count")
+	{
+		viper.Set(KeyFlushArchiveCount, -1)
+		if err := validateFlushArchiveOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+		viper.Set(KeyFlushArchiveCount, 0)
+	}
+
+	t.Log("dir w/o count or max age")
+	{
+		viper.Set(KeyFlushArchiveDir, "/tmp")
+		if err := validateFlushArchiveOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+		viper.Set(KeyFlushArchiveCount, 10)
+		if err := validateFlushArchiveOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// GraphiteMapping defines a rule mapping graphite metric paths to a metric
+// name with stream tags. Pattern nodes are separated by dots, a * node
+// matches any one node of a path. $1..$n in the name and tag values are
+// replaced with the nodes matched by the wildcards.
+type GraphiteMapping struct {
+	Pattern []string // pattern nodes
+	Name    string
+	Tags    []string // cat:val
+}
+
+var graphiteCaptureRx = regexp.MustCompile(`\$([0-9]+)`)
+
+// GraphiteMappings returns the graphite mapping rules, in the order they are
+// applied, from the mapping settings (pattern name [cat:val ...])
+func GraphiteMappings() ([]GraphiteMapping, error) {
+	settings := viper.GetStringSlice(KeyGraphiteMapping)
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	mappings := make([]GraphiteMapping, 0, len(settings))
+	for _, setting := range settings {
+		fields := strings.Fields(setting)
+		if len(fields) < 2 {
+			return nil, errors.Errorf("invalid graphite mapping (%s), expected pattern name [cat:val ...]", setting)
+		}
+		m := GraphiteMapping{
+			Pattern: strings.Split(fields[0], "."),
+			Name:    fields[1],
+			Tags:    fields[2:],
+		}
+		wildcards := 0
+		for _, node := range m.Pattern {
+			if node == "" {
+				return nil, errors.Errorf("invalid graphite mapping pattern (%s), empty node", fields[0])
+			}
+			if node == "*" {
+				wildcards++
+			} else if strings.Contains(node, "*") {
+				return nil, errors.Errorf("invalid graphite mapping pattern (%s), * must be a whole node", fields[0])
+			}
+		}
+		refs := []string{m.Name}
+		for _, tag := range m.Tags {
+			if t := strings.SplitN(tag, ":", 2); len(t) != 2 || t[0] == "" || t[1] == "" {
+				return nil, errors.Errorf("invalid graphite mapping tag (%s), expected cat:val", tag)
+			}
+			refs = append(refs, tag)
+		}
+		for _, ref := range refs {
+			for _, capture := range graphiteCaptureRx.FindAllStringSubmatch(ref, -1) {
+				if n, err := strconv.Atoi(capture[1]); err != nil || n < 1 || n > wildcards {
+					return nil, errors.Errorf("invalid graphite mapping (%s), %s does not match a wildcard", setting, capture[0])
+				}
+			}
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// validateGraphiteOptions verifies the graphite listener address and mapping rules
+func validateGraphiteOptions() error {
+	addr := viper.GetString(KeyGraphiteAddr)
+	if addr == "" {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return errors.Wrapf(err, "invalid graphite address (%s)", addr)
+	}
+
+	_, err := GraphiteMappings()
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateGraphiteOptions(t *testing.T) {
+	t.Log("Testing validateGraphiteOptions")
+
+	defer viper.Reset()
+
+	t.Log("not set")
+	{
+		viper.Reset()
+		if err := validateGraphiteOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid")
+	{
+		viper.Reset()
+		viper.Set(KeyGraphiteAddr, ":2003")
+		viper.Set(KeyGraphiteMapping, []string{
+			"servers.*.cpu.* cpu host:$1 cpu:$2",
+			"app.requests requests",
+		})
+		if err := validateGraphiteOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		mappings, err := GraphiteMappings()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := []GraphiteMapping{
+			{Pattern: []string{"servers", "*", "cpu", "*"}, Name: "cpu", Tags: []string{"host:$1", "cpu:$2"}},
+			{Pattern: []string{"app", "requests"}, Name: "requests", Tags: []string{}},
+		}
+		if !reflect.DeepEqual(mappings, expect) {
+			t.Fatalf("unexpected mappings %v", mappings)
+		}
+	}
+
+	tt := []struct {
+		name    string
+		addr    string
+		mapping []string
+		expect  string
+	}{
+		{"bad address", "2003", nil, "invalid graphite address (2003)"},
+		{"no name", ":2003", []string{"servers.*.cpu"}, "invalid graphite mapping (servers.*.cpu), expected pattern name [cat:val ...]"},
+		{"empty node", ":2003", []string{"servers..cpu cpu"}, "invalid graphite mapping pattern (servers..cpu), empty node"},
+		{"partial wildcard", ":2003", []string{"servers.web*.cpu cpu"}, "invalid graphite mapping pattern (servers.web*.cpu), * must be a whole node"},
+		{"bad tag", ":2003", []string{"servers.*.cpu cpu host"}, "invalid graphite mapping tag (host), expected cat:val"},
+		{"bad capture", ":2003", []string{"servers.*.cpu cpu host:$2"}, "invalid graphite mapping (servers.*.cpu cpu host:$2), $2 does not match a wildcard"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid (%s)", tst.name)
+		viper.Reset()
+		viper.Set(KeyGraphiteAddr, tst.addr)
+		viper.Set(KeyGraphiteMapping, tst.mapping)
+		err := validateGraphiteOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.HasPrefix(err.Error(), tst.expect) {
+			t.Fatalf("unexpected error (%s)", err)
+		}
+	}
+}
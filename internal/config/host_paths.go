@@ -0,0 +1,66 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// hostPath is a host filesystem location used by the builtin collectors
+type hostPath struct {
+	key        string // setting with the path
+	defaultVal string // path on the host
+	env        string // environment variable used by the generic (gopsutil) collectors
+}
+
+var hostPaths = []hostPath{
+	{KeyHostProc, defaults.HostProc, "HOST_PROC"},
+	{KeyHostSys, defaults.HostSys, "HOST_SYS"},
+	{KeyHostEtc, defaults.HostEtc, "HOST_ETC"},
+	{KeyHostVar, defaults.HostVar, "HOST_VAR"},
+	{KeyHostRun, defaults.HostRun, "HOST_RUN"},
+}
+
+// applyHostPaths verifies the host paths and makes them available to all of
+// the builtins. The procfs, edge and security collectors use the settings,
+// the generic collectors read the environment variables - a path set in the
+// configuration file (e.g. host_proc: /host/proc when the host's /proc is
+// mounted in a container) is exported so the generic collectors use it too.
+func applyHostPaths() error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	for _, hp := range hostPaths {
+		p := viper.GetString(hp.key)
+		if p == "" {
+			p = hp.defaultVal
+			viper.Set(hp.key, p)
+		}
+		if p == hp.defaultVal {
+			continue
+		}
+
+		fi, err := os.Stat(p)
+		if err != nil {
+			return errors.Wrapf(err, "%s (%s)", hp.key, p)
+		}
+		if !fi.IsDir() {
+			return errors.Errorf("%s (%s) not a directory", hp.key, p)
+		}
+
+		if err := os.Setenv(hp.env, p); err != nil {
+			return errors.Wrapf(err, "setting %s", hp.env)
+		}
+	}
+
+	return nil
+}
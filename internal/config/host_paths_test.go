@@ -0,0 +1,66 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestApplyHostPaths(t *testing.T) {
+	t.Log("Testing applyHostPaths")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := ioutil.TempDir("", "hostpaths")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func() {
+		for _, hp := range hostPaths {
+			viper.Set(hp.key, nil)
+		}
+		os.Unsetenv("HOST_PROC")
+	}()
+
+	t.Log("\tdefaults")
+	{
+		viper.Set(KeyHostProc, "")
+		if err := applyHostPaths(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if p := viper.GetString(KeyHostProc); p != defaults.HostProc {
+			t.Fatalf("unexpected host proc (%s)", p)
+		}
+	}
+
+	t.Log("\tmounted host proc")
+	{
+		viper.Set(KeyHostProc, dir)
+		if err := applyHostPaths(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if p := os.Getenv("HOST_PROC"); p != dir {
+			t.Fatalf("unexpected HOST_PROC (%s)", p)
+		}
+	}
+
+	t.Log("\tinvalid")
+	{
+		viper.Set(KeyHostProc, filepath.Join(dir, "missing"))
+		if err := applyHostPaths(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
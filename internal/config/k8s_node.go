@@ -0,0 +1,179 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// environment variables set from the downward API in the DaemonSet pod spec, e.g.
+//
+//	env:
+//	- name: NODE_NAME
+//	  valueFrom: {fieldRef: {fieldPath: spec.nodeName}}
+const (
+	k8sNodeNameEnv     = "NODE_NAME"
+	k8sPodNamespaceEnv = "POD_NAMESPACE"
+	k8sTimeout         = 5 * time.Second
+)
+
+var (
+	k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	k8sNodeLabelsFor     = fetchK8sNodeLabels
+
+	// k8sNodeDisabledCollectors are not meaningful in a DaemonSet pod (device access)
+	k8sNodeDisabledCollectors = []string{"edge/", "security/"}
+)
+
+// applyK8sNodeMode configures the agent to run as a Kubernetes DaemonSet, one
+// agent per node:
+//   - base tags k8s_node:<node> and k8s_namespace:<namespace> (downward API)
+//     and the node labels listed in --k8s-node-labels (Kubernetes API)
+//   - check target is the node name (the pod's hostname is the pod name)
+//   - host_proc, host_sys, etc. are under the host root (e.g. /host/proc)
+//   - collectors which are not meaningful in a pod are disabled
+//
+// Settings changed from their default are left as-is.
+func applyK8sNodeMode() error {
+	nodeName := os.Getenv(k8sNodeNameEnv)
+	if nodeName == "" {
+		return errors.Errorf("k8s node mode requires the node name in %s (downward API spec.nodeName)", k8sNodeNameEnv)
+	}
+
+	tagList := []string{"k8s_node:" + nodeName}
+	if ns := os.Getenv(k8sPodNamespaceEnv); ns != "" {
+		tagList = append(tagList, "k8s_namespace:"+ns)
+	}
+	if keys := viper.GetStringSlice(KeyK8sNodeLabels); len(keys) > 0 {
+		labels, err := k8sNodeLabelsFor(nodeName)
+		if err != nil {
+			log.Warn().Err(err).Str("node", nodeName).Msg("k8s node mode, unable to retrieve node labels")
+		}
+		for _, key := range keys {
+			if v := labels[key]; v != "" {
+				tagList = append(tagList, key+":"+v)
+			}
+		}
+	}
+	checkTags := viper.GetString(KeyCheckTags)
+	if checkTags != "" {
+		checkTags += ","
+	}
+	viper.Set(KeyCheckTags, checkTags+strings.Join(tagList, ","))
+
+	if viper.GetString(KeyCheckTarget) == defaults.CheckTarget {
+		viper.Set(KeyCheckTarget, nodeName)
+	}
+
+	if root := viper.GetString(KeyK8sHostRoot); root != "" {
+		for _, hp := range hostPaths {
+			if v := viper.GetString(hp.key); v != "" && v != hp.defaultVal {
+				continue
+			}
+			// only the host paths mounted in the pod (e.g. /host/proc and /host/sys)
+			p := filepath.Join(root, hp.defaultVal)
+			if _, err := os.Stat(p); err != nil {
+				continue
+			}
+			viper.Set(hp.key, p)
+		}
+	}
+
+	collectors := viper.GetStringSlice(KeyCollectors)
+	enabled := make([]string, 0, len(collectors))
+	for _, c := range collectors {
+		disabled := false
+		for _, prefix := range k8sNodeDisabledCollectors {
+			if strings.HasPrefix(c, prefix) {
+				disabled = true
+				break
+			}
+		}
+		if disabled {
+			log.Warn().Str("collector", c).Msg("k8s node mode, disabling collector")
+			continue
+		}
+		enabled = append(enabled, c)
+	}
+	viper.Set(KeyCollectors, enabled)
+
+	log.Info().Str("node", nodeName).Strs("tags", tagList).Msg("k8s node mode")
+	return nil
+}
+
+// fetchK8sNodeLabels retrieves the labels of the node from the Kubernetes API
+// with the pod's service account (requires get on nodes)
+func fetchK8sNodeLabels(nodeName string) (map[string]string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a kubernetes cluster (KUBERNETES_SERVICE_HOST/PORT)")
+	}
+
+	token, err := ioutil.ReadFile(filepath.Join(k8sServiceAccountDir, "token"))
+	if err != nil {
+		return nil, errors.Wrap(err, "service account token")
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(k8sServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "service account ca")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("service account ca, no certificates")
+	}
+
+	client := &http.Client{
+		Timeout:   k8sTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+	u := "https://" + net.JoinHostPort(host, port) + "/api/v1/nodes/" + url.PathEscape(nodeName)
+	return getK8sNodeLabels(client, u, strings.TrimSpace(string(token)))
+}
+
+func getK8sNodeLabels(client *http.Client, u, token string) (map[string]string, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "node request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("node request, %s", resp.Status)
+	}
+
+	var node struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return nil, errors.Wrap(err, "parsing node")
+	}
+
+	return node.Metadata.Labels, nil
+}
@@ -0,0 +1,153 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestApplyK8sNodeMode(t *testing.T) {
+	t.Log("Testing applyK8sNodeMode")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	keys := []string{KeyK8sNodeLabels, KeyK8sHostRoot, KeyCheckTags, KeyCheckTarget, KeyCollectors, KeyHostProc, KeyHostSys, KeyHostEtc, KeyHostVar, KeyHostRun}
+	envs := []string{k8sNodeNameEnv, k8sPodNamespaceEnv}
+	defer func() {
+		for _, key := range keys {
+			viper.Set(key, nil)
+		}
+		for _, env := range envs {
+			os.Unsetenv(env)
+		}
+		k8sNodeLabelsFor = fetchK8sNodeLabels
+	}()
+
+	root, err := ioutil.TempDir("", "k8s")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"proc", "sys"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0700); err != nil {
+			t.Fatalf("creating dir (%s)", err)
+		}
+	}
+
+	t.Log("\tno node name")
+	{
+		os.Unsetenv(k8sNodeNameEnv)
+		if err := applyK8sNodeMode(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tdaemonset")
+	{
+		os.Setenv(k8sNodeNameEnv, "node1")
+		os.Setenv(k8sPodNamespaceEnv, "monitoring")
+		k8sNodeLabelsFor = func(string) (map[string]string, error) {
+			return map[string]string{"topology.kubernetes.io/zone": "us-east-1a", "other": "x"}, nil
+		}
+		viper.Set(KeyK8sNodeLabels, []string{"topology.kubernetes.io/zone", "kubernetes.io/os"})
+		viper.Set(KeyK8sHostRoot, root)
+		viper.Set(KeyCheckTags, "env:prod")
+		viper.Set(KeyCheckTarget, defaults.CheckTarget)
+		viper.Set(KeyCollectors, []string{"cpu", "edge/modbus", "security/fim"})
+		viper.Set(KeyHostProc, defaults.HostProc)
+		viper.Set(KeyHostSys, "/custom/sys")
+		viper.Set(KeyHostEtc, defaults.HostEtc)
+
+		if err := applyK8sNodeMode(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		if tags := viper.GetString(KeyCheckTags); tags != "env:prod,k8s_node:node1,k8s_namespace:monitoring,topology.kubernetes.io/zone:us-east-1a" {
+			t.Fatalf("unexpected tags (%s)", tags)
+		}
+		if target := viper.GetString(KeyCheckTarget); target != "node1" {
+			t.Fatalf("unexpected target (%s)", target)
+		}
+		if p := viper.GetString(KeyHostProc); p != filepath.Join(root, "proc") {
+			t.Fatalf("unexpected host proc (%s)", p)
+		}
+		if p := viper.GetString(KeyHostSys); p != "/custom/sys" {
+			t.Fatalf("unexpected host sys (%s)", p)
+		}
+		if p := viper.GetString(KeyHostEtc); p != defaults.HostEtc {
+			t.Fatalf("unexpected host etc, not mounted (%s)", p)
+		}
+		if c := viper.GetStringSlice(KeyCollectors); !reflect.DeepEqual(c, []string{"cpu"}) {
+			t.Fatalf("unexpected collectors (%v)", c)
+		}
+	}
+
+	t.Log("\tlabels unavailable")
+	{
+		k8sNodeLabelsFor = func(string) (map[string]string, error) {
+			return nil, errors.New("forbidden")
+		}
+		os.Unsetenv(k8sPodNamespaceEnv)
+		viper.Set(KeyCheckTags, "")
+		viper.Set(KeyCheckTarget, "mytarget")
+
+		if err := applyK8sNodeMode(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if tags := viper.GetString(KeyCheckTags); tags != "k8s_node:node1" {
+			t.Fatalf("unexpected tags (%s)", tags)
+		}
+		if target := viper.GetString(KeyCheckTarget); target != "mytarget" {
+			t.Fatalf("unexpected target (%s)", target)
+		}
+	}
+}
+
+func TestGetK8sNodeLabels(t *testing.T) {
+	t.Log("Testing getK8sNodeLabels")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/nodes/node1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"kind":"Node","metadata":{"name":"node1","labels":{"kubernetes.io/os":"linux"}}}`))
+	}))
+	defer ts.Close()
+
+	t.Log("\tvalid")
+	{
+		labels, err := getK8sNodeLabels(ts.Client(), ts.URL+"/api/v1/nodes/node1", "token")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if labels["kubernetes.io/os"] != "linux" {
+			t.Fatalf("unexpected labels (%v)", labels)
+		}
+	}
+
+	t.Log("\tunauthorized")
+	{
+		if _, err := getK8sNodeLabels(ts.Client(), ts.URL+"/api/v1/nodes/node1", "bad"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
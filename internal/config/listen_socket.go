@@ -0,0 +1,50 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ParseSocketMode parses an octal file mode (e.g. "0660") for unix socket files,
+// an empty mode returns 0 (leave permissions as created)
+func ParseSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid socket mode (%s)", mode)
+	}
+	if m == 0 || m > 0777 {
+		return 0, errors.Errorf("invalid socket mode (%s), must be between 0001 and 0777", mode)
+	}
+	return os.FileMode(m), nil
+}
+
+func validateListenSocketOptions() error {
+	if _, err := ParseSocketMode(viper.GetString(KeyListenSocketMode)); err != nil {
+		return err
+	}
+
+	if !viper.GetBool(KeyListenSocketOnly) {
+		return nil
+	}
+
+	if len(viper.GetStringSlice(KeyListenSocket)) == 0 {
+		return errors.New("socket only requires at least one --listen-socket")
+	}
+
+	if viper.GetBool(KeyReverse) {
+		return errors.New("socket only is not compatible with reverse, reverse requires a tcp listener")
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestParseSocketMode(t *testing.T) {
+	t.Log("Testing ParseSocketMode")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	tests := []struct {
+		mode      string
+		expect    os.FileMode
+		shouldErr bool
+	}{
+		{"", 0, false},
+		{"0660", 0660, false},
+		{"600", 0600, false},
+		{"0777", 0777, false},
+		{"0", 0, true},
+		{"1777", 0, true},
+		{"0990", 0, true},
+		{"foo", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Logf("\tmode '%s'", test.mode)
+		m, err := ParseSocketMode(test.mode)
+		if test.shouldErr {
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if m != test.expect {
+			t.Fatalf("expected %o, got %o", test.expect, m)
+		}
+	}
+}
+
+func TestValidateListenSocketOptions(t *testing.T) {
+	t.Log("Testing validateListenSocketOptions")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tdefaults")
+	{
+		viper.Reset()
+		if err := validateListenSocketOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("\tinvalid mode")
+	{
+		viper.Reset()
+		viper.Set(KeyListenSocketMode, "abc")
+		if err := validateListenSocketOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tonly, no sockets")
+	{
+		viper.Reset()
+		viper.Set(KeyListenSocketOnly, true)
+		if err := validateListenSocketOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tonly, reverse")
+	{
+		viper.Reset()
+		viper.Set(KeyListenSocketOnly, true)
+		viper.Set(KeyListenSocket, []string{"/tmp/foo.sock"})
+		viper.Set(KeyReverse, true)
+		if err := validateListenSocketOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tonly, valid")
+	{
+		viper.Reset()
+		viper.Set(KeyListenSocketOnly, true)
+		viper.Set(KeyListenSocket, []string{"/tmp/foo.sock"})
+		viper.Set(KeyListenSocketMode, "0660")
+		if err := validateListenSocketOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	viper.Reset()
+}
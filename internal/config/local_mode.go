@@ -0,0 +1,34 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// applyLocalMode disables the settings which require the Circonus API, so the
+// agent only serves metrics locally (listener, statsd, builtins, plugins, prom)
+// e.g. in air-gapped environments where the agent is scraped by other tooling.
+// Settings which are disabled are logged rather than failing validation, so a
+// config shared with api enabled agents can be used as-is.
+func applyLocalMode() {
+	for _, key := range []string{KeyReverse, KeyCheckCreate, KeyCheckEnableNewMetrics, KeyStatsdGroupCreate} {
+		if viper.GetBool(key) {
+			log.Warn().Str("setting", key).Msg("local mode, disabling setting which requires the Circonus API")
+		}
+		viper.Set(key, false)
+	}
+
+	for _, key := range []string{KeyCheckBundleID, KeyStatsdGroupCID} {
+		if viper.GetString(key) != "" {
+			log.Warn().Str("setting", key).Msg("local mode, disabling setting which requires the Circonus API")
+		}
+		viper.Set(key, "")
+	}
+
+	log.Info().Msg("local mode, Circonus API disabled")
+}
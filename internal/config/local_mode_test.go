@@ -0,0 +1,52 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestLocalMode(t *testing.T) {
+	t.Log("Testing local mode")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	apiKey := viper.GetString(KeyAPITokenKey)
+	defer func() {
+		viper.Set(KeyLocalMode, false)
+		viper.Set(KeyAPITokenKey, apiKey)
+	}()
+
+	viper.Set(KeyLocalMode, true)
+	viper.Set(KeyAPITokenKey, "")
+	viper.Set(KeyReverse, true)
+	viper.Set(KeyCheckCreate, true)
+	viper.Set(KeyCheckEnableNewMetrics, true)
+	viper.Set(KeyStatsdGroupCreate, true)
+	viper.Set(KeyCheckBundleID, "123")
+	viper.Set(KeyStatsdGroupCID, "456")
+
+	if err := Validate(); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	for _, key := range []string{KeyReverse, KeyCheckCreate, KeyCheckEnableNewMetrics, KeyStatsdGroupCreate} {
+		if viper.GetBool(key) {
+			t.Fatalf("expected %s disabled", key)
+		}
+	}
+	for _, key := range []string{KeyCheckBundleID, KeyStatsdGroupCID} {
+		if viper.GetString(key) != "" {
+			t.Fatalf("expected %s empty, got (%s)", key, viper.GetString(key))
+		}
+	}
+	if apiRequired() {
+		t.Fatal("expected api not required")
+	}
+}
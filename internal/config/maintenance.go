@@ -0,0 +1,242 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"encoding/csv"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// maxMaintenanceDuration limits how far back a window's start is searched
+const maxMaintenanceDuration = 7 * 24 * time.Hour
+
+// MaintenanceWindow is a scheduled window during which the selected builtin
+// collectors and plugins are paused. The window starts at each time matching
+// the cron schedule (minute hour day-of-month month day-of-week, local time)
+// and lasts for the duration.
+type MaintenanceWindow struct {
+	Spec      string
+	Duration  time.Duration
+	Selectors []string // glob patterns matched against builtin ids and plugin names
+	minutes   uint64
+	hours     uint64
+	days      uint64
+	months    uint64
+	weekdays  uint64
+	anyDay    bool // day-of-month is *
+	anyDOW    bool // day-of-week is *
+}
+
+// cronField defines the range of a cron schedule field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 and 7 are sunday
+}
+
+// MaintenanceWindows returns the maintenance windows from the settings
+// ("cron|duration|selector selector...", e.g. "0 2 * * 6|3h|disk diskstats smart*")
+func MaintenanceWindows() ([]MaintenanceWindow, error) {
+	var settings []string
+	if s, ok := viper.Get(KeyMaintenanceWindows).(string); ok {
+		if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+			// command line, viper returns the string array flag (not split on
+			// commas, cron lists) as its csv string representation
+			r := csv.NewReader(strings.NewReader(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")))
+			r.LazyQuotes = true
+			list, err := r.Read()
+			if err != nil && err != io.EOF {
+				return nil, errors.Wrap(err, "parsing maintenance windows")
+			}
+			return parseMaintenanceWindows(list)
+		}
+		// environment variable, settings contain spaces, windows are separated by ;
+		for _, setting := range strings.Split(s, ";") {
+			if setting = strings.TrimSpace(setting); setting != "" {
+				settings = append(settings, setting)
+			}
+		}
+	} else {
+		settings = viper.GetStringSlice(KeyMaintenanceWindows)
+	}
+	return parseMaintenanceWindows(settings)
+}
+
+func parseMaintenanceWindows(settings []string) ([]MaintenanceWindow, error) {
+	windows := make([]MaintenanceWindow, 0, len(settings))
+	for _, setting := range settings {
+		w, err := parseMaintenanceWindow(setting)
+		if err != nil {
+			return nil, errors.Wrapf(err, "maintenance window (%s)", setting)
+		}
+		windows = append(windows, *w)
+	}
+	return windows, nil
+}
+
+func parseMaintenanceWindow(setting string) (*MaintenanceWindow, error) {
+	parts := strings.Split(setting, "|")
+	if len(parts) != 3 {
+		return nil, errors.New("expected cron|duration|selectors")
+	}
+
+	w := &MaintenanceWindow{Spec: strings.TrimSpace(parts[0])}
+
+	fields := strings.Fields(w.Spec)
+	if len(fields) != len(cronFields) {
+		return nil, errors.Errorf("invalid schedule (%s), expected minute hour day-of-month month day-of-week", w.Spec)
+	}
+	sets := make([]uint64, len(cronFields))
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	w.minutes, w.hours, w.days, w.months, w.weekdays = sets[0], sets[1], sets[2], sets[3], sets[4]
+	if w.weekdays&(1<<7) != 0 {
+		w.weekdays |= 1 // 7 is sunday
+	}
+	w.anyDay = fields[2] == "*"
+	w.anyDOW = fields[4] == "*"
+
+	d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing duration")
+	}
+	if d < time.Minute || d > maxMaintenanceDuration {
+		return nil, errors.Errorf("invalid duration (%s), must be between 1m and %s", parts[1], maxMaintenanceDuration)
+	}
+	w.Duration = d
+
+	w.Selectors = strings.Fields(parts[2])
+	if len(w.Selectors) == 0 {
+		return nil, errors.New("no selectors")
+	}
+	for _, sel := range w.Selectors {
+		if _, err := path.Match(sel, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid selector (%s)", sel)
+		}
+	}
+
+	return w, nil
+}
+
+// parseCronField parses a cron field (*, n, a-b, */s, a-b/s and lists of them)
+// into a bit set of the values
+func parseCronField(spec string, f cronField) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(spec, ",") {
+		rng, step := item, 1
+		if idx := strings.Index(item, "/"); idx != -1 {
+			s, err := strconv.Atoi(item[idx+1:])
+			if err != nil || s < 1 {
+				return 0, errors.Errorf("invalid %s step (%s)", f.name, item)
+			}
+			rng, step = item[:idx], s
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			v, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, errors.Errorf("invalid %s (%s)", f.name, item)
+			}
+			lo, hi = v, v
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errors.Errorf("invalid %s (%s)", f.name, item)
+				}
+			} else if step > 1 {
+				hi = f.max // n/s, from n to the end of the range
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, errors.Errorf("%s out of range (%s)", f.name, item)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// matches returns true if the schedule matches the minute of t, day-of-month
+// and day-of-week match if either does when both are restricted (cron)
+func (w *MaintenanceWindow) matches(t time.Time) bool {
+	if w.minutes&(1<<uint(t.Minute())) == 0 || w.hours&(1<<uint(t.Hour())) == 0 || w.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := w.days&(1<<uint(t.Day())) != 0
+	dow := w.weekdays&(1<<uint(t.Weekday())) != 0
+	if w.anyDay || w.anyDOW {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Active returns true if now is within the window, a start matching the
+// schedule within the last duration
+func (w *MaintenanceWindow) Active(now time.Time) bool {
+	t := now.Truncate(time.Minute)
+	for start := t; now.Sub(start) < w.Duration; start = start.Add(-time.Minute) {
+		if w.matches(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// Selects returns true if the id (builtin id or plugin name) matches a selector
+func (w *MaintenanceWindow) Selects(id string) bool {
+	for _, sel := range w.Selectors {
+		if ok, _ := path.Match(sel, id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveMaintenanceWindows returns the windows active at now
+func ActiveMaintenanceWindows(windows []MaintenanceWindow, now time.Time) []MaintenanceWindow {
+	var active []MaintenanceWindow
+	for i := range windows {
+		if windows[i].Active(now) {
+			active = append(active, windows[i])
+		}
+	}
+	return active
+}
+
+// InMaintenance returns true if any of the (active) windows selects the id
+func InMaintenance(active []MaintenanceWindow, id string) bool {
+	for i := range active {
+		if active[i].Selects(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateMaintenanceWindowOptions() error {
+	_, err := MaintenanceWindows()
+	return err
+}
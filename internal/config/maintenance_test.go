@@ -0,0 +1,179 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateMaintenanceWindowOptions(t *testing.T) {
+	t.Log("Testing validateMaintenanceWindowOptions")
+
+	defer viper.Reset()
+
+	t.Log("not set")
+	{
+		viper.Reset()
+		if err := validateMaintenanceWindowOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid")
+	{
+		viper.Reset()
+		viper.Set(KeyMaintenanceWindows, []string{"0 2 * * 6|3h|disk diskstats backup*", "*/15 1-5,22 1 1,7 1-5|10m|cpu"})
+		if err := validateMaintenanceWindowOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		windows, err := MaintenanceWindows()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(windows) != 2 || windows[0].Duration != 3*time.Hour || len(windows[0].Selectors) != 3 {
+			t.Fatalf("unexpected windows %#v", windows)
+		}
+	}
+
+	t.Log("environment")
+	{
+		viper.Reset()
+		viper.Set(KeyMaintenanceWindows, "0 2 * * 6|3h|disk; */15 1,22 * * *|10m|cpu ;")
+		windows, err := MaintenanceWindows()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(windows) != 2 || windows[1].Spec != "*/15 1,22 * * *" {
+			t.Fatalf("unexpected windows %#v", windows)
+		}
+	}
+
+	t.Log("command line")
+	{
+		viper.Reset()
+		viper.Set(KeyMaintenanceWindows, `["0 2 * * 1,6|3h|disk",*/5 * * * *|5m|cpu]`)
+		windows, err := MaintenanceWindows()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(windows) != 2 || windows[0].Spec != "0 2 * * 1,6" || windows[1].Selectors[0] != "cpu" {
+			t.Fatalf("unexpected windows %#v", windows)
+		}
+	}
+
+	tt := []struct {
+		name   string
+		window string
+		expect string
+	}{
+		{"no selectors", "0 2 * * 6|3h", "expected cron|duration|selectors"},
+		{"empty selectors", "0 2 * * 6|3h| ", "no selectors"},
+		{"short schedule", "0 2 * *|3h|disk", "invalid schedule"},
+		{"bad minute", "60 2 * * 6|3h|disk", "minute out of range"},
+		{"bad range", "0 5-2 * * 6|3h|disk", "hour out of range"},
+		{"bad step", "*/0 2 * * 6|3h|disk", "invalid minute step"},
+		{"bad day", "0 2 x * 6|3h|disk", "invalid day of month"},
+		{"bad duration", "0 2 * * 6|soon|disk", "parsing duration"},
+		{"long duration", "0 2 * * 6|200h|disk", "invalid duration"},
+		{"bad selector", "0 2 * * 6|3h|disk[", "invalid selector"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid (%s)", tst.name)
+		viper.Reset()
+		viper.Set(KeyMaintenanceWindows, []string{tst.window})
+		err := validateMaintenanceWindowOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(err.Error(), tst.expect) {
+			t.Fatalf("expected (%s) got (%s)", tst.expect, err)
+		}
+	}
+}
+
+func TestMaintenanceWindowActive(t *testing.T) {
+	t.Log("Testing MaintenanceWindow.Active")
+
+	// saturday 2020-06-06
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, time.June, day, hour, minute, 30, 0, time.Local)
+	}
+
+	t.Log("environment")
+	{
+		viper.Reset()
+		viper.Set(KeyMaintenanceWindows, "0 2 * * 6|3h|disk; */15 1,22 * * *|10m|cpu ;")
+		windows, err := MaintenanceWindows()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(windows) != 2 || windows[1].Spec != "*/15 1,22 * * *" {
+			t.Fatalf("unexpected windows %#v", windows)
+		}
+	}
+
+	tt := []struct {
+		name   string
+		window string
+		now    time.Time
+		active bool
+	}{
+		{"start", "0 2 * * 6|3h|disk", at(6, 2, 0), true},
+		{"within", "0 2 * * 6|3h|disk", at(6, 4, 59), true},
+		{"ended", "0 2 * * 6|3h|disk", at(6, 5, 0), false},
+		{"before", "0 2 * * 6|3h|disk", at(6, 1, 59), false},
+		{"other day", "0 2 * * 6|3h|disk", at(7, 3, 0), false},
+		{"sunday as 7", "0 2 * * 7|3h|disk", at(7, 3, 0), true},
+		{"spans midnight", "30 23 * * 5|2h|disk", at(6, 1, 0), true},
+		{"dom or dow", "0 2 15 * 1|1h|disk", at(15, 2, 10), true},
+		{"dom or dow, neither", "0 2 15 * 1|1h|disk", at(16, 2, 10), false},
+		{"step", "*/20 * * * *|5m|disk", at(6, 10, 44), true},
+		{"step, between", "*/20 * * * *|5m|disk", at(6, 10, 46), false},
+		{"month", "0 0 1 7 *|24h|disk", at(6, 0, 0), false},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s", tst.name)
+		w, err := parseMaintenanceWindow(tst.window)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if active := w.Active(tst.now); active != tst.active {
+			t.Fatalf("expected %v got %v", tst.active, active)
+		}
+	}
+}
+
+func TestInMaintenance(t *testing.T) {
+	t.Log("Testing InMaintenance")
+
+	w, err := parseMaintenanceWindow("0 2 * * *|1h|disk backup*")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	windows := []MaintenanceWindow{*w}
+	now := time.Date(2020, time.June, 6, 2, 30, 0, 0, time.Local)
+
+	t.Log("\tinactive")
+	{
+		if active := ActiveMaintenanceWindows(windows, now.Add(time.Hour)); len(active) != 0 {
+			t.Fatalf("expected no active windows, got %#v", active)
+		}
+	}
+
+	active := ActiveMaintenanceWindows(windows, now)
+	for id, expect := range map[string]bool{"disk": true, "backup_check": true, "diskstats": false, "cpu": false} {
+		t.Logf("\t%s", id)
+		if InMaintenance(active, id) != expect {
+			t.Fatalf("expected %v", expect)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// MetricMergeLast the most recent value replaces earlier values
+	MetricMergeLast = "last"
+	// MetricMergeSum numeric values are added together, histogram samples are combined
+	MetricMergeSum = "sum"
+	// MetricMergeReject the first value is kept, later values are dropped and counted
+	MetricMergeReject = "reject"
+)
+
+// IsValidMetricMerge verifies a metric merge policy setting
+func IsValidMetricMerge(policy string) bool {
+	switch policy {
+	case MetricMergeLast, MetricMergeSum, MetricMergeReject:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateMetricMergeOptions verifies the metric merge policy, empty uses the default (last)
+func validateMetricMergeOptions() error {
+	policy := viper.GetString(KeyMetricMerge)
+	if policy == "" {
+		viper.Set(KeyMetricMerge, MetricMergeLast)
+		return nil
+	}
+	if !IsValidMetricMerge(policy) {
+		return errors.Errorf("invalid metric merge policy (%s)", policy)
+	}
+	return nil
+}
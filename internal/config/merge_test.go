@@ -0,0 +1,51 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateMetricMergeOptions(t *testing.T) {
+	t.Log("Testing validateMetricMergeOptions")
+
+	t.Log("empty (default)")
+	{
+		viper.Set(KeyMetricMerge, "")
+		if err := validateMetricMergeOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if p := viper.GetString(KeyMetricMerge); p != MetricMergeLast {
+			t.Fatalf("expected %s, got %s", MetricMergeLast, p)
+		}
+	}
+
+	t.Log("valid")
+	{
+		for _, p := range []string{MetricMergeLast, MetricMergeSum, MetricMergeReject} {
+			viper.Set(KeyMetricMerge, p)
+			if err := validateMetricMergeOptions(); err != nil {
+				t.Fatalf("expected NO error for %s, got (%s)", p, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		viper.Set(KeyMetricMerge, "first")
+		err := validateMetricMergeOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != "invalid metric merge policy (first)" {
+			t.Fatalf("unexpected error (%s)", err)
+		}
+	}
+
+	viper.Set(KeyMetricMerge, "")
+}
@@ -0,0 +1,79 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// MetricAggregateSum sum of the instance values
+	MetricAggregateSum = "sum"
+	// MetricAggregateAvg average of the instance values
+	MetricAggregateAvg = "avg"
+	// MetricAggregateMin minimum of the instance values
+	MetricAggregateMin = "min"
+	// MetricAggregateMax maximum of the instance values
+	MetricAggregateMax = "max"
+	// MetricAggregateCount number of instances
+	MetricAggregateCount = "count"
+)
+
+// defaultMetricAggregateFuncs are used when a setting does not list the functions
+var defaultMetricAggregateFuncs = []string{MetricAggregateSum, MetricAggregateAvg, MetricAggregateMax}
+
+// MetricAggregation defines how the per-instance metrics of a builtin
+// collector are aggregated, instances are identified by the tag category
+// (e.g. cpu, container) and replaced by a metric for each function
+type MetricAggregation struct {
+	Category  string
+	Functions []string
+}
+
+// MetricAggregations returns the aggregation of each builtin collector from
+// the metric aggregate settings (id:category[:func|func...]), collectors
+// without an aggregation are not included
+func MetricAggregations() (map[string]MetricAggregation, error) {
+	aggs := make(map[string]MetricAggregation)
+	for _, setting := range viper.GetStringSlice(KeyMetricAggregate) {
+		parts := strings.SplitN(setting, ":", 3)
+		if len(parts) < 2 {
+			return nil, errors.Errorf("invalid metric aggregate (%s), expected id:category[:functions]", setting)
+		}
+		id := strings.TrimSpace(parts[0])
+		category := strings.ToLower(strings.TrimSpace(parts[1]))
+		if id == "" || category == "" {
+			return nil, errors.Errorf("invalid metric aggregate (%s), expected id:category[:functions]", setting)
+		}
+		if _, dup := aggs[id]; dup {
+			return nil, errors.Errorf("duplicate metric aggregate for %s", id)
+		}
+		funcs := defaultMetricAggregateFuncs
+		if len(parts) == 3 {
+			funcs = []string{}
+			for _, fn := range strings.Split(parts[2], "|") {
+				fn = strings.TrimSpace(fn)
+				switch fn {
+				case MetricAggregateSum, MetricAggregateAvg, MetricAggregateMin, MetricAggregateMax, MetricAggregateCount:
+					funcs = append(funcs, fn)
+				default:
+					return nil, errors.Errorf("invalid metric aggregate function for %s (%s)", id, fn)
+				}
+			}
+		}
+		aggs[id] = MetricAggregation{Category: category, Functions: funcs}
+	}
+	return aggs, nil
+}
+
+// validateMetricAggregateOptions verifies the metric aggregate settings
+func validateMetricAggregateOptions() error {
+	_, err := MetricAggregations()
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateMetricAggregateOptions(t *testing.T) {
+	t.Log("Testing validateMetricAggregateOptions")
+
+	defer viper.Reset()
+
+	t.Log("not set")
+	{
+		viper.Reset()
+		if err := validateMetricAggregateOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid")
+	{
+		viper.Reset()
+		viper.Set(KeyMetricAggregate, []string{"cpu:CPU", "docker:container:avg|max|count"})
+		if err := validateMetricAggregateOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		aggs, err := MetricAggregations()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := map[string]MetricAggregation{
+			"cpu":    {Category: "cpu", Functions: []string{"sum", "avg", "max"}},
+			"docker": {Category: "container", Functions: []string{"avg", "max", "count"}},
+		}
+		if !reflect.DeepEqual(aggs, expect) {
+			t.Fatalf("unexpected aggregations %v", aggs)
+		}
+	}
+
+	tt := []struct {
+		name   string
+		agg    []string
+		expect string
+	}{
+		{"no category", []string{"cpu"}, "invalid metric aggregate (cpu), expected id:category[:functions]"},
+		{"empty category", []string{"cpu: "}, "invalid metric aggregate (cpu: ), expected id:category[:functions]"},
+		{"bad function", []string{"cpu:cpu:median"}, "invalid metric aggregate function for cpu (median)"},
+		{"duplicate", []string{"cpu:cpu", "cpu:core"}, "duplicate metric aggregate for cpu"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid (%s)", tst.name)
+		viper.Reset()
+		viper.Set(KeyMetricAggregate, tst.agg)
+		err := validateMetricAggregateOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Fatalf("unexpected error (%s)", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// MetricTopK defines the top K instance selection of a builtin collector,
+// instances are identified by the tag category (e.g. process-name) and ranked
+// by the value of the metric, the metrics of the K instances with the highest
+// values are emitted as-is, the remaining instances are summed into "other"
+type MetricTopK struct {
+	Category string
+	K        int
+	Metric   string
+}
+
+// MetricTopKs returns the top K selection of each builtin collector from the
+// metric top k settings (id:category:k:metric), collectors without a top K
+// selection are not included
+func MetricTopKs() (map[string]MetricTopK, error) {
+	topks := make(map[string]MetricTopK)
+	for _, setting := range viper.GetStringSlice(KeyMetricTopK) {
+		parts := strings.SplitN(setting, ":", 4)
+		if len(parts) != 4 {
+			return nil, errors.Errorf("invalid metric top k (%s), expected id:category:k:metric", setting)
+		}
+		id := strings.TrimSpace(parts[0])
+		category := strings.ToLower(strings.TrimSpace(parts[1]))
+		metric := strings.TrimSpace(parts[3])
+		if id == "" || category == "" || metric == "" {
+			return nil, errors.Errorf("invalid metric top k (%s), expected id:category:k:metric", setting)
+		}
+		if _, dup := topks[id]; dup {
+			return nil, errors.Errorf("duplicate metric top k for %s", id)
+		}
+		k, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing metric top k for %s", id)
+		}
+		if k <= 0 {
+			return nil, errors.Errorf("invalid metric top k for %s (%d)", id, k)
+		}
+		topks[id] = MetricTopK{Category: category, K: k, Metric: metric}
+	}
+	return topks, nil
+}
+
+// validateMetricTopKOptions verifies the metric top k settings, a collector
+// can have either a top K selection or an aggregation
+func validateMetricTopKOptions() error {
+	topks, err := MetricTopKs()
+	if err != nil {
+		return err
+	}
+	aggs, err := MetricAggregations()
+	if err != nil {
+		return err
+	}
+	for id := range topks {
+		if _, ok := aggs[id]; ok {
+			return errors.Errorf("%s has both a metric top k and a metric aggregate", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateMetricTopKOptions(t *testing.T) {
+	t.Log("Testing validateMetricTopKOptions")
+
+	defer viper.Reset()
+
+	t.Log("not set")
+	{
+		viper.Reset()
+		if err := validateMetricTopKOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid")
+	{
+		viper.Reset()
+		viper.Set(KeyMetricTopK, []string{"processes:Process-Name:10:PercentProcessorTime"})
+		viper.Set(KeyMetricAggregate, []string{"cpu:cpu"})
+		if err := validateMetricTopKOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		topks, err := MetricTopKs()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := map[string]MetricTopK{
+			"processes": {Category: "process-name", K: 10, Metric: "PercentProcessorTime"},
+		}
+		if !reflect.DeepEqual(topks, expect) {
+			t.Fatalf("unexpected top k %v", topks)
+		}
+	}
+
+	tt := []struct {
+		name   string
+		topk   []string
+		expect string
+	}{
+		{"no metric", []string{"processes:process-name:10"}, "invalid metric top k (processes:process-name:10), expected id:category:k:metric"},
+		{"empty metric", []string{"processes:process-name:10: "}, "invalid metric top k (processes:process-name:10: ), expected id:category:k:metric"},
+		{"bad k", []string{"processes:process-name:ten:x"}, `parsing metric top k for processes: strconv.Atoi: parsing "ten": invalid syntax`},
+		{"zero k", []string{"processes:process-name:0:x"}, "invalid metric top k for processes (0)"},
+		{"duplicate", []string{"processes:process-name:5:x", "processes:process-name:10:x"}, "duplicate metric top k for processes"},
+		{"aggregated", []string{"cpu:cpu:5:user"}, "cpu has both a metric top k and a metric aggregate"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid (%s)", tst.name)
+		viper.Reset()
+		viper.Set(KeyMetricTopK, tst.topk)
+		viper.Set(KeyMetricAggregate, []string{"cpu:cpu"})
+		err := validateMetricTopKOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Fatalf("unexpected error (%s)", err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// MetricTTLs returns the metric ttl of each source from the metric ttl
+// settings (source:duration), sources without a ttl are not included
+func MetricTTLs() (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, setting := range viper.GetStringSlice(KeyMetricTTL) {
+		parts := strings.SplitN(setting, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid metric ttl (%s), expected source:duration", setting)
+		}
+		source := strings.TrimSpace(parts[0])
+		if !IsValidSource(source) {
+			return nil, errors.Errorf("invalid metric ttl source (%s)", source)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing metric ttl for %s", source)
+		}
+		if d <= 0 {
+			return nil, errors.Errorf("invalid metric ttl for %s (%s)", source, parts[1])
+		}
+		ttls[source] = d
+	}
+	return ttls, nil
+}
+
+// validateMetricTTLOptions verifies the metric ttl settings
+func validateMetricTTLOptions() error {
+	ttls, err := MetricTTLs()
+	if err != nil {
+		return err
+	}
+	if viper.GetBool(KeyMetricTombstones) && len(ttls) == 0 {
+		return errors.New("metric tombstones require a metric ttl")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateMetricTTLOptions(t *testing.T) {
+	t.Log("Testing validateMetricTTLOptions")
+
+	defer viper.Reset()
+
+	t.Log("not set")
+	{
+		viper.Reset()
+		if err := validateMetricTTLOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid")
+	{
+		viper.Reset()
+		viper.Set(KeyMetricTTL, []string{"plugins:10m", "statsd: 1h"})
+		viper.Set(KeyMetricTombstones, true)
+		if err := validateMetricTTLOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		ttls, err := MetricTTLs()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(ttls) != 2 || ttls["plugins"] != 10*time.Minute || ttls["statsd"] != time.Hour {
+			t.Fatalf("unexpected ttls %v", ttls)
+		}
+	}
+
+	tt := []struct {
+		name   string
+		ttl    []string
+		expect string
+	}{
+		{"no duration", []string{"plugins"}, "invalid metric ttl (plugins), expected source:duration"},
+		{"bad source", []string{"nad:10m"}, "invalid metric ttl source (nad)"},
+		{"bad duration", []string{"plugins:ten"}, `parsing metric ttl for plugins: time: invalid duration "ten"`},
+		{"zero duration", []string{"plugins:0s"}, "invalid metric ttl for plugins (0s)"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid (%s)", tst.name)
+		viper.Reset()
+		viper.Set(KeyMetricTTL, tst.ttl)
+		err := validateMetricTTLOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Fatalf("unexpected error (%s)", err)
+		}
+	}
+
+	t.Log("tombstones without ttl")
+	{
+		viper.Reset()
+		viper.Set(KeyMetricTombstones, true)
+		if err := validateMetricTTLOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// validateOTLPOptions verifies the OTLP receiver address and the tls certificate and key
+func validateOTLPOptions() error {
+	addr := viper.GetString(KeyOTLPAddr)
+	certFile := viper.GetString(KeyOTLPCertFile)
+	keyFile := viper.GetString(KeyOTLPKeyFile)
+
+	if addr == "" {
+		if certFile != "" || keyFile != "" {
+			return errors.New("otlp cert/key file set, no otlp address")
+		}
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return errors.Wrapf(err, "invalid otlp address (%s)", addr)
+	}
+
+	if certFile == "" && keyFile == "" {
+		return nil
+	}
+	if certFile == "" || keyFile == "" {
+		return errors.New("otlp tls requires both cert and key file")
+	}
+	for key, file := range map[string]string{KeyOTLPCertFile: certFile, KeyOTLPKeyFile: keyFile} {
+		f, err := verifyFile(file)
+		if err != nil {
+			return errors.Wrapf(err, "otlp %s", key)
+		}
+		viper.Set(key, f)
+	}
+
+	return nil
+}
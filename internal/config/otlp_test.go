@@ -0,0 +1,84 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateOTLPOptions(t *testing.T) {
+	t.Log("Testing validateOTLPOptions")
+
+	defer viper.Reset()
+
+	f, err := ioutil.TempFile("", "otlp")
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	t.Log("not set")
+	{
+		viper.Reset()
+		if err := validateOTLPOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid, no tls")
+	{
+		viper.Reset()
+		viper.Set(KeyOTLPAddr, ":4318")
+		if err := validateOTLPOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid, tls")
+	{
+		viper.Reset()
+		viper.Set(KeyOTLPAddr, "localhost:4317")
+		viper.Set(KeyOTLPCertFile, f.Name())
+		viper.Set(KeyOTLPKeyFile, f.Name())
+		if err := validateOTLPOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	tt := []struct {
+		name     string
+		addr     string
+		certFile string
+		keyFile  string
+		expect   string
+	}{
+		{"no address", "", f.Name(), "", "no otlp address"},
+		{"no port", "localhost", "", "", "invalid otlp address (localhost)"},
+		{"no key", ":4317", f.Name(), "", "requires both cert and key file"},
+		{"missing cert", ":4317", "/tmp/missing-otlp.pem", f.Name(), "otlp otlp.cert_file"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid (%s)", tst.name)
+		viper.Reset()
+		viper.Set(KeyOTLPAddr, tst.addr)
+		viper.Set(KeyOTLPCertFile, tst.certFile)
+		viper.Set(KeyOTLPKeyFile, tst.keyFile)
+		err := validateOTLPOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(err.Error(), tst.expect) {
+			t.Fatalf("expected (%s) got (%s)", tst.expect, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// OutputCheck the /run response (the check, directly or via the reverse connection)
+	OutputCheck = "check"
+	// OutputPrometheus the prometheus endpoints (/prom and /metrics)
+	OutputPrometheus = "prometheus"
+)
+
+// OutputTags defines the naming of the metrics of an output, a prefix added
+// to each metric name, stream tags added (replacing a tag of the same
+// category) and tag categories removed
+type OutputTags struct {
+	Prefix string
+	Add    []string // cat:val
+	Remove []string // cat
+}
+
+// OutputTagSettings returns the naming of each output from the output tag
+// settings (output:prefix:value, output:add:cat:val, output:remove:cat),
+// outputs without settings are not included
+func OutputTagSettings() (map[string]OutputTags, error) {
+	outputs := make(map[string]OutputTags)
+	for _, setting := range viper.GetStringSlice(KeyOutputTags) {
+		parts := strings.SplitN(setting, ":", 3)
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid output tags (%s), expected output:action:value", setting)
+		}
+		output := strings.TrimSpace(parts[0])
+		if output != OutputCheck && output != OutputPrometheus {
+			return nil, errors.Errorf("invalid output tags output (%s)", output)
+		}
+		value := strings.TrimSpace(parts[2])
+		if value == "" {
+			return nil, errors.Errorf("invalid output tags (%s), empty value", setting)
+		}
+		ot := outputs[output]
+		switch action := strings.TrimSpace(parts[1]); action {
+		case "prefix":
+			if ot.Prefix != "" {
+				return nil, errors.Errorf("duplicate output tags prefix for %s", output)
+			}
+			ot.Prefix = value
+		case "add":
+			if tag := strings.SplitN(value, ":", 2); len(tag) != 2 || tag[0] == "" || tag[1] == "" {
+				return nil, errors.Errorf("invalid output tags tag (%s), expected cat:val", value)
+			}
+			ot.Add = append(ot.Add, value)
+		case "remove":
+			ot.Remove = append(ot.Remove, value)
+		default:
+			return nil, errors.Errorf("invalid output tags action (%s), expected prefix, add or remove", action)
+		}
+		outputs[output] = ot
+	}
+	return outputs, nil
+}
+
+// validateOutputTagOptions verifies the output tag settings
+func validateOutputTagOptions() error {
+	_, err := OutputTagSettings()
+	return err
+}
@@ -0,0 +1,79 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateOutputTagOptions(t *testing.T) {
+	t.Log("Testing validateOutputTagOptions")
+
+	defer viper.Reset()
+
+	t.Log("not set")
+	{
+		viper.Reset()
+		if err := validateOutputTagOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid")
+	{
+		viper.Reset()
+		viper.Set(KeyOutputTags, []string{
+			"prometheus:prefix:agent_",
+			"prometheus:add:env:prod",
+			"prometheus:remove:source",
+			"check:remove:collector",
+			"check:remove:source",
+		})
+		if err := validateOutputTagOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		outputs, err := OutputTagSettings()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		expect := map[string]OutputTags{
+			OutputPrometheus: {Prefix: "agent_", Add: []string{"env:prod"}, Remove: []string{"source"}},
+			OutputCheck:      {Remove: []string{"collector", "source"}},
+		}
+		if !reflect.DeepEqual(outputs, expect) {
+			t.Fatalf("unexpected output tags %v", outputs)
+		}
+	}
+
+	tt := []struct {
+		name    string
+		setting []string
+		expect  string
+	}{
+		{"no value", []string{"check:prefix"}, "invalid output tags (check:prefix), expected output:action:value"},
+		{"empty value", []string{"check:prefix: "}, "invalid output tags (check:prefix: ), empty value"},
+		{"bad output", []string{"remote:prefix:x_"}, "invalid output tags output (remote)"},
+		{"bad action", []string{"check:rename:x"}, "invalid output tags action (rename), expected prefix, add or remove"},
+		{"bad tag", []string{"check:add:env"}, "invalid output tags tag (env), expected cat:val"},
+		{"duplicate prefix", []string{"check:prefix:a_", "check:prefix:b_"}, "duplicate output tags prefix for check"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("invalid (%s)", tst.name)
+		viper.Reset()
+		viper.Set(KeyOutputTags, tst.setting)
+		err := validateOutputTagOptions()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Fatalf("unexpected error (%s)", err)
+		}
+	}
+}
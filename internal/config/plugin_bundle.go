@@ -0,0 +1,72 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// PluginBundlePublicKey returns the decoded plugin bundle public key
+func PluginBundlePublicKey() (ed25519.PublicKey, error) {
+	key := viper.GetString(KeyPluginBundlePublicKey)
+	if key == "" {
+		return nil, errors.New("invalid plugin bundle public key (empty)")
+	}
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding plugin bundle public key")
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("invalid plugin bundle public key size (%d)", len(data))
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// validatePluginBundleOptions verifies the plugin bundle url, public key and
+// interval, a bundle is only fetched when a url is set
+func validatePluginBundleOptions() error {
+	bundleURL := viper.GetString(KeyPluginBundleURL)
+	if bundleURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(bundleURL)
+	if err != nil {
+		return errors.Wrap(err, "parsing plugin bundle url")
+	}
+	if u.Scheme != "https" && u.Scheme != "s3" {
+		return errors.Errorf("invalid plugin bundle url scheme (%s), must be https or s3", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.Errorf("invalid plugin bundle url (%s), no host or bucket", bundleURL)
+	}
+
+	if len(viper.GetStringSlice(KeyPluginList)) > 0 {
+		return errors.New("plugin bundle requires a plugin directory, not a plugin list")
+	}
+
+	if _, err := PluginBundlePublicKey(); err != nil {
+		return err
+	}
+
+	if interval := viper.GetString(KeyPluginBundleInterval); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return errors.Wrap(err, "parsing plugin bundle interval")
+		}
+		if d < 0 {
+			return errors.Errorf("invalid plugin bundle interval (%s)", interval)
+		}
+	}
+
+	return nil
+}
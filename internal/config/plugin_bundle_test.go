@@ -0,0 +1,75 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidatePluginBundleOptions(t *testing.T) {
+	t.Log("Testing validatePluginBundleOptions")
+
+	defer viper.Reset()
+
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+
+	t.Log("not configured")
+	{
+		viper.Reset()
+		if err := validatePluginBundleOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("valid")
+	{
+		for _, u := range []string{"https://example.com/plugins/{role}.tgz", "s3://bucket/plugins/web.tgz"} {
+			viper.Reset()
+			viper.Set(KeyPluginBundleURL, u)
+			viper.Set(KeyPluginBundlePublicKey, key)
+			viper.Set(KeyPluginBundleInterval, "30m")
+			if err := validatePluginBundleOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", u, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		tests := []struct {
+			description string
+			url         string
+			key         string
+			interval    string
+			pluginList  []string
+		}{
+			{"http url", "http://example.com/web.tgz", key, "", nil},
+			{"no host", "https:///web.tgz", key, "", nil},
+			{"no key", "https://example.com/web.tgz", "", "", nil},
+			{"bad key", "https://example.com/web.tgz", "!!", "", nil},
+			{"short key", "https://example.com/web.tgz", base64.StdEncoding.EncodeToString([]byte("short")), "", nil},
+			{"bad interval", "https://example.com/web.tgz", key, "soon", nil},
+			{"negative interval", "https://example.com/web.tgz", key, "-5m", nil},
+			{"plugin list", "https://example.com/web.tgz", key, "", []string{"/bin/true"}},
+		}
+		for _, test := range tests {
+			viper.Reset()
+			viper.Set(KeyPluginBundleURL, test.url)
+			viper.Set(KeyPluginBundlePublicKey, test.key)
+			viper.Set(KeyPluginBundleInterval, test.interval)
+			if test.pluginList != nil {
+				viper.Set(KeyPluginList, test.pluginList)
+			}
+			if err := validatePluginBundleOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", test.description)
+			}
+		}
+	}
+}
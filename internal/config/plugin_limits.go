@@ -0,0 +1,22 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// validatePluginLimitOptions verifies the plugin memory and cpu limits, 0 disables a limit
+func validatePluginLimitOptions() error {
+	if mem := viper.GetInt64(KeyPluginMaxMemoryBytes); mem < 0 {
+		return errors.Errorf("invalid plugin max memory bytes (%d)", mem)
+	}
+	if cpu := viper.GetInt(KeyPluginMaxCPUPercent); cpu < 0 || cpu > 100 {
+		return errors.Errorf("invalid plugin max cpu percent (%d), 0-100", cpu)
+	}
+	return nil
+}
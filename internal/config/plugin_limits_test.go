@@ -0,0 +1,49 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidatePluginLimitOptions(t *testing.T) {
+	t.Log("Testing validatePluginLimitOptions")
+
+	defer func() {
+		viper.Set(KeyPluginMaxMemoryBytes, 0)
+		viper.Set(KeyPluginMaxCPUPercent, 0)
+	}()
+
+	t.Log("valid")
+	{
+		for _, tst := range []struct {
+			mem int64
+			cpu int
+		}{{0, 0}, {512 * 1024 * 1024, 0}, {0, 50}, {1024, 100}} {
+			viper.Set(KeyPluginMaxMemoryBytes, tst.mem)
+			viper.Set(KeyPluginMaxCPUPercent, tst.cpu)
+			if err := validatePluginLimitOptions(); err != nil {
+				t.Fatalf("expected NO error for (%d, %d), got (%s)", tst.mem, tst.cpu, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, tst := range []struct {
+			mem int64
+			cpu int
+		}{{-1, 0}, {0, -1}, {0, 101}} {
+			viper.Set(KeyPluginMaxMemoryBytes, tst.mem)
+			viper.Set(KeyPluginMaxCPUPercent, tst.cpu)
+			if err := validatePluginLimitOptions(); err == nil {
+				t.Fatalf("expected error for (%d, %d)", tst.mem, tst.cpu)
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// validatePluginTimeoutOptions verifies the plugin timeout, empty or 0 disables the timeout
+func validatePluginTimeoutOptions() error {
+	timeout := viper.GetString(KeyPluginTimeout)
+	if timeout == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return errors.Wrap(err, "parsing plugin timeout")
+	}
+	if d < 0 {
+		return errors.Errorf("invalid plugin timeout (%s)", timeout)
+	}
+	return nil
+}
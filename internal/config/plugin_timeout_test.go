@@ -0,0 +1,38 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidatePluginTimeoutOptions(t *testing.T) {
+	t.Log("Testing validatePluginTimeoutOptions")
+
+	t.Log("valid")
+	{
+		for _, d := range []string{"", "0", "30s", "1m30s"} {
+			viper.Set(KeyPluginTimeout, d)
+			if err := validatePluginTimeoutOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", d, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, d := range []string{"30", "-5s", "never"} {
+			viper.Set(KeyPluginTimeout, d)
+			if err := validatePluginTimeoutOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", d)
+			}
+		}
+	}
+
+	viper.Set(KeyPluginTimeout, "")
+}
@@ -0,0 +1,214 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// Profile defines a named bundle of settings for a host role (e.g. web, db, batch), so
+// one config file can cover multiple roles. A profile is selected explicitly (--profile)
+// or, when not set, the first profile whose match criteria are all met is applied.
+type Profile struct {
+	Name           string   `json:"name" yaml:"name" toml:"name"`
+	MatchHostname  string   `mapstructure:"match_hostname" json:"match_hostname" yaml:"match_hostname" toml:"match_hostname"`     // regex matched against hostname
+	MatchEnv       string   `mapstructure:"match_env" json:"match_env" yaml:"match_env" toml:"match_env"`                         // NAME=regex, matched against environment variable NAME
+	MatchCloudTag  string   `mapstructure:"match_cloud_tag" json:"match_cloud_tag" yaml:"match_cloud_tag" toml:"match_cloud_tag"` // key=regex, matched against cloud instance tag key (aws, gcp, azure)
+	Collectors     []string `json:"collectors" yaml:"collectors" toml:"collectors"`                                               // replaces collectors
+	CheckTags      string   `mapstructure:"check_tags" json:"check_tags" yaml:"check_tags" toml:"check_tags"`                     // appended to check.tags
+	MetricFilters  string   `mapstructure:"metric_filters" json:"metric_filters" yaml:"metric_filters" toml:"metric_filters"`     // replaces check.metric_filters (same json format)
+	hostnameRx     *regexp.Regexp
+	envName        string
+	envRx          *regexp.Regexp
+	cloudTagKey    string
+	cloudTagRx     *regexp.Regexp
+	hasConstraints bool
+}
+
+var cloudTagFor = lookupCloudTag
+
+// ApplyProfile selects and applies a profile (see Profile), the settings of the
+// selected profile override the corresponding settings in the configuration.
+// Returns the name of the profile applied, empty if no profile was applied.
+func ApplyProfile(logger zerolog.Logger) (string, error) {
+	var profiles []Profile
+	if err := viper.UnmarshalKey(KeyProfiles, &profiles); err != nil {
+		return "", errors.Wrap(err, "parsing profiles")
+	}
+
+	selected := viper.GetString(KeyProfile)
+
+	if len(profiles) == 0 {
+		if selected != "" {
+			return "", errors.Errorf("profile (%s) not found, no profiles defined", selected)
+		}
+		return "", nil
+	}
+
+	for i := range profiles {
+		if err := profiles[i].compile(); err != nil {
+			return "", err
+		}
+	}
+
+	var profile *Profile
+	if selected != "" {
+		for i := range profiles {
+			if profiles[i].Name == selected {
+				profile = &profiles[i]
+				break
+			}
+		}
+		if profile == nil {
+			return "", errors.Errorf("profile (%s) not found", selected)
+		}
+		logger.Info().Str("profile", profile.Name).Msg("using selected profile")
+	} else {
+		for i := range profiles {
+			ok, err := profiles[i].matches()
+			if err != nil {
+				logger.Warn().Err(err).Str("profile", profiles[i].Name).Msg("unable to evaluate profile match")
+				continue
+			}
+			if ok {
+				profile = &profiles[i]
+				break
+			}
+		}
+		if profile == nil {
+			logger.Info().Int("profiles", len(profiles)).Msg("no matching profile")
+			return "", nil
+		}
+		logger.Info().Str("profile", profile.Name).Msg("using matching profile")
+	}
+
+	profile.apply()
+
+	return profile.Name, nil
+}
+
+// compile validates the profile and compiles the match criteria
+func (p *Profile) compile() error {
+	if p.Name == "" {
+		return errors.New("invalid profile, no name")
+	}
+
+	if p.MatchHostname != "" {
+		rx, err := regexp.Compile(p.MatchHostname)
+		if err != nil {
+			return errors.Wrapf(err, "profile %s, match_hostname", p.Name)
+		}
+		p.hostnameRx = rx
+		p.hasConstraints = true
+	}
+
+	if p.MatchEnv != "" {
+		name, rx, err := parseMatchSpec(p.MatchEnv)
+		if err != nil {
+			return errors.Wrapf(err, "profile %s, match_env", p.Name)
+		}
+		p.envName = name
+		p.envRx = rx
+		p.hasConstraints = true
+	}
+
+	if p.MatchCloudTag != "" {
+		key, rx, err := parseMatchSpec(p.MatchCloudTag)
+		if err != nil {
+			return errors.Wrapf(err, "profile %s, match_cloud_tag", p.Name)
+		}
+		p.cloudTagKey = key
+		p.cloudTagRx = rx
+		p.hasConstraints = true
+	}
+
+	if p.MetricFilters != "" {
+		var filters [][]string
+		if err := json.Unmarshal([]byte(p.MetricFilters), &filters); err != nil {
+			return errors.Wrapf(err, "profile %s, metric_filters", p.Name)
+		}
+	}
+
+	return nil
+}
+
+// parseMatchSpec parses a name=regex match spec
+func parseMatchSpec(spec string) (string, *regexp.Regexp, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", nil, errors.Errorf("invalid match (%s), expected name=regex", spec)
+	}
+	rx, err := regexp.Compile(parts[1])
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "invalid match (%s)", spec)
+	}
+	return parts[0], rx, nil
+}
+
+// matches returns true if all of the profile's match criteria are met, a
+// profile without match criteria only applies when selected explicitly
+func (p *Profile) matches() (bool, error) {
+	if !p.hasConstraints {
+		return false, nil
+	}
+
+	if p.hostnameRx != nil {
+		hn, err := osHostname()
+		if err != nil {
+			return false, errors.Wrap(err, "hostname")
+		}
+		if !p.hostnameRx.MatchString(hn) {
+			return false, nil
+		}
+	}
+
+	if p.envRx != nil {
+		v, ok := os.LookupEnv(p.envName)
+		if !ok || !p.envRx.MatchString(v) {
+			return false, nil
+		}
+	}
+
+	if p.cloudTagRx != nil {
+		v, err := cloudTagFor(p.cloudTagKey)
+		if err != nil {
+			return false, errors.Wrap(err, "cloud tag")
+		}
+		if !p.cloudTagRx.MatchString(v) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// apply overrides the configuration with the profile's settings
+func (p *Profile) apply() {
+	viper.Set(KeyProfile, p.Name)
+
+	if len(p.Collectors) > 0 {
+		viper.Set(KeyCollectors, p.Collectors)
+	}
+
+	if p.CheckTags != "" {
+		checkTags := viper.GetString(KeyCheckTags)
+		if checkTags != "" {
+			checkTags += ","
+		}
+		viper.Set(KeyCheckTags, checkTags+p.CheckTags)
+	}
+
+	if p.MetricFilters != "" {
+		viper.Set(KeyCheckMetricFilters, p.MetricFilters)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cloud instance metadata endpoints, tags are looked up from the first
+// provider which responds (aws, gcp, azure)
+var (
+	awsMetadataURL   = "http://169.254.169.254/latest"
+	gcpMetadataURL   = "http://metadata.google.internal/computeMetadata/v1"
+	azureMetadataURL = "http://169.254.169.254/metadata/instance/compute"
+	cloudTagTimeout  = 2 * time.Second
+)
+
+var (
+	cloudTags     map[string]string
+	cloudTagsErr  error
+	cloudTagsOnce sync.Once
+
+	cloudInstanceID     string
+	cloudInstanceIDErr  error
+	cloudInstanceIDOnce sync.Once
+)
+
+// lookupCloudTag returns the value of the cloud instance tag key, the
+// instance tags are only retrieved once
+func lookupCloudTag(key string) (string, error) {
+	cloudTagsOnce.Do(func() {
+		cloudTags, cloudTagsErr = fetchCloudTags()
+	})
+	if cloudTagsErr != nil {
+		return "", cloudTagsErr
+	}
+	return cloudTags[key], nil
+}
+
+func fetchCloudTags() (map[string]string, error) {
+	client := &http.Client{Timeout: cloudTagTimeout}
+
+	if t, err := awsTags(client); err == nil {
+		return t, nil
+	}
+	if t, err := gcpTags(client); err == nil {
+		return t, nil
+	}
+	if t, err := azureTags(client); err == nil {
+		return t, nil
+	}
+
+	return nil, errors.New("unable to retrieve instance tags from cloud metadata service")
+}
+
+// lookupCloudInstanceID returns the cloud instance id, it is only retrieved once
+func lookupCloudInstanceID() (string, error) {
+	cloudInstanceIDOnce.Do(func() {
+		cloudInstanceID, cloudInstanceIDErr = fetchCloudInstanceID()
+	})
+	return cloudInstanceID, cloudInstanceIDErr
+}
+
+func fetchCloudInstanceID() (string, error) {
+	client := &http.Client{Timeout: cloudTagTimeout}
+
+	if hdr, err := awsTokenHeader(client); err == nil {
+		if id, err := metadataRequest(client, awsMetadataURL+"/meta-data/instance-id", hdr); err == nil {
+			return strings.TrimSpace(string(id)), nil
+		}
+	}
+	if id, err := metadataRequest(client, gcpMetadataURL+"/instance/id", map[string]string{"Metadata-Flavor": "Google"}); err == nil {
+		return strings.TrimSpace(string(id)), nil
+	}
+	if id, err := metadataRequest(client, azureMetadataURL+"/vmId?api-version=2019-06-04&format=text", map[string]string{"Metadata": "true"}); err == nil {
+		return strings.TrimSpace(string(id)), nil
+	}
+
+	return "", errors.New("unable to retrieve instance id from cloud metadata service")
+}
+
+// awsTokenHeader returns the session token header required by IMDSv2 requests
+func awsTokenHeader(client *http.Client) (map[string]string, error) {
+	req, err := http.NewRequest("PUT", awsMetadataURL+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := metadataGet(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"X-aws-ec2-metadata-token": string(token)}, nil
+}
+
+// awsTags requires instance metadata tags to be enabled on the instance (IMDSv2)
+func awsTags(client *http.Client) (map[string]string, error) {
+	hdr, err := awsTokenHeader(client)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := metadataRequest(client, awsMetadataURL+"/meta-data/tags/instance", hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := make(map[string]string)
+	for _, key := range strings.Split(strings.TrimSpace(string(keys)), "\n") {
+		if key == "" {
+			continue
+		}
+		val, err := metadataRequest(client, awsMetadataURL+"/meta-data/tags/instance/"+key, hdr)
+		if err != nil {
+			return nil, err
+		}
+		t[key] = string(val)
+	}
+
+	return t, nil
+}
+
+// gcpTags uses instance metadata attributes (custom metadata key/value pairs)
+func gcpTags(client *http.Client) (map[string]string, error) {
+	data, err := metadataRequest(client, gcpMetadataURL+"/instance/attributes/?recursive=true", map[string]string{"Metadata-Flavor": "Google"})
+	if err != nil {
+		return nil, err
+	}
+
+	var t map[string]string
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, errors.Wrap(err, "parsing gcp attributes")
+	}
+
+	return t, nil
+}
+
+func azureTags(client *http.Client) (map[string]string, error) {
+	data, err := metadataRequest(client, azureMetadataURL+"/tagsList?api-version=2019-06-04", map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, err
+	}
+
+	var tagList []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &tagList); err != nil {
+		return nil, errors.Wrap(err, "parsing azure tags")
+	}
+
+	t := make(map[string]string, len(tagList))
+	for _, tag := range tagList {
+		t[tag.Name] = tag.Value
+	}
+
+	return t, nil
+}
+
+func metadataRequest(client *http.Client, url string, hdr map[string]string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	return metadataGet(client, req)
+}
+
+func metadataGet(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s %s", req.URL.Path, resp.Status)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,208 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestApplyProfile(t *testing.T) {
+	t.Log("Testing ApplyProfile")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	osHostname = func() (string, error) { return "db01.example.com", nil }
+	cloudTagFor = func(key string) (string, error) {
+		if key == "role" {
+			return "batch", nil
+		}
+		return "", nil
+	}
+	defer func() {
+		osHostname = os.Hostname
+		cloudTagFor = lookupCloudTag
+	}()
+
+	profiles := []map[string]interface{}{
+		{"name": "default"},
+		{"name": "web", "match_hostname": `^web\d+`, "collectors": []string{"cpu", "if"}},
+		{"name": "db", "match_hostname": `^db\d+`, "check_tags": "role:db", "collectors": []string{"cpu", "disk"}, "metric_filters": `[["allow","^disk",""]]`},
+	}
+
+	reset := func() {
+		viper.Reset()
+		viper.Set(KeyCheckTags, "env:prod")
+	}
+
+	t.Log("\tno profiles")
+	{
+		reset()
+		name, err := ApplyProfile(zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if name != "" {
+			t.Fatalf("expected no profile, got %s", name)
+		}
+	}
+
+	t.Log("\tno profiles, selected")
+	{
+		reset()
+		viper.Set(KeyProfile, "web")
+		if _, err := ApplyProfile(zerolog.Nop()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\thostname match")
+	{
+		reset()
+		viper.Set(KeyProfiles, profiles)
+		name, err := ApplyProfile(zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if name != "db" {
+			t.Fatalf("expected db, got %s", name)
+		}
+		if v := viper.GetString(KeyProfile); v != "db" {
+			t.Fatalf("expected db, got %s", v)
+		}
+		if v := viper.GetStringSlice(KeyCollectors); !reflect.DeepEqual(v, []string{"cpu", "disk"}) {
+			t.Fatalf("unexpected collectors %v", v)
+		}
+		if v := viper.GetString(KeyCheckTags); v != "env:prod,role:db" {
+			t.Fatalf("unexpected check tags %s", v)
+		}
+		if v := viper.GetString(KeyCheckMetricFilters); v != `[["allow","^disk",""]]` {
+			t.Fatalf("unexpected metric filters %s", v)
+		}
+	}
+
+	t.Log("\tselected")
+	{
+		reset()
+		viper.Set(KeyProfiles, profiles)
+		viper.Set(KeyProfile, "web")
+		name, err := ApplyProfile(zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if name != "web" {
+			t.Fatalf("expected web, got %s", name)
+		}
+		if v := viper.GetString(KeyCheckTags); v != "env:prod" {
+			t.Fatalf("unexpected check tags %s", v)
+		}
+	}
+
+	t.Log("\tselected, not found")
+	{
+		reset()
+		viper.Set(KeyProfiles, profiles)
+		viper.Set(KeyProfile, "batch")
+		if _, err := ApplyProfile(zerolog.Nop()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tenv and cloud tag match")
+	{
+		reset()
+		os.Setenv("CA_TEST_ROLE", "batch-worker")
+		defer os.Unsetenv("CA_TEST_ROLE")
+		viper.Set(KeyProfiles, []map[string]interface{}{
+			{"name": "env", "match_env": "CA_TEST_ROLE=^web"},
+			{"name": "batch", "match_env": "CA_TEST_ROLE=^batch", "match_cloud_tag": "role=^batch$"},
+		})
+		name, err := ApplyProfile(zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if name != "batch" {
+			t.Fatalf("expected batch, got %s", name)
+		}
+	}
+
+	t.Log("\tcloud tag error")
+	{
+		reset()
+		cloudTagFor = func(key string) (string, error) { return "", errors.New("no metadata") }
+		viper.Set(KeyProfiles, []map[string]interface{}{
+			{"name": "batch", "match_cloud_tag": "role=^batch$"},
+		})
+		name, err := ApplyProfile(zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if name != "" {
+			t.Fatalf("expected no profile, got %s", name)
+		}
+	}
+
+	t.Log("\tinvalid")
+	{
+		tt := []map[string]interface{}{
+			{"match_hostname": "^web"},
+			{"name": "bad", "match_hostname": "("},
+			{"name": "bad", "match_env": "^web"},
+			{"name": "bad", "match_cloud_tag": "=web"},
+			{"name": "bad", "metric_filters": "allow"},
+		}
+		for _, p := range tt {
+			reset()
+			viper.Set(KeyProfiles, []map[string]interface{}{p})
+			if _, err := ApplyProfile(zerolog.Nop()); err == nil {
+				t.Fatalf("expected error for %v", p)
+			}
+		}
+	}
+
+	viper.Reset()
+}
+
+func TestFetchCloudTags(t *testing.T) {
+	t.Log("Testing fetchCloudTags")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/azure/tagsList":
+			if r.Header.Get("Metadata") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`[{"name":"role","value":"web"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origAWS, origGCP, origAzure := awsMetadataURL, gcpMetadataURL, azureMetadataURL
+	awsMetadataURL = ts.URL + "/aws"
+	gcpMetadataURL = ts.URL + "/gcp"
+	azureMetadataURL = ts.URL + "/azure"
+	defer func() {
+		awsMetadataURL, gcpMetadataURL, azureMetadataURL = origAWS, origGCP, origAzure
+	}()
+
+	tags, err := fetchCloudTags()
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if tags["role"] != "web" {
+		t.Fatalf("expected web, got %v", tags)
+	}
+}
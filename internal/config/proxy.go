@@ -0,0 +1,53 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+var proxyNameRx = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ProxyTargets returns the local exporter urls served through /proxy/<name>, by name
+func ProxyTargets() (map[string]string, error) {
+	targets := make(map[string]string)
+	for _, spec := range viper.GetStringSlice(KeyProxyTargets) {
+		parts := strings.SplitN(strings.TrimSpace(spec), "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid proxy target (%s), expected name=url", spec)
+		}
+		name, target := parts[0], parts[1]
+		if !proxyNameRx.MatchString(name) {
+			return nil, errors.Errorf("invalid proxy target name (%s)", name)
+		}
+		if _, dup := targets[name]; dup {
+			return nil, errors.Errorf("duplicate proxy target name (%s)", name)
+		}
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "proxy target %s", name)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, errors.Errorf("invalid proxy target %s url scheme (%s), expected http or https", name, u.Scheme)
+		}
+		if u.Host == "" {
+			return nil, errors.Errorf("invalid proxy target %s url (%s), no host", name, target)
+		}
+		targets[name] = target
+	}
+	return targets, nil
+}
+
+// validateProxyTargetOptions verifies the proxy targets
+func validateProxyTargetOptions() error {
+	_, err := ProxyTargets()
+	return err
+}
@@ -0,0 +1,48 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestProxyTargets(t *testing.T) {
+	t.Log("Testing ProxyTargets")
+
+	defer viper.Set(KeyProxyTargets, []string{})
+
+	t.Log("valid")
+	{
+		viper.Set(KeyProxyTargets, []string{"node=http://localhost:9100/metrics", "app_1=https://127.0.0.1:8443/metrics?x=1"})
+		targets, err := ProxyTargets()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(targets) != 2 || targets["node"] != "http://localhost:9100/metrics" {
+			t.Fatalf("unexpected targets %v", targets)
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, spec := range []string{"node", "=http://localhost:9100", "no de=http://localhost:9100", "node=ftp://localhost/metrics", "node=/metrics"} {
+			viper.Set(KeyProxyTargets, []string{spec})
+			if err := validateProxyTargetOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", spec)
+			}
+		}
+	}
+
+	t.Log("duplicate")
+	{
+		viper.Set(KeyProxyTargets, []string{"node=http://localhost:9100/metrics", "node=http://localhost:9101/metrics"})
+		if err := validateProxyTargetOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -6,7 +6,12 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -15,6 +20,26 @@ import (
 
 func validateReverseOptions() error {
 
+	if policy := viper.GetString(KeyReverseDialPolicy); policy != "" && !IsValidDialPolicy(policy) {
+		return errors.Errorf("invalid reverse dial policy (%s)", policy)
+	}
+
+	if refresh := viper.GetString(KeyReverseBrokerCARefresh); refresh != "" {
+		d, err := time.ParseDuration(refresh)
+		if err != nil {
+			return errors.Wrap(err, "parsing reverse broker ca refresh")
+		}
+		if d < 0 {
+			return errors.Errorf("invalid reverse broker ca refresh (%s)", refresh)
+		}
+	}
+
+	if file := viper.GetString(KeyReverseAdminKeyFile); file != "" {
+		if _, err := LoadReverseAdminKey(file); err != nil {
+			return errors.Wrap(err, "reverse admin key")
+		}
+	}
+
 	cid := viper.GetString(KeyCheckBundleID)
 
 	// 1. cid = 'cosi' - try to load system check registration
@@ -45,3 +70,25 @@ func validateReverseOptions() error {
 	// valid cid or, if cid empty, reverse will search for a cid
 	return nil
 }
+
+// LoadReverseAdminKey reads the public key (ed25519, PEM encoded PKIX) used to
+// verify administrative commands received over the reverse connection
+func LoadReverseAdminKey(file string) (ed25519.PublicKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("no PEM data found (%s)", file)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing public key (%s)", file)
+	}
+	key, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("not an ed25519 public key (%s)", file)
+	}
+	return key, nil
+}
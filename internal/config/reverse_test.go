@@ -6,7 +6,14 @@
 package config
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -79,4 +86,100 @@ func TestValidateReverseOptions(t *testing.T) {
 			t.Errorf("unexpected error (%s)", err)
 		}
 	}
+
+	t.Log("Reverse, (invalid dial policy)")
+	{
+		viper.Set(KeyCheckBundleID, "123")
+		viper.Set(KeyReverseDialPolicy, "ipv5")
+		err := validateReverseOptions()
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if err.Error() != "invalid reverse dial policy (ipv5)" {
+			t.Errorf("unexpected error (%s)", err)
+		}
+		viper.Set(KeyReverseDialPolicy, "")
+	}
+
+	t.Log("Reverse, (valid dial policy)")
+	{
+		viper.Set(KeyCheckBundleID, "123")
+		viper.Set(KeyReverseDialPolicy, DialPolicyPreferIPv6)
+		err := validateReverseOptions()
+		if err != nil {
+			t.Fatalf("Expected NO error, got (%v)", err)
+		}
+		viper.Set(KeyReverseDialPolicy, "")
+	}
+
+	t.Log("Reverse, (invalid broker ca refresh)")
+	{
+		viper.Set(KeyCheckBundleID, "123")
+		for _, refresh := range []string{"abc", "-1h"} {
+			viper.Set(KeyReverseBrokerCARefresh, refresh)
+			if err := validateReverseOptions(); err == nil {
+				t.Fatalf("Expected error for (%s)", refresh)
+			}
+		}
+		viper.Set(KeyReverseBrokerCARefresh, "")
+	}
+
+	t.Log("Reverse, (valid broker ca refresh)")
+	{
+		viper.Set(KeyCheckBundleID, "123")
+		for _, refresh := range []string{"0", "12h"} {
+			viper.Set(KeyReverseBrokerCARefresh, refresh)
+			if err := validateReverseOptions(); err != nil {
+				t.Fatalf("Expected NO error, got (%v)", err)
+			}
+		}
+		viper.Set(KeyReverseBrokerCARefresh, "")
+	}
+
+	t.Log("Reverse, (admin key file)")
+	{
+		dir, err := ioutil.TempDir("", "revadmin")
+		if err != nil {
+			t.Fatalf("creating temp dir (%s)", err)
+		}
+		defer os.RemoveAll(dir)
+
+		pub, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatalf("generating key (%s)", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatalf("marshaling key (%s)", err)
+		}
+		keyFile := filepath.Join(dir, "admin.pem")
+		if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+			t.Fatalf("writing key (%s)", err)
+		}
+		badFile := filepath.Join(dir, "bad.pem")
+		if err := ioutil.WriteFile(badFile, []byte("not a key"), 0600); err != nil {
+			t.Fatalf("writing key (%s)", err)
+		}
+
+		viper.Set(KeyCheckBundleID, "123")
+		viper.Set(KeyReverseAdminKeyFile, keyFile)
+		if err := validateReverseOptions(); err != nil {
+			t.Fatalf("Expected NO error, got (%v)", err)
+		}
+		key, err := LoadReverseAdminKey(keyFile)
+		if err != nil {
+			t.Fatalf("Expected NO error, got (%v)", err)
+		}
+		if !bytes.Equal(key, pub) {
+			t.Fatal("expected key to match")
+		}
+
+		for _, file := range []string{badFile, filepath.Join(dir, "missing.pem")} {
+			viper.Set(KeyReverseAdminKeyFile, file)
+			if err := validateReverseOptions(); err == nil {
+				t.Fatalf("Expected error for (%s)", file)
+			}
+		}
+		viper.Set(KeyReverseAdminKeyFile, "")
+	}
 }
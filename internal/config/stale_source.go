@@ -0,0 +1,50 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// IsValidSource verifies a source (metric input conduit) name
+func IsValidSource(source string) bool {
+	switch source {
+	case "builtins", "plugins", "receiver", "statsd", "prometheus", "otlp", "graphite":
+		return true
+	default:
+		return false
+	}
+}
+
+// validateStaleSourceOptions verifies the stale source age and the mandatory sources
+func validateStaleSourceOptions() error {
+	age := time.Duration(0)
+	if s := viper.GetString(KeyStaleSourceAge); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.Wrap(err, "parsing stale source age")
+		}
+		if d < 0 {
+			return errors.Errorf("invalid stale source age (%s)", s)
+		}
+		age = d
+	}
+
+	sources := viper.GetStringSlice(KeyStaleSources)
+	if len(sources) > 0 && age == 0 {
+		return errors.New("stale sources require a stale source age")
+	}
+	for _, source := range sources {
+		if !IsValidSource(source) {
+			return errors.Errorf("invalid stale source (%s)", source)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateStaleSourceOptions(t *testing.T) {
+	t.Log("Testing validateStaleSourceOptions")
+
+	defer func() {
+		viper.Set(KeyStaleSourceAge, "")
+		viper.Set(KeyStaleSources, []string{})
+	}()
+
+	tests := []struct {
+		age     string
+		sources []string
+		ok      bool
+	}{
+		{"", []string{}, true},
+		{"0", []string{}, true},
+		{"5m", []string{}, true},
+		{"5m", []string{"builtins", "statsd"}, true},
+		{"0", []string{"builtins"}, false},
+		{"5m", []string{"collectors"}, false},
+		{"-1m", []string{}, false},
+		{"5", []string{}, false},
+	}
+
+	for _, tt := range tests {
+		viper.Set(KeyStaleSourceAge, tt.age)
+		viper.Set(KeyStaleSources, tt.sources)
+		err := validateStaleSourceOptions()
+		if tt.ok && err != nil {
+			t.Fatalf("expected NO error for (%s %v), got (%s)", tt.age, tt.sources, err)
+		}
+		if !tt.ok && err == nil {
+			t.Fatalf("expected error for (%s %v)", tt.age, tt.sources)
+		}
+	}
+}
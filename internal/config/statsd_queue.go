@@ -0,0 +1,42 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+const (
+	// StatsdQueuePushback readers wait for queue space, tcp clients are slowed down
+	// and udp packets are dropped by the os once the socket receive buffer fills
+	StatsdQueuePushback = "pushback"
+	// StatsdQueueDropOldest the oldest queued packet is discarded to make room
+	StatsdQueueDropOldest = "drop-oldest"
+)
+
+// IsValidStatsdQueuePolicy verifies a statsd queue policy setting
+func IsValidStatsdQueuePolicy(policy string) bool {
+	switch policy {
+	case StatsdQueuePushback, StatsdQueueDropOldest:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	// StatsdTCPFramingNewline tcp clients send newline terminated metrics
+	StatsdTCPFramingNewline = "newline"
+	// StatsdTCPFramingLength tcp clients send frames of one or more (newline
+	// separated) metrics, each prefixed with its length (4 byte, big endian)
+	StatsdTCPFramingLength = "length"
+)
+
+// IsValidStatsdTCPFraming verifies a statsd tcp framing setting
+func IsValidStatsdTCPFraming(framing string) bool {
+	switch framing {
+	case StatsdTCPFramingNewline, StatsdTCPFramingLength:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// storageLocation is a file or directory written by a subsystem
+type storageLocation struct {
+	key        string      // setting with the location
+	defaultVal string      // default of the setting, replaced when the base directory is set
+	base       string      // base directory setting (state, cache or spool dir)
+	name       string      // file or directory name within the base directory
+	isDir      bool        // location is a directory (otherwise, a file)
+	enabled    func() bool // the subsystem using the location is enabled
+}
+
+func storageLocations() []storageLocation {
+	return []storageLocation{
+		{key: KeyAuditStateFile, defaultVal: defaults.AuditStateFile, base: KeyStateDir, name: "audit.json", enabled: func() bool { return viper.GetBool(KeyAuditConfig) }},
+		{key: KeyHeartbeatStateFile, defaultVal: defaults.HeartbeatStateFile, base: KeyStateDir, name: "heartbeat.json", enabled: func() bool { return viper.GetBool(KeyHeartbeat) }},
+		{key: KeyCounterStateFile, defaultVal: defaults.CounterStateFile, base: KeyStateDir, name: "counters.json", enabled: func() bool { return viper.GetBool(KeyCounterState) }},
+		{key: KeyCheckMetricStateDir, defaultVal: defaults.CheckMetricStatePath, base: KeyStateDir, name: "metrics", isDir: true, enabled: func() bool { return viper.GetBool(KeyCheckEnableNewMetrics) }},
+		{key: KeyAPICacheDir, defaultVal: defaults.APICacheDir, base: KeyCacheDir, name: "api", isDir: true, enabled: apiRequired},
+		{key: KeyFlushArchiveDir, defaultVal: "", base: KeySpoolDir, name: "flushes", isDir: true, enabled: func() bool {
+			return viper.GetInt(KeyFlushArchiveCount) > 0 || viper.GetString(KeyFlushArchiveMaxAge) != ""
+		}},
+	}
+}
+
+// applyStorageDirs places the state, cache and spool of the subsystems in the
+// --state-dir, --cache-dir and --spool-dir directories (settings changed from
+// their default are left as-is). A location which is not writable (e.g. a
+// read-only root filesystem) is cleared and the subsystem keeps its state in
+// memory, it is not persisted across restarts.
+func applyStorageDirs() {
+	for _, loc := range storageLocations() {
+		if dir := viper.GetString(loc.base); dir != "" && viper.GetString(loc.key) == loc.defaultVal {
+			viper.Set(loc.key, filepath.Join(dir, loc.name))
+		}
+
+		path := viper.GetString(loc.key)
+		if path == "" || !loc.enabled() {
+			continue
+		}
+
+		dir := path
+		if !loc.isDir {
+			dir = filepath.Dir(path)
+		}
+		if err := verifyWritableDir(dir); err != nil {
+			log.Warn().Err(err).Str("setting", loc.key).Str("path", path).Msg("not writable, keeping state in memory")
+			viper.Set(loc.key, "")
+		}
+	}
+
+	// plugin bundle state is kept in the plugin directory when the state directory is not writable
+	if dir := viper.GetString(KeyStateDir); dir != "" && viper.GetString(KeyPluginBundleURL) != "" {
+		if err := verifyWritableDir(dir); err != nil {
+			log.Warn().Err(err).Str("setting", KeyStateDir).Str("path", dir).Msg("not writable, ignoring")
+			viper.Set(KeyStateDir, "")
+		}
+	}
+}
+
+// verifyWritableDir creates dir, if needed, and verifies a file can be written in it
+func verifyWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+	tf, err := ioutil.TempFile(dir, "verify")
+	if err != nil {
+		return errors.Wrap(err, "creating test file")
+	}
+	tf.Close()
+	if err := os.Remove(tf.Name()); err != nil {
+		return errors.Wrap(err, "removing test file")
+	}
+	return nil
+}
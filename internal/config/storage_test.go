@@ -0,0 +1,87 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestApplyStorageDirs(t *testing.T) {
+	t.Log("Testing applyStorageDirs")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a path below a file can not be created (independent of permissions)
+	notDir := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(notDir, []byte("x"), 0600); err != nil {
+		t.Fatalf("writing file (%s)", err)
+	}
+
+	keys := []string{KeyStateDir, KeySpoolDir, KeyHeartbeat, KeyHeartbeatStateFile, KeyAuditConfig, KeyAuditStateFile, KeyFlushArchiveCount, KeyFlushArchiveDir}
+	defer func() {
+		for _, key := range keys {
+			viper.Set(key, nil)
+		}
+		viper.Set(KeyHeartbeatStateFile, defaults.HeartbeatStateFile)
+		viper.Set(KeyAuditStateFile, defaults.AuditStateFile)
+	}()
+
+	t.Log("\tstate and spool dirs")
+	{
+		viper.Set(KeyStateDir, filepath.Join(dir, "state"))
+		viper.Set(KeySpoolDir, filepath.Join(dir, "spool"))
+		viper.Set(KeyHeartbeat, true)
+		viper.Set(KeyHeartbeatStateFile, defaults.HeartbeatStateFile)
+		viper.Set(KeyAuditConfig, true)
+		viper.Set(KeyAuditStateFile, filepath.Join(dir, "audit.json"))
+		viper.Set(KeyFlushArchiveCount, 10)
+		viper.Set(KeyFlushArchiveDir, "")
+
+		applyStorageDirs()
+
+		if f := viper.GetString(KeyHeartbeatStateFile); f != filepath.Join(dir, "state", "heartbeat.json") {
+			t.Fatalf("unexpected heartbeat state file (%s)", f)
+		}
+		if f := viper.GetString(KeyAuditStateFile); f != filepath.Join(dir, "audit.json") {
+			t.Fatalf("expected explicit audit state file kept, got (%s)", f)
+		}
+		if d := viper.GetString(KeyFlushArchiveDir); d != filepath.Join(dir, "spool", "flushes") {
+			t.Fatalf("unexpected flush archive dir (%s)", d)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "state")); err != nil {
+			t.Fatalf("expected state dir created, got (%s)", err)
+		}
+	}
+
+	t.Log("\tnot writable, in memory")
+	{
+		viper.Set(KeyStateDir, filepath.Join(notDir, "state"))
+		viper.Set(KeyHeartbeatStateFile, defaults.HeartbeatStateFile)
+		viper.Set(KeyAuditStateFile, filepath.Join(notDir, "audit.json"))
+
+		applyStorageDirs()
+
+		if f := viper.GetString(KeyHeartbeatStateFile); f != "" {
+			t.Fatalf("expected heartbeat state in memory, got (%s)", f)
+		}
+		if f := viper.GetString(KeyAuditStateFile); f != "" {
+			t.Fatalf("expected audit state in memory, got (%s)", f)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Check target strategies, applied in the order specified
+const (
+	TargetStrategyHostname    = "hostname"     // raw hostname (os.Hostname)
+	TargetStrategyFQDN        = "fqdn"         // fully qualified domain name (resolved via DNS)
+	TargetStrategyStripDomain = "strip-domain" // short name, everything up to the first '.'
+	TargetStrategyLowercase   = "lowercase"    // lowercase
+)
+
+// TargetInfo is the data available to check target templates
+// e.g. "{{.ShortName}}.example.com" or "{{lower .FQDN}}"
+type TargetInfo struct {
+	Hostname  string
+	FQDN      string
+	ShortName string
+}
+
+var (
+	osHostname  = os.Hostname
+	lookupCNAME = net.LookupCNAME
+)
+
+// resolveCheckTarget derives the check target (also used for the cluster node tag)
+// from the configured target strategy or template. An explicit --check-target
+// (anything other than the default hostname) always takes precedence.
+func resolveCheckTarget() error {
+	strategy := viper.GetString(KeyCheckTargetStrategy)
+	tmpl := viper.GetString(KeyCheckTargetTemplate)
+	if strategy == "" && tmpl == "" {
+		return nil
+	}
+
+	if target := viper.GetString(KeyCheckTarget); target != "" && target != defaults.CheckTarget {
+		log.Warn().Str("target", target).Msg("explicit check target set, ignoring check target strategy/template")
+		return nil
+	}
+
+	hostname, err := osHostname()
+	if err != nil {
+		return errors.Wrap(err, "check target hostname")
+	}
+
+	var target string
+	if tmpl != "" {
+		target, err = renderTargetTemplate(tmpl, hostname)
+	} else {
+		target, err = applyTargetStrategy(strategy, hostname)
+	}
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return errors.New("check target resolved to empty string")
+	}
+
+	log.Debug().Str("strategy", strategy).Str("template", tmpl).Str("target", target).Msg("resolved check target")
+	viper.Set(KeyCheckTarget, target)
+
+	return nil
+}
+
+// applyTargetStrategy applies a comma separated list of strategies to hostname
+func applyTargetStrategy(strategy, hostname string) (string, error) {
+	target := hostname
+	for _, s := range strings.Split(strategy, ",") {
+		switch strings.TrimSpace(strings.ToLower(s)) {
+		case TargetStrategyHostname:
+			target = hostname
+		case TargetStrategyFQDN:
+			target = fqdn(target)
+		case TargetStrategyStripDomain:
+			target = shortName(target)
+		case TargetStrategyLowercase:
+			target = strings.ToLower(target)
+		case "":
+		default:
+			return "", errors.Errorf("invalid check target strategy (%s)", s)
+		}
+	}
+	return target, nil
+}
+
+// renderTargetTemplate renders a check target template
+func renderTargetTemplate(tmpl, hostname string) (string, error) {
+	t, err := template.New("target").Funcs(template.FuncMap{
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}).Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing check target template")
+	}
+
+	info := TargetInfo{
+		Hostname:  hostname,
+		FQDN:      fqdn(hostname),
+		ShortName: shortName(hostname),
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, info); err != nil {
+		return "", errors.Wrap(err, "executing check target template")
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// fqdn resolves the fully qualified domain name for host, returns host if it cannot be resolved
+func fqdn(host string) string {
+	cname, err := lookupCNAME(host)
+	if err != nil || cname == "" {
+		log.Warn().Err(err).Str("host", host).Msg("unable to resolve fqdn, using hostname")
+		return host
+	}
+	return strings.TrimSuffix(cname, ".")
+}
+
+// shortName returns host up to the first '.'
+func shortName(host string) string {
+	if idx := strings.Index(host, "."); idx > 0 {
+		return host[:idx]
+	}
+	return host
+}
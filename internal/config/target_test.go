@@ -0,0 +1,87 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestResolveCheckTarget(t *testing.T) {
+	t.Log("Testing resolveCheckTarget")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	origHostname, origLookup := osHostname, lookupCNAME
+	defer func() {
+		osHostname, lookupCNAME = origHostname, origLookup
+	}()
+	osHostname = func() (string, error) { return "Web01", nil }
+	lookupCNAME = func(host string) (string, error) { return host + ".Example.com.", nil }
+
+	tests := []struct {
+		desc     string
+		target   string
+		strategy string
+		tmpl     string
+		expect   string
+		err      bool
+	}{
+		{"none", "foo", "", "", "foo", false},
+		{"hostname", defaults.CheckTarget, "hostname", "", "Web01", false},
+		{"fqdn", defaults.CheckTarget, "fqdn", "", "Web01.Example.com", false},
+		{"fqdn,lowercase", defaults.CheckTarget, "fqdn, lowercase", "", "web01.example.com", false},
+		{"strip-domain,lowercase", defaults.CheckTarget, "fqdn,strip-domain,lowercase", "", "web01", false},
+		{"invalid", defaults.CheckTarget, "foo", "", "", true},
+		{"template", defaults.CheckTarget, "", "{{lower .ShortName}}.prod", "web01.prod", false},
+		{"template fqdn", defaults.CheckTarget, "lowercase", "{{.FQDN}}", "Web01.Example.com", false},
+		{"template invalid", defaults.CheckTarget, "", "{{.Foo", "", true},
+		{"template empty", defaults.CheckTarget, "", "{{/* */}}", "", true},
+		{"explicit override", "10.1.2.3", "fqdn,lowercase", "", "10.1.2.3", false},
+	}
+
+	for _, test := range tests {
+		tst := test
+		t.Logf("\t%s", tst.desc)
+		viper.Reset()
+		viper.Set(KeyCheckTarget, tst.target)
+		viper.Set(KeyCheckTargetStrategy, tst.strategy)
+		viper.Set(KeyCheckTargetTemplate, tst.tmpl)
+		err := resolveCheckTarget()
+		if tst.err {
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if got := viper.GetString(KeyCheckTarget); got != tst.expect {
+			t.Fatalf("expected (%s) got (%s)", tst.expect, got)
+		}
+	}
+
+	t.Log("\tfqdn lookup failure, fallback to hostname")
+	{
+		lookupCNAME = func(host string) (string, error) { return "", errors.New("no such host") }
+		viper.Reset()
+		viper.Set(KeyCheckTarget, defaults.CheckTarget)
+		viper.Set(KeyCheckTargetStrategy, "fqdn")
+		if err := resolveCheckTarget(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if got := viper.GetString(KeyCheckTarget); got != "Web01" {
+			t.Fatalf("expected (Web01) got (%s)", got)
+		}
+	}
+
+	viper.Reset()
+}
@@ -0,0 +1,29 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// validateTextMetricResendOptions verifies the text metric resend interval, empty submits text metrics with every flush
+func validateTextMetricResendOptions() error {
+	resend := viper.GetString(KeyTextMetricResend)
+	if resend == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(resend)
+	if err != nil {
+		return errors.Wrap(err, "parsing text metric resend")
+	}
+	if d < 0 {
+		return errors.Errorf("invalid text metric resend (%s)", resend)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateTextMetricResendOptions(t *testing.T) {
+	t.Log("Testing validateTextMetricResendOptions")
+
+	t.Log("valid")
+	{
+		for _, r := range []string{"", "0", "10m", "1h30m"} {
+			viper.Set(KeyTextMetricResend, r)
+			if err := validateTextMetricResendOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", r, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, r := range []string{"10", "-5m", "soon"} {
+			viper.Set(KeyTextMetricResend, r)
+			if err := validateTextMetricResendOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", r)
+			}
+		}
+	}
+
+	viper.Set(KeyTextMetricResend, "")
+}
@@ -0,0 +1,37 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// validateWatchdogOptions verifies the watchdog interval, collector timeout and stuck factor
+func validateWatchdogOptions() error {
+	if !viper.GetBool(KeyWatchdog) {
+		return nil
+	}
+
+	for _, key := range []string{KeyWatchdogInterval, KeyWatchdogCollectorTimeout} {
+		val := viper.GetString(key)
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return errors.Wrapf(err, "parsing %s", key)
+		}
+		if d <= 0 {
+			return errors.Errorf("invalid %s (%s), must be greater than zero", key, val)
+		}
+	}
+
+	if f := viper.GetInt(KeyWatchdogStuckFactor); f < 1 {
+		return errors.Errorf("invalid %s (%d), must be at least 1", KeyWatchdogStuckFactor, f)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateWatchdogOptions(t *testing.T) {
+	t.Log("Testing validateWatchdogOptions")
+
+	t.Log("disabled")
+	{
+		viper.Set(KeyWatchdog, false)
+		viper.Set(KeyWatchdogInterval, "never")
+		if err := validateWatchdogOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	viper.Set(KeyWatchdog, true)
+	viper.Set(KeyWatchdogStuckFactor, 3)
+
+	t.Log("valid")
+	{
+		viper.Set(KeyWatchdogInterval, "30s")
+		viper.Set(KeyWatchdogCollectorTimeout, "1m")
+		if err := validateWatchdogOptions(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("invalid interval")
+	{
+		for _, d := range []string{"", "30", "0s", "-5s"} {
+			viper.Set(KeyWatchdogInterval, d)
+			if err := validateWatchdogOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", d)
+			}
+		}
+		viper.Set(KeyWatchdogInterval, "30s")
+	}
+
+	t.Log("invalid collector timeout")
+	{
+		viper.Set(KeyWatchdogCollectorTimeout, "0")
+		if err := validateWatchdogOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+		viper.Set(KeyWatchdogCollectorTimeout, "1m")
+	}
+
+	t.Log("invalid stuck factor")
+	{
+		viper.Set(KeyWatchdogStuckFactor, 0)
+		if err := validateWatchdogOptions(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	viper.Set(KeyWatchdog, false)
+}
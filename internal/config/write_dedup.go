@@ -0,0 +1,29 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// validateWriteDedupOptions verifies the /write idempotency key ttl, empty or 0 ignores idempotency keys
+func validateWriteDedupOptions() error {
+	ttl := viper.GetString(KeyWriteDedupTTL)
+	if ttl == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return errors.Wrap(err, "parsing write dedup ttl")
+	}
+	if d < 0 {
+		return errors.Errorf("invalid write dedup ttl (%s)", ttl)
+	}
+	return nil
+}
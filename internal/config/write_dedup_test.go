@@ -0,0 +1,38 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateWriteDedupOptions(t *testing.T) {
+	t.Log("Testing validateWriteDedupOptions")
+
+	t.Log("valid")
+	{
+		for _, ttl := range []string{"", "0", "5m", "1h"} {
+			viper.Set(KeyWriteDedupTTL, ttl)
+			if err := validateWriteDedupOptions(); err != nil {
+				t.Fatalf("expected NO error for (%s), got (%s)", ttl, err)
+			}
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, ttl := range []string{"300", "-5m", "later"} {
+			viper.Set(KeyWriteDedupTTL, ttl)
+			if err := validateWriteDedupOptions(); err == nil {
+				t.Fatalf("expected error for (%s)", ttl)
+			}
+		}
+	}
+
+	viper.Set(KeyWriteDedupTTL, "")
+}
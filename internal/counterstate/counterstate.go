@@ -0,0 +1,201 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package counterstate persists counter state across agent restarts - the
+// last-known raw values of counters (so collectors deriving rates from them
+// do not produce a spike after a restart) and StatsD counters accumulated
+// since the last flush (so a partially aggregated window is not lost).
+package counterstate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// state is persisted to the state file
+type state struct {
+	Saved    time.Time         `json:"saved"`
+	Counters map[string]uint64 `json:"counters"` // last-known raw counter values, keyed by collector id and counter name
+	Statsd   map[string]uint64 `json:"statsd"`   // statsd counters not yet flushed, keyed by metric name with stream tags
+}
+
+// Store holds the counter state
+type Store struct {
+	file     string
+	state    state
+	restored map[string]uint64 // statsd counters saved when the agent last stopped
+	logger   zerolog.Logger
+	sync.Mutex
+}
+
+// New loads the counter state saved when the agent last stopped, returns
+// nil if counter state persistence is not enabled. The restored StatsD
+// counters are removed from the state file, so they are only applied once
+// even if the agent does not stop cleanly.
+func New() (*Store, error) {
+	if !viper.GetBool(config.KeyCounterState) {
+		return nil, nil
+	}
+
+	// an empty state file keeps the state in memory (e.g. read-only filesystem), it is not saved
+	stateFile := viper.GetString(config.KeyCounterStateFile)
+
+	s := &Store{
+		file:   stateFile,
+		logger: log.With().Str("pkg", "counterstate").Logger(),
+		state: state{
+			Counters: make(map[string]uint64),
+			Statsd:   make(map[string]uint64),
+		},
+	}
+
+	prev, err := loadState(stateFile)
+	switch {
+	case stateFile == "":
+		s.logger.Info().Msg("no counter state file, state kept in memory")
+	case err == nil:
+		if prev.Counters != nil {
+			s.state.Counters = prev.Counters
+		}
+		s.restored = prev.Statsd
+		s.logger.Info().Int("counters", len(s.state.Counters)).Int("statsd", len(s.restored)).Time("saved", prev.Saved).Msg("restored counter state")
+	case os.IsNotExist(errors.Cause(err)):
+		s.logger.Info().Str("file", stateFile).Msg("no previous counter state")
+	default:
+		s.logger.Warn().Err(err).Str("file", stateFile).Msg("ignoring previous counter state")
+	}
+
+	if len(s.restored) > 0 {
+		s.state.Saved = time.Now().UTC()
+		if err := saveState(stateFile, &s.state); err != nil {
+			s.logger.Warn().Err(err).Str("file", stateFile).Msg("clearing restored statsd counters")
+		}
+	}
+
+	return s, nil
+}
+
+// Counter returns the last-known raw value of a counter
+func (s *Store) Counter(id, name string) (uint64, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	v, ok := s.state.Counters[id+defaults.MetricNameSeparator+name]
+	return v, ok
+}
+
+// SetCounter records the raw value of a counter
+func (s *Store) SetCounter(id, name string, v uint64) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	s.state.Counters[id+defaults.MetricNameSeparator+name] = v
+	s.Unlock()
+}
+
+// TakeStatsd returns the restored StatsD counters (metric name with stream
+// tags and accumulated value), subsequent calls return nothing
+func (s *Store) TakeStatsd() map[string]uint64 {
+	if s == nil {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	counters := s.restored
+	s.restored = nil
+	return counters
+}
+
+// SetStatsd records the StatsD counters not yet flushed, to be saved
+func (s *Store) SetStatsd(counters map[string]uint64) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	s.state.Statsd = make(map[string]uint64, len(counters))
+	for name, v := range counters {
+		s.state.Statsd[name] = v
+	}
+	s.Unlock()
+}
+
+// Save writes the counter state to the state file (call when the agent stops)
+func (s *Store) Save() error {
+	if s == nil {
+		return nil
+	}
+
+	if s.file == "" {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.state.Saved = time.Now().UTC()
+	if err := saveState(s.file, &s.state); err != nil {
+		return err
+	}
+
+	s.logger.Info().Int("counters", len(s.state.Counters)).Int("statsd", len(s.state.Statsd)).Msg("saved counter state")
+	return nil
+}
+
+func loadState(file string) (*state, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading state file")
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, errors.Wrap(err, "parsing state file")
+	}
+
+	return &s, nil
+}
+
+func saveState(file string, s *state) error {
+	sf, err := ioutil.TempFile(filepath.Dir(file), "counters")
+	if err != nil {
+		return errors.Wrap(err, "creating temp state file")
+	}
+
+	enc := json.NewEncoder(sf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		sf.Close()
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "error encoding state (removing temp file)")
+	}
+
+	sf.Close()
+	if err := os.Rename(sf.Name(), file); err != nil {
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "updating state file (removing temp file)")
+	}
+
+	return nil
+}
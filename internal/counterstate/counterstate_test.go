@@ -0,0 +1,131 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package counterstate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	dir, err := ioutil.TempDir("", "counterstate")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "counters.json")
+
+	t.Log("\tnot enabled")
+	{
+		viper.Reset()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if s != nil {
+			t.Fatal("expected nil")
+		}
+		if err := s.Save(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if _, ok := s.Counter("cpu", "user"); ok {
+			t.Fatal("expected no counter")
+		}
+	}
+
+	t.Log("\tno state file (in memory)")
+	{
+		viper.Reset()
+		viper.Set(config.KeyCounterState, true)
+		s, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		s.SetCounter("foo", "bar", 1)
+		if v, ok := s.Counter("foo", "bar"); !ok || v != 1 {
+			t.Fatalf("expected 1, got %d", v)
+		}
+		if err := s.Save(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	viper.Reset()
+	viper.Set(config.KeyCounterState, true)
+	viper.Set(config.KeyCounterStateFile, stateFile)
+
+	t.Log("\tfirst start")
+	{
+		s, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c := s.TakeStatsd(); len(c) != 0 {
+			t.Fatalf("expected no statsd counters, got %v", c)
+		}
+		s.SetCounter("cpu", "user", 100)
+		s.SetStatsd(map[string]uint64{"foo": 3})
+		if err := s.Save(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("\trestart")
+	{
+		s, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if v, ok := s.Counter("cpu", "user"); !ok || v != 100 {
+			t.Fatalf("expected cpu user 100, got %d", v)
+		}
+		if c := s.TakeStatsd(); len(c) != 1 || c["foo"] != 3 {
+			t.Fatalf("expected statsd foo 3, got %v", c)
+		}
+		if c := s.TakeStatsd(); len(c) != 0 {
+			t.Fatalf("expected statsd counters taken, got %v", c)
+		}
+	}
+
+	t.Log("\tunclean stop (statsd counters only restored once)")
+	{
+		s, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if c := s.TakeStatsd(); len(c) != 0 {
+			t.Fatalf("expected no statsd counters, got %v", c)
+		}
+		if v, ok := s.Counter("cpu", "user"); !ok || v != 100 {
+			t.Fatalf("expected cpu user 100, got %d", v)
+		}
+	}
+
+	t.Log("\tinvalid state file")
+	{
+		if err := ioutil.WriteFile(stateFile, []byte("{"), 0644); err != nil {
+			t.Fatalf("writing state file (%s)", err)
+		}
+		s, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if _, ok := s.Counter("cpu", "user"); ok {
+			t.Fatal("expected no counter")
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package errs defines categorized agent errors. The category and retryable
+// classification of an error is used to decide whether to retry or abort,
+// rather than matching on error text, and recent errors are tracked by
+// category for reporting in /health.
+package errs
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Category identifies the class of an error
+type Category string
+
+// Error categories
+const (
+	Config    Category = "config"            // invalid configuration, not retryable
+	Network   Category = "transient-network" // network/api unavailable, retryable
+	Broker    Category = "broker"            // broker communication
+	Collector Category = "collector"         // builtin collector
+	Plugin    Category = "plugin"            // plugin execution
+	Unknown   Category = "unknown"           // uncategorized
+)
+
+// Error is an error with a category and retryable classification
+type Error struct {
+	Category  Category
+	Retryable bool
+	Err       error
+}
+
+// Error returns the message of the underlying error
+func (e *Error) Error() string {
+	if e == nil || e.Err == nil {
+		return "<nil>"
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the underlying error (github.com/pkg/errors compatible)
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// New categorizes an error, returns nil if err is nil
+func New(cat Category, retryable bool, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: cat, Retryable: retryable, Err: err}
+}
+
+// NewConfig categorizes err as a (not retryable) configuration error
+func NewConfig(err error) error {
+	return New(Config, false, err)
+}
+
+// NewNetwork categorizes err as a (retryable) transient network error
+func NewNetwork(err error) error {
+	return New(Network, true, err)
+}
+
+// NewBroker categorizes err as a broker error
+func NewBroker(err error, retryable bool) error {
+	return New(Broker, retryable, err)
+}
+
+// NewCollector categorizes err as a (retryable) builtin collector error
+func NewCollector(err error) error {
+	return New(Collector, true, err)
+}
+
+// NewPlugin categorizes err as a (retryable) plugin error
+func NewPlugin(err error) error {
+	return New(Plugin, true, err)
+}
+
+// CategoryOf returns the category of err, uncategorized net errors
+// are treated as transient network errors
+func CategoryOf(err error) Category {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Category
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return Network
+	}
+	return Unknown
+}
+
+// IsRetryable returns whether the operation resulting in err may succeed if retried
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Retryable
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return ne.Timeout() || ne.Temporary()
+	}
+	return false
+}
+
+// Stat is the error history for a category
+type Stat struct {
+	Count     uint64    `json:"count"`
+	Last      time.Time `json:"last"`
+	Message   string    `json:"message"`
+	Retryable bool      `json:"retryable"`
+}
+
+var (
+	stats   = make(map[Category]*Stat)
+	statsmu sync.Mutex
+)
+
+// Record tracks err by category, for reporting in /health
+func Record(err error) {
+	if err == nil {
+		return
+	}
+	cat := CategoryOf(err)
+	statsmu.Lock()
+	defer statsmu.Unlock()
+	s, ok := stats[cat]
+	if !ok {
+		s = &Stat{}
+		stats[cat] = s
+	}
+	s.Count++
+	s.Last = time.Now()
+	s.Message = err.Error()
+	s.Retryable = IsRetryable(err)
+}
+
+// Stats returns a copy of the recorded error history
+func Stats() map[Category]Stat {
+	statsmu.Lock()
+	defer statsmu.Unlock()
+	out := make(map[Category]Stat, len(stats))
+	for cat, s := range stats {
+		out[cat] = *s
+	}
+	return out
+}
+
+// Reset clears the recorded error history
+func Reset() {
+	statsmu.Lock()
+	stats = make(map[Category]*Stat)
+	statsmu.Unlock()
+}
@@ -0,0 +1,87 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package errs
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestClassification(t *testing.T) {
+	t.Log("Testing error classification")
+
+	tests := []struct {
+		desc      string
+		err       error
+		category  Category
+		retryable bool
+	}{
+		{"nil", nil, "", false},
+		{"uncategorized", errors.New("foo"), Unknown, false},
+		{"config", NewConfig(errors.New("foo")), Config, false},
+		{"network", NewNetwork(errors.New("foo")), Network, true},
+		{"broker retry", NewBroker(errors.New("foo"), true), Broker, true},
+		{"broker no retry", NewBroker(errors.New("foo"), false), Broker, false},
+		{"collector", NewCollector(errors.New("foo")), Collector, true},
+		{"plugin", NewPlugin(errors.New("foo")), Plugin, true},
+		{"wrapped", errors.Wrap(NewConfig(errors.New("foo")), "bar"), Config, false},
+		{"net error", &net.DNSError{Err: "foo", IsTimeout: true}, Network, true},
+		{"wrapped net error", errors.Wrap(&net.DNSError{Err: "foo"}, "bar"), Network, false},
+	}
+
+	for _, test := range tests {
+		tst := test
+		t.Logf("\t%s", tst.desc)
+		if cat := CategoryOf(tst.err); cat != tst.category {
+			t.Fatalf("expected category (%s) got (%s)", tst.category, cat)
+		}
+		if r := IsRetryable(tst.err); r != tst.retryable {
+			t.Fatalf("expected retryable %v got %v", tst.retryable, r)
+		}
+	}
+
+	t.Log("\tmessage and cause")
+	{
+		orig := errors.New("foo")
+		err := NewConfig(orig)
+		if err.Error() != "foo" {
+			t.Fatalf("expected (foo) got (%s)", err)
+		}
+		if errors.Cause(err) != orig {
+			t.Fatal("expected cause to be original error")
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	t.Log("Testing Record")
+
+	Reset()
+	defer Reset()
+
+	Record(nil)
+	if len(Stats()) != 0 {
+		t.Fatal("expected no stats")
+	}
+
+	Record(NewPlugin(errors.New("foo")))
+	Record(NewPlugin(errors.New("bar")))
+	Record(NewConfig(errors.New("baz")))
+
+	stats := Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 categories, got %d", len(stats))
+	}
+	ps := stats[Plugin]
+	if ps.Count != 2 || ps.Message != "bar" || !ps.Retryable {
+		t.Fatalf("unexpected plugin stat %#v", ps)
+	}
+	if cs := stats[Config]; cs.Count != 1 || cs.Retryable {
+		t.Fatalf("unexpected config stat %#v", cs)
+	}
+}
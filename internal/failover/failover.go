@@ -0,0 +1,234 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package failover mirrors metrics of clustered services (e.g. SQL Server or
+// file server roles in a Windows failover cluster) to a secondary metric
+// stream tagged with the cluster resource network name. Only the node
+// currently owning the resource (the node the resource address is bound to)
+// emits the secondary stream, so it follows the service across failovers.
+package failover
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// OwnerMetric is emitted for each resource, 1 on the owning node, 0 otherwise
+	OwnerMetric = "cluster_resource_owner"
+	// TagCategory is the stream tag category of the secondary metric stream
+	TagCategory = "cluster-resource"
+
+	resolveInterval = 5 * time.Minute
+)
+
+var (
+	interfaceAddrs = net.InterfaceAddrs
+	lookupHost     = net.LookupHost
+)
+
+type resource struct {
+	name      string
+	static    bool           // address configured, not resolved from name
+	metricRx  *regexp.Regexp // nil, all metrics
+	streamTag string         // encoded stream tag added to mirrored metrics
+	ownerName string         // owner metric name, with stream tags
+	addrs     []net.IP
+	resolved  time.Time
+}
+
+// Resources mirrors metrics for the configured failover resources
+type Resources struct {
+	resources []*resource
+	logger    zerolog.Logger
+	sync.Mutex
+}
+
+// New loads the failover resources from the configuration, returns nil if
+// no resources are configured
+func New() (*Resources, error) {
+	var cfg []config.FailoverResource
+	if err := viper.UnmarshalKey(config.KeyFailoverResources, &cfg); err != nil {
+		return nil, errors.Wrap(err, "parsing failover resources")
+	}
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+
+	r := &Resources{
+		resources: make([]*resource, 0, len(cfg)),
+		logger:    log.With().Str("pkg", "failover").Logger(),
+	}
+
+	baseTags := tags.FromList(tags.GetBaseTags())
+	for idx, rcfg := range cfg {
+		res, err := newResource(rcfg, baseTags)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failover resource %d (%s)", idx, rcfg.Name)
+		}
+		r.resources = append(r.resources, res)
+	}
+
+	r.logger.Info().Int("resources", len(r.resources)).Msg("loaded failover resources")
+
+	return r, nil
+}
+
+func newResource(cfg config.FailoverResource, baseTags tags.Tags) (*resource, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("missing name")
+	}
+
+	resTag := tags.Tag{Category: TagCategory, Value: cfg.Name}
+	res := &resource{
+		name:      cfg.Name,
+		streamTag: tags.EncodeMetricStreamTags(tags.Tags{resTag}),
+	}
+
+	ownerTags := make(tags.Tags, 0, len(baseTags)+1)
+	ownerTags = append(ownerTags, baseTags...)
+	ownerTags = append(ownerTags, resTag)
+	res.ownerName = tags.MetricNameWithStreamTags(OwnerMetric, ownerTags)
+
+	if cfg.Address != "" {
+		ip := net.ParseIP(cfg.Address)
+		if ip == nil {
+			return nil, errors.Errorf("invalid address (%s)", cfg.Address)
+		}
+		res.addrs = []net.IP{ip}
+		res.static = true
+	}
+
+	if cfg.MetricFilter != "" {
+		rx, err := regexp.Compile(cfg.MetricFilter)
+		if err != nil {
+			return nil, errors.Wrap(err, "metric_filter")
+		}
+		res.metricRx = rx
+	}
+
+	return res, nil
+}
+
+// Apply adds the owner metric for each resource and, for the resources owned
+// by this node, a copy of each matching metric tagged with the resource name
+func (r *Resources) Apply(metrics *cgm.Metrics) {
+	if r == nil || metrics == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	local, err := localAddrs()
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("listing local addresses, skipping failover resources")
+		return
+	}
+
+	// names are collected first, mirrored metrics are not mirrored again
+	names := make([]string, 0, len(*metrics))
+	for name := range *metrics {
+		names = append(names, name)
+	}
+
+	now := time.Now()
+	for _, res := range r.resources {
+		if !res.static && now.Sub(res.resolved) >= resolveInterval {
+			r.resolve(res, now)
+		}
+
+		owned := res.ownedBy(local)
+		owner := uint32(0)
+		if owned {
+			owner = 1
+		}
+		(*metrics)[res.ownerName] = cgm.Metric{Value: owner, Type: "I"}
+
+		if !owned {
+			continue
+		}
+
+		mirrored := 0
+		for _, name := range names {
+			if res.metricRx != nil && !res.metricRx.MatchString(name) {
+				continue
+			}
+			(*metrics)[withStreamTag(name, res.streamTag)] = (*metrics)[name]
+			mirrored++
+		}
+		r.logger.Debug().Str("resource", res.name).Int("metrics", mirrored).Msg("mirrored failover resource metrics")
+	}
+}
+
+// resolve looks up the resource addresses from its name, the previous
+// addresses are retained if the lookup fails
+func (r *Resources) resolve(res *resource, now time.Time) {
+	res.resolved = now
+
+	hosts, err := lookupHost(res.name)
+	if err != nil {
+		r.logger.Warn().Err(err).Str("resource", res.name).Msg("resolving failover resource address")
+		return
+	}
+
+	addrs := make([]net.IP, 0, len(hosts))
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			addrs = append(addrs, ip)
+		}
+	}
+	res.addrs = addrs
+}
+
+// ownedBy returns true if any resource address is a local address
+func (res *resource) ownedBy(local []net.IP) bool {
+	for _, a := range res.addrs {
+		for _, l := range local {
+			if a.Equal(l) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// localAddrs returns the addresses bound to the local interfaces
+func localAddrs() ([]net.IP, error) {
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		switch v := a.(type) {
+		case *net.IPNet:
+			ips = append(ips, v.IP)
+		case *net.IPAddr:
+			ips = append(ips, v.IP)
+		}
+	}
+	return ips, nil
+}
+
+// withStreamTag adds an encoded stream tag to a metric name, appending to
+// the existing stream tags if the name has any
+func withStreamTag(name, streamTag string) string {
+	if strings.Contains(name, "|ST[") && strings.HasSuffix(name, "]") {
+		return name[:len(name)-1] + tags.Separator + streamTag + "]"
+	}
+	return name + "|ST[" + streamTag + "]"
+}
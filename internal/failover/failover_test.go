@@ -0,0 +1,159 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package failover
+
+import (
+	"net"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func stubNet(t *testing.T, local []string, resolved map[string][]string) func() {
+	t.Helper()
+	origAddrs, origLookup := interfaceAddrs, lookupHost
+	interfaceAddrs = func() ([]net.Addr, error) {
+		addrs := make([]net.Addr, 0, len(local))
+		for _, a := range local {
+			addrs = append(addrs, &net.IPNet{IP: net.ParseIP(a), Mask: net.CIDRMask(24, 32)})
+		}
+		return addrs, nil
+	}
+	lookupHost = func(host string) ([]string, error) {
+		if addrs, ok := resolved[host]; ok {
+			return addrs, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	return func() { interfaceAddrs, lookupHost = origAddrs, origLookup }
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	t.Log("\tnone configured")
+	{
+		r, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if r != nil {
+			t.Fatal("expected nil")
+		}
+	}
+
+	tests := []struct {
+		name string
+		res  config.FailoverResource
+	}{
+		{"missing name", config.FailoverResource{Address: "10.0.0.50"}},
+		{"invalid address", config.FailoverResource{Name: "sqlprod", Address: "10.0.0"}},
+		{"invalid metric filter", config.FailoverResource{Name: "sqlprod", MetricFilter: "("}},
+	}
+	for _, test := range tests {
+		t.Logf("\t%s", test.name)
+		viper.Set(config.KeyFailoverResources, []config.FailoverResource{test.res})
+		if _, err := New(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tvalid")
+	{
+		viper.Set(config.KeyFailoverResources, []config.FailoverResource{{Name: "sqlprod", MetricFilter: "^mssql"}})
+		r, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(r.resources) != 1 {
+			t.Fatalf("expected 1 resource, got %d", len(r.resources))
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Log("Testing Apply")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+	defer stubNet(t, []string{"10.0.0.10", "10.0.0.50"}, map[string][]string{"fileprod": {"10.0.0.60"}})()
+
+	viper.Set(config.KeyFailoverResources, []config.FailoverResource{
+		{Name: "sqlprod", Address: "10.0.0.50", MetricFilter: "^mssql"},
+		{Name: "fileprod"},
+	})
+	r, err := New()
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	tagged := tags.MetricNameWithStreamTags("mssql_connections", tags.Tags{{Category: "instance", Value: "default"}})
+	metrics := cgm.Metrics{
+		"mssql_batches": cgm.Metric{Value: 10, Type: "L"},
+		tagged:          cgm.Metric{Value: 5, Type: "L"},
+		"cpu_used":      cgm.Metric{Value: 20.5, Type: "n"},
+	}
+
+	r.Apply(&metrics)
+
+	sqlTag := tags.EncodeMetricStreamTags(tags.Tags{{Category: TagCategory, Value: "sqlprod"}})
+	fileTag := tags.EncodeMetricStreamTags(tags.Tags{{Category: TagCategory, Value: "fileprod"}})
+
+	if m, ok := metrics["mssql_batches|ST["+sqlTag+"]"]; !ok || m.Value.(int) != 10 {
+		t.Fatalf("expected mirrored mssql_batches, got %v", metrics)
+	}
+	if _, ok := metrics[tagged[:len(tagged)-1]+","+sqlTag+"]"]; !ok {
+		t.Fatalf("expected mirrored tagged metric, got %v", metrics)
+	}
+	if _, ok := metrics["cpu_used|ST["+sqlTag+"]"]; ok {
+		t.Fatal("expected cpu_used to not match metric filter")
+	}
+	if m, ok := metrics[OwnerMetric+"|ST["+sqlTag+"]"]; !ok || m.Value.(uint32) != 1 {
+		t.Fatalf("expected sqlprod owner 1, got %v", m.Value)
+	}
+
+	// fileprod resolves to an address not bound locally
+	if m, ok := metrics[OwnerMetric+"|ST["+fileTag+"]"]; !ok || m.Value.(uint32) != 0 {
+		t.Fatalf("expected fileprod owner 0, got %v", m.Value)
+	}
+	if _, ok := metrics["cpu_used|ST["+fileTag+"]"]; ok {
+		t.Fatal("expected no metrics mirrored for fileprod")
+	}
+	if len(metrics) != 7 {
+		t.Fatalf("expected 7 metrics, got %d (%v)", len(metrics), metrics)
+	}
+
+	t.Log("\tnil")
+	{
+		var nr *Resources
+		nr.Apply(&metrics)
+	}
+}
+
+func TestWithStreamTag(t *testing.T) {
+	t.Log("Testing withStreamTag")
+
+	tests := []struct {
+		name   string
+		expect string
+	}{
+		{"foo", `foo|ST[b"eA==":b"eQ=="]`},
+		{`foo|ST[b"YQ==":b"Yg=="]`, `foo|ST[b"YQ==":b"Yg==",b"eA==":b"eQ=="]`},
+	}
+	for _, test := range tests {
+		if name := withStreamTag(test.name, `b"eA==":b"eQ=="`); name != test.expect {
+			t.Fatalf("expected (%s) got (%s)", test.expect, name)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package graphite receives metrics from legacy carbon clients using the
+// graphite plaintext protocol (path value timestamp, one metric per line)
+// over tcp. Metric paths are converted to metric names with stream tags by
+// the mapping rules and included in the check output with the next flush.
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/pending"
+	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// maxLineSize limits the size of a line (metric)
+	maxLineSize = 64 * 1024
+
+	// idleTimeout closes connections without writes for this long
+	idleTimeout = 5 * time.Minute
+)
+
+// Server defines the graphite listener
+type Server struct {
+	disabled  bool
+	address   string
+	ctx       context.Context
+	listener  net.Listener
+	conns     map[string]net.Conn
+	connsmu   sync.Mutex
+	mappings  []mapping
+	metrics   *cgm.CirconusMetrics
+	metricsmu sync.Mutex
+	pending   *pending.Limiter // series written since the last flush (see max pending series)
+	dropped   uint64           // lines not recorded since the last flush
+	baseTags  []string
+	logger    zerolog.Logger
+}
+
+// New returns a graphite listener, disabled when no address is configured
+func New(ctx context.Context) (*Server, error) {
+	s := Server{
+		disabled: viper.GetString(config.KeyGraphiteAddr) == "",
+		logger:   log.With().Str("pkg", "graphite").Logger(),
+	}
+
+	if s.disabled {
+		s.logger.Info().Msg("disabled, not configuring")
+		return &s, nil
+	}
+
+	mappings, err := config.GraphiteMappings()
+	if err != nil {
+		return nil, errors.Wrap(err, "graphite mapping")
+	}
+
+	s.ctx = ctx
+	s.address = viper.GetString(config.KeyGraphiteAddr)
+	s.conns = make(map[string]net.Conn)
+	s.mappings = newMappings(mappings)
+	s.pending = pending.NewLimiter(viper.GetUint(config.KeyMaxPendingSeries))
+	s.baseTags = append(tags.GetBaseTags(), []string{
+		"source:" + release.NAME,
+		"collector:graphite",
+	}...)
+
+	cmc := &cgm.Config{
+		Debug: viper.GetBool(config.KeyDebugCGM),
+		Log:   logshim{logh: s.logger.With().Str("pkg", "cgm.graphite").Logger()},
+	}
+	// put cgm into manual mode (no interval, no api key, invalid submission url)
+	cmc.Interval = "0"                            // disable automatic flush
+	cmc.CheckManager.Check.SubmissionURL = "none" // disable check management (create/update)
+
+	hm, err := cgm.NewCirconusMetrics(cmc)
+	if err != nil {
+		return nil, errors.Wrap(err, "graphite cgm")
+	}
+	s.metrics = hm
+
+	return &s, nil
+}
+
+// logshim is used to satisfy apiclient Logger interface (avoiding ptr receiver issue)
+type logshim struct {
+	logh zerolog.Logger
+}
+
+func (l logshim) Printf(fmt string, v ...interface{}) {
+	l.logh.Printf(fmt, v...)
+}
+
+// Enabled returns true if the graphite listener is configured
+func (s *Server) Enabled() bool {
+	return s != nil && !s.disabled
+}
+
+// Start the graphite listener
+func (s *Server) Start() error {
+	if s.disabled {
+		s.logger.Info().Msg("disabled, not starting listener")
+		return nil
+	}
+
+	l, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return errors.Wrap(err, "graphite listener")
+	}
+	s.connsmu.Lock()
+	s.listener = l
+	s.connsmu.Unlock()
+
+	go func() {
+		<-s.ctx.Done()
+		s.Stop()
+	}()
+
+	s.logger.Info().Str("listen", l.Addr().String()).Msg("graphite listener starting (tcp)")
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.stopped() {
+				return nil
+			}
+			if nerr, ok := err.(net.Error); ok && nerr.Temporary() {
+				s.logger.Warn().Err(err).Msg("accepting graphite connection")
+				continue
+			}
+			return errors.Wrap(err, "graphite listener")
+		}
+		s.addConnection(conn)
+		go s.reader(conn)
+	}
+}
+
+// Stop the graphite listener and close open connections
+func (s *Server) Stop() {
+	if s.disabled {
+		return
+	}
+	s.connsmu.Lock()
+	defer s.connsmu.Unlock()
+	if s.listener == nil {
+		return
+	}
+	s.logger.Info().Msg("stopping graphite listener")
+	if err := s.listener.Close(); err != nil {
+		s.logger.Warn().Err(err).Msg("closing graphite listener")
+	}
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.listener = nil
+}
+
+// Flush returns the metrics received since the last flush
+func (s *Server) Flush() *cgm.Metrics {
+	if s.disabled {
+		return nil
+	}
+
+	s.metricsmu.Lock()
+	defer s.metricsmu.Unlock()
+
+	pendingDropped := s.pending.Reset()
+	m := s.metrics.FlushMetrics()
+	baseTags := tags.FromList(s.baseTags)
+	(*m)[tags.MetricNameWithStreamTags(DroppedMetric, baseTags)] = cgm.Metric{Type: "L", Value: s.dropped}
+	if s.pending != nil {
+		(*m)[tags.MetricNameWithStreamTags(pending.DroppedMetric, baseTags)] = cgm.Metric{Type: "L", Value: pendingDropped}
+	}
+	s.dropped = 0
+
+	return m
+}
+
+// stopped returns true once the listener has been stopped
+func (s *Server) stopped() bool {
+	s.connsmu.Lock()
+	defer s.connsmu.Unlock()
+	return s.listener == nil
+}
+
+func (s *Server) addConnection(conn net.Conn) {
+	s.connsmu.Lock()
+	s.conns[conn.RemoteAddr().String()] = conn
+	s.connsmu.Unlock()
+}
+
+func (s *Server) removeConnection(conn net.Conn) {
+	s.connsmu.Lock()
+	delete(s.conns, conn.RemoteAddr().String())
+	s.connsmu.Unlock()
+}
+
+// reader records the metrics written to a connection, until the client
+// closes the connection or it is idle for longer than idleTimeout
+func (s *Server) reader(conn net.Conn) {
+	addr := conn.RemoteAddr().String()
+	defer func() {
+		s.logger.Debug().Str("remote", addr).Msg("closing graphite connection")
+		conn.Close()
+		s.removeConnection(conn)
+	}()
+
+	r := bufio.NewReaderSize(conn, maxLineSize)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+			s.logger.Warn().Err(err).Str("remote", addr).Msg("setting graphite read deadline")
+			return
+		}
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			s.logger.Warn().Str("remote", addr).Msg("graphite line too long, closing connection")
+			s.recordDropped(1)
+			return
+		}
+		if len(line) > 0 {
+			s.recordLine(string(line))
+		}
+		if err != nil {
+			if err == io.EOF || s.stopped() {
+				return
+			}
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				s.logger.Debug().Str("remote", addr).Msg("idle graphite connection")
+				return
+			}
+			s.logger.Warn().Err(err).Str("remote", addr).Msg("reading graphite connection")
+			return
+		}
+	}
+}
+
+func (s *Server) recordDropped(n uint64) {
+	s.metricsmu.Lock()
+	s.dropped += n
+	s.metricsmu.Unlock()
+}
@@ -0,0 +1,236 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package graphite
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func newTestServer(ctx context.Context, t *testing.T) *Server {
+	t.Helper()
+	viper.Reset()
+	viper.Set(config.KeyGraphiteAddr, "127.0.0.1:0")
+	viper.Set(config.KeyGraphiteMapping, []string{
+		"servers.*.cpu.* cpu host:$1 cpu:$2",
+		"app.*.requests $1_requests",
+	})
+	s, err := New(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	return s
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	t.Log("\tdisabled")
+	{
+		viper.Reset()
+		s, err := New(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if s.Enabled() {
+			t.Fatal("expected disabled")
+		}
+		if m := s.Flush(); m != nil {
+			t.Fatalf("expected nil, got %v", m)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+	}
+
+	t.Log("\tnil")
+	{
+		var s *Server
+		if s.Enabled() {
+			t.Fatal("expected disabled")
+		}
+	}
+
+	t.Log("\tinvalid mapping")
+	{
+		viper.Reset()
+		viper.Set(config.KeyGraphiteAddr, "127.0.0.1:0")
+		viper.Set(config.KeyGraphiteMapping, []string{"servers.*.cpu"})
+		if _, err := New(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tenabled")
+	{
+		s := newTestServer(context.Background(), t)
+		if !s.Enabled() {
+			t.Fatal("expected enabled")
+		}
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	t.Log("Testing parseLine")
+
+	t.Log("\tvalid")
+	{
+		path, ptags, v, err := parseLine("servers.web1.load 1.5 1600000000")
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if path != "servers.web1.load" || len(ptags) != 0 || v != 1.5 {
+			t.Fatalf("unexpected %s %v %v", path, ptags, v)
+		}
+	}
+
+	t.Log("\tvalid, graphite tags")
+	{
+		path, ptags, v, err := parseLine("disk.used;dc=east;mount=/ 42 -1")
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if path != "disk.used" || len(ptags) != 2 || ptags[1].Value != "/" || v != 42 {
+			t.Fatalf("unexpected %s %v %v", path, ptags, v)
+		}
+	}
+
+	tt := []string{
+		"servers.web1.load",
+		"servers.web1.load 1 2 3",
+		"servers.web1.load one 1600000000",
+		"servers.web1.load NaN 1600000000",
+		"servers..load 1 1600000000",
+		".servers.load 1 1600000000",
+		"disk.used;dc 42 1600000000",
+	}
+
+	for _, line := range tt {
+		t.Logf("\tinvalid (%s)", line)
+		if _, _, _, err := parseLine(line); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	t.Log("Testing metricName")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	s := newTestServer(context.Background(), t)
+
+	tt := []struct {
+		path string
+		name string
+		tags tags.Tags
+	}{
+		{"servers.web1.cpu.0", "cpu", tags.Tags{{Category: "host", Value: "web1"}, {Category: "cpu", Value: "0"}}},
+		{"app.api.requests", "api_requests", tags.Tags{}},
+		{"servers.web1.cpu", "servers.web1.cpu", nil},
+		{"servers.web1.cpu.0.user", "servers.web1.cpu.0.user", nil},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s", tst.path)
+		name, mtags := s.metricName(tst.path)
+		if name != tst.name {
+			t.Fatalf("expected %s, got %s", tst.name, name)
+		}
+		if len(mtags) != len(tst.tags) {
+			t.Fatalf("expected %v, got %v", tst.tags, mtags)
+		}
+		for i, tag := range tst.tags {
+			if mtags[i] != tag {
+				t.Fatalf("expected %v, got %v", tst.tags, mtags)
+			}
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	t.Log("Testing Start")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newTestServer(ctx, t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start()
+	}()
+
+	var addr string
+	for i := 0; i < 100 && addr == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+		s.connsmu.Lock()
+		if s.listener != nil {
+			addr = s.listener.Addr().String()
+		}
+		s.connsmu.Unlock()
+	}
+	if addr == "" {
+		t.Fatal("listener not started")
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if _, err := conn.Write([]byte("servers.web1.cpu.0 12.5 1600000000\nservers.web1.cpu.0 15 1600000010\nbad line\nqueue.depth;queue=jobs 3 1600000000\n")); err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	conn.Close()
+
+	// wait for the connection to be read (the bad line is recorded, then the connection closed)
+	for i := 0; i < 100; i++ {
+		s.metricsmu.Lock()
+		dropped := s.dropped
+		s.metricsmu.Unlock()
+		s.connsmu.Lock()
+		n := len(s.conns)
+		s.connsmu.Unlock()
+		if dropped > 0 && n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m := s.Flush()
+	if metric, ok := testutil.FindMetric(*m, "cpu", "host:web1", "cpu:0"); !ok || metric.Value != 15.0 {
+		t.Fatalf("expected cpu 15, got %v (%v)", metric.Value, m)
+	}
+	if metric, ok := testutil.FindMetric(*m, "queue.depth", "queue:jobs"); !ok || metric.Value != 3.0 {
+		t.Fatalf("expected queue.depth 3, got %v (%v)", metric.Value, m)
+	}
+	if metric, ok := testutil.FindMetric(*m, DroppedMetric); !ok || metric.Value != uint64(1) {
+		t.Fatalf("expected %s 1, got %v (%v)", DroppedMetric, metric.Value, m)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener not stopped")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package graphite
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/pkg/errors"
+)
+
+// DroppedMetric counter, lines received which could not be recorded
+// (e.g. invalid format, invalid value)
+const DroppedMetric = "graphite_dropped"
+
+var captureRx = regexp.MustCompile(`\$([0-9]+)`)
+
+// mapping converts the metric paths matching a pattern to a metric name with stream tags
+type mapping struct {
+	pattern []string
+	name    string
+	tags    tags.Tags
+}
+
+func newMappings(rules []config.GraphiteMapping) []mapping {
+	mappings := make([]mapping, 0, len(rules))
+	for _, rule := range rules {
+		mappings = append(mappings, mapping{
+			pattern: rule.Pattern,
+			name:    rule.Name,
+			tags:    tags.FromList(rule.Tags),
+		})
+	}
+	return mappings
+}
+
+// match returns the nodes of the path matched by the pattern wildcards, false
+// if the path does not match the pattern
+func (m *mapping) match(nodes []string) ([]string, bool) {
+	if len(nodes) != len(m.pattern) {
+		return nil, false
+	}
+	var captures []string
+	for i, node := range m.pattern {
+		if node == "*" {
+			captures = append(captures, nodes[i])
+			continue
+		}
+		if node != nodes[i] {
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+// expand replaces the $n references with the nodes matched by the wildcards
+func expand(s string, captures []string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	return captureRx.ReplaceAllStringFunc(s, func(ref string) string {
+		n, err := strconv.Atoi(ref[1:])
+		if err != nil || n < 1 || n > len(captures) {
+			return ref
+		}
+		return captures[n-1]
+	})
+}
+
+// metricName returns the metric name and stream tags of a metric path, using
+// the first mapping rule matching the path, the path as-is if no rule matches
+func (s *Server) metricName(path string) (string, tags.Tags) {
+	nodes := strings.Split(path, ".")
+	for _, m := range s.mappings {
+		captures, ok := m.match(nodes)
+		if !ok {
+			continue
+		}
+		mtags := make(tags.Tags, 0, len(m.tags))
+		for _, t := range m.tags {
+			mtags = append(mtags, tags.Tag{Category: t.Category, Value: expand(t.Value, captures)})
+		}
+		return expand(m.name, captures), mtags
+	}
+	return path, nil
+}
+
+// parseLine parses a plaintext protocol line (path value timestamp), the
+// path can include graphite tags (path;tag=value;...). The timestamp is not
+// used, metrics are reported with the next flush.
+func parseLine(line string) (string, tags.Tags, float64, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return "", nil, 0, errors.Errorf("invalid line format '%s'", line)
+	}
+
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", nil, 0, errors.Wrapf(err, "invalid value '%s'", line)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", nil, 0, errors.Errorf("invalid value '%s'", line)
+	}
+
+	parts := strings.Split(fields[0], ";")
+	path := parts[0]
+	if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+		return "", nil, 0, errors.Errorf("invalid path '%s'", line)
+	}
+
+	var ptags tags.Tags
+	for _, tag := range parts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return "", nil, 0, errors.Errorf("invalid tag '%s'", line)
+		}
+		ptags = append(ptags, tags.Tag{Category: kv[0], Value: kv[1]})
+	}
+
+	return path, ptags, v, nil
+}
+
+// recordLine records the metric of a plaintext protocol line
+func (s *Server) recordLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	path, ptags, v, err := parseLine(line)
+	if err != nil {
+		s.logger.Debug().Err(err).Msg("parsing graphite line, dropped")
+		s.recordDropped(1)
+		return
+	}
+
+	name, mtags := s.metricName(path)
+	metricTags := append(tags.FromList(s.baseTags), mtags...)
+	metricTags = append(metricTags, ptags...)
+
+	s.metricsmu.Lock()
+	defer s.metricsmu.Unlock()
+	if s.pending.Allow(name, metricTags) {
+		s.metrics.GaugeWithTags(name, metricTags, v)
+	}
+}
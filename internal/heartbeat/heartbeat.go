@@ -0,0 +1,156 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package heartbeat emits agent heartbeat metrics with each full run. The
+// flush sequence is monotonic within a start and resets when the agent
+// restarts, the restart count is persisted across starts so "agent silent"
+// and "agent restarted" can be detected downstream without absence alerts.
+package heartbeat
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// SequenceMetric counter, flush sequence number (starts at 1 with each agent start)
+	SequenceMetric = "agent_heartbeat_seq"
+	// TimestampMetric numeric, flush timestamp (unix epoch seconds)
+	TimestampMetric = "agent_heartbeat_ts"
+	// StartTimeMetric numeric, agent start timestamp (unix epoch seconds)
+	StartTimeMetric = "agent_start_time"
+	// RestartsMetric counter, number of agent restarts since install (first start)
+	RestartsMetric = "agent_restarts"
+)
+
+// state is persisted to the state file
+type state struct {
+	Installed time.Time `json:"installed"` // first start
+	LastStart time.Time `json:"last_start"`
+	Restarts  uint64    `json:"restarts"`
+}
+
+// Heartbeat emits the agent heartbeat metrics
+type Heartbeat struct {
+	state    state
+	seq      uint64
+	baseTags tags.Tags
+	logger   zerolog.Logger
+	sync.Mutex
+}
+
+// New loads the state saved by the previous start, counts this start as a
+// restart and saves the state, returns nil if heartbeat is not enabled
+func New() (*Heartbeat, error) {
+	if !viper.GetBool(config.KeyHeartbeat) {
+		return nil, nil
+	}
+
+	// an empty state file keeps the state in memory (e.g. read-only filesystem), restarts are not counted
+	stateFile := viper.GetString(config.KeyHeartbeatStateFile)
+
+	h := &Heartbeat{
+		baseTags: tags.FromList(tags.GetBaseTags()),
+		logger:   log.With().Str("pkg", "heartbeat").Logger(),
+	}
+
+	now := time.Now().UTC()
+	cur := state{Installed: now, LastStart: now}
+
+	prev, err := loadState(stateFile)
+	switch {
+	case stateFile == "":
+		h.logger.Info().Msg("no heartbeat state file, state kept in memory")
+	case err == nil:
+		cur.Installed = prev.Installed
+		cur.Restarts = prev.Restarts + 1
+		h.logger.Info().Uint64("restarts", cur.Restarts).Time("previous_start", prev.LastStart).Msg("agent restarted")
+	case os.IsNotExist(errors.Cause(err)):
+		h.logger.Info().Str("file", stateFile).Msg("no previous heartbeat state, first start")
+	default:
+		h.logger.Warn().Err(err).Str("file", stateFile).Msg("ignoring previous heartbeat state, restart count reset")
+	}
+
+	h.state = cur
+
+	if stateFile == "" {
+		return h, nil
+	}
+
+	if err := saveState(stateFile, &cur); err != nil {
+		h.logger.Warn().Err(err).Str("file", stateFile).Msg("saving heartbeat state")
+	}
+
+	return h, nil
+}
+
+// Apply advances the flush sequence and adds the heartbeat metrics
+func (h *Heartbeat) Apply(metrics *cgm.Metrics) {
+	if h == nil || metrics == nil {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	h.seq++
+	h.addMetric(metrics, SequenceMetric, "L", h.seq)
+	h.addMetric(metrics, TimestampMetric, "L", uint64(time.Now().Unix()))
+	h.addMetric(metrics, StartTimeMetric, "L", uint64(h.state.LastStart.Unix()))
+	h.addMetric(metrics, RestartsMetric, "L", h.state.Restarts)
+}
+
+func (h *Heartbeat) addMetric(metrics *cgm.Metrics, name, mtype string, val interface{}) {
+	(*metrics)[tags.MetricNameWithStreamTags(name, h.baseTags)] = cgm.Metric{Type: mtype, Value: val}
+}
+
+func loadState(file string) (*state, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading state file")
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, errors.Wrap(err, "parsing state file")
+	}
+
+	return &s, nil
+}
+
+func saveState(file string, s *state) error {
+	sf, err := ioutil.TempFile(filepath.Dir(file), "heartbeat")
+	if err != nil {
+		return errors.Wrap(err, "creating temp state file")
+	}
+
+	enc := json.NewEncoder(sf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		sf.Close()
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "error encoding state (removing temp file)")
+	}
+
+	sf.Close()
+	if err := os.Rename(sf.Name(), file); err != nil {
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "updating state file (removing temp file)")
+	}
+
+	return nil
+}
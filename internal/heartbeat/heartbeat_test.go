@@ -0,0 +1,129 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package heartbeat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins/collector/testutil"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	dir, err := ioutil.TempDir("", "heartbeat")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "heartbeat.json")
+
+	t.Log("\tnot enabled")
+	{
+		viper.Reset()
+		h, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if h != nil {
+			t.Fatal("expected nil")
+		}
+	}
+
+	t.Log("\tno state file (in memory)")
+	{
+		viper.Reset()
+		viper.Set(config.KeyHeartbeat, true)
+		h, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := cgm.Metrics{}
+		h.Apply(&metrics)
+		if m, ok := testutil.FindMetric(metrics, RestartsMetric); !ok || m.Value.(uint64) != 0 {
+			t.Fatalf("expected 0 restarts, got %v", m.Value)
+		}
+	}
+
+	viper.Reset()
+	viper.Set(config.KeyHeartbeat, true)
+	viper.Set(config.KeyHeartbeatStateFile, stateFile)
+
+	t.Log("\tfirst start")
+	{
+		h, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		for seq := uint64(1); seq <= 2; seq++ {
+			metrics := cgm.Metrics{}
+			h.Apply(&metrics)
+			if m, ok := testutil.FindMetric(metrics, SequenceMetric); !ok || m.Value.(uint64) != seq {
+				t.Fatalf("expected sequence %d, got %v", seq, m.Value)
+			}
+			if m, ok := testutil.FindMetric(metrics, RestartsMetric); !ok || m.Value.(uint64) != 0 {
+				t.Fatalf("expected 0 restarts, got %v", m.Value)
+			}
+			if m, ok := testutil.FindMetric(metrics, TimestampMetric); !ok || m.Value.(uint64) == 0 {
+				t.Fatalf("expected timestamp, got %v", m.Value)
+			}
+			if m, ok := testutil.FindMetric(metrics, StartTimeMetric); !ok || m.Value.(uint64) == 0 {
+				t.Fatalf("expected start time, got %v", m.Value)
+			}
+		}
+	}
+
+	t.Log("\trestart")
+	{
+		h, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metrics := cgm.Metrics{}
+		h.Apply(&metrics)
+		if m, _ := testutil.FindMetric(metrics, SequenceMetric); m.Value.(uint64) != 1 {
+			t.Fatalf("expected sequence 1, got %v", m.Value)
+		}
+		if m, _ := testutil.FindMetric(metrics, RestartsMetric); m.Value.(uint64) != 1 {
+			t.Fatalf("expected 1 restart, got %v", m.Value)
+		}
+	}
+
+	t.Log("\tinvalid state file")
+	{
+		if err := ioutil.WriteFile(stateFile, []byte("{"), 0644); err != nil {
+			t.Fatalf("writing state file (%s)", err)
+		}
+		h, err := New()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if h.state.Restarts != 0 {
+			t.Fatalf("expected restart count reset, got %#v", h.state)
+		}
+	}
+}
+
+func TestApplyNil(t *testing.T) {
+	t.Log("Testing Apply (not enabled)")
+
+	var h *Heartbeat
+	metrics := cgm.Metrics{}
+	h.Apply(&metrics)
+	if len(metrics) != 0 {
+		t.Fatalf("expected no metrics, got %v", metrics)
+	}
+}
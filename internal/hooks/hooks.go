@@ -0,0 +1,408 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package hooks provides local evaluation of simple threshold rules against
+// collected metrics, running local actions (command, file, log) when a rule
+// changes state. Evaluation does not depend on the circonus api or broker so
+// first-response remediation works when the control plane is unreachable.
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Rule states
+const (
+	StateOK     = "ok"
+	StateBreach = "breach"
+)
+
+// Action types
+const (
+	ActionExec = "exec"
+	ActionFile = "file"
+	ActionLog  = "log"
+)
+
+const (
+	defaultActionTimeout = 30 * time.Second
+)
+
+// actionConfig defines a single action in the hooks file
+type actionConfig struct {
+	Type    string   `json:"type"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Path    string   `json:"path"`
+	Timeout string   `json:"timeout"`
+}
+
+// ruleConfig defines a single threshold rule in the hooks file
+type ruleConfig struct {
+	ID       string         `json:"id"`
+	Metric   string         `json:"metric"`
+	Op       string         `json:"op"`
+	Value    float64        `json:"value"`
+	For      int            `json:"for"`
+	Cooldown string         `json:"cooldown"`
+	Actions  []actionConfig `json:"actions"`
+	Clear    []actionConfig `json:"clear_actions"`
+}
+
+// hooksFile defines the structure of the hooks file
+type hooksFile struct {
+	Rules []ruleConfig `json:"rules"`
+}
+
+type action struct {
+	kind    string
+	command string
+	args    []string
+	path    string
+	timeout time.Duration
+}
+
+// metricState tracks a rule's state for a single matching metric
+type metricState struct {
+	state    string
+	breaches int
+	lastFire time.Time
+	running  bool // actions for the metric's last state change are still running
+}
+
+type rule struct {
+	id       string
+	metricRx *regexp.Regexp
+	op       string
+	value    float64
+	count    int
+	cooldown time.Duration
+	actions  []action
+	clear    []action
+	states   map[string]*metricState
+}
+
+// Event describes a rule state change, passed to actions
+type Event struct {
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Op        string    `json:"op"`
+	State     string    `json:"state"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Hooks evaluates threshold rules against collected metrics
+type Hooks struct {
+	ctx    context.Context
+	rules  []*rule
+	logger zerolog.Logger
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+// New loads the rules from the hooks file, returns nil if no file is configured
+func New(ctx context.Context, file string) (*Hooks, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading hooks file (%s)", file)
+	}
+
+	var cfg hooksFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "parsing hooks file (%s)", file)
+	}
+
+	h := &Hooks{
+		ctx:    ctx,
+		rules:  make([]*rule, 0, len(cfg.Rules)),
+		logger: log.With().Str("pkg", "hooks").Logger(),
+	}
+
+	for idx, rcfg := range cfg.Rules {
+		r, err := newRule(rcfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "hooks rule %d (%s)", idx, rcfg.ID)
+		}
+		h.rules = append(h.rules, r)
+	}
+
+	h.logger.Info().Int("rules", len(h.rules)).Str("file", file).Msg("loaded hooks")
+
+	return h, nil
+}
+
+func newRule(cfg ruleConfig) (*rule, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("missing id")
+	}
+	if cfg.Metric == "" {
+		return nil, errors.New("missing metric")
+	}
+	rx, err := regexp.Compile(cfg.Metric)
+	if err != nil {
+		return nil, errors.Wrap(err, "metric")
+	}
+	switch cfg.Op {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return nil, errors.Errorf("invalid op (%s)", cfg.Op)
+	}
+	if len(cfg.Actions) == 0 && len(cfg.Clear) == 0 {
+		return nil, errors.New("no actions")
+	}
+
+	r := &rule{
+		id:       cfg.ID,
+		metricRx: rx,
+		op:       cfg.Op,
+		value:    cfg.Value,
+		count:    cfg.For,
+		states:   make(map[string]*metricState),
+	}
+	if r.count < 1 {
+		r.count = 1
+	}
+	if cfg.Cooldown != "" {
+		d, err := time.ParseDuration(cfg.Cooldown)
+		if err != nil {
+			return nil, errors.Wrap(err, "cooldown")
+		}
+		r.cooldown = d
+	}
+	if r.actions, err = newActions(cfg.Actions); err != nil {
+		return nil, errors.Wrap(err, "actions")
+	}
+	if r.clear, err = newActions(cfg.Clear); err != nil {
+		return nil, errors.Wrap(err, "clear_actions")
+	}
+
+	return r, nil
+}
+
+func newActions(cfgs []actionConfig) ([]action, error) {
+	actions := make([]action, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		a := action{
+			kind:    cfg.Type,
+			command: cfg.Command,
+			args:    cfg.Args,
+			path:    cfg.Path,
+			timeout: defaultActionTimeout,
+		}
+		switch cfg.Type {
+		case ActionExec:
+			if cfg.Command == "" {
+				return nil, errors.New("exec action missing command")
+			}
+		case ActionFile:
+			if cfg.Path == "" {
+				return nil, errors.New("file action missing path")
+			}
+		case ActionLog:
+		default:
+			return nil, errors.Errorf("invalid action type (%s)", cfg.Type)
+		}
+		if cfg.Timeout != "" {
+			d, err := time.ParseDuration(cfg.Timeout)
+			if err != nil {
+				return nil, errors.Wrap(err, "timeout")
+			}
+			a.timeout = d
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// Evaluate checks collected metrics against the rules, actions for rules
+// changing state are run in the background so collection is not delayed
+func (h *Hooks) Evaluate(metrics *cgm.Metrics) {
+	if h == nil || metrics == nil {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	now := time.Now()
+	for _, r := range h.rules {
+		for name, m := range *metrics {
+			if !r.metricRx.MatchString(name) {
+				continue
+			}
+			mv, _ := sample.Unwrap(m.Value)
+			v, ok := sample.Float64(mv)
+			if !ok {
+				continue
+			}
+			if ev := r.evaluate(name, v, now); ev != nil {
+				h.fire(r, ev)
+			}
+		}
+	}
+}
+
+// evaluate updates the state for a metric, returning an event if the state changed.
+// While the actions of the metric's last state change are running, the state is
+// left unchanged so the change is dispatched by a later evaluation.
+func (r *rule) evaluate(name string, v float64, now time.Time) *Event {
+	ms, ok := r.states[name]
+	if !ok {
+		ms = &metricState{state: StateOK}
+		r.states[name] = ms
+	}
+
+	ev := &Event{
+		Rule:      r.id,
+		Metric:    name,
+		Value:     v,
+		Threshold: r.value,
+		Op:        r.op,
+		Timestamp: now,
+	}
+
+	if !r.breached(v) {
+		ms.breaches = 0
+		if ms.state == StateBreach && !ms.running {
+			ms.state = StateOK
+			ev.State = StateOK
+			return ev
+		}
+		return nil
+	}
+
+	ms.breaches++
+	if ms.state == StateBreach || ms.breaches < r.count {
+		return nil
+	}
+	if r.cooldown > 0 && !ms.lastFire.IsZero() && now.Sub(ms.lastFire) < r.cooldown {
+		return nil
+	}
+	if ms.running {
+		return nil
+	}
+	ms.state = StateBreach
+	ms.lastFire = now
+	ev.State = StateBreach
+	return ev
+}
+
+func (r *rule) breached(v float64) bool {
+	switch r.op {
+	case ">":
+		return v > r.value
+	case ">=":
+		return v >= r.value
+	case "<":
+		return v < r.value
+	case "<=":
+		return v <= r.value
+	case "==":
+		return v == r.value
+	case "!=":
+		return v != r.value
+	}
+	return false
+}
+
+// fire runs the actions for an event, only one set of actions runs per rule and
+// metric at a time (see evaluate)
+func (h *Hooks) fire(r *rule, ev *Event) {
+	actions := r.actions
+	if ev.State == StateOK {
+		actions = r.clear
+	}
+	if len(actions) == 0 {
+		return
+	}
+	ms := r.states[ev.Metric]
+	ms.running = true
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		for _, a := range actions {
+			if err := h.run(a, ev); err != nil {
+				h.logger.Error().Err(err).Str("rule", ev.Rule).Str("type", a.kind).Msg("hook action")
+			}
+		}
+		h.Lock()
+		ms.running = false
+		h.Unlock()
+	}()
+}
+
+// Wait blocks until running actions have finished
+func (h *Hooks) Wait() {
+	if h == nil {
+		return
+	}
+	h.wg.Wait()
+}
+
+func (h *Hooks) run(a action, ev *Event) error {
+	switch a.kind {
+	case ActionLog:
+		h.logger.Warn().
+			Str("rule", ev.Rule).
+			Str("metric", ev.Metric).
+			Float64("value", ev.Value).
+			Str("op", ev.Op).
+			Float64("threshold", ev.Threshold).
+			Str("state", ev.State).
+			Msg("hook")
+	case ActionFile:
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return errors.Wrap(err, "encoding event")
+		}
+		f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return errors.Wrap(err, "opening hook file")
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			f.Close()
+			return errors.Wrap(err, "writing hook file")
+		}
+		return f.Close()
+	case ActionExec:
+		ctx, cancel := context.WithTimeout(h.ctx, a.timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, a.command, a.args...) //nolint:gosec
+		cmd.Env = append(os.Environ(),
+			"CA_HOOK_RULE="+ev.Rule,
+			"CA_HOOK_METRIC="+ev.Metric,
+			"CA_HOOK_VALUE="+strconv.FormatFloat(ev.Value, 'f', -1, 64),
+			"CA_HOOK_THRESHOLD="+strconv.FormatFloat(ev.Threshold, 'f', -1, 64),
+			"CA_HOOK_OP="+ev.Op,
+			"CA_HOOK_STATE="+ev.State,
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return errors.Wrapf(err, "running (%s) output (%s)", a.command, string(out))
+		}
+		h.logger.Info().Str("rule", ev.Rule).Str("command", a.command).Str("state", ev.State).Msg("hook command complete")
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+func writeHooksFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, "hooks.json")
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("writing hooks file (%s)", err)
+	}
+	return file
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Log("\tno file")
+	{
+		h, err := New(context.Background(), "")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if h != nil {
+			t.Fatal("expected nil")
+		}
+	}
+
+	t.Log("\tmissing file")
+	{
+		if _, err := New(context.Background(), filepath.Join(dir, "missing.json")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		content string
+		err     bool
+	}{
+		{"invalid json", `{`, true},
+		{"missing id", `{"rules":[{"metric":"foo","op":">","actions":[{"type":"log"}]}]}`, true},
+		{"invalid regex", `{"rules":[{"id":"a","metric":"(","op":">","actions":[{"type":"log"}]}]}`, true},
+		{"invalid op", `{"rules":[{"id":"a","metric":"foo","op":"=>","actions":[{"type":"log"}]}]}`, true},
+		{"no actions", `{"rules":[{"id":"a","metric":"foo","op":">"}]}`, true},
+		{"invalid action", `{"rules":[{"id":"a","metric":"foo","op":">","actions":[{"type":"foo"}]}]}`, true},
+		{"exec no command", `{"rules":[{"id":"a","metric":"foo","op":">","actions":[{"type":"exec"}]}]}`, true},
+		{"invalid cooldown", `{"rules":[{"id":"a","metric":"foo","op":">","cooldown":"x","actions":[{"type":"log"}]}]}`, true},
+		{"valid", `{"rules":[{"id":"a","metric":"foo","op":">","value":1,"actions":[{"type":"log"}]}]}`, false},
+	}
+
+	for _, test := range tests {
+		tst := test
+		t.Logf("\t%s", tst.desc)
+		_, err := New(context.Background(), writeHooksFile(t, dir, tst.content))
+		if tst.err && err == nil {
+			t.Fatal("expected error")
+		}
+		if !tst.err && err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Log("Testing Evaluate")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	events := filepath.Join(dir, "events.log")
+	cfg := `{"rules":[{"id":"high","metric":"^load","op":">","value":10,"for":2,
+		"actions":[{"type":"file","path":"` + events + `"}],
+		"clear_actions":[{"type":"file","path":"` + events + `"}]}]}`
+
+	h, err := New(context.Background(), writeHooksFile(t, dir, cfg))
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	readEvents := func() []Event {
+		h.Wait()
+		data, err := ioutil.ReadFile(events)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("reading events (%s)", err)
+		}
+		var evs []Event
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var ev Event
+			if err := json.Unmarshal([]byte(line), &ev); err != nil {
+				t.Fatalf("parsing event (%s)", err)
+			}
+			evs = append(evs, ev)
+		}
+		return evs
+	}
+
+	eval := func(v interface{}) {
+		h.Evaluate(&cgm.Metrics{
+			"load":    cgm.Metric{Type: "n", Value: v},
+			"version": cgm.Metric{Type: "s", Value: "v1.0.0"},
+		})
+	}
+
+	t.Log("\tbelow threshold")
+	{
+		eval(5)
+		if evs := readEvents(); len(evs) != 0 {
+			t.Fatalf("expected no events, got %v", evs)
+		}
+	}
+
+	t.Log("\tfirst breach, for not reached")
+	{
+		eval(15.5)
+		if evs := readEvents(); len(evs) != 0 {
+			t.Fatalf("expected no events, got %v", evs)
+		}
+	}
+
+	t.Log("\tsecond breach")
+	{
+		eval(uint64(20))
+		evs := readEvents()
+		if len(evs) != 1 {
+			t.Fatalf("expected 1 event, got %v", evs)
+		}
+		if evs[0].State != StateBreach || evs[0].Value != 20 || evs[0].Rule != "high" {
+			t.Fatalf("unexpected event %v", evs[0])
+		}
+	}
+
+	t.Log("\tstill breached, no new event")
+	{
+		eval(25)
+		if evs := readEvents(); len(evs) != 1 {
+			t.Fatalf("expected 1 event, got %v", evs)
+		}
+	}
+
+	t.Log("\tclear")
+	{
+		eval(1)
+		evs := readEvents()
+		if len(evs) != 2 {
+			t.Fatalf("expected 2 events, got %v", evs)
+		}
+		if evs[1].State != StateOK {
+			t.Fatalf("expected ok state, got %v", evs[1])
+		}
+	}
+}
+
+func TestRuleCooldown(t *testing.T) {
+	t.Log("Testing rule cooldown")
+
+	r, err := newRule(ruleConfig{ID: "a", Metric: "foo", Op: ">=", Value: 1, Cooldown: "1m", Actions: []actionConfig{{Type: ActionLog}}})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	now := time.Now()
+	if ev := r.evaluate("foo", 1, now); ev == nil || ev.State != StateBreach {
+		t.Fatalf("expected breach event, got %v", ev)
+	}
+	if ev := r.evaluate("foo", 0, now.Add(time.Second)); ev == nil || ev.State != StateOK {
+		t.Fatalf("expected ok event, got %v", ev)
+	}
+	if ev := r.evaluate("foo", 1, now.Add(2*time.Second)); ev != nil {
+		t.Fatalf("expected no event during cooldown, got %v", ev)
+	}
+	if ev := r.evaluate("foo", 1, now.Add(2*time.Minute)); ev == nil || ev.State != StateBreach {
+		t.Fatalf("expected breach event after cooldown, got %v", ev)
+	}
+}
+
+func TestRuleActionsRunning(t *testing.T) {
+	t.Log("Testing rule state changes while actions are running")
+
+	r, err := newRule(ruleConfig{ID: "a", Metric: "^foo", Op: ">=", Value: 1, Actions: []actionConfig{{Type: ActionLog}}})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	now := time.Now()
+	if ev := r.evaluate("foo1", 1, now); ev == nil || ev.State != StateBreach {
+		t.Fatalf("expected breach event, got %v", ev)
+	}
+	r.states["foo1"].running = true
+
+	// other metrics of the rule are not affected by foo1's running actions
+	if ev := r.evaluate("foo2", 1, now); ev == nil || ev.State != StateBreach {
+		t.Fatalf("expected breach event for foo2, got %v", ev)
+	}
+
+	// foo1 clears while its actions run, the change is not lost
+	if ev := r.evaluate("foo1", 0, now.Add(time.Second)); ev != nil {
+		t.Fatalf("expected no event while actions running, got %v", ev)
+	}
+	if r.states["foo1"].state != StateBreach {
+		t.Fatalf("expected state unchanged, got %s", r.states["foo1"].state)
+	}
+	r.states["foo1"].running = false
+	if ev := r.evaluate("foo1", 0, now.Add(2*time.Second)); ev == nil || ev.State != StateOK {
+		t.Fatalf("expected ok event, got %v", ev)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package logging provides log destinations for the agent other than
+// stdout: a rotating log file and the system log (syslog/Windows Event Log).
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	backupTimeFormat = "20060102T150405.000"
+	compressSuffix   = ".gz"
+)
+
+// RotatingFile is an io.Writer writing to a log file which is rotated when it
+// exceeds a maximum size or age. Rotated files are optionally compressed and
+// only the most recent maxBackups are retained.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	file       *os.File
+	size       int64
+	opened     time.Time
+	wg         sync.WaitGroup
+	sync.Mutex
+}
+
+// NewRotatingFile opens (appending to) a rotating log file. A maxSize or
+// maxAge of zero disables that rotation trigger, a maxBackups of zero
+// retains all rotated files.
+func NewRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*RotatingFile, error) {
+	if path == "" {
+		return nil, errors.New("invalid log file (empty)")
+	}
+	rf := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "creating log directory")
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "opening log file")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "stat log file")
+	}
+	rf.file = f
+	rf.size = info.Size()
+	rf.opened = info.ModTime()
+	if rf.size == 0 {
+		rf.opened = time.Now()
+	}
+	return nil
+}
+
+// Write implements io.Writer, rotating the file first if needed
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.Lock()
+	defer rf.Unlock()
+
+	if rf.file == nil {
+		return 0, errors.New("log file closed")
+	}
+
+	if rf.size > 0 && rf.needsRotate(int64(len(p))) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) needsRotate(n int64) bool {
+	if rf.maxSize > 0 && rf.size+n > rf.maxSize {
+		return true
+	}
+	if rf.maxAge > 0 && time.Since(rf.opened) > rf.maxAge {
+		return true
+	}
+	return false
+}
+
+// Rotate forces a rotation of the log file
+func (rf *RotatingFile) Rotate() error {
+	rf.Lock()
+	defer rf.Unlock()
+	return rf.rotate()
+}
+
+func (rf *RotatingFile) rotate() error {
+	if rf.file != nil {
+		if err := rf.file.Close(); err != nil {
+			return errors.Wrap(err, "closing log file")
+		}
+		rf.file = nil
+	}
+
+	backup := rf.backupName(time.Now())
+	if err := os.Rename(rf.path, backup); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "renaming log file")
+	}
+
+	if err := rf.open(); err != nil {
+		return err
+	}
+
+	rf.wg.Add(1)
+	go func() {
+		defer rf.wg.Done()
+		if rf.compress {
+			_ = compressFile(backup)
+		}
+		rf.prune()
+	}()
+
+	return nil
+}
+
+// backupName returns the rotated file name, e.g. agent.log -> agent-20200102T150405.000.log
+func (rf *RotatingFile) backupName(t time.Time) string {
+	dir := filepath.Dir(rf.path)
+	base := filepath.Base(rf.path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext)
+	return filepath.Join(dir, prefix+"-"+t.Format(backupTimeFormat)+ext)
+}
+
+// backups returns the rotated files grouped by rotation time, newest first
+// (a file being compressed may briefly exist with and without the suffix)
+func (rf *RotatingFile) backups() [][]string {
+	base := filepath.Base(rf.path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(rf.path), prefix+"*"))
+	if err != nil {
+		return nil
+	}
+	byTS := make(map[string][]string)
+	for _, m := range matches {
+		ts := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), prefix), compressSuffix), ext)
+		if _, err := time.Parse(backupTimeFormat, ts); err != nil {
+			continue
+		}
+		byTS[ts] = append(byTS[ts], m)
+	}
+	stamps := make([]string, 0, len(byTS))
+	for ts := range byTS {
+		stamps = append(stamps, ts)
+	}
+	// timestamp format sorts lexically
+	sort.Sort(sort.Reverse(sort.StringSlice(stamps)))
+	files := make([][]string, 0, len(stamps))
+	for _, ts := range stamps {
+		files = append(files, byTS[ts])
+	}
+	return files
+}
+
+// prune removes rotated files beyond maxBackups
+func (rf *RotatingFile) prune() {
+	if rf.maxBackups <= 0 {
+		return
+	}
+	backups := rf.backups()
+	for i := rf.maxBackups; i < len(backups); i++ {
+		for _, file := range backups[i] {
+			_ = os.Remove(file)
+		}
+	}
+}
+
+// Close waits for pending compression and closes the log file
+func (rf *RotatingFile) Close() error {
+	rf.Lock()
+	defer rf.Unlock()
+	rf.wg.Wait()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+// compressFile gzips a rotated file, removing the original
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dst := src + compressSuffix
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
@@ -0,0 +1,139 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	t.Log("Testing RotatingFile")
+
+	dir, err := ioutil.TempDir("", "logging")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Log("\tinvalid (empty path)")
+	{
+		if _, err := NewRotatingFile("", 0, 0, 0, false); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tsize rotation")
+	{
+		file := filepath.Join(dir, "size", "agent.log")
+		rf, err := NewRotatingFile(file, 20, 0, 2, false)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		line := []byte("0123456789\n") // 11 bytes, two fit in 20
+		for i := 0; i < 4; i++ {
+			if _, err := rf.Write(line); err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			time.Sleep(2 * time.Millisecond) // unique backup names
+		}
+		if err := rf.Close(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if string(data) != string(line) {
+			t.Fatalf("expected one line in current file, got (%s)", string(data))
+		}
+		if n := len(rf.backups()); n != 2 {
+			t.Fatalf("expected 2 backups, got %d", n)
+		}
+	}
+
+	t.Log("\tbackups pruned")
+	{
+		file := filepath.Join(dir, "prune", "agent.log")
+		rf, err := NewRotatingFile(file, 0, 0, 2, false)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := rf.Write([]byte("line\n")); err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if err := rf.Rotate(); err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+		_ = rf.Close()
+		if n := len(rf.backups()); n != 2 {
+			t.Fatalf("expected 2 backups, got %d", n)
+		}
+	}
+
+	t.Log("\tcompression")
+	{
+		file := filepath.Join(dir, "compress", "agent.log")
+		rf, err := NewRotatingFile(file, 0, 0, 0, true)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if _, err := rf.Write([]byte("line\n")); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := rf.Rotate(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		_ = rf.Close()
+		backups := rf.backups()
+		if len(backups) != 1 || len(backups[0]) != 1 {
+			t.Fatalf("expected 1 backup, got %v", backups)
+		}
+		if !strings.HasSuffix(backups[0][0], compressSuffix) {
+			t.Fatalf("expected compressed backup, got (%s)", backups[0][0])
+		}
+	}
+
+	t.Log("\tage rotation")
+	{
+		file := filepath.Join(dir, "age", "agent.log")
+		rf, err := NewRotatingFile(file, 0, time.Millisecond, 0, false)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if _, err := rf.Write([]byte("line\n")); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if _, err := rf.Write([]byte("line\n")); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		_ = rf.Close()
+		if n := len(rf.backups()); n != 1 {
+			t.Fatalf("expected 1 backup, got %d", n)
+		}
+	}
+
+	t.Log("\twrite after close")
+	{
+		file := filepath.Join(dir, "closed", "agent.log")
+		rf, err := NewRotatingFile(file, 0, 0, 0, false)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		_ = rf.Close()
+		if _, err := rf.Write([]byte("line\n")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !windows
+
+package logging
+
+import (
+	"log/syslog"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// NewSystemWriter returns a writer logging to the local syslog daemon
+func NewSystemWriter(name string) (zerolog.LevelWriter, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to syslog")
+	}
+	return zerolog.SyslogLevelWriter(w), nil
+}
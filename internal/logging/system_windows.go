@@ -0,0 +1,57 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package logging
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+const eventID = 1
+
+// eventLogWriter writes log lines to the Windows Event Log at the matching level
+type eventLogWriter struct {
+	el *eventlog.Log
+}
+
+// NewSystemWriter returns a writer logging to the Windows Event Log, the
+// event source is registered if needed (requires administrator privileges)
+func NewSystemWriter(name string) (zerolog.LevelWriter, error) {
+	el, err := eventlog.Open(name)
+	if err != nil {
+		// source may not be registered yet
+		if ierr := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); ierr != nil {
+			return nil, errors.Wrap(err, "opening event log")
+		}
+		el, err = eventlog.Open(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening event log")
+		}
+	}
+	return &eventLogWriter{el: el}, nil
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	return len(p), w.el.Info(eventID, string(p))
+}
+
+func (w *eventLogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var err error
+	switch level {
+	case zerolog.DebugLevel, zerolog.TraceLevel:
+		// debug output is not sent to the event log
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		err = w.el.Info(eventID, string(p))
+	case zerolog.WarnLevel:
+		err = w.el.Warning(eventID, string(p))
+	default:
+		err = w.el.Error(eventID, string(p))
+	}
+	return len(p), err
+}
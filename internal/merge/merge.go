@@ -0,0 +1,270 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package merge defines how a metric (same name and stream tags) which is
+// emitted more than once within a flush is handled - either by more than
+// one source (e.g. a plugin and a builtin) or by more than one client of
+// a source (e.g. two clients writing to the receiver or statsd).
+package merge
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// Merge policies (see config.KeyMetricMerge)
+const (
+	Last   = config.MetricMergeLast
+	Sum    = config.MetricMergeSum
+	Reject = config.MetricMergeReject
+)
+
+// ConflictMetric name of the metric counting values dropped by Reject
+const ConflictMetric = "circonus_agent_merge_conflicts"
+
+// Metrics combines two values for the same metric using policy, a is the
+// value already present and b the value being merged. The returned bool is
+// false when b was rejected (a is returned unchanged).
+//
+// With Sum, numeric values of the same metric type are added and histogram
+// samples are combined, anything else (text, null values, different metric
+// types) is replaced by b.
+func Metrics(policy string, a, b cgm.Metric) (cgm.Metric, bool) {
+	switch policy {
+	case Reject:
+		return a, false
+	case Sum:
+		if m, ok := add(a, b); ok {
+			return m, true
+		}
+		return b, true
+	default:
+		return b, true
+	}
+}
+
+// add sums two metrics of the same type, if either value has an explicit
+// timestamp the sum has the later of the timestamps
+func add(a, b cgm.Metric) (cgm.Metric, bool) {
+	if a.Type != b.Type {
+		return a, false
+	}
+
+	av, ats := sample.Unwrap(a.Value)
+	bv, bts := sample.Unwrap(b.Value)
+	if bts < ats {
+		bts = ats
+	}
+
+	var v interface{}
+	switch a.Type {
+	case "i", "l":
+		an, aok := toInt64(av)
+		bn, bok := toInt64(bv)
+		if !aok || !bok {
+			return a, false
+		}
+		v = an + bn
+	case "I", "L":
+		an, aok := toUint64(av)
+		bn, bok := toUint64(bv)
+		if !aok || !bok {
+			return a, false
+		}
+		v = an + bn
+	case "n":
+		an, aok := toFloat64(av)
+		bn, bok := toFloat64(bv)
+		if !aok || !bok {
+			return a, false
+		}
+		v = an + bn
+	case "h":
+		// encoded histogram buckets (H[value]=count), combining the
+		// bucket lists merges the distributions
+		al, aok := av.([]string)
+		bl, bok := bv.([]string)
+		if !aok || !bok {
+			return a, false
+		}
+		l := make([]string, 0, len(al)+len(bl))
+		l = append(l, al...)
+		l = append(l, bl...)
+		v = l
+	default:
+		return a, false
+	}
+
+	return cgm.Metric{Type: a.Type, Value: sample.Stamp(v, bts)}, true
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch tv := v.(type) {
+	case int:
+		return int64(tv), true
+	case int8:
+		return int64(tv), true
+	case int16:
+		return int64(tv), true
+	case int32:
+		return int64(tv), true
+	case int64:
+		return tv, true
+	case float64:
+		return int64(tv), true
+	case string:
+		n, err := strconv.ParseInt(tv, 10, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toUint64(v interface{}) (uint64, bool) {
+	switch tv := v.(type) {
+	case uint:
+		return uint64(tv), true
+	case uint8:
+		return uint64(tv), true
+	case uint16:
+		return uint64(tv), true
+	case uint32:
+		return uint64(tv), true
+	case uint64:
+		return tv, true
+	case float64:
+		if tv < 0 {
+			return 0, false
+		}
+		return uint64(tv), true
+	case string:
+		n, err := strconv.ParseUint(tv, 10, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch tv := v.(type) {
+	case float64:
+		return tv, true
+	case float32:
+		return float64(tv), true
+	case int:
+		return float64(tv), true
+	case int32:
+		return float64(tv), true
+	case int64:
+		return float64(tv), true
+	case uint32:
+		return float64(tv), true
+	case uint64:
+		return float64(tv), true
+	case string:
+		n, err := strconv.ParseFloat(tv, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// Action is how a source should apply a write to its metrics
+type Action int
+
+const (
+	// Replace set the metric to the new value
+	Replace Action = iota
+	// Add add the new value to the current value
+	Add
+	// Drop ignore the new value
+	Drop
+)
+
+// Window tracks the metrics written to a source within a flush interval,
+// for sources which aggregate writes from multiple clients (receiver, statsd).
+// With the Last policy nothing is tracked.
+type Window struct {
+	sync.Mutex
+	policy    string
+	types     map[string]string
+	conflicts uint64
+}
+
+// NewWindow returns a write window for policy (empty is Last)
+func NewWindow(policy string) *Window {
+	if policy == "" {
+		policy = Last
+	}
+	w := &Window{policy: policy}
+	if policy != Last {
+		w.types = make(map[string]string)
+	}
+	return w
+}
+
+// Write records a write of a metric with valueType (the type of the value being
+// written, writes of different value types to the same metric are never added)
+// and returns how the write should be applied.
+func (w *Window) Write(name string, metricTags cgm.Tags, valueType string) Action {
+	if w == nil || w.policy == Last {
+		return Replace
+	}
+
+	key := tags.MetricNameWithStreamTags(name, metricTags)
+
+	w.Lock()
+	defer w.Unlock()
+
+	prevType, seen := w.types[key]
+	if !seen {
+		w.types[key] = valueType
+		return Replace
+	}
+
+	switch w.policy {
+	case Reject:
+		w.conflicts++
+		return Drop
+	case Sum:
+		if prevType == valueType && valueType != "s" {
+			return Add
+		}
+		w.types[key] = valueType
+		return Replace
+	default:
+		return Replace
+	}
+}
+
+// Reset starts a new window (call when the source is flushed), returns the
+// number of writes dropped in the previous window
+func (w *Window) Reset() uint64 {
+	if w == nil || w.policy == Last {
+		return 0
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	n := w.conflicts
+	w.conflicts = 0
+	w.types = make(map[string]string, len(w.types))
+
+	return n
+}
+
+// Policy returns the window's merge policy
+func (w *Window) Policy() string {
+	if w == nil {
+		return Last
+	}
+	return w.policy
+}
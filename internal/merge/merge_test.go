@@ -0,0 +1,119 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package merge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Log("Testing Metrics")
+
+	tt := []struct {
+		name   string
+		policy string
+		a      cgm.Metric
+		b      cgm.Metric
+		expect cgm.Metric
+		ok     bool
+	}{
+		{"last", Last, cgm.Metric{Type: "L", Value: uint64(1)}, cgm.Metric{Type: "L", Value: uint64(2)}, cgm.Metric{Type: "L", Value: uint64(2)}, true},
+		{"reject", Reject, cgm.Metric{Type: "L", Value: uint64(1)}, cgm.Metric{Type: "L", Value: uint64(2)}, cgm.Metric{Type: "L", Value: uint64(1)}, false},
+		{"sum uint", Sum, cgm.Metric{Type: "L", Value: uint64(1)}, cgm.Metric{Type: "L", Value: uint32(2)}, cgm.Metric{Type: "L", Value: uint64(3)}, true},
+		{"sum int", Sum, cgm.Metric{Type: "i", Value: int32(-1)}, cgm.Metric{Type: "i", Value: "5"}, cgm.Metric{Type: "i", Value: int64(4)}, true},
+		{"sum float", Sum, cgm.Metric{Type: "n", Value: 1.5}, cgm.Metric{Type: "n", Value: uint64(2)}, cgm.Metric{Type: "n", Value: 3.5}, true},
+		{"sum histogram", Sum, cgm.Metric{Type: "h", Value: []string{"H[1.0e+00]=1"}}, cgm.Metric{Type: "h", Value: []string{"H[2.0e+00]=3"}}, cgm.Metric{Type: "h", Value: []string{"H[1.0e+00]=1", "H[2.0e+00]=3"}}, true},
+		{"sum text", Sum, cgm.Metric{Type: "s", Value: "foo"}, cgm.Metric{Type: "s", Value: "bar"}, cgm.Metric{Type: "s", Value: "bar"}, true},
+		{"sum type mismatch", Sum, cgm.Metric{Type: "L", Value: uint64(1)}, cgm.Metric{Type: "n", Value: 2.5}, cgm.Metric{Type: "n", Value: 2.5}, true},
+		{"sum timestamped", Sum, cgm.Metric{Type: "L", Value: sample.Stamp(uint64(1), 1000)}, cgm.Metric{Type: "L", Value: uint64(2)}, cgm.Metric{Type: "L", Value: sample.Stamp(uint64(3), 1000)}, true},
+		{"sum null", Sum, cgm.Metric{Type: "L", Value: "[[null]]"}, cgm.Metric{Type: "L", Value: uint64(2)}, cgm.Metric{Type: "L", Value: uint64(2)}, true},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s", tst.name)
+		m, ok := Metrics(tst.policy, tst.a, tst.b)
+		if ok != tst.ok {
+			t.Fatalf("expected ok %t, got %t", tst.ok, ok)
+		}
+		if !reflect.DeepEqual(m, tst.expect) {
+			t.Fatalf("expected %#v, got %#v", tst.expect, m)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	t.Log("Testing Window")
+
+	mtags := cgm.Tags{{Category: "collector", Value: "write"}}
+
+	t.Log("\tlast")
+	{
+		w := NewWindow(Last)
+		for i := 0; i < 2; i++ {
+			if a := w.Write("foo", mtags, "n"); a != Replace {
+				t.Fatalf("expected Replace, got %d", a)
+			}
+		}
+		if n := w.Reset(); n != 0 {
+			t.Fatalf("expected 0 conflicts, got %d", n)
+		}
+	}
+
+	t.Log("\tsum")
+	{
+		w := NewWindow(Sum)
+		if a := w.Write("foo", mtags, "n"); a != Replace {
+			t.Fatalf("expected Replace, got %d", a)
+		}
+		if a := w.Write("foo", mtags, "n"); a != Add {
+			t.Fatalf("expected Add, got %d", a)
+		}
+		if a := w.Write("foo", mtags, "L"); a != Replace {
+			t.Fatalf("expected Replace (type change), got %d", a)
+		}
+		if a := w.Write("foo", nil, "n"); a != Replace {
+			t.Fatalf("expected Replace (different tags), got %d", a)
+		}
+		if a := w.Write("bar", mtags, "s"); a != Replace {
+			t.Fatalf("expected Replace, got %d", a)
+		}
+		if a := w.Write("bar", mtags, "s"); a != Replace {
+			t.Fatalf("expected Replace (text), got %d", a)
+		}
+	}
+
+	t.Log("\treject")
+	{
+		w := NewWindow(Reject)
+		if a := w.Write("foo", mtags, "n"); a != Replace {
+			t.Fatalf("expected Replace, got %d", a)
+		}
+		if a := w.Write("foo", mtags, "n"); a != Drop {
+			t.Fatalf("expected Drop, got %d", a)
+		}
+		if n := w.Reset(); n != 1 {
+			t.Fatalf("expected 1 conflict, got %d", n)
+		}
+		if a := w.Write("foo", mtags, "n"); a != Replace {
+			t.Fatalf("expected Replace after reset, got %d", a)
+		}
+	}
+
+	t.Log("\tnil")
+	{
+		var w *Window
+		if a := w.Write("foo", mtags, "n"); a != Replace {
+			t.Fatalf("expected Replace, got %d", a)
+		}
+		if p := w.Policy(); p != Last {
+			t.Fatalf("expected %s, got %s", Last, p)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package otlp
+
+import (
+	"math"
+
+	"github.com/circonus-labs/circonus-agent/internal/pbwire"
+	"github.com/pkg/errors"
+)
+
+// decodeExportRequest decodes an ExportMetricsServiceRequest (protobuf)
+func decodeExportRequest(buf []byte) (*exportRequest, error) {
+	var req exportRequest
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		if f.Num != 1 || f.Wire != pbwire.Bytes {
+			return nil
+		}
+		rm, err := decodeResourceMetrics(f.B)
+		if err != nil {
+			return errors.Wrap(err, "resource metrics")
+		}
+		req.ResourceMetrics = append(req.ResourceMetrics, rm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func decodeResourceMetrics(buf []byte) (resourceMetrics, error) {
+	var rm resourceMetrics
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		if f.Wire != pbwire.Bytes {
+			return nil
+		}
+		switch f.Num {
+		case 1: // resource
+			return pbwire.Decode(f.B, func(rf pbwire.Field) error {
+				if rf.Num != 1 || rf.Wire != pbwire.Bytes {
+					return nil
+				}
+				kv, err := decodeKeyValue(rf.B)
+				if err != nil {
+					return err
+				}
+				rm.Resource.Attributes = append(rm.Resource.Attributes, kv)
+				return nil
+			})
+		case 2, 1000: // scope_metrics, instrumentation_library_metrics (deprecated, same layout)
+			sm, err := decodeScopeMetrics(f.B)
+			if err != nil {
+				return errors.Wrap(err, "scope metrics")
+			}
+			rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+		}
+		return nil
+	})
+	return rm, err
+}
+
+func decodeScopeMetrics(buf []byte) (scopeMetrics, error) {
+	var sm scopeMetrics
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		if f.Num != 2 || f.Wire != pbwire.Bytes {
+			return nil
+		}
+		m, err := decodeMetric(f.B)
+		if err != nil {
+			return errors.Wrap(err, "metric")
+		}
+		sm.Metrics = append(sm.Metrics, m)
+		return nil
+	})
+	return sm, err
+}
+
+func decodeMetric(buf []byte) (metric, error) {
+	var m metric
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		if f.Wire != pbwire.Bytes {
+			return nil
+		}
+		switch f.Num {
+		case 1:
+			m.Name = string(f.B)
+		case 3:
+			m.Unit = string(f.B)
+		case 5:
+			m.Gauge = &gauge{}
+			return pbwire.Decode(f.B, func(gf pbwire.Field) error {
+				if gf.Num != 1 || gf.Wire != pbwire.Bytes {
+					return nil
+				}
+				dp, err := decodeNumberDataPoint(gf.B)
+				if err != nil {
+					return err
+				}
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+				return nil
+			})
+		case 7:
+			m.Sum = &sum{}
+			return pbwire.Decode(f.B, func(sf pbwire.Field) error {
+				switch {
+				case sf.Num == 1 && sf.Wire == pbwire.Bytes:
+					dp, err := decodeNumberDataPoint(sf.B)
+					if err != nil {
+						return err
+					}
+					m.Sum.DataPoints = append(m.Sum.DataPoints, dp)
+				case sf.Num == 2 && sf.Wire == pbwire.Varint:
+					m.Sum.AggregationTemporality = int(sf.U)
+				case sf.Num == 3 && sf.Wire == pbwire.Varint:
+					m.Sum.IsMonotonic = sf.U != 0
+				}
+				return nil
+			})
+		case 9:
+			m.Histogram = &histogram{}
+			return pbwire.Decode(f.B, func(hf pbwire.Field) error {
+				switch {
+				case hf.Num == 1 && hf.Wire == pbwire.Bytes:
+					dp, err := decodeHistogramDataPoint(hf.B)
+					if err != nil {
+						return err
+					}
+					m.Histogram.DataPoints = append(m.Histogram.DataPoints, dp)
+				case hf.Num == 2 && hf.Wire == pbwire.Varint:
+					m.Histogram.AggregationTemporality = int(hf.U)
+				}
+				return nil
+			})
+		case 10:
+			m.ExponentialHistogram = &struct{}{}
+		case 11:
+			m.Summary = &summary{}
+			return pbwire.Decode(f.B, func(sf pbwire.Field) error {
+				if sf.Num != 1 || sf.Wire != pbwire.Bytes {
+					return nil
+				}
+				dp, err := decodeSummaryDataPoint(sf.B)
+				if err != nil {
+					return err
+				}
+				m.Summary.DataPoints = append(m.Summary.DataPoints, dp)
+				return nil
+			})
+		}
+		return nil
+	})
+	return m, err
+}
+
+func decodeNumberDataPoint(buf []byte) (numberDataPoint, error) {
+	var dp numberDataPoint
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		switch {
+		case f.Num == 7 && f.Wire == pbwire.Bytes:
+			kv, err := decodeKeyValue(f.B)
+			if err != nil {
+				return err
+			}
+			dp.Attributes = append(dp.Attributes, kv)
+		case f.Num == 4 && f.Wire == pbwire.Fixed64:
+			v := f.Double()
+			dp.AsDouble = &v
+		case f.Num == 6 && f.Wire == pbwire.Fixed64:
+			v := int64Num(int64(f.U))
+			dp.AsInt = &v
+		}
+		return nil
+	})
+	return dp, errors.Wrap(err, "number data point")
+}
+
+func decodeHistogramDataPoint(buf []byte) (histogramDataPoint, error) {
+	var dp histogramDataPoint
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		switch {
+		case f.Num == 9 && f.Wire == pbwire.Bytes:
+			kv, err := decodeKeyValue(f.B)
+			if err != nil {
+				return err
+			}
+			dp.Attributes = append(dp.Attributes, kv)
+		case f.Num == 4 && f.Wire == pbwire.Fixed64:
+			dp.Count = uint64Num(f.U)
+		case f.Num == 5 && f.Wire == pbwire.Fixed64:
+			v := f.Double()
+			dp.Sum = &v
+		case f.Num == 6:
+			vals, err := pbwire.RepeatedFixed64(f)
+			if err != nil {
+				return errors.Wrap(err, "bucket counts")
+			}
+			for _, v := range vals {
+				dp.BucketCounts = append(dp.BucketCounts, uint64Num(v))
+			}
+		case f.Num == 7:
+			vals, err := pbwire.RepeatedFixed64(f)
+			if err != nil {
+				return errors.Wrap(err, "explicit bounds")
+			}
+			for _, v := range vals {
+				dp.ExplicitBounds = append(dp.ExplicitBounds, math.Float64frombits(v))
+			}
+		case f.Num == 12 && f.Wire == pbwire.Fixed64:
+			v := f.Double()
+			dp.Max = &v
+		}
+		return nil
+	})
+	return dp, errors.Wrap(err, "histogram data point")
+}
+
+func decodeSummaryDataPoint(buf []byte) (summaryDataPoint, error) {
+	var dp summaryDataPoint
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		switch {
+		case f.Num == 7 && f.Wire == pbwire.Bytes:
+			kv, err := decodeKeyValue(f.B)
+			if err != nil {
+				return err
+			}
+			dp.Attributes = append(dp.Attributes, kv)
+		case f.Num == 4 && f.Wire == pbwire.Fixed64:
+			dp.Count = uint64Num(f.U)
+		case f.Num == 5 && f.Wire == pbwire.Fixed64:
+			dp.Sum = f.Double()
+		case f.Num == 6 && f.Wire == pbwire.Bytes:
+			var q valueAtQuantile
+			err := pbwire.Decode(f.B, func(qf pbwire.Field) error {
+				if qf.Wire != pbwire.Fixed64 {
+					return nil
+				}
+				switch qf.Num {
+				case 1:
+					q.Quantile = qf.Double()
+				case 2:
+					q.Value = qf.Double()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			dp.QuantileValues = append(dp.QuantileValues, q)
+		}
+		return nil
+	})
+	return dp, errors.Wrap(err, "summary data point")
+}
+
+func decodeKeyValue(buf []byte) (keyValue, error) {
+	var kv keyValue
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		if f.Wire != pbwire.Bytes {
+			return nil
+		}
+		switch f.Num {
+		case 1:
+			kv.Key = string(f.B)
+		case 2:
+			return pbwire.Decode(f.B, func(vf pbwire.Field) error {
+				switch {
+				case vf.Num == 1 && vf.Wire == pbwire.Bytes:
+					s := string(vf.B)
+					kv.Value.StringValue = &s
+				case vf.Num == 2 && vf.Wire == pbwire.Varint:
+					b := vf.U != 0
+					kv.Value.BoolValue = &b
+				case vf.Num == 3 && vf.Wire == pbwire.Varint:
+					i := int64Num(int64(vf.U))
+					kv.Value.IntValue = &i
+				case vf.Num == 4 && vf.Wire == pbwire.Fixed64:
+					d := vf.Double()
+					kv.Value.DoubleValue = &d
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return kv, errors.Wrap(err, "attribute")
+}
@@ -0,0 +1,235 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package otlp
+
+import (
+	"strconv"
+
+	"github.com/circonus-labs/circonus-agent/internal/pbwire"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+)
+
+// DroppedMetric counter, data points received which could not be converted
+// (e.g. exponential histograms, data points without a value)
+const DroppedMetric = "otlp_dropped"
+
+const rejectedMessage = "data points not converted (exponential histogram, invalid data point)"
+
+// record converts the metrics of an export request, returns the number of
+// data points rejected
+//
+// gauges and sums are numeric metrics, cumulative sums are the reported
+// value, delta sums are added until the next flush. histograms are circonus
+// histograms (each bucket's count recorded at the bucket's upper bound),
+// cumulative histograms are converted to the counts since the previous export.
+// summaries are numeric _count, _sum and per quantile (quantile stream tag)
+// metrics. resource and data point attributes are stream tags.
+func (s *Server) record(req *exportRequest) uint64 {
+	s.metricsmu.Lock()
+	defer s.metricsmu.Unlock()
+
+	rejected := uint64(0)
+	for _, rm := range req.ResourceMetrics {
+		resTags := append(tags.FromList(s.baseTags), attributeTags(rm.Resource.Attributes)...)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				rejected += s.recordMetric(m, resTags)
+			}
+		}
+	}
+	s.dropped += rejected
+
+	return rejected
+}
+
+func (s *Server) recordMetric(m metric, resTags tags.Tags) uint64 {
+	if m.Name == "" {
+		return 1
+	}
+
+	mtags := resTags
+	if m.Unit != "" && m.Unit != "1" {
+		mtags = append(append(tags.Tags{}, resTags...), tags.Tag{Category: "units", Value: m.Unit})
+	}
+	pointTags := func(attrs []keyValue) tags.Tags {
+		return append(append(tags.Tags{}, mtags...), attributeTags(attrs)...)
+	}
+
+	rejected := uint64(0)
+	switch {
+	case m.Gauge != nil:
+		for _, dp := range m.Gauge.DataPoints {
+			v, ok := dp.value()
+			if !ok {
+				rejected++
+				continue
+			}
+			t := pointTags(dp.Attributes)
+			if s.pending.Allow(m.Name, t) {
+				s.metrics.GaugeWithTags(m.Name, t, v)
+			}
+		}
+	case m.Sum != nil:
+		for _, dp := range m.Sum.DataPoints {
+			v, ok := dp.value()
+			if !ok {
+				rejected++
+				continue
+			}
+			t := pointTags(dp.Attributes)
+			if !s.pending.Allow(m.Name, t) {
+				continue
+			}
+			if m.Sum.AggregationTemporality == temporalityDelta {
+				s.metrics.AddGaugeWithTags(m.Name, t, v)
+			} else {
+				s.metrics.GaugeWithTags(m.Name, t, v)
+			}
+		}
+	case m.Histogram != nil:
+		for _, dp := range m.Histogram.DataPoints {
+			t := pointTags(dp.Attributes)
+			if !s.recordHistogram(m.Name, t, dp, m.Histogram.AggregationTemporality == temporalityCumulative) {
+				rejected++
+			}
+		}
+	case m.Summary != nil:
+		for _, dp := range m.Summary.DataPoints {
+			t := pointTags(dp.Attributes)
+			if !s.pending.Allow(m.Name, t) {
+				continue
+			}
+			s.metrics.GaugeWithTags(m.Name+"_count", t, float64(dp.Count))
+			s.metrics.GaugeWithTags(m.Name+"_sum", t, dp.Sum)
+			for _, q := range dp.QuantileValues {
+				qt := append(append(tags.Tags{}, t...), tags.Tag{Category: "quantile", Value: strconv.FormatFloat(q.Quantile, 'g', -1, 64)})
+				s.metrics.GaugeWithTags(m.Name, qt, q.Value)
+			}
+		}
+	case m.ExponentialHistogram != nil:
+		s.logger.Debug().Str("metric", m.Name).Msg("exponential histograms not supported, dropped")
+		rejected++
+	default:
+		rejected++
+	}
+
+	return rejected
+}
+
+// recordHistogram records the bucket counts of a histogram data point
+func (s *Server) recordHistogram(name string, t tags.Tags, dp histogramDataPoint, cumulative bool) bool {
+	if len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+		if len(dp.BucketCounts) != 0 || dp.Count != 0 {
+			return false // malformed
+		}
+		return true // empty
+	}
+
+	counts := make([]uint64, len(dp.BucketCounts))
+	for i, c := range dp.BucketCounts {
+		counts[i] = uint64(c)
+	}
+
+	if cumulative {
+		key := tags.MetricNameWithStreamTags(name, t)
+		prev, seen := s.cumulative[key]
+		s.cumulative[key] = counts
+		if seen && len(prev) == len(counts) {
+			delta := make([]uint64, len(counts))
+			for i := range counts {
+				if counts[i] < prev[i] { // reset (e.g. application restarted)
+					delta = counts
+					break
+				}
+				delta[i] = counts[i] - prev[i]
+			}
+			counts = delta
+		}
+	}
+
+	if !s.pending.Allow(name, t) {
+		return true
+	}
+
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+		s.metrics.RecordCountForValueWithTags(name, t, bucketValue(dp, i), int64(n))
+	}
+
+	return true
+}
+
+// bucketValue returns the value a bucket's count is recorded at, the upper
+// bound of the bucket ((bounds[i-1], bounds[i]]), the max (or the highest
+// bound) for the overflow bucket, the average without bounds
+func bucketValue(dp histogramDataPoint, i int) float64 {
+	bounds := dp.ExplicitBounds
+	switch {
+	case i < len(bounds):
+		return bounds[i]
+	case len(bounds) == 0:
+		if dp.Sum != nil && dp.Count > 0 {
+			return *dp.Sum / float64(dp.Count)
+		}
+		if dp.Max != nil {
+			return *dp.Max
+		}
+		return 0
+	case dp.Max != nil && *dp.Max > bounds[len(bounds)-1]:
+		return *dp.Max
+	default:
+		return bounds[len(bounds)-1]
+	}
+}
+
+// value returns the value of a number data point as a float64 (so that
+// delta sums can be added regardless of the data point value type)
+func (dp numberDataPoint) value() (float64, bool) {
+	switch {
+	case dp.AsDouble != nil:
+		return *dp.AsDouble, true
+	case dp.AsInt != nil:
+		return float64(*dp.AsInt), true
+	default:
+		return 0, false
+	}
+}
+
+// attributeTags converts attributes with scalar values to stream tags
+func attributeTags(attrs []keyValue) tags.Tags {
+	t := make(tags.Tags, 0, len(attrs))
+	for _, kv := range attrs {
+		if kv.Key == "" {
+			continue
+		}
+		v, ok := kv.Value.tagValue()
+		if !ok {
+			continue
+		}
+		t = append(t, tags.Tag{Category: kv.Key, Value: v})
+	}
+	return t
+}
+
+// encodeExportResponse encodes an ExportMetricsServiceResponse (protobuf),
+// with a partial success when data points were rejected
+func encodeExportResponse(rejected uint64) []byte {
+	if rejected == 0 {
+		return []byte{}
+	}
+	var ps []byte
+	ps = pbwire.AppendKey(ps, 1, pbwire.Varint)
+	ps = pbwire.AppendUvarint(ps, rejected)
+	ps = pbwire.AppendKey(ps, 2, pbwire.Bytes)
+	ps = pbwire.AppendUvarint(ps, uint64(len(rejectedMessage)))
+	ps = append(ps, rejectedMessage...)
+
+	resp := pbwire.AppendKey([]byte{}, 1, pbwire.Bytes)
+	resp = pbwire.AppendUvarint(resp, uint64(len(ps)))
+	return append(resp, ps...)
+}
@@ -0,0 +1,150 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package otlp
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// The subset of the OTLP metrics data model (opentelemetry-proto, metrics/v1)
+// converted by the receiver. Requests are decoded into these types from either
+// encoding, the json tags follow the OTLP/JSON field names (lowerCamelCase).
+
+// aggregation temporality of sums and histograms
+const (
+	temporalityUnspecified = 0
+	temporalityDelta       = 1
+	temporalityCumulative  = 2
+)
+
+type exportRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeMetrics struct {
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name                 string     `json:"name"`
+	Unit                 string     `json:"unit"`
+	Gauge                *gauge     `json:"gauge"`
+	Sum                  *sum       `json:"sum"`
+	Histogram            *histogram `json:"histogram"`
+	ExponentialHistogram *struct{}  `json:"exponentialHistogram"` // not converted
+	Summary              *summary   `json:"summary"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type histogram struct {
+	DataPoints             []histogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type summary struct {
+	DataPoints []summaryDataPoint `json:"dataPoints"`
+}
+
+type numberDataPoint struct {
+	Attributes []keyValue `json:"attributes"`
+	AsDouble   *float64   `json:"asDouble"`
+	AsInt      *int64Num  `json:"asInt"`
+}
+
+type histogramDataPoint struct {
+	Attributes     []keyValue  `json:"attributes"`
+	Count          uint64Num   `json:"count"`
+	Sum            *float64    `json:"sum"`
+	BucketCounts   []uint64Num `json:"bucketCounts"`
+	ExplicitBounds []float64   `json:"explicitBounds"`
+	Max            *float64    `json:"max"`
+}
+
+type summaryDataPoint struct {
+	Attributes     []keyValue        `json:"attributes"`
+	Count          uint64Num         `json:"count"`
+	Sum            float64           `json:"sum"`
+	QuantileValues []valueAtQuantile `json:"quantileValues"`
+}
+
+type valueAtQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+// anyValue scalar attribute values, arrays, key/value lists and bytes are not
+// converted to stream tags
+type anyValue struct {
+	StringValue *string   `json:"stringValue"`
+	BoolValue   *bool     `json:"boolValue"`
+	IntValue    *int64Num `json:"intValue"`
+	DoubleValue *float64  `json:"doubleValue"`
+}
+
+// tagValue returns the value as a stream tag value, false if it is not a scalar
+func (v anyValue) tagValue() (string, bool) {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue, true
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue), true
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10), true
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// int64Num and uint64Num are 64 bit integers, OTLP/JSON encodes them as
+// strings (json numbers are accepted as well)
+type int64Num int64
+type uint64Num uint64
+
+func (n *int64Num) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return errors.Errorf("invalid int64 (%s)", data)
+	}
+	*n = int64Num(v)
+	return nil
+}
+
+func (n *uint64Num) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseUint(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return errors.Errorf("invalid uint64 (%s)", data)
+	}
+	*n = uint64Num(v)
+	return nil
+}
@@ -0,0 +1,351 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package otlp receives metrics pushed by applications instrumented with the
+// OpenTelemetry SDK, over OTLP/HTTP (protobuf or json) and OTLP/gRPC. The
+// metrics are converted to circonus metrics with stream tags (resource and
+// data point attributes) and included in the check output with the next flush.
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/pending"
+	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// MetricsPath OTLP/HTTP metrics export path
+	MetricsPath = "/v1/metrics"
+	// ExportMethodPath OTLP/gRPC metrics service export method
+	ExportMethodPath = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+	protobufMediaType = "application/x-protobuf"
+	jsonMediaType     = "application/json"
+	grpcMediaType     = "application/grpc"
+
+	// maxRequestSize limits the size of a (decompressed) export request
+	maxRequestSize = 16 * 1024 * 1024
+)
+
+// grpc status codes
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcUnimplemented   = 12
+)
+
+// Server defines the OTLP receiver
+type Server struct {
+	disabled   bool
+	address    string
+	certFile   string
+	keyFile    string
+	ctx        context.Context
+	server     *http.Server
+	metrics    *cgm.CirconusMetrics
+	metricsmu  sync.Mutex
+	pending    *pending.Limiter    // series written since the last flush (see max pending series)
+	cumulative map[string][]uint64 // last bucket counts of cumulative histograms
+	dropped    uint64              // data points not converted since the last flush
+	baseTags   []string
+	logger     zerolog.Logger
+}
+
+// New returns an OTLP receiver, disabled when no address is configured
+func New(ctx context.Context) (*Server, error) {
+	s := Server{
+		disabled: viper.GetString(config.KeyOTLPAddr) == "",
+		logger:   log.With().Str("pkg", "otlp").Logger(),
+	}
+
+	if s.disabled {
+		s.logger.Info().Msg("disabled, not configuring")
+		return &s, nil
+	}
+
+	s.ctx = ctx
+	s.address = viper.GetString(config.KeyOTLPAddr)
+	s.certFile = viper.GetString(config.KeyOTLPCertFile)
+	s.keyFile = viper.GetString(config.KeyOTLPKeyFile)
+	s.pending = pending.NewLimiter(viper.GetUint(config.KeyMaxPendingSeries))
+	s.cumulative = make(map[string][]uint64)
+	s.baseTags = append(tags.GetBaseTags(), []string{
+		"source:" + release.NAME,
+		"collector:otlp",
+	}...)
+
+	cmc := &cgm.Config{
+		Debug: viper.GetBool(config.KeyDebugCGM),
+		Log:   logshim{logh: s.logger.With().Str("pkg", "cgm.otlp").Logger()},
+	}
+	// put cgm into manual mode (no interval, no api key, invalid submission url)
+	cmc.Interval = "0"                            // disable automatic flush
+	cmc.CheckManager.Check.SubmissionURL = "none" // disable check management (create/update)
+
+	hm, err := cgm.NewCirconusMetrics(cmc)
+	if err != nil {
+		return nil, errors.Wrap(err, "otlp receiver cgm")
+	}
+	s.metrics = hm
+
+	s.server = &http.Server{
+		Addr:              s.address,
+		Handler:           &s,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       60 * time.Second,
+		WriteTimeout:      60 * time.Second,
+	}
+
+	return &s, nil
+}
+
+// logshim is used to satisfy apiclient Logger interface (avoiding ptr receiver issue)
+type logshim struct {
+	logh zerolog.Logger
+}
+
+func (l logshim) Printf(fmt string, v ...interface{}) {
+	l.logh.Printf(fmt, v...)
+}
+
+// Enabled returns true if the OTLP receiver is configured
+func (s *Server) Enabled() bool {
+	return s != nil && !s.disabled
+}
+
+// Start the OTLP receiver, OTLP/gRPC requires tls (cert and key file) as
+// http/2 is only negotiated on tls connections
+func (s *Server) Start() error {
+	if s.disabled {
+		s.logger.Info().Msg("disabled, not starting listener")
+		return nil
+	}
+
+	go func() {
+		<-s.ctx.Done()
+		s.Stop()
+	}()
+
+	var err error
+	if s.certFile != "" {
+		s.logger.Info().Str("listen", s.address).Msg("OTLP receiver starting (http, grpc)")
+		err = s.server.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		s.logger.Info().Str("listen", s.address).Msg("OTLP receiver starting (http)")
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, "OTLP receiver")
+	}
+	return nil
+}
+
+// Stop the OTLP receiver
+func (s *Server) Stop() {
+	if s.disabled || s.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	s.logger.Info().Msg("stopping OTLP receiver")
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Warn().Err(err).Msg("closing OTLP receiver")
+	}
+}
+
+// Flush returns the metrics received since the last flush
+func (s *Server) Flush() *cgm.Metrics {
+	if s.disabled {
+		return nil
+	}
+
+	s.metricsmu.Lock()
+	defer s.metricsmu.Unlock()
+
+	pendingDropped := s.pending.Reset()
+	m := s.metrics.FlushMetrics()
+	baseTags := tags.FromList(s.baseTags)
+	(*m)[tags.MetricNameWithStreamTags(DroppedMetric, baseTags)] = cgm.Metric{Type: "L", Value: s.dropped}
+	if s.pending != nil {
+		(*m)[tags.MetricNameWithStreamTags(pending.DroppedMetric, baseTags)] = cgm.Metric{Type: "L", Value: pendingDropped}
+	}
+	s.dropped = 0
+
+	return m
+}
+
+// ServeHTTP handles OTLP/HTTP and OTLP/gRPC export requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == grpcMediaType || strings.HasPrefix(mediaType, grpcMediaType+"+") {
+		s.grpcExport(w, r)
+		return
+	}
+
+	if r.URL.Path != MetricsPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType != protobufMediaType && mediaType != jsonMediaType {
+		http.Error(w, "unsupported content type ("+mediaType+")", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	data, err := readRequest(body)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("OTLP/HTTP export")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req *exportRequest
+	if mediaType == jsonMediaType {
+		req = &exportRequest{}
+		err = json.Unmarshal(data, req)
+	} else {
+		req, err = decodeExportRequest(data)
+	}
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("OTLP/HTTP export")
+		http.Error(w, "parsing export request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rejected := s.record(req)
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+	if mediaType == jsonMediaType {
+		resp := map[string]interface{}{}
+		if rejected > 0 {
+			resp["partialSuccess"] = map[string]string{
+				"rejectedDataPoints": strconv.FormatUint(rejected, 10),
+				"errorMessage":       rejectedMessage,
+			}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	}
+	_, _ = w.Write(encodeExportResponse(rejected))
+}
+
+// grpcExport handles the OTLP/gRPC MetricsService/Export unary call
+func (s *Server) grpcExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", grpcMediaType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	status := func(code int, msg string) {
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		if msg != "" {
+			w.Header().Set("Grpc-Message", url.PathEscape(msg))
+		}
+	}
+
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost {
+		http.Error(w, "grpc requires http/2 (tls)", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.URL.Path != ExportMethodPath {
+		status(grpcUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+
+	msg, err := readGRPCMessage(r)
+	if err == nil {
+		var req *exportRequest
+		if req, err = decodeExportRequest(msg); err == nil {
+			rejected := s.record(req)
+			resp := encodeExportResponse(rejected)
+			frame := make([]byte, 5, 5+len(resp))
+			binary.BigEndian.PutUint32(frame[1:], uint32(len(resp)))
+			_, _ = w.Write(append(frame, resp...))
+			status(grpcOK, "")
+			return
+		}
+	}
+
+	s.logger.Warn().Err(err).Msg("OTLP/gRPC export")
+	status(grpcInvalidArgument, err.Error())
+}
+
+// readGRPCMessage reads the (single, length prefixed) message of a unary call
+func readGRPCMessage(r *http.Request) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r.Body, prefix[:]); err != nil {
+		return nil, errors.Wrap(err, "reading grpc message prefix")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxRequestSize {
+		return nil, errors.Errorf("grpc message too large (%d)", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r.Body, msg); err != nil {
+		return nil, errors.Wrap(err, "reading grpc message")
+	}
+	if prefix[0] == 0 {
+		return msg, nil
+	}
+	if enc := r.Header.Get("Grpc-Encoding"); enc != "gzip" {
+		return nil, errors.Errorf("unsupported grpc encoding (%s)", enc)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(msg))
+	if err != nil {
+		return nil, errors.Wrap(err, "grpc message")
+	}
+	defer gz.Close()
+	return readRequest(gz)
+}
+
+// readRequest reads an export request, limited to maxRequestSize
+func readRequest(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxRequestSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading export request")
+	}
+	if len(data) > maxRequestSize {
+		return nil, errors.Errorf("export request too large (>%d bytes)", maxRequestSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,383 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/pbwire"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// protobuf encoding helpers for building export requests
+
+func pbKey(num, wire int) []byte {
+	return pbwire.AppendUvarint(nil, uint64(num<<3|wire))
+}
+
+func pbBytes(num int, parts ...[]byte) []byte {
+	msg := bytes.Join(parts, nil)
+	b := append(pbKey(num, pbwire.Bytes), pbwire.AppendUvarint(nil, uint64(len(msg)))...)
+	return append(b, msg...)
+}
+
+func pbString(num int, s string) []byte {
+	return pbBytes(num, []byte(s))
+}
+
+func pbVarint(num int, v uint64) []byte {
+	return append(pbKey(num, pbwire.Varint), pbwire.AppendUvarint(nil, v)...)
+}
+
+func pbFixed64(num int, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(pbKey(num, pbwire.Fixed64), b[:]...)
+}
+
+func pbDouble(num int, v float64) []byte {
+	return pbFixed64(num, math.Float64bits(v))
+}
+
+// pbAttr returns a KeyValue (string value) attribute field
+func pbAttr(num int, key, val string) []byte {
+	return pbBytes(num, pbString(1, key), pbBytes(2, pbString(1, val)))
+}
+
+// testRequest returns a protobuf export request with a gauge, a delta sum,
+// a cumulative histogram, a summary and an exponential histogram
+func testRequest(histCounts []uint64) []byte {
+	var packedCounts, packedBounds []byte
+	for _, c := range histCounts {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], c)
+		packedCounts = append(packedCounts, b[:]...)
+	}
+	for _, bound := range []float64{10, 100} {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(bound))
+		packedBounds = append(packedBounds, b[:]...)
+	}
+
+	gauge := pbBytes(2, // metrics
+		pbString(1, "queue.depth"),
+		pbBytes(5, pbBytes(1, pbAttr(7, "queue", "jobs"), pbFixed64(6, 42))))
+	sum := pbBytes(2,
+		pbString(1, "requests"),
+		pbString(3, "1"),
+		pbBytes(7, pbBytes(1, pbDouble(4, 2.5)), pbVarint(2, temporalityDelta), pbVarint(3, 1)))
+	hist := pbBytes(2,
+		pbString(1, "latency"),
+		pbString(3, "ms"),
+		pbBytes(9, pbBytes(1, pbBytes(6, packedCounts), pbBytes(7, packedBounds), pbDouble(12, 250)), pbVarint(2, temporalityCumulative)))
+	summary := pbBytes(2,
+		pbString(1, "gc"),
+		pbBytes(11, pbBytes(1, pbFixed64(4, 3), pbDouble(5, 1.5), pbBytes(6, pbDouble(1, 0.99), pbDouble(2, 0.9)))))
+	expHist := pbBytes(2, pbString(1, "sizes"), pbBytes(10))
+
+	return pbBytes(1, // resource_metrics
+		pbBytes(1, pbAttr(1, "service.name", "checkout")),
+		pbBytes(2, pbBytes(1, pbString(1, "scope")), gauge, sum, hist, summary, expHist))
+}
+
+func newTestServer(t *testing.T) *Server {
+	viper.Set(config.KeyOTLPAddr, "localhost:4318")
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	return s
+}
+
+func metricName(name string, extra ...string) string {
+	tagList := append(append([]string{}, tags.GetBaseTags()...), "source:circonus-agent", "collector:otlp", "service.name:checkout")
+	return tags.MetricNameWithStreamTags(name, tags.FromList(append(tagList, extra...)))
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	t.Log("\tdisabled")
+	{
+		viper.Reset()
+		s, err := New(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if s.Enabled() {
+			t.Fatal("expected disabled")
+		}
+		if m := s.Flush(); m != nil {
+			t.Fatalf("expected nil, got %v", m)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+	}
+
+	t.Log("\tnil")
+	{
+		var s *Server
+		if s.Enabled() {
+			t.Fatal("expected disabled")
+		}
+	}
+
+	t.Log("\tenabled")
+	{
+		s := newTestServer(t)
+		if !s.Enabled() {
+			t.Fatal("expected enabled")
+		}
+	}
+}
+
+func TestDecodeExportRequest(t *testing.T) {
+	t.Log("Testing decodeExportRequest")
+
+	t.Log("\tvalid")
+	{
+		req, err := decodeExportRequest(testRequest([]uint64{1, 2, 3}))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if len(req.ResourceMetrics) != 1 || len(req.ResourceMetrics[0].ScopeMetrics) != 1 {
+			t.Fatalf("unexpected request %#v", req)
+		}
+		if v, ok := req.ResourceMetrics[0].Resource.Attributes[0].Value.tagValue(); !ok || v != "checkout" {
+			t.Fatalf("unexpected resource attribute (%s)", v)
+		}
+		metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+		if len(metrics) != 5 {
+			t.Fatalf("expected 5 metrics, got %d", len(metrics))
+		}
+		if dp := metrics[0].Gauge.DataPoints[0]; dp.AsInt == nil || *dp.AsInt != 42 {
+			t.Fatalf("unexpected gauge %#v", dp)
+		}
+		if s := metrics[1].Sum; s.AggregationTemporality != temporalityDelta || !s.IsMonotonic || *s.DataPoints[0].AsDouble != 2.5 {
+			t.Fatalf("unexpected sum %#v", s)
+		}
+		if dp := metrics[2].Histogram.DataPoints[0]; len(dp.BucketCounts) != 3 || len(dp.ExplicitBounds) != 2 || *dp.Max != 250 {
+			t.Fatalf("unexpected histogram %#v", dp)
+		}
+		if dp := metrics[3].Summary.DataPoints[0]; dp.Count != 3 || dp.QuantileValues[0].Quantile != 0.99 {
+			t.Fatalf("unexpected summary %#v", dp)
+		}
+		if metrics[4].ExponentialHistogram == nil {
+			t.Fatal("expected exponential histogram")
+		}
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", testRequest([]uint64{1})[:20]},
+		{"invalid wire type", []byte{0x0f}},
+		{"bad packed counts", pbBytes(1, pbBytes(2, pbBytes(2, pbString(1, "x"), pbBytes(9, pbBytes(1, pbBytes(6, []byte{1, 2, 3}))))))},
+	}
+
+	for _, tst := range tests {
+		t.Logf("\t%s", tst.name)
+		if _, err := decodeExportRequest(tst.data); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	t.Log("Testing record")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	s := newTestServer(t)
+
+	req, err := decodeExportRequest(testRequest([]uint64{1, 2, 3}))
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if rejected := s.record(req); rejected != 1 {
+		t.Fatalf("expected 1 rejected (exponential histogram), got %d", rejected)
+	}
+	if rejected := s.record(req); rejected != 1 {
+		t.Fatalf("expected 1 rejected (exponential histogram), got %d", rejected)
+	}
+
+	m := *s.Flush()
+
+	expect := map[string]interface{}{
+		metricName("queue.depth", "queue:jobs"): float64(42),
+		metricName("requests"):                  float64(5), // delta, added
+		metricName("gc_count"):                  float64(3),
+		metricName("gc_sum"):                    1.5,
+		metricName("gc", "quantile:0.99"):       0.9,
+	}
+	for name, val := range expect {
+		mv, ok := m[name]
+		if !ok {
+			t.Fatalf("expected %s in %v", name, m)
+		}
+		if mv.Value != val {
+			t.Fatalf("expected %s %v, got %v", name, val, mv.Value)
+		}
+	}
+
+	hist, ok := m[metricName("latency", "units:ms")]
+	if !ok || hist.Type != "h" {
+		t.Fatalf("expected latency histogram in %v", m)
+	}
+	// cumulative, second export with the same counts adds nothing
+	if samples := strings.Join(hist.Value.([]string), ","); samples != "H[1.0e+01]=1,H[1.0e+02]=2,H[2.5e+02]=3" {
+		t.Fatalf("unexpected histogram (%s)", samples)
+	}
+
+	dropped := tags.MetricNameWithStreamTags(DroppedMetric, tags.FromList(s.baseTags))
+	if m[dropped].Value != uint64(2) {
+		t.Fatalf("expected 2 dropped, got %v", m[dropped].Value)
+	}
+
+	t.Log("\tcumulative histogram, counts since previous export")
+	{
+		req, err := decodeExportRequest(testRequest([]uint64{1, 4, 3}))
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		s.record(req)
+		m := *s.Flush()
+		hist := m[metricName("latency", "units:ms")]
+		if samples := strings.Join(hist.Value.([]string), ","); samples != "H[1.0e+02]=2" {
+			t.Fatalf("unexpected histogram (%s)", samples)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	t.Log("Testing ServeHTTP")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	s := newTestServer(t)
+
+	jsonReq := `{"resourceMetrics":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]},
+		"scopeMetrics":[{"metrics":[{"name":"temp","gauge":{"dataPoints":[{"asInt":"21","attributes":[{"key":"room","value":{"intValue":"4"}}]}]}},
+		{"name":"sizes","exponentialHistogram":{"dataPoints":[{"count":"1"}]}}]}]}]}`
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        []byte
+		status      int
+		respBody    string
+	}{
+		{"json", "POST", MetricsPath, "application/json", []byte(jsonReq), http.StatusOK, `"rejectedDataPoints":"1"`},
+		{"protobuf", "POST", MetricsPath, "application/x-protobuf", testRequest([]uint64{1, 2, 3}), http.StatusOK, rejectedMessage},
+		{"invalid json", "POST", MetricsPath, "application/json", []byte("{"), http.StatusBadRequest, "parsing export request"},
+		{"invalid protobuf", "POST", MetricsPath, "application/x-protobuf", []byte{0x0f}, http.StatusBadRequest, "parsing export request"},
+		{"content type", "POST", MetricsPath, "text/plain", []byte("x"), http.StatusUnsupportedMediaType, "unsupported content type"},
+		{"method", "GET", MetricsPath, "application/json", nil, http.StatusMethodNotAllowed, ""},
+		{"path", "POST", "/v1/traces", "application/json", []byte("{}"), http.StatusNotFound, ""},
+	}
+
+	for _, tst := range tests {
+		t.Logf("\t%s", tst.name)
+		r := httptest.NewRequest(tst.method, tst.path, bytes.NewReader(tst.body))
+		r.Header.Set("Content-Type", tst.contentType)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tst.status {
+			t.Fatalf("expected %d, got %d (%s)", tst.status, resp.StatusCode, body)
+		}
+		if !strings.Contains(string(body), tst.respBody) {
+			t.Fatalf("expected (%s) in (%s)", tst.respBody, body)
+		}
+	}
+
+	m := *s.Flush()
+	name := metricName("temp", "room:4")
+	if mv, ok := m[name]; !ok || mv.Value != float64(21) {
+		t.Fatalf("expected %s 21 in %v", name, m)
+	}
+}
+
+func TestGRPCExport(t *testing.T) {
+	t.Log("Testing grpcExport")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	s := newTestServer(t)
+
+	frame := func(msg []byte) []byte {
+		b := make([]byte, 5, 5+len(msg))
+		binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+		return append(b, msg...)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		proto  int
+		body   []byte
+		status string
+		http   int
+	}{
+		{"export", ExportMethodPath, 2, frame(testRequest([]uint64{1, 2, 3})), "0", http.StatusOK},
+		{"invalid message", ExportMethodPath, 2, frame([]byte{0x0f}), "3", http.StatusOK},
+		{"truncated frame", ExportMethodPath, 2, []byte{0, 0, 0, 0, 9, 1}, "3", http.StatusOK},
+		{"unknown method", "/opentelemetry.proto.collector.trace.v1.TraceService/Export", 2, frame(nil), "12", http.StatusOK},
+		{"http/1.1", ExportMethodPath, 1, frame(nil), "", http.StatusHTTPVersionNotSupported},
+	}
+
+	for _, tst := range tests {
+		t.Logf("\t%s", tst.name)
+		r := httptest.NewRequest("POST", tst.path, bytes.NewReader(tst.body))
+		r.Header.Set("Content-Type", "application/grpc")
+		r.ProtoMajor = tst.proto
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tst.http {
+			t.Fatalf("expected %d, got %d", tst.http, resp.StatusCode)
+		}
+		if status := resp.Trailer.Get("Grpc-Status"); status != tst.status {
+			t.Fatalf("expected grpc status (%s), got (%s) %v", tst.status, status, resp.Trailer)
+		}
+		if tst.status == "0" {
+			if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+				t.Fatalf("invalid response frame %v", body)
+			}
+		}
+	}
+
+	var m cgm.Metrics = *s.Flush()
+	if _, ok := m[metricName("gc_count")]; !ok {
+		t.Fatalf("expected gc_count in %v", m)
+	}
+}
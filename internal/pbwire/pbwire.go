@@ -0,0 +1,125 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package pbwire is a minimal protobuf wire format decoder (and encoding
+// helpers) for the messages accepted by the agent (receiver protobuf
+// requests, OTLP metrics), without generated code.
+package pbwire
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// protobuf wire types
+const (
+	Varint  = 0
+	Fixed64 = 1
+	Bytes   = 2
+	Fixed32 = 5
+)
+
+// Field is a decoded protobuf field, Num is the field number, U the value of
+// a varint, fixed64 or fixed32 field and B the value of a length delimited field
+type Field struct {
+	Num  int
+	Wire int
+	U    uint64
+	B    []byte
+}
+
+// Double returns the value of a double (fixed64) field
+func (f Field) Double() float64 {
+	return math.Float64frombits(f.U)
+}
+
+// Decode calls fn for each field of a protobuf message, fields are passed in
+// the order encoded, unknown fields are the caller's to ignore
+func Decode(buf []byte, fn func(f Field) error) error {
+	for pos := 0; pos < len(buf); {
+		key, n := binary.Uvarint(buf[pos:])
+		if n <= 0 {
+			return errors.New("invalid field key")
+		}
+		pos += n
+		f := Field{Num: int(key >> 3), Wire: int(key & 7)}
+		if f.Num == 0 {
+			return errors.New("invalid field number")
+		}
+		switch f.Wire {
+		case Varint:
+			v, n := binary.Uvarint(buf[pos:])
+			if n <= 0 {
+				return errors.New("invalid varint")
+			}
+			f.U = v
+			pos += n
+		case Fixed64:
+			if len(buf)-pos < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			f.U = binary.LittleEndian.Uint64(buf[pos:])
+			pos += 8
+		case Bytes:
+			l, n := binary.Uvarint(buf[pos:])
+			if n <= 0 {
+				return errors.New("invalid length")
+			}
+			pos += n
+			if l > uint64(len(buf)-pos) {
+				return io.ErrUnexpectedEOF
+			}
+			f.B = buf[pos : pos+int(l)]
+			pos += int(l)
+		case Fixed32:
+			if len(buf)-pos < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			f.U = uint64(binary.LittleEndian.Uint32(buf[pos:]))
+			pos += 4
+		default:
+			return errors.Errorf("unsupported wire type %d", f.Wire)
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepeatedFixed64 returns the values of a repeated fixed64/double field,
+// packed (length delimited) or not
+func RepeatedFixed64(f Field) ([]uint64, error) {
+	switch f.Wire {
+	case Fixed64:
+		return []uint64{f.U}, nil
+	case Bytes:
+		if len(f.B)%8 != 0 {
+			return nil, errors.New("invalid packed fixed64 length")
+		}
+		vals := make([]uint64, 0, len(f.B)/8)
+		for i := 0; i < len(f.B); i += 8 {
+			vals = append(vals, binary.LittleEndian.Uint64(f.B[i:]))
+		}
+		return vals, nil
+	default:
+		return nil, errors.Errorf("unexpected wire type %d for fixed64", f.Wire)
+	}
+}
+
+// AppendUvarint appends a varint encoded value to buf
+func AppendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// AppendKey appends a field key (field number and wire type) to buf
+func AppendKey(buf []byte, num, wire int) []byte {
+	return AppendUvarint(buf, uint64(num<<3|wire))
+}
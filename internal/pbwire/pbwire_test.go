@@ -0,0 +1,114 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package pbwire
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	t.Log("Testing Decode")
+
+	var buf []byte
+	buf = AppendKey(buf, 1, Varint)
+	buf = AppendUvarint(buf, 300)
+	buf = AppendKey(buf, 2, Fixed64)
+	buf = append(buf, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(buf[len(buf)-8:], math.Float64bits(1.5))
+	buf = AppendKey(buf, 3, Bytes)
+	buf = AppendUvarint(buf, 3)
+	buf = append(buf, "foo"...)
+	buf = AppendKey(buf, 4, Fixed32)
+	buf = append(buf, 7, 0, 0, 0)
+
+	t.Log("\tvalid")
+	{
+		var fields []Field
+		err := Decode(buf, func(f Field) error {
+			fields = append(fields, f)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(fields) != 4 {
+			t.Fatalf("expected 4 fields, got %v", fields)
+		}
+		if f := fields[0]; f.Num != 1 || f.Wire != Varint || f.U != 300 {
+			t.Fatalf("unexpected varint field %#v", f)
+		}
+		if f := fields[1]; f.Num != 2 || f.Wire != Fixed64 || f.Double() != 1.5 {
+			t.Fatalf("unexpected fixed64 field %#v", f)
+		}
+		if f := fields[2]; f.Num != 3 || f.Wire != Bytes || string(f.B) != "foo" {
+			t.Fatalf("unexpected bytes field %#v", f)
+		}
+		if f := fields[3]; f.Num != 4 || f.Wire != Fixed32 || f.U != 7 {
+			t.Fatalf("unexpected fixed32 field %#v", f)
+		}
+	}
+
+	tt := []struct {
+		name string
+		buf  []byte
+	}{
+		{"truncated varint", buf[:2]},
+		{"truncated fixed64", buf[:6]},
+		{"truncated bytes", buf[:len(buf)-7]},
+		{"truncated fixed32", buf[:len(buf)-1]},
+		{"field number 0", AppendKey(nil, 0, Varint)},
+		{"unsupported wire type", AppendKey(nil, 1, 3)},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\tinvalid (%s)", tst.name)
+		err := Decode(tst.buf, func(f Field) error { return nil })
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestRepeatedFixed64(t *testing.T) {
+	t.Log("Testing RepeatedFixed64")
+
+	t.Log("\tunpacked")
+	{
+		vals, err := RepeatedFixed64(Field{Num: 1, Wire: Fixed64, U: 5})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(vals) != 1 || vals[0] != 5 {
+			t.Fatalf("expected [5], got %v", vals)
+		}
+	}
+
+	t.Log("\tpacked")
+	{
+		b := make([]byte, 16)
+		binary.LittleEndian.PutUint64(b, 1)
+		binary.LittleEndian.PutUint64(b[8:], 2)
+		vals, err := RepeatedFixed64(Field{Num: 1, Wire: Bytes, B: b})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(vals) != 2 || vals[0] != 1 || vals[1] != 2 {
+			t.Fatalf("expected [1 2], got %v", vals)
+		}
+	}
+
+	t.Log("\tinvalid")
+	{
+		if _, err := RepeatedFixed64(Field{Num: 1, Wire: Bytes, B: make([]byte, 9)}); err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := RepeatedFixed64(Field{Num: 1, Wire: Varint}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package pending bounds the number of distinct metric series a source
+// (statsd, the receiver) accumulates between flushes. When flushes stall
+// (e.g. the broker is not fetching metrics) clients keep writing, the limit
+// keeps memory from growing without bound - writes to series already pending
+// are still applied, writes creating new series are dropped and counted.
+package pending
+
+import (
+	"sync"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// DroppedMetric name of the metric counting writes dropped by the limit
+const DroppedMetric = "circonus_agent_series_dropped"
+
+// Limiter tracks the series written to a source since the last flush
+type Limiter struct {
+	sync.Mutex
+	max     int
+	series  map[string]bool
+	dropped uint64
+}
+
+// NewLimiter returns a limiter allowing max series between flushes, returns
+// nil (no limit) if max is zero
+func NewLimiter(max uint) *Limiter {
+	if max == 0 {
+		return nil
+	}
+	return &Limiter{
+		max:    int(max),
+		series: make(map[string]bool),
+	}
+}
+
+// Allow records a write to a series, returns false if the series is not
+// already pending and the limit has been reached
+func (l *Limiter) Allow(name string, metricTags cgm.Tags) bool {
+	if l == nil {
+		return true
+	}
+
+	key := tags.MetricNameWithStreamTags(name, metricTags)
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.series[key] {
+		return true
+	}
+	if len(l.series) >= l.max {
+		l.dropped++
+		return false
+	}
+	l.series[key] = true
+	return true
+}
+
+// Reset starts a new interval (call when the source is flushed), returns the
+// number of writes dropped in the previous interval
+func (l *Limiter) Reset() uint64 {
+	if l == nil {
+		return 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	n := l.dropped
+	l.dropped = 0
+	l.series = make(map[string]bool)
+
+	return n
+}
@@ -0,0 +1,61 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package pending
+
+import (
+	"testing"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Log("Testing Limiter")
+
+	t.Log("\tno limit")
+	{
+		l := NewLimiter(0)
+		if l != nil {
+			t.Fatal("expected nil")
+		}
+		for i := 0; i < 10; i++ {
+			if !l.Allow("foo", cgm.Tags{{Category: "n", Value: string(rune('a' + i))}}) {
+				t.Fatal("expected allowed")
+			}
+		}
+		if n := l.Reset(); n != 0 {
+			t.Fatalf("expected 0 dropped, got %d", n)
+		}
+	}
+
+	t.Log("\tlimit")
+	{
+		l := NewLimiter(2)
+		if !l.Allow("foo", nil) {
+			t.Fatal("expected foo allowed")
+		}
+		if !l.Allow("foo", cgm.Tags{{Category: "a", Value: "b"}}) {
+			t.Fatal("expected foo|a:b allowed")
+		}
+		if l.Allow("bar", nil) {
+			t.Fatal("expected bar dropped")
+		}
+		if l.Allow("foo", cgm.Tags{{Category: "a", Value: "c"}}) {
+			t.Fatal("expected foo|a:c dropped")
+		}
+		if !l.Allow("foo", nil) {
+			t.Fatal("expected pending foo allowed")
+		}
+		if n := l.Reset(); n != 2 {
+			t.Fatalf("expected 2 dropped, got %d", n)
+		}
+		if !l.Allow("bar", nil) {
+			t.Fatal("expected bar allowed after reset")
+		}
+		if n := l.Reset(); n != 0 {
+			t.Fatalf("expected 0 dropped, got %d", n)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package pluginbundle installs a signed bundle (tar.gz) of plugins for the
+// host's role, fetched from an artifact store (https or s3), in the plugin
+// directory so new plugins do not require a configuration management cycle.
+//
+// The bundle signature (ed25519, raw or base64) is fetched from the bundle
+// url with a ".sig" suffix and verified before anything is unpacked. Files
+// installed by the previous bundle which are not in the new bundle are
+// removed.
+package pluginbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// StateFile records the installed bundle in the plugin directory (ignored by the plugin scan)
+	StateFile = ".plugin_bundle.json"
+	// StartupTimeout bounds the update when the agent starts, so an unresponsive
+	// artifact store does not delay startup (the plugins already installed are used)
+	StartupTimeout = 30 * time.Second
+
+	roleVar       = "{role}"
+	sigSuffix     = ".sig"
+	maxBundleSize = 256 * 1024 * 1024
+	fetchTimeout  = 5 * time.Minute
+)
+
+var (
+	// limits of the unpacked bundle, the gzipped bundle is at most maxBundleSize
+	maxFileSize   int64 = 256 * 1024 * 1024
+	maxUnpackSize int64 = 1024 * 1024 * 1024
+)
+
+// state is persisted to the state file
+type state struct {
+	URL       string    `json:"url"`
+	SHA256    string    `json:"sha256"`
+	Files     []string  `json:"files"` // relative to the plugin directory
+	Installed time.Time `json:"installed"`
+}
+
+// Bundle fetches and installs the plugin bundle
+type Bundle struct {
+	url       string
+	publicKey ed25519.PublicKey
+	pluginDir string
+	stateFile string // in the state directory, if set (otherwise, StateFile in the plugin directory)
+	interval  time.Duration
+	client    *http.Client
+	logger    zerolog.Logger
+}
+
+// New returns a plugin bundle for the role (the plugin bundle role setting
+// takes precedence), returns nil if no plugin bundle url is configured
+func New(pluginDir, role string) (*Bundle, error) {
+	bundleURL := viper.GetString(config.KeyPluginBundleURL)
+	if bundleURL == "" {
+		return nil, nil
+	}
+
+	if pluginDir == "" {
+		return nil, errors.New("plugin bundle requires a plugin directory")
+	}
+
+	if r := viper.GetString(config.KeyPluginBundleRole); r != "" {
+		role = r
+	}
+	if strings.Contains(bundleURL, roleVar) {
+		if role == "" {
+			return nil, errors.Errorf("plugin bundle url (%s) requires a role, none set and no profile applied", bundleURL)
+		}
+		bundleURL = strings.Replace(bundleURL, roleVar, url.PathEscape(role), -1)
+	}
+
+	u, err := resolveURL(bundleURL)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := config.PluginBundlePublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var interval time.Duration
+	if v := viper.GetString(config.KeyPluginBundleInterval); v != "" {
+		interval, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing plugin bundle interval")
+		}
+	}
+
+	var stateFile string
+	if dir := viper.GetString(config.KeyStateDir); dir != "" {
+		stateFile = filepath.Join(dir, "plugin_bundle.json")
+	}
+
+	return &Bundle{
+		url:       u,
+		publicKey: key,
+		pluginDir: pluginDir,
+		stateFile: stateFile,
+		interval:  interval,
+		client:    &http.Client{Timeout: fetchTimeout},
+		logger:    log.With().Str("pkg", "pluginbundle").Str("url", u).Logger(),
+	}, nil
+}
+
+// Start checks for an updated bundle every interval, calling rescan when a
+// new bundle is installed, until ctx is done
+func (b *Bundle) Start(ctx context.Context, rescan func() error) error {
+	if b == nil || b.interval == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			updated, err := b.Update(ctx)
+			if err != nil {
+				b.logger.Warn().Err(err).Msg("updating plugin bundle")
+				continue
+			}
+			if !updated {
+				continue
+			}
+			if err := rescan(); err != nil {
+				b.logger.Warn().Err(err).Msg("rescanning plugins")
+			}
+		}
+	}
+}
+
+// Update fetches the bundle and, if it has changed since the last one
+// installed, verifies and installs it - returns true if a bundle was installed
+func (b *Bundle) Update(ctx context.Context) (bool, error) {
+	if b == nil {
+		return false, nil
+	}
+
+	data, err := b.fetch(ctx, b.url)
+	if err != nil {
+		return false, errors.Wrap(err, "fetching bundle")
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	prev := b.loadState()
+	if prev.SHA256 == digest && prev.URL == b.url {
+		b.logger.Debug().Str("sha256", digest).Msg("plugin bundle unchanged")
+		return false, nil
+	}
+
+	sig, err := b.fetch(ctx, b.url+sigSuffix)
+	if err != nil {
+		return false, errors.Wrap(err, "fetching bundle signature")
+	}
+	if err := verify(b.publicKey, data, sig); err != nil {
+		return false, err
+	}
+
+	files, err := b.install(data)
+	if err != nil {
+		return false, errors.Wrap(err, "installing bundle")
+	}
+
+	b.removeStale(prev.Files, files)
+
+	if err := b.saveState(&state{URL: b.url, SHA256: digest, Files: files, Installed: time.Now().UTC()}); err != nil {
+		b.logger.Warn().Err(err).Msg("saving plugin bundle state")
+	}
+
+	b.logger.Info().Str("sha256", digest).Int("files", len(files)).Msg("installed plugin bundle")
+	return true, nil
+}
+
+// fetch retrieves a url, at most maxBundleSize bytes
+func (b *Bundle) fetch(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing request")
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s - %s", resp.Status, u)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response")
+	}
+	if len(data) > maxBundleSize {
+		return nil, errors.Errorf("response too large (>%d bytes) - %s", maxBundleSize, u)
+	}
+
+	return data, nil
+}
+
+// install unpacks the bundle in a temporary directory within the plugin
+// directory, then moves each file into place, returns the files installed
+func (b *Bundle) install(data []byte) ([]string, error) {
+	tmpDir, err := ioutil.TempDir(b.pluginDir, ".plugin_bundle")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files, err := unpack(bytes.NewReader(data), tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		dst := filepath.Join(b.pluginDir, f)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, errors.Wrap(err, "creating directory")
+		}
+		if err := os.Rename(filepath.Join(tmpDir, f), dst); err != nil {
+			return nil, errors.Wrapf(err, "installing %s", f)
+		}
+	}
+
+	return files, nil
+}
+
+// removeStale removes the files installed by the previous bundle which are
+// not in the current bundle
+func (b *Bundle) removeStale(prev, curr []string) {
+	keep := make(map[string]bool, len(curr))
+	for _, f := range curr {
+		keep[f] = true
+	}
+	for _, f := range prev {
+		if keep[f] || !validName(f) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.pluginDir, f)); err != nil && !os.IsNotExist(err) {
+			b.logger.Warn().Err(err).Str("file", f).Msg("removing file from previous bundle")
+			continue
+		}
+		b.logger.Info().Str("file", f).Msg("removed file from previous bundle")
+	}
+}
+
+// statePath returns the location of the bundle state file
+func (b *Bundle) statePath() string {
+	if b.stateFile != "" {
+		return b.stateFile
+	}
+	return filepath.Join(b.pluginDir, StateFile)
+}
+
+func (b *Bundle) loadState() *state {
+	var s state
+	data, err := ioutil.ReadFile(b.statePath())
+	if err != nil {
+		return &s
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		b.logger.Warn().Err(err).Msg("ignoring plugin bundle state")
+		return &state{}
+	}
+	return &s
+}
+
+func (b *Bundle) saveState(s *state) error {
+	file := b.statePath()
+	sf, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil {
+		return errors.Wrap(err, "creating temp state file")
+	}
+
+	enc := json.NewEncoder(sf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		sf.Close()
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "error encoding state (removing temp file)")
+	}
+
+	sf.Close()
+	if err := os.Rename(sf.Name(), file); err != nil {
+		os.Remove(sf.Name())
+		return errors.Wrap(err, "updating state file (removing temp file)")
+	}
+
+	return nil
+}
+
+// resolveURL verifies the bundle url, s3://bucket/key is fetched from the
+// bucket's https endpoint (use a presigned https url for private buckets)
+func resolveURL(bundleURL string) (string, error) {
+	u, err := url.Parse(bundleURL)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing plugin bundle url")
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.String(), nil
+	case "s3":
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return "", errors.Errorf("invalid plugin bundle url (%s), expected s3://bucket/key", bundleURL)
+		}
+		return "https://" + u.Host + ".s3.amazonaws.com/" + strings.TrimPrefix(u.Path, "/"), nil
+	default:
+		return "", errors.Errorf("invalid plugin bundle url scheme (%s), must be https or s3", u.Scheme)
+	}
+}
+
+// verify checks the ed25519 signature (raw or base64) of the bundle
+func verify(key ed25519.PublicKey, data, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return errors.Wrap(err, "decoding bundle signature")
+		}
+		sig = decoded
+	}
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(key, data, sig) {
+		return errors.New("invalid bundle signature")
+	}
+	return nil
+}
+
+// unpack extracts the regular files (and directories) of a tar.gz into dir,
+// returns the files extracted - links, devices, absolute paths, paths outside
+// of dir and files over maxFileSize (or maxUnpackSize in total) are rejected
+func unpack(r io.Reader, dir string) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading bundle")
+	}
+	defer gz.Close()
+
+	var files []string
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading bundle")
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if name == "." {
+			continue
+		}
+		if !validName(name) {
+			return nil, errors.Errorf("invalid bundle entry (%s)", hdr.Name)
+		}
+		dst := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return nil, errors.Wrap(err, "creating directory")
+			}
+		case tar.TypeReg, tar.TypeRegA: //nolint:staticcheck
+			if hdr.Size > maxFileSize {
+				return nil, errors.Errorf("bundle entry (%s) too large (>%d bytes)", hdr.Name, maxFileSize)
+			}
+			if total+hdr.Size > maxUnpackSize {
+				return nil, errors.Errorf("bundle too large unpacked (>%d bytes)", maxUnpackSize)
+			}
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return nil, errors.Wrap(err, "creating directory")
+			}
+			f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return nil, errors.Wrap(err, "creating file")
+			}
+			n, err := io.Copy(f, io.LimitReader(tr, hdr.Size))
+			if err != nil {
+				f.Close()
+				return nil, errors.Wrapf(err, "extracting %s", hdr.Name)
+			}
+			total += n
+			if err := f.Close(); err != nil {
+				return nil, errors.Wrapf(err, "extracting %s", hdr.Name)
+			}
+			files = append(files, name)
+		default:
+			return nil, errors.Errorf("unsupported bundle entry type (%s)", hdr.Name)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("empty bundle")
+	}
+
+	return files, nil
+}
+
+// validName determines if a (cleaned) bundle entry name is relative and
+// within the plugin directory
+func validName(name string) bool {
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, string(filepath.Separator)) {
+		return false
+	}
+	if name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return name != StateFile
+}
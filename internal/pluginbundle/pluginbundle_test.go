@@ -0,0 +1,269 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package pluginbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func makeBundle(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("writing header (%s)", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("writing content (%s)", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar (%s)", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("closing gzip (%s)", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer viper.Reset()
+
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generating key (%s)", err)
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	t.Log("\tnot configured")
+	{
+		viper.Reset()
+		b, err := New("/tmp", "web")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if b != nil {
+			t.Fatal("expected nil")
+		}
+	}
+
+	t.Log("\trole from profile")
+	{
+		viper.Reset()
+		viper.Set(config.KeyPluginBundleURL, "s3://bucket/plugins/{role}.tgz")
+		viper.Set(config.KeyPluginBundlePublicKey, key)
+		b, err := New("/tmp", "web")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if b.url != "https://bucket.s3.amazonaws.com/plugins/web.tgz" {
+			t.Fatalf("unexpected url (%s)", b.url)
+		}
+	}
+
+	t.Log("\trole setting")
+	{
+		viper.Set(config.KeyPluginBundleRole, "db")
+		b, err := New("/tmp", "web")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if b.url != "https://bucket.s3.amazonaws.com/plugins/db.tgz" {
+			t.Fatalf("unexpected url (%s)", b.url)
+		}
+	}
+
+	t.Log("\tno role")
+	{
+		viper.Set(config.KeyPluginBundleRole, "")
+		if _, err := New("/tmp", ""); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tno plugin dir")
+	{
+		if _, err := New("", "web"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Log("Testing Update")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generating key (%s)", err)
+	}
+
+	dir, err := ioutil.TempDir("", "pluginbundle")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var bundle, sig []byte
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/web.tgz":
+			_, _ = w.Write(bundle)
+		case "/web.tgz.sig":
+			_, _ = w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	b := &Bundle{
+		url:       ts.URL + "/web.tgz",
+		publicKey: pub,
+		pluginDir: dir,
+		client:    ts.Client(),
+		logger:    zerolog.Nop(),
+	}
+
+	t.Log("\tinstall")
+	{
+		bundle = makeBundle(t, map[string]string{"foo.sh": "#!/bin/sh\n", "bar.sh": "#!/bin/sh\n", "lib/common.sh": "x=1\n"})
+		sig = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, bundle)))
+		updated, err := b.Update(context.Background())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !updated {
+			t.Fatal("expected updated")
+		}
+		fi, err := os.Stat(filepath.Join(dir, "foo.sh"))
+		if err != nil {
+			t.Fatalf("expected foo.sh, got (%s)", err)
+		}
+		if fi.Mode().Perm()&0111 == 0 {
+			t.Fatalf("expected executable, got %s", fi.Mode())
+		}
+		if _, err := os.Stat(filepath.Join(dir, "lib", "common.sh")); err != nil {
+			t.Fatalf("expected lib/common.sh, got (%s)", err)
+		}
+	}
+
+	t.Log("\tunchanged")
+	{
+		updated, err := b.Update(context.Background())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if updated {
+			t.Fatal("expected not updated")
+		}
+	}
+
+	t.Log("\tbad signature")
+	{
+		bundle = makeBundle(t, map[string]string{"evil.sh": "#!/bin/sh\n"})
+		if _, err := b.Update(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "evil.sh")); !os.IsNotExist(err) {
+			t.Fatal("expected evil.sh not installed")
+		}
+	}
+
+	t.Log("\tupdate (raw signature, stale file removed)")
+	{
+		bundle = makeBundle(t, map[string]string{"foo.sh": "#!/bin/sh\necho 2\n", "lib/common.sh": "x=2\n"})
+		sig = ed25519.Sign(priv, bundle)
+		updated, err := b.Update(context.Background())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !updated {
+			t.Fatal("expected updated")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bar.sh")); !os.IsNotExist(err) {
+			t.Fatal("expected bar.sh removed")
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, "foo.sh"))
+		if err != nil || string(data) != "#!/bin/sh\necho 2\n" {
+			t.Fatalf("expected updated foo.sh, got (%s) (%v)", string(data), err)
+		}
+	}
+
+	t.Log("\tpath outside plugin dir")
+	{
+		bundle = makeBundle(t, map[string]string{"../escape.sh": "#!/bin/sh\n"})
+		sig = ed25519.Sign(priv, bundle)
+		if _, err := b.Update(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.sh")); !os.IsNotExist(err) {
+			t.Fatal("expected escape.sh not installed")
+		}
+	}
+}
+
+func TestUnpackLimits(t *testing.T) {
+	t.Log("Testing unpack limits")
+
+	dir, err := ioutil.TempDir("", "pluginbundle")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(file, total int64) {
+		maxFileSize, maxUnpackSize = file, total
+	}(maxFileSize, maxUnpackSize)
+	maxFileSize, maxUnpackSize = 16, 24
+
+	tt := []struct {
+		name        string
+		files       map[string]string
+		shouldFail  bool
+		expectedErr string
+	}{
+		{"within limits", map[string]string{"foo.sh": "0123456789", "bar.sh": "0123456789"}, false, ""},
+		{"file too large", map[string]string{"foo.sh": "01234567890123456"}, true, "bundle entry (foo.sh) too large (>16 bytes)"},
+		{"total too large", map[string]string{"foo.sh": "0123456789abcdef", "bar.sh": "0123456789abcdef"}, true, "bundle too large unpacked (>24 bytes)"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s", tst.name)
+		_, err := unpack(bytes.NewReader(makeBundle(t, tst.files)), dir)
+		if tst.shouldFail {
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if err.Error() != tst.expectedErr {
+				t.Fatalf("expected (%s) got (%s)", tst.expectedErr, err)
+			}
+		} else if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+}
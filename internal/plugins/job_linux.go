@@ -0,0 +1,76 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package plugins
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// procJob is the process group of a plugin, the plugin and the processes it
+// starts (unless they create their own session or group). The group is
+// killed when the run is terminated and when the run ends, so no process of
+// the tree outlives the run.
+type procJob struct {
+	pgid   int
+	closed bool
+	sync.Mutex
+}
+
+// procAttr starts the plugin as the leader of a new process group, the plugin
+// is killed if the agent dies (its children are killed with the group when
+// the agent exits normally)
+func procAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+}
+
+// newProcJob returns the process group of the (started) plugin process,
+// limits are not applied on linux
+func newProcJob(proc *os.Process, limits jobLimits) (*procJob, error) {
+	return &procJob{pgid: proc.Pid}, nil
+}
+
+// terminate kills all processes in the group
+func (j *procJob) terminate() error {
+	if j == nil {
+		return nil
+	}
+	j.Lock()
+	defer j.Unlock()
+	if j.closed {
+		return nil
+	}
+	return j.kill()
+}
+
+// close kills processes still in the group
+func (j *procJob) close() {
+	if j == nil {
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+	if j.closed {
+		return
+	}
+	j.closed = true
+	_ = j.kill()
+}
+
+func (j *procJob) kill() error {
+	if err := syscall.Kill(-j.pgid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return errors.Wrap(err, "killing plugin process group")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux
+
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTree runs script with sh, returns the pid it echoes (background child)
+func startTree(t *testing.T, script string) (*exec.Cmd, int) {
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.SysProcAttr = procAttr()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	return cmd, pid
+}
+
+// running returns false when pid is gone or a zombie (not yet reaped)
+func running(pid int) bool {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// pid (comm) state ...
+	f := strings.Fields(string(data[strings.LastIndex(string(data), ")")+1:]))
+	return len(f) > 0 && f[0] != "Z"
+}
+
+// exited waits for pid to exit
+func exited(pid int) bool {
+	for i := 0; i < 100; i++ {
+		if !running(pid) {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+func TestProcJob(t *testing.T) {
+	t.Log("Testing procJob")
+
+	t.Log("\tterminate process group")
+	{
+		cmd, child := startTree(t, "sleep 60 & echo $!; wait")
+
+		job, err := newProcJob(cmd.Process, jobLimits{})
+		if err != nil {
+			_ = cmd.Process.Kill()
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		defer job.close()
+
+		if err := job.terminate(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := cmd.Wait(); err == nil {
+			t.Fatal("expected error (terminated)")
+		}
+		if !exited(child) {
+			t.Fatalf("expected child %d terminated", child)
+		}
+	}
+
+	t.Log("\tchildren left running terminated on close")
+	{
+		cmd, child := startTree(t, "sleep 60 & echo $!")
+
+		job, err := newProcJob(cmd.Process, jobLimits{})
+		if err != nil {
+			_ = cmd.Process.Kill()
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if !running(child) {
+			t.Fatalf("expected child %d running", child)
+		}
+
+		job.close()
+		if !exited(child) {
+			t.Fatalf("expected child %d terminated", child)
+		}
+		if err := job.terminate(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("\tclosed")
+	{
+		var job *procJob
+		if err := job.terminate(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		job.close()
+	}
+}
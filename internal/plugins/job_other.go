@@ -0,0 +1,31 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !windows,!linux
+
+package plugins
+
+import (
+	"os"
+	"syscall"
+)
+
+// procJob is a no-op, plugin process trees are contained on windows (job
+// object) and linux (process group)
+type procJob struct{}
+
+func procAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func newProcJob(proc *os.Process, limits jobLimits) (*procJob, error) {
+	return nil, nil
+}
+
+func (j *procJob) terminate() error {
+	return nil
+}
+
+func (j *procJob) close() {}
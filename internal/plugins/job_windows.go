@@ -0,0 +1,118 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package plugins
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	jobObjectCPURateControlEnable  = 0x1
+	jobObjectCPURateControlHardCap = 0x4
+	jobTerminatedExitCode          = 1
+)
+
+// jobObjectCPURateControlInformation JOBOBJECT_CPU_RATE_CONTROL_INFORMATION
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	CPURate      uint32 // cycles per 10,000 cycles of all cpus
+}
+
+// procJob is a job object containing a plugin process and all of the
+// processes it starts. The job is created with kill on close, so no process
+// of the tree outlives the run.
+type procJob struct {
+	handle windows.Handle
+	closed bool
+	sync.Mutex
+}
+
+// procAttr the plugin process is assigned to the job object once started
+func procAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// newProcJob creates a job object with the limits and assigns the (started)
+// plugin process to it. Processes the plugin started before it was assigned
+// to the job are not in the job.
+func newProcJob(proc *os.Process, limits jobLimits) (*procJob, error) {
+	handle, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating job object")
+	}
+	j := &procJob{handle: handle}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if limits.maxMemory > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_MEMORY
+		info.JobMemoryLimit = uintptr(limits.maxMemory)
+	}
+	if _, err := windows.SetInformationJobObject(handle, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		j.close()
+		return nil, errors.Wrap(err, "setting job object limits")
+	}
+
+	if limits.maxCPUPercent > 0 {
+		cpu := jobObjectCPURateControlInformation{
+			ControlFlags: jobObjectCPURateControlEnable | jobObjectCPURateControlHardCap,
+			CPURate:      uint32(limits.maxCPUPercent * 100),
+		}
+		if _, err := windows.SetInformationJobObject(handle, windows.JobObjectCpuRateControlInformation, uintptr(unsafe.Pointer(&cpu)), uint32(unsafe.Sizeof(cpu))); err != nil {
+			j.close()
+			return nil, errors.Wrap(err, "setting job object cpu rate (requires windows 8/server 2012)")
+		}
+	}
+
+	ph, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(proc.Pid))
+	if err != nil {
+		j.close()
+		return nil, errors.Wrap(err, "opening plugin process")
+	}
+	defer windows.CloseHandle(ph) //nolint:errcheck
+
+	if err := windows.AssignProcessToJobObject(handle, ph); err != nil {
+		j.close()
+		return nil, errors.Wrap(err, "assigning plugin process to job object")
+	}
+
+	return j, nil
+}
+
+// terminate kills all processes in the job
+func (j *procJob) terminate() error {
+	if j == nil {
+		return nil
+	}
+	j.Lock()
+	defer j.Unlock()
+	if j.closed {
+		return nil
+	}
+	return errors.Wrap(windows.TerminateJobObject(j.handle, jobTerminatedExitCode), "terminating job object")
+}
+
+// close releases the job, processes still in the job are killed
+func (j *procJob) close() {
+	if j == nil {
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+	if j.closed {
+		return
+	}
+	j.closed = true
+	_ = windows.CloseHandle(j.handle)
+}
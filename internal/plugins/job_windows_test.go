@@ -0,0 +1,58 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build windows
+
+package plugins
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestProcJob(t *testing.T) {
+	t.Log("Testing procJob")
+
+	t.Log("\tterminate process tree")
+	{
+		// cmd starts ping as a child process
+		cmd := exec.Command("cmd.exe", "/c", "ping -n 60 127.0.0.1 >NUL")
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		job, err := newProcJob(cmd.Process, jobLimits{maxMemory: 256 * 1024 * 1024, maxCPUPercent: 50})
+		if err != nil {
+			_ = cmd.Process.Kill()
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		defer job.close()
+
+		if err := job.terminate(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Fatal("expected error (terminated)")
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("expected process terminated")
+		}
+	}
+
+	t.Log("\tclosed")
+	{
+		var job *procJob
+		if err := job.terminate(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		job.close()
+	}
+}
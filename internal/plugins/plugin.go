@@ -8,6 +8,7 @@ package plugins
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
@@ -17,13 +18,19 @@ import (
 	"time"
 
 	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/circonus-labs/circonus-agent/internal/watchdog"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 )
 
-// drain returns and resets plugin's current metrics
-func (p *plugin) drain() *cgm.Metrics {
+var metricTypes = regexp.MustCompile("^[iIlLnOs]$")
+
+// drain returns and resets plugin's current metrics, when the plugin has not
+// produced new metrics the previous metrics are returned unless older than ttl
+func (p *plugin) drain(ttl time.Duration) *cgm.Metrics {
 	p.Lock()
 	defer p.Unlock()
 
@@ -31,6 +38,10 @@ func (p *plugin) drain() *cgm.Metrics {
 	if p.metrics == nil {
 		if p.prevMetrics == nil {
 			metrics = &cgm.Metrics{}
+		} else if ttl > 0 && time.Since(p.metricsTime) > ttl {
+			p.logger.Warn().Str("ttl", ttl.String()).Time("produced", p.metricsTime).Msg("no new metrics within ttl, discarding previous metrics")
+			p.prevMetrics = nil
+			metrics = &cgm.Metrics{}
 		} else {
 			metrics = p.prevMetrics
 		}
@@ -38,6 +49,18 @@ func (p *plugin) drain() *cgm.Metrics {
 		metrics = p.metrics
 		p.metrics = nil
 		p.prevMetrics = metrics
+		p.metricsTime = time.Now()
+	}
+
+	if len(p.status) > 0 {
+		withStatus := make(cgm.Metrics, len(*metrics)+len(p.status))
+		for mn, mv := range *metrics {
+			withStatus[mn] = mv
+		}
+		for mn, mv := range p.status {
+			withStatus[mn] = mv
+		}
+		metrics = &withStatus
 	}
 
 	return metrics
@@ -59,200 +82,292 @@ func (p *plugin) baseTagList() []string {
 
 // parsePluginOutput handles json and tab delimited output from plugins.
 func (p *plugin) parsePluginOutput(output []string) error {
-	p.Lock()
-	defer p.Unlock()
+	op := p.newOutputParser()
+	for _, line := range output {
+		op.parseLine(line)
+	}
+	return op.finish()
+}
 
-	if len(output) == 0 {
-		p.metrics = &cgm.Metrics{}
-		return errors.Errorf("zero lines of output")
+// outputParser incrementally parses plugin output as it is read. Tab delimited
+// lines are parsed as they arrive, rather than buffering the entire output,
+// json output is accumulated until complete.
+type outputParser struct {
+	p          *plugin
+	logger     zerolog.Logger
+	baseTags   []string
+	metrics    cgm.Metrics
+	json       bytes.Buffer
+	isJSON     bool
+	lines      int
+	size       int
+	duplicates int
+	start      time.Time
+}
+
+// newOutputParser returns a parser for a batch of plugin output
+func (p *plugin) newOutputParser() *outputParser {
+	p.Lock()
+	baseTags := p.baseTagList()
+	p.Unlock()
+	return &outputParser{
+		p:        p,
+		logger:   p.logger,
+		baseTags: baseTags,
+		metrics:  cgm.Metrics{},
+		start:    time.Now(),
 	}
+}
 
-	parseStart := time.Now()
-	metrics := cgm.Metrics{}
-	numDuplicates := 0
+// parseLine handles a single (non-blank) line of output, if first char of
+// first line is '{' then the output is assumed to be json
+func (op *outputParser) parseLine(line string) {
+	op.lines++
+	op.size += len(line) + 1
 
-	// if first char of first line is '{' then assume output is json
-	if output[0][:1] == "{" {
-		var jm tags.JSONMetrics
-		err := json.Unmarshal([]byte(strings.Join(output, "\n")), &jm)
-		if err != nil {
-			p.logger.Error().
-				Err(err).
-				Str("output", strings.Join(output, "\n")).
-				Msg("parsing json")
-			p.metrics = &cgm.Metrics{}
-			return errors.Wrap(err, "parsing json")
-		}
-		for mn, md := range jm {
-			// add stream tags to metric name
-			tagList := p.baseTagList()
-			tagList = append(tagList, md.Tags...)
-			metrics[tags.MetricNameWithStreamTags(mn, tags.FromList(tagList))] = cgm.Metric{Type: md.Type, Value: md.Value}
+	if op.lines == 1 && strings.HasPrefix(line, "{") {
+		op.isJSON = true
+	}
+
+	if op.isJSON {
+		if op.lines > 1 {
+			op.json.WriteByte('\n')
 		}
-		p.metrics = &metrics
-		return nil
+		op.json.WriteString(line)
+		return
 	}
 
 	// otherwise, assume it is delimited fields:
 	//  fieldDelimiter is current TAB
-	//  metric_name<TAB>metric_type[<TAB>metric_value<TAB>tags]
+	//  metric_name<TAB>metric_type[<TAB>metric_value[<TAB>tags[<TAB>timestamp]]]
 	//  foo\ti\t10  - int32 foo w/value 10
 	//  bar\tL      - uint64 bar w/o value (null, metric is present but has no value)
 	// note: tags is a comma separated list of key:value pairs (e.g. foo:bar,cat:dog)
-	metricTypes := regexp.MustCompile("^[iIlLnOs]$")
-	for _, line := range output {
-		tagList := p.baseTagList()
+	// note: timestamp (format v2) is milliseconds since epoch, tags may be empty
+	tagList := append([]string{}, op.baseTags...)
+
+	delimCount := strings.Count(line, fieldDelimiter)
+	if delimCount == 0 {
+		op.logger.Error().
+			Str("line", line).
+			Msg("invalid format, zero field delimiters found")
+		return
+	}
 
-		delimCount := strings.Count(line, fieldDelimiter)
-		if delimCount == 0 {
-			p.logger.Error().
-				Str("line", line).
-				Msg("invalid format, zero field delimiters found")
-			continue
-		}
+	fields := strings.Split(line, fieldDelimiter)
+	if len(fields) <= 1 || len(fields) > 5 {
+		op.logger.Error().
+			Str("line", line).
+			Int("fields", len(fields)).
+			Int("delimiters", delimCount).
+			Msg("invalid number of fields - expect 2, 3, 4, or 5")
+		return
+	}
 
-		fields := strings.Split(line, fieldDelimiter)
-		if len(fields) <= 1 || len(fields) > 4 {
-			p.logger.Error().
-				Str("line", line).
-				Int("fields", len(fields)).
-				Int("delimiters", delimCount).
-				Msg("invalid number of fields - expect 2, 3, or 4")
-			continue
-		}
+	metricName := strings.Replace(fields[0], " ", "_", -1)
+	metricType := strings.TrimSpace(fields[1])
 
-		metricName := strings.Replace(fields[0], " ", "_", -1)
-		metricType := strings.TrimSpace(fields[1])
+	if _, ok := op.metrics[metricName]; ok {
+		op.logger.Warn().Str("name", metricName).Msg("duplicate name, skipping")
+		op.duplicates++
+		return
+	}
 
-		if _, ok := metrics[metricName]; ok {
-			p.logger.Warn().Str("name", metricName).Msg("duplicate name, skipping")
-			numDuplicates++
-			continue
+	if !metricTypes.MatchString(metricType) {
+		op.logger.Error().
+			Str("line", line).
+			Str("type", metricType).
+			Msg("invalid metric type")
+		return
+	}
+
+	// only received a name and type (intentionally null value)
+	if len(fields) == 2 {
+		op.metrics[tags.MetricNameWithStreamTags(metricName, tags.FromList(tagList))] = cgm.Metric{
+			Type:  metricType,
+			Value: nullMetricValue,
 		}
+		return
+	}
 
-		if !metricTypes.MatchString(metricType) {
-			p.logger.Error().
+	metricValue := fields[2]
+
+	// add stream tags to metric name
+	if len(fields) >= 4 && fields[3] != "" {
+		metricTags := strings.Split(fields[3], tags.Separator)
+		tagList = append(tagList, metricTags...)
+	}
+
+	// explicit timestamp
+	var ts uint64
+	if len(fields) == 5 {
+		var err error
+		ts, err = sample.ParseTimestamp(strings.TrimSpace(fields[4]))
+		if err != nil {
+			op.logger.Error().
+				Err(err).
 				Str("line", line).
-				Str("type", metricType).
-				Msg("invalid metric type")
-			continue
+				Msg("invalid timestamp")
+			return
 		}
+	}
+	metricName = tags.MetricNameWithStreamTags(metricName, tags.FromList(tagList))
 
-		// only received a name and type (intentionally null value)
-		if len(fields) == 2 {
-			metrics[tags.MetricNameWithStreamTags(metricName, tags.FromList(tagList))] = cgm.Metric{
-				Type:  metricType,
-				Value: nullMetricValue,
-			}
-			continue
+	// intentionally null value, explicit syntax
+	if strings.ToLower(metricValue) == nullMetricValue {
+		op.metrics[metricName] = cgm.Metric{
+			Type:  metricType,
+			Value: nullMetricValue,
 		}
+		return
+	}
 
-		metricValue := fields[2]
+	metric := cgm.Metric{}
 
-		// add stream tags to metric name
-		if len(fields) == 4 {
-			metricTags := strings.Split(fields[3], tags.Separator)
-			tagList = append(tagList, metricTags...)
+	switch metricType {
+	case "i": // signed 32bit
+		metric.Type = metricType
+		i, err := strconv.ParseInt(metricValue, 10, 32)
+		if err != nil {
+			op.logger.Error().
+				Err(err).
+				Str("line", line).
+				Msg("unable to parse int32")
+			return
 		}
-		metricName = tags.MetricNameWithStreamTags(metricName, tags.FromList(tagList))
-
-		// intentionally null value, explicit syntax
-		if strings.ToLower(metricValue) == nullMetricValue {
-			metrics[metricName] = cgm.Metric{
-				Type:  metricType,
-				Value: nullMetricValue,
-			}
-			continue
+		metric.Value = int32(i)
+	case "I": // unsigned 32bit
+		metric.Type = metricType
+		u, err := strconv.ParseUint(metricValue, 10, 32)
+		if err != nil {
+			op.logger.Error().
+				Err(err).
+				Str("line", line).
+				Msg("unable to parse uint32")
+			return
 		}
-
-		metric := cgm.Metric{}
-
-		switch metricType {
-		case "i": // signed 32bit
-			metric.Type = metricType
-			i, err := strconv.ParseInt(metricValue, 10, 32)
-			if err != nil {
-				p.logger.Error().
-					Err(err).
-					Str("line", line).
-					Msg("unable to parse int32")
-				continue
-			}
-			metric.Value = int32(i)
-		case "I": // unsigned 32bit
-			metric.Type = metricType
-			u, err := strconv.ParseUint(metricValue, 10, 32)
-			if err != nil {
-				p.logger.Error().
-					Err(err).
-					Str("line", line).
-					Msg("unable to parse uint32")
-				continue
-			}
-			metric.Value = uint32(u)
-		case "l": // signed 64bit
-			metric.Type = metricType
-			i, err := strconv.ParseInt(metricValue, 10, 64)
-			if err != nil {
-				p.logger.Error().
-					Err(err).
-					Str("line", line).
-					Msg("unable to parse int64")
-				continue
-			}
-			metric.Value = i
-		case "L": // unsigned 64bit
-			metric.Type = metricType
-			u, err := strconv.ParseUint(metricValue, 10, 64)
-			if err != nil {
-				p.logger.Error().
-					Err(err).
-					Str("line", line).
-					Msg("unable to parse uint64")
-				continue
-			}
-			metric.Value = u
-		case "n": // double
-			metric.Type = metricType
-			f, err := strconv.ParseFloat(metricValue, 64)
-			if err != nil {
-				p.logger.Error().
-					Err(err).
-					Str("line", line).
-					Msg("unable to parse double/float")
-				continue
-			}
-			metric.Value = f
-		case "s": // string
-			metric.Type = metricType
-			metric.Value = metricValue
-		case "O": // have Circonus automatically detect
-			metric.Type = metricType
-			metric.Value = metricValue
-		default:
-			p.logger.Error().
+		metric.Value = uint32(u)
+	case "l": // signed 64bit
+		metric.Type = metricType
+		i, err := strconv.ParseInt(metricValue, 10, 64)
+		if err != nil {
+			op.logger.Error().
+				Err(err).
 				Str("line", line).
-				Str("type", metricType).
-				Msg("unknown metric type")
-			continue
+				Msg("unable to parse int64")
+			return
+		}
+		metric.Value = i
+	case "L": // unsigned 64bit
+		metric.Type = metricType
+		u, err := strconv.ParseUint(metricValue, 10, 64)
+		if err != nil {
+			op.logger.Error().
+				Err(err).
+				Str("line", line).
+				Msg("unable to parse uint64")
+			return
 		}
+		metric.Value = u
+	case "n": // double
+		metric.Type = metricType
+		f, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			op.logger.Error().
+				Err(err).
+				Str("line", line).
+				Msg("unable to parse double/float")
+			return
+		}
+		metric.Value = f
+	case "s": // string
+		metric.Type = metricType
+		metric.Value = metricValue
+	case "O": // have Circonus automatically detect
+		metric.Type = metricType
+		metric.Value = metricValue
+	default:
+		op.logger.Error().
+			Str("line", line).
+			Str("type", metricType).
+			Msg("unknown metric type")
+		return
+	}
 
-		metrics[metricName] = metric
+	metric.Value = sample.Stamp(metric.Value, ts)
+	op.metrics[metricName] = metric
+}
+
+// finish completes parsing the batch of output and saves the plugin's metrics
+func (op *outputParser) finish() error {
+	p := op.p
+	p.Lock()
+	defer p.Unlock()
+
+	if op.lines == 0 {
+		p.metrics = &cgm.Metrics{}
+		return errors.Errorf("zero lines of output")
 	}
 
-	p.logger.Debug().
-		Str("duration", time.Since(parseStart).String()).
-		Int("lines", len(output)).
-		Int("metrics", len(metrics)).
-		Int("duplicates", numDuplicates).
-		Int("errors", len(output)-(len(metrics)+numDuplicates)).
+	if op.isJSON {
+		var jm tags.JSONMetrics
+		err := json.Unmarshal(op.json.Bytes(), &jm)
+		if err != nil {
+			op.logger.Error().
+				Err(err).
+				Str("output", op.json.String()).
+				Msg("parsing json")
+			p.metrics = &cgm.Metrics{}
+			return errors.Wrap(err, "parsing json")
+		}
+		metrics := make(cgm.Metrics, len(jm))
+		for mn, md := range jm {
+			if md.Timestamp > 0 {
+				if err := sample.Validate(md.Timestamp); err != nil {
+					op.logger.Error().Err(err).Str("metric", mn).Msg("invalid timestamp, ignoring metric")
+					continue
+				}
+			}
+			// add stream tags to metric name
+			tagList := append([]string{}, op.baseTags...)
+			tagList = append(tagList, md.Tags...)
+			metrics[tags.MetricNameWithStreamTags(mn, tags.FromList(tagList))] = cgm.Metric{Type: md.Type, Value: sample.Stamp(md.Value, md.Timestamp)}
+		}
+		p.metrics = &metrics
+		return nil
+	}
+
+	op.logger.Debug().
+		Str("duration", time.Since(op.start).String()).
+		Int("lines", op.lines).
+		Int("bytes", op.size).
+		Int("metrics", len(op.metrics)).
+		Int("duplicates", op.duplicates).
+		Int("errors", op.lines-(len(op.metrics)+op.duplicates)).
 		Msg("processed plugin output")
 
+	metrics := op.metrics
 	p.metrics = &metrics
 
 	return nil
 }
 
+// abort discards the batch of output, the plugin's metrics are reset so that
+// a partial (or stale) set of metrics is not reported
+func (op *outputParser) abort() {
+	op.p.Lock()
+	op.p.metrics = &cgm.Metrics{}
+	op.p.Unlock()
+}
+
+// stop terminates a running plugin (and its process tree), e.g. a long
+// running plugin which was removed, the plugin is not run again
+func (p *plugin) stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
 // exec runs a specific plugin and saves plugin output
 func (p *plugin) exec() error {
 	// NOTE: !! IMPORTANT !!
@@ -283,17 +398,22 @@ func (p *plugin) exec() error {
 	plog.Debug().Msg("start")
 	p.currStart = time.Now()
 	p.running = true
-	// TBD: timeouts, create a new deadline context
-	//      Problem is some plugins do not exit intentionally - long running.
-	//      There is no way [currently] to know whether a plugin is
-	//      intentionally "long running".
-	//
+	// NOTE: timeouts are opt-in (plugin timeout), some plugins do not exit
+	//       intentionally - long running. There is no way [currently] to
+	//       know whether a plugin is intentionally "long running".
+	runCtx, cancel := p.ctx, context.CancelFunc(func() {})
+	if p.timeout > 0 {
+		runCtx, cancel = context.WithTimeout(p.ctx, p.timeout)
+	}
+	defer cancel()
+
 	// G204: Subprocess launched with function call as argument or cmd arguments (gosec)
 	// -- the `command` is built internally, there is no tainted data in the `command`,
 	//    there is no remediation for this warning/error in gosec documentation.
 	//
-	p.cmd = exec.CommandContext(p.ctx, p.command) //nolint:gosec
+	p.cmd = exec.CommandContext(runCtx, p.command) //nolint:gosec
 	p.cmd.Dir = p.runDir
+	p.cmd.SysProcAttr = procAttr()
 	if p.instanceArgs != nil {
 		p.cmd.Args = append(p.cmd.Args, p.instanceArgs...)
 	}
@@ -309,6 +429,8 @@ func (p *plugin) exec() error {
 		p.lastEnd = time.Now()
 		p.lastRunDuration = time.Since(p.lastStart)
 		p.lastError = err
+		timedOut := err != nil && runCtx.Err() == context.DeadlineExceeded
+		p.status = p.runStatus(p.cmd.ProcessState, p.lastRunDuration, timedOut, errOut.String())
 		p.running = false
 		p.Unlock()
 	}
@@ -323,8 +445,10 @@ func (p *plugin) exec() error {
 		return errors.Wrap(err, msg)
 	}
 
-	lines := []string{}
 	scanner := bufio.NewScanner(stdout)
+	if p.maxOutput > bufio.MaxScanTokenSize {
+		scanner.Buffer(make([]byte, 0, 4096), p.maxOutput)
+	}
 
 	if err := p.cmd.Start(); err != nil {
 		msg := "cmd start"
@@ -336,37 +460,107 @@ func (p *plugin) exec() error {
 		return errors.Wrap(err, msg)
 	}
 
+	// the plugin and the processes it starts run in a job object (windows) or
+	// process group (linux) so that the whole process tree is terminated
+	// (timeout, max output, agent shutdown), on windows the memory/cpu limits
+	// apply to the tree. Processes left running when the plugin exits are
+	// terminated with the job.
+	job, err := newProcJob(p.cmd.Process, p.limits)
+	if err != nil {
+		plog.Warn().Err(err).Msg("plugin process tree not contained")
+	}
+	defer job.close()
+	if job != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-runCtx.Done():
+				if err := job.terminate(); err != nil {
+					plog.Warn().Err(err).Msg("terminating plugin process tree")
+				}
+			case <-stop:
+			}
+		}()
+	}
+
+	// plugins without a timeout may be long running, only runs with a
+	// timeout are tracked by the watchdog. A stuck run has outlived the
+	// timeout termination (e.g. a child process holding stdout open), a
+	// restart kills the plugin and closes stdout so the run completes.
+	if p.timeout > 0 {
+		cmd := p.cmd
+		done := watchdog.Track(watchdog.Plugins, p.id, p.timeout, func() {
+			if err := job.terminate(); err != nil {
+				plog.Warn().Err(err).Msg("terminating stuck plugin process tree")
+			}
+			if err := cmd.Process.Kill(); err != nil {
+				plog.Warn().Err(err).Msg("terminating stuck plugin")
+			}
+			stdout.Close()
+		})
+		defer done()
+	}
+
+	var runErr error
+
+	// output is parsed as it is read, a batch of output (all output, or for
+	// long running plugins, output up to a blank line) larger than maxOutput
+	// terminates the plugin rather than buffering it all in memory
+	op := p.newOutputParser()
+	exceeded := false
 	for scanner.Scan() {
 		line := scanner.Text()
 
 		// blank line, long running plugin signal to parse
 		// what has already been received.
 		if line == "" {
-			if err := p.parsePluginOutput(lines); err != nil {
+			if err := op.finish(); err != nil {
 				plog.Error().Err(err).Str("id", p.id).Msg("parsing output")
 			}
-			lines = []string{}
+			op = p.newOutputParser()
 			continue
 		}
 
-		// add line to buffer for processing
-		lines = append(lines, line)
-	}
+		if p.maxOutput > 0 && op.size+len(line)+1 > p.maxOutput {
+			exceeded = true
+			break
+		}
 
-	var runErr error
+		op.parseLine(line)
+	}
 
 	if err := scanner.Err(); err != nil {
-		plog.Error().
-			Err(err).
-			Msg("reading stdio")
+		if err == bufio.ErrTooLong {
+			exceeded = true
+		} else {
+			plog.Error().
+				Err(err).
+				Msg("reading stdio")
 
-		runErr = errors.Wrap(err, "scanner, reading stdio")
+			runErr = errors.Wrap(err, "scanner, reading stdio")
+		}
 	}
 
-	// parse lines if there are any in the buffer
-	// or, in case of long running plugin, any left in buffer on exit
-	if err := p.parsePluginOutput(lines); err != nil {
-		plog.Error().Err(err).Str("id", p.id).Msg("parsing output")
+	if exceeded {
+		plog.Error().
+			Int("max_bytes", p.maxOutput).
+			Str("cmd", p.command).
+			Msg("output exceeds max size, terminating plugin")
+		op.abort()
+		if err := job.terminate(); err != nil {
+			plog.Warn().Err(err).Msg("terminating plugin process tree")
+		}
+		if err := p.cmd.Process.Kill(); err != nil {
+			plog.Warn().Err(err).Msg("terminating plugin")
+		}
+		runErr = errors.Errorf("output exceeds max size (%d bytes)", p.maxOutput)
+	} else {
+		// parse lines if there are any in the buffer
+		// or, in case of long running plugin, any left in buffer on exit
+		if err := op.finish(); err != nil {
+			plog.Error().Err(err).Str("id", p.id).Msg("parsing output")
+		}
 	}
 
 	if err := p.cmd.Wait(); err != nil {
@@ -400,6 +594,14 @@ func (p *plugin) exec() error {
 		}
 	}
 
+	if runErr != nil && runCtx.Err() == context.DeadlineExceeded {
+		plog.Error().
+			Str("timeout", p.timeout.String()).
+			Str("cmd", p.command).
+			Msg("timed out, terminated plugin")
+		runErr = errors.Wrapf(runErr, "timed out (%s)", p.timeout)
+	}
+
 	resetStatus(runErr)
 	return runErr
 }
@@ -14,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/circonus-labs/circonus-agent/internal/sample"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/pkg/errors"
@@ -36,7 +37,7 @@ func TestDrain(t *testing.T) {
 	t.Log("blank w/o prevMetrics")
 	{
 
-		data := p.drain()
+		data := p.drain(0)
 		if data == nil {
 			t.Fatal("expected data")
 		}
@@ -46,11 +47,33 @@ func TestDrain(t *testing.T) {
 	{
 		p.prevMetrics = &cgm.Metrics{}
 
-		data := p.drain()
+		data := p.drain(0)
 		if data == nil {
 			t.Fatal("expected data")
 		}
 	}
+
+	t.Log("new metrics, then previous metrics within ttl")
+	{
+		p.metrics = &cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}
+		if data := p.drain(time.Minute); len(*data) != 1 {
+			t.Fatalf("expected new metrics, got %#v", data)
+		}
+		if data := p.drain(time.Minute); len(*data) != 1 {
+			t.Fatalf("expected previous metrics, got %#v", data)
+		}
+	}
+
+	t.Log("previous metrics older than ttl")
+	{
+		p.metricsTime = time.Now().Add(-2 * time.Minute)
+		if data := p.drain(time.Minute); len(*data) != 0 {
+			t.Fatalf("expected no metrics, got %#v", data)
+		}
+		if p.prevMetrics != nil {
+			t.Fatal("expected previous metrics discarded")
+		}
+	}
 }
 
 func TestParsePluginOutput(t *testing.T) {
@@ -108,6 +131,22 @@ func TestParsePluginOutput(t *testing.T) {
 		}
 	}
 
+	t.Log("json metric w/timestamp")
+	{
+		err := p.parsePluginOutput([]string{`{"metric": {"_type": "L", "_value": 22, "_ts": 1590000000123}}`})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(*p.metrics) != 1 {
+			t.Fatalf("expected 1 metric, have (%#v)", p.metrics)
+		}
+		for _, m := range *p.metrics {
+			if _, ts := sample.Unwrap(m.Value); ts != 1590000000123 {
+				t.Fatalf("expected timestamp 1590000000123, got %d", ts)
+			}
+		}
+	}
+
 	var tabDelimTests = []struct {
 		description     string
 		output          []string
@@ -129,7 +168,10 @@ func TestParsePluginOutput(t *testing.T) {
 		{"invalid uint64", []string{"metric\tL\tfoo"}, 0},
 		{"invalid double", []string{"metric\tn\tfoo"}, 0},
 		{"invalid delimiter", []string{"metric L 1"}, 0},
-		{"invalid number of fields", []string{"metric\tL\t1\tfoo\tbar"}, 0},
+		{"timestamp", []string{"metric\tL\t1\t\t1590000000123"}, 1},
+		{"timestamp w/tags", []string{"metric\tL\t1\tfoo:bar\t1590000000123"}, 1},
+		{"invalid timestamp", []string{"metric\tL\t1\tfoo:bar\tbaz"}, 0},
+		{"invalid number of fields", []string{"metric\tL\t1\tfoo\tbar\tbaz"}, 0},
 		{"invalid metric type", []string{"metric\tfoo\t1"}, 0},
 		{"invalid metric type", []string{"metric\t\t1"}, 0},
 	}
@@ -253,4 +295,39 @@ func TestExec(t *testing.T) {
 			t.Fatalf("expected '%s' metric, got (%v)", metricName, *p.metrics)
 		}
 	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	t.Log("output within max size")
+	{
+		p.command = path.Join(testDir, "bigoutput.sh")
+		p.instanceArgs = nil
+		p.maxOutput = 1024 * 1024
+		err := p.exec()
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(*p.metrics) != 10000 {
+			t.Fatalf("expected 10000 metrics, got %d", len(*p.metrics))
+		}
+	}
+
+	t.Log("output exceeds max size")
+	{
+		p.command = path.Join(testDir, "bigoutput.sh")
+		p.instanceArgs = nil
+		p.maxOutput = 1024
+		err := p.exec()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(err.Error(), "output exceeds max size") {
+			t.Fatalf("expected output exceeds max size error, got (%s)", err)
+		}
+		if len(*p.metrics) != 0 {
+			t.Fatalf("expected 0 metrics, got %d", len(*p.metrics))
+		}
+		p.maxOutput = 0
+	}
 }
@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -32,6 +33,11 @@ type Plugins struct {
 	plugList      []string
 	ctx           context.Context
 	logger        zerolog.Logger
+	limits        jobLimits // windows, process tree limits (see plugin max memory/cpu)
+	maxOutput     int
+	metricTTL     time.Duration              // last output of a plugin is not used once older (see metric ttl)
+	timeout       time.Duration              // plugin runs taking longer are terminated (see plugin timeout)
+	maintenance   []config.MaintenanceWindow // scheduled windows pausing plugins (--maintenance-window)
 	pluginDir     string
 	reservedNames map[string]bool
 	running       bool
@@ -43,6 +49,7 @@ type plugin struct {
 	cmd             *exec.Cmd
 	command         string
 	ctx             context.Context
+	cancel          context.CancelFunc // stops the plugin's run (e.g. plugin removed), see stop
 	id              string
 	instanceArgs    []string
 	instanceID      string
@@ -51,17 +58,28 @@ type plugin struct {
 	currStart       time.Time
 	lastStart       time.Time
 	lastEnd         time.Time
+	limits          jobLimits
 	logger          zerolog.Logger
+	maxOutput       int
 	metrics         *cgm.Metrics
+	metricsTime     time.Time // when prevMetrics were produced
 	name            string
 	prevMetrics     *cgm.Metrics
 	runDir          string
 	running         bool
 	runTTL          time.Duration
+	timeout         time.Duration
+	status          cgm.Metrics // run status metrics of the last run (exit code, duration, failure details)
 	baseTags        []string
 	sync.Mutex
 }
 
+// jobLimits of a plugin's process tree, applied with a job object on windows (0=unlimited)
+type jobLimits struct {
+	maxMemory     int64
+	maxCPUPercent int
+}
+
 const (
 	fieldDelimiter  = "\t"
 	nullMetricValue = "[[null]]"
@@ -73,10 +91,35 @@ func New(ctx context.Context, defaultPluginPath string) (*Plugins, error) {
 		ctx:           ctx,
 		running:       false,
 		logger:        log.With().Str("pkg", "plugins").Logger(),
-		reservedNames: map[string]bool{"prom": true, "write": true, "statsd": true},
+		reservedNames: map[string]bool{"prom": true, "write": true, "statsd": true, "otlp": true, "graphite": true},
 		active:        make(map[string]*plugin),
+		maxOutput:     viper.GetInt(config.KeyPluginMaxOutputBytes),
+		limits: jobLimits{
+			maxMemory:     viper.GetInt64(config.KeyPluginMaxMemoryBytes),
+			maxCPUPercent: viper.GetInt(config.KeyPluginMaxCPUPercent),
+		},
+	}
+
+	ttls, err := config.MetricTTLs()
+	if err != nil {
+		return nil, errors.Wrap(err, "metric ttl")
+	}
+	p.metricTTL = ttls["plugins"]
+
+	if timeout := viper.GetString(config.KeyPluginTimeout); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "plugin timeout")
+		}
+		p.timeout = d
 	}
 
+	windows, err := config.MaintenanceWindows()
+	if err != nil {
+		return nil, errors.Wrap(err, "maintenance window config")
+	}
+	p.maintenance = windows
+
 	pluginDir := viper.GetString(config.KeyPluginDir)
 	pluginList := viper.GetStringSlice(config.KeyPluginList)
 
@@ -133,6 +176,12 @@ func New(ctx context.Context, defaultPluginPath string) (*Plugins, error) {
 	return &p, nil
 }
 
+// Dir returns the plugin directory, empty when using a plugin list (or the
+// directory does not exist)
+func (p *Plugins) Dir() string {
+	return p.pluginDir
+}
+
 // Flush plugin metrics
 func (p *Plugins) Flush(pluginName string) *cgm.Metrics {
 	p.RLock()
@@ -142,13 +191,17 @@ func (p *Plugins) Flush(pluginName string) *cgm.Metrics {
 	// appstats.MapSet("plugins", "last_flush", time.Now())
 
 	metrics := cgm.Metrics{}
+	active := config.ActiveMaintenanceWindows(p.maintenance, time.Now())
 
 	for pluginID, plug := range p.active {
+		if p.inMaintenance(active, pluginID) {
+			continue
+		}
 		if pluginName == "" || // all plugins
 			pluginID == pluginName || // specific plugin
 			strings.HasPrefix(pluginID, pluginName+defaults.MetricNameSeparator) { // specific plugin with instances
 
-			m := plug.drain()
+			m := plug.drain(p.metricTTL)
 			for mn, mv := range *m {
 				metrics[mn] = mv
 			}
@@ -198,12 +251,18 @@ func (p *Plugins) Run(pluginName string) error {
 
 	var wg sync.WaitGroup
 
+	active := config.ActiveMaintenanceWindows(p.maintenance, start)
+
 	if pluginName != "" {
 		numFound := 0
 		for pluginID, pluginRef := range p.active {
 			if pluginID == pluginName || // specific plugin
 				strings.HasPrefix(pluginID, pluginName+"`") { // specific plugin with instances
 				numFound++
+				if p.inMaintenance(active, pluginID) {
+					p.logger.Debug().Str("id", pluginID).Msg("plugin paused, maintenance window")
+					continue
+				}
 				wg.Add(1)
 				p.logger.Debug().Str("id", pluginID).Msg("running")
 				go func(id string, plug *plugin) {
@@ -223,6 +282,10 @@ func (p *Plugins) Run(pluginName string) error {
 	} else {
 		p.logger.Debug().Str("plugin(s)", strings.Join(p.plugList, ",")).Msg("running")
 		for pluginID, pluginRef := range p.active {
+			if p.inMaintenance(active, pluginID) {
+				p.logger.Debug().Str("id", pluginID).Msg("plugin paused, maintenance window")
+				continue
+			}
 			wg.Add(1)
 			go func(id string, plug *plugin) {
 				if err := plug.exec(); err != nil {
@@ -247,6 +310,21 @@ func (p *Plugins) Run(pluginName string) error {
 	return nil
 }
 
+// inMaintenance returns true if an active maintenance window selects the
+// plugin, by id (name`instance) or by name (all instances)
+func (p *Plugins) inMaintenance(active []config.MaintenanceWindow, pluginID string) bool {
+	if len(active) == 0 {
+		return false
+	}
+	if config.InMaintenance(active, pluginID) {
+		return true
+	}
+	if idx := strings.Index(pluginID, "`"); idx != -1 {
+		return config.InMaintenance(active, pluginID[:idx])
+	}
+	return false
+}
+
 // IsValid determines if a specific plugin is valid
 func (p *Plugins) IsValid(pluginName string) bool {
 	if pluginName == "" {
@@ -266,7 +344,7 @@ func (p *Plugins) IsValid(pluginName string) bool {
 	return false
 }
 
-// IsInternal checks to see if the plugin is one of the internal plugins (write|statsd)
+// IsInternal checks to see if the plugin is one of the internal plugins (write|statsd|otlp|graphite)
 func (p *Plugins) IsInternal(pluginName string) bool {
 	if pluginName == "" {
 		return false
@@ -276,6 +354,20 @@ func (p *Plugins) IsInternal(pluginName string) bool {
 	return reserved
 }
 
+// List returns the sorted ids of the active plugins
+func (p *Plugins) List() []string {
+	p.RLock()
+	defer p.RUnlock()
+
+	ids := make([]string, 0, len(p.active))
+	for id := range p.active {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
 // Inventory returns list of active plugins
 func (p *Plugins) Inventory() []byte {
 	p.Lock()
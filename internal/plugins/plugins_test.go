@@ -11,6 +11,7 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -337,3 +338,55 @@ func TestInventory(t *testing.T) {
 		}
 	}
 }
+
+func TestList(t *testing.T) {
+	t.Log("Testing List")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	viper.Reset()
+	viper.Set(config.KeyPluginDir, "testdata")
+
+	p, nerr := New(context.Background(), "")
+	if nerr != nil {
+		t.Fatalf("new err %s", nerr)
+	}
+
+	t.Log("No plugins")
+	{
+		if ids := p.List(); len(ids) != 0 {
+			t.Fatalf("expected no plugins, got %v", ids)
+		}
+	}
+
+	b, err := builtins.New(context.Background())
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	p.pluginDir = "testdata"
+
+	if err := p.Scan(b); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	t.Log("Valid")
+	{
+		ids := p.List()
+		if len(ids) == 0 {
+			t.Fatal("expected plugins")
+		}
+		if !sort.StringsAreSorted(ids) {
+			t.Fatalf("expected sorted ids, got %v", ids)
+		}
+		found := false
+		for _, id := range ids {
+			if id == "test" {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected test plugin, got %v", ids)
+		}
+	}
+}
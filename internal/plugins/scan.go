@@ -6,6 +6,7 @@
 package plugins
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -49,6 +50,46 @@ func (p *Plugins) Scan(b *builtins.Builtins) error {
 		}
 	}
 
+	if err := p.scan(b); err != nil {
+		return err
+	}
+
+	initialRun()
+
+	if len(p.active) == 0 {
+		p.logger.Warn().Msg("no active plugins found")
+	}
+
+	return nil
+}
+
+// Rescan deactivates the plugins whose command no longer exists and scans the
+// plugin directory for new/updated plugins (e.g. after a plugin bundle update)
+func (p *Plugins) Rescan(b *builtins.Builtins) error {
+	p.Lock()
+	for id, plug := range p.active {
+		if _, err := os.Stat(plug.command); err != nil {
+			p.logger.Info().Str("id", id).Str("cmd", plug.command).Msg("plugin removed, deactivating")
+			plug.stop()
+			delete(p.active, id)
+		}
+	}
+	p.Unlock()
+
+	return p.Scan(b)
+}
+
+// Load scans the plugin directory (or plugin list) without the initial run
+// of the plugins, used for one-off runs (e.g. profile-run)
+func (p *Plugins) Load(b *builtins.Builtins) error {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.scan(b)
+}
+
+// scan finds the active plugins in the plugin directory or plugin list
+func (p *Plugins) scan(b *builtins.Builtins) error {
 	pluginList := viper.GetStringSlice(config.KeyPluginList)
 
 	if p.pluginDir != "" {
@@ -61,12 +102,6 @@ func (p *Plugins) Scan(b *builtins.Builtins) error {
 		}
 	}
 
-	initialRun()
-
-	if len(p.active) == 0 {
-		p.logger.Warn().Msg("no active plugins found")
-	}
-
 	return nil
 }
 
@@ -151,14 +186,19 @@ func (p *Plugins) verifyPluginList(l []string) error {
 
 		plug, ok := p.active[fileBase]
 		if !ok {
+			ctx, cancel := context.WithCancel(p.ctx)
 			p.active[fileBase] = &plugin{
-				ctx:      p.ctx,
-				id:       fileBase,
-				name:     fileBase,
-				logger:   p.logger.With().Str("id", fileBase).Logger(),
-				runDir:   fileDir,
-				runTTL:   runTTL,
-				baseTags: tags.GetBaseTags(),
+				ctx:       ctx,
+				cancel:    cancel,
+				id:        fileBase,
+				name:      fileBase,
+				limits:    p.limits,
+				logger:    p.logger.With().Str("id", fileBase).Logger(),
+				maxOutput: p.maxOutput,
+				runDir:    fileDir,
+				runTTL:    runTTL,
+				timeout:   p.timeout,
+				baseTags:  tags.GetBaseTags(),
 			}
 			plug = p.active[fileBase]
 		}
@@ -333,14 +373,19 @@ func (p *Plugins) scanPluginDirectory(b *builtins.Builtins) error {
 		if cfg == nil {
 			plug, ok := p.active[fileBase]
 			if !ok {
+				ctx, cancel := context.WithCancel(p.ctx)
 				p.active[fileBase] = &plugin{
-					ctx:      p.ctx,
-					id:       fileBase,
-					name:     fileBase,
-					logger:   p.logger.With().Str("id", fileBase).Logger(),
-					runDir:   p.pluginDir,
-					runTTL:   runTTL,
-					baseTags: tags.GetBaseTags(),
+					ctx:       ctx,
+					cancel:    cancel,
+					id:        fileBase,
+					name:      fileBase,
+					limits:    p.limits,
+					logger:    p.logger.With().Str("id", fileBase).Logger(),
+					maxOutput: p.maxOutput,
+					runDir:    p.pluginDir,
+					runTTL:    runTTL,
+					timeout:   p.timeout,
+					baseTags:  tags.GetBaseTags(),
 				}
 				plug = p.active[fileBase]
 			}
@@ -355,15 +400,20 @@ func (p *Plugins) scanPluginDirectory(b *builtins.Builtins) error {
 				pluginName := fileBase + defaults.MetricNameSeparator + inst
 				plug, ok := p.active[pluginName]
 				if !ok {
+					ctx, cancel := context.WithCancel(p.ctx)
 					p.active[pluginName] = &plugin{
-						ctx:          p.ctx,
+						ctx:          ctx,
+						cancel:       cancel,
 						id:           fileBase,
 						instanceID:   inst,
 						instanceArgs: args,
 						name:         pluginName,
+						limits:       p.limits,
 						logger:       p.logger.With().Str("id", pluginName).Logger(),
+						maxOutput:    p.maxOutput,
 						runDir:       p.pluginDir,
 						runTTL:       runTTL,
+						timeout:      p.timeout,
 						baseTags:     tags.GetBaseTags(),
 					}
 					plug = p.active[pluginName]
@@ -0,0 +1,66 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package plugins
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// run status metrics, emitted with the plugin's metrics so that plugin
+// failures can be alerted on
+const (
+	statusExitCode = "plugin_exit_code" // exit code of the last run, -1 when terminated by a signal or not started
+	statusDuration = "plugin_duration"  // seconds the last run took
+	statusSignal   = "plugin_signal"    // signal which terminated the last run
+	statusTimeout  = "plugin_timeout"   // 1 when the last run was terminated by the plugin timeout
+	statusStderr   = "plugin_stderr"    // stderr of the last (failed) run
+
+	// maxStatusStderr limits the stderr emitted as a text metric
+	maxStatusStderr = 1024
+)
+
+// runStatus returns the run status metrics of a run, tagged with the plugin's
+// base tags. The exit code and duration are emitted for every run, the signal,
+// timeout and stderr only when the run failed. Caller must hold the plugin lock.
+func (p *plugin) runStatus(state *os.ProcessState, duration time.Duration, timedOut bool, stderr string) cgm.Metrics {
+	baseTags := tags.FromList(p.baseTagList())
+	status := cgm.Metrics{
+		tags.MetricNameWithStreamTags(statusDuration, baseTags): cgm.Metric{Type: "n", Value: duration.Seconds()},
+	}
+
+	exitCode := -1
+	if state != nil {
+		exitCode = state.ExitCode()
+	}
+	status[tags.MetricNameWithStreamTags(statusExitCode, baseTags)] = cgm.Metric{Type: "i", Value: exitCode}
+
+	if state != nil && state.Success() && !timedOut {
+		return status
+	}
+
+	if state != nil {
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			status[tags.MetricNameWithStreamTags(statusSignal, baseTags)] = cgm.Metric{Type: "s", Value: ws.Signal().String()}
+		}
+	}
+	if timedOut {
+		status[tags.MetricNameWithStreamTags(statusTimeout, baseTags)] = cgm.Metric{Type: "L", Value: uint64(1)}
+	}
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		if len(stderr) > maxStatusStderr {
+			stderr = stderr[:maxStatusStderr]
+		}
+		status[tags.MetricNameWithStreamTags(statusStderr, baseTags)] = cgm.Metric{Type: "s", Value: stderr}
+	}
+
+	return status
+}
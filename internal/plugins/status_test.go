@@ -0,0 +1,137 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package plugins
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+func TestRunStatus(t *testing.T) {
+	t.Log("Testing runStatus")
+
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unable to get cwd (%s)", err)
+	}
+	testDir := path.Join(dir, "testdata")
+
+	baseTags := cgm.Tags{
+		cgm.Tag{Category: "collector", Value: "test"},
+		cgm.Tag{Category: "source", Value: "circonus-agent"},
+	}
+	statusMetric := func(m *cgm.Metrics, name string) (cgm.Metric, bool) {
+		mv, ok := (*m)[tags.MetricNameWithStreamTags(name, baseTags)]
+		return mv, ok
+	}
+
+	p := &plugin{
+		ctx:  context.Background(),
+		id:   "test",
+		name: "test",
+	}
+
+	t.Log("\tsuccess")
+	{
+		p.command = path.Join(testDir, "test.sh")
+		if err := p.exec(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		m := p.drain(0)
+		if mv, ok := statusMetric(m, statusExitCode); !ok || mv.Value != 0 {
+			t.Fatalf("expected exit code 0, got %#v", mv)
+		}
+		if _, ok := statusMetric(m, statusDuration); !ok {
+			t.Fatalf("expected duration, got %v", m)
+		}
+		if _, ok := statusMetric(m, statusStderr); ok {
+			t.Fatalf("expected no stderr, got %v", m)
+		}
+		if _, ok := statusMetric(m, "metric"); !ok {
+			t.Fatalf("expected plugin metrics, got %v", m)
+		}
+	}
+
+	t.Log("\texit non-zero")
+	{
+		p.command = path.Join(testDir, "error.sh")
+		if err := p.exec(); err == nil {
+			t.Fatal("expected error")
+		}
+		m := p.drain(0)
+		if mv, ok := statusMetric(m, statusExitCode); !ok || mv.Value != 1 {
+			t.Fatalf("expected exit code 1, got %#v", mv)
+		}
+		if mv, ok := statusMetric(m, statusStderr); !ok || mv.Type != "s" || mv.Value != "foo bar" {
+			t.Fatalf("expected stderr 'foo bar', got %#v", mv)
+		}
+		if _, ok := statusMetric(m, statusSignal); ok {
+			t.Fatalf("expected no signal, got %v", m)
+		}
+	}
+
+	t.Log("\tnot found")
+	{
+		p.command = path.Join(testDir, "invalid")
+		if err := p.exec(); err == nil {
+			t.Fatal("expected error")
+		}
+		m := p.drain(0)
+		if mv, ok := statusMetric(m, statusExitCode); !ok || mv.Value != -1 {
+			t.Fatalf("expected exit code -1, got %#v", mv)
+		}
+	}
+
+	t.Log("\ttimeout")
+	{
+		tmpDir, err := ioutil.TempDir("", "plugin-timeout")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		cmd := filepath.Join(tmpDir, "sleep.sh")
+		if err := ioutil.WriteFile(cmd, []byte("#!/bin/sh\nexec sleep 10\n"), 0700); err != nil { //nolint:gosec
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		p.command = cmd
+		p.timeout = 100 * time.Millisecond
+		start := time.Now()
+		if err := p.exec(); err == nil {
+			t.Fatal("expected error")
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("expected plugin to be terminated")
+		}
+		m := p.drain(0)
+		if mv, ok := statusMetric(m, statusTimeout); !ok || mv.Value != uint64(1) {
+			t.Fatalf("expected timeout 1, got %#v", mv)
+		}
+		if mv, ok := statusMetric(m, statusSignal); !ok || mv.Value != "killed" {
+			t.Fatalf("expected signal killed, got %#v", mv)
+		}
+		if mv, ok := statusMetric(m, statusExitCode); !ok || mv.Value != -1 {
+			t.Fatalf("expected exit code -1, got %#v", mv)
+		}
+		p.timeout = 0
+	}
+}
@@ -0,0 +1,5 @@
+#!/usr/bin/env bash
+
+for i in $(seq 1 10000); do
+    printf "metric%d\tn\t%d\n" $i $i
+done
@@ -0,0 +1,96 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package promtext converts prometheus text format metrics into circonus
+// samples, used by the prometheus collector and the exporter proxy.
+package promtext
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// CleanRx strips unwanted characters from metric names and labels
+var CleanRx = regexp.MustCompile("[\r\n\"']")
+
+// Parse converts the metrics in data (formats supported from
+// https://prometheus.io/docs/instrumenting/exposition_formats/), calling fn
+// for each sample of the families wanted (nil for all). Summaries and
+// histograms are NAME_count, NAME_sum and a sample per quantile or bucket
+// (NAME_UPPERBOUND, cumulative count). Samples with an explicit timestamp
+// keep it (see sample.Stamp), infinite and NaN values are skipped.
+func Parse(data io.Reader, wanted func(family string) bool, fn func(name string, m *dto.Metric, val interface{})) error {
+	var parser expfmt.TextParser
+
+	metricFamilies, err := parser.TextToMetricFamilies(data)
+	if err != nil {
+		return err
+	}
+
+	for mn, mf := range metricFamilies {
+		if wanted != nil && !wanted(mn) {
+			continue
+		}
+		for _, m := range mf.Metric {
+			var ts uint64
+			if m.GetTimestampMs() > 0 {
+				ts = uint64(m.GetTimestampMs())
+			}
+			switch mf.GetType() {
+			case dto.MetricType_SUMMARY:
+				fn(mn+"_count", m, sample.Stamp(float64(m.GetSummary().GetSampleCount()), ts))
+				fn(mn+"_sum", m, sample.Stamp(m.GetSummary().GetSampleSum(), ts))
+				for _, q := range m.GetSummary().Quantile {
+					if q.Value != nil && !math.IsNaN(*q.Value) {
+						fn(mn+"_"+fmt.Sprint(q.GetQuantile()), m, sample.Stamp(*q.Value, ts))
+					}
+				}
+			case dto.MetricType_HISTOGRAM:
+				fn(mn+"_count", m, sample.Stamp(float64(m.GetHistogram().GetSampleCount()), ts))
+				fn(mn+"_sum", m, sample.Stamp(m.GetHistogram().GetSampleSum(), ts))
+				for _, b := range m.GetHistogram().Bucket {
+					if b.CumulativeCount != nil {
+						fn(mn+"_"+fmt.Sprint(b.GetUpperBound()), m, sample.Stamp(*b.CumulativeCount, ts))
+					}
+				}
+			default:
+				var v *float64
+				switch {
+				case m.Gauge != nil:
+					v = m.GetGauge().Value
+				case m.Counter != nil:
+					v = m.GetCounter().Value
+				case m.Untyped != nil:
+					v = m.GetUntyped().Value
+				}
+				if v == nil || math.IsInf(*v, 0) || math.IsNaN(*v) {
+					continue
+				}
+				fn(mn, m, sample.Stamp(*v, ts))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Labels returns the labels of a sample as stream tags (name:value), with
+// the characters matching rx removed
+func Labels(m *dto.Metric, rx *regexp.Regexp) []string {
+	labels := make([]string, 0, len(m.Label))
+	for _, label := range m.Label {
+		if label.Name != nil && label.Value != nil {
+			labels = append(labels, rx.ReplaceAllString(*label.Name, "")+tags.Delimiter+rx.ReplaceAllString(*label.Value, ""))
+		}
+	}
+	return labels
+}
@@ -0,0 +1,103 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package promtext
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const testData = `# TYPE requests counter
+requests{code="200",path="/a\"b"} 10
+# TYPE temp gauge
+temp 21.5 1500000000000
+# TYPE weird untyped
+weird +Inf
+# TYPE latency summary
+latency{quantile="0.5"} 0.2
+latency{quantile="0.9"} NaN
+latency_sum 3
+latency_count 12
+# TYPE size histogram
+size_bucket{le="10"} 2
+size_bucket{le="+Inf"} 5
+size_sum 40
+size_count 5
+`
+
+func TestParse(t *testing.T) {
+	t.Log("Testing Parse")
+
+	t.Log("\tall families")
+	{
+		got := make(map[string]interface{})
+		err := Parse(strings.NewReader(testData), nil, func(name string, m *dto.Metric, val interface{}) {
+			got[name] = val
+		})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		names := make([]string, 0, len(got))
+		for name := range got {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		expect := "[latency_0.5 latency_count latency_sum requests size_+Inf size_10 size_count size_sum temp]"
+		if fmt.Sprint(names) != expect {
+			t.Fatalf("expected %s, got %v", expect, names)
+		}
+
+		v, ts := sample.Unwrap(got["temp"])
+		if v != 21.5 || ts != 1500000000000 {
+			t.Fatalf("expected 21.5 at 1500000000000, got %v at %d", v, ts)
+		}
+		if v, _ := sample.Unwrap(got["size_10"]); v != uint64(2) {
+			t.Fatalf("expected bucket count 2, got %v (%T)", v, v)
+		}
+	}
+
+	t.Log("\twanted")
+	{
+		var got []string
+		err := Parse(strings.NewReader(testData), func(family string) bool { return family == "requests" }, func(name string, m *dto.Metric, val interface{}) {
+			got = append(got, name)
+		})
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(got) != 1 || got[0] != "requests" {
+			t.Fatalf("expected [requests], got %v", got)
+		}
+	}
+
+	t.Log("\tinvalid")
+	{
+		if err := Parse(strings.NewReader("requests{ 1\n"), nil, func(string, *dto.Metric, interface{}) {}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	t.Log("Testing Labels")
+
+	err := Parse(strings.NewReader(testData), func(family string) bool { return family == "requests" }, func(name string, m *dto.Metric, val interface{}) {
+		labels := Labels(m, CleanRx)
+		expect := "[code:200 path:/ab]"
+		if fmt.Sprint(labels) != expect {
+			t.Fatalf("expected %s, got %v", expect, labels)
+		}
+	})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package connection
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AdminHandler executes administrative commands received from the broker
+type AdminHandler interface {
+	RescanPlugins() error
+	SetLogLevel(level string) error
+	RunCollector(id string) error
+}
+
+// Admin verifies and executes signed administrative commands received over
+// the reverse connection, so agents behind NAT can be managed without
+// exposing the local API. Commands are signed (ed25519) by the control plane,
+// are only valid for the agent's check (target) and for a limited time, a
+// nonce is only accepted once.
+//
+// The broker sends an ADMIN command frame followed by a request frame with:
+//
+//	{"payload": "<base64 command>", "signature": "<base64 ed25519 signature of payload>"}
+//
+// where the command is:
+//
+//	{"command": "set_log_level", "args": {"level": "debug"}, "target": "<check uuid>", "ts": 1590000000, "nonce": "..."}
+//
+// The result, {"ok": true} or {"ok": false, "error": "..."}, is sent on the
+// command's channel.
+type Admin struct {
+	handler   AdminHandler
+	key       ed25519.PublicKey
+	checkUUID string
+	nonces    map[string]time.Time
+	sync.Mutex
+}
+
+// AdminCommand is a verified administrative command
+type AdminCommand struct {
+	Command   string            `json:"command"`
+	Args      map[string]string `json:"args"`
+	Target    string            `json:"target"`
+	Timestamp int64             `json:"ts"`
+	Nonce     string            `json:"nonce"`
+}
+
+// adminEnvelope is the signed administrative command request
+type adminEnvelope struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// adminResult is the response to an administrative command
+type adminResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	AdminRefreshCheck  = "refresh_check"  // refresh check configuration, re-establishes the reverse connection
+	AdminRescanPlugins = "rescan_plugins" // rescan the plugin directory
+	AdminSetLogLevel   = "set_log_level"  // args: level
+	AdminRunCollector  = "run_collector"  // args: id, run a builtin collector now
+
+	// adminMaxSkew commands signed more than this long ago (or in the future) are rejected
+	adminMaxSkew = 5 * time.Minute
+)
+
+// NewAdmin returns an administrative command processor, commands are verified
+// with key and must target checkUUID
+func NewAdmin(handler AdminHandler, key ed25519.PublicKey, checkUUID string) (*Admin, error) {
+	if handler == nil {
+		return nil, errors.New("invalid admin handler (nil)")
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid admin key")
+	}
+	if checkUUID == "" {
+		return nil, errors.New("invalid check uuid (empty)")
+	}
+	return &Admin{
+		handler:   handler,
+		key:       key,
+		checkUUID: checkUUID,
+		nonces:    make(map[string]time.Time),
+	}, nil
+}
+
+// verify the signature, target, age and nonce of a command request
+func (a *Admin) verify(request []byte, now time.Time) (*AdminCommand, error) {
+	var env adminEnvelope
+	if err := json.Unmarshal(request, &env); err != nil {
+		return nil, errors.Wrap(err, "parsing admin request")
+	}
+	if len(env.Payload) == 0 || len(env.Signature) == 0 {
+		return nil, errors.New("admin request missing payload or signature")
+	}
+	if !ed25519.Verify(a.key, env.Payload, env.Signature) {
+		return nil, errors.New("invalid admin request signature")
+	}
+
+	var cmd AdminCommand
+	if err := json.Unmarshal(env.Payload, &cmd); err != nil {
+		return nil, errors.Wrap(err, "parsing admin command")
+	}
+	if cmd.Target != a.checkUUID {
+		return nil, errors.Errorf("admin command target (%s) is not this check", cmd.Target)
+	}
+	ts := time.Unix(cmd.Timestamp, 0)
+	if ts.Before(now.Add(-adminMaxSkew)) || ts.After(now.Add(adminMaxSkew)) {
+		return nil, errors.Errorf("admin command expired or not yet valid (%s)", ts.UTC().Format(time.RFC3339))
+	}
+	if cmd.Nonce == "" {
+		return nil, errors.New("admin command missing nonce")
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	for nonce, seen := range a.nonces {
+		if now.Sub(seen) > 2*adminMaxSkew {
+			delete(a.nonces, nonce)
+		}
+	}
+	if _, seen := a.nonces[cmd.Nonce]; seen {
+		return nil, errors.Errorf("admin command replayed (nonce %s)", cmd.Nonce)
+	}
+	a.nonces[cmd.Nonce] = now
+
+	return &cmd, nil
+}
+
+// execute a verified command, refresh_check is handled by the connection
+func (a *Admin) execute(cmd *AdminCommand) error {
+	switch cmd.Command {
+	case AdminRefreshCheck:
+		return nil
+	case AdminRescanPlugins:
+		return a.handler.RescanPlugins()
+	case AdminSetLogLevel:
+		if cmd.Args["level"] == "" {
+			return errors.New("missing level")
+		}
+		return a.handler.SetLogLevel(cmd.Args["level"])
+	case AdminRunCollector:
+		if cmd.Args["id"] == "" {
+			return errors.New("missing id")
+		}
+		return a.handler.RunCollector(cmd.Args["id"])
+	default:
+		return errors.Errorf("unknown admin command (%s)", cmd.Command)
+	}
+}
+
+// processAdminCommand verifies and executes an administrative command, the
+// result is sent to the broker in place of metrics
+func (c *Connection) processAdminCommand(cmd command) command {
+	result := adminResult{OK: true}
+
+	if c.admin == nil {
+		result = adminResult{Error: "remote administration not enabled"}
+	} else if acmd, err := c.admin.verify(cmd.request, time.Now()); err != nil {
+		c.logger.Warn().Err(err).Uint16("channel_id", cmd.channelID).Msg("rejected admin command")
+		result = adminResult{Error: err.Error()}
+	} else {
+		c.logger.Info().Str("command", acmd.Command).Interface("args", acmd.Args).Str("nonce", acmd.Nonce).Msg("admin command")
+		if err := c.admin.execute(acmd); err != nil {
+			c.logger.Warn().Err(err).Str("command", acmd.Command).Msg("admin command")
+			result = adminResult{Error: err.Error()}
+		} else if acmd.Command == AdminRefreshCheck {
+			cmd.refreshCheck = true
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		cmd.err = errors.Wrap(err, "admin result")
+		return cmd
+	}
+	cmd.metrics = &data
+	return cmd
+}
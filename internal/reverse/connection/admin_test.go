@@ -0,0 +1,183 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package connection
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type testAdminHandler struct {
+	calls []string
+}
+
+func (h *testAdminHandler) RescanPlugins() error {
+	h.calls = append(h.calls, "rescan")
+	return nil
+}
+
+func (h *testAdminHandler) SetLogLevel(level string) error {
+	h.calls = append(h.calls, "level:"+level)
+	return nil
+}
+
+func (h *testAdminHandler) RunCollector(id string) error {
+	if id == "bad" {
+		return errors.New("unknown builtin collector (bad)")
+	}
+	h.calls = append(h.calls, "run:"+id)
+	return nil
+}
+
+func signAdminCommand(t *testing.T, key ed25519.PrivateKey, cmd AdminCommand) []byte {
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	req, err := json.Marshal(adminEnvelope{Payload: payload, Signature: ed25519.Sign(key, payload)})
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	return req
+}
+
+func TestAdminVerify(t *testing.T) {
+	t.Log("Testing Admin.verify")
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	a, err := NewAdmin(&testAdminHandler{}, pub, "abc-123")
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	now := time.Now()
+	valid := AdminCommand{Command: AdminRescanPlugins, Target: "abc-123", Timestamp: now.Unix(), Nonce: "n1"}
+
+	t.Log("\tvalid")
+	{
+		cmd, err := a.verify(signAdminCommand(t, priv, valid), now)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if cmd.Command != AdminRescanPlugins {
+			t.Fatalf("unexpected command (%s)", cmd.Command)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		request []byte
+		errText string
+	}{
+		{"replayed", signAdminCommand(t, priv, valid), "replayed"},
+		{"invalid json", []byte("{"), "parsing admin request"},
+		{"no signature", []byte(`{"payload":"e30="}`), "missing payload or signature"},
+		{"wrong key", signAdminCommand(t, otherPriv, AdminCommand{Command: AdminRescanPlugins, Target: "abc-123", Timestamp: now.Unix(), Nonce: "n2"}), "signature"},
+		{"wrong target", signAdminCommand(t, priv, AdminCommand{Command: AdminRescanPlugins, Target: "def-456", Timestamp: now.Unix(), Nonce: "n3"}), "not this check"},
+		{"expired", signAdminCommand(t, priv, AdminCommand{Command: AdminRescanPlugins, Target: "abc-123", Timestamp: now.Add(-time.Hour).Unix(), Nonce: "n4"}), "expired"},
+		{"no nonce", signAdminCommand(t, priv, AdminCommand{Command: AdminRescanPlugins, Target: "abc-123", Timestamp: now.Unix()}), "nonce"},
+	}
+
+	for _, tst := range tests {
+		t.Logf("\t%s", tst.name)
+		_, err := a.verify(tst.request, now)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(err.Error(), tst.errText) {
+			t.Fatalf("expected (%s) got (%s)", tst.errText, err)
+		}
+	}
+}
+
+func TestProcessAdminCommand(t *testing.T) {
+	t.Log("Testing processAdminCommand")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	h := &testAdminHandler{}
+	a, err := NewAdmin(h, pub, "abc-123")
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+
+	result := func(cmd command) adminResult {
+		if cmd.err != nil {
+			t.Fatalf("expected no error, got (%s)", cmd.err)
+		}
+		var r adminResult
+		if err := json.Unmarshal(*cmd.metrics, &r); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		return r
+	}
+	request := func(nonce, command string, args map[string]string) []byte {
+		return signAdminCommand(t, priv, AdminCommand{Command: command, Args: args, Target: "abc-123", Timestamp: time.Now().Unix(), Nonce: nonce})
+	}
+
+	t.Log("\tdisabled")
+	{
+		c := Connection{}
+		r := result(c.processAdminCommand(command{name: CommandAdmin, request: request("d1", AdminRescanPlugins, nil)}))
+		if r.OK || r.Error != "remote administration not enabled" {
+			t.Fatalf("unexpected result (%#v)", r)
+		}
+	}
+
+	c := Connection{admin: a}
+
+	tests := []struct {
+		name    string
+		request []byte
+		ok      bool
+		refresh bool
+	}{
+		{"rescan", request("1", AdminRescanPlugins, nil), true, false},
+		{"log level", request("2", AdminSetLogLevel, map[string]string{"level": "debug"}), true, false},
+		{"log level, missing", request("3", AdminSetLogLevel, nil), false, false},
+		{"run collector", request("4", AdminRunCollector, map[string]string{"id": "cpu"}), true, false},
+		{"run collector, unknown", request("5", AdminRunCollector, map[string]string{"id": "bad"}), false, false},
+		{"refresh check", request("6", AdminRefreshCheck, nil), true, true},
+		{"unknown", request("7", "shutdown", nil), false, false},
+		{"replayed", request("1", AdminRescanPlugins, nil), false, false},
+	}
+
+	for _, tst := range tests {
+		t.Logf("\t%s", tst.name)
+		cmd := c.processAdminCommand(command{name: CommandAdmin, request: tst.request})
+		r := result(cmd)
+		if r.OK != tst.ok {
+			t.Fatalf("expected ok=%v, got (%#v)", tst.ok, r)
+		}
+		if cmd.refreshCheck != tst.refresh {
+			t.Fatalf("expected refresh=%v", tst.refresh)
+		}
+	}
+
+	expect := "rescan,level:debug,run:cpu"
+	if calls := strings.Join(h.calls, ","); calls != expect {
+		t.Fatalf("expected (%s) got (%s)", expect, calls)
+	}
+}
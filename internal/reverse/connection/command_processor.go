@@ -40,6 +40,10 @@ func (c *Connection) processCommand(cmd command) command {
 		return cmd
 	}
 
+	if cmd.name == CommandAdmin {
+		return c.processAdminCommand(cmd)
+	}
+
 	if cmd.name != CommandConnect {
 		cmd.ignore = true
 		cmd.err = errors.Errorf("unused/empty command (%s)", cmd.name)
@@ -71,8 +71,8 @@ func (c *Connection) readCommand(r io.Reader) command {
 		name:      string(cmdPkt.payload),
 	}
 
-	if cmd.name == CommandConnect {
-		// connect command requires a request
+	if cmd.name == CommandConnect || cmd.name == CommandAdmin {
+		// connect and admin commands require a request
 		cmd.start = time.Now()
 		reqPkt, err := c.readFrameFromBroker(r)
 		if err != nil {
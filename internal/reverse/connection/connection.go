@@ -9,6 +9,7 @@ import (
 	"context"
 	crand "crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"math"
 	"math/big"
@@ -19,6 +20,8 @@ import (
 
 	"github.com/circonus-labs/circonus-agent/internal/check"
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/errs"
+	"github.com/maier/go-appstats"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
@@ -26,6 +29,7 @@ import (
 
 type Connection struct {
 	logger          zerolog.Logger
+	admin           *Admin
 	State           string
 	LastRequestTime *time.Time
 	agentAddress    string
@@ -39,15 +43,16 @@ type Connection struct {
 
 // command contains details of the command received from the broker
 type command struct {
-	err       error
-	ignore    bool
-	fatal     bool
-	reset     bool
-	channelID uint16
-	name      string
-	request   []byte
-	metrics   *[]byte
-	start     time.Time
+	err          error
+	ignore       bool
+	fatal        bool
+	reset        bool
+	refreshCheck bool
+	channelID    uint16
+	name         string
+	request      []byte
+	metrics      *[]byte
+	start        time.Time
 }
 
 // noitHeader defines the header received from the noit/broker
@@ -63,12 +68,6 @@ type noitFrame struct {
 	payload []byte
 }
 
-// connError returned from connect(), adds flag indicating whether to retry
-type connError struct {
-	err   error
-	retry bool
-}
-
 type OpError struct {
 	Err          string
 	Fatal        bool
@@ -86,6 +85,15 @@ func (e *OpError) Error() string {
 	return e.Err
 }
 
+// Unwrap returns the original error
+func (e *OpError) Unwrap() error {
+	return e.OrigErr
+}
+
+// RouteHeader is the request header with the metric route of a check other
+// than the agent's check (see --check-routes)
+const RouteHeader = "X-Circonus-Route"
+
 const (
 	StateConnActive = "CONN_ACTIVE" // connected, broker requesting metrics
 	StateConnIdle   = "CONN_IDLE"   // connected, no requests
@@ -93,6 +101,7 @@ const (
 	StateError      = "ERROR"       // connection is erroring
 	CommandConnect  = "CONNECT"     // Connect command, must be followed by a request payload
 	CommandReset    = "RESET"       // Reset command, resets the connection
+	CommandAdmin    = "ADMIN"       // Admin command, must be followed by a signed request payload (see Admin)
 
 	// NOTE: TBD, make some of these user-configurable
 	CommTimeoutSeconds   = 10    // seconds, when communicating with noit
@@ -107,7 +116,8 @@ const (
 	ConfigRetryLimit     = 5     // if failed attempts > limit, force check reconfig (see if broker configuration changed)
 )
 
-func New(parentLogger zerolog.Logger, agentAddress string, cfg *check.ReverseConfig) (*Connection, error) {
+// New returns a reverse connection, admin is optional (nil disables administrative commands)
+func New(parentLogger zerolog.Logger, agentAddress string, cfg *check.ReverseConfig, admin *Admin) (*Connection, error) {
 	if agentAddress == "" {
 		return nil, errors.Errorf("invalid agent address (empty)")
 	}
@@ -122,6 +132,7 @@ func New(parentLogger zerolog.Logger, agentAddress string, cfg *check.ReverseCon
 	}
 
 	c := Connection{
+		admin:        admin,
 		agentAddress: agentAddress,
 		revConfig:    *cfg,
 		State:        StateNew,
@@ -139,16 +150,21 @@ func (c *Connection) Start(ctx context.Context) error {
 
 	for {
 
-		conn, cerr := c.connect()
-		if cerr != nil {
-			if cerr.retry {
-				c.logger.Warn().Err(cerr.err).Msg("retrying")
+		conn, err := c.connect()
+		if err != nil {
+			errs.Record(err)
+			c.Lock()
+			c.State = StateError
+			c.Unlock()
+			_ = appstats.SetString("reverse.state", StateError)
+			if errs.IsRetryable(err) {
+				c.logger.Warn().Err(err).Msg("retrying")
 				continue
 			}
-			c.logger.Error().Err(cerr.err).Msg("unable to establish reverse connection to broker")
+			c.logger.Error().Err(err).Msg("unable to establish reverse connection to broker")
 			return &OpError{
 				RefreshCheck: true,
-				OrigErr:      cerr.err,
+				OrigErr:      err,
 			}
 
 		}
@@ -185,9 +201,11 @@ func (c *Connection) Start(ctx context.Context) error {
 				case result.fatal:
 					c.logger.Error().Err(result.err).Interface("result", result).Msg("fatal error, exiting")
 					conn.Close()
+					berr := errs.NewBroker(result.err, false)
+					errs.Record(berr)
 					return &OpError{
 						Fatal:   true,
-						OrigErr: result.err,
+						OrigErr: berr,
 					}
 				default:
 					c.logger.Error().Err(result.err).Interface("result", result).Msg("unhandled error state...")
@@ -195,21 +213,37 @@ func (c *Connection) Start(ctx context.Context) error {
 				}
 			}
 
-			// send metrics to broker
+			// send metrics (or admin command result) to broker
 			if err := c.sendMetricData(conn, result.channelID, result.metrics, result.start); err != nil {
 				c.logger.Warn().Err(err).Msg("sending metric data, resetting connection")
 				conn.Close()
+				berr := errs.NewBroker(err, true)
+				errs.Record(berr)
 				return &OpError{
 					RefreshCheck: true,
-					OrigErr:      err,
+					OrigErr:      berr,
 				}
 			}
 
+			if result.name == CommandAdmin {
+				if result.refreshCheck {
+					c.logger.Info().Msg("admin command, refreshing check")
+					conn.Close()
+					return &OpError{
+						RefreshCheck: true,
+						OrigErr:      errors.New("admin command, refresh check"),
+					}
+				}
+				continue
+			}
+
 			c.Lock()
 			c.State = StateConnActive
 			reqTime := result.start
 			c.LastRequestTime = &reqTime
 			c.Unlock()
+			_ = appstats.SetString("reverse.state", StateConnActive)
+			_ = appstats.SetString("reverse.last_request", reqTime.String())
 
 			c.logger.Debug().Uint16("channel_id", result.channelID).Str("duration", time.Since(result.start).String()).Msg("CONNECT command request processed")
 
@@ -227,12 +261,12 @@ func (c *Connection) Start(ctx context.Context) error {
 
 // connect to broker w/tls and send initial introduction
 // NOTE: all reverse connections require tls
-func (c *Connection) connect() (*tls.Conn, *connError) {
+func (c *Connection) connect() (*tls.Conn, error) {
 	c.Lock()
 	if c.connAttempts > 0 {
 		if c.maxConnRetry != -1 && c.connAttempts >= c.maxConnRetry {
 			c.Unlock()
-			return nil, &connError{retry: false, err: errors.Errorf("max broker connection attempts reached (%d of %d)", c.connAttempts, c.maxConnRetry)}
+			return nil, errs.NewBroker(errors.Errorf("max broker connection attempts reached (%d of %d)", c.connAttempts, c.maxConnRetry), false)
 		}
 
 		c.logger.Info().
@@ -258,7 +292,7 @@ func (c *Connection) connect() (*tls.Conn, *connError) {
 		if c.connAttempts%ConfigRetryLimit == 0 {
 			// Check configuration refresh -- TBD on if check refresh really needed or just find owner again for clustered
 			c.Unlock()
-			return nil, &connError{retry: false, err: errors.Errorf("max connection attempts (%d), check refresh", c.connAttempts)}
+			return nil, errs.NewBroker(errors.Errorf("max connection attempts (%d), check refresh", c.connAttempts), false)
 		}
 	}
 	c.Unlock()
@@ -271,14 +305,19 @@ func (c *Connection) connect() (*tls.Conn, *connError) {
 	dialer := &net.Dialer{Timeout: DialerTimeoutSeconds * time.Second}
 	conn, err := tls.DialWithDialer(dialer, "tcp", c.revConfig.BrokerAddr.String(), c.revConfig.TLSConfig)
 	if err != nil {
+		var uaerr x509.UnknownAuthorityError
+		if errors.As(err, &uaerr) {
+			// broker CA may have been rotated, refresh check (and broker CA) before retrying
+			return nil, errs.NewBroker(errors.Wrapf(err, "verifying broker certificate for %s (broker CA rotated?)", revHost), false)
+		}
 		if ne, ok := err.(*net.OpError); ok {
 			if ne.Timeout() {
-				return nil, &connError{retry: ne.Temporary(), err: errors.Wrapf(err, "timeout connecting to %s", revHost)}
+				return nil, errs.NewBroker(errors.Wrapf(err, "timeout connecting to %s", revHost), ne.Temporary())
 			}
 		}
-		return nil, &connError{retry: true, err: errors.Wrapf(err, "connecting to %s", revHost)}
+		return nil, errs.NewBroker(errors.Wrapf(err, "connecting to %s", revHost), true)
 	}
-	c.logger.Info().Str("host", revHost).Msg("connected")
+	c.logger.Info().Str("host", revHost).Str("family", config.AddressFamily(c.revConfig.BrokerAddr.IP)).Msg("connected")
 
 	if err := conn.SetDeadline(time.Now().Add(CommTimeoutSeconds * time.Second)); err != nil {
 		c.logger.Warn().Err(err).Msg("setting connection deadline")
@@ -290,7 +329,7 @@ func (c *Connection) connect() (*tls.Conn, *connError) {
 	c.logger.Debug().Msg(fmt.Sprintf("sending intro '%s'", introReq))
 	if _, err := fmt.Fprintf(conn, "%s HTTP/1.1\r\n\r\n", introReq); err != nil {
 		c.logger.Error().Err(err).Msg("sending intro")
-		return nil, &connError{retry: true, err: errors.Wrapf(err, "unable to write intro to %s", revHost)}
+		return nil, errs.NewBroker(errors.Wrapf(err, "unable to write intro to %s", revHost), true)
 	}
 
 	c.Lock()
@@ -298,6 +337,9 @@ func (c *Connection) connect() (*tls.Conn, *connError) {
 	// reset timeouts after successful (re)connection
 	c.commTimeouts = 0
 	c.Unlock()
+	_ = appstats.SetString("reverse.state", StateConnIdle)
+	_ = appstats.SetString("reverse.broker", revHost)
+	_ = appstats.SetString("reverse.last_connect", time.Now().String())
 
 	return conn, nil
 }
@@ -333,8 +375,3 @@ func (c *Connection) resetConnectionAttempts() {
 	}
 	c.Unlock()
 }
-
-// Error returns string representation of a connError
-func (e *connError) Error() string {
-	return e.err.Error()
-}
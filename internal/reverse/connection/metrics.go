@@ -6,6 +6,7 @@
 package connection
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -83,14 +84,19 @@ func (c *Connection) fetchMetricData(request *[]byte, channelID uint16) (*[]byte
 		c.logger.Warn().Err(err).Msg("setting connection deadline")
 	}
 
-	numBytes, err := conn.Write(*request)
+	req := *request
+	if c.revConfig.Route != "" {
+		req = addRouteHeader(req, c.revConfig.Route)
+	}
+
+	numBytes, err := conn.Write(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "writing metric request")
 	}
-	if numBytes != len(*request) {
+	if numBytes != len(req) {
 		c.logger.Warn().
 			Int("written_bytes", numBytes).
-			Int("request_len", len(*request)).
+			Int("request_len", len(req)).
 			Msg("Mismatch")
 	}
 
@@ -103,3 +109,19 @@ func (c *Connection) fetchMetricData(request *[]byte, channelID uint16) (*[]byte
 
 	return &data, nil
 }
+
+// addRouteHeader adds the metric route header after the request line of the
+// broker's request, so the agent responds with the metrics routed to the check
+func addRouteHeader(request []byte, route string) []byte {
+	eol := bytes.Index(request, []byte("\r\n"))
+	if eol == -1 {
+		return request
+	}
+	eol += 2
+	hdr := RouteHeader + ": " + route + "\r\n"
+	req := make([]byte, 0, len(request)+len(hdr))
+	req = append(req, request[:eol]...)
+	req = append(req, hdr...)
+	req = append(req, request[eol:]...)
+	return req
+}
@@ -5,6 +5,10 @@
 
 package connection
 
+import (
+	"testing"
+)
+
 // import (
 // 	"bytes"
 // 	"context"
@@ -122,3 +126,25 @@ package connection
 // 	}
 // 	cancel()
 // }
+
+func TestAddRouteHeader(t *testing.T) {
+	t.Log("Testing addRouteHeader")
+
+	tests := []struct {
+		name    string
+		request string
+		expect  string
+	}{
+		{"request", "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n", "GET / HTTP/1.1\r\nX-Circonus-Route: 456\r\nHost: localhost\r\n\r\n"},
+		{"request line only", "GET / HTTP/1.1\r\n\r\n", "GET / HTTP/1.1\r\nX-Circonus-Route: 456\r\n\r\n"},
+		{"invalid request", "GET /", "GET /"},
+	}
+
+	for _, tt := range tests {
+		t.Logf("\t%s", tt.name)
+		got := string(addRouteHeader([]byte(tt.request), "456"))
+		if got != tt.expect {
+			t.Fatalf("expected %q, got %q", tt.expect, got)
+		}
+	}
+}
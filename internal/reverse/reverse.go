@@ -13,21 +13,32 @@ import (
 
 	"github.com/circonus-labs/circonus-agent/internal/check"
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/errs"
 	"github.com/circonus-labs/circonus-agent/internal/reverse/connection"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 )
 
+// refreshRetryDelay is the wait before retrying a check refresh which
+// failed with a retryable error (e.g. api temporarily unavailable)
+var refreshRetryDelay = time.Minute
+
 type Reverse struct {
-	agentAddress string
-	configs      *check.ReverseConfigs
-	chk          *check.Check
-	enabled      bool
-	logger       zerolog.Logger
+	agentAddress  string
+	admin         *connection.Admin
+	configs       *check.ReverseConfigs
+	chk           *check.Check
+	enabled       bool
+	logger        zerolog.Logger
+	caRefresh     time.Duration
+	nextCARefresh time.Time
+	checkRefresh  time.Duration
 }
 
-func New(parentLogger zerolog.Logger, chk *check.Check, agentAddress string) (*Reverse, error) {
+// New returns a reverse instance, admin executes the administrative commands
+// received over the reverse connection (enabled with a reverse admin key file)
+func New(parentLogger zerolog.Logger, chk *check.Check, agentAddress string, admin connection.AdminHandler) (*Reverse, error) {
 	if chk == nil {
 		return nil, errors.New("invalid check (nil")
 	}
@@ -51,6 +62,21 @@ func New(parentLogger zerolog.Logger, chk *check.Check, agentAddress string) (*R
 	}
 	r.configs = cfgs
 
+	if refresh := viper.GetString(config.KeyReverseBrokerCARefresh); refresh != "" {
+		d, err := time.ParseDuration(refresh)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing reverse broker ca refresh")
+		}
+		r.caRefresh = d
+		r.nextCARefresh = time.Now().Add(d)
+	}
+
+	checkRefresh, err := config.CheckRefreshInterval()
+	if err != nil {
+		return nil, errors.Wrap(err, "setting up reverse")
+	}
+	r.checkRefresh = checkRefresh
+
 	cm, err := chk.CheckMeta()
 	if err != nil {
 		return nil, errors.Wrap(err, "setting up reverse")
@@ -62,6 +88,18 @@ func New(parentLogger zerolog.Logger, chk *check.Check, agentAddress string) (*R
 		Str("check_uuid", cm.CheckUUID).
 		Logger()
 
+	if file := viper.GetString(config.KeyReverseAdminKeyFile); file != "" && admin != nil {
+		key, err := config.LoadReverseAdminKey(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "reverse admin key")
+		}
+		r.admin, err = connection.NewAdmin(admin, key, cm.CheckUUID)
+		if err != nil {
+			return nil, errors.Wrap(err, "reverse admin")
+		}
+		r.logger.Info().Str("key_file", file).Msg("remote administration enabled")
+	}
+
 	return r, nil
 }
 
@@ -89,14 +127,24 @@ func (r *Reverse) Start(ctx context.Context) error {
 		default:
 		}
 
-		if time.Since(lastRefresh) > 5*time.Minute {
+		if time.Since(lastRefresh) > r.checkRefresh {
 			refreshCheck = true
 		}
 
 		if refreshCheck {
 			r.logger.Debug().Msg("refreshing check")
 			if err := r.chk.RefreshReverseConfig(); err != nil {
-				r.logger.Error().Err(err).Msg("refreshing reverse configuration")
+				errs.Record(err)
+				if errs.IsRetryable(err) {
+					r.logger.Warn().Err(err).Str("category", string(errs.CategoryOf(err))).Str("retry_in", refreshRetryDelay.String()).Msg("refreshing reverse configuration")
+					select {
+					case <-rctx.Done():
+						return nil
+					case <-time.After(refreshRetryDelay):
+					}
+					continue
+				}
+				r.logger.Error().Err(err).Str("category", string(errs.CategoryOf(err))).Msg("refreshing reverse configuration")
 				cancel()
 				return err
 			}
@@ -107,6 +155,7 @@ func (r *Reverse) Start(ctx context.Context) error {
 			}
 			r.configs = cfgs
 			refreshCheck = false
+			lastRefresh = time.Now()
 		}
 
 		r.logger.Debug().Msg("find primary broker instance")
@@ -134,7 +183,7 @@ func (r *Reverse) Start(ctx context.Context) error {
 			Str("address", cfg.BrokerAddr.String()).
 			Str("url", cfg.ReverseURL.String()).
 			Msg("reverse broker config")
-		rc, err := connection.New(r.logger, r.agentAddress, &cfg)
+		rc, err := connection.New(r.logger, r.agentAddress, &cfg, r.admin)
 		if err != nil {
 			cancel()
 			return err
@@ -142,11 +191,19 @@ func (r *Reverse) Start(ctx context.Context) error {
 
 		var wg sync.WaitGroup
 
+		cctx, ccancel := context.WithCancel(rctx)
+		caRotated := make(chan struct{}, 1)
+		watchDone := make(chan struct{})
+		go func() {
+			r.watchBrokerCA(cctx, ccancel, caRotated)
+			close(watchDone)
+		}()
+
 		wg.Add(1)
 
 		go func() {
 			r.logger.Debug().Msg("starting reverse connection")
-			if err := rc.Start(rctx); err != nil {
+			if err := rc.Start(cctx); err != nil {
 				r.logger.Warn().Err(err).Msg("reverse connection")
 				if cerr, ok := err.(*connection.OpError); ok {
 					if cerr.Fatal {
@@ -161,5 +218,49 @@ func (r *Reverse) Start(ctx context.Context) error {
 		}()
 
 		wg.Wait()
+		ccancel()
+		<-watchDone
+
+		select {
+		case <-caRotated:
+			cfgs, err := r.chk.GetReverseConfigs()
+			if err != nil {
+				return errors.Wrap(err, "getting reverse configurations")
+			}
+			r.configs = cfgs
+		default:
+		}
+	}
+}
+
+// watchBrokerCA periodically refreshes the broker CA certificate while a reverse
+// connection is active. If the certificate changed, the connection is cancelled
+// so that it is re-established using the rebuilt reverse configuration.
+func (r *Reverse) watchBrokerCA(ctx context.Context, cancel context.CancelFunc, rotated chan<- struct{}) {
+	if r.caRefresh <= 0 {
+		return
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(r.nextCARefresh))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		r.nextCARefresh = time.Now().Add(r.caRefresh)
+		changed, err := r.chk.RefreshBrokerCA()
+		if err != nil {
+			r.logger.Warn().Err(err).Msg("refreshing broker CA certificate, will retry at next interval")
+			continue
+		}
+		if changed {
+			r.logger.Info().Msg("broker CA certificate rotated, re-establishing reverse connection")
+			rotated <- struct{}{}
+			cancel()
+			return
+		}
 	}
 }
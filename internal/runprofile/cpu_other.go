@@ -0,0 +1,15 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build !linux,!darwin,!freebsd
+
+package runprofile
+
+import "time"
+
+// cpuTimes is not supported on this platform, cpu time is reported as zero
+func cpuTimes() (time.Duration, time.Duration) {
+	return 0, 0
+}
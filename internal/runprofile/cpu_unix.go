@@ -0,0 +1,27 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// +build linux darwin freebsd
+
+package runprofile
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpuTimes returns the user+system cpu time of the agent and of its
+// (terminated and waited for) child processes
+func cpuTimes() (time.Duration, time.Duration) {
+	return rusage(syscall.RUSAGE_SELF), rusage(syscall.RUSAGE_CHILDREN)
+}
+
+func rusage(who int) time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(who, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
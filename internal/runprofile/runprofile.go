@@ -0,0 +1,189 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package runprofile performs one collection of each builtin collector and
+// plugin, one at a time, and reports the wall and cpu time, allocations and
+// output size of each so expensive collectors and plugins can be found.
+package runprofile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KindBuiltin a builtin collector
+	KindBuiltin = "builtin"
+	// KindPlugin a plugin
+	KindPlugin = "plugin"
+
+	// SortWall sort the report by wall time (default), all sorts are descending
+	SortWall = "wall"
+	// SortCPU sort the report by cpu time
+	SortCPU = "cpu"
+	// SortAlloc sort the report by bytes allocated
+	SortAlloc = "alloc"
+	// SortMetrics sort the report by number of metrics
+	SortMetrics = "metrics"
+	// SortBytes sort the report by output size
+	SortBytes = "bytes"
+)
+
+// Builtins runs builtin collectors one at a time
+type Builtins interface {
+	IDs() []string
+	CollectOne(ctx context.Context, id string) (cgm.Metrics, error)
+}
+
+// Plugins runs plugins one at a time
+type Plugins interface {
+	List() []string
+	Run(id string) error
+	Flush(id string) *cgm.Metrics
+}
+
+// Timing is the profile of one collector or plugin
+type Timing struct {
+	Kind        string        `json:"kind"`
+	ID          string        `json:"id"`
+	Wall        time.Duration `json:"wall"`
+	CPU         time.Duration `json:"cpu"` // user+system, plugins: of the plugin process
+	AllocBytes  uint64        `json:"alloc_bytes"`
+	Allocs      uint64        `json:"allocs"`
+	Metrics     int           `json:"metrics"`
+	OutputBytes int           `json:"output_bytes"` // encoded (json) size of the metrics
+	Error       string        `json:"error,omitempty"`
+}
+
+// Run profiles each builtin collector and then each plugin, either may be nil
+func Run(ctx context.Context, b Builtins, p Plugins) []Timing {
+	var timings []Timing
+
+	if b != nil {
+		for _, id := range b.IDs() {
+			if ctx.Err() != nil {
+				return timings
+			}
+			timings = append(timings, measure(KindBuiltin, id, func() (cgm.Metrics, error) {
+				return b.CollectOne(ctx, id)
+			}))
+		}
+	}
+
+	if p != nil {
+		for _, id := range p.List() {
+			if ctx.Err() != nil {
+				return timings
+			}
+			timings = append(timings, measure(KindPlugin, id, func() (cgm.Metrics, error) {
+				if err := p.Run(id); err != nil {
+					return nil, err
+				}
+				m := p.Flush(id)
+				if m == nil {
+					return cgm.Metrics{}, nil
+				}
+				return *m, nil
+			}))
+		}
+	}
+
+	return timings
+}
+
+// measure runs fn, collecting its timings
+func measure(kind, id string, fn func() (cgm.Metrics, error)) Timing {
+	t := Timing{Kind: kind, ID: id}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	selfStart, childStart := cpuTimes()
+	start := time.Now()
+
+	metrics, err := fn()
+
+	t.Wall = time.Since(start)
+	selfEnd, childEnd := cpuTimes()
+	runtime.ReadMemStats(&after)
+
+	if kind == KindPlugin {
+		t.CPU = childEnd - childStart
+	} else {
+		t.CPU = selfEnd - selfStart
+	}
+	t.AllocBytes = after.TotalAlloc - before.TotalAlloc
+	t.Allocs = after.Mallocs - before.Mallocs
+
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+
+	t.Metrics = len(metrics)
+	t.OutputBytes = encodedSize(metrics)
+
+	return t
+}
+
+// encodedSize returns the approximate json encoded size of the metrics,
+// metrics which cannot be encoded (e.g. NaN values) are not counted
+func encodedSize(metrics cgm.Metrics) int {
+	size := 2 // {}
+	for name, m := range metrics {
+		data, err := json.Marshal(m)
+		if err != nil {
+			continue
+		}
+		size += len(name) + 4 + len(data) // "name":value,
+	}
+	return size
+}
+
+// Sort orders the timings by key, descending
+func Sort(timings []Timing, key string) error {
+	var val func(t *Timing) int64
+	switch key {
+	case "", SortWall:
+		val = func(t *Timing) int64 { return int64(t.Wall) }
+	case SortCPU:
+		val = func(t *Timing) int64 { return int64(t.CPU) }
+	case SortAlloc:
+		val = func(t *Timing) int64 { return int64(t.AllocBytes) }
+	case SortMetrics:
+		val = func(t *Timing) int64 { return int64(t.Metrics) }
+	case SortBytes:
+		val = func(t *Timing) int64 { return int64(t.OutputBytes) }
+	default:
+		return errors.Errorf("invalid sort key (%s)", key)
+	}
+
+	sort.SliceStable(timings, func(i, j int) bool {
+		return val(&timings[i]) > val(&timings[j])
+	})
+
+	return nil
+}
+
+// Report writes the timings as a table
+func Report(w io.Writer, timings []Timing) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tID\tWALL\tCPU\tALLOC\tALLOCS\tMETRICS\tBYTES\tERROR")
+	for _, t := range timings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+			t.Kind, t.ID,
+			t.Wall.Round(time.Microsecond), t.CPU.Round(time.Microsecond),
+			t.AllocBytes, t.Allocs, t.Metrics, t.OutputBytes, t.Error)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,124 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package runprofile
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+type fakeBuiltins struct{}
+
+func (fakeBuiltins) IDs() []string { return []string{"cpu", "disk", "bad"} }
+
+func (fakeBuiltins) CollectOne(ctx context.Context, id string) (cgm.Metrics, error) {
+	switch id {
+	case "cpu":
+		return cgm.Metrics{"usage": cgm.Metric{Type: "n", Value: 1.5}}, nil
+	case "disk":
+		time.Sleep(10 * time.Millisecond)
+		return cgm.Metrics{
+			"reads":  cgm.Metric{Type: "L", Value: uint64(1)},
+			"writes": cgm.Metric{Type: "L", Value: uint64(2)},
+			"util":   cgm.Metric{Type: "n", Value: math.NaN()},
+		}, nil
+	default:
+		return nil, errors.New("collect failed")
+	}
+}
+
+type fakePlugins struct{ ran []string }
+
+func (p *fakePlugins) List() []string { return []string{"mysql"} }
+
+func (p *fakePlugins) Run(id string) error {
+	p.ran = append(p.ran, id)
+	return nil
+}
+
+func (p *fakePlugins) Flush(id string) *cgm.Metrics {
+	return &cgm.Metrics{"mysql`queries": cgm.Metric{Type: "L", Value: uint64(10)}}
+}
+
+func TestRun(t *testing.T) {
+	t.Log("Testing Run")
+
+	p := &fakePlugins{}
+	timings := Run(context.Background(), fakeBuiltins{}, p)
+	if len(timings) != 4 {
+		t.Fatalf("expected 4 timings, got %d", len(timings))
+	}
+	if len(p.ran) != 1 || p.ran[0] != "mysql" {
+		t.Fatalf("expected mysql run, got %v", p.ran)
+	}
+
+	byID := make(map[string]Timing)
+	for _, tm := range timings {
+		byID[tm.ID] = tm
+	}
+	if tm := byID["disk"]; tm.Kind != KindBuiltin || tm.Metrics != 3 || tm.Wall < 10*time.Millisecond || tm.OutputBytes == 0 {
+		t.Fatalf("unexpected disk timing %#v", tm)
+	}
+	if tm := byID["bad"]; tm.Error != "collect failed" {
+		t.Fatalf("expected error, got %#v", tm)
+	}
+	if tm := byID["mysql"]; tm.Kind != KindPlugin || tm.Metrics != 1 {
+		t.Fatalf("unexpected mysql timing %#v", tm)
+	}
+
+	t.Log("\tnil sources")
+	{
+		if timings := Run(context.Background(), nil, nil); len(timings) != 0 {
+			t.Fatalf("expected no timings, got %v", timings)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	t.Log("Testing Sort")
+
+	timings := []Timing{
+		{ID: "a", Wall: time.Second, Metrics: 5},
+		{ID: "b", Wall: 3 * time.Second, Metrics: 1},
+		{ID: "c", Wall: 2 * time.Second, Metrics: 9},
+	}
+
+	if err := Sort(timings, SortWall); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if timings[0].ID != "b" || timings[2].ID != "a" {
+		t.Fatalf("expected b,c,a got %v", timings)
+	}
+
+	if err := Sort(timings, SortMetrics); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if timings[0].ID != "c" || timings[2].ID != "b" {
+		t.Fatalf("expected c,a,b got %v", timings)
+	}
+
+	if err := Sort(timings, "name"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Log("Testing Report")
+
+	var buf bytes.Buffer
+	Report(&buf, []Timing{{Kind: KindPlugin, ID: "mysql", Wall: time.Millisecond, Metrics: 1, Error: "timeout"}})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "KIND") || !strings.Contains(lines[1], "mysql") || !strings.Contains(lines[1], "timeout") {
+		t.Fatalf("unexpected report (%s)", buf.String())
+	}
+}
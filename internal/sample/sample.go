@@ -0,0 +1,114 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package sample defines metric values with an explicit timestamp. Metric
+// values are normally recorded at the time the agent is polled (flush time),
+// a value wrapped in a sample.Value carries the time it was observed so that
+// delayed or backfilled data is submitted with the correct time (the `_ts`
+// attribute of the metric in /run responses).
+package sample
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Value is a metric value observed at Timestamp
+type Value struct {
+	Value     interface{}
+	Timestamp uint64 // milliseconds since the unix epoch
+}
+
+// New returns v observed at ts
+func New(v interface{}, ts time.Time) Value {
+	return Value{Value: v, Timestamp: uint64(ts.UnixNano() / int64(time.Millisecond))}
+}
+
+// Stamp wraps v with a timestamp in milliseconds, v is returned
+// unchanged if ms is zero (no explicit timestamp)
+func Stamp(v interface{}, ms uint64) interface{} {
+	if ms == 0 {
+		return v
+	}
+	return Value{Value: v, Timestamp: ms}
+}
+
+// Unwrap returns the underlying value and timestamp of v, the
+// timestamp is zero if v does not have an explicit timestamp
+func Unwrap(v interface{}) (interface{}, uint64) {
+	if sv, ok := v.(Value); ok {
+		return sv.Value, sv.Timestamp
+	}
+	return v, 0
+}
+
+// Float64 returns a numeric value as a float64, false if v is not numeric
+// (text, histograms, or a value with an explicit timestamp - see Unwrap)
+func Float64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// MarshalJSON encodes the underlying value only, the timestamp is a
+// separate attribute of the metric (see server encoding)
+func (v Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value)
+}
+
+// ParseTimestamp parses a timestamp in milliseconds since the unix epoch
+func ParseTimestamp(s string) (uint64, error) {
+	ms, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing timestamp")
+	}
+	if err := Validate(ms); err != nil {
+		return 0, err
+	}
+	return ms, nil
+}
+
+// maxFuture timestamps further in the future are rejected, a common
+// mistake is sending microseconds or nanoseconds instead of milliseconds
+const maxFuture = 24 * time.Hour
+
+// Validate verifies a timestamp in milliseconds is plausible
+func Validate(ms uint64) error {
+	limit := uint64(time.Now().Add(maxFuture).UnixNano() / int64(time.Millisecond))
+	if ms > limit {
+		return errors.Errorf("invalid timestamp (%d), more than %s in the future - expected milliseconds since epoch", ms, maxFuture)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package sample
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStampUnwrap(t *testing.T) {
+	t.Log("Testing Stamp/Unwrap")
+
+	t.Log("\tno timestamp")
+	{
+		v := Stamp(1.5, 0)
+		if _, ok := v.(Value); ok {
+			t.Fatal("expected unwrapped value")
+		}
+		uv, ts := Unwrap(v)
+		if uv != 1.5 || ts != 0 {
+			t.Fatalf("expected 1.5/0, got %v/%d", uv, ts)
+		}
+	}
+
+	t.Log("\ttimestamp")
+	{
+		v := Stamp(uint64(10), 1590000000123)
+		uv, ts := Unwrap(v)
+		if uv != uint64(10) || ts != 1590000000123 {
+			t.Fatalf("expected 10/1590000000123, got %v/%d", uv, ts)
+		}
+	}
+
+	t.Log("\tnew")
+	{
+		now := time.Unix(1590000000, 123000000)
+		_, ts := Unwrap(New("foo", now))
+		if ts != 1590000000123 {
+			t.Fatalf("expected 1590000000123, got %d", ts)
+		}
+	}
+}
+
+func TestFloat64(t *testing.T) {
+	t.Log("Testing Float64")
+
+	tt := []struct {
+		name   string
+		v      interface{}
+		expect float64
+		ok     bool
+	}{
+		{"float64", 1.5, 1.5, true},
+		{"float32", float32(2.5), 2.5, true},
+		{"int", 3, 3, true},
+		{"int8", int8(-4), -4, true},
+		{"uint64", uint64(5), 5, true},
+		{"json number", json.Number("6.5"), 6.5, true},
+		{"invalid json number", json.Number("x"), 0, false},
+		{"text", "7", 0, false},
+		{"stamped", Stamp(8, 1590000000123), 0, false},
+		{"nil", nil, 0, false},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s", tst.name)
+		f, ok := Float64(tst.v)
+		if ok != tst.ok || f != tst.expect {
+			t.Fatalf("expected %v/%v, got %v/%v", tst.expect, tst.ok, f, ok)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	t.Log("Testing MarshalJSON")
+
+	data, err := json.Marshal(map[string]interface{}{"v": Stamp(1.5, 1590000000123)})
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	if string(data) != `{"v":1.5}` {
+		t.Fatalf("unexpected encoding %s", string(data))
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	t.Log("Testing ParseTimestamp")
+
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+
+	tt := []struct {
+		name      string
+		ts        string
+		shouldErr bool
+	}{
+		{"valid", "1590000000123", false},
+		{"now", strconv.FormatUint(now, 10), false},
+		{"invalid", "abc", true},
+		{"negative", "-1", true},
+		{"microseconds", strconv.FormatUint(now*1000, 10), true},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s", tst.name)
+		_, err := ParseTimestamp(tst.ts)
+		if tst.shouldErr && err == nil {
+			t.Fatal("expected error")
+		}
+		if !tst.shouldErr && err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+	}
+}
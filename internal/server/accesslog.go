@@ -0,0 +1,185 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type traceCtxKey struct{}
+
+var traceparentRx = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// span is a single timed operation within a request
+type span struct {
+	name     string
+	spanID   string
+	start    time.Time
+	duration time.Duration
+	metrics  int
+}
+
+// requestTrace collects timings for a request, ids follow the W3C trace
+// context format so spans can be correlated with OpenTelemetry traces
+type requestTrace struct {
+	traceID      string
+	spanID       string
+	parentSpanID string
+	spans        []span
+	sync.Mutex
+}
+
+// statusWriter records the status and number of bytes written for a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += n
+	return n, err
+}
+
+// newRequestTrace creates a trace for a request, continuing the trace
+// identified in a traceparent header if one is present
+func newRequestTrace(r *http.Request) *requestTrace {
+	rt := &requestTrace{spanID: randomID(8)}
+	if m := traceparentRx.FindStringSubmatch(r.Header.Get("traceparent")); m != nil {
+		rt.traceID = m[1]
+		rt.parentSpanID = m[2]
+	} else {
+		rt.traceID = randomID(16)
+	}
+	return rt
+}
+
+// traceFromContext returns the request trace, if any
+func traceFromContext(ctx context.Context) *requestTrace {
+	rt, _ := ctx.Value(traceCtxKey{}).(*requestTrace)
+	return rt
+}
+
+// record adds a timed span (e.g. a collection conduit) to the trace
+func (rt *requestTrace) record(name string, start time.Time, metrics int) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	rt.spans = append(rt.spans, span{
+		name:     name,
+		spanID:   randomID(8),
+		start:    start,
+		duration: time.Since(start),
+		metrics:  metrics,
+	})
+	rt.Unlock()
+}
+
+// timings returns the recorded span durations, keyed by span name, as a log dict
+func (rt *requestTrace) timings() *zerolog.Event {
+	d := zerolog.Dict()
+	if rt == nil {
+		return d
+	}
+	rt.Lock()
+	for _, sp := range rt.spans {
+		d = d.Str(sp.name, sp.duration.String())
+	}
+	rt.Unlock()
+	return d
+}
+
+// accessLogHandler wraps a handler emitting an access log line and/or
+// trace spans for each request, if enabled
+func (s *Server) accessLogHandler(next http.Handler) http.Handler {
+	if !s.accessLog && !s.traceSpans {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rt := newRequestTrace(r)
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), traceCtxKey{}, rt)))
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		duration := time.Since(start)
+
+		if s.accessLog {
+			s.logger.Info().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("query", r.URL.RawQuery).
+				Str("remote", r.RemoteAddr).
+				Int("status", sw.status).
+				Int("bytes", sw.bytes).
+				Str("duration", duration.String()).
+				Str("trace_id", rt.traceID).
+				Dict("timings", rt.timings()).
+				Msg("access")
+		}
+
+		if s.traceSpans && pluginPathRx.MatchString(r.URL.Path) {
+			s.logSpans(rt, r, start, duration, sw.status)
+		}
+	})
+}
+
+// logSpans emits the request span and any child spans recorded during handling
+func (s *Server) logSpans(rt *requestTrace, r *http.Request, start time.Time, duration time.Duration, status int) {
+	s.logger.Info().
+		Str("trace_id", rt.traceID).
+		Str("span_id", rt.spanID).
+		Str("parent_span_id", rt.parentSpanID).
+		Str("name", r.Method+" "+r.URL.Path).
+		Time("start", start).
+		Str("duration", duration.String()).
+		Int("status", status).
+		Msg("span")
+
+	rt.Lock()
+	defer rt.Unlock()
+	for _, sp := range rt.spans {
+		s.logger.Info().
+			Str("trace_id", rt.traceID).
+			Str("span_id", sp.spanID).
+			Str("parent_span_id", rt.spanID).
+			Str("name", sp.name).
+			Time("start", sp.start).
+			Str("duration", sp.duration.String()).
+			Int("metrics", sp.metrics).
+			Msg("span")
+	}
+}
+
+// randomID returns a random hex id of n bytes
+func randomID(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
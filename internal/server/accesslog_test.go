@@ -0,0 +1,158 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewRequestTrace(t *testing.T) {
+	t.Log("Testing newRequestTrace")
+
+	t.Log("\tno traceparent")
+	{
+		req := httptest.NewRequest("GET", "/run", nil)
+		rt := newRequestTrace(req)
+		if len(rt.traceID) != 32 {
+			t.Fatalf("expected 32 char trace id, got (%s)", rt.traceID)
+		}
+		if len(rt.spanID) != 16 {
+			t.Fatalf("expected 16 char span id, got (%s)", rt.spanID)
+		}
+		if rt.parentSpanID != "" {
+			t.Fatalf("expected empty parent span id, got (%s)", rt.parentSpanID)
+		}
+	}
+
+	t.Log("\tvalid traceparent")
+	{
+		req := httptest.NewRequest("GET", "/run", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		rt := newRequestTrace(req)
+		if rt.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("expected trace id from header, got (%s)", rt.traceID)
+		}
+		if rt.parentSpanID != "00f067aa0ba902b7" {
+			t.Fatalf("expected parent span id from header, got (%s)", rt.parentSpanID)
+		}
+	}
+
+	t.Log("\tinvalid traceparent")
+	{
+		req := httptest.NewRequest("GET", "/run", nil)
+		req.Header.Set("traceparent", "foo")
+		rt := newRequestTrace(req)
+		if rt.parentSpanID != "" {
+			t.Fatalf("expected empty parent span id, got (%s)", rt.parentSpanID)
+		}
+	}
+}
+
+func TestAccessLogHandler(t *testing.T) {
+	t.Log("Testing accessLogHandler")
+
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	defer zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceFromContext(r.Context()).record("builtins", time.Now(), 3)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	})
+
+	t.Log("\tdisabled")
+	{
+		var buf bytes.Buffer
+		s := &Server{logger: zerolog.New(&buf)}
+		h := s.accessLogHandler(next)
+		req := httptest.NewRequest("GET", "/run", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if buf.Len() != 0 {
+			t.Fatalf("expected no log output, got (%s)", buf.String())
+		}
+	}
+
+	t.Log("\taccess log")
+	{
+		var buf bytes.Buffer
+		s := &Server{logger: zerolog.New(&buf), accessLog: true}
+		h := s.accessLogHandler(next)
+		req := httptest.NewRequest("GET", "/run", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("expected NO error, got (%s) %s", err, buf.String())
+		}
+		if entry["message"] != "access" {
+			t.Fatalf("expected access message, got (%v)", entry["message"])
+		}
+		if entry["status"] != float64(http.StatusOK) {
+			t.Fatalf("expected status 200, got (%v)", entry["status"])
+		}
+		if entry["bytes"] != float64(2) {
+			t.Fatalf("expected 2 bytes, got (%v)", entry["bytes"])
+		}
+		timings, ok := entry["timings"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected timings, got (%v)", entry["timings"])
+		}
+		if _, ok := timings["builtins"]; !ok {
+			t.Fatalf("expected builtins timing, got (%v)", timings)
+		}
+	}
+
+	t.Log("\ttrace spans")
+	{
+		var buf bytes.Buffer
+		s := &Server{logger: zerolog.New(&buf), traceSpans: true}
+		h := s.accessLogHandler(next)
+		req := httptest.NewRequest("GET", "/run", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 span lines, got %d (%s)", len(lines), buf.String())
+		}
+		var root, child map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[0]), &root); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := json.Unmarshal([]byte(lines[1]), &child); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if child["parent_span_id"] != root["span_id"] {
+			t.Fatalf("expected child parent (%v) to be root span (%v)", child["parent_span_id"], root["span_id"])
+		}
+		if child["trace_id"] != root["trace_id"] {
+			t.Fatalf("expected same trace id, got (%v) (%v)", child["trace_id"], root["trace_id"])
+		}
+	}
+
+	t.Log("\ttrace spans, non-run path")
+	{
+		var buf bytes.Buffer
+		s := &Server{logger: zerolog.New(&buf), traceSpans: true}
+		h := s.accessLogHandler(next)
+		req := httptest.NewRequest("GET", "/inventory", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if buf.Len() != 0 {
+			t.Fatalf("expected no log output, got (%s)", buf.String())
+		}
+	}
+}
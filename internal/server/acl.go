@@ -0,0 +1,188 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	appstats "github.com/maier/go-appstats"
+	"github.com/pkg/errors"
+)
+
+const (
+	// aclSSLListener is the key used in the acl file for the ssl listener
+	aclSSLListener = "ssl"
+)
+
+// listenerACLConfig defines the access settings for a single listener in the acl file
+type listenerACLConfig struct {
+	Allow     []string `json:"allow"`
+	Deny      []string `json:"deny"`
+	AuthToken string   `json:"auth_token"`
+}
+
+// aclFile defines the structure of the listen acl file, listeners
+// are keyed by listen spec (e.g. ":2609", "127.0.0.1:2609") or "ssl"
+type aclFile struct {
+	Listeners map[string]listenerACLConfig `json:"listeners"`
+}
+
+// listenerACL is the parsed access settings for a listener
+type listenerACL struct {
+	allow     []*net.IPNet
+	deny      []*net.IPNet
+	authToken string
+}
+
+// loadListenerACLs reads the listen acl file and returns the acls
+// keyed by the resolved listen address (or "ssl")
+func loadListenerACLs(file string) (map[string]*listenerACL, error) {
+	acls := make(map[string]*listenerACL)
+	if file == "" {
+		return acls, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading listen acl file (%s)", file)
+	}
+
+	var cfg aclFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "parsing listen acl file (%s)", file)
+	}
+
+	for spec, lcfg := range cfg.Listeners {
+		key := spec
+		if spec != aclSSLListener {
+			ta, err := config.ParseListen(spec)
+			if err != nil {
+				return nil, errors.Wrapf(err, "listen acl (%s)", spec)
+			}
+			key = ta.String()
+		}
+
+		acl, err := newListenerACL(lcfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listen acl (%s)", spec)
+		}
+		acls[key] = acl
+	}
+
+	return acls, nil
+}
+
+// newListenerACL parses the allow/deny lists, entries can be an ip or cidr
+func newListenerACL(cfg listenerACLConfig) (*listenerACL, error) {
+	acl := &listenerACL{authToken: cfg.AuthToken}
+
+	parse := func(list []string) ([]*net.IPNet, error) {
+		nets := make([]*net.IPNet, 0, len(list))
+		for _, item := range list {
+			if !strings.Contains(item, "/") {
+				ip := net.ParseIP(config.StripBrackets(item))
+				if ip == nil {
+					return nil, errors.Errorf("invalid ip (%s)", item)
+				}
+				bits := 128
+				if ip.To4() != nil {
+					ip = ip.To4()
+					bits = 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			_, n, err := net.ParseCIDR(item)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid cidr")
+			}
+			nets = append(nets, n)
+		}
+		return nets, nil
+	}
+
+	var err error
+	if acl.allow, err = parse(cfg.Allow); err != nil {
+		return nil, errors.Wrap(err, "allow")
+	}
+	if acl.deny, err = parse(cfg.Deny); err != nil {
+		return nil, errors.Wrap(err, "deny")
+	}
+
+	return acl, nil
+}
+
+// check verifies a request against the acl, returning the http status
+// to respond with when the request is not permitted (or 0 if it is)
+func (acl *listenerACL) check(r *http.Request) int {
+	if acl == nil {
+		return 0
+	}
+
+	if len(acl.allow) > 0 || len(acl.deny) > 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return http.StatusForbidden
+		}
+		for _, n := range acl.deny {
+			if n.Contains(ip) {
+				return http.StatusForbidden
+			}
+		}
+		if len(acl.allow) > 0 {
+			allowed := false
+			for _, n := range acl.allow {
+				if n.Contains(ip) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return http.StatusForbidden
+			}
+		}
+	}
+
+	if acl.authToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(acl.authToken)) != 1 {
+			return http.StatusUnauthorized
+		}
+	}
+
+	return 0
+}
+
+// aclHandler wraps a handler enforcing the listener's acl, if one is defined
+func (s *Server) aclHandler(acl *listenerACL, next http.Handler) http.Handler {
+	if acl == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := acl.check(r); status != 0 {
+			_ = appstats.IncrementInt("requests_denied")
+			s.logger.Warn().
+				Str("remote", r.RemoteAddr).
+				Str("method", r.Method).
+				Str("url", r.URL.String()).
+				Int("status", status).
+				Msg("request denied by listener acl")
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
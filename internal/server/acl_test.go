@@ -0,0 +1,190 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLoadListenerACLs(t *testing.T) {
+	t.Log("Testing loadListenerACLs")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := ioutil.TempDir("", "acltest")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(data string) string {
+		f := filepath.Join(dir, "acl.json")
+		if err := ioutil.WriteFile(f, []byte(data), 0600); err != nil {
+			t.Fatalf("writing acl file (%s)", err)
+		}
+		return f
+	}
+
+	t.Log("no file")
+	{
+		acls, err := loadListenerACLs("")
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(acls) != 0 {
+			t.Fatalf("expected 0 acls, got %d", len(acls))
+		}
+	}
+
+	t.Log("missing file")
+	{
+		_, err := loadListenerACLs(filepath.Join(dir, "missing.json"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("bad syntax")
+	{
+		_, err := loadListenerACLs(writeFile(`{"listeners":`))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("invalid cidr")
+	{
+		_, err := loadListenerACLs(writeFile(`{"listeners":{"127.0.0.1:2609":{"allow":["10.0.0.0/99"]}}}`))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("invalid ip")
+	{
+		_, err := loadListenerACLs(writeFile(`{"listeners":{"127.0.0.1:2609":{"deny":["foo"]}}}`))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("valid")
+	{
+		acls, err := loadListenerACLs(writeFile(`{"listeners":{"127.0.0.1":{"allow":["127.0.0.1","[::1]"]},"ssl":{"auth_token":"foo"}}}`))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(acls) != 2 {
+			t.Fatalf("expected 2 acls, got %d", len(acls))
+		}
+		if _, ok := acls["127.0.0.1:2609"]; !ok {
+			t.Fatalf("expected 127.0.0.1:2609 acl, got %#v", acls)
+		}
+		if acl, ok := acls[aclSSLListener]; !ok {
+			t.Fatalf("expected ssl acl, got %#v", acls)
+		} else if acl.authToken != "foo" {
+			t.Fatalf("expected auth token 'foo', got (%s)", acl.authToken)
+		}
+	}
+}
+
+func TestListenerACLCheck(t *testing.T) {
+	t.Log("Testing listenerACL.check")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	acl, err := newListenerACL(listenerACLConfig{
+		Allow:     []string{"10.0.0.0/8", "::1"},
+		Deny:      []string{"10.1.2.3"},
+		AuthToken: "secret",
+	})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	tests := []struct {
+		desc   string
+		remote string
+		token  string
+		expect int
+	}{
+		{"allowed w/token", "10.0.0.1:1234", "Bearer secret", 0},
+		{"allowed ipv6 w/token", "[::1]:1234", "Bearer secret", 0},
+		{"allowed w/o token", "10.0.0.1:1234", "", http.StatusUnauthorized},
+		{"allowed w/bad token", "10.0.0.1:1234", "Bearer foo", http.StatusUnauthorized},
+		{"denied", "10.1.2.3:1234", "Bearer secret", http.StatusForbidden},
+		{"not allowed", "192.168.1.1:1234", "Bearer secret", http.StatusForbidden},
+		{"invalid remote", "foo", "Bearer secret", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Log(test.desc)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		if test.token != "" {
+			req.Header.Set("Authorization", test.token)
+		}
+		if status := acl.check(req); status != test.expect {
+			t.Fatalf("expected %d, got %d", test.expect, status)
+		}
+	}
+
+	t.Log("nil acl")
+	{
+		var nacl *listenerACL
+		req := httptest.NewRequest("GET", "/", nil)
+		if status := nacl.check(req); status != 0 {
+			t.Fatalf("expected 0, got %d", status)
+		}
+	}
+}
+
+func TestACLHandler(t *testing.T) {
+	t.Log("Testing aclHandler")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	s := &Server{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	acl, err := newListenerACL(listenerACLConfig{Allow: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	h := s.aclHandler(acl, next)
+
+	t.Log("allowed")
+	{
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected %d, got %d", http.StatusNoContent, w.Code)
+		}
+	}
+
+	t.Log("denied")
+	{
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "127.0.0.2:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("expected %d, got %d", http.StatusForbidden, w.Code)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsMaxAge seconds a browser may cache a preflight response
+const corsMaxAge = "600"
+
+// corsPolicy defines the origins allowed to make cross-origin (read-only)
+// requests to the local api, e.g. a dashboard hosted elsewhere polling
+// /ui/status or /health
+type corsPolicy struct {
+	any     bool
+	origins map[string]bool
+}
+
+// newCORSPolicy returns a policy for the origins, nil (cors disabled) if there are none
+func newCORSPolicy(origins []string) *corsPolicy {
+	if len(origins) == 0 {
+		return nil
+	}
+	p := &corsPolicy{origins: make(map[string]bool, len(origins))}
+	for _, origin := range origins {
+		if origin == "*" {
+			p.any = true
+			continue
+		}
+		p.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return p
+}
+
+// allows determines if an origin may make cross-origin requests
+func (p *corsPolicy) allows(origin string) bool {
+	if p == nil || origin == "" {
+		return false
+	}
+	return p.any || p.origins[strings.ToLower(origin)]
+}
+
+// corsHandler adds the cors response headers for allowed origins and answers
+// preflight requests. Only GET is allowed cross-origin, requests which change
+// state (/write, /collectors/...) are not exposed to other origins.
+func (s *Server) corsHandler(next http.Handler) http.Handler {
+	if s.cors == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !s.cors.allows(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			if r.Header.Get("Access-Control-Request-Method") != "GET" {
+				s.logger.Warn().Str("origin", origin).Str("method", r.Header.Get("Access-Control-Request-Method")).Str("url", r.URL.String()).Msg("cors preflight, method not allowed")
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Methods", "GET")
+			h.Set("Access-Control-Allow-Headers", "Accept, Accept-Encoding, Authorization")
+			h.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if r.Method == "GET" {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listenerHandler returns the handler of a listener, cors is applied before
+// the listener's acl so preflight requests (which never carry credentials)
+// are answered on listeners requiring an auth token
+func (s *Server) listenerHandler(acl *listenerACL, next http.Handler) http.Handler {
+	return s.accessLogHandler(s.corsHandler(s.aclHandler(acl, next)))
+}
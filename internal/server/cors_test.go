@@ -0,0 +1,184 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	t.Log("Testing corsPolicy")
+
+	t.Log("disabled")
+	{
+		p := newCORSPolicy(nil)
+		if p != nil {
+			t.Fatalf("expected nil, got %#v", p)
+		}
+		if p.allows("https://example.com") {
+			t.Fatal("expected not allowed")
+		}
+	}
+
+	t.Log("origins")
+	{
+		p := newCORSPolicy([]string{"https://Dashboard.example.com/"})
+		if !p.allows("https://dashboard.example.com") {
+			t.Fatal("expected allowed")
+		}
+		if p.allows("https://example.com") || p.allows("") {
+			t.Fatal("expected not allowed")
+		}
+	}
+
+	t.Log("any")
+	{
+		p := newCORSPolicy([]string{"*"})
+		if !p.allows("https://example.com") {
+			t.Fatal("expected allowed")
+		}
+	}
+}
+
+func TestCORSHandler(t *testing.T) {
+	t.Log("Testing corsHandler")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	s := &Server{cors: newCORSPolicy([]string{"https://dashboard.example.com"})}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := s.corsHandler(next)
+
+	t.Log("allowed origin")
+	{
+		req := httptest.NewRequest("GET", "/ui/status", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://dashboard.example.com" {
+			t.Fatalf("expected origin, got (%s)", v)
+		}
+	}
+
+	t.Log("other origin")
+	{
+		req := httptest.NewRequest("GET", "/ui/status", nil)
+		req.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+			t.Fatalf("expected no allow origin, got (%s)", v)
+		}
+	}
+
+	t.Log("allowed origin, POST")
+	{
+		req := httptest.NewRequest("POST", "/write/foo", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+			t.Fatalf("expected no allow origin, got (%s)", v)
+		}
+	}
+
+	t.Log("preflight")
+	{
+		req := httptest.NewRequest("OPTIONS", "/ui/status", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if v := w.Header().Get("Access-Control-Allow-Methods"); v != "GET" {
+			t.Fatalf("expected GET, got (%s)", v)
+		}
+	}
+
+	t.Log("preflight, PUT")
+	{
+		req := httptest.NewRequest("OPTIONS", "/write/foo", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	}
+}
+
+func TestCORSAuthToken(t *testing.T) {
+	t.Log("Testing cors on a listener with an auth token")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	s := &Server{cors: newCORSPolicy([]string{"https://dashboard.example.com"})}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	acl, err := newListenerACL(listenerACLConfig{AuthToken: "secret"})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	h := s.listenerHandler(acl, next)
+
+	t.Log("preflight")
+	{
+		req := httptest.NewRequest("OPTIONS", "/ui/status", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		req.Header.Set("Access-Control-Request-Headers", "authorization")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if v := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(v, "Authorization") {
+			t.Fatalf("expected Authorization allowed, got (%s)", v)
+		}
+	}
+
+	t.Log("authenticated")
+	{
+		req := httptest.NewRequest("GET", "/ui/status", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://dashboard.example.com" {
+			t.Fatalf("expected origin, got (%s)", v)
+		}
+	}
+
+	t.Log("no token")
+	{
+		req := httptest.NewRequest("GET", "/ui/status", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// deltaMediaType is the Accept (request) and Content-Type (response) of delta encoded /run responses
+	deltaMediaType = "application/vnd.circonus.delta+json"
+	// deltaBaseHeader is the request header with the sequence of the last delta response applied by the client
+	deltaBaseHeader = "X-Circonus-Delta-Base"
+	// deltaSeqHeader is the response header with the sequence of a delta encoded response
+	deltaSeqHeader = "X-Circonus-Delta-Seq"
+	// deltaHistory is the number of prior responses retained as delta bases
+	deltaHistory = 4
+	// deltaKeyframeInterval a full response (keyframe) is sent at least every N responses, bounds dictionary growth
+	deltaKeyframeInterval = 60
+)
+
+// deltaEncoder encodes /run responses as a dictionary of metric names and the
+// changes versus a prior response (the base) acknowledged by the client, for
+// brokers negotiating the encoding (Accept: application/vnd.circonus.delta+json).
+//
+// A response is:
+//
+//	{"seq":N,"base":B,"dict_offset":K,"dict":["name",...],"set":[[idx,"type",value(,ts)],...],"delta":[[idx,diff],...],"removed":[idx,...]}
+//
+// dict holds the names added to the dictionary after the base (index K onward),
+// set metrics which are new or whose value changed, delta integer metrics as the
+// difference from the base value and removed metrics in the base but not in the
+// response - unchanged metrics are omitted. A response with base 0 is a keyframe,
+// the dictionary starts over and all metrics are in set. Applying a response to
+// the base yields the metrics a regular /run response would contain.
+type deltaEncoder struct {
+	seq       uint64
+	keyframe  uint64         // seq of the keyframe starting the current dictionary
+	dict      []string       // names, by index
+	index     map[string]int // name -> dictionary index
+	snapshots []deltaSnapshot
+	sync.Mutex
+}
+
+type deltaSnapshot struct {
+	seq      uint64
+	keyframe uint64
+	dictSize int // dictionary entries known to the client at seq
+	metrics  *cgm.Metrics
+}
+
+// newDeltaEncoder returns nil if delta encoding is not enabled
+func newDeltaEncoder(enabled bool) *deltaEncoder {
+	if !enabled {
+		return nil
+	}
+	return &deltaEncoder{index: make(map[string]int)}
+}
+
+// accepts determines if the request negotiates a delta encoded response
+func (d *deltaEncoder) accepts(r *http.Request) bool {
+	if d == nil {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), deltaMediaType)
+}
+
+// encode returns the delta encoding of metrics versus the base requested and the sequence of the response
+func (d *deltaEncoder) encode(m *cgm.Metrics, r *http.Request) ([]byte, uint64, error) {
+	if m == nil {
+		m = &cgm.Metrics{}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	var base *deltaSnapshot
+	if b, err := strconv.ParseUint(r.Header.Get(deltaBaseHeader), 10, 64); err == nil && b > 0 {
+		for i := range d.snapshots {
+			if d.snapshots[i].seq == b && d.snapshots[i].keyframe == d.keyframe {
+				base = &d.snapshots[i]
+				break
+			}
+		}
+	}
+
+	d.seq++
+	if base == nil || d.seq-d.keyframe >= deltaKeyframeInterval {
+		base = nil
+		d.keyframe = d.seq
+		d.dict = d.dict[:0]
+		d.index = make(map[string]int)
+	}
+
+	names := make([]string, 0, len(*m))
+	for name := range *m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dictOffset := 0
+	var prev cgm.Metrics
+	if base != nil {
+		dictOffset = base.dictSize
+		prev = *base.metrics
+	}
+
+	set := make([]byte, 0, 1024)
+	delta := make([]byte, 0, 1024)
+	for _, name := range names {
+		metric := (*m)[name]
+		idx, known := d.index[name]
+		if !known {
+			idx = len(d.dict)
+			d.index[name] = idx
+			d.dict = append(d.dict, name)
+		}
+		if pm, ok := prev[name]; ok && idx < dictOffset {
+			if pm.Type == metric.Type && reflect.DeepEqual(pm.Value, metric.Value) {
+				continue
+			}
+			if pm.Type == metric.Type {
+				if diff, ok := intDelta(pm.Value, metric.Value); ok {
+					if len(delta) > 0 {
+						delta = append(delta, ',')
+					}
+					delta = append(delta, '[')
+					delta = strconv.AppendInt(delta, int64(idx), 10)
+					delta = append(delta, ',')
+					delta = strconv.AppendInt(delta, diff, 10)
+					delta = append(delta, ']')
+					continue
+				}
+			}
+		}
+		if len(set) > 0 {
+			set = append(set, ',')
+		}
+		var err error
+		set, err = appendDeltaSet(set, idx, metric)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "encoding metric %s", name)
+		}
+	}
+
+	removed := make([]byte, 0, 64)
+	if base != nil {
+		removedNames := make([]string, 0)
+		for name := range prev {
+			if _, ok := (*m)[name]; !ok {
+				removedNames = append(removedNames, name)
+			}
+		}
+		sort.Strings(removedNames)
+		for _, name := range removedNames {
+			idx, ok := d.index[name]
+			if !ok || idx >= dictOffset {
+				continue
+			}
+			if len(removed) > 0 {
+				removed = append(removed, ',')
+			}
+			removed = strconv.AppendInt(removed, int64(idx), 10)
+		}
+	}
+
+	var baseSeq uint64
+	if base != nil {
+		baseSeq = base.seq
+	}
+
+	b := make([]byte, 0, len(set)+len(delta)+len(removed)+256)
+	b = append(b, `{"seq":`...)
+	b = strconv.AppendUint(b, d.seq, 10)
+	b = append(b, `,"base":`...)
+	b = strconv.AppendUint(b, baseSeq, 10)
+	b = append(b, `,"dict_offset":`...)
+	b = strconv.AppendInt(b, int64(dictOffset), 10)
+	b = append(b, `,"dict":[`...)
+	for i, name := range d.dict[dictOffset:] {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendString(b, name)
+	}
+	b = append(b, `],"set":[`...)
+	b = append(b, set...)
+	b = append(b, `],"delta":[`...)
+	b = append(b, delta...)
+	b = append(b, `],"removed":[`...)
+	b = append(b, removed...)
+	b = append(b, "]}"...)
+
+	d.snapshots = append(d.snapshots, deltaSnapshot{seq: d.seq, keyframe: d.keyframe, dictSize: len(d.dict), metrics: m})
+	if len(d.snapshots) > deltaHistory {
+		d.snapshots = append(d.snapshots[:0], d.snapshots[len(d.snapshots)-deltaHistory:]...)
+	}
+
+	return b, d.seq, nil
+}
+
+// appendDeltaSet appends `[idx,"type",value]` to dst, metrics with an explicit
+// timestamp have a fourth element `ts` (ms)
+func appendDeltaSet(dst []byte, idx int, metric cgm.Metric) ([]byte, error) {
+	v, ts := sample.Unwrap(metric.Value)
+	dst = append(dst, '[')
+	dst = strconv.AppendInt(dst, int64(idx), 10)
+	dst = append(dst, ',')
+	dst = appendString(dst, metric.Type)
+	dst = append(dst, ',')
+	dst, err := appendValue(dst, v)
+	if err != nil {
+		return dst, err
+	}
+	if ts > 0 {
+		dst = append(dst, ',')
+		dst = strconv.AppendUint(dst, ts, 10)
+	}
+	return append(dst, ']'), nil
+}
+
+// intDelta returns the difference between two integer values of the same
+// type, ok is false for other types or if the difference overflows an int64
+func intDelta(prev, cur interface{}) (int64, bool) {
+	switch c := cur.(type) {
+	case int:
+		if p, ok := prev.(int); ok {
+			return signedDelta(int64(p), int64(c))
+		}
+	case int32:
+		if p, ok := prev.(int32); ok {
+			return int64(c) - int64(p), true
+		}
+	case int64:
+		if p, ok := prev.(int64); ok {
+			return signedDelta(p, c)
+		}
+	case uint:
+		if p, ok := prev.(uint); ok {
+			return unsignedDelta(uint64(p), uint64(c))
+		}
+	case uint32:
+		if p, ok := prev.(uint32); ok {
+			return int64(c) - int64(p), true
+		}
+	case uint64:
+		if p, ok := prev.(uint64); ok {
+			return unsignedDelta(p, c)
+		}
+	}
+	return 0, false
+}
+
+func signedDelta(prev, cur int64) (int64, bool) {
+	diff := cur - prev
+	if (cur >= prev) != (diff >= 0) {
+		return 0, false
+	}
+	return diff, true
+}
+
+func unsignedDelta(prev, cur uint64) (int64, bool) {
+	if cur >= prev {
+		if cur-prev > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(cur - prev), true
+	}
+	if prev-cur > math.MaxInt64 {
+		return 0, false
+	}
+	return -int64(prev - cur), true
+}
@@ -0,0 +1,225 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+type deltaResponse struct {
+	Seq        uint64              `json:"seq"`
+	Base       uint64              `json:"base"`
+	DictOffset int                 `json:"dict_offset"`
+	Dict       []string            `json:"dict"`
+	Set        [][]json.RawMessage `json:"set"`
+	Delta      [][2]int64          `json:"delta"`
+	Removed    []int               `json:"removed"`
+}
+
+// deltaClient applies delta responses, as a broker would
+type deltaClient struct {
+	seq     uint64
+	dict    []string
+	metrics map[string]interface{} // name -> [type, value]
+}
+
+func (c *deltaClient) request(t *testing.T, d *deltaEncoder, m *cgm.Metrics) deltaResponse {
+	r := httptest.NewRequest("GET", "/run", nil)
+	r.Header.Set("Accept", deltaMediaType)
+	if c.seq > 0 {
+		r.Header.Set(deltaBaseHeader, strconv.FormatUint(c.seq, 10))
+	}
+	data, seq, err := d.encode(m, r)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	var resp deltaResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("parsing response (%s) %s", err, string(data))
+	}
+	if resp.Seq != seq {
+		t.Fatalf("expected seq %d, got %d", seq, resp.Seq)
+	}
+
+	if resp.Base == 0 {
+		c.dict = nil
+		c.metrics = make(map[string]interface{})
+	} else if resp.Base != c.seq {
+		t.Fatalf("expected base %d, got %d", c.seq, resp.Base)
+	}
+	if resp.DictOffset != len(c.dict) {
+		t.Fatalf("expected dict offset %d, got %d", len(c.dict), resp.DictOffset)
+	}
+	c.dict = append(c.dict, resp.Dict...)
+	for _, s := range resp.Set {
+		var idx int
+		var typ string
+		var val interface{}
+		_ = json.Unmarshal(s[0], &idx)
+		_ = json.Unmarshal(s[1], &typ)
+		_ = json.Unmarshal(s[2], &val)
+		c.metrics[c.dict[idx]] = []interface{}{typ, val}
+	}
+	for _, dv := range resp.Delta {
+		prev := c.metrics[c.dict[dv[0]]].([]interface{})
+		c.metrics[c.dict[dv[0]]] = []interface{}{prev[0], prev[1].(float64) + float64(dv[1])}
+	}
+	for _, idx := range resp.Removed {
+		delete(c.metrics, c.dict[idx])
+	}
+	c.seq = resp.Seq
+	return resp
+}
+
+// expected returns metrics in the form a client decodes them
+func expected(m *cgm.Metrics) map[string]interface{} {
+	data, _ := json.Marshal(m)
+	var full map[string]map[string]interface{}
+	_ = json.Unmarshal(data, &full)
+	e := make(map[string]interface{})
+	for name, v := range full {
+		e[name] = []interface{}{v["_type"], v["_value"]}
+	}
+	return e
+}
+
+func TestDeltaEncoder(t *testing.T) {
+	t.Log("Testing deltaEncoder")
+
+	t.Log("\tdisabled")
+	{
+		d := newDeltaEncoder(false)
+		r := httptest.NewRequest("GET", "/run", nil)
+		r.Header.Set("Accept", deltaMediaType)
+		if d.accepts(r) {
+			t.Fatal("expected false")
+		}
+	}
+
+	d := newDeltaEncoder(true)
+	c := &deltaClient{}
+
+	t.Log("\tnot negotiated")
+	{
+		if d.accepts(httptest.NewRequest("GET", "/run", nil)) {
+			t.Fatal("expected false")
+		}
+	}
+
+	t.Log("\tkeyframe")
+	{
+		m := &cgm.Metrics{
+			"a": cgm.Metric{Type: "L", Value: uint64(10)},
+			"b": cgm.Metric{Type: "n", Value: 1.5},
+			"c": cgm.Metric{Type: "s", Value: "foo"},
+		}
+		resp := c.request(t, d, m)
+		if resp.Base != 0 || len(resp.Dict) != 3 || len(resp.Set) != 3 {
+			t.Fatalf("expected keyframe, got %+v", resp)
+		}
+		if !reflect.DeepEqual(c.metrics, expected(m)) {
+			t.Fatalf("expected %v, got %v", expected(m), c.metrics)
+		}
+	}
+
+	t.Log("\tdelta")
+	{
+		m := &cgm.Metrics{
+			"a": cgm.Metric{Type: "L", Value: uint64(7)},
+			"b": cgm.Metric{Type: "n", Value: 1.5},
+			"d": cgm.Metric{Type: "i", Value: int32(-3)},
+		}
+		resp := c.request(t, d, m)
+		if resp.Base == 0 {
+			t.Fatal("expected delta")
+		}
+		if len(resp.Dict) != 1 || resp.Dict[0] != "d" {
+			t.Fatalf("expected new name d, got %v", resp.Dict)
+		}
+		if len(resp.Delta) != 1 || resp.Delta[0][1] != -3 {
+			t.Fatalf("expected a delta -3, got %v", resp.Delta)
+		}
+		if len(resp.Set) != 1 || len(resp.Removed) != 1 {
+			t.Fatalf("expected d set and c removed, got %+v", resp)
+		}
+		if !reflect.DeepEqual(c.metrics, expected(m)) {
+			t.Fatalf("expected %v, got %v", expected(m), c.metrics)
+		}
+	}
+
+	t.Log("\tre-added")
+	{
+		m := &cgm.Metrics{
+			"a": cgm.Metric{Type: "L", Value: uint64(7)},
+			"c": cgm.Metric{Type: "s", Value: "bar"},
+		}
+		resp := c.request(t, d, m)
+		if len(resp.Dict) != 0 {
+			t.Fatalf("expected no new names, got %v", resp.Dict)
+		}
+		if !reflect.DeepEqual(c.metrics, expected(m)) {
+			t.Fatalf("expected %v, got %v", expected(m), c.metrics)
+		}
+	}
+
+	t.Log("\tunknown base")
+	{
+		c.seq = 1000
+		m := &cgm.Metrics{"a": cgm.Metric{Type: "L", Value: uint64(8)}}
+		resp := c.request(t, d, m)
+		if resp.Base != 0 || resp.DictOffset != 0 {
+			t.Fatalf("expected keyframe, got %+v", resp)
+		}
+		if !reflect.DeepEqual(c.metrics, expected(m)) {
+			t.Fatalf("expected %v, got %v", expected(m), c.metrics)
+		}
+	}
+
+	t.Log("\tkeyframe interval")
+	{
+		m := &cgm.Metrics{"a": cgm.Metric{Type: "L", Value: uint64(8)}}
+		keyframes := 0
+		for i := 0; i < deltaKeyframeInterval; i++ {
+			if resp := c.request(t, d, m); resp.Base == 0 {
+				keyframes++
+			}
+		}
+		if keyframes != 1 {
+			t.Fatalf("expected 1 keyframe, got %d", keyframes)
+		}
+	}
+}
+
+func TestRunDelta(t *testing.T) {
+	t.Log("Testing encodeDeltaResponse")
+
+	s := &Server{delta: newDeltaEncoder(true), logger: zerolog.Nop()}
+	m := &cgm.Metrics{"a": cgm.Metric{Type: "L", Value: uint64(1)}}
+
+	r := httptest.NewRequest("GET", "/run", nil)
+	r.Header.Set("Accept", deltaMediaType)
+	w := httptest.NewRecorder()
+	s.encodeDeltaResponse(m, w, r, time.Now())
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != deltaMediaType {
+		t.Fatalf("expected %s, got %s", deltaMediaType, ct)
+	}
+	if seq := w.Header().Get(deltaSeqHeader); seq != "1" {
+		t.Fatalf("expected seq 1, got %s", seq)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+// scratchPool holds encoding buffers, metrics are encoded into a scratch
+// buffer and written to the destination in chunks
+var scratchPool = sync.Pool{New: func() interface{} {
+	b := make([]byte, 0, 64*1024)
+	return &b
+}}
+
+// writeMetrics streams metrics as JSON to w, the output is identical to
+// json.Marshal (keys sorted, same escaping and number formatting) without
+// building the entire encoded document or reflecting over each metric -
+// with the exception of timestamped values (see sample.Value)
+func writeMetrics(w io.Writer, m *cgm.Metrics) error {
+	if m == nil || len(*m) == 0 {
+		_, err := io.WriteString(w, "{}")
+		return err
+	}
+
+	names := make([]string, 0, len(*m))
+	for name := range *m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	scratch := scratchPool.Get().(*[]byte)
+	defer scratchPool.Put(scratch)
+
+	b := (*scratch)[:0]
+	b = append(b, '{')
+	for i, name := range names {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		var err error
+		b, err = appendMetric(b, name, (*m)[name])
+		if err != nil {
+			*scratch = b[:0]
+			return err
+		}
+		// flush periodically so large metric sets are not held twice
+		if len(b) >= 32*1024 {
+			if _, err := w.Write(b); err != nil {
+				*scratch = b[:0]
+				return err
+			}
+			b = b[:0]
+		}
+	}
+	b = append(b, '}')
+	_, err := w.Write(b)
+
+	*scratch = b[:0]
+
+	return err
+}
+
+// appendMetric appends `"name":{"_type":"t","_value":v}` to dst, metrics
+// with an explicit timestamp also have a `"_ts":ms` attribute
+func appendMetric(dst []byte, name string, metric cgm.Metric) ([]byte, error) {
+	v, ts := sample.Unwrap(metric.Value)
+	dst = appendString(dst, name)
+	dst = append(dst, `:{"_type":`...)
+	dst = appendString(dst, metric.Type)
+	dst = append(dst, `,"_value":`...)
+	dst, err := appendValue(dst, v)
+	if err != nil {
+		return dst, errors.Wrapf(err, "encoding metric %s", name)
+	}
+	if ts > 0 {
+		dst = append(dst, `,"_ts":`...)
+		dst = strconv.AppendUint(dst, ts, 10)
+	}
+	return append(dst, '}'), nil
+}
+
+// appendValue appends the JSON encoding of common metric value types to dst,
+// less common types fall back to encoding/json
+func appendValue(dst []byte, v interface{}) ([]byte, error) {
+	switch tv := v.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case string:
+		return appendString(dst, tv), nil
+	case bool:
+		return strconv.AppendBool(dst, tv), nil
+	case float64:
+		return appendFloat(dst, tv, 64)
+	case float32:
+		return appendFloat(dst, float64(tv), 32)
+	case int:
+		return strconv.AppendInt(dst, int64(tv), 10), nil
+	case int8:
+		return strconv.AppendInt(dst, int64(tv), 10), nil
+	case int16:
+		return strconv.AppendInt(dst, int64(tv), 10), nil
+	case int32:
+		return strconv.AppendInt(dst, int64(tv), 10), nil
+	case int64:
+		return strconv.AppendInt(dst, tv, 10), nil
+	case uint:
+		return strconv.AppendUint(dst, uint64(tv), 10), nil
+	case uint8:
+		return strconv.AppendUint(dst, uint64(tv), 10), nil
+	case uint16:
+		return strconv.AppendUint(dst, uint64(tv), 10), nil
+	case uint32:
+		return strconv.AppendUint(dst, uint64(tv), 10), nil
+	case uint64:
+		return strconv.AppendUint(dst, tv, 10), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, data...), nil
+	}
+}
+
+// appendFloat formats a float the same way encoding/json does
+func appendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, errors.Errorf("unsupported value: %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+
+	return dst, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends s as a quoted JSON string, escaping the same
+// characters as encoding/json (including HTML characters)
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,139 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	t.Log("Testing writeMetrics")
+
+	t.Log("empty")
+	{
+		var buf bytes.Buffer
+		if err := writeMetrics(&buf, &cgm.Metrics{}); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if buf.String() != "{}" {
+			t.Fatalf("expected {}, got (%s)", buf.String())
+		}
+	}
+
+	t.Log("matches encoding/json")
+	{
+		m := cgm.Metrics{
+			"str":                        {Type: "s", Value: "a \"quoted\" <value> & \\ \n\t\b\f\x01 \u2028 \xff"},
+			"bool":                       {Type: "s", Value: true},
+			"nil":                        {Type: "n", Value: nil},
+			"f64":                        {Type: "n", Value: 1.5},
+			"f64_small":                  {Type: "n", Value: 0.000000123},
+			"f64_large":                  {Type: "n", Value: 1e22},
+			"f64_zero":                   {Type: "n", Value: float64(0)},
+			"f32":                        {Type: "n", Value: float32(3.14)},
+			"int":                        {Type: "i", Value: -42},
+			"int64":                      {Type: "l", Value: int64(math.MinInt64)},
+			"uint64":                     {Type: "L", Value: uint64(math.MaxUint64)},
+			"uint8":                      {Type: "I", Value: uint8(7)},
+			"hist":                       {Type: "h", Value: []string{"H[1.0e+00]=1"}},
+			"foo|ST[a:b,c:\"d\"]":        {Type: "L", Value: uint32(1)},
+			"ünïcödé":                    {Type: "s", Value: "日本"},
+			"html<script>&amp;</script>": {Type: "s", Value: "x"},
+		}
+
+		var buf bytes.Buffer
+		if err := writeMetrics(&buf, &m); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		expect, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if buf.String() != string(expect) {
+			t.Fatalf("expected\n%s\ngot\n%s", string(expect), buf.String())
+		}
+	}
+
+	t.Log("timestamped")
+	{
+		m := cgm.Metrics{
+			"a": {Type: "L", Value: sample.Stamp(uint64(1), 1590000000123)},
+			"b": {Type: "n", Value: 1.5},
+		}
+		var buf bytes.Buffer
+		if err := writeMetrics(&buf, &m); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		expect := `{"a":{"_type":"L","_value":1,"_ts":1590000000123},"b":{"_type":"n","_value":1.5}}`
+		if buf.String() != expect {
+			t.Fatalf("expected\n%s\ngot\n%s", expect, buf.String())
+		}
+	}
+
+	t.Log("large (chunked writes)")
+	{
+		m := testMetrics(5000)
+		var buf bytes.Buffer
+		if err := writeMetrics(&buf, m); err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		expect, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if buf.String() != string(expect) {
+			t.Fatal("expected output to match encoding/json")
+		}
+	}
+
+	t.Log("invalid value (NaN)")
+	{
+		m := cgm.Metrics{"nan": {Type: "n", Value: math.NaN()}}
+		var buf bytes.Buffer
+		if err := writeMetrics(&buf, &m); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func benchMetrics(n int) *cgm.Metrics {
+	m := cgm.Metrics{}
+	for i := 0; i < n; i++ {
+		m[fmt.Sprintf("cpu`core%d|ST[host:web%03d,role:frontend]", i, i%100)] = cgm.Metric{Type: "n", Value: float64(i) * 1.5}
+	}
+	return &m
+}
+
+func BenchmarkWriteMetrics(b *testing.B) {
+	m := benchMetrics(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := getBuffer()
+		if err := writeMetrics(buf, m); err != nil {
+			b.Fatal(err)
+		}
+		putBuffer(buf)
+	}
+}
+
+func BenchmarkJSONMarshalMetrics(b *testing.B) {
+	m := benchMetrics(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
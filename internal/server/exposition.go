@@ -0,0 +1,238 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/circonus-labs/circonusllhist"
+)
+
+const (
+	// expositionMediaType is the Content-Type of the prometheus text exposition format
+	expositionMediaType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// expositionQuantiles are the quantiles of a histogram, rendered as a summary
+var expositionQuantiles = []float64{0.5, 0.9, 0.99}
+
+// expositionFamily the samples of a metric family (a metric name) and its type
+type expositionFamily struct {
+	mtype   string
+	samples []string
+}
+
+// promExposition handles GET /metrics, the metrics of the last flush (/run)
+// in the prometheus text exposition format, so the agent can be scraped by
+// prometheus while reporting to circonus. The prometheus output tags are
+// applied, metric names are sanitized, stream tags are rendered as labels.
+// Numeric metrics are gauges, histograms are summaries (quantiles, sum and
+// count), text metrics are not rendered.
+func (s *Server) promExposition(w http.ResponseWriter) {
+	lastMetricsmu.Lock()
+	metrics := lastMetrics.metrics
+	lastMetricsmu.Unlock()
+	metrics = s.outputs.apply(config.OutputPrometheus, metrics)
+
+	w.Header().Set("Content-Type", expositionMediaType)
+	w.WriteHeader(http.StatusOK)
+	if metrics == nil {
+		return
+	}
+
+	families := make(map[string]*expositionFamily)
+	for name, metric := range *metrics {
+		s.addExposition(families, name, metric)
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		f := families[name]
+		sort.Strings(f.samples)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, f.mtype)
+		for _, smpl := range f.samples {
+			buf.WriteString(smpl)
+		}
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		s.logger.Error().Err(err).Msg("writing prometheus exposition")
+	}
+}
+
+// addExposition adds the sample(s) of a metric to its family
+func (s *Server) addExposition(families map[string]*expositionFamily, name string, metric cgm.Metric) {
+	base, tagList, _ := tags.SplitMetricStreamTags(name)
+	family := expositionName(base)
+	labels := expositionLabels(tagList)
+
+	v, ts := sample.Unwrap(metric.Value)
+	if v == nil {
+		return // retired series (tombstone)
+	}
+	stamp := ""
+	if ts > 0 {
+		stamp = " " + strconv.FormatUint(ts, 10)
+	}
+
+	switch metric.Type {
+	case "i", "I", "l", "L", "n", "h":
+		if samples, ok := histogramSamples(v); ok {
+			s.addSummary(families, family, labels, samples)
+			return
+		}
+		sv := fmt.Sprintf("%v", v)
+		if _, err := strconv.ParseFloat(sv, 64); err != nil {
+			s.logger.Debug().Err(err).Str("metric", name).Msg("prometheus exposition, invalid value")
+			return
+		}
+		f := expositionFamilyFor(families, family, "gauge")
+		if f == nil {
+			return
+		}
+		f.samples = append(f.samples, family+expositionLabelSet(labels)+" "+sv+stamp+"\n")
+	default:
+		// no text metrics in the exposition format
+	}
+}
+
+// addSummary adds a histogram as a summary, quantiles are approximated from the histogram bins
+func (s *Server) addSummary(families map[string]*expositionFamily, family string, labels []string, samples []string) {
+	h, err := circonusllhist.NewFromStrings(samples, false)
+	if err != nil {
+		s.logger.Debug().Err(err).Str("metric", family).Msg("prometheus exposition, invalid histogram")
+		return
+	}
+	var count uint64
+	for _, smpl := range samples {
+		if idx := strings.LastIndex(smpl, "="); idx != -1 {
+			n, err := strconv.ParseUint(smpl[idx+1:], 10, 64)
+			if err == nil {
+				count += n
+			}
+		}
+	}
+	qv, err := h.ApproxQuantile(expositionQuantiles)
+	if err != nil {
+		s.logger.Debug().Err(err).Str("metric", family).Msg("prometheus exposition, histogram quantiles")
+		return
+	}
+
+	f := expositionFamilyFor(families, family, "summary")
+	if f == nil {
+		return
+	}
+	for i, q := range expositionQuantiles {
+		ql := append(append([]string{}, labels...), `quantile="`+strconv.FormatFloat(q, 'g', -1, 64)+`"`)
+		f.samples = append(f.samples, family+expositionLabelSet(ql)+" "+strconv.FormatFloat(qv[i], 'g', -1, 64)+"\n")
+	}
+	f.samples = append(f.samples,
+		family+"_sum"+expositionLabelSet(labels)+" "+strconv.FormatFloat(h.ApproxSum(), 'g', -1, 64)+"\n",
+		family+"_count"+expositionLabelSet(labels)+" "+strconv.FormatUint(count, 10)+"\n")
+}
+
+// expositionFamilyFor returns the family of a metric name, nil if the name is
+// already used by a family of a different type
+func expositionFamilyFor(families map[string]*expositionFamily, name, mtype string) *expositionFamily {
+	f, ok := families[name]
+	if !ok {
+		f = &expositionFamily{mtype: mtype}
+		families[name] = f
+	}
+	if f.mtype != mtype {
+		return nil
+	}
+	return f
+}
+
+// histogramSamples returns the encoded samples (H[bin]=count) of a histogram value
+func histogramSamples(v interface{}) ([]string, bool) {
+	switch t := v.(type) {
+	case []string:
+		return t, len(t) > 0
+	case []interface{}:
+		samples := make([]string, 0, len(t))
+		for _, smpl := range t {
+			sv, ok := smpl.(string)
+			if !ok {
+				return nil, false
+			}
+			samples = append(samples, sv)
+		}
+		return samples, len(samples) > 0
+	default:
+		return nil, false
+	}
+}
+
+// expositionName sanitizes a metric name, characters not valid in a
+// prometheus metric name (e.g. the ` separator) are replaced with _
+func expositionName(name string) string {
+	return sanitizeExposition(name, true)
+}
+
+// expositionLabels converts stream tags into label pairs (name="value"),
+// a tag whose sanitized name is already used is dropped
+func expositionLabels(tagList tags.Tags) []string {
+	labels := make([]string, 0, len(tagList))
+	seen := make(map[string]bool, len(tagList))
+	for _, tag := range tagList {
+		name := sanitizeExposition(tag.Category, false)
+		if seen[name] || strings.HasPrefix(name, "__") {
+			continue
+		}
+		seen[name] = true
+		labels = append(labels, name+`="`+escapeLabelValue(tag.Value)+`"`)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func expositionLabelSet(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// sanitizeExposition replaces the characters not valid in a metric name
+// ([a-zA-Z_:][a-zA-Z0-9_:]*) or label name (no :) with _
+func sanitizeExposition(name string, colon bool) string {
+	if name == "" {
+		return "_"
+	}
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c >= '0' && c <= '9' && i > 0:
+		case c == ':' && colon:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
@@ -0,0 +1,104 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+func TestPromExposition(t *testing.T) {
+	t.Log("Testing promExposition")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	s := &Server{logger: zerolog.Nop()}
+
+	defer func() {
+		lastMetricsmu.Lock()
+		lastMetrics.metrics = nil
+		lastMetricsmu.Unlock()
+	}()
+
+	t.Log("\tno metrics")
+	{
+		lastMetricsmu.Lock()
+		lastMetrics.metrics = nil
+		lastMetricsmu.Unlock()
+
+		w := httptest.NewRecorder()
+		s.promExposition(w)
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		if len(body) != 0 {
+			t.Fatalf("expected empty body, got (%s)", body)
+		}
+	}
+
+	t.Log("\tw/metrics")
+	{
+		lastMetricsmu.Lock()
+		lastMetrics.ts = time.Now()
+		lastMetrics.metrics = &cgm.Metrics{
+			"cpu`idle|ST[cpu:0,units:percent]":                cgm.Metric{Type: "n", Value: 97.5},
+			"cpu`idle|ST[cpu:1,units:percent]":                cgm.Metric{Type: "n", Value: 42},
+			`disk.io|ST[b"ZGV2aWNlLW5hbWU=":b"c2RhICJvcyI="]`: cgm.Metric{Type: "L", Value: uint64(18446744073709551615)},
+			"latency":         cgm.Metric{Type: "h", Value: []string{"H[1.0e+00]=2", "H[2.0e+00]=2"}},
+			"stamped":         cgm.Metric{Type: "I", Value: sample.Stamp(uint32(5), 1590000000123)},
+			"version":         cgm.Metric{Type: "s", Value: "1.0.0"},
+			"retired|ST[a:b]": cgm.Metric{Type: "L", Value: nil},
+		}
+		lastMetricsmu.Unlock()
+
+		w := httptest.NewRecorder()
+		s.promExposition(w)
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != expositionMediaType {
+			t.Fatalf("unexpected content type (%s)", ct)
+		}
+
+		expect := []string{
+			"# TYPE cpu_idle gauge\n",
+			`cpu_idle{cpu="0",units="percent"} 97.5` + "\n",
+			`cpu_idle{cpu="1",units="percent"} 42` + "\n",
+			"# TYPE disk_io gauge\n",
+			`disk_io{device_name="sda \"os\""} 18446744073709551615` + "\n",
+			"# TYPE latency summary\n",
+			`latency{quantile="0.5"} `,
+			"latency_count 4\n",
+			"latency_sum ",
+			"stamped 5 1590000000123\n",
+		}
+		for _, e := range expect {
+			if !strings.Contains(string(body), e) {
+				t.Fatalf("expected (%s) got (%s)", e, body)
+			}
+		}
+		for _, ne := range []string{"version", "retired"} {
+			if strings.Contains(string(body), ne) {
+				t.Fatalf("unexpected (%s) in (%s)", ne, body)
+			}
+		}
+	}
+}
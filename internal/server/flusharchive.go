@@ -0,0 +1,226 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	flushFilePrefix = "flush_"
+	flushFileSuffix = ".json"
+)
+
+// flushArchive keeps the most recent flushes (limited by count and/or age) so
+// the metrics sent at a given time can be retrieved from /debug/flushes, when
+// a directory is configured the flushes are also persisted across restarts
+type flushArchive struct {
+	count   int
+	maxAge  time.Duration
+	dir     string
+	flushes []archivedFlush // oldest first
+	logger  zerolog.Logger
+	sync.Mutex
+}
+
+type archivedFlush struct {
+	ts         time.Time
+	numMetrics int
+	data       []byte // metrics as sent (json)
+}
+
+// flushSummary describes an archived flush in the /debug/flushes list
+type flushSummary struct {
+	Timestamp  time.Time `json:"timestamp"`
+	NumMetrics int       `json:"num_metrics"`
+	Bytes      int       `json:"bytes"`
+}
+
+// newFlushArchive returns nil if flushes are not archived (no count or age limit)
+func newFlushArchive(count int, maxAge time.Duration, dir string, logger zerolog.Logger) (*flushArchive, error) {
+	if count <= 0 && maxAge <= 0 {
+		return nil, nil
+	}
+
+	fa := &flushArchive{
+		count:  count,
+		maxAge: maxAge,
+		dir:    dir,
+		logger: logger.With().Str("pkg", "flush-archive").Logger(),
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return nil, errors.Wrap(err, "flush archive dir")
+		}
+		if err := fa.load(); err != nil {
+			return nil, errors.Wrap(err, "loading flush archive")
+		}
+		fa.prune(time.Now())
+	}
+
+	return fa, nil
+}
+
+// add archives the metrics of a flush
+func (fa *flushArchive) add(metrics *cgm.Metrics, ts time.Time) {
+	if fa == nil || metrics == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, metrics); err != nil {
+		fa.logger.Warn().Err(err).Msg("encoding flush")
+		return
+	}
+
+	f := archivedFlush{ts: ts, numMetrics: len(*metrics), data: buf.Bytes()}
+
+	fa.Lock()
+	defer fa.Unlock()
+
+	if fa.dir != "" {
+		if err := fa.save(f); err != nil {
+			fa.logger.Warn().Err(err).Msg("persisting flush")
+		}
+	}
+
+	fa.flushes = append(fa.flushes, f)
+	fa.prune(ts)
+}
+
+// list returns a summary of the archived flushes, oldest first
+func (fa *flushArchive) list(now time.Time) []flushSummary {
+	summary := []flushSummary{}
+	if fa == nil {
+		return summary
+	}
+
+	fa.Lock()
+	defer fa.Unlock()
+
+	fa.prune(now)
+	for _, f := range fa.flushes {
+		summary = append(summary, flushSummary{Timestamp: f.ts, NumMetrics: f.numMetrics, Bytes: len(f.data)})
+	}
+	return summary
+}
+
+// at returns the flush in effect at t (the most recent flush at or before t)
+func (fa *flushArchive) at(t time.Time) (archivedFlush, bool) {
+	if fa == nil {
+		return archivedFlush{}, false
+	}
+
+	fa.Lock()
+	defer fa.Unlock()
+
+	fa.prune(time.Now())
+	i := sort.Search(len(fa.flushes), func(i int) bool { return fa.flushes[i].ts.After(t) })
+	if i == 0 {
+		return archivedFlush{}, false
+	}
+	return fa.flushes[i-1], true
+}
+
+// prune drops flushes over the count or older than the max age, must be called with the lock held
+func (fa *flushArchive) prune(now time.Time) {
+	drop := 0
+	if fa.count > 0 && len(fa.flushes) > fa.count {
+		drop = len(fa.flushes) - fa.count
+	}
+	if fa.maxAge > 0 {
+		for drop < len(fa.flushes) && now.Sub(fa.flushes[drop].ts) > fa.maxAge {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return
+	}
+
+	if fa.dir != "" {
+		for _, f := range fa.flushes[:drop] {
+			if err := os.Remove(fa.file(f.ts)); err != nil && !os.IsNotExist(err) {
+				fa.logger.Warn().Err(err).Msg("removing archived flush")
+			}
+		}
+	}
+
+	fa.flushes = append(fa.flushes[:0], fa.flushes[drop:]...)
+}
+
+// file returns the persisted file name of a flush
+func (fa *flushArchive) file(ts time.Time) string {
+	return filepath.Join(fa.dir, flushFilePrefix+strconv.FormatInt(ts.UnixNano(), 10)+flushFileSuffix)
+}
+
+// save persists a flush (write to temp file, rename)
+func (fa *flushArchive) save(f archivedFlush) error {
+	tmp, err := ioutil.TempFile(fa.dir, "tmp_"+flushFilePrefix)
+	if err != nil {
+		return errors.Wrap(err, "creating temp file")
+	}
+	if _, err := tmp.Write(f.data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "writing flush")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "closing flush")
+	}
+	if err := os.Rename(tmp.Name(), fa.file(f.ts)); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "renaming flush")
+	}
+	return nil
+}
+
+// load reads the flushes persisted in the archive directory
+func (fa *flushArchive) load() error {
+	entries, err := ioutil.ReadDir(fa.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, flushFilePrefix) || !strings.HasSuffix(name, flushFileSuffix) {
+			continue
+		}
+		ns, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, flushFilePrefix), flushFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(fa.dir, name))
+		if err != nil {
+			fa.logger.Warn().Err(err).Str("file", name).Msg("reading archived flush")
+			continue
+		}
+		var m cgm.Metrics
+		if err := json.Unmarshal(data, &m); err != nil {
+			fa.logger.Warn().Err(err).Str("file", name).Msg("parsing archived flush, ignoring")
+			continue
+		}
+		fa.flushes = append(fa.flushes, archivedFlush{ts: time.Unix(0, ns), numMetrics: len(m), data: data})
+	}
+
+	sort.Slice(fa.flushes, func(i, j int) bool { return fa.flushes[i].ts.Before(fa.flushes[j].ts) })
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+func TestFlushArchive(t *testing.T) {
+	t.Log("Testing flushArchive")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tdisabled")
+	{
+		fa, err := newFlushArchive(0, 0, "", zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if fa != nil {
+			t.Fatal("expected nil")
+		}
+		fa.add(&cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}, time.Now())
+		if l := fa.list(time.Now()); len(l) != 0 {
+			t.Fatalf("expected empty list, got %v", l)
+		}
+		if _, ok := fa.at(time.Now()); ok {
+			t.Fatal("expected no flush")
+		}
+	}
+
+	start := time.Now()
+
+	t.Log("\tcount")
+	{
+		fa, err := newFlushArchive(2, 0, "", zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		for i := 0; i < 3; i++ {
+			fa.add(&cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(i)}}, start.Add(time.Duration(i)*time.Minute))
+		}
+		if l := fa.list(start); len(l) != 2 || !l[0].Timestamp.Equal(start.Add(time.Minute)) {
+			t.Fatalf("expected 2 most recent flushes, got %v", l)
+		}
+		f, ok := fa.at(start.Add(90 * time.Second))
+		if !ok || string(f.data) != `{"foo":{"_type":"L","_value":1}}` {
+			t.Fatalf("expected flush at +1m, got %v (%s)", ok, string(f.data))
+		}
+		if _, ok := fa.at(start.Add(30 * time.Second)); ok {
+			t.Fatal("expected no flush before the oldest archived")
+		}
+	}
+
+	t.Log("\tmax age")
+	{
+		fa, err := newFlushArchive(0, 5*time.Minute, "", zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		fa.add(&cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}, start)
+		fa.add(&cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(2)}}, start.Add(3*time.Minute))
+		if l := fa.list(start.Add(6 * time.Minute)); len(l) != 1 || l[0].NumMetrics != 1 {
+			t.Fatalf("expected 1 flush, got %v", l)
+		}
+	}
+
+	t.Log("\tpersisted")
+	{
+		dir, err := ioutil.TempDir("", "flusharchive")
+		if err != nil {
+			t.Fatalf("creating temp dir (%s)", err)
+		}
+		defer os.RemoveAll(dir)
+
+		now := time.Now()
+		fa, err := newFlushArchive(2, 0, dir, zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		for i := 0; i < 3; i++ {
+			fa.add(&cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(i)}}, now.Add(time.Duration(i)*time.Second))
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("reading dir (%s)", err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("expected 2 files, got %d", len(files))
+		}
+
+		fa2, err := newFlushArchive(2, 0, dir, zerolog.Nop())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		f, ok := fa2.at(now.Add(time.Minute))
+		if !ok || string(f.data) != `{"foo":{"_type":"L","_value":2}}` || f.numMetrics != 1 {
+			t.Fatalf("expected most recent flush, got %v (%s)", ok, string(f.data))
+		}
+	}
+}
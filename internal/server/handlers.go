@@ -12,6 +12,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -19,8 +21,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/circonus-labs/circonus-agent/internal/builtins"
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/errs"
+	"github.com/circonus-labs/circonus-agent/internal/merge"
 	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
 	"github.com/circonus-labs/circonus-agent/internal/server/promrecv"
 	"github.com/circonus-labs/circonus-agent/internal/server/receiver"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
@@ -29,9 +35,49 @@ import (
 	"github.com/spf13/viper"
 )
 
+// maxPooledBuffer buffers which grew beyond this size are not returned to the pool
+const maxPooledBuffer = 64 * 1024 * 1024
+
+var (
+	bufferPool     = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	gzipWriterPool sync.Pool
+)
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns a buffer to the pool, unless it is too large to retain
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// getGzipWriter returns a pooled gzip writer reset to write to w
+func getGzipWriter(w io.Writer) *gzip.Writer {
+	if gz, ok := gzipWriterPool.Get().(*gzip.Writer); ok {
+		gz.Reset(w)
+		return gz
+	}
+	return gzip.NewWriter(w)
+}
+
 // run handles requests to execute plugins and return metrics emitted
 // handles /, /run, or /run/plugin_name
+// concurrent requests for the same item share a single collection pass
+// requests for a metric route (see --check-routes) receive the routed metrics,
+// metrics of full collections are held until their route requests metrics
 func (s *Server) run(w http.ResponseWriter, r *http.Request) {
+	if token := r.URL.Query().Get(continuationParam); token != "" {
+		s.runContinuation(w, r, token)
+		return
+	}
+
 	id := ""
 
 	if strings.HasPrefix(r.URL.Path, "/run/") { // run specific item
@@ -65,17 +111,70 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	route := requestedRoute(r)
+	if !s.routes.valid(route) {
+		s.logger.Warn().
+			Str("route", route).
+			Msg("unknown metric route requested")
+		http.NotFound(w, r)
+		return
+	}
+
+	runStart := time.Now()
+	key := id
+	if route != "" {
+		key += "?" + routeParam + "=" + route
+	}
+	v, _, shared := s.runGroup.Do(key, func() (interface{}, error) {
+		metrics := s.collect(id, traceFromContext(r.Context()))
+		if id != "" {
+			// single item runs are not held for (or taken from) the routes
+			return s.routes.routed(metrics, route), nil
+		}
+		return s.routes.deliver(metrics, route), nil
+	})
+	metrics := v.(*cgm.Metrics)
+	if shared {
+		_ = appstats.IncrementInt("requests_shared")
+		s.logger.Debug().Str("id", id).Str("route", route).Msg("sharing collection with concurrent request(s)")
+	}
+
+	if id == "" && route == "" && s.delta.accepts(r) {
+		s.encodeDeltaResponse(metrics, w, r, runStart)
+		return
+	}
+
+	page, token, remaining, err := s.pager.split(metrics)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("paginating metrics, sending full response")
+		page = metrics
+		token = ""
+	}
+	if token != "" {
+		w.Header().Set(continuationHeader, token)
+		w.Header().Set(pagesRemainingHeader, strconv.Itoa(remaining))
+		s.logger.Debug().Int("num_metrics", len(*metrics)).Int("page_metrics", len(*page)).Int("pages_remaining", remaining).Msg("response paginated")
+	}
+
+	s.encodeResponse(page, w, r, runStart)
+}
+
+// collect runs/flushes the requested conduits and aggregates the metrics, concurrent
+// requests for the same id share a single collection (see run)
+func (s *Server) collect(id string, rt *requestTrace) *cgm.Metrics {
 	type conduit struct {
 		id      string
 		metrics *cgm.Metrics
 	}
-	conduitCh := make(chan conduit, 5) // number of conduits
+	conduitCh := make(chan conduit, 7) // number of conduits
 	// default conduits to true if id is blank, otherwise set all to false
 	runBuiltins := id == ""
 	runPlugins := id == ""
 	flushProm := id == ""
 	flushReceiver := id == ""
 	flushStatsd := id == ""
+	flushOTLP := id == ""
+	flushGraphite := id == ""
 
 	if id != "" {
 		// identify conduit to collect from based on id passed
@@ -86,6 +185,10 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 			flushReceiver = true
 		case id == "statsd":
 			flushStatsd = true
+		case id == "otlp":
+			flushOTLP = true
+		case id == "graphite":
+			flushGraphite = true
 		case s.builtins.IsBuiltin(id):
 			runBuiltins = true
 		default:
@@ -104,6 +207,7 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 			numMetrics := 0
 			s.logger.Debug().Str("conduit_id", conduitID).Msg("start")
 			if err := s.builtins.Run(s.groupCtx, id); err != nil {
+				errs.Record(errs.NewCollector(err))
 				s.logger.Error().Err(err).Str("id", id).Msg("running builtin")
 			}
 			builtinMetrics := s.builtins.Flush(id)
@@ -112,6 +216,7 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 				conduitCh <- conduit{id: conduitID, metrics: builtinMetrics}
 			}
 			s.logger.Debug().Str("conduit_id", conduitID).Str("duration", time.Since(start).String()).Int("metrics", numMetrics).Msg("done")
+			rt.record(conduitID, start, numMetrics)
 			wg.Done()
 		}()
 	}
@@ -127,6 +232,7 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 			numMetrics := 0
 			s.logger.Debug().Str("conduit_id", conduitID).Msg("start")
 			if err := s.plugins.Run(id); err != nil {
+				errs.Record(errs.NewPlugin(err))
 				s.logger.Error().Err(err).Str("id", id).Msg("running plugin")
 			}
 			pluginMetrics := s.plugins.Flush(id)
@@ -135,6 +241,7 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 				conduitCh <- conduit{id: conduitID, metrics: pluginMetrics}
 			}
 			s.logger.Debug().Str("conduit_id", conduitID).Str("duration", time.Since(start).String()).Int("metrics", numMetrics).Msg("done")
+			rt.record(conduitID, start, numMetrics)
 			wg.Done()
 		}()
 	}
@@ -152,6 +259,7 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 				conduitCh <- conduit{id: conduitID, metrics: receiverMetrics}
 			}
 			s.logger.Debug().Str("conduit_id", conduitID).Str("duration", time.Since(start).String()).Int("metrics", numMetrics).Msg("done")
+			rt.record(conduitID, start, numMetrics)
 			wg.Done()
 		}()
 	}
@@ -170,11 +278,48 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 					conduitCh <- conduit{id: conduitID, metrics: statsdMetrics}
 				}
 				s.logger.Debug().Str("conduit_id", conduitID).Str("duration", time.Since(start).String()).Int("metrics", numMetrics).Msg("done")
+				rt.record(conduitID, start, numMetrics)
 				wg.Done()
 			}()
 		}
 	}
 
+	if flushOTLP && s.otlpSvr.Enabled() {
+		wg.Add(1)
+		go func() {
+			start := time.Now()
+			conduitID := "otlp"
+			numMetrics := 0
+			s.logger.Debug().Str("conduit_id", conduitID).Msg("start")
+			otlpMetrics := s.otlpSvr.Flush()
+			if otlpMetrics != nil && len(*otlpMetrics) > 0 {
+				numMetrics = len(*otlpMetrics)
+				conduitCh <- conduit{id: conduitID, metrics: otlpMetrics}
+			}
+			s.logger.Debug().Str("conduit_id", conduitID).Str("duration", time.Since(start).String()).Int("metrics", numMetrics).Msg("done")
+			rt.record(conduitID, start, numMetrics)
+			wg.Done()
+		}()
+	}
+
+	if flushGraphite && s.graphSvr.Enabled() {
+		wg.Add(1)
+		go func() {
+			start := time.Now()
+			conduitID := "graphite"
+			numMetrics := 0
+			s.logger.Debug().Str("conduit_id", conduitID).Msg("start")
+			graphiteMetrics := s.graphSvr.Flush()
+			if graphiteMetrics != nil && len(*graphiteMetrics) > 0 {
+				numMetrics = len(*graphiteMetrics)
+				conduitCh <- conduit{id: conduitID, metrics: graphiteMetrics}
+			}
+			s.logger.Debug().Str("conduit_id", conduitID).Str("duration", time.Since(start).String()).Int("metrics", numMetrics).Msg("done")
+			rt.record(conduitID, start, numMetrics)
+			wg.Done()
+		}()
+	}
+
 	if flushProm {
 		wg.Add(1)
 		go func() {
@@ -188,6 +333,7 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 				conduitCh <- conduit{id: conduitID, metrics: promMetrics}
 			}
 			s.logger.Debug().Str("conduit_id", conduitID).Str("duration", time.Since(start).String()).Int("metrics", numMetrics).Msg("done")
+			rt.record(conduitID, start, numMetrics)
 			wg.Done()
 		}()
 	}
@@ -199,10 +345,23 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 	s.logger.Debug().Str("duration", time.Since(runStart).String()).Msg("collection complete")
 
 	// s.logger.Debug().Msg("aggregating metrics")
-	metrics := cgm.Metrics{}
+	results := make(map[string]*cgm.Metrics, len(conduitOrder))
 	for cm := range conduitCh {
-		for m, v := range *cm.metrics {
-			metrics[m] = v
+		results[cm.id] = cm.metrics
+		s.sources.seen(cm.id, runStart)
+		s.retirement.seen(cm.id, cm.metrics, runStart)
+	}
+	metrics := cgm.Metrics{}
+	policy := viper.GetString(config.KeyMetricMerge)
+	conflicts := uint64(0)
+	for _, id := range conduitOrder {
+		cm, ok := results[id]
+		if !ok {
+			continue
+		}
+		conflicts += mergeMetrics(metrics, cm, id, policy, s.logger)
+		if id == "builtins" {
+			s.builtins.Release(cm)
 		}
 	}
 	{
@@ -213,7 +372,41 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		metrics[tags.MetricNameWithStreamTags("circonus_agent", tags.FromList(mtags))] = cgm.Metric{Value: release.NAME + "_" + release.VERSION, Type: "s"}
+		if policy == merge.Reject {
+			metrics[tags.MetricNameWithStreamTags(merge.ConflictMetric, tags.FromList(mtags))] = cgm.Metric{Value: conflicts, Type: "L"}
+		}
+	}
+	if conflicts > 0 {
+		_ = appstats.AddInt("merge_conflicts", int64(conflicts))
+		s.logger.Warn().Uint64("conflicts", conflicts).Msg("metrics emitted by more than one source rejected")
+	}
+
+	s.failover.Apply(&metrics)
+	s.audit.Apply(&metrics)
+	if id == "" {
+		s.heartbeat.Apply(&metrics)
+		s.maintain.apply(&metrics, time.Now())
+		if s.sources != nil {
+			sources := make([]string, 0, len(conduitOrder))
+			for _, source := range conduitOrder {
+				if source == "statsd" && s.statsdSvr == nil {
+					continue
+				}
+				if source == "otlp" && !s.otlpSvr.Enabled() {
+					continue
+				}
+				if source == "graphite" && !s.graphSvr.Enabled() {
+					continue
+				}
+				sources = append(sources, source)
+			}
+			s.sources.apply(&metrics, sources, time.Now())
+		}
+		if retired := s.retirement.apply(&metrics, time.Now()); retired > 0 {
+			s.logger.Info().Int("series", retired).Msg("retired series not reported within metric ttl")
+		}
 	}
+
 	s.logger.Debug().Int("num_metrics", len(metrics)).Msg("aggregated")
 
 	lastMetricsmu.Lock()
@@ -221,11 +414,44 @@ func (s *Server) run(w http.ResponseWriter, r *http.Request) {
 	lastMetrics.ts = time.Now()
 	lastMetricsmu.Unlock()
 
-	if err := s.check.EnableNewMetrics(&metrics); err != nil {
+	// the check output is named per the output tags, the last metrics (the
+	// prometheus outputs) and hooks see the metrics as collected
+	checkMetrics := s.outputs.apply(config.OutputCheck, &metrics)
+
+	if err := s.check.EnableNewMetrics(s.routes.unrouted(checkMetrics)); err != nil {
 		s.logger.Warn().Err(err).Msg("unable to update check bundle metrics")
 	}
 
-	s.encodeResponse(&metrics, w, r, runStart)
+	s.hooks.Evaluate(&metrics)
+
+	if id == "" {
+		// constant text metrics are only submitted on change (or resend interval), full runs only
+		sent := s.textMetric.filter(checkMetrics, time.Now())
+		s.flushes.add(sent, time.Now())
+		return sent
+	}
+
+	return checkMetrics
+}
+
+// runContinuation responds with the next page of a paginated /run response
+func (s *Server) runContinuation(w http.ResponseWriter, r *http.Request, token string) {
+	start := time.Now()
+
+	page, nextToken, remaining, err := s.pager.next(token)
+	if err != nil {
+		_ = appstats.IncrementInt("requests_bad")
+		s.logger.Warn().Err(err).Str("url", r.URL.String()).Msg("continuation")
+		http.NotFound(w, r)
+		return
+	}
+
+	if nextToken != "" {
+		w.Header().Set(continuationHeader, nextToken)
+		w.Header().Set(pagesRemainingHeader, strconv.Itoa(remaining))
+	}
+
+	s.encodeResponse(page, w, r, start)
 }
 
 // encodeResponse takes care of encoding the response to an HTTP request for metrics.
@@ -243,9 +469,59 @@ func (s *Server) encodeResponse(m *cgm.Metrics, w http.ResponseWriter, r *http.R
 	w.Header().Set("Transfer-Encoding", "identity")
 	w.Header().Set("Content-Type", "application/json")
 
-	var data []byte
 	var jsonData []byte
-	var err error
+
+	// metrics are streamed into a pooled buffer rather than marshaled, large
+	// responses would otherwise allocate on every request, agents emitting
+	// 100k+ series drive frequent gc cycles
+	jsonBuf := getBuffer()
+	defer putBuffer(jsonBuf)
+	if err := writeMetrics(jsonBuf, m); err != nil {
+		// log the error and respond with empty metrics
+		s.logger.Error().
+			Err(err).
+			Interface("metrics", m).
+			Msg("encoding metrics to JSON for response")
+		jsonData = []byte("{}")
+	} else {
+		jsonData = jsonBuf.Bytes()
+	}
+
+	s.writeResponse(jsonData, len(*m), w, r, runStart)
+
+	dumpDir := viper.GetString(config.KeyDebugDumpMetrics)
+	if dumpDir != "" {
+		dumpFile := filepath.Join(dumpDir, "metrics_"+time.Now().Format("20060102_150405")+".json")
+		if err := ioutil.WriteFile(dumpFile, jsonData, 0644); err != nil { //nolint:gosec
+			s.logger.Error().
+				Err(err).
+				Str("file", dumpFile).
+				Msg("dumping metrics")
+		}
+	}
+}
+
+// encodeDeltaResponse responds with the delta encoding of metrics (see deltaEncoder),
+// if an error occurs it is logged and a regular response is sent
+func (s *Server) encodeDeltaResponse(m *cgm.Metrics, w http.ResponseWriter, r *http.Request, runStart time.Time) {
+	data, seq, err := s.delta.encode(m, r)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("delta encoding metrics, sending full response")
+		s.encodeResponse(m, w, r, runStart)
+		return
+	}
+
+	w.Header().Set("Transfer-Encoding", "identity")
+	w.Header().Set("Content-Type", deltaMediaType)
+	w.Header().Set(deltaSeqHeader, strconv.FormatUint(seq, 10))
+
+	s.writeResponse(data, len(*m), w, r, runStart)
+}
+
+// writeResponse writes an encoded metrics response, compressed when the request
+// accepts gzip and --no-gzip is not set. If compression fails, it is logged and
+// empty {} metrics are returned.
+func (s *Server) writeResponse(jsonData []byte, numMetrics int, w http.ResponseWriter, r *http.Request, runStart time.Time) {
 	var useGzip bool
 
 	if viper.GetBool(config.KeyDisableGzip) {
@@ -255,22 +531,17 @@ func (s *Server) encodeResponse(m *cgm.Metrics, w http.ResponseWriter, r *http.R
 		useGzip = strings.Contains(acceptedEncodings, "*") || strings.Contains(acceptedEncodings, "gzip")
 	}
 
-	jsonData, err = json.Marshal(m)
-	if err != nil {
-		// log the error and respond with empty metrics
-		s.logger.Error().
-			Err(err).
-			Interface("metrics", m).
-			Msg("encoding metrics to JSON for response")
-		jsonData = []byte("{}")
-	}
-	data = jsonData
+	data := jsonData
 
 	if useGzip {
-		var buf bytes.Buffer
-		gz := gzip.NewWriter(&buf)
+		gzBuf := getBuffer()
+		defer putBuffer(gzBuf)
+		gz := getGzipWriter(gzBuf)
 		_, err := gz.Write(jsonData)
-		gz.Close()
+		if cerr := gz.Close(); err == nil {
+			err = cerr
+		}
+		gzipWriterPool.Put(gz)
 		if err != nil {
 			// log the error and respond with empty metrics
 			s.logger.Error().
@@ -279,7 +550,7 @@ func (s *Server) encodeResponse(m *cgm.Metrics, w http.ResponseWriter, r *http.R
 			data = []byte("{}")
 		} else {
 			w.Header().Set("Content-Encoding", "gzip")
-			data = buf.Bytes()
+			data = gzBuf.Bytes()
 		}
 	}
 
@@ -291,18 +562,52 @@ func (s *Server) encodeResponse(m *cgm.Metrics, w http.ResponseWriter, r *http.R
 		return
 	}
 
-	s.logger.Info().Str("duration", time.Since(runStart).String()).Int("num_metrics", len(*m)).Bool("compressed", useGzip).Int("content_bytes", len(data)).Msg("request response")
+	s.logger.Info().Str("duration", time.Since(runStart).String()).Int("num_metrics", numMetrics).Bool("compressed", useGzip).Int("content_bytes", len(data)).Msg("request response")
+}
 
-	dumpDir := viper.GetString(config.KeyDebugDumpMetrics)
-	if dumpDir != "" {
-		dumpFile := filepath.Join(dumpDir, "metrics_"+time.Now().Format("20060102_150405")+".json")
-		if err := ioutil.WriteFile(dumpFile, jsonData, 0644); err != nil { //nolint:gosec
-			s.logger.Error().
-				Err(err).
-				Str("file", dumpFile).
-				Msg("dumping metrics")
+// health responds with "Alive", or if json is requested (Accept: application/json)
+// the status and recent errors by category (config, transient-network, broker, collector, plugin).
+// When a mandatory source is stale, responds with "Degraded" (503) and the stale sources.
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	stale := s.sources.stale(time.Now())
+	status := http.StatusOK
+	if len(stale) > 0 {
+		status = http.StatusServiceUnavailable
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.WriteHeader(status)
+		if len(stale) > 0 {
+			_, _ = fmt.Fprintln(w, "Degraded")
+			return
 		}
+		_, _ = fmt.Fprintln(w, "Alive")
+		return
+	}
+
+	resp := struct {
+		Status       string                      `json:"status"`
+		StaleSources []string                    `json:"stale_sources,omitempty"`
+		Errors       map[errs.Category]errs.Stat `json:"errors"`
+	}{
+		Status:       "alive",
+		StaleSources: stale,
+		Errors:       errs.Stats(),
+	}
+	if len(stale) > 0 {
+		resp.Status = "degraded"
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("encoding health")
+		http.Error(w, "encoding health", http.StatusInternalServerError)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
 }
 
 // inventory returns the current, active plugin inventory
@@ -317,9 +622,126 @@ func (s *Server) inventory(w http.ResponseWriter) {
 	_, _ = w.Write(inventory)
 }
 
+// collectors returns the status of the builtin collectors
+func (s *Server) collectors(w http.ResponseWriter) {
+	status := []builtins.CollectorStatus{}
+	if s.builtins != nil {
+		status = s.builtins.Collectors()
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("encoding collectors")
+		http.Error(w, "encoding collectors", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// setCollectorState enables or disables a builtin collector, only accepted
+// from the local host (loopback or unix socket)
+func (s *Server) setCollectorState(w http.ResponseWriter, r *http.Request) {
+	if !isLocalRequest(r) {
+		s.logger.Warn().Str("remote", r.RemoteAddr).Str("url", r.URL.String()).Msg("collector state change not from local host")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	m := collectorRx.FindStringSubmatch(r.URL.Path)
+	if m == nil || s.builtins == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := s.builtins.SetEnabled(m[1], m[2] == "enable"); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// debugFlushes lists the archived flushes, or with ?at=<time> (RFC3339 or unix
+// epoch seconds) responds with the metrics sent by the flush in effect at that time
+func (s *Server) debugFlushes(w http.ResponseWriter, r *http.Request) {
+	if s.flushes == nil {
+		http.Error(w, "flush archive not enabled", http.StatusNotFound)
+		return
+	}
+
+	at := r.URL.Query().Get("at")
+	if at == "" {
+		data, err := json.Marshal(s.flushes.list(time.Now()))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("encoding flush list")
+			http.Error(w, "encoding flush list", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+		return
+	}
+
+	ts, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		secs, perr := strconv.ParseInt(at, 10, 64)
+		if perr != nil {
+			http.Error(w, "invalid time, use RFC3339 or unix epoch seconds", http.StatusBadRequest)
+			return
+		}
+		ts = time.Unix(secs, 0)
+	}
+
+	f, ok := s.flushes.at(ts)
+	if !ok {
+		http.Error(w, "no flush archived at "+at, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Flush-Timestamp", f.ts.Format(time.RFC3339Nano))
+	_, _ = w.Write(f.data)
+}
+
+// proxyExporter responds with the metrics of a local exporter, handles /proxy/<name>
+func (s *Server) proxyExporter(w http.ResponseWriter, r *http.Request) {
+	m := proxyPathRx.FindStringSubmatch(r.URL.Path)
+	if m == nil || !s.proxy.has(m[1]) {
+		_ = appstats.IncrementInt("requests_bad")
+		s.logger.Warn().Str("url", r.URL.String()).Msg("unknown proxy target requested")
+		http.NotFound(w, r)
+		return
+	}
+
+	start := time.Now()
+	metrics, err := s.proxy.fetch(r.Context(), m[1])
+	if err != nil {
+		s.logger.Warn().Err(err).Str("target", m[1]).Msg("proxy")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	s.encodeResponse(metrics, w, r, start)
+}
+
+// isLocalRequest determines if a request was received from the local host
+func isLocalRequest(r *http.Request) bool {
+	if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+		return true // unix socket
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // socketHandler gates /write for the socket server only
 func (s *Server) socketHandler(w http.ResponseWriter, r *http.Request) {
-	if !writePathRx.MatchString(r.URL.Path) {
+	if !writePathRx.MatchString(r.URL.Path) && !dbWritePathRx.MatchString(r.URL.Path) {
 		_ = appstats.IncrementInt("requests_bad")
 		s.logger.Warn().
 			Str("method", r.Method).
@@ -346,9 +768,22 @@ func (s *Server) socketHandler(w http.ResponseWriter, r *http.Request) {
 // metrics. No validation is applied to the "format" of the metrics beyond k/v.
 // Where 'key' is the metric name and 'value' is the metric value as either a
 // simple value (e.g. {"name": 1, "foo": "bar", ...}) or a structured value
-// representation (e.g. {"foo": {_type: "i", _value: 1}, ...}).
+// representation (e.g. {"foo": {_type: "i", _value: 1}, ...}). A protobuf
+// payload (Content-Type: application/x-protobuf, etc/receiver.proto) is
+// accepted for high frequency local producers. An InfluxDB line protocol
+// payload (Content-Type: text/plain) is accepted from telegraf and other
+// influxdb clients, which write to /write?db=ID (the influxdb v1 write api).
+// A request with an Idempotency-Key header is applied once, a retry with the
+// same key within --write-dedup-ttl is acknowledged without being applied
+// (or rejected with 409 while the first request is still being applied).
 func (s *Server) write(w http.ResponseWriter, r *http.Request) {
 	id := strings.Replace(r.URL.Path, "/write/", "", -1)
+	if dbWritePathRx.MatchString(r.URL.Path) {
+		id = r.URL.Query().Get("db")
+		if !writePathRx.MatchString("/write/" + id) {
+			id = ""
+		}
+	}
 	// a write request *MUST* include a metric group id to act as a namespace.
 	// in other words, a "plugin name", all metrics for that write will appear
 	// _under_ the metric group id (aka plugin name)
@@ -358,11 +793,60 @@ func (s *Server) write(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := receiver.Parse(id, r.Body); err != nil {
+	var dedupKey string
+	if key := r.Header.Get(idempotencyKeyHeader); key != "" && s.writeDedup != nil {
+		if len(key) > maxIdempotencyKeyLen {
+			s.logger.Warn().Str("id", id).Msg("write recevier - idempotency key too long")
+			http.Error(w, fmt.Sprintf("%s longer than %d", idempotencyKeyHeader, maxIdempotencyKeyLen), http.StatusBadRequest)
+			return
+		}
+		dedupKey = id + "\x00" + key // keys are scoped to the metric group id
+	}
+
+	parse := receiver.Parse
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch ct {
+	case receiver.ProtobufMediaType:
+		parse = receiver.ParseProtobuf
+	case receiver.InfluxMediaType:
+		precision := r.URL.Query().Get("precision")
+		parse = func(id string, data io.Reader) error {
+			return receiver.ParseInflux(id, precision, data)
+		}
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("write recevier")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	switch s.writeDedup.begin(dedupKey, time.Now()) {
+	case writeApplied:
+		_ = appstats.IncrementInt("write_duplicates")
+		s.logger.Debug().Str("id", id).Str("key", r.Header.Get(idempotencyKeyHeader)).Msg("write recevier - duplicate, not applied")
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case writeInFlight:
+		s.logger.Debug().Str("id", id).Str("key", r.Header.Get(idempotencyKeyHeader)).Msg("write recevier - duplicate in flight")
+		http.Error(w, fmt.Sprintf("write with the same %s in progress", idempotencyKeyHeader), http.StatusConflict)
+		return
+	}
+
+	if err := parse(id, body); err != nil {
+		s.writeDedup.done(dedupKey, false, time.Now())
 		s.logger.Warn().Err(err).Msg("write recevier")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	s.writeDedup.done(dedupKey, true, time.Now())
 
 	if meta, _ := s.check.CheckMeta(); meta != nil {
 		// we ignore the error here intentionally; one of the modes
@@ -403,6 +887,7 @@ func (s *Server) promOutput(w http.ResponseWriter) {
 	ms := lastMetrics.ts.UnixNano() / int64(time.Millisecond)
 	lastMetricsmu.Unlock()
 	s.logger.Debug().Str("in", "prom output").Msg("unlock lastMetrics")
+	metrics = s.outputs.apply(config.OutputPrometheus, metrics)
 
 	if metrics == nil || len(*metrics) == 0 {
 		w.WriteHeader(http.StatusNoContent)
@@ -423,7 +908,11 @@ func (s *Server) metricsToPromFormat(w io.Writer, prefix string, ts int64, val i
 	switch t := val.(type) {
 	case cgm.Metric:
 		metric := val.(cgm.Metric)
-		sv := fmt.Sprintf("%v", metric.Value)
+		v, sts := sample.Unwrap(metric.Value)
+		if sts > 0 {
+			ts = int64(sts)
+		}
+		sv := fmt.Sprintf("%v", v)
 		switch metric.Type {
 		case "i":
 			fallthrough
@@ -9,6 +9,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -16,14 +17,17 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/circonus-labs/circonus-agent/internal/builtins"
 	"github.com/circonus-labs/circonus-agent/internal/check"
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/errs"
 	"github.com/circonus-labs/circonus-agent/internal/plugins"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 )
@@ -70,7 +74,7 @@ func TestRun(t *testing.T) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	s, err := New(ctx, c, b, p, nil)
+	s, err := New(ctx, c, b, p, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -93,6 +97,158 @@ func TestRun(t *testing.T) {
 	cancel()
 }
 
+func TestRunConcurrent(t *testing.T) {
+	t.Log("Testing run (concurrent requests)")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, derr := os.Getwd()
+	if derr != nil {
+		t.Fatalf("unable to get cwd (%s)", derr)
+	}
+	testDir := path.Join(dir, "testdata")
+
+	viper.Reset()
+	viper.Set(config.KeyPluginDir, testDir)
+	viper.Set(config.KeyListen, ":2609")
+	b, berr := builtins.New(context.Background())
+	if berr != nil {
+		t.Fatalf("expected no error, got (%s)", berr)
+	}
+	p, perr := plugins.New(context.Background(), "")
+	if perr != nil {
+		t.Fatalf("expected NO error, got (%s)", perr)
+	}
+	if serr := p.Scan(b); serr != nil {
+		t.Fatalf("expected no error, got (%s)", serr)
+	}
+	c, cerr := check.New(nil)
+	if cerr != nil {
+		t.Fatalf("expected no error, got (%s)", cerr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := New(ctx, c, b, p, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	numReqs := 5
+	codes := make([]int, numReqs)
+	bodies := make([]string, numReqs)
+	var wg sync.WaitGroup
+	for i := 0; i < numReqs; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/run/test", nil)
+			w := httptest.NewRecorder()
+			s.run(w, req)
+			codes[idx] = w.Code
+			bodies[idx] = w.Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < numReqs; i++ {
+		if codes[i] != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, codes[i])
+		}
+		if !strings.Contains(bodies[i], "metric") {
+			t.Fatalf("expected test plugin metric, got (%s)", bodies[i])
+		}
+	}
+	cancel()
+}
+
+func TestRunRoutes(t *testing.T) {
+	t.Log("Testing run (metric routes)")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, derr := os.Getwd()
+	if derr != nil {
+		t.Fatalf("unable to get cwd (%s)", derr)
+	}
+	testDir := path.Join(dir, "testdata")
+
+	viper.Reset()
+	viper.Set(config.KeyPluginDir, testDir)
+	viper.Set(config.KeyListen, ":2609")
+	viper.Set(config.KeyCheckRoutes, []string{"456:name:security_"})
+	b, berr := builtins.New(context.Background())
+	if berr != nil {
+		t.Fatalf("expected no error, got (%s)", berr)
+	}
+	p, perr := plugins.New(context.Background(), "")
+	if perr != nil {
+		t.Fatalf("expected NO error, got (%s)", perr)
+	}
+	if serr := p.Scan(b); serr != nil {
+		t.Fatalf("expected no error, got (%s)", serr)
+	}
+	c, cerr := check.New(nil)
+	if cerr != nil {
+		t.Fatalf("expected no error, got (%s)", cerr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := New(ctx, c, b, p, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	viper.Set(config.KeyCheckRoutes, []string{})
+
+	run := func(target string) string {
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		s.run(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s expected %d, got %d", target, http.StatusOK, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	_ = run("/run/write") // drop metrics left by other tests
+
+	req := httptest.NewRequest("PUT", "/write/foo", strings.NewReader(`{"security_route":{"_type":"L","_value":1},"route_held":{"_type":"L","_value":2}}`))
+	w := httptest.NewRecorder()
+	s.write(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d (%s)", http.StatusNoContent, w.Code, w.Body.String())
+	}
+
+	t.Log("\tfull collection, route 456")
+	{
+		body := run("/run?route=456")
+		if !strings.Contains(body, "security_route") || strings.Contains(body, "route_held") {
+			t.Fatalf("expected only security_route, got (%s)", body)
+		}
+	}
+
+	t.Log("\t/run/test")
+	{
+		time.Sleep(100 * time.Millisecond) // plugin run of the full collection
+		body := run("/run/test")
+		if !strings.Contains(body, "metric") {
+			t.Fatalf("expected test plugin metric, got (%s)", body)
+		}
+		if strings.Contains(body, "route_held") {
+			t.Fatalf("expected metric held for the agent's check not in /run/test, got (%s)", body)
+		}
+	}
+
+	t.Log("\tfull collection, agent check")
+	{
+		body := run("/run")
+		if !strings.Contains(body, "route_held") {
+			t.Fatalf("expected metric held for the agent's check, got (%s)", body)
+		}
+		if strings.Contains(body, "security_route") {
+			t.Fatalf("expected no routed metric, got (%s)", body)
+		}
+	}
+}
+
 func TestInventory(t *testing.T) {
 	t.Log("Testing inventory")
 	zerolog.SetGlobalLevel(zerolog.Disabled)
@@ -116,7 +272,7 @@ func TestInventory(t *testing.T) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	s, err := New(ctx, c, nil, p, nil)
+	s, err := New(ctx, c, nil, p, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -138,19 +294,105 @@ func TestInventory(t *testing.T) {
 	cancel()
 }
 
+func TestHealth(t *testing.T) {
+	t.Log("Testing health")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	s := &Server{logger: zerolog.Nop()}
+
+	errs.Reset()
+	defer errs.Reset()
+	errs.Record(errs.NewPlugin(errors.New("plugin failed")))
+
+	t.Log("\tplain")
+	{
+		req := httptest.NewRequest("GET", "/health", nil)
+		w := httptest.NewRecorder()
+		s.health(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Body.String() != "Alive\n" {
+			t.Fatalf("expected Alive, got (%s)", w.Body.String())
+		}
+	}
+
+	t.Log("\tjson")
+	{
+		req := httptest.NewRequest("GET", "/health", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		s.health(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		var resp struct {
+			Status string                      `json:"status"`
+			Errors map[errs.Category]errs.Stat `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if resp.Status != "alive" {
+			t.Fatalf("expected alive, got (%s)", resp.Status)
+		}
+		if ps, ok := resp.Errors[errs.Plugin]; !ok || ps.Count != 1 {
+			t.Fatalf("expected plugin error, got (%v)", resp.Errors)
+		}
+	}
+
+	s.sources = newSourceAges(time.Minute, []string{"plugins"})
+	s.sources.start = time.Now().Add(-time.Hour)
+
+	t.Log("\tplain, stale source")
+	{
+		req := httptest.NewRequest("GET", "/health", nil)
+		w := httptest.NewRecorder()
+		s.health(w, req)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+		if w.Body.String() != "Degraded\n" {
+			t.Fatalf("expected Degraded, got (%s)", w.Body.String())
+		}
+	}
+
+	t.Log("\tjson, stale source")
+	{
+		req := httptest.NewRequest("GET", "/health", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		s.health(w, req)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+		var resp struct {
+			Status       string   `json:"status"`
+			StaleSources []string `json:"stale_sources"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if resp.Status != "degraded" || len(resp.StaleSources) != 1 || resp.StaleSources[0] != "plugins" {
+			t.Fatalf("expected degraded plugins, got (%#v)", resp)
+		}
+	}
+}
+
 func TestWrite(t *testing.T) {
 	t.Log("Testing write")
 	zerolog.SetGlobalLevel(zerolog.Disabled)
 
 	viper.Reset()
 	viper.Set(config.KeyListen, ":2609")
+	viper.Set(config.KeyWriteDedupTTL, "5m")
 	c, cerr := check.New(nil)
 	if cerr != nil {
 		t.Fatalf("expected no error, got (%s)", cerr)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	s, err := New(ctx, c, nil, nil, nil)
+	s, err := New(ctx, c, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -219,6 +461,94 @@ func TestWrite(t *testing.T) {
 		}
 	}
 
+	t.Logf("PUT /write/foo w/idempotency key -> %d", http.StatusNoContent)
+	{
+		for _, tc := range []struct {
+			path     string
+			key      string
+			body     string
+			status   int
+			replayed bool
+		}{
+			{"/write/foo", "batch-1", `{"test":{"_type": "L", "_value":1}}`, http.StatusNoContent, false},
+			{"/write/foo", "batch-1", `{"test":{"_type": "L", "_value":1}}`, http.StatusNoContent, true},
+			{"/write/bar", "batch-1", `{"test":{"_type": "L", "_value":1}}`, http.StatusNoContent, false},
+			{"/write/foo", "batch-2", `{"test":1`, http.StatusBadRequest, false},
+			{"/write/foo", "batch-2", `{"test":{"_type": "L", "_value":1}}`, http.StatusNoContent, false},
+			{"/write/foo", strings.Repeat("k", maxIdempotencyKeyLen+1), `{"test":{"_type": "L", "_value":1}}`, http.StatusBadRequest, false},
+		} {
+			req := httptest.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+			req.Header.Set(idempotencyKeyHeader, tc.key)
+			w := httptest.NewRecorder()
+
+			s.write(w, req)
+
+			resp := w.Result()
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Fatalf("%s %.10s expected %d, got %d", tc.path, tc.key, tc.status, resp.StatusCode)
+			}
+			if replayed := resp.Header.Get(idempotentReplayedHeader) == "true"; replayed != tc.replayed {
+				t.Fatalf("%s %.10s expected replayed %v, got %v", tc.path, tc.key, tc.replayed, replayed)
+			}
+		}
+	}
+
+	t.Logf("PUT /write/foo w/idempotency key in flight -> %d", http.StatusConflict)
+	{
+		s.writeDedup.begin("foo\x00batch-3", time.Now())
+
+		req := httptest.NewRequest("PUT", "/write/foo", strings.NewReader(`{"test":{"_type": "L", "_value":1}}`))
+		req.Header.Set(idempotencyKeyHeader, "batch-3")
+		w := httptest.NewRecorder()
+
+		s.write(w, req)
+
+		resp := w.Result()
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected %d, got %d", http.StatusConflict, resp.StatusCode)
+		}
+	}
+
+	t.Logf("POST /write w/o db -> %d", http.StatusNotFound)
+	{
+		reqBody := bytes.NewReader([]byte("cpu usage_idle=99.5"))
+
+		req := httptest.NewRequest("POST", "/write", reqBody)
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		w := httptest.NewRecorder()
+
+		s.write(w, req)
+
+		resp := w.Result()
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	}
+
+	t.Logf("POST /write?db=telegraf w/line protocol -> %d", http.StatusNoContent)
+	{
+		reqBody := bytes.NewReader([]byte("cpu,cpu=cpu0 usage_idle=99.5 1590000000\n"))
+
+		req := httptest.NewRequest("POST", "/write?db=telegraf&precision=s", reqBody)
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		w := httptest.NewRecorder()
+
+		s.write(w, req)
+
+		resp := w.Result()
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected %d, got %d", http.StatusNoContent, resp.StatusCode)
+		}
+	}
+
 	cancel()
 }
 
@@ -280,7 +610,7 @@ func TestPromReceiver(t *testing.T) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	s, err := New(ctx, c, nil, nil, nil)
+	s, err := New(ctx, c, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -317,7 +647,7 @@ func TestSocketHandler(t *testing.T) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	s, err := New(ctx, c, nil, nil, nil)
+	s, err := New(ctx, c, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -412,7 +742,7 @@ func TestPromOutput(t *testing.T) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	s, err := New(ctx, c, nil, nil, nil)
+	s, err := New(ctx, c, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -477,7 +807,7 @@ func TestMetricsToPromFormat(t *testing.T) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	s, err := New(ctx, c, nil, nil, nil)
+	s, err := New(ctx, c, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -622,3 +952,119 @@ func TestMetricsToPromFormat(t *testing.T) {
 
 	cancel()
 }
+
+func TestCollectors(t *testing.T) {
+	t.Log("Testing collectors")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	viper.Reset()
+	b, err := builtins.New(context.Background())
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	s := &Server{logger: zerolog.Nop(), builtins: b}
+
+	t.Log("\tGET /collectors")
+	{
+		w := httptest.NewRecorder()
+		s.collectors(w)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		var status []builtins.CollectorStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("\tPUT /collectors/foo/disable (not local)")
+	{
+		req := httptest.NewRequest("PUT", "/collectors/foo/disable", nil)
+		req.RemoteAddr = "10.1.2.3:12345"
+		w := httptest.NewRecorder()
+		s.setCollectorState(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("expected %d, got %d", http.StatusForbidden, w.Code)
+		}
+	}
+
+	t.Log("\tPUT /collectors/foo/disable (unknown)")
+	{
+		req := httptest.NewRequest("PUT", "/collectors/foo/disable", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		w := httptest.NewRecorder()
+		s.setCollectorState(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestDebugFlushes(t *testing.T) {
+	t.Log("Testing debugFlushes")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tnot enabled")
+	{
+		s := &Server{logger: zerolog.Nop()}
+		w := httptest.NewRecorder()
+		s.debugFlushes(w, httptest.NewRequest("GET", "/debug/flushes", nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+
+	fa, err := newFlushArchive(10, 0, "", zerolog.Nop())
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	ts := time.Date(2020, 6, 1, 14, 32, 0, 0, time.UTC)
+	fa.add(&cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}, ts)
+	s := &Server{logger: zerolog.Nop(), flushes: fa}
+
+	t.Log("\tGET /debug/flushes")
+	{
+		w := httptest.NewRecorder()
+		s.debugFlushes(w, httptest.NewRequest("GET", "/debug/flushes", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		var list []flushSummary
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(list) != 1 || list[0].NumMetrics != 1 {
+			t.Fatalf("expected 1 flush, got %v", list)
+		}
+	}
+
+	t.Log("\tGET /debug/flushes?at=<rfc3339>")
+	{
+		w := httptest.NewRecorder()
+		s.debugFlushes(w, httptest.NewRequest("GET", "/debug/flushes?at=2020-06-01T14:32:30Z", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Body.String() != `{"foo":{"_type":"L","_value":1}}` {
+			t.Fatalf("unexpected body (%s)", w.Body.String())
+		}
+	}
+
+	t.Log("\tGET /debug/flushes?at=<epoch> (before first flush)")
+	{
+		w := httptest.NewRecorder()
+		s.debugFlushes(w, httptest.NewRequest("GET", fmt.Sprintf("/debug/flushes?at=%d", ts.Add(-time.Minute).Unix()), nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+
+	t.Log("\tGET /debug/flushes?at=invalid")
+	{
+		w := httptest.NewRecorder()
+		s.debugFlushes(w, httptest.NewRequest("GET", "/debug/flushes?at=yesterday", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// idempotencyKeyHeader identifies a /write request, a retried request
+	// with the same key (and metric group id) within the ttl is not applied again
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader is set on the response to a duplicate request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen longer keys are rejected
+	maxIdempotencyKeyLen = 255
+	// maxIdempotencyKeys bounds the keys held, writes with a new key are
+	// applied (not deduplicated) while the cache is full
+	maxIdempotencyKeys = 100000
+)
+
+// results of writeDedup.begin
+const (
+	writeApply    = iota // apply the write
+	writeApplied         // duplicate of a write applied within the ttl
+	writeInFlight        // duplicate of a write still being applied
+)
+
+// writeDedup holds the idempotency keys of the writes applied within the
+// ttl, so that at-least-once producers (retrying shippers) do not apply a
+// write twice (e.g. double counting counters)
+type writeDedup struct {
+	ttl       time.Duration
+	keys      map[string]time.Time // key -> applied
+	inFlight  map[string]bool      // keys of the writes being applied
+	nextSweep time.Time
+	sync.Mutex
+}
+
+// newWriteDedup returns nil if idempotency keys are ignored
+func newWriteDedup(ttl time.Duration) *writeDedup {
+	if ttl <= 0 {
+		return nil
+	}
+	return &writeDedup{
+		ttl:      ttl,
+		keys:     make(map[string]time.Time),
+		inFlight: make(map[string]bool),
+	}
+}
+
+// begin starts a write, returns writeApplied if the key was applied within
+// the ttl and writeInFlight if a write with the key is still being applied
+// (e.g. a client timed out and retried). Otherwise the key is held as in
+// flight until done is called with the outcome of the write.
+func (wd *writeDedup) begin(key string, now time.Time) int {
+	if wd == nil || key == "" {
+		return writeApply
+	}
+
+	wd.Lock()
+	defer wd.Unlock()
+
+	if now.After(wd.nextSweep) {
+		for k, ts := range wd.keys {
+			if now.Sub(ts) >= wd.ttl {
+				delete(wd.keys, k)
+			}
+		}
+		wd.nextSweep = now.Add(wd.ttl / 2)
+	}
+
+	if ts, ok := wd.keys[key]; ok && now.Sub(ts) < wd.ttl {
+		return writeApplied
+	}
+	if wd.inFlight[key] {
+		return writeInFlight
+	}
+	if len(wd.keys)+len(wd.inFlight) >= maxIdempotencyKeys {
+		return writeApply
+	}
+	wd.inFlight[key] = true
+	return writeApply
+}
+
+// done ends a write started with begin, the key is recorded only if the
+// write was applied - a failed write may be retried
+func (wd *writeDedup) done(key string, applied bool, now time.Time) {
+	if wd == nil || key == "" {
+		return
+	}
+
+	wd.Lock()
+	defer wd.Unlock()
+
+	if !wd.inFlight[key] {
+		return // not held, cache was full
+	}
+	delete(wd.inFlight, key)
+	if applied {
+		wd.keys[key] = now
+	}
+}
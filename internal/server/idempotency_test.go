@@ -0,0 +1,101 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWriteDedup(t *testing.T) {
+	t.Log("Testing writeDedup")
+
+	t.Log("\tdisabled")
+	{
+		wd := newWriteDedup(0)
+		if wd != nil {
+			t.Fatal("expected nil")
+		}
+		if wd.begin("foo\x00key", time.Now()) != writeApply || wd.begin("foo\x00key", time.Now()) != writeApply {
+			t.Fatal("expected keys to be ignored")
+		}
+		wd.done("foo\x00key", true, time.Now())
+	}
+
+	now := time.Now()
+
+	t.Log("\tduplicate within ttl")
+	{
+		wd := newWriteDedup(time.Minute)
+		if wd.begin("foo\x00key", now) != writeApply {
+			t.Fatal("expected first write to be applied")
+		}
+		wd.done("foo\x00key", true, now)
+		if wd.begin("foo\x00key", now.Add(30*time.Second)) != writeApplied {
+			t.Fatal("expected duplicate")
+		}
+		if wd.begin("bar\x00key", now) != writeApply {
+			t.Fatal("expected key of another id to be applied")
+		}
+		if wd.begin("", now) != writeApply || wd.begin("", now) != writeApply {
+			t.Fatal("expected writes without key to be applied")
+		}
+	}
+
+	t.Log("\tin flight")
+	{
+		wd := newWriteDedup(time.Minute)
+		wd.begin("foo\x00key", now)
+		if wd.begin("foo\x00key", now) != writeInFlight {
+			t.Fatal("expected in flight")
+		}
+		wd.done("foo\x00key", true, now)
+		if wd.begin("foo\x00key", now) != writeApplied {
+			t.Fatal("expected duplicate once applied")
+		}
+	}
+
+	t.Log("\tnot applied")
+	{
+		wd := newWriteDedup(time.Minute)
+		wd.begin("foo\x00key", now)
+		wd.done("foo\x00key", false, now)
+		if wd.begin("foo\x00key", now) != writeApply {
+			t.Fatal("expected key of failed write to be applied")
+		}
+	}
+
+	t.Log("\texpired")
+	{
+		wd := newWriteDedup(time.Minute)
+		wd.begin("foo\x00key", now)
+		wd.done("foo\x00key", true, now)
+		if wd.begin("foo\x00key", now.Add(time.Minute)) != writeApply {
+			t.Fatal("expected expired key to be applied")
+		}
+		wd.done("foo\x00key", true, now.Add(time.Minute))
+		wd.begin("foo\x00new", now.Add(3*time.Minute)) // sweeps
+		if len(wd.keys) != 0 {
+			t.Fatalf("expected expired keys to be swept, have %v", wd.keys)
+		}
+	}
+
+	t.Log("\tfull")
+	{
+		wd := newWriteDedup(time.Minute)
+		for i := 0; i < maxIdempotencyKeys; i++ {
+			wd.keys[strconv.Itoa(i)+"\x00key"] = now
+		}
+		if wd.begin("foo\x00key", now) != writeApply || wd.begin("foo\x00key", now) != writeApply {
+			t.Fatal("expected keys to be ignored when full")
+		}
+		wd.done("foo\x00key", true, now)
+		if _, ok := wd.keys["foo\x00key"]; ok {
+			t.Fatal("expected key not recorded when full")
+		}
+	}
+}
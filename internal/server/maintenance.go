@@ -0,0 +1,63 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+// MaintenanceMetric number of maintenance windows active (0 outside of maintenance)
+const MaintenanceMetric = "agent_maintenance"
+
+// maintenanceGauge emits whether the agent is in a scheduled maintenance
+// window, the builtins and plugins selected by an active window are paused
+type maintenanceGauge struct {
+	windows []config.MaintenanceWindow
+	active  int
+	logger  zerolog.Logger
+	sync.Mutex
+}
+
+// newMaintenanceGauge returns nil if there are no maintenance windows
+func newMaintenanceGauge(windows []config.MaintenanceWindow, logger zerolog.Logger) *maintenanceGauge {
+	if len(windows) == 0 {
+		return nil
+	}
+	return &maintenanceGauge{windows: windows, logger: logger}
+}
+
+// apply adds the maintenance gauge, logs windows starting/ending
+func (mg *maintenanceGauge) apply(metrics *cgm.Metrics, now time.Time) {
+	if mg == nil || metrics == nil {
+		return
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
+
+	active := config.ActiveMaintenanceWindows(mg.windows, now)
+	if n := len(active); n != mg.active {
+		if n > 0 {
+			specs := make([]string, 0, n)
+			for _, w := range active {
+				specs = append(specs, w.Spec+"|"+w.Duration.String())
+			}
+			mg.logger.Info().Str("windows", strings.Join(specs, ",")).Msg("maintenance window active, selected collectors paused")
+		} else {
+			mg.logger.Info().Msg("maintenance window ended, collectors resumed")
+		}
+		mg.active = n
+	}
+
+	(*metrics)[tags.MetricNameWithStreamTags(MaintenanceMetric, tags.FromList(tags.GetBaseTags()))] = cgm.Metric{Type: "L", Value: uint64(mg.active)}
+}
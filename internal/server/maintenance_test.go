@@ -0,0 +1,69 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func TestMaintenanceGauge(t *testing.T) {
+	t.Log("Testing maintenanceGauge")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tdisabled")
+	{
+		mg := newMaintenanceGauge(nil, zerolog.Nop())
+		if mg != nil {
+			t.Fatal("expected nil")
+		}
+		metrics := cgm.Metrics{}
+		mg.apply(&metrics, time.Now())
+		if len(metrics) != 0 {
+			t.Fatalf("expected no metrics, got %v", metrics)
+		}
+	}
+
+	defer viper.Reset()
+	viper.Set(config.KeyMaintenanceWindows, []string{"0 2 * * *|1h|disk"})
+	windows, err := config.MaintenanceWindows()
+	if err != nil {
+		t.Fatalf("expected no error, got (%s)", err)
+	}
+	mg := newMaintenanceGauge(windows, zerolog.Nop())
+	name := tags.MetricNameWithStreamTags(MaintenanceMetric, tags.FromList(tags.GetBaseTags()))
+	start := time.Date(2020, time.June, 6, 2, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		expect uint64
+	}{
+		{"before", start.Add(-time.Minute), 0},
+		{"active", start.Add(30 * time.Minute), 1},
+		{"ended", start.Add(time.Hour), 0},
+	}
+
+	for _, tst := range tests {
+		t.Logf("\t%s", tst.name)
+		metrics := cgm.Metrics{}
+		mg.apply(&metrics, tst.now)
+		m, ok := metrics[name]
+		if !ok {
+			t.Fatalf("expected %s in %v", name, metrics)
+		}
+		if m.Value.(uint64) != tst.expect {
+			t.Fatalf("expected %d got %v", tst.expect, m.Value)
+		}
+	}
+}
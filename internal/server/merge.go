@@ -0,0 +1,38 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"github.com/circonus-labs/circonus-agent/internal/merge"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+// conduitOrder is the order conduit metrics are aggregated in, so that the
+// merge policy is applied consistently across runs (e.g. with last, a
+// statsd metric replaces a builtin metric with the same name and tags)
+var conduitOrder = []string{"builtins", "plugins", "receiver", "statsd", "prometheus", "otlp", "graphite"}
+
+// mergeMetrics adds the metrics from a conduit to dst, applying the merge
+// policy to metrics already in dst, returns the number of rejected metrics
+func mergeMetrics(dst cgm.Metrics, src *cgm.Metrics, source, policy string, logger zerolog.Logger) uint64 {
+	conflicts := uint64(0)
+	for name, v := range *src {
+		prev, exists := dst[name]
+		if !exists {
+			dst[name] = v
+			continue
+		}
+		m, ok := merge.Metrics(policy, prev, v)
+		if !ok {
+			conflicts++
+			logger.Debug().Str("metric", name).Str("source", source).Msg("rejected, metric already emitted by another source")
+			continue
+		}
+		dst[name] = m
+	}
+	return conflicts
+}
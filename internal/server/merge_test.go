@@ -0,0 +1,61 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/merge"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+func TestMergeMetrics(t *testing.T) {
+	t.Log("Testing mergeMetrics")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	builtins := cgm.Metrics{
+		"dup":  cgm.Metric{Type: "L", Value: uint64(1)},
+		"text": cgm.Metric{Type: "s", Value: "builtin"},
+		"foo":  cgm.Metric{Type: "L", Value: uint64(10)},
+	}
+	plugins := cgm.Metrics{
+		"dup":  cgm.Metric{Type: "L", Value: uint64(2)},
+		"text": cgm.Metric{Type: "s", Value: "plugin"},
+		"bar":  cgm.Metric{Type: "n", Value: 1.5},
+	}
+
+	tt := []struct {
+		policy    string
+		dup       interface{}
+		text      interface{}
+		conflicts uint64
+	}{
+		{merge.Last, uint64(2), "plugin", 0},
+		{merge.Sum, uint64(3), "plugin", 0},
+		{merge.Reject, uint64(1), "builtin", 2},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s", tst.policy)
+		dst := cgm.Metrics{}
+		conflicts := mergeMetrics(dst, &builtins, "builtins", tst.policy, zerolog.Nop())
+		conflicts += mergeMetrics(dst, &plugins, "plugins", tst.policy, zerolog.Nop())
+		if conflicts != tst.conflicts {
+			t.Fatalf("expected %d conflicts, got %d", tst.conflicts, conflicts)
+		}
+		if len(dst) != 4 {
+			t.Fatalf("expected 4 metrics, got %d", len(dst))
+		}
+		if v := dst["dup"].Value; v != tst.dup {
+			t.Fatalf("expected dup %v, got %v", tst.dup, v)
+		}
+		if v := dst["text"].Value; v != tst.text {
+			t.Fatalf("expected text %v, got %v", tst.text, v)
+		}
+	}
+}
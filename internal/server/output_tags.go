@@ -0,0 +1,104 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// outputNaming renames the metrics of an output (see --output-tags), so the
+// same metrics can follow the naming conventions of different destinations
+type outputNaming struct {
+	prefix  string
+	add     []string        // encoded stream tags (cat:val)
+	replace map[string]bool // categories of the added tags, replace existing tags
+	remove  map[string]bool // categories removed
+}
+
+// outputNamings the naming of each output, nil if no output has settings
+type outputNamings map[string]*outputNaming
+
+// newOutputNamings returns nil if no output has output tag settings
+func newOutputNamings(settings map[string]config.OutputTags) outputNamings {
+	if len(settings) == 0 {
+		return nil
+	}
+	namings := make(outputNamings, len(settings))
+	for output, ot := range settings {
+		n := &outputNaming{
+			prefix:  ot.Prefix,
+			replace: make(map[string]bool, len(ot.Add)),
+			remove:  make(map[string]bool, len(ot.Remove)),
+		}
+		for _, t := range tags.FromList(ot.Add) {
+			encoded := tags.EncodeMetricStreamTags(tags.Tags{t})
+			if encoded == "" {
+				continue
+			}
+			n.add = append(n.add, encoded)
+			n.replace[strings.ToLower(t.Category)] = true
+		}
+		for _, category := range ot.Remove {
+			n.remove[strings.ToLower(category)] = true
+		}
+		namings[output] = n
+	}
+	return namings
+}
+
+// apply returns the metrics renamed for an output, the metrics as-is when the
+// output has no settings. The metrics passed are not modified (they are shared
+// with the other outputs). Series which only differed by a removed tag
+// collapse into one, the value of one of them is reported.
+func (on outputNamings) apply(output string, metrics *cgm.Metrics) *cgm.Metrics {
+	n, ok := on[output]
+	if !ok || metrics == nil {
+		return metrics
+	}
+
+	renamed := make(cgm.Metrics, len(*metrics))
+	for name, metric := range *metrics {
+		renamed[n.rename(name)] = metric
+	}
+	return &renamed
+}
+
+// rename returns the metric name with the prefix and the stream tags of the output
+func (n *outputNaming) rename(name string) string {
+	if len(n.add) == 0 && len(n.remove) == 0 {
+		return n.prefix + name
+	}
+
+	base, tagList, ok := tags.SplitMetricStreamTags(name)
+	if !ok && strings.Contains(name, "|ST[") {
+		return n.prefix + name // stream tags not decodable, leave them as-is
+	}
+
+	// keep the existing tags as encoded in the name, only the categories
+	// are decoded (see SplitMetricStreamTags) to identify the removed tags
+	streamTags := make([]string, 0, len(tagList)+len(n.add))
+	if ok {
+		for i, encoded := range strings.Split(name[len(base)+len("|ST["):len(name)-1], tags.Separator) {
+			category := strings.ToLower(tagList[i].Category)
+			if n.remove[category] || n.replace[category] {
+				continue
+			}
+			streamTags = append(streamTags, encoded)
+		}
+	}
+	streamTags = append(streamTags, n.add...)
+
+	if len(streamTags) == 0 {
+		return n.prefix + base
+	}
+	sort.Strings(streamTags)
+	return n.prefix + base + "|ST[" + strings.Join(streamTags, tags.Separator) + "]"
+}
@@ -0,0 +1,93 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestOutputNamings(t *testing.T) {
+	t.Log("Testing outputNamings")
+
+	t.Log("\tdisabled")
+	{
+		on := newOutputNamings(nil)
+		if on != nil {
+			t.Fatal("expected nil")
+		}
+		metrics := &cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}
+		if m := on.apply(config.OutputCheck, metrics); m != metrics {
+			t.Fatalf("expected metrics as-is, got %v", m)
+		}
+	}
+
+	on := newOutputNamings(map[string]config.OutputTags{
+		config.OutputPrometheus: {Prefix: "agent_", Add: []string{"env:prod"}, Remove: []string{"source"}},
+		config.OutputCheck:      {Prefix: "host1`"},
+	})
+
+	tagged := tags.MetricNameWithStreamTags("cpu", tags.Tags{{Category: "source", Value: "builtins"}, {Category: "env", Value: "dev"}, {Category: "cpu", Value: "0"}})
+	metrics := &cgm.Metrics{
+		"uptime":   cgm.Metric{Type: "L", Value: uint64(1)},
+		tagged:     cgm.Metric{Type: "n", Value: 0.5},
+		"bad|ST[x": cgm.Metric{Type: "L", Value: uint64(2)},
+	}
+
+	t.Log("\tno settings for output")
+	{
+		other := newOutputNamings(map[string]config.OutputTags{config.OutputCheck: {Prefix: "x_"}})
+		if m := other.apply(config.OutputPrometheus, metrics); m != metrics {
+			t.Fatalf("expected metrics as-is, got %v", m)
+		}
+	}
+
+	t.Log("\tprefix")
+	{
+		m := on.apply(config.OutputCheck, metrics)
+		if len(*m) != 3 {
+			t.Fatalf("expected 3 metrics, got %v", m)
+		}
+		for _, name := range []string{"host1`uptime", "host1`" + tagged, "host1`bad|ST[x"} {
+			if _, ok := (*m)[name]; !ok {
+				t.Fatalf("expected %s, got %v", name, m)
+			}
+		}
+		if _, ok := (*metrics)["uptime"]; !ok || len(*metrics) != 3 {
+			t.Fatalf("expected metrics not modified, got %v", metrics)
+		}
+	}
+
+	t.Log("\tprefix, add, remove")
+	{
+		m := on.apply(config.OutputPrometheus, metrics)
+		expect := map[string]bool{
+			tags.MetricNameWithStreamTags("agent_uptime", tags.Tags{{Category: "env", Value: "prod"}}):                             true,
+			tags.MetricNameWithStreamTags("agent_cpu", tags.Tags{{Category: "cpu", Value: "0"}, {Category: "env", Value: "prod"}}): true,
+			"agent_bad|ST[x": true,
+		}
+		if len(*m) != len(expect) {
+			t.Fatalf("expected %d metrics, got %v", len(expect), m)
+		}
+		for name := range expect {
+			if _, ok := (*m)[name]; !ok {
+				t.Fatalf("expected %s, got %v", name, m)
+			}
+		}
+	}
+
+	t.Log("\tremove all tags")
+	{
+		rm := newOutputNamings(map[string]config.OutputTags{config.OutputCheck: {Remove: []string{"source", "env", "cpu"}}})
+		m := rm.apply(config.OutputCheck, &cgm.Metrics{tagged: cgm.Metric{Type: "n", Value: 0.5}})
+		if _, ok := (*m)["cpu"]; !ok || len(*m) != 1 {
+			t.Fatalf("expected cpu, got %v", m)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// continuationParam is the query parameter used to request the next page of a /run response
+	continuationParam = "continuation"
+	// continuationHeader is the response header containing the token for the next page
+	continuationHeader = "X-Circonus-Continuation"
+	// pagesRemainingHeader is the response header containing the number of pages remaining
+	pagesRemainingHeader = "X-Circonus-Pages-Remaining"
+	// continuationTTL is how long remaining pages are held for retrieval
+	continuationTTL = 5 * time.Minute
+)
+
+// runPager holds the remaining pages of /run responses which exceeded the
+// configured response size budget, keyed by continuation token
+type runPager struct {
+	maxBytes int
+	pages    map[string]*pageSet
+	sync.Mutex
+}
+
+type pageSet struct {
+	pages   []*cgm.Metrics
+	expires time.Time
+}
+
+func newRunPager(maxBytes int) *runPager {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &runPager{
+		maxBytes: maxBytes,
+		pages:    make(map[string]*pageSet),
+	}
+}
+
+// paginate splits metrics into pages with an encoded size of at most maxBytes
+// (a single metric larger than the budget is sent in a page by itself). Metrics
+// are ordered by name so that metrics from a given source are kept together.
+func paginate(m *cgm.Metrics, maxBytes int) []*cgm.Metrics {
+	if m == nil || len(*m) == 0 {
+		return []*cgm.Metrics{m}
+	}
+
+	names := make([]string, 0, len(*m))
+	for name := range *m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pages := []*cgm.Metrics{}
+	page := cgm.Metrics{}
+	pageSize := 2 // {}
+	var scratch []byte
+	for _, name := range names {
+		metric := (*m)[name]
+		size := 1 // comma
+		if b, err := appendMetric(scratch[:0], name, metric); err == nil {
+			size += len(b)
+			scratch = b
+		}
+		if len(page) > 0 && pageSize+size > maxBytes {
+			p := page
+			pages = append(pages, &p)
+			page = cgm.Metrics{}
+			pageSize = 2
+		}
+		page[name] = metric
+		pageSize += size
+	}
+	pages = append(pages, &page)
+
+	return pages
+}
+
+// split returns the first page of metrics and, if the metrics exceed the
+// response size budget, the continuation token for retrieving the next page
+func (p *runPager) split(m *cgm.Metrics) (*cgm.Metrics, string, int, error) {
+	if p == nil {
+		return m, "", 0, nil
+	}
+
+	pages := paginate(m, p.maxBytes)
+	if len(pages) == 1 {
+		return pages[0], "", 0, nil
+	}
+
+	token, err := p.store(pages[1:])
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return pages[0], token, len(pages) - 1, nil
+}
+
+// next returns the next page for a continuation token, along with the token
+// for the subsequent page (if any) and the number of pages remaining
+func (p *runPager) next(token string) (*cgm.Metrics, string, int, error) {
+	if p == nil {
+		return nil, "", 0, errors.New("pagination not enabled")
+	}
+
+	p.Lock()
+	ps, ok := p.pages[token]
+	if ok {
+		delete(p.pages, token)
+	}
+	p.Unlock()
+
+	if !ok || time.Now().After(ps.expires) {
+		return nil, "", 0, errors.Errorf("unknown or expired continuation token (%s)", token)
+	}
+
+	if len(ps.pages) == 1 {
+		return ps.pages[0], "", 0, nil
+	}
+
+	nextToken, err := p.store(ps.pages[1:])
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return ps.pages[0], nextToken, len(ps.pages) - 1, nil
+}
+
+// store saves pages under a new continuation token, expired page sets are purged
+func (p *runPager) store(pages []*cgm.Metrics) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "generating continuation token")
+	}
+	token := hex.EncodeToString(buf)
+
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	for t, ps := range p.pages {
+		if now.After(ps.expires) {
+			delete(p.pages, t)
+		}
+	}
+
+	p.pages[token] = &pageSet{pages: pages, expires: now.Add(continuationTTL)}
+
+	return token, nil
+}
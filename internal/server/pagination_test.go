@@ -0,0 +1,206 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/rs/zerolog"
+)
+
+func testMetrics(n int) *cgm.Metrics {
+	m := cgm.Metrics{}
+	for i := 0; i < n; i++ {
+		m[fmt.Sprintf("metric%03d", i)] = cgm.Metric{Type: "L", Value: uint64(i)}
+	}
+	return &m
+}
+
+func TestPaginate(t *testing.T) {
+	t.Log("Testing paginate")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tempty")
+	{
+		pages := paginate(&cgm.Metrics{}, 100)
+		if len(pages) != 1 {
+			t.Fatalf("expected 1 page, got %d", len(pages))
+		}
+	}
+
+	t.Log("\tunder budget")
+	{
+		pages := paginate(testMetrics(5), 10000)
+		if len(pages) != 1 {
+			t.Fatalf("expected 1 page, got %d", len(pages))
+		}
+	}
+
+	t.Log("\tover budget")
+	{
+		maxBytes := 200
+		m := testMetrics(50)
+		pages := paginate(m, maxBytes)
+		if len(pages) < 2 {
+			t.Fatalf("expected >1 pages, got %d", len(pages))
+		}
+		total := 0
+		for _, p := range pages {
+			data, err := json.Marshal(p)
+			if err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if len(data) > maxBytes {
+				t.Fatalf("expected page <= %d bytes, got %d", maxBytes, len(data))
+			}
+			total += len(*p)
+		}
+		if total != len(*m) {
+			t.Fatalf("expected %d metrics, got %d", len(*m), total)
+		}
+	}
+
+	t.Log("\tmetric larger than budget")
+	{
+		pages := paginate(testMetrics(3), 10)
+		if len(pages) != 3 {
+			t.Fatalf("expected 3 pages, got %d", len(pages))
+		}
+	}
+}
+
+func TestRunPager(t *testing.T) {
+	t.Log("Testing runPager")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tdisabled")
+	{
+		p := newRunPager(0)
+		if p != nil {
+			t.Fatal("expected nil pager")
+		}
+		m := testMetrics(10)
+		page, token, remaining, err := p.split(m)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if token != "" || remaining != 0 || len(*page) != len(*m) {
+			t.Fatalf("expected full response, got %d metrics, token '%s', %d remaining", len(*page), token, remaining)
+		}
+		if _, _, _, err := p.next("foo"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tsplit and next")
+	{
+		p := newRunPager(200)
+		m := testMetrics(50)
+		page, token, remaining, err := p.split(m)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if token == "" {
+			t.Fatal("expected continuation token")
+		}
+		total := len(*page)
+		for token != "" {
+			prev := remaining
+			page, token, remaining, err = p.next(token)
+			if err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if remaining != prev-1 {
+				t.Fatalf("expected %d remaining, got %d", prev-1, remaining)
+			}
+			total += len(*page)
+		}
+		if total != len(*m) {
+			t.Fatalf("expected %d metrics, got %d", len(*m), total)
+		}
+		if len(p.pages) != 0 {
+			t.Fatalf("expected 0 stored page sets, got %d", len(p.pages))
+		}
+	}
+
+	t.Log("\ttoken reuse")
+	{
+		p := newRunPager(200)
+		_, token, _, err := p.split(testMetrics(50))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if _, _, _, err := p.next(token); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if _, _, _, err := p.next(token); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\texpired token")
+	{
+		p := newRunPager(200)
+		_, token, _, err := p.split(testMetrics(50))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		p.pages[token].expires = time.Now().Add(-time.Second)
+		if _, _, _, err := p.next(token); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestRunContinuation(t *testing.T) {
+	t.Log("Testing runContinuation")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	s := &Server{pager: newRunPager(200)}
+
+	t.Log("\tunknown token")
+	{
+		req := httptest.NewRequest("GET", "/run?continuation=foo", nil)
+		w := httptest.NewRecorder()
+		s.run(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+
+	t.Log("\tvalid token")
+	{
+		_, token, remaining, err := s.pager.split(testMetrics(50))
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		req := httptest.NewRequest("GET", "/run?continuation="+token, nil)
+		w := httptest.NewRecorder()
+		s.run(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		var m cgm.Metrics
+		if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(m) == 0 {
+			t.Fatal("expected metrics")
+		}
+		if remaining > 1 && w.Header().Get(continuationHeader) == "" {
+			t.Fatal("expected continuation header")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/promtext"
+	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const proxyTimeout = 10 * time.Second
+
+// exporterProxy pulls metrics from local exporters (prometheus text format)
+// on request, so a check can collect third-party exporter data through the
+// agent's port (and reverse connection)
+type exporterProxy struct {
+	targets  map[string]string // name -> url
+	client   *http.Client
+	baseTags []string
+}
+
+// newExporterProxy returns nil if there are no proxy targets
+func newExporterProxy(targets map[string]string) *exporterProxy {
+	if len(targets) == 0 {
+		return nil
+	}
+	return &exporterProxy{
+		targets: targets,
+		client: &http.Client{
+			Timeout: proxyTimeout,
+			Transport: &http.Transport{
+				DisableKeepAlives:   true,
+				MaxIdleConnsPerHost: 1,
+			},
+		},
+		baseTags: tags.GetBaseTags(),
+	}
+}
+
+// has determines if name is a configured proxy target
+func (p *exporterProxy) has(name string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.targets[name]
+	return ok
+}
+
+// fetch retrieves the metrics of the named exporter, each metric is tagged
+// with proxy:<name> and its labels
+func (p *exporterProxy) fetch(ctx context.Context, name string) (*cgm.Metrics, error) {
+	u, ok := p.targets[name]
+	if !ok {
+		return nil, errors.Errorf("unknown proxy target (%s)", name)
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "proxy request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "proxy request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("proxy request, %s", resp.Status)
+	}
+
+	metrics := make(cgm.Metrics)
+	if err := p.parse(name, resp.Body, &metrics); err != nil {
+		return nil, errors.Wrap(err, "parsing exporter metrics")
+	}
+
+	return &metrics, nil
+}
+
+// parse converts prometheus text format metrics
+func (p *exporterProxy) parse(name string, data io.Reader, metrics *cgm.Metrics) error {
+	return promtext.Parse(data, nil, func(mn string, m *dto.Metric, val interface{}) {
+		metricName := tags.MetricNameWithStreamTags(promtext.CleanRx.ReplaceAllString(mn, ""), p.getTags(name, m))
+		(*metrics)[metricName] = cgm.Metric{Type: "n", Value: val}
+	})
+}
+
+func (p *exporterProxy) getTags(name string, m *dto.Metric) tags.Tags {
+	labels := promtext.Labels(m, promtext.CleanRx)
+	tagList := make([]string, 0, len(p.baseTags)+len(labels)+2)
+	tagList = append(tagList, p.baseTags...)
+	tagList = append(tagList, "source"+tags.Delimiter+release.NAME, "proxy"+tags.Delimiter+name)
+	tagList = append(tagList, labels...)
+	return tags.FromList(tagList)
+}
@@ -0,0 +1,111 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+const testExporterMetrics = `# HELP node_load1 1m load average.
+# TYPE node_load1 gauge
+node_load1 0.25
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} 1234.5
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.1"} 3
+http_request_duration_seconds_bucket{le="+Inf"} 5
+http_request_duration_seconds_sum 1.5
+http_request_duration_seconds_count 5
+`
+
+func TestProxyExporter(t *testing.T) {
+	t.Log("Testing proxyExporter")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			_, _ = w.Write([]byte(testExporterMetrics))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer exporter.Close()
+
+	s := &Server{
+		logger: zerolog.Nop(),
+		proxy: newExporterProxy(map[string]string{
+			"node":    exporter.URL + "/metrics",
+			"missing": exporter.URL + "/missing",
+		}),
+	}
+
+	t.Log("\tdisabled")
+	{
+		if newExporterProxy(nil) != nil {
+			t.Fatal("expected nil")
+		}
+		s := &Server{logger: zerolog.Nop()}
+		w := httptest.NewRecorder()
+		s.proxyExporter(w, httptest.NewRequest("GET", "/proxy/node", nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+
+	t.Log("\tGET /proxy/node")
+	{
+		w := httptest.NewRecorder()
+		s.proxyExporter(w, httptest.NewRequest("GET", "/proxy/node", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d (%s)", http.StatusOK, w.Code, w.Body.String())
+		}
+		var metrics map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &metrics); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if len(metrics) != 6 {
+			t.Fatalf("expected 6 metrics, got %d %v", len(metrics), metrics)
+		}
+		tag := func(cat, val string) string {
+			return `b"` + base64.StdEncoding.EncodeToString([]byte(cat)) + `":b"` + base64.StdEncoding.EncodeToString([]byte(val)) + `"`
+		}
+		for name := range metrics {
+			if !strings.Contains(name, tag("proxy", "node")) {
+				t.Fatalf("expected proxy tag, got (%s)", name)
+			}
+			if strings.HasPrefix(name, "node_cpu_seconds_total") && !strings.Contains(name, tag("mode", "idle")) {
+				t.Fatalf("expected label tags, got (%s)", name)
+			}
+		}
+	}
+
+	t.Log("\tGET /proxy/unknown")
+	{
+		w := httptest.NewRecorder()
+		s.proxyExporter(w, httptest.NewRequest("GET", "/proxy/unknown", nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+
+	t.Log("\tGET /proxy/missing (exporter error)")
+	{
+		w := httptest.NewRecorder()
+		s.proxyExporter(w, httptest.NewRequest("GET", "/proxy/missing", nil))
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected %d, got %d", http.StatusBadGateway, w.Code)
+		}
+	}
+}
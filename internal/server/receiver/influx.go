@@ -0,0 +1,202 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package receiver
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/pkg/errors"
+)
+
+// InfluxMediaType is the Content-Type of InfluxDB line protocol requests (e.g. telegraf)
+const InfluxMediaType = "text/plain"
+
+// maxInfluxLineSize limits the size of a line protocol line
+const maxInfluxLineSize = 1024 * 1024
+
+var (
+	influxKeyUnescaper    = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\\`, `\`)
+	influxStringUnescaper = strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+)
+
+// influxPrecision timestamp units, by precision (query parameter, default nanoseconds)
+var influxPrecision = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// ParseInflux handles incoming PUT/POST requests with InfluxDB line protocol
+// payloads (measurement,tag=val field=val timestamp). Each field is a metric
+// named measurement`field, tags are stream tags. Float fields are numeric,
+// integer (i suffix) are int64, unsigned (u suffix) are uint64, booleans are
+// 1 or 0 and strings are text metrics. Timestamps are in the precision given
+// (n, u, ms, s, m, h - default n). The request is rejected if any line is invalid.
+func ParseInflux(id, precision string, data io.Reader) error {
+	if err := initCGM(); err != nil {
+		return err
+	}
+
+	unit, ok := influxPrecision[precision]
+	if !ok {
+		return errors.Errorf("invalid precision (%s) for %s", precision, id)
+	}
+
+	var lines []tags.JSONMetrics
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(make([]byte, 0, 4096), maxInfluxLineSize)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m, err := parseInfluxLine(line, unit)
+		if err != nil {
+			return errors.Wrapf(err, "parsing line protocol for %s, line %d", id, lineNum)
+		}
+		lines = append(lines, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrapf(err, "reading line protocol for %s", id)
+	}
+
+	// fields of one line are recorded together, the same field can occur on
+	// more than one line (different tags)
+	for _, m := range lines {
+		record(id, m)
+	}
+	return nil
+}
+
+// parseInfluxLine converts a line protocol line into the metrics of its fields
+func parseInfluxLine(line string, unit time.Duration) (tags.JSONMetrics, error) {
+	sections := splitInflux(line, ' ', true)
+	if len(sections) != 2 && len(sections) != 3 {
+		return nil, errors.New("expected measurement[,tags] fields [timestamp]")
+	}
+
+	keyParts := splitInflux(sections[0], ',', false)
+	measurement := influxKeyUnescaper.Replace(keyParts[0])
+	if measurement == "" {
+		return nil, errors.New("empty measurement")
+	}
+	tagList := make([]string, 0, len(keyParts)-1)
+	for _, tag := range keyParts[1:] {
+		kv := splitInflux(tag, '=', false)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, errors.Errorf("invalid tag (%s)", tag)
+		}
+		tagList = append(tagList, influxKeyUnescaper.Replace(kv[0])+tags.Delimiter+influxKeyUnescaper.Replace(kv[1]))
+	}
+
+	var ts uint64
+	if len(sections) == 3 {
+		v, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil || v < 0 {
+			return nil, errors.Errorf("invalid timestamp (%s)", sections[2])
+		}
+		if unit < time.Millisecond {
+			ts = uint64(v / int64(time.Millisecond/unit))
+		} else {
+			ts = uint64(v * int64(unit/time.Millisecond))
+		}
+	}
+
+	metrics := make(tags.JSONMetrics)
+	for _, field := range splitInflux(sections[1], ',', true) {
+		kv := splitInflux(field, '=', true)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, errors.Errorf("invalid field (%s)", field)
+		}
+		mtype, value, err := parseInfluxValue(kv[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s", kv[0])
+		}
+		name := measurement + defaults.MetricNameSeparator + influxKeyUnescaper.Replace(kv[0])
+		metrics[name] = tags.JSONMetric{
+			Tags:      tagList,
+			Type:      mtype,
+			Value:     value,
+			Timestamp: ts,
+		}
+	}
+
+	return metrics, nil
+}
+
+// parseInfluxValue returns the metric type and value of a field value
+func parseInfluxValue(raw string) (string, interface{}, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return "", nil, errors.Errorf("invalid string value (%s)", raw)
+		}
+		return "s", influxStringUnescaper.Replace(raw[1 : len(raw)-1]), nil
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "invalid integer value")
+		}
+		return "l", v, nil
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "invalid unsigned value")
+		}
+		return "L", v, nil
+	}
+
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return "i", int64(1), nil
+	case "f", "F", "false", "False", "FALSE":
+		return "i", int64(0), nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "invalid float value")
+	}
+	return "n", v, nil
+}
+
+// splitInflux splits s on sep, a separator escaped with a backslash (or, when
+// quotes is set, within a double quoted string) does not split. When sep is
+// = only the first separator splits (field values can contain =).
+func splitInflux(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++ // skip escaped char
+		case c == '"' && quotes:
+			inQuotes = !inQuotes
+		case c == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+			if sep == '=' {
+				return append(parts, s[start:])
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,168 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package receiver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/rs/zerolog"
+)
+
+func TestParseInfluxLine(t *testing.T) {
+	t.Log("Testing parseInfluxLine")
+
+	t.Log("\tvalid")
+	{
+		m, err := parseInfluxLine(`cpu\ load,host=web\,1,region=us\=east idle=99.5,user=3i,ctx=12u,ok=t,state="a \"b\" c=d" 1590000000123456789`, time.Nanosecond)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		metricTags := []string{"host:web,1", "region:us=east"}
+		expect := tags.JSONMetrics{
+			"cpu load`idle":  {Tags: metricTags, Type: "n", Value: 99.5, Timestamp: 1590000000123},
+			"cpu load`user":  {Tags: metricTags, Type: "l", Value: int64(3), Timestamp: 1590000000123},
+			"cpu load`ctx":   {Tags: metricTags, Type: "L", Value: uint64(12), Timestamp: 1590000000123},
+			"cpu load`ok":    {Tags: metricTags, Type: "i", Value: int64(1), Timestamp: 1590000000123},
+			"cpu load`state": {Tags: metricTags, Type: "s", Value: `a "b" c=d`, Timestamp: 1590000000123},
+		}
+		if !reflect.DeepEqual(m, expect) {
+			t.Fatalf("unexpected metrics %#v", m)
+		}
+	}
+
+	t.Log("\tvalid, no tags or timestamp")
+	{
+		m, err := parseInfluxLine("mem used=1024i", time.Nanosecond)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if metric, ok := m["mem`used"]; !ok || metric.Value != int64(1024) || metric.Timestamp != 0 || len(metric.Tags) != 0 {
+			t.Fatalf("unexpected metrics %#v", m)
+		}
+	}
+
+	t.Log("\tprecision")
+	{
+		for unit, ts := range map[time.Duration]string{time.Second: "1590000000", time.Microsecond: "1590000000000000", time.Millisecond: "1590000000000"} {
+			m, err := parseInfluxLine("mem used=1 "+ts, unit)
+			if err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if m["mem`used"].Timestamp != 1590000000000 {
+				t.Fatalf("%s expected 1590000000000, got %d", unit, m["mem`used"].Timestamp)
+			}
+		}
+	}
+
+	tt := []string{
+		"mem",
+		"mem used=1 1590000000 extra",
+		",host=a used=1",
+		"mem,host used=1",
+		"mem,host= used=1",
+		"mem used",
+		"mem used=",
+		"mem used=abc",
+		"mem used=1.5i",
+		"mem used=-1u",
+		`mem used="abc`,
+		"mem used=1 soon",
+	}
+
+	for _, line := range tt {
+		t.Logf("\tinvalid (%s)", line)
+		if _, err := parseInfluxLine(line, time.Nanosecond); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestParseInflux(t *testing.T) {
+	t.Log("Testing ParseInflux")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	err := initCGM()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	_ = Flush()
+
+	metricName := func(name string, extra ...tags.Tag) string {
+		return tags.MetricNameWithStreamTags(name, append(tags.Tags{
+			tags.Tag{Category: "source", Value: "circonus-agent"},
+			tags.Tag{Category: "collector", Value: "write"},
+			tags.Tag{Category: "collector_id", Value: "telegraf"},
+		}, extra...))
+	}
+
+	t.Log("\tinvalid precision")
+	{
+		if err := ParseInflux("telegraf", "d", strings.NewReader("mem used=1")); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tinvalid line, nothing recorded")
+	{
+		if err := ParseInflux("telegraf", "", strings.NewReader("mem used=1\nmem used\n")); err == nil {
+			t.Fatal("expected error")
+		}
+		m := Flush()
+		if _, ok := (*m)[metricName("mem`used")]; ok {
+			t.Fatalf("expected no metrics, got %#v", m)
+		}
+	}
+
+	t.Log("\tvalid")
+	{
+		data := strings.Join([]string{
+			"# comment",
+			"cpu,cpu=cpu0 usage_idle=99.5",
+			"cpu,cpu=cpu1 usage_idle=97",
+			"",
+			"disk,path=/ used=1024i,status=\"ok\"",
+			"net bytes_recv=42i 1590000000123",
+		}, "\n")
+		if err := ParseInflux("telegraf", "ms", strings.NewReader(data)); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		m := Flush()
+
+		tt := []struct {
+			name  string
+			value interface{}
+		}{
+			{metricName("cpu`usage_idle", tags.Tag{Category: "cpu", Value: "cpu0"}), float64(99.5)},
+			{metricName("cpu`usage_idle", tags.Tag{Category: "cpu", Value: "cpu1"}), float64(97)},
+			{metricName("disk`used", tags.Tag{Category: "path", Value: "/"}), int64(1024)},
+			{metricName("disk`status", tags.Tag{Category: "path", Value: "/"}), "ok"},
+		}
+		for _, tst := range tt {
+			metric, ok := (*m)[tst.name]
+			if !ok {
+				t.Fatalf("expected metric %s, %#v", tst.name, m)
+			}
+			if metric.Value != tst.value {
+				t.Fatalf("%s expected %v, got %v", tst.name, tst.value, metric.Value)
+			}
+		}
+
+		metric, ok := (*m)[metricName("net`bytes_recv")]
+		if !ok {
+			t.Fatalf("expected metric net`bytes_recv, %#v", m)
+		}
+		if v, ts := sample.Unwrap(metric.Value); v != int64(42) || ts != 1590000000123 {
+			t.Fatalf("expected 42@1590000000123, got %v@%d", v, ts)
+		}
+	}
+}
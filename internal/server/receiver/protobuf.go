@@ -0,0 +1,133 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package receiver
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/circonus-labs/circonus-agent/internal/pbwire"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/pkg/errors"
+)
+
+// ProtobufMediaType is the Content-Type of protobuf encoded requests, the
+// schema is etc/receiver.proto
+const ProtobufMediaType = "application/x-protobuf"
+
+// ParseProtobuf handles incoming PUT/POST requests with a protobuf encoded
+// payload (Metrics message, see etc/receiver.proto)
+func ParseProtobuf(id string, data io.Reader) error {
+	if err := initCGM(); err != nil {
+		return err
+	}
+
+	buf, err := ioutil.ReadAll(data)
+	if err != nil {
+		return errors.Wrapf(err, "reading protobuf for %s", id)
+	}
+
+	tmp, err := decodeMetrics(buf)
+	if err != nil {
+		return errors.Wrapf(err, "parsing protobuf for %s", id)
+	}
+
+	record(id, tmp)
+	return nil
+}
+
+// decodeMetrics decodes a Metrics message into the same representation as
+// a JSON request, numeric values are kept as int64/uint64 (no float64 round
+// trip) and histogram samples as []histSample
+func decodeMetrics(buf []byte) (tags.JSONMetrics, error) {
+	ret := make(tags.JSONMetrics)
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		if f.Num != 1 || f.Wire != pbwire.Bytes {
+			return nil
+		}
+		name, metric, err := decodeMetric(f.B)
+		if err != nil {
+			return errors.Wrapf(err, "metric %d", len(ret))
+		}
+		if name == "" {
+			return errors.Errorf("metric %d, no name", len(ret))
+		}
+		ret[name] = metric
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// decodeMetric decodes a Metric message
+func decodeMetric(buf []byte) (string, tags.JSONMetric, error) {
+	var name string
+	var metric tags.JSONMetric
+	var samples []histSample
+
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		switch {
+		case f.Num == 1 && f.Wire == pbwire.Bytes:
+			name = string(f.B)
+		case f.Num == 2 && f.Wire == pbwire.Bytes:
+			metric.Type = string(f.B)
+		case f.Num == 3 && f.Wire == pbwire.Bytes:
+			metric.Tags = append(metric.Tags, string(f.B))
+		case f.Num == 4 && f.Wire == pbwire.Varint:
+			metric.Value = int64(f.U)
+		case f.Num == 5 && f.Wire == pbwire.Varint:
+			metric.Value = f.U
+		case f.Num == 6 && f.Wire == pbwire.Fixed64:
+			metric.Value = f.Double()
+		case f.Num == 7 && f.Wire == pbwire.Bytes:
+			metric.Value = string(f.B)
+		case f.Num == 8: // packed or unpacked
+			vals, err := pbwire.RepeatedFixed64(f)
+			if err != nil {
+				return errors.Wrap(err, "invalid samples")
+			}
+			for _, v := range vals {
+				samples = append(samples, histSample{value: math.Float64frombits(v)})
+			}
+		case f.Num == 9 && f.Wire == pbwire.Bytes:
+			s, err := decodeBucket(f.B)
+			if err != nil {
+				return err
+			}
+			samples = append(samples, s)
+		case f.Num == 10 && f.Wire == pbwire.Varint:
+			metric.Timestamp = f.U
+		}
+		return nil
+	})
+	if err != nil {
+		return "", metric, err
+	}
+
+	if metric.Value == nil && len(samples) > 0 {
+		metric.Value = samples
+	}
+
+	return name, metric, nil
+}
+
+// decodeBucket decodes a Bucket message
+func decodeBucket(buf []byte) (histSample, error) {
+	s := histSample{bucket: true}
+	err := pbwire.Decode(buf, func(f pbwire.Field) error {
+		switch {
+		case f.Num == 1 && f.Wire == pbwire.Fixed64:
+			s.value = f.Double()
+		case f.Num == 2 && f.Wire == pbwire.Varint:
+			s.count = int64(f.U)
+		}
+		return nil
+	})
+	return s, err
+}
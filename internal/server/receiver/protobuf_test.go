@@ -0,0 +1,152 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package receiver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/pbwire"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	"github.com/rs/zerolog"
+)
+
+// minimal protobuf encoding helpers for the tests
+
+func pbUvarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func pbKey(field, wire int) []byte {
+	return pbUvarint(uint64(field<<3 | wire))
+}
+
+func pbVarint(field int, v uint64) []byte {
+	return append(pbKey(field, pbwire.Varint), pbUvarint(v)...)
+}
+
+func pbDouble(field int, v float64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+	return append(pbKey(field, pbwire.Fixed64), b...)
+}
+
+func pbBytes(field int, parts ...[]byte) []byte {
+	v := bytes.Join(parts, nil)
+	b := append(pbKey(field, pbwire.Bytes), pbUvarint(uint64(len(v)))...)
+	return append(b, v...)
+}
+
+func pbString(field int, v string) []byte {
+	return pbBytes(field, []byte(v))
+}
+
+func TestParseProtobuf(t *testing.T) {
+	t.Log("Testing ParseProtobuf")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	err := initCGM()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	_ = Flush()
+
+	metricName := func(name string, extra ...tags.Tag) string {
+		return tags.MetricNameWithStreamTags(name, append(tags.Tags{
+			tags.Tag{Category: "source", Value: "circonus-agent"},
+			tags.Tag{Category: "collector", Value: "write"},
+			tags.Tag{Category: "collector_id", Value: "testpb"},
+		}, extra...))
+	}
+
+	t.Log("\tinvalid (truncated)")
+	{
+		data := pbBytes(1, pbString(1, "test"))
+		if err := ParseProtobuf("testpb", bytes.NewReader(data[:len(data)-1])); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tinvalid (no name)")
+	{
+		data := pbBytes(1, pbString(2, "L"), pbVarint(5, 1))
+		if err := ParseProtobuf("testpb", bytes.NewReader(data)); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("\tno metrics")
+	{
+		if err := ParseProtobuf("testpb", bytes.NewReader([]byte{})); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	t.Log("\tvalid")
+	{
+		neg := int64(-5)
+		data := bytes.Join([][]byte{
+			pbBytes(1, pbString(1, "int"), pbString(2, "l"), pbVarint(4, uint64(neg))),
+			pbBytes(1, pbString(1, "uint"), pbString(2, "L"), pbVarint(5, math.MaxUint64)),
+			pbBytes(1, pbString(1, "float"), pbString(2, "n"), pbDouble(6, 1.5), pbString(3, "abc:123")),
+			pbBytes(1, pbString(1, "text"), pbString(2, "s"), pbString(7, "foo")),
+			pbBytes(1, pbString(1, "stamped"), pbString(2, "I"), pbVarint(5, 10), pbVarint(10, 1590000000123)),
+			pbBytes(1, pbString(1, "hist"), pbString(2, "h"),
+				pbBytes(8, pbDouble(1, 1)[1:], pbDouble(1, 2)[1:]), // packed samples
+				pbBytes(9, pbDouble(1, 3), pbVarint(2, 4)),
+				pbVarint(99, 1)), // unknown field
+		}, nil)
+		if err := ParseProtobuf("testpb", bytes.NewReader(data)); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+
+		m := Flush()
+
+		tt := []struct {
+			name  string
+			value interface{}
+		}{
+			{metricName("int"), int64(-5)},
+			{metricName("uint"), uint64(math.MaxUint64)},
+			{metricName("float", tags.Tag{Category: "abc", Value: "123"}), float64(1.5)},
+			{metricName("text"), "foo"},
+		}
+		for _, tst := range tt {
+			metric, ok := (*m)[tst.name]
+			if !ok {
+				t.Fatalf("expected metric %s, %#v", tst.name, m)
+			}
+			if metric.Value != tst.value {
+				t.Fatalf("%s expected %v, got %v", tst.name, tst.value, metric.Value)
+			}
+		}
+
+		metric, ok := (*m)[metricName("stamped")]
+		if !ok {
+			t.Fatalf("expected metric stamped, %#v", m)
+		}
+		if v, ts := sample.Unwrap(metric.Value); v != uint32(10) || ts != 1590000000123 {
+			t.Fatalf("expected 10@1590000000123, got %v@%d", v, ts)
+		}
+
+		metric, ok = (*m)[metricName("hist")]
+		if !ok {
+			t.Fatalf("expected metric hist, %#v", m)
+		}
+		hist := strings.Join(metric.Value.([]string), ",")
+		for _, expect := range []string{"H[1.0e+00]=1", "H[2.0e+00]=1", "H[3.0e+00]=4"} {
+			if !strings.Contains(hist, expect) {
+				t.Fatalf("expected (%s) got (%s)", expect, hist)
+			}
+		}
+	}
+}
@@ -15,7 +15,10 @@ import (
 	"sync"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/merge"
+	"github.com/circonus-labs/circonus-agent/internal/pending"
 	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/pkg/errors"
@@ -27,6 +30,10 @@ import (
 var (
 	metricsmu        sync.Mutex
 	metrics          *cgm.CirconusMetrics
+	window           *merge.Window    // metrics written since the last flush (see merge policy)
+	limiter          *pending.Limiter // series written since the last flush (see max pending series)
+	stampedmu        sync.Mutex
+	stamped          cgm.Metrics // metrics written with an explicit timestamp since the last flush
 	baseTags         []string
 	histogramRx      *regexp.Regexp // encoded histogram regular express (e.g. coming from a cgm put to /write)
 	histogramRxNames []string
@@ -69,6 +76,8 @@ func initCGM() error {
 	}
 
 	metrics = hm
+	window = merge.NewWindow(viper.GetString(config.KeyMetricMerge))
+	limiter = pending.NewLimiter(viper.GetUint(config.KeyMaxPendingSeries))
 
 	baseTags = tags.GetBaseTags()
 	baseTags = append(baseTags, []string{
@@ -82,7 +91,22 @@ func initCGM() error {
 // Flush returns current metrics
 func Flush() *cgm.Metrics {
 	_ = initCGM()
-	return metrics.FlushMetrics()
+	conflicts := window.Reset()
+	dropped := limiter.Reset()
+	m := metrics.FlushMetrics()
+	stampedmu.Lock()
+	for name, metric := range stamped {
+		(*m)[name] = metric
+	}
+	stamped = nil
+	stampedmu.Unlock()
+	if window.Policy() == merge.Reject {
+		(*m)[tags.MetricNameWithStreamTags(merge.ConflictMetric, tags.FromList(baseTags))] = cgm.Metric{Type: "L", Value: conflicts}
+	}
+	if limiter != nil {
+		(*m)[tags.MetricNameWithStreamTags(pending.DroppedMetric, tags.FromList(baseTags))] = cgm.Metric{Type: "L", Value: dropped}
+	}
+	return m
 }
 
 // Parse handles incoming PUT/POST requests
@@ -99,6 +123,12 @@ func Parse(id string, data io.Reader) error {
 		return errors.Wrapf(err, "parsing json for %s", id)
 	}
 
+	record(id, tmp)
+	return nil
+}
+
+// record adds the metrics of a request (JSON or protobuf)
+func record(id string, tmp tags.JSONMetrics) {
 	for name, metric := range tmp {
 		metricName := name
 
@@ -108,29 +138,45 @@ func Parse(id string, data io.Reader) error {
 		tagList = append(tagList, "collector_id:"+id)
 		metricTags := tags.FromList(tagList)
 
+		if !limiter.Allow(metricName, metricTags) {
+			logger.Debug().Str("metric", metricName).Msg("dropped, max pending series reached")
+			continue
+		}
+
+		if metric.Timestamp > 0 {
+			if err := sample.Validate(metric.Timestamp); err != nil {
+				logger.Warn().Err(err).Str("metric", metricName).Msg("ignoring metric")
+				continue
+			}
+			if addStamped(metricName, metricTags, metric) {
+				continue
+			}
+			logger.Debug().Str("metric", metricName).Msg("histogram samples are aggregated, ignoring timestamp")
+		}
+
 		switch metric.Type {
 		case "i":
 			if v := parseInt32(metricName, metric); v != nil {
-				metrics.AddGaugeWithTags(metricName, metricTags, *v)
+				setGauge(metricName, metricTags, "i", *v)
 			}
 		case "I":
 			if v := parseUint32(metricName, metric); v != nil {
-				metrics.AddGaugeWithTags(metricName, metricTags, *v)
+				setGauge(metricName, metricTags, "I", *v)
 			}
 		case "l":
 			if v := parseInt64(metricName, metric); v != nil {
-				metrics.AddGaugeWithTags(metricName, metricTags, *v)
+				setGauge(metricName, metricTags, "l", *v)
 			}
 		case "L":
 			if v := parseUint64(metricName, metric); v != nil {
-				metrics.AddGaugeWithTags(metricName, metricTags, *v)
+				setGauge(metricName, metricTags, "L", *v)
 			}
 		case "h":
 			fallthrough
 		case "n":
 			v, isHist := parseFloat(metricName, metric)
 			if v != nil {
-				metrics.AddGaugeWithTags(metricName, metricTags, *v)
+				setGauge(metricName, metricTags, "n", *v)
 			} else if isHist {
 				samples := parseHistogram(metricName, metric)
 				if samples != nil && len(*samples) > 0 {
@@ -144,13 +190,87 @@ func Parse(id string, data io.Reader) error {
 				}
 			}
 		case "s":
-			metrics.SetTextWithTags(metricName, metricTags, fmt.Sprintf("%v", metric.Value))
+			if window.Write(metricName, metricTags, "s") != merge.Drop {
+				metrics.SetTextWithTags(metricName, metricTags, fmt.Sprintf("%v", metric.Value))
+			}
 		default:
 			logger.Warn().Str("metric", metricName).Str("type", metric.Type).Str("pkg", "receiver").Msg("unsupported metric type")
 		}
 	}
+}
 
-	return nil
+// addStamped saves a metric with an explicit timestamp, the value is not
+// aggregated (cgm records values at flush time) - the most recent write of a
+// metric since the last flush is used. Returns false for histogram samples.
+func addStamped(metricName string, metricTags cgm.Tags, metric tags.JSONMetric) bool {
+	var v interface{}
+	switch metric.Type {
+	case "i":
+		if pv := parseInt32(metricName, metric); pv != nil {
+			v = *pv
+		}
+	case "I":
+		if pv := parseUint32(metricName, metric); pv != nil {
+			v = *pv
+		}
+	case "l":
+		if pv := parseInt64(metricName, metric); pv != nil {
+			v = *pv
+		}
+	case "L":
+		if pv := parseUint64(metricName, metric); pv != nil {
+			v = *pv
+		}
+	case "h", "n":
+		pv, isHist := parseFloat(metricName, metric)
+		if isHist {
+			return false
+		}
+		if pv != nil {
+			v = *pv
+		}
+	case "s":
+		v = fmt.Sprintf("%v", metric.Value)
+	default:
+		logger.Warn().Str("metric", metricName).Str("type", metric.Type).Str("pkg", "receiver").Msg("unsupported metric type")
+		return true
+	}
+	if v == nil {
+		return true // parse error, already logged
+	}
+	if window.Write(metricName, metricTags, "ts") == merge.Drop {
+		logger.Debug().Str("metric", metricName).Msg("rejected, metric already written since last flush")
+		return true
+	}
+
+	mtype := metric.Type
+	if mtype == "h" {
+		mtype = "n"
+	}
+
+	stampedmu.Lock()
+	if stamped == nil {
+		stamped = make(cgm.Metrics)
+	}
+	stamped[tags.MetricNameWithStreamTags(metricName, metricTags)] = cgm.Metric{Type: mtype, Value: sample.Stamp(v, metric.Timestamp)}
+	stampedmu.Unlock()
+
+	return true
+}
+
+// setGauge writes a gauge value, a gauge written more than once between flushes
+// is replaced, added to or dropped depending on the merge policy (histogram
+// samples always accumulate). valueType identifies the go type of v, values
+// are only added to a gauge holding the same type.
+func setGauge(metricName string, metricTags cgm.Tags, valueType string, v interface{}) {
+	switch window.Write(metricName, metricTags, valueType) {
+	case merge.Add:
+		metrics.AddGaugeWithTags(metricName, metricTags, v)
+	case merge.Replace:
+		metrics.SetGaugeWithTags(metricName, metricTags, v)
+	case merge.Drop:
+		logger.Debug().Str("metric", metricName).Msg("rejected, metric already written since last flush")
+	}
 }
 
 func parseInt32(metricName string, metric tags.JSONMetric) *int32 {
@@ -158,6 +278,12 @@ func parseInt32(metricName string, metric tags.JSONMetric) *int32 {
 	case float64:
 		v := int32(metric.Value.(float64))
 		return &v
+	case int64: // protobuf
+		v := int32(metric.Value.(int64))
+		return &v
+	case uint64: // protobuf
+		v := int32(metric.Value.(uint64))
+		return &v
 	case string:
 		v, err := strconv.ParseInt(metric.Value.(string), 10, 32)
 		if err == nil {
@@ -184,6 +310,12 @@ func parseUint32(metricName string, metric tags.JSONMetric) *uint32 {
 	case float64:
 		v := uint32(metric.Value.(float64))
 		return &v
+	case int64: // protobuf
+		v := uint32(metric.Value.(int64))
+		return &v
+	case uint64: // protobuf
+		v := uint32(metric.Value.(uint64))
+		return &v
 	case string:
 		v, err := strconv.ParseUint(metric.Value.(string), 10, 32)
 		if err == nil {
@@ -210,6 +342,12 @@ func parseInt64(metricName string, metric tags.JSONMetric) *int64 {
 	case float64:
 		v := int64(metric.Value.(float64))
 		return &v
+	case int64: // protobuf
+		v := int64(metric.Value.(int64))
+		return &v
+	case uint64: // protobuf
+		v := int64(metric.Value.(uint64))
+		return &v
 	case string:
 		v, err := strconv.ParseInt(metric.Value.(string), 10, 64)
 		if err == nil {
@@ -236,6 +374,12 @@ func parseUint64(metricName string, metric tags.JSONMetric) *uint64 {
 	case float64:
 		v := uint64(metric.Value.(float64))
 		return &v
+	case int64: // protobuf
+		v := uint64(metric.Value.(int64))
+		return &v
+	case uint64: // protobuf
+		v := uint64(metric.Value.(uint64))
+		return &v
 	case string:
 		v, err := strconv.ParseUint(metric.Value.(string), 10, 64)
 		if err == nil {
@@ -262,7 +406,13 @@ func parseFloat(metricName string, metric tags.JSONMetric) (*float64, bool) {
 	case float64:
 		v := metric.Value.(float64)
 		return &v, false
-	case []interface{}: // treat as histogram
+	case int64: // protobuf
+		v := float64(metric.Value.(int64))
+		return &v, false
+	case uint64: // protobuf
+		v := float64(metric.Value.(uint64))
+		return &v, false
+	case []interface{}, []histSample: // treat as histogram
 		return nil, true
 	case string:
 		v, err := strconv.ParseFloat(metric.Value.(string), 64)
@@ -293,6 +443,8 @@ type histSample struct {
 
 func parseHistogram(metricName string, metric tags.JSONMetric) *[]histSample {
 	switch t := metric.Value.(type) {
+	case []histSample: // protobuf
+		return &t
 	case []interface{}:
 		ret := make([]histSample, 0, len(metric.Value.([]interface{})))
 		for idx, v := range metric.Value.([]interface{}) {
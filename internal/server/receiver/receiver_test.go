@@ -14,6 +14,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/circonus-labs/circonus-agent/internal/merge"
+	"github.com/circonus-labs/circonus-agent/internal/pending"
+	"github.com/circonus-labs/circonus-agent/internal/sample"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	"github.com/rs/zerolog"
 )
@@ -386,6 +389,190 @@ func TestParse(t *testing.T) {
 
 }
 
+func TestParseMerge(t *testing.T) {
+	t.Log("Testing Parse merge policies")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	err := initCGM()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	defer func() { window = merge.NewWindow(merge.Last) }()
+
+	metricName := tags.MetricNameWithStreamTags("test", tags.Tags{
+		tags.Tag{Category: "source", Value: "circonus-agent"},
+		tags.Tag{Category: "collector", Value: "write"},
+		tags.Tag{Category: "collector_id", Value: "testm"},
+	})
+	conflictName := tags.MetricNameWithStreamTags(merge.ConflictMetric, tags.FromList(baseTags))
+
+	write := func(data string) {
+		if err := Parse("testm", ioutil.NopCloser(strings.NewReader(data))); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	tt := []struct {
+		policy    string
+		writes    []string
+		expect    interface{}
+		conflicts interface{}
+	}{
+		{merge.Last, []string{`{"test": {"_type": "L", "_value": 1}}`, `{"test": {"_type": "L", "_value": 2}}`}, uint64(2), nil},
+		{merge.Sum, []string{`{"test": {"_type": "L", "_value": 1}}`, `{"test": {"_type": "L", "_value": 2}}`}, uint64(3), nil},
+		{merge.Sum, []string{`{"test": {"_type": "L", "_value": 1}}`, `{"test": {"_type": "n", "_value": 2.5}}`}, float64(2.5), nil},
+		{merge.Reject, []string{`{"test": {"_type": "L", "_value": 1}}`, `{"test": {"_type": "L", "_value": 2}}`}, uint64(1), uint64(1)},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s", tst.policy)
+		window = merge.NewWindow(tst.policy)
+		for _, data := range tst.writes {
+			write(data)
+		}
+		m := Flush()
+		metric, ok := (*m)[metricName]
+		if !ok {
+			t.Fatalf("expected metric '%s', %#v", metricName, m)
+		}
+		if metric.Value != tst.expect {
+			t.Fatalf("expected %v (%T), got %v (%T)", tst.expect, tst.expect, metric.Value, metric.Value)
+		}
+		conflicts, ok := (*m)[conflictName]
+		if tst.conflicts == nil {
+			if ok {
+				t.Fatalf("unexpected metric '%s'", conflictName)
+			}
+			continue
+		}
+		if conflicts.Value != tst.conflicts {
+			t.Fatalf("expected %v conflicts, got %v", tst.conflicts, conflicts.Value)
+		}
+	}
+}
+
+func TestParsePendingLimit(t *testing.T) {
+	t.Log("Testing Parse max pending series")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	err := initCGM()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	limiter = pending.NewLimiter(2)
+	defer func() { limiter = nil }()
+
+	droppedName := tags.MetricNameWithStreamTags(pending.DroppedMetric, tags.FromList(baseTags))
+	metricName := func(name string) string {
+		return tags.MetricNameWithStreamTags(name, tags.Tags{
+			tags.Tag{Category: "source", Value: "circonus-agent"},
+			tags.Tag{Category: "collector", Value: "write"},
+			tags.Tag{Category: "collector_id", Value: "testp"},
+		})
+	}
+
+	data := `{"a": {"_type": "L", "_value": 1}, "b": {"_type": "L", "_value": 2}}`
+	if err := Parse("testp", ioutil.NopCloser(strings.NewReader(data))); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	data = `{"a": {"_type": "L", "_value": 3}, "c": {"_type": "L", "_value": 4}}`
+	if err := Parse("testp", ioutil.NopCloser(strings.NewReader(data))); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	m := Flush()
+	if metric, ok := (*m)[metricName("a")]; !ok || metric.Value != uint64(3) {
+		t.Fatalf("expected a=3, got %#v", m)
+	}
+	if _, ok := (*m)[metricName("b")]; !ok {
+		t.Fatalf("expected b, got %#v", m)
+	}
+	if _, ok := (*m)[metricName("c")]; ok {
+		t.Fatalf("expected c dropped, got %#v", m)
+	}
+	if dropped, ok := (*m)[droppedName]; !ok || dropped.Value != uint64(1) {
+		t.Fatalf("expected 1 dropped, got %#v", dropped)
+	}
+
+	data = `{"c": {"_type": "L", "_value": 5}}`
+	if err := Parse("testp", ioutil.NopCloser(strings.NewReader(data))); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	m = Flush()
+	if _, ok := (*m)[metricName("c")]; !ok {
+		t.Fatalf("expected c after flush, got %#v", m)
+	}
+	if dropped := (*m)[droppedName]; dropped.Value != uint64(0) {
+		t.Fatalf("expected 0 dropped, got %#v", dropped)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	t.Log("Testing Parse w/timestamps")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	err := initCGM()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	metricName := func(name string) string {
+		return tags.MetricNameWithStreamTags(name, tags.Tags{
+			tags.Tag{Category: "source", Value: "circonus-agent"},
+			tags.Tag{Category: "collector", Value: "write"},
+			tags.Tag{Category: "collector_id", Value: "testts"},
+		})
+	}
+
+	data := `{
+		"gauge": {"_type": "L", "_value": 10, "_ts": 1590000000123},
+		"text": {"_type": "s", "_value": "foo", "_ts": 1590000000456},
+		"hist": {"_type": "h", "_value": [1, 2], "_ts": 1590000000789},
+		"future": {"_type": "L", "_value": 10, "_ts": 1590000000123000}
+	}`
+	if err := Parse("testts", ioutil.NopCloser(strings.NewReader(data))); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	m := Flush()
+
+	tt := []struct {
+		name  string
+		value interface{}
+		ts    uint64
+	}{
+		{"gauge", uint64(10), 1590000000123},
+		{"text", "foo", 1590000000456},
+	}
+	for _, tst := range tt {
+		metric, ok := (*m)[metricName(tst.name)]
+		if !ok {
+			t.Fatalf("expected metric %s, %#v", tst.name, m)
+		}
+		v, ts := sample.Unwrap(metric.Value)
+		if v != tst.value || ts != tst.ts {
+			t.Fatalf("expected %v@%d, got %v@%d", tst.value, tst.ts, v, ts)
+		}
+	}
+
+	if metric, ok := (*m)[metricName("hist")]; !ok {
+		t.Fatalf("expected metric hist, %#v", m)
+	} else if _, ts := sample.Unwrap(metric.Value); ts != 0 {
+		t.Fatalf("expected no timestamp for histogram, got %d", ts)
+	}
+
+	if _, ok := (*m)[metricName("future")]; ok {
+		t.Fatal("expected metric with invalid timestamp to be ignored")
+	}
+
+	if m := Flush(); len(*m) != 0 {
+		t.Fatalf("expected 0 metrics after flush, got %#v", m)
+	}
+}
+
 func createMetric(t string, v interface{}) tags.JSONMetric {
 
 	// convert native literal types to json then back to
@@ -0,0 +1,96 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// tombstoneValue is emitted once for a retired series (explicit null)
+const tombstoneValue = "[[null]]"
+
+// seriesRetirement tracks when each series of a source with a metric ttl was
+// last reported, a series not reported within the ttl is retired (forgotten)
+// and optionally flagged once with a tombstone - the series with a null value
+type seriesRetirement struct {
+	ttls       map[string]time.Duration
+	tombstones bool
+	series     map[string]map[string]seriesSeen // source -> metric name (with stream tags)
+	sync.Mutex
+}
+
+type seriesSeen struct {
+	last  time.Time
+	mtype string
+}
+
+// newSeriesRetirement returns nil if no source has a metric ttl
+func newSeriesRetirement(ttls map[string]time.Duration, tombstones bool) *seriesRetirement {
+	if len(ttls) == 0 {
+		return nil
+	}
+	return &seriesRetirement{
+		ttls:       ttls,
+		tombstones: tombstones,
+		series:     make(map[string]map[string]seriesSeen),
+	}
+}
+
+// seen records the series reported by a source
+func (sr *seriesRetirement) seen(source string, metrics *cgm.Metrics, ts time.Time) {
+	if sr == nil || metrics == nil {
+		return
+	}
+	if _, ok := sr.ttls[source]; !ok {
+		return
+	}
+
+	sr.Lock()
+	defer sr.Unlock()
+
+	series, ok := sr.series[source]
+	if !ok {
+		series = make(map[string]seriesSeen, len(*metrics))
+		sr.series[source] = series
+	}
+	for name, metric := range *metrics {
+		series[name] = seriesSeen{last: ts, mtype: metric.Type}
+	}
+}
+
+// apply retires the series not reported within their source's ttl, adding a
+// tombstone for each if enabled, returns the number of series retired
+func (sr *seriesRetirement) apply(metrics *cgm.Metrics, now time.Time) int {
+	if sr == nil || metrics == nil {
+		return 0
+	}
+
+	sr.Lock()
+	defer sr.Unlock()
+
+	retired := 0
+	for source, series := range sr.series {
+		ttl := sr.ttls[source]
+		for name, s := range series {
+			if now.Sub(s.last) <= ttl {
+				continue
+			}
+			delete(series, name)
+			retired++
+			if !sr.tombstones {
+				continue
+			}
+			if _, ok := (*metrics)[name]; !ok {
+				(*metrics)[name] = cgm.Metric{Type: s.mtype, Value: tombstoneValue}
+			}
+		}
+	}
+
+	return retired
+}
@@ -0,0 +1,78 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestSeriesRetirement(t *testing.T) {
+	t.Log("Testing seriesRetirement")
+
+	t.Log("\tdisabled")
+	{
+		sr := newSeriesRetirement(nil, true)
+		if sr != nil {
+			t.Fatal("expected nil")
+		}
+		sr.seen("plugins", &cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}, time.Now())
+		metrics := cgm.Metrics{}
+		if n := sr.apply(&metrics, time.Now()); n != 0 || len(metrics) != 0 {
+			t.Fatalf("expected nothing retired, got %d %v", n, metrics)
+		}
+	}
+
+	start := time.Now()
+	ttls := map[string]time.Duration{"plugins": 5 * time.Minute}
+
+	t.Log("\ttombstones")
+	{
+		sr := newSeriesRetirement(ttls, true)
+		sr.seen("plugins", &cgm.Metrics{
+			"disk`sda`used": cgm.Metric{Type: "L", Value: uint64(1)},
+			"disk`sdb`used": cgm.Metric{Type: "L", Value: uint64(2)},
+		}, start)
+		sr.seen("statsd", &cgm.Metrics{"foo": cgm.Metric{Type: "n", Value: 1.5}}, start) // no ttl, not tracked
+		sr.seen("plugins", &cgm.Metrics{"disk`sda`used": cgm.Metric{Type: "L", Value: uint64(1)}}, start.Add(4*time.Minute))
+
+		metrics := cgm.Metrics{}
+		if n := sr.apply(&metrics, start.Add(4*time.Minute)); n != 0 || len(metrics) != 0 {
+			t.Fatalf("expected nothing retired within ttl, got %d %v", n, metrics)
+		}
+
+		if n := sr.apply(&metrics, start.Add(6*time.Minute)); n != 1 {
+			t.Fatalf("expected 1 retired, got %d", n)
+		}
+		if m, ok := metrics["disk`sdb`used"]; !ok || m.Type != "L" || m.Value != tombstoneValue {
+			t.Fatalf("expected sdb tombstone, got %v", metrics)
+		}
+		if len(metrics) != 1 {
+			t.Fatalf("expected only sdb tombstone, got %v", metrics)
+		}
+
+		metrics = cgm.Metrics{}
+		if n := sr.apply(&metrics, start.Add(7*time.Minute)); n != 0 || len(metrics) != 0 {
+			t.Fatalf("expected tombstone only once, got %d %v", n, metrics)
+		}
+
+		if n := sr.apply(&metrics, start.Add(10*time.Minute)); n != 1 {
+			t.Fatalf("expected sda retired, got %d", n)
+		}
+	}
+
+	t.Log("\tno tombstones")
+	{
+		sr := newSeriesRetirement(ttls, false)
+		sr.seen("plugins", &cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}, start)
+		metrics := cgm.Metrics{}
+		if n := sr.apply(&metrics, start.Add(6*time.Minute)); n != 1 || len(metrics) != 0 {
+			t.Fatalf("expected 1 retired without tombstone, got %d %v", n, metrics)
+		}
+	}
+}
@@ -7,7 +7,6 @@ package server
 
 import (
 	"expvar"
-	"fmt"
 	"net/http"
 
 	"github.com/maier/go-appstats"
@@ -22,8 +21,7 @@ func (s *Server) router(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		switch {
 		case r.URL.Path == "/health", r.URL.Path == "/health/":
-			w.WriteHeader(http.StatusOK)
-			_, _ = fmt.Fprintln(w, "Alive")
+			s.health(w, r)
 		case pluginPathRx.MatchString(r.URL.Path): // run plugin(s)
 			// s.logger.Debug().Msg("calling run")
 			s.run(w, r)
@@ -34,6 +32,16 @@ func (s *Server) router(w http.ResponseWriter, r *http.Request) {
 			expvar.Handler().ServeHTTP(w, r)
 		case promPathRx.MatchString(r.URL.Path): // output prom format...
 			s.promOutput(w)
+		case metricsPathRx.MatchString(r.URL.Path): // prometheus exposition format
+			s.promExposition(w)
+		case collectorsRx.MatchString(r.URL.Path): // builtin collector status
+			s.collectors(w)
+		case flushesPathRx.MatchString(r.URL.Path): // recent flushes
+			s.debugFlushes(w, r)
+		case proxyPathRx.MatchString(r.URL.Path): // local exporter proxy
+			s.proxyExporter(w, r)
+		case uiPathRx.MatchString(r.URL.Path): // status page
+			s.statusUI(w, r)
 		default:
 			_ = appstats.IncrementInt("requests_bad")
 			s.logger.Warn().Str("method", r.Method).Str("url", r.URL.String()).Msg("not found")
@@ -43,10 +51,12 @@ func (s *Server) router(w http.ResponseWriter, r *http.Request) {
 		fallthrough
 	case "PUT":
 		switch {
-		case writePathRx.MatchString(r.URL.Path):
+		case writePathRx.MatchString(r.URL.Path), dbWritePathRx.MatchString(r.URL.Path):
 			s.write(w, r)
 		case promPathRx.MatchString(r.URL.Path):
 			s.promReceiver(w, r)
+		case collectorRx.MatchString(r.URL.Path):
+			s.setCollectorState(w, r)
 		default:
 			_ = appstats.IncrementInt("requests_bad")
 			s.logger.Warn().Str("method", r.Method).Str("url", r.URL.String()).Msg("not found")
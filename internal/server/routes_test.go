@@ -41,7 +41,7 @@ func TestRouter(t *testing.T) {
 		}
 
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, c, nil, nil, nil)
+		s, err := New(ctx, c, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -90,7 +90,7 @@ func TestRouter(t *testing.T) {
 		}
 
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, c, b, p, nil)
+		s, err := New(ctx, c, b, p, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -128,7 +128,7 @@ func TestRouter(t *testing.T) {
 		}
 
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, c, b, p, nil)
+		s, err := New(ctx, c, b, p, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -185,7 +185,7 @@ func TestRouter(t *testing.T) {
 		}
 
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, c, b, p, nil)
+		s, err := New(ctx, c, b, p, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -212,7 +212,7 @@ func TestRouter(t *testing.T) {
 		}
 
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, c, nil, nil, nil)
+		s, err := New(ctx, c, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -238,7 +238,7 @@ func TestRouter(t *testing.T) {
 		}
 
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, c, nil, nil, nil)
+		s, err := New(ctx, c, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -0,0 +1,146 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+const (
+	// routeHeader is the request header with the metric route of a check
+	// other than the agent's check, set on the requests of the reverse
+	// connections of the routed checks
+	routeHeader = "X-Circonus-Route"
+	// routeParam is the query parameter with the metric route, for brokers
+	// polling the agent directly (e.g. /run?route=456)
+	routeParam = "route"
+)
+
+// metricRouter sends the metrics matching the check routes (see --check-routes)
+// to other check bundles. Each collection is split by destination and the
+// share of each destination is held until the destination requests metrics,
+// so every destination receives the metrics collected since its last request
+// regardless of which destination triggered the collection.
+type metricRouter struct {
+	routes  []config.CheckRoute
+	dests   map[string]bool         // route ids
+	pending map[string]*cgm.Metrics // by route id, "" is the agent's check
+	sync.Mutex
+}
+
+// newMetricRouter returns nil if there are no check routes
+func newMetricRouter(routes []config.CheckRoute) *metricRouter {
+	if len(routes) == 0 {
+		return nil
+	}
+	mr := &metricRouter{
+		routes:  routes,
+		dests:   make(map[string]bool, len(routes)),
+		pending: make(map[string]*cgm.Metrics, len(routes)+1),
+	}
+	for _, route := range routes {
+		mr.dests[route.ID] = true
+	}
+	return mr
+}
+
+// requestedRoute returns the metric route of a request, "" for the agent's check
+func requestedRoute(r *http.Request) string {
+	if route := r.Header.Get(routeHeader); route != "" {
+		return strings.TrimSpace(route)
+	}
+	return strings.TrimSpace(r.URL.Query().Get(routeParam))
+}
+
+// valid determines if the route is known, "" (the agent's check) is always valid
+func (mr *metricRouter) valid(route string) bool {
+	if route == "" {
+		return true
+	}
+	if mr == nil {
+		return false
+	}
+	return mr.dests[route]
+}
+
+// destination returns the route id of the first route matching the metric,
+// "" if no route matches
+func (mr *metricRouter) destination(name string) string {
+	base, tagList, _ := tags.SplitMetricStreamTags(name)
+	for _, route := range mr.routes {
+		if route.Name != nil {
+			if route.Name.MatchString(base) {
+				return route.ID
+			}
+			continue
+		}
+		for _, t := range tagList {
+			if strings.EqualFold(t.Category, route.TagCategory) && route.TagValue.MatchString(t.Value) {
+				return route.ID
+			}
+		}
+	}
+	return ""
+}
+
+// deliver holds the metrics of a full collection for each destination and
+// returns (and releases) the metrics held for the route. A metric collected again before
+// its destination requested metrics is replaced by the latest value. The
+// metrics passed are returned as-is if there are no check routes.
+func (mr *metricRouter) deliver(metrics *cgm.Metrics, route string) *cgm.Metrics {
+	if mr == nil {
+		return metrics
+	}
+
+	mr.Lock()
+	defer mr.Unlock()
+
+	for name, m := range *metrics {
+		dest := mr.destination(name)
+		held, ok := mr.pending[dest]
+		if !ok {
+			held = &cgm.Metrics{}
+			mr.pending[dest] = held
+		}
+		(*held)[name] = m
+	}
+
+	held, ok := mr.pending[route]
+	if !ok {
+		return &cgm.Metrics{}
+	}
+	delete(mr.pending, route)
+	return held
+}
+
+// unrouted returns the metrics sent to the agent's check, the metrics passed
+// are returned as-is if there are no check routes
+func (mr *metricRouter) unrouted(metrics *cgm.Metrics) *cgm.Metrics {
+	return mr.routed(metrics, "")
+}
+
+// routed returns the metrics of a collection sent to the route without
+// holding the others, for single item runs (e.g. /run/cpu) which are not
+// part of the collections delivered to the routes. The metrics passed are
+// returned as-is if there are no check routes.
+func (mr *metricRouter) routed(metrics *cgm.Metrics, route string) *cgm.Metrics {
+	if mr == nil {
+		return metrics
+	}
+	share := make(cgm.Metrics, len(*metrics))
+	for name, m := range *metrics {
+		if mr.destination(name) == route {
+			share[name] = m
+		}
+	}
+	return &share
+}
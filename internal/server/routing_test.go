@@ -0,0 +1,178 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestMetricRouter(t *testing.T) {
+	t.Log("Testing metricRouter")
+
+	t.Log("\tdisabled")
+	{
+		mr := newMetricRouter(nil)
+		if mr != nil {
+			t.Fatal("expected nil")
+		}
+		metrics := &cgm.Metrics{"foo": cgm.Metric{Type: "L", Value: uint64(1)}}
+		if m := mr.deliver(metrics, ""); m != metrics {
+			t.Fatalf("expected metrics as-is, got %v", m)
+		}
+		if m := mr.unrouted(metrics); m != metrics {
+			t.Fatalf("expected metrics as-is, got %v", m)
+		}
+		if !mr.valid("") {
+			t.Fatal("expected agent check route to be valid")
+		}
+		if mr.valid("456") {
+			t.Fatal("expected unknown route to be invalid")
+		}
+	}
+
+	mr := newMetricRouter([]config.CheckRoute{
+		{ID: "456", BundleID: "/check_bundle/456", TagCategory: "collector", TagValue: regexp.MustCompile(`^(audit|auth)$`)},
+		{ID: "789", BundleID: "/check_bundle/789", Name: regexp.MustCompile(`^security_`)},
+		{ID: "999", BundleID: "/check_bundle/999", Name: regexp.MustCompile(`^security_logins`)},
+	})
+
+	audit := tags.MetricNameWithStreamTags("events", tags.Tags{{Category: "collector", Value: "audit"}})
+	cpu := tags.MetricNameWithStreamTags("cpu", tags.Tags{{Category: "collector", Value: "cpu"}})
+	logins := tags.MetricNameWithStreamTags("security_logins", tags.Tags{{Category: "collector", Value: "auth"}})
+
+	t.Log("\tdestination")
+	{
+		tt := []struct {
+			name   string
+			expect string
+		}{
+			{audit, "456"},
+			{cpu, ""},
+			{logins, "456"}, // first matching route
+			{"security_denied", "789"},
+			{"security_logins", "789"},
+			{"uptime", ""},
+		}
+		for _, tst := range tt {
+			if dest := mr.destination(tst.name); dest != tst.expect {
+				t.Fatalf("expected %s destination %q, got %q", tst.name, tst.expect, dest)
+			}
+		}
+	}
+
+	metrics := &cgm.Metrics{
+		audit:             cgm.Metric{Type: "L", Value: uint64(1)},
+		cpu:               cgm.Metric{Type: "n", Value: 0.5},
+		"security_denied": cgm.Metric{Type: "L", Value: uint64(2)},
+		"uptime":          cgm.Metric{Type: "L", Value: uint64(3)},
+	}
+
+	t.Log("\tunrouted")
+	{
+		m := mr.unrouted(metrics)
+		if len(*m) != 2 {
+			t.Fatalf("expected 2 metrics, got %v", m)
+		}
+		if _, ok := (*m)[cpu]; !ok {
+			t.Fatalf("expected %s, got %v", cpu, m)
+		}
+		if len(*metrics) != 4 {
+			t.Fatalf("expected metrics passed to be unchanged, got %v", metrics)
+		}
+	}
+
+	t.Log("\trouted")
+	{
+		m := mr.routed(metrics, "456")
+		if _, ok := (*m)[audit]; !ok || len(*m) != 1 {
+			t.Fatalf("expected %s, got %v", audit, m)
+		}
+		if m := mr.deliver(&cgm.Metrics{}, "456"); len(*m) != 0 {
+			t.Fatalf("expected no metrics held, got %v", m)
+		}
+	}
+
+	t.Log("\tdeliver")
+	{
+		m := mr.deliver(metrics, "")
+		if len(*m) != 2 {
+			t.Fatalf("expected 2 metrics, got %v", m)
+		}
+		if _, ok := (*m)["uptime"]; !ok {
+			t.Fatalf("expected uptime, got %v", m)
+		}
+
+		// collected again before the route requested metrics, latest value held
+		again := &cgm.Metrics{audit: cgm.Metric{Type: "L", Value: uint64(5)}}
+		m = mr.deliver(again, "456")
+		if len(*m) != 1 {
+			t.Fatalf("expected 1 metric, got %v", m)
+		}
+		if v := (*m)[audit].Value; v != uint64(5) {
+			t.Fatalf("expected latest value 5, got %v", v)
+		}
+
+		m = mr.deliver(&cgm.Metrics{}, "789")
+		if _, ok := (*m)["security_denied"]; !ok || len(*m) != 1 {
+			t.Fatalf("expected security_denied, got %v", m)
+		}
+
+		// metrics released once delivered
+		m = mr.deliver(&cgm.Metrics{}, "789")
+		if len(*m) != 0 {
+			t.Fatalf("expected no metrics, got %v", m)
+		}
+		m = mr.deliver(&cgm.Metrics{}, "")
+		if len(*m) != 0 {
+			t.Fatalf("expected no metrics, got %v", m)
+		}
+	}
+
+	t.Log("\tvalid")
+	{
+		for _, route := range []string{"", "456", "789"} {
+			if !mr.valid(route) {
+				t.Fatalf("expected route %q to be valid", route)
+			}
+		}
+		if mr.valid("123") {
+			t.Fatal("expected unknown route to be invalid")
+		}
+	}
+}
+
+func TestRequestedRoute(t *testing.T) {
+	t.Log("Testing requestedRoute")
+
+	tt := []struct {
+		name   string
+		target string
+		header string
+		expect string
+	}{
+		{"none", "/", "", ""},
+		{"query", "/run?route=456", "", "456"},
+		{"header", "/", "789", "789"},
+		{"header and query", "/run?route=456", "789", "789"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s", tst.name)
+		r := httptest.NewRequest("GET", tst.target, nil)
+		if tst.header != "" {
+			r.Header.Set(routeHeader, tst.header)
+		}
+		if route := requestedRoute(r); route != tst.expect {
+			t.Fatalf("expected %q, got %q", tst.expect, route)
+		}
+	}
+}
@@ -15,10 +15,16 @@ import (
 	"sync"
 	"time"
 
+	"github.com/circonus-labs/circonus-agent/internal/audit"
 	"github.com/circonus-labs/circonus-agent/internal/builtins"
 	"github.com/circonus-labs/circonus-agent/internal/check"
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/failover"
+	"github.com/circonus-labs/circonus-agent/internal/graphite"
+	"github.com/circonus-labs/circonus-agent/internal/heartbeat"
+	"github.com/circonus-labs/circonus-agent/internal/hooks"
+	"github.com/circonus-labs/circonus-agent/internal/otlp"
 	"github.com/circonus-labs/circonus-agent/internal/plugins"
 	"github.com/circonus-labs/circonus-agent/internal/statsd"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
@@ -27,6 +33,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 type httpServer struct {
@@ -51,14 +58,37 @@ type sslServer struct {
 type Server struct {
 	group      *errgroup.Group
 	groupCtx   context.Context
+	accessLog  bool
+	traceSpans bool
 	builtins   *builtins.Builtins
 	check      *check.Check
+	hooks      *hooks.Hooks
+	failover   *failover.Resources
+	audit      *audit.Audit
+	heartbeat  *heartbeat.Heartbeat
 	logger     zerolog.Logger
+	pager      *runPager
+	delta      *deltaEncoder
 	plugins    *plugins.Plugins
+	runGroup   singleflight.Group
 	svrHTTP    []*httpServer
 	svrHTTPS   *sslServer
 	svrSockets []*socketServer
 	statsdSvr  *statsd.Server
+	otlpSvr    *otlp.Server
+	graphSvr   *graphite.Server
+	textMetric *textMetrics
+	writeDedup *writeDedup
+	sources    *sourceAges
+	maintain   *maintenanceGauge
+	retirement *seriesRetirement
+	outputs    outputNamings
+	routes     *metricRouter
+	flushes    *flushArchive
+	proxy      *exporterProxy
+	cors       *corsPolicy
+	statusPage bool
+	started    time.Time
 }
 
 type previousMetrics struct {
@@ -70,27 +100,152 @@ var (
 	pluginPathRx    = regexp.MustCompile("^/(run(/[a-zA-Z0-9_-]*)?)?$")
 	inventoryPathRx = regexp.MustCompile("^/inventory/?$")
 	writePathRx     = regexp.MustCompile("^/write/[a-zA-Z0-9_-]+$")
+	dbWritePathRx   = regexp.MustCompile("^/write$") // influxdb v1 write api, id is the db query parameter
 	statsPathRx     = regexp.MustCompile("^/stats/?$")
 	promPathRx      = regexp.MustCompile("^/prom/?$")
+	metricsPathRx   = regexp.MustCompile("^/metrics/?$")
+	collectorsRx    = regexp.MustCompile("^/collectors/?$")
+	collectorRx     = regexp.MustCompile("^/collectors/([a-zA-Z0-9_./-]+)/(enable|disable)$")
+	flushesPathRx   = regexp.MustCompile("^/debug/flushes/?$")
+	proxyPathRx     = regexp.MustCompile("^/proxy/([a-zA-Z0-9_-]+)/?$")
+	uiPathRx        = regexp.MustCompile("^/ui(/[a-z./]*)?$")
 	lastMetrics     = &previousMetrics{}
 	lastMetricsmu   sync.Mutex
 )
 
 // New creates a new instance of the listening servers
-func New(ctx context.Context, c *check.Check, b *builtins.Builtins, p *plugins.Plugins, ss *statsd.Server) (*Server, error) {
+func New(ctx context.Context, c *check.Check, b *builtins.Builtins, p *plugins.Plugins, ss *statsd.Server, ots *otlp.Server, gs *graphite.Server) (*Server, error) {
 	g, gctx := errgroup.WithContext(ctx)
 	s := Server{
-		group:     g,
-		groupCtx:  gctx,
-		logger:    log.With().Str("pkg", "server").Logger(),
-		builtins:  b,
-		plugins:   p,
-		statsdSvr: ss,
-		check:     c,
+		group:      g,
+		groupCtx:   gctx,
+		logger:     log.With().Str("pkg", "server").Logger(),
+		builtins:   b,
+		plugins:    p,
+		statsdSvr:  ss,
+		otlpSvr:    ots,
+		graphSvr:   gs,
+		check:      c,
+		pager:      newRunPager(viper.GetInt(config.KeyRunMaxResponseBytes)),
+		delta:      newDeltaEncoder(viper.GetBool(config.KeyRunDeltaEncoding)),
+		accessLog:  viper.GetBool(config.KeyLogAccess),
+		traceSpans: viper.GetBool(config.KeyLogTraceSpans),
+		cors:       newCORSPolicy(viper.GetStringSlice(config.KeyCORSOrigins)),
+		statusPage: viper.GetBool(config.KeyStatusUI),
+		started:    time.Now(),
 	}
 
+	acls, err := loadListenerACLs(viper.GetString(config.KeyListenACLFile))
+	if err != nil {
+		s.logger.Error().Err(err).Msg("loading listen acls")
+		return nil, errors.Wrap(err, "listen acl")
+	}
+
+	s.hooks, err = hooks.New(gctx, viper.GetString(config.KeyHooksFile))
+	if err != nil {
+		s.logger.Error().Err(err).Msg("loading hooks")
+		return nil, errors.Wrap(err, "hooks")
+	}
+
+	s.failover, err = failover.New()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("loading failover resources")
+		return nil, errors.Wrap(err, "failover resources")
+	}
+
+	var activePlugins []string
+	if p != nil {
+		activePlugins = p.List()
+	}
+	s.audit, err = audit.New(activePlugins)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("loading config audit")
+		return nil, errors.Wrap(err, "config audit")
+	}
+
+	s.heartbeat, err = heartbeat.New()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("loading heartbeat")
+		return nil, errors.Wrap(err, "heartbeat")
+	}
+
+	windows, err := config.MaintenanceWindows()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("loading maintenance windows")
+		return nil, errors.Wrap(err, "maintenance windows")
+	}
+	s.maintain = newMaintenanceGauge(windows, s.logger)
+
+	if maxAge := viper.GetString(config.KeyStaleSourceAge); maxAge != "" {
+		d, err := time.ParseDuration(maxAge)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("parsing stale source age")
+			return nil, errors.Wrap(err, "stale source age")
+		}
+		s.sources = newSourceAges(d, viper.GetStringSlice(config.KeyStaleSources))
+	}
+
+	ttls, err := config.MetricTTLs()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("parsing metric ttl")
+		return nil, errors.Wrap(err, "metric ttl")
+	}
+	s.retirement = newSeriesRetirement(ttls, viper.GetBool(config.KeyMetricTombstones))
+
+	outputTags, err := config.OutputTagSettings()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("parsing output tags")
+		return nil, errors.Wrap(err, "output tags")
+	}
+	s.outputs = newOutputNamings(outputTags)
+
+	routes, err := config.CheckRoutes()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("parsing check routes")
+		return nil, errors.Wrap(err, "check routes")
+	}
+	s.routes = newMetricRouter(routes)
+
+	if resend := viper.GetString(config.KeyTextMetricResend); resend != "" {
+		d, err := time.ParseDuration(resend)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("parsing text metric resend")
+			return nil, errors.Wrap(err, "text metric resend")
+		}
+		s.textMetric = newTextMetrics(d)
+	}
+
+	if ttl := viper.GetString(config.KeyWriteDedupTTL); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("parsing write dedup ttl")
+			return nil, errors.Wrap(err, "write dedup ttl")
+		}
+		s.writeDedup = newWriteDedup(d)
+	}
+
+	flushMaxAge, err := config.FlushArchiveMaxAge()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("parsing flush archive max age")
+		return nil, errors.Wrap(err, "flush archive")
+	}
+	s.flushes, err = newFlushArchive(viper.GetInt(config.KeyFlushArchiveCount), flushMaxAge, viper.GetString(config.KeyFlushArchiveDir), s.logger)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("loading flush archive")
+		return nil, errors.Wrap(err, "flush archive")
+	}
+
+	proxyTargets, err := config.ProxyTargets()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("parsing proxy targets")
+		return nil, errors.Wrap(err, "proxy targets")
+	}
+	s.proxy = newExporterProxy(proxyTargets)
+
 	// HTTP listener (1-n)
-	{
+	if viper.GetBool(config.KeyListenSocketOnly) {
+		s.logger.Info().Msg("socket only, tcp listener(s) disabled")
+	} else {
 		serverList := viper.GetStringSlice(config.KeyListen)
 		if len(serverList) == 0 {
 			serverList = []string{defaults.Listen}
@@ -102,11 +257,16 @@ func New(ctx context.Context, c *check.Check, b *builtins.Builtins, p *plugins.P
 				return nil, errors.Wrap(err, "HTTP Server")
 			}
 
+			acl := acls[ta.String()]
+			if acl != nil {
+				s.logger.Info().Str("listen", ta.String()).Msg("acl enabled")
+			}
+
 			svr := httpServer{
 				address: ta,
 				server: &http.Server{
 					Addr:    ta.String(),
-					Handler: http.HandlerFunc(s.router),
+					Handler: s.listenerHandler(acl, http.HandlerFunc(s.router)),
 				},
 			}
 			svr.server.SetKeepAlivesEnabled(false)
@@ -141,7 +301,7 @@ func New(ctx context.Context, c *check.Check, b *builtins.Builtins, p *plugins.P
 			keyFile:  keyFile,
 			server: &http.Server{
 				Addr:    ta.String(),
-				Handler: http.HandlerFunc(s.router),
+				Handler: s.listenerHandler(acls[aclSSLListener], http.HandlerFunc(s.router)),
 				// Handler: httpgzip.NewHandler(http.HandlerFunc(s.router), []string{"application/json"}),
 			},
 		}
@@ -152,6 +312,16 @@ func New(ctx context.Context, c *check.Check, b *builtins.Builtins, p *plugins.P
 
 	// Socket listener (1-n)
 	if runtime.GOOS != "windows" {
+		socketMode, err := config.ParseSocketMode(viper.GetString(config.KeyListenSocketMode))
+		if err != nil {
+			return nil, errors.Wrap(err, "Socket server")
+		}
+
+		socketHandler := http.HandlerFunc(s.socketHandler)
+		if viper.GetBool(config.KeyListenSocketAPI) {
+			socketHandler = http.HandlerFunc(s.router)
+		}
+
 		socketList := viper.GetStringSlice(config.KeyListenSocket)
 		for idx, addr := range socketList {
 			ua, err := net.ResolveUnixAddr("unix", addr)
@@ -171,10 +341,18 @@ func New(ctx context.Context, c *check.Check, b *builtins.Builtins, p *plugins.P
 				return nil, errors.Wrap(err, "creating socket")
 			}
 
+			if socketMode != 0 {
+				if err := os.Chmod(ua.String(), socketMode); err != nil {
+					ul.Close()
+					s.logger.Error().Err(err).Int("id", idx).Str("addr", ua.String()).Msg("setting socket mode")
+					return nil, errors.Wrap(err, "setting socket mode")
+				}
+			}
+
 			s.svrSockets = append(s.svrSockets, &socketServer{
 				address:  ua,
 				listener: ul,
-				server:   &http.Server{Handler: http.HandlerFunc(s.socketHandler)},
+				server:   &http.Server{Handler: s.accessLogHandler(socketHandler)},
 			})
 		}
 	}
@@ -193,7 +371,7 @@ func (s *Server) GetReverseAgentAddress() (string, error) {
 
 // Start main listening server(s)
 func (s *Server) Start() error {
-	if len(s.svrHTTP) == 0 && s.svrHTTPS == nil && len(s.svrSockets) > 0 {
+	if len(s.svrHTTP) == 0 && s.svrHTTPS == nil && len(s.svrSockets) == 0 {
 		return errors.New("No servers defined")
 	}
 
@@ -261,7 +439,7 @@ func (s *Server) startHTTP(svr *httpServer) error {
 		return nil
 	}
 
-	s.logger.Info().Str("listen", svr.address.String()).Msg("Starting")
+	s.logger.Info().Str("listen", svr.address.String()).Str("family", config.AddressFamily(svr.address.IP)).Msg("Starting")
 	if err := svr.server.ListenAndServe(); err != nil {
 		if err != http.ErrServerClosed {
 			s.logger.Fatal().Err(err).Msg("HTTP Server, stopping agent")
@@ -277,7 +455,7 @@ func (s *Server) startHTTPS() error {
 		s.logger.Debug().Msg("no SSL listen configured, skipping server")
 		return nil
 	}
-	s.logger.Info().Str("listen", s.svrHTTPS.server.Addr).Msg("SSL starting")
+	s.logger.Info().Str("listen", s.svrHTTPS.server.Addr).Str("family", config.AddressFamily(s.svrHTTPS.address.IP)).Msg("SSL starting")
 	if err := s.svrHTTPS.server.ListenAndServeTLS(s.svrHTTPS.certFile, s.svrHTTPS.keyFile); err != nil {
 		if err != http.ErrServerClosed {
 			s.logger.Fatal().Err(err).Msg("SSL Server, stopping agent")
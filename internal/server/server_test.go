@@ -8,6 +8,7 @@ package server
 import (
 	"context"
 	"errors"
+	"os"
 	"path"
 	"regexp"
 	"runtime"
@@ -29,7 +30,7 @@ func TestNew(t *testing.T) {
 		{
 			viper.Reset()
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected no error, got (%s)", err)
 			}
@@ -44,7 +45,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{""})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected no error, got (%s)", err)
 			}
@@ -59,7 +60,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{":2609"})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected NO error, got (%s)", err)
 			}
@@ -74,7 +75,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{"2609"})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected NO error, got (%s)", err)
 			}
@@ -94,7 +95,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			_, err := New(ctx, nil, nil, nil, nil)
+			_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err == nil {
 				t.Fatal("expected error")
 			}
@@ -107,7 +108,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected NO error, got (%s)", err)
 			}
@@ -127,7 +128,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected NO error, got (%s)", err)
 			}
@@ -147,7 +148,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			_, err := New(ctx, nil, nil, nil, nil)
+			_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err == nil {
 				t.Fatal("expected error")
 			}
@@ -160,7 +161,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected NO error, got (%s)", err)
 			}
@@ -180,7 +181,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected NO error, got (%s)", err)
 			}
@@ -200,7 +201,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			_, err := New(ctx, nil, nil, nil, nil)
+			_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err == nil {
 				t.Fatal("expected error")
 			}
@@ -214,7 +215,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeyListen, []string{addr})
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected NO error, got (%s)", err)
 			}
@@ -235,7 +236,7 @@ func TestNew(t *testing.T) {
 			viper.Reset()
 			viper.Set(config.KeySSLListen, ":2610")
 			ctx, cancel := context.WithCancel(context.Background())
-			_, err := New(ctx, nil, nil, nil, nil)
+			_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err == nil {
 				t.Fatal("expecting error")
 			}
@@ -248,7 +249,7 @@ func TestNew(t *testing.T) {
 			viper.Set(config.KeySSLListen, ":2610")
 			viper.Set(config.KeySSLCertFile, "testdata/missing.crt")
 			ctx, cancel := context.WithCancel(context.Background())
-			_, err := New(ctx, nil, nil, nil, nil)
+			_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err == nil {
 				t.Fatal("expecting error")
 			}
@@ -261,7 +262,7 @@ func TestNew(t *testing.T) {
 			viper.Set(config.KeySSLListen, ":2610")
 			viper.Set(config.KeySSLCertFile, "testdata/cert.crt")
 			ctx, cancel := context.WithCancel(context.Background())
-			_, err := New(ctx, nil, nil, nil, nil)
+			_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err == nil {
 				t.Fatal("expecting error")
 			}
@@ -275,7 +276,7 @@ func TestNew(t *testing.T) {
 			viper.Set(config.KeySSLCertFile, "testdata/cert.crt")
 			viper.Set(config.KeySSLKeyFile, "testdata/missing.key")
 			ctx, cancel := context.WithCancel(context.Background())
-			_, err := New(ctx, nil, nil, nil, nil)
+			_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err == nil {
 				t.Fatal("expecting error")
 			}
@@ -291,7 +292,7 @@ func TestNew(t *testing.T) {
 				viper.Reset()
 				viper.Set(config.KeyListenSocket, []string{"testdata/exists.sock"})
 				ctx, cancel := context.WithCancel(context.Background())
-				_, err := New(ctx, nil, nil, nil, nil)
+				_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 				if err == nil {
 					t.Fatal("expected error")
 				}
@@ -303,7 +304,7 @@ func TestNew(t *testing.T) {
 				viper.Reset()
 				viper.Set(config.KeyListenSocket, []string{path.Join("testdata", "test.sock")})
 				ctx, cancel := context.WithCancel(context.Background())
-				s, err := New(ctx, nil, nil, nil, nil)
+				s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 				if err != nil {
 					t.Fatalf("expected no error, got (%s)", err)
 				}
@@ -317,6 +318,64 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewSocketOptions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets not available on " + runtime.GOOS)
+	}
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("Testing New w/Socket options")
+
+	t.Log("\tinvalid mode")
+	{
+		viper.Reset()
+		viper.Set(config.KeyListenSocket, []string{path.Join("testdata", "test.sock")})
+		viper.Set(config.KeyListenSocketMode, "999")
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := New(ctx, nil, nil, nil, nil, nil, nil)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		cancel()
+	}
+
+	t.Log("\tmode, api, only")
+	{
+		viper.Reset()
+		sockFile := path.Join("testdata", "test.sock")
+		viper.Set(config.KeyListenSocket, []string{sockFile})
+		viper.Set(config.KeyListenSocketMode, "0600")
+		viper.Set(config.KeyListenSocketAPI, true)
+		viper.Set(config.KeyListenSocketOnly, true)
+		ctx, cancel := context.WithCancel(context.Background())
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if len(s.svrHTTP) != 0 {
+			t.Fatalf("expected 0 http servers, got %d", len(s.svrHTTP))
+		}
+		if len(s.svrSockets) != 1 {
+			t.Fatal("expected 1 sockets")
+		}
+		fi, err := os.Stat(sockFile)
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if fi.Mode().Perm() != 0600 {
+			t.Fatalf("expected mode 0600, got %o", fi.Mode().Perm())
+		}
+		if _, err := s.GetReverseAgentAddress(); err == nil {
+			t.Fatal("expected error")
+		}
+		s.svrSockets[0].listener.Close()
+		cancel()
+	}
+
+	viper.Reset()
+}
+
 func TestStartHTTP(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.Disabled)
 
@@ -337,7 +396,7 @@ func TestStartHTTP(t *testing.T) {
 		viper.Reset()
 		viper.Set(config.KeyListen, []string{":65111"})
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -376,7 +435,7 @@ func TestStartHTTPS(t *testing.T) {
 		viper.Set(config.KeySSLCertFile, "testdata/cert.crt")
 		viper.Set(config.KeySSLKeyFile, "testdata/key.key")
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -412,7 +471,7 @@ func TestStartSocket(t *testing.T) {
 		viper.Reset()
 		viper.Set(config.KeyListenSocket, []string{"nodir/test.sock"})
 		ctx, cancel := context.WithCancel(context.Background())
-		_, err := New(ctx, nil, nil, nil, nil)
+		_, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err == nil {
 			t.Fatal("expected error")
 		}
@@ -424,7 +483,7 @@ func TestStartSocket(t *testing.T) {
 		viper.Reset()
 		viper.Set(config.KeyListenSocket, []string{path.Join("testdata", "test.sock")})
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -446,7 +505,7 @@ func TestStartSocket(t *testing.T) {
 		viper.Reset()
 		viper.Set(config.KeyListenSocket, []string{path.Join("testdata", "test.sock")})
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -473,7 +532,7 @@ func TestStart(t *testing.T) {
 	{
 		viper.Reset()
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -491,7 +550,7 @@ func TestStart(t *testing.T) {
 		viper.Set(config.KeySSLCertFile, "testdata/cert.crt")
 		viper.Set(config.KeySSLKeyFile, "testdata/key.key")
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -517,7 +576,7 @@ func TestStop(t *testing.T) {
 	t.Run("no servers", func(t *testing.T) {
 		viper.Reset()
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -531,7 +590,7 @@ func TestStop(t *testing.T) {
 		viper.Reset()
 		viper.Set(config.KeyListen, []string{":65226"})
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx, nil, nil, nil, nil)
+		s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("expected no error, got (%s)", err)
 		}
@@ -553,7 +612,7 @@ func TestStop(t *testing.T) {
 			viper.Set(config.KeyListen, []string{"localhost:"})
 			viper.Set(config.KeyListenSocket, path.Join("testdata", "test.sock"))
 			ctx, cancel := context.WithCancel(context.Background())
-			s, err := New(ctx, nil, nil, nil, nil)
+			s, err := New(ctx, nil, nil, nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("expected no error, got (%s)", err)
 			}
@@ -0,0 +1,95 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// SourceAgeMetric time since a source (input conduit) last produced metrics
+const SourceAgeMetric = "source_age_seconds"
+
+// sourceAges tracks when each source last produced metrics (dead-man switch),
+// a source which has not produced metrics since the agent started is aged
+// from the start time
+type sourceAges struct {
+	start     time.Time
+	last      map[string]time.Time
+	maxAge    time.Duration
+	mandatory []string
+	sync.Mutex
+}
+
+// newSourceAges returns nil if source staleness tracking is not enabled
+func newSourceAges(maxAge time.Duration, mandatory []string) *sourceAges {
+	if maxAge <= 0 {
+		return nil
+	}
+	return &sourceAges{
+		start:     time.Now(),
+		last:      make(map[string]time.Time),
+		maxAge:    maxAge,
+		mandatory: mandatory,
+	}
+}
+
+// seen records a source producing metrics
+func (sa *sourceAges) seen(source string, ts time.Time) {
+	if sa == nil {
+		return
+	}
+	sa.Lock()
+	sa.last[source] = ts
+	sa.Unlock()
+}
+
+func (sa *sourceAges) age(source string, now time.Time) time.Duration {
+	last, ok := sa.last[source]
+	if !ok {
+		last = sa.start
+	}
+	return now.Sub(last)
+}
+
+// apply adds the age of each of the sources
+func (sa *sourceAges) apply(metrics *cgm.Metrics, sources []string, now time.Time) {
+	if sa == nil || metrics == nil {
+		return
+	}
+
+	sa.Lock()
+	defer sa.Unlock()
+
+	baseTags := tags.GetBaseTags()
+	for _, source := range sources {
+		mtags := append([]string{"conduit:" + source}, baseTags...)
+		(*metrics)[tags.MetricNameWithStreamTags(SourceAgeMetric, tags.FromList(mtags))] = cgm.Metric{Type: "n", Value: sa.age(source, now).Seconds()}
+	}
+}
+
+// stale returns the mandatory sources which have not produced metrics within the max age
+func (sa *sourceAges) stale(now time.Time) []string {
+	if sa == nil {
+		return nil
+	}
+
+	sa.Lock()
+	defer sa.Unlock()
+
+	var stale []string
+	for _, source := range sa.mandatory {
+		if sa.age(source, now) > sa.maxAge {
+			stale = append(stale, source)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
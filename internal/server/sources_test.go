@@ -0,0 +1,66 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/tags"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestSourceAges(t *testing.T) {
+	t.Log("Testing sourceAges")
+
+	t.Log("\tdisabled")
+	{
+		sa := newSourceAges(0, []string{"builtins"})
+		if sa != nil {
+			t.Fatal("expected nil")
+		}
+		sa.seen("builtins", time.Now())
+		metrics := cgm.Metrics{}
+		sa.apply(&metrics, []string{"builtins"}, time.Now())
+		if len(metrics) != 0 {
+			t.Fatalf("expected no metrics, got %v", metrics)
+		}
+		if stale := sa.stale(time.Now()); len(stale) != 0 {
+			t.Fatalf("expected no stale sources, got %v", stale)
+		}
+	}
+
+	sa := newSourceAges(5*time.Minute, []string{"statsd", "builtins"})
+	now := sa.start.Add(10 * time.Minute)
+	sa.seen("builtins", now.Add(-time.Minute))
+
+	t.Log("\tapply")
+	{
+		metrics := cgm.Metrics{}
+		sa.apply(&metrics, []string{"builtins", "statsd"}, now)
+		if len(metrics) != 2 {
+			t.Fatalf("expected 2 metrics, got %v", metrics)
+		}
+		for source, expect := range map[string]float64{"builtins": 60, "statsd": 600} { // statsd never produced metrics, aged from start
+			name := tags.MetricNameWithStreamTags(SourceAgeMetric, tags.FromList([]string{"conduit:" + source}))
+			if m, ok := metrics[name]; !ok || m.Value.(float64) != expect {
+				t.Fatalf("expected %s %v, got %v", source, expect, m.Value)
+			}
+		}
+	}
+
+	t.Log("\tstale")
+	{
+		if stale := sa.stale(now); !reflect.DeepEqual(stale, []string{"statsd"}) {
+			t.Fatalf("expected [statsd], got %v", stale)
+		}
+		sa.seen("statsd", now)
+		if stale := sa.stale(now); len(stale) != 0 {
+			t.Fatalf("expected no stale sources, got %v", stale)
+		}
+	}
+}
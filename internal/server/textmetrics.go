@@ -0,0 +1,76 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+// textMetrics tracks the text metrics submitted, so that constant text
+// metrics (versions, states, facts) are not submitted with every flush
+type textMetrics struct {
+	resend time.Duration
+	sent   map[string]sentText
+	sync.Mutex
+}
+
+type sentText struct {
+	value string // string form of the (unwrapped) value, plugin text values can be objects or arrays
+	ts    time.Time
+}
+
+// newTextMetrics returns nil if text metrics are submitted with every flush
+func newTextMetrics(resend time.Duration) *textMetrics {
+	if resend <= 0 {
+		return nil
+	}
+	return &textMetrics{
+		resend: resend,
+		sent:   make(map[string]sentText),
+	}
+}
+
+// filter returns the metrics to submit, text metrics are omitted when their
+// value has not changed since last submitted within the resend interval
+func (tm *textMetrics) filter(metrics *cgm.Metrics, now time.Time) *cgm.Metrics {
+	if tm == nil || metrics == nil {
+		return metrics
+	}
+
+	tm.Lock()
+	defer tm.Unlock()
+
+	out := make(cgm.Metrics, len(*metrics))
+	seen := make(map[string]bool)
+	for name, m := range *metrics {
+		if m.Type != "s" {
+			out[name] = m
+			continue
+		}
+		seen[name] = true
+		v, _ := sample.Unwrap(m.Value)
+		value := fmt.Sprint(v)
+		if prev, ok := tm.sent[name]; ok && prev.value == value && now.Sub(prev.ts) < tm.resend {
+			continue
+		}
+		tm.sent[name] = sentText{value: value, ts: now}
+		out[name] = m
+	}
+
+	// a text metric which stops being emitted is submitted again as soon as it reappears
+	for name := range tm.sent {
+		if !seen[name] {
+			delete(tm.sent, name)
+		}
+	}
+
+	return &out
+}
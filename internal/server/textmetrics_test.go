@@ -0,0 +1,104 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/sample"
+	cgm "github.com/circonus-labs/circonus-gometrics/v3"
+)
+
+func TestTextMetricsFilter(t *testing.T) {
+	t.Log("Testing textMetrics filter")
+
+	t.Log("\tdisabled")
+	{
+		tm := newTextMetrics(0)
+		if tm != nil {
+			t.Fatal("expected nil")
+		}
+		metrics := cgm.Metrics{"version": cgm.Metric{Type: "s", Value: "1.0"}}
+		if got := tm.filter(&metrics, time.Now()); len(*got) != 1 {
+			t.Fatalf("expected 1 metric, got %v", *got)
+		}
+	}
+
+	tm := newTextMetrics(10 * time.Minute)
+	start := time.Now()
+	metrics := func(version string) *cgm.Metrics {
+		return &cgm.Metrics{
+			"version": cgm.Metric{Type: "s", Value: version},
+			"load":    cgm.Metric{Type: "n", Value: 1.5},
+		}
+	}
+
+	t.Log("\tfirst flush")
+	{
+		got := tm.filter(metrics("1.0"), start)
+		if _, ok := (*got)["version"]; !ok || len(*got) != 2 {
+			t.Fatalf("expected version and load, got %v", *got)
+		}
+	}
+
+	t.Log("\tunchanged")
+	{
+		got := tm.filter(metrics("1.0"), start.Add(time.Minute))
+		if _, ok := (*got)["version"]; ok {
+			t.Fatalf("expected no version, got %v", *got)
+		}
+		if _, ok := (*got)["load"]; !ok {
+			t.Fatalf("expected load, got %v", *got)
+		}
+	}
+
+	t.Log("\tchanged")
+	{
+		got := tm.filter(metrics("1.1"), start.Add(2*time.Minute))
+		if m, ok := (*got)["version"]; !ok || m.Value != "1.1" {
+			t.Fatalf("expected version 1.1, got %v", *got)
+		}
+	}
+
+	t.Log("\tresend interval elapsed")
+	{
+		got := tm.filter(metrics("1.1"), start.Add(13*time.Minute))
+		if _, ok := (*got)["version"]; !ok {
+			t.Fatalf("expected version, got %v", *got)
+		}
+	}
+
+	t.Log("\treappears")
+	{
+		tm.filter(&cgm.Metrics{"load": cgm.Metric{Type: "n", Value: 1.5}}, start.Add(14*time.Minute))
+		got := tm.filter(metrics("1.1"), start.Add(15*time.Minute))
+		if _, ok := (*got)["version"]; !ok {
+			t.Fatalf("expected version, got %v", *got)
+		}
+	}
+
+	t.Log("\tobject and array values")
+	{
+		tm := newTextMetrics(10 * time.Minute)
+		metrics := func(role string, ms uint64) *cgm.Metrics {
+			return &cgm.Metrics{
+				"facts": cgm.Metric{Type: "s", Value: sample.Stamp(map[string]interface{}{"role": role}, ms)},
+				"disks": cgm.Metric{Type: "s", Value: sample.Stamp([]interface{}{"sda", "sdb"}, ms)},
+			}
+		}
+		if got := tm.filter(metrics("web", 1000), start); len(*got) != 2 {
+			t.Fatalf("expected facts and disks, got %v", *got)
+		}
+		if got := tm.filter(metrics("web", 2000), start.Add(time.Minute)); len(*got) != 0 {
+			t.Fatalf("expected no metrics, got %v", *got)
+		}
+		got := tm.filter(metrics("db", 3000), start.Add(2*time.Minute))
+		if _, ok := (*got)["facts"]; !ok || len(*got) != 1 {
+			t.Fatalf("expected facts, got %v", *got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/builtins"
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/errs"
+	"github.com/circonus-labs/circonus-agent/internal/release"
+	"github.com/spf13/viper"
+)
+
+// uiAsset is a file of the bundled status page
+type uiAsset struct {
+	contentType string
+	data        string
+}
+
+// uiAssets the status page, served under /ui/ (index.html for /ui/)
+var uiAssets = map[string]uiAsset{
+	"index.html": {contentType: "text/html; charset=utf-8", data: uiIndexHTML},
+	"app.js":     {contentType: "application/javascript; charset=utf-8", data: uiAppJS},
+	"style.css":  {contentType: "text/css; charset=utf-8", data: uiStyleCSS},
+}
+
+// uiContentSecurityPolicy restricts the status page to its own assets and api
+const uiContentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; img-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// uiStatus is the status page summary (/ui/status)
+type uiStatus struct {
+	Agent        uiAgent                     `json:"agent"`
+	Status       string                      `json:"status"`
+	StaleSources []string                    `json:"stale_sources,omitempty"`
+	LastFlush    *uiFlush                    `json:"last_flush,omitempty"`
+	Collectors   []builtins.CollectorStatus  `json:"collectors"`
+	Plugins      []string                    `json:"plugins"`
+	Reverse      uiReverse                   `json:"reverse"`
+	Errors       map[errs.Category]errs.Stat `json:"errors"`
+}
+
+type uiAgent struct {
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+	Commit  string    `json:"commit"`
+	Started time.Time `json:"started"`
+	Uptime  string    `json:"uptime"`
+}
+
+type uiFlush struct {
+	Time    time.Time `json:"time"`
+	Age     string    `json:"age"`
+	Metrics int       `json:"metrics"`
+}
+
+type uiReverse struct {
+	Enabled     bool   `json:"enabled"`
+	State       string `json:"state,omitempty"`
+	Broker      string `json:"broker,omitempty"`
+	LastConnect string `json:"last_connect,omitempty"`
+	LastRequest string `json:"last_request,omitempty"`
+}
+
+// statusUI serves the status page assets and summary, handles /ui/...
+func (s *Server) statusUI(w http.ResponseWriter, r *http.Request) {
+	if !s.statusPage {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.URL.Path == "/ui" {
+		// assets and the summary are referenced relative to /ui/
+		http.Redirect(w, r, "/ui/", http.StatusFound)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/ui/")
+	switch name {
+	case "status", "status/":
+		s.uiSummary(w)
+		return
+	case "":
+		name = "index.html"
+	}
+
+	asset, ok := uiAssets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", asset.contentType)
+	h.Set("Content-Security-Policy", uiContentSecurityPolicy)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "no-cache")
+	// the assets are part of the binary, unchanged since the agent started
+	http.ServeContent(w, r, name, s.started, strings.NewReader(asset.data))
+}
+
+// uiSummary responds with the status page summary, the health status, last
+// flush, builtin collectors, plugins, reverse connection and recent errors
+func (s *Server) uiSummary(w http.ResponseWriter) {
+	now := time.Now()
+
+	status := uiStatus{
+		Agent: uiAgent{
+			Name:    release.NAME,
+			Version: release.VERSION,
+			Commit:  release.COMMIT,
+			Started: s.started,
+			Uptime:  now.Sub(s.started).Truncate(time.Second).String(),
+		},
+		Status:       "alive",
+		StaleSources: s.sources.stale(now),
+		Collectors:   []builtins.CollectorStatus{},
+		Plugins:      []string{},
+		Reverse: uiReverse{
+			Enabled:     viper.GetBool(config.KeyReverse),
+			State:       appStat("reverse.state"),
+			Broker:      appStat("reverse.broker"),
+			LastConnect: appStat("reverse.last_connect"),
+			LastRequest: appStat("reverse.last_request"),
+		},
+		Errors: errs.Stats(),
+	}
+	if len(status.StaleSources) > 0 {
+		status.Status = "degraded"
+	}
+
+	lastMetricsmu.Lock()
+	if lastMetrics.metrics != nil {
+		status.LastFlush = &uiFlush{
+			Time:    lastMetrics.ts,
+			Age:     now.Sub(lastMetrics.ts).Truncate(time.Second).String(),
+			Metrics: len(*lastMetrics.metrics),
+		}
+	}
+	lastMetricsmu.Unlock()
+
+	if s.builtins != nil {
+		status.Collectors = s.builtins.Collectors()
+	}
+	if s.plugins != nil {
+		status.Plugins = s.plugins.List()
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(status); err != nil {
+		s.logger.Error().Err(err).Msg("encoding status")
+		http.Error(w, "encoding status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// appStat returns the value of a string app stat (see /stats), empty if not set
+func appStat(name string) string {
+	stats, ok := expvar.Get("stats").(*expvar.Map)
+	if !ok {
+		return ""
+	}
+	if v, ok := stats.Get(name).(*expvar.String); ok {
+		return v.Value()
+	}
+	return ""
+}
@@ -0,0 +1,245 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+// status page assets (see ui.go), kept self-contained with no external
+// resources so the page works on hosts without internet access
+
+const uiIndexHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>circonus-agent status</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<header>
+  <h1>circonus-agent</h1>
+  <span id="agent"></span>
+  <span id="status" class="badge">loading</span>
+</header>
+<main>
+  <p id="error" class="error" hidden></p>
+  <section>
+    <h2>Overview</h2>
+    <dl id="overview"></dl>
+  </section>
+  <section>
+    <h2>Reverse connection</h2>
+    <dl id="reverse"></dl>
+  </section>
+  <section>
+    <h2>Builtin collectors</h2>
+    <table>
+      <thead><tr><th>Collector</th><th>Enabled</th><th>Last run</th><th>Duration</th><th>Last error</th></tr></thead>
+      <tbody id="collectors"></tbody>
+    </table>
+  </section>
+  <section>
+    <h2>Recent errors</h2>
+    <table>
+      <thead><tr><th>Category</th><th>Count</th><th>Last</th><th>Retryable</th><th>Message</th></tr></thead>
+      <tbody id="errors"></tbody>
+    </table>
+  </section>
+</main>
+<footer>Refreshed every 5 seconds, <a href="status">raw status</a></footer>
+<script src="app.js"></script>
+</body>
+</html>
+`
+
+const uiAppJS = `'use strict';
+
+(function () {
+  var refreshInterval = 5000;
+
+  function el(tag, text, cls) {
+    var e = document.createElement(tag);
+    if (text !== undefined && text !== null) {
+      e.textContent = String(text);
+    }
+    if (cls) {
+      e.className = cls;
+    }
+    return e;
+  }
+
+  function fill(id, nodes) {
+    var target = document.getElementById(id);
+    while (target.firstChild) {
+      target.removeChild(target.firstChild);
+    }
+    nodes.forEach(function (n) { target.appendChild(n); });
+  }
+
+  function definitions(items) {
+    var nodes = [];
+    items.forEach(function (item) {
+      nodes.push(el('dt', item[0]));
+      nodes.push(el('dd', item[1] === undefined || item[1] === '' ? '-' : item[1], item[2]));
+    });
+    return nodes;
+  }
+
+  function row(cells, cls) {
+    var tr = el('tr', null, cls);
+    cells.forEach(function (c) { tr.appendChild(el('td', c === undefined || c === '' ? '-' : c)); });
+    return tr;
+  }
+
+  function render(s) {
+    document.getElementById('error').hidden = true;
+    document.getElementById('agent').textContent = s.agent.version + ' (' + s.agent.commit + ')';
+
+    var status = document.getElementById('status');
+    status.textContent = s.status;
+    status.className = 'badge ' + s.status;
+
+    var flush = s.last_flush
+      ? s.last_flush.age + ' ago, ' + s.last_flush.metrics + ' metrics'
+      : 'no flush yet';
+    fill('overview', definitions([
+      ['Started', new Date(s.agent.started).toLocaleString()],
+      ['Uptime', s.agent.uptime],
+      ['Last flush', flush],
+      ['Plugins', s.plugins.length ? s.plugins.join(', ') : 'none'],
+      ['Stale sources', (s.stale_sources || []).join(', ') || 'none', (s.stale_sources || []).length ? 'bad' : '']
+    ]));
+
+    var rev = s.reverse;
+    fill('reverse', definitions(rev.enabled ? [
+      ['State', rev.state || 'not connected', rev.state === 'ERROR' || !rev.state ? 'bad' : ''],
+      ['Broker', rev.broker],
+      ['Last connect', rev.last_connect],
+      ['Last request', rev.last_request]
+    ] : [['State', 'disabled']]));
+
+    fill('collectors', s.collectors.map(function (c) {
+      return row([c.name, c.enabled ? 'yes' : 'no', c.last_run_end, c.last_run_duration, c.last_error], c.last_error ? 'bad' : '');
+    }));
+
+    var categories = Object.keys(s.errors || {}).sort();
+    fill('errors', categories.length ? categories.map(function (cat) {
+      var e = s.errors[cat];
+      return row([cat, e.count, new Date(e.last).toLocaleString(), e.retryable ? 'yes' : 'no', e.message], 'bad');
+    }) : [row(['none', '', '', '', ''])]);
+  }
+
+  function refresh() {
+    var req = new XMLHttpRequest();
+    req.open('GET', 'status');
+    req.setRequestHeader('Accept', 'application/json');
+    req.onload = function () {
+      if (req.status !== 200) {
+        showError('status request failed (' + req.status + ')');
+        return;
+      }
+      try {
+        render(JSON.parse(req.responseText));
+      } catch (err) {
+        showError('invalid status response (' + err + ')');
+      }
+    };
+    req.onerror = function () { showError('agent not reachable'); };
+    req.send();
+  }
+
+  function showError(msg) {
+    var e = document.getElementById('error');
+    e.textContent = msg;
+    e.hidden = false;
+    var status = document.getElementById('status');
+    status.textContent = 'unknown';
+    status.className = 'badge';
+  }
+
+  refresh();
+  setInterval(refresh, refreshInterval);
+})();
+`
+
+const uiStyleCSS = `body {
+  font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif;
+  margin: 0;
+  color: #222;
+  background: #f6f7f9;
+}
+header {
+  display: flex;
+  align-items: center;
+  gap: 1em;
+  padding: 0.75em 1.5em;
+  background: #1f2d3d;
+  color: #fff;
+}
+header h1 {
+  font-size: 1.25em;
+  margin: 0;
+}
+main {
+  padding: 0 1.5em;
+}
+section {
+  background: #fff;
+  border: 1px solid #dde1e6;
+  border-radius: 4px;
+  margin: 1em 0;
+  padding: 0.5em 1em 1em;
+}
+h2 {
+  font-size: 1em;
+}
+dl {
+  display: grid;
+  grid-template-columns: max-content auto;
+  gap: 0.25em 1.5em;
+  margin: 0;
+}
+dt {
+  font-weight: bold;
+}
+dd {
+  margin: 0;
+}
+table {
+  border-collapse: collapse;
+  width: 100%;
+}
+th, td {
+  text-align: left;
+  padding: 0.3em 0.6em;
+  border-bottom: 1px solid #eceef1;
+  vertical-align: top;
+}
+.badge {
+  padding: 0.15em 0.6em;
+  border-radius: 3px;
+  background: #8892a0;
+  text-transform: uppercase;
+  font-size: 0.8em;
+}
+.badge.alive {
+  background: #2e8540;
+}
+.badge.degraded {
+  background: #c0392b;
+}
+.bad {
+  color: #c0392b;
+}
+.error {
+  color: #fff;
+  background: #c0392b;
+  padding: 0.5em 1em;
+}
+footer {
+  padding: 0 1.5em 1em;
+  color: #6b7480;
+  font-size: 0.85em;
+}
+`
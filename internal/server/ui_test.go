@@ -0,0 +1,108 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maier/go-appstats"
+	"github.com/rs/zerolog"
+)
+
+func TestStatusUI(t *testing.T) {
+	t.Log("Testing statusUI")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("disabled")
+	{
+		s := &Server{}
+		req := httptest.NewRequest("GET", "/ui/", nil)
+		w := httptest.NewRecorder()
+		s.statusUI(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+
+	s := &Server{statusPage: true, started: time.Now()}
+
+	t.Log("redirect")
+	{
+		req := httptest.NewRequest("GET", "/ui", nil)
+		w := httptest.NewRecorder()
+		s.statusUI(w, req)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/ui/" {
+			t.Fatalf("expected redirect to /ui/, got %d %s", w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	tt := []struct {
+		path        string
+		contentType string
+	}{
+		{"/ui/", "text/html"},
+		{"/ui/app.js", "application/javascript"},
+		{"/ui/style.css", "text/css"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("asset %s", tst.path)
+		req := httptest.NewRequest("GET", tst.path, nil)
+		w := httptest.NewRecorder()
+		s.statusUI(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tst.contentType) {
+			t.Fatalf("expected %s, got %s", tst.contentType, ct)
+		}
+		if w.Header().Get("Content-Security-Policy") == "" {
+			t.Fatal("expected content security policy")
+		}
+	}
+
+	t.Log("unknown asset")
+	{
+		req := httptest.NewRequest("GET", "/ui/missing.js", nil)
+		w := httptest.NewRecorder()
+		s.statusUI(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+		}
+	}
+
+	t.Log("status")
+	{
+		_ = appstats.SetString("reverse.state", "CONN_IDLE")
+
+		req := httptest.NewRequest("GET", "/ui/status", nil)
+		w := httptest.NewRecorder()
+		s.statusUI(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var status uiStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if status.Status != "alive" {
+			t.Fatalf("expected alive, got (%s)", status.Status)
+		}
+		if status.Reverse.State != "CONN_IDLE" {
+			t.Fatalf("expected CONN_IDLE, got (%s)", status.Reverse.State)
+		}
+		if status.Collectors == nil || status.Plugins == nil {
+			t.Fatalf("expected empty lists, got %#v", status)
+		}
+	}
+}
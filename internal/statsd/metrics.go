@@ -7,10 +7,12 @@ package statsd
 
 import (
 	"bytes"
+	"math"
 	"strconv"
 	"strings"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/circonus-labs/circonus-agent/internal/merge"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
 	"github.com/maier/go-appstats"
@@ -76,6 +78,12 @@ func (s *Server) parseMetric(metric string) error {
 		return nil
 	}
 
+	// DogStatsD events and service checks are not metrics
+	if strings.HasPrefix(metric, "_e{") || strings.HasPrefix(metric, "_sc|") {
+		s.logger.Debug().Str("packet", metric).Msg("ignoring DogStatsD event/service check")
+		return nil
+	}
+
 	metricName := ""
 	metricType := ""
 	metricValue := ""
@@ -96,7 +104,13 @@ func (s *Server) parseMetric(metric string) error {
 				metricType = matchVal
 			case "value":
 				metricValue = matchVal
-			case "sample":
+			case "sample", "sample_after_tags":
+				if matchVal == "" {
+					continue
+				}
+				if metricRate != "" {
+					return errors.Errorf("invalid metric format '%s' (multiple sampling rates), ignoring", metric)
+				}
 				metricRate = matchVal
 			case "tags":
 				metricTagSpec = matchVal
@@ -115,7 +129,9 @@ func (s *Server) parseMetric(metric string) error {
 		if err != nil {
 			return errors.Errorf("invalid metric sampling rate (%s), ignoring", err)
 		}
-		sampleRate = r
+		if r > 0 && r < 1 {
+			sampleRate = r
+		}
 	}
 
 	var (
@@ -132,19 +148,40 @@ func (s *Server) parseMetric(metric string) error {
 	}
 
 	if dest == nil {
+		if metricDest == destGroup {
+			return errors.Errorf("no group check for group metric (%s), see --statsd-group-cid or --statsd-group-create", metric)
+		}
 		return errors.Errorf("invalid metric destination (%s)->(%s)", metric, metricDest)
 	}
 
-	// add stream tags to metric name
+	// add stream tags to metric name, DogStatsD tags without a value (no
+	// category:value pair) cannot be stream tags, they are dropped
 	metricTagList := []string{}
 	if metricTagSpec != "" {
-		metricTagList = strings.Split(metricTagSpec, tags.Separator)
+		for _, tag := range strings.Split(metricTagSpec, tags.Separator) {
+			if !strings.Contains(tag, tags.Delimiter) {
+				s.logger.Debug().Str("tag", tag).Str("metric", metricName).Msg("ignoring tag without value")
+				continue
+			}
+			metricTagList = append(metricTagList, tag)
+		}
 	}
 	tagList := make([]string, 0, len(s.baseTags)+len(metricTagList))
 	tagList = append(tagList, s.baseTags...)
 	tagList = append(tagList, metricTagList...)
 	metricTags := tags.FromList(tagList)
 
+	if metricType == "s" {
+		// in the case of sets, the value is the unique "thing" to be tracked
+		// counters are used to track individual "things"
+		metricTags = append(metricTags, cgm.Tag{Category: "set_id", Value: metricValue})
+	}
+
+	if metricDest == destHost && !s.hostPending.Allow(metricName, metricTags) {
+		s.logger.Debug().Str("metric", metricName).Msg("dropped, max pending series reached")
+		return nil
+	}
+
 	switch metricType {
 	case "c": // counter
 		v, err := strconv.ParseUint(metricValue, 10, 64)
@@ -152,16 +189,22 @@ func (s *Server) parseMetric(metric string) error {
 			return errors.Wrap(err, "invalid counter value")
 		}
 		if sampleRate > 0 {
-			v = uint64(float64(v) * (1 / sampleRate))
+			v = uint64(math.Round(float64(v) / sampleRate))
 		}
 		if viper.GetBool(config.KeyClusterEnabled) {
 			metricTags = append(metricTags, cgm.Tag{Category: "statsd_type", Value: "count"})
 			dest.RecordCountForValueWithTags(metricName, metricTags, 0, int64(v))
 		} else {
 			dest.IncrementByValueWithTags(metricName, metricTags, v)
+			if metricDest == destHost {
+				s.addHostCounter(metricName, metricTags, v)
+			}
 		}
 	case "g": // gauge
-		var val interface{}
+		var (
+			val     interface{}
+			valType string
+		)
 		switch {
 		case strings.Contains(metricValue, "."):
 			v, err := strconv.ParseFloat(metricValue, 64)
@@ -169,6 +212,7 @@ func (s *Server) parseMetric(metric string) error {
 				return errors.Wrap(err, "invalid gauge value")
 			}
 			val = v
+			valType = "n"
 			// dest.GaugeWithTags(metricName, metricTags, v)
 		case strings.Contains(metricValue, "-"):
 			v, err := strconv.ParseInt(metricValue, 10, 64)
@@ -176,6 +220,7 @@ func (s *Server) parseMetric(metric string) error {
 				return errors.Wrap(err, "invalid gauge value")
 			}
 			val = v
+			valType = "l"
 			// dest.GaugeWithTags(metricName, metricTags, v)
 		default:
 			v, err := strconv.ParseUint(metricValue, 10, 64)
@@ -183,36 +228,56 @@ func (s *Server) parseMetric(metric string) error {
 				return errors.Wrap(err, "invalid gauge value")
 			}
 			val = v
+			valType = "L"
 			// dest.GaugeWithTags(metricName, metricTags, v)
 		}
 		if viper.GetBool(config.KeyClusterEnabled) && viper.GetBool(config.KeyClusterStatsdHistogramGauges) {
 			dest.RemoveHistogramWithTags(metricName, metricTags)
 			dest.SetHistogramValueWithTags(metricName, metricTags, val.(float64))
+		} else if metricDest == destHost {
+			// gauges from multiple clients, see merge policy
+			switch s.hostWindow.Write(metricName, metricTags, valType) {
+			case merge.Add:
+				dest.AddGaugeWithTags(metricName, metricTags, val)
+			case merge.Replace:
+				dest.GaugeWithTags(metricName, metricTags, val)
+			case merge.Drop:
+				s.logger.Debug().Str("metric", metricName).Msg("rejected, gauge already written since last flush")
+				return nil
+			}
 		} else {
 			dest.GaugeWithTags(metricName, metricTags, val)
 		}
 	case "h": // histogram (circonus)
 		fallthrough
+	case "d": // distribution (DogStatsD)
+		fallthrough
 	case "ms": // measurement
 		v, err := strconv.ParseFloat(metricValue, 64)
 		if err != nil {
 			return errors.Wrap(err, "invalid histogram value")
 		}
 		if sampleRate > 0 {
-			v /= sampleRate
+			// a sampled value stands for 1/rate occurrences of the value
+			dest.RecordCountForValueWithTags(metricName, metricTags, v, int64(math.Round(1/sampleRate)))
+		} else {
+			dest.RecordValueWithTags(metricName, metricTags, v)
 		}
-		dest.RecordValueWithTags(metricName, metricTags, v)
 	case "s": // set
-		// in the case of sets, the value is the unique "thing" to be tracked
-		// counters are used to track individual "things"
-		metricTags = append(metricTags, cgm.Tag{Category: "set_id", Value: metricValue})
 		if viper.GetBool(config.KeyClusterEnabled) {
 			metricTags = append(metricTags, cgm.Tag{Category: "statsd_type", Value: "count"})
 			dest.RecordCountForValueWithTags(metricName, metricTags, 0, 1)
 		} else {
 			dest.IncrementWithTags(metricName, metricTags)
+			if metricDest == destHost {
+				s.addHostCounter(metricName, metricTags, 1)
+			}
 		}
 	case "t": // text (circonus)
+		if metricDest == destHost && s.hostWindow.Write(metricName, metricTags, "s") == merge.Drop {
+			s.logger.Debug().Str("metric", metricName).Msg("rejected, text already written since last flush")
+			return nil
+		}
 		dest.SetTextWithTags(metricName, metricTags, metricValue)
 	default:
 		return errors.Errorf("invalid metric type (%s)", metricType)
@@ -8,10 +8,18 @@ package statsd
 import (
 	"context"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/counterstate"
+	"github.com/circonus-labs/circonus-agent/internal/merge"
+	"github.com/circonus-labs/circonus-agent/internal/pending"
+	"github.com/circonus-labs/circonus-agent/internal/tags"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 )
@@ -24,7 +32,7 @@ func TestProcessPacket(t *testing.T) {
 	viper.Set(config.KeyStatsdDisabled, false)
 	viper.Set(config.KeyStatsdPort, "65125")
 	viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
-	s, err := New(context.Background())
+	s, err := New(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -68,7 +76,7 @@ func TestGetMetricDest(t *testing.T) {
 		viper.Set(config.KeyStatsdDisabled, false)
 		viper.Set(config.KeyStatsdPort, "65125")
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -106,7 +114,7 @@ func TestGetMetricDest(t *testing.T) {
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
 		viper.Set(config.KeyStatsdHostPrefix, "host.")
 		viper.Set(config.KeyStatsdGroupPrefix, "group.")
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -143,7 +151,7 @@ func TestGetMetricDest(t *testing.T) {
 		viper.Set(config.KeyStatsdPort, "65125")
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
 		viper.Set(config.KeyStatsdGroupPrefix, "group.")
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -180,7 +188,7 @@ func TestGetMetricDest(t *testing.T) {
 		viper.Set(config.KeyStatsdPort, "65125")
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
 		viper.Set(config.KeyStatsdHostPrefix, "host.")
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -208,7 +216,7 @@ func TestParseMetric(t *testing.T) {
 	viper.Set(config.KeyStatsdDisabled, false)
 	viper.Set(config.KeyStatsdPort, "65125")
 	viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
-	s, err := New(context.Background())
+	s, err := New(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("expected NO error, got (%s)", err)
 	}
@@ -267,6 +275,12 @@ func TestParseMetric(t *testing.T) {
 		{"test:1.0a|h", errors.New(`invalid histogram value: strconv.ParseFloat: parsing "1.0a": invalid syntax`)},
 		{"test:1.0a|ms", errors.New(`invalid histogram value: strconv.ParseFloat: parsing "1.0a": invalid syntax`)},
 		{"test:1|q", errors.New("invalid metric type (q)")},
+		{"test:1|d", nil},
+		{"test:1|c|#c:v|@.5", nil},
+		{"test:1|c|#url:http://example.com/a,debug", nil},
+		{"test:1|c|@.5|#c:v|@.5", errors.New("invalid metric format 'test:1|c|@.5|#c:v|@.5' (multiple sampling rates), ignoring")},
+		{"_e{5,4}:title|text|#c:v", nil},
+		{"_sc|check|0|#c:v", nil},
 	}
 
 	for _, mt := range mtests {
@@ -285,4 +299,263 @@ func TestParseMetric(t *testing.T) {
 			}
 		}
 	}
+
+	t.Log("Group metric, no group check")
+	{
+		s.hostPrefix = ""
+		s.groupPrefix = "group."
+		expect := errors.New("no group check for group metric (group.test:1|c), see --statsd-group-cid or --statsd-group-create")
+		err := s.parseMetric("group.test:1|c")
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if expect.Error() != err.Error() {
+			t.Fatalf("expected (%s) got (%s)", expect, err)
+		}
+	}
+}
+
+func TestParseMetricDogStatsD(t *testing.T) {
+	t.Log("Testing parseMetric DogStatsD extensions")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	viper.Set(config.KeyStatsdDisabled, false)
+	viper.Set(config.KeyStatsdPort, "65125")
+	viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
+	defer viper.Reset()
+	s, err := New(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := s.initHostMetrics(); err != nil {
+		t.Fatalf("initHostMetrics %s", err)
+	}
+
+	withTags := func(name string, extra ...string) string {
+		return tags.MetricNameWithStreamTags(name, tags.FromList(append(append([]string{}, s.baseTags...), extra...)))
+	}
+
+	t.Log("\ttags")
+	{
+		if err := s.parseMetric("req:1|c|#env:prod,url:http://example.com/a,canary|@0.5"); err != nil {
+			t.Fatalf("expected nil, got (%s)", err)
+		}
+		m := s.Flush()
+		name := withTags("req", "env:prod", "url:http://example.com/a")
+		metric, ok := (*m)[name]
+		if !ok {
+			t.Fatalf("expected metric '%s', %#v", name, m)
+		}
+		if metric.Value != uint64(2) {
+			t.Fatalf("expected 2 (sample rate 0.5), got %v", metric.Value)
+		}
+	}
+
+	t.Log("\tsampled counter")
+	{
+		if err := s.parseMetric("req:3|c|@0.3"); err != nil {
+			t.Fatalf("expected nil, got (%s)", err)
+		}
+		m := s.Flush()
+		if metric := (*m)[withTags("req")]; metric.Value != uint64(10) {
+			t.Fatalf("expected 10, got %v", metric.Value)
+		}
+	}
+
+	t.Log("\tsampled timer")
+	{
+		if err := s.parseMetric("latency:250|ms|@0.1|#env:prod"); err != nil {
+			t.Fatalf("expected nil, got (%s)", err)
+		}
+		if err := s.parseMetric("latency:250|d|#env:prod"); err != nil {
+			t.Fatalf("expected nil, got (%s)", err)
+		}
+		m := s.Flush()
+		name := withTags("latency", "env:prod")
+		metric, ok := (*m)[name]
+		if !ok {
+			t.Fatalf("expected metric '%s', %#v", name, m)
+		}
+		samples, ok := metric.Value.([]string)
+		if !ok || len(samples) != 1 || !strings.HasSuffix(samples[0], "=11") || !strings.Contains(samples[0], "2.5e+02") {
+			t.Fatalf("expected 11 samples of 250, got %#v", metric.Value)
+		}
+	}
+}
+
+func TestParseMetricMerge(t *testing.T) {
+	t.Log("Testing parseMetric merge policies")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	viper.Set(config.KeyStatsdDisabled, false)
+	viper.Set(config.KeyStatsdPort, "65125")
+	viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
+	s, err := New(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := s.initHostMetrics(); err != nil {
+		t.Fatalf("initHostMetrics %s", err)
+	}
+
+	metricName := tags.MetricNameWithStreamTags("test", tags.FromList(s.baseTags))
+	conflictName := tags.MetricNameWithStreamTags(merge.ConflictMetric, tags.FromList(s.baseTags))
+
+	tt := []struct {
+		policy    string
+		metrics   []string
+		expect    interface{}
+		conflicts interface{}
+	}{
+		{merge.Last, []string{"test:1|g", "test:2|g"}, uint64(2), nil},
+		{merge.Sum, []string{"test:1|g", "test:2|g"}, uint64(3), nil},
+		{merge.Sum, []string{"test:1|g", "test:2.5|g"}, float64(2.5), nil},
+		{merge.Reject, []string{"test:1|g", "test:2|g"}, uint64(1), uint64(1)},
+		{merge.Reject, []string{"test:foo|t", "test:bar|t"}, "foo", uint64(1)},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\t%s %v", tst.policy, tst.metrics)
+		s.hostWindow = merge.NewWindow(tst.policy)
+		for _, metric := range tst.metrics {
+			if err := s.parseMetric(metric); err != nil {
+				t.Fatalf("expected nil, got (%s)", err)
+			}
+		}
+		m := s.Flush()
+		metric, ok := (*m)[metricName]
+		if !ok {
+			t.Fatalf("expected metric '%s', %#v", metricName, m)
+		}
+		if metric.Value != tst.expect {
+			t.Fatalf("expected %v (%T), got %v (%T)", tst.expect, tst.expect, metric.Value, metric.Value)
+		}
+		conflicts, ok := (*m)[conflictName]
+		if tst.conflicts == nil {
+			if ok {
+				t.Fatalf("unexpected metric '%s'", conflictName)
+			}
+			continue
+		}
+		if conflicts.Value != tst.conflicts {
+			t.Fatalf("expected %v conflicts, got %v", tst.conflicts, conflicts.Value)
+		}
+	}
+}
+
+func TestParseMetricPendingLimit(t *testing.T) {
+	t.Log("Testing parseMetric max pending series")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	viper.Set(config.KeyStatsdDisabled, false)
+	viper.Set(config.KeyStatsdPort, "65125")
+	viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
+	viper.Set(config.KeyMaxPendingSeries, 2)
+	defer viper.Reset()
+	s, err := New(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := s.initHostMetrics(); err != nil {
+		t.Fatalf("initHostMetrics %s", err)
+	}
+
+	droppedName := tags.MetricNameWithStreamTags(pending.DroppedMetric, tags.FromList(s.baseTags))
+
+	for _, metric := range []string{"a:1|g", "b:1|c", "a:2|g", "c:1|g", "s:x|s"} {
+		if err := s.parseMetric(metric); err != nil {
+			t.Fatalf("expected nil, got (%s)", err)
+		}
+	}
+	m := s.Flush()
+	if metric, ok := (*m)[tags.MetricNameWithStreamTags("a", tags.FromList(s.baseTags))]; !ok || metric.Value != uint64(2) {
+		t.Fatalf("expected a=2, got %#v", m)
+	}
+	if _, ok := (*m)[tags.MetricNameWithStreamTags("c", tags.FromList(s.baseTags))]; ok {
+		t.Fatalf("expected c dropped, got %#v", m)
+	}
+	if dropped, ok := (*m)[droppedName]; !ok || dropped.Value != uint64(2) {
+		t.Fatalf("expected 2 dropped, got %#v", dropped)
+	}
+
+	if err := s.parseMetric("c:1|g"); err != nil {
+		t.Fatalf("expected nil, got (%s)", err)
+	}
+	m = s.Flush()
+	if _, ok := (*m)[tags.MetricNameWithStreamTags("c", tags.FromList(s.baseTags))]; !ok {
+		t.Fatalf("expected c after flush, got %#v", m)
+	}
+	if dropped := (*m)[droppedName]; dropped.Value != uint64(0) {
+		t.Fatalf("expected 0 dropped, got %#v", dropped)
+	}
+}
+
+func TestParseMetricCounterState(t *testing.T) {
+	t.Log("Testing parseMetric counter state")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatalf("creating temp dir (%s)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	viper.Set(config.KeyStatsdDisabled, false)
+	viper.Set(config.KeyStatsdPort, "65125")
+	viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
+	viper.Set(config.KeyCounterState, true)
+	viper.Set(config.KeyCounterStateFile, filepath.Join(dir, "counters.json"))
+	defer viper.Reset()
+
+	counters, err := counterstate.New()
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	s, err := New(context.Background(), counters)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	for _, metric := range []string{"foo:2|c", "foo:3|c", "bar:1|c"} {
+		if err := s.parseMetric(metric); err != nil {
+			t.Fatalf("expected nil, got (%s)", err)
+		}
+	}
+	_ = s.Flush()
+	for _, metric := range []string{"foo:4|c", "baz:1|g"} {
+		if err := s.parseMetric(metric); err != nil {
+			t.Fatalf("expected nil, got (%s)", err)
+		}
+	}
+
+	// agent stops
+	s.saveHostCounters()
+	if err := counters.Save(); err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+
+	// agent starts
+	counters, err = counterstate.New()
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	s, err = New(context.Background(), counters)
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	if err := s.parseMetric("foo:1|c"); err != nil {
+		t.Fatalf("expected nil, got (%s)", err)
+	}
+
+	m := s.Flush()
+	if metric, ok := (*m)[tags.MetricNameWithStreamTags("foo", tags.FromList(s.baseTags))]; !ok || metric.Value != uint64(5) {
+		t.Fatalf("expected foo=5, got %#v", m)
+	}
+	if len(*m) != 1 {
+		t.Fatalf("expected only foo, got %#v", m)
+	}
 }
@@ -7,17 +7,24 @@ package statsd
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/x509"
+	"encoding/binary"
+	"io"
 	"io/ioutil"
 	"net"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
 	"github.com/circonus-labs/circonus-agent/internal/config/defaults"
+	"github.com/circonus-labs/circonus-agent/internal/counterstate"
+	"github.com/circonus-labs/circonus-agent/internal/merge"
+	"github.com/circonus-labs/circonus-agent/internal/pending"
 	"github.com/circonus-labs/circonus-agent/internal/release"
 	"github.com/circonus-labs/circonus-agent/internal/tags"
 	cgm "github.com/circonus-labs/circonus-gometrics/v3"
@@ -41,12 +48,21 @@ type Server struct {
 	tcpAddress            *net.TCPAddr
 	hostMetrics           *cgm.CirconusMetrics
 	hostMetricsmu         sync.Mutex
+	hostWindow            *merge.Window     // host metrics written since the last flush (see merge policy)
+	hostPending           *pending.Limiter  // host series written since the last flush (see max pending series)
+	hostCounters          map[string]uint64 // host counters accumulated since the last flush (see counter state)
+	hostCountersmu        sync.Mutex
+	counters              *counterstate.Store
 	groupMetrics          *cgm.CirconusMetrics
 	groupMetricsmu        sync.Mutex
 	logger                zerolog.Logger
 	hostPrefix            string
 	hostCategory          string
 	groupCID              string
+	groupCreate           bool
+	groupTarget           string
+	groupTags             string
+	groupBroker           string
 	groupPrefix           string
 	groupCounterOp        string
 	groupGaugeOp          string
@@ -61,20 +77,30 @@ type Server struct {
 	tcpListener           *net.TCPListener
 	tcpMaxConnections     uint
 	tcpConnections        map[string]*net.TCPConn
+	tcpFraming            string
+	tcpReadTimeout        time.Duration
+	queueSize             uint
+	queuePolicy           string
 	baseTags              []string
 	sync.Mutex
 }
 
 const (
-	maxPacketSize   = 1472
-	packetQueueSize = 1000
-	destHost        = "host"
-	destGroup       = "group"
-	destIgnore      = "ignore"
+	maxPacketSize = 1472
+	destHost      = "host"
+	destGroup     = "group"
+	destIgnore    = "ignore"
 )
 
-// New returns a statsd server definition
-func New(ctx context.Context) (*Server, error) {
+// maxTCPFrameSize max size of a tcp line or length prefixed frame
+const maxTCPFrameSize = 64 * 1024
+
+// groupSearchTag identifies a statsd group check found or created by the agents in a group
+const groupSearchTag = "service:circonus-agent-statsd-group"
+
+// New returns a statsd server definition, host counters saved in the counter
+// state when the agent last stopped are restored (counters may be nil)
+func New(ctx context.Context, counters *counterstate.Store) (*Server, error) {
 	s := Server{
 		disabled: viper.GetBool(config.KeyStatsdDisabled),
 		logger:   log.With().Str("pkg", "statsd").Logger(),
@@ -100,6 +126,10 @@ func New(ctx context.Context) (*Server, error) {
 		hostPrefix:        viper.GetString(config.KeyStatsdHostPrefix),
 		hostCategory:      viper.GetString(config.KeyStatsdHostCategory),
 		groupCID:          viper.GetString(config.KeyStatsdGroupCID),
+		groupCreate:       viper.GetBool(config.KeyStatsdGroupCreate),
+		groupTarget:       viper.GetString(config.KeyStatsdGroupTarget),
+		groupTags:         viper.GetString(config.KeyStatsdGroupTags),
+		groupBroker:       viper.GetString(config.KeyStatsdGroupBroker),
 		groupPrefix:       viper.GetString(config.KeyStatsdGroupPrefix),
 		groupCounterOp:    viper.GetString(config.KeyStatsdGroupCounters),
 		groupGaugeOp:      viper.GetString(config.KeyStatsdGroupGauges),
@@ -112,10 +142,25 @@ func New(ctx context.Context) (*Server, error) {
 		baseTags:          tags.GetBaseTags(),
 		tcpConnections:    map[string]*net.TCPConn{},
 		tcpMaxConnections: viper.GetUint(config.KeyStatsdMaxTCPConns),
+		tcpFraming:        viper.GetString(config.KeyStatsdTCPFraming),
+		hostWindow:        merge.NewWindow(viper.GetString(config.KeyMetricMerge)),
+		hostPending:       pending.NewLimiter(viper.GetUint(config.KeyMaxPendingSeries)),
+		queueSize:         viper.GetUint(config.KeyStatsdQueueSize),
+		queuePolicy:       viper.GetString(config.KeyStatsdQueuePolicy),
+		counters:          counters,
 	}
 
 	s.enableUDPListener = !s.disabled
 	s.enableTCPListener = viper.GetBool(config.KeyStatsdEnableTCP)
+	if s.enableTCPListener {
+		if timeout := viper.GetString(config.KeyStatsdTCPReadTimeout); timeout != "" {
+			d, err := time.ParseDuration(timeout)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing StatsD TCP read timeout")
+			}
+			s.tcpReadTimeout = d
+		}
+	}
 
 	s.baseTags = append(s.baseTags, []string{
 		"source:" + release.NAME,
@@ -124,8 +169,9 @@ func New(ctx context.Context) (*Server, error) {
 
 	// standard statsd metric format supported (with addition of tags):
 	// name:value|type[|@rate][|#tag_list]
-	// where tag_list is comma separated list of <tag_category:tag_value> pairs
-	s.metricRegex = regexp.MustCompile(`^(?P<name>[^:\s]+):(?P<value>[^|\s]+)\|(?P<type>[a-z]+)(?:\|@(?P<sample>[0-9.]+))?(?:\|#(?P<tags>[^:,]+:[^:,]+(,[^:,]+:[^:,]+)*))?$`)
+	// where tag_list is comma separated list of <tag_category:tag_value> pairs,
+	// as emitted by DogStatsD clients the rate may also follow the tag list
+	s.metricRegex = regexp.MustCompile(`^(?P<name>[^:\s]+):(?P<value>[^|\s]+)\|(?P<type>[a-z]+)(?:\|@(?P<sample>[0-9.]+))?(?:\|#(?P<tags>[^,|]+(,[^,|]+)*))?(?:\|@(?P<sample_after_tags>[0-9.]+))?$`)
 	s.metricRegexGroupNames = s.metricRegex.SubexpNames()
 
 	if !s.disabled {
@@ -136,12 +182,15 @@ func New(ctx context.Context) (*Server, error) {
 		if ierr := s.initGroupMetrics(); ierr != nil {
 			return nil, errors.Wrap(ierr, "initializing group metrics for StatsD")
 		}
+
+		s.restoreHostCounters()
 	}
 
 	addr := viper.GetString(config.KeyStatsdAddr)
 	if addr == "" {
 		addr = defaults.StatsdAddr
 	}
+	addr = config.StripBrackets(addr) // accept bracketed ipv6 literals e.g. [::1]
 	port := viper.GetString(config.KeyStatsdPort)
 	if port == "" {
 		port = defaults.StatsdPort
@@ -155,8 +204,11 @@ func New(ctx context.Context) (*Server, error) {
 		}
 		s.udpAddress = addr
 	}
-	// TCP listening address
+	// TCP listening address, same port as UDP unless a TCP port is configured
 	if s.enableTCPListener {
+		if tcpPort := viper.GetString(config.KeyStatsdTCPPort); tcpPort != "" {
+			address = net.JoinHostPort(addr, tcpPort)
+		}
 		addr, err := net.ResolveTCPAddr("tcp", address)
 		if err != nil {
 			return nil, errors.Wrapf(err, "resolving TCP address '%s'", address)
@@ -181,7 +233,7 @@ func (s *Server) Start() error {
 		return errors.Wrap(err, "starting TCP listener")
 	}
 
-	packetCh := make(chan []byte, packetQueueSize)
+	packetCh := make(chan []byte, s.queueSize)
 
 	if s.enableUDPListener && s.udpListener != nil {
 		s.group.Go(func() error {
@@ -218,7 +270,9 @@ func (s *Server) Start() error {
 		}
 	}()
 
-	return s.group.Wait()
+	err := s.group.Wait()
+	s.saveHostCounters()
+	return err
 }
 
 // Flush *host* metrics only
@@ -235,7 +289,22 @@ func (s *Server) Flush() *cgm.Metrics {
 		return &cgm.Metrics{}
 	}
 
-	return s.hostMetrics.FlushMetrics()
+	conflicts := s.hostWindow.Reset()
+	dropped := s.hostPending.Reset()
+	if s.counters != nil {
+		s.hostCountersmu.Lock()
+		s.hostCounters = make(map[string]uint64, len(s.hostCounters))
+		s.hostCountersmu.Unlock()
+	}
+	m := s.hostMetrics.FlushMetrics()
+	if s.hostWindow.Policy() == merge.Reject {
+		(*m)[tags.MetricNameWithStreamTags(merge.ConflictMetric, tags.FromList(s.baseTags))] = cgm.Metric{Type: "L", Value: conflicts}
+	}
+	if s.hostPending != nil {
+		(*m)[tags.MetricNameWithStreamTags(pending.DroppedMetric, tags.FromList(s.baseTags))] = cgm.Metric{Type: "L", Value: dropped}
+	}
+
+	return m
 }
 
 // startUDP the StatsD UDP listener
@@ -250,6 +319,7 @@ func (s *Server) startUDP() error {
 	if err != nil {
 		return errors.Wrap(err, "starting statsd udp listener")
 	}
+	s.logger.Info().Str("listen", s.udpAddress.String()).Str("family", config.AddressFamily(s.udpAddress.IP)).Msg("UDP listener")
 	s.udpListener = l
 	return nil
 }
@@ -266,6 +336,7 @@ func (s *Server) startTCP() error {
 	if err != nil {
 		return errors.Wrap(err, "starting statsd tcp listener")
 	}
+	s.logger.Info().Str("listen", s.tcpAddress.String()).Str("family", config.AddressFamily(s.tcpAddress.IP)).Msg("TCP listener")
 	s.tcpListener = l
 	return nil
 }
@@ -303,12 +374,56 @@ func (s *Server) initHostMetrics() error {
 	return nil
 }
 
+// restoreHostCounters adds the host counters saved in the counter state to the host metrics
+func (s *Server) restoreHostCounters() {
+	if s.counters == nil {
+		return
+	}
+
+	s.hostCountersmu.Lock()
+	defer s.hostCountersmu.Unlock()
+
+	s.hostCounters = s.counters.TakeStatsd()
+	if s.hostCounters == nil {
+		s.hostCounters = make(map[string]uint64)
+	}
+	for name, v := range s.hostCounters {
+		s.hostMetrics.IncrementByValue(name, v)
+	}
+	if len(s.hostCounters) > 0 {
+		s.logger.Info().Int("counters", len(s.hostCounters)).Msg("restored host counters")
+	}
+}
+
+// saveHostCounters records the host counters not yet flushed in the counter state
+func (s *Server) saveHostCounters() {
+	if s.counters == nil {
+		return
+	}
+
+	s.hostCountersmu.Lock()
+	s.counters.SetStatsd(s.hostCounters)
+	s.hostCountersmu.Unlock()
+}
+
+// addHostCounter tracks a host counter increment, to be saved when the agent stops
+func (s *Server) addHostCounter(metricName string, metricTags cgm.Tags, v uint64) {
+	if s.counters == nil {
+		return
+	}
+
+	s.hostCountersmu.Lock()
+	s.hostCounters[tags.MetricNameWithStreamTags(metricName, metricTags)] += v
+	s.hostCountersmu.Unlock()
+}
+
 // initGroupMetrics initializes the group metric circonus-gometrics instance
 // NOTE: Group metrics are sent directly to circonus, to an existing HTTPTRAP
 //       check created manually or by cosi - the group check is intended to be
-//       used by multiple systems.
+//       used by multiple systems. With group create, the check is found (or
+//       created) by its target, the agents in a group share the target.
 func (s *Server) initGroupMetrics() error {
-	if s.groupCID == "" {
+	if s.groupCID == "" && !s.groupCreate {
 		s.logger.Info().Msg("group check disabled")
 		return nil
 	}
@@ -323,7 +438,18 @@ func (s *Server) initGroupMetrics() error {
 	cmc.CheckManager.API.TokenKey = s.apiKey
 	cmc.CheckManager.API.TokenApp = s.apiApp
 	cmc.CheckManager.API.URL = s.apiURL
-	cmc.CheckManager.Check.ID = s.groupCID
+	if s.groupCID != "" {
+		cmc.CheckManager.Check.ID = s.groupCID
+	} else {
+		cmc.CheckManager.Check.InstanceID = s.groupTarget
+		cmc.CheckManager.Check.TargetHost = s.groupTarget
+		cmc.CheckManager.Check.DisplayName = s.groupTarget + " /statsd-group"
+		cmc.CheckManager.Check.SearchTag = groupSearchTag
+		cmc.CheckManager.Check.Tags = s.groupTags
+		if broker := strings.ToLower(s.groupBroker); broker != "" && broker != "select" {
+			cmc.CheckManager.Broker.ID = strings.Replace(broker, "/broker/", "", 1)
+		}
+	}
 
 	if s.apiCAFile != "" {
 		cert, err := ioutil.ReadFile(s.apiCAFile)
@@ -351,7 +477,7 @@ func (s *Server) initGroupMetrics() error {
 }
 
 // udpReader reads packets from the statsd udp listener, adds packets recevied to the queue
-func (s *Server) udpReader(packetCh chan<- []byte) error {
+func (s *Server) udpReader(packetCh chan []byte) error {
 	for {
 		if s.done() {
 			return nil
@@ -366,13 +492,13 @@ func (s *Server) udpReader(packetCh chan<- []byte) error {
 			_ = appstats.IncrementInt("statsd_packets_total")
 			pkt := make([]byte, n)
 			copy(pkt, buff[:n])
-			packetCh <- pkt
+			s.enqueue(packetCh, pkt)
 		}
 	}
 }
 
 // tcpHandler reads packets from the statsd tcp listener, adds packets recevied to the queue
-func (s *Server) tcpHandler(packetCh chan<- []byte) error {
+func (s *Server) tcpHandler(packetCh chan []byte) error {
 	for {
 		if s.done() {
 			return nil
@@ -383,7 +509,7 @@ func (s *Server) tcpHandler(packetCh chan<- []byte) error {
 			continue
 		}
 		s.Lock()
-		if uint(len(s.tcpConnections)) > s.tcpMaxConnections {
+		if uint(len(s.tcpConnections)) >= s.tcpMaxConnections {
 			s.tcpRefuseConnection(conn)
 			s.Unlock()
 			continue
@@ -391,9 +517,6 @@ func (s *Server) tcpHandler(packetCh chan<- []byte) error {
 		s.Unlock()
 		s.tcpAddConnection(conn)
 		go func(conn *net.TCPConn) {
-			if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
-				s.logger.Warn().Err(err).Msg("setting statsd tcp connection deadline")
-			}
 			if err := s.tcpReader(conn, packetCh); err != nil {
 				s.logger.Warn().Err(err).Msg("handling tcp connection")
 			}
@@ -401,8 +524,11 @@ func (s *Server) tcpHandler(packetCh chan<- []byte) error {
 	}
 }
 
-// tcpReader reads packets from the statsd tcp listener, adds packets recevied to the queue
-func (s *Server) tcpReader(conn *net.TCPConn, packetCh chan<- []byte) error {
+// tcpReader reads the metrics sent on a statsd tcp connection, adds each line
+// (newline framing) or frame (length framing) received to the queue. The
+// connection is closed when the client closes it, sends an invalid frame or
+// is idle for longer than the read timeout.
+func (s *Server) tcpReader(conn *net.TCPConn, packetCh chan []byte) error {
 	addr := conn.RemoteAddr().String()
 	defer func() {
 		s.logger.Debug().Str("remote", addr).Msg("closing statsd tcp connection")
@@ -410,30 +536,92 @@ func (s *Server) tcpReader(conn *net.TCPConn, packetCh chan<- []byte) error {
 		s.tcpRemoveConnection(addr)
 	}()
 
+	r := bufio.NewReaderSize(conn, maxTCPFrameSize)
 	for {
 		if s.done() {
 			return nil
 		}
-		scanner := bufio.NewScanner(conn)
-		for scanner.Scan() {
+		if s.tcpReadTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(s.tcpReadTimeout)); err != nil {
+				return errors.Wrap(err, "setting statsd tcp read deadline")
+			}
+		}
+		pkt, err := readTCPFrame(r, s.tcpFraming)
+		if len(pkt) > 0 {
 			_ = appstats.IncrementInt("statsd_packets_total")
-			packetCh <- scanner.Bytes()
+			s.enqueue(packetCh, pkt)
 		}
-		if s.done() {
-			return nil
+		if err != nil {
+			if err == io.EOF || s.done() {
+				return nil
+			}
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				s.logger.Debug().Str("remote", addr).Str("timeout", s.tcpReadTimeout.String()).Msg("idle statsd tcp connection")
+				return nil
+			}
+			return err
 		}
-		if err := scanner.Err(); err != nil {
-			s.logger.Debug().Err(err).Str("remote", addr).Msg("statsd tcp conn scanner error")
+	}
+}
 
-			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-				s.logger.Debug().Err(nerr).Str("remote", addr).Msg("resetting deadline")
-				if derr := conn.SetDeadline(time.Now().Add(10 * time.Second)); derr != nil {
-					return derr
-				}
-				continue
+// readTCPFrame returns the next line (newline framing, without the line
+// terminator) or frame (length framing, a 4 byte big endian length followed
+// by the metrics) read from a statsd tcp connection. The returned slice is
+// not reused by subsequent reads (it is queued for processing).
+func readTCPFrame(r *bufio.Reader, framing string) ([]byte, error) {
+	if framing == config.StatsdTCPFramingLength {
+		var prefix [4]byte
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, errors.New("truncated statsd tcp frame length")
 			}
+			return nil, err
+		}
+		size := binary.BigEndian.Uint32(prefix[:])
+		if size > maxTCPFrameSize {
+			return nil, errors.Errorf("statsd tcp frame too large (%d>%d)", size, maxTCPFrameSize)
+		}
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				return nil, errors.New("truncated statsd tcp frame")
+			}
+			return nil, err
+		}
+		return frame, nil
+	}
 
-			return err
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errors.Errorf("statsd tcp line too long (>%d)", maxTCPFrameSize)
+	}
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, err
+	}
+	pkt := make([]byte, len(line))
+	copy(pkt, line)
+	return pkt, err
+}
+
+// enqueue adds a packet to the queue, when the queue is full the reader waits
+// for the processor (pushback) or, with the drop-oldest policy, the oldest
+// queued packet is discarded
+func (s *Server) enqueue(packetCh chan []byte, pkt []byte) {
+	if s.queuePolicy != config.StatsdQueueDropOldest {
+		packetCh <- pkt
+		return
+	}
+	for {
+		select {
+		case packetCh <- pkt:
+			return
+		default:
+		}
+		select {
+		case <-packetCh:
+			_ = appstats.IncrementInt("statsd_packets_dropped")
+		default:
 		}
 	}
 }
@@ -505,19 +693,8 @@ func validateStatsdOptions() error {
 		return nil
 	}
 
-	port := viper.GetString(config.KeyStatsdPort)
-	if port == "" {
-		return errors.New("invalid StatsD port (empty)")
-	}
-	if ok, err := regexp.MatchString("^[0-9]+$", port); err != nil {
-		return errors.Wrapf(err, "invalid StatsD port (%s)", port)
-	} else if !ok {
-		return errors.Errorf("invalid StatsD port (%s)", port)
-	}
-	if pnum, err := strconv.ParseUint(port, 10, 32); err != nil {
-		return errors.Wrap(err, "invalid StatsD port")
-	} else if pnum < 1024 || pnum > 65535 {
-		return errors.Errorf("invalid StatsD port 1024>%s<65535", port)
+	if err := validatePort("port", viper.GetString(config.KeyStatsdPort)); err != nil {
+		return err
 	}
 
 	// can be empty (all metrics go to host)
@@ -529,26 +706,83 @@ func validateStatsdOptions() error {
 		return errors.New("invalid StatsD host category (empty)")
 	}
 
-	groupCID := viper.GetString(config.KeyStatsdGroupCID)
-	if groupCID == "" {
-		return nil // statsd group check support disabled, all metrics go to host
+	if viper.GetUint(config.KeyStatsdQueueSize) == 0 {
+		viper.Set(config.KeyStatsdQueueSize, defaults.StatsdQueueSize)
 	}
 
-	if groupCID == "cosi" {
-		cid, err := config.LoadCosiCheckID("group")
-		if err != nil {
-			return err
+	queuePolicy := viper.GetString(config.KeyStatsdQueuePolicy)
+	if queuePolicy == "" {
+		viper.Set(config.KeyStatsdQueuePolicy, defaults.StatsdQueuePolicy)
+	} else if !config.IsValidStatsdQueuePolicy(queuePolicy) {
+		return errors.Errorf("invalid StatsD queue policy (%s)", queuePolicy)
+	}
+
+	if viper.GetBool(config.KeyStatsdEnableTCP) {
+		if tcpPort := viper.GetString(config.KeyStatsdTCPPort); tcpPort != "" {
+			if err := validatePort("TCP port", tcpPort); err != nil {
+				return err
+			}
+		}
+
+		framing := viper.GetString(config.KeyStatsdTCPFraming)
+		if framing == "" {
+			viper.Set(config.KeyStatsdTCPFraming, defaults.StatsdTCPFraming)
+		} else if !config.IsValidStatsdTCPFraming(framing) {
+			return errors.Errorf("invalid StatsD TCP framing (%s)", framing)
+		}
+
+		if timeout := viper.GetString(config.KeyStatsdTCPReadTimeout); timeout != "" {
+			d, err := time.ParseDuration(timeout)
+			if err != nil {
+				return errors.Wrap(err, "invalid StatsD TCP read timeout")
+			}
+			if d < 0 {
+				return errors.Errorf("invalid StatsD TCP read timeout (%s)", timeout)
+			}
+		}
+
+		if viper.GetUint(config.KeyStatsdMaxTCPConns) == 0 {
+			return errors.New("invalid StatsD max TCP connections (0)")
 		}
-		groupCID = cid
-		viper.Set(config.KeyStatsdGroupCID, groupCID)
 	}
 
-	ok, err := config.IsValidCheckID(groupCID)
-	if err != nil {
-		return errors.Wrap(err, "validating StatsD Group Check ID")
+	groupCID := viper.GetString(config.KeyStatsdGroupCID)
+	groupCreate := viper.GetBool(config.KeyStatsdGroupCreate)
+	if groupCID == "" && !groupCreate {
+		return nil // statsd group check support disabled, all metrics go to host
 	}
-	if !ok {
-		return errors.Errorf("invalid StatsD Group Check ID (%s)", groupCID)
+
+	if groupCID != "" && groupCreate {
+		return errors.New("use --statsd-group-create OR --statsd-group-cid, they are mutually exclusive")
+	}
+
+	if groupCreate {
+		if viper.GetString(config.KeyStatsdGroupTarget) == "" {
+			return errors.New("invalid StatsD group target (empty)")
+		}
+		broker := viper.GetString(config.KeyStatsdGroupBroker)
+		if broker != "" && strings.ToLower(broker) != "select" {
+			if ok, _ := regexp.MatchString(`^(/broker/)?[0-9]+$`, broker); !ok {
+				return errors.Errorf("invalid StatsD group broker (%s)", broker)
+			}
+		}
+	} else {
+		if groupCID == "cosi" {
+			cid, err := config.LoadCosiCheckID("group")
+			if err != nil {
+				return err
+			}
+			groupCID = cid
+			viper.Set(config.KeyStatsdGroupCID, groupCID)
+		}
+
+		ok, err := config.IsValidCheckID(groupCID)
+		if err != nil {
+			return errors.Wrap(err, "validating StatsD Group Check ID")
+		}
+		if !ok {
+			return errors.Errorf("invalid StatsD Group Check ID (%s)", groupCID)
+		}
 	}
 
 	groupPrefix := viper.GetString(config.KeyStatsdGroupPrefix)
@@ -592,3 +826,21 @@ func validateStatsdOptions() error {
 
 	return nil
 }
+
+// validatePort verifies a statsd listener port (what identifies the setting in errors)
+func validatePort(what, port string) error {
+	if port == "" {
+		return errors.Errorf("invalid StatsD %s (empty)", what)
+	}
+	if ok, err := regexp.MatchString("^[0-9]+$", port); err != nil {
+		return errors.Wrapf(err, "invalid StatsD %s (%s)", what, port)
+	} else if !ok {
+		return errors.Errorf("invalid StatsD %s (%s)", what, port)
+	}
+	if pnum, err := strconv.ParseUint(port, 10, 32); err != nil {
+		return errors.Wrapf(err, "invalid StatsD %s", what)
+	} else if pnum < 1024 || pnum > 65535 {
+		return errors.Errorf("invalid StatsD %s 1024>%s<65535", what, port)
+	}
+	return nil
+}
@@ -6,8 +6,13 @@
 package statsd
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
+	"io"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -26,7 +31,7 @@ func TestNew(t *testing.T) {
 	t.Log("Disabled")
 	{
 		viper.Set(config.KeyStatsdDisabled, true)
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -40,7 +45,7 @@ func TestNew(t *testing.T) {
 	{
 		viper.Set(config.KeyStatsdDisabled, false)
 		expect := errors.New("invalid StatsD port (empty)")
-		_, err := New(context.Background())
+		_, err := New(context.Background(), nil)
 		if err == nil {
 			t.Fatal("expect error")
 		}
@@ -55,7 +60,7 @@ func TestNew(t *testing.T) {
 		viper.Set(config.KeyStatsdDisabled, false)
 		viper.Set(config.KeyStatsdPort, "65125")
 		expect := errors.New("invalid StatsD host category (empty)")
-		_, err := New(context.Background())
+		_, err := New(context.Background(), nil)
 		if err == nil {
 			t.Fatal("expect error")
 		}
@@ -70,7 +75,7 @@ func TestNew(t *testing.T) {
 		viper.Set(config.KeyStatsdDisabled, false)
 		viper.Set(config.KeyStatsdPort, "65125")
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
-		_, err := New(context.Background())
+		_, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -86,7 +91,7 @@ func TestStart(t *testing.T) {
 	t.Log("Disabled")
 	{
 		viper.Set(config.KeyStatsdDisabled, true)
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -103,7 +108,7 @@ func TestStart(t *testing.T) {
 		viper.Set(config.KeyStatsdPort, "65125")
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
 		ctx, cancel := context.WithCancel(context.Background())
-		s, err := New(ctx)
+		s, err := New(ctx, nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -129,7 +134,7 @@ func TestFlush(t *testing.T) {
 	t.Log("Flush (disabled)")
 	{
 		viper.Set(config.KeyStatsdDisabled, true)
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -146,7 +151,7 @@ func TestFlush(t *testing.T) {
 		viper.Set(config.KeyStatsdDisabled, false)
 		viper.Set(config.KeyStatsdPort, "65125")
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -165,7 +170,7 @@ func TestFlush(t *testing.T) {
 		viper.Set(config.KeyStatsdDisabled, false)
 		viper.Set(config.KeyStatsdPort, "65125")
 		viper.Set(config.KeyStatsdHostCategory, defaults.StatsdHostCategory)
-		s, err := New(context.Background())
+		s, err := New(context.Background(), nil)
 		if err != nil {
 			t.Fatalf("expected NO error, got (%s)", err)
 		}
@@ -270,6 +275,79 @@ func TestValidateStatsdOptions(t *testing.T) {
 
 	viper.Set(config.KeyStatsdHostCategory, "statsd")
 
+	t.Log("Queue policy (default)")
+	{
+		viper.Set(config.KeyStatsdQueuePolicy, "")
+		viper.Set(config.KeyStatsdQueueSize, 0)
+		if err := validateStatsdOptions(); err != nil {
+			t.Fatalf("Expected NO error, got (%v)", err)
+		}
+		if p := viper.GetString(config.KeyStatsdQueuePolicy); p != defaults.StatsdQueuePolicy {
+			t.Fatalf("Expected %s, got %s", defaults.StatsdQueuePolicy, p)
+		}
+		if n := viper.GetUint(config.KeyStatsdQueueSize); n != defaults.StatsdQueueSize {
+			t.Fatalf("Expected %d, got %d", defaults.StatsdQueueSize, n)
+		}
+	}
+
+	t.Log("Queue policy (invalid)")
+	{
+		viper.Set(config.KeyStatsdQueuePolicy, "drop-newest")
+
+		expectedErr := errors.New("invalid StatsD queue policy (drop-newest)")
+		err := validateStatsdOptions()
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if err.Error() != expectedErr.Error() {
+			t.Errorf("Expected (%s) got (%s)", expectedErr, err)
+		}
+	}
+
+	viper.Set(config.KeyStatsdQueuePolicy, config.StatsdQueueDropOldest)
+
+	viper.Set(config.KeyStatsdEnableTCP, true)
+	viper.Set(config.KeyStatsdMaxTCPConns, defaults.StatsdMaxTCPConns)
+
+	t.Log("TCP framing (default)")
+	{
+		viper.Set(config.KeyStatsdTCPFraming, "")
+		if err := validateStatsdOptions(); err != nil {
+			t.Fatalf("Expected NO error, got (%v)", err)
+		}
+		if f := viper.GetString(config.KeyStatsdTCPFraming); f != defaults.StatsdTCPFraming {
+			t.Fatalf("Expected %s, got %s", defaults.StatsdTCPFraming, f)
+		}
+	}
+
+	tcpTests := []struct {
+		name   string
+		key    string
+		value  interface{}
+		expect string
+	}{
+		{"TCP port (invalid, out of range)", config.KeyStatsdTCPPort, "80", "invalid StatsD TCP port 1024>80<65535"},
+		{"TCP framing (invalid)", config.KeyStatsdTCPFraming, "crlf", "invalid StatsD TCP framing (crlf)"},
+		{"TCP read timeout (invalid)", config.KeyStatsdTCPReadTimeout, "1 minute", `invalid StatsD TCP read timeout: time: unknown unit " minute" in duration "1 minute"`},
+		{"TCP read timeout (negative)", config.KeyStatsdTCPReadTimeout, "-1s", "invalid StatsD TCP read timeout (-1s)"},
+		{"TCP max connections (invalid, 0)", config.KeyStatsdMaxTCPConns, 0, "invalid StatsD max TCP connections (0)"},
+	}
+	for _, tst := range tcpTests {
+		t.Log(tst.name)
+		prev := viper.Get(tst.key)
+		viper.Set(tst.key, tst.value)
+		err := validateStatsdOptions()
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Errorf("Expected (%s) got (%s)", tst.expect, err)
+		}
+		viper.Set(tst.key, prev)
+	}
+
+	viper.Set(config.KeyStatsdEnableTCP, false)
+
 	t.Log("Group CID, OK - none")
 	{
 		viper.Set(config.KeyStatsdGroupCID, "")
@@ -279,6 +357,37 @@ func TestValidateStatsdOptions(t *testing.T) {
 		}
 	}
 
+	groupCreateTests := []struct {
+		name   string
+		cid    string
+		target string
+		broker string
+		expect string
+	}{
+		{"Group create (with group CID)", "123", defaults.StatsdGroupTarget, "", "use --statsd-group-create OR --statsd-group-cid, they are mutually exclusive"},
+		{"Group create (invalid, empty target)", "", "", "", "invalid StatsD group target (empty)"},
+		{"Group create (invalid broker)", "", defaults.StatsdGroupTarget, "abc", "invalid StatsD group broker (abc)"},
+		{"Group create, valid - select", "", defaults.StatsdGroupTarget, "select", "invalid StatsD host/group prefix (both empty)"},
+		{"Group create, valid - /broker/35", "", "web", "/broker/35", "invalid StatsD host/group prefix (both empty)"},
+	}
+	for _, tst := range groupCreateTests {
+		t.Log(tst.name)
+		viper.Set(config.KeyStatsdGroupCreate, true)
+		viper.Set(config.KeyStatsdGroupCID, tst.cid)
+		viper.Set(config.KeyStatsdGroupTarget, tst.target)
+		viper.Set(config.KeyStatsdGroupBroker, tst.broker)
+		err := validateStatsdOptions()
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Errorf("Expected (%s) got (%s)", tst.expect, err)
+		}
+	}
+
+	viper.Set(config.KeyStatsdGroupCreate, false)
+	viper.Set(config.KeyStatsdGroupBroker, "")
+
 	t.Log("Group CID (cosi, no cfg)")
 	{
 		viper.Set(config.KeyStatsdGroupCID, "cosi")
@@ -513,3 +622,198 @@ func TestValidateStatsdOptions(t *testing.T) {
 
 	viper.Reset()
 }
+
+func TestEnqueue(t *testing.T) {
+	t.Log("Testing enqueue")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tdrop-oldest")
+	{
+		s := &Server{queuePolicy: config.StatsdQueueDropOldest}
+		packetCh := make(chan []byte, 2)
+		for _, pkt := range []string{"a:1|c", "b:1|c", "c:1|c"} {
+			s.enqueue(packetCh, []byte(pkt))
+		}
+		if len(packetCh) != 2 {
+			t.Fatalf("expected 2 queued, got %d", len(packetCh))
+		}
+		if pkt := string(<-packetCh); pkt != "b:1|c" {
+			t.Fatalf("expected oldest dropped, got %s", pkt)
+		}
+		if pkt := string(<-packetCh); pkt != "c:1|c" {
+			t.Fatalf("expected c:1|c, got %s", pkt)
+		}
+	}
+
+	t.Log("\tpushback")
+	{
+		s := &Server{queuePolicy: config.StatsdQueuePushback}
+		packetCh := make(chan []byte, 1)
+		s.enqueue(packetCh, []byte("a:1|c"))
+		done := make(chan struct{})
+		go func() {
+			s.enqueue(packetCh, []byte("b:1|c"))
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatal("expected enqueue to wait for queue space")
+		case <-time.After(50 * time.Millisecond):
+		}
+		if pkt := string(<-packetCh); pkt != "a:1|c" {
+			t.Fatalf("expected a:1|c, got %s", pkt)
+		}
+		<-done
+		if pkt := string(<-packetCh); pkt != "b:1|c" {
+			t.Fatalf("expected b:1|c, got %s", pkt)
+		}
+	}
+}
+
+func TestReadTCPFrame(t *testing.T) {
+	t.Log("Testing readTCPFrame")
+
+	t.Log("\tnewline")
+	{
+		r := bufio.NewReader(strings.NewReader("a:1|c\r\n\nb:2|g\nc:3|ms"))
+		expect := []string{"a:1|c", "", "b:2|g", "c:3|ms"}
+		for i, e := range expect {
+			pkt, err := readTCPFrame(r, config.StatsdTCPFramingNewline)
+			if i < len(expect)-1 && err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if string(pkt) != e {
+				t.Fatalf("expected (%s) got (%s)", e, string(pkt))
+			}
+		}
+		if _, err := readTCPFrame(r, config.StatsdTCPFramingNewline); err != io.EOF {
+			t.Fatalf("expected EOF, got (%v)", err)
+		}
+	}
+
+	t.Log("\tnewline, line too long")
+	{
+		r := bufio.NewReaderSize(strings.NewReader(strings.Repeat("x", maxTCPFrameSize+1)), maxTCPFrameSize)
+		if _, err := readTCPFrame(r, config.StatsdTCPFramingNewline); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	frame := func(data string) []byte {
+		b := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(b, uint32(len(data)))
+		return append(b, data...)
+	}
+
+	t.Log("\tlength")
+	{
+		var buf bytes.Buffer
+		buf.Write(frame("a:1|c\nb:2|g"))
+		buf.Write(frame("c:3|ms"))
+		r := bufio.NewReader(&buf)
+		for _, e := range []string{"a:1|c\nb:2|g", "c:3|ms"} {
+			pkt, err := readTCPFrame(r, config.StatsdTCPFramingLength)
+			if err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+			if string(pkt) != e {
+				t.Fatalf("expected (%s) got (%s)", e, string(pkt))
+			}
+		}
+		if _, err := readTCPFrame(r, config.StatsdTCPFramingLength); err != io.EOF {
+			t.Fatalf("expected EOF, got (%v)", err)
+		}
+	}
+
+	tt := []struct {
+		name   string
+		data   []byte
+		expect string
+	}{
+		{"truncated length", []byte{0, 0}, "truncated statsd tcp frame length"},
+		{"truncated frame", frame("a:1|c")[:7], "truncated statsd tcp frame"},
+		{"too large", []byte{0, 1, 0, 1}, "statsd tcp frame too large (65537>65536)"},
+	}
+	for _, tst := range tt {
+		t.Logf("\tlength, %s", tst.name)
+		_, err := readTCPFrame(bufio.NewReader(bytes.NewReader(tst.data)), config.StatsdTCPFramingLength)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if err.Error() != tst.expect {
+			t.Fatalf("expected (%s) got (%s)", tst.expect, err)
+		}
+	}
+}
+
+func TestTCPReader(t *testing.T) {
+	t.Log("Testing tcpReader")
+
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	defer l.Close()
+
+	s := &Server{
+		groupCtx:       context.Background(),
+		tcpConnections: map[string]*net.TCPConn{},
+		tcpFraming:     config.StatsdTCPFramingNewline,
+		tcpReadTimeout: 100 * time.Millisecond,
+	}
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	defer client.Close()
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		t.Fatalf("expected NO error, got (%s)", err)
+	}
+	s.tcpAddConnection(conn)
+
+	packetCh := make(chan []byte, 10)
+	done := make(chan error)
+	go func() {
+		done <- s.tcpReader(conn, packetCh)
+	}()
+
+	t.Log("\tmetrics")
+	{
+		if _, err := client.Write([]byte("a:1|c\nb:2|g\n")); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		for _, e := range []string{"a:1|c", "b:2|g"} {
+			select {
+			case pkt := <-packetCh:
+				if string(pkt) != e {
+					t.Fatalf("expected (%s) got (%s)", e, string(pkt))
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected (%s)", e)
+			}
+		}
+	}
+
+	t.Log("\tidle connection closed")
+	{
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("expected NO error, got (%s)", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected idle connection to be closed")
+		}
+		s.Lock()
+		n := len(s.tcpConnections)
+		s.Unlock()
+		if n != 0 {
+			t.Fatalf("expected connection removed, got %d", n)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package tags
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxPooledTags tag slices which grew beyond this are not returned to the pool
+const maxPooledTags = MAX_TAGS
+
+var (
+	tagsPool    = sync.Pool{New: func() interface{} { t := make(Tags, 0, 16); return &t }}
+	nameBufPool = sync.Pool{New: func() interface{} { b := make([]byte, 0, 512); return &b }}
+)
+
+// GetTags returns an empty tag slice from the pool, for building the tag list
+// of a metric, return it with PutTags once the metric name has been built
+func GetTags() *Tags {
+	t := tagsPool.Get().(*Tags)
+	*t = (*t)[:0]
+	return t
+}
+
+// PutTags returns a tag slice to the pool, it must not be used afterwards
+func PutTags(t *Tags) {
+	if t == nil || cap(*t) > maxPooledTags {
+		return
+	}
+	// release references to tag strings
+	for i := range *t {
+		(*t)[i] = Tag{}
+	}
+	tagsPool.Put(t)
+}
+
+// appendStreamTags appends the stream tag encoding of an encoded tag list
+// (see EncodeMetricTags) to dst
+func appendStreamTags(dst []byte, tagList []string) []byte {
+	wrote := false
+	for i, tag := range tagList {
+		if i >= MAX_TAGS {
+			log.Warn().Int("num", len(tagList)).Int("max", MAX_TAGS).Strs("tags", tagList).Msg("ignoring tags over max")
+			break
+		}
+		idx := strings.Index(tag, Delimiter)
+		if idx == -1 {
+			log.Warn().Str("tag", tag).Msg("invalid tag format, ignoring")
+			continue // invalid tag, skip it
+		}
+		if wrote {
+			dst = append(dst, ',')
+		}
+		wrote = true
+		dst = appendEncoded(dst, tag[:idx])
+		dst = append(dst, ':')
+		dst = appendEncoded(dst, tag[idx+1:])
+	}
+	return dst
+}
+
+// appendEncoded appends `b"<base64 s>"` to dst, unless s has already been
+// base64 encoded and formatted
+func appendEncoded(dst []byte, s string) []byte {
+	const encodedSig = `b"`
+	if strings.HasPrefix(s, encodedSig) {
+		return append(dst, s...)
+	}
+	dst = append(dst, encodedSig...)
+	n := base64.StdEncoding.EncodedLen(len(s))
+	start := len(dst)
+	for cap(dst)-start < n {
+		dst = append(dst[:cap(dst)], 0)
+	}
+	dst = dst[:start+n]
+	base64.StdEncoding.Encode(dst[start:], []byte(s))
+	return append(dst, '"')
+}
@@ -0,0 +1,94 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package tags
+
+import (
+	"testing"
+)
+
+func TestMetricNameWithStreamTags(t *testing.T) {
+	t.Log("Testing MetricNameWithStreamTags")
+
+	tt := []struct {
+		name   string
+		metric string
+		tags   Tags
+		expect string
+	}{
+		{"no tags", "foo", Tags{}, "foo"},
+		{"one tag", "foo", Tags{{Category: "c1", Value: "v1"}}, `foo|ST[b"YzE=":b"djE="]`},
+		{"sorted, lower, dedup", "foo", Tags{{Category: "C2", Value: "v 2"}, {Category: "c1", Value: "v1"}, {Category: "c1", Value: "v1"}}, `foo|ST[b"YzE=":b"djE=",b"YzI=":b"djI="]`},
+		{"existing stream tags", "foo|ST[a:b]", Tags{{Category: "c1", Value: "v1"}}, "foo|ST[a:b]"},
+		{"invalid tag", "foo", Tags{{Category: "c1", Value: ""}}, "foo"},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s", tst.name)
+		result := MetricNameWithStreamTags(tst.metric, tst.tags)
+		if result != tst.expect {
+			t.Fatalf("expected (%s) got (%s)", tst.expect, result)
+		}
+	}
+}
+
+func TestAppendStreamTags(t *testing.T) {
+	t.Log("Testing appendStreamTags")
+
+	tt := []struct {
+		name    string
+		tagList []string
+		expect  string
+	}{
+		{"one tag", []string{"c1:v1"}, `b"YzE=":b"djE="`},
+		{"two tags", []string{"c1:v1", "c2:v2"}, `b"YzE=":b"djE=",b"YzI=":b"djI="`},
+		{"first tag invalid", []string{"invalid", "c1:v1"}, `b"YzE=":b"djE="`},
+		{"middle tag invalid", []string{"c1:v1", "invalid", "c2:v2"}, `b"YzE=":b"djE=",b"YzI=":b"djI="`},
+		{"all tags invalid", []string{"invalid"}, ""},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s", tst.name)
+		result := string(appendStreamTags(nil, tst.tagList))
+		if result != tst.expect {
+			t.Fatalf("expected (%s) got (%s)", tst.expect, result)
+		}
+	}
+}
+
+func TestGetPutTags(t *testing.T) {
+	t.Log("Testing GetTags/PutTags")
+
+	tl := GetTags()
+	if len(*tl) != 0 {
+		t.Fatalf("expected empty tags, got %v", *tl)
+	}
+	*tl = append(*tl, Tag{Category: "c1", Value: "v1"})
+	PutTags(tl)
+
+	tl = GetTags()
+	if len(*tl) != 0 {
+		t.Fatalf("expected empty tags, got %v", *tl)
+	}
+	PutTags(tl)
+
+	PutTags(nil) // no panic
+}
+
+func BenchmarkMetricNameWithStreamTags(b *testing.B) {
+	mtags := Tags{
+		{Category: "source", Value: "circonus-agent"},
+		{Category: "collector", Value: "disk"},
+		{Category: "units", Value: "bytes"},
+		{Category: "device", Value: "sda"},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tl := GetTags()
+		*tl = append(*tl, mtags...)
+		_ = MetricNameWithStreamTags("read_bytes", *tl)
+		PutTags(tl)
+	}
+}
@@ -7,7 +7,6 @@ package tags
 
 import (
 	"encoding/base64"
-	"fmt"
 	"regexp"
 	"sort"
 	"strings"
@@ -38,9 +37,10 @@ type TaggedMetrics map[string]TaggedMetric
 
 // JSONMetric defines an individual metric received in JSON
 type JSONMetric struct {
-	Tags  []string    `json:"_tags"`
-	Type  string      `json:"_type"`
-	Value interface{} `json:"_value"`
+	Tags      []string    `json:"_tags"`
+	Type      string      `json:"_type"`
+	Value     interface{} `json:"_value"`
+	Timestamp uint64      `json:"_ts,omitempty"` // optional, milliseconds since epoch
 }
 
 // JSONMetrics holds list of JSON metrics received at /write receiver interface
@@ -169,12 +169,66 @@ func MetricNameWithStreamTags(metric string, tags Tags) string {
 		return metric
 	}
 
-	taglist := EncodeMetricStreamTags(tags)
-	if taglist != "" {
-		return metric + "|ST[" + taglist + "]"
+	tagList := EncodeMetricTags(tags)
+	if len(tagList) == 0 {
+		return metric
+	}
+
+	bp := nameBufPool.Get().(*[]byte)
+	b := append((*bp)[:0], metric...)
+	b = append(b, "|ST["...)
+	b = appendStreamTags(b, tagList)
+	b = append(b, ']')
+	name := string(b)
+	*bp = b[:0]
+	nameBufPool.Put(bp)
+
+	return name
+}
+
+// SplitMetricStreamTags returns the metric name and decoded stream tags of a
+// metric name with stream tags (name|ST[cat:val,...]), tags may be base64
+// encoded (b"...")
+func SplitMetricStreamTags(name string) (string, Tags, bool) {
+	idx := strings.Index(name, "|ST[")
+	if idx == -1 || !strings.HasSuffix(name, "]") {
+		return name, nil, false
+	}
+	base, spec := name[:idx], name[idx+4:len(name)-1]
+
+	tagList := Tags{}
+	for _, tag := range strings.Split(spec, Separator) {
+		parts := strings.SplitN(tag, Delimiter, 2)
+		if len(parts) != 2 {
+			return name, nil, false
+		}
+		category, ok := decodeStreamTag(parts[0])
+		if !ok {
+			return name, nil, false
+		}
+		value, ok := decodeStreamTag(parts[1])
+		if !ok {
+			return name, nil, false
+		}
+		tagList = append(tagList, Tag{Category: category, Value: value})
 	}
 
-	return metric
+	return base, tagList, true
+}
+
+// decodeStreamTag decodes a base64 encoded (b"...") stream tag category or value
+func decodeStreamTag(s string) (string, bool) {
+	if !strings.HasPrefix(s, `b"`) {
+		return s, true
+	}
+	if len(s) < 3 || !strings.HasSuffix(s, `"`) {
+		return "", false
+	}
+	b, err := base64.StdEncoding.DecodeString(s[2 : len(s)-1])
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
 }
 
 // EncodeMetricStreamTags encodes Tags into a string suitable for use with
@@ -190,31 +244,13 @@ func EncodeMetricStreamTags(tags Tags) string {
 		return ""
 	}
 
-	tagList := make([]string, len(tmpTags))
-	for i, tag := range tmpTags {
-		if i >= MAX_TAGS {
-			log.Warn().Int("num", len(tags)).Int("max", MAX_TAGS).Interface("tags", tags).Msg("ignoring tags over max")
-			break
-		}
-		tagParts := strings.SplitN(tag, ":", 2)
-		if len(tagParts) != 2 {
-			log.Warn().Int("num", len(tagParts)).Str("tag", tag).Msg("invalid tag format, ignoring")
-			continue // invalid tag, skip it
-		}
-		encodeFmt := `b"%s"`
-		encodedSig := `b"` // has cat or val been previously (or manually) base64 encoded and formatted
-		tc := tagParts[0]
-		tv := tagParts[1]
-		if !strings.HasPrefix(tc, encodedSig) {
-			tc = fmt.Sprintf(encodeFmt, base64.StdEncoding.EncodeToString([]byte(tc)))
-		}
-		if !strings.HasPrefix(tv, encodedSig) {
-			tv = fmt.Sprintf(encodeFmt, base64.StdEncoding.EncodeToString([]byte(tv)))
-		}
-		tagList[i] = tc + ":" + tv
-	}
+	bp := nameBufPool.Get().(*[]byte)
+	b := appendStreamTags((*bp)[:0], tmpTags)
+	encoded := string(b)
+	*bp = b[:0]
+	nameBufPool.Put(bp)
 
-	return strings.Join(tagList, ",")
+	return encoded
 }
 
 // EncodeMetricTags encodes Tags into an array of strings. The format
@@ -6,6 +6,7 @@
 package tags
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/circonus-labs/circonus-agent/internal/config"
@@ -53,6 +54,39 @@ func TestPrepStreamTags(t *testing.T) {
 	}
 }
 
+func TestSplitMetricStreamTags(t *testing.T) {
+	t.Log("Testing SplitMetricStreamTags")
+
+	tt := []struct {
+		name       string
+		metricName string
+		base       string
+		tags       Tags
+		ok         bool
+	}{
+		{"no tags", "foo", "foo", nil, false},
+		{"tags", "foo|ST[c1:v1,c2:v2]", "foo", Tags{{Category: "c1", Value: "v1"}, {Category: "c2", Value: "v2"}}, true},
+		{"encoded", `foo|ST[b"YSBi":b"djE="]`, "foo", Tags{{Category: "a b", Value: "v1"}}, true},
+		{"invalid tag", "foo|ST[c1]", "foo|ST[c1]", nil, false},
+		{"invalid encoding", `foo|ST[c1:b"!!"]`, `foo|ST[c1:b"!!"]`, nil, false},
+	}
+
+	for _, tst := range tt {
+		t.Logf("\ttest -- %s (%s)", tst.name, tst.metricName)
+
+		base, tags, ok := SplitMetricStreamTags(tst.metricName)
+		if ok != tst.ok {
+			t.Fatalf("expected ok=%v", tst.ok)
+		}
+		if base != tst.base {
+			t.Fatalf("expected (%s) got (%s)", tst.base, base)
+		}
+		if ok && !reflect.DeepEqual(tags, tst.tags) {
+			t.Fatalf("expected (%#v) got (%#v)", tst.tags, tags)
+		}
+	}
+}
+
 func TestGetBaseTags(t *testing.T) {
 	t.Log("Testing GetBaseTags")
 
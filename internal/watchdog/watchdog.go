@@ -0,0 +1,240 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+// Package watchdog samples the agent's goroutine count and detects builtin
+// collector and plugin runs stuck past a multiple of their timeout. Stacks are
+// dumped to the log when a leak is suspected or a run is stuck, the events
+// are counted in the agent's self metrics (/stats) and the subsystem of a
+// stuck run can optionally be restarted.
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	appstats "github.com/maier/go-appstats"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// Builtins subsystem of builtin collector runs
+	Builtins = "builtins"
+	// Plugins subsystem of plugin runs
+	Plugins = "plugins"
+
+	leakSamples   = 10  // goroutine count increasing over this many consecutive samples is a suspected leak
+	leakMinGrowth = 100 // and grew by at least this many goroutines
+)
+
+// run is a tracked builtin collector or plugin run
+type run struct {
+	subsystem string
+	id        string
+	start     time.Time
+	timeout   time.Duration // 0 uses the collector timeout
+	restart   func()
+	stuck     bool // reported as stuck
+}
+
+// runs in progress, tracked whether or not the watchdog is enabled
+var (
+	runs    = make(map[uint64]*run)
+	runSeq  uint64
+	runsMtx sync.Mutex
+)
+
+// Track registers a run of a builtin collector or plugin, call the returned
+// func when the run completes. The run is stuck when it is still running after
+// the stuck factor times timeout (0 uses the watchdog collector timeout).
+// restart, optional, is called to restart a stuck run's subsystem (e.g.
+// cancel the run and stop waiting for it).
+func Track(subsystem, id string, timeout time.Duration, restart func()) func() {
+	runsMtx.Lock()
+	runSeq++
+	seq := runSeq
+	runs[seq] = &run{
+		subsystem: subsystem,
+		id:        id,
+		start:     time.Now(),
+		timeout:   timeout,
+		restart:   restart,
+	}
+	runsMtx.Unlock()
+
+	return func() {
+		runsMtx.Lock()
+		delete(runs, seq)
+		runsMtx.Unlock()
+	}
+}
+
+// Watchdog periodically samples the goroutine count and checks the tracked runs
+type Watchdog struct {
+	ctx              context.Context
+	disabled         bool
+	interval         time.Duration
+	collectorTimeout time.Duration
+	factor           int
+	restart          bool
+	samples          []int // goroutine counts, most recent last
+	logger           zerolog.Logger
+}
+
+// New returns a watchdog, Start is a no-op when the watchdog is not enabled
+func New(ctx context.Context) (*Watchdog, error) {
+	w := &Watchdog{
+		ctx:      ctx,
+		disabled: !viper.GetBool(config.KeyWatchdog),
+		logger:   log.With().Str("pkg", "watchdog").Logger(),
+	}
+
+	if w.disabled {
+		return w, nil
+	}
+
+	interval, err := time.ParseDuration(viper.GetString(config.KeyWatchdogInterval))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing watchdog interval")
+	}
+	w.interval = interval
+
+	timeout, err := time.ParseDuration(viper.GetString(config.KeyWatchdogCollectorTimeout))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing watchdog collector timeout")
+	}
+	w.collectorTimeout = timeout
+
+	w.factor = viper.GetInt(config.KeyWatchdogStuckFactor)
+	if w.factor < 1 {
+		return nil, errors.Errorf("invalid watchdog stuck factor (%d)", w.factor)
+	}
+	w.restart = viper.GetBool(config.KeyWatchdogRestart)
+
+	return w, nil
+}
+
+// Start runs the watchdog checks until the context is done
+func (w *Watchdog) Start() error {
+	if w.disabled {
+		w.logger.Debug().Msg("disabled, not starting")
+		return nil
+	}
+
+	w.logger.Info().
+		Str("interval", w.interval.String()).
+		Str("collector_timeout", w.collectorTimeout.String()).
+		Int("stuck_factor", w.factor).
+		Bool("restart", w.restart).
+		Msg("watchdog starting")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			w.checkGoroutines(runtime.NumGoroutine())
+			w.checkRuns(now)
+		}
+	}
+}
+
+// checkGoroutines records a goroutine count sample, a leak is suspected when
+// the count increased with each of the last leakSamples samples
+func (w *Watchdog) checkGoroutines(n int) {
+	_ = appstats.SetInt("watchdog.goroutines", int64(n))
+
+	w.samples = append(w.samples, n)
+	if len(w.samples) > leakSamples {
+		w.samples = w.samples[len(w.samples)-leakSamples:]
+	}
+	if len(w.samples) < leakSamples {
+		return
+	}
+
+	for i := 1; i < len(w.samples); i++ {
+		if w.samples[i] <= w.samples[i-1] {
+			return
+		}
+	}
+	if n-w.samples[0] < leakMinGrowth {
+		return
+	}
+
+	_ = appstats.IncrementInt("watchdog.goroutine_leaks")
+	w.logger.Warn().
+		Ints("samples", w.samples).
+		Str("stacks", stacks()).
+		Msg("goroutine count increasing, suspected leak")
+
+	// the next report requires leakSamples new samples
+	w.samples = w.samples[:0]
+}
+
+// checkRuns reports runs which are stuck (once per run) and restarts their subsystem when enabled
+func (w *Watchdog) checkRuns(now time.Time) {
+	var stuck []*run
+
+	runsMtx.Lock()
+	for _, r := range runs {
+		if r.stuck {
+			continue
+		}
+		timeout := r.timeout
+		if timeout == 0 {
+			timeout = w.collectorTimeout
+		}
+		if now.Sub(r.start) > time.Duration(w.factor)*timeout {
+			r.stuck = true
+			stuck = append(stuck, r)
+		}
+	}
+	runsMtx.Unlock()
+
+	if len(stuck) == 0 {
+		return
+	}
+
+	sort.Slice(stuck, func(i, j int) bool { return stuck[i].start.Before(stuck[j].start) })
+
+	dump := stacks()
+	for _, r := range stuck {
+		_ = appstats.IncrementInt("watchdog.stuck")
+		_ = appstats.IncrementInt("watchdog.stuck." + r.subsystem)
+		w.logger.Error().
+			Str("subsystem", r.subsystem).
+			Str("id", r.id).
+			Str("running", now.Sub(r.start).Round(time.Second).String()).
+			Str("stacks", dump).
+			Msg("run stuck")
+
+		if !w.restart || r.restart == nil {
+			continue
+		}
+		w.logger.Warn().Str("subsystem", r.subsystem).Str("id", r.id).Msg("restarting stuck run")
+		_ = appstats.IncrementInt("watchdog.restarts")
+		r.restart()
+	}
+}
+
+// stacks returns the stacks of all goroutines, identical stacks are grouped
+func stacks() string {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return err.Error()
+	}
+	return buf.String()
+}
@@ -0,0 +1,197 @@
+// Copyright © 2020 Circonus, Inc. <support@circonus.com>
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+
+package watchdog
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-agent/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+func statInt(name string) int64 {
+	v := expvar.Get("stats").(*expvar.Map).Get(name)
+	if v == nil {
+		return 0
+	}
+	return v.(*expvar.Int).Value()
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	t.Log("\tdisabled")
+	{
+		viper.Set(config.KeyWatchdog, false)
+		w, err := New(context.Background())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if err := w.Start(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	viper.Set(config.KeyWatchdog, true)
+	viper.Set(config.KeyWatchdogInterval, "10s")
+	viper.Set(config.KeyWatchdogCollectorTimeout, "1m")
+	viper.Set(config.KeyWatchdogStuckFactor, 2)
+
+	t.Log("\tenabled")
+	{
+		w, err := New(context.Background())
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		if w.interval != 10*time.Second || w.collectorTimeout != time.Minute || w.factor != 2 {
+			t.Fatalf("unexpected settings %#v", w)
+		}
+	}
+
+	t.Log("\tinvalid interval")
+	{
+		viper.Set(config.KeyWatchdogInterval, "ten")
+		if _, err := New(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+		viper.Set(config.KeyWatchdogInterval, "10s")
+	}
+
+	t.Log("\tstart/stop")
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		w, err := New(ctx)
+		if err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+		cancel()
+		if err := w.Start(); err != nil {
+			t.Fatalf("expected NO error, got (%s)", err)
+		}
+	}
+
+	viper.Set(config.KeyWatchdog, false)
+}
+
+func TestCheckRuns(t *testing.T) {
+	t.Log("Testing checkRuns")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	w := &Watchdog{collectorTimeout: time.Minute, factor: 3}
+
+	restarted := 0
+	done := Track(Builtins, "slow", 0, func() { restarted++ })
+	plugDone := Track(Plugins, "plug", 10*time.Second, nil)
+	defer plugDone()
+
+	stuck := statInt("watchdog.stuck")
+	pluginsStuck := statInt("watchdog.stuck." + Plugins)
+	now := time.Now()
+
+	t.Log("\tnot stuck")
+	{
+		w.checkRuns(now.Add(20 * time.Second))
+		if n := statInt("watchdog.stuck"); n != stuck {
+			t.Fatalf("expected %d stuck, got %d", stuck, n)
+		}
+	}
+
+	t.Log("\tplugin stuck (3x 10s)")
+	{
+		w.checkRuns(now.Add(time.Minute))
+		if n := statInt("watchdog.stuck"); n != stuck+1 {
+			t.Fatalf("expected %d stuck, got %d", stuck+1, n)
+		}
+		if n := statInt("watchdog.stuck." + Plugins); n != pluginsStuck+1 {
+			t.Fatalf("expected %d plugins stuck, got %d", pluginsStuck+1, n)
+		}
+	}
+
+	t.Log("\tbuiltin stuck (3x collector timeout), reported once, no restart")
+	{
+		w.checkRuns(now.Add(4 * time.Minute))
+		w.checkRuns(now.Add(5 * time.Minute))
+		if n := statInt("watchdog.stuck"); n != stuck+2 {
+			t.Fatalf("expected %d stuck, got %d", stuck+2, n)
+		}
+		if restarted != 0 {
+			t.Fatal("expected no restart")
+		}
+	}
+
+	done()
+
+	t.Log("\trestart")
+	{
+		w.restart = true
+		done := Track(Builtins, "slow", 0, func() { restarted++ })
+		defer done()
+		w.checkRuns(time.Now().Add(4 * time.Minute))
+		if restarted != 1 {
+			t.Fatalf("expected 1 restart, got %d", restarted)
+		}
+	}
+
+	t.Log("\tcompleted run")
+	{
+		done := Track(Builtins, "fast", 0, func() { restarted++ })
+		done()
+		w.checkRuns(time.Now().Add(time.Hour))
+		if restarted != 1 {
+			t.Fatalf("expected completed run not restarted, got %d restarts", restarted)
+		}
+	}
+}
+
+func TestCheckGoroutines(t *testing.T) {
+	t.Log("Testing checkGoroutines")
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	w := &Watchdog{}
+	leaks := statInt("watchdog.goroutine_leaks")
+
+	t.Log("\tstable")
+	{
+		for i := 0; i < leakSamples*2; i++ {
+			w.checkGoroutines(50 + i%2)
+		}
+		if n := statInt("watchdog.goroutine_leaks"); n != leaks {
+			t.Fatalf("expected %d leaks, got %d", leaks, n)
+		}
+		if n := statInt("watchdog.goroutines"); n != 51 {
+			t.Fatalf("expected 51 goroutines, got %d", n)
+		}
+	}
+
+	t.Log("\tslow growth")
+	{
+		for i := 0; i < leakSamples; i++ {
+			w.checkGoroutines(100 + i)
+		}
+		if n := statInt("watchdog.goroutine_leaks"); n != leaks {
+			t.Fatalf("expected %d leaks, got %d", leaks, n)
+		}
+	}
+
+	t.Log("\tleak")
+	{
+		w := &Watchdog{}
+		for i := 0; i < leakSamples; i++ {
+			w.checkGoroutines(200 + i*50)
+		}
+		if n := statInt("watchdog.goroutine_leaks"); n != leaks+1 {
+			t.Fatalf("expected %d leaks, got %d", leaks+1, n)
+		}
+		if len(w.samples) != 0 {
+			t.Fatalf("expected samples reset, got %v", w.samples)
+		}
+	}
+}